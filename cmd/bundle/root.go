@@ -0,0 +1,230 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/kratos/cmd/cliclient"
+	"github.com/ory/kratos/configbundle"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/cmdx"
+)
+
+const (
+	FlagIncludeSecrets = "include-secrets"
+	FlagOutput         = "output"
+	FlagApply          = "apply"
+)
+
+func NewBundleCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "bundle",
+		Short: "Export and import configuration bundles",
+		Long: `Configuration bundles contain the identity schemas, courier templates, OIDC providers, and feature flags of an environment.
+
+Bundles are signed using "secrets.bundle", so all environments exchanging bundles must share these secrets.`,
+	}
+	cmd.AddCommand(NewExportCmd())
+	cmd.AddCommand(NewImportCmd())
+	cliclient.RegisterClientFlags(cmd.PersistentFlags())
+	return cmd
+}
+
+func NewExportCmd() *cobra.Command {
+	var (
+		includeSecrets bool
+		output         string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export a signed configuration bundle",
+		Example: `To promote the configuration of a staging environment, run:
+
+	{{ .CommandPath }} --endpoint https://kratos-admin.staging.example.org --output bundle.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			url := configbundle.AdminRouteConfigBundle
+			if includeSecrets {
+				url += "?include_secrets=true"
+			}
+
+			body, err := do(cmd, http.MethodGet, url, nil)
+			if err != nil {
+				return err
+			}
+
+			return write(cmd, output, body)
+		},
+	}
+	cmd.Flags().BoolVar(&includeSecrets, FlagIncludeSecrets, false, "Include the client secrets and private keys of OIDC providers. The bundle is signed but not encrypted!")
+	cmd.Flags().StringVarP(&output, FlagOutput, FlagOutput[:1], "", "Write the bundle to this file instead of STD_OUT.")
+	return cmd
+}
+
+func NewImportCmd() *cobra.Command {
+	var output, apply string
+
+	cmd := &cobra.Command{
+		Use:   "import [bundle.json]",
+		Short: "Verify a configuration bundle and apply it to a configuration file",
+		Long: `Verifies the signature of a configuration bundle using the bundle secrets of the target environment.
+
+With --apply, the bundled keys of the given configuration file of the target environment are replaced by the values of the bundle. Other keys are left unchanged, but comments and the formatting of the file are not preserved. Kratos reloads the identity schemas, courier templates, OIDC providers, and feature flags once the file changed.
+
+Without --apply, the contained configuration file is written, which can be loaded in addition to the environment's configuration:
+
+	kratos serve -c kratos.yml -c bundle-config.json
+
+If no bundle file is given, the bundle is read from STD_IN.`,
+		Example: `To import a bundle into the production environment, run:
+
+	{{ .CommandPath }} bundle.json --endpoint https://kratos-admin.example.org --apply /etc/kratos/kratos.yml`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				raw []byte
+				err error
+			)
+			if len(args) == 1 {
+				raw, err = os.ReadFile(args[0])
+			} else {
+				raw, err = io.ReadAll(cmd.InOrStdin())
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not read the bundle: %s\n", err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			body, err := do(cmd, http.MethodPost, configbundle.AdminRouteConfigBundleVerify, raw)
+			if err != nil {
+				return err
+			}
+
+			var b configbundle.Bundle
+			if err := json.Unmarshal(body, &b); err != nil {
+				return errors.WithStack(err)
+			}
+
+			if apply != "" {
+				if err := applyTo(apply, &b); err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not apply the bundle to %s: %s\n", apply, err)
+					return cmdx.FailSilently(cmd)
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Applied the bundle to %s.\n", apply)
+				return nil
+			}
+
+			var out bytes.Buffer
+			if err := json.Indent(&out, b.Config, "", "  "); err != nil {
+				return errors.WithStack(err)
+			}
+			out.WriteString("\n")
+
+			return write(cmd, output, out.Bytes())
+		},
+	}
+	cmd.Flags().StringVarP(&output, FlagOutput, FlagOutput[:1], "", "Write the configuration file to this path instead of STD_OUT.")
+	cmd.Flags().StringVar(&apply, FlagApply, "", "Apply the bundle to this YAML or JSON configuration file of the target environment.")
+	cmd.MarkFlagsMutuallyExclusive(FlagOutput, FlagApply)
+	return cmd
+}
+
+// applyTo replaces the bundled keys of the configuration file at path with the values of the bundle.
+func applyTo(path string, b *configbundle.Bundle) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	isYAML := !strings.EqualFold(filepath.Ext(path), ".json")
+	if isYAML {
+		if raw, err = yaml.YAMLToJSON(raw); err != nil {
+			return errors.WithStack(err)
+		}
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			raw = nil
+		}
+	}
+
+	conf, err := b.ApplyTo(raw)
+	if err != nil {
+		return err
+	}
+
+	if isYAML {
+		if conf, err = yaml.JSONToYAML(conf); err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		var out bytes.Buffer
+		if err := json.Indent(&out, conf, "", "  "); err != nil {
+			return errors.WithStack(err)
+		}
+		out.WriteString("\n")
+		conf = out.Bytes()
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.WriteFile(path, conf, info.Mode().Perm()))
+}
+
+func do(cmd *cobra.Command, method, path string, body []byte) ([]byte, error) {
+	c, err := cliclient.NewClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	conf := c.GetConfig()
+
+	req, err := http.NewRequestWithContext(cmd.Context(), method, conf.Servers[0].URL+x.AdminPrefix+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := conf.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	payload, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "The request failed with status code %d: %s\n", res.StatusCode, payload)
+		return nil, cmdx.FailSilently(cmd)
+	}
+
+	return payload, nil
+}
+
+func write(cmd *cobra.Command, output string, data []byte) error {
+	if output == "" {
+		_, err := cmd.OutOrStdout().Write(data)
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.WriteFile(output, data, 0o600))
+}
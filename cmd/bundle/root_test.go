@@ -0,0 +1,95 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/kratos/cmd/bundle"
+	"github.com/ory/kratos/cmd/cliclient"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/x/cmdx"
+)
+
+func TestBundleCmd(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	_, admin := testhelpers.NewKratosServerWithCSRF(t, reg)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stubs/identity.schema.json")
+	conf.MustSet(ctx, config.ViperKeySecretsBundle, []string{"bundle-secret-0123456789"})
+	conf.MustSet(ctx, config.ViperKeyFeatureFlagFasterSessionExtend, true)
+
+	c := &cmdx.CommandExecuter{
+		New: func() *cobra.Command {
+			return bundle.NewBundleCmd()
+		},
+		PersistentArgs: []string{"--" + cliclient.FlagEndpoint, admin.URL},
+	}
+
+	t.Run("case=exports and imports a bundle", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "bundle.json")
+		c.ExecNoErr(t, "export", "--"+bundle.FlagOutput, out)
+
+		stdOut := c.ExecNoErr(t, "import", out)
+		assert.True(t, gjson.Get(stdOut, "feature_flags.faster_session_extend").Bool(), stdOut)
+		assert.Equal(t, config.DefaultIdentityTraitsSchemaID, gjson.Get(stdOut, "identity.schemas.0.id").String(), stdOut)
+	})
+
+	t.Run("case=imports a bundle from stdin", func(t *testing.T) {
+		signed := c.ExecNoErr(t, "export")
+
+		stdOut, stdErr, err := c.Exec(strings.NewReader(signed), "import")
+		require.NoError(t, err, stdErr)
+		assert.True(t, gjson.Get(stdOut, "feature_flags.faster_session_extend").Bool(), stdOut)
+	})
+
+	t.Run("case=applies a bundle to a configuration file", func(t *testing.T) {
+		bundleFile := filepath.Join(t.TempDir(), "bundle.json")
+		c.ExecNoErr(t, "export", "--"+bundle.FlagOutput, bundleFile)
+
+		for _, tc := range []struct{ name, content string }{
+			{name: "kratos.yml", content: "dsn: memory\nfeature_flags:\n  faster_session_extend: false\n"},
+			{name: "kratos.json", content: `{"dsn":"memory","feature_flags":{"faster_session_extend":false}}`},
+		} {
+			t.Run("file="+tc.name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), tc.name)
+				require.NoError(t, os.WriteFile(path, []byte(tc.content), 0o600))
+
+				c.ExecNoErr(t, "import", bundleFile, "--"+bundle.FlagApply, path)
+
+				raw, err := os.ReadFile(path)
+				require.NoError(t, err)
+				applied, err := yaml.YAMLToJSON(raw)
+				require.NoError(t, err)
+				assert.Equal(t, "memory", gjson.GetBytes(applied, "dsn").String(), "%s", raw)
+				assert.True(t, gjson.GetBytes(applied, "feature_flags.faster_session_extend").Bool(), "%s", raw)
+				assert.Equal(t, config.DefaultIdentityTraitsSchemaID, gjson.GetBytes(applied, "identity.schemas.0.id").String(), "%s", raw)
+			})
+		}
+	})
+
+	t.Run("case=fails to import a modified bundle", func(t *testing.T) {
+		signed, err := sjson.Set(c.ExecNoErr(t, "export"), "bundle.config.feature_flags.faster_session_extend", false)
+		require.NoError(t, err)
+
+		out := filepath.Join(t.TempDir(), "bundle.json")
+		require.NoError(t, os.WriteFile(out, []byte(signed), 0o600))
+
+		stdErr := c.ExecExpectedErr(t, "import", out)
+		assert.Contains(t, stdErr, "The bundle signature is invalid")
+	})
+}
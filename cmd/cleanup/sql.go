@@ -23,6 +23,7 @@ func NewCleanupSQLCmd() *cobra.Command {
 		Use:   "sql <database-url>",
 		Short: "Cleanup sql database from expired flows and sessions",
 		Long: `Run this command as frequently as you need.
+Each table is cleaned up in batches until no expired records are left.
 It is recommended to run this command close to the SQL instance (e.g. same subnet) instead of over the public internet.
 This decreases risk of failure and decreases time required.
 You can read in the database URL using the -e flag, for example:
@@ -44,6 +45,7 @@ Before running this command on an existing database, create a back up!
 	configx.RegisterFlags(c.PersistentFlags())
 	c.Flags().BoolP("read-from-env", "e", true, "If set, reads the database connection string from the environment variable DSN or config file key dsn.")
 	c.Flags().Duration(config.ViperKeyDatabaseCleanupSleepTables, time.Minute, "How long to wait between each table cleanup")
+	c.Flags().Duration(config.ViperKeyDatabaseCleanupSleepBatches, 0, "How long to wait between two batches of the same table")
 	c.Flags().IntP(config.ViperKeyDatabaseCleanupBatchSize, "b", 100, "Set the number of records to be cleaned per run")
 	c.Flags().Duration("keep-last", 0, "Don't remove records younger than")
 	return c
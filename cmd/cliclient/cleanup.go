@@ -27,7 +27,7 @@ func NewCleanupHandler() *CleanupHandler {
 
 func (h *CleanupHandler) CleanupSQL(cmd *cobra.Command, args []string) error {
 	opts := []configx.OptionModifier{
-		configx.WithFlags(cmd.Flags()),
+		config.WithFlags(cmd.Flags()),
 		configx.SkipValidation(),
 	}
 
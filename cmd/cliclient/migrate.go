@@ -4,7 +4,9 @@
 package cliclient
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/ory/x/popx"
 	"github.com/ory/x/servicelocatorx"
@@ -19,6 +21,7 @@ import (
 
 	"github.com/ory/kratos/driver"
 	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/persistence"
 	"github.com/ory/x/cmdx"
 	"github.com/ory/x/flagx"
 )
@@ -37,7 +40,7 @@ func (h *MigrateHandler) getPersister(cmd *cobra.Command, args []string, opts []
 			servicelocatorx.NewOptions(),
 			nil,
 			[]configx.OptionModifier{
-				configx.WithFlags(cmd.Flags()),
+				config.WithFlags(cmd.Flags()),
 				configx.SkipValidation(),
 			})
 		if err != nil {
@@ -60,7 +63,7 @@ func (h *MigrateHandler) getPersister(cmd *cobra.Command, args []string, opts []
 			servicelocatorx.NewOptions(),
 			nil,
 			[]configx.OptionModifier{
-				configx.WithFlags(cmd.Flags()),
+				config.WithFlags(cmd.Flags()),
 				configx.SkipValidation(),
 				configx.WithValue(config.ViperKeyDSN, args[0]),
 			})
@@ -86,17 +89,104 @@ func (h *MigrateHandler) MigrateSQLDown(cmd *cobra.Command, args []string, opts
 }
 
 func (h *MigrateHandler) MigrateSQLStatus(cmd *cobra.Command, args []string, opts ...driver.RegistryOption) error {
-	p, err := h.getPersister(cmd, args, opts)
+	d, err := h.getPersister(cmd, args, opts)
 	if err != nil {
 		return err
 	}
-	return popx.MigrateStatus(cmd, p.Persister())
+
+	phase, err := migrationPhase(cmd)
+	if err != nil {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return cmdx.FailSilently(cmd)
+	}
+
+	if err := d.Persister().Connection(cmd.Context()).Open(); err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not open the database connection:\n%+v\n", err)
+		return cmdx.FailSilently(cmd)
+	}
+
+	hasPending := func(s persistence.MigrationPhaseStatuses) bool {
+		if phase != "" {
+			return s.HasPending(phase)
+		}
+		return s.HasPending(persistence.MigrationPhaseExpand) || s.HasPending(persistence.MigrationPhaseContract)
+	}
+
+	status, err := d.Persister().MigrationPhaseStatus(cmd.Context())
+	if err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not get migration status: %+v\n", err)
+		return cmdx.FailSilently(cmd)
+	}
+
+	for flagx.MustGetBool(cmd, "block") && hasPending(status) {
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Waiting for migrations to finish...\n")
+		for _, m := range status {
+			if m.State == popx.Pending && (phase == "" || m.Phase == phase) {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), " - %s\n", m.Name)
+			}
+		}
+		time.Sleep(time.Second)
+		status, err = d.Persister().MigrationPhaseStatus(cmd.Context())
+		if err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not get migration status: %+v\n", err)
+			return cmdx.FailSilently(cmd)
+		}
+	}
+
+	cmdx.PrintTable(cmd, status)
+	return nil
 }
 
 func (h *MigrateHandler) MigrateSQLUp(cmd *cobra.Command, args []string, opts ...driver.RegistryOption) error {
-	p, err := h.getPersister(cmd, args, opts)
+	d, err := h.getPersister(cmd, args, opts)
 	if err != nil {
 		return err
 	}
-	return popx.MigrateSQLUp(cmd, p.Persister())
+
+	phase, err := migrationPhase(cmd)
+	if err != nil {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return cmdx.FailSilently(cmd)
+	} else if phase == "" {
+		return popx.MigrateSQLUp(cmd, d.Persister())
+	}
+
+	return popx.MigrateSQLUp(cmd, &phasedMigrationProvider{Persister: d.Persister(), phase: phase})
+}
+
+// migrationPhase returns the phase set using the --phase flag, or an empty phase if the flag is
+// not set.
+func migrationPhase(cmd *cobra.Command) (persistence.MigrationPhase, error) {
+	phase := flagx.MustGetString(cmd, "phase")
+	if phase == "" {
+		return "", nil
+	}
+	return persistence.ParseMigrationPhase(phase)
+}
+
+// phasedMigrationProvider applies the migrations of a single phase. Pending migrations of the
+// contract phase are reported as deferred during the expand phase.
+type phasedMigrationProvider struct {
+	persistence.Persister
+	phase persistence.MigrationPhase
+}
+
+func (p *phasedMigrationProvider) MigrationStatus(ctx context.Context) (popx.MigrationStatuses, error) {
+	status, err := p.Persister.MigrationPhaseStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(popx.MigrationStatuses, len(status))
+	for i, s := range status {
+		result[i] = s.MigrationStatus
+		if s.State == popx.Pending && p.phase == persistence.MigrationPhaseExpand && s.Phase == persistence.MigrationPhaseContract {
+			result[i].State = "Deferred"
+		}
+	}
+	return result, nil
+}
+
+func (p *phasedMigrationProvider) MigrateUp(ctx context.Context) error {
+	return p.Persister.MigrateUpPhase(ctx, p.phase)
 }
@@ -0,0 +1,84 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package cliclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/configx"
+	"github.com/ory/x/contextx"
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/servicelocatorx"
+)
+
+// MaxIssuedAdminAPITokenLifespan is the longest lifespan of an admin API token issued using
+// `kratos ops issue-admin-token`.
+const MaxIssuedAdminAPITokenLifespan = 24 * time.Hour
+
+type OpsHandler struct{}
+
+func NewOpsHandler() *OpsHandler {
+	return &OpsHandler{}
+}
+
+func (h *OpsHandler) IssueAdminToken(cmd *cobra.Command, args []string) error {
+	ttl := flagx.MustGetDuration(cmd, "ttl")
+	if ttl <= 0 || ttl > MaxIssuedAdminAPITokenLifespan {
+		return errors.Errorf(`the "ttl" flag must be greater than 0 and at most %s`, MaxIssuedAdminAPITokenLifespan)
+	}
+
+	opts := []configx.OptionModifier{
+		config.WithFlags(cmd.Flags()),
+		configx.SkipValidation(),
+	}
+
+	if !flagx.MustGetBool(cmd, "read-from-env") {
+		if len(args) != 1 {
+			return errors.New(`expected to get the DSN as an argument, or the "read-from-env" flag`)
+		}
+		opts = append(opts, configx.WithValue(config.ViperKeyDSN, args[0]))
+	}
+
+	d, err := driver.NewWithoutInit(
+		cmd.Context(),
+		cmd.ErrOrStderr(),
+		servicelocatorx.NewOptions(),
+		nil,
+		opts,
+	)
+	if len(d.Config().DSN(cmd.Context())) == 0 {
+		return errors.New(`required config value "dsn" was not set`)
+	} else if err != nil {
+		return errors.Wrap(err, "An error occurred initializing the admin API token issuer")
+	}
+
+	if err := d.Init(cmd.Context(), &contextx.Default{}); err != nil {
+		return errors.Wrap(err, "An error occurred initializing the admin API token issuer")
+	}
+
+	token, issued := x.NewIssuedAdminAPIToken(ttl)
+	if err := d.IssuedAdminAPITokenPersister().CreateIssuedAdminAPIToken(cmd.Context(), issued); err != nil {
+		return errors.Wrap(err, "An error occurred while storing the admin API token")
+	}
+
+	d.Audit().
+		WithField("issued_admin_api_token_id", issued.ID).
+		WithField("expires_at", issued.ExpiresAt).
+		Info("Issued an admin API token using the command line interface.")
+
+	if tokens, err := d.Config().AdminAPITokens(cmd.Context()); err == nil && len(tokens) == 0 {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), `No admin API tokens are configured in "serve.admin.api_tokens", the admin API currently accepts requests without a token.`)
+	}
+
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Issued admin API token %s, it can be used for one request until %s.\n", issued.ID, issued.ExpiresAt.Format(time.RFC3339))
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), token)
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package cliclient
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/x/configx"
+	"github.com/ory/x/contextx"
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/servicelocatorx"
+)
+
+type ReencryptHandler struct{}
+
+func NewReencryptHandler() *ReencryptHandler {
+	return &ReencryptHandler{}
+}
+
+func (h *ReencryptHandler) ReencryptTraits(cmd *cobra.Command, args []string) error {
+	opts := []configx.OptionModifier{
+		config.WithFlags(cmd.Flags()),
+		configx.SkipValidation(),
+	}
+
+	if !flagx.MustGetBool(cmd, "read-from-env") {
+		if len(args) != 1 {
+			return errors.New(`expected to get the DSN as an argument, or the "read-from-env" flag`)
+		}
+		opts = append(opts, configx.WithValue(config.ViperKeyDSN, args[0]))
+	}
+
+	d, err := driver.NewWithoutInit(
+		cmd.Context(),
+		cmd.ErrOrStderr(),
+		servicelocatorx.NewOptions(),
+		nil,
+		opts,
+	)
+	if len(d.Config().DSN(cmd.Context())) == 0 {
+		return errors.New(`required config value "dsn" was not set`)
+	} else if err != nil {
+		return errors.Wrap(err, "An error occurred initializing the re-encryption")
+	}
+
+	if err := d.Init(cmd.Context(), &contextx.Default{}); err != nil {
+		return errors.Wrap(err, "An error occurred initializing the re-encryption")
+	}
+
+	n, err := d.PrivilegedIdentityPool().ReencryptIdentityTraits(cmd.Context(), flagx.MustGetInt(cmd, "batch-size"))
+	if err != nil {
+		return errors.Wrapf(err, "An error occurred while encrypting the identity traits after %d identities were updated", n)
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Updated the traits of %d identities.\n", n)
+	return nil
+}
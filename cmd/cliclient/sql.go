@@ -0,0 +1,60 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package cliclient
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/persistence/sql"
+	"github.com/ory/x/configx"
+	"github.com/ory/x/contextx"
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/servicelocatorx"
+)
+
+type SQLHandler struct{}
+
+func NewSQLHandler() *SQLHandler {
+	return &SQLHandler{}
+}
+
+func (h *SQLHandler) Analyze(cmd *cobra.Command, args []string) ([]sql.QueryPlan, error) {
+	opts := []configx.OptionModifier{
+		config.WithFlags(cmd.Flags()),
+		configx.SkipValidation(),
+	}
+
+	if !flagx.MustGetBool(cmd, "read-from-env") {
+		if len(args) != 1 {
+			return nil, errors.New(`expected to get the DSN as an argument, or the "read-from-env" flag`)
+		}
+		opts = append(opts, configx.WithValue(config.ViperKeyDSN, args[0]))
+	}
+
+	d, err := driver.NewWithoutInit(
+		cmd.Context(),
+		cmd.ErrOrStderr(),
+		servicelocatorx.NewOptions(),
+		nil,
+		opts,
+	)
+	if len(d.Config().DSN(cmd.Context())) == 0 {
+		return nil, errors.New(`required config value "dsn" was not set`)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "An error occurred initializing the analysis")
+	}
+
+	if err := d.Init(cmd.Context(), &contextx.Default{}); err != nil {
+		return nil, errors.Wrap(err, "An error occurred initializing the analysis")
+	}
+
+	plans, err := sql.AnalyzeQueries(cmd.Context(), d.Persister().GetConnection(cmd.Context()), d.Persister().NetworkID(cmd.Context()))
+	if err != nil {
+		return nil, errors.Wrap(err, "An error occurred while analyzing the queries")
+	}
+	return plans, nil
+}
@@ -68,6 +68,8 @@ func init() {
 		"NewInfoSelfServiceSettingsLookupSecret":                  text.NewInfoSelfServiceSettingsLookupSecret("{secret}"),
 		"NewInfoSelfServiceSettingsLookupSecretUsed":              text.NewInfoSelfServiceSettingsLookupSecretUsed(aSecondAgo),
 		"NewInfoSelfServiceSettingsLookupSecretsLabel":            text.NewInfoSelfServiceSettingsLookupSecretsLabel(),
+		"NewInfoSelfServiceSettingsLookupSecretUnused":            text.NewInfoSelfServiceSettingsLookupSecretUnused(),
+		"NewInfoSelfServiceSettingsLookupSecretStatus":            text.NewInfoSelfServiceSettingsLookupSecretStatus(11, 12, []interface{}{text.NewInfoSelfServiceSettingsLookupSecretUnused(), text.NewInfoSelfServiceSettingsLookupSecretUsed(aSecondAgo)}),
 		"NewInfoSelfServiceSettingsUpdateLinkOIDC":                text.NewInfoSelfServiceSettingsUpdateLinkOIDC("{provider}"),
 		"NewInfoSelfServiceSettingsUpdateUnlinkOIDC":              text.NewInfoSelfServiceSettingsUpdateUnlinkOIDC("{provider}"),
 		"NewInfoSelfServiceRegisterWebAuthnDisplayName":           text.NewInfoSelfServiceRegisterWebAuthnDisplayName(),
@@ -139,11 +141,13 @@ func init() {
 		"NewInfoRegistrationContinue":                             text.NewInfoRegistrationContinue(),
 		"NewInfoRegistrationBack":                                 text.NewInfoRegistrationBack(),
 		"NewInfoSelfServiceChooseCredentials":                     text.NewInfoSelfServiceChooseCredentials(),
+		"NewInfoRegistrationIdentitySchema":                       text.NewInfoRegistrationIdentitySchema("{identity_schema}"),
 		"NewErrorValidationRegistrationFlowExpired":               text.NewErrorValidationRegistrationFlowExpired(aSecondAgo),
 		"NewErrorValidationRecoveryFlowExpired":                   text.NewErrorValidationRecoveryFlowExpired(aSecondAgo),
 		"NewRecoverySuccessful":                                   text.NewRecoverySuccessful(inAMinute),
 		"NewRecoveryEmailSent":                                    text.NewRecoveryEmailSent(),
 		"NewRecoveryEmailWithCodeSent":                            text.NewRecoveryEmailWithCodeSent(),
+		"NewRecoveryPushApproved":                                 text.NewRecoveryPushApproved(),
 		"NewErrorValidationRecoveryTokenInvalidOrAlreadyUsed":     text.NewErrorValidationRecoveryTokenInvalidOrAlreadyUsed(),
 		"NewErrorValidationRecoveryCodeInvalidOrAlreadyUsed":      text.NewErrorValidationRecoveryCodeInvalidOrAlreadyUsed(),
 		"NewErrorValidationRecoveryRetrySuccess":                  text.NewErrorValidationRecoveryRetrySuccess(),
@@ -175,12 +179,45 @@ func init() {
 		"NewInfoSelfServiceRegistrationRegisterCode":              text.NewInfoSelfServiceRegistrationRegisterCode(),
 		"NewErrorValidationLoginLinkedCredentialsDoNotMatch":      text.NewErrorValidationLoginLinkedCredentialsDoNotMatch(),
 		"NewErrorValidationAddressUnknown":                        text.NewErrorValidationAddressUnknown(),
+		"NewErrorValidationLoginTemporaryPasswordExpired":         text.NewErrorValidationLoginTemporaryPasswordExpired(aSecondAgo),
+		"NewErrorValidationLoginTooManySessions":                  text.NewErrorValidationLoginTooManySessions(5),
 		"NewInfoSelfServiceLoginCodeMFA":                          text.NewInfoSelfServiceLoginCodeMFA(),
 		"NewInfoLoginPassword":                                    text.NewInfoLoginPassword(),
 		"NewErrorValidationAccountNotFound":                       text.NewErrorValidationAccountNotFound(),
 		"NewInfoSelfServiceLoginAAL2CodeAddress":                  text.NewInfoSelfServiceLoginAAL2CodeAddress("{channel}", "{address}"),
 		"NewErrorCaptchaFailed":                                   text.NewErrorCaptchaFailed(),
 		"NewCaptchaContainerMessage":                              text.NewCaptchaContainerMessage(),
+		"NewInfoSelfServiceLoginCrossDeviceUserCode":              text.NewInfoSelfServiceLoginCrossDeviceUserCode("{user_code}"),
+		"NewInfoSelfServiceLoginCrossDeviceQRCode":                text.NewInfoSelfServiceLoginCrossDeviceQRCode(),
+		"NewInfoSelfServiceLoginCrossDeviceApprove":               text.NewInfoSelfServiceLoginCrossDeviceApprove(),
+		"NewInfoSelfServiceLoginCrossDeviceReject":                text.NewInfoSelfServiceLoginCrossDeviceReject(),
+		"NewInfoSelfServiceLoginCrossDeviceApproved":              text.NewInfoSelfServiceLoginCrossDeviceApproved(),
+		"NewInfoSelfServiceLoginCrossDeviceRejected":              text.NewInfoSelfServiceLoginCrossDeviceRejected(),
+		"NewInfoSelfServiceLoginDeviceKey":                        text.NewInfoSelfServiceLoginDeviceKey(),
+		"NewInfoSelfServiceSettingsRegisterDeviceKey":             text.NewInfoSelfServiceSettingsRegisterDeviceKey(),
+		"NewInfoSelfServiceRegisterDeviceKeyDisplayName":          text.NewInfoSelfServiceRegisterDeviceKeyDisplayName(),
+		"NewInfoSelfServiceRemoveDeviceKey":                       text.NewInfoSelfServiceRemoveDeviceKey("{display_name}", aSecondAgo),
+		"NewErrorValidationNoDeviceKey":                           text.NewErrorValidationNoDeviceKey(),
+		"NewErrorValidationDeviceKeySignatureInvalid":             text.NewErrorValidationDeviceKeySignatureInvalid(),
+		"NewErrorValidationDeviceKeyInvalid":                      text.NewErrorValidationDeviceKeyInvalid(),
+		"NewInfoSelfServiceLoginExternalMFA":                      text.NewInfoSelfServiceLoginExternalMFA("{provider}"),
+		"NewInfoSelfServiceLoginMoreFirstFactorsRequired":         text.NewInfoSelfServiceLoginMoreFirstFactorsRequired(1, []string{"{methods}"}),
+		"NewErrorValidationNoExternalMFA":                         text.NewErrorValidationNoExternalMFA(),
+		"NewErrorValidationExternalMFADenied":                     text.NewErrorValidationExternalMFADenied(),
+		"NewErrorValidationWebAuthnAuthenticatorNotAllowed":       text.NewErrorValidationWebAuthnAuthenticatorNotAllowed(),
+		"NewErrorValidationDisposableEmail":                       text.NewErrorValidationDisposableEmail("{domain}"),
+		"NewErrorValidationOIDCEmailDomainNotAllowed":             text.NewErrorValidationOIDCEmailDomainNotAllowed("{provider}", "{domain}"),
+		"NewInfoSelfServiceSettingsVerifyTraitChange":             text.NewInfoSelfServiceSettingsVerifyTraitChange("{address}"),
+		"NewInfoSelfServiceSettingsPasskeyEnrollment":             text.NewInfoSelfServiceSettingsPasskeyEnrollment(),
+		"NewInfoSelfServiceSettingsProfileCompletion":             text.NewInfoSelfServiceSettingsProfileCompletion(),
+		"NewErrorValidationSettingsVerificationCodeInvalid":       text.NewErrorValidationSettingsVerificationCodeInvalid(),
+		"NewErrorValidationSettingsTraitChangeDiscarded":          text.NewErrorValidationSettingsTraitChangeDiscarded(),
+		"NewErrorValidationSettingsLinkAddressNotVerified":        text.NewErrorValidationSettingsLinkAddressNotVerified(),
+		"NewInfoNodeLabelRememberMe":                              text.NewInfoNodeLabelRememberMe(),
+		"NewInfoNodeLabelRevokeAllSessions":                       text.NewInfoNodeLabelRevokeAllSessions(),
+		"NewInfoNodeLabelRequirePasswordReset":                    text.NewInfoNodeLabelRequirePasswordReset(),
+		"NewInfoSelfServiceLogoutRevokeAllSessions":               text.NewInfoSelfServiceLogoutRevokeAllSessions(),
+		"NewInfoSelfServiceLogoutAllSessionsRevoked":              text.NewInfoSelfServiceLogoutAllSessionsRevoked(3),
 	}
 }
 
@@ -0,0 +1,73 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/configx"
+	"github.com/ory/x/contextx"
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/logrusx"
+)
+
+// NewRenderCmd represents the render command
+func NewRenderCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "render",
+		Short: "Print the effective configuration",
+		Long: `Prints the effective configuration with secrets redacted.
+
+The configuration is merged from the schema defaults, the config files in the order they are passed
+using --config, and the environment variables. References to environment variables of the form
+${NAME} or ${NAME:default} in the config files are replaced with their values.`,
+		Example: `Render the configuration of the production environment:
+	kratos config render -c kratos.yaml -c kratos.production.yaml
+
+Render the configuration as JSON:
+	kratos config render -c kratos.yaml --format json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conf, err := config.New(
+				cmd.Context(),
+				logrusx.New("Ory Kratos", config.Version),
+				cmd.ErrOrStderr(),
+				&contextx.Default{},
+				config.WithFlags(cmd.Flags()),
+				configx.WithContext(cmd.Context()),
+			)
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unable to load the configuration: %s\n", err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			switch format := flagx.MustGetString(cmd, "format"); format {
+			case "yaml":
+				out, err := yaml.Parser().Marshal(conf.Redacted(cmd.Context()))
+				if err != nil {
+					return err
+				}
+				_, _ = cmd.OutOrStdout().Write(out)
+				return nil
+			case "json":
+				e := json.NewEncoder(cmd.OutOrStdout())
+				e.SetEscapeHTML(false)
+				e.SetIndent("", "  ")
+				return e.Encode(conf.Redacted(cmd.Context()))
+			default:
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unknown format %q, expected \"yaml\" or \"json\".\n", format)
+				return cmdx.FailSilently(cmd)
+			}
+		},
+	}
+
+	configx.RegisterFlags(c.PersistentFlags())
+	c.Flags().String("format", "yaml", `The output format, either "yaml" or "json".`)
+	return c
+}
@@ -0,0 +1,32 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	_ "github.com/ory/jsonschema/v3/base64loader"
+	_ "github.com/ory/jsonschema/v3/fileloader"
+)
+
+func TestRenderCmd(t *testing.T) {
+	t.Setenv("KRATOS_TEST_ADMIN_PORT", "5434")
+
+	cmd := NewRenderCmd()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"-c", "../../driver/config/stub/.kratos.yaml", "-c", "stub/overlay.yaml", "--format", "json"})
+	require.NoError(t, cmd.Execute(), stderr.String())
+
+	out := stdout.Bytes()
+	assert.EqualValues(t, 5434, gjson.GetBytes(out, "serve.admin.port").Int())
+	assert.Equal(t, "<redacted>", gjson.GetBytes(out, "dsn").String())
+	assert.Equal(t, "<redacted>", gjson.GetBytes(out, "secrets.cookie.0").String())
+}
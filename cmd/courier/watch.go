@@ -14,9 +14,11 @@ import (
 
 	"github.com/ory/graceful"
 	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/configx"
 	"github.com/ory/x/otelx"
+	prometheus "github.com/ory/x/prometheusx"
 	"github.com/ory/x/reqlog"
 	"github.com/ory/x/servicelocatorx"
 )
@@ -25,8 +27,11 @@ func NewWatchCmd(slOpts []servicelocatorx.Option, dOpts []driver.RegistryOption)
 	c := &cobra.Command{
 		Use:   "watch",
 		Short: "Starts the Ory Kratos message courier",
+		Long: `Starts the Ory Kratos message courier.
+
+Several couriers can watch the same database concurrently to scale the dispatch of messages horizontally. Each message is claimed by exactly one courier. Claiming rows without waiting for other couriers requires PostgreSQL, CockroachDB, or MySQL 8.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			r, err := driver.New(cmd.Context(), cmd.ErrOrStderr(), servicelocatorx.NewOptions(slOpts...), dOpts, []configx.OptionModifier{configx.WithFlags(cmd.Flags())})
+			r, err := driver.New(cmd.Context(), cmd.ErrOrStderr(), servicelocatorx.NewOptions(slOpts...), dOpts, []configx.OptionModifier{config.WithFlags(cmd.Flags())})
 			if err != nil {
 				return err
 			}
@@ -61,7 +66,7 @@ func ServeMetrics(ctx context.Context, r driver.Registry) error {
 
 	router := x.NewRouterAdmin()
 
-	r.MetricsHandler().SetRoutes(router.Router)
+	router.Router.GET(prometheus.MetricsPrometheusPath, x.ServeMetrics)
 	n.Use(reqlog.NewMiddlewareFromLogger(l, "admin#"+c.SelfPublicURL(ctx).String()))
 	n.Use(r.PrometheusManager())
 
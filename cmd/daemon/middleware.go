@@ -13,7 +13,18 @@ import (
 	"github.com/ory/x/logrusx"
 
 	"github.com/ory/x/healthx"
+	prometheus "github.com/ory/x/prometheusx"
 	"github.com/ory/x/reqlog"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/recovery"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+	"github.com/ory/kratos/x/webauthnx"
 )
 
 func NewNegroniLoggerMiddleware(l *logrusx.Logger, name string) *reqlog.Middleware {
@@ -37,3 +48,31 @@ func NewNegroniLoggerMiddleware(l *logrusx.Logger, name string) *reqlog.Middlewa
 	}
 	return n
 }
+
+// publicRequestPriority classifies requests to the public endpoint for load shedding. Flow
+// initialization is shed first so that already started flows can still be completed.
+func publicRequestPriority(r *http.Request) x.RequestPriority {
+	switch r.URL.Path {
+	case healthx.AliveCheckPath, healthx.ReadyCheckPath, healthx.VersionPath, prometheus.MetricsPrometheusPath:
+		return x.RequestPriorityCritical
+	case login.RouteInitBrowserFlow, login.RouteInitAPIFlow,
+		registration.RouteInitBrowserFlow, registration.RouteInitAPIFlow,
+		recovery.RouteInitBrowserFlow, recovery.RouteInitAPIFlow,
+		verification.RouteInitBrowserFlow, verification.RouteInitAPIFlow,
+		settings.RouteInitBrowserFlow, settings.RouteInitAPIFlow:
+		if r.Method == http.MethodGet {
+			return x.RequestPriorityLow
+		}
+	case session.RouteWhoamiEvents:
+		return x.RequestPriorityStream
+	}
+	return x.RequestPriorityHigh
+}
+
+// publicSecurityHeaderRoutes are the route policies of the public endpoint which apply
+// regardless of the configuration.
+var publicSecurityHeaderRoutes = []config.SecurityHeadersRoute{
+	// The WebAuthn script is loaded by the UI from another origin. Allow this even if the UI
+	// sets a Cross-Origin-Embedder-Policy.
+	{Path: webauthnx.ScriptURL, Headers: map[string]string{"Cross-Origin-Resource-Policy": "cross-origin"}},
+}
@@ -19,6 +19,7 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/net/context"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
 
 	"github.com/ory/analytics-go/v5"
 	"github.com/ory/graceful"
@@ -96,10 +97,13 @@ func servePublic(r driver.Registry, cmd *cobra.Command, eg *errgroup.Group, slOp
 
 	n.UseFunc(semconv.Middleware)
 	n.Use(publicLogger)
+	n.Use(x.NewSecurityHeaders(r, "public", publicSecurityHeaderRoutes...))
 	n.Use(x.HTTPLoaderContextMiddleware(r))
+	n.Use(x.DebugTraceMiddleware(r))
 	n.Use(sqa(ctx, cmd, r))
 
 	n.Use(r.PrometheusManager())
+	n.Use(x.NewLoadShedder(r, publicRequestPriority))
 
 	router := x.NewRouterPublic()
 	csrf := x.NewCSRFHandler(router, r)
@@ -190,10 +194,12 @@ func serveAdmin(r driver.Registry, cmd *cobra.Command, eg *errgroup.Group, slOpt
 	}
 	n.UseFunc(semconv.Middleware)
 	n.Use(adminLogger)
+	n.Use(x.NewSecurityHeaders(r, "admin"))
 	n.UseFunc(x.RedirectAdminMiddleware)
 	n.Use(x.HTTPLoaderContextMiddleware(r))
 	n.Use(sqa(ctx, cmd, r))
 	n.Use(r.PrometheusManager())
+	n.Use(x.NewAdminAPITokenAuthorizer(r))
 
 	router := x.NewRouterAdmin()
 	r.RegisterAdminRoutes(ctx, router)
@@ -247,6 +253,42 @@ func serveAdmin(r driver.Registry, cmd *cobra.Command, eg *errgroup.Group, slOpt
 	})
 }
 
+func serveGRPC(r driver.Registry, cmd *cobra.Command, eg *errgroup.Group, opts []Option) {
+	modifiers := NewOptions(cmd.Context(), opts)
+	ctx := modifiers.ctx
+
+	c := r.Config()
+	if !c.GRPCServerEnabled(ctx) {
+		return
+	}
+
+	serveGRPCListener(ctx, r, eg, "public", driver.NewGRPCPublicServer(ctx, r), c.GRPCPublicListenOn(ctx))
+	serveGRPCListener(ctx, r, eg, "admin", driver.NewGRPCAdminServer(ctx, r), c.GRPCAdminListenOn(ctx))
+}
+
+func serveGRPCListener(ctx stdctx.Context, r driver.Registry, eg *errgroup.Group, name string, server *grpc.Server, addr string) {
+	l := r.Logger()
+	eg.Go(func() error {
+		l.Printf("Starting the %s gRPC server on: %s", name, addr)
+		listener, err := networkx.MakeListener(addr, nil)
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			<-ctx.Done()
+			server.GracefulStop()
+		}()
+
+		if err := server.Serve(listener); err != nil {
+			l.Errorf("Failed to serve %s gRPC: %s", name, err)
+			return err
+		}
+		l.Printf("%s gRPC server was shutdown gracefully", name)
+		return nil
+	})
+}
+
 func sqa(ctx stdctx.Context, cmd *cobra.Command, d driver.Registry) *metricsx.Service {
 	// Creates only ones
 	// instance
@@ -323,13 +365,20 @@ func sqa(ctx stdctx.Context, cmd *cobra.Command, d driver.Registry) *metricsx.Se
 
 func bgTasks(d driver.Registry, cmd *cobra.Command, opts []Option) error {
 	modifiers := NewOptions(cmd.Context(), opts)
-	ctx := modifiers.ctx
+	g, ctx := errgroup.WithContext(modifiers.ctx)
 
 	if d.Config().IsBackgroundCourierEnabled(ctx) {
-		return courier.Watch(ctx, d)
+		g.Go(func() error {
+			return courier.Watch(ctx, d)
+		})
+	}
+	if d.Config().JobsEnabled(ctx) {
+		g.Go(func() error {
+			return d.JobScheduler().Start(ctx)
+		})
 	}
 
-	return nil
+	return g.Wait()
 }
 
 func ServeAll(d driver.Registry, slOpts *servicelocatorx.Options, opts []Option) func(cmd *cobra.Command, args []string) error {
@@ -341,11 +390,17 @@ func ServeAll(d driver.Registry, slOpts *servicelocatorx.Options, opts []Option)
 		cmd.SetContext(ctx)
 		opts = append(opts, WithContext(ctx))
 
+		d.Config().ReportCompatibilitySwitches(ctx)
+
 		servePublic(d, cmd, g, slOpts, opts)
 		serveAdmin(d, cmd, g, slOpts, opts)
+		serveGRPC(d, cmd, g, opts)
 		g.Go(func() error {
 			return bgTasks(d, cmd, opts)
 		})
+		g.Go(func() error {
+			return d.ConfigReloader().Watch(ctx)
+		})
 		return g.Wait()
 	}
 }
@@ -73,7 +73,7 @@ func configProvider(cmd *cobra.Command, flagConf *argon2Config) (*argon2Config,
 		l,
 		cmd.ErrOrStderr(),
 		&contextx.Default{},
-		configx.WithFlags(cmd.Flags()),
+		config.WithFlags(cmd.Flags()),
 		configx.SkipValidation(),
 		configx.WithContext(cmd.Context()),
 		configx.WithImmutables("hashers"),
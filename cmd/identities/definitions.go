@@ -4,9 +4,11 @@
 package identities
 
 import (
+	"fmt"
 	"strings"
 
 	kratos "github.com/ory/kratos/internal/httpclient"
+	"github.com/ory/kratos/ui/node"
 
 	"github.com/ory/x/cmdx"
 )
@@ -83,3 +85,38 @@ func (c outputIdentityCollection) Interface() interface{} {
 func (c *outputIdentityCollection) Len() int {
 	return len(c.Identities)
 }
+
+type outputNodeCollection struct {
+	Nodes node.Nodes `json:"nodes"`
+}
+
+func (outputNodeCollection) Header() []string {
+	return []string{"NAME", "TYPE", "GROUP", "REQUIRED", "LABEL", "VALUE"}
+}
+
+func (c outputNodeCollection) Table() [][]string {
+	rows := make([][]string, len(c.Nodes))
+	for i, n := range c.Nodes {
+		row := []string{n.ID(), string(n.Type), string(n.Group), cmdx.None, cmdx.None, cmdx.None}
+		if a, ok := n.Attributes.(*node.InputAttributes); ok {
+			row[1] = string(a.Type)
+			row[3] = fmt.Sprintf("%t", a.Required)
+		}
+		if n.Meta != nil && n.Meta.Label != nil {
+			row[4] = n.Meta.Label.Text
+		}
+		if v := n.GetValue(); v != nil {
+			row[5] = fmt.Sprintf("%v", v)
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func (c outputNodeCollection) Interface() interface{} {
+	return c.Nodes
+}
+
+func (c *outputNodeCollection) Len() int {
+	return len(c.Nodes)
+}
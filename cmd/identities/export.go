@@ -0,0 +1,182 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identities
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/pagination/keysetpagination"
+
+	"github.com/ory/kratos/cmd/cliclient"
+)
+
+const formatNDJSON = "ndjson"
+
+func NewExportCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export resources",
+	}
+	cmd.AddCommand(NewExportIdentitiesCmd())
+	cliclient.RegisterClientFlags(cmd.PersistentFlags())
+	cmdx.RegisterFormatFlags(cmd.PersistentFlags())
+	return cmd
+}
+
+// exportCheckpoint is the state of an export which is persisted after every page.
+type exportCheckpoint struct {
+	PageToken string `json:"page_token"`
+	Exported  int    `json:"exported"`
+}
+
+// NewExportIdentitiesCmd represents the export command
+func NewExportIdentitiesCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "identities",
+		Short: "Export all identities",
+		Example: `Export all identities including their password hashes to a file:
+
+	{{ .CommandPath }} --format ndjson --include-credential password --output identities.ndjson
+
+Resume an interrupted export:
+
+	{{ .CommandPath }} --format ndjson --output identities.ndjson --checkpoint export.checkpoint`,
+		Long: `Export all identities by paging through the admin API.
+
+The identities are streamed as newline delimited JSON (--format ndjson, the default) or as a JSON array (--format json). The output can be imported again using "... import identities".
+
+When a checkpoint file is given, the position of the export is stored in it after every page. Running the same command again resumes the export from that position and appends to the output file. Checkpoints are only supported for the ndjson format.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := cliclient.NewClient(cmd)
+			if err != nil {
+				return err
+			}
+
+			asArray := false
+			switch f := flagx.MustGetString(cmd, cmdx.FlagFormat); f {
+			case formatNDJSON, string(cmdx.FormatDefault):
+			case string(cmdx.FormatJSON):
+				asArray = true
+			default:
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Format %q is not supported, use %q or %q.\n", f, formatNDJSON, cmdx.FormatJSON)
+				return cmdx.FailSilently(cmd)
+			}
+
+			checkpointPath := flagx.MustGetString(cmd, "checkpoint")
+			if asArray && checkpointPath != "" {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Checkpoints are only supported for the %q format.\n", formatNDJSON)
+				return cmdx.FailSilently(cmd)
+			}
+
+			var checkpoint exportCheckpoint
+			if err := loadCheckpoint(checkpointPath, &checkpoint); err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if fn := flagx.MustGetString(cmd, "output"); fn != "" {
+				flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+				if checkpoint.PageToken != "" {
+					flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+				}
+				f, err := os.OpenFile(fn, flags, 0600)
+				if err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not open output file: %s\n", fn, err)
+					return cmdx.FailSilently(cmd)
+				}
+				defer f.Close()
+				out = f
+			}
+
+			w := bufio.NewWriter(out)
+			if asArray {
+				_, _ = w.WriteString("[")
+			}
+
+			pageSize := flagx.MustGetInt(cmd, "page-size")
+			consistency := flagx.MustGetString(cmd, "consistency")
+			includeCredential := flagx.MustGetStringSlice(cmd, "include-credential")
+			for {
+				req := c.IdentityAPI.ListIdentities(cmd.Context()).
+					Consistency(consistency).
+					PageSize(int64(pageSize))
+				if checkpoint.PageToken != "" {
+					req = req.PageToken(checkpoint.PageToken)
+				}
+				if len(includeCredential) > 0 {
+					req = req.IncludeCredential(includeCredential)
+				}
+
+				identities, res, err := req.Execute()
+				if err != nil {
+					return cmdx.PrintOpenAPIError(cmd, err)
+				}
+
+				for _, i := range identities {
+					if err := writeExportedIdentity(w, i, asArray, checkpoint.Exported > 0); err != nil {
+						return err
+					}
+					checkpoint.Exported++
+				}
+				if err := w.Flush(); err != nil {
+					return errors.WithStack(err)
+				}
+
+				checkpoint.PageToken = keysetpagination.ParseHeader(res).NextToken
+				if checkpoint.PageToken == "" || len(identities) == 0 {
+					break
+				}
+				if err := saveCheckpoint(checkpointPath, &checkpoint); err != nil {
+					return err
+				}
+			}
+
+			if asArray {
+				_, _ = w.WriteString("]\n")
+				if err := w.Flush(); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+
+			// The export is complete, so there is nothing left to resume.
+			if checkpointPath != "" {
+				if err := os.Remove(checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return errors.WithStack(err)
+				}
+			}
+
+			return nil
+		},
+	}
+	c.Flags().String("output", "", "The file to write the identities to. Defaults to STD_OUT.")
+	c.Flags().String("checkpoint", "", "The file to store the export position in, allowing to resume an interrupted export.")
+	c.Flags().Int("page-size", 500, "The number of identities to fetch per request.")
+	c.Flags().String("consistency", "eventual", "The read consistency to use. Can be either \"strong\" or \"eventual\". Defaults to \"eventual\".")
+	c.Flags().StringSlice("include-credential", nil, "Include the credentials of the given types, e.g. \"password\" or \"oidc\", in the export.")
+	return c
+}
+
+func writeExportedIdentity(w io.Writer, i any, asArray, separate bool) error {
+	raw, err := json.Marshal(i)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if asArray && separate {
+		raw = append([]byte(","), raw...)
+	} else if !asArray {
+		raw = append(raw, '\n')
+	}
+	_, err = w.Write(raw)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,94 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identities_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/x/cmdx"
+
+	"github.com/ory/kratos/cmd/cliclient"
+	"github.com/ory/kratos/cmd/identities"
+)
+
+func TestExportCmd(t *testing.T) {
+	reg, cmd := setup(t, identities.NewExportIdentitiesCmd)
+	_, ids := makeIdentities(t, reg, 5)
+
+	exportedIDs := func(t *testing.T, ndjson string) (actual []string) {
+		for _, line := range strings.Split(strings.TrimSpace(ndjson), "\n") {
+			require.True(t, gjson.Valid(line), line)
+			actual = append(actual, gjson.Get(line, "id").String())
+		}
+		return
+	}
+
+	t.Run("case=exports all identities as ndjson", func(t *testing.T) {
+		stdOut := cmd.ExecNoErr(t, "--format", "ndjson", "--page-size", "2")
+		assert.ElementsMatch(t, ids, exportedIDs(t, stdOut))
+	})
+
+	t.Run("case=exports all identities as json", func(t *testing.T) {
+		stdOut := cmd.ExecNoErr(t, "--format", "json", "--page-size", "2")
+
+		var actual []string
+		for _, id := range gjson.Get(stdOut, "#.id").Array() {
+			actual = append(actual, id.String())
+		}
+		assert.ElementsMatch(t, ids, actual)
+	})
+
+	t.Run("case=exports to file", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "identities.ndjson")
+		checkpoint := filepath.Join(t.TempDir(), "export.checkpoint")
+		cmd.ExecNoErr(t, "--format", "ndjson", "--page-size", "2", "--output", out, "--checkpoint", checkpoint)
+
+		raw, err := os.ReadFile(out)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, ids, exportedIDs(t, string(raw)))
+		assert.NoFileExists(t, checkpoint, "the checkpoint is removed once the export is complete")
+	})
+
+	t.Run("case=resumes from checkpoint", func(t *testing.T) {
+		list := &cmdx.CommandExecuter{
+			New: func() *cobra.Command {
+				c := identities.NewListIdentitiesCmd()
+				cliclient.RegisterClientFlags(c.Flags())
+				cmdx.RegisterFormatFlags(c.Flags())
+				return c
+			},
+			PersistentArgs: cmd.PersistentArgs,
+		}
+		first := list.ExecNoErr(t, "--page-size", "2")
+		token := gjson.Get(first, "next_page_token").String()
+		require.NotEmpty(t, token)
+
+		out := filepath.Join(t.TempDir(), "identities.ndjson")
+		require.NoError(t, os.WriteFile(out, []byte(gjson.Get(first, "identities.0").Raw+"\n"+gjson.Get(first, "identities.1").Raw+"\n"), 0600))
+		checkpoint := filepath.Join(t.TempDir(), "export.checkpoint")
+		raw, err := json.Marshal(map[string]any{"page_token": token, "exported": 2})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(checkpoint, raw, 0600))
+
+		cmd.ExecNoErr(t, "--format", "ndjson", "--page-size", "2", "--output", out, "--checkpoint", checkpoint)
+
+		raw, err = os.ReadFile(out)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, ids, exportedIDs(t, string(raw)))
+	})
+
+	t.Run("case=fails on unsupported format", func(t *testing.T) {
+		_, _, err := cmd.Exec(nil, "--format", "yaml")
+		assert.Error(t, err)
+	})
+}
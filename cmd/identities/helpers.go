@@ -4,14 +4,19 @@
 package identities
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"unicode"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/tidwall/gjson"
 
 	"github.com/ory/x/cmdx"
+	"github.com/ory/x/jsonnetsecure"
 )
 
 func parseIdentities(raw []byte) (rawIdentities []string) {
@@ -51,3 +56,128 @@ func readIdentities(cmd *cobra.Command, args []string) (map[string]string, error
 	}
 	return rawIdentities, nil
 }
+
+// streamIdentities calls fn for every identity read from the files or STD_IN, without loading
+// them into memory at once. Besides a single or an array of identities, the input may contain
+// one identity per line (NDJSON). The index is the position of the identity within its source.
+func streamIdentities(cmd *cobra.Command, args []string, fn func(source string, idx int, raw string) error) error {
+	if len(args) == 0 {
+		return decodeIdentities(cmd, "STD_IN", cmd.InOrStdin(), fn)
+	}
+	for _, name := range args {
+		if err := func() error {
+			f, err := os.Open(name)
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not open identity file: %s\n", name, err)
+				return cmdx.FailSilently(cmd)
+			}
+			defer f.Close()
+			return decodeIdentities(cmd, name, f, fn)
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeIdentities(cmd *cobra.Command, source string, r io.Reader, fn func(source string, idx int, raw string) error) error {
+	br := bufio.NewReader(r)
+	isArray := false
+	for {
+		b, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not read: %s\n", source, err)
+			return cmdx.FailSilently(cmd)
+		}
+		if !unicode.IsSpace(rune(b)) {
+			isArray = b == '['
+			_ = br.UnreadByte()
+			break
+		}
+	}
+
+	dec := json.NewDecoder(br)
+	if isArray {
+		// Consume the opening bracket.
+		if _, err := dec.Token(); err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not parse identities: %s\n", source, err)
+			return cmdx.FailSilently(cmd)
+		}
+	}
+
+	for idx := 0; !isArray || dec.More(); idx++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) && !isArray {
+			return nil
+		} else if err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s[%d]: Could not parse identity: %s\n", source, idx, err)
+			return cmdx.FailSilently(cmd)
+		}
+		if err := fn(source, idx, string(raw)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// identityTransformer maps an identity using a Jsonnet snippet. It returns false if the snippet
+// evaluated to null, which means that the identity should be skipped.
+type identityTransformer func(raw string) (string, bool, error)
+
+// newIdentityTransformer returns a transformer evaluating the Jsonnet file at path, or nil if
+// path is empty. The identity is available to the snippet as `std.extVar('identity')`.
+func newIdentityTransformer(path string) (identityTransformer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	snippet, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read the transformation file %s", path)
+	}
+
+	vm := jsonnetsecure.MakeSecureVM()
+	return func(raw string) (string, bool, error) {
+		vm.ExtCode("identity", raw)
+		out, err := vm.EvaluateAnonymousSnippet(path, string(snippet))
+		if err != nil {
+			return "", false, errors.Wrap(err, "could not transform identity")
+		}
+		if res := gjson.Parse(out); res.Type == gjson.Null {
+			return "", false, nil
+		}
+		return out, true, nil
+	}, nil
+}
+
+// loadCheckpoint reads the checkpoint at path into v. It does nothing if path is empty or the
+// checkpoint does not exist yet.
+func loadCheckpoint(path string, v any) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "could not read checkpoint %s", path)
+	}
+	return errors.Wrapf(json.Unmarshal(raw, v), "could not parse checkpoint %s", path)
+}
+
+// saveCheckpoint atomically replaces the checkpoint at path with v. It does nothing if path is
+// empty.
+func saveCheckpoint(path string, v any) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.WriteFile(path+".tmp", raw, 0600); err != nil {
+		return errors.Wrapf(err, "could not write checkpoint %s", path)
+	}
+	return errors.Wrapf(os.Rename(path+".tmp", path), "could not write checkpoint %s", path)
+}
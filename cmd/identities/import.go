@@ -4,8 +4,16 @@
 package identities
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/flagx"
 
 	kratos "github.com/ory/kratos/internal/httpclient"
 
@@ -27,9 +35,29 @@ func NewImportCmd() *cobra.Command {
 	return cmd
 }
 
+// importCheckpoint is the state of an import which is persisted after every batch. It holds
+// the number of leading identities of each source which have been processed, and the
+// identities after those which were imported nonetheless because they were part of the same
+// batch as an identity which could not be imported.
+type importCheckpoint struct {
+	Processed map[string]int   `json:"processed"`
+	Imported  map[string][]int `json:"imported,omitempty"`
+}
+
+// importEntry is an identity which is waiting to be imported as part of the next batch.
+type importEntry struct {
+	source, src string
+	idx         int
+	body        *kratos.CreateIdentityBody
+}
+
+// maxImportBatchSize is the maximum number of identities per batch. The imported identities of
+// each batch are fetched using the ids filter of the list identities API, which is limited to 500.
+const maxImportBatchSize = 500
+
 // NewImportIdentitiesCmd represents the import command
 func NewImportIdentitiesCmd() *cobra.Command {
-	return &cobra.Command{
+	c := &cobra.Command{
 		Use:   "identities file-1.json [file-2.json] [file-3.json] [file-n.json]",
 		Short: "Import one or more identities from files or STD_IN",
 		Example: `Create an example identity:
@@ -47,52 +75,268 @@ func NewImportIdentitiesCmd() *cobra.Command {
 
 Alternatively:
 
-	cat file.json | {{ .CommandPath }}`,
+	cat file.json | {{ .CommandPath }}
+
+Map exported identities to another identity schema and check the result without importing anything:
+
+	cat > ./transform.jsonnet <<EOF
+	local identity = std.extVar('identity');
+	{
+	    schema_id: "customer",
+	    traits: {
+	        email: identity.traits.email,
+	    },
+	}
+	EOF
+
+	{{ .CommandPath }} --transform transform.jsonnet --dry-run identities.ndjson`,
 		Long: `Import identities from files or STD_IN.
 
-Files can contain only a single or an array of identities. The validity of files can be tested beforehand using "... identities validate".`,
+Files can contain only a single or an array of identities, or one identity per line (NDJSON). The validity of files can be tested beforehand using "... identities validate".
+
+Each identity can be mapped using a Jsonnet file (--transform). The identity is available as "std.extVar('identity')" and the snippet must return the payload to import, or null to skip the identity. Using --dry-run, the identities are transformed and validated but not imported.
+
+Identities are imported in batches (--batch-size), and the imported identities are printed after every batch.
+
+When a checkpoint file is given, the import stops after the first batch containing an identity which could not be imported, and the progress is stored in the checkpoint file after every batch. Running the same command again resumes the import from that identity, skipping the identities which were already imported.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			c, err := cliclient.NewClient(cmd)
 			if err != nil {
 				return err
 			}
 
-			imported := make([]kratos.Identity, 0, len(args))
-			failed := make(map[string]error)
-
-			is, err := readIdentities(cmd, args)
+			transform, err := newIdentityTransformer(flagx.MustGetString(cmd, "transform"))
 			if err != nil {
 				return err
 			}
+			dryRun := flagx.MustGetBool(cmd, "dry-run")
+			checkpointPath := flagx.MustGetString(cmd, "checkpoint")
+			if dryRun {
+				// A dry run does not import anything, so there is no progress to store.
+				checkpointPath = ""
+			}
+
+			batchSize := flagx.MustGetInt(cmd, "batch-size")
+			if batchSize < 1 || batchSize > maxImportBatchSize {
+				return errors.Errorf("the batch size must be between 1 and %d", maxImportBatchSize)
+			}
+
+			checkpoint := importCheckpoint{Processed: make(map[string]int)}
+			if err := loadCheckpoint(checkpointPath, &checkpoint); err != nil {
+				return err
+			}
+			if checkpoint.Imported == nil {
+				checkpoint.Imported = make(map[string][]int)
+			}
+
+			batch := make([]importEntry, 0, batchSize)
+			failed := make(map[string]error)
+			var validated, batches int
+
+			// flush imports the pending batch, prints the imported identities, and stores the
+			// progress in the checkpoint.
+			flush := func(final bool) error {
+				if len(batch) == 0 {
+					return nil
+				}
+				defer func() { batch = batch[:0] }()
+
+				patches := make([]kratos.IdentityPatch, 0, len(batch))
+				for _, e := range batch {
+					if e.body != nil {
+						patches = append(patches, kratos.IdentityPatch{Create: e.body})
+					}
+				}
+
+				var results []kratos.IdentityPatchResponse
+				var batchErr error
+				if len(patches) > 0 {
+					res, _, err := c.IdentityAPI.BatchPatchIdentities(cmd.Context()).
+						PatchIdentitiesBody(kratos.PatchIdentitiesBody{Identities: patches}).
+						Execute()
+					if err != nil {
+						batchErr = cmdx.PrintOpenAPIError(cmd, err)
+					} else {
+						results = res.Identities
+					}
+				}
+
+				batchFailed := make(map[string]error)
+				stopped := make(map[string]bool)
+				ids := make([]string, 0, len(patches))
+				var patchIdx int
+				for _, e := range batch {
+					if e.body != nil {
+						err := batchErr
+						if err == nil {
+							if patchIdx >= len(results) {
+								err = errors.New("the server did not return a result for this identity")
+							} else if r := results[patchIdx]; r.GetAction() == "error" {
+								err = patchError(r.Error)
+							} else {
+								ids = append(ids, r.GetIdentity())
+							}
+						}
+						patchIdx++
+
+						if err != nil {
+							batchFailed[e.src] = err
+							stopped[e.source] = true
+							continue
+						}
+					}
+
+					if stopped[e.source] {
+						if e.body != nil {
+							checkpoint.Imported[e.source] = append(checkpoint.Imported[e.source], e.idx)
+						}
+						continue
+					}
+					checkpoint.Processed[e.source] = e.idx + 1
+					checkpoint.Imported[e.source] = slices.DeleteFunc(checkpoint.Imported[e.source], func(idx int) bool { return idx <= e.idx })
+					if len(checkpoint.Imported[e.source]) == 0 {
+						delete(checkpoint.Imported, e.source)
+					}
+				}
+
+				if len(ids) > 0 {
+					imported, _, err := c.IdentityAPI.ListIdentities(cmd.Context()).Ids(ids).Execute()
+					if err != nil {
+						return cmdx.PrintOpenAPIError(cmd, err)
+					}
+					if final && batches == 0 && len(imported) == 1 {
+						cmdx.PrintRow(cmd, (*outputIdentity)(&imported[0]))
+					} else {
+						cmdx.PrintTable(cmd, &outputIdentityCollection{Identities: imported})
+					}
+				}
+				batches++
+				cmdx.PrintErrors(cmd, batchFailed)
+				for src, err := range batchFailed {
+					failed[src] = err
+				}
+
+				if err := saveCheckpoint(checkpointPath, &checkpoint); err != nil {
+					return err
+				}
+				if len(batchFailed) != 0 && checkpointPath != "" {
+					return errStopImport
+				}
+				return nil
+			}
+
+			process := func(source, src string, idx int, i string) error {
+				if transform != nil {
+					var (
+						ok  bool
+						err error
+					)
+					if i, ok, err = transform(i); err != nil {
+						return err
+					} else if !ok {
+						batch = append(batch, importEntry{source: source, src: src, idx: idx})
+						return nil
+					}
+				}
+
+				if dryRun {
+					if err := ValidateIdentity(cmd, src, i, func(ctx context.Context, id string) (map[string]interface{}, *http.Response, error) {
+						return c.IdentityAPI.GetIdentitySchema(ctx, id).Execute()
+					}); err != nil {
+						return err
+					}
+					validated++
+					return nil
+				}
 
-			for src, i := range is {
 				var params kratos.CreateIdentityBody
-				err = json.Unmarshal([]byte(i), &params)
-				if err != nil {
-					_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "STD_IN: Could not parse identity")
-					return cmdx.FailSilently(cmd)
+				if err := json.Unmarshal([]byte(i), &params); err != nil {
+					return errors.Wrap(err, "could not parse identity")
 				}
+				batch = append(batch, importEntry{source: source, src: src, idx: idx, body: &params})
+				return nil
+			}
 
-				ident, _, err := c.IdentityAPI.CreateIdentity(cmd.Context()).CreateIdentityBody(params).Execute()
-				if err != nil {
-					failed[src] = cmdx.PrintOpenAPIError(cmd, err)
-				} else {
-					imported = append(imported, *ident)
+			err = streamIdentities(cmd, args, func(source string, idx int, i string) error {
+				if idx < checkpoint.Processed[source] {
+					return nil
 				}
+
+				src := fmt.Sprintf("%s[%d]", source, idx)
+				if slices.Contains(checkpoint.Imported[source], idx) {
+					// Already imported, but the checkpoint still needs to advance past it.
+					batch = append(batch, importEntry{source: source, src: src, idx: idx})
+				} else if err := process(source, src, idx, i); err != nil {
+					failed[src] = err
+					if dryRun {
+						return nil
+					}
+					cmdx.PrintErrors(cmd, map[string]error{src: err})
+					if checkpointPath != "" {
+						if err := flush(false); err != nil {
+							return err
+						}
+						return errStopImport
+					}
+					return nil
+				}
+
+				if len(batch) >= batchSize {
+					return flush(false)
+				}
+				return nil
+			})
+			if err == nil {
+				err = flush(true)
+			}
+			if err != nil && !errors.Is(err, errStopImport) {
+				return err
 			}
 
-			if len(imported) == 1 {
-				cmdx.PrintRow(cmd, (*outputIdentity)(&imported[0]))
-			} else {
-				cmdx.PrintTable(cmd, &outputIdentityCollection{Identities: imported})
+			if dryRun {
+				if len(failed) != 0 {
+					return cmdx.FailSilently(cmd)
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "All %d identities are valid.\n", validated)
+				return nil
 			}
-			cmdx.PrintErrors(cmd, failed)
 
 			if len(failed) != 0 {
 				return cmdx.FailSilently(cmd)
 			}
 
+			// The import is complete, so there is nothing left to resume.
+			if checkpointPath != "" {
+				if err := os.Remove(checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return errors.WithStack(err)
+				}
+			}
+
 			return nil
 		},
 	}
+	c.Flags().String("transform", "", "A Jsonnet file which maps each identity before it is imported.")
+	c.Flags().Bool("dry-run", false, "Transform and validate the identities without importing them.")
+	c.Flags().String("checkpoint", "", "The file to store the import progress in, allowing to resume an interrupted import.")
+	c.Flags().Int("batch-size", 100, fmt.Sprintf("The number of identities to import per request, at most %d.", maxImportBatchSize))
+	return c
+}
+
+var errStopImport = errors.New("stop import")
+
+// patchError converts the error of a failed identity patch into an error.
+func patchError(raw interface{}) error {
+	var e struct {
+		Message string `json:"message"`
+		Reason  string `json:"reason"`
+	}
+	if b, err := json.Marshal(raw); err == nil {
+		_ = json.Unmarshal(b, &e)
+	}
+	if e.Reason != "" {
+		return errors.New(e.Reason)
+	} else if e.Message != "" {
+		return errors.New(e.Message)
+	}
+	return errors.New("the identity could not be imported")
 }
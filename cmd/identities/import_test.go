@@ -8,6 +8,8 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/ory/kratos/identity"
@@ -123,4 +125,130 @@ func TestImportCmd(t *testing.T) {
 		_, err = reg.Persister().GetIdentity(context.Background(), id, identity.ExpandNothing)
 		assert.NoError(t, err)
 	})
+
+	writeFile := func(t *testing.T, name, content string) string {
+		fn := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(fn, []byte(content), 0600))
+		return fn
+	}
+
+	assertImported := func(t *testing.T, stdOut string, n int) {
+		// Every batch is printed on its own line, and a single identity is printed as an object.
+		var ids []gjson.Result
+		for _, line := range strings.Split(strings.TrimSpace(stdOut), "\n") {
+			if gjson.Parse(line).IsObject() {
+				ids = append(ids, gjson.Get(line, "id"))
+			} else {
+				ids = append(ids, gjson.Get(line, "#.id").Array()...)
+			}
+		}
+		require.Len(t, ids, n, stdOut)
+		for _, raw := range ids {
+			id, err := uuid.FromString(raw.String())
+			require.NoError(t, err)
+			_, err = reg.Persister().GetIdentity(context.Background(), id, identity.ExpandNothing)
+			assert.NoError(t, err)
+		}
+	}
+
+	t.Run("case=imports identities from ndjson", func(t *testing.T) {
+		fn := writeFile(t, "identities.ndjson", `{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+
+{"schema_id":"default","traits":{}}
+`)
+		assertImported(t, cmd.ExecNoErr(t, fn), 3)
+	})
+
+	t.Run("case=transforms identities", func(t *testing.T) {
+		fn := writeFile(t, "identities.ndjson", `{"id":"not-imported","schema":"default","traits":{"name":"a"}}
+{"id":"not-imported","schema":"default","traits":{"name":"b"}}
+{"id":"not-imported","schema":"default","traits":{"name":"skip"}}
+`)
+		transform := writeFile(t, "transform.jsonnet", `local identity = std.extVar('identity');
+if identity.traits.name == 'skip' then null else {
+  schema_id: identity.schema,
+  traits: { testKey: identity.traits.name },
+}`)
+
+		stdOut := cmd.ExecNoErr(t, "--transform", transform, fn)
+		assertImported(t, stdOut, 2)
+		assert.ElementsMatch(t, []string{"a", "b"}, []string{gjson.Get(stdOut, "0.traits.testKey").String(), gjson.Get(stdOut, "1.traits.testKey").String()})
+	})
+
+	t.Run("case=validates identities in dry-run", func(t *testing.T) {
+		before, err := reg.Persister().CountIdentities(context.Background())
+		require.NoError(t, err)
+
+		valid := writeFile(t, "valid.ndjson", `{"schema_id":"default","traits":{"testKey":"a"}}
+{"schema_id":"default","traits":{}}
+`)
+		stdOut := cmd.ExecNoErr(t, "--dry-run", valid)
+		assert.Contains(t, stdOut, "All 2 identities are valid.")
+
+		invalid := writeFile(t, "invalid.ndjson", `{"schema_id":"default","traits":{"testKey":"a"}}
+{"schema_id":"default","traits":{"unknown":"b"}}
+`)
+		_, stdErr, err := cmd.Exec(nil, "--dry-run", invalid)
+		require.Error(t, err)
+		assert.Contains(t, stdErr, invalid+"[1]: not valid")
+
+		after, err := reg.Persister().CountIdentities(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("case=resumes from checkpoint", func(t *testing.T) {
+		fn := writeFile(t, "identities.ndjson", `{"schema_id":"default","traits":{}}
+{"schema_id":"does-not-exist","traits":{}}
+{"schema_id":"default","traits":{}}
+`)
+		checkpoint := filepath.Join(t.TempDir(), "import.checkpoint")
+
+		stdOut, _, err := cmd.Exec(nil, "--checkpoint", checkpoint, fn)
+		require.Error(t, err, "the import stops after the batch with the identity which can not be imported")
+		assertImported(t, stdOut, 2)
+		raw, err := os.ReadFile(checkpoint)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, gjson.GetBytes(raw, "processed."+gjson.Escape(fn)).Int(), "%s", raw)
+		assert.Equal(t, "[2]", gjson.GetBytes(raw, "imported."+gjson.Escape(fn)).Raw, "%s", raw)
+
+		require.NoError(t, os.WriteFile(fn, []byte(`{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+`), 0600))
+		assertImported(t, cmd.ExecNoErr(t, "--checkpoint", checkpoint, fn), 1)
+		assert.NoFileExists(t, checkpoint, "the checkpoint is removed once the import is complete")
+	})
+
+	t.Run("case=imports in batches", func(t *testing.T) {
+		fn := writeFile(t, "identities.ndjson", `{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+{"schema_id":"does-not-exist","traits":{}}
+{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+`)
+		checkpoint := filepath.Join(t.TempDir(), "import.checkpoint")
+
+		stdOut, _, err := cmd.Exec(nil, "--batch-size", "2", "--checkpoint", checkpoint, fn)
+		require.Error(t, err)
+		assert.Len(t, strings.Split(strings.TrimSpace(stdOut), "\n"), 2, "the identities are printed after every batch: %s", stdOut)
+		assertImported(t, stdOut, 3)
+		raw, err := os.ReadFile(checkpoint)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, gjson.GetBytes(raw, "processed."+gjson.Escape(fn)).Int(), "%s", raw)
+		assert.Equal(t, "[3]", gjson.GetBytes(raw, "imported."+gjson.Escape(fn)).Raw, "the batch after the failing one is not imported: %s", raw)
+
+		require.NoError(t, os.WriteFile(fn, []byte(`{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+{"schema_id":"default","traits":{}}
+`), 0600))
+		assertImported(t, cmd.ExecNoErr(t, "--batch-size", "2", "--checkpoint", checkpoint, fn), 2)
+		assert.NoFileExists(t, checkpoint)
+
+		_, _, err = cmd.Exec(nil, "--batch-size", "501", fn)
+		require.Error(t, err)
+	})
 }
@@ -0,0 +1,331 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identities
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/flagx"
+	"github.com/ory/x/jsonschemax"
+
+	"github.com/ory/kratos/embedx"
+	"github.com/ory/kratos/selfservice/strategy/password"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+)
+
+// NewValidateIdentitySchemaCmd represents the validate identity-schema command
+func NewValidateIdentitySchemaCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "identity-schema path/to/identity.schema.json",
+		Short: "Validate an identity schema and preview its registration form",
+		Example: `Validate an identity schema and check that a sample identity satisfies it:
+
+	{{ .CommandPath }} identity.schema.json --with-sample traits.json`,
+		Long: `Validate an identity schema against the identity meta schema, without requiring a running Ory Kratos instance.
+
+When a sample is given using --with-sample, the traits in the sample file are validated against the identity schema as well.
+
+If the identity schema is valid, the UI nodes which the password registration method renders for it are printed, filled with the values of the sample.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schemaURL, err := identitySchemaURL(args[0])
+			if err != nil {
+				return err
+			}
+
+			raw, err := loadIdentitySchema(cmd, schemaURL)
+			if err != nil {
+				return err
+			}
+
+			if err := validateIdentitySchema(cmd.Context(), raw); err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: not valid\n", args[0])
+				jsonschemax.FormatValidationErrorForCLI(cmd.ErrOrStderr(), raw, err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			var sample []byte
+			if fn := flagx.MustGetString(cmd, "with-sample"); fn != "" {
+				sample, err = os.ReadFile(fn)
+				if err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not open sample file: %s\n", fn, err)
+					return cmdx.FailSilently(cmd)
+				}
+
+				s, err := jsonschema.NewCompiler().Compile(cmd.Context(), schemaURL)
+				if err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not compile the identity schema: %s\n", args[0], err)
+					return cmdx.FailSilently(cmd)
+				}
+
+				payload := append(append([]byte(`{"traits":`), sample...), '}')
+				if err := s.Validate(bytes.NewReader(payload)); err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: not valid\n", fn)
+					jsonschemax.FormatValidationErrorForCLI(cmd.ErrOrStderr(), payload, err)
+					return cmdx.FailSilently(cmd)
+				}
+			}
+
+			nodes, err := previewRegistrationNodes(cmd, schemaURL, sample)
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not render the registration form: %s\n", args[0], err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			cmdx.PrintTable(cmd, &outputNodeCollection{Nodes: nodes})
+			return nil
+		},
+	}
+	c.Flags().String("with-sample", "", "A JSON file containing traits which are validated against the identity schema.")
+	return c
+}
+
+// NewLintIdentitySchemaCmd represents the lint identity-schema command
+func NewLintIdentitySchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "identity-schema path/to/identity.schema.json [more/identity.schema.json]",
+		Short: "Lint identity schemas",
+		Long: `Lints identity schemas and exits with a status code of 1 when errors are detected.
+
+Besides validating the identity schema against the identity meta schema, the linter warns about
+- keywords in the traits which Ory Kratos does not render in forms, such as "oneOf" or "if",
+- identity schemas without any trait which is used as an identifier for a credential,
+- identifiers which are not strings, and
+- traits used for verification or recovery via email which are not formatted as email.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var failed bool
+			for _, fn := range args {
+				schemaURL, err := identitySchemaURL(fn)
+				if err != nil {
+					return err
+				}
+
+				raw, err := loadIdentitySchema(cmd, schemaURL)
+				if err != nil {
+					return err
+				}
+
+				issues := LintIdentitySchema(cmd.Context(), raw)
+				for _, issue := range issues {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s#%s: %s: %s\n", fn, issue.Pointer, issue.Severity, issue.Message)
+					failed = failed || issue.Severity == LintSeverityError
+				}
+			}
+
+			if failed {
+				return cmdx.FailSilently(cmd)
+			}
+			return nil
+		},
+	}
+}
+
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+)
+
+// LintIssue is a problem found in an identity schema.
+type LintIssue struct {
+	Severity string
+	// Pointer is the JSON pointer to the offending part of the identity schema.
+	Pointer string
+	Message string
+}
+
+// unsupportedTraitKeywords are JSON Schema keywords which are not considered when the forms for
+// the traits are rendered. Traits defined within them do not show up in any form.
+var unsupportedTraitKeywords = []string{
+	"oneOf", "anyOf", "allOf", "not", "if", "then", "else",
+	"patternProperties", "dependencies", "dependentSchemas", "dependentRequired", "unevaluatedProperties",
+}
+
+// LintIdentitySchema returns the problems found in the raw identity schema.
+func LintIdentitySchema(ctx context.Context, raw []byte) (issues []LintIssue) {
+	if err := validateIdentitySchema(ctx, raw); err != nil {
+		var ve *jsonschema.ValidationError
+		if !errors.As(err, &ve) {
+			return []LintIssue{{Severity: LintSeverityError, Message: err.Error()}}
+		}
+		for _, cause := range leafValidationErrors(ve) {
+			issues = append(issues, LintIssue{Severity: LintSeverityError, Pointer: cause.InstancePtr, Message: cause.Message})
+		}
+		return issues
+	}
+
+	var identifiers int
+	walkTraits(gjson.GetBytes(raw, "properties.traits"), "/properties/traits", func(ptr string, trait gjson.Result) {
+		for _, keyword := range unsupportedTraitKeywords {
+			if trait.Get(gjson.Escape(keyword)).Exists() {
+				issues = append(issues, LintIssue{
+					Severity: LintSeverityWarning,
+					Pointer:  ptr + "/" + keyword,
+					Message:  fmt.Sprintf("The keyword %q is not supported when rendering forms, traits defined in it are not shown to users.", keyword),
+				})
+			}
+		}
+
+		ext := trait.Get(gjson.Escape("ory.sh/kratos"))
+		ext.Get("credentials").ForEach(func(credential, config gjson.Result) bool {
+			if !config.Get("identifier").Bool() {
+				return true
+			}
+			identifiers++
+			if t := trait.Get("type").String(); t != "string" {
+				issues = append(issues, LintIssue{
+					Severity: LintSeverityWarning,
+					Pointer:  ptr,
+					Message:  fmt.Sprintf("The trait is used as %s identifier but is of type %q instead of \"string\".", credential.String(), t),
+				})
+			}
+			return true
+		})
+
+		for _, flow := range []string{"verification", "recovery"} {
+			if ext.Get(flow+".via").String() == "email" && trait.Get("format").String() != "email" {
+				issues = append(issues, LintIssue{
+					Severity: LintSeverityWarning,
+					Pointer:  ptr,
+					Message:  fmt.Sprintf("The trait is used for %s via email but does not have \"format\": \"email\".", flow),
+				})
+			}
+		}
+	})
+
+	if identifiers == 0 {
+		issues = append(issues, LintIssue{
+			Severity: LintSeverityWarning,
+			Pointer:  "/properties/traits",
+			Message:  `No trait is used as an identifier ("ory.sh/kratos": {"credentials": {"<method>": {"identifier": true}}}), users can only sign in using social sign in.`,
+		})
+	}
+
+	return issues
+}
+
+// walkTraits calls fn for the traits schema and all nested schemas of objects and arrays.
+func walkTraits(s gjson.Result, ptr string, fn func(ptr string, s gjson.Result)) {
+	if !s.IsObject() {
+		return
+	}
+	fn(ptr, s)
+
+	var names []string
+	s.Get("properties").ForEach(func(name, _ gjson.Result) bool {
+		names = append(names, name.String())
+		return true
+	})
+	for _, name := range names {
+		walkTraits(s.Get("properties."+gjson.Escape(name)), ptr+"/properties/"+escapePointer(name), fn)
+	}
+	walkTraits(s.Get("items"), ptr+"/items", fn)
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func leafValidationErrors(ve *jsonschema.ValidationError) (leafs []*jsonschema.ValidationError) {
+	if len(ve.Causes) == 0 {
+		return []*jsonschema.ValidationError{ve}
+	}
+	for _, cause := range ve.Causes {
+		leafs = append(leafs, leafValidationErrors(cause)...)
+	}
+	sort.SliceStable(leafs, func(i, j int) bool {
+		return leafs[i].InstancePtr < leafs[j].InstancePtr
+	})
+	return leafs
+}
+
+var identityMetaSchema *jsonschema.Schema
+
+// validateIdentitySchema validates the raw identity schema against the identity meta schema.
+func validateIdentitySchema(ctx context.Context, raw []byte) error {
+	if identityMetaSchema == nil {
+		c := jsonschema.NewCompiler()
+		if err := embedx.AddSchemaResources(c, embedx.IdentityMeta); err != nil {
+			return err
+		}
+		s, err := c.Compile(ctx, embedx.IdentityMeta.GetSchemaID())
+		if err != nil {
+			return errors.Wrap(err, "Could not compile the identity meta schema. This is an error with the binary you use and should be reported. Thanks ;)")
+		}
+		identityMetaSchema = s
+	}
+	return identityMetaSchema.Validate(bytes.NewReader(raw))
+}
+
+// identitySchemaURL returns the URL of the identity schema, which is either given as URL or as
+// path to a local file.
+func identitySchemaURL(location string) (string, error) {
+	if u, err := url.Parse(location); err == nil && len(u.Scheme) > 1 {
+		return location, nil
+	}
+	abs, err := filepath.Abs(location)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return "file://" + filepath.ToSlash(abs), nil
+}
+
+func loadIdentitySchema(cmd *cobra.Command, schemaURL string) ([]byte, error) {
+	r, err := jsonschema.LoadURL(cmd.Context(), schemaURL)
+	if err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not load identity schema: %s\n", schemaURL, err)
+		return nil, cmdx.FailSilently(cmd)
+	}
+	defer r.Close()
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%s: Could not read identity schema: %s\n", schemaURL, err)
+		return nil, cmdx.FailSilently(cmd)
+	}
+	return raw, nil
+}
+
+// previewRegistrationNodes returns the nodes the password registration method renders for the
+// identity schema, filled with the traits from the sample.
+func previewRegistrationNodes(cmd *cobra.Command, schemaURL string, sample []byte) (node.Nodes, error) {
+	traits, err := container.NodesFromJSONSchema(cmd.Context(), node.PasswordGroup, schemaURL, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := container.New("")
+	for _, n := range traits {
+		c.SetNode(n)
+	}
+	if len(sample) > 0 {
+		c.UpdateNodeValuesFromJSON(sample, "traits", node.PasswordGroup)
+	}
+	c.Nodes.Upsert(password.NewPasswordNode("password", node.InputAttributeAutocompleteNewPassword))
+	c.Nodes.Append(node.NewInputField("method", "password", node.PasswordGroup, node.InputAttributeTypeSubmit).WithMetaLabel(text.NewInfoRegistration()))
+
+	if err := c.Nodes.SortBySchema(cmd.Context(),
+		node.SortBySchema(schemaURL),
+		node.SortUpdateOrder(node.PasswordLoginOrder),
+	); err != nil {
+		return nil, err
+	}
+	return c.Nodes, nil
+}
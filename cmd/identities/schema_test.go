@@ -0,0 +1,144 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identities_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/x/cmdx"
+
+	"github.com/ory/kratos/cmd/identities"
+)
+
+const lintTestSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "traits": {
+      "type": "object",
+      "properties": {
+        "email": {
+          "type": "string",
+          "title": "E-Mail",
+          "ory.sh/kratos": {
+            "credentials": { "password": { "identifier": true } },
+            "verification": { "via": "email" }
+          }
+        },
+        "age": {
+          "type": "integer",
+          "ory.sh/kratos": {
+            "credentials": { "code": { "identifier": true, "via": "email" } }
+          }
+        },
+        "name": {
+          "type": "object",
+          "properties": {
+            "first": { "type": "string" }
+          },
+          "oneOf": [{ "required": ["first"] }]
+        }
+      },
+      "required": ["email"]
+    }
+  }
+}`
+
+func writeSchema(t *testing.T, content string) string {
+	fn := filepath.Join(t.TempDir(), "identity.schema.json")
+	require.NoError(t, os.WriteFile(fn, []byte(content), 0600))
+	return fn
+}
+
+func TestLintIdentitySchema(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("case=reports schema errors", func(t *testing.T) {
+		issues := identities.LintIdentitySchema(ctx, []byte(`{"properties":{"traits":{"type":"object","properties":{"email":{"type":"string","ory.sh/kratos":{"credentials":{"unknown":{}}}}}}}}`))
+		require.NotEmpty(t, issues)
+		for _, issue := range issues {
+			assert.Equal(t, identities.LintSeverityError, issue.Severity)
+		}
+	})
+
+	t.Run("case=reports warnings", func(t *testing.T) {
+		var actual []string
+		for _, issue := range identities.LintIdentitySchema(ctx, []byte(lintTestSchema)) {
+			assert.Equal(t, identities.LintSeverityWarning, issue.Severity)
+			actual = append(actual, issue.Pointer)
+		}
+		assert.ElementsMatch(t, []string{
+			"/properties/traits/properties/email",
+			"/properties/traits/properties/age",
+			"/properties/traits/properties/name/oneOf",
+		}, actual)
+	})
+
+	t.Run("case=warns about missing identifiers", func(t *testing.T) {
+		issues := identities.LintIdentitySchema(ctx, []byte(`{"properties":{"traits":{"type":"object","properties":{"name":{"type":"string"}}}}}`))
+		require.Len(t, issues, 1)
+		assert.Contains(t, issues[0].Message, "No trait is used as an identifier")
+	})
+
+	t.Run("case=command fails only on errors", func(t *testing.T) {
+		cmd := &cmdx.CommandExecuter{New: identities.NewLintIdentitySchemaCmd}
+
+		_, stdErr, err := cmd.Exec(nil, writeSchema(t, lintTestSchema))
+		require.NoError(t, err)
+		assert.Contains(t, stdErr, `The keyword "oneOf" is not supported`)
+
+		_, _, err = cmd.Exec(nil, writeSchema(t, `{"properties":{}}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestValidateIdentitySchemaCmd(t *testing.T) {
+	cmd := &cmdx.CommandExecuter{
+		New: func() *cobra.Command {
+			c := identities.NewValidateIdentitySchemaCmd()
+			cmdx.RegisterFormatFlags(c.Flags())
+			return c
+		},
+		PersistentArgs: []string{"--" + cmdx.FlagFormat, string(cmdx.FormatJSON)},
+	}
+	schema := writeSchema(t, lintTestSchema)
+
+	t.Run("case=renders registration nodes", func(t *testing.T) {
+		stdOut := cmd.ExecNoErr(t, schema)
+		assert.Equal(t, []any{"traits.email", "password", "traits.age", "traits.name.first", "method"}, gjson.Get(stdOut, "#.attributes.name").Value(), stdOut)
+		assert.Equal(t, "E-Mail", gjson.Get(stdOut, "0.meta.label.text").String(), stdOut)
+		assert.True(t, gjson.Get(stdOut, "0.attributes.required").Bool(), stdOut)
+	})
+
+	t.Run("case=validates and renders sample", func(t *testing.T) {
+		sample := filepath.Join(t.TempDir(), "traits.json")
+		require.NoError(t, os.WriteFile(sample, []byte(`{"email":"foo@ory.sh","age":42}`), 0600))
+
+		stdOut := cmd.ExecNoErr(t, schema, "--with-sample", sample)
+		assert.Equal(t, "foo@ory.sh", gjson.Get(stdOut, `#(attributes.name=="traits.email").attributes.value`).String(), stdOut)
+	})
+
+	t.Run("case=fails on invalid sample", func(t *testing.T) {
+		sample := filepath.Join(t.TempDir(), "traits.json")
+		require.NoError(t, os.WriteFile(sample, []byte(`{"age":42}`), 0600))
+
+		_, stdErr, err := cmd.Exec(nil, schema, "--with-sample", sample)
+		require.Error(t, err)
+		assert.Contains(t, stdErr, sample+": not valid")
+	})
+
+	t.Run("case=fails on invalid schema", func(t *testing.T) {
+		_, stdErr, err := cmd.Exec(nil, writeSchema(t, `{"properties":{}}`))
+		require.Error(t, err)
+		assert.Contains(t, stdErr, "not valid")
+	})
+}
@@ -30,6 +30,7 @@ func NewValidateCmd() *cobra.Command {
 		Short: "Validate resources",
 	}
 	cmd.AddCommand(NewValidateIdentityCmd())
+	cmd.AddCommand(NewValidateIdentitySchemaCmd())
 	cliclient.RegisterClientFlags(cmd.PersistentFlags())
 	cmdx.RegisterFormatFlags(cmd.PersistentFlags())
 	return cmd
@@ -35,13 +35,23 @@ func NewMigrateSQLDownCmd(opts ...driver.RegistryOption) *cobra.Command {
 }
 
 func NewMigrateSQLUpCmd(opts ...driver.RegistryOption) *cobra.Command {
-	return popx.NewMigrateSQLUpCmd("kratos", func(cmd *cobra.Command, args []string) error {
+	return registerPhaseFlag(popx.NewMigrateSQLUpCmd("kratos", func(cmd *cobra.Command, args []string) error {
 		return cliclient.NewMigrateHandler().MigrateSQLUp(cmd, args, opts...)
-	})
+	}))
 }
 
 func NewMigrateSQLStatusCmd(opts ...driver.RegistryOption) *cobra.Command {
-	return popx.NewMigrateSQLStatusCmd("kratos", func(cmd *cobra.Command, args []string) error {
+	return registerPhaseFlag(popx.NewMigrateSQLStatusCmd("kratos", func(cmd *cobra.Command, args []string) error {
 		return cliclient.NewMigrateHandler().MigrateSQLStatus(cmd, args, opts...)
-	})
+	}))
+}
+
+// registerPhaseFlag registers the --phase flag which allows upgrading without downtime:
+//
+//  1. Apply the expand phase, which is compatible with the running release.
+//  2. Roll out the new release.
+//  3. Apply the contract phase once the previous release no longer runs.
+func registerPhaseFlag(cmd *cobra.Command) *cobra.Command {
+	cmd.Flags().String("phase", "", `Only consider migrations of this phase, either "expand" or "contract". If not set, all migrations are considered.`)
+	return cmd
 }
@@ -0,0 +1,46 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package ops
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ory/kratos/cmd/cliclient"
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/configx"
+)
+
+// NewIssueAdminTokenCmd represents the issue-admin-token command
+func NewIssueAdminTokenCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "issue-admin-token [<database-url>]",
+		Short: "Issue a short-lived admin API token",
+		Long: `Issues a one-time admin API token which grants all scopes for a single request before it expires.
+
+Use this command to regain access to the admin API if the tokens configured in "serve.admin.api_tokens"
+are unavailable, for example to update them. The token is printed to the standard output once and only its hash is stored. Issuing
+the token and every request authorized with it are recorded in the audit log.
+
+You can read in the database URL using the -e flag, for example:
+	export DSN=...
+	kratos ops issue-admin-token -e --ttl 15m
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := cliclient.NewOpsHandler().IssueAdminToken(cmd, args)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				return cmdx.FailSilently(cmd)
+			}
+			return nil
+		},
+	}
+
+	configx.RegisterFlags(c.PersistentFlags())
+	c.Flags().BoolP("read-from-env", "e", true, "If set, reads the database connection string from the environment variable DSN or config file key dsn.")
+	c.Flags().Duration("ttl", 15*time.Minute, "Set the lifespan of the admin API token")
+	return c
+}
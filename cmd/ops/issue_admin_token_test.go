@@ -0,0 +1,39 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package ops
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_ExecuteIssueAdminTokenFailedDSN(t *testing.T) {
+	cmd := NewIssueAdminTokenCmd()
+	b := bytes.NewBufferString("")
+	cmd.SetOut(b)
+	cmd.SetArgs([]string{"--read-from-env=false"})
+	_ = cmd.Execute()
+	out, err := io.ReadAll(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "expected to get the DSN as an argument") {
+		t.Fatalf("expected \"%s\" got \"%s\"", "expected to get the DSN as an argument", string(out))
+	}
+}
+
+func Test_ExecuteIssueAdminTokenInvalidTTL(t *testing.T) {
+	for _, ttl := range []string{"0s", "-5m", "25h"} {
+		cmd := NewIssueAdminTokenCmd()
+		b := bytes.NewBufferString("")
+		cmd.SetOut(b)
+		cmd.SetArgs([]string{"--ttl", ttl})
+		_ = cmd.Execute()
+		if !strings.Contains(b.String(), `the "ttl" flag must be greater than 0`) {
+			t.Fatalf("expected ttl %s to be rejected, got \"%s\"", ttl, b.String())
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package ops
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/configx"
+)
+
+func NewOpsCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "ops",
+		Short: "Operational helpers which require direct access to the database",
+	}
+	configx.RegisterFlags(c.PersistentFlags())
+	return c
+}
+
+func RegisterCommandRecursive(parent *cobra.Command) {
+	c := NewOpsCmd()
+	parent.AddCommand(c)
+	c.AddCommand(NewIssueAdminTokenCmd())
+}
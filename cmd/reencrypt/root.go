@@ -0,0 +1,25 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package reencrypt
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/configx"
+)
+
+func NewReencryptCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "reencrypt",
+		Short: "Helpers to encrypt data again after rotating secrets",
+	}
+	configx.RegisterFlags(c.PersistentFlags())
+	return c
+}
+
+func RegisterCommandRecursive(parent *cobra.Command) {
+	c := NewReencryptCmd()
+	parent.AddCommand(c)
+	c.AddCommand(NewReencryptTraitsCmd())
+}
@@ -0,0 +1,47 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package reencrypt
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ory/kratos/cmd/cliclient"
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/configx"
+)
+
+// NewReencryptTraitsCmd represents the traits command
+func NewReencryptTraitsCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "traits <database-url>",
+		Short: "Encrypt the identity traits with the current traits secret",
+		Long: `Encrypts all identity traits annotated with "encrypt": true in the identity schema again.
+
+Run this command after adding a new secret to the beginning of "secrets.traits" to encrypt all traits
+with the new secret. Once the command has finished, the old secret can be removed. The command also
+encrypts traits which were newly annotated and decrypts traits which are no longer annotated.
+
+You can read in the database URL using the -e flag, for example:
+	export DSN=...
+	kratos reencrypt traits -e
+### WARNING ###
+Before running this command on an existing database, create a back up!
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := cliclient.NewReencryptHandler().ReencryptTraits(cmd, args)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				return cmdx.FailSilently(cmd)
+			}
+			return nil
+		},
+	}
+
+	configx.RegisterFlags(c.PersistentFlags())
+	c.Flags().BoolP("read-from-env", "e", true, "If set, reads the database connection string from the environment variable DSN or config file key dsn.")
+	c.Flags().IntP("batch-size", "b", 100, "Set the number of identities to load per batch")
+	return c
+}
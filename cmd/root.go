@@ -10,14 +10,19 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/ory/kratos/cmd/bundle"
 	"github.com/ory/kratos/cmd/cleanup"
+	configcmd "github.com/ory/kratos/cmd/config"
 	"github.com/ory/kratos/cmd/courier"
 	"github.com/ory/kratos/cmd/hashers"
 	"github.com/ory/kratos/cmd/identities"
 	"github.com/ory/kratos/cmd/jsonnet"
 	"github.com/ory/kratos/cmd/migrate"
+	"github.com/ory/kratos/cmd/ops"
+	"github.com/ory/kratos/cmd/reencrypt"
 	"github.com/ory/kratos/cmd/remote"
 	"github.com/ory/kratos/cmd/serve"
+	"github.com/ory/kratos/cmd/sql"
 	"github.com/ory/kratos/driver"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/x/cmdx"
@@ -33,16 +38,24 @@ func NewRootCmd(driverOpts ...driver.RegistryOption) (cmd *cobra.Command) {
 	cmdx.EnableUsageTemplating(cmd)
 
 	courier.RegisterCommandRecursive(cmd, nil, driverOpts)
+	cmd.AddCommand(bundle.NewBundleCmd())
 	cmd.AddCommand(identities.NewGetCmd())
 	cmd.AddCommand(identities.NewDeleteCmd())
 	cmd.AddCommand(jsonnet.NewFormatCmd())
 	hashers.RegisterCommandRecursive(cmd)
 	cmd.AddCommand(identities.NewImportCmd())
-	cmd.AddCommand(jsonnet.NewLintCmd())
+	cmd.AddCommand(identities.NewExportCmd())
+	lint := jsonnet.NewLintCmd()
+	lint.AddCommand(identities.NewLintIdentitySchemaCmd())
+	cmd.AddCommand(lint)
 	cmd.AddCommand(identities.NewListCmd())
 	migrate.RegisterCommandRecursive(cmd)
 	serve.RegisterCommandRecursive(cmd, nil, driverOpts)
 	cleanup.RegisterCommandRecursive(cmd)
+	sql.RegisterCommandRecursive(cmd)
+	configcmd.RegisterCommandRecursive(cmd)
+	reencrypt.RegisterCommandRecursive(cmd)
+	ops.RegisterCommandRecursive(cmd)
 	remote.RegisterCommandRecursive(cmd)
 	cmd.AddCommand(identities.NewValidateCmd())
 	cmd.AddCommand(cmdx.Version(&config.Version, &config.Commit, &config.Date))
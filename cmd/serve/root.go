@@ -23,7 +23,7 @@ func NewServeCmd(slOpts []servicelocatorx.Option, dOpts []driver.RegistryOption)
 			ctx := cmd.Context()
 			opts := configx.ConfigOptionsFromContext(ctx)
 			sl := servicelocatorx.NewOptions(slOpts...)
-			d, err := driver.New(ctx, cmd.ErrOrStderr(), sl, dOpts, append(opts, configx.WithFlags(cmd.Flags())))
+			d, err := driver.New(ctx, cmd.ErrOrStderr(), sl, dOpts, append(opts, config.WithFlags(cmd.Flags())))
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,77 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ory/kratos/cmd/cliclient"
+	"github.com/ory/kratos/persistence/sql"
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/configx"
+)
+
+// NewAnalyzeCmd represents the analyze command
+func NewAnalyzeCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "analyze <database-url>",
+		Short: "Report slow query candidates",
+		Long: `Explains the execution plans of the most frequently executed queries, such as session lookups by
+token, identity lookups by identifier and flow lookups, against the live database schema. Queries which
+scan whole tables or sort without an index are reported as slow query candidates.
+
+The database decides whether to use an index based on the statistics of the tables. Run this command
+against a database with production data to get meaningful results.
+
+You can read in the database URL using the -e flag, for example:
+	export DSN=...
+	kratos sql analyze -e
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plans, err := cliclient.NewSQLHandler().Analyze(cmd, args)
+			if err != nil {
+				fmt.Fprintln(cmd.OutOrStdout(), err)
+				return cmdx.FailSilently(cmd)
+			}
+			cmdx.PrintTable(cmd, &outputQueryPlans{Plans: plans})
+			return nil
+		},
+	}
+
+	configx.RegisterFlags(c.PersistentFlags())
+	cmdx.RegisterFormatFlags(c.PersistentFlags())
+	c.Flags().BoolP("read-from-env", "e", true, "If set, reads the database connection string from the environment variable DSN or config file key dsn.")
+	return c
+}
+
+type outputQueryPlans struct {
+	Plans []sql.QueryPlan `json:"plans"`
+}
+
+func (outputQueryPlans) Header() []string {
+	return []string{"QUERY", "SLOW CANDIDATE", "PLAN", "REASON"}
+}
+
+func (c outputQueryPlans) Table() [][]string {
+	rows := make([][]string, len(c.Plans))
+	for i, p := range c.Plans {
+		reason := p.Reason
+		if reason == "" {
+			reason = cmdx.None
+		}
+		rows[i] = []string{p.Name, fmt.Sprintf("%t", p.SlowCandidate), strings.Join(p.Plan, "; "), reason}
+	}
+	return rows
+}
+
+func (c outputQueryPlans) Interface() interface{} {
+	return c.Plans
+}
+
+func (c *outputQueryPlans) Len() int {
+	return len(c.Plans)
+}
@@ -0,0 +1,25 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ory/x/configx"
+)
+
+func NewSQLCmd() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "sql",
+		Short: "Helpers to operate the SQL database",
+	}
+	configx.RegisterFlags(c.PersistentFlags())
+	return c
+}
+
+func RegisterCommandRecursive(parent *cobra.Command) {
+	c := NewSQLCmd()
+	parent.AddCommand(c)
+	c.AddCommand(NewAnalyzeCmd())
+}
@@ -0,0 +1,230 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package configbundle
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+)
+
+// Version is the version of the bundle format.
+const Version = 1
+
+var (
+	// bundledKeys are the configuration keys which are exported as part of a bundle.
+	bundledKeys = []string{
+		config.ViperKeyDefaultIdentitySchemaID,
+		config.ViperKeyCourierTemplates,
+		config.ViperKeySelfServiceOIDCProviders,
+		config.ViperKeyFeatureFlags,
+	}
+
+	// secretProviderKeys are removed from OIDC providers unless secrets are explicitly included.
+	secretProviderKeys = []string{"client_secret", "apple_private_key"}
+)
+
+type (
+	bundleDependencies interface {
+		x.TracingProvider
+		config.Provider
+		schema.IdentitySchemaProvider
+		schema.HandlerProvider
+	}
+
+	// Configuration Bundle
+	//
+	// A bundle contains the identity schemas, courier templates, OIDC providers and
+	// feature flags of an environment.
+	//
+	// swagger:model configBundle
+	Bundle struct {
+		// The version of the bundle format.
+		//
+		// required: true
+		Version int `json:"version"`
+
+		// The time the bundle was exported at.
+		//
+		// required: true
+		CreatedAt time.Time `json:"created_at"`
+
+		// The configuration contained in the bundle. Identity schemas are inlined using
+		// `base64://` URLs, so the configuration can be loaded by any environment.
+		//
+		// required: true
+		Config json.RawMessage `json:"config"`
+	}
+
+	// Signed Configuration Bundle
+	//
+	// swagger:model signedConfigBundle
+	SignedBundle struct {
+		// The JSON-encoded configuration bundle.
+		//
+		// required: true
+		Bundle json.RawMessage `json:"bundle"`
+
+		// The base64-encoded HMAC-SHA256 signature of the bundle, computed using `secrets.bundle`.
+		//
+		// required: true
+		Signature string `json:"signature"`
+	}
+
+	ExportOptions struct {
+		// IncludeSecrets includes the client secrets and private keys of OIDC providers.
+		IncludeSecrets bool
+	}
+)
+
+// Export creates a signed bundle of the configuration in ctx.
+func Export(ctx context.Context, d bundleDependencies, opts ExportOptions) (_ *SignedBundle, err error) {
+	ctx, span := d.Tracer(ctx).Tracer().Start(ctx, "configbundle.Export")
+	defer otelx.End(span, &err)
+
+	conf := []byte("{}")
+	for _, key := range bundledKeys {
+		value := d.Config().GetProvider(ctx).Get(key)
+		if value == nil {
+			continue
+		}
+		if conf, err = sjson.SetBytes(conf, key, value); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if !opts.IncludeSecrets {
+		for k := range gjson.GetBytes(conf, config.ViperKeySelfServiceOIDCProviders).Array() {
+			for _, secret := range secretProviderKeys {
+				if conf, err = sjson.DeleteBytes(conf, config.ViperKeySelfServiceOIDCProviders+"."+strconv.Itoa(k)+"."+secret); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			}
+		}
+	}
+
+	list, err := d.IdentityTraitsSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	schemas := list.List(0, list.Total())
+
+	inlined := make([]map[string]string, 0, len(schemas))
+	for k := range schemas {
+		src, err := d.SchemaHandler().ReadSchema(ctx, &schemas[k])
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := io.ReadAll(io.LimitReader(src, 1024*1024))
+		_ = src.Close()
+		if err != nil {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReason("Unable to read identity schema."))
+		}
+
+		inlined = append(inlined, map[string]string{
+			"id":  schemas[k].ID,
+			"url": "base64://" + base64.StdEncoding.EncodeToString(raw),
+		})
+	}
+
+	if conf, err = sjson.SetBytes(conf, config.ViperKeyIdentitySchemas, inlined); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	bundle, err := json.Marshal(&Bundle{
+		Version:   Version,
+		CreatedAt: time.Now().UTC().Round(time.Second),
+		Config:    conf,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &SignedBundle{
+		Bundle:    bundle,
+		Signature: base64.StdEncoding.EncodeToString(sign(d.Config().SecretsBundle(ctx)[0], bundle)),
+	}, nil
+}
+
+// Verify checks the signature of the bundle using the secrets configured in ctx and returns
+// the decoded bundle.
+func Verify(ctx context.Context, d config.Provider, b *SignedBundle) (*Bundle, error) {
+	signature, err := base64.StdEncoding.DecodeString(b.Signature)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("The bundle signature is not base64 encoded."))
+	}
+
+	var valid bool
+	for _, secret := range d.Config().SecretsBundle(ctx) {
+		if hmac.Equal(signature, sign(secret, b.Bundle)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, errors.WithStack(herodot.ErrForbidden.WithReason("The bundle signature is invalid. Make sure that both environments share the same bundle secrets."))
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(b.Bundle, &bundle); err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReason("The bundle could not be decoded."))
+	}
+
+	if bundle.Version != Version {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The bundle version %d is not supported, expected version %d.", bundle.Version, Version))
+	}
+
+	if !gjson.ValidBytes(bundle.Config) || !gjson.ParseBytes(bundle.Config).IsObject() {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("The bundle configuration must be an object."))
+	}
+
+	return &bundle, nil
+}
+
+// ApplyTo sets the bundled configuration keys of a configuration file to the values of the bundle
+// and returns the updated configuration. Keys which are not part of the bundle are left unchanged.
+func (b *Bundle) ApplyTo(conf []byte) ([]byte, error) {
+	if len(conf) == 0 {
+		conf = []byte("{}")
+	}
+	if !gjson.ValidBytes(conf) || !gjson.ParseBytes(conf).IsObject() {
+		return nil, errors.New("the configuration must be an object")
+	}
+
+	for _, key := range append([]string{config.ViperKeyIdentitySchemas}, bundledKeys...) {
+		value := gjson.GetBytes(b.Config, key)
+		if !value.Exists() {
+			continue
+		}
+
+		var err error
+		if conf, err = sjson.SetRawBytes(conf, key, []byte(value.Raw)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return conf, nil
+}
+
+func sign(secret, message []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(message)
+	return mac.Sum(nil)
+}
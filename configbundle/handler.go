@@ -0,0 +1,160 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package configbundle
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+)
+
+const (
+	AdminRouteConfigBundle       = "/config/bundle"
+	AdminRouteConfigBundleVerify = AdminRouteConfigBundle + "/verify"
+)
+
+type (
+	handlerDependencies interface {
+		bundleDependencies
+		x.WriterProvider
+		x.LoggingProvider
+		x.CSRFProvider
+	}
+	Handler struct {
+		r handlerDependencies
+	}
+	HandlerProvider interface {
+		ConfigBundleHandler() *Handler
+	}
+)
+
+func NewHandler(r handlerDependencies) *Handler {
+	return &Handler{r: r}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	h.r.CSRFHandler().IgnoreGlobs(x.AdminPrefix+AdminRouteConfigBundle, x.AdminPrefix+AdminRouteConfigBundleVerify)
+	public.GET(x.AdminPrefix+AdminRouteConfigBundle, x.RedirectToAdminRoute(h.r))
+	public.POST(x.AdminPrefix+AdminRouteConfigBundleVerify, x.RedirectToAdminRoute(h.r))
+}
+
+func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.GET(AdminRouteConfigBundle, h.exportConfigBundle)
+	admin.POST(AdminRouteConfigBundleVerify, h.verifyConfigBundle)
+}
+
+// Export Configuration Bundle Parameters
+//
+// swagger:parameters exportConfigBundle
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type exportConfigBundle struct {
+	// Include the client secrets and private keys of OIDC providers in the bundle.
+	//
+	// The bundle is signed but not encrypted. Only include secrets if the bundle is
+	// transferred and stored securely. If admin API tokens are configured, the token
+	// requires the `config_secrets:read` scope.
+	//
+	// in: query
+	IncludeSecrets bool `json:"include_secrets"`
+}
+
+// swagger:route GET /admin/config/bundle identity exportConfigBundle
+//
+// # Export Configuration Bundle
+//
+// Exports the identity schemas, courier templates, OIDC providers, and feature flags of this
+// environment as a bundle which is signed using `secrets.bundle`. The bundle can be imported
+// into another environment sharing the same bundle secrets.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: signedConfigBundle
+//	  403: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) exportConfigBundle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	includeSecrets, _ := strconv.ParseBool(r.URL.Query().Get("include_secrets"))
+	if t, ok := x.AdminAPITokenFromContext(r.Context()); ok && includeSecrets && !t.HasScope(config.AdminAPITokenScopeConfigSecretsRead) {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrForbidden.WithReasonf("The admin API token requires the %s scope to export secrets.", config.AdminAPITokenScopeConfigSecretsRead)))
+		return
+	}
+
+	b, err := Export(r.Context(), h.r, ExportOptions{IncludeSecrets: includeSecrets})
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, b)
+}
+
+// Verify Configuration Bundle Parameters
+//
+// swagger:parameters verifyConfigBundle
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type verifyConfigBundle struct {
+	// in: body
+	// required: true
+	Body SignedBundle
+}
+
+// swagger:route POST /admin/config/bundle/verify identity verifyConfigBundle
+//
+// # Verify Configuration Bundle
+//
+// Verifies the signature of a bundle exported by another environment and returns its
+// contents. The returned `config` is a configuration file which can be loaded in addition
+// to this environment's configuration (e.g. `kratos serve -c kratos.yml -c bundle.json`).
+//
+// This endpoint does not change the configuration. Use `kratos bundle import --apply` to
+// apply a verified bundle to the configuration file of the environment.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: configBundle
+//	  400: errorGeneric
+//	  403: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) verifyConfigBundle(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var sb SignedBundle
+	if err := json.NewDecoder(r.Body).Decode(&sb); err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReason("The request body could not be decoded.")))
+		return
+	}
+
+	b, err := Verify(r.Context(), h.r, &sb)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, b)
+}
@@ -0,0 +1,156 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package configbundle_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/kratos/configbundle"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/x"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	_, admin := testhelpers.NewKratosServerWithCSRF(t, reg)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	conf.MustSet(ctx, config.ViperKeySecretsBundle, []string{"bundle-secret-0123456789"})
+	conf.MustSet(ctx, config.ViperKeyFeatureFlagFasterSessionExtend, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceOIDCProviders, []map[string]any{{
+		"id":            "github",
+		"provider":      "github",
+		"client_id":     "client",
+		"client_secret": "secret",
+		"mapper_url":    "file://./stub/oidc.jsonnet",
+	}})
+
+	export := func(t *testing.T, query string) []byte {
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "GET", admin.URL+x.AdminPrefix+configbundle.AdminRouteConfigBundle+query, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		return body
+	}
+
+	verify := func(t *testing.T, signed []byte) ([]byte, *http.Response) {
+		return testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", admin.URL+x.AdminPrefix+configbundle.AdminRouteConfigBundleVerify, json.RawMessage(signed))
+	}
+
+	t.Run("case=exports the configuration", func(t *testing.T) {
+		signed := export(t, "")
+		assert.NotEmpty(t, gjson.GetBytes(signed, "signature").String(), "%s", signed)
+
+		bundle := gjson.GetBytes(signed, "bundle")
+		assert.EqualValues(t, configbundle.Version, bundle.Get("version").Int(), "%s", signed)
+
+		c := bundle.Get("config")
+		assert.True(t, c.Get("feature_flags.faster_session_extend").Bool(), "%s", c.Raw)
+		assert.Equal(t, "github", c.Get("selfservice.methods.oidc.config.providers.0.id").String(), "%s", c.Raw)
+		assert.False(t, c.Get("selfservice.methods.oidc.config.providers.0.client_secret").Exists(), "secrets must not be exported by default: %s", c.Raw)
+
+		expected, err := os.ReadFile("stub/identity.schema.json")
+		require.NoError(t, err)
+		schema := c.Get("identity.schemas.0")
+		assert.Equal(t, config.DefaultIdentityTraitsSchemaID, schema.Get("id").String(), "%s", c.Raw)
+		require.True(t, strings.HasPrefix(schema.Get("url").String(), "base64://"), "%s", c.Raw)
+		actual, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(schema.Get("url").String(), "base64://"))
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("case=exports secrets if requested", func(t *testing.T) {
+		signed := export(t, "?include_secrets=true")
+		assert.Equal(t, "secret", gjson.GetBytes(signed, "bundle.config.selfservice.methods.oidc.config.providers.0.client_secret").String(), "%s", signed)
+	})
+
+	t.Run("case=requires a scope to export secrets", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyAdminAPITokens, []map[string]any{
+			{"id": "support", "token": "support-token-0123456789abcdefghijkl", "scopes": []string{config.AdminAPITokenScopeCredentialsRead}},
+			{"id": "ops", "token": "ops-token-0123456789abcdefghijklmnopqr", "scopes": []string{config.AdminAPITokenScopeConfigSecretsRead}},
+		})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyAdminAPITokens, nil) })
+
+		exportWithToken := func(t *testing.T, token, query string) ([]byte, *http.Response) {
+			req, err := http.NewRequest("GET", admin.URL+x.AdminPrefix+configbundle.AdminRouteConfigBundle+query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			res, err := admin.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			return body, res
+		}
+
+		body, res := exportWithToken(t, "support-token-0123456789abcdefghijkl", "?include_secrets=true")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, "%s", body)
+
+		body, res = exportWithToken(t, "support-token-0123456789abcdefghijkl", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+
+		body, res = exportWithToken(t, "ops-token-0123456789abcdefghijklmnopqr", "?include_secrets=true")
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.Equal(t, "secret", gjson.GetBytes(body, "bundle.config.selfservice.methods.oidc.config.providers.0.client_secret").String(), "%s", body)
+	})
+
+	t.Run("case=verifies a bundle", func(t *testing.T) {
+		signed := export(t, "")
+
+		body, res := verify(t, signed)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.JSONEq(t, gjson.GetBytes(signed, "bundle.config").Raw, gjson.GetBytes(body, "config").Raw)
+	})
+
+	t.Run("case=verifies a bundle signed with a rotated secret", func(t *testing.T) {
+		signed := export(t, "")
+
+		conf.MustSet(ctx, config.ViperKeySecretsBundle, []string{"bundle-secret-abcdefghij", "bundle-secret-0123456789"})
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySecretsBundle, []string{"bundle-secret-0123456789"})
+		})
+
+		body, res := verify(t, signed)
+		assert.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+	})
+
+	t.Run("case=rejects a bundle signed with another secret", func(t *testing.T) {
+		signed := export(t, "")
+
+		conf.MustSet(ctx, config.ViperKeySecretsBundle, []string{"bundle-secret-abcdefghij"})
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySecretsBundle, []string{"bundle-secret-0123456789"})
+		})
+
+		body, res := verify(t, signed)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, "%s", body)
+	})
+
+	t.Run("case=rejects a modified bundle", func(t *testing.T) {
+		signed := export(t, "")
+
+		modified, err := sjson.SetBytes(signed, "bundle.config.feature_flags.faster_session_extend", false)
+		require.NoError(t, err)
+
+		body, res := verify(t, modified)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, "%s", body)
+	})
+
+	t.Run("case=rejects a malformed bundle", func(t *testing.T) {
+		body, res := verify(t, []byte(`{"bundle":{},"signature":"not base64!"}`))
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, "%s", body)
+	})
+}
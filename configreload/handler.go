@@ -0,0 +1,116 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package configreload
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/x"
+)
+
+const AdminRouteConfigReload = "/config/reload"
+
+type (
+	handlerDependencies interface {
+		reloaderDependencies
+		ReloaderProvider
+		x.WriterProvider
+		x.CSRFProvider
+	}
+	Handler struct {
+		r handlerDependencies
+	}
+	HandlerProvider interface {
+		ConfigReloadHandler() *Handler
+	}
+)
+
+// Configuration Reload
+//
+// The identity schemas and OpenID Connect providers which are in use after the configuration was reloaded.
+//
+// swagger:model configReload
+type Result struct {
+	// The IDs of the identity schemas.
+	//
+	// required: true
+	IdentitySchemas []string `json:"identity_schemas"`
+
+	// The IDs of the OpenID Connect providers.
+	//
+	// required: true
+	OIDCProviders []string `json:"oidc_providers"`
+}
+
+func NewHandler(r handlerDependencies) *Handler {
+	return &Handler{r: r}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	h.r.CSRFHandler().IgnorePath(x.AdminPrefix + AdminRouteConfigReload)
+	public.POST(x.AdminPrefix+AdminRouteConfigReload, x.RedirectToAdminRoute(h.r))
+}
+
+func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.POST(AdminRouteConfigReload, h.reloadConfig)
+}
+
+// swagger:route POST /admin/config/reload identity reloadConfig
+//
+// # Reload the Configuration
+//
+// Reads the configuration files and identity schemas again and replaces the configuration
+// of this instance if they are valid. This allows, for example, to add OpenID Connect providers
+// or to change identity schemas without restarting the process. If the new configuration is
+// invalid or changes keys which require a restart (e.g. `serve`), the current configuration
+// remains in use.
+//
+// The configuration is only reloaded on the instance which receives the request.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: configReload
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) reloadConfig(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+
+	if err := h.r.ConfigReloader().Reload(ctx); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	ss, err := h.r.Config().IdentityTraitsSchemas(ctx)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	result := Result{IdentitySchemas: make([]string, 0, len(ss)), OIDCProviders: []string{}}
+	for _, s := range ss {
+		result.IdentitySchemas = append(result.IdentitySchemas, s.ID)
+	}
+	for _, id := range gjson.GetBytes(h.r.Config().SelfServiceStrategy(ctx, string(identity.CredentialsTypeOIDC)).Config, "providers.#.id").Array() {
+		result.OIDCProviders = append(result.OIDCProviders, id.String())
+	}
+
+	h.r.Audit().
+		WithRequest(r).
+		WithField("identity_schemas", result.IdentitySchemas).
+		WithField("oidc_providers", result.OIDCProviders).
+		Info("Reloaded the configuration.")
+
+	h.r.Writer().Write(w, r, &result)
+}
@@ -0,0 +1,158 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package configreload_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/configreload"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/configx"
+)
+
+const configTemplate = `
+serve:
+  public:
+    port: %d
+identity:
+  default_schema_id: default
+  schemas:
+    - id: default
+      url: file://%s
+selfservice:
+  methods:
+    oidc:
+      enabled: true
+      config:
+        providers: %s
+`
+
+func writeSchema(t *testing.T, path, trait string) {
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "traits": {
+      "type": "object",
+      "properties": {
+        "`+trait+`": {
+          "type": "string"
+        }
+      }
+    }
+  }
+}`), 0o600))
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	schemaPath := filepath.Join(dir, "identity.schema.json")
+	writeSchema(t, schemaPath, "email")
+
+	configPath := filepath.Join(dir, "kratos.yml")
+	writeConfig := func(t *testing.T, port int, providers string) {
+		// The file is replaced atomically so that the configuration file watcher does not load a partially written file.
+		tmp := filepath.Join(dir, "kratos.yml.tmp")
+		require.NoError(t, os.WriteFile(tmp, []byte(fmt.Sprintf(configTemplate, port, schemaPath, providers)), 0o600))
+		require.NoError(t, os.Rename(tmp, configPath))
+	}
+	writeConfig(t, 4433, `[]`)
+
+	conf, reg := internal.NewFastRegistryWithMocks(t, configx.WithConfigFiles(configPath))
+	_, admin := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	reload := func(t *testing.T, expectCode int) gjson.Result {
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", admin.URL+x.AdminPrefix+configreload.AdminRouteConfigReload, nil)
+		require.Equal(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	providers := func() string {
+		return gjson.GetBytes(conf.SelfServiceStrategy(ctx, "oidc").Config, "providers.#.id").Raw
+	}
+
+	t.Run("case=adds OIDC providers without a restart", func(t *testing.T) {
+		writeConfig(t, 4433, `[{"id":"github","provider":"github","client_id":"client","client_secret":"secret","mapper_url":"file://./stub/oidc.jsonnet"}]`)
+
+		actual := reload(t, http.StatusOK)
+		assert.Equal(t, `["default"]`, actual.Get("identity_schemas").Raw, "%s", actual.Raw)
+		assert.Equal(t, `["github"]`, actual.Get("oidc_providers").Raw, "%s", actual.Raw)
+		assert.Equal(t, `["github"]`, providers())
+	})
+
+	t.Run("case=reloads changed identity schemas", func(t *testing.T) {
+		t.Cleanup(func() { writeSchema(t, schemaPath, "email") })
+
+		keys, err := schema.GetKeysInOrder(ctx, "file://"+schemaPath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"traits.email"}, keys)
+
+		writeSchema(t, schemaPath, "name")
+		reload(t, http.StatusOK)
+
+		keys, err = schema.GetKeysInOrder(ctx, "file://"+schemaPath)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"traits.name"}, keys)
+	})
+
+	t.Run("case=keeps the configuration if an identity schema is invalid", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(schemaPath, []byte(`{"type":`), 0o600))
+		t.Cleanup(func() { writeSchema(t, schemaPath, "email") })
+
+		actual := reload(t, http.StatusBadRequest)
+		assert.Contains(t, actual.Get("error.reason").String(), "identity schema configuration is invalid", "%s", actual.Raw)
+		assert.Equal(t, `["github"]`, providers())
+	})
+
+	t.Run("case=rejects changes to immutable keys", func(t *testing.T) {
+		github := `[{"id":"github","provider":"github","client_id":"client","client_secret":"secret","mapper_url":"file://./stub/oidc.jsonnet"}]`
+		writeConfig(t, 4434, github)
+		t.Cleanup(func() { writeConfig(t, 4433, github) })
+
+		actual := reload(t, http.StatusBadRequest)
+		assert.Contains(t, actual.Get("error.reason").String(), `immutable configuration key "serve"`, "%s", actual.Raw)
+	})
+}
+
+func TestReloader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	schemaPath := filepath.Join(t.TempDir(), "identity.schema.json")
+	writeSchema(t, schemaPath, "email")
+
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://"+schemaPath)
+	conf.MustSet(ctx, config.ViperKeyIdentitySchemasWatchInterval, "10ms")
+
+	keys, err := schema.GetKeysInOrder(ctx, "file://"+schemaPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"traits.email"}, keys)
+
+	go func() { _ = reg.ConfigReloader().Watch(ctx) }()
+	// Give the watcher time to load the initial identity schemas.
+	time.Sleep(100 * time.Millisecond)
+
+	writeSchema(t, schemaPath, "name")
+	assert.Eventually(t, func() bool {
+		keys, err := schema.GetKeysInOrder(ctx, "file://"+schemaPath)
+		return err == nil && len(keys) == 1 && keys[0] == "traits.name"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,90 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package configreload
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x"
+)
+
+type (
+	reloaderDependencies interface {
+		config.Provider
+		x.LoggingProvider
+	}
+
+	// Reloader reloads the configuration and identity schemas without restarting the process.
+	Reloader struct {
+		r reloaderDependencies
+	}
+	ReloaderProvider interface {
+		ConfigReloader() *Reloader
+	}
+)
+
+func NewReloader(r reloaderDependencies) *Reloader {
+	return &Reloader{r: r}
+}
+
+// Reload reads the configuration from its sources again and replaces the current configuration
+// if it is valid. The identity schemas are loaded again as well.
+func (r *Reloader) Reload(ctx context.Context) error {
+	if err := r.r.Config().Reload(ctx); err != nil {
+		return err
+	}
+
+	schema.ResetKeysInOrderCache()
+	return nil
+}
+
+// Watch periodically checks the identity schemas for changes until the context is canceled.
+// Changed identity schemas are validated before they are used. Changes to the configuration
+// files are picked up by the configuration file watcher.
+func (r *Reloader) Watch(ctx context.Context) error {
+	interval := r.r.Config().IdentitySchemasWatchInterval(ctx)
+	if interval <= 0 {
+		return nil
+	}
+
+	fingerprint, err := r.r.Config().IdentitySchemasFingerprint(ctx)
+	if err != nil {
+		r.r.Logger().WithError(err).Error("Unable to load the identity schemas.")
+	}
+	lastErr := err
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		next, err := r.r.Config().IdentitySchemasFingerprint(ctx)
+		if err != nil {
+			// Only report the error once to not flood the logs.
+			if lastErr == nil || lastErr.Error() != err.Error() {
+				r.r.Logger().WithError(err).
+					Errorf("The changed identity schema configuration is invalid and could not be loaded. Please address the validation errors.")
+			}
+			lastErr = err
+			continue
+		}
+		lastErr = nil
+
+		if next == fingerprint {
+			continue
+		}
+
+		fingerprint = next
+		schema.ResetKeysInOrderCache()
+		r.r.Logger().Info("The identity schemas changed and were reloaded.")
+	}
+}
@@ -52,13 +52,13 @@ func NewContainer(name string, o managerOptions) *Container {
 		ID:         uuid.Nil,
 		Name:       name,
 		IdentityID: x.PointToUUID(o.iid),
-		ExpiresAt:  time.Now().Add(o.ttl).UTC().Truncate(time.Second),
+		ExpiresAt:  x.Now().Add(o.ttl).UTC().Truncate(time.Second),
 		Payload:    sqlxx.NullJSONRawMessage(o.payload),
 	}
 }
 
 func (c *Container) Valid(identity uuid.UUID) error {
-	if c.ExpiresAt.Before(time.Now()) {
+	if c.ExpiresAt.Before(x.Now()) {
 		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("You must restart the flow because the resumable session has expired."))
 	}
 
@@ -98,7 +98,7 @@ func (m *ManagerCookie) Continue(ctx context.Context, w http.ResponseWriter, r *
 		if err := m.d.ContinuityPersister().SetContinuitySessionExpiry(
 			ctx,
 			container.ID,
-			time.Now().UTC().Add(o.setExpiresIn).Truncate(time.Second),
+			x.Now().UTC().Add(o.setExpiresIn).Truncate(time.Second),
 		); err != nil && !errors.Is(err, sqlcon.ErrNoRows) {
 			return nil, err
 		}
@@ -147,7 +147,7 @@ func (m *ManagerCookie) container(ctx context.Context, w http.ResponseWriter, r
 		return nil, errors.WithStack(ErrNotResumable.WithDebugf("Resumable ID from cookie could not be found in the datastore: %+v", err))
 	} else if err != nil {
 		return nil, err
-	} else if container.ExpiresAt.Before(time.Now()) {
+	} else if container.ExpiresAt.Before(x.Now()) {
 		_ = x.SessionUnsetKey(w, r, m.d.ContinuityCookieManager(ctx), CookieName, name)
 		return nil, errors.WithStack(ErrNotResumable.WithDebugf("Resumable session has expired"))
 	}
@@ -5,6 +5,7 @@ package courier
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/ory/x/jsonnetsecure"
@@ -16,22 +17,26 @@ import (
 	"github.com/ory/kratos/courier/template"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/x"
+	"github.com/ory/kratos/x/secretref"
 )
 
 type (
 	Dependencies interface {
 		PersistenceProvider
+		NotifierProvider
 		x.TracingProvider
 		x.LoggingProvider
 		ConfigProvider
 		x.HTTPClientProvider
 		jsonnetsecure.VMProvider
+		secretref.Provider
 	}
 
 	Courier interface {
 		Work(ctx context.Context) error
 		QueueEmail(ctx context.Context, t EmailTemplate) (uuid.UUID, error)
 		QueueSMS(ctx context.Context, t SMSTemplate) (uuid.UUID, error)
+		QueuePush(ctx context.Context, n *PushNotification) (uuid.UUID, error)
 		DispatchQueue(ctx context.Context) error
 		DispatchMessage(ctx context.Context, msg Message) error
 		UseBackoff(b backoff.BackOff)
@@ -51,6 +56,12 @@ type (
 		failOnDispatchError         bool
 		backoff                     backoff.BackOff
 		newEmailTemplateFromMessage func(d template.Dependencies, msg Message) (EmailTemplate, error)
+
+		limitersMu sync.Mutex
+		limiters   map[string]*rateLimiter
+
+		smtpPools       smtpPools
+		pushCredentials pushCredentials
 	}
 )
 
@@ -93,6 +104,12 @@ func (c *courier) UseBackoff(b backoff.BackOff) {
 
 func (c *courier) watchMessages(ctx context.Context, errChan chan error) {
 	wait := c.deps.CourierConfig().CourierWorkerPullWait(ctx)
+	queued, err := c.deps.CourierNotifier().WatchMessageQueue(ctx)
+	if err != nil {
+		errChan <- err
+		return
+	}
+
 	c.backoff.Reset()
 	for {
 		if err := backoff.Retry(func() error {
@@ -101,6 +118,12 @@ func (c *courier) watchMessages(ctx context.Context, errChan chan error) {
 			errChan <- err
 			return
 		}
-		time.Sleep(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		case <-queued:
+		}
 	}
 }
@@ -5,14 +5,33 @@ package courier
 
 import (
 	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
 	"github.com/ory/x/otelx"
 )
 
+var deliveryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "kratos_courier_message_delivery_duration_seconds",
+	Help:    "Time from queueing a courier message until it was sent, labelled with the channel and the template type. Samples link to their trace using exemplars.",
+	Buckets: []float64{.1, .5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+}, []string{"channel", "template_type"})
+
+// DispatcherCollectors returns the Prometheus collectors of the courier dispatcher. They are
+// registered by the registry's metrics setup.
+func DispatcherCollectors() []prometheus.Collector {
+	return []prometheus.Collector{deliveryDuration}
+}
+
 func (c *courier) channels(ctx context.Context, id string) (Channel, error) {
 	cs, err := c.deps.CourierConfig().CourierChannels(ctx)
 	if err != nil {
@@ -25,13 +44,45 @@ func (c *courier) channels(ctx context.Context, id string) (Channel, error) {
 		}
 		switch channel.Type {
 		case "smtp":
-			courierChannel, err := NewSMTPChannelWithCustomTemplates(c.deps, channel.SMTPConfig, c.newEmailTemplateFromMessage)
+			smtpConfig := *channel.SMTPConfig
+			smtpConfig.ConnectionURI, err = c.deps.SecretResolver().Resolve(ctx, smtpConfig.ConnectionURI)
 			if err != nil {
 				return nil, err
 			}
+
+			courierChannel, err := NewSMTPChannelWithCustomTemplates(c.deps, &smtpConfig, c.newEmailTemplateFromMessage)
+			if err != nil {
+				return nil, err
+			}
+			courierChannel.pools = &c.smtpPools
 			return courierChannel, nil
 		case "http":
 			return newHttpChannel(channel.ID, channel.RequestConfig, c.deps), nil
+		case SandboxChannelType:
+			return newSandboxChannel(channel.ID, c.deps), nil
+		case "push":
+			var pushConfig config.PushConfig
+			if channel.PushConfig != nil {
+				pushConfig = *channel.PushConfig
+			}
+			if pushConfig.FCM != nil {
+				fcm := *pushConfig.FCM
+				fcm.ServiceAccountJSON, err = c.deps.SecretResolver().Resolve(ctx, fcm.ServiceAccountJSON)
+				if err != nil {
+					return nil, err
+				}
+				pushConfig.FCM = &fcm
+			}
+			if pushConfig.APNs != nil {
+				apns := *pushConfig.APNs
+				apns.PrivateKey, err = c.deps.SecretResolver().Resolve(ctx, apns.PrivateKey)
+				if err != nil {
+					return nil, err
+				}
+				pushConfig.APNs = &apns
+			}
+
+			return newPushChannel(channel.ID, &pushConfig, c.deps, &c.pushCredentials), nil
 		default:
 			return nil, errors.Errorf("unknown courier channel type: %s", channel.Type)
 		}
@@ -41,7 +92,11 @@ func (c *courier) channels(ctx context.Context, id string) (Channel, error) {
 }
 
 func (c *courier) DispatchMessage(ctx context.Context, msg Message) (err error) {
-	ctx, span := c.deps.Tracer(ctx).Tracer().Start(ctx, "courier.DispatchMessage", trace.WithAttributes(
+	// The message is dispatched outside of the request which queued it. The delivery therefore
+	// continues the request's baggage and links to its trace instead of joining it.
+	queuedCtx := msg.queuedContext()
+	ctx = baggage.ContextWithBaggage(ctx, baggage.FromContext(queuedCtx))
+	ctx, span := c.deps.Tracer(ctx).Tracer().Start(ctx, "courier.DispatchMessage", trace.WithLinks(trace.LinkFromContext(queuedCtx)), trace.WithAttributes(
 		attribute.Stringer("message.id", msg.ID),
 		attribute.Stringer("message.nid", msg.NID),
 		attribute.Stringer("message.type", msg.Type),
@@ -84,11 +139,14 @@ func (c *courier) DispatchMessage(ctx context.Context, msg Message) (err error)
 		return err
 	}
 
+	x.ObserveWithExemplar(ctx, deliveryDuration.WithLabelValues(channel.ID(), string(msg.TemplateType)), time.Since(msg.CreatedAt).Seconds())
 	logger.Debug("Courier sent out message.")
 
 	return nil
 }
 
+// DispatchQueue pulls the next messages from the queue and dispatches them. Every channel has its
+// own pool of workers, so that a slow or failing channel does not hold up the others.
 func (c *courier) DispatchQueue(ctx context.Context) error {
 	maxRetries := c.deps.CourierConfig().CourierMessageRetries(ctx)
 	pullCount := c.deps.CourierConfig().CourierWorkerPullCount(ctx)
@@ -102,59 +160,174 @@ func (c *courier) DispatchQueue(ctx context.Context) error {
 		return err
 	}
 
-	for k, msg := range messages {
-		logger := c.deps.Logger().
-			WithField("message_id", msg.ID).
-			WithField("message_nid", msg.NID).
-			WithField("message_type", msg.Type).
-			WithField("message_template_type", msg.TemplateType).
-			WithField("message_subject", msg.Subject)
-
-		if msg.SendCount > maxRetries {
-			if err := c.deps.CourierPersister().SetMessageStatus(ctx, msg.ID, MessageStatusAbandoned); err != nil {
-				logger.
-					WithError(err).
-					Error(`Unable to set the retried message's status to "abandoned".`)
-				return err
-			}
+	// Messages are grouped by channel in the order they were pulled.
+	var channelIDs []string
+	byChannel := make(map[string][]Message)
+	for _, msg := range messages {
+		id := msg.Channel.String()
+		if _, ok := byChannel[id]; !ok {
+			channelIDs = append(channelIDs, id)
+		}
+		byChannel[id] = append(byChannel[id], msg)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for _, id := range channelIDs {
+		limits := c.deps.CourierConfig().CourierWorkerChannelLimits(ctx, id)
+		limiter := c.rateLimiter(id, limits.RateLimit)
 
-			// Skip the message
+		queue := make(chan Message, len(byChannel[id]))
+		for _, msg := range byChannel[id] {
+			queue <- msg
+		}
+		close(queue)
+
+		for range min(limits.Concurrency, len(byChannel[id])) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for msg := range queue {
+					var err error
+					if err = limiter.wait(ctx); err != nil {
+						// Hand the message back instead of leaving it in the processing state.
+						err = c.requeue(context.WithoutCancel(ctx), msg, time.Now())
+					} else {
+						err = c.dispatchQueued(ctx, msg, maxRetries)
+					}
+					if err != nil {
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// dispatchQueued dispatches a message pulled from the queue. Messages which failed too often are
+// moved to the dead-letter queue, and failed messages are queued again with an exponential backoff.
+func (c *courier) dispatchQueued(ctx context.Context, msg Message, maxRetries int) error {
+	logger := c.deps.Logger().
+		WithField("message_id", msg.ID).
+		WithField("message_nid", msg.NID).
+		WithField("message_type", msg.Type).
+		WithField("message_template_type", msg.TemplateType).
+		WithField("message_subject", msg.Subject)
+
+	if msg.SendCount > maxRetries {
+		if err := c.deps.CourierPersister().SetMessageStatus(ctx, msg.ID, MessageStatusDeadLetter); err != nil {
 			logger.
-				Warnf(`Message was abandoned because it did not deliver after %d attempts`, msg.SendCount)
-		} else if err := c.DispatchMessage(ctx, msg); err != nil {
+				WithError(err).
+				Error(`Unable to set the retried message's status to "dead_letter".`)
+			return err
+		}
+
+		logger.
+			Warnf(`Message was moved to the dead-letter queue because it did not deliver after %d attempts`, msg.SendCount)
+		return nil
+	}
+
+	if c.isUndeliverable(ctx, msg) {
+		if err := c.deps.CourierPersister().SetMessageStatus(ctx, msg.ID, MessageStatusAbandoned); err != nil {
 			logger.
 				WithError(err).
-				Warn(`Unable to dispatch message.`)
-			if err := c.deps.CourierPersister().RecordDispatch(ctx, msg.ID, CourierMessageDispatchStatusFailed, err); err != nil {
-				logger.
-					WithError(err).
-					Error(`Unable to record failure log entry.`)
-				if c.failOnDispatchError {
-					return err
-				}
-			}
+				Error(`Unable to set the undeliverable message's status to "abandoned".`)
+			return err
+		}
+		if err := c.deps.CourierPersister().RecordDispatch(ctx, msg.ID, CourierMessageDispatchStatusFailed, errors.WithStack(ErrRecipientUndeliverable)); err != nil {
+			logger.
+				WithError(err).
+				Error(`Unable to record failure log entry.`)
+		}
 
-			for _, replace := range messages[k:] {
-				if err := c.deps.CourierPersister().SetMessageStatus(ctx, replace.ID, MessageStatusQueued); err != nil {
-					logger.
-						WithError(err).
-						Error(`Unable to reset the failed message's status to "queued".`)
-					if c.failOnDispatchError {
-						return err
-					}
-				}
-			}
+		logger.
+			Warn(`Message was abandoned because the recipient previously bounced or complained.`)
+		return nil
+	}
 
+	if err := c.DispatchMessage(ctx, msg); err != nil {
+		logger.
+			WithError(err).
+			Warn(`Unable to dispatch message.`)
+		if err := c.deps.CourierPersister().RecordDispatch(ctx, msg.ID, CourierMessageDispatchStatusFailed, err); err != nil {
+			logger.
+				WithError(err).
+				Error(`Unable to record failure log entry.`)
 			if c.failOnDispatchError {
 				return err
 			}
-		} else if err := c.deps.CourierPersister().RecordDispatch(ctx, msg.ID, CourierMessageDispatchStatusSuccess, nil); err != nil {
-			logger.
-				WithError(err).
-				Error(`Unable to record success log entry.`)
-			// continue with execution, as the message was successfully dispatched
 		}
+
+		if err := c.requeue(ctx, msg, time.Now().Add(c.retryDelay(ctx, msg.SendCount+1))); err != nil && c.failOnDispatchError {
+			return err
+		}
+
+		if c.failOnDispatchError {
+			return err
+		}
+		return nil
+	}
+
+	if err := c.deps.CourierPersister().RecordDispatch(ctx, msg.ID, CourierMessageDispatchStatusSuccess, nil); err != nil {
+		logger.
+			WithError(err).
+			Error(`Unable to record success log entry.`)
+		// continue with execution, as the message was successfully dispatched
 	}
 
 	return nil
 }
+
+func (c *courier) requeue(ctx context.Context, msg Message, notBefore time.Time) error {
+	if err := c.deps.CourierPersister().RequeueMessage(ctx, msg.ID, notBefore); err != nil {
+		c.deps.Logger().
+			WithError(err).
+			WithField("message_id", msg.ID).
+			Error(`Unable to reset the failed message's status to "queued".`)
+		return err
+	}
+	return nil
+}
+
+// retryDelay returns the delay before the given attempt. It doubles with every attempt, up to the
+// configured maximum, and is randomized by up to 50 percent so that failed messages do not all
+// retry at once.
+func (c *courier) retryDelay(ctx context.Context, attempt int) time.Duration {
+	initial := c.deps.CourierConfig().CourierWorkerRetryInitialInterval(ctx)
+	maxDelay := c.deps.CourierConfig().CourierWorkerRetryMaxInterval(ctx)
+	if initial <= 0 {
+		return 0
+	}
+
+	delay := initial
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+
+	//nolint:gosec // the jitter does not need to be cryptographically secure
+	return delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1))
+}
+
+func (c *courier) isUndeliverable(ctx context.Context, msg Message) bool {
+	undeliverable, err := c.deps.CourierPersister().IsRecipientUndeliverable(ctx, msg.Recipient)
+	if err != nil {
+		// Rather send the message than drop it if the lookup fails.
+		c.deps.Logger().
+			WithError(err).
+			WithField("message_id", msg.ID).
+			Warn("Unable to check whether the message recipient is undeliverable.")
+		return false
+	}
+	return undeliverable
+}
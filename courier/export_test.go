@@ -0,0 +1,16 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"net/url"
+	"testing"
+)
+
+// SetValidSNSSigningCertURLForTest allows fetching Amazon SNS signing certificates from test servers.
+func SetValidSNSSigningCertURLForTest(t testing.TB, valid func(u *url.URL) bool) {
+	previous := validSNSSigningCertURL
+	validSNSSigningCertURL = valid
+	t.Cleanup(func() { validSNSSigningCertURL = previous })
+}
@@ -4,14 +4,20 @@
 package courier
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
 
 	"github.com/ory/herodot"
 	"github.com/ory/x/pagination/keysetpagination"
 	"github.com/ory/x/pagination/migrationpagination"
+	"github.com/ory/x/sqlcon"
 
 	"github.com/julienschmidt/httprouter"
 
@@ -23,6 +29,11 @@ const (
 	AdminRouteCourier      = "/courier"
 	AdminRouteListMessages = AdminRouteCourier + "/messages"
 	AdminRouteGetMessage   = AdminRouteCourier + "/messages/:msgID"
+	AdminRouteRetryMessage = AdminRouteCourier + "/messages/:msgID/retry"
+
+	AdminRouteListSandboxMessages = AdminRouteCourier + "/sandbox/messages"
+
+	RouteDeliveryReceipts = "/self-service/courier/receipts/:provider"
 )
 
 type (
@@ -30,11 +41,15 @@ type (
 		x.WriterProvider
 		x.LoggingProvider
 		x.CSRFProvider
+		x.HTTPClientProvider
 		PersistenceProvider
 		config.Provider
 	}
 	Handler struct {
 		r handlerDependencies
+
+		// snsCerts caches the Amazon SNS signing certificates by URL.
+		snsCerts sync.Map
 	}
 	HandlerProvider interface {
 		CourierHandler() *Handler
@@ -49,11 +64,22 @@ func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
 	h.r.CSRFHandler().IgnoreGlobs(x.AdminPrefix+AdminRouteListMessages, AdminRouteListMessages)
 	public.GET(x.AdminPrefix+AdminRouteListMessages, x.RedirectToAdminRoute(h.r))
 	public.GET(x.AdminPrefix+AdminRouteGetMessage, x.RedirectToAdminRoute(h.r))
+
+	h.r.CSRFHandler().IgnoreGlob(x.AdminPrefix + AdminRouteListMessages + "/*/retry")
+	public.POST(x.AdminPrefix+AdminRouteRetryMessage, x.RedirectToAdminRoute(h.r))
+
+	h.r.CSRFHandler().IgnoreGlobs(x.AdminPrefix+AdminRouteListSandboxMessages, AdminRouteListSandboxMessages)
+	public.GET(x.AdminPrefix+AdminRouteListSandboxMessages, x.RedirectToAdminRoute(h.r))
+
+	h.r.CSRFHandler().IgnoreGlob(strings.Replace(RouteDeliveryReceipts, ":provider", "*", 1))
+	public.POST(RouteDeliveryReceipts, h.receiveDeliveryReceipts)
 }
 
 func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
 	admin.GET(AdminRouteListMessages, h.listCourierMessages)
 	admin.GET(AdminRouteGetMessage, h.getCourierMessage)
+	admin.POST(AdminRouteRetryMessage, h.retryCourierMessage)
+	admin.GET(AdminRouteListSandboxMessages, h.listSandboxMessages)
 }
 
 // Paginated Courier Message List Response
@@ -90,6 +116,11 @@ type ListCourierMessagesParameters struct {
 	// required: false
 	// in: query
 	Recipient string `json:"recipient"`
+
+	// Channels filters out messages based on the channel they are sent through.
+	//
+	// swagger:ignore
+	Channels []string `json:"-"`
 }
 
 // swagger:route GET /admin/courier/messages courier listCourierMessages
@@ -209,3 +240,241 @@ func (h *Handler) getCourierMessage(w http.ResponseWriter, r *http.Request, ps h
 
 	h.r.Writer().Write(w, r, message)
 }
+
+// Retry Courier Message Parameters
+//
+// swagger:parameters retryCourierMessage
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type retryCourierMessage struct {
+	// MessageID is the ID of the message.
+	//
+	// required: true
+	// in: path
+	MessageID string `json:"id"`
+}
+
+// swagger:route POST /admin/courier/messages/{id}/retry courier retryCourierMessage
+//
+// # Retry a Message
+//
+// Queues a message which was moved to the dead-letter queue or abandoned again. The send count of
+// the message is reset.
+//
+//	Produces:
+//	- application/json
+//
+//	Security:
+//		oryAccessToken:
+//
+//	Schemes: http, https
+//
+//	Responses:
+//		200: message
+//		400: errorGeneric
+//		404: errorGeneric
+//		default: errorGeneric
+func (h *Handler) retryCourierMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+	msgID, err := uuid.FromString(ps.ByName("msgID"))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, herodot.ErrBadRequest.WithError(err.Error()).WithDebugf("could not parse parameter {id} as UUID, got %s", ps.ByName("msgID")))
+		return
+	}
+
+	message, err := h.r.CourierPersister().FetchMessage(ctx, msgID)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if message.Status != MessageStatusDeadLetter && message.Status != MessageStatusAbandoned {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Only messages with status %q or %q can be retried, but the message has status %q.", MessageStatusDeadLetter, MessageStatusAbandoned, message.Status)))
+		return
+	}
+
+	if err := h.r.CourierPersister().RetryMessage(ctx, msgID); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Audit().
+		WithRequest(r).
+		WithField("message_id", msgID).
+		Info("An administrator queued a courier message for retry.")
+
+	message, err = h.r.CourierPersister().FetchMessage(ctx, msgID)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if !h.r.Config().IsInsecureDevMode(ctx) {
+		message.Body = "<redacted-unless-dev-mode>"
+	}
+
+	h.r.Writer().Write(w, r, message)
+}
+
+// Receive Courier Delivery Receipts Parameters
+//
+// swagger:parameters receiveCourierDeliveryReceipts
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type receiveCourierDeliveryReceipts struct {
+	// Provider is one of `ses`, `sendgrid`, `mailgun`, or `twilio`.
+	//
+	// required: true
+	// in: path
+	Provider string `json:"provider"`
+
+	// Authorization contains `courier.delivery_receipts.token` as a bearer token or as the password
+	// of HTTP basic authentication. It is required unless the provider's signature is configured.
+	//
+	// in: header
+	Authorization string `json:"Authorization"`
+
+	// MessageID is the ID of the courier message the receipt is about. Use it for providers which
+	// do not return custom data.
+	//
+	// in: query
+	MessageID string `json:"message_id"`
+}
+
+// swagger:route POST /self-service/courier/receipts/{provider} courier receiveCourierDeliveryReceipts
+//
+// # Receive Delivery Receipts
+//
+// Receives delivery, bounce, and complaint callbacks from email and SMS providers and updates the
+// status of the courier messages. If `courier.delivery_receipts.suppress_undeliverable` is enabled,
+// addresses which permanently bounced or complained are marked as undeliverable.
+//
+//	Consumes:
+//	- application/json
+//	- application/x-www-form-urlencoded
+//	- text/plain
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  204: emptyResponse
+//	  400: errorGeneric
+//	  401: errorGeneric
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) receiveDeliveryReceipts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+	if !h.r.Config().CourierDeliveryReceiptsEnabled(ctx) {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("Delivery receipts are disabled.")))
+		return
+	}
+
+	provider := ps.ByName("provider")
+	body, err := readReceiptBody(r)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := h.authenticateDeliveryReceipt(ctx, r, provider, body); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	receipts, confirmURL, err := parseDeliveryReceipts(r, provider, body)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if confirmURL != "" {
+		if err := h.confirmSNSSubscription(ctx, confirmURL); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+	}
+
+	for k := range receipts {
+		if err := h.applyDeliveryReceipt(ctx, &receipts[k]); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// confirmSNSSubscription confirms the subscription of the endpoint to an Amazon SNS topic.
+func (h *Handler) confirmSNSSubscription(ctx context.Context, confirmURL string) error {
+	u, err := url.Parse(confirmURL)
+	if err != nil || u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The Amazon SNS subscription URL is invalid."))
+	}
+
+	res, err := h.r.HTTPClient(ctx).Get(u.String())
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReason("Unable to confirm the Amazon SNS subscription.").WithWrap(err))
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to confirm the Amazon SNS subscription, received status code %d.", res.StatusCode))
+	}
+
+	h.r.Logger().WithField("topic", u.Query().Get("TopicArn")).Info("Confirmed the Amazon SNS subscription for courier delivery receipts.")
+	return nil
+}
+
+func (h *Handler) applyDeliveryReceipt(ctx context.Context, receipt *DeliveryReceipt) error {
+	var (
+		msg *Message
+		err error
+	)
+	if receipt.MessageID != uuid.Nil {
+		msg, err = h.r.CourierPersister().FetchMessage(ctx, receipt.MessageID)
+	} else if receipt.Recipient != "" {
+		msg, err = h.r.CourierPersister().LatestSentMessage(ctx, receipt.Recipient)
+	} else {
+		return nil
+	}
+	if errors.Is(err, sqlcon.ErrNoRows) {
+		h.r.Logger().
+			WithField("message_id", receipt.MessageID).
+			WithSensitiveField("recipient", receipt.Recipient).
+			Debug("Ignoring delivery receipt for an unknown courier message.")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// A delivery receipt does not override an earlier bounce or complaint.
+	if status := receipt.Status(); status != MessageStatusDelivered || msg.Status == MessageStatusSent {
+		if err := h.r.CourierPersister().SetMessageStatus(ctx, msg.ID, status); err != nil {
+			return err
+		}
+	}
+
+	recipient := receipt.Recipient
+	if recipient == "" {
+		recipient = msg.Recipient
+	}
+
+	logger := h.r.Audit().
+		WithField("message_id", msg.ID).
+		WithField("delivery_event", receipt.Event).
+		WithField("delivery_reason", receipt.Reason).
+		WithSensitiveField("recipient", recipient)
+
+	if receipt.Undeliverable() && h.r.Config().CourierDeliveryReceiptsSuppressUndeliverable(ctx) {
+		if err := h.r.CourierPersister().SetRecipientUndeliverable(ctx, recipient); err != nil {
+			return err
+		}
+		logger.Info("Marked the address as undeliverable after it bounced or complained.")
+	} else if receipt.Event != DeliveryEventDelivered {
+		logger.Info("Received a bounce or complaint for a courier message.")
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/pagination/keysetpagination"
+)
+
+// List Sandbox Messages Parameters
+//
+// swagger:parameters listSandboxMessages
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type listSandboxMessages struct {
+	keysetpagination.RequestParameters
+
+	// Recipient filters out messages based on recipient.
+	// If no value is provided, it doesn't take effect on filter.
+	//
+	// required: false
+	// in: query
+	Recipient string `json:"recipient"`
+}
+
+// swagger:route GET /admin/courier/sandbox/messages courier listSandboxMessages
+//
+// # List Sandbox Messages
+//
+// Lists the messages of the courier channels of type `sandbox`. These messages are never sent,
+// which allows end-to-end tests to read verification codes and links from this endpoint instead
+// of an SMTP catcher. Unlike the list messages endpoint, the message bodies are never redacted.
+//
+//	Produces:
+//	- application/json
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: listCourierMessages
+//	  400: errorGeneric
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) listSandboxMessages(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	channels, err := h.r.Config().CourierChannels(r.Context())
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	var filter ListCourierMessagesParameters
+	for _, c := range channels {
+		if c.Type == SandboxChannelType {
+			filter.Channels = append(filter.Channels, c.ID)
+		}
+	}
+	if len(filter.Channels) == 0 {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("No courier channel of type sandbox is configured.")))
+		return
+	}
+	filter.Recipient = r.URL.Query().Get("recipient")
+
+	paginator, err := keysetpagination.Parse(r.URL.Query(), keysetpagination.NewMapPageToken)
+	if err != nil {
+		h.r.Writer().WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	l, tc, nextPage, err := h.r.CourierPersister().ListMessages(r.Context(), filter, paginator)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", fmt.Sprint(tc))
+	u := *r.URL
+	keysetpagination.Header(w, &u, nextPage)
+	h.r.Writer().Write(w, r, l)
+}
@@ -5,11 +5,27 @@ package courier_test
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,13 +34,16 @@ import (
 	"github.com/tidwall/gjson"
 
 	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/courier/template"
 	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/internal"
 	"github.com/ory/kratos/internal/testhelpers"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/ioutilx"
 	"github.com/ory/x/pagination/keysetpagination"
 	"github.com/ory/x/snapshotx"
+	"github.com/ory/x/sqlxx"
 	"github.com/ory/x/urlx"
 
 	"github.com/stretchr/testify/assert"
@@ -284,4 +303,427 @@ func TestHandler(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("handler=retryCourierMessage", func(t *testing.T) {
+		retry := func(t *testing.T, s *httptest.Server, id string, expectCode int) gjson.Result {
+			t.Helper()
+			res, err := s.Client().Post(s.URL+"/admin/courier/messages/"+id+"/retry", "application/json", nil)
+			require.NoError(t, err)
+			body := ioutilx.MustReadAll(res.Body)
+			require.NoError(t, res.Body.Close())
+			assert.Equalf(t, expectCode, res.StatusCode, "%s", body)
+			return gjson.ParseBytes(body)
+		}
+
+		newMessage := func(t *testing.T, status courier.MessageStatus) courier.Message {
+			message := courier.Message{}
+			require.NoError(t, faker.FakeData(&message))
+			message.Type = courier.MessageTypeEmail
+			require.NoError(t, reg.CourierPersister().AddMessage(ctx, &message))
+			require.NoError(t, reg.CourierPersister().IncrementMessageSendCount(ctx, message.ID))
+			require.NoError(t, reg.CourierPersister().SetMessageStatus(ctx, message.ID, status))
+			return message
+		}
+
+		for _, tc := range tss {
+			t.Run("endpoint="+tc.name, func(t *testing.T) {
+				t.Run("case=queues a dead-lettered message again", func(t *testing.T) {
+					message := newMessage(t, courier.MessageStatusDeadLetter)
+
+					body := retry(t, tc.s, message.ID.String(), http.StatusOK)
+					assert.Equal(t, "queued", body.Get("status").String(), "%s", body.Raw)
+					assert.EqualValues(t, 0, body.Get("send_count").Int())
+
+					actual, err := reg.CourierPersister().FetchMessage(ctx, message.ID)
+					require.NoError(t, err)
+					assert.Equal(t, courier.MessageStatusQueued, actual.Status)
+				})
+
+				t.Run("case=rejects messages which were sent", func(t *testing.T) {
+					message := newMessage(t, courier.MessageStatusSent)
+
+					body := retry(t, tc.s, message.ID.String(), http.StatusBadRequest)
+					assert.Contains(t, body.Get("error.reason").String(), `has status "sent"`)
+				})
+
+				t.Run("case=returns an error if no message is found", func(t *testing.T) {
+					retry(t, tc.s, uuid.Nil.String(), http.StatusNotFound)
+				})
+			})
+		}
+	})
+}
+
+func TestSandboxMessages(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	_, adminTS := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	list := func(t *testing.T, qs string, expectCode int) gjson.Result {
+		t.Helper()
+		res, err := adminTS.Client().Get(adminTS.URL + courier.AdminRouteListSandboxMessages + qs)
+		require.NoError(t, err)
+		body := ioutilx.MustReadAll(res.Body)
+		require.NoError(t, res.Body.Close())
+		assert.Equalf(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=returns not found without sandbox channels", func(t *testing.T) {
+		list(t, "", http.StatusNotFound)
+	})
+
+	conf.MustSet(ctx, config.ViperKeyCourierDeliveryStrategy, courier.SandboxChannelType)
+	conf.MustSet(ctx, config.ViperKeyCourierChannels, []map[string]any{{"id": "sms", "type": courier.SandboxChannelType}})
+
+	c, err := reg.Courier(ctx)
+	require.NoError(t, err)
+	c.FailOnDispatchError()
+
+	newMessage := func(t *testing.T, channel, recipient string) courier.Message {
+		message := courier.Message{
+			Type:         courier.MessageTypeEmail,
+			Status:       courier.MessageStatusQueued,
+			Channel:      sqlxx.NullString(channel),
+			Recipient:    recipient,
+			Subject:      "Verify your account",
+			Body:         "Your code is 123456",
+			TemplateType: template.TypeVerificationCodeValid,
+		}
+		require.NoError(t, reg.CourierPersister().AddMessage(ctx, &message))
+		return message
+	}
+
+	email := newMessage(t, "email", "sandbox@ory.sh")
+	sms := newMessage(t, "sms", "+49123456789")
+	require.NoError(t, c.DispatchQueue(ctx))
+
+	for _, id := range []uuid.UUID{email.ID, sms.ID} {
+		actual, err := reg.CourierPersister().FetchMessage(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, courier.MessageStatusSent, actual.Status)
+	}
+
+	// Messages of other channels are not part of the sandbox.
+	newMessage(t, courier.PushChannelID, "sandbox@ory.sh")
+
+	t.Run("case=lists the messages of sandbox channels", func(t *testing.T) {
+		actual := list(t, "", http.StatusOK)
+		assert.ElementsMatch(t, []string{email.ID.String(), sms.ID.String()}, gjson.Get(actual.Raw, "#.id").Value(), "%s", actual.Raw)
+		assert.Equal(t, "Your code is 123456", actual.Get("0.body").String(), "bodies are never redacted")
+	})
+
+	t.Run("case=filters by recipient", func(t *testing.T) {
+		actual := list(t, "?recipient=sandbox@ory.sh", http.StatusOK)
+		require.Len(t, actual.Array(), 1, "%s", actual.Raw)
+		assert.Equal(t, email.ID.String(), actual.Get("0.id").String())
+	})
+}
+
+func TestDeliveryReceipts(t *testing.T) {
+	ctx := testhelpers.WithDefaultIdentitySchemaFromRaw(context.Background(), []byte(`{"type": "object"}`))
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	publicTS, _ := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	const token = "some-receipt-token-value"
+	conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsEnabled, true)
+	conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsToken, token)
+	conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsSuppressUndeliverable, true)
+
+	request := func(t *testing.T, provider, query, contentType, body string, header http.Header) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("POST", publicTS.URL+"/self-service/courier/receipts/"+provider+"?"+query, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header = header.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set("Content-Type", contentType)
+		res, err := publicTS.Client().Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = res.Body.Close() })
+		return res
+	}
+
+	post := func(t *testing.T, provider, query, contentType, body string) *http.Response {
+		t.Helper()
+		return request(t, provider, query, contentType, body, http.Header{"Authorization": {"Bearer " + token}})
+	}
+
+	newSentMessage := func(t *testing.T, recipient string) *courier.Message {
+		t.Helper()
+		m := &courier.Message{Type: courier.MessageTypeEmail, Recipient: recipient, Subject: "subject", Body: "body", TemplateType: "stub"}
+		require.NoError(t, reg.CourierPersister().AddMessage(ctx, m))
+		require.NoError(t, reg.CourierPersister().SetMessageStatus(ctx, m.ID, courier.MessageStatusSent))
+		return m
+	}
+
+	newIdentity := func(t *testing.T, recipient string) *identity.Identity {
+		t.Helper()
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.VerifiableAddresses = []identity.VerifiableAddress{*identity.NewVerifiableEmailAddress(recipient, i.ID)}
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		return i
+	}
+
+	requireStatus := func(t *testing.T, id uuid.UUID, expected courier.MessageStatus) {
+		t.Helper()
+		m, err := reg.CourierPersister().FetchMessage(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, expected, m.Status)
+	}
+
+	requireUndeliverable := func(t *testing.T, recipient string, expected bool) {
+		t.Helper()
+		undeliverable, err := reg.CourierPersister().IsRecipientUndeliverable(ctx, recipient)
+		require.NoError(t, err)
+		assert.Equal(t, expected, undeliverable)
+	}
+
+	t.Run("case=rejects invalid tokens", func(t *testing.T) {
+		res := request(t, "sendgrid", "", "application/json", `[]`, http.Header{"Authorization": {"Bearer invalid"}})
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res = request(t, "sendgrid", "", "application/json", `[]`, nil)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("case=only accepts the token in the authorization header", func(t *testing.T) {
+		res := request(t, "sendgrid", "token="+token, "application/json", `[]`, nil)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		req, err := http.NewRequest("POST", publicTS.URL+"/self-service/courier/receipts/sendgrid", strings.NewReader(`[]`))
+		require.NoError(t, err)
+		req.SetBasicAuth("kratos", token)
+		res, err = publicTS.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+
+	t.Run("case=rejects unknown providers", func(t *testing.T) {
+		res := post(t, "pigeon", "", "application/json", `[]`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("case=sendgrid bounce marks the address as undeliverable", func(t *testing.T) {
+		recipient := testhelpers.RandomEmail()
+		newIdentity(t, recipient)
+		m := newSentMessage(t, recipient)
+
+		res := post(t, "sendgrid", "", "application/json",
+			fmt.Sprintf(`[{"email": %q, "event": "bounce", "type": "bounce", "reason": "550 no such user", "kratos_message_id": %q}]`, recipient, m.ID))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		requireStatus(t, m.ID, courier.MessageStatusBounced)
+		requireUndeliverable(t, recipient, true)
+	})
+
+	snsKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	snsCert, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}, &x509.Certificate{}, &snsKey.PublicKey, snsKey)
+	require.NoError(t, err)
+	snsCertTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: snsCert})
+	}))
+	t.Cleanup(snsCertTS.Close)
+	courier.SetValidSNSSigningCertURLForTest(t, func(u *url.URL) bool { return u.Host == snsCertTS.Listener.Addr().String() })
+
+	signSNS := func(t *testing.T, notification map[string]string) string {
+		t.Helper()
+		notification["SignatureVersion"] = "2"
+		notification["SigningCertURL"] = snsCertTS.URL + "/SimpleNotificationService.pem"
+		var signed strings.Builder
+		for _, key := range []string{"Message", "MessageId", "Subject", "Timestamp", "TopicArn", "Type"} {
+			if v, ok := notification[key]; ok {
+				signed.WriteString(key + "\n" + v + "\n")
+			}
+		}
+		digest := sha256.Sum256([]byte(signed.String()))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, snsKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		notification["Signature"] = base64.StdEncoding.EncodeToString(signature)
+		raw, err := json.Marshal(notification)
+		require.NoError(t, err)
+		return string(raw)
+	}
+
+	newSESDelivery := func(t *testing.T, m *courier.Message, topic string) map[string]string {
+		message := fmt.Sprintf(`{"notificationType": "Delivery", "mail": {"headers": [{"name": %q, "value": %q}]}, "delivery": {"recipients": [%q]}}`, courier.MessageIDHeader, m.ID, m.Recipient)
+		return map[string]string{"Type": "Notification", "MessageId": x.NewUUID().String(), "TopicArn": topic, "Timestamp": time.Now().UTC().Format(time.RFC3339), "Message": message}
+	}
+
+	t.Run("case=ses delivery references the message using the header", func(t *testing.T) {
+		m := newSentMessage(t, testhelpers.RandomEmail())
+
+		res := post(t, "ses", "", "text/plain", signSNS(t, newSESDelivery(t, m, "arn:aws:sns:us-east-1:123456789012:ses")))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		requireStatus(t, m.ID, courier.MessageStatusDelivered)
+	})
+
+	t.Run("case=ses rejects messages without a valid signature", func(t *testing.T) {
+		m := newSentMessage(t, testhelpers.RandomEmail())
+
+		notification, err := json.Marshal(newSESDelivery(t, m, "arn:aws:sns:us-east-1:123456789012:ses"))
+		require.NoError(t, err)
+		res := post(t, "ses", "", "text/plain", string(notification))
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		signed := signSNS(t, newSESDelivery(t, m, "arn:aws:sns:us-east-1:123456789012:ses"))
+		res = post(t, "ses", "", "text/plain", strings.Replace(signed, "Delivery", "Bounce", 1))
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		requireStatus(t, m.ID, courier.MessageStatusSent)
+	})
+
+	t.Run("case=ses accepts allowed topics without the token", func(t *testing.T) {
+		const topic = "arn:aws:sns:us-east-1:123456789012:allowed"
+		conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsSESTopicARNs, []string{topic})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsSESTopicARNs, nil) })
+
+		m := newSentMessage(t, testhelpers.RandomEmail())
+		res := request(t, "ses", "", "text/plain", signSNS(t, newSESDelivery(t, m, "arn:aws:sns:us-east-1:123456789012:other")), nil)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res = request(t, "ses", "", "text/plain", signSNS(t, newSESDelivery(t, m, topic)), nil)
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		requireStatus(t, m.ID, courier.MessageStatusDelivered)
+	})
+
+	t.Run("case=sendgrid verifies the signed event webhook", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+		conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsSendGridVerificationKey, base64.StdEncoding.EncodeToString(public))
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsSendGridVerificationKey, "") })
+
+		sign := func(t *testing.T, timestamp time.Time, body string) http.Header {
+			ts := fmt.Sprint(timestamp.Unix())
+			digest := sha256.Sum256([]byte(ts + body))
+			signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+			require.NoError(t, err)
+			return http.Header{
+				"X-Twilio-Email-Event-Webhook-Signature": {base64.StdEncoding.EncodeToString(signature)},
+				"X-Twilio-Email-Event-Webhook-Timestamp": {ts},
+			}
+		}
+
+		m := newSentMessage(t, testhelpers.RandomEmail())
+		body := fmt.Sprintf(`[{"email": %q, "event": "delivered", "kratos_message_id": %q}]`, m.Recipient, m.ID)
+
+		res := post(t, "sendgrid", "", "application/json", body)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "the token is not sufficient once the signature is configured")
+
+		res = request(t, "sendgrid", "", "application/json", body, sign(t, time.Now().Add(-time.Hour), body))
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		res = request(t, "sendgrid", "", "application/json", body, sign(t, time.Now(), `[]`))
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		requireStatus(t, m.ID, courier.MessageStatusSent)
+
+		res = request(t, "sendgrid", "", "application/json", body, sign(t, time.Now(), body))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		requireStatus(t, m.ID, courier.MessageStatusDelivered)
+	})
+
+	t.Run("case=mailgun verifies the webhook signature", func(t *testing.T) {
+		const signingKey = "some-mailgun-signing-key"
+		conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsMailgunSigningKey, signingKey)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsMailgunSigningKey, "") })
+
+		m := newSentMessage(t, testhelpers.RandomEmail())
+		body := func(key string) string {
+			timestamp, token := fmt.Sprint(time.Now().Unix()), x.NewUUID().String()
+			mac := hmac.New(sha256.New, []byte(key))
+			_, _ = mac.Write([]byte(timestamp + token))
+			return fmt.Sprintf(`{"signature": {"timestamp": %q, "token": %q, "signature": %q}, "event-data": {"event": "delivered", "user-variables": {"kratos_message_id": %q}}}`,
+				timestamp, token, hex.EncodeToString(mac.Sum(nil)), m.ID)
+		}
+
+		res := request(t, "mailgun", "", "application/json", body("some-other-key"), nil)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		requireStatus(t, m.ID, courier.MessageStatusSent)
+
+		res = request(t, "mailgun", "", "application/json", body(signingKey), nil)
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		requireStatus(t, m.ID, courier.MessageStatusDelivered)
+	})
+
+	t.Run("case=twilio verifies the request signature", func(t *testing.T) {
+		const authToken = "some-twilio-auth-token"
+		conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsTwilioAuthToken, authToken)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsTwilioAuthToken, "") })
+
+		m := newSentMessage(t, testhelpers.RandomEmail())
+		query := "message_id=" + m.ID.String()
+		form := url.Values{"MessageStatus": {"delivered"}, "To": {m.Recipient}}
+		sign := func(key string) http.Header {
+			mac := hmac.New(sha1.New, []byte(key))
+			_, _ = mac.Write([]byte(urlx.AppendPaths(conf.SelfPublicURL(ctx), "/self-service/courier/receipts/twilio").String() + "?" + query))
+			_, _ = mac.Write([]byte("MessageStatusdeliveredTo" + m.Recipient))
+			return http.Header{"X-Twilio-Signature": {base64.StdEncoding.EncodeToString(mac.Sum(nil))}}
+		}
+
+		res := request(t, "twilio", query, "application/x-www-form-urlencoded", form.Encode(), sign("some-other-token"))
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		requireStatus(t, m.ID, courier.MessageStatusSent)
+
+		res = request(t, "twilio", query, "application/x-www-form-urlencoded", form.Encode(), sign(authToken))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+		requireStatus(t, m.ID, courier.MessageStatusDelivered)
+	})
+
+	t.Run("case=mailgun complaint is matched by recipient", func(t *testing.T) {
+		recipient := testhelpers.RandomEmail()
+		newIdentity(t, recipient)
+		m := newSentMessage(t, recipient)
+
+		res := post(t, "mailgun", "", "application/json",
+			fmt.Sprintf(`{"event-data": {"event": "complained", "recipient": %q}}`, recipient))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		requireStatus(t, m.ID, courier.MessageStatusComplained)
+		requireUndeliverable(t, recipient, true)
+	})
+
+	t.Run("case=twilio temporary failure does not suppress the address", func(t *testing.T) {
+		recipient := testhelpers.RandomEmail()
+		newIdentity(t, recipient)
+		m := newSentMessage(t, recipient)
+
+		res := post(t, "twilio", "message_id="+m.ID.String(), "application/x-www-form-urlencoded",
+			url.Values{"MessageStatus": {"undelivered"}, "To": {recipient}, "ErrorCode": {"30003"}}.Encode())
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		requireStatus(t, m.ID, courier.MessageStatusBounced)
+		requireUndeliverable(t, recipient, false)
+	})
+
+	t.Run("case=delivery does not override a bounce", func(t *testing.T) {
+		recipient := testhelpers.RandomEmail()
+		m := newSentMessage(t, recipient)
+		require.NoError(t, reg.CourierPersister().SetMessageStatus(ctx, m.ID, courier.MessageStatusBounced))
+
+		res := post(t, "sendgrid", "", "application/json",
+			fmt.Sprintf(`[{"email": %q, "event": "delivered", "kratos_message_id": %q}]`, recipient, m.ID))
+		require.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		requireStatus(t, m.ID, courier.MessageStatusBounced)
+	})
+
+	t.Run("case=ignores receipts for unknown messages", func(t *testing.T) {
+		res := post(t, "sendgrid", "", "application/json",
+			fmt.Sprintf(`[{"email": %q, "event": "delivered"}]`, testhelpers.RandomEmail()))
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+
+	t.Run("case=returns not found if disabled", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsEnabled, false)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyCourierDeliveryReceiptsEnabled, true) })
+
+		res := post(t, "sendgrid", "", "application/json", `[]`)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
 }
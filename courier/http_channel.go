@@ -8,11 +8,13 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/gofrs/uuid"
 	"github.com/tidwall/gjson"
 
 	"github.com/pkg/errors"
 
 	"github.com/ory/kratos/courier/template"
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/request"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/jsonnetsecure"
@@ -49,6 +51,7 @@ func (c *httpChannel) ID() string {
 }
 
 type httpDataModel struct {
+	MessageID    uuid.UUID             `json:"message_id"`
 	Recipient    string                `json:"recipient"`
 	Subject      string                `json:"subject"`
 	Body         string                `json:"body"`
@@ -61,6 +64,7 @@ func (c *httpChannel) Dispatch(ctx context.Context, msg Message) (err error) {
 	ctx, span := c.d.Tracer(ctx).Tracer().Start(ctx, "courier.httpChannel.Dispatch")
 	defer otelx.End(span, &err)
 
+	ctx = config.WithHTTPClientIntegration(ctx, config.HTTPClientIntegrationCourier)
 	builder, err := request.NewBuilder(ctx, c.requestConfig, c.d)
 	if err != nil {
 		return errors.WithStack(err)
@@ -72,6 +76,7 @@ func (c *httpChannel) Dispatch(ctx context.Context, msg Message) (err error) {
 	}
 
 	td := httpDataModel{
+		MessageID:    msg.ID,
 		Recipient:    msg.Recipient,
 		Subject:      msg.Subject,
 		Body:         msg.Body,
@@ -85,6 +90,7 @@ func (c *httpChannel) Dispatch(ctx context.Context, msg Message) (err error) {
 		return errors.WithStack(err)
 	}
 	req = req.WithContext(ctx)
+	x.InjectTraceContext(ctx, req.Header)
 
 	res, err := c.d.HTTPClient(ctx).Do(req)
 	if err != nil {
@@ -120,6 +126,8 @@ func newTemplate(d template.Dependencies, msg Message) (Template, error) {
 		return NewEmailTemplateFromMessage(d, msg)
 	case MessageTypeSMS:
 		return NewSMSTemplateFromMessage(d, msg)
+	case MessageTypePush:
+		return NewPushNotificationFromMessage(msg)
 	default:
 		return nil, fmt.Errorf("received unexpected message type: %s", msg.Type)
 	}
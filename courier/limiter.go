@@ -0,0 +1,77 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out the dispatches of a channel so that no more than the configured number of
+// messages per second are sent.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func (l *rateLimiter) setRate(perSecond float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if perSecond <= 0 {
+		l.interval = 0
+		return
+	}
+	l.interval = time.Duration(float64(time.Second) / perSecond)
+}
+
+// wait blocks until the next dispatch is allowed or the context is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimiter returns the rate limiter of the channel. It lives as long as the courier, so that
+// the limit also holds across pulls from the queue.
+func (c *courier) rateLimiter(channelID string, perSecond float64) *rateLimiter {
+	c.limitersMu.Lock()
+	defer c.limitersMu.Unlock()
+
+	if c.limiters == nil {
+		c.limiters = make(map[string]*rateLimiter)
+	}
+	l, ok := c.limiters[channelID]
+	if !ok {
+		l = new(rateLimiter)
+		c.limiters[channelID] = l
+	}
+	l.setRate(perSecond)
+	return l
+}
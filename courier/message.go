@@ -29,6 +29,10 @@ const (
 	MessageStatusSent
 	MessageStatusProcessing
 	MessageStatusAbandoned
+	MessageStatusDelivered
+	MessageStatusBounced
+	MessageStatusComplained
+	MessageStatusDeadLetter
 )
 
 const (
@@ -36,6 +40,10 @@ const (
 	messageStatusSentText       = "sent"
 	messageStatusProcessingText = "processing"
 	messageStatusAbandonedText  = "abandoned"
+	messageStatusDeliveredText  = "delivered"
+	messageStatusBouncedText    = "bounced"
+	messageStatusComplainedText = "complained"
+	messageStatusDeadLetterText = "dead_letter"
 )
 
 func ToMessageStatus(str string) (MessageStatus, error) {
@@ -48,6 +56,14 @@ func ToMessageStatus(str string) (MessageStatus, error) {
 		return MessageStatusProcessing, nil
 	case s.AddCase(MessageStatusAbandoned.String()):
 		return MessageStatusAbandoned, nil
+	case s.AddCase(MessageStatusDelivered.String()):
+		return MessageStatusDelivered, nil
+	case s.AddCase(MessageStatusBounced.String()):
+		return MessageStatusBounced, nil
+	case s.AddCase(MessageStatusComplained.String()):
+		return MessageStatusComplained, nil
+	case s.AddCase(MessageStatusDeadLetter.String()):
+		return MessageStatusDeadLetter, nil
 	default:
 		return 0, errors.WithStack(herodot.ErrBadRequest.WithWrap(s.ToUnknownCaseErr()).WithReason("Message status is not valid"))
 	}
@@ -63,6 +79,14 @@ func (ms MessageStatus) String() string {
 		return messageStatusProcessingText
 	case MessageStatusAbandoned:
 		return messageStatusAbandonedText
+	case MessageStatusDelivered:
+		return messageStatusDeliveredText
+	case MessageStatusBounced:
+		return messageStatusBouncedText
+	case MessageStatusComplained:
+		return messageStatusComplainedText
+	case MessageStatusDeadLetter:
+		return messageStatusDeadLetterText
 	default:
 		return ""
 	}
@@ -70,7 +94,8 @@ func (ms MessageStatus) String() string {
 
 func (ms MessageStatus) IsValid() error {
 	switch ms {
-	case MessageStatusQueued, MessageStatusSent, MessageStatusProcessing, MessageStatusAbandoned:
+	case MessageStatusQueued, MessageStatusSent, MessageStatusProcessing, MessageStatusAbandoned,
+		MessageStatusDelivered, MessageStatusBounced, MessageStatusComplained, MessageStatusDeadLetter:
 		return nil
 	default:
 		return errors.WithStack(herodot.ErrBadRequest.WithReason("Message status is not valid"))
@@ -101,7 +126,7 @@ func (ms *MessageStatus) UnmarshalJSON(data []byte) error {
 
 // A Message's Type
 //
-// It can either be `email` or `phone`
+// It can either be `email`, `phone`, or `push`
 //
 // swagger:model courierMessageType
 type MessageType int
@@ -109,11 +134,13 @@ type MessageType int
 const (
 	MessageTypeEmail MessageType = iota + 1
 	MessageTypeSMS
+	MessageTypePush
 )
 
 const (
 	messageTypeEmailText = "email"
 	messageTypeSMSText   = "sms"
+	messageTypePushText  = "push"
 )
 
 func ToMessageType(str string) (MessageType, error) {
@@ -122,6 +149,8 @@ func ToMessageType(str string) (MessageType, error) {
 		return MessageTypeEmail, nil
 	case s.AddCase(messageTypeSMSText):
 		return MessageTypeSMS, nil
+	case s.AddCase(messageTypePushText):
+		return MessageTypePush, nil
 	default:
 		return 0, errors.WithStack(herodot.ErrBadRequest.WithWrap(s.ToUnknownCaseErr()).WithReason("Message type is not valid"))
 	}
@@ -133,6 +162,8 @@ func (mt MessageType) String() string {
 		return messageTypeEmailText
 	case MessageTypeSMS:
 		return messageTypeSMSText
+	case MessageTypePush:
+		return messageTypePushText
 	default:
 		return ""
 	}
@@ -140,7 +171,7 @@ func (mt MessageType) String() string {
 
 func (mt MessageType) IsValid() error {
 	switch mt {
-	case MessageTypeEmail, MessageTypeSMS:
+	case MessageTypeEmail, MessageTypeSMS, MessageTypePush:
 		return nil
 	default:
 		return errors.WithStack(herodot.ErrBadRequest.WithReason("Message type is not valid"))
@@ -191,9 +222,17 @@ type Message struct {
 	Channel sqlxx.NullString `json:"channel" db:"channel"`
 
 	TemplateData []byte `json:"-" db:"template_data"`
+
+	// TraceContext contains the trace context and baggage of the request which queued the
+	// message, so that its delivery can be linked to the trace.
+	TraceContext sqlxx.NullJSONRawMessage `json:"-" faker:"-" db:"trace_context"`
 	// required: true
 	SendCount int `json:"send_count" db:"send_count"`
 
+	// NextAttemptAt is set when a failed message is queued for a retry. The message is not
+	// dispatched before this time.
+	NextAttemptAt *sqlxx.NullTime `json:"next_attempt_at,omitempty" faker:"-" db:"next_attempt_at"`
+
 	// Dispatches store information about the attempts of delivering a message
 	// May contain an error if any happened, or just the `success` state.
 	Dispatches []MessageDispatch `json:"dispatches,omitempty" has_many:"courier_message_dispatches" order_by:"created_at desc" faker:"-"`
@@ -20,10 +20,14 @@ func TestMessageStatusValidity(t *testing.T) {
 func TestToMessageStatus(t *testing.T) {
 	t.Run("case=should return corresponding MessageStatus for given str", func(t *testing.T) {
 		for str, exp := range map[string]courier.MessageStatus{
-			"queued":     courier.MessageStatusQueued,
-			"sent":       courier.MessageStatusSent,
-			"processing": courier.MessageStatusProcessing,
-			"abandoned":  courier.MessageStatusAbandoned,
+			"queued":      courier.MessageStatusQueued,
+			"sent":        courier.MessageStatusSent,
+			"processing":  courier.MessageStatusProcessing,
+			"abandoned":   courier.MessageStatusAbandoned,
+			"delivered":   courier.MessageStatusDelivered,
+			"bounced":     courier.MessageStatusBounced,
+			"complained":  courier.MessageStatusComplained,
+			"dead_letter": courier.MessageStatusDeadLetter,
 		} {
 			result, err := courier.ToMessageStatus(str)
 			require.NoError(t, err)
@@ -47,6 +51,7 @@ func TestToMessageType(t *testing.T) {
 		for str, exp := range map[string]courier.MessageType{
 			"email": courier.MessageTypeEmail,
 			"sms":   courier.MessageTypeSMS,
+			"push":  courier.MessageTypePush,
 		} {
 			result, err := courier.ToMessageType(str)
 			require.NoError(t, err)
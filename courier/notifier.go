@@ -0,0 +1,69 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// Notifier wakes up couriers as soon as a message was queued, so that they do not have to
+	// wait for the next pull of the queue.
+	Notifier interface {
+		// NotifyMessageQueued signals the couriers that a message was queued.
+		NotifyMessageQueued(ctx context.Context)
+
+		// WatchMessageQueue returns a channel which receives a value whenever a message might
+		// have been queued. The channel is closed once the context is done.
+		WatchMessageQueue(ctx context.Context) (<-chan struct{}, error)
+	}
+
+	NotifierProvider interface {
+		CourierNotifier() Notifier
+	}
+)
+
+var _ Notifier = new(LocalNotifier)
+
+// LocalNotifier notifies the couriers running in the same process.
+type LocalNotifier struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func NewLocalNotifier() *LocalNotifier {
+	return &LocalNotifier{subscribers: make(map[chan struct{}]struct{})}
+}
+
+func (n *LocalNotifier) NotifyMessageQueued(context.Context) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subscribers {
+		// A pending notification already wakes up the subscriber.
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *LocalNotifier) WatchMessageQueue(ctx context.Context) (<-chan struct{}, error) {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	n.subscribers[ch] = struct{}{}
+	n.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		n.mu.Lock()
+		delete(n.subscribers, ch)
+		n.mu.Unlock()
+		close(ch)
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,125 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/courier/template/email"
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+)
+
+func TestLocalNotifier(t *testing.T) {
+	n := courier.NewLocalNotifier()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first, err := n.WatchMessageQueue(ctx)
+	require.NoError(t, err)
+	second, err := n.WatchMessageQueue(ctx)
+	require.NoError(t, err)
+
+	// Notifications are coalesced while the subscriber is busy.
+	n.NotifyMessageQueued(ctx)
+	n.NotifyMessageQueued(ctx)
+
+	for _, ch := range []<-chan struct{}{first, second} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("expected a notification")
+		}
+		select {
+		case <-ch:
+			t.Fatal("expected notifications to be coalesced")
+		default:
+		}
+	}
+
+	cancel()
+	for _, ch := range []<-chan struct{}{first, second} {
+		assert.Eventually(t, func() bool {
+			_, ok := <-ch
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestInstantDispatch(t *testing.T) {
+	ctx := context.Background()
+
+	var received atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	t.Cleanup(srv.Close)
+
+	setup := func(t *testing.T, instant bool) (courier.Courier, *driver.RegistryDefault) {
+		conf, reg := internal.NewFastRegistryWithMocks(t)
+		conf.MustSet(ctx, config.ViperKeyCourierDeliveryStrategy, "http")
+		conf.MustSet(ctx, config.ViperKeyCourierHTTPRequestConfig, fmt.Sprintf(`{"url": "%s", "method": "POST"}`, srv.URL))
+		conf.MustSet(ctx, config.ViperKeyCourierWorkerPullWait, "1h")
+		conf.MustSet(ctx, config.ViperKeyCourierWorkerInstantDispatch, instant)
+
+		c, err := reg.Courier(ctx)
+		require.NoError(t, err)
+		return c, reg
+	}
+
+	queue := func(t *testing.T, ctx context.Context, c courier.Courier, reg *driver.RegistryDefault) {
+		_, err := c.QueueEmail(ctx, email.NewTestStub(reg, &email.TestStubModel{
+			To:      "test@ory.sh",
+			Subject: "subject",
+			Body:    "body",
+		}))
+		require.NoError(t, err)
+	}
+
+	t.Run("case=dispatches queued messages without waiting for the next pull", func(t *testing.T) {
+		received.Store(0)
+		c, reg := setup(t, true)
+
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		go func() {
+			assert.NoError(t, c.Work(ctx))
+		}()
+
+		// Give the courier time to finish the initial pull.
+		time.Sleep(100 * time.Millisecond)
+		queue(t, ctx, c, reg)
+
+		assert.Eventually(t, func() bool {
+			return received.Load() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("case=waits for the next pull if instant dispatch is disabled", func(t *testing.T) {
+		received.Store(0)
+		c, reg := setup(t, false)
+
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		go func() {
+			assert.NoError(t, c.Work(ctx))
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		queue(t, ctx, c, reg)
+
+		time.Sleep(time.Second)
+		assert.EqualValues(t, 0, received.Load())
+	})
+}
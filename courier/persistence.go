@@ -5,6 +5,7 @@ package courier
 
 import (
 	"context"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
@@ -12,7 +13,13 @@ import (
 	"github.com/ory/x/pagination/keysetpagination"
 )
 
-var ErrQueueEmpty = errors.New("queue is empty")
+var (
+	ErrQueueEmpty = errors.New("queue is empty")
+
+	// ErrRecipientUndeliverable is recorded for messages which were not sent because the recipient
+	// address is marked as undeliverable.
+	ErrRecipientUndeliverable = errors.New("the recipient address is marked as undeliverable")
+)
 
 type (
 	Persister interface {
@@ -26,6 +33,13 @@ type (
 
 		IncrementMessageSendCount(context.Context, uuid.UUID) error
 
+		// RequeueMessage puts a message which is being processed back into the queue. It is not
+		// dispatched again before notBefore.
+		RequeueMessage(ctx context.Context, id uuid.UUID, notBefore time.Time) error
+
+		// RetryMessage queues a message again and resets its send count.
+		RetryMessage(ctx context.Context, id uuid.UUID) error
+
 		// ListMessages lists all messages in the store given the page, itemsPerPage, status and recipient.
 		// Returns list of messages, total count of messages satisfied by given filter, and error if any
 		ListMessages(context.Context, ListCourierMessagesParameters, []keysetpagination.Option) ([]Message, int64, *keysetpagination.Paginator, error)
@@ -36,6 +50,18 @@ type (
 		// Records an attempt of sending out a courier message
 		// Returns an error if it fails
 		RecordDispatch(ctx context.Context, msgID uuid.UUID, status CourierMessageDispatchStatus, err error) error
+
+		// LatestSentMessage returns the most recent sent or delivered message to the recipient. It is
+		// used to match delivery receipts which do not reference a message ID.
+		LatestSentMessage(ctx context.Context, recipient string) (*Message, error)
+
+		// SetRecipientUndeliverable marks all verifiable addresses matching the recipient as
+		// undeliverable.
+		SetRecipientUndeliverable(ctx context.Context, recipient string) error
+
+		// IsRecipientUndeliverable returns whether a verifiable address matching the recipient is
+		// marked as undeliverable.
+		IsRecipientUndeliverable(ctx context.Context, recipient string) (bool, error)
 	}
 	PersistenceProvider interface {
 		CourierPersister() Persister
@@ -0,0 +1,86 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/courier/template"
+)
+
+const (
+	// PushChannelID is the ID of the courier channel push notifications are dispatched through.
+	PushChannelID = "push"
+
+	// PushPlatformFCM delivers push notifications through Firebase Cloud Messaging.
+	PushPlatformFCM = "fcm"
+	// PushPlatformAPNs delivers push notifications through the Apple Push Notification service.
+	PushPlatformAPNs = "apns"
+)
+
+// PushNotification is a notification delivered to a native app. The data is passed to the app
+// alongside the alert and is not shown to the user.
+type PushNotification struct {
+	Type        template.TemplateType `json:"template_type"`
+	Platform    string                `json:"platform"`
+	DeviceToken string                `json:"device_token"`
+	Title       string                `json:"title"`
+	Body        string                `json:"body"`
+	Data        map[string]string     `json:"data,omitempty"`
+}
+
+var _ Template = new(PushNotification)
+
+func (n *PushNotification) TemplateType() template.TemplateType {
+	return n.Type
+}
+
+func (n *PushNotification) MarshalJSON() ([]byte, error) {
+	type pushNotification PushNotification
+	return json.Marshal((*pushNotification)(n))
+}
+
+func NewPushNotificationFromMessage(m Message) (*PushNotification, error) {
+	var n PushNotification
+	if err := json.Unmarshal(m.TemplateData, &n); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &n, nil
+}
+
+func (c *courier) QueuePush(ctx context.Context, n *PushNotification) (uuid.UUID, error) {
+	switch n.Platform {
+	case PushPlatformFCM, PushPlatformAPNs:
+	default:
+		return uuid.Nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Push platform %q is not supported.", n.Platform))
+	}
+
+	templateData, err := json.Marshal(n)
+	if err != nil {
+		return uuid.Nil, errors.WithStack(err)
+	}
+
+	message := &Message{
+		Status:       MessageStatusQueued,
+		Type:         MessageTypePush,
+		Channel:      PushChannelID,
+		Recipient:    n.DeviceToken,
+		Subject:      n.Title,
+		TemplateType: n.TemplateType(),
+		TemplateData: templateData,
+		Body:         n.Body,
+		TraceContext: traceContext(ctx),
+	}
+	if err := c.deps.CourierPersister().AddMessage(ctx, message); err != nil {
+		return uuid.Nil, err
+	}
+	c.deps.CourierNotifier().NotifyMessageQueued(ctx)
+
+	return message.ID, nil
+}
@@ -0,0 +1,277 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	oauth2jwt "golang.org/x/oauth2/jwt"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/stringsx"
+)
+
+const (
+	fcmDefaultEndpoint      = "https://fcm.googleapis.com"
+	fcmDefaultTokenURL      = "https://oauth2.googleapis.com/token"
+	fcmScope                = "https://www.googleapis.com/auth/firebase.messaging"
+	apnsProductionEndpoint  = "https://api.push.apple.com"
+	apnsSandboxEndpoint     = "https://api.sandbox.push.apple.com"
+	apnsProviderTokenMaxAge = 30 * time.Minute
+)
+
+type (
+	// pushChannel delivers push notifications through Firebase Cloud Messaging or the Apple Push
+	// Notification service, depending on the platform the device token was issued for.
+	pushChannel struct {
+		id          string
+		conf        *config.PushConfig
+		d           channelDependencies
+		credentials *pushCredentials
+	}
+
+	// pushCredentials caches the access tokens of the push services, so that not every message
+	// has to request a new token.
+	pushCredentials struct {
+		mu   sync.Mutex
+		fcm  map[string]oauth2.TokenSource
+		apns map[string]apnsProviderToken
+	}
+
+	apnsProviderToken struct {
+		token    string
+		issuedAt time.Time
+	}
+
+	fcmServiceAccount struct {
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+	}
+)
+
+var _ Channel = new(pushChannel)
+
+func newPushChannel(id string, conf *config.PushConfig, d channelDependencies, credentials *pushCredentials) *pushChannel {
+	return &pushChannel{
+		id:          id,
+		conf:        conf,
+		d:           d,
+		credentials: credentials,
+	}
+}
+
+func (c *pushChannel) ID() string {
+	return c.id
+}
+
+func (c *pushChannel) Dispatch(ctx context.Context, msg Message) (err error) {
+	ctx, span := c.d.Tracer(ctx).Tracer().Start(ctx, "courier.pushChannel.Dispatch")
+	defer otelx.End(span, &err)
+
+	n, err := NewPushNotificationFromMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	var req *retryablehttp.Request
+	switch n.Platform {
+	case PushPlatformFCM:
+		if c.conf == nil || c.conf.FCM == nil {
+			return errors.Errorf("unable to dispatch push notification because Firebase Cloud Messaging is not configured")
+		}
+		req, err = c.newFCMRequest(ctx, c.conf.FCM, n)
+	case PushPlatformAPNs:
+		if c.conf == nil || c.conf.APNs == nil {
+			return errors.Errorf("unable to dispatch push notification because the Apple Push Notification service is not configured")
+		}
+		req, err = c.newAPNsRequest(ctx, c.conf.APNs, n)
+	default:
+		return errors.Errorf("unable to dispatch push notification to unknown platform: %s", n.Platform)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := c.d.HTTPClient(ctx).Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	logger := c.d.Logger().
+		WithField("push_platform", n.Platform).
+		WithField("message_id", msg.ID).
+		WithField("message_nid", msg.NID).
+		WithField("message_type", msg.Type).
+		WithField("message_template_type", msg.TemplateType)
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		logger.Debug("Courier sent out push notification.")
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	err = errors.Errorf(
+		"unable to dispatch push notification because upstream server replied with status code %d: %s",
+		res.StatusCode, body,
+	)
+	logger.
+		WithError(err).
+		Error("Sending push notification failed.")
+	return errors.WithStack(err)
+}
+
+func (c *pushChannel) newFCMRequest(ctx context.Context, conf *config.FCMConfig, n *PushNotification) (*retryablehttp.Request, error) {
+	token, err := c.credentials.fcmToken(ctx, c.d, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token": n.DeviceToken,
+			"notification": map[string]string{
+				"title": n.Title,
+				"body":  n.Body,
+			},
+			"data": n.Data,
+		},
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	u := strings.TrimRight(stringsx.Coalesce(conf.Endpoint, fcmDefaultEndpoint), "/") +
+		"/v1/projects/" + url.PathEscape(conf.ProjectID) + "/messages:send"
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token.SetAuthHeader(req.Request)
+	return req, nil
+}
+
+func (c *pushChannel) newAPNsRequest(ctx context.Context, conf *config.APNsConfig, n *PushNotification) (*retryablehttp.Request, error) {
+	token, err := c.credentials.apnsToken(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make(map[string]any, len(n.Data)+1)
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+	payload["aps"] = map[string]any{
+		"alert": map[string]string{
+			"title": n.Title,
+			"body":  n.Body,
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	endpoint := apnsProductionEndpoint
+	if conf.Sandbox {
+		endpoint = apnsSandboxEndpoint
+	}
+	u := strings.TrimRight(stringsx.Coalesce(conf.Endpoint, endpoint), "/") + "/3/device/" + url.PathEscape(n.DeviceToken)
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("apns-topic", conf.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	return req, nil
+}
+
+// fcmToken returns an OAuth2 access token of the service account. Token sources live as long as
+// the courier, so that tokens are reused until they expire.
+func (p *pushCredentials) fcmToken(ctx context.Context, d channelDependencies, conf *config.FCMConfig) (*oauth2.Token, error) {
+	p.mu.Lock()
+	ts, ok := p.fcm[conf.ServiceAccountJSON]
+	if !ok {
+		var sa fcmServiceAccount
+		if err := json.Unmarshal([]byte(conf.ServiceAccountJSON), &sa); err != nil {
+			p.mu.Unlock()
+			return nil, errors.Wrap(err, "unable to decode the Firebase service account key")
+		}
+
+		jc := &oauth2jwt.Config{
+			Email:        sa.ClientEmail,
+			PrivateKey:   []byte(sa.PrivateKey),
+			PrivateKeyID: sa.PrivateKeyID,
+			Scopes:       []string{fcmScope},
+			TokenURL:     stringsx.Coalesce(sa.TokenURI, fcmDefaultTokenURL),
+		}
+		// The token source outlives the context of this dispatch.
+		ts = jc.TokenSource(context.WithValue(context.Background(), oauth2.HTTPClient, d.HTTPClient(ctx).HTTPClient))
+
+		if p.fcm == nil {
+			p.fcm = make(map[string]oauth2.TokenSource)
+		}
+		p.fcm[conf.ServiceAccountJSON] = ts
+	}
+	p.mu.Unlock()
+
+	token, err := ts.Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch a Firebase Cloud Messaging access token")
+	}
+	return token, nil
+}
+
+// apnsToken returns the provider authentication token of the APNs key. Apple rejects tokens
+// older than an hour as well as tokens which are refreshed too often.
+func (p *pushCredentials) apnsToken(conf *config.APNsConfig) (string, error) {
+	key := strings.Join([]string{conf.TeamID, conf.KeyID, conf.PrivateKey}, "\n")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t, ok := p.apns[key]; ok && time.Since(t.issuedAt) < apnsProviderTokenMaxAge {
+		return t.token, nil
+	}
+
+	privateKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(conf.PrivateKey))
+	if err != nil {
+		return "", errors.Wrap(err, "unable to decode the APNs authentication key")
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": conf.TeamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = conf.KeyID
+	signed, err := token.SignedString(privateKey)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to sign the APNs provider token")
+	}
+
+	if p.apns == nil {
+		p.apns = make(map[string]apnsProviderToken)
+	}
+	p.apns[key] = apnsProviderToken{token: signed, issuedAt: now}
+	return signed, nil
+}
@@ -0,0 +1,165 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/courier/template"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/x"
+)
+
+func TestQueuePush(t *testing.T) {
+	ctx := context.Background()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pemEncode := func(key any) string {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	}
+
+	var (
+		mu       sync.Mutex
+		received = map[string][]byte{}
+		headers  = map[string]http.Header{}
+	)
+	router := http.NewServeMux()
+	router.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assertion, err := jwt.Parse(r.PostForm.Get("assertion"), func(*jwt.Token) (any, error) { return &rsaKey.PublicKey, nil })
+		if !assert.NoError(t, err) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "courier@project.iam.gserviceaccount.com", assertion.Claims.(jwt.MapClaims)["iss"])
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"fcm-access-token","token_type":"Bearer","expires_in":3600}`))
+	})
+	record := func(platform string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			received[platform] = x.MustReadAll(r.Body)
+			headers[platform] = r.Header.Clone()
+			headers[platform].Set("X-Path", r.URL.Path)
+		}
+	}
+	router.HandleFunc("POST /v1/projects/project/messages:send", record(courier.PushPlatformFCM))
+	router.HandleFunc("POST /3/device/", record(courier.PushPlatformAPNs))
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	serviceAccount, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "courier@project.iam.gserviceaccount.com",
+		"private_key":    pemEncode(rsaKey),
+		"private_key_id": "service-account-key",
+		"token_uri":      srv.URL + "/token",
+	})
+	require.NoError(t, err)
+	channels, err := json.Marshal([]map[string]any{{
+		"id":   "push",
+		"type": "push",
+		"push_config": map[string]any{
+			"fcm": map[string]any{
+				"project_id":           "project",
+				"service_account_json": string(serviceAccount),
+				"endpoint":             srv.URL,
+			},
+			"apns": map[string]any{
+				"team_id":     "team",
+				"key_id":      "apns-key",
+				"private_key": pemEncode(ecKey),
+				"topic":       "com.example.app",
+				"endpoint":    srv.URL,
+			},
+		},
+	}})
+	require.NoError(t, err)
+
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeyCourierChannels, string(channels))
+	conf.MustSet(ctx, config.ViperKeyCourierSMTPURL, "http://foo.url")
+	conf.MustSet(ctx, config.ViperKeyClientHTTPNoPrivateIPRanges, false)
+
+	c, err := reg.Courier(ctx)
+	require.NoError(t, err)
+	c.FailOnDispatchError()
+
+	for _, platform := range []string{courier.PushPlatformFCM, courier.PushPlatformAPNs} {
+		_, err := c.QueuePush(ctx, &courier.PushNotification{
+			Type:        template.TypeRecoveryPushApproval,
+			Platform:    platform,
+			DeviceToken: platform + "-device-token",
+			Title:       "Account recovery requested",
+			Body:        "Approve the request if it was you.",
+			Data:        map[string]string{"challenge": "push_challenge"},
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.DispatchQueue(ctx))
+
+	messages, _, _, err := reg.CourierPersister().ListMessages(ctx, courier.ListCourierMessagesParameters{}, nil)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	for _, m := range messages {
+		assert.Equal(t, courier.MessageTypePush, m.Type)
+		assert.Equal(t, courier.MessageStatusSent, m.Status)
+		assert.Equal(t, template.TypeRecoveryPushApproval, m.TemplateType)
+	}
+
+	t.Run("platform=fcm", func(t *testing.T) {
+		body := received[courier.PushPlatformFCM]
+		assert.Equal(t, "Bearer fcm-access-token", headers[courier.PushPlatformFCM].Get("Authorization"))
+		assert.Equal(t, "fcm-device-token", gjson.GetBytes(body, "message.token").String(), "%s", body)
+		assert.Equal(t, "Account recovery requested", gjson.GetBytes(body, "message.notification.title").String(), "%s", body)
+		assert.Equal(t, "push_challenge", gjson.GetBytes(body, "message.data.challenge").String(), "%s", body)
+	})
+
+	t.Run("platform=apns", func(t *testing.T) {
+		body := received[courier.PushPlatformAPNs]
+		h := headers[courier.PushPlatformAPNs]
+		assert.Equal(t, "/3/device/apns-device-token", h.Get("X-Path"))
+		assert.Equal(t, "com.example.app", h.Get("apns-topic"))
+		assert.Equal(t, "alert", h.Get("apns-push-type"))
+		assert.Equal(t, "Account recovery requested", gjson.GetBytes(body, "aps.alert.title").String(), "%s", body)
+		assert.Equal(t, "push_challenge", gjson.GetBytes(body, "challenge").String(), "%s", body)
+
+		token, err := jwt.Parse(strings.TrimPrefix(h.Get("Authorization"), "bearer "), func(*jwt.Token) (any, error) { return &ecKey.PublicKey, nil })
+		require.NoError(t, err)
+		assert.Equal(t, "apns-key", token.Header["kid"])
+		assert.Equal(t, "team", token.Claims.(jwt.MapClaims)["iss"])
+	})
+
+	t.Run("case=rejects unknown platforms", func(t *testing.T) {
+		_, err := c.QueuePush(ctx, &courier.PushNotification{Platform: "unknown", DeviceToken: "token"})
+		require.Error(t, err)
+		assert.Contains(t, fmt.Sprintf("%+v", err), "is not supported")
+	})
+}
@@ -0,0 +1,302 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+)
+
+// MessageIDHeader is set on all emails sent by the courier, so that delivery receipts can
+// reference the message.
+const MessageIDHeader = "X-Kratos-Message-Id"
+
+const (
+	// receiptMessageIDVariable is the name of the custom argument (SendGrid) or user variable
+	// (Mailgun) which contains the message ID.
+	receiptMessageIDVariable = "kratos_message_id"
+
+	// receiptMessageIDQuery is the query parameter of the callback URL which contains the
+	// message ID. Use it with providers which do not return custom data, such as Twilio.
+	receiptMessageIDQuery = "message_id"
+
+	// maxReceiptBodySize limits the size of delivery receipt payloads.
+	maxReceiptBodySize = 1 << 20
+)
+
+type (
+	// DeliveryEvent is the kind of delivery receipt.
+	DeliveryEvent string
+
+	// DeliveryReceipt is a delivery, bounce, or complaint notification sent by an email or SMS
+	// provider.
+	DeliveryReceipt struct {
+		// MessageID is the ID of the courier message, if the provider returned it.
+		MessageID uuid.UUID
+
+		// Recipient is the address the notification is about.
+		Recipient string
+
+		Event DeliveryEvent
+
+		// Permanent is set for bounces which will not succeed when retried.
+		Permanent bool
+
+		// Reason is the provider's description of a bounce or complaint.
+		Reason string
+	}
+)
+
+const (
+	DeliveryEventDelivered  DeliveryEvent = "delivered"
+	DeliveryEventBounced    DeliveryEvent = "bounced"
+	DeliveryEventComplained DeliveryEvent = "complained"
+)
+
+const (
+	ReceiptProviderSES      = "ses"
+	ReceiptProviderSendGrid = "sendgrid"
+	ReceiptProviderMailgun  = "mailgun"
+	ReceiptProviderTwilio   = "twilio"
+)
+
+// Status returns the message status for the receipt.
+func (r *DeliveryReceipt) Status() MessageStatus {
+	switch r.Event {
+	case DeliveryEventBounced:
+		return MessageStatusBounced
+	case DeliveryEventComplained:
+		return MessageStatusComplained
+	default:
+		return MessageStatusDelivered
+	}
+}
+
+// Undeliverable returns whether no further messages should be sent to the recipient.
+func (r *DeliveryReceipt) Undeliverable() bool {
+	return r.Event == DeliveryEventComplained || (r.Event == DeliveryEventBounced && r.Permanent)
+}
+
+// parseDeliveryReceipts parses the callback of the given provider. For Amazon SNS subscription
+// confirmations, it returns the URL which confirms the subscription instead.
+func parseDeliveryReceipts(r *http.Request, provider string, body []byte) (receipts []DeliveryReceipt, confirmURL string, err error) {
+	switch provider {
+	case ReceiptProviderSES, ReceiptProviderSendGrid, ReceiptProviderMailgun:
+		if !gjson.ValidBytes(body) {
+			return nil, "", errors.WithStack(herodot.ErrBadRequest.WithReason("The delivery receipt is not valid JSON."))
+		}
+	}
+
+	switch provider {
+	case ReceiptProviderSES:
+		receipts, confirmURL, err = parseSESReceipts(body)
+	case ReceiptProviderSendGrid:
+		receipts, err = parseSendGridReceipts(body)
+	case ReceiptProviderMailgun:
+		receipts, err = parseMailgunReceipts(body)
+	case ReceiptProviderTwilio:
+		form, parseErr := url.ParseQuery(string(body))
+		if parseErr != nil {
+			return nil, "", errors.WithStack(herodot.ErrBadRequest.WithReason("Unable to parse the delivery receipt.").WithWrap(parseErr))
+		}
+		receipts = parseTwilioReceipts(form)
+	default:
+		return nil, "", errors.WithStack(herodot.ErrNotFound.WithReasonf("Delivery receipts from provider %q are not supported.", provider))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if id := r.URL.Query().Get(receiptMessageIDQuery); id != "" {
+		messageID, err := uuid.FromString(id)
+		if err != nil {
+			return nil, "", errors.WithStack(herodot.ErrBadRequest.WithReasonf("The %s query parameter is not a valid message ID.", receiptMessageIDQuery))
+		}
+		for k := range receipts {
+			receipts[k].MessageID = messageID
+		}
+	}
+
+	return receipts, confirmURL, nil
+}
+
+func readReceiptBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReceiptBodySize))
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("Unable to read the delivery receipt.").WithWrap(err))
+	}
+	return body, nil
+}
+
+func messageIDOf(value string) uuid.UUID {
+	id, err := uuid.FromString(strings.TrimSpace(value))
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// parseSESReceipts parses Amazon SES notifications delivered through Amazon SNS.
+//
+// See https://docs.aws.amazon.com/ses/latest/dg/notification-contents.html
+func parseSESReceipts(body []byte) ([]DeliveryReceipt, string, error) {
+	notification := gjson.ParseBytes(body)
+	switch notification.Get("Type").String() {
+	case "SubscriptionConfirmation":
+		return nil, notification.Get("SubscribeURL").String(), nil
+	case "Notification":
+	default:
+		return nil, "", nil
+	}
+
+	message := gjson.Parse(notification.Get("Message").String())
+	var messageID uuid.UUID
+	for _, header := range message.Get("mail.headers").Array() {
+		if strings.EqualFold(header.Get("name").String(), MessageIDHeader) {
+			messageID = messageIDOf(header.Get("value").String())
+		}
+	}
+
+	eventType := message.Get("notificationType").String()
+	if eventType == "" {
+		eventType = message.Get("eventType").String()
+	}
+
+	var receipts []DeliveryReceipt
+	switch eventType {
+	case "Delivery":
+		for _, recipient := range message.Get("delivery.recipients").Array() {
+			receipts = append(receipts, DeliveryReceipt{MessageID: messageID, Recipient: recipient.String(), Event: DeliveryEventDelivered})
+		}
+	case "Bounce":
+		permanent := message.Get("bounce.bounceType").String() == "Permanent"
+		for _, recipient := range message.Get("bounce.bouncedRecipients").Array() {
+			receipts = append(receipts, DeliveryReceipt{
+				MessageID: messageID,
+				Recipient: recipient.Get("emailAddress").String(),
+				Event:     DeliveryEventBounced,
+				Permanent: permanent,
+				Reason:    recipient.Get("diagnosticCode").String(),
+			})
+		}
+	case "Complaint":
+		for _, recipient := range message.Get("complaint.complainedRecipients").Array() {
+			receipts = append(receipts, DeliveryReceipt{
+				MessageID: messageID,
+				Recipient: recipient.Get("emailAddress").String(),
+				Event:     DeliveryEventComplained,
+				Reason:    message.Get("complaint.complaintFeedbackType").String(),
+			})
+		}
+	}
+	return receipts, "", nil
+}
+
+// parseSendGridReceipts parses SendGrid event webhooks. Set the `kratos_message_id` custom
+// argument to reference the message.
+//
+// See https://www.twilio.com/docs/sendgrid/for-developers/tracking-events/event
+func parseSendGridReceipts(body []byte) ([]DeliveryReceipt, error) {
+	var events []struct {
+		Email     string `json:"email"`
+		Event     string `json:"event"`
+		Type      string `json:"type"`
+		Reason    string `json:"reason"`
+		MessageID string `json:"kratos_message_id"`
+	}
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("Unable to parse the delivery receipt.").WithWrap(err))
+	}
+
+	receipts := make([]DeliveryReceipt, 0, len(events))
+	for _, e := range events {
+		receipt := DeliveryReceipt{MessageID: messageIDOf(e.MessageID), Recipient: e.Email, Reason: e.Reason}
+		switch e.Event {
+		case "delivered":
+			receipt.Event = DeliveryEventDelivered
+		case "bounce":
+			receipt.Event = DeliveryEventBounced
+			receipt.Permanent = e.Type != "blocked"
+		case "dropped":
+			receipt.Event = DeliveryEventBounced
+			receipt.Permanent = true
+		case "spamreport":
+			receipt.Event = DeliveryEventComplained
+		default:
+			continue
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// parseMailgunReceipts parses Mailgun webhooks. Set the `kratos_message_id` user variable to
+// reference the message.
+//
+// See https://documentation.mailgun.com/docs/mailgun/user-manual/tracking-messages/
+func parseMailgunReceipts(body []byte) ([]DeliveryReceipt, error) {
+	e := gjson.GetBytes(body, "event-data")
+	receipt := DeliveryReceipt{
+		MessageID: messageIDOf(e.Get("user-variables." + receiptMessageIDVariable).String()),
+		Recipient: e.Get("recipient").String(),
+		Reason:    e.Get("delivery-status.description").String(),
+	}
+	switch e.Get("event").String() {
+	case "delivered":
+		receipt.Event = DeliveryEventDelivered
+	case "failed":
+		receipt.Event = DeliveryEventBounced
+		receipt.Permanent = e.Get("severity").String() == "permanent"
+	case "complained":
+		receipt.Event = DeliveryEventComplained
+	default:
+		return nil, nil
+	}
+	return []DeliveryReceipt{receipt}, nil
+}
+
+// twilioPermanentErrors are Twilio error codes for numbers which can not receive messages.
+//
+// See https://www.twilio.com/docs/api/errors
+var twilioPermanentErrors = map[string]bool{
+	"21211": true, // invalid 'To' phone number
+	"21610": true, // the recipient unsubscribed
+	"21614": true, // 'To' number is not a valid mobile number
+	"30005": true, // unknown destination handset
+	"30006": true, // landline or unreachable carrier
+}
+
+// parseTwilioReceipts parses Twilio status callbacks. Twilio does not return custom data, so add
+// the `message_id` query parameter to the status callback URL to reference the message.
+//
+// See https://www.twilio.com/docs/messaging/guides/track-outbound-message-status
+func parseTwilioReceipts(form map[string][]string) []DeliveryReceipt {
+	get := func(key string) string {
+		if v := form[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	receipt := DeliveryReceipt{Recipient: get("To"), Reason: get("ErrorCode")}
+	switch get("MessageStatus") {
+	case "delivered":
+		receipt.Event = DeliveryEventDelivered
+	case "undelivered", "failed":
+		receipt.Event = DeliveryEventBounced
+		receipt.Permanent = twilioPermanentErrors[get("ErrorCode")]
+	default:
+		return nil
+	}
+	return []DeliveryReceipt{receipt}
+}
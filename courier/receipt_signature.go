@@ -0,0 +1,301 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1" //#nosec G505 -- Amazon SNS and Twilio sign using SHA1.
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+)
+
+// receiptSignatureTolerance is the maximum age of signed SendGrid and Mailgun callbacks.
+const receiptSignatureTolerance = 10 * time.Minute
+
+// snsSigningCertHost matches the hosts Amazon SNS serves its signing certificates from.
+var snsSigningCertHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// validSNSSigningCertURL returns whether the signing certificate of an Amazon SNS message may be
+// fetched from the URL.
+var validSNSSigningCertURL = func(u *url.URL) bool {
+	return u.Scheme == "https" && snsSigningCertHost.MatchString(u.Hostname()) && strings.HasSuffix(u.Path, ".pem")
+}
+
+func errInvalidReceiptSignature(reason string) error {
+	return errors.WithStack(herodot.ErrUnauthorized.WithReasonf("The delivery receipt signature is invalid: %s", reason))
+}
+
+// authenticateDeliveryReceipt verifies the signature of the callback. Callbacks of providers
+// whose signature is not configured must contain the callback token in the Authorization header.
+func (h *Handler) authenticateDeliveryReceipt(ctx context.Context, r *http.Request, provider string, body []byte) error {
+	conf := h.r.Config()
+	switch provider {
+	case ReceiptProviderSES:
+		// Amazon SNS always signs its messages, but anyone can subscribe the endpoint to their
+		// own topic. The signature is therefore only sufficient if the topic is allowed.
+		if err := h.verifySNSSignature(ctx, body); err != nil {
+			return err
+		}
+		if arns := conf.CourierDeliveryReceiptsSESTopicARNs(ctx); len(arns) > 0 {
+			if !slices.Contains(arns, gjson.GetBytes(body, "TopicArn").String()) {
+				return errors.WithStack(herodot.ErrUnauthorized.WithReason("The Amazon SNS topic is not allowed to send delivery receipts."))
+			}
+			return nil
+		}
+	case ReceiptProviderSendGrid:
+		if key := conf.CourierDeliveryReceiptsSendGridVerificationKey(ctx); key != "" {
+			return verifySendGridSignature(key, r.Header, body, time.Now())
+		}
+	case ReceiptProviderMailgun:
+		if key := conf.CourierDeliveryReceiptsMailgunSigningKey(ctx); key != "" {
+			return verifyMailgunSignature(key, body, time.Now())
+		}
+	case ReceiptProviderTwilio:
+		if token := conf.CourierDeliveryReceiptsTwilioAuthToken(ctx); token != "" {
+			callbackURL := *conf.SelfPublicURL(ctx)
+			callbackURL.Path = strings.TrimRight(callbackURL.Path, "/") + r.URL.Path
+			callbackURL.RawQuery = r.URL.RawQuery
+			return verifyTwilioSignature(token, callbackURL.String(), r.Header.Get("X-Twilio-Signature"), body)
+		}
+	}
+
+	token := conf.CourierDeliveryReceiptsToken(ctx)
+	if token == "" || subtle.ConstantTimeCompare([]byte(receiptToken(r)), []byte(token)) != 1 {
+		return errors.WithStack(herodot.ErrUnauthorized.WithReason("The delivery receipt token is invalid."))
+	}
+	return nil
+}
+
+// receiptToken returns the callback token from the Authorization header. Providers which can not
+// send custom headers can use HTTP basic authentication with the token as the password.
+func receiptToken(r *http.Request) string {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return ""
+}
+
+// verifySNSSignature verifies the signature of an Amazon SNS message.
+//
+// See https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message.html
+func (h *Handler) verifySNSSignature(ctx context.Context, body []byte) error {
+	message := gjson.ParseBytes(body)
+
+	certURL, err := url.Parse(message.Get("SigningCertURL").String())
+	if err != nil || !validSNSSigningCertURL(certURL) {
+		return errInvalidReceiptSignature("the signing certificate URL is not an Amazon SNS URL")
+	}
+
+	cert, err := h.snsSigningCert(ctx, certURL.String())
+	if err != nil {
+		return err
+	}
+
+	return verifySNSMessage(message, cert)
+}
+
+func (h *Handler) snsSigningCert(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	if cert, ok := h.snsCerts.Load(certURL); ok {
+		return cert.(*x509.Certificate), nil
+	}
+
+	res, err := h.r.HTTPClient(ctx).Get(certURL)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("Unable to fetch the Amazon SNS signing certificate.").WithWrap(err))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to fetch the Amazon SNS signing certificate, received status code %d.", res.StatusCode))
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(res.Body, maxReceiptBodySize))
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("Unable to fetch the Amazon SNS signing certificate.").WithWrap(err))
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("The Amazon SNS signing certificate is not PEM encoded."))
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("Unable to parse the Amazon SNS signing certificate.").WithWrap(err))
+	}
+
+	h.snsCerts.Store(certURL, cert)
+	return cert, nil
+}
+
+func verifySNSMessage(message gjson.Result, cert *x509.Certificate) error {
+	var keys []string
+	switch message.Get("Type").String() {
+	case "Notification":
+		keys = []string{"Message", "MessageId", "Subject", "Timestamp", "TopicArn", "Type"}
+	case "SubscriptionConfirmation", "UnsubscribeConfirmation":
+		keys = []string{"Message", "MessageId", "SubscribeURL", "Timestamp", "Token", "TopicArn", "Type"}
+	default:
+		return errInvalidReceiptSignature("the message type is unknown")
+	}
+
+	var signed strings.Builder
+	for _, key := range keys {
+		if v := message.Get(key); v.Exists() {
+			signed.WriteString(key + "\n" + v.String() + "\n")
+		}
+	}
+
+	var hash crypto.Hash
+	switch message.Get("SignatureVersion").String() {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return errInvalidReceiptSignature("the signature version is unknown")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(message.Get("Signature").String())
+	if err != nil {
+		return errInvalidReceiptSignature("the signature is not base64 encoded")
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errInvalidReceiptSignature("the signing certificate does not contain an RSA key")
+	}
+
+	digest := hash.New()
+	_, _ = digest.Write([]byte(signed.String()))
+	if err := rsa.VerifyPKCS1v15(key, hash, digest.Sum(nil), signature); err != nil {
+		return errInvalidReceiptSignature("the signature does not match")
+	}
+	return nil
+}
+
+// verifySendGridSignature verifies the signature of a SendGrid signed event webhook.
+//
+// See https://www.twilio.com/docs/sendgrid/for-developers/tracking-events/getting-started-event-webhook-security-features
+func verifySendGridSignature(verificationKey string, header http.Header, body []byte, now time.Time) error {
+	rawKey, err := base64.StdEncoding.DecodeString(verificationKey)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReason("The SendGrid verification key is not base64 encoded.").WithWrap(err))
+	}
+	parsed, err := x509.ParsePKIXPublicKey(rawKey)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReason("Unable to parse the SendGrid verification key.").WithWrap(err))
+	}
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReason("The SendGrid verification key is not an ECDSA key."))
+	}
+
+	timestamp := header.Get("X-Twilio-Email-Event-Webhook-Timestamp")
+	if err := checkReceiptTimestamp(timestamp, now); err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(header.Get("X-Twilio-Email-Event-Webhook-Signature"))
+	if err != nil {
+		return errInvalidReceiptSignature("the signature is not base64 encoded")
+	}
+
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	if !ecdsa.VerifyASN1(key, digest[:], signature) {
+		return errInvalidReceiptSignature("the signature does not match")
+	}
+	return nil
+}
+
+// verifyMailgunSignature verifies the signature of a Mailgun webhook.
+//
+// See https://documentation.mailgun.com/docs/mailgun/user-manual/tracking-messages/#securing-webhooks
+func verifyMailgunSignature(signingKey string, body []byte, now time.Time) error {
+	signature := gjson.GetBytes(body, "signature")
+	timestamp := signature.Get("timestamp").String()
+	if err := checkReceiptTimestamp(timestamp, now); err != nil {
+		return err
+	}
+
+	expected, err := hex.DecodeString(signature.Get("signature").String())
+	if err != nil {
+		return errInvalidReceiptSignature("the signature is not hex encoded")
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingKey))
+	_, _ = mac.Write([]byte(timestamp + signature.Get("token").String()))
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errInvalidReceiptSignature("the signature does not match")
+	}
+	return nil
+}
+
+// verifyTwilioSignature verifies the X-Twilio-Signature header of a status callback.
+//
+// See https://www.twilio.com/docs/usage/webhooks/webhooks-security
+func verifyTwilioSignature(authToken, callbackURL, signature string, body []byte) error {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("Unable to parse the delivery receipt.").WithWrap(err))
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errInvalidReceiptSignature("the signature is not base64 encoded")
+	}
+
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	_, _ = mac.Write([]byte(callbackURL))
+	for _, key := range keys {
+		values := slices.Clone(form[key])
+		sort.Strings(values)
+		for _, value := range values {
+			_, _ = mac.Write([]byte(key + value))
+		}
+	}
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errInvalidReceiptSignature("the signature does not match")
+	}
+	return nil
+}
+
+// checkReceiptTimestamp rejects signed callbacks which are too old to prevent replays.
+func checkReceiptTimestamp(timestamp string, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errInvalidReceiptSignature("the timestamp is invalid")
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > receiptSignatureTolerance || age < -receiptSignatureTolerance {
+		return errInvalidReceiptSignature("the timestamp is too old")
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"context"
+)
+
+// SandboxChannelType is the type of channels which do not send messages. Messages dispatched
+// through them are marked as sent and can be read using the sandbox API, so that end-to-end tests
+// can assert on verification codes and links without an SMTP catcher.
+const SandboxChannelType = "sandbox"
+
+type sandboxChannel struct {
+	id string
+	d  Dependencies
+}
+
+var _ Channel = new(sandboxChannel)
+
+func newSandboxChannel(id string, d Dependencies) *sandboxChannel {
+	return &sandboxChannel{id: id, d: d}
+}
+
+func (c *sandboxChannel) ID() string {
+	return c.id
+}
+
+func (c *sandboxChannel) Dispatch(ctx context.Context, msg Message) error {
+	c.d.Logger().
+		WithField("message_id", msg.ID).
+		WithField("message_type", msg.Type).
+		WithField("message_template_type", msg.TemplateType).
+		WithField("message_subject", msg.Subject).
+		Debug("Courier kept message in the sandbox instead of sending it.")
+	return nil
+}
@@ -34,10 +34,12 @@ func (c *courier) QueueSMS(ctx context.Context, t SMSTemplate) (uuid.UUID, error
 		TemplateType: t.TemplateType(),
 		TemplateData: templateData,
 		Body:         body,
+		TraceContext: traceContext(ctx),
 	}
 	if err := c.deps.CourierPersister().AddMessage(ctx, message); err != nil {
 		return uuid.Nil, err
 	}
+	c.deps.CourierNotifier().NotifyMessageQueued(ctx)
 
 	return message.ID, nil
 }
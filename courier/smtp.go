@@ -129,11 +129,13 @@ func (c *courier) QueueEmail(ctx context.Context, t EmailTemplate) (uuid.UUID, e
 		Subject:      subject,
 		TemplateType: t.TemplateType(),
 		TemplateData: templateData,
+		TraceContext: traceContext(ctx),
 	}
 
 	if err := c.deps.CourierPersister().AddMessage(ctx, message); err != nil {
 		return uuid.Nil, err
 	}
+	c.deps.CourierNotifier().NotifyMessageQueued(ctx)
 
 	return message.ID, nil
 }
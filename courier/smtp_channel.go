@@ -5,11 +5,15 @@ package courier
 
 import (
 	"context"
+	"maps"
 	"net"
 	"net/textproto"
+	"slices"
 	"strconv"
 
 	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
 	"go.opentelemetry.io/otel/trace"
 
@@ -23,8 +27,13 @@ import (
 type (
 	SMTPChannel struct {
 		smtpClient *SMTPClient
+		smtpConfig *config.SMTPConfig
 		d          Dependencies
 
+		// pools keeps connections open between messages. Every message connects to the
+		// server if it is nil.
+		pools *smtpPools
+
 		newEmailTemplateFromMessage func(d template.Dependencies, msg Message) (EmailTemplate, error)
 	}
 )
@@ -42,6 +51,7 @@ func NewSMTPChannelWithCustomTemplates(deps Dependencies, cfg *config.SMTPConfig
 	}
 	return &SMTPChannel{
 		smtpClient:                  smtpClient,
+		smtpConfig:                  cfg,
 		d:                           deps,
 		newEmailTemplateFromMessage: newEmailTemplateFromMessage,
 	}, nil
@@ -55,10 +65,6 @@ func (c *SMTPChannel) Dispatch(ctx context.Context, msg Message) (err error) {
 	ctx, span := c.d.Tracer(ctx).Tracer().Start(ctx, "courier.SMTPChannel.Dispatch")
 	defer otelx.End(span, &err)
 
-	if c.smtpClient.Host == "" {
-		return errors.WithStack(herodot.ErrInternalServerError.WithErrorf("Courier tried to deliver an email but %s is not set!", config.ViperKeyCourierSMTPURL))
-	}
-
 	channels, err := c.d.CourierConfig().CourierChannels(ctx)
 	if err != nil {
 		return err
@@ -76,17 +82,47 @@ func (c *SMTPChannel) Dispatch(ctx context.Context, msg Message) (err error) {
 		return errors.WithStack(herodot.ErrInternalServerError.WithErrorf("Courier tried to deliver an email but SMTP channel is misconfigured."))
 	}
 
+	fromAddress, fromName, headers := cfg.FromAddress, cfg.FromName, cfg.Headers
+	smtpClient, smtpConfig := c.smtpClient, c.smtpConfig
+	if sender := findSMTPSender(cfg.Senders, msg); sender != nil {
+		if sender.FromAddress != "" {
+			fromAddress, fromName = sender.FromAddress, sender.FromName
+		}
+		if len(sender.Headers) > 0 {
+			headers = make(map[string]string, len(cfg.Headers)+len(sender.Headers))
+			maps.Copy(headers, cfg.Headers)
+			maps.Copy(headers, sender.Headers)
+		}
+		if sender.ConnectionURI != "" {
+			senderConfig := *c.smtpConfig
+			senderConfig.ConnectionURI, err = c.d.SecretResolver().Resolve(ctx, sender.ConnectionURI)
+			if err != nil {
+				return err
+			}
+			if smtpClient, err = NewSMTPClient(c.d, &senderConfig); err != nil {
+				return err
+			}
+			smtpConfig = &senderConfig
+		}
+	}
+
+	if smtpClient.Host == "" {
+		return errors.WithStack(herodot.ErrInternalServerError.WithErrorf("Courier tried to deliver an email but %s is not set!", config.ViperKeyCourierSMTPURL))
+	}
+
 	gm := mail.NewMessage()
-	if cfg.FromName == "" {
-		gm.SetHeader("From", cfg.FromAddress)
+	if fromName == "" {
+		gm.SetHeader("From", fromAddress)
 	} else {
-		gm.SetAddressHeader("From", cfg.FromAddress, cfg.FromName)
+		gm.SetAddressHeader("From", fromAddress, fromName)
 	}
 
 	gm.SetHeader("To", msg.Recipient)
 	gm.SetHeader("Subject", msg.Subject)
 
-	headers := cfg.Headers
+	// Delivery receipts reference the message using this header.
+	gm.SetHeader(MessageIDHeader, msg.ID.String())
+
 	for k, v := range headers {
 		gm.SetHeader(k, v)
 	}
@@ -94,9 +130,9 @@ func (c *SMTPChannel) Dispatch(ctx context.Context, msg Message) (err error) {
 	gm.SetBody("text/plain", msg.Body)
 
 	logger := c.d.Logger().
-		WithField("smtp_server", net.JoinHostPort(c.smtpClient.Host, strconv.Itoa(c.smtpClient.Port))).
-		WithField("smtp_ssl_enabled", c.smtpClient.SSL).
-		WithField("message_from", cfg.FromAddress).
+		WithField("smtp_server", net.JoinHostPort(smtpClient.Host, strconv.Itoa(smtpClient.Port))).
+		WithField("smtp_ssl_enabled", smtpClient.SSL).
+		WithField("message_from", fromAddress).
 		WithField("message_id", msg.ID).
 		WithField("message_nid", msg.NID).
 		WithField("message_type", msg.Type).
@@ -114,28 +150,16 @@ func (c *SMTPChannel) Dispatch(ctx context.Context, msg Message) (err error) {
 		gm.AddAlternative("text/html", htmlBody)
 	}
 
-	dialCtx, dialSpan := c.d.Tracer(ctx).Tracer().Start(ctx, "courier.SMTPChannel.Dispatch.Dial", trace.WithAttributes(
-		semconv.NetPeerName(c.smtpClient.Host),
-		semconv.NetPeerPort(c.smtpClient.Port),
-		semconv.NetProtocolName("smtp"),
-	))
-	snd, err := c.smtpClient.Dial(dialCtx)
-	otelx.End(dialSpan, &err)
-
-	if err != nil {
-		logger.
-			WithError(err).
-			Error("Unable to dial SMTP connection.")
-		return errors.WithStack(herodot.ErrInternalServerError.
-			WithError(err.Error()).WithReason("failed to send email via smtp"))
-	}
-	defer snd.Close()
-
-	sendCtx, sendSpan := c.d.Tracer(ctx).Tracer().Start(ctx, "courier.SMTPChannel.Dispatch.Send")
-	err = mail.Send(sendCtx, snd, gm)
-	otelx.End(sendSpan, &err)
+	if err := c.send(ctx, smtpClient, smtpConfig, gm); err != nil {
+		var dialErr *smtpDialError
+		if errors.As(err, &dialErr) {
+			logger.
+				WithError(err).
+				Error("Unable to dial SMTP connection.")
+			return errors.WithStack(herodot.ErrInternalServerError.
+				WithError(err.Error()).WithReason("failed to send email via smtp"))
+		}
 
-	if err != nil {
 		logger.
 			WithError(err).
 			Error("Unable to send email using SMTP connection.")
@@ -165,3 +189,81 @@ func (c *SMTPChannel) Dispatch(ctx context.Context, msg Message) (err error) {
 
 	return nil
 }
+
+// smtpDialError is returned by send if no connection to the SMTP server could be established.
+type smtpDialError struct{ error }
+
+func (e *smtpDialError) Unwrap() error { return e.error }
+
+// send sends the message using an idle connection from the pool, or a new connection if there
+// is none. A pooled connection which fails for another reason than an SMTP error may have been
+// closed by the server in the meantime, so the message is sent again over a new connection.
+func (c *SMTPChannel) send(ctx context.Context, client *SMTPClient, cfg *config.SMTPConfig, gm *mail.Message) error {
+	var pool *smtpPool
+	if c.pools != nil {
+		pool = c.pools.get(cfg)
+	}
+
+	if snd := pool.take(); snd != nil {
+		sendCtx, sendSpan := c.d.Tracer(ctx).Tracer().Start(ctx, "courier.SMTPChannel.Dispatch.Send", trace.WithAttributes(attribute.Bool("smtp.connection_reused", true)))
+		err := mail.Send(sendCtx, snd, gm)
+		otelx.End(sendSpan, &err)
+		if err == nil {
+			pool.put(snd)
+			return nil
+		}
+		_ = snd.Close()
+
+		var protoErr *textproto.Error
+		var mailErr *mail.SendError
+		if errors.As(err, &protoErr) || (errors.As(err, &mailErr) && errors.As(mailErr.Cause, &protoErr)) {
+			return err
+		}
+		c.d.Logger().
+			WithError(err).
+			Debug("Pooled SMTP connection is no longer usable, connecting again.")
+	}
+
+	dialCtx, dialSpan := c.d.Tracer(ctx).Tracer().Start(ctx, "courier.SMTPChannel.Dispatch.Dial", trace.WithAttributes(
+		semconv.NetPeerName(client.Host),
+		semconv.NetPeerPort(client.Port),
+		semconv.NetProtocolName("smtp"),
+	))
+	snd, err := client.Dial(dialCtx)
+	otelx.End(dialSpan, &err)
+	if err != nil {
+		return &smtpDialError{err}
+	}
+
+	sendCtx, sendSpan := c.d.Tracer(ctx).Tracer().Start(ctx, "courier.SMTPChannel.Dispatch.Send")
+	err = mail.Send(sendCtx, snd, gm)
+	otelx.End(sendSpan, &err)
+	if err != nil {
+		_ = snd.Close()
+		return err
+	}
+
+	pool.put(snd)
+	return nil
+}
+
+// findSMTPSender returns the first sender which matches the template type and the identity
+// schema of the message.
+func findSMTPSender(senders []config.SMTPSender, msg Message) *config.SMTPSender {
+	if len(senders) == 0 {
+		return nil
+	}
+
+	schemaID := gjson.GetBytes(msg.TemplateData, "identity.schema_id").String()
+	for k := range senders {
+		s := &senders[k]
+		if len(s.TemplateTypes) > 0 && !slices.Contains(s.TemplateTypes, string(msg.TemplateType)) {
+			continue
+		}
+		if len(s.IdentitySchemas) > 0 && !slices.Contains(s.IdentitySchemas, schemaID) {
+			continue
+		}
+		return s
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/kratos/driver/config"
+	gomail "github.com/ory/mail/v3"
+)
+
+type (
+	// smtpPools keeps the idle connections to all SMTP servers the courier sends through.
+	smtpPools struct {
+		mu    sync.Mutex
+		pools map[string]*smtpPool
+	}
+
+	// smtpPool keeps idle connections to one SMTP server open, so that not every message has
+	// to connect and authenticate again.
+	smtpPool struct {
+		mu          sync.Mutex
+		idle        []idleSMTPConn
+		maxIdle     int
+		idleTimeout time.Duration
+	}
+
+	idleSMTPConn struct {
+		gomail.SendCloser
+		since time.Time
+	}
+)
+
+// get returns the pool of the SMTP server. Pools live as long as the courier, so that
+// connections are reused across pulls from the queue.
+func (p *smtpPools) get(cfg *config.SMTPConfig) *smtpPool {
+	key := strings.Join([]string{cfg.ConnectionURI, cfg.ClientCertPath, cfg.ClientKeyPath, cfg.LocalName}, "\n")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pools == nil {
+		p.pools = make(map[string]*smtpPool)
+	}
+	pool, ok := p.pools[key]
+	if !ok {
+		pool = new(smtpPool)
+		p.pools[key] = pool
+	}
+	pool.configure(cfg.Pool)
+	return pool
+}
+
+func (p *smtpPool) configure(cfg config.SMTPPoolConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.maxIdle = cfg.MaxIdleConnections
+	p.idleTimeout = cfg.IdleTimeout
+	for len(p.idle) > max(p.maxIdle, 0) {
+		_ = p.idle[0].Close()
+		p.idle = p.idle[1:]
+	}
+}
+
+// take returns the most recently used idle connection, or nil if there is none. Connections
+// which were idle for longer than the idle timeout are closed, as the server likely closed
+// them already.
+func (p *smtpPool) take() gomail.SendCloser {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.idle) > 0 {
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.idleTimeout > 0 && time.Since(conn.since) > p.idleTimeout {
+			_ = conn.Close()
+			continue
+		}
+		return conn.SendCloser
+	}
+	return nil
+}
+
+// put hands a healthy connection back to the pool. It is closed if the pool is full.
+func (p *smtpPool) put(conn gomail.SendCloser) {
+	if p == nil {
+		_ = conn.Close()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle) >= p.maxIdle {
+		_ = conn.Close()
+		return
+	}
+	p.idle = append(p.idle, idleSMTPConn{SendCloser: conn, since: time.Now()})
+}
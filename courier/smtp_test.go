@@ -4,6 +4,7 @@
 package courier_test
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
@@ -15,8 +16,12 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"net"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -258,3 +263,156 @@ func generateTestClientCert() (clientCert *os.File, clientKey *os.File, err erro
 
 	return clientCert, clientKey, nil
 }
+
+// fakeSMTPServer accepts every message and records the connections and senders.
+type fakeSMTPServer struct {
+	url string
+
+	mu    sync.Mutex
+	conns int
+	from  []string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	s := &fakeSMTPServer{url: fmt.Sprintf("smtp://%s/?disable_starttls=true", l.Addr())}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.mu.Lock()
+			s.from = append(s.from, strings.Trim(strings.TrimSpace(line)[len("MAIL FROM:"):], "<>"))
+			s.mu.Unlock()
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 Go ahead")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+			}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) stats() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns, slices.Clone(s.from)
+}
+
+func TestSMTPConnectionPool(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	server := newFakeSMTPServer(t)
+	conf.MustSet(ctx, config.ViperKeyCourierSMTPURL, server.url)
+	conf.MustSet(ctx, config.ViperKeyCourierSMTPFrom, "test-stub@ory.sh")
+
+	c, err := reg.Courier(ctx)
+	require.NoError(t, err)
+
+	dispatch := func(t *testing.T, count int) {
+		t.Helper()
+		for range count {
+			queueNewMessage(t, ctx, c, reg)
+		}
+		require.NoError(t, c.DispatchQueue(ctx))
+	}
+
+	t.Run("case=reuses connections", func(t *testing.T) {
+		dispatch(t, 3)
+		dispatch(t, 2)
+
+		conns, from := server.stats()
+		assert.Equal(t, 1, conns)
+		assert.Len(t, from, 5)
+	})
+
+	t.Run("case=connects for every message without pooling", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyCourierSMTP+".pool.max_idle_connections", 0)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyCourierSMTP+".pool.max_idle_connections", 2) })
+
+		before, _ := server.stats()
+		dispatch(t, 2)
+
+		conns, _ := server.stats()
+		assert.Equal(t, 2, conns-before)
+	})
+}
+
+func TestSMTPSenders(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	defaultServer, brandServer := newFakeSMTPServer(t), newFakeSMTPServer(t)
+	conf.MustSet(ctx, config.ViperKeyCourierSMTPURL, defaultServer.url)
+	conf.MustSet(ctx, config.ViperKeyCourierSMTPFrom, "test-stub@ory.sh")
+	conf.MustSet(ctx, config.ViperKeyCourierSMTP+".senders", []map[string]any{
+		{"template_types": []string{"login_code_valid"}, "identity_schemas": []string{"acme"}, "connection_uri": brandServer.url, "from_address": "no-reply@acme.example"},
+		{"template_types": []string{"login_code_valid"}, "from_address": "login@ory.sh"},
+	})
+
+	c, err := reg.Courier(ctx)
+	require.NoError(t, err)
+
+	queueLoginCode := func(t *testing.T, schemaID string) {
+		t.Helper()
+		_, err := c.QueueEmail(ctx, templates.NewLoginCodeValid(reg, &templates.LoginCodeValidModel{
+			To:        "test-recipient-1@example.org",
+			LoginCode: "123456",
+			Identity:  map[string]interface{}{"schema_id": schemaID},
+		}))
+		require.NoError(t, err)
+	}
+
+	queueNewMessage(t, ctx, c, reg)
+	require.NoError(t, c.DispatchQueue(ctx))
+	queueLoginCode(t, "default")
+	require.NoError(t, c.DispatchQueue(ctx))
+	queueLoginCode(t, "acme")
+	require.NoError(t, c.DispatchQueue(ctx))
+
+	_, from := defaultServer.stats()
+	assert.Equal(t, []string{"test-stub@ory.sh", "login@ory.sh"}, from)
+	_, from = brandServer.stats()
+	assert.Equal(t, []string{"no-reply@acme.example"}, from)
+}
@@ -0,0 +1,66 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/ory/kratos/courier/template"
+)
+
+type (
+	LoginNotification struct {
+		deps  template.Dependencies
+		model *LoginNotificationModel
+	}
+	LoginNotificationModel struct {
+		To             string                 `json:"to"`
+		Identity       map[string]interface{} `json:"identity"`
+		RequestURL     string                 `json:"request_url"`
+		NewCountry     bool                   `json:"new_country"`
+		NewDevice      bool                   `json:"new_device"`
+		IPAddress      string                 `json:"ip_address"`
+		UserAgent      string                 `json:"user_agent"`
+		Location       string                 `json:"location"`
+		City           string                 `json:"city"`
+		Country        string                 `json:"country"`
+		ASN            int64                  `json:"asn"`
+		ASOrganization string                 `json:"as_organization"`
+		RevokeURL      string                 `json:"revoke_url"`
+		template.Branding
+	}
+)
+
+func NewLoginNotification(d template.Dependencies, m *LoginNotificationModel) *LoginNotification {
+	return &LoginNotification{deps: d, model: m}
+}
+
+func (t *LoginNotification) EmailRecipient() (string, error) {
+	return t.model.To, nil
+}
+
+func (t *LoginNotification) EmailSubject(ctx context.Context) (string, error) {
+	subject, err := template.LoadText(ctx, t.deps, os.DirFS(t.deps.CourierConfig().CourierTemplatesRoot(ctx)), "login_notification/email.subject.gotmpl", "login_notification/email.subject*", t.model, t.deps.CourierConfig().CourierTemplatesLoginNotification(ctx).Subject)
+
+	return strings.TrimSpace(subject), err
+}
+
+func (t *LoginNotification) EmailBody(ctx context.Context) (string, error) {
+	return template.LoadHTML(ctx, t.deps, os.DirFS(t.deps.CourierConfig().CourierTemplatesRoot(ctx)), "login_notification/email.body.gotmpl", "login_notification/email.body*", t.model, t.deps.CourierConfig().CourierTemplatesLoginNotification(ctx).Body.HTML)
+}
+
+func (t *LoginNotification) EmailBodyPlaintext(ctx context.Context) (string, error) {
+	return template.LoadText(ctx, t.deps, os.DirFS(t.deps.CourierConfig().CourierTemplatesRoot(ctx)), "login_notification/email.body.plaintext.gotmpl", "login_notification/email.body.plaintext*", t.model, t.deps.CourierConfig().CourierTemplatesLoginNotification(ctx).Body.PlainText)
+}
+
+func (t *LoginNotification) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.model)
+}
+
+func (t *LoginNotification) TemplateType() template.TemplateType {
+	return template.TypeLoginNotification
+}
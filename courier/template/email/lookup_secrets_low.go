@@ -0,0 +1,57 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/ory/kratos/courier/template"
+)
+
+type (
+	LookupSecretsLow struct {
+		deps  template.Dependencies
+		model *LookupSecretsLowModel
+	}
+	LookupSecretsLowModel struct {
+		To             string                 `json:"to"`
+		Identity       map[string]interface{} `json:"identity"`
+		RequestURL     string                 `json:"request_url"`
+		RemainingCodes int                    `json:"remaining_codes"`
+		template.Branding
+	}
+)
+
+func NewLookupSecretsLow(d template.Dependencies, m *LookupSecretsLowModel) *LookupSecretsLow {
+	return &LookupSecretsLow{deps: d, model: m}
+}
+
+func (t *LookupSecretsLow) EmailRecipient() (string, error) {
+	return t.model.To, nil
+}
+
+func (t *LookupSecretsLow) EmailSubject(ctx context.Context) (string, error) {
+	subject, err := template.LoadText(ctx, t.deps, os.DirFS(t.deps.CourierConfig().CourierTemplatesRoot(ctx)), "lookup_secrets_low/email.subject.gotmpl", "lookup_secrets_low/email.subject*", t.model, t.deps.CourierConfig().CourierTemplatesLookupSecretsLow(ctx).Subject)
+
+	return strings.TrimSpace(subject), err
+}
+
+func (t *LookupSecretsLow) EmailBody(ctx context.Context) (string, error) {
+	return template.LoadHTML(ctx, t.deps, os.DirFS(t.deps.CourierConfig().CourierTemplatesRoot(ctx)), "lookup_secrets_low/email.body.gotmpl", "lookup_secrets_low/email.body*", t.model, t.deps.CourierConfig().CourierTemplatesLookupSecretsLow(ctx).Body.HTML)
+}
+
+func (t *LookupSecretsLow) EmailBodyPlaintext(ctx context.Context) (string, error) {
+	return template.LoadText(ctx, t.deps, os.DirFS(t.deps.CourierConfig().CourierTemplatesRoot(ctx)), "lookup_secrets_low/email.body.plaintext.gotmpl", "lookup_secrets_low/email.body.plaintext*", t.model, t.deps.CourierConfig().CourierTemplatesLookupSecretsLow(ctx).Body.PlainText)
+}
+
+func (t *LookupSecretsLow) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.model)
+}
+
+func (t *LookupSecretsLow) TemplateType() template.TemplateType {
+	return template.TypeLookupSecretsLow
+}
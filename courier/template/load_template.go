@@ -10,6 +10,7 @@ import (
 	htemplate "html/template"
 	"io"
 	"io/fs"
+	"path"
 	"path/filepath"
 	"text/template"
 
@@ -145,10 +146,29 @@ func loadTemplate(filesystem fs.FS, name, pattern string, html bool) (Template,
 	return tpl, nil
 }
 
+// brandedTemplate returns the name and pattern of the template in the subdirectory of the model's
+// brand, if the filesystem contains it.
+func brandedTemplate(filesystem fs.FS, model interface{}, name, pattern string) (string, string, bool) {
+	m, ok := model.(brandedModel)
+	if !ok || m.TemplateBrand() == "" {
+		return name, pattern, false
+	}
+
+	brand := m.TemplateBrand()
+	if matches, _ := fs.Glob(filesystem, path.Join(brand, name)); matches == nil {
+		return name, pattern, false
+	}
+	if pattern != "" {
+		pattern = path.Join(brand, pattern)
+	}
+	return path.Join(brand, name), pattern, true
+}
+
 func LoadText(ctx context.Context, d templateDependencies, filesystem fs.FS, name, pattern string, model interface{}, remoteURL string) (string, error) {
 	var t Template
 	var err error
-	if remoteURL != "" {
+	name, pattern, branded := brandedTemplate(filesystem, model, name, pattern)
+	if remoteURL != "" && !branded {
 		t, err = loadRemoteTemplate(ctx, d, remoteURL, false)
 		if err != nil {
 			return "", err
@@ -170,7 +190,8 @@ func LoadText(ctx context.Context, d templateDependencies, filesystem fs.FS, nam
 func LoadHTML(ctx context.Context, d templateDependencies, filesystem fs.FS, name, pattern string, model interface{}, remoteURL string) (string, error) {
 	var t Template
 	var err error
-	if remoteURL != "" {
+	name, pattern, branded := brandedTemplate(filesystem, model, name, pattern)
+	if remoteURL != "" && !branded {
 		t, err = loadRemoteTemplate(ctx, d, remoteURL, true)
 		if err != nil {
 			return "", err
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -86,6 +87,27 @@ func TestLoadTextTemplate(t *testing.T) {
 		assert.Contains(t, actual, "lang=en_US")
 	})
 
+	t.Run("method=brand templates", func(t *testing.T) {
+		template.Cache, _ = lru.New[string, template.Template](16) // prevent Cache hit
+		ctx := context.Background()
+		_, reg := internal.NewFastRegistryWithMocks(t)
+		fs := fstest.MapFS{
+			"test_stub/email.body.gotmpl":      {Data: []byte("default body")},
+			"acme/test_stub/email.body.gotmpl": {Data: []byte("{{ .Brand }} body")},
+		}
+
+		load := func(t *testing.T, brand string) string {
+			model := &struct{ template.Branding }{template.Branding{Brand: brand}}
+			actual, err := template.LoadText(ctx, reg, fs, "test_stub/email.body.gotmpl", "", model, "")
+			require.NoError(t, err)
+			return actual
+		}
+
+		assert.Equal(t, "acme body", load(t, "acme"))
+		assert.Equal(t, "default body", load(t, "globex"), "falls back to the default template")
+		assert.Equal(t, "default body", load(t, ""))
+	})
+
 	t.Run("method=Cache works", func(t *testing.T) {
 		dir := os.TempDir()
 		name := x.NewUUID().String() + ".body.gotmpl"
@@ -12,3 +12,18 @@ type Dependencies interface {
 	CourierConfig() config.CourierConfigs
 	x.HTTPClientProvider
 }
+
+// Branding is embedded in the template models. Templates of a brand are loaded from the `<brand>`
+// subdirectory of the template root, if it contains them.
+type Branding struct {
+	// Brand is the brand of the flow which sent the message.
+	Brand string `json:"brand,omitempty"`
+}
+
+func (b Branding) TemplateBrand() string {
+	return b.Brand
+}
+
+type brandedModel interface {
+	TemplateBrand() string
+}
@@ -87,6 +87,10 @@ func TestRemoteTemplates(t *testing.T, basePath string, tmplType template.Templa
 			return email.NewLoginCodeValid(d, &email.LoginCodeValidModel{})
 		case template.TypeRegistrationCodeValid:
 			return email.NewRegistrationCodeValid(d, &email.RegistrationCodeValidModel{})
+		case template.TypeLoginNotification:
+			return email.NewLoginNotification(d, &email.LoginNotificationModel{})
+		case template.TypeLookupSecretsLow:
+			return email.NewLookupSecretsLow(d, &email.LookupSecretsLowModel{})
 		default:
 			return nil
 		}
@@ -9,15 +9,19 @@ package template
 type TemplateType string
 
 const (
-	TypeRecoveryInvalid         TemplateType = "recovery_invalid"
-	TypeRecoveryValid           TemplateType = "recovery_valid"
-	TypeRecoveryCodeInvalid     TemplateType = "recovery_code_invalid"
-	TypeRecoveryCodeValid       TemplateType = "recovery_code_valid"
-	TypeVerificationInvalid     TemplateType = "verification_invalid"
-	TypeVerificationValid       TemplateType = "verification_valid"
-	TypeVerificationCodeInvalid TemplateType = "verification_code_invalid"
-	TypeVerificationCodeValid   TemplateType = "verification_code_valid"
-	TypeTestStub                TemplateType = "stub"
-	TypeLoginCodeValid          TemplateType = "login_code_valid"
-	TypeRegistrationCodeValid   TemplateType = "registration_code_valid"
+	TypeRecoveryInvalid          TemplateType = "recovery_invalid"
+	TypeRecoveryValid            TemplateType = "recovery_valid"
+	TypeRecoveryCodeInvalid      TemplateType = "recovery_code_invalid"
+	TypeRecoveryCodeValid        TemplateType = "recovery_code_valid"
+	TypeVerificationInvalid      TemplateType = "verification_invalid"
+	TypeVerificationValid        TemplateType = "verification_valid"
+	TypeVerificationCodeInvalid  TemplateType = "verification_code_invalid"
+	TypeVerificationCodeValid    TemplateType = "verification_code_valid"
+	TypeTestStub                 TemplateType = "stub"
+	TypeLoginCodeValid           TemplateType = "login_code_valid"
+	TypeRegistrationCodeValid    TemplateType = "registration_code_valid"
+	TypeRecoveryPushApproval     TemplateType = "recovery_push_approval"
+	TypeVerificationPushApproval TemplateType = "verification_push_approval"
+	TypeLoginNotification        TemplateType = "login_notification"
+	TypeLookupSecretsLow         TemplateType = "lookup_secrets_low"
 )
@@ -97,6 +97,18 @@ func NewEmailTemplateFromMessage(d template.Dependencies, msg Message) (EmailTem
 			return nil, err
 		}
 		return email.NewRegistrationCodeValid(d, &t), nil
+	case template.TypeLoginNotification:
+		var t email.LoginNotificationModel
+		if err := json.Unmarshal(msg.TemplateData, &t); err != nil {
+			return nil, err
+		}
+		return email.NewLoginNotification(d, &t), nil
+	case template.TypeLookupSecretsLow:
+		var t email.LookupSecretsLowModel
+		if err := json.Unmarshal(msg.TemplateData, &t); err != nil {
+			return nil, err
+		}
+		return email.NewLookupSecretsLow(d, &t), nil
 	default:
 		return nil, errors.Errorf("received unexpected message template type: %s", msg.TemplateType)
 	}
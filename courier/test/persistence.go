@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -117,6 +118,49 @@ func TestPersister(ctx context.Context, newNetworkUnlessExisting NetworkWrapper,
 			assert.Equal(t, originalSendCount+1, ms[0].SendCount)
 		})
 
+		t.Run("case=concurrent workers never pull the same message", func(t *testing.T) {
+			_, p := newNetwork(t, ctx)
+
+			expected := make([]uuid.UUID, 20)
+			for k := range expected {
+				var message courier.Message
+				require.NoError(t, faker.FakeData(&message))
+				require.NoError(t, p.AddMessage(ctx, &message))
+				expected[k] = message.ID
+			}
+
+			var (
+				wg      sync.WaitGroup
+				mu      sync.Mutex
+				claimed = make(map[uuid.UUID]int)
+			)
+			for range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						ms, err := p.NextMessages(ctx, 3)
+						if errors.Is(err, courier.ErrQueueEmpty) {
+							return
+						} else if !assert.NoError(t, err) {
+							return
+						}
+
+						mu.Lock()
+						for _, m := range ms {
+							claimed[m.ID]++
+						}
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			for _, id := range expected {
+				assert.Equal(t, 1, claimed[id], "message %s must be pulled exactly once", id)
+			}
+		})
+
 		t.Run("case=list messages", func(t *testing.T) {
 			status := courier.MessageStatusProcessing
 			filter := courier.ListCourierMessagesParameters{
@@ -286,5 +330,71 @@ func TestPersister(ctx context.Context, newNetworkUnlessExisting NetworkWrapper,
 				require.ErrorIs(t, err, sqlcon.ErrNoRows)
 			})
 		})
+
+		t.Run("case=LatestSentMessage", func(t *testing.T) {
+			var message courier.Message
+			require.NoError(t, faker.FakeData(&message))
+			require.NoError(t, p.AddMessage(ctx, &message))
+
+			_, err := p.LatestSentMessage(ctx, message.Recipient)
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+
+			require.NoError(t, p.SetMessageStatus(ctx, message.ID, courier.MessageStatusSent))
+			actual, err := p.LatestSentMessage(ctx, message.Recipient)
+			require.NoError(t, err)
+			assert.Equal(t, message.ID, actual.ID)
+
+			t.Run("can not get on another network", func(t *testing.T) {
+				_, p := newNetwork(t, ctx)
+
+				_, err := p.LatestSentMessage(ctx, message.Recipient)
+				require.ErrorIs(t, err, sqlcon.ErrNoRows)
+			})
+		})
+
+		t.Run("case=RequeueMessage and RetryMessage", func(t *testing.T) {
+			var message courier.Message
+			require.NoError(t, faker.FakeData(&message))
+			require.NoError(t, p.AddMessage(ctx, &message))
+			require.NoError(t, p.SetMessageStatus(ctx, message.ID, courier.MessageStatusProcessing))
+			require.NoError(t, p.IncrementMessageSendCount(ctx, message.ID))
+
+			notBefore := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			require.NoError(t, p.RequeueMessage(ctx, message.ID, notBefore))
+			actual, err := p.FetchMessage(ctx, message.ID)
+			require.NoError(t, err)
+			assert.Equal(t, courier.MessageStatusQueued, actual.Status)
+			require.NotNil(t, actual.NextAttemptAt)
+			assert.True(t, notBefore.Equal(time.Time(*actual.NextAttemptAt)), "%s != %s", notBefore, time.Time(*actual.NextAttemptAt))
+
+			// Only messages which are being processed are requeued.
+			require.NoError(t, p.SetMessageStatus(ctx, message.ID, courier.MessageStatusDeadLetter))
+			require.NoError(t, p.RequeueMessage(ctx, message.ID, notBefore))
+			actual, err = p.FetchMessage(ctx, message.ID)
+			require.NoError(t, err)
+			assert.Equal(t, courier.MessageStatusDeadLetter, actual.Status)
+
+			require.NoError(t, p.RetryMessage(ctx, message.ID))
+			actual, err = p.FetchMessage(ctx, message.ID)
+			require.NoError(t, err)
+			assert.Equal(t, courier.MessageStatusQueued, actual.Status)
+			assert.Zero(t, actual.SendCount)
+			assert.Nil(t, actual.NextAttemptAt)
+
+			t.Run("can not retry on another network", func(t *testing.T) {
+				_, p := newNetwork(t, ctx)
+
+				require.ErrorIs(t, p.RetryMessage(ctx, message.ID), sqlcon.ErrNoRows)
+			})
+		})
+
+		t.Run("case=undeliverable recipients without addresses", func(t *testing.T) {
+			recipient := faker.Email()
+			require.NoError(t, p.SetRecipientUndeliverable(ctx, recipient))
+
+			undeliverable, err := p.IsRecipientUndeliverable(ctx, recipient)
+			require.NoError(t, err)
+			assert.False(t, undeliverable)
+		})
 	}
 }
@@ -0,0 +1,40 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package courier
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/ory/x/sqlxx"
+)
+
+// traceContext returns the trace context and baggage of ctx to be stored with a queued message.
+func traceContext(ctx context.Context) sqlxx.NullJSONRawMessage {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+
+	raw, err := json.Marshal(carrier)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// queuedContext returns a context with the trace context and baggage of the request which
+// queued the message.
+func (m Message) queuedContext() context.Context {
+	ctx := context.Background()
+	var carrier propagation.MapCarrier
+	if len(m.TraceContext) == 0 || json.Unmarshal(m.TraceContext, &carrier) != nil {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
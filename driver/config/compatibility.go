@@ -0,0 +1,112 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CompatibilitySwitch restores the behavior of a previous version of Ory Kratos. Switches
+// allow API consumers to upgrade Ory Kratos without updating their clients at the same time.
+// Every switch is removed after its removal deadline.
+type CompatibilitySwitch struct {
+	// Key is the name of the switch in the `compatibility` configuration section.
+	Key string
+
+	// Description describes the legacy behavior restored by the switch.
+	Description string
+
+	// RemovalDeadline is the date after which the switch will be removed.
+	RemovalDeadline time.Time
+}
+
+var (
+	// CompatibilityLegacyNodeOrdering keeps the UI nodes of login, registration, and settings
+	// flows in the order they were added by the strategies instead of sorting them by group.
+	CompatibilityLegacyNodeOrdering = CompatibilitySwitch{
+		Key:             "legacy_node_ordering",
+		Description:     "UI nodes are not sorted by group.",
+		RemovalDeadline: time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+	}
+
+	// CompatibilityLegacyErrorFormat additionally returns self-service errors as a list in
+	// the `errors` key of the error container.
+	CompatibilityLegacyErrorFormat = CompatibilitySwitch{
+		Key:             "legacy_error_format",
+		Description:     "Self-service errors are additionally returned as a list in the `errors` key.",
+		RemovalDeadline: time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+	}
+
+	// CompatibilityLegacyWebhookPayload removes the `request_cookies` and `session` keys from
+	// the payload passed to web hook Jsonnet templates.
+	CompatibilityLegacyWebhookPayload = CompatibilitySwitch{
+		Key:             "legacy_webhook_payload",
+		Description:     "Web hook payloads do not contain the `request_cookies` and `session` keys.",
+		RemovalDeadline: time.Date(2027, time.October, 31, 0, 0, 0, 0, time.UTC),
+	}
+
+	// CompatibilitySwitches are all available compatibility switches.
+	CompatibilitySwitches = []CompatibilitySwitch{
+		CompatibilityLegacyNodeOrdering,
+		CompatibilityLegacyErrorFormat,
+		CompatibilityLegacyWebhookPayload,
+	}
+
+	compatibilitySwitchEnabled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kratos_compatibility_switch_enabled",
+		Help: "Whether a compatibility switch is enabled (1) or not (0), labelled with the date after which the switch will be removed.",
+	}, []string{"switch", "removal_deadline"})
+
+	compatibilitySwitchUsed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_compatibility_switch_used_total",
+		Help: "Number of times the legacy behavior of a compatibility switch was applied.",
+	}, []string{"switch"})
+)
+
+// CompatibilityCollectors returns the Prometheus collectors which expose the state and usage of
+// the compatibility switches. They are registered by the registry's metrics setup.
+func CompatibilityCollectors() []prometheus.Collector {
+	return []prometheus.Collector{compatibilitySwitchEnabled, compatibilitySwitchUsed}
+}
+
+// CompatibilitySwitchEnabled returns true if the compatibility switch is enabled.
+func (p *Config) CompatibilitySwitchEnabled(ctx context.Context, s CompatibilitySwitch) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyCompatibility + "." + s.Key)
+}
+
+// UseCompatibilitySwitch returns true if the legacy behavior of the compatibility switch
+// should be applied, and records the usage in the metrics.
+func (p *Config) UseCompatibilitySwitch(ctx context.Context, s CompatibilitySwitch) bool {
+	if !p.CompatibilitySwitchEnabled(ctx, s) {
+		return false
+	}
+
+	compatibilitySwitchUsed.WithLabelValues(s.Key).Inc()
+	return true
+}
+
+// ReportCompatibilitySwitches logs all enabled compatibility switches together with their
+// removal deadline and exposes their state in the metrics.
+func (p *Config) ReportCompatibilitySwitches(ctx context.Context) {
+	for _, s := range CompatibilitySwitches {
+		deadline := s.RemovalDeadline.Format(time.DateOnly)
+		if !p.CompatibilitySwitchEnabled(ctx, s) {
+			compatibilitySwitchEnabled.WithLabelValues(s.Key, deadline).Set(0)
+			continue
+		}
+		compatibilitySwitchEnabled.WithLabelValues(s.Key, deadline).Set(1)
+
+		l := p.l.
+			WithField("compatibility_switch", ViperKeyCompatibility+"."+s.Key).
+			WithField("removal_deadline", deadline)
+		if time.Now().After(s.RemovalDeadline) {
+			l.Errorf("Compatibility switch %q has passed its removal deadline and will be removed in the next release: %s Update your API clients and disable the switch.", s.Key, s.Description)
+		} else {
+			l.Warnf("Compatibility switch %q is enabled: %s The switch will be removed after %s, update your API clients before then.", s.Key, s.Description, deadline)
+		}
+	}
+}
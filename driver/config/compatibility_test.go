@@ -0,0 +1,51 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package config_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/x/configx"
+	"github.com/ory/x/contextx"
+	"github.com/ory/x/logrusx"
+)
+
+func TestCompatibilitySwitches(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("case=disabled by default", func(t *testing.T) {
+		p := config.MustNew(t, logrusx.New("", ""), os.Stderr, &contextx.Default{}, configx.SkipValidation())
+		for _, s := range config.CompatibilitySwitches {
+			assert.False(t, p.CompatibilitySwitchEnabled(ctx, s), s.Key)
+			assert.False(t, p.UseCompatibilitySwitch(ctx, s), s.Key)
+		}
+	})
+
+	t.Run("case=reports enabled switches", func(t *testing.T) {
+		logger := logrusx.New("", "")
+		hook := new(test.Hook)
+		logger.Logger.Hooks.Add(hook)
+
+		p := config.MustNew(t, logger, os.Stderr, &contextx.Default{}, configx.SkipValidation(),
+			configx.WithValue(config.ViperKeyCompatibility+"."+config.CompatibilityLegacyErrorFormat.Key, true))
+		assert.True(t, p.UseCompatibilitySwitch(ctx, config.CompatibilityLegacyErrorFormat))
+		assert.False(t, p.UseCompatibilitySwitch(ctx, config.CompatibilityLegacyNodeOrdering))
+
+		hook.Reset()
+		p.ReportCompatibilitySwitches(ctx)
+
+		require.Len(t, hook.Entries, 1)
+		assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+		assert.Equal(t, "compatibility.legacy_error_format", hook.LastEntry().Data["compatibility_switch"])
+		assert.Equal(t, "2027-04-30", hook.LastEntry().Data["removal_deadline"])
+	})
+}
@@ -6,15 +6,22 @@ package config
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
+	"reflect"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -36,14 +43,17 @@ import (
 	"github.com/ory/jsonschema/v3"
 	"github.com/ory/jsonschema/v3/httploader"
 	"github.com/ory/kratos/embedx"
+	"github.com/ory/kratos/x/egress"
 	"github.com/ory/x/configx"
 	"github.com/ory/x/contextx"
+	"github.com/ory/x/dbal"
 	"github.com/ory/x/httpx"
 	"github.com/ory/x/jsonschemax"
 	"github.com/ory/x/logrusx"
 	"github.com/ory/x/otelx"
 	"github.com/ory/x/stringsx"
 	"github.com/ory/x/tlsx"
+	"github.com/ory/x/urlx"
 	"github.com/ory/x/watcherx"
 )
 
@@ -74,18 +84,35 @@ const (
 	ViperKeyCourierHTTPRequestConfig                         = "courier.http.request_config"
 	ViperKeyCourierTemplatesLoginCodeValidEmail              = "courier.templates.login_code.valid.email"
 	ViperKeyCourierTemplatesRegistrationCodeValidEmail       = "courier.templates.registration_code.valid.email"
+	ViperKeyCourierTemplatesLoginNotificationEmail           = "courier.templates.login_notification.email"
+	ViperKeyCourierTemplatesLookupSecretsLowEmail            = "courier.templates.lookup_secrets_low.email"
 	ViperKeyCourierSMTP                                      = "courier.smtp"
 	ViperKeyCourierSMTPFrom                                  = "courier.smtp.from_address"
 	ViperKeyCourierSMTPFromName                              = "courier.smtp.from_name"
 	ViperKeyCourierSMTPHeaders                               = "courier.smtp.headers"
 	ViperKeyCourierSMTPLocalName                             = "courier.smtp.local_name"
 	ViperKeyCourierMessageRetries                            = "courier.message_retries"
+	ViperKeyCourierDeliveryReceiptsEnabled                   = "courier.delivery_receipts.enabled"
+	ViperKeyCourierDeliveryReceiptsToken                     = "courier.delivery_receipts.token"
+	ViperKeyCourierDeliveryReceiptsSuppressUndeliverable     = "courier.delivery_receipts.suppress_undeliverable"
+	ViperKeyCourierDeliveryReceiptsSESTopicARNs              = "courier.delivery_receipts.ses.topic_arns"
+	ViperKeyCourierDeliveryReceiptsSendGridVerificationKey   = "courier.delivery_receipts.sendgrid.verification_key"
+	ViperKeyCourierDeliveryReceiptsMailgunSigningKey         = "courier.delivery_receipts.mailgun.signing_key"
+	ViperKeyCourierDeliveryReceiptsTwilioAuthToken           = "courier.delivery_receipts.twilio.auth_token"
 	ViperKeyCourierWorkerPullCount                           = "courier.worker.pull_count"
 	ViperKeyCourierWorkerPullWait                            = "courier.worker.pull_wait"
+	ViperKeyCourierWorkerInstantDispatch                     = "courier.worker.instant_dispatch"
+	ViperKeyCourierWorkerChannels                            = "courier.worker.channels"
+	ViperKeyCourierWorkerRetryInitialInterval                = "courier.worker.retry_backoff.initial_interval"
+	ViperKeyCourierWorkerRetryMaxInterval                    = "courier.worker.retry_backoff.max_interval"
 	ViperKeyCourierChannels                                  = "courier.channels"
 	ViperKeySecretsDefault                                   = "secrets.default"
 	ViperKeySecretsCookie                                    = "secrets.cookie"
 	ViperKeySecretsCipher                                    = "secrets.cipher"
+	ViperKeySecretsBundle                                    = "secrets.bundle"
+	ViperKeySecretsTraits                                    = "secrets.traits"
+	ViperKeySecretsPairwise                                  = "secrets.pairwise"
+	ViperKeySecretBackendsRefreshInterval                    = "secret_backends.refresh_interval"
 	ViperKeyDisablePublicHealthRequestLog                    = "serve.public.request_log.disable_for_health"
 	ViperKeyPublicBaseURL                                    = "serve.public.base_url"
 	ViperKeyPublicPort                                       = "serve.public.port"
@@ -97,6 +124,14 @@ const (
 	ViperKeyPublicTLSKeyBase64                               = "serve.public.tls.key.base64"
 	ViperKeyPublicTLSCertPath                                = "serve.public.tls.cert.path"
 	ViperKeyPublicTLSKeyPath                                 = "serve.public.tls.key.path"
+	ViperKeyPublicLoadSheddingEnabled                        = "serve.public.load_shedding.enabled"
+	ViperKeyPublicLoadSheddingRetryAfter                     = "serve.public.load_shedding.retry_after"
+	ViperKeyPublicLoadSheddingLowInFlight                    = "serve.public.load_shedding.low_priority.max_in_flight"
+	ViperKeyPublicLoadSheddingLowLatency                     = "serve.public.load_shedding.low_priority.max_persister_latency"
+	ViperKeyPublicLoadSheddingHighInFlight                   = "serve.public.load_shedding.high_priority.max_in_flight"
+	ViperKeyPublicLoadSheddingHighLatency                    = "serve.public.load_shedding.high_priority.max_persister_latency"
+	ViperKeyPublicLoadSheddingStreamsInFlight                = "serve.public.load_shedding.streams.max_in_flight"
+	ViperKeyPublicLoadSheddingStreamsLatency                 = "serve.public.load_shedding.streams.max_persister_latency"
 	ViperKeyDisableAdminHealthRequestLog                     = "serve.admin.request_log.disable_for_health"
 	ViperKeyAdminBaseURL                                     = "serve.admin.base_url"
 	ViperKeyAdminPort                                        = "serve.admin.port"
@@ -108,6 +143,21 @@ const (
 	ViperKeyAdminTLSKeyBase64                                = "serve.admin.tls.key.base64"
 	ViperKeyAdminTLSCertPath                                 = "serve.admin.tls.cert.path"
 	ViperKeyAdminTLSKeyPath                                  = "serve.admin.tls.key.path"
+	ViperKeyAdminAPITokens                                   = "serve.admin.api_tokens"
+	ViperKeyGRPCEnabled                                      = "serve.grpc.enabled"
+	ViperKeyGRPCPublicPort                                   = "serve.grpc.public.port"
+	ViperKeyGRPCPublicHost                                   = "serve.grpc.public.host"
+	ViperKeyGRPCPublicReflection                             = "serve.grpc.public.reflection"
+	ViperKeyGRPCPublicTLSCertBase64                          = "serve.grpc.public.tls.cert.base64"
+	ViperKeyGRPCPublicTLSKeyBase64                           = "serve.grpc.public.tls.key.base64"
+	ViperKeyGRPCPublicTLSCertPath                            = "serve.grpc.public.tls.cert.path"
+	ViperKeyGRPCPublicTLSKeyPath                             = "serve.grpc.public.tls.key.path"
+	ViperKeyGRPCAdminPort                                    = "serve.grpc.admin.port"
+	ViperKeyGRPCAdminHost                                    = "serve.grpc.admin.host"
+	ViperKeyGRPCAdminTLSCertBase64                           = "serve.grpc.admin.tls.cert.base64"
+	ViperKeyGRPCAdminTLSKeyBase64                            = "serve.grpc.admin.tls.key.base64"
+	ViperKeyGRPCAdminTLSCertPath                             = "serve.grpc.admin.tls.cert.path"
+	ViperKeyGRPCAdminTLSKeyPath                              = "serve.grpc.admin.tls.key.path"
 	ViperKeySessionLifespan                                  = "session.lifespan"
 	ViperKeySessionSameSite                                  = "session.cookie.same_site"
 	ViperKeySessionSecure                                    = "session.cookie.secure"
@@ -121,17 +171,48 @@ const (
 	ViperKeyFeatureFlagFasterSessionExtend                   = "feature_flags.faster_session_extend"
 	ViperKeySessionWhoAmICachingMaxAge                       = "feature_flags.cacheable_sessions_max_age"
 	ViperKeyUseContinueWithTransitions                       = "feature_flags.use_continue_with_transitions"
+	ViperKeyFeatureFlagErrorEnvelope                         = "feature_flags.error_envelope"
 	ViperKeySessionRefreshMinTimeLeft                        = "session.earliest_possible_extend"
+	ViperKeySessionIdleTimeout                               = "session.idle_timeout"
+	ViperKeySessionActivityUpdateInterval                    = "session.activity_update_interval"
+	ViperKeySessionRememberMeEnabled                         = "session.remember_me.enabled"
+	ViperKeySessionRememberMeShortLifespan                   = "session.remember_me.short_lifespan"
+	ViperKeySessionRememberMeMethods                         = "session.remember_me.methods"
+	ViperKeySessionGeoIPCityDatabase                         = "session.geoip.city_database"
+	ViperKeySessionGeoIPASNDatabase                          = "session.geoip.asn_database"
+	ViperKeySessionRevokeLinkLifespan                        = "session.revoke_link.lifespan"
+	ViperKeySessionRevokeLinkUIURL                           = "session.revoke_link.ui_url"
+	ViperKeySessionEventsWarnBefore                          = "session.events.warn_before"
+	ViperKeySessionMetadataMaxSize                           = "session.metadata.max_size"
+	ViperKeySessionMetadataSchema                            = "session.metadata.schema"
+	ViperKeySessionConcurrencyMaxSessions                    = "session.concurrency.max_sessions"
+	ViperKeySessionConcurrencyPolicy                         = "session.concurrency.policy"
+	ViperKeySessionEventsCheckInterval                       = "session.events.check_interval"
+	ViperKeySessionIntrospectMaxItems                        = "session.introspect.max_items"
 	ViperKeyCookieSameSite                                   = "cookies.same_site"
 	ViperKeyCookieDomain                                     = "cookies.domain"
 	ViperKeyCookiePath                                       = "cookies.path"
 	ViperKeyCookieSecure                                     = "cookies.secure"
 	ViperKeySelfServiceStrategyConfig                        = "selfservice.methods"
 	ViperKeySelfServiceBrowserDefaultReturnTo                = "selfservice." + DefaultBrowserReturnURL
+	ViperKeySelfServiceFunnelTrackingEnabled                 = "selfservice.funnel_tracking.enabled"
+	ViperKeySelfServiceMFAEnrollmentCampaign                 = "selfservice.mfa_enrollment_campaign"
+	ViperKeySelfServiceDisposableEmailsEnabled               = "selfservice.disposable_emails.enabled"
+	ViperKeySelfServiceDisposableEmailsBlocklistURL          = "selfservice.disposable_emails.blocklist_url"
+	ViperKeySelfServiceDisposableEmailsRefreshInterval       = "selfservice.disposable_emails.refresh_interval"
+	ViperKeySelfServiceBrands                                = "selfservice.brands"
+	ViperKeySelfServiceLocalization                          = "selfservice.localization"
+	ViperKeySelfServiceMessageOverrides                      = "selfservice.message_overrides"
 	ViperKeyURLsAllowedReturnToDomains                       = "selfservice.allowed_return_urls"
+	ViperKeyURLsReturnToGrants                               = "selfservice.return_to_grants"
+	ViperKeySelfServiceHostedUIEnabled                       = "selfservice.hosted_ui.enabled"
+	ViperKeySelfServiceDebugTraceEnabled                     = "selfservice.debug_trace.enabled"
+	ViperKeySelfServiceDebugTraceResponse                    = "selfservice.debug_trace.response"
+	ViperKeySelfServiceHostedUITheme                         = "selfservice.hosted_ui.theme"
 	ViperKeySelfServiceRegistrationEnabled                   = "selfservice.flows.registration.enabled"
 	ViperKeySelfServiceRegistrationLoginHints                = "selfservice.flows.registration.login_hints"
 	ViperKeySelfServiceRegistrationEnableLegacyOneStep       = "selfservice.flows.registration.enable_legacy_one_step"
+	ViperKeySelfServiceRegistrationSteps                     = "selfservice.flows.registration.steps"
 	ViperKeySelfServiceRegistrationUI                        = "selfservice.flows.registration.ui_url"
 	ViperKeySelfServiceRegistrationRequestLifespan           = "selfservice.flows.registration.lifespan"
 	ViperKeySelfServiceRegistrationAfter                     = "selfservice.flows.registration.after"
@@ -139,17 +220,27 @@ const (
 	ViperKeySelfServiceLoginUI                               = "selfservice.flows.login.ui_url"
 	ViperKeySelfServiceLoginFlowStyle                        = "selfservice.flows.login.style"
 	ViperKeySecurityAccountEnumerationMitigate               = "security.account_enumeration.mitigate"
+	ViperKeySecurityCSRFTokenRotation                        = "security.csrf.token_rotation"
+	ViperKeySecurityCSRFCookieSameSite                       = "security.csrf.cookie.same_site"
+	ViperKeySecurityCSRFCookiePartitioned                    = "security.csrf.cookie.partitioned"
 	ViperKeySelfServiceLoginRequestLifespan                  = "selfservice.flows.login.lifespan"
 	ViperKeySelfServiceLoginAfter                            = "selfservice.flows.login.after"
 	ViperKeySelfServiceLoginBeforeHooks                      = "selfservice.flows.login.before.hooks"
+	ViperKeySelfServiceLoginFirstFactorPolicies              = "selfservice.flows.login.first_factor_policies"
 	ViperKeySelfServiceErrorUI                               = "selfservice.flows.error.ui_url"
 	ViperKeySelfServiceLogoutBrowserDefaultReturnTo          = "selfservice.flows.logout.after." + DefaultBrowserReturnURL
+	ViperKeySelfServiceCrossDeviceLoginEnabled               = "selfservice.flows.cross_device_login.enabled"
+	ViperKeySelfServiceCrossDeviceLoginUI                    = "selfservice.flows.cross_device_login.ui_url"
+	ViperKeySelfServiceCrossDeviceLoginLifespan              = "selfservice.flows.cross_device_login.lifespan"
+	ViperKeySelfServiceCrossDeviceLoginPollInterval          = "selfservice.flows.cross_device_login.poll_interval"
 	ViperKeySelfServiceSettingsURL                           = "selfservice.flows.settings.ui_url"
 	ViperKeySelfServiceSettingsAfter                         = "selfservice.flows.settings.after"
 	ViperKeySelfServiceSettingsBeforeHooks                   = "selfservice.flows.settings.before.hooks"
 	ViperKeySelfServiceSettingsRequestLifespan               = "selfservice.flows.settings.lifespan"
 	ViperKeySelfServiceSettingsPrivilegedAuthenticationAfter = "selfservice.flows.settings.privileged_session_max_age"
 	ViperKeySelfServiceSettingsRequiredAAL                   = "selfservice.flows.settings.required_aal"
+	ViperKeySelfServiceSettingsRequiredAuthenticationMethods = "selfservice.flows.settings.required_authentication_methods"
+	ViperKeySelfServiceSettingsGroups                        = "selfservice.flows.settings.groups"
 	ViperKeySelfServiceRecoveryAfter                         = "selfservice.flows.recovery.after"
 	ViperKeySelfServiceRecoveryBeforeHooks                   = "selfservice.flows.recovery.before.hooks"
 	ViperKeySelfServiceRecoveryEnabled                       = "selfservice.flows.recovery.enabled"
@@ -158,6 +249,7 @@ const (
 	ViperKeySelfServiceRecoveryRequestLifespan               = "selfservice.flows.recovery.lifespan"
 	ViperKeySelfServiceRecoveryBrowserDefaultReturnTo        = "selfservice.flows.recovery.after." + DefaultBrowserReturnURL
 	ViperKeySelfServiceRecoveryNotifyUnknownRecipients       = "selfservice.flows.recovery.notify_unknown_recipients"
+	ViperKeySelfServiceRecoveryNativeDeepLink                = "selfservice.flows.recovery.native_deep_link"
 	ViperKeySelfServiceVerificationEnabled                   = "selfservice.flows.verification.enabled"
 	ViperKeySelfServiceVerificationUI                        = "selfservice.flows.verification.ui_url"
 	ViperKeySelfServiceVerificationRequestLifespan           = "selfservice.flows.verification.lifespan"
@@ -166,8 +258,23 @@ const (
 	ViperKeySelfServiceVerificationBeforeHooks               = "selfservice.flows.verification.before.hooks"
 	ViperKeySelfServiceVerificationUse                       = "selfservice.flows.verification.use"
 	ViperKeySelfServiceVerificationNotifyUnknownRecipients   = "selfservice.flows.verification.notify_unknown_recipients"
+	ViperKeySelfServiceVerificationNativeDeepLink            = "selfservice.flows.verification.native_deep_link"
+	ViperKeySelfServiceVerificationRequiredForLogin          = "selfservice.flows.verification.required_for.login"
+	ViperKeySelfServiceVerificationRequiredForOIDCLinking    = "selfservice.flows.verification.required_for.oidc_linking"
+	ViperKeySelfServiceVerificationRequiredForRecovery       = "selfservice.flows.verification.required_for.recovery"
 	ViperKeyDefaultIdentitySchemaID                          = "identity.default_schema_id"
 	ViperKeyIdentitySchemas                                  = "identity.schemas"
+	ViperKeyIdentitySchemasWatchInterval                     = "identity.schemas_watch_interval"
+	ViperKeyIdentityDerivedTraitsMapper                      = "identity.derived_traits.mapper_url"
+	ViperKeyIdentityEmailNormalizationStripPlusAlias         = "identity.email_normalization.strip_plus_alias"
+	ViperKeyIdentityEmailNormalizationStripGmailDots         = "identity.email_normalization.strip_gmail_dots"
+	ViperKeyIdentityPhoneNormalizationDefaultRegion          = "identity.phone_normalization.default_region"
+	ViperKeyIdentityLifecycleAutoArchiveAfter                = "identity.lifecycle.auto_archive_after"
+	ViperKeyIdentityLifecycleLoginBehavior                   = "identity.lifecycle.login_behavior"
+	ViperKeyCourierTemplates                                 = "courier.templates"
+	ViperKeySelfServiceOIDCProviders                         = "selfservice.methods.oidc.config.providers"
+	ViperKeyFeatureFlags                                     = "feature_flags"
+	ViperKeyCompatibility                                    = "compatibility"
 	ViperKeyHasherAlgorithm                                  = "hashers.algorithm"
 	ViperKeyHasherArgon2ConfigMemory                         = "hashers.argon2.memory"
 	ViperKeyHasherArgon2ConfigIterations                     = "hashers.argon2.iterations"
@@ -180,18 +287,51 @@ const (
 	ViperKeyHasherBcryptCost                                 = "hashers.bcrypt.cost"
 	ViperKeyCipherAlgorithm                                  = "ciphers.algorithm"
 	ViperKeyDatabaseCleanupSleepTables                       = "database.cleanup.sleep.tables"
+	ViperKeyDatabaseCleanupSleepBatches                      = "database.cleanup.sleep.batches"
 	ViperKeyDatabaseCleanupBatchSize                         = "database.cleanup.batch_size"
+	ViperKeyDatabaseCleanupRetention                         = "database.cleanup.retention"
+	ViperKeyDatabaseFlowStorageRedisURL                      = "database.flow_storage.redis.url"
+	ViperKeyDatabaseSQLiteJournalMode                        = "database.sqlite.journal_mode"
+	ViperKeyDatabaseSQLiteSynchronous                        = "database.sqlite.synchronous"
+	ViperKeyDatabaseSQLiteBusyTimeout                        = "database.sqlite.busy_timeout"
+	ViperKeyDatabaseSQLiteTransactionLock                    = "database.sqlite.transaction_lock"
+	ViperKeyDatabaseSQLiteCheckpointMode                     = "database.sqlite.checkpoint.mode"
+	ViperKeyDatabaseSQLiteEmbedded                           = "database.sqlite.embedded"
+	ViperKeyHealthReadinessTimeout                           = "health.readiness.timeout"
+	ViperKeyHealthReadinessDatabaseEnabled                   = "health.readiness.checks.database.enabled"
+	ViperKeyHealthReadinessDatabaseMaxReplicationLag         = "health.readiness.checks.database.max_replication_lag"
+	ViperKeyHealthReadinessSMTPEnabled                       = "health.readiness.checks.smtp.enabled"
+	ViperKeyHealthReadinessCourierBacklogEnabled             = "health.readiness.checks.courier_backlog.enabled"
+	ViperKeyHealthReadinessCourierBacklogMaxMessages         = "health.readiness.checks.courier_backlog.max_messages"
+	ViperKeyHealthReadinessIdentitySchemasEnabled            = "health.readiness.checks.identity_schemas.enabled"
+	ViperKeyJobsEnabled                                      = "jobs.enabled"
+	ViperKeyJobsLeaseDuration                                = "jobs.lease_duration"
+	ViperKeyJobsSchedules                                    = "jobs.schedules"
+	ViperKeyJobsDisabled                                     = "jobs.disabled"
+	ViperKeyLogAuditSinkURL                                  = "log.audit_sink.url"
+	ViperKeyLogAuditSinkFormat                               = "log.audit_sink.format"
+	ViperKeyLogAuditSinkHeaders                              = "log.audit_sink.headers"
+	ViperKeyLogAuditSinkBufferSize                           = "log.audit_sink.buffer_size"
+	ViperKeyLogAuditSinkMaxRetries                           = "log.audit_sink.max_retries"
+	ViperKeyLogAuditSinkBlockTimeout                         = "log.audit_sink.block_timeout"
 	ViperKeyLinkLifespan                                     = "selfservice.methods.link.config.lifespan"
 	ViperKeyLinkBaseURL                                      = "selfservice.methods.link.config.base_url"
 	ViperKeyCodeLifespan                                     = "selfservice.methods.code.config.lifespan"
 	ViperKeyCodeConfigMissingCredentialFallbackEnabled       = "selfservice.methods.code.config.missing_credential_fallback_enabled"
+	ViperKeyCodeConfigPushApprovalEnabled                    = "selfservice.methods.code.config.push_approval_enabled"
 	ViperKeyPasswordHaveIBeenPwnedHost                       = "selfservice.methods.password.config.haveibeenpwned_host"
 	ViperKeyPasswordHaveIBeenPwnedEnabled                    = "selfservice.methods.password.config.haveibeenpwned_enabled"
 	ViperKeyPasswordMaxBreaches                              = "selfservice.methods.password.config.max_breaches"
 	ViperKeyPasswordMinLength                                = "selfservice.methods.password.config.min_password_length"
 	ViperKeyPasswordIdentifierSimilarityCheckEnabled         = "selfservice.methods.password.config.identifier_similarity_check_enabled"
 	ViperKeyIgnoreNetworkErrors                              = "selfservice.methods.password.config.ignore_network_errors"
+	ViperKeyPasswordMaxAge                                   = "selfservice.methods.password.config.max_age"
+	ViperKeyPasswordExpiryWarning                            = "selfservice.methods.password.config.expiry_warning"
 	ViperKeyTOTPIssuer                                       = "selfservice.methods.totp.config.issuer"
+	ViperKeyLookupSecretLowCodesThreshold                    = "selfservice.methods.lookup_secret.config.low_codes_threshold"
+	ViperKeyTOTPQRCodeFormat                                 = "selfservice.methods.totp.config.qr_code.format"
+	ViperKeyTOTPQRCodeSource                                 = "selfservice.methods.totp.config.qr_code.source"
+	ViperKeyTOTPSecretKeyNode                                = "selfservice.methods.totp.config.secret_key_node"
 	ViperKeyOIDCBaseRedirectURL                              = "selfservice.methods.oidc.config.base_redirect_uri"
 	ViperKeySAMLBaseRedirectURL                              = "selfservice.methods.saml.config.base_redirect_uri"
 	ViperKeyWebAuthnRPDisplayName                            = "selfservice.methods.webauthn.config.rp.display_name"
@@ -199,15 +339,27 @@ const (
 	ViperKeyWebAuthnRPOrigin                                 = "selfservice.methods.webauthn.config.rp.origin"
 	ViperKeyWebAuthnRPOrigins                                = "selfservice.methods.webauthn.config.rp.origins"
 	ViperKeyWebAuthnPasswordless                             = "selfservice.methods.webauthn.config.passwordless"
+	ViperKeyWebAuthnAttestationMDSEnabled                    = "selfservice.methods.webauthn.config.attestation.mds.enabled"
+	ViperKeyWebAuthnAttestationMDSCachePath                  = "selfservice.methods.webauthn.config.attestation.mds.cache_path"
+	ViperKeyWebAuthnAttestationAllowedAAGUIDs                = "selfservice.methods.webauthn.config.attestation.allowed_aaguids"
+	ViperKeyWebAuthnAttestationDeniedAAGUIDs                 = "selfservice.methods.webauthn.config.attestation.denied_aaguids"
 	ViperKeyPasskeyEnabled                                   = "selfservice.methods.passkey.enabled"
 	ViperKeyPasskeyRPDisplayName                             = "selfservice.methods.passkey.config.rp.display_name"
 	ViperKeyPasskeyRPID                                      = "selfservice.methods.passkey.config.rp.id"
 	ViperKeyPasskeyRPOrigins                                 = "selfservice.methods.passkey.config.rp.origins"
+	ViperKeyPasskeyAttestationMDSEnabled                     = "selfservice.methods.passkey.config.attestation.mds.enabled"
+	ViperKeyPasskeyAttestationMDSCachePath                   = "selfservice.methods.passkey.config.attestation.mds.cache_path"
+	ViperKeyPasskeyAttestationAllowedAAGUIDs                 = "selfservice.methods.passkey.config.attestation.allowed_aaguids"
+	ViperKeyPasskeyAttestationDeniedAAGUIDs                  = "selfservice.methods.passkey.config.attestation.denied_aaguids"
 	ViperKeyOAuth2ProviderURL                                = "oauth2_provider.url"
 	ViperKeyOAuth2ProviderHeader                             = "oauth2_provider.headers"
 	ViperKeyOAuth2ProviderOverrideReturnTo                   = "oauth2_provider.override_return_to"
 	ViperKeyClientHTTPNoPrivateIPRanges                      = "clients.http.disallow_private_ip_ranges"
 	ViperKeyClientHTTPPrivateIPExceptionURLs                 = "clients.http.private_ip_exception_urls"
+	ViperKeyClientHTTPProxyURL                               = "clients.http.proxy_url"
+	ViperKeyClientHTTPAllowedCIDRs                           = "clients.http.allowed_cidrs"
+	ViperKeyClientHTTPDeniedCIDRs                            = "clients.http.denied_cidrs"
+	ViperKeyClientHTTPIntegrations                           = "clients.http.integrations"
 	ViperKeyWebhookHeaderAllowlist                           = "clients.web_hook.header_allowlist"
 	ViperKeyPreviewDefaultReadConsistencyLevel               = "preview.default_read_consistency_level"
 	ViperKeyVersion                                          = "version"
@@ -229,6 +381,48 @@ const (
 // DefaultSessionCookieName returns the default cookie name for the kratos session.
 const DefaultSessionCookieName = "ory_kratos_session"
 
+// CSRFTokenRotation defines when the anti-CSRF token is rotated.
+type CSRFTokenRotation string
+
+const (
+	// CSRFTokenRotationSession rotates the anti-CSRF token when the session changes, for example on login or logout.
+	CSRFTokenRotationSession CSRFTokenRotation = "session"
+
+	// CSRFTokenRotationFlow additionally rotates the anti-CSRF token whenever a browser flow is initialized.
+	CSRFTokenRotationFlow CSRFTokenRotation = "flow"
+
+	// CSRFTokenRotationRequest additionally rotates the anti-CSRF token whenever a browser flow is submitted.
+	CSRFTokenRotationRequest CSRFTokenRotation = "request"
+)
+
+// SessionConcurrencyPolicy defines what happens when an identity reaches the maximum number of
+// concurrent sessions.
+type SessionConcurrencyPolicy string
+
+const (
+	// SessionConcurrencyPolicyReject rejects logins which would exceed the limit.
+	SessionConcurrencyPolicyReject SessionConcurrencyPolicy = "reject"
+
+	// SessionConcurrencyPolicyEvictOldest revokes the sessions which were issued first.
+	SessionConcurrencyPolicyEvictOldest SessionConcurrencyPolicy = "evict_oldest"
+
+	// SessionConcurrencyPolicyEvictLeastRecentlyUsed revokes the sessions which were changed or
+	// extended least recently.
+	SessionConcurrencyPolicyEvictLeastRecentlyUsed SessionConcurrencyPolicy = "evict_least_recently_used"
+)
+
+// IdentityLoginBehavior defines what happens when an identity which is not active signs in.
+type IdentityLoginBehavior string
+
+const (
+	// IdentityLoginBehaviorDeny rejects the login.
+	IdentityLoginBehaviorDeny IdentityLoginBehavior = "deny"
+
+	// IdentityLoginBehaviorReactivate changes the state of the identity to active and continues
+	// the login.
+	IdentityLoginBehaviorReactivate IdentityLoginBehavior = "reactivate"
+)
+
 type (
 	Argon2 struct {
 		Memory            bytesize.ByteSize `json:"memory"`
@@ -257,16 +451,73 @@ type (
 		MFAEnabled          bool `json:"mfa_enabled"`
 	}
 	Schema struct {
-		ID  string `json:"id" koanf:"id"`
-		URL string `json:"url" koanf:"url"`
+		ID          string            `json:"id" koanf:"id"`
+		URL         string            `json:"url" koanf:"url"`
+		SelfService SchemaSelfService `json:"selfservice" koanf:"selfservice"`
+	}
+	SchemaSelfService struct {
+		// Selectable is true if the schema can be chosen when initializing a registration flow.
+		Selectable bool `json:"selectable,omitempty" koanf:"selectable"`
+
+		// RegistrationMethods restricts the methods available for registering identities with
+		// this schema. All enabled methods are available if empty.
+		RegistrationMethods []string `json:"registration_methods,omitempty" koanf:"registration_methods"`
+
+		// MFAEnrollmentRolloutPercentage overrides the rollout percentage of the MFA enrollment
+		// campaign for identities with this schema.
+		MFAEnrollmentRolloutPercentage *int `json:"mfa_enrollment_rollout_percentage,omitempty" koanf:"mfa_enrollment_rollout_percentage"`
+
+		// BlockDisposableEmails overrides whether disposable email addresses are rejected for
+		// identities with this schema.
+		BlockDisposableEmails *bool `json:"block_disposable_emails,omitempty" koanf:"block_disposable_emails"`
+
+		// TOTP overrides the issuer and label of TOTP keys for identities with this schema.
+		TOTP *SchemaSelfServiceTOTP `json:"totp,omitempty" koanf:"totp"`
+	}
+	SchemaSelfServiceTOTP struct {
+		// Issuer is a Go template rendering the issuer shown in the TOTP app.
+		Issuer string `json:"issuer,omitempty" koanf:"issuer"`
+
+		// Label is a Go template rendering the account name shown in the TOTP app.
+		Label string `json:"label,omitempty" koanf:"label"`
+	}
+	MFAEnrollmentCampaign struct {
+		Enabled            bool
+		RedirectAfterLogin bool
+		// Deadline is zero if the campaign has no deadline.
+		Deadline          time.Time
+		RolloutPercentage int
+	}
+	DisposableEmails struct {
+		Enabled bool
+		// BlocklistURL is empty if only the bundled blocklist is used.
+		BlocklistURL    string
+		RefreshInterval time.Duration
+	}
+	LoginFirstFactorPolicy struct {
+		IdentitySchema string   `json:"identity_schema" koanf:"identity_schema"`
+		Methods        []string `json:"methods" koanf:"methods"`
+		Minimum        int      `json:"minimum" koanf:"minimum"`
 	}
 	PasswordPolicy struct {
-		HaveIBeenPwnedHost               string `json:"haveibeenpwned_host"`
-		HaveIBeenPwnedEnabled            bool   `json:"haveibeenpwned_enabled"`
-		MaxBreaches                      uint   `json:"max_breaches"`
-		IgnoreNetworkErrors              bool   `json:"ignore_network_errors"`
-		MinPasswordLength                uint   `json:"min_password_length"`
-		IdentifierSimilarityCheckEnabled bool   `json:"identifier_similarity_check_enabled"`
+		HaveIBeenPwnedHost               string        `json:"haveibeenpwned_host"`
+		HaveIBeenPwnedEnabled            bool          `json:"haveibeenpwned_enabled"`
+		MaxBreaches                      uint          `json:"max_breaches"`
+		IgnoreNetworkErrors              bool          `json:"ignore_network_errors"`
+		MinPasswordLength                uint          `json:"min_password_length"`
+		IdentifierSimilarityCheckEnabled bool          `json:"identifier_similarity_check_enabled"`
+		MaxAge                           time.Duration `json:"max_age"`
+		ExpiryWarning                    time.Duration `json:"expiry_warning"`
+	}
+	WebAuthnAttestation struct {
+		MDSEnabled     bool     `json:"mds_enabled"`
+		MDSCachePath   string   `json:"mds_cache_path"`
+		AllowedAAGUIDs []string `json:"allowed_aaguids"`
+		DeniedAAGUIDs  []string `json:"denied_aaguids"`
+	}
+	EmailNormalization struct {
+		StripPlusAlias bool `json:"strip_plus_alias"`
+		StripGmailDots bool `json:"strip_gmail_dots"`
 	}
 	Schemas                  []Schema
 	CourierEmailBodyTemplate struct {
@@ -283,13 +534,37 @@ type (
 	CourierSMSTemplateBody struct {
 		PlainText string `json:"plaintext"`
 	}
+	CourierChannelLimits struct {
+		// Concurrency is the number of messages dispatched in parallel.
+		Concurrency int
+		// RateLimit is the number of messages dispatched per second. Zero disables the limit.
+		RateLimit float64
+	}
 	CourierChannel struct {
 		ID               string          `json:"id" koanf:"id"`
 		Type             string          `json:"type" koanf:"type"`
 		SMTPConfig       *SMTPConfig     `json:"smtp_config" koanf:"smtp_config"`
+		PushConfig       *PushConfig     `json:"push_config" koanf:"push_config"`
 		RequestConfig    json.RawMessage `json:"request_config" koanf:"-"`
 		RequestConfigRaw map[string]any  `json:"-" koanf:"request_config"`
 	}
+	PushConfig struct {
+		FCM  *FCMConfig  `json:"fcm" koanf:"fcm"`
+		APNs *APNsConfig `json:"apns" koanf:"apns"`
+	}
+	FCMConfig struct {
+		ProjectID          string `json:"project_id" koanf:"project_id"`
+		ServiceAccountJSON string `json:"service_account_json" koanf:"service_account_json"`
+		Endpoint           string `json:"endpoint" koanf:"endpoint"`
+	}
+	APNsConfig struct {
+		TeamID     string `json:"team_id" koanf:"team_id"`
+		KeyID      string `json:"key_id" koanf:"key_id"`
+		PrivateKey string `json:"private_key" koanf:"private_key"`
+		Topic      string `json:"topic" koanf:"topic"`
+		Sandbox    bool   `json:"sandbox" koanf:"sandbox"`
+		Endpoint   string `json:"endpoint" koanf:"endpoint"`
+	}
 	SMTPConfig struct {
 		ConnectionURI  string            `json:"connection_uri" koanf:"connection_uri"`
 		ClientCertPath string            `json:"client_cert_path" koanf:"client_cert_path"`
@@ -298,17 +573,118 @@ type (
 		FromName       string            `json:"from_name" koanf:"from_name"`
 		Headers        map[string]string `json:"headers" koanf:"headers"`
 		LocalName      string            `json:"local_name" koanf:"local_name"`
+		Pool           SMTPPoolConfig    `json:"pool" koanf:"pool"`
+
+		// Senders override the sender identity, and optionally the SMTP server, for messages
+		// of certain template types or identity schemas. The first matching sender is used.
+		Senders []SMTPSender `json:"senders" koanf:"senders"`
+	}
+	SMTPPoolConfig struct {
+		// MaxIdleConnections is the number of connections kept open per SMTP server. Zero
+		// disables pooling.
+		MaxIdleConnections int           `json:"max_idle_connections" koanf:"max_idle_connections"`
+		IdleTimeout        time.Duration `json:"idle_timeout" koanf:"idle_timeout"`
+	}
+	SMTPSender struct {
+		TemplateTypes   []string          `json:"template_types" koanf:"template_types"`
+		IdentitySchemas []string          `json:"identity_schemas" koanf:"identity_schemas"`
+		ConnectionURI   string            `json:"connection_uri" koanf:"connection_uri"`
+		FromAddress     string            `json:"from_address" koanf:"from_address"`
+		FromName        string            `json:"from_name" koanf:"from_name"`
+		Headers         map[string]string `json:"headers" koanf:"headers"`
 	}
 	PasswordMigrationHook struct {
 		Enabled bool            `json:"enabled" koanf:"enabled"`
 		Config  json.RawMessage `json:"config" koanf:"config"`
 	}
+	LoadShedding struct {
+		Enabled      bool
+		RetryAfter   time.Duration
+		LowPriority  LoadSheddingThreshold
+		HighPriority LoadSheddingThreshold
+
+		// Streams applies to long-lived streaming requests, which are counted separately.
+		Streams LoadSheddingThreshold
+	}
+	LoadSheddingThreshold struct {
+		MaxInFlight         int64
+		MaxPersisterLatency time.Duration
+	}
+	SecurityHeaders struct {
+		Enabled                   bool
+		StrictTransportSecurity   StrictTransportSecurity
+		ContentTypeOptions        string
+		FrameOptions              string
+		ReferrerPolicy            string
+		CrossOriginOpenerPolicy   string
+		CrossOriginEmbedderPolicy string
+		CrossOriginResourcePolicy string
+
+		// Routes override the headers for matching paths.
+		Routes []SecurityHeadersRoute
+	}
+	StrictTransportSecurity struct {
+		Enabled           bool
+		MaxAge            time.Duration
+		IncludeSubdomains bool
+		Preload           bool
+	}
+	SecurityHeadersRoute struct {
+		// Path is matched exactly, or as a prefix if it ends with `*`.
+		Path string `json:"path" koanf:"path"`
+
+		// Headers are set on responses for matching paths. Empty values remove the header.
+		Headers map[string]string `json:"headers" koanf:"headers"`
+	}
+	UILocalization struct {
+		Enabled  bool
+		Catalogs []UITranslationCatalog
+	}
+	UITranslationCatalog struct {
+		// Language is a BCP 47 language tag, for example `de` or `pt-BR`.
+		Language string `json:"language" koanf:"language"`
+
+		// URL points to a JSON or PO file with the translations, keyed by message ID.
+		URL string `json:"url" koanf:"url"`
+	}
+	UIMessageOverrides struct {
+		// URL points to a JSON or PO file with the overridden texts, keyed by message ID.
+		URL string `json:"url" koanf:"url"`
+
+		// Texts maps message IDs to their overridden text. They take precedence over the file.
+		Texts map[string]string `json:"texts" koanf:"texts"`
+	}
+	RegistrationStep struct {
+		// ID identifies the step in the registration flow.
+		ID string `json:"id" koanf:"id"`
+
+		// Title is shown to the user.
+		Title string `json:"title" koanf:"title"`
+
+		// Traits are the paths of the traits asked for in this step, for example `name` or
+		// `address.street`.
+		Traits []string `json:"traits" koanf:"traits"`
+	}
+	AdminAPIToken struct {
+		ID             string   `json:"id" koanf:"id"`
+		Token          string   `json:"-" koanf:"token"`
+		Scopes         []string `json:"scopes" koanf:"scopes"`
+		RedactedTraits []string `json:"redacted_traits" koanf:"redacted_traits"`
+	}
 	Config struct {
 		l                  *logrusx.Logger
-		p                  *configx.Provider
+		p                  atomic.Pointer[configx.Provider]
 		c                  contextx.Contextualizer
 		identityMetaSchema *jsonschema.Schema
 		stdOutOrErr        io.Writer
+
+		// newProvider creates a new configuration provider from the configuration sources and
+		// is used to reload the configuration. It is nil for custom configurations.
+		newProvider    func(ctx context.Context) (*configx.Provider, error)
+		providerCtx    context.Context
+		immutables     map[string]any
+		cancelProvider context.CancelFunc
+		reloadMutex    sync.Mutex
 	}
 	Provider interface {
 		Config() *Config
@@ -325,12 +701,18 @@ type (
 		CourierTemplatesVerificationCodeValid(ctx context.Context) *CourierEmailTemplate
 		CourierTemplatesLoginCodeValid(ctx context.Context) *CourierEmailTemplate
 		CourierTemplatesRegistrationCodeValid(ctx context.Context) *CourierEmailTemplate
+		CourierTemplatesLoginNotification(ctx context.Context) *CourierEmailTemplate
+		CourierTemplatesLookupSecretsLow(ctx context.Context) *CourierEmailTemplate
 		CourierSMSTemplatesVerificationCodeValid(ctx context.Context) *CourierSMSTemplate
 		CourierSMSTemplatesLoginCodeValid(ctx context.Context) *CourierSMSTemplate
 		CourierSMSTemplatesRegistrationCodeValid(ctx context.Context) *CourierSMSTemplate
 		CourierMessageRetries(ctx context.Context) int
 		CourierWorkerPullCount(ctx context.Context) int
 		CourierWorkerPullWait(ctx context.Context) time.Duration
+		CourierWorkerInstantDispatch(ctx context.Context) bool
+		CourierWorkerChannelLimits(ctx context.Context, channelID string) *CourierChannelLimits
+		CourierWorkerRetryInitialInterval(ctx context.Context) time.Duration
+		CourierWorkerRetryMaxInterval(ctx context.Context) time.Duration
 		CourierChannels(context.Context) ([]*CourierChannel, error)
 	}
 )
@@ -381,6 +763,18 @@ func (s Schemas) FindSchemaByID(id string) (*Schema, error) {
 	return nil, errors.Errorf("unable to find identity schema with id: %s", id)
 }
 
+// RegistrationMethodEnabled returns true if identities with this schema can be registered
+// using the given method.
+func (s *Schema) RegistrationMethodEnabled(method string) bool {
+	return len(s.SelfService.RegistrationMethods) == 0 || slices.Contains(s.SelfService.RegistrationMethods, method)
+}
+
+var (
+	// immutableKeys can not be changed without restarting the process.
+	immutableKeys       = []string{"serve", "profiling", "log"}
+	exceptImmutableKeys = []string{"serve.public.cors.allowed_origins"}
+)
+
 func MustNew(t testing.TB, l *logrusx.Logger, stdOutOrErr io.Writer, ctxer contextx.Contextualizer, opts ...configx.OptionModifier) *Config {
 	p, err := New(context.TODO(), l, stdOutOrErr, ctxer, opts...)
 	require.NoError(t, err)
@@ -392,9 +786,9 @@ func New(ctx context.Context, l *logrusx.Logger, stdOutOrErr io.Writer, ctxer co
 
 	opts = append([]configx.OptionModifier{
 		configx.WithStderrValidationReporter(),
-		configx.OmitKeysFromTracing("dsn", "courier.smtp.connection_uri", "secrets.default", "secrets.cookie", "secrets.cipher", "client_secret"),
-		configx.WithImmutables("serve", "profiling", "log"),
-		configx.WithExceptImmutables("serve.public.cors.allowed_origins"),
+		configx.OmitKeysFromTracing("dsn", "courier.smtp.connection_uri", "secrets.default", "secrets.cookie", "secrets.cipher", "secrets.traits", "serve.admin.api_tokens", "client_secret"),
+		configx.WithImmutables(immutableKeys...),
+		configx.WithExceptImmutables(exceptImmutableKeys...),
 		configx.WithLogrusWatcher(l),
 		configx.WithLogger(l),
 		configx.WithContext(ctx),
@@ -409,14 +803,24 @@ func New(ctx context.Context, l *logrusx.Logger, stdOutOrErr io.Writer, ctxer co
 		}),
 	}, opts...)
 
-	p, err := configx.New(ctx, []byte(embedx.ConfigSchema), opts...)
+	newProvider := func(ctx context.Context) (*configx.Provider, error) {
+		return configx.New(ctx, []byte(embedx.ConfigSchema), append(opts, configx.WithContext(ctx))...)
+	}
+
+	pctx, cancel := context.WithCancel(ctx)
+	p, err := newProvider(pctx)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	l.UseConfig(p)
 
 	c = NewCustom(l, p, stdOutOrErr, ctxer)
+	c.providerCtx = ctx
+	c.immutables = immutableValues(p)
+	c.newProvider = newProvider
+	c.cancelProvider = cancel
 
 	if !p.SkipValidation() {
 		if err := c.validateIdentitySchemas(ctx); err != nil {
@@ -429,7 +833,70 @@ func New(ctx context.Context, l *logrusx.Logger, stdOutOrErr io.Writer, ctxer co
 
 func NewCustom(l *logrusx.Logger, p *configx.Provider, stdOutOrErr io.Writer, ctxt contextx.Contextualizer) *Config {
 	l.UseConfig(p)
-	return &Config{l: l, p: p, c: ctxt, stdOutOrErr: stdOutOrErr}
+	c := &Config{l: l, c: ctxt, stdOutOrErr: stdOutOrErr}
+	c.p.Store(p)
+	return c
+}
+
+// Reload reads the configuration from its sources again and atomically replaces the current
+// configuration if the new configuration and the identity schemas it references are valid.
+// Changes to immutable keys are rejected. If an error is returned the current configuration
+// remains in use.
+//
+// Values set using Set or MustSet are discarded.
+func (p *Config) Reload(ctx context.Context) error {
+	if p.newProvider == nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The configuration was not loaded from configuration sources and can not be reloaded."))
+	}
+
+	p.reloadMutex.Lock()
+	defer p.reloadMutex.Unlock()
+
+	pctx, cancel := context.WithCancel(p.providerCtx)
+	next, err := p.newProvider(pctx)
+	if err != nil {
+		cancel()
+		return errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReasonf("The configuration is invalid and could not be loaded: %s", err))
+	}
+
+	immutables := immutableValues(next)
+	for _, key := range immutableKeys {
+		if !reflect.DeepEqual(p.immutables[key], immutables[key]) {
+			cancel()
+			err := configx.NewImmutableError(key, fmt.Sprintf("%v", p.immutables[key]), fmt.Sprintf("%v", immutables[key]))
+			return errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReasonf("The configuration could not be reloaded: %s", err))
+		}
+	}
+
+	candidate := &Config{l: p.l, c: &contextx.Default{}, identityMetaSchema: p.identityMetaSchema, stdOutOrErr: p.stdOutOrErr}
+	candidate.p.Store(next)
+	if err := candidate.validateIdentitySchemas(ctx); err != nil {
+		cancel()
+		return errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReasonf("The identity schema configuration is invalid and could not be loaded: %s", err))
+	}
+
+	p.p.Store(next)
+	p.l.UseConfig(next)
+	p.immutables = immutables
+	p.cancelProvider()
+	p.cancelProvider = cancel
+
+	return nil
+}
+
+// immutableValues returns the values of the immutable keys as loaded from the configuration sources.
+func immutableValues(c *configx.Provider) map[string]any {
+	values := make(map[string]any, len(immutableKeys))
+	for _, key := range immutableKeys {
+		k := c.Koanf.Cut(key)
+		for _, except := range exceptImmutableKeys {
+			if path, ok := strings.CutPrefix(except, key+configx.Delimiter); ok {
+				k.Delete(path)
+			}
+		}
+		values[key] = k.Raw()
+	}
+	return values
 }
 
 func (p *Config) getIdentitySchemaValidator(ctx context.Context) (*jsonschema.Schema, error) {
@@ -457,6 +924,13 @@ func SetValidateIdentitySchemaResilientClientOptions(ctx context.Context, option
 }
 
 func (p *Config) validateIdentitySchemas(ctx context.Context) error {
+	_, err := p.IdentitySchemasFingerprint(ctx)
+	return err
+}
+
+// IdentitySchemasFingerprint validates the identity schemas against the identity meta schema and
+// returns a fingerprint of their contents, which changes whenever one of the schemas changes.
+func (p *Config) IdentitySchemasFingerprint(ctx context.Context) (string, error) {
 	opts := []httpx.ResilientOptions{
 		httpx.ResilientClientWithLogger(p.l),
 		httpx.ResilientClientWithMaxRetry(2),
@@ -475,36 +949,49 @@ func (p *Config) validateIdentitySchemas(ctx context.Context) error {
 		opts = append(opts, httpx.ResilientClientDisallowInternalIPs())
 	}
 
-	ctx = context.WithValue(ctx, httploader.ContextKey, httpx.NewResilientClient(opts...))
+	client := httpx.NewResilientClient(opts...)
+	policy, err := p.ClientHTTPEgressPolicy(ctx, HTTPClientIntegrationSchemas)
+	if err != nil {
+		return "", err
+	}
+	if !policy.IsDefault() {
+		client.HTTPClient.Transport = egress.SharedTransport(policy)
+	}
+
+	ctx = context.WithValue(ctx, httploader.ContextKey, client)
 
 	j, err := p.getIdentitySchemaValidator(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	ss, err := p.IdentityTraitsSchemas(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
 
+	fingerprint := sha256.New()
 	for _, s := range ss {
 		resource, err := jsonschema.LoadURL(ctx, s.URL)
 		if err != nil {
-			return errors.WithStack(err)
+			return "", errors.WithStack(err)
 		}
 		defer resource.Close()
 
 		schema, err := io.ReadAll(io.LimitReader(resource, 1024*1024))
 		if err != nil {
-			return errors.WithStack(err)
+			return "", errors.WithStack(err)
 		}
 
 		if err = j.Validate(bytes.NewBuffer(schema)); err != nil {
 			p.formatJsonErrors(schema, err)
-			return errors.WithStack(err)
+			return "", errors.WithStack(err)
 		}
+
+		_, _ = fmt.Fprintf(fingerprint, "%s\x00%s\x00%d\x00", s.ID, s.URL, len(schema))
+		_, _ = fingerprint.Write(schema)
 	}
-	return nil
+	return hex.EncodeToString(fingerprint.Sum(nil)), nil
 }
 
 func (p *Config) formatJsonErrors(schema []byte, err error) {
@@ -526,20 +1013,20 @@ func (p *Config) CORS(ctx context.Context, iface string) (cors.Options, bool) {
 func (p *Config) cors(ctx context.Context, prefix string) (cors.Options, bool) {
 	return p.GetProvider(ctx).CORS(prefix, cors.Options{
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type", "Cookie"},
-		ExposedHeaders:   []string{"Content-Type", "Set-Cookie"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Cookie", "If-None-Match"},
+		ExposedHeaders:   []string{"Content-Type", "Set-Cookie", "ETag"},
 		AllowCredentials: true,
 	})
 }
 
 // Deprecated: use context-based WithConfigValue instead
 func (p *Config) Set(_ context.Context, key string, value interface{}) error {
-	return p.p.Set(key, value)
+	return p.p.Load().Set(key, value)
 }
 
 // Deprecated: use context-based WithConfigValue instead
 func (p *Config) MustSet(_ context.Context, key string, value interface{}) {
-	if err := p.p.Set(key, value); err != nil {
+	if err := p.p.Load().Set(key, value); err != nil {
 		p.l.WithError(err).Fatalf("Unable to set \"%s\" to \"%s\".", key, value)
 	}
 }
@@ -577,9 +1064,16 @@ func (p *Config) HasherBcrypt(ctx context.Context) *Bcrypt {
 }
 
 func (p *Config) listenOn(ctx context.Context, key string) string {
-	fb := 4433
-	if key == "admin" {
+	fb, host := 4433, ""
+	switch key {
+	case "admin":
 		fb = 4434
+	case "grpc.public":
+		fb = 4435
+	case "grpc.admin":
+		// The admin gRPC API manages identities, so it is only reachable locally unless
+		// configured otherwise.
+		fb, host = 4436, "127.0.0.1"
 	}
 
 	pp := p.GetProvider(ctx)
@@ -588,17 +1082,20 @@ func (p *Config) listenOn(ctx context.Context, key string) string {
 		p.l.Fatalf("serve.%s.port can not be zero or negative", key)
 	}
 
-	return configx.GetAddress(pp.String("serve."+key+".host"), port)
+	return configx.GetAddress(pp.StringF("serve."+key+".host", host), port)
 }
 
 func (p *Config) DefaultIdentityTraitsSchemaURL(ctx context.Context) (*url.URL, error) {
+	return p.IdentityTraitsSchemaURL(ctx, p.DefaultIdentityTraitsSchemaID(ctx))
+}
+
+func (p *Config) IdentityTraitsSchemaURL(ctx context.Context, id string) (*url.URL, error) {
 	ss, err := p.IdentityTraitsSchemas(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	search := p.GetProvider(ctx).String(ViperKeyDefaultIdentitySchemaID)
-	found, err := ss.FindSchemaByID(search)
+	found, err := ss.FindSchemaByID(id)
 	if err != nil {
 		return nil, err
 	}
@@ -610,10 +1107,71 @@ func (p *Config) DefaultIdentityTraitsSchemaID(ctx context.Context) string {
 	return p.GetProvider(ctx).String(ViperKeyDefaultIdentitySchemaID)
 }
 
+func (p *Config) IdentityDerivedTraitsMapper(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyIdentityDerivedTraitsMapper)
+}
+
+// IdentityEmailNormalization returns the rules which are applied to email addresses before they
+// are used as credential identifiers. Verifiable and recovery addresses are not affected.
+func (p *Config) IdentityEmailNormalization(ctx context.Context) *EmailNormalization {
+	return &EmailNormalization{
+		StripPlusAlias: p.GetProvider(ctx).BoolF(ViperKeyIdentityEmailNormalizationStripPlusAlias, false),
+		StripGmailDots: p.GetProvider(ctx).BoolF(ViperKeyIdentityEmailNormalizationStripGmailDots, false),
+	}
+}
+
+// IdentityPhoneDefaultRegion returns the region, an ISO 3166-1 alpha-2 code, of phone numbers which
+// are entered in national format. If it is empty, only numbers in international format are normalized.
+func (p *Config) IdentityPhoneDefaultRegion(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyIdentityPhoneNormalizationDefaultRegion)
+}
+
+// IdentityAutoArchiveAfter returns the period after which identities which did not sign in are
+// archived. Zero disables archiving.
+func (p *Config) IdentityAutoArchiveAfter(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeyIdentityLifecycleAutoArchiveAfter, 0)
+}
+
+// IdentityLoginBehavior returns what happens if an identity in the given state (other than active)
+// signs in. Locked identities are always denied.
+func (p *Config) IdentityLoginBehavior(ctx context.Context, state string) IdentityLoginBehavior {
+	if state == "locked" {
+		return IdentityLoginBehaviorDeny
+	}
+	switch b := IdentityLoginBehavior(p.GetProvider(ctx).String(ViperKeyIdentityLifecycleLoginBehavior + "." + state)); b {
+	case IdentityLoginBehaviorReactivate:
+		return b
+	}
+	return IdentityLoginBehaviorDeny
+}
+
 func (p *Config) TOTPIssuer(ctx context.Context) string {
 	return p.GetProvider(ctx).StringF(ViperKeyTOTPIssuer, p.SelfPublicURL(ctx).Hostname())
 }
 
+// LookupSecretLowCodesThreshold returns the number of unused lookup secrets at or below which
+// the identity is emailed after using one. Zero disables the email.
+func (p *Config) LookupSecretLowCodesThreshold(ctx context.Context) int {
+	return p.GetProvider(ctx).IntF(ViperKeyLookupSecretLowCodesThreshold, 0)
+}
+
+// TOTPQRCodeFormat returns the image format of TOTP QR codes, either "png" or "svg".
+func (p *Config) TOTPQRCodeFormat(ctx context.Context) string {
+	return p.GetProvider(ctx).StringF(ViperKeyTOTPQRCodeFormat, "png")
+}
+
+// TOTPQRCodeSource returns whether TOTP QR codes are embedded as a data URI ("data_uri") or
+// served by the TOTP QR code endpoint ("endpoint").
+func (p *Config) TOTPQRCodeSource(ctx context.Context) string {
+	return p.GetProvider(ctx).StringF(ViperKeyTOTPQRCodeSource, "data_uri")
+}
+
+// TOTPSecretKeyNode returns whether the TOTP secret is shown as a text node ("text") or as a
+// read-only input node which can be copied to the clipboard ("input").
+func (p *Config) TOTPSecretKeyNode(ctx context.Context) string {
+	return p.GetProvider(ctx).StringF(ViperKeyTOTPSecretKeyNode, "text")
+}
+
 func (p *Config) OIDCRedirectURIBase(ctx context.Context) *url.URL {
 	return p.GetProvider(ctx).URIF(ViperKeyOIDCBaseRedirectURL, p.SelfPublicURL(ctx))
 }
@@ -622,6 +1180,12 @@ func (p *Config) SAMLRedirectURIBase(ctx context.Context) *url.URL {
 	return p.GetProvider(ctx).URIF(ViperKeySAMLBaseRedirectURL, p.SelfPublicURL(ctx))
 }
 
+// IdentitySchemasWatchInterval returns the interval in which the identity schemas are checked
+// for changes. Watching is disabled if the interval is zero.
+func (p *Config) IdentitySchemasWatchInterval(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeyIdentitySchemasWatchInterval, 30*time.Second)
+}
+
 func (p *Config) IdentityTraitsSchemas(ctx context.Context) (ss Schemas, err error) {
 	if err = p.GetProvider(ctx).Koanf.Unmarshal(ViperKeyIdentitySchemas, &ss); err != nil {
 		return ss, nil
@@ -634,6 +1198,28 @@ func (p *Config) AdminListenOn(ctx context.Context) string {
 	return p.listenOn(ctx, "admin")
 }
 
+// GRPCServerEnabled returns true if the gRPC server should be started.
+func (p *Config) GRPCServerEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyGRPCEnabled)
+}
+
+// GRPCPublicListenOn returns the address of the public gRPC API, which serves session
+// introspection.
+func (p *Config) GRPCPublicListenOn(ctx context.Context) string {
+	return p.listenOn(ctx, "grpc.public")
+}
+
+// GRPCAdminListenOn returns the address of the admin gRPC API, which serves identity management.
+func (p *Config) GRPCAdminListenOn(ctx context.Context) string {
+	return p.listenOn(ctx, "grpc.admin")
+}
+
+// GRPCPublicReflectionEnabled returns true if the public gRPC API supports reflection. The admin
+// gRPC API always does.
+func (p *Config) GRPCPublicReflectionEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyGRPCPublicReflection)
+}
+
 func (p *Config) PublicListenOn(ctx context.Context) string {
 	return p.listenOn(ctx, "public")
 }
@@ -688,6 +1274,103 @@ func (p *Config) ClientHTTPPrivateIPExceptionURLs(ctx context.Context) []string
 	return p.GetProvider(ctx).Strings(ViperKeyClientHTTPPrivateIPExceptionURLs)
 }
 
+// The integrations whose outgoing HTTP requests can be configured in `clients.http.integrations`.
+const (
+	HTTPClientIntegrationWebhooks = "webhooks"
+	HTTPClientIntegrationOIDC     = "oidc"
+	HTTPClientIntegrationCourier  = "courier_http"
+	HTTPClientIntegrationSchemas  = "schema_fetch"
+)
+
+type httpClientIntegrationContextKey int
+
+const (
+	httpClientIntegrationKey httpClientIntegrationContextKey = iota + 1
+	httpClientNoPrivateIPRangesKey
+)
+
+// WithHTTPClientIntegration marks HTTP clients created with the returned context as being used by
+// the given integration, so that its egress settings apply.
+func WithHTTPClientIntegration(ctx context.Context, integration string) context.Context {
+	return context.WithValue(ctx, httpClientIntegrationKey, integration)
+}
+
+// HTTPClientIntegrationFromContext returns the integration set with WithHTTPClientIntegration, or
+// an empty string.
+func HTTPClientIntegrationFromContext(ctx context.Context) string {
+	integration, _ := ctx.Value(httpClientIntegrationKey).(string)
+	return integration
+}
+
+// WithHTTPClientNoPrivateIPRanges makes HTTP clients created with the returned context reject
+// requests to private IP ranges, regardless of `clients.http.disallow_private_ip_ranges`.
+func WithHTTPClientNoPrivateIPRanges(ctx context.Context) context.Context {
+	return context.WithValue(ctx, httpClientNoPrivateIPRangesKey, true)
+}
+
+// HTTPClientNoPrivateIPRangesFromContext returns true if the context was created with
+// WithHTTPClientNoPrivateIPRanges.
+func HTTPClientNoPrivateIPRangesFromContext(ctx context.Context) bool {
+	disallow, _ := ctx.Value(httpClientNoPrivateIPRangesKey).(bool)
+	return disallow
+}
+
+// ClientHTTPEgressPolicy returns the policy for outgoing HTTP requests of the integration. The
+// proxy and CIDR settings of the integration replace the global ones if they are set.
+func (p *Config) ClientHTTPEgressPolicy(ctx context.Context, integration string) (*egress.Policy, error) {
+	pp := p.GetProvider(ctx)
+	policy := &egress.Policy{
+		DisallowPrivateIPRanges: pp.Bool(ViperKeyClientHTTPNoPrivateIPRanges),
+		PrivateIPExceptionURLs:  pp.Strings(ViperKeyClientHTTPPrivateIPExceptionURLs),
+	}
+
+	key := func(global string) string {
+		if integration == "" {
+			return global
+		}
+		local := ViperKeyClientHTTPIntegrations + "." + integration + strings.TrimPrefix(global, "clients.http")
+		if pp.Exists(local) {
+			return local
+		}
+		return global
+	}
+
+	if raw := pp.String(key(ViperKeyClientHTTPProxyURL)); raw != "" {
+		proxy, err := url.Parse(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse the HTTP client proxy URL")
+		}
+		policy.ProxyURL = proxy
+	}
+
+	var err error
+	if policy.AllowedCIDRs, err = parseCIDRs(pp.Strings(key(ViperKeyClientHTTPAllowedCIDRs))); err != nil {
+		return nil, err
+	}
+	if policy.DeniedCIDRs, err = parseCIDRs(pp.Strings(key(ViperKeyClientHTTPDeniedCIDRs))); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func parseCIDRs(raw []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(raw))
+	for _, r := range raw {
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			// Single addresses are accepted as well.
+			addr, addrErr := netip.ParseAddr(r)
+			if addrErr != nil {
+				return nil, errors.Wrapf(err, "unable to parse the HTTP client CIDR %q", r)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
 func (p *Config) SelfServiceFlowRegistrationEnabled(ctx context.Context) bool {
 	return p.GetProvider(ctx).Bool(ViperKeySelfServiceRegistrationEnabled)
 }
@@ -700,6 +1383,18 @@ func (p *Config) SelfServiceFlowRegistrationTwoSteps(ctx context.Context) bool {
 	return !p.GetProvider(ctx).BoolF(ViperKeySelfServiceRegistrationEnableLegacyOneStep, false)
 }
 
+// SelfServiceFlowRegistrationSteps returns the steps the profile form of two-step registration is
+// split into. If no steps are configured, all traits are asked for at once.
+func (p *Config) SelfServiceFlowRegistrationSteps(ctx context.Context) (steps []RegistrationStep, _ error) {
+	if !p.SelfServiceFlowRegistrationTwoSteps(ctx) {
+		return nil, nil
+	}
+	if err := p.GetProvider(ctx).Koanf.Unmarshal(ViperKeySelfServiceRegistrationSteps, &steps); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return steps, nil
+}
+
 func (p *Config) SelfServiceFlowVerificationEnabled(ctx context.Context) bool {
 	return p.GetProvider(ctx).Bool(ViperKeySelfServiceVerificationEnabled)
 }
@@ -732,6 +1427,24 @@ func (p *Config) SelfServiceFlowVerificationNotifyUnknownRecipients(ctx context.
 	return p.GetProvider(ctx).BoolF(ViperKeySelfServiceVerificationNotifyUnknownRecipients, false)
 }
 
+// SelfServiceFlowVerificationRequiredForLogin returns true if logging in with the given method
+// requires the identity to have at least one verified address.
+func (p *Config) SelfServiceFlowVerificationRequiredForLogin(ctx context.Context, method string) bool {
+	return slices.Contains(p.GetProvider(ctx).Strings(ViperKeySelfServiceVerificationRequiredForLogin), method)
+}
+
+// SelfServiceFlowVerificationRequiredForOIDCLinking returns true if linking a social sign in
+// provider requires the identity to have at least one verified address.
+func (p *Config) SelfServiceFlowVerificationRequiredForOIDCLinking(ctx context.Context) bool {
+	return p.GetProvider(ctx).BoolF(ViperKeySelfServiceVerificationRequiredForOIDCLinking, false)
+}
+
+// SelfServiceFlowVerificationRequiredForRecovery returns true if recovery messages may only be
+// sent to recovery addresses which have been verified.
+func (p *Config) SelfServiceFlowVerificationRequiredForRecovery(ctx context.Context) bool {
+	return p.GetProvider(ctx).BoolF(ViperKeySelfServiceVerificationRequiredForRecovery, false)
+}
+
 func (p *Config) SelfServiceFlowSettingsBeforeHooks(ctx context.Context) []SelfServiceHook {
 	return p.selfServiceHooks(ctx, ViperKeySelfServiceSettingsBeforeHooks)
 }
@@ -871,11 +1584,54 @@ func (p *Config) SecretsSession(ctx context.Context) [][]byte {
 	return result
 }
 
+func (p *Config) SecretsBundle(ctx context.Context) [][]byte {
+	secrets := p.GetProvider(ctx).Strings(ViperKeySecretsBundle)
+	if len(secrets) == 0 {
+		return p.SecretsDefault(ctx)
+	}
+
+	result := make([][]byte, len(secrets))
+	for k, v := range secrets {
+		result[k] = []byte(v)
+	}
+
+	return result
+}
+
+// SecretsPairwise returns the secrets used to derive pairwise subject identifiers. Only the first
+// secret is used, because changing it changes all pairwise subject identifiers.
+func (p *Config) SecretsPairwise(ctx context.Context) [][]byte {
+	secrets := p.GetProvider(ctx).Strings(ViperKeySecretsPairwise)
+	if len(secrets) == 0 {
+		return p.SecretsDefault(ctx)
+	}
+
+	result := make([][]byte, len(secrets))
+	for k, v := range secrets {
+		result[k] = []byte(v)
+	}
+
+	return result
+}
+
 func (p *Config) SecretsCipher(ctx context.Context) [][32]byte {
 	secrets := p.GetProvider(ctx).Strings(ViperKeySecretsCipher)
 	return ToCipherSecrets(secrets)
 }
 
+// SecretsTraits returns the secrets used to encrypt the identity traits which are annotated
+// with `"encrypt": true` in the identity schema.
+func (p *Config) SecretsTraits(ctx context.Context) [][32]byte {
+	secrets := p.GetProvider(ctx).Strings(ViperKeySecretsTraits)
+	return ToCipherSecrets(secrets)
+}
+
+// SecretBackendsRefreshInterval returns how long secrets fetched from external secret backends
+// are cached before they are fetched again.
+func (p *Config) SecretBackendsRefreshInterval(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySecretBackendsRefreshInterval, 5*time.Minute)
+}
+
 func ToCipherSecrets(secrets []string) [][32]byte {
 	var cleanSecrets []string
 	for k := range secrets {
@@ -895,6 +1651,67 @@ func ToCipherSecrets(secrets []string) [][32]byte {
 	return result
 }
 
+// SelfServiceFunnelTrackingEnabled returns whether the progress of the individual self-service
+// flows is stored for the flow funnel API.
+func (p *Config) SelfServiceFunnelTrackingEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeySelfServiceFunnelTrackingEnabled)
+}
+
+func (p *Config) SelfServiceMFAEnrollmentCampaign(ctx context.Context) (*MFAEnrollmentCampaign, error) {
+	pp := p.GetProvider(ctx)
+	prefix := ViperKeySelfServiceMFAEnrollmentCampaign + "."
+	c := &MFAEnrollmentCampaign{
+		Enabled:            pp.Bool(prefix + "enabled"),
+		RedirectAfterLogin: pp.Bool(prefix + "redirect_after_login"),
+		RolloutPercentage:  pp.IntF(prefix+"rollout_percentage", 100),
+	}
+	if deadline := pp.String(prefix + "deadline"); deadline != "" {
+		t, err := time.Parse(time.RFC3339, deadline)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		c.Deadline = t
+	}
+	return c, nil
+}
+
+// SelfServiceDisposableEmails returns the configuration for rejecting email addresses of
+// disposable email providers.
+func (p *Config) SelfServiceDisposableEmails(ctx context.Context) *DisposableEmails {
+	pp := p.GetProvider(ctx)
+	return &DisposableEmails{
+		Enabled:         pp.Bool(ViperKeySelfServiceDisposableEmailsEnabled),
+		BlocklistURL:    pp.String(ViperKeySelfServiceDisposableEmailsBlocklistURL),
+		RefreshInterval: pp.DurationF(ViperKeySelfServiceDisposableEmailsRefreshInterval, 24*time.Hour),
+	}
+}
+
+// SelfServiceBrands returns the brands which can be selected when creating a self-service flow.
+func (p *Config) SelfServiceBrands(ctx context.Context) []string {
+	return p.GetProvider(ctx).Strings(ViperKeySelfServiceBrands)
+}
+
+// SelfServiceLocalization returns the translation catalogs used to localize the texts of UI
+// messages and nodes.
+func (p *Config) SelfServiceLocalization(ctx context.Context) (*UILocalization, error) {
+	pp := p.GetProvider(ctx)
+	l := &UILocalization{Enabled: pp.Bool(ViperKeySelfServiceLocalization + ".enabled")}
+	if err := pp.Koanf.Unmarshal(ViperKeySelfServiceLocalization+".catalogs", &l.Catalogs); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return l, nil
+}
+
+// SelfServiceMessageOverrides returns the texts which replace the default (English) texts of
+// UI messages and nodes.
+func (p *Config) SelfServiceMessageOverrides(ctx context.Context) (*UIMessageOverrides, error) {
+	var o UIMessageOverrides
+	if err := p.GetProvider(ctx).Koanf.Unmarshal(ViperKeySelfServiceMessageOverrides, &o); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &o, nil
+}
+
 func (p *Config) SelfServiceBrowserDefaultReturnTo(ctx context.Context) *url.URL {
 	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySelfServiceBrowserDefaultReturnTo)
 }
@@ -943,6 +1760,94 @@ func (p *Config) DisablePublicHealthRequestLog(ctx context.Context) bool {
 	return p.GetProvider(ctx).Bool(ViperKeyDisablePublicHealthRequestLog)
 }
 
+const (
+	// AdminAPITokenScopeCredentialsRead allows an admin API token to read the credentials configuration of identities.
+	AdminAPITokenScopeCredentialsRead = "credentials:read"
+
+	// AdminAPITokenScopeMetadataAdminRead allows an admin API token to read the admin metadata of identities.
+	AdminAPITokenScopeMetadataAdminRead = "metadata_admin:read"
+
+	// AdminAPITokenScopeUpstreamTokensRead allows an admin API token to read the access tokens of the
+	// upstream OpenID Connect providers identities signed in with.
+	AdminAPITokenScopeUpstreamTokensRead = "upstream_tokens:read"
+
+	// AdminAPITokenScopeConfigSecretsRead allows an admin API token to export configuration bundles
+	// including secrets.
+	AdminAPITokenScopeConfigSecretsRead = "config_secrets:read"
+)
+
+// AdminAPITokenScopes returns all scopes an admin API token can be granted.
+func AdminAPITokenScopes() []string {
+	return []string{
+		AdminAPITokenScopeCredentialsRead,
+		AdminAPITokenScopeMetadataAdminRead,
+		AdminAPITokenScopeUpstreamTokensRead,
+		AdminAPITokenScopeConfigSecretsRead,
+	}
+}
+
+func (t *AdminAPIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// AdminAPITokens returns the tokens which are accepted by the admin API. If no tokens are
+// configured, the admin API does not require a token.
+func (p *Config) AdminAPITokens(ctx context.Context) (tokens []AdminAPIToken, _ error) {
+	if err := p.GetProvider(ctx).Koanf.Unmarshal(ViperKeyAdminAPITokens, &tokens); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return tokens, nil
+}
+
+func (p *Config) PublicLoadShedding(ctx context.Context) *LoadShedding {
+	pp := p.GetProvider(ctx)
+	return &LoadShedding{
+		Enabled:    pp.Bool(ViperKeyPublicLoadSheddingEnabled),
+		RetryAfter: pp.DurationF(ViperKeyPublicLoadSheddingRetryAfter, 5*time.Second),
+		LowPriority: LoadSheddingThreshold{
+			MaxInFlight:         int64(pp.IntF(ViperKeyPublicLoadSheddingLowInFlight, 250)),
+			MaxPersisterLatency: pp.DurationF(ViperKeyPublicLoadSheddingLowLatency, 500*time.Millisecond),
+		},
+		HighPriority: LoadSheddingThreshold{
+			MaxInFlight:         int64(pp.IntF(ViperKeyPublicLoadSheddingHighInFlight, 1000)),
+			MaxPersisterLatency: pp.DurationF(ViperKeyPublicLoadSheddingHighLatency, 2*time.Second),
+		},
+		Streams: LoadSheddingThreshold{
+			MaxInFlight:         int64(pp.IntF(ViperKeyPublicLoadSheddingStreamsInFlight, 10000)),
+			MaxPersisterLatency: pp.DurationF(ViperKeyPublicLoadSheddingStreamsLatency, 2*time.Second),
+		},
+	}
+}
+
+// SecurityHeaders returns the security headers set on responses of the public or admin endpoint.
+func (p *Config) SecurityHeaders(ctx context.Context, iface string) (*SecurityHeaders, error) {
+	if iface != "public" && iface != "admin" {
+		panic(fmt.Sprintf("Received unexpected security headers interface: %s", iface))
+	}
+
+	pp := p.GetProvider(ctx)
+	prefix := "serve." + iface + ".security_headers."
+	h := &SecurityHeaders{
+		Enabled: pp.BoolF(prefix+"enabled", true),
+		StrictTransportSecurity: StrictTransportSecurity{
+			Enabled:           pp.BoolF(prefix+"strict_transport_security.enabled", true),
+			MaxAge:            pp.DurationF(prefix+"strict_transport_security.max_age", 365*24*time.Hour),
+			IncludeSubdomains: pp.Bool(prefix + "strict_transport_security.include_subdomains"),
+			Preload:           pp.Bool(prefix + "strict_transport_security.preload"),
+		},
+		ContentTypeOptions:        pp.StringF(prefix+"content_type_options", "nosniff"),
+		FrameOptions:              pp.StringF(prefix+"frame_options", "DENY"),
+		ReferrerPolicy:            pp.StringF(prefix+"referrer_policy", "strict-origin-when-cross-origin"),
+		CrossOriginOpenerPolicy:   pp.String(prefix + "cross_origin_opener_policy"),
+		CrossOriginEmbedderPolicy: pp.String(prefix + "cross_origin_embedder_policy"),
+		CrossOriginResourcePolicy: pp.String(prefix + "cross_origin_resource_policy"),
+	}
+	if err := pp.Koanf.Unmarshal(prefix+"routes", &h.Routes); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return h, nil
+}
+
 func (p *Config) SelfPublicURL(ctx context.Context) *url.URL {
 	return p.baseURL(ctx, ViperKeyPublicBaseURL, ViperKeyPublicHost, ViperKeyPublicPort, 4433)
 }
@@ -994,24 +1899,58 @@ func (p *Config) OAuth2ProviderURL(ctx context.Context) *url.URL {
 	return parsed
 }
 
+// HostedUIPath is the path prefix of the pages of the hosted UI on the public endpoint.
+const HostedUIPath = "/ui"
+
+// SelfServiceHostedUIEnabled returns true if Ory Kratos renders the self-service UI itself. The
+// hosted UI replaces all configured UI URLs.
+func (p *Config) SelfServiceHostedUIEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeySelfServiceHostedUIEnabled)
+}
+
+// SelfServiceHostedUITheme returns the CSS variables of the hosted UI, keyed by their name
+// without the leading dashes.
+func (p *Config) SelfServiceHostedUITheme(ctx context.Context) map[string]string {
+	return p.GetProvider(ctx).StringMap(ViperKeySelfServiceHostedUITheme)
+}
+
+// SelfServiceDebugTraceEnabled returns true if requests may opt into a debug trace of the
+// self-service strategies and hooks.
+func (p *Config) SelfServiceDebugTraceEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeySelfServiceDebugTraceEnabled)
+}
+
+// SelfServiceDebugTraceResponse returns true if debug traces are returned in a response header
+// in addition to being logged.
+func (p *Config) SelfServiceDebugTraceResponse(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeySelfServiceDebugTraceResponse)
+}
+
+func (p *Config) selfServiceUI(ctx context.Context, key, page string) *url.URL {
+	if p.SelfServiceHostedUIEnabled(ctx) {
+		return urlx.AppendPaths(p.SelfPublicURL(ctx), HostedUIPath, page)
+	}
+	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, key)
+}
+
 func (p *Config) SelfServiceFlowLoginUI(ctx context.Context) *url.URL {
-	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySelfServiceLoginUI)
+	return p.selfServiceUI(ctx, ViperKeySelfServiceLoginUI, "login")
 }
 
 func (p *Config) SelfServiceFlowSettingsUI(ctx context.Context) *url.URL {
-	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySelfServiceSettingsURL)
+	return p.selfServiceUI(ctx, ViperKeySelfServiceSettingsURL, "settings")
 }
 
 func (p *Config) SelfServiceFlowErrorURL(ctx context.Context) *url.URL {
-	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySelfServiceErrorUI)
+	return p.selfServiceUI(ctx, ViperKeySelfServiceErrorUI, "error")
 }
 
 func (p *Config) SelfServiceFlowRegistrationUI(ctx context.Context) *url.URL {
-	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySelfServiceRegistrationUI)
+	return p.selfServiceUI(ctx, ViperKeySelfServiceRegistrationUI, "registration")
 }
 
 func (p *Config) SelfServiceFlowRecoveryUI(ctx context.Context) *url.URL {
-	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySelfServiceRecoveryUI)
+	return p.selfServiceUI(ctx, ViperKeySelfServiceRecoveryUI, "recovery")
 }
 
 // SessionLifespan returns time.Hour*24 when the value is not set.
@@ -1023,6 +1962,39 @@ func (p *Config) SessionPersistentCookie(ctx context.Context) bool {
 	return p.GetProvider(ctx).Bool(ViperKeySessionPersistentCookie)
 }
 
+// SessionMetadataMaxSize returns the maximum size of the encoded session metadata in bytes.
+func (p *Config) SessionMetadataMaxSize(ctx context.Context) int {
+	return p.GetProvider(ctx).IntF(ViperKeySessionMetadataMaxSize, 4096)
+}
+
+// SessionMetadataSchema returns the URL of the JSON Schema the session metadata is validated
+// against, or an empty string if the metadata is not validated.
+func (p *Config) SessionMetadataSchema(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeySessionMetadataSchema)
+}
+
+// SessionIntrospectMaxItems returns the maximum number of sessions which one call of the batch
+// session introspection endpoint may look up.
+func (p *Config) SessionIntrospectMaxItems(ctx context.Context) int {
+	return p.GetProvider(ctx).IntF(ViperKeySessionIntrospectMaxItems, 100)
+}
+
+// SessionConcurrencyMaxSessions returns the maximum number of active sessions per identity, or 0
+// if the number is not limited.
+func (p *Config) SessionConcurrencyMaxSessions(ctx context.Context) int {
+	return p.GetProvider(ctx).IntF(ViperKeySessionConcurrencyMaxSessions, 0)
+}
+
+// SessionConcurrencyPolicy returns what happens when an identity exceeds the maximum number of
+// active sessions.
+func (p *Config) SessionConcurrencyPolicy(ctx context.Context) SessionConcurrencyPolicy {
+	switch policy := SessionConcurrencyPolicy(p.GetProvider(ctx).String(ViperKeySessionConcurrencyPolicy)); policy {
+	case SessionConcurrencyPolicyReject, SessionConcurrencyPolicyEvictLeastRecentlyUsed:
+		return policy
+	}
+	return SessionConcurrencyPolicyEvictOldest
+}
+
 func (p *Config) SelfServiceBrowserAllowedReturnToDomains(ctx context.Context) (us []url.URL) {
 	src := p.GetProvider(ctx).Strings(ViperKeyURLsAllowedReturnToDomains)
 	for k, u := range src {
@@ -1054,10 +2026,53 @@ func (p *Config) SelfServiceBrowserAllowedReturnToDomains(ctx context.Context) (
 	return us
 }
 
+// ReturnToGrants configures signed grants which allow return_to URLs in addition to the static
+// allow list. A grant is a JSON Web Token signed with a key of the JSON Web Key Set.
+type ReturnToGrants struct {
+	JWKSURL string `koanf:"jwks_url" json:"jwks_url"`
+	Issuer  string `koanf:"issuer" json:"issuer"`
+}
+
+// SelfServiceBrowserReturnToGrants returns the configuration of signed return_to grants, or nil
+// if they are not enabled.
+func (p *Config) SelfServiceBrowserReturnToGrants(ctx context.Context) (*ReturnToGrants, error) {
+	if p.GetProvider(ctx).String(ViperKeyURLsReturnToGrants+".jwks_url") == "" {
+		return nil, nil
+	}
+
+	var result ReturnToGrants
+	if err := p.GetProvider(ctx).Unmarshal(ViperKeyURLsReturnToGrants, &result); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode return_to grants configuration \"%s\": %s", ViperKeyURLsReturnToGrants, err))
+	}
+
+	return &result, nil
+}
+
 func (p *Config) SelfServiceFlowLoginRequestLifespan(ctx context.Context) time.Duration {
 	return p.GetProvider(ctx).DurationF(ViperKeySelfServiceLoginRequestLifespan, time.Hour)
 }
 
+// SelfServiceFlowLoginFirstFactorPolicy returns the first factor composition policy for the given
+// identity schema, or nil if no policy applies.
+func (p *Config) SelfServiceFlowLoginFirstFactorPolicy(ctx context.Context, schemaID string) (*LoginFirstFactorPolicy, error) {
+	var policies []LoginFirstFactorPolicy
+	if err := p.GetProvider(ctx).Koanf.Unmarshal(ViperKeySelfServiceLoginFirstFactorPolicies, &policies); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for k := range policies {
+		if policies[k].IdentitySchema != schemaID {
+			continue
+		}
+		if policies[k].Minimum <= 0 || policies[k].Minimum > len(policies[k].Methods) {
+			policies[k].Minimum = len(policies[k].Methods)
+		}
+		return &policies[k], nil
+	}
+
+	return nil, nil
+}
+
 func (p *Config) SelfServiceFlowSettingsFlowLifespan(ctx context.Context) time.Duration {
 	return p.GetProvider(ctx).DurationF(ViperKeySelfServiceSettingsRequestLifespan, time.Hour)
 }
@@ -1070,6 +2085,22 @@ func (p *Config) SelfServiceFlowLogoutRedirectURL(ctx context.Context) *url.URL
 	return p.GetProvider(ctx).RequestURIF(ViperKeySelfServiceLogoutBrowserDefaultReturnTo, p.SelfServiceBrowserDefaultReturnTo(ctx))
 }
 
+func (p *Config) SelfServiceFlowCrossDeviceLoginEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeySelfServiceCrossDeviceLoginEnabled)
+}
+
+func (p *Config) SelfServiceFlowCrossDeviceLoginUI(ctx context.Context) *url.URL {
+	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySelfServiceCrossDeviceLoginUI)
+}
+
+func (p *Config) SelfServiceFlowCrossDeviceLoginLifespan(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySelfServiceCrossDeviceLoginLifespan, 10*time.Minute)
+}
+
+func (p *Config) SelfServiceFlowCrossDeviceLoginPollInterval(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySelfServiceCrossDeviceLoginPollInterval, 5*time.Second)
+}
+
 func (p *Config) CourierEmailStrategy(ctx context.Context) string {
 	return p.GetProvider(ctx).StringF(ViperKeyCourierDeliveryStrategy, "smtp")
 }
@@ -1194,10 +2225,56 @@ func (p *Config) CourierTemplatesRegistrationCodeValid(ctx context.Context) *Cou
 	return p.CourierEmailTemplatesHelper(ctx, ViperKeyCourierTemplatesRegistrationCodeValidEmail)
 }
 
+func (p *Config) CourierTemplatesLoginNotification(ctx context.Context) *CourierEmailTemplate {
+	return p.CourierEmailTemplatesHelper(ctx, ViperKeyCourierTemplatesLoginNotificationEmail)
+}
+
+func (p *Config) CourierTemplatesLookupSecretsLow(ctx context.Context) *CourierEmailTemplate {
+	return p.CourierEmailTemplatesHelper(ctx, ViperKeyCourierTemplatesLookupSecretsLowEmail)
+}
+
 func (p *Config) CourierMessageRetries(ctx context.Context) int {
 	return p.GetProvider(ctx).IntF(ViperKeyCourierMessageRetries, 5)
 }
 
+func (p *Config) CourierDeliveryReceiptsEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyCourierDeliveryReceiptsEnabled)
+}
+
+func (p *Config) CourierDeliveryReceiptsToken(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyCourierDeliveryReceiptsToken)
+}
+
+// CourierDeliveryReceiptsSuppressUndeliverable returns whether addresses which permanently bounced or
+// complained are marked as undeliverable.
+func (p *Config) CourierDeliveryReceiptsSuppressUndeliverable(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyCourierDeliveryReceiptsSuppressUndeliverable)
+}
+
+// CourierDeliveryReceiptsSESTopicARNs returns the Amazon SNS topics which may send SES delivery
+// receipts. If empty, SES delivery receipts are authenticated using the token.
+func (p *Config) CourierDeliveryReceiptsSESTopicARNs(ctx context.Context) []string {
+	return p.GetProvider(ctx).Strings(ViperKeyCourierDeliveryReceiptsSESTopicARNs)
+}
+
+// CourierDeliveryReceiptsSendGridVerificationKey returns the public key which verifies the
+// signature of SendGrid event webhooks.
+func (p *Config) CourierDeliveryReceiptsSendGridVerificationKey(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyCourierDeliveryReceiptsSendGridVerificationKey)
+}
+
+// CourierDeliveryReceiptsMailgunSigningKey returns the key which verifies the signature of
+// Mailgun webhooks.
+func (p *Config) CourierDeliveryReceiptsMailgunSigningKey(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyCourierDeliveryReceiptsMailgunSigningKey)
+}
+
+// CourierDeliveryReceiptsTwilioAuthToken returns the auth token which verifies the signature of
+// Twilio status callbacks.
+func (p *Config) CourierDeliveryReceiptsTwilioAuthToken(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyCourierDeliveryReceiptsTwilioAuthToken)
+}
+
 func (p *Config) CourierWorkerPullCount(ctx context.Context) int {
 	return p.GetProvider(ctx).Int(ViperKeyCourierWorkerPullCount)
 }
@@ -1206,6 +2283,30 @@ func (p *Config) CourierWorkerPullWait(ctx context.Context) time.Duration {
 	return p.GetProvider(ctx).Duration(ViperKeyCourierWorkerPullWait)
 }
 
+// CourierWorkerInstantDispatch returns whether couriers are notified of queued messages instead
+// of only pulling the queue every `courier.worker.pull_wait`.
+func (p *Config) CourierWorkerInstantDispatch(ctx context.Context) bool {
+	return p.GetProvider(ctx).BoolF(ViperKeyCourierWorkerInstantDispatch, true)
+}
+
+// CourierWorkerChannelLimits returns how many messages of the channel are dispatched in parallel
+// and per second.
+func (p *Config) CourierWorkerChannelLimits(ctx context.Context, channelID string) *CourierChannelLimits {
+	key := ViperKeyCourierWorkerChannels + "." + channelID
+	return &CourierChannelLimits{
+		Concurrency: max(p.GetProvider(ctx).IntF(key+".concurrency", 1), 1),
+		RateLimit:   max(p.GetProvider(ctx).Float64F(key+".rate_limit", 0), 0),
+	}
+}
+
+func (p *Config) CourierWorkerRetryInitialInterval(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeyCourierWorkerRetryInitialInterval, time.Second)
+}
+
+func (p *Config) CourierWorkerRetryMaxInterval(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeyCourierWorkerRetryMaxInterval, 5*time.Minute)
+}
+
 func (p *Config) CourierSMTPHeaders(ctx context.Context) map[string]string {
 	return p.GetProvider(ctx).StringMap(ViperKeyCourierSMTPHeaders)
 }
@@ -1306,7 +2407,7 @@ func (p *Config) IsInsecureDevMode(ctx context.Context) bool {
 }
 
 func (p *Config) IsBackgroundCourierEnabled(ctx context.Context) bool {
-	return p.GetProvider(ctx).Bool("watch-courier")
+	return p.GetProvider(ctx).Bool("watch-courier") || p.DatabaseSQLiteEmbedded(ctx)
 }
 
 func (p *Config) CourierExposeMetricsPort(ctx context.Context) int {
@@ -1318,7 +2419,7 @@ func (p *Config) MetricsListenOn(ctx context.Context) string {
 }
 
 func (p *Config) SelfServiceFlowVerificationUI(ctx context.Context) *url.URL {
-	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySelfServiceVerificationUI)
+	return p.selfServiceUI(ctx, ViperKeySelfServiceVerificationUI, "verification")
 }
 
 func (p *Config) SelfServiceFlowVerificationRequestLifespan(ctx context.Context) time.Duration {
@@ -1345,6 +2446,43 @@ func (p *Config) SelfServiceFlowRecoveryNotifyUnknownRecipients(ctx context.Cont
 	return p.GetProvider(ctx).BoolF(ViperKeySelfServiceRecoveryNotifyUnknownRecipients, false)
 }
 
+// NativeDeepLink configures the app deep link or universal link which native apps are asked to
+// open once a flow was completed. The link carries a payload signed with a key of the JSON Web
+// Key Set, so that the app can trust it.
+type NativeDeepLink struct {
+	URL     string        `koanf:"url" json:"url"`
+	JWKSURL string        `koanf:"jwks_url" json:"jwks_url"`
+	TTL     time.Duration `koanf:"ttl" json:"ttl"`
+}
+
+// SelfServiceFlowVerificationNativeDeepLink returns the deep link opened by native apps after
+// verification, or nil if none is configured.
+func (p *Config) SelfServiceFlowVerificationNativeDeepLink(ctx context.Context) (*NativeDeepLink, error) {
+	return p.nativeDeepLink(ctx, ViperKeySelfServiceVerificationNativeDeepLink)
+}
+
+// SelfServiceFlowRecoveryNativeDeepLink returns the deep link opened by native apps after
+// recovery, or nil if none is configured.
+func (p *Config) SelfServiceFlowRecoveryNativeDeepLink(ctx context.Context) (*NativeDeepLink, error) {
+	return p.nativeDeepLink(ctx, ViperKeySelfServiceRecoveryNativeDeepLink)
+}
+
+func (p *Config) nativeDeepLink(ctx context.Context, key string) (*NativeDeepLink, error) {
+	if p.GetProvider(ctx).String(key+".url") == "" {
+		return nil, nil
+	}
+
+	var result NativeDeepLink
+	if err := p.GetProvider(ctx).Unmarshal(key, &result); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode native deep link configuration \"%s\": %s", key, err))
+	}
+	if result.TTL == 0 {
+		result.TTL = 10 * time.Minute
+	}
+
+	return &result, nil
+}
+
 func (p *Config) SelfServiceLinkMethodLifespan(ctx context.Context) time.Duration {
 	return p.GetProvider(ctx).DurationF(ViperKeyLinkLifespan, time.Hour)
 }
@@ -1361,14 +2499,143 @@ func (p *Config) SelfServiceCodeMethodMissingCredentialFallbackEnabled(ctx conte
 	return p.GetProvider(ctx).Bool(ViperKeyCodeConfigMissingCredentialFallbackEnabled)
 }
 
+func (p *Config) SelfServiceCodeMethodPushApprovalEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyCodeConfigPushApprovalEnabled)
+}
+
 func (p *Config) DatabaseCleanupSleepTables(ctx context.Context) time.Duration {
 	return p.GetProvider(ctx).Duration(ViperKeyDatabaseCleanupSleepTables)
 }
 
+func (p *Config) DatabaseCleanupSleepBatches(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).Duration(ViperKeyDatabaseCleanupSleepBatches)
+}
+
 func (p *Config) DatabaseCleanupBatchSize(ctx context.Context) int {
 	return p.GetProvider(ctx).Int(ViperKeyDatabaseCleanupBatchSize)
 }
 
+// DatabaseCleanupRetention returns how long expired rows of the table are kept before they are
+// cleaned up.
+func (p *Config) DatabaseCleanupRetention(ctx context.Context, table string) time.Duration {
+	return p.GetProvider(ctx).Duration(ViperKeyDatabaseCleanupRetention + "." + table)
+}
+
+// DatabaseSQLiteJournalMode returns the configured journal mode, or an empty string if the
+// journal mode of the database file is kept.
+func (p *Config) DatabaseSQLiteJournalMode(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyDatabaseSQLiteJournalMode)
+}
+
+func (p *Config) DatabaseSQLiteSynchronous(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyDatabaseSQLiteSynchronous)
+}
+
+// DatabaseSQLiteBusyTimeout returns the configured busy timeout, or zero if the default of the
+// driver applies.
+func (p *Config) DatabaseSQLiteBusyTimeout(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).Duration(ViperKeyDatabaseSQLiteBusyTimeout)
+}
+
+func (p *Config) DatabaseSQLiteTransactionLock(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeyDatabaseSQLiteTransactionLock)
+}
+
+func (p *Config) DatabaseSQLiteCheckpointMode(ctx context.Context) string {
+	return p.GetProvider(ctx).StringF(ViperKeyDatabaseSQLiteCheckpointMode, "passive")
+}
+
+// DatabaseSQLiteEmbedded returns true if a single instance runs on top of an SQLite database
+// with the courier and the jobs in-process.
+func (p *Config) DatabaseSQLiteEmbedded(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyDatabaseSQLiteEmbedded) && dbal.IsSQLite(p.DSN(ctx))
+}
+
+// HealthReadinessTimeout returns how long each readiness check may take.
+func (p *Config) HealthReadinessTimeout(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeyHealthReadinessTimeout, 5*time.Second)
+}
+
+func (p *Config) HealthReadinessDatabaseEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).BoolF(ViperKeyHealthReadinessDatabaseEnabled, true)
+}
+
+// HealthReadinessDatabaseMaxReplicationLag returns the replication lag above which the instance
+// is not ready, or zero if the replication lag is not checked.
+func (p *Config) HealthReadinessDatabaseMaxReplicationLag(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).Duration(ViperKeyHealthReadinessDatabaseMaxReplicationLag)
+}
+
+func (p *Config) HealthReadinessSMTPEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyHealthReadinessSMTPEnabled)
+}
+
+func (p *Config) HealthReadinessCourierBacklogEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyHealthReadinessCourierBacklogEnabled)
+}
+
+// HealthReadinessCourierBacklogMaxMessages returns the number of queued courier messages above
+// which the instance is not ready.
+func (p *Config) HealthReadinessCourierBacklogMaxMessages(ctx context.Context) int64 {
+	return int64(p.GetProvider(ctx).IntF(ViperKeyHealthReadinessCourierBacklogMaxMessages, 1000))
+}
+
+func (p *Config) HealthReadinessIdentitySchemasEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyHealthReadinessIdentitySchemasEnabled)
+}
+
+func (p *Config) JobsEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyJobsEnabled) || p.DatabaseSQLiteEmbedded(ctx)
+}
+
+// JobsLeaseDuration returns how long the leader lease of the job scheduler is valid unless it is
+// renewed.
+func (p *Config) JobsLeaseDuration(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeyJobsLeaseDuration, 30*time.Second)
+}
+
+// JobSchedule returns the configured schedule of the job, or an empty string if the job runs
+// on its default schedule.
+func (p *Config) JobSchedule(ctx context.Context, name string) string {
+	return p.GetProvider(ctx).String(ViperKeyJobsSchedules + "." + name)
+}
+
+func (p *Config) JobsDisabled(ctx context.Context) []string {
+	return p.GetProvider(ctx).Strings(ViperKeyJobsDisabled)
+}
+
+// DatabaseFlowStorageRedisURL returns the URL of the Redis server which stores flows, or nil if
+// flows are stored in SQL.
+func (p *Config) DatabaseFlowStorageRedisURL(ctx context.Context) *url.URL {
+	return p.GetProvider(ctx).RequestURIF(ViperKeyDatabaseFlowStorageRedisURL, nil)
+}
+
+// LogAuditSinkURL returns the URL of the syslog server or HTTP collector which receives audit
+// log events, or nil if audit events are only written to the log.
+func (p *Config) LogAuditSinkURL(ctx context.Context) *url.URL {
+	return p.GetProvider(ctx).RequestURIF(ViperKeyLogAuditSinkURL, nil)
+}
+
+func (p *Config) LogAuditSinkFormat(ctx context.Context) string {
+	return p.GetProvider(ctx).StringF(ViperKeyLogAuditSinkFormat, "json")
+}
+
+func (p *Config) LogAuditSinkHeaders(ctx context.Context) map[string]string {
+	return p.GetProvider(ctx).StringMap(ViperKeyLogAuditSinkHeaders)
+}
+
+func (p *Config) LogAuditSinkBufferSize(ctx context.Context) int {
+	return p.GetProvider(ctx).IntF(ViperKeyLogAuditSinkBufferSize, 1024)
+}
+
+func (p *Config) LogAuditSinkMaxRetries(ctx context.Context) int {
+	return p.GetProvider(ctx).IntF(ViperKeyLogAuditSinkMaxRetries, 3)
+}
+
+func (p *Config) LogAuditSinkBlockTimeout(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeyLogAuditSinkBlockTimeout, 100*time.Millisecond)
+}
+
 func (p *Config) SelfServiceFlowRecoveryAfterHooks(ctx context.Context, strategy string) []SelfServiceHook {
 	return p.selfServiceHooks(ctx, HookStrategyKey(ViperKeySelfServiceRecoveryAfter, strategy))
 }
@@ -1377,6 +2644,22 @@ func (p *Config) SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx context.Cont
 	return p.GetProvider(ctx).DurationF(ViperKeySelfServiceSettingsPrivilegedAuthenticationAfter, time.Hour)
 }
 
+// SelfServiceFlowSettingsPrivilegedSessionMaxAgeForGroup returns the privileged session max age of the
+// given node group, falling back to the global one.
+func (p *Config) SelfServiceFlowSettingsPrivilegedSessionMaxAgeForGroup(ctx context.Context, group string) time.Duration {
+	key := ViperKeySelfServiceSettingsGroups + "." + group + ".privileged_session_max_age"
+	if p.GetProvider(ctx).String(key) == "" {
+		return p.SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)
+	}
+	return p.GetProvider(ctx).DurationF(key, time.Hour)
+}
+
+// SelfServiceSettingsRequiredAALForGroup returns the AAL required to update the settings of the given
+// node group, or an empty string if the group has no requirement.
+func (p *Config) SelfServiceSettingsRequiredAALForGroup(ctx context.Context, group string) string {
+	return p.GetProvider(ctx).String(ViperKeySelfServiceSettingsGroups + "." + group + ".required_aal")
+}
+
 func (p *Config) SessionSameSiteMode(ctx context.Context) http.SameSite {
 	if !p.GetProvider(ctx).Exists(ViperKeySessionSameSite) {
 		return p.CookieSameSiteMode(ctx)
@@ -1431,14 +2714,88 @@ func (p *Config) UseContinueWithTransitions(ctx context.Context) bool {
 	return p.GetProvider(ctx).Bool(ViperKeyUseContinueWithTransitions)
 }
 
+func (p *Config) UseErrorEnvelope(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeyFeatureFlagErrorEnvelope)
+}
+
 func (p *Config) SessionRefreshMinTimeLeft(ctx context.Context) time.Duration {
 	return p.GetProvider(ctx).DurationF(ViperKeySessionRefreshMinTimeLeft, p.SessionLifespan(ctx))
 }
 
+// SessionIdleTimeout returns after which time without use a session becomes invalid, or 0 if
+// sessions do not expire when idle.
+func (p *Config) SessionIdleTimeout(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySessionIdleTimeout, 0)
+}
+
+// SessionActivityUpdateInterval returns how often the last use of a session is written to the
+// database. Uses within the interval are not recorded to prevent a write per request.
+func (p *Config) SessionActivityUpdateInterval(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySessionActivityUpdateInterval, time.Minute)
+}
+
+// SessionRememberMeEnabled returns true if users choose in the login flow whether the session is
+// remembered.
+func (p *Config) SessionRememberMeEnabled(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeySessionRememberMeEnabled)
+}
+
+// SessionRememberMeShortLifespan returns the lifespan of sessions which are not remembered.
+func (p *Config) SessionRememberMeShortLifespan(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySessionRememberMeShortLifespan, time.Hour)
+}
+
+// SessionRememberMeDefault returns whether API flows completed with the given method which do not
+// submit a choice are remembered.
+func (p *Config) SessionRememberMeDefault(ctx context.Context, method string) bool {
+	return p.GetProvider(ctx).Bool(ViperKeySessionRememberMeMethods + "." + method)
+}
+
+// SessionGeoIPCityDatabase returns the location of the MaxMind city or country database used to
+// resolve the location of session devices, or an empty string if none is configured.
+func (p *Config) SessionGeoIPCityDatabase(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeySessionGeoIPCityDatabase)
+}
+
+// SessionGeoIPASNDatabase returns the location of the MaxMind ASN database used to resolve the
+// network of session devices, or an empty string if none is configured.
+func (p *Config) SessionGeoIPASNDatabase(ctx context.Context) string {
+	return p.GetProvider(ctx).String(ViperKeySessionGeoIPASNDatabase)
+}
+
+// SessionRevokeLinkLifespan returns how long the session revocation links sent in security
+// notifications are valid.
+func (p *Config) SessionRevokeLinkLifespan(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySessionRevokeLinkLifespan, 24*time.Hour)
+}
+
+// SessionRevokeLinkUIURL returns the URL of the page which asks to confirm the revocation of all
+// sessions, or nil if browsers are not redirected to a confirmation page.
+func (p *Config) SessionRevokeLinkUIURL(ctx context.Context) *url.URL {
+	if !p.GetProvider(ctx).Exists(ViperKeySessionRevokeLinkUIURL) {
+		return nil
+	}
+	return p.ParseAbsoluteOrRelativeURIOrFail(ctx, ViperKeySessionRevokeLinkUIURL)
+}
+
+func (p *Config) SessionEventsWarnBefore(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySessionEventsWarnBefore, 5*time.Minute)
+}
+
+func (p *Config) SessionEventsCheckInterval(ctx context.Context) time.Duration {
+	return p.GetProvider(ctx).DurationF(ViperKeySessionEventsCheckInterval, 15*time.Second)
+}
+
 func (p *Config) SelfServiceSettingsRequiredAAL(ctx context.Context) string {
 	return p.GetProvider(ctx).String(ViperKeySelfServiceSettingsRequiredAAL)
 }
 
+// SelfServiceSettingsRequiredAuthenticationMethods returns the authentication methods of which the
+// session must have completed at least one to update the settings.
+func (p *Config) SelfServiceSettingsRequiredAuthenticationMethods(ctx context.Context) []string {
+	return p.GetProvider(ctx).Strings(ViperKeySelfServiceSettingsRequiredAuthenticationMethods)
+}
+
 func (p *Config) CookieSameSiteMode(ctx context.Context) http.SameSite {
 	switch p.GetProvider(ctx).StringF(ViperKeyCookieSameSite, "Lax") {
 	case "Lax":
@@ -1451,6 +2808,26 @@ func (p *Config) CookieSameSiteMode(ctx context.Context) http.SameSite {
 	return http.SameSiteDefaultMode
 }
 
+func (p *Config) CSRFCookieSameSiteMode(ctx context.Context) http.SameSite {
+	if !p.GetProvider(ctx).Exists(ViperKeySecurityCSRFCookieSameSite) {
+		return p.CookieSameSiteMode(ctx)
+	}
+
+	switch p.GetProvider(ctx).String(ViperKeySecurityCSRFCookieSameSite) {
+	case "Lax":
+		return http.SameSiteLaxMode
+	case "Strict":
+		return http.SameSiteStrictMode
+	case "None":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteDefaultMode
+}
+
+func (p *Config) CSRFCookiePartitioned(ctx context.Context) bool {
+	return p.GetProvider(ctx).Bool(ViperKeySecurityCSRFCookiePartitioned)
+}
+
 func (p *Config) SessionPath(ctx context.Context) string {
 	if !p.GetProvider(ctx).Exists(ViperKeySessionPath) {
 		return p.CookiePath(ctx)
@@ -1507,6 +2884,8 @@ func (p *Config) PasswordPolicyConfig(ctx context.Context) *PasswordPolicy {
 		IgnoreNetworkErrors:              p.GetProvider(ctx).BoolF(ViperKeyIgnoreNetworkErrors, true),
 		MinPasswordLength:                uint(p.GetProvider(ctx).IntF(ViperKeyPasswordMinLength, 8)),
 		IdentifierSimilarityCheckEnabled: p.GetProvider(ctx).BoolF(ViperKeyPasswordIdentifierSimilarityCheckEnabled, true),
+		MaxAge:                           p.GetProvider(ctx).DurationF(ViperKeyPasswordMaxAge, 0),
+		ExpiryWarning:                    p.GetProvider(ctx).DurationF(ViperKeyPasswordExpiryWarning, 7*24*time.Hour),
 	}
 }
 
@@ -1526,7 +2905,17 @@ func (p *Config) WebAuthnConfig(ctx context.Context) *webauthn.Config {
 		AuthenticatorSelection: protocol.AuthenticatorSelection{
 			UserVerification: protocol.VerificationDiscouraged,
 		},
-		EncodeUserIDAsString: false,
+		AttestationPreference: p.WebAuthnAttestation(ctx).conveyancePreference(),
+		EncodeUserIDAsString:  false,
+	}
+}
+
+func (p *Config) WebAuthnAttestation(ctx context.Context) *WebAuthnAttestation {
+	return &WebAuthnAttestation{
+		MDSEnabled:     p.GetProvider(ctx).BoolF(ViperKeyWebAuthnAttestationMDSEnabled, false),
+		MDSCachePath:   p.GetProvider(ctx).String(ViperKeyWebAuthnAttestationMDSCachePath),
+		AllowedAAGUIDs: p.GetProvider(ctx).Strings(ViperKeyWebAuthnAttestationAllowedAAGUIDs),
+		DeniedAAGUIDs:  p.GetProvider(ctx).Strings(ViperKeyWebAuthnAttestationDeniedAAGUIDs),
 	}
 }
 
@@ -1544,8 +2933,32 @@ func (p *Config) PasskeyConfig(ctx context.Context) *webauthn.Config {
 			ResidentKey:             protocol.ResidentKeyRequirementRequired,
 			UserVerification:        protocol.VerificationPreferred,
 		},
-		EncodeUserIDAsString: false,
+		AttestationPreference: p.PasskeyAttestation(ctx).conveyancePreference(),
+		EncodeUserIDAsString:  false,
+	}
+}
+
+func (p *Config) PasskeyAttestation(ctx context.Context) *WebAuthnAttestation {
+	return &WebAuthnAttestation{
+		MDSEnabled:     p.GetProvider(ctx).BoolF(ViperKeyPasskeyAttestationMDSEnabled, false),
+		MDSCachePath:   p.GetProvider(ctx).String(ViperKeyPasskeyAttestationMDSCachePath),
+		AllowedAAGUIDs: p.GetProvider(ctx).Strings(ViperKeyPasskeyAttestationAllowedAAGUIDs),
+		DeniedAAGUIDs:  p.GetProvider(ctx).Strings(ViperKeyPasskeyAttestationDeniedAAGUIDs),
+	}
+}
+
+// Enabled returns true if registered authenticators are checked against the attestation policy.
+func (a *WebAuthnAttestation) Enabled() bool {
+	return a.MDSEnabled || len(a.AllowedAAGUIDs) > 0 || len(a.DeniedAAGUIDs) > 0
+}
+
+// conveyancePreference requests the attestation statement from the authenticator if it is needed to
+// enforce the attestation policy. Otherwise, no attestation is requested.
+func (a *WebAuthnAttestation) conveyancePreference() protocol.ConveyancePreference {
+	if a.Enabled() {
+		return protocol.PreferDirectAttestation
 	}
+	return ""
 }
 
 func (p *Config) HasherPasswordHashingAlgorithm(ctx context.Context) string {
@@ -1598,6 +3011,28 @@ func (p *Config) GetTLSCertificatesForAdmin(ctx context.Context) CertFunc {
 	)
 }
 
+func (p *Config) GetTLSCertificatesForGRPCPublic(ctx context.Context) CertFunc {
+	return p.getTLSCertificates(
+		ctx,
+		"grpc.public",
+		p.GetProvider(ctx).String(ViperKeyGRPCPublicTLSCertBase64),
+		p.GetProvider(ctx).String(ViperKeyGRPCPublicTLSKeyBase64),
+		p.GetProvider(ctx).String(ViperKeyGRPCPublicTLSCertPath),
+		p.GetProvider(ctx).String(ViperKeyGRPCPublicTLSKeyPath),
+	)
+}
+
+func (p *Config) GetTLSCertificatesForGRPCAdmin(ctx context.Context) CertFunc {
+	return p.getTLSCertificates(
+		ctx,
+		"grpc.admin",
+		p.GetProvider(ctx).String(ViperKeyGRPCAdminTLSCertBase64),
+		p.GetProvider(ctx).String(ViperKeyGRPCAdminTLSKeyBase64),
+		p.GetProvider(ctx).String(ViperKeyGRPCAdminTLSCertPath),
+		p.GetProvider(ctx).String(ViperKeyGRPCAdminTLSKeyPath),
+	)
+}
+
 func (p *Config) getTLSCertificates(ctx context.Context, daemon, certBase64, keyBase64, certPath, keyPath string) CertFunc {
 	if certBase64 != "" && keyBase64 != "" {
 		cert, err := tlsx.CertificateFromBase64(certBase64, keyBase64)
@@ -1628,13 +3063,26 @@ func (p *Config) getTLSCertificates(ctx context.Context, daemon, certBase64, key
 }
 
 func (p *Config) GetProvider(ctx context.Context) *configx.Provider {
-	return p.c.Config(ctx, p.p)
+	return p.c.Config(ctx, p.p.Load())
 }
 
+const (
+	SessionTokenizeSubjectTypePublic   = "public"
+	SessionTokenizeSubjectTypePairwise = "pairwise"
+)
+
 type SessionTokenizeFormat struct {
 	TTL             time.Duration `koanf:"ttl" json:"ttl"`
 	ClaimsMapperURL string        `koanf:"claims_mapper_url" json:"claims_mapper_url"`
 	JWKSURL         string        `koanf:"jwks_url" json:"jwks_url"`
+
+	// SubjectType is either `public`, which uses the identity ID as the subject, or `pairwise`,
+	// which uses an identifier derived from the identity ID and the audience.
+	SubjectType string `koanf:"subject_type" json:"subject_type,omitempty"`
+
+	// Audience is set as the `aud` claim and is used to derive pairwise subject identifiers.
+	// Defaults to the template name.
+	Audience string `koanf:"audience" json:"audience,omitempty"`
 }
 
 func (p *Config) TokenizeTemplate(ctx context.Context, key string) (_ *SessionTokenizeFormat, err error) {
@@ -1680,3 +3128,11 @@ func (p *Config) SelfServiceLoginFlowIdentifierFirstEnabled(ctx context.Context)
 func (p *Config) SecurityAccountEnumerationMitigate(ctx context.Context) bool {
 	return p.GetProvider(ctx).Bool(ViperKeySecurityAccountEnumerationMitigate)
 }
+
+func (p *Config) SecurityCSRFTokenRotation(ctx context.Context) CSRFTokenRotation {
+	switch r := CSRFTokenRotation(p.GetProvider(ctx).String(ViperKeySecurityCSRFTokenRotation)); r {
+	case CSRFTokenRotationFlow, CSRFTokenRotationRequest:
+		return r
+	}
+	return CSRFTokenRotationSession
+}
@@ -0,0 +1,104 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/toml"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/v2"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/ory/x/configx"
+)
+
+// interpolationPattern matches `${NAME}`, `${NAME:default}` and the escape sequence `$${`.
+var interpolationPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([^}]*))?\}`)
+
+// WithFlags loads the configuration from the command line flags like configx.WithFlags.
+//
+// The config files passed using --config are merged in the order specified, so that an
+// environment specific file can override values of a base file. References to environment
+// variables of the form `${NAME}` or `${NAME:default}` in the files are replaced with the values of
+// the environment variables. Write `$${` to keep a literal `${`.
+//
+// The files are loaded as user providers, so this option must not be combined with
+// configx.WithUserProviders.
+func WithFlags(flags *pflag.FlagSet) configx.OptionModifier {
+	return func(p *configx.Provider) {
+		configx.WithFlags(flags)(p)
+
+		files, _ := flags.GetStringSlice(configx.FlagConfig)
+		providers := make([]koanf.Provider, 0, len(files))
+		for _, file := range files {
+			providers = append(providers, &interpolatedFile{path: file})
+		}
+		configx.WithUserProviders(providers...)(p)
+	}
+}
+
+// interpolatedFile reads a config file and replaces references to environment variables. The file
+// is also loaded and watched by configx, whose values are overridden by this provider.
+type interpolatedFile struct {
+	path string
+}
+
+func (f *interpolatedFile) ReadBytes() ([]byte, error) {
+	return nil, errors.New("interpolated file provider does not support this method")
+}
+
+func (f *interpolatedFile) Read() (map[string]interface{}, error) {
+	//#nosec G304 -- the path is set by the operator
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	content, err = InterpolateEnv(content)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to interpolate config file %s", f.path)
+	}
+
+	switch e := filepath.Ext(f.path); e {
+	case ".json":
+		return json.Parser().Unmarshal(content)
+	case ".yaml", ".yml":
+		return yaml.Parser().Unmarshal(content)
+	case ".toml":
+		return toml.Parser().Unmarshal(content)
+	default:
+		return nil, errors.Errorf("unknown config file extension: %s", e)
+	}
+}
+
+// InterpolateEnv replaces references to environment variables of the form `${NAME}` or
+// `${NAME:default}`. The default is used if the environment variable is not set. It is an error to
+// reference an environment variable which is not set and has no default.
+func InterpolateEnv(content []byte) ([]byte, error) {
+	var err error
+	result := interpolationPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		if string(match) == "$${" {
+			return []byte("${")
+		}
+
+		groups := interpolationPattern.FindSubmatch(match)
+		if value, ok := os.LookupEnv(string(groups[1])); ok {
+			return []byte(value)
+		}
+		if groups[2] != nil {
+			return groups[2]
+		}
+
+		if err == nil {
+			err = errors.Errorf("environment variable %s is not set and has no default", groups[1])
+		}
+		return match
+	})
+	return result, err
+}
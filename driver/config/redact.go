@@ -0,0 +1,90 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"context"
+	"strings"
+)
+
+// RedactedValue replaces secrets in the rendered configuration.
+const RedactedValue = "<redacted>"
+
+var (
+	// sensitiveKeys are the names of keys whose values are secret. Objects with these names, like
+	// the password method, are not secret.
+	sensitiveKeys = map[string]struct{}{
+		"dsn":               {},
+		"connection_uri":    {},
+		"secret":            {},
+		"secret_key":        {},
+		"client_secret":     {},
+		"password":          {},
+		"private_key":       {},
+		"apple_private_key": {},
+		"token":             {},
+	}
+
+	// sensitiveSections are the paths of objects whose values are all secret.
+	sensitiveSections = map[string]struct{}{
+		"secrets": {},
+	}
+
+	// sensitivePathSuffixes are the suffixes of paths whose values are secret even though the
+	// name of the key alone is not.
+	sensitivePathSuffixes = []string{
+		"key.base64",
+		"auth.config.value",
+	}
+)
+
+// Redacted returns the effective configuration with all secrets replaced by RedactedValue.
+func (p *Config) Redacted(ctx context.Context) map[string]interface{} {
+	redacted, _ := redact(p.GetProvider(ctx).Raw(), "", false).(map[string]interface{})
+	return redacted
+}
+
+func redact(value interface{}, path string, sensitive bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			result[key] = redact(child, childPath, sensitive || isSensitive(key, childPath, child))
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			result[i] = redact(child, path, sensitive)
+		}
+		return result
+	}
+
+	if sensitive && value != nil {
+		return RedactedValue
+	}
+	return value
+}
+
+func isSensitive(key, path string, value interface{}) bool {
+	if _, ok := sensitiveSections[path]; ok {
+		return true
+	}
+	if _, ok := value.(map[string]interface{}); ok {
+		return false
+	}
+	if _, ok := sensitiveKeys[key]; ok {
+		return true
+	}
+	for _, suffix := range sensitivePathSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
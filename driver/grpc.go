@@ -0,0 +1,69 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"crypto/tls"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	identityv1 "github.com/ory/kratos/gen/identity/v1"
+	sessionv1 "github.com/ory/kratos/gen/session/v1"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+)
+
+// NewGRPCPublicServer returns the public gRPC API server. It serves the session service and the
+// health checking protocol, and reflection if enabled.
+func NewGRPCPublicServer(ctx context.Context, r Registry) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(x.GRPCErrorUnaryInterceptor(r.Logger())),
+		grpc.ChainStreamInterceptor(x.GRPCErrorStreamInterceptor(r.Logger())),
+	}
+	if certs := r.Config().GetTLSCertificatesForGRPCPublic(ctx); certs != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{GetCertificate: certs, MinVersion: tls.VersionTLS12})))
+	}
+
+	server := grpc.NewServer(opts...)
+	sessionv1.RegisterSessionServiceServer(server, session.NewGRPCHandler(r.SessionHandler()))
+	healthv1.RegisterHealthServer(server, health.NewServer())
+	if r.Config().GRPCPublicReflectionEnabled(ctx) {
+		reflection.Register(server)
+	}
+	return server
+}
+
+// NewGRPCAdminServer returns the admin gRPC API server. It serves the identity service, the
+// health checking protocol and reflection. Calls to the identity service require an admin API
+// token if admin API tokens are configured.
+func NewGRPCAdminServer(ctx context.Context, r Registry) *grpc.Server {
+	authorizer := x.NewAdminAPITokenAuthorizer(r)
+	admin := []string{identityv1.IdentityService_ServiceDesc.ServiceName}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			x.GRPCErrorUnaryInterceptor(r.Logger()),
+			authorizer.UnaryServerInterceptor(admin...),
+		),
+		grpc.ChainStreamInterceptor(
+			x.GRPCErrorStreamInterceptor(r.Logger()),
+			authorizer.StreamServerInterceptor(admin...),
+		),
+	}
+	if certs := r.Config().GetTLSCertificatesForGRPCAdmin(ctx); certs != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{GetCertificate: certs, MinVersion: tls.VersionTLS12})))
+	}
+
+	server := grpc.NewServer(opts...)
+	identityv1.RegisterIdentityServiceServer(server, identity.NewGRPCHandler(r.IdentityHandler()))
+	healthv1.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	return server
+}
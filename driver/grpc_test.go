@@ -0,0 +1,220 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package driver_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthv1 "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	identityv1 "github.com/ory/kratos/gen/identity/v1"
+	sessionv1 "github.com/ory/kratos/gen/session/v1"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/x"
+)
+
+func TestGRPCServer(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchemaFromRaw(conf, []byte(`{
+  "type": "object",
+  "properties": {
+    "traits": {
+      "type": "object",
+      "properties": {
+        "email": {
+          "type": "string",
+          "ory.sh/kratos": {"credentials": {"password": {"identifier": true}}}
+        }
+      }
+    }
+  }
+}`))
+
+	serve := func(t *testing.T, server *grpc.Server) *grpc.ClientConn {
+		listener := bufconn.Listen(1024 * 1024)
+		go func() { _ = server.Serve(listener) }()
+		t.Cleanup(server.Stop)
+
+		conn, err := grpc.NewClient("passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+	publicConn := serve(t, driver.NewGRPCPublicServer(ctx, reg))
+	adminConn := serve(t, driver.NewGRPCAdminServer(ctx, reg))
+
+	identities := identityv1.NewIdentityServiceClient(adminConn)
+	sessions := sessionv1.NewSessionServiceClient(publicConn)
+
+	traits := func(t *testing.T, email string) *structpb.Struct {
+		s, err := structpb.NewStruct(map[string]any{"email": email})
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("case=serves the health checking protocol", func(t *testing.T) {
+		for _, conn := range []*grpc.ClientConn{publicConn, adminConn} {
+			res, err := healthv1.NewHealthClient(conn).Check(ctx, &healthv1.HealthCheckRequest{})
+			require.NoError(t, err)
+			assert.Equal(t, healthv1.HealthCheckResponse_SERVING, res.Status)
+		}
+	})
+
+	t.Run("case=does not serve the identity service publicly", func(t *testing.T) {
+		_, err := identityv1.NewIdentityServiceClient(publicConn).GetIdentity(ctx, &identityv1.GetIdentityRequest{Id: x.NewUUID().String()})
+		assert.Equal(t, codes.Unimplemented, status.Code(err), "%+v", err)
+
+		_, err = sessionv1.NewSessionServiceClient(adminConn).Whoami(ctx, &sessionv1.WhoamiRequest{SessionToken: "invalid"})
+		assert.Equal(t, codes.Unimplemented, status.Code(err), "%+v", err)
+	})
+
+	t.Run("case=does not serve reflection publicly by default", func(t *testing.T) {
+		stream, err := reflectionv1.NewServerReflectionClient(publicConn).ServerReflectionInfo(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&reflectionv1.ServerReflectionRequest{MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{}}))
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unimplemented, status.Code(err), "%+v", err)
+
+		stream, err = reflectionv1.NewServerReflectionClient(adminConn).ServerReflectionInfo(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&reflectionv1.ServerReflectionRequest{MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{}}))
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		assert.NotEmpty(t, res.GetListServicesResponse().GetService())
+	})
+
+	t.Run("case=manages identities", func(t *testing.T) {
+		email := x.NewUUID().String() + "@ory.sh"
+		created, err := identities.CreateIdentity(ctx, &identityv1.CreateIdentityRequest{
+			SchemaId: config.DefaultIdentityTraitsSchemaID,
+			Traits:   traits(t, email),
+		})
+		require.NoError(t, err)
+		id := created.Identity.Id
+		assert.Equal(t, "active", created.Identity.State)
+		assert.Equal(t, email, created.Identity.Traits.AsMap()["email"])
+
+		_, err = identities.CreateIdentity(ctx, &identityv1.CreateIdentityRequest{
+			SchemaId: config.DefaultIdentityTraitsSchemaID,
+			Traits:   traits(t, email),
+		})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err), "%+v", err)
+
+		got, err := identities.GetIdentity(ctx, &identityv1.GetIdentityRequest{Id: id})
+		require.NoError(t, err)
+		assert.Equal(t, email, got.Identity.Traits.AsMap()["email"])
+
+		updatedEmail := x.NewUUID().String() + "@ory.sh"
+		updated, err := identities.UpdateIdentity(ctx, &identityv1.UpdateIdentityRequest{
+			Id:     id,
+			State:  "inactive",
+			Traits: traits(t, updatedEmail),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "inactive", updated.Identity.State)
+		assert.Equal(t, updatedEmail, updated.Identity.Traits.AsMap()["email"])
+
+		_, err = identities.UpdateIdentity(ctx, &identityv1.UpdateIdentityRequest{Id: id, State: "invalid", Traits: traits(t, updatedEmail)})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "%+v", err)
+
+		_, err = identities.DeleteIdentity(ctx, &identityv1.DeleteIdentityRequest{Id: id})
+		require.NoError(t, err)
+
+		_, err = identities.GetIdentity(ctx, &identityv1.GetIdentityRequest{Id: id})
+		assert.Equal(t, codes.NotFound, status.Code(err), "%+v", err)
+
+		_, err = identities.GetIdentity(ctx, &identityv1.GetIdentityRequest{Id: "not-a-uuid"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "%+v", err)
+	})
+
+	t.Run("case=streams identities page by page", func(t *testing.T) {
+		for k := 0; k < 5; k++ {
+			_, err := identities.CreateIdentity(ctx, &identityv1.CreateIdentityRequest{
+				SchemaId: config.DefaultIdentityTraitsSchemaID,
+				Traits:   traits(t, fmt.Sprintf("list-%d-%s@ory.sh", k, x.NewUUID())),
+			})
+			require.NoError(t, err)
+		}
+
+		stream, err := identities.ListIdentities(ctx, &identityv1.ListIdentitiesRequest{PageSize: 2})
+		require.NoError(t, err)
+
+		var pages, total int
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(res.Identities), 2)
+			pages++
+			total += len(res.Identities)
+		}
+		assert.GreaterOrEqual(t, total, 5)
+		assert.GreaterOrEqual(t, pages, 3)
+	})
+
+	t.Run("case=returns the session of a token", func(t *testing.T) {
+		s := testhelpers.CreateSession(t, reg)
+
+		res, err := sessions.Whoami(ctx, &sessionv1.WhoamiRequest{SessionToken: s.Token})
+		require.NoError(t, err)
+		assert.Equal(t, s.ID.String(), res.Session.Id)
+		assert.True(t, res.Session.Active)
+		assert.Equal(t, s.IdentityID.String(), res.Session.Identity.Id)
+
+		_, err = sessions.Whoami(ctx, &sessionv1.WhoamiRequest{SessionToken: "invalid"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "%+v", err)
+	})
+
+	t.Run("case=requires an admin API token for identities", func(t *testing.T) {
+		const token = "grpc-token-0123456789abcdefghijklmnop"
+		conf.MustSet(ctx, config.ViperKeyAdminAPITokens, []map[string]any{{"id": "grpc", "token": token}})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyAdminAPITokens, nil) })
+
+		_, err := identities.CreateIdentity(ctx, &identityv1.CreateIdentityRequest{SchemaId: config.DefaultIdentityTraitsSchemaID})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "%+v", err)
+
+		stream, err := identities.ListIdentities(ctx, &identityv1.ListIdentitiesRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err), "%+v", err)
+
+		metadataAdmin, err := structpb.NewStruct(map[string]any{"internal": true})
+		require.NoError(t, err)
+		authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		created, err := identities.CreateIdentity(authorized, &identityv1.CreateIdentityRequest{
+			SchemaId:      config.DefaultIdentityTraitsSchemaID,
+			Traits:        traits(t, x.NewUUID().String()+"@ory.sh"),
+			MetadataAdmin: metadataAdmin,
+		})
+		require.NoError(t, err)
+		assert.Nil(t, created.Identity.MetadataAdmin, "the token does not have the metadata_admin:read scope")
+
+		s := testhelpers.CreateSession(t, reg)
+		_, err = sessions.Whoami(ctx, &sessionv1.WhoamiRequest{SessionToken: s.Token})
+		require.NoError(t, err, "the session service does not require an admin API token")
+	})
+}
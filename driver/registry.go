@@ -11,26 +11,40 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ory/kratos/cipher"
+	"github.com/ory/kratos/configbundle"
+	"github.com/ory/kratos/configreload"
 	"github.com/ory/kratos/continuity"
 	"github.com/ory/kratos/courier"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/hash"
+	"github.com/ory/kratos/health"
 	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/jobs"
 	"github.com/ory/kratos/persistence"
+	"github.com/ory/kratos/persistence/sql"
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/errorx"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
+	"github.com/ory/kratos/selfservice/flow/funnel"
+	"github.com/ory/kratos/selfservice/flow/inspect"
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/selfservice/flow/logout"
 	"github.com/ory/kratos/selfservice/flow/recovery"
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/flow/simulate"
 	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
+	"github.com/ory/kratos/selfservice/hostedui"
 	"github.com/ory/kratos/selfservice/sessiontokenexchange"
+	"github.com/ory/kratos/selfservice/sso"
 	"github.com/ory/kratos/selfservice/strategy/code"
 	"github.com/ory/kratos/selfservice/strategy/link"
+	"github.com/ory/kratos/selfservice/strategy/oidc"
 	password2 "github.com/ory/kratos/selfservice/strategy/password"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/x"
+	"github.com/ory/kratos/x/secretref"
 	"github.com/ory/nosurf"
 	"github.com/ory/x/contextx"
 	"github.com/ory/x/dbal"
@@ -51,10 +65,12 @@ type Registry interface {
 	WithJsonnetVMProvider(jsonnetsecure.VMProvider) Registry
 
 	WithCSRFHandler(c nosurf.Handler)
+	HasCSRFHandler() bool
 	WithCSRFTokenGenerator(cg x.CSRFToken)
 
 	MetricsHandler() *prometheus.Handler
 	HealthHandler(ctx context.Context) *healthx.Handler
+	health.HandlerProvider
 	CookieManager(ctx context.Context) sessions.StoreExact
 	ContinuityCookieManager(ctx context.Context) sessions.StoreExact
 
@@ -74,6 +90,7 @@ type Registry interface {
 	x.WriterProvider
 	x.LoggingProvider
 	x.HTTPClientProvider
+	x.JWKSFetchProvider
 	jsonnetsecure.VMProvider
 
 	continuity.ManagementProvider
@@ -82,6 +99,7 @@ type Registry interface {
 	cipher.Provider
 
 	courier.Provider
+	courier.NotifierProvider
 
 	persistence.Provider
 
@@ -89,6 +107,8 @@ type Registry interface {
 	errorx.HandlerProvider
 	errorx.PersistenceProvider
 
+	hostedui.HandlerProvider
+
 	hash.HashProvider
 
 	identity.HandlerProvider
@@ -97,6 +117,11 @@ type Registry interface {
 	identity.PrivilegedPoolProvider
 	identity.ManagementProvider
 	identity.ActiveCredentialsCounterStrategyProvider
+	identity.DerivedTraitsMapperProvider
+	identity.WebhookPersistenceProvider
+	identity.WebhookSenderProvider
+	identity.TraitsEncrypterProvider
+	identity.TraitsKeyWrapperProvider
 
 	courier.HandlerProvider
 	courier.PersistenceProvider
@@ -104,6 +129,10 @@ type Registry interface {
 	schema.HandlerProvider
 	schema.IdentitySchemaProvider
 
+	configbundle.HandlerProvider
+	configreload.HandlerProvider
+	configreload.ReloaderProvider
+
 	password2.ValidationProvider
 
 	session.HandlerProvider
@@ -125,6 +154,32 @@ type Registry interface {
 
 	logout.HandlerProvider
 
+	crossdevice.FlowPersistenceProvider
+	crossdevice.HandlerProvider
+
+	funnel.PersistenceProvider
+	funnel.RecorderProvider
+	funnel.HandlerProvider
+
+	inspect.PersistenceProvider
+	inspect.HandlerProvider
+
+	simulate.HandlerProvider
+
+	sso.PersistenceProvider
+	sso.HandlerProvider
+
+	deadletter.PersistenceProvider
+	deadletter.HandlerProvider
+
+	oidc.TokenVaultHandlerProvider
+
+	x.IssuedAdminAPITokenPersistenceProvider
+	secretref.Provider
+
+	jobs.LeasePersistenceProvider
+	jobs.SchedulerProvider
+
 	registration.FlowPersistenceProvider
 	registration.ErrorHandlerProvider
 	registration.HooksProvider
@@ -180,6 +235,8 @@ type options struct {
 	config                        *config.Config
 	replaceTracer                 func(*otelx.Tracer) *otelx.Tracer
 	replaceIdentitySchemaProvider func(Registry) schema.IdentitySchemaProvider
+	replaceTraitsKeyWrapper       func(Registry) identity.TraitsKeyWrapper
+	extraSecretBackends           []secretref.Backend
 	inspect                       func(Registry) error
 	extraMigrations               []fs.FS
 	extraGoMigrations             popx.Migrations
@@ -188,6 +245,7 @@ type options struct {
 	extraHandlers                 []NewHandlerRegistrar
 	disableMigrationLogging       bool
 	jsonnetPool                   jsonnetsecure.Pool
+	sqliteCheckpointHooks         []sql.SQLiteCheckpointHook
 }
 
 type RegistryOption func(*options)
@@ -214,6 +272,22 @@ func WithIdentitySchemaProvider(f func(r Registry) schema.IdentitySchemaProvider
 	}
 }
 
+// WithTraitsKeyWrapper replaces the key wrapper which encrypts the data keys of encrypted
+// identity traits, for example to use a key management service instead of `secrets.traits`.
+func WithTraitsKeyWrapper(f func(r Registry) identity.TraitsKeyWrapper) RegistryOption {
+	return func(o *options) {
+		o.replaceTraitsKeyWrapper = f
+	}
+}
+
+// WithSecretBackends adds secret backends which resolve secret references in the configuration.
+// A backend replaces the built-in backend with the same scheme.
+func WithSecretBackends(backends ...secretref.Backend) RegistryOption {
+	return func(o *options) {
+		o.extraSecretBackends = append(o.extraSecretBackends, backends...)
+	}
+}
+
 func ReplaceTracer(f func(*otelx.Tracer) *otelx.Tracer) RegistryOption {
 	return func(o *options) {
 		o.replaceTracer = f
@@ -263,6 +337,14 @@ func WithExtraGoMigrations(m ...popx.Migration) RegistryOption {
 	}
 }
 
+// WithSQLiteCheckpointHooks adds hooks which are notified around the checkpoints of the
+// sqlite_wal_checkpoint job.
+func WithSQLiteCheckpointHooks(hooks ...sql.SQLiteCheckpointHook) RegistryOption {
+	return func(o *options) {
+		o.sqliteCheckpointHooks = append(o.sqliteCheckpointHooks, hooks...)
+	}
+}
+
 func WithDisabledMigrationLogging() RegistryOption {
 	return func(o *options) {
 		o.disableMigrationLogging = true
@@ -23,28 +23,45 @@ import (
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/luna-duclos/instrumentedsql"
 	"github.com/pkg/errors"
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/otel/trace/noop"
 
 	"github.com/ory/herodot"
 	"github.com/ory/kratos/cipher"
+	"github.com/ory/kratos/configbundle"
+	"github.com/ory/kratos/configreload"
 	"github.com/ory/kratos/continuity"
 	"github.com/ory/kratos/courier"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/hash"
+	"github.com/ory/kratos/health"
 	"github.com/ory/kratos/hydra"
+	"github.com/ory/kratos/i18n"
 	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/jobs"
 	"github.com/ory/kratos/persistence"
+	"github.com/ory/kratos/persistence/redis"
 	"github.com/ory/kratos/persistence/sql"
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/errorx"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
+	"github.com/ory/kratos/selfservice/flow/funnel"
+	"github.com/ory/kratos/selfservice/flow/inspect"
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/selfservice/flow/logout"
 	"github.com/ory/kratos/selfservice/flow/recovery"
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/flow/simulate"
 	"github.com/ory/kratos/selfservice/flow/verification"
 	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
+	"github.com/ory/kratos/selfservice/hostedui"
+	"github.com/ory/kratos/selfservice/sso"
 	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/kratos/selfservice/strategy/devicekey"
+	"github.com/ory/kratos/selfservice/strategy/externalmfa"
 	"github.com/ory/kratos/selfservice/strategy/link"
 	"github.com/ory/kratos/selfservice/strategy/lookup"
 	"github.com/ory/kratos/selfservice/strategy/oidc"
@@ -55,6 +72,10 @@ import (
 	"github.com/ory/kratos/selfservice/strategy/webauthn"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/x"
+	"github.com/ory/kratos/x/audit"
+	"github.com/ory/kratos/x/egress"
+	"github.com/ory/kratos/x/secretref"
+	"github.com/ory/kratos/x/webauthnx"
 	"github.com/ory/nosurf"
 	"github.com/ory/x/contextx"
 	"github.com/ory/x/dbal"
@@ -64,8 +85,6 @@ import (
 	"github.com/ory/x/jwksx"
 	"github.com/ory/x/logrusx"
 	"github.com/ory/x/otelx"
-	otelsql "github.com/ory/x/otelx/sql"
-	"github.com/ory/x/popx"
 	prometheus "github.com/ory/x/prometheusx"
 	"github.com/ory/x/sqlcon"
 )
@@ -81,38 +100,65 @@ type RegistryDefault struct {
 	extraHandlerFactories    []NewHandlerRegistrar
 	extraHandlers            []x.HandlerRegistrar
 
-	nosurf         nosurf.Handler
-	trc            *otelx.Tracer
-	pmm            *prometheus.MetricsManager
-	writer         herodot.Writer
-	healthxHandler *healthx.Handler
-	metricsHandler *prometheus.Handler
+	nosurf           nosurf.Handler
+	trc              *otelx.Tracer
+	pmm              *prometheus.MetricsManager
+	writer           herodot.Writer
+	healthxHandler   *healthx.Handler
+	readinessHandler *health.Handler
+	metricsHandler   *prometheus.Handler
 
 	persister       persistence.Persister
-	migrationStatus popx.MigrationStatuses
+	migrationStatus persistence.MigrationPhaseStatuses
+
+	hookVerifier                *hook.Verifier
+	hookSessionIssuer           *hook.SessionIssuer
+	hookSessionDestroyer        *hook.SessionDestroyer
+	hookAddressVerifier         *hook.AddressVerifier
+	hookRequiredAddressVerifier *hook.AddressVerifier
+	hookShowVerificationUI      *hook.ShowVerificationUIHook
+	hookShowPasswordChangeUI    *hook.ShowPasswordChangeUIHook
+	hookCodeAddressVerifier     *hook.CodeAddressVerifier
+	hookTwoStepRegistration     *hook.TwoStepRegistration
+	grpcHookConnections         *hook.GRPCHookConnections
+
+	identityHandler             *identity.Handler
+	identityValidator           *identity.Validator
+	identityManager             *identity.Manager
+	identitySchemaProvider      schema.IdentitySchemaProvider
+	identityDerivedTraitsMapper *identity.DerivedTraitsMapper
+	disposableEmailBlocker      *identity.DisposableEmailBlocker
+	identityWebhookSender       *identity.WebhookSender
+	identityTraitsEncrypter     *identity.TraitsEncrypter
+	identityTraitsKeyWrapper    identity.TraitsKeyWrapper
+	secretResolver              *secretref.Resolver
+	flowFunnelRecorder          *funnel.Recorder
+	flowFunnelHandler           *funnel.Handler
+	flowInspectionHandler       *inspect.Handler
+	flowSimulationHandler       *simulate.Handler
+	ssoConnectionHandler        *sso.Handler
+	failedWebhookEventHandler   *deadletter.Handler
+	oidcTokenVaultHandler       *oidc.TokenVaultHandler
+
+	courierHandler  *courier.Handler
+	courierNotifier courier.Notifier
 
-	hookVerifier            *hook.Verifier
-	hookSessionIssuer       *hook.SessionIssuer
-	hookSessionDestroyer    *hook.SessionDestroyer
-	hookAddressVerifier     *hook.AddressVerifier
-	hookShowVerificationUI  *hook.ShowVerificationUIHook
-	hookCodeAddressVerifier *hook.CodeAddressVerifier
-	hookTwoStepRegistration *hook.TwoStepRegistration
+	continuityManager continuity.Manager
 
-	identityHandler        *identity.Handler
-	identityValidator      *identity.Validator
-	identityManager        *identity.Manager
-	identitySchemaProvider schema.IdentitySchemaProvider
+	schemaHandler *schema.Handler
 
-	courierHandler *courier.Handler
+	configBundleHandler *configbundle.Handler
+	configReloader      *configreload.Reloader
+	configReloadHandler *configreload.Handler
 
-	continuityManager continuity.Manager
+	jobScheduler *jobs.Scheduler
 
-	schemaHandler *schema.Handler
+	sqliteCheckpointHooks []sql.SQLiteCheckpointHook
 
 	sessionHandler   *session.Handler
 	sessionManager   session.Manager
 	sessionTokenizer *session.Tokenizer
+	sessionGeoIP     *session.GeoIPResolver
 
 	passwordHasher    hash.Hasher
 	passwordValidator password.Validator
@@ -120,7 +166,9 @@ type RegistryDefault struct {
 	crypter cipher.Cipher
 
 	errorHandler *errorx.Handler
-	errorManager *errorx.Manager
+
+	hostedUIHandler *hostedui.Handler
+	errorManager    *errorx.Manager
 
 	selfserviceRegistrationExecutor            *registration.HookExecutor
 	selfserviceRegistrationHandler             *registration.Handler
@@ -131,6 +179,8 @@ type RegistryDefault struct {
 	selfserviceLoginHandler             *login.Handler
 	selfserviceLoginRequestErrorHandler *login.ErrorHandler
 
+	selfserviceCrossDeviceLoginHandler *crossdevice.Handler
+
 	selfserviceSettingsHandler      *settings.Handler
 	selfserviceSettingsErrorHandler *settings.ErrorHandler
 	selfserviceSettingsExecutor     *settings.HookExecutor
@@ -163,6 +213,10 @@ type RegistryDefault struct {
 	jsonnetVMProvider jsonnetsecure.VMProvider
 	jsonnetPool       jsonnetsecure.Pool
 	jwkFetcher        *jwksx.FetcherNext
+
+	webAuthnMetadataLoader *webauthnx.MetadataLoader
+
+	auditSink *audit.Sink
 }
 
 func (m *RegistryDefault) JsonnetVM(ctx context.Context) (jsonnetsecure.VM, error) {
@@ -181,6 +235,10 @@ func (m *RegistryDefault) RegisterPublicRoutes(ctx context.Context, router *x.Ro
 		h.RegisterPublicRoutes(router)
 	}
 	m.LoginHandler().RegisterPublicRoutes(router)
+	m.CrossDeviceLoginHandler().RegisterPublicRoutes(router)
+	m.FlowFunnelHandler().RegisterPublicRoutes(router)
+	m.FlowInspectionHandler().RegisterPublicRoutes(router)
+	m.FlowSimulationHandler().RegisterPublicRoutes(router)
 	m.RegistrationHandler().RegisterPublicRoutes(router)
 	m.LogoutHandler().RegisterPublicRoutes(router)
 	m.SettingsHandler().RegisterPublicRoutes(router)
@@ -191,7 +249,13 @@ func (m *RegistryDefault) RegisterPublicRoutes(ctx context.Context, router *x.Ro
 	m.AllRegistrationStrategies().RegisterPublicRoutes(router)
 	m.SessionHandler().RegisterPublicRoutes(router)
 	m.SelfServiceErrorHandler().RegisterPublicRoutes(router)
+	m.HostedUIHandler().RegisterPublicRoutes(router)
 	m.SchemaHandler().RegisterPublicRoutes(router)
+	m.ConfigBundleHandler().RegisterPublicRoutes(router)
+	m.ConfigReloadHandler().RegisterPublicRoutes(router)
+	m.SSOConnectionHandler().RegisterPublicRoutes(router)
+	m.FailedWebhookEventHandler().RegisterPublicRoutes(router)
+	m.OIDCTokenVaultHandler().RegisterPublicRoutes(router)
 
 	m.AllRecoveryStrategies().RegisterPublicRoutes(router)
 	m.RecoveryHandler().RegisterPublicRoutes(router)
@@ -199,7 +263,8 @@ func (m *RegistryDefault) RegisterPublicRoutes(ctx context.Context, router *x.Ro
 	m.VerificationHandler().RegisterPublicRoutes(router)
 	m.AllVerificationStrategies().RegisterPublicRoutes(router)
 
-	m.HealthHandler(ctx).SetHealthRoutes(router.Router, false)
+	router.Handler("GET", healthx.AliveCheckPath, m.HealthHandler(ctx).Alive())
+	router.Handler("GET", healthx.ReadyCheckPath, m.ReadinessHandler().Ready(false))
 }
 
 func (m *RegistryDefault) RegisterAdminRoutes(ctx context.Context, router *x.RouterAdmin) {
@@ -208,8 +273,17 @@ func (m *RegistryDefault) RegisterAdminRoutes(ctx context.Context, router *x.Rou
 	}
 	m.RegistrationHandler().RegisterAdminRoutes(router)
 	m.LoginHandler().RegisterAdminRoutes(router)
+	m.CrossDeviceLoginHandler().RegisterAdminRoutes(router)
+	m.FlowFunnelHandler().RegisterAdminRoutes(router)
+	m.FlowInspectionHandler().RegisterAdminRoutes(router)
+	m.FlowSimulationHandler().RegisterAdminRoutes(router)
 	m.LogoutHandler().RegisterAdminRoutes(router)
 	m.SchemaHandler().RegisterAdminRoutes(router)
+	m.ConfigBundleHandler().RegisterAdminRoutes(router)
+	m.ConfigReloadHandler().RegisterAdminRoutes(router)
+	m.SSOConnectionHandler().RegisterAdminRoutes(router)
+	m.FailedWebhookEventHandler().RegisterAdminRoutes(router)
+	m.OIDCTokenVaultHandler().RegisterAdminRoutes(router)
 	m.SettingsHandler().RegisterAdminRoutes(router)
 	m.IdentityHandler().RegisterAdminRoutes(router)
 	m.CourierHandler().RegisterAdminRoutes(router)
@@ -222,9 +296,11 @@ func (m *RegistryDefault) RegisterAdminRoutes(ctx context.Context, router *x.Rou
 	m.VerificationHandler().RegisterAdminRoutes(router)
 	m.AllVerificationStrategies().RegisterAdminRoutes(router)
 
-	m.HealthHandler(ctx).SetHealthRoutes(router, true)
+	router.Handler("GET", healthx.AliveCheckPath, m.HealthHandler(ctx).Alive())
+	router.Handler("GET", healthx.ReadyCheckPath, m.ReadinessHandler().Ready(true))
 	m.HealthHandler(ctx).SetVersionRoutes(router)
-	m.MetricsHandler().SetRoutes(router)
+	router.GET(prometheus.MetricsPrometheusPath, x.ServeMetrics)
+	x.RegisterTestClockRoutes(router, m.Writer())
 
 	config.NewConfigHashHandler(m, router)
 }
@@ -261,20 +337,19 @@ func (m *RegistryDefault) HealthHandler(_ context.Context) *healthx.Handler {
 	if m.healthxHandler == nil {
 		m.healthxHandler = healthx.NewHandler(m.Writer(), config.Version,
 			healthx.ReadyCheckers{
-				"database": func(r *http.Request) error {
-					return m.PingContext(r.Context())
-				},
 				"migrations": func(r *http.Request) error {
-					if m.migrationStatus != nil && !m.migrationStatus.HasPending() {
+					// Pending contract migrations do not affect this release, which is
+					// compatible with the schema before and after the contract phase.
+					if m.migrationStatus != nil && !m.migrationStatus.HasPending(persistence.MigrationPhaseExpand) {
 						return nil
 					}
 
-					status, err := m.Persister().MigrationStatus(r.Context())
+					status, err := m.Persister().MigrationPhaseStatus(r.Context())
 					if err != nil {
 						return err
 					}
 
-					if status.HasPending() {
+					if status.HasPending(persistence.MigrationPhaseExpand) {
 						return errors.Errorf("migrations have not yet been fully applied")
 					}
 
@@ -287,6 +362,14 @@ func (m *RegistryDefault) HealthHandler(_ context.Context) *healthx.Handler {
 	return m.healthxHandler
 }
 
+func (m *RegistryDefault) ReadinessHandler() *health.Handler {
+	if m.readinessHandler == nil {
+		m.readinessHandler = health.NewHandler(m, m.HealthHandler(context.Background()).ReadyChecks)
+	}
+
+	return m.readinessHandler
+}
+
 func (m *RegistryDefault) MetricsHandler() *prometheus.Handler {
 	if m.metricsHandler == nil {
 		m.metricsHandler = prometheus.NewHandler(m.Writer(), config.Version)
@@ -299,6 +382,11 @@ func (m *RegistryDefault) WithCSRFHandler(c nosurf.Handler) {
 	m.nosurf = c
 }
 
+// HasCSRFHandler returns true if a CSRF handler was set using WithCSRFHandler.
+func (m *RegistryDefault) HasCSRFHandler() bool {
+	return m.nosurf != nil
+}
+
 func (m *RegistryDefault) CSRFHandler() nosurf.Handler {
 	if m.nosurf == nil {
 		panic("csrf handler is not set")
@@ -336,6 +424,8 @@ func (m *RegistryDefault) selfServiceStrategies() []any {
 				passkey.NewStrategy(m),
 				webauthn.NewStrategy(m),
 				lookup.NewStrategy(m),
+				devicekey.NewStrategy(m),
+				externalmfa.NewStrategy(m),
 				idfirst.NewStrategy(m),
 			}
 		}
@@ -444,8 +534,8 @@ func (m *RegistryDefault) WithSelfserviceStrategies(t testing.TB, strategies []a
 
 func (m *RegistryDefault) Writer() herodot.Writer {
 	if m.writer == nil {
-		h := herodot.NewJSONWriter(m.Logger())
-		m.writer = h
+		h := x.WithErrorEnvelope(m, herodot.NewJSONWriter(m.Logger()))
+		m.writer = i18n.NewWriter(h, i18n.NewLocalizer(m))
 	}
 	return m.writer
 }
@@ -478,6 +568,27 @@ func (m *RegistryDefault) SchemaHandler() *schema.Handler {
 	return m.schemaHandler
 }
 
+func (m *RegistryDefault) ConfigBundleHandler() *configbundle.Handler {
+	if m.configBundleHandler == nil {
+		m.configBundleHandler = configbundle.NewHandler(m)
+	}
+	return m.configBundleHandler
+}
+
+func (m *RegistryDefault) ConfigReloader() *configreload.Reloader {
+	if m.configReloader == nil {
+		m.configReloader = configreload.NewReloader(m)
+	}
+	return m.configReloader
+}
+
+func (m *RegistryDefault) ConfigReloadHandler() *configreload.Handler {
+	if m.configReloadHandler == nil {
+		m.configReloadHandler = configreload.NewHandler(m)
+	}
+	return m.configReloadHandler
+}
+
 func (m *RegistryDefault) SessionHandler() *session.Handler {
 	if m.sessionHandler == nil {
 		m.sessionHandler = session.NewHandler(m)
@@ -529,6 +640,13 @@ func (m *RegistryDefault) SelfServiceErrorHandler() *errorx.Handler {
 	return m.errorHandler
 }
 
+func (m *RegistryDefault) HostedUIHandler() *hostedui.Handler {
+	if m.hostedUIHandler == nil {
+		m.hostedUIHandler = hostedui.NewHandler(m)
+	}
+	return m.hostedUIHandler
+}
+
 func (m *RegistryDefault) CookieManager(ctx context.Context) sessions.StoreExact {
 	var keys [][]byte
 	for _, k := range m.Config().SecretsSession(ctx) {
@@ -631,7 +749,7 @@ func (m *RegistryDefault) Init(ctx context.Context, ctxer contextx.Contextualize
 	var instrumentedDriverOpts []instrumentedsql.Opt
 	if m.Tracer(ctx).IsLoaded() {
 		instrumentedDriverOpts = []instrumentedsql.Opt{
-			instrumentedsql.WithTracer(otelsql.NewTracer()),
+			instrumentedsql.WithTracer(x.NewSQLTracer()),
 			instrumentedsql.WithOpsExcluded(instrumentedsql.OpSQLRowsNext),
 			instrumentedsql.WithOmitArgs(), // don't risk leaking PII or secrets
 		}
@@ -656,6 +774,16 @@ func (m *RegistryDefault) Init(ctx context.Context, ctxer contextx.Contextualize
 		m.identitySchemaProvider = o.replaceIdentitySchemaProvider(m)
 	}
 
+	if o.replaceTraitsKeyWrapper != nil {
+		m.identityTraitsKeyWrapper = o.replaceTraitsKeyWrapper(m)
+	}
+
+	m.sqliteCheckpointHooks = append(m.sqliteCheckpointHooks, o.sqliteCheckpointHooks...)
+
+	if o.extraSecretBackends != nil {
+		m.secretResolver = secretref.NewResolver(m, append(secretref.DefaultBackends(), o.extraSecretBackends...)...)
+	}
+
 	bc := backoff.NewExponentialBackOff()
 	bc.MaxElapsedTime = time.Minute * 5
 	bc.Reset()
@@ -669,7 +797,7 @@ func (m *RegistryDefault) Init(ctx context.Context, ctxer contextx.Contextualize
 			WithField("connMaxLifetime", connMaxLifetime).
 			Debug("Connecting to SQL Database")
 		c, err := pop.NewConnection(&pop.ConnectionDetails{
-			URL:                       sqlcon.FinalizeDSN(m.l, cleanedDSN),
+			URL:                       sqlcon.FinalizeDSN(m.l, m.sqliteDSN(ctx, cleanedDSN)),
 			IdlePool:                  idlePool,
 			ConnMaxLifetime:           connMaxLifetime,
 			ConnMaxIdleTime:           connMaxIdleTime,
@@ -728,6 +856,31 @@ func (m *RegistryDefault) Init(ctx context.Context, ctxer contextx.Contextualize
 		return err
 	}
 
+	if u := m.Config().DatabaseFlowStorageRedisURL(ctx); u != nil && !o.skipNetworkInit {
+		p, err := redis.NewPersister(ctx, m, m.persister, u)
+		if err != nil {
+			return err
+		}
+		m.persister = p
+	}
+
+	if u := m.Config().LogAuditSinkURL(ctx); u != nil && m.auditSink == nil {
+		sink, err := audit.NewSink(m.Logger(), audit.Options{
+			URL:          u,
+			Format:       m.Config().LogAuditSinkFormat(ctx),
+			Headers:      m.Config().LogAuditSinkHeaders(ctx),
+			BufferSize:   m.Config().LogAuditSinkBufferSize(ctx),
+			MaxRetries:   m.Config().LogAuditSinkMaxRetries(ctx),
+			BlockTimeout: m.Config().LogAuditSinkBlockTimeout(ctx),
+			Version:      config.Version,
+		})
+		if err != nil {
+			return err
+		}
+		m.auditSink = sink
+		m.Logger().Logrus().AddHook(sink)
+	}
+
 	if o.inspect != nil {
 		if err := o.inspect(m); err != nil {
 			return errors.WithStack(err)
@@ -744,6 +897,15 @@ func (m *RegistryDefault) Courier(ctx context.Context) (courier.Courier, error)
 	return courier.NewCourier(ctx, m)
 }
 
+func (m *RegistryDefault) CourierNotifier() courier.Notifier {
+	m.rwl.Lock()
+	defer m.rwl.Unlock()
+	if m.courierNotifier == nil {
+		m.courierNotifier = sql.NewCourierNotifier(m, m.Persister())
+	}
+	return m.courierNotifier
+}
+
 func (m *RegistryDefault) ContinuityManager() continuity.Manager {
 	if m.continuityManager == nil {
 		m.continuityManager = continuity.NewManagerCookie(m)
@@ -775,6 +937,38 @@ func (m *RegistryDefault) LoginFlowPersister() login.FlowPersister {
 	return m.persister
 }
 
+func (m *RegistryDefault) CrossDeviceLoginFlowPersister() crossdevice.FlowPersister {
+	return m.persister
+}
+
+func (m *RegistryDefault) IdentityWebhookPersister() identity.WebhookPersister {
+	return m.persister
+}
+
+func (m *RegistryDefault) CredentialsUsagePersister() identity.CredentialsUsagePersister {
+	return m.persister
+}
+
+func (m *RegistryDefault) IssuedAdminAPITokenPersister() x.IssuedAdminAPITokenPersister {
+	return m.persister
+}
+
+func (m *RegistryDefault) FlowFunnelPersister() funnel.Persister {
+	return m.persister
+}
+
+func (m *RegistryDefault) FlowInspectionPersister() inspect.Persister {
+	return m.persister
+}
+
+func (m *RegistryDefault) SSOConnectionPersister() sso.Persister {
+	return m.persister
+}
+
+func (m *RegistryDefault) FailedWebhookEventPersister() deadletter.Persister {
+	return m.persister
+}
+
 func (m *RegistryDefault) SettingsFlowPersister() settings.FlowPersister {
 	return m.persister
 }
@@ -854,11 +1048,34 @@ func (m *RegistryDefault) PrometheusManager() *prometheus.MetricsManager {
 	defer m.rwl.Unlock()
 	if m.pmm == nil {
 		m.pmm = prometheus.NewMetricsManagerWithPrefix("kratos", prometheus.HTTPMetrics, m.buildVersion, m.buildHash, m.buildDate)
+		m.registerCollectors(config.CompatibilityCollectors()...)
+		m.registerCollectors(flow.EventCollectors()...)
+		m.registerCollectors(hook.WebHookCollectors()...)
+		m.registerCollectors(courier.DispatcherCollectors()...)
+		m.registerCollectors(session.MFAEnrollmentCollectors()...)
+		m.registerCollectors(funnel.RecorderCollectors()...)
+		m.registerCollectors(flow.TracingCollectors()...)
+		m.registerCollectors(audit.SinkCollectors()...)
+		m.registerCollectors(hook.CircuitBreakerCollectors()...)
+		m.registerCollectors(sql.CleanupCollectors()...)
+		m.registerCollectors(session.ConcurrencyCollectors()...)
+		m.registerCollectors(x.LoadSheddingCollectors()...)
 	}
 	return m.pmm
 }
 
-func (m *RegistryDefault) HTTPClient(_ context.Context, opts ...httpx.ResilientOptions) *retryablehttp.Client {
+// registerCollectors registers the collectors with the default Prometheus registerer, which the
+// metrics handler serves. Collectors which another registry of this process registered already
+// are kept.
+func (m *RegistryDefault) registerCollectors(collectors ...promclient.Collector) {
+	for _, c := range collectors {
+		if err := promclient.Register(c); err != nil && !errors.As(err, new(promclient.AlreadyRegisteredError)) {
+			m.Logger().WithError(err).Error("Unable to register Prometheus metrics.")
+		}
+	}
+}
+
+func (m *RegistryDefault) HTTPClient(ctx context.Context, opts ...httpx.ResilientOptions) *retryablehttp.Client {
 	opts = append(opts,
 		httpx.ResilientClientWithLogger(m.Logger()),
 		httpx.ResilientClientWithMaxRetry(2),
@@ -866,8 +1083,10 @@ func (m *RegistryDefault) HTTPClient(_ context.Context, opts ...httpx.ResilientO
 		httpx.ResilientClientWithTracer(noop.NewTracerProvider().Tracer("Ory Kratos")), // will use the tracer from a context if available
 	)
 
+	noPrivateIPRanges := config.HTTPClientNoPrivateIPRangesFromContext(ctx)
+
 	// One of the few exceptions, this usually should not be hot reloaded.
-	if m.Config().ClientHTTPNoPrivateIPRanges(contextx.RootContext) {
+	if noPrivateIPRanges || m.Config().ClientHTTPNoPrivateIPRanges(contextx.RootContext) {
 		opts = append(
 			opts,
 			httpx.ResilientClientDisallowInternalIPs(),
@@ -875,7 +1094,28 @@ func (m *RegistryDefault) HTTPClient(_ context.Context, opts ...httpx.ResilientO
 			httpx.ResilientClientAllowInternalIPRequestsTo(m.Config().ClientHTTPPrivateIPExceptionURLs(contextx.RootContext)...),
 		)
 	}
-	return httpx.NewResilientClient(opts...)
+	cl := httpx.NewResilientClient(opts...)
+
+	// One of the few exceptions, this usually should not be hot reloaded.
+	policy, err := m.Config().ClientHTTPEgressPolicy(contextx.RootContext, config.HTTPClientIntegrationFromContext(ctx))
+	if err != nil {
+		m.Logger().WithError(err).Error("Unable to load the egress policy of the HTTP client, outgoing requests will be rejected.")
+		cl.HTTPClient.Transport = rejectingTransport{err: err}
+		return cl
+	}
+	if policy.IsDefault() {
+		return cl
+	}
+
+	policy.DisallowPrivateIPRanges = policy.DisallowPrivateIPRanges || noPrivateIPRanges
+	cl.HTTPClient.Transport = egress.SharedTransport(policy)
+	return cl
+}
+
+type rejectingTransport struct{ err error }
+
+func (t rejectingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
 }
 
 func (m *RegistryDefault) WithContextualizer(ctxer contextx.Contextualizer) Registry {
@@ -909,6 +1149,15 @@ func (m *RegistryDefault) JWKSFetcher() *jwksx.FetcherNext {
 	return m.jwkFetcher
 }
 
+func (m *RegistryDefault) WebAuthnMetadataLoader() *webauthnx.MetadataLoader {
+	m.rwl.Lock()
+	defer m.rwl.Unlock()
+	if m.webAuthnMetadataLoader == nil {
+		m.webAuthnMetadataLoader = webauthnx.NewMetadataLoader(m.HTTPClient(contextx.RootContext).StandardClient())
+	}
+	return m.webAuthnMetadataLoader
+}
+
 func (m *RegistryDefault) SessionTokenizer() *session.Tokenizer {
 	if m.sessionTokenizer == nil {
 		m.sessionTokenizer = session.NewTokenizer(m)
@@ -916,6 +1165,104 @@ func (m *RegistryDefault) SessionTokenizer() *session.Tokenizer {
 	return m.sessionTokenizer
 }
 
+func (m *RegistryDefault) SessionGeoIPResolver() *session.GeoIPResolver {
+	if m.sessionGeoIP == nil {
+		m.sessionGeoIP = session.NewGeoIPResolver(m)
+	}
+	return m.sessionGeoIP
+}
+
+func (m *RegistryDefault) IdentityDerivedTraitsMapper() *identity.DerivedTraitsMapper {
+	if m.identityDerivedTraitsMapper == nil {
+		m.identityDerivedTraitsMapper = identity.NewDerivedTraitsMapper(m)
+	}
+	return m.identityDerivedTraitsMapper
+}
+
+func (m *RegistryDefault) DisposableEmailBlocker() *identity.DisposableEmailBlocker {
+	if m.disposableEmailBlocker == nil {
+		m.disposableEmailBlocker = identity.NewDisposableEmailBlocker(m)
+	}
+	return m.disposableEmailBlocker
+}
+
+func (m *RegistryDefault) IdentityWebhookSender() *identity.WebhookSender {
+	if m.identityWebhookSender == nil {
+		m.identityWebhookSender = identity.NewWebhookSender(m)
+	}
+	return m.identityWebhookSender
+}
+
+func (m *RegistryDefault) IdentityTraitsEncrypter() *identity.TraitsEncrypter {
+	if m.identityTraitsEncrypter == nil {
+		m.identityTraitsEncrypter = identity.NewTraitsEncrypter(m)
+	}
+	return m.identityTraitsEncrypter
+}
+
+func (m *RegistryDefault) IdentityTraitsKeyWrapper() identity.TraitsKeyWrapper {
+	if m.identityTraitsKeyWrapper == nil {
+		m.identityTraitsKeyWrapper = identity.NewLocalTraitsKeyWrapper(m.Config())
+	}
+	return m.identityTraitsKeyWrapper
+}
+
+func (m *RegistryDefault) FlowFunnelRecorder() *funnel.Recorder {
+	if m.flowFunnelRecorder == nil {
+		m.flowFunnelRecorder = funnel.NewRecorder(m)
+	}
+	return m.flowFunnelRecorder
+}
+
+func (m *RegistryDefault) FlowFunnelHandler() *funnel.Handler {
+	if m.flowFunnelHandler == nil {
+		m.flowFunnelHandler = funnel.NewHandler(m)
+	}
+	return m.flowFunnelHandler
+}
+
+func (m *RegistryDefault) FlowInspectionHandler() *inspect.Handler {
+	if m.flowInspectionHandler == nil {
+		m.flowInspectionHandler = inspect.NewHandler(m)
+	}
+	return m.flowInspectionHandler
+}
+
+func (m *RegistryDefault) FlowSimulationHandler() *simulate.Handler {
+	if m.flowSimulationHandler == nil {
+		m.flowSimulationHandler = simulate.NewHandler(m)
+	}
+	return m.flowSimulationHandler
+}
+
+func (m *RegistryDefault) SSOConnectionHandler() *sso.Handler {
+	if m.ssoConnectionHandler == nil {
+		m.ssoConnectionHandler = sso.NewHandler(m)
+	}
+	return m.ssoConnectionHandler
+}
+
+func (m *RegistryDefault) FailedWebhookEventHandler() *deadletter.Handler {
+	if m.failedWebhookEventHandler == nil {
+		m.failedWebhookEventHandler = deadletter.NewHandler(m)
+	}
+	return m.failedWebhookEventHandler
+}
+
+func (m *RegistryDefault) OIDCTokenVaultHandler() *oidc.TokenVaultHandler {
+	if m.oidcTokenVaultHandler == nil {
+		m.oidcTokenVaultHandler = oidc.NewTokenVaultHandler(m)
+	}
+	return m.oidcTokenVaultHandler
+}
+
+func (m *RegistryDefault) SecretResolver() *secretref.Resolver {
+	if m.secretResolver == nil {
+		m.secretResolver = secretref.NewResolver(m, secretref.DefaultBackends()...)
+	}
+	return m.secretResolver
+}
+
 func (m *RegistryDefault) ExtraHandlers() []x.HandlerRegistrar {
 	if m.extraHandlers == nil {
 		for _, newHandler := range m.extraHandlerFactories {
@@ -43,6 +43,13 @@ func (m *RegistryDefault) HookAddressVerifier() *hook.AddressVerifier {
 	return m.hookAddressVerifier
 }
 
+func (m *RegistryDefault) HookRequiredAddressVerifier() *hook.AddressVerifier {
+	if m.hookRequiredAddressVerifier == nil {
+		m.hookRequiredAddressVerifier = hook.NewRequiredAddressVerifier()
+	}
+	return m.hookRequiredAddressVerifier
+}
+
 func (m *RegistryDefault) HookShowVerificationUI() *hook.ShowVerificationUIHook {
 	if m.hookShowVerificationUI == nil {
 		m.hookShowVerificationUI = hook.NewShowVerificationUIHook(m)
@@ -50,6 +57,13 @@ func (m *RegistryDefault) HookShowVerificationUI() *hook.ShowVerificationUIHook
 	return m.hookShowVerificationUI
 }
 
+func (m *RegistryDefault) HookShowPasswordChangeUI() *hook.ShowPasswordChangeUIHook {
+	if m.hookShowPasswordChangeUI == nil {
+		m.hookShowPasswordChangeUI = hook.NewShowPasswordChangeUIHook(m)
+	}
+	return m.hookShowPasswordChangeUI
+}
+
 func (m *RegistryDefault) HookTwoStepRegistration() *hook.TwoStepRegistration {
 	if m.hookTwoStepRegistration == nil {
 		m.hookTwoStepRegistration = hook.NewTwoStepRegistration(m)
@@ -57,6 +71,13 @@ func (m *RegistryDefault) HookTwoStepRegistration() *hook.TwoStepRegistration {
 	return m.hookTwoStepRegistration
 }
 
+func (m *RegistryDefault) GRPCHookConnections() *hook.GRPCHookConnections {
+	if m.grpcHookConnections == nil {
+		m.grpcHookConnections = hook.NewGRPCHookConnections()
+	}
+	return m.grpcHookConnections
+}
+
 func (m *RegistryDefault) WithHooks(hooks map[string]func(config.SelfServiceHook) interface{}) {
 	m.injectedSelfserviceHooks = hooks
 }
@@ -75,14 +96,24 @@ func (m *RegistryDefault) getHooks(credentialsType string, configs []config.Self
 			i = append(i, m.HookSessionDestroyer())
 		case hook.KeyWebHook:
 			i = append(i, hook.NewWebHook(m, h.Config))
+		case hook.KeyGRPCHook:
+			i = append(i, hook.NewGRPCHook(m, h.Config))
 		case hook.KeyAddressVerifier:
 			i = append(i, m.HookAddressVerifier())
 		case hook.KeyVerificationUI:
 			i = append(i, m.HookShowVerificationUI())
+		case hook.KeyPasskeyEnrollmentUI:
+			i = append(i, hook.NewShowPasskeyEnrollmentUIHook(m, h.Config))
+		case hook.KeyProfileCompletionUI:
+			i = append(i, hook.NewShowProfileCompletionUIHook(m, h.Config))
 		case hook.KeyTwoStepRegistration:
 			i = append(i, m.HookTwoStepRegistration())
 		case hook.KeyVerifier:
 			i = append(i, m.HookVerifier())
+		case hook.KeySessionMetadata:
+			i = append(i, hook.NewSessionMetadataHook(m, h.Config))
+		case hook.KeyLoginNotification:
+			i = append(i, hook.NewLoginNotificationHook(m, h.Config))
 		default:
 			var found bool
 			for name, m := range m.injectedSelfserviceHooks {
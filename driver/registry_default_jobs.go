@@ -0,0 +1,101 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+
+	"github.com/ory/kratos/jobs"
+	"github.com/ory/kratos/persistence/sql"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/contextx"
+	"github.com/ory/x/dbal"
+)
+
+func (m *RegistryDefault) JobLeasePersister() jobs.LeasePersister {
+	return m.Persister()
+}
+
+// JobScheduler returns the scheduler with the built-in jobs registered. Custom jobs may be
+// registered before the scheduler is started.
+func (m *RegistryDefault) JobScheduler() *jobs.Scheduler {
+	if m.jobScheduler == nil {
+		s := jobs.NewScheduler(m)
+		for _, job := range m.builtinJobs() {
+			if err := s.Register(job); err != nil {
+				m.Logger().WithError(err).Fatalf("Unable to register job %q.", job.Name)
+			}
+		}
+		m.jobScheduler = s
+	}
+	return m.jobScheduler
+}
+
+func (m *RegistryDefault) builtinJobs() []jobs.Job {
+	var flowTables []string
+	for _, table := range sql.GarbageCollectedTables() {
+		if table != sql.GarbageCollectSessions {
+			flowTables = append(flowTables, table)
+		}
+	}
+
+	builtin := []jobs.Job{
+		{
+			Name:            "cleanup_expired_flows",
+			DefaultSchedule: "*/15 * * * *",
+			Run: func(ctx context.Context) error {
+				return m.Persister().CollectGarbage(ctx, flowTables...)
+			},
+		},
+		{
+			Name:            "prune_expired_sessions",
+			DefaultSchedule: "0 * * * *",
+			Run: func(ctx context.Context) error {
+				return m.Persister().CollectGarbage(ctx, sql.GarbageCollectSessions)
+			},
+		},
+		{
+			// Dispatches queued messages, including messages whose retry is due, in case no
+			// courier worker is running.
+			Name:            "courier_retries",
+			DefaultSchedule: "@every 1m",
+			Run: func(ctx context.Context) error {
+				if m.Config().IsBackgroundCourierEnabled(ctx) {
+					return nil
+				}
+				c, err := m.Courier(ctx)
+				if err != nil {
+					return err
+				}
+				return c.DispatchQueue(ctx)
+			},
+		},
+		{
+			Name:            "archive_inactive_identities",
+			DefaultSchedule: "0 3 * * *",
+			Run: func(ctx context.Context) error {
+				after := m.Config().IdentityAutoArchiveAfter(ctx)
+				if after <= 0 {
+					return nil
+				}
+				n, err := m.IdentityManager().ArchiveInactiveIdentities(ctx, x.Now().Add(-after))
+				if n > 0 {
+					m.Logger().WithField("identities_archived", n).Info("Archived inactive identities.")
+				}
+				return err
+			},
+		},
+	}
+
+	// One of the few exceptions, this usually should not be hot reloaded.
+	if ctx := contextx.RootContext; m.Config().DatabaseSQLiteJournalMode(ctx) == "wal" && !dbal.IsMemorySQLite(m.Config().DSN(ctx)) {
+		builtin = append(builtin, jobs.Job{
+			Name:            "sqlite_wal_checkpoint",
+			DefaultSchedule: "@every 5m",
+			Run:             m.checkpointSQLite,
+		})
+	}
+
+	return builtin
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
 	"github.com/ory/kratos/selfservice/flow/login"
 )
 
@@ -43,9 +44,19 @@ func (m *RegistryDefault) PostLoginHooks(ctx context.Context, credentialsType id
 			}
 		}
 	}
+
+	if m.Config().SelfServiceFlowVerificationRequiredForLogin(ctx, string(credentialsType)) {
+		// The verified address requirement runs before any other hook so that no side effects
+		// (e.g. web hooks) happen for logins which are rejected.
+		b = append([]login.PostHookExecutor{m.HookRequiredAddressVerifier()}, b...)
+	}
 	return
 }
 
+func (m *RegistryDefault) PasswordChangeLoginHook() login.PostHookExecutor {
+	return m.HookShowPasswordChangeUI()
+}
+
 func (m *RegistryDefault) LoginHandler() *login.Handler {
 	if m.selfserviceLoginHandler == nil {
 		m.selfserviceLoginHandler = login.NewHandler(m)
@@ -61,3 +72,11 @@ func (m *RegistryDefault) LoginFlowErrorHandler() *login.ErrorHandler {
 
 	return m.selfserviceLoginRequestErrorHandler
 }
+
+func (m *RegistryDefault) CrossDeviceLoginHandler() *crossdevice.Handler {
+	if m.selfserviceCrossDeviceLoginHandler == nil {
+		m.selfserviceCrossDeviceLoginHandler = crossdevice.NewHandler(m)
+	}
+
+	return m.selfserviceCrossDeviceLoginHandler
+}
@@ -0,0 +1,71 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package driver
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ory/kratos/persistence/sql"
+	"github.com/ory/x/dbal"
+)
+
+// sqliteDSN adds the connection parameters of `database.sqlite` to SQLite DSNs. The parameters
+// are applied by the driver to every connection of the pool.
+func (m *RegistryDefault) sqliteDSN(ctx context.Context, dsn string) string {
+	if !dbal.IsSQLite(dsn) {
+		return dsn
+	}
+
+	base, rawQuery, _ := strings.Cut(dsn, "?")
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		m.Logger().WithError(err).Warn("Unable to parse the SQLite DSN query, the SQLite settings are not applied.")
+		return dsn
+	}
+
+	c := m.Config()
+	if mode := c.DatabaseSQLiteJournalMode(ctx); mode != "" {
+		q.Set("_journal_mode", strings.ToUpper(mode))
+	}
+	if synchronous := c.DatabaseSQLiteSynchronous(ctx); synchronous != "" {
+		q.Set("_synchronous", strings.ToUpper(synchronous))
+	}
+	if timeout := c.DatabaseSQLiteBusyTimeout(ctx); timeout > 0 {
+		q.Set("_busy_timeout", strconv.FormatInt(timeout.Milliseconds(), 10))
+	}
+	if lock := c.DatabaseSQLiteTransactionLock(ctx); lock != "" {
+		q.Set("_txlock", lock)
+	}
+
+	return base + "?" + q.Encode()
+}
+
+// checkpointSQLite checkpoints the write-ahead log and notifies the checkpoint hooks.
+func (m *RegistryDefault) checkpointSQLite(ctx context.Context) error {
+	for _, h := range m.sqliteCheckpointHooks {
+		if err := h.BeforeSQLiteCheckpoint(ctx); err != nil {
+			return err
+		}
+	}
+
+	checkpoint, err := sql.CheckpointSQLite(ctx, m.Persister().GetConnection(ctx), m.Config().DatabaseSQLiteCheckpointMode(ctx))
+	if err != nil {
+		return err
+	}
+	m.Logger().
+		WithField("busy", checkpoint.Busy).
+		WithField("log_frames", checkpoint.LogFrames).
+		WithField("checkpointed_frames", checkpoint.CheckpointedFrames).
+		Debug("Checkpointed the SQLite write-ahead log.")
+
+	for _, h := range m.sqliteCheckpointHooks {
+		if err := h.AfterSQLiteCheckpoint(ctx, checkpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package driver_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/persistence/sql"
+	"github.com/ory/x/configx"
+	"github.com/ory/x/servicelocatorx"
+)
+
+type checkpointHook struct {
+	before, after atomic.Int32
+	last          atomic.Pointer[sql.SQLiteCheckpoint]
+}
+
+func (h *checkpointHook) BeforeSQLiteCheckpoint(context.Context) error {
+	h.before.Add(1)
+	return nil
+}
+
+func (h *checkpointHook) AfterSQLiteCheckpoint(_ context.Context, checkpoint *sql.SQLiteCheckpoint) error {
+	h.last.Store(checkpoint)
+	h.after.Add(1)
+	return nil
+}
+
+func TestRegistryDefault_SQLite(t *testing.T) {
+	ctx := context.Background()
+	hook := new(checkpointHook)
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "db.sqlite") + "?_fk=true"
+
+	r, err := driver.New(ctx, os.Stderr, servicelocatorx.NewOptions(),
+		[]driver.RegistryOption{driver.SkipNetworkInit, driver.WithSQLiteCheckpointHooks(hook)},
+		[]configx.OptionModifier{
+			configx.WithValues(map[string]any{
+				config.ViperKeyDSN:                                      dsn,
+				config.ViperKeyDatabaseSQLiteJournalMode:                "wal",
+				config.ViperKeyDatabaseSQLiteSynchronous:                "normal",
+				config.ViperKeyDatabaseSQLiteBusyTimeout:                "7s",
+				config.ViperKeyDatabaseSQLiteTransactionLock:            "immediate",
+				config.ViperKeyDatabaseSQLiteCheckpointMode:             "truncate",
+				config.ViperKeyDatabaseSQLiteEmbedded:                   true,
+				config.ViperKeyJobsSchedules + ".sqlite_wal_checkpoint": "@every 1s",
+			}),
+			configx.SkipValidation(),
+		})
+	require.NoError(t, err)
+
+	t.Run("case=connection parameters are applied", func(t *testing.T) {
+		db := r.Persister().GetConnection(ctx).Store.SQLDB()
+
+		var journalMode string
+		require.NoError(t, db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode))
+		assert.Equal(t, "wal", journalMode)
+
+		var synchronous int
+		require.NoError(t, db.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&synchronous))
+		assert.Equal(t, 1, synchronous, "normal")
+
+		var busyTimeout int
+		require.NoError(t, db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout))
+		assert.Equal(t, 7000, busyTimeout)
+	})
+
+	t.Run("case=embedded mode runs the courier and jobs", func(t *testing.T) {
+		assert.True(t, r.Config().IsBackgroundCourierEnabled(ctx))
+		assert.True(t, r.Config().JobsEnabled(ctx))
+	})
+
+	t.Run("case=write-ahead log is checkpointed", func(t *testing.T) {
+		assert.Contains(t, r.JobScheduler().Jobs(), "sqlite_wal_checkpoint")
+
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- r.JobScheduler().Start(ctx) }()
+
+		// The scheduler does not need the lease table in embedded mode.
+		require.Eventually(t, r.JobScheduler().IsLeader, 5*time.Second, 10*time.Millisecond)
+		require.Eventually(t, func() bool { return hook.after.Load() >= 1 }, 5*time.Second, 50*time.Millisecond)
+		assert.GreaterOrEqual(t, hook.before.Load(), hook.after.Load())
+		assert.False(t, hook.last.Load().Busy)
+
+		cancel()
+		require.NoError(t, <-done)
+	})
+}
+
+func TestSQLiteCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	r, err := driver.New(ctx, os.Stderr, servicelocatorx.NewOptions(),
+		[]driver.RegistryOption{driver.SkipNetworkInit},
+		[]configx.OptionModifier{
+			configx.WithValue(config.ViperKeyDSN, "sqlite://"+filepath.Join(t.TempDir(), "db.sqlite")+"?_fk=true"),
+			configx.SkipValidation(),
+		})
+	require.NoError(t, err)
+
+	_, err = sql.CheckpointSQLite(ctx, r.Persister().GetConnection(ctx), "invalid")
+	assert.Error(t, err)
+
+	assert.NotContains(t, r.JobScheduler().Jobs(), "sqlite_wal_checkpoint", "the job is only registered in WAL mode")
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"testing"
 
 	confighelpers "github.com/ory/kratos/driver/config/testhelpers"
@@ -22,16 +23,24 @@ import (
 	"github.com/ory/x/configx"
 	"github.com/ory/x/logrusx"
 
+	promclient "github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/ory/kratos/courier"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/persistence/sql"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/flow/settings"
 	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+	"github.com/ory/kratos/x/audit"
 )
 
 func TestDriverDefault_Hooks(t *testing.T) {
@@ -449,6 +458,26 @@ func TestDriverDefault_Hooks(t *testing.T) {
 					}
 				},
 			},
+			{
+				uc: "Verified address is required for password logins",
+				config: map[string]any{
+					config.ViperKeySelfServiceVerificationRequiredForLogin: []string{"password"},
+					config.ViperKeySelfServiceLoginAfter + ".password.hooks": []map[string]any{
+						{"hook": "revoke_active_sessions"},
+					},
+				},
+				expect: func(reg *driver.RegistryDefault) []login.PostHookExecutor {
+					return []login.PostHookExecutor{
+						hook.NewRequiredAddressVerifier(),
+						hook.NewSessionDestroyer(reg),
+					}
+				},
+			},
+			{
+				uc:     "Verified address is required for other login methods only",
+				config: map[string]any{config.ViperKeySelfServiceVerificationRequiredForLogin: []string{"oidc", "code"}},
+				expect: func(reg *driver.RegistryDefault) []login.PostHookExecutor { return nil },
+			},
 			{
 				uc: "Two web_hooks are configured on a global level",
 				config: map[string]any{
@@ -872,7 +901,7 @@ func TestDefaultRegistry_AllStrategies(t *testing.T) {
 	_, reg := internal.NewVeryFastRegistryWithoutDB(t)
 
 	t.Run("case=all login strategies", func(t *testing.T) {
-		expects := []string{"password", "oidc", "code", "totp", "passkey", "webauthn", "lookup_secret", "identifier_first"}
+		expects := []string{"password", "oidc", "code", "totp", "passkey", "webauthn", "lookup_secret", "device_key", "external_mfa", "identifier_first"}
 		s := reg.AllLoginStrategies()
 		require.Len(t, s, len(expects))
 		for k, e := range expects {
@@ -890,7 +919,7 @@ func TestDefaultRegistry_AllStrategies(t *testing.T) {
 	})
 
 	t.Run("case=all settings strategies", func(t *testing.T) {
-		expects := []string{"password", "oidc", "profile", "totp", "passkey", "webauthn", "lookup_secret"}
+		expects := []string{"password", "oidc", "profile", "totp", "passkey", "webauthn", "lookup_secret", "device_key"}
 		s := reg.AllSettingsStrategies()
 		require.Len(t, s, len(expects))
 		for k, e := range expects {
@@ -971,3 +1000,38 @@ func TestGetActiveVerificationStrategy(t *testing.T) {
 		}
 	})
 }
+
+func TestDefaultRegistry_PrometheusManager(t *testing.T) {
+	ctx := context.Background()
+	_, reg := internal.NewVeryFastRegistryWithoutDB(t)
+	_, other := internal.NewVeryFastRegistryWithoutDB(t)
+
+	require.NotNil(t, reg.PrometheusManager())
+	require.NotNil(t, other.PrometheusManager(), "a second registry must not fail to register the metrics again")
+
+	reg.Config().ReportCompatibilitySwitches(ctx)
+	families, err := promclient.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	names := make([]string, len(families))
+	for k, f := range families {
+		names[k] = f.GetName()
+	}
+	assert.Contains(t, names, "kratos_compatibility_switch_enabled")
+
+	for _, c := range slices.Concat(
+		flow.EventCollectors(),
+		hook.WebHookCollectors(),
+		courier.DispatcherCollectors(),
+		session.MFAEnrollmentCollectors(),
+		funnel.RecorderCollectors(),
+		flow.TracingCollectors(),
+		audit.SinkCollectors(),
+		hook.CircuitBreakerCollectors(),
+		sql.CleanupCollectors(),
+		session.ConcurrencyCollectors(),
+		x.LoadSheddingCollectors(),
+	) {
+		assert.ErrorAs(t, promclient.Register(c), new(promclient.AlreadyRegisteredError), "%T must be registered by the registry", c)
+	}
+}
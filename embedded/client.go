@@ -0,0 +1,164 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+// Package embedded executes self-service flows in-process, so that Go applications can embed Ory
+// Kratos as a library instead of running it as a separate service.
+//
+// The client executes native (API) flows, which are authenticated using session tokens. The flows
+// are executed by the same strategies and hooks as the public API, but are called directly and
+// never pass through an HTTP server.
+package embedded
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+)
+
+type (
+	// Client executes self-service flows in-process.
+	Client struct {
+		r driver.Registry
+	}
+
+	// LoginFlowOptions configure a new login flow.
+	LoginFlowOptions struct {
+		// Refresh forces the identity to authenticate again even if the session is still valid.
+		Refresh bool
+		// AAL requests a specific authenticator assurance level.
+		AAL identity.AuthenticatorAssuranceLevel
+		// SessionToken is the token of the session to refresh or upgrade.
+		SessionToken string
+	}
+
+	// LoginResult is the result of submitting a login flow. If the submission was invalid or the
+	// method requires another step, Flow contains the messages and Session is nil.
+	LoginResult struct {
+		Flow         *login.Flow
+		Session      *session.Session
+		SessionToken string
+	}
+
+	// RegistrationResult is the result of submitting a registration flow. If the submission was
+	// invalid or the method requires another step, Flow contains the messages and Identity is nil.
+	// Session is only set if the session hook is configured for the registration method.
+	RegistrationResult struct {
+		Flow         *registration.Flow
+		Identity     *identity.Identity
+		Session      *session.Session
+		SessionToken string
+	}
+
+	// SettingsResult is the result of submitting a settings flow. Flow contains the updated
+	// identity, or the validation messages if the identity was not updated.
+	SettingsResult struct {
+		Flow    *settings.Flow
+		Updated bool
+	}
+)
+
+// NewClient returns a client which executes the flows of the registry. The registry must be
+// initialized, but its HTTP servers do not need to run.
+func NewClient(r driver.Registry) *Client {
+	return &Client{r: r}
+}
+
+// CreateLoginFlow initializes a native login flow.
+func (c *Client) CreateLoginFlow(ctx context.Context, opts LoginFlowOptions) (*login.Flow, error) {
+	return c.r.LoginHandler().CreateNativeFlow(c.context(ctx), login.NativeFlowParameters{
+		Refresh:      opts.Refresh,
+		RequestedAAL: opts.AAL,
+		SessionToken: opts.SessionToken,
+	})
+}
+
+// UpdateLoginFlow submits the login flow with the login method.
+func (c *Client) UpdateLoginFlow(ctx context.Context, flowID uuid.UUID, sessionToken string, body LoginMethod) (*LoginResult, error) {
+	payload, err := encodeMethod(body.loginMethod(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, f, err := c.r.LoginHandler().UpdateNativeFlow(c.context(ctx), flowID, sessionToken, payload)
+	if err != nil {
+		return nil, err
+	} else if f != nil {
+		return &LoginResult{Flow: f}, nil
+	}
+	return &LoginResult{Session: res.Session, SessionToken: res.Token}, nil
+}
+
+// CreateRegistrationFlow initializes a native registration flow.
+func (c *Client) CreateRegistrationFlow(ctx context.Context) (*registration.Flow, error) {
+	return c.r.RegistrationHandler().CreateNativeFlow(c.context(ctx))
+}
+
+// UpdateRegistrationFlow submits the registration flow with the registration method.
+func (c *Client) UpdateRegistrationFlow(ctx context.Context, flowID uuid.UUID, body RegistrationMethod) (*RegistrationResult, error) {
+	payload, err := encodeMethod(body.registrationMethod(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, f, err := c.r.RegistrationHandler().UpdateNativeFlow(c.context(ctx), flowID, payload)
+	if err != nil {
+		return nil, err
+	} else if f != nil {
+		return &RegistrationResult{Flow: f}, nil
+	}
+	return &RegistrationResult{Identity: res.Identity, Session: res.Session, SessionToken: res.Token}, nil
+}
+
+// CreateSettingsFlow initializes a native settings flow for the session.
+func (c *Client) CreateSettingsFlow(ctx context.Context, sessionToken string) (*settings.Flow, error) {
+	return c.r.SettingsHandler().CreateNativeFlow(c.context(ctx), sessionToken)
+}
+
+// UpdateSettingsFlow submits the settings flow with the settings method.
+func (c *Client) UpdateSettingsFlow(ctx context.Context, flowID uuid.UUID, sessionToken string, body SettingsMethod) (*SettingsResult, error) {
+	payload, err := encodeMethod(body.settingsMethod(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	f, updated, err := c.r.SettingsHandler().UpdateNativeFlow(c.context(ctx), flowID, sessionToken, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &SettingsResult{Flow: f, Updated: updated}, nil
+}
+
+// Whoami returns the session of the session token.
+func (c *Client) Whoami(ctx context.Context, sessionToken string) (*session.Session, error) {
+	return c.r.SessionHandler().Whoami(c.context(ctx), sessionToken)
+}
+
+// context adds the HTTP clients which the public API adds to every request.
+func (c *Client) context(ctx context.Context) context.Context {
+	return x.WithHTTPLoaderContext(ctx, c.r)
+}
+
+// encodeMethod encodes the body of a method as the JSON payload which the strategies decode.
+func encodeMethod(method string, body any) (json.RawMessage, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	payload, err = sjson.SetBytes(payload, "method", method)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,151 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/embedded"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/x"
+)
+
+var schema = []byte(`{
+  "$id": "https://example.com/person.schema.json",
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "traits": {
+      "type": "object",
+      "properties": {
+        "email": {
+          "type": "string",
+          "format": "email",
+          "ory.sh/kratos": {
+            "credentials": {
+              "password": {
+                "identifier": true
+              }
+            }
+          }
+        },
+        "website": {
+          "type": "string"
+        }
+      },
+      "required": ["email"]
+    }
+  },
+  "additionalProperties": false
+}`)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchemaFromRaw(conf, schema)
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypePassword), map[string]interface{}{"enabled": true})
+	conf.MustSet(ctx, config.HookStrategyKey(config.ViperKeySelfServiceRegistrationAfter, identity.CredentialsTypePassword.String()), []config.SelfServiceHook{{Name: "session"}})
+
+	c := embedded.NewClient(reg)
+	email := x.NewUUID().String() + "@ory.sh"
+	password := x.NewUUID().String()
+
+	var sessionToken string
+	t.Run("case=registers an identity", func(t *testing.T) {
+		f, err := c.CreateRegistrationFlow(ctx)
+		require.NoError(t, err)
+
+		t.Run("case=invalid submission returns the flow", func(t *testing.T) {
+			res, err := c.UpdateRegistrationFlow(ctx, f.ID, embedded.PasswordRegistration{
+				Traits:   identity.Traits(`{"email":"not-an-email"}`),
+				Password: password,
+			})
+			require.NoError(t, err)
+			require.NotNil(t, res.Flow)
+			assert.Nil(t, res.Identity)
+			assert.Contains(t, gjson.Get(x.MustEncodeJSON(t, res.Flow.UI), "nodes.#(attributes.name==traits.email).messages.0.text").String(), "is not valid")
+		})
+
+		res, err := c.UpdateRegistrationFlow(ctx, f.ID, embedded.PasswordRegistration{
+			Traits:   identity.Traits(`{"email":"` + email + `"}`),
+			Password: password,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, res.Identity)
+		assert.Equal(t, email, gjson.GetBytes(res.Identity.Traits, "email").String())
+		require.NotNil(t, res.Session)
+		assert.NotEmpty(t, res.SessionToken)
+	})
+
+	t.Run("case=logs in", func(t *testing.T) {
+		f, err := c.CreateLoginFlow(ctx, embedded.LoginFlowOptions{})
+		require.NoError(t, err)
+
+		res, err := c.UpdateLoginFlow(ctx, f.ID, "", embedded.PasswordLogin{
+			Identifier: email,
+			Password:   "wrong-password",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, res.Flow)
+		assert.Nil(t, res.Session)
+
+		res, err = c.UpdateLoginFlow(ctx, f.ID, "", embedded.PasswordLogin{
+			Identifier: email,
+			Password:   password,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, res.Session)
+		require.NotEmpty(t, res.SessionToken)
+		sessionToken = res.SessionToken
+	})
+
+	t.Run("case=returns the session", func(t *testing.T) {
+		s, err := c.Whoami(ctx, sessionToken)
+		require.NoError(t, err)
+		assert.Equal(t, email, gjson.GetBytes(s.Identity.Traits, "email").String())
+
+		_, err = c.Whoami(ctx, "invalid-token")
+		require.Error(t, err)
+		var e *herodot.DefaultError
+		require.ErrorAs(t, err, &e)
+		assert.Equal(t, http.StatusUnauthorized, e.StatusCode())
+	})
+
+	t.Run("case=updates the profile", func(t *testing.T) {
+		f, err := c.CreateSettingsFlow(ctx, sessionToken)
+		require.NoError(t, err)
+
+		t.Run("case=invalid submission returns the flow", func(t *testing.T) {
+			res, err := c.UpdateSettingsFlow(ctx, f.ID, sessionToken, embedded.ProfileSettings{
+				Traits: identity.Traits(`{"email":"not-an-email"}`),
+			})
+			require.NoError(t, err)
+			assert.False(t, res.Updated)
+			assert.Contains(t, gjson.Get(x.MustEncodeJSON(t, res.Flow.UI), "nodes.#(attributes.name==traits.email).messages.0.text").String(), "is not valid")
+		})
+
+		res, err := c.UpdateSettingsFlow(ctx, f.ID, sessionToken, embedded.ProfileSettings{
+			Traits: identity.Traits(`{"email":"` + email + `","website":"https://www.ory.sh"}`),
+		})
+		require.NoError(t, err)
+		assert.True(t, res.Updated)
+		assert.Equal(t, "https://www.ory.sh", gjson.GetBytes(res.Flow.Identity.Traits, "website").String())
+	})
+
+	t.Run("case=unknown flow is an error", func(t *testing.T) {
+		_, err := c.UpdateLoginFlow(ctx, uuid.Must(uuid.NewV4()), "", embedded.PasswordLogin{})
+		require.Error(t, err)
+	})
+}
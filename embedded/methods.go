@@ -0,0 +1,103 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow/settings"
+)
+
+type (
+	// LoginMethod is the payload of a login method.
+	LoginMethod interface {
+		loginMethod() string
+	}
+
+	// PasswordLogin signs in with an identifier and a password.
+	PasswordLogin struct {
+		Identifier string `json:"identifier"`
+		Password   string `json:"password"`
+	}
+
+	// CodeLogin signs in with a one-time code. Without a code, the code is sent to the identifier.
+	CodeLogin struct {
+		Identifier string `json:"identifier,omitempty"`
+		Code       string `json:"code,omitempty"`
+	}
+
+	// TOTPLogin completes the second factor with a TOTP code.
+	TOTPLogin struct {
+		Code string `json:"totp_code"`
+	}
+
+	// LookupSecretLogin completes the second factor with a lookup secret.
+	LookupSecretLogin struct {
+		Secret string `json:"lookup_secret"`
+	}
+
+	// RegistrationMethod is the payload of a registration method.
+	RegistrationMethod interface {
+		registrationMethod() string
+	}
+
+	// PasswordRegistration signs up with traits and a password.
+	PasswordRegistration struct {
+		Traits   identity.Traits `json:"traits"`
+		Password string          `json:"password"`
+	}
+
+	// CodeRegistration signs up with traits and a one-time code. Without a code, the code is sent
+	// to the address in the traits.
+	CodeRegistration struct {
+		Traits identity.Traits `json:"traits"`
+		Code   string          `json:"code,omitempty"`
+	}
+
+	// SettingsMethod is the payload of a settings method.
+	SettingsMethod interface {
+		settingsMethod() string
+	}
+
+	// ProfileSettings updates the traits of the identity.
+	ProfileSettings struct {
+		Traits identity.Traits `json:"traits"`
+	}
+
+	// PasswordSettings sets a new password.
+	PasswordSettings struct {
+		Password string `json:"password"`
+	}
+
+	// TOTPSettings links a TOTP app with a code, or unlinks it.
+	TOTPSettings struct {
+		Code   string `json:"totp_code,omitempty"`
+		Unlink bool   `json:"totp_unlink,omitempty"`
+	}
+
+	// LookupSecretSettings manages the lookup secrets of the identity.
+	LookupSecretSettings struct {
+		Reveal     bool `json:"lookup_secret_reveal,omitempty"`
+		Regenerate bool `json:"lookup_secret_regenerate,omitempty"`
+		Confirm    bool `json:"lookup_secret_confirm,omitempty"`
+		Disable    bool `json:"lookup_secret_disable,omitempty"`
+	}
+)
+
+func (PasswordLogin) loginMethod() string     { return identity.CredentialsTypePassword.String() }
+func (CodeLogin) loginMethod() string         { return identity.CredentialsTypeCodeAuth.String() }
+func (TOTPLogin) loginMethod() string         { return identity.CredentialsTypeTOTP.String() }
+func (LookupSecretLogin) loginMethod() string { return identity.CredentialsTypeLookup.String() }
+
+func (PasswordRegistration) registrationMethod() string {
+	return identity.CredentialsTypePassword.String()
+}
+
+func (CodeRegistration) registrationMethod() string {
+	return identity.CredentialsTypeCodeAuth.String()
+}
+
+func (ProfileSettings) settingsMethod() string      { return settings.StrategyProfile }
+func (PasswordSettings) settingsMethod() string     { return identity.CredentialsTypePassword.String() }
+func (TOTPSettings) settingsMethod() string         { return identity.CredentialsTypeTOTP.String() }
+func (LookupSecretSettings) settingsMethod() string { return identity.CredentialsTypeLookup.String() }
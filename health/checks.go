@@ -0,0 +1,126 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/kratos/courier"
+	"github.com/ory/x/pagination/keysetpagination"
+)
+
+func (h *Handler) checkDatabase(ctx context.Context) (map[string]any, error) {
+	if err := h.d.Persister().Ping(ctx); err != nil {
+		return nil, err
+	}
+
+	maxLag := h.d.Config().HealthReadinessDatabaseMaxReplicationLag(ctx)
+	if maxLag == 0 {
+		return nil, nil
+	}
+
+	lag, err := h.d.Persister().ReplicationLag(ctx)
+	if err != nil {
+		return nil, err
+	}
+	details := map[string]any{"replication_lag": lag.String()}
+	if lag > maxLag {
+		return details, errors.Errorf("the replication lag of %s exceeds the maximum of %s", lag, maxLag)
+	}
+	return details, nil
+}
+
+// checkSMTP connects to the SMTP servers of all courier channels. Servers using plain SMTP
+// must greet the client. Servers using implicit TLS must accept the connection.
+func (h *Handler) checkSMTP(ctx context.Context) (map[string]any, error) {
+	channels, err := h.d.Config().CourierChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, channel := range channels {
+		if channel.SMTPConfig == nil || channel.SMTPConfig.ConnectionURI == "" {
+			continue
+		}
+
+		uri, err := url.Parse(channel.SMTPConfig.ConnectionURI)
+		if err != nil {
+			return nil, errors.Errorf("the SMTP connection URI of channel %q is malformed", channel.ID)
+		}
+		if err := dialSMTP(ctx, uri); err != nil {
+			return nil, errors.Wrapf(err, "unable to reach the SMTP server of channel %q", channel.ID)
+		}
+	}
+	return nil, nil
+}
+
+func dialSMTP(ctx context.Context, uri *url.URL) error {
+	port := uri.Port()
+	if port == "" {
+		port = "25"
+		if uri.Scheme == "smtps" {
+			port = "465"
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(uri.Hostname(), port))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	if uri.Scheme == "smtps" {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if _, _, err := textproto.NewReader(bufio.NewReader(conn)).ReadResponse(220); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (h *Handler) checkCourierBacklog(ctx context.Context) (map[string]any, error) {
+	queued := courier.MessageStatusQueued
+	_, count, _, err := h.d.CourierPersister().ListMessages(ctx,
+		courier.ListCourierMessagesParameters{Status: &queued},
+		[]keysetpagination.Option{keysetpagination.WithSize(1)})
+	if err != nil {
+		return nil, err
+	}
+
+	maxMessages := h.d.Config().HealthReadinessCourierBacklogMaxMessages(ctx)
+	details := map[string]any{"queued_messages": count}
+	if count > maxMessages {
+		return details, errors.Errorf("%d messages are queued which exceeds the maximum of %d", count, maxMessages)
+	}
+	return details, nil
+}
+
+// checkIdentitySchemas loads and compiles all identity schemas.
+func (h *Handler) checkIdentitySchemas(ctx context.Context) (map[string]any, error) {
+	schemas, err := h.d.IdentityTraitsSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range schemas.List(0, schemas.Total()) {
+		if _, err := jsonschema.NewCompiler().Compile(ctx, s.URL.String()); err != nil {
+			return nil, errors.Wrapf(err, "unable to load identity schema %q", s.ID)
+		}
+	}
+	return map[string]any{"schemas": schemas.Total()}, nil
+}
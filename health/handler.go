@@ -0,0 +1,167 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/persistence"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/healthx"
+)
+
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+
+	obfuscatedError = "error may contain sensitive information and was obfuscated"
+)
+
+type (
+	handlerDependencies interface {
+		config.Provider
+		x.WriterProvider
+		persistence.Provider
+		courier.PersistenceProvider
+		schema.IdentitySchemaProvider
+	}
+	HandlerProvider interface {
+		ReadinessHandler() *Handler
+	}
+	// Handler serves the readiness endpoints. Next to the checks every instance runs, it runs
+	// the checks of downstream dependencies which are enabled in the configuration.
+	Handler struct {
+		d      handlerDependencies
+		checks healthx.ReadyCheckers
+	}
+
+	// checker returns an error if the dependency is not ready. The details are reported
+	// alongside the status of the check.
+	checker func(ctx context.Context) (details map[string]any, err error)
+
+	// Readiness Status
+	//
+	// swagger:ignore
+	readinessStatus struct {
+		// Status is "ok" if all checks succeeded and "error" otherwise.
+		Status string `json:"status"`
+
+		// Checks contains the result of every check by name.
+		Checks map[string]checkStatus `json:"checks"`
+
+		// Errors contains the errors of the failed checks by name. It is kept for compatibility
+		// with the previous format of the response.
+		Errors map[string]string `json:"errors,omitempty"`
+	}
+	checkStatus struct {
+		Status   string         `json:"status"`
+		Error    string         `json:"error,omitempty"`
+		Duration string         `json:"duration"`
+		Details  map[string]any `json:"details,omitempty"`
+	}
+)
+
+// NewHandler returns a readiness handler which runs the given checks on every request.
+func NewHandler(d handlerDependencies, checks healthx.ReadyCheckers) *Handler {
+	return &Handler{d: d, checks: checks}
+}
+
+// Ready returns status 200 if all checks succeed and status 503 otherwise. Errors and details
+// are only included in the response if shareErrors is true.
+func (h *Handler) Ready(shareErrors bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checkers := h.checkers(r)
+		status := readinessStatus{
+			Status: StatusOK,
+			Checks: make(map[string]checkStatus, len(checkers)),
+		}
+
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		timeout := h.d.Config().HealthReadinessTimeout(r.Context())
+		for name, check := range checkers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result := run(r.Context(), timeout, check)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if result.Status == StatusError {
+					status.Status = StatusError
+					if status.Errors == nil {
+						status.Errors = map[string]string{}
+					}
+					if !shareErrors {
+						result.Error = obfuscatedError
+					}
+					status.Errors[name] = result.Error
+				}
+				if !shareErrors {
+					result.Details = nil
+				}
+				status.Checks[name] = result
+			}()
+		}
+		wg.Wait()
+
+		if status.Status != StatusOK {
+			h.d.Writer().WriteCode(w, r, http.StatusServiceUnavailable, &status)
+			return
+		}
+		h.d.Writer().Write(w, r, &status)
+	})
+}
+
+// checkers returns the checks which are enabled for the request.
+func (h *Handler) checkers(r *http.Request) map[string]checker {
+	ctx := r.Context()
+	conf := h.d.Config()
+
+	checkers := make(map[string]checker, len(h.checks)+4)
+	for name, check := range h.checks {
+		checkers[name] = func(ctx context.Context) (map[string]any, error) {
+			return nil, check(r.WithContext(ctx))
+		}
+	}
+	if conf.HealthReadinessDatabaseEnabled(ctx) {
+		checkers["database"] = h.checkDatabase
+	}
+	if conf.HealthReadinessSMTPEnabled(ctx) {
+		checkers["smtp"] = h.checkSMTP
+	}
+	if conf.HealthReadinessCourierBacklogEnabled(ctx) {
+		checkers["courier_backlog"] = h.checkCourierBacklog
+	}
+	if conf.HealthReadinessIdentitySchemasEnabled(ctx) {
+		checkers["identity_schemas"] = h.checkIdentitySchemas
+	}
+	return checkers
+}
+
+func run(ctx context.Context, timeout time.Duration, check checker) checkStatus {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check(ctx)
+	result := checkStatus{
+		Status:   StatusOK,
+		Duration: time.Since(start).String(),
+		Details:  details,
+	}
+	if err != nil {
+		result.Status = StatusError
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,161 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package health_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/x"
+)
+
+func TestReady(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://../test/e2e/profiles/email/identity.traits.schema.json")
+
+	admin := httptest.NewServer(reg.ReadinessHandler().Ready(true))
+	t.Cleanup(admin.Close)
+	public := httptest.NewServer(reg.ReadinessHandler().Ready(false))
+	t.Cleanup(public.Close)
+
+	ready := func(t *testing.T, s *httptest.Server, expectCode int) gjson.Result {
+		t.Helper()
+		res, err := s.Client().Get(s.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=runs the default checks", func(t *testing.T) {
+		body := ready(t, admin, http.StatusOK)
+		assert.Equal(t, "ok", body.Get("status").String())
+		assert.Equal(t, "ok", body.Get("checks.database.status").String(), "%s", body)
+		assert.Equal(t, "ok", body.Get("checks.migrations.status").String(), "%s", body)
+		assert.False(t, body.Get("checks.smtp").Exists(), "%s", body)
+		assert.False(t, body.Get("checks.courier_backlog").Exists(), "%s", body)
+		assert.False(t, body.Get("checks.identity_schemas").Exists(), "%s", body)
+		assert.False(t, body.Get("errors").Exists(), "%s", body)
+	})
+
+	t.Run("case=database check can be disabled", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyHealthReadinessDatabaseEnabled, false)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyHealthReadinessDatabaseEnabled, true)
+		})
+
+		body := ready(t, admin, http.StatusOK)
+		assert.False(t, body.Get("checks.database").Exists(), "%s", body)
+		assert.True(t, body.Get("checks.migrations").Exists(), "%s", body)
+	})
+
+	t.Run("case=database check reports the replication lag", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyHealthReadinessDatabaseMaxReplicationLag, "30s")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyHealthReadinessDatabaseMaxReplicationLag, nil)
+		})
+
+		body := ready(t, admin, http.StatusOK)
+		assert.Equal(t, "0s", body.Get("checks.database.details.replication_lag").String(), "%s", body)
+	})
+
+	t.Run("case=courier backlog", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyHealthReadinessCourierBacklogEnabled, true)
+		conf.MustSet(ctx, config.ViperKeyHealthReadinessCourierBacklogMaxMessages, 0)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyHealthReadinessCourierBacklogEnabled, false)
+			conf.MustSet(ctx, config.ViperKeyHealthReadinessCourierBacklogMaxMessages, nil)
+		})
+
+		body := ready(t, admin, http.StatusOK)
+		assert.Equal(t, "ok", body.Get("checks.courier_backlog.status").String(), "%s", body)
+		assert.EqualValues(t, 0, body.Get("checks.courier_backlog.details.queued_messages").Int(), "%s", body)
+
+		require.NoError(t, reg.CourierPersister().AddMessage(ctx, &courier.Message{
+			ID:        x.NewUUID(),
+			Type:      courier.MessageTypeEmail,
+			Channel:   "email",
+			Status:    courier.MessageStatusQueued,
+			Recipient: "health@example.com",
+			Subject:   "health",
+			Body:      "health",
+		}))
+
+		t.Run("case=shares errors on the admin endpoint", func(t *testing.T) {
+			body := ready(t, admin, http.StatusServiceUnavailable)
+			assert.Equal(t, "error", body.Get("status").String())
+			assert.Equal(t, "error", body.Get("checks.courier_backlog.status").String(), "%s", body)
+			assert.EqualValues(t, 1, body.Get("checks.courier_backlog.details.queued_messages").Int(), "%s", body)
+			assert.Contains(t, body.Get("errors.courier_backlog").String(), "exceeds the maximum of 0", "%s", body)
+			assert.Equal(t, "ok", body.Get("checks.database.status").String(), "%s", body)
+		})
+
+		t.Run("case=obfuscates errors on the public endpoint", func(t *testing.T) {
+			body := ready(t, public, http.StatusServiceUnavailable)
+			assert.Equal(t, "error", body.Get("checks.courier_backlog.status").String(), "%s", body)
+			assert.NotContains(t, body.Raw, "exceeds the maximum")
+			assert.False(t, body.Get("checks.courier_backlog.details").Exists(), "%s", body)
+			assert.True(t, body.Get("errors.courier_backlog").Exists(), "%s", body)
+		})
+	})
+
+	t.Run("case=smtp", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = l.Close() })
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				_, _ = conn.Write([]byte("220 localhost ESMTP ready\r\n"))
+				_ = conn.Close()
+			}
+		}()
+
+		conf.MustSet(ctx, config.ViperKeyHealthReadinessSMTPEnabled, true)
+		conf.MustSet(ctx, config.ViperKeyCourierSMTPURL, "smtp://"+l.Addr().String()+"/?disable_starttls=true")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyHealthReadinessSMTPEnabled, false)
+		})
+
+		body := ready(t, admin, http.StatusOK)
+		assert.Equal(t, "ok", body.Get("checks.smtp.status").String(), "%s", body)
+
+		require.NoError(t, l.Close())
+		body = ready(t, admin, http.StatusServiceUnavailable)
+		assert.Contains(t, body.Get("checks.smtp.error").String(), `unable to reach the SMTP server of channel "email"`, "%s", body)
+	})
+
+	t.Run("case=identity schemas", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyHealthReadinessIdentitySchemasEnabled, true)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyHealthReadinessIdentitySchemasEnabled, false)
+		})
+
+		body := ready(t, admin, http.StatusOK)
+		assert.Equal(t, "ok", body.Get("checks.identity_schemas.status").String(), "%s", body)
+		assert.EqualValues(t, 1, body.Get("checks.identity_schemas.details.schemas").Int(), "%s", body)
+
+		testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/does-not-exist.schema.json")
+		body = ready(t, admin, http.StatusServiceUnavailable)
+		assert.Contains(t, body.Get("checks.identity_schemas.error").String(), `unable to load identity schema "default"`, "%s", body)
+	})
+}
@@ -22,6 +22,7 @@ var ErrFakeAcceptLoginRequestFailed = errors.New("failed to accept login request
 type FakeHydra struct {
 	Skip       bool
 	RequestURL string
+	LoginHint  string
 }
 
 var _ Hydra = &FakeHydra{}
@@ -51,10 +52,14 @@ func (h *FakeHydra) GetLoginRequest(_ context.Context, loginChallenge string) (*
 	case FakeInvalidLoginChallenge:
 		return nil, herodot.ErrBadRequest.WithReasonf("Unable to get OAuth 2.0 Login Challenge.")
 	case FakeValidLoginChallenge:
-		return &hydraclientgo.OAuth2LoginRequest{
+		lr := &hydraclientgo.OAuth2LoginRequest{
 			RequestUrl: h.RequestURL,
 			Skip:       h.Skip,
-		}, nil
+		}
+		if h.LoginHint != "" {
+			lr.OidcContext = &hydraclientgo.OAuth2ConsentRequestOpenIDConnectContext{LoginHint: &h.LoginHint}
+		}
+		return lr, nil
 	default:
 		panic("unknown fake login_challenge " + loginChallenge)
 	}
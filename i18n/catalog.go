@@ -0,0 +1,140 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/text"
+)
+
+// Catalog maps message IDs to their translation.
+type Catalog map[text.ID]string
+
+var placeholder = regexp.MustCompile(`\{([a-zA-Z0-9_.]+)\}`)
+
+// ParseCatalog parses a catalog. Files ending in `.po` are parsed as GNU gettext PO files, all
+// others as JSON objects which map message IDs to translations.
+func ParseCatalog(name string, data []byte) (Catalog, error) {
+	if strings.EqualFold(path.Ext(name), ".po") {
+		return parsePO(data)
+	}
+	return parseJSON(data)
+}
+
+func parseJSON(data []byte) (Catalog, error) {
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return NewCatalog(raw)
+}
+
+// NewCatalog creates a catalog from texts keyed by the string representation of message IDs.
+func NewCatalog(texts map[string]string) (Catalog, error) {
+	c := make(Catalog, len(texts))
+	for key, translation := range texts {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, errors.Errorf("expected the catalog key %q to be a message ID", key)
+		}
+		c[text.ID(id)] = translation
+	}
+	return c, nil
+}
+
+// parsePO parses the entries of a PO file. The message ID is read from `msgctxt`, so that
+// `msgid` can hold the English text for translators, or from `msgid` if there is no context.
+func parsePO(data []byte) (Catalog, error) {
+	c := make(Catalog)
+
+	var ctxt, id, str string
+	var field *string
+	flush := func() error {
+		defer func() { ctxt, id, str, field = "", "", "", nil }()
+
+		key := ctxt
+		if key == "" {
+			key = id
+		}
+		if key == "" || str == "" {
+			// The header of the file or an untranslated entry.
+			return nil
+		}
+		messageID, err := strconv.Atoi(key)
+		if err != nil {
+			return errors.Errorf("expected the PO entry %q to reference a message ID", key)
+		}
+		c[text.ID(messageID)] = str
+		return nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		l := strings.TrimSpace(scanner.Text())
+
+		var value string
+		switch {
+		case l == "" || strings.HasPrefix(l, "#"):
+			continue
+		case strings.HasPrefix(l, "msgctxt "):
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			field, value = &ctxt, strings.TrimPrefix(l, "msgctxt ")
+		case strings.HasPrefix(l, "msgid "):
+			if field != &ctxt {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			field, value = &id, strings.TrimPrefix(l, "msgid ")
+		case strings.HasPrefix(l, "msgstr "):
+			field, value = &str, strings.TrimPrefix(l, "msgstr ")
+		case strings.HasPrefix(l, `"`) && field != nil:
+			value = l
+		default:
+			return nil, errors.Errorf("unable to parse line %d of the PO file", line)
+		}
+
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, errors.Errorf("unable to parse line %d of the PO file: %s", line, err)
+		}
+		*field += unquoted
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Translate returns the translation of the message. Placeholders such as `{min_length}` are
+// replaced with the values from the message context.
+func (c Catalog) Translate(id text.ID, context gjson.Result) (string, bool) {
+	translation, ok := c[id]
+	if !ok {
+		return "", false
+	}
+
+	return placeholder.ReplaceAllStringFunc(translation, func(match string) string {
+		value := context.Get(match[1 : len(match)-1])
+		if !value.Exists() {
+			return match
+		}
+		return value.String()
+	}), true
+}
@@ -0,0 +1,179 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package i18n_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/i18n"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+)
+
+func TestParseCatalog(t *testing.T) {
+	t.Run("format=json", func(t *testing.T) {
+		raw, err := os.ReadFile("stub/de.json")
+		require.NoError(t, err)
+
+		c, err := i18n.ParseCatalog("stub/de.json", raw)
+		require.NoError(t, err)
+		assert.Equal(t, "Kennung", c[text.InfoNodeLabelID])
+
+		translation, ok := c.Translate(text.ErrorValidationMinLength, gjson.Parse(`{"min_length":8,"actual_length":3}`))
+		require.True(t, ok)
+		assert.Equal(t, "Länge muss >= 8 sein, ist aber 3", translation)
+
+		_, ok = c.Translate(text.InfoNodeLabelEmail, gjson.Result{})
+		assert.False(t, ok)
+	})
+
+	t.Run("format=po", func(t *testing.T) {
+		raw, err := os.ReadFile("stub/fr.po")
+		require.NoError(t, err)
+
+		c, err := i18n.ParseCatalog("stub/fr.po", raw)
+		require.NoError(t, err)
+		assert.Equal(t, i18n.Catalog{text.InfoNodeLabelID: "Identifiant"}, c, "untranslated entries and the header are skipped")
+	})
+
+	t.Run("case=rejects keys which are not message IDs", func(t *testing.T) {
+		_, err := i18n.ParseCatalog("catalog.json", []byte(`{"password":"Passwort"}`))
+		require.Error(t, err)
+
+		_, err = i18n.ParseCatalog("catalog.po", []byte("msgid \"password\"\nmsgstr \"Passwort\"\n"))
+		require.Error(t, err)
+	})
+}
+
+func TestWriter(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+
+	f := &login.Flow{RequestURL: "https://www.ory.sh/self-service/login/browser", UI: container.New("")}
+	f.UI.Messages.Add(text.NewErrorValidationMinLength(8, 3))
+	f.UI.Nodes.Append(node.NewInputField("identifier", nil, node.DefaultGroup, node.InputAttributeTypeText).
+		WithMetaLabel(text.NewInfoNodeLabelID()))
+
+	write := func(t *testing.T, f *login.Flow, r *http.Request) (*httptest.ResponseRecorder, gjson.Result) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		reg.Writer().Write(w, r, f)
+		require.Equal(t, http.StatusOK, w.Code, "%s", w.Body.String())
+		return w, gjson.ParseBytes(w.Body.Bytes())
+	}
+
+	t.Run("case=disabled", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/self-service/login/flows?lang=de", nil)
+		w, body := write(t, f, r)
+		assert.Empty(t, w.Header().Get("Content-Language"))
+		assert.Equal(t, "ID", body.Get("ui.nodes.0.meta.label.text").String())
+	})
+
+	conf.MustSet(ctx, config.ViperKeySelfServiceLocalization, map[string]any{
+		"enabled": true,
+		"catalogs": []map[string]any{
+			{"language": "de", "url": "file://./stub/de.json"},
+			{"language": "fr", "url": "file://./stub/fr.po"},
+		},
+	})
+
+	t.Run("case=negotiates by the query parameter", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/self-service/login/flows?lang=de", nil)
+		r.Header.Set("Accept-Language", "fr")
+		w, body := write(t, f, r)
+		assert.Equal(t, "de", w.Header().Get("Content-Language"))
+		assert.Equal(t, "Kennung", body.Get("ui.nodes.0.meta.label.text").String(), "%s", body.Raw)
+		assert.EqualValues(t, text.InfoNodeLabelID, body.Get("ui.nodes.0.meta.label.id").Int(), "%s", body.Raw)
+		assert.Equal(t, "Länge muss >= 8 sein, ist aber 3", body.Get("ui.messages.0.text").String(), "%s", body.Raw)
+		assert.EqualValues(t, 8, body.Get("ui.messages.0.context.min_length").Int(), "%s", body.Raw)
+
+		assert.Equal(t, "ID", f.UI.Nodes[0].Meta.Label.Text, "the flow itself is not modified")
+	})
+
+	t.Run("case=negotiates by the flow's request URL", func(t *testing.T) {
+		f := *f
+		f.RequestURL += "?lang=fr-CA"
+		r := httptest.NewRequest("GET", "/self-service/login/flows", nil)
+		r.Header.Set("Accept-Language", "de")
+		w, body := write(t, &f, r)
+		assert.Equal(t, "fr", w.Header().Get("Content-Language"))
+		assert.Equal(t, "Identifiant", body.Get("ui.nodes.0.meta.label.text").String(), "%s", body.Raw)
+		assert.Equal(t, "length must be >= 8, but got 3", body.Get("ui.messages.0.text").String(), "untranslated messages keep their text")
+	})
+
+	t.Run("case=negotiates by the Accept-Language header", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/self-service/login/flows", nil)
+		r.Header.Set("Accept-Language", "es, de-AT;q=0.8")
+		w, body := write(t, f, r)
+		assert.Equal(t, "de", w.Header().Get("Content-Language"))
+		assert.Equal(t, "Kennung", body.Get("ui.nodes.0.meta.label.text").String(), "%s", body.Raw)
+	})
+
+	t.Run("case=keeps the default texts if no language matches", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/self-service/login/flows", nil)
+		r.Header.Set("Accept-Language", "es")
+		w, body := write(t, f, r)
+		assert.Empty(t, w.Header().Get("Content-Language"))
+		assert.Equal(t, "ID", body.Get("ui.nodes.0.meta.label.text").String())
+	})
+
+	t.Run("case=applies message overrides", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceMessageOverrides, map[string]any{
+			"url": "file://./stub/de.json",
+			"texts": map[string]any{
+				"4000003": "Use at least {min_length} characters.",
+			},
+		})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceMessageOverrides, nil) })
+
+		r := httptest.NewRequest("GET", "/self-service/login/flows", nil)
+		w, body := write(t, f, r)
+		assert.Empty(t, w.Header().Get("Content-Language"))
+		assert.Equal(t, "Use at least 8 characters.", body.Get("ui.messages.0.text").String(), "texts take precedence over the file")
+		assert.EqualValues(t, text.ErrorValidationMinLength, body.Get("ui.messages.0.id").Int(), "%s", body.Raw)
+		assert.Equal(t, "Kennung", body.Get("ui.nodes.0.meta.label.text").String(), "%s", body.Raw)
+
+		r = httptest.NewRequest("GET", "/self-service/login/flows?lang=fr", nil)
+		w, body = write(t, f, r)
+		assert.Equal(t, "fr", w.Header().Get("Content-Language"))
+		assert.Equal(t, "Identifiant", body.Get("ui.nodes.0.meta.label.text").String(), "translations take precedence over overrides")
+		assert.Equal(t, "Use at least 8 characters.", body.Get("ui.messages.0.text").String(), "%s", body.Raw)
+	})
+
+	t.Run("case=ignores payloads which are not flows", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/self-service/login/flows?lang=de", nil)
+		w := httptest.NewRecorder()
+		reg.Writer().Write(w, r, map[string]string{"text": "ID"})
+		assert.Empty(t, w.Header().Get("Content-Language"))
+		assert.JSONEq(t, `{"text":"ID"}`, w.Body.String())
+	})
+}
+
+func TestLocalize(t *testing.T) {
+	flow, err := json.Marshal(map[string]any{"ui": map[string]any{"nodes": []any{
+		map[string]any{"attributes": map[string]any{"text": text.NewInfoNodeLabelID()}, "messages": []any{}},
+	}}})
+	require.NoError(t, err)
+
+	localized, err := i18n.Localize(flow, i18n.Catalog{text.InfoNodeLabelID: "Kennung"})
+	require.NoError(t, err)
+	assert.Equal(t, "Kennung", gjson.GetBytes(localized, "ui.nodes.0.attributes.text.text").String())
+
+	localized, err = i18n.Localize(flow, i18n.Catalog{}, i18n.Catalog{text.InfoNodeLabelID: "Identifier"})
+	require.NoError(t, err)
+	assert.Equal(t, "Identifier", gjson.GetBytes(localized, "ui.nodes.0.attributes.text.text").String(), "falls back to the next catalog")
+}
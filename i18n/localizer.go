@@ -0,0 +1,158 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/language"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/fetcher"
+)
+
+type (
+	localizerDependencies interface {
+		config.Provider
+		x.HTTPClientProvider
+		x.LoggingProvider
+	}
+
+	// Localizer negotiates the language of a request and loads the translation catalogs
+	// configured in `selfservice.localization` and the message overrides.
+	Localizer struct {
+		d localizerDependencies
+
+		mu       sync.Mutex
+		catalogs map[string]Catalog
+	}
+)
+
+func NewLocalizer(d localizerDependencies) *Localizer {
+	return &Localizer{d: d, catalogs: make(map[string]Catalog)}
+}
+
+// Negotiate returns the catalog of the language preferred by the request. The language is
+// selected, in order, by the `lang` query parameter, the `lang` query parameter of the URL
+// which initialized the flow, and the `Accept-Language` header. It returns false if
+// localization is disabled or none of the configured languages is acceptable.
+func (l *Localizer) Negotiate(r *http.Request, flowRequestURL string) (Catalog, language.Tag, bool, error) {
+	ctx := r.Context()
+	conf, err := l.d.Config().SelfServiceLocalization(ctx)
+	if err != nil {
+		return nil, language.Und, false, err
+	}
+	if !conf.Enabled || len(conf.Catalogs) == 0 {
+		return nil, language.Und, false, nil
+	}
+
+	supported := make([]language.Tag, len(conf.Catalogs))
+	for k, c := range conf.Catalogs {
+		tag, err := language.Parse(c.Language)
+		if err != nil {
+			return nil, language.Und, false, errors.Errorf("unable to parse the language %q of the translation catalog: %s", c.Language, err)
+		}
+		supported[k] = tag
+	}
+
+	desired, ok := requestedLanguages(r, flowRequestURL)
+	if !ok {
+		return nil, language.Und, false, nil
+	}
+
+	_, index, confidence := language.NewMatcher(supported).Match(desired...)
+	if confidence == language.No {
+		return nil, language.Und, false, nil
+	}
+
+	catalog, err := l.load(ctx, conf.Catalogs[index].URL)
+	if err != nil {
+		return nil, language.Und, false, err
+	}
+	return catalog, supported[index], true, nil
+}
+
+// Overrides returns the texts configured in `selfservice.message_overrides`, which replace the
+// default texts of messages. Texts set in the configuration take precedence over the file.
+func (l *Localizer) Overrides(ctx context.Context) (Catalog, error) {
+	conf, err := l.d.Config().SelfServiceMessageOverrides(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(Catalog)
+	if conf.URL != "" {
+		file, err := l.load(ctx, conf.URL)
+		if err != nil {
+			return nil, err
+		}
+		for id, t := range file {
+			overrides[id] = t
+		}
+	}
+
+	texts, err := NewCatalog(conf.Texts)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to parse the message overrides")
+	}
+	for id, t := range texts {
+		overrides[id] = t
+	}
+	return overrides, nil
+}
+
+func requestedLanguages(r *http.Request, flowRequestURL string) ([]language.Tag, bool) {
+	parseParam := func(lang string) ([]language.Tag, bool) {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return nil, false
+		}
+		return []language.Tag{tag}, true
+	}
+
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if tags, ok := parseParam(lang); ok {
+			return tags, true
+		}
+	}
+	if u, err := url.Parse(flowRequestURL); err == nil && flowRequestURL != "" {
+		if lang := u.Query().Get("lang"); lang != "" {
+			if tags, ok := parseParam(lang); ok {
+				return tags, true
+			}
+		}
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil || len(tags) == 0 {
+		return nil, false
+	}
+	return tags, true
+}
+
+func (l *Localizer) load(ctx context.Context, source string) (Catalog, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if c, ok := l.catalogs[source]; ok {
+		return c, nil
+	}
+
+	raw, err := fetcher.NewFetcher(fetcher.WithClient(l.d.HTTPClient(ctx))).FetchContext(ctx, source)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	c, err := ParseCatalog(source, raw.Bytes())
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to parse the translation catalog %s", source)
+	}
+	l.catalogs[source] = c
+	return c, nil
+}
@@ -0,0 +1,136 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package i18n
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
+)
+
+// localizable is implemented by the self-service flows.
+type localizable interface {
+	GetUI() *container.Container
+	GetRequestURL() string
+}
+
+var _ herodot.Writer = (*Writer)(nil)
+
+// Writer localizes the texts of UI messages and node labels in flows before handing them to
+// the wrapped writer, and applies the configured message overrides. Message IDs are never
+// changed, so that UIs can keep relying on them.
+type Writer struct {
+	herodot.Writer
+	l *Localizer
+}
+
+func NewWriter(w herodot.Writer, l *Localizer) *Writer {
+	return &Writer{Writer: w, l: l}
+}
+
+func (h *Writer) Write(w http.ResponseWriter, r *http.Request, e interface{}, opts ...herodot.EncoderOptions) {
+	h.WriteCode(w, r, http.StatusOK, e, opts...)
+}
+
+func (h *Writer) WriteCode(w http.ResponseWriter, r *http.Request, code int, e interface{}, opts ...herodot.EncoderOptions) {
+	h.Writer.WriteCode(w, r, code, h.localize(w, r, e), opts...)
+}
+
+func (h *Writer) WriteCreated(w http.ResponseWriter, r *http.Request, location string, e interface{}) {
+	h.Writer.WriteCreated(w, r, location, h.localize(w, r, e))
+}
+
+// localize returns the localized payload. The payload itself is not modified, as it is
+// usually the flow which was just persisted. If anything fails, the payload is returned as is.
+func (h *Writer) localize(w http.ResponseWriter, r *http.Request, e interface{}) interface{} {
+	f, ok := e.(localizable)
+	if !ok || f.GetUI() == nil {
+		return e
+	}
+
+	overrides, err := h.l.Overrides(r.Context())
+	if err != nil {
+		h.l.d.Logger().WithRequest(r).WithError(err).Warn("Unable to load the message overrides, responding with the default texts.")
+		overrides = nil
+	}
+
+	catalogs := make([]Catalog, 0, 2)
+	catalog, tag, negotiated, err := h.l.Negotiate(r, f.GetRequestURL())
+	if err != nil {
+		h.l.d.Logger().WithRequest(r).WithError(err).Warn("Unable to localize the flow, responding with the default texts.")
+	} else if negotiated {
+		catalogs = append(catalogs, catalog)
+	}
+	if len(overrides) > 0 {
+		catalogs = append(catalogs, overrides)
+	}
+	if len(catalogs) == 0 {
+		return e
+	}
+
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return e
+	}
+
+	localized, err := Localize(raw, catalogs...)
+	if err != nil {
+		h.l.d.Logger().WithRequest(r).WithError(err).Warn("Unable to localize the flow, responding with the default texts.")
+		return e
+	}
+
+	if negotiated {
+		w.Header().Set("Content-Language", tag.String())
+	}
+	return json.RawMessage(localized)
+}
+
+// Localize replaces the texts of all UI messages and node labels in the JSON encoded flow with
+// their translation from the first catalog which has one. Messages without a translation are
+// left as is.
+func Localize(flow []byte, catalogs ...Catalog) ([]byte, error) {
+	paths := make([]string, 0)
+	ui := gjson.GetBytes(flow, "ui")
+
+	ui.Get("messages").ForEach(func(key, _ gjson.Result) bool {
+		paths = append(paths, "ui.messages."+key.String())
+		return true
+	})
+	ui.Get("nodes").ForEach(func(node, n gjson.Result) bool {
+		prefix := "ui.nodes." + node.String()
+		n.Get("messages").ForEach(func(key, _ gjson.Result) bool {
+			paths = append(paths, prefix+".messages."+key.String())
+			return true
+		})
+		if n.Get("meta.label").IsObject() {
+			paths = append(paths, prefix+".meta.label")
+		}
+		if n.Get("attributes.text").IsObject() {
+			paths = append(paths, prefix+".attributes.text")
+		}
+		return true
+	})
+
+	var err error
+	for _, p := range paths {
+		message := gjson.GetBytes(flow, p)
+		for _, catalog := range catalogs {
+			translation, ok := catalog.Translate(text.ID(message.Get("id").Int()), message.Get("context"))
+			if !ok {
+				continue
+			}
+			if flow, err = sjson.SetBytes(flow, p+".text", translation); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return flow, nil
+}
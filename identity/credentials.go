@@ -90,6 +90,12 @@ const (
 	CredentialsTypePasskey  CredentialsType = "passkey"
 	CredentialsTypeProfile  CredentialsType = "profile"
 	CredentialsTypeSAML     CredentialsType = "saml"
+
+	// CredentialsTypeDeviceKey is a device-bound key used by native apps to re-authenticate a session.
+	CredentialsTypeDeviceKey CredentialsType = "device_key"
+
+	// CredentialsTypeExternalMFA links the identity to its user at an external MFA provider.
+	CredentialsTypeExternalMFA CredentialsType = "external_mfa"
 )
 
 func (c CredentialsType) String() string {
@@ -112,6 +118,10 @@ func (c CredentialsType) ToUiNodeGroup() node.UiNodeGroup {
 		return node.CodeGroup
 	case CredentialsTypePasskey:
 		return node.PasskeyGroup
+	case CredentialsTypeDeviceKey:
+		return node.DeviceKeyGroup
+	case CredentialsTypeExternalMFA:
+		return node.ExternalMFAGroup
 	default:
 		return node.DefaultGroup
 	}
@@ -126,6 +136,8 @@ var AllCredentialTypes = []CredentialsType{
 	CredentialsTypeWebAuthn,
 	CredentialsTypeCodeAuth,
 	CredentialsTypePasskey,
+	CredentialsTypeDeviceKey,
+	CredentialsTypeExternalMFA,
 }
 
 const (
@@ -133,6 +145,11 @@ const (
 	// It is not used within the credentials object itself.
 	CredentialsTypeRecoveryLink CredentialsType = "link_recovery"
 	CredentialsTypeRecoveryCode CredentialsType = "code_recovery"
+
+	// CredentialsTypeCrossDevice is a special credential type used in the authentication method
+	// reference of sessions issued by the cross-device login flow.
+	// It is not used within the credentials object itself.
+	CredentialsTypeCrossDevice CredentialsType = "cross_device"
 )
 
 // ParseCredentialsType parses a string into a CredentialsType or returns false as the second argument.
@@ -147,7 +164,9 @@ func ParseCredentialsType(in string) (CredentialsType, bool) {
 		CredentialsTypeCodeAuth,
 		CredentialsTypeRecoveryLink,
 		CredentialsTypeRecoveryCode,
-		CredentialsTypePasskey:
+		CredentialsTypePasskey,
+		CredentialsTypeDeviceKey,
+		CredentialsTypeExternalMFA:
 		return t, true
 	}
 	return "", false
@@ -220,6 +239,16 @@ type (
 		CountActiveMultiFactorCredentials(context.Context, map[CredentialsType]Credentials) (int, error)
 	}
 
+	// ActiveIdentityMultiFactorCounter is implemented by credentials counters whose second factor
+	// does not only depend on the credentials, for example because codes can be sent to the
+	// identity's verifiable addresses. It takes precedence over CountActiveMultiFactorCredentials
+	// when the available AAL of an identity is computed.
+	//
+	// swagger:ignore
+	ActiveIdentityMultiFactorCounter interface {
+		CountActiveMultiFactorCredentialsOfIdentity(context.Context, *Identity) (int, error)
+	}
+
 	// swagger:ignore
 	ActiveCredentialsCounterStrategyProvider interface {
 		ActiveCredentialsCounterStrategies(context.Context) []ActiveCredentialsCounter
@@ -0,0 +1,49 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"time"
+)
+
+// CredentialsDeviceKeyConfig is the struct that is being used as part of the identity credentials.
+type CredentialsDeviceKeyConfig struct {
+	// List of device-bound keys
+	Keys []CredentialDeviceKey `json:"keys"`
+}
+
+// CredentialDeviceKey is a public key whose private key never leaves the device it was generated on
+// and is typically protected by platform biometrics.
+type CredentialDeviceKey struct {
+	// ID is generated by Ory Kratos when the key is registered.
+	ID string `json:"id"`
+
+	// DisplayName is a human-readable name of the device.
+	DisplayName string `json:"display_name"`
+
+	// PublicKey is the DER-encoded PKIX public key. Supported are ECDSA P-256 and Ed25519 keys.
+	PublicKey []byte `json:"public_key"`
+
+	// Attestation is the optional, unverified attestation statement provided by the device.
+	Attestation string `json:"attestation,omitempty"`
+
+	// PushToken is the optional token the device receives push notifications with.
+	PushToken string `json:"push_token,omitempty"`
+
+	// PushPlatform is the push service which issued the push token, either `fcm` or `apns`.
+	PushPlatform string `json:"push_platform,omitempty"`
+
+	// AddedAt is the time the key was registered.
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Find returns the key with the given ID or nil if no such key exists.
+func (c *CredentialsDeviceKeyConfig) Find(id string) *CredentialDeviceKey {
+	for k := range c.Keys {
+		if c.Keys[k].ID == id {
+			return &c.Keys[k]
+		}
+	}
+	return nil
+}
@@ -9,15 +9,57 @@ import (
 	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/ui/node"
 
+	"github.com/ory/x/randx"
 	"github.com/ory/x/sqlxx"
 )
 
+const (
+	// LookupSecretCodes is the number of codes in a set of lookup secrets.
+	LookupSecretCodes = 12
+
+	// lookupSecretLength is the length of each lookup secret code.
+	lookupSecretLength = 8
+)
+
 // CredentialsConfig is the struct that is being used as part of the identity credentials.
 type CredentialsLookupConfig struct {
 	// List of recovery codes
 	RecoveryCodes []RecoveryCode `json:"recovery_codes"`
 }
 
+// NewCredentialsLookupConfig generates a new set of unused lookup secrets.
+func NewCredentialsLookupConfig() *CredentialsLookupConfig {
+	codes := make([]RecoveryCode, LookupSecretCodes)
+	for k := range codes {
+		codes[k] = RecoveryCode{Code: randx.MustString(lookupSecretLength, randx.AlphaLowerNum)}
+	}
+	return &CredentialsLookupConfig{RecoveryCodes: codes}
+}
+
+// RemainingCodes returns the number of codes which were not used yet.
+func (c *CredentialsLookupConfig) RemainingCodes() (remaining int) {
+	for _, code := range c.RecoveryCodes {
+		if time.Time(code.UsedAt).IsZero() {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// ToStatusNode shows whether and when each code was used without revealing the codes.
+func (c *CredentialsLookupConfig) ToStatusNode() *node.Node {
+	messages := make([]text.Message, len(c.RecoveryCodes))
+	for k, code := range c.RecoveryCodes {
+		if time.Time(code.UsedAt).IsZero() {
+			messages[k] = *text.NewInfoSelfServiceSettingsLookupSecretUnused()
+		} else {
+			messages[k] = *text.NewInfoSelfServiceSettingsLookupSecretUsed(time.Time(code.UsedAt).UTC())
+		}
+	}
+
+	return node.NewTextField(node.LookupStatus, text.NewInfoSelfServiceSettingsLookupSecretStatus(c.RemainingCodes(), len(c.RecoveryCodes), messages), node.LookupGroup)
+}
+
 func (c *CredentialsLookupConfig) ToNode() *node.Node {
 	messages := make([]text.Message, len(c.RecoveryCodes))
 	formatted := make([]string, len(c.RecoveryCodes))
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -31,13 +32,18 @@ type CredentialsOIDCProvider struct {
 	InitialRefreshToken string `json:"initial_refresh_token"`
 	Organization        string `json:"organization,omitempty"`
 	UseAutoLink         bool   `json:"use_auto_link,omitzero"`
+
+	// AccessTokenExpiresAt is the expiry of the access token. The initial access and refresh tokens
+	// are replaced once the access token was refreshed.
+	AccessTokenExpiresAt *time.Time `json:"access_token_expires_at,omitempty"`
 }
 
 // swagger:ignore
 type CredentialsOIDCEncryptedTokens struct {
-	RefreshToken string `json:"refresh_token,omitempty"`
-	IDToken      string `json:"id_token,omitempty"`
-	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	IDToken      string     `json:"id_token,omitempty"`
+	AccessToken  string     `json:"access_token,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
 }
 
 func (c *CredentialsOIDCEncryptedTokens) GetRefreshToken() string {
@@ -61,6 +67,13 @@ func (c *CredentialsOIDCEncryptedTokens) GetIDToken() string {
 	return c.IDToken
 }
 
+func (c *CredentialsOIDCEncryptedTokens) GetExpiresAt() *time.Time {
+	if c == nil {
+		return nil
+	}
+	return c.ExpiresAt
+}
+
 // NewCredentialsOIDC creates a new OIDC credential.
 func NewCredentialsOIDC(tokens *CredentialsOIDCEncryptedTokens, provider, subject, organization string) (*Credentials, error) {
 	if provider == "" {
@@ -75,12 +88,13 @@ func NewCredentialsOIDC(tokens *CredentialsOIDCEncryptedTokens, provider, subjec
 	if err := json.NewEncoder(&b).Encode(CredentialsOIDC{
 		Providers: []CredentialsOIDCProvider{
 			{
-				Subject:             subject,
-				Provider:            provider,
-				InitialIDToken:      tokens.GetIDToken(),
-				InitialAccessToken:  tokens.GetAccessToken(),
-				InitialRefreshToken: tokens.GetRefreshToken(),
-				Organization:        organization,
+				Subject:              subject,
+				Provider:             provider,
+				InitialIDToken:       tokens.GetIDToken(),
+				InitialAccessToken:   tokens.GetAccessToken(),
+				InitialRefreshToken:  tokens.GetRefreshToken(),
+				Organization:         organization,
+				AccessTokenExpiresAt: tokens.GetExpiresAt(),
 			},
 		},
 	}); err != nil {
@@ -100,6 +114,7 @@ func (c *CredentialsOIDCProvider) GetTokens() *CredentialsOIDCEncryptedTokens {
 		RefreshToken: c.InitialRefreshToken,
 		IDToken:      c.InitialIDToken,
 		AccessToken:  c.InitialAccessToken,
+		ExpiresAt:    c.AccessTokenExpiresAt,
 	}
 }
 
@@ -3,6 +3,13 @@
 
 package identity
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
 // CredentialsPassword is contains the configuration for credentials of the type password.
 //
 // swagger:model identityCredentialsPassword
@@ -14,8 +21,54 @@ type CredentialsPassword struct {
 	// using the password migration hook. If set, and the HashedPassword is empty, a
 	// webhook will be called during login to migrate the password.
 	UsePasswordMigrationHook bool `json:"use_password_migration_hook,omitempty"`
+
+	// Temporary is set to true if the password was set by an administrator and
+	// must be changed after the next login.
+	Temporary bool `json:"temporary,omitempty"`
+
+	// ExpiresAt is the time after which a temporary password can no longer be used
+	// to sign in.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// ChangedAt is the time the password was last set. It is used to determine when
+	// the password must be rotated.
+	ChangedAt *time.Time `json:"password_changed_at,omitempty"`
 }
 
 func (cp *CredentialsPassword) ShouldUsePasswordMigrationHook() bool {
 	return cp != nil && cp.HashedPassword == "" && cp.UsePasswordMigrationHook
 }
+
+// IsExpired returns true if the password is temporary and can no longer be used to sign in.
+func (cp *CredentialsPassword) IsExpired(now time.Time) bool {
+	return cp != nil && cp.ExpiresAt != nil && now.After(*cp.ExpiresAt)
+}
+
+// RotateAt returns when the password must be rotated if passwords can be used for at most maxAge.
+// Passwords which were set before the change time was tracked count from when the credentials
+// were last updated.
+func (cp *CredentialsPassword) RotateAt(c *Credentials, maxAge time.Duration) time.Time {
+	changedAt := c.UpdatedAt
+	if cp.ChangedAt != nil {
+		changedAt = *cp.ChangedAt
+	}
+	return changedAt.Add(maxAge)
+}
+
+// PasswordRotateAt returns when the password of the identity must be rotated if passwords can be used
+// for at most maxAge. It returns false if the identity has no password or its credentials were not loaded.
+func (i *Identity) PasswordRotateAt(maxAge time.Duration) (time.Time, bool, error) {
+	c, ok := i.GetCredentials(CredentialsTypePassword)
+	if !ok || len(c.Config) == 0 {
+		return time.Time{}, false, nil
+	}
+
+	var cp CredentialsPassword
+	if err := json.Unmarshal(c.Config, &cp); err != nil {
+		return time.Time{}, false, errors.WithStack(err)
+	}
+	if cp.HashedPassword == "" {
+		return time.Time{}, false, nil
+	}
+	return cp.RotateAt(c, maxAge), true, nil
+}
@@ -0,0 +1,234 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/x/webauthnx/aaguid"
+)
+
+type (
+	CredentialsUsagePersister interface {
+		// GetCredentialsLastUsed returns when the identity last completed an authentication
+		// challenge with each credentials type.
+		GetCredentialsLastUsed(ctx context.Context, identityID uuid.UUID) (map[CredentialsType]time.Time, error)
+	}
+	CredentialsUsagePersistenceProvider interface {
+		CredentialsUsagePersister() CredentialsUsagePersister
+	}
+)
+
+// Identity Credentials Summary
+//
+// A summary of the credentials of an identity which does not contain any secrets.
+//
+// swagger:model identityCredentialsSummary
+type CredentialsSummary struct {
+	// The ID of the identity.
+	//
+	// required: true
+	IdentityID uuid.UUID `json:"identity_id"`
+
+	// The highest authenticator assurance level the identity is able to reach with its credentials.
+	//
+	// required: true
+	AvailableAAL AuthenticatorAssuranceLevel `json:"available_aal"`
+
+	// Whether the identity has set up a credential which can be used as a second factor.
+	//
+	// required: true
+	MFAEnabled bool `json:"mfa_enabled"`
+
+	// The credentials of the identity, ordered by type.
+	//
+	// required: true
+	Credentials []CredentialSummary `json:"credentials"`
+}
+
+// Identity Credential Summary
+//
+// swagger:model identityCredentialSummary
+type CredentialSummary struct {
+	// The type of the credential.
+	//
+	// required: true
+	Type CredentialsType `json:"type"`
+
+	// The identifiers of the credential.
+	//
+	// required: true
+	Identifiers []string `json:"identifiers"`
+
+	// Whether the credential can be used as a first factor.
+	//
+	// required: true
+	FirstFactor bool `json:"first_factor"`
+
+	// Whether the credential can be used as a second factor.
+	//
+	// required: true
+	MultiFactor bool `json:"multi_factor"`
+
+	// When the identity last completed an authentication challenge with this credential type.
+	// Only sessions which have not yet been cleaned up are taken into account.
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+
+	// The WebAuthn authenticators of `webauthn` and `passkey` credentials.
+	WebAuthnAuthenticators []WebAuthnAuthenticatorSummary `json:"webauthn_authenticators,omitempty"`
+
+	// When the credential was created.
+	//
+	// required: true
+	CreatedAt time.Time `json:"created_at"`
+
+	// When the credential was last updated.
+	//
+	// required: true
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Identity WebAuthn Authenticator Summary
+//
+// swagger:model identityWebAuthnAuthenticatorSummary
+type WebAuthnAuthenticatorSummary struct {
+	// The name of the authenticator.
+	DisplayName string `json:"display_name"`
+
+	// The AAGUID identifying the model of the authenticator.
+	AAGUID string `json:"aaguid,omitempty"`
+
+	// The attestation type used when the authenticator was registered.
+	AttestationType string `json:"attestation_type"`
+
+	// Whether the authenticator can be used without a password.
+	IsPasswordless bool `json:"is_passwordless"`
+
+	// The signature counter of the authenticator.
+	SignCount uint32 `json:"sign_count"`
+
+	// Whether the signature counter indicated that the authenticator might have been cloned.
+	CloneWarning bool `json:"clone_warning"`
+
+	// Whether the authenticator is eligible to be backed up, for example to a cloud keychain.
+	BackupEligible bool `json:"backup_eligible"`
+
+	// Whether the authenticator is currently backed up.
+	BackupState bool `json:"backup_state"`
+
+	// When the authenticator was added.
+	AddedAt time.Time `json:"added_at"`
+}
+
+// SummarizeCredentials summarizes the credentials of the identity, which must have been loaded
+// including its credentials. lastUsed contains when each credentials type was last used.
+func (m *Manager) SummarizeCredentials(ctx context.Context, i *Identity, lastUsed map[CredentialsType]time.Time) (*CredentialsSummary, error) {
+	summary := &CredentialsSummary{
+		IdentityID:   i.ID,
+		AvailableAAL: NoAuthenticatorAssuranceLevel,
+		Credentials:  make([]CredentialSummary, 0, len(i.Credentials)),
+	}
+
+	counters := make(map[CredentialsType]ActiveCredentialsCounter)
+	for _, strategy := range m.r.ActiveCredentialsCounterStrategies(ctx) {
+		counters[strategy.ID()] = strategy
+	}
+
+	for t, c := range i.Credentials {
+		cs := CredentialSummary{
+			Type:        t,
+			Identifiers: c.Identifiers,
+			CreatedAt:   c.CreatedAt,
+			UpdatedAt:   c.UpdatedAt,
+		}
+		if cs.Identifiers == nil {
+			cs.Identifiers = []string{}
+		}
+		if at, ok := lastUsed[t]; ok {
+			cs.LastUsedAt = &at
+		}
+
+		if counter, ok := counters[t]; ok {
+			only := map[CredentialsType]Credentials{t: c}
+			first, err := counter.CountActiveFirstFactorCredentials(ctx, only)
+			if err != nil {
+				return nil, err
+			}
+			multi, err := counter.CountActiveMultiFactorCredentials(ctx, only)
+			if err != nil {
+				return nil, err
+			}
+			cs.FirstFactor, cs.MultiFactor = first > 0, multi > 0
+		}
+
+		if t == CredentialsTypeWebAuthn || t == CredentialsTypePasskey {
+			authenticators, err := summarizeWebAuthnAuthenticators(c)
+			if err != nil {
+				return nil, err
+			}
+			cs.WebAuthnAuthenticators = authenticators
+		}
+
+		switch {
+		case cs.MultiFactor:
+			summary.MFAEnabled = true
+			summary.AvailableAAL = AuthenticatorAssuranceLevel2
+		case cs.FirstFactor && summary.AvailableAAL == NoAuthenticatorAssuranceLevel:
+			summary.AvailableAAL = AuthenticatorAssuranceLevel1
+		}
+
+		summary.Credentials = append(summary.Credentials, cs)
+	}
+
+	sort.Slice(summary.Credentials, func(i, j int) bool {
+		return summary.Credentials[i].Type < summary.Credentials[j].Type
+	})
+
+	return summary, nil
+}
+
+func summarizeWebAuthnAuthenticators(c Credentials) ([]WebAuthnAuthenticatorSummary, error) {
+	if len(c.Config) == 0 {
+		return nil, nil
+	}
+
+	var conf CredentialsWebAuthnConfig
+	if err := json.Unmarshal(c.Config, &conf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	authenticators := make([]WebAuthnAuthenticatorSummary, 0, len(conf.Credentials))
+	for _, wc := range conf.Credentials {
+		a := WebAuthnAuthenticatorSummary{
+			DisplayName:     wc.DisplayName,
+			AttestationType: wc.AttestationType,
+			IsPasswordless:  wc.IsPasswordless,
+			AddedAt:         wc.AddedAt,
+		}
+		if wc.Authenticator != nil {
+			a.SignCount = wc.Authenticator.SignCount
+			a.CloneWarning = wc.Authenticator.CloneWarning
+			if id, err := uuid.FromBytes(wc.Authenticator.AAGUID); err == nil {
+				a.AAGUID = id.String()
+			}
+			if a.DisplayName == "" {
+				if known := aaguid.Lookup(wc.Authenticator.AAGUID); known != nil {
+					a.DisplayName = known.Name
+				}
+			}
+		}
+		if wc.Flags != nil {
+			a.BackupEligible = wc.Flags.BackupEligible
+			a.BackupState = wc.Flags.BackupState
+		}
+		authenticators = append(authenticators, a)
+	}
+	return authenticators, nil
+}
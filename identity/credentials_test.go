@@ -40,6 +40,8 @@ func TestParseCredentialsType(t *testing.T) {
 		{"totp", CredentialsTypeTOTP},
 		{"webauthn", CredentialsTypeWebAuthn},
 		{"lookup_secret", CredentialsTypeLookup},
+		{"device_key", CredentialsTypeDeviceKey},
+		{"external_mfa", CredentialsTypeExternalMFA},
 		{"link_recovery", CredentialsTypeRecoveryLink},
 		{"code_recovery", CredentialsTypeRecoveryCode},
 	} {
@@ -0,0 +1,130 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/fetcher"
+	"github.com/ory/x/jsonnetsecure"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlxx"
+)
+
+type (
+	derivedTraitsDependencies interface {
+		jsonnetsecure.VMProvider
+		x.TracingProvider
+		x.HTTPClientProvider
+		config.Provider
+	}
+	DerivedTraitsMapper struct {
+		r     derivedTraitsDependencies
+		cache *ristretto.Cache[[]byte, []byte]
+		// results caches the derived traits by the hash of the Jsonnet source and the identity it
+		// was evaluated with, so that listing identities does not evaluate the mapper for every
+		// unchanged identity.
+		results *ristretto.Cache[[]byte, []byte]
+	}
+	DerivedTraitsMapperProvider interface {
+		IdentityDerivedTraitsMapper() *DerivedTraitsMapper
+	}
+
+	// derivedTraitsInput is the identity as seen by the derived traits Jsonnet mapper.
+	derivedTraitsInput struct {
+		ID             uuid.UUID                `json:"id"`
+		SchemaID       string                   `json:"schema_id"`
+		State          State                    `json:"state"`
+		Traits         Traits                   `json:"traits"`
+		MetadataPublic sqlxx.NullJSONRawMessage `json:"metadata_public"`
+		CreatedAt      time.Time                `json:"created_at"`
+		UpdatedAt      time.Time                `json:"updated_at"`
+	}
+)
+
+func NewDerivedTraitsMapper(r derivedTraitsDependencies) *DerivedTraitsMapper {
+	cache, _ := ristretto.NewCache(&ristretto.Config[[]byte, []byte]{
+		MaxCost:     50 << 20, // 50MB,
+		NumCounters: 500_000,  // 1kB per snippet -> 50k snippets -> 500k counters
+		BufferItems: 64,
+	})
+	results, _ := ristretto.NewCache(&ristretto.Config[[]byte, []byte]{
+		MaxCost:     50 << 20, // 50MB,
+		NumCounters: 500_000,  // 1kB per result -> 50k results -> 500k counters
+		BufferItems: 64,
+	})
+	return &DerivedTraitsMapper{r: r, cache: cache, results: results}
+}
+
+// DeriveTraits sets the derived traits of the identity using the configured Jsonnet mapper.
+// The identity is left untouched if no mapper is configured.
+func (m *DerivedTraitsMapper) DeriveTraits(ctx context.Context, i *Identity) (err error) {
+	mapper := m.r.Config().IdentityDerivedTraitsMapper(ctx)
+	if len(mapper) == 0 {
+		return nil
+	}
+
+	ctx, span := m.r.Tracer(ctx).Tracer().Start(ctx, "identity.DerivedTraitsMapper.DeriveTraits")
+	defer otelx.End(span, &err)
+
+	identityRaw, err := json.Marshal(&derivedTraitsInput{
+		ID:             i.ID,
+		SchemaID:       i.SchemaID,
+		State:          i.State,
+		Traits:         i.Traits,
+		MetadataPublic: i.MetadataPublic,
+		CreatedAt:      i.CreatedAt,
+		UpdatedAt:      i.UpdatedAt,
+	})
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to encode identity to JSON."))
+	}
+
+	f := fetcher.NewFetcher(fetcher.WithClient(m.r.HTTPClient(ctx)), fetcher.WithCache(m.cache, 60*time.Minute))
+	jsonnet, err := f.FetchContext(ctx, mapper)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	_, _ = h.Write(jsonnet.Bytes())
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(identityRaw)
+	key := h.Sum(nil)
+	if derived, ok := m.results.Get(key); ok {
+		i.DerivedTraits = json.RawMessage(derived)
+		return nil
+	}
+
+	vm, err := m.r.JsonnetVM(ctx)
+	if err != nil {
+		return err
+	}
+
+	vm.ExtCode("identity", string(identityRaw))
+	evaluated, err := vm.EvaluateAnonymousSnippet(mapper, jsonnet.String())
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithDebug(err.Error()).WithReasonf("Unable to execute derived traits JsonNet."))
+	}
+
+	derived := gjson.Get(evaluated, "derived_traits")
+	if !derived.IsObject() {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Expected derived traits JsonNet to return a derived_traits object but it did not."))
+	}
+
+	m.results.SetWithTTL(key, []byte(derived.Raw), int64(len(derived.Raw)), 60*time.Minute)
+	i.DerivedTraits = json.RawMessage(derived.Raw)
+	return nil
+}
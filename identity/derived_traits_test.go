@@ -0,0 +1,59 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+)
+
+func TestDerivedTraitsMapper(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	m := identity.NewDerivedTraitsMapper(reg)
+
+	newIdentity := func() *identity.Identity {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"bar":"Jane","email":"jane@ory.sh"}`)
+		return i
+	}
+
+	t.Run("case=does nothing without a mapper", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "")
+		i := newIdentity()
+		require.NoError(t, m.DeriveTraits(ctx, i))
+		assert.Empty(t, i.DerivedTraits)
+	})
+
+	t.Run("case=derives traits", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "file://./stub/derived_traits/display_name.jsonnet")
+		i := newIdentity()
+		require.NoError(t, m.DeriveTraits(ctx, i))
+		assert.JSONEq(t, `{"display_name":"Jane <jane@ory.sh>","schema_id":"default"}`, string(i.DerivedTraits))
+	})
+
+	t.Run("case=derives the traits of changed identities again", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "file://./stub/derived_traits/display_name.jsonnet")
+		i := newIdentity()
+		for _, name := range []string{"Jane", "John", "Jane"} {
+			i.Traits = identity.Traits(`{"bar":"` + name + `","email":"jane@ory.sh"}`)
+			require.NoError(t, m.DeriveTraits(ctx, i))
+			assert.JSONEq(t, `{"display_name":"`+name+` <jane@ory.sh>","schema_id":"default"}`, string(i.DerivedTraits))
+		}
+	})
+
+	t.Run("case=fails if the mapper does not return derived traits", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "file://./stub/derived_traits/invalid.jsonnet")
+		i := newIdentity()
+		require.Error(t, m.DeriveTraits(ctx, i))
+		assert.Empty(t, i.DerivedTraits)
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/fetcher"
+	"github.com/ory/x/otelx"
+)
+
+//go:embed disposable_email_domains.txt
+var bundledDisposableEmailDomains string
+
+type (
+	disposableEmailDependencies interface {
+		config.Provider
+		x.TracingProvider
+		x.HTTPClientProvider
+		x.LoggingProvider
+		ValidationProvider
+	}
+	DisposableEmailBlockerProvider interface {
+		DisposableEmailBlocker() *DisposableEmailBlocker
+	}
+
+	// DisposableEmailBlocker rejects email addresses of disposable email providers. It uses the
+	// bundled blocklist and, if configured, the blocklist loaded from the blocklist URL.
+	DisposableEmailBlocker struct {
+		r       disposableEmailDependencies
+		bundled map[string]struct{}
+
+		l         sync.Mutex
+		remote    map[string]struct{}
+		remoteURL string
+		fetchedAt time.Time
+	}
+
+	schemaExtensionDisposableEmail struct {
+		b      *DisposableEmailBlocker
+		remote map[string]struct{}
+	}
+)
+
+func NewDisposableEmailBlocker(r disposableEmailDependencies) *DisposableEmailBlocker {
+	bundled, _ := parseDisposableEmailDomains(strings.NewReader(bundledDisposableEmailDomains))
+	return &DisposableEmailBlocker{r: r, bundled: bundled}
+}
+
+// Validate returns a validation error for every email address in the identity's traits which
+// belongs to a disposable email provider. It does nothing if blocking is disabled for the
+// identity's schema.
+func (b *DisposableEmailBlocker) Validate(ctx context.Context, i *Identity) (err error) {
+	c := b.r.Config().SelfServiceDisposableEmails(ctx)
+	enabled := c.Enabled
+	if schemas, err := b.r.Config().IdentityTraitsSchemas(ctx); err == nil {
+		if s, err := schemas.FindSchemaByID(i.SchemaID); err == nil && s.SelfService.BlockDisposableEmails != nil {
+			enabled = *s.SelfService.BlockDisposableEmails
+		}
+	}
+	if !enabled {
+		return nil
+	}
+
+	ctx, span := b.r.Tracer(ctx).Tracer().Start(ctx, "identity.DisposableEmailBlocker.Validate")
+	defer otelx.End(span, &err)
+
+	return b.r.IdentityValidator().ValidateWithRunner(ctx, i, &schemaExtensionDisposableEmail{
+		b:      b,
+		remote: b.remoteDomains(ctx, c),
+	})
+}
+
+// remoteDomains returns the domains loaded from the blocklist URL. The blocklist is loaded again
+// once the refresh interval passed. If loading fails, the previously loaded domains are used.
+func (b *DisposableEmailBlocker) remoteDomains(ctx context.Context, c *config.DisposableEmails) map[string]struct{} {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if c.BlocklistURL == "" {
+		return nil
+	}
+	if b.remoteURL == c.BlocklistURL && time.Since(b.fetchedAt) < c.RefreshInterval {
+		return b.remote
+	}
+
+	if b.remoteURL != c.BlocklistURL {
+		b.remote = nil
+	}
+	b.remoteURL, b.fetchedAt = c.BlocklistURL, time.Now()
+
+	f := fetcher.NewFetcher(fetcher.WithClient(b.r.HTTPClient(ctx)))
+	res, err := f.FetchContext(ctx, c.BlocklistURL)
+	if err != nil {
+		b.r.Logger().WithError(err).WithField("blocklist_url", c.BlocklistURL).
+			Warn("Unable to load the disposable email blocklist, using the previously loaded blocklist.")
+		return b.remote
+	}
+
+	remote, err := parseDisposableEmailDomains(res)
+	if err != nil {
+		b.r.Logger().WithError(err).WithField("blocklist_url", c.BlocklistURL).
+			Warn("Unable to parse the disposable email blocklist, using the previously loaded blocklist.")
+		return b.remote
+	}
+
+	b.remote = remote
+	return b.remote
+}
+
+// isDisposable returns the blocked domain if the email address or one of its parent domains is
+// on the blocklist.
+func (e *schemaExtensionDisposableEmail) isDisposable(address string) (string, bool) {
+	_, domain, ok := strings.Cut(x.NormalizeEmailIdentifier(address), "@")
+	if !ok {
+		return "", false
+	}
+
+	for domain != "" {
+		if _, ok := e.b.bundled[domain]; ok {
+			return domain, true
+		}
+		if _, ok := e.remote[domain]; ok {
+			return domain, true
+		}
+
+		_, domain, _ = strings.Cut(domain, ".")
+	}
+	return "", false
+}
+
+func (e *schemaExtensionDisposableEmail) Run(ctx jsonschema.ValidationContext, s schema.ExtensionConfig, value interface{}) error {
+	format, _ := s.RawSchema["format"].(string)
+	if format != "email" && s.Verification.Via != AddressTypeEmail && s.Recovery.Via != AddressTypeEmail {
+		return nil
+	}
+
+	if domain, ok := e.isDisposable(fmt.Sprintf("%s", value)); ok {
+		return ctx.Error("disposable_email", "email domain %q is disposable", domain)
+	}
+	return nil
+}
+
+func (e *schemaExtensionDisposableEmail) Finish() error {
+	return nil
+}
+
+func parseDisposableEmailDomains(r io.Reader) (map[string]struct{}, error) {
+	domains := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[line] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
@@ -0,0 +1,114 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/x/pointerx"
+)
+
+func TestDisposableEmailBlocker(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	setSchemas := func(t *testing.T, block *bool) {
+		conf.MustSet(ctx, config.ViperKeyIdentitySchemas, []config.Schema{{
+			ID:          config.DefaultIdentityTraitsSchemaID,
+			URL:         "file://./stub/manager.schema.json",
+			SelfService: config.SchemaSelfService{BlockDisposableEmails: block},
+		}})
+	}
+	setSchemas(t, nil)
+	conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsEnabled, true)
+	b := identity.NewDisposableEmailBlocker(reg)
+
+	newIdentity := func(traits string) *identity.Identity {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(traits)
+		return i
+	}
+
+	assertDisposable := func(t *testing.T, err error, pointer, domain string) {
+		var e *jsonschema.ValidationError
+		require.ErrorAs(t, err, &e)
+		assert.Equal(t, pointer, e.InstancePtr)
+		assert.Equal(t, fmt.Sprintf("email domain %q is disposable", domain), e.Message)
+	}
+
+	t.Run("case=allows regular email addresses", func(t *testing.T) {
+		require.NoError(t, b.Validate(ctx, newIdentity(`{"email":"jane@ory.sh","email_verify":"jane@example.org"}`)))
+	})
+
+	t.Run("case=rejects domains of the bundled blocklist", func(t *testing.T) {
+		assertDisposable(t, b.Validate(ctx, newIdentity(`{"email":"jane@Mailinator.com"}`)), "#/traits/email", "mailinator.com")
+		assertDisposable(t, b.Validate(ctx, newIdentity(`{"email_recovery":"jane@inbox.yopmail.com"}`)), "#/traits/email_recovery", "yopmail.com")
+	})
+
+	t.Run("case=does nothing if disabled", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsEnabled, false)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsEnabled, true)
+		})
+
+		require.NoError(t, b.Validate(ctx, newIdentity(`{"email":"jane@mailinator.com"}`)))
+
+		t.Run("case=unless enabled for the schema", func(t *testing.T) {
+			setSchemas(t, pointerx.Ptr(true))
+			t.Cleanup(func() { setSchemas(t, nil) })
+
+			assertDisposable(t, b.Validate(ctx, newIdentity(`{"email":"jane@mailinator.com"}`)), "#/traits/email", "mailinator.com")
+		})
+	})
+
+	t.Run("case=can be disabled for a schema", func(t *testing.T) {
+		setSchemas(t, pointerx.Ptr(false))
+		t.Cleanup(func() { setSchemas(t, nil) })
+
+		require.NoError(t, b.Validate(ctx, newIdentity(`{"email":"jane@mailinator.com"}`)))
+	})
+
+	t.Run("case=loads the blocklist URL", func(t *testing.T) {
+		var requests atomic.Int32
+		blocklist := "# comment\n\nExample.org\n"
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			_, _ = w.Write([]byte(blocklist))
+		}))
+		t.Cleanup(ts.Close)
+
+		conf.MustSet(ctx, config.ViperKeyClientHTTPNoPrivateIPRanges, false)
+		conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsBlocklistURL, ts.URL)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsBlocklistURL, "")
+		})
+
+		assertDisposable(t, b.Validate(ctx, newIdentity(`{"email_verify":"jane@example.org"}`)), "#/traits/email_verify", "example.org")
+		assertDisposable(t, b.Validate(ctx, newIdentity(`{"email":"jane@mailinator.com"}`)), "#/traits/email", "mailinator.com")
+		assert.EqualValues(t, 1, requests.Load(), "the blocklist is only loaded once per refresh interval")
+
+		t.Run("case=refreshes the blocklist", func(t *testing.T) {
+			conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsRefreshInterval, "1ns")
+			t.Cleanup(func() {
+				conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsRefreshInterval, "24h")
+			})
+
+			blocklist = "example.com"
+			require.NoError(t, b.Validate(ctx, newIdentity(`{"email":"jane@example.org"}`)))
+			assertDisposable(t, b.Validate(ctx, newIdentity(`{"email":"jane@example.com"}`)), "#/traits/email", "example.com")
+			assert.EqualValues(t, 3, requests.Load())
+		})
+	})
+}
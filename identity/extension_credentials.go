@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/x"
 
 	"github.com/ory/jsonschema/v3"
@@ -25,11 +26,13 @@ type SchemaExtensionCredentials struct {
 	i         *Identity
 	v         map[CredentialsType][]string
 	addresses []CredentialsCodeAddress
+	emails    *config.EmailNormalization
+	region    string
 	l         sync.Mutex
 }
 
-func NewSchemaExtensionCredentials(i *Identity) *SchemaExtensionCredentials {
-	return &SchemaExtensionCredentials{i: i}
+func NewSchemaExtensionCredentials(i *Identity, emails *config.EmailNormalization, phoneRegion string) *SchemaExtensionCredentials {
+	return &SchemaExtensionCredentials{i: i, emails: emails, region: phoneRegion}
 }
 
 func (r *SchemaExtensionCredentials) setIdentifier(ct CredentialsType, value interface{}) {
@@ -55,12 +58,31 @@ func (r *SchemaExtensionCredentials) Run(ctx jsonschema.ValidationContext, s sch
 	r.l.Lock()
 	defer r.l.Unlock()
 
+	// Phone numbers are stored in E.164 format so that the same number always results in the
+	// same identifier, regardless of how it was formatted. Invalid numbers are reported by the
+	// format validator.
+	format, _ := s.RawSchema["format"].(string)
+	if format == "tel" {
+		value = x.NormalizePhoneIdentifier(strings.TrimSpace(fmt.Sprintf("%s", value)), r.region)
+	}
+
+	// Email identifiers are canonicalized to prevent registering several accounts for the
+	// same mailbox. The value itself is kept as is, as it is used to deliver messages.
+	identifier := value
+	if format == "email" {
+		identifier = x.CanonicalizeEmailIdentifier(fmt.Sprintf("%s", value), r.emails)
+	}
+
 	if s.Credentials.Password.Identifier {
-		r.setIdentifier(CredentialsTypePassword, value)
+		r.setIdentifier(CredentialsTypePassword, identifier)
 	}
 
 	if s.Credentials.WebAuthn.Identifier {
-		r.setIdentifier(CredentialsTypeWebAuthn, value)
+		r.setIdentifier(CredentialsTypeWebAuthn, identifier)
+	}
+
+	if s.Credentials.ExternalMFA.Identifier {
+		r.setIdentifier(CredentialsTypeExternalMFA, identifier)
 	}
 
 	if s.Credentials.Code.Identifier {
@@ -81,7 +103,7 @@ func (r *SchemaExtensionCredentials) Run(ctx jsonschema.ValidationContext, s sch
 
 		var conf CredentialsCode
 		conf.Addresses = r.addresses
-		value, err := x.NormalizeIdentifier(fmt.Sprintf("%s", value), string(via))
+		value, err := x.NormalizeIdentifier(fmt.Sprintf("%s", value), string(via), r.region)
 		if err != nil {
 			return &jsonschema.ValidationError{Message: err.Error()}
 		}
@@ -108,6 +130,9 @@ func (r *SchemaExtensionCredentials) Run(ctx jsonschema.ValidationContext, s sch
 
 		r.v[CredentialsTypeCodeAuth] = stringslice.Unique(append(r.v[CredentialsTypeCodeAuth],
 			lo.Map(conf.Addresses, func(item CredentialsCodeAddress, _ int) string {
+				if item.Channel == CodeChannelEmail {
+					return x.CanonicalizeEmailIdentifier(item.Address, r.emails)
+				}
 				return item.Address
 			})...,
 		))
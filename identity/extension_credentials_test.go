@@ -16,6 +16,7 @@ import (
 	"github.com/ory/jsonschema/v3"
 	_ "github.com/ory/jsonschema/v3/fileloader"
 
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/schema"
 
@@ -33,6 +34,7 @@ func TestSchemaExtensionCredentials(t *testing.T) {
 		expectedIdentifiers []string
 		existing            *identity.Credentials
 		ct                  identity.CredentialsType
+		emails              config.EmailNormalization
 	}{
 		{
 			doc:                 `{"email":"foo@ory.sh"}`,
@@ -151,6 +153,32 @@ func TestSchemaExtensionCredentials(t *testing.T) {
 			},
 			ct: identity.CredentialsTypeCodeAuth,
 		},
+		{
+			doc:                 `{"email":"FOO@ory.sh","phone":"+49 176 671 11 638"}`,
+			schema:              "file://./stub/extension/credentials/code-phone-email.schema.json",
+			expectedIdentifiers: []string{"+4917667111638", "foo@ory.sh"},
+			ct:                  identity.CredentialsTypePassword,
+		},
+		{
+			doc:                 `{"email":"John.Doe+news@GoogleMail.com"}`,
+			schema:              "file://./stub/extension/credentials/code-phone-email.schema.json",
+			expectedIdentifiers: []string{"johndoe@gmail.com"},
+			ct:                  identity.CredentialsTypePassword,
+			emails:              config.EmailNormalization{StripPlusAlias: true, StripGmailDots: true},
+		},
+		{
+			doc:                 `{"email":"John.Doe+news@GoogleMail.com"}`,
+			schema:              "file://./stub/extension/credentials/code-phone-email.schema.json",
+			expectedIdentifiers: []string{"johndoe@gmail.com"},
+			ct:                  identity.CredentialsTypeCodeAuth,
+			emails:              config.EmailNormalization{StripPlusAlias: true, StripGmailDots: true},
+		},
+		{
+			doc:                 `{"email":"foo@ory.sh","username":"Foo"}`,
+			schema:              "file://./stub/extension/credentials/external_mfa.schema.json",
+			expectedIdentifiers: []string{"foo"},
+			ct:                  identity.CredentialsTypeExternalMFA,
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
 			c := jsonschema.NewCompiler()
@@ -158,7 +186,7 @@ func TestSchemaExtensionCredentials(t *testing.T) {
 			require.NoError(t, err)
 
 			i := new(identity.Identity)
-			e := identity.NewSchemaExtensionCredentials(i)
+			e := identity.NewSchemaExtensionCredentials(i, &tc.emails, "")
 			if tc.existing != nil {
 				i.SetCredentials(tc.ct, *tc.existing)
 			}
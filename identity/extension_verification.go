@@ -13,6 +13,7 @@ import (
 
 	"github.com/ory/jsonschema/v3"
 	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x"
 )
 
 func init() {
@@ -23,13 +24,14 @@ func init() {
 
 type SchemaExtensionVerification struct {
 	lifespan time.Duration
+	region   string
 	l        sync.Mutex
 	v        []VerifiableAddress
 	i        *Identity
 }
 
-func NewSchemaExtensionVerification(i *Identity, lifespan time.Duration) *SchemaExtensionVerification {
-	return &SchemaExtensionVerification{i: i, lifespan: lifespan}
+func NewSchemaExtensionVerification(i *Identity, lifespan time.Duration, phoneRegion string) *SchemaExtensionVerification {
+	return &SchemaExtensionVerification{i: i, lifespan: lifespan, region: phoneRegion}
 }
 
 const (
@@ -76,6 +78,8 @@ func (r *SchemaExtensionVerification) Run(ctx jsonschema.ValidationContext, s sc
 	switch formatString {
 	case "email":
 		normalized = strings.ToLower(strings.TrimSpace(fmt.Sprintf("%s", value)))
+	case "tel":
+		normalized = x.NormalizePhoneIdentifier(strings.TrimSpace(fmt.Sprintf("%s", value)), r.region)
 	default:
 		normalized = strings.TrimSpace(fmt.Sprintf("%s", value))
 	}
@@ -86,14 +90,15 @@ func (r *SchemaExtensionVerification) Run(ctx jsonschema.ValidationContext, s sc
 }
 
 func (r *SchemaExtensionVerification) Finish() error {
-	r.i.VerifiableAddresses = merge(r.v, r.i.VerifiableAddresses)
+	r.i.VerifiableAddresses = r.merge(r.v, r.i.VerifiableAddresses)
 	return nil
 }
 
 // merge merges the base with the overrides through comparison with `has`. It changes the base slice in place.
-func merge(base []VerifiableAddress, overrides []VerifiableAddress) []VerifiableAddress {
+func (r *SchemaExtensionVerification) merge(base []VerifiableAddress, overrides []VerifiableAddress) []VerifiableAddress {
 	for i := range base {
-		if override := has(overrides, &base[i]); override != nil {
+		if override := r.has(overrides, &base[i]); override != nil {
+			override.Value = base[i].Value
 			base[i] = *override
 		}
 	}
@@ -102,21 +107,26 @@ func merge(base []VerifiableAddress, overrides []VerifiableAddress) []Verifiable
 }
 
 func (r *SchemaExtensionVerification) appendAddress(address *VerifiableAddress) {
-	if h := has(r.i.VerifiableAddresses, address); h != nil {
-		if has(r.v, address) == nil {
+	if h := r.has(r.i.VerifiableAddresses, address); h != nil {
+		h.Value = address.Value
+		if r.has(r.v, address) == nil {
 			r.v = append(r.v, *h)
 		}
 		return
 	}
 
-	if has(r.v, address) == nil {
+	if r.has(r.v, address) == nil {
 		r.v = append(r.v, *address)
 	}
 }
 
-func has(haystack []VerifiableAddress, needle *VerifiableAddress) *VerifiableAddress {
+func (r *SchemaExtensionVerification) has(haystack []VerifiableAddress, needle *VerifiableAddress) *VerifiableAddress {
 	for _, has := range haystack {
-		if has.Value == needle.Value && has.Via == needle.Via {
+		if has.Via != needle.Via {
+			continue
+		}
+		// Addresses stored before phone numbers were normalized still match their E.164 form.
+		if has.Value == needle.Value || (has.Via == ChannelTypeSMS && x.NormalizePhoneIdentifier(has.Value, r.region) == x.NormalizePhoneIdentifier(needle.Value, r.region)) {
 			return &has
 		}
 	}
@@ -335,6 +335,50 @@ func TestSchemaExtensionVerification(t *testing.T) {
 				doc:       `{"phones":["+18004444444","+18004444444","12112112"], "username": "+380634872774"}`,
 				expectErr: errors.New("I[#/phones/2] S[#/properties/phones/items/format] \"12112112\" is not valid \"tel\""),
 			},
+			{
+				name:   "phone:must normalize to E.164",
+				schema: phoneSchemaPath,
+				doc:    `{"phones":["+44 20 8759 9036","+442087599036"], "username": "+1 (800) 444-4444"}`,
+				expect: []VerifiableAddress{
+					{
+						Value:      "+442087599036",
+						Verified:   false,
+						Status:     VerifiableAddressStatusPending,
+						Via:        ChannelTypeSMS,
+						IdentityID: iid,
+					},
+					{
+						Value:      "+18004444444",
+						Verified:   false,
+						Status:     VerifiableAddressStatusPending,
+						Via:        ChannelTypeSMS,
+						IdentityID: iid,
+					},
+				},
+			},
+			{
+				name:   "phone:must keep addresses stored before normalization",
+				schema: phoneSchemaPath,
+				doc:    `{"phones":["+442087599036"]}`,
+				existing: []VerifiableAddress{
+					{
+						Value:      "+44 20 8759 9036",
+						Verified:   true,
+						Status:     VerifiableAddressStatusCompleted,
+						Via:        ChannelTypeSMS,
+						IdentityID: iid,
+					},
+				},
+				expect: []VerifiableAddress{
+					{
+						Value:      "+442087599036",
+						Verified:   true,
+						Status:     VerifiableAddressStatusCompleted,
+						Via:        ChannelTypeSMS,
+						IdentityID: iid,
+					},
+				},
+			},
 			{
 				name:      "missing format returns an error",
 				schema:    missingFormatSchemaPath,
@@ -393,7 +437,7 @@ func TestSchemaExtensionVerification(t *testing.T) {
 				runner, err := schema.NewExtensionRunner(ctx)
 				require.NoError(t, err)
 
-				e := NewSchemaExtensionVerification(id, time.Minute)
+				e := NewSchemaExtensionVerification(id, time.Minute, "")
 				runner.AddRunner(e).Register(c)
 
 				err = c.MustCompile(ctx, tc.schema).Validate(bytes.NewBufferString(tc.doc))
@@ -475,7 +519,7 @@ func TestMergeVerifiableAddresses(t *testing.T) {
 		},
 	} {
 		t.Run("case="+tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.expected, merge(tt.base, tt.overrides))
+			assert.Equal(t, tt.expected, new(SchemaExtensionVerification).merge(tt.base, tt.overrides))
 		})
 	}
 }
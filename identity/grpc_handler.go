@@ -0,0 +1,272 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/ory/herodot"
+	identityv1 "github.com/ory/kratos/gen/identity/v1"
+	"github.com/ory/x/pagination/keysetpagination"
+	"github.com/ory/x/sqlcon"
+	"github.com/ory/x/sqlxx"
+)
+
+// GRPCHandler implements the identity service of the gRPC API.
+type GRPCHandler struct {
+	identityv1.UnimplementedIdentityServiceServer
+	h *Handler
+}
+
+var _ identityv1.IdentityServiceServer = (*GRPCHandler)(nil)
+
+func NewGRPCHandler(h *Handler) *GRPCHandler {
+	return &GRPCHandler{h: h}
+}
+
+func (g *GRPCHandler) GetIdentity(ctx context.Context, req *identityv1.GetIdentityRequest) (*identityv1.GetIdentityResponse, error) {
+	id, err := parseGRPCIdentityID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := g.h.r.PrivilegedIdentityPool().GetIdentity(ctx, id, ExpandDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	pi, err := g.protoIdentity(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	return &identityv1.GetIdentityResponse{Identity: pi}, nil
+}
+
+func (g *GRPCHandler) ListIdentities(req *identityv1.ListIdentitiesRequest, stream identityv1.IdentityService_ListIdentitiesServer) error {
+	ctx := stream.Context()
+
+	size := int(req.GetPageSize())
+	if size <= 0 {
+		size = 250
+	} else if size > 1000 {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The page size must not be larger than 1000."))
+	}
+
+	pagination := []keysetpagination.Option{keysetpagination.WithSize(size)}
+	for {
+		identities, next, err := g.h.r.PrivilegedIdentityPool().ListIdentities(ctx, ListIdentityParameters{
+			Expand:                ExpandDefault,
+			CredentialsIdentifier: req.GetCredentialsIdentifier(),
+			KeySetPagination:      pagination,
+		})
+		if err != nil {
+			return err
+		}
+
+		res := &identityv1.ListIdentitiesResponse{Identities: make([]*identityv1.Identity, 0, len(identities))}
+		for k := range identities {
+			pi, err := g.protoIdentity(ctx, &identities[k])
+			if err != nil {
+				return err
+			}
+			res.Identities = append(res.Identities, pi)
+		}
+
+		if len(res.Identities) > 0 {
+			if err := stream.Send(res); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		if next.IsLast() || len(identities) == 0 {
+			return nil
+		}
+		pagination = next.ToOptions()
+	}
+}
+
+func (g *GRPCHandler) CreateIdentity(ctx context.Context, req *identityv1.CreateIdentityRequest) (*identityv1.CreateIdentityResponse, error) {
+	cr := CreateIdentityBody{
+		SchemaID: req.GetSchemaId(),
+		State:    State(req.GetState()),
+	}
+	for _, field := range []struct {
+		from *structpb.Struct
+		to   *json.RawMessage
+	}{
+		{req.GetTraits(), &cr.Traits},
+		{req.GetMetadataPublic(), &cr.MetadataPublic},
+		{req.GetMetadataAdmin(), &cr.MetadataAdmin},
+	} {
+		raw, err := structToJSON(field.from)
+		if err != nil {
+			return nil, err
+		}
+		*field.to = raw
+	}
+
+	i, err := g.h.identityFromCreateIdentityBody(ctx, &cr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.h.r.IdentityManager().Create(ctx, i); err != nil {
+		if errors.Is(err, sqlcon.ErrUniqueViolation) {
+			return nil, errors.WithStack(herodot.ErrConflict.WithReason("This identity conflicts with another identity that already exists."))
+		}
+		return nil, err
+	}
+	g.h.r.Audit().WithField("identity_id", i.ID).Info("An administrator created an identity using the gRPC API.")
+
+	pi, err := g.protoIdentity(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	return &identityv1.CreateIdentityResponse{Identity: pi}, nil
+}
+
+func (g *GRPCHandler) UpdateIdentity(ctx context.Context, req *identityv1.UpdateIdentityRequest) (*identityv1.UpdateIdentityResponse, error) {
+	id, err := parseGRPCIdentityID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := g.h.r.PrivilegedIdentityPool().GetIdentityConfidential(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetSchemaId() != "" {
+		i.SchemaID = req.GetSchemaId()
+	}
+
+	if state := State(req.GetState()); state != "" && i.State != state {
+		if err := i.State.ValidateTransition(state); err != nil {
+			return nil, err
+		}
+
+		stateChangedAt := sqlxx.NullTime(time.Now())
+		i.State = state
+		i.StateChangedAt = &stateChangedAt
+		i.StateReason = ""
+	}
+
+	for _, field := range []struct {
+		from *structpb.Struct
+		to   func(json.RawMessage)
+	}{
+		{req.GetTraits(), func(raw json.RawMessage) { i.Traits = Traits(raw) }},
+		{req.GetMetadataPublic(), func(raw json.RawMessage) { i.MetadataPublic = sqlxx.NullJSONRawMessage(raw) }},
+		{req.GetMetadataAdmin(), func(raw json.RawMessage) { i.MetadataAdmin = sqlxx.NullJSONRawMessage(raw) }},
+	} {
+		raw, err := structToJSON(field.from)
+		if err != nil {
+			return nil, err
+		}
+		field.to(raw)
+	}
+
+	if err := g.h.r.IdentityManager().Update(ctx, i, ManagerAllowWriteProtectedTraits); err != nil {
+		return nil, err
+	}
+	g.h.r.Audit().WithField("identity_id", i.ID).Info("An administrator updated an identity using the gRPC API.")
+
+	pi, err := g.protoIdentity(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	return &identityv1.UpdateIdentityResponse{Identity: pi}, nil
+}
+
+func (g *GRPCHandler) DeleteIdentity(ctx context.Context, req *identityv1.DeleteIdentityRequest) (*identityv1.DeleteIdentityResponse, error) {
+	id, err := parseGRPCIdentityID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.h.r.PrivilegedIdentityPool().DeleteIdentity(ctx, id); err != nil {
+		return nil, err
+	}
+	g.h.r.Audit().WithField("identity_id", id).Info("An administrator deleted an identity using the gRPC API.")
+
+	return &identityv1.DeleteIdentityResponse{}, nil
+}
+
+// protoIdentity converts the identity without the fields which the admin API token of the call is
+// not allowed to read.
+func (g *GRPCHandler) protoIdentity(ctx context.Context, i *Identity) (*identityv1.Identity, error) {
+	redacted, err := RedactForAdminAPIToken(ctx, *i)
+	if err != nil {
+		return nil, err
+	}
+	return ProtoIdentity(&redacted)
+}
+
+// ProtoIdentity converts the identity to its representation in the gRPC API. Credentials are never
+// included.
+func ProtoIdentity(i *Identity) (*identityv1.Identity, error) {
+	if i == nil {
+		return nil, nil
+	}
+
+	pi := &identityv1.Identity{
+		Id:        i.ID.String(),
+		SchemaId:  i.SchemaID,
+		State:     string(i.State),
+		CreatedAt: timestamppb.New(i.CreatedAt),
+		UpdatedAt: timestamppb.New(i.UpdatedAt),
+	}
+	for _, field := range []struct {
+		raw []byte
+		to  **structpb.Struct
+	}{
+		{i.Traits, &pi.Traits},
+		{i.MetadataPublic, &pi.MetadataPublic},
+		{i.MetadataAdmin, &pi.MetadataAdmin},
+	} {
+		s, err := jsonToStruct(field.raw)
+		if err != nil {
+			return nil, err
+		}
+		*field.to = s
+	}
+	return pi, nil
+}
+
+func parseGRPCIdentityID(id string) (uuid.UUID, error) {
+	parsed, err := uuid.FromString(id)
+	if err != nil {
+		return uuid.Nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The identity ID %q is not a valid UUID.", id).WithWrap(err))
+	}
+	return parsed, nil
+}
+
+func jsonToStruct(raw []byte) (*structpb.Struct, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, err := structpb.NewStruct(m)
+	return s, errors.WithStack(err)
+}
+
+func structToJSON(s *structpb.Struct) (json.RawMessage, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(s.AsMap())
+	return raw, errors.WithStack(err)
+}
@@ -7,7 +7,9 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -29,6 +31,7 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
+	"github.com/tidwall/sjson"
 
 	"github.com/ory/x/decoderx"
 	"github.com/ory/x/jsonx"
@@ -40,9 +43,13 @@ import (
 )
 
 const (
-	RouteCollection     = "/identities"
-	RouteItem           = RouteCollection + "/:id"
-	RouteCredentialItem = RouteItem + "/credentials/:type"
+	RouteCollection         = "/identities"
+	RouteItem               = RouteCollection + "/:id"
+	RouteCredentialItem     = RouteItem + "/credentials/:type"
+	RouteCredentialsSummary = RouteItem + "/credentials/summary"
+	RouteWebhookItem        = RouteItem + "/webhook"
+	RoutePasswordResetItem  = RouteItem + "/password-reset"
+	RouteLookupSecretsItem  = RouteItem + "/lookup-secrets"
 
 	BatchPatchIdentitiesLimit = 2000
 )
@@ -57,6 +64,11 @@ type (
 		x.CSRFProvider
 		cipher.Provider
 		hash.HashProvider
+		DerivedTraitsMapperProvider
+		WebhookPersistenceProvider
+		WebhookSenderProvider
+		CredentialsUsagePersistenceProvider
+		x.LoggingProvider
 	}
 	HandlerProvider interface {
 		IdentityHandler() *Handler
@@ -82,8 +94,14 @@ func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
 	h.r.CSRFHandler().IgnoreGlobs(
 		RouteCollection, RouteCollection+"/*",
 		RouteCollection+"/*/credentials/*",
+		RouteCollection+"/*/webhook",
+		RouteCollection+"/*/password-reset",
+		RouteCollection+"/*/lookup-secrets",
 		x.AdminPrefix+RouteCollection, x.AdminPrefix+RouteCollection+"/*",
 		x.AdminPrefix+RouteCollection+"/*/credentials/*",
+		x.AdminPrefix+RouteCollection+"/*/webhook",
+		x.AdminPrefix+RouteCollection+"/*/password-reset",
+		x.AdminPrefix+RouteCollection+"/*/lookup-secrets",
 	)
 
 	public.GET(RouteCollection, x.RedirectToAdminRoute(h.r))
@@ -93,6 +111,13 @@ func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
 	public.PUT(RouteItem, x.RedirectToAdminRoute(h.r))
 	public.PATCH(RouteItem, x.RedirectToAdminRoute(h.r))
 	public.DELETE(RouteCredentialItem, x.RedirectToAdminRoute(h.r))
+	public.GET(RouteCredentialsSummary, x.RedirectToAdminRoute(h.r))
+	public.GET(RouteWebhookItem, x.RedirectToAdminRoute(h.r))
+	public.PUT(RouteWebhookItem, x.RedirectToAdminRoute(h.r))
+	public.DELETE(RouteWebhookItem, x.RedirectToAdminRoute(h.r))
+	public.PUT(RoutePasswordResetItem, x.RedirectToAdminRoute(h.r))
+	public.DELETE(RoutePasswordResetItem, x.RedirectToAdminRoute(h.r))
+	public.POST(RouteLookupSecretsItem, x.RedirectToAdminRoute(h.r))
 
 	public.GET(x.AdminPrefix+RouteCollection, x.RedirectToAdminRoute(h.r))
 	public.GET(x.AdminPrefix+RouteItem, x.RedirectToAdminRoute(h.r))
@@ -101,6 +126,13 @@ func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
 	public.PUT(x.AdminPrefix+RouteItem, x.RedirectToAdminRoute(h.r))
 	public.PATCH(x.AdminPrefix+RouteItem, x.RedirectToAdminRoute(h.r))
 	public.DELETE(x.AdminPrefix+RouteCredentialItem, x.RedirectToAdminRoute(h.r))
+	public.GET(x.AdminPrefix+RouteCredentialsSummary, x.RedirectToAdminRoute(h.r))
+	public.GET(x.AdminPrefix+RouteWebhookItem, x.RedirectToAdminRoute(h.r))
+	public.PUT(x.AdminPrefix+RouteWebhookItem, x.RedirectToAdminRoute(h.r))
+	public.DELETE(x.AdminPrefix+RouteWebhookItem, x.RedirectToAdminRoute(h.r))
+	public.PUT(x.AdminPrefix+RoutePasswordResetItem, x.RedirectToAdminRoute(h.r))
+	public.DELETE(x.AdminPrefix+RoutePasswordResetItem, x.RedirectToAdminRoute(h.r))
+	public.POST(x.AdminPrefix+RouteLookupSecretsItem, x.RedirectToAdminRoute(h.r))
 }
 
 func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
@@ -114,6 +146,16 @@ func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
 	admin.PUT(RouteItem, h.update)
 
 	admin.DELETE(RouteCredentialItem, h.deleteIdentityCredentials)
+	admin.GET(RouteCredentialsSummary, h.getIdentityCredentialsSummary)
+
+	admin.GET(RouteWebhookItem, h.getIdentityWebhook)
+	admin.PUT(RouteWebhookItem, h.setIdentityWebhook)
+	admin.DELETE(RouteWebhookItem, h.deleteIdentityWebhook)
+
+	admin.PUT(RoutePasswordResetItem, h.requireIdentityPasswordReset)
+	admin.DELETE(RoutePasswordResetItem, h.cancelIdentityPasswordReset)
+
+	admin.POST(RouteLookupSecretsItem, h.regenerateIdentityLookupSecrets)
 }
 
 // Paginated Identity List Response
@@ -300,7 +342,18 @@ func (h *Handler) list(w http.ResponseWriter, r *http.Request, _ httprouter.Para
 			return
 		}
 
-		isam[i] = WithCredentialsAndAdminMetadataInJSON(*emit)
+		if err := h.r.IdentityDerivedTraitsMapper().DeriveTraits(r.Context(), emit); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+
+		redacted, err := RedactForAdminAPIToken(r.Context(), *emit)
+		if err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+
+		isam[i] = WithCredentialsAndAdminMetadataInJSON(redacted)
 	}
 
 	h.r.Writer().Write(w, r, isam)
@@ -375,7 +428,42 @@ func (h *Handler) get(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
-	h.r.Writer().Write(w, r, WithCredentialsAndAdminMetadataInJSON(*emit))
+
+	if err := h.r.IdentityDerivedTraitsMapper().DeriveTraits(r.Context(), emit); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	redacted, err := RedactForAdminAPIToken(r.Context(), *emit)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if x.NotModified(w, r, identityETag(r, &redacted)) {
+		return
+	}
+	h.r.Writer().Write(w, r, WithCredentialsAndAdminMetadataInJSON(redacted))
+}
+
+// identityETag derives the entity tag of an identity from the update timestamps of the identity,
+// its credentials and addresses. The query and the admin API token are included because they
+// change which fields are returned.
+func identityETag(r *http.Request, i *Identity) string {
+	values := []any{i.ID, i.UpdatedAt.UnixNano(), r.URL.RawQuery}
+	if t, ok := x.AdminAPITokenFromContext(r.Context()); ok {
+		values = append(values, t.ID)
+	}
+	for _, ct := range slices.Sorted(maps.Keys(i.Credentials)) {
+		values = append(values, ct, i.Credentials[ct].UpdatedAt.UnixNano())
+	}
+	for _, a := range i.VerifiableAddresses {
+		values = append(values, a.ID, a.UpdatedAt.UnixNano())
+	}
+	for _, a := range i.RecoveryAddresses {
+		values = append(values, a.ID, a.UpdatedAt.UnixNano())
+	}
+	return x.WeakETag(values...)
 }
 
 // Create Identity Parameters
@@ -436,6 +524,12 @@ type CreateIdentityBody struct {
 	// required: false
 	State State `json:"state"`
 
+	// StateReason is the reason code of the state, for example `fraud_suspected`. It consists of up to
+	// 64 lowercase letters, digits, and underscores.
+	//
+	// required: false
+	StateReason string `json:"state_reason"`
+
 	// OrganizationID is the ID of the organization to which the identity belongs.
 	//
 	// required: false
@@ -473,6 +567,13 @@ type AdminIdentityImportCredentialsPasswordConfig struct {
 
 	// If set to true, the password will be migrated using the password migration hook.
 	UsePasswordMigrationHook bool `json:"use_password_migration_hook,omitempty"`
+
+	// If set to true, the password is temporary. The identity can sign in with it but must
+	// set a new password before the session can be used for anything else.
+	Temporary bool `json:"temporary,omitempty"`
+
+	// If set, the password is temporary and can no longer be used to sign in after this time.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 // Create Identity and Import Social Sign In Credentials
@@ -556,6 +657,13 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 		}
 		return
 	}
+	h.r.Audit().WithRequest(r).WithField("identity_id", i.ID).Info("An administrator created an identity.")
+
+	redacted, err := RedactForAdminAPIToken(r.Context(), *i)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
 
 	h.r.Writer().WriteCreated(w, r,
 		urlx.AppendPaths(
@@ -563,7 +671,7 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 			"identities",
 			i.ID.String(),
 		).String(),
-		WithCredentialsMetadataAndAdminMetadataInJSON(*i),
+		WithCredentialsMetadataAndAdminMetadataInJSON(redacted),
 	)
 }
 
@@ -576,12 +684,16 @@ func (h *Handler) identityFromCreateIdentityBody(ctx context.Context, cr *Create
 		}
 		state = cr.State
 	}
+	if err := ValidateStateReason(cr.StateReason); err != nil {
+		return nil, err
+	}
 
 	i := &Identity{
 		SchemaID:            cr.SchemaID,
 		Traits:              []byte(cr.Traits),
 		State:               state,
 		StateChangedAt:      &stateChangedAt,
+		StateReason:         cr.StateReason,
 		VerifiableAddresses: cr.VerifiableAddresses,
 		RecoveryAddresses:   cr.RecoveryAddresses,
 		MetadataAdmin:       []byte(cr.MetadataAdmin),
@@ -684,6 +796,7 @@ func (h *Handler) batchPatchIdentities(w http.ResponseWriter, r *http.Request, _
 				res.Identities[resIdx].Error = failed.Error
 			} else {
 				res.Identities[resIdx].IdentityID = &ident.ID
+				h.r.Audit().WithRequest(r).WithField("identity_id", ident.ID).Info("An administrator created an identity.")
 			}
 		}
 	}
@@ -743,6 +856,12 @@ type UpdateIdentityBody struct {
 	//
 	// required: true
 	State State `json:"state"`
+
+	// StateReason is the reason code of the state change, for example `fraud_suspected`. It is only
+	// applied if the state changes.
+	//
+	// required: false
+	StateReason string `json:"state_reason"`
 }
 
 // swagger:route PUT /admin/identities/{id} identity updateIdentity
@@ -793,11 +912,16 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request, ps httprouter.P
 			h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("%s", err).WithWrap(err)))
 			return
 		}
+		if err := identity.State.ValidateTransition(ur.State); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
 
 		stateChangedAt := sqlxx.NullTime(time.Now())
 
 		identity.State = ur.State
 		identity.StateChangedAt = &stateChangedAt
+		identity.StateReason = ur.StateReason
 	}
 
 	identity.Traits = []byte(ur.Traits)
@@ -820,8 +944,15 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
+	h.r.Audit().WithRequest(r).WithField("identity_id", identity.ID).Info("An administrator updated an identity.")
 
-	h.r.Writer().Write(w, r, WithCredentialsMetadataAndAdminMetadataInJSON(*identity))
+	redacted, err := RedactForAdminAPIToken(r.Context(), *identity)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, WithCredentialsMetadataAndAdminMetadataInJSON(redacted))
 }
 
 // Delete Identity Parameters
@@ -859,11 +990,25 @@ type deleteIdentity struct {
 //	  404: errorGeneric
 //	  default: errorGeneric
 func (h *Handler) delete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	if err := h.r.PrivilegedIdentityPool().DeleteIdentity(r.Context(), x.ParseUUID(ps.ByName("id"))); err != nil {
+	id := x.ParseUUID(ps.ByName("id"))
+
+	// The webhook is deleted together with the identity, so we need to load it first.
+	webhook, err := h.r.IdentityWebhookPersister().GetIdentityWebhook(r.Context(), id)
+	if err != nil && !errors.Is(err, sqlcon.ErrNoRows) {
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
 
+	if err := h.r.PrivilegedIdentityPool().DeleteIdentity(r.Context(), id); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+	h.r.Audit().WithRequest(r).WithField("identity_id", id).Info("An administrator deleted an identity.")
+
+	if webhook != nil {
+		h.r.IdentityWebhookSender().Deliver(r.Context(), webhook, WebhookEventIdentityDeleted, nil)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -924,6 +1069,7 @@ func (h *Handler) patch(w http.ResponseWriter, r *http.Request, ps httprouter.Pa
 
 	credentials := identity.Credentials
 	oldState := identity.State
+	oldStateReason := identity.StateReason
 
 	patchedIdentity := WithAdminMetadataInJSON(*identity)
 
@@ -944,20 +1090,19 @@ func (h *Handler) patch(w http.ResponseWriter, r *http.Request, ps httprouter.Pa
 
 	if oldState != patchedIdentity.State {
 		// Check if the changed state was actually valid
-		if err := patchedIdentity.State.IsValid(); err != nil {
-			h.r.Writer().WriteError(w, r, errors.WithStack(
-				herodot.
-					ErrBadRequest.
-					WithReasonf("The supplied state ('%s') was not valid. Valid states are ('%s', '%s').", string(patchedIdentity.State), StateActive, StateInactive).
-					WithErrorf("%v", err).
-					WithWrap(err),
-			))
+		if err := oldState.ValidateTransition(patchedIdentity.State); err != nil {
+			h.r.Writer().WriteError(w, r, err)
 			return
 		}
 
 		// If the state changed, we need to update the timestamp of it
 		stateChangedAt := sqlxx.NullTime(time.Now())
 		patchedIdentity.StateChangedAt = &stateChangedAt
+
+		// The reason of the previous state does not apply to the new one.
+		if patchedIdentity.StateReason == oldStateReason {
+			patchedIdentity.StateReason = ""
+		}
 	}
 
 	updatedIdentity := Identity(patchedIdentity)
@@ -970,8 +1115,15 @@ func (h *Handler) patch(w http.ResponseWriter, r *http.Request, ps httprouter.Pa
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
+	h.r.Audit().WithRequest(r).WithField("identity_id", updatedIdentity.ID).Info("An administrator patched an identity.")
+
+	redacted, err := RedactForAdminAPIToken(r.Context(), updatedIdentity)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
 
-	h.r.Writer().Write(w, r, WithCredentialsMetadataAndAdminMetadataInJSON(updatedIdentity))
+	h.r.Writer().Write(w, r, WithCredentialsMetadataAndAdminMetadataInJSON(redacted))
 }
 
 // Delete Credential Parameters
@@ -1078,6 +1230,99 @@ func (h *Handler) deleteIdentityCredentials(w http.ResponseWriter, r *http.Reque
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
+	h.r.Audit().WithRequest(r).
+		WithField("identity_id", identity.ID).
+		WithField("credentials_type", cred.Type).
+		Info("An administrator deleted identity credentials.")
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Get Identity Credentials Summary Parameters
+//
+// swagger:parameters getIdentityCredentialsSummary
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type getIdentityCredentialsSummary struct {
+	// ID must be set to the ID of identity you want to summarize the credentials of.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+}
+
+// swagger:route GET /admin/identities/{id}/credentials/summary identity getIdentityCredentialsSummary
+//
+// # Get a Summary of the Credentials of an Identity
+//
+// Returns which credential methods the identity has set up, which authenticator assurance level
+// it is able to reach, when each method was last used, and the metadata of its WebAuthn
+// authenticators. The summary never contains secrets, so it is safe to show in support tooling.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: identityCredentialsSummary
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) getIdentityCredentialsSummary(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+	i, err := h.r.PrivilegedIdentityPool().GetIdentityConfidential(ctx, x.ParseUUID(ps.ByName("id")))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	lastUsed, err := h.r.CredentialsUsagePersister().GetCredentialsLastUsed(ctx, i.ID)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	summary, err := h.r.IdentityManager().SummarizeCredentials(ctx, i, lastUsed)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, summary)
+}
+
+// RedactForAdminAPIToken returns a copy of the identity without the fields which the admin API
+// token of the request is not allowed to read.
+func RedactForAdminAPIToken(ctx context.Context, i Identity) (Identity, error) {
+	t, ok := x.AdminAPITokenFromContext(ctx)
+	if !ok {
+		return i, nil
+	}
+
+	if !t.HasScope(config.AdminAPITokenScopeCredentialsRead) && len(i.Credentials) > 0 {
+		credentials := make(map[CredentialsType]Credentials, len(i.Credentials))
+		for k, c := range i.Credentials {
+			c.Config = nil
+			credentials[k] = c
+		}
+		i.Credentials = credentials
+	}
+
+	if !t.HasScope(config.AdminAPITokenScopeMetadataAdminRead) {
+		i.MetadataAdmin = nil
+	}
+
+	for _, path := range t.RedactedTraits {
+		traits, err := sjson.DeleteBytes(i.Traits, path)
+		if err != nil {
+			return i, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to redact the trait %q.", path))
+		}
+		i.Traits = traits
+	}
+
+	return i, nil
+}
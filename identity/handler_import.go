@@ -6,6 +6,7 @@ package identity
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -43,8 +44,18 @@ func (h *Handler) importCredentials(ctx context.Context, i *Identity, creds *Ide
 }
 
 func (h *Handler) importPasswordCredentials(ctx context.Context, i *Identity, creds *AdminIdentityImportCredentialsPassword) (err error) {
+	// Temporary passwords must be changed after the next login.
+	temporary := creds.Config.Temporary || creds.Config.ExpiresAt != nil
+	if temporary {
+		i.PasswordResetRequired = true
+	}
+
 	if creds.Config.UsePasswordMigrationHook {
-		return i.SetCredentialsWithConfig(CredentialsTypePassword, Credentials{}, CredentialsPassword{UsePasswordMigrationHook: true})
+		return i.SetCredentialsWithConfig(CredentialsTypePassword, Credentials{}, CredentialsPassword{
+			UsePasswordMigrationHook: true,
+			Temporary:                temporary,
+			ExpiresAt:                creds.Config.ExpiresAt,
+		})
 	}
 
 	// In here we deliberately ignore any password policies as the point here is to import passwords, even if they
@@ -64,7 +75,13 @@ func (h *Handler) importPasswordCredentials(ctx context.Context, i *Identity, cr
 		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("The imported password does not match any known hash format. For more information see https://www.ory.sh/dr/2"))
 	}
 
-	return i.SetCredentialsWithConfig(CredentialsTypePassword, Credentials{}, CredentialsPassword{HashedPassword: string(hashed)})
+	now := time.Now().UTC()
+	return i.SetCredentialsWithConfig(CredentialsTypePassword, Credentials{}, CredentialsPassword{
+		HashedPassword: string(hashed),
+		Temporary:      temporary,
+		ExpiresAt:      creds.Config.ExpiresAt,
+		ChangedAt:      &now,
+	})
 }
 
 func (h *Handler) importOIDCCredentials(_ context.Context, i *Identity, creds *AdminIdentityImportCredentialsOIDC) error {
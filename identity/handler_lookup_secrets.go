@@ -0,0 +1,100 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/x"
+)
+
+// Regenerate Identity Lookup Secrets Parameters
+//
+// swagger:parameters regenerateIdentityLookupSecrets
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type regenerateIdentityLookupSecrets struct {
+	// ID must be set to the ID of identity whose lookup secrets should be regenerated.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+}
+
+// Regenerated Lookup Secrets Response
+//
+// swagger:response identityLookupSecrets
+type _ struct {
+	// The new lookup secrets
+	//
+	// in:body
+	Body CredentialsLookupConfig
+}
+
+// swagger:route POST /admin/identities/{id}/lookup-secrets identity regenerateIdentityLookupSecrets
+//
+// # Regenerate the Lookup Secrets of an Identity
+//
+// Replaces the lookup secrets (backup recovery codes) of an identity with a new set and returns it.
+// All codes of the previous set are invalidated, including unused ones. The identity must already
+// have lookup secrets set up.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: identityLookupSecrets
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) regenerateIdentityLookupSecrets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+	i, err := h.r.PrivilegedIdentityPool().GetIdentityConfidential(ctx, x.ParseUUID(ps.ByName("id")))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if _, ok := i.GetCredentials(CredentialsTypeLookup); !ok {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("The identity has no lookup secrets set up.")))
+		return
+	}
+
+	regenerated := NewCredentialsLookupConfig()
+	config, err := json.Marshal(regenerated)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(err))
+		return
+	}
+
+	// We do not really need the identifier, so we add the identity's ID.
+	i.SetCredentials(CredentialsTypeLookup, Credentials{
+		Type:        CredentialsTypeLookup,
+		Identifiers: []string{i.ID.String()},
+		Config:      config,
+	})
+	if err := h.r.IdentityManager().Update(
+		ctx,
+		i,
+		ManagerAllowWriteProtectedTraits,
+	); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+	h.r.Audit().WithRequest(r).
+		WithField("identity_id", i.ID).
+		Info("An administrator regenerated the lookup secrets of the identity.")
+
+	h.r.Writer().Write(w, r, regenerated)
+}
@@ -0,0 +1,110 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/ory/kratos/x"
+)
+
+// Require Identity Password Reset Parameters
+//
+// swagger:parameters requireIdentityPasswordReset
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type requireIdentityPasswordReset struct {
+	// ID must be set to the ID of identity which must set a new password.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+}
+
+// swagger:route PUT /admin/identities/{id}/password-reset identity requireIdentityPasswordReset
+//
+// # Require an Identity to Set a New Password
+//
+// Requires the identity to set a new password after its next login. The session issued by that login
+// can only be used in the settings flow until a new password was set. Calling `/sessions/whoami` with
+// it fails with the `session_password_reset_required` error. Browser logins continue in the settings flow.
+//
+// Existing sessions of the identity are not affected.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  204: emptyResponse
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) requireIdentityPasswordReset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.setPasswordResetRequired(w, r, ps, true)
+}
+
+// Cancel Identity Password Reset Parameters
+//
+// swagger:parameters cancelIdentityPasswordReset
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type cancelIdentityPasswordReset struct {
+	// ID must be set to the ID of identity which no longer needs to set a new password.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+}
+
+// swagger:route DELETE /admin/identities/{id}/password-reset identity cancelIdentityPasswordReset
+//
+// # Cancel a Required Password Reset of an Identity
+//
+// No longer requires the identity to set a new password. Sessions which were restricted to the settings
+// flow stay restricted until the identity signs in again or sets a new password.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  204: emptyResponse
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) cancelIdentityPasswordReset(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.setPasswordResetRequired(w, r, ps, false)
+}
+
+func (h *Handler) setPasswordResetRequired(w http.ResponseWriter, r *http.Request, ps httprouter.Params, required bool) {
+	ctx := r.Context()
+	i, err := h.r.PrivilegedIdentityPool().GetIdentity(ctx, x.ParseUUID(ps.ByName("id")), ExpandNothing)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	i.PasswordResetRequired = required
+	if err := h.r.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "password_reset_required"); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+	h.r.Audit().WithRequest(r).
+		WithField("identity_id", i.ID).
+		WithField("password_reset_required", required).
+		Info("An administrator changed whether the identity must set a new password.")
+
+	w.WriteHeader(http.StatusNoContent)
+}
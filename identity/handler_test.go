@@ -31,6 +31,7 @@ import (
 	"github.com/ory/kratos/internal"
 	"github.com/ory/kratos/internal/testhelpers"
 	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/ioutilx"
 	"github.com/ory/x/randx"
@@ -133,6 +134,64 @@ func TestHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("case=should get identity with derived traits", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "file://./stub/derived_traits/display_name.jsonnet")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "")
+		})
+
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"bar":"Jane","email":"jane@ory.sh"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+		res := get(t, adminTS, "/identities/"+i.ID.String(), http.StatusOK)
+		assert.JSONEq(t, `{"display_name":"Jane <jane@ory.sh>","schema_id":"default"}`, res.Get("derived_traits").Raw, "%s", res.Raw)
+
+		res = get(t, adminTS, "/identities?ids="+i.ID.String(), http.StatusOK)
+		assert.JSONEq(t, `{"display_name":"Jane <jane@ory.sh>","schema_id":"default"}`, res.Get("0.derived_traits").Raw, "%s", res.Raw)
+
+		actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.Empty(t, actual.DerivedTraits, "derived traits must not be persisted")
+	})
+
+	t.Run("case=should support conditional requests when getting an identity", func(t *testing.T) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"bar":"etag"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+		getWithETag := func(t *testing.T, query, ifNoneMatch string) *http.Response {
+			req, err := http.NewRequest("GET", adminTS.URL+"/identities/"+i.ID.String()+query, nil)
+			require.NoError(t, err)
+			if ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", ifNoneMatch)
+			}
+			res, err := adminTS.Client().Do(req)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = res.Body.Close() })
+			return res
+		}
+
+		res := getWithETag(t, "", "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		etag := res.Header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		res = getWithETag(t, "", etag)
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+		assert.Equal(t, etag, res.Header.Get("ETag"))
+
+		res = getWithETag(t, "?include_credential=password", etag)
+		assert.Equal(t, http.StatusOK, res.StatusCode, "the query changes the representation")
+
+		i.Traits = identity.Traits(`{"bar":"etag-updated"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentity(ctx, i))
+
+		res = getWithETag(t, "", etag)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEqual(t, etag, res.Header.Get("ETag"))
+	})
+
 	t.Run("case=should fail to create an identity because schema id does not exist", func(t *testing.T) {
 		for name, ts := range map[string]*httptest.Server{"public": publicTS, "admin": adminTS} {
 			t.Run("endpoint="+name, func(t *testing.T) {
@@ -206,7 +265,7 @@ func TestHandler(t *testing.T) {
 	})
 
 	t.Run("case=should be able to import users", func(t *testing.T) {
-		ignoreDefault := []string{"id", "schema_url", "state_changed_at", "created_at", "updated_at"}
+		ignoreDefault := []string{"id", "schema_url", "state_changed_at", "created_at", "updated_at", "password_changed_at"}
 		t.Run("without any credentials", func(t *testing.T) {
 			res := send(t, adminTS, "POST", "/identities", http.StatusCreated, identity.CreateIdentityBody{Traits: []byte(`{"email": "import-1@ory.sh"}`)})
 			actual, err := reg.PrivilegedIdentityPool().GetIdentityConfidential(ctx, uuid.FromStringOrNil(res.Get("id").String()))
@@ -786,6 +845,37 @@ func TestHandler(t *testing.T) {
 			}
 		})
 
+		t.Run("case=should update an identity with a temporary password", func(t *testing.T) {
+			i := &identity.Identity{Traits: identity.Traits(fmt.Sprintf(`{"subject":"%s"}`, x.NewUUID().String()))}
+			require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+			expiresAt := time.Now().Add(time.Hour).UTC().Round(time.Second)
+			res := send(t, adminTS, "PUT", "/identities/"+i.ID.String(), http.StatusOK, &identity.UpdateIdentityBody{
+				Traits:   []byte(`{"bar":"baz"}`),
+				SchemaID: i.SchemaID,
+				State:    identity.StateActive,
+				Credentials: &identity.IdentityWithCredentials{
+					Password: &identity.AdminIdentityImportCredentialsPassword{
+						Config: identity.AdminIdentityImportCredentialsPasswordConfig{
+							Password:  "pswd1234",
+							Temporary: true,
+							ExpiresAt: &expiresAt,
+						},
+					},
+				},
+			})
+			assert.True(t, res.Get("password_reset_required").Bool(), "%s", res.Raw)
+
+			actual, err := reg.PrivilegedIdentityPool().GetIdentityConfidential(ctx, i.ID)
+			require.NoError(t, err)
+			assert.True(t, actual.PasswordResetRequired)
+
+			config := actual.Credentials[identity.CredentialsTypePassword].Config
+			assert.True(t, gjson.GetBytes(config, "temporary").Bool(), "%s", config)
+			assert.True(t, expiresAt.Equal(gjson.GetBytes(config, "expires_at").Time()), "%s", config)
+			require.NoError(t, hash.Compare(ctx, []byte("pswd1234"), []byte(gjson.GetBytes(config, "hashed_password").String())))
+		})
+
 		t.Run("case=should delete a user and no longer be able to retrieve it", func(t *testing.T) {
 			for name, ts := range map[string]*httptest.Server{"public": publicTS, "admin": adminTS} {
 				t.Run("endpoint="+name, func(t *testing.T) {
@@ -1227,7 +1317,7 @@ func TestHandler(t *testing.T) {
 				}
 
 				res := send(t, ts, "PATCH", "/identities/"+i.ID.String(), http.StatusBadRequest, &patch)
-				assert.EqualValues(t, "The supplied state ('invalid-value') was not valid. Valid states are ('active', 'inactive').", res.Get("error.reason").String(), "%s", res.Raw)
+				assert.EqualValues(t, "The supplied state ('invalid-value') was not valid. Valid states are ('active', 'inactive', 'locked', 'archived').", res.Get("error.reason").String(), "%s", res.Raw)
 
 				res = get(t, ts, "/identities/"+i.ID.String(), http.StatusOK)
 				// Assert that the schema ID is unchanged
@@ -2028,6 +2118,125 @@ func TestHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("case=should require and cancel a password reset", func(t *testing.T) {
+		i := identity.NewIdentity("")
+		i.Traits = identity.Traits("{}")
+		require.NoError(t, reg.Persister().CreateIdentity(ctx, i))
+
+		for name, ts := range map[string]*httptest.Server{"public": publicTS, "admin": adminTS} {
+			t.Run("endpoint="+name, func(t *testing.T) {
+				send(t, ts, "PUT", "/identities/"+i.ID.String()+"/password-reset", http.StatusNoContent, nil)
+				res := get(t, ts, "/identities/"+i.ID.String(), http.StatusOK)
+				assert.True(t, res.Get("password_reset_required").Bool(), "%s", res.Raw)
+
+				remove(t, ts, "/identities/"+i.ID.String()+"/password-reset", http.StatusNoContent)
+				res = get(t, ts, "/identities/"+i.ID.String(), http.StatusOK)
+				assert.False(t, res.Get("password_reset_required").Exists(), "%s", res.Raw)
+			})
+		}
+
+		t.Run("case=unknown identity", func(t *testing.T) {
+			send(t, adminTS, "PUT", "/identities/"+x.NewUUID().String()+"/password-reset", http.StatusNotFound, nil)
+		})
+	})
+
+	t.Run("case=should regenerate lookup secrets", func(t *testing.T) {
+		i := identity.NewIdentity("")
+		i.Traits = identity.Traits("{}")
+		i.SetCredentials(identity.CredentialsTypeLookup, identity.Credentials{
+			Type:        identity.CredentialsTypeLookup,
+			Identifiers: []string{i.ID.String()},
+			Config:      []byte(`{"recovery_codes":[{"code":"foo"},{"code":"bar","used_at":"2024-01-01T00:00:00Z"}]}`),
+		})
+		require.NoError(t, reg.Persister().CreateIdentity(ctx, i))
+
+		for name, ts := range map[string]*httptest.Server{"public": publicTS, "admin": adminTS} {
+			t.Run("endpoint="+name, func(t *testing.T) {
+				res := send(t, ts, "POST", "/identities/"+i.ID.String()+"/lookup-secrets", http.StatusOK, nil)
+				codes := res.Get("recovery_codes").Array()
+				require.Len(t, codes, identity.LookupSecretCodes, "%s", res.Raw)
+				for _, c := range codes {
+					assert.NotEqual(t, "foo", c.Get("code").String())
+					assert.Empty(t, c.Get("used_at").Value(), "%s", res.Raw)
+				}
+
+				actual, err := reg.PrivilegedIdentityPool().GetIdentityConfidential(ctx, i.ID)
+				require.NoError(t, err)
+				creds, ok := actual.GetCredentials(identity.CredentialsTypeLookup)
+				require.True(t, ok)
+				assert.JSONEq(t, res.Raw, string(creds.Config))
+			})
+		}
+
+		t.Run("case=identity without lookup secrets", func(t *testing.T) {
+			i := identity.NewIdentity("")
+			i.Traits = identity.Traits("{}")
+			require.NoError(t, reg.Persister().CreateIdentity(ctx, i))
+			send(t, adminTS, "POST", "/identities/"+i.ID.String()+"/lookup-secrets", http.StatusNotFound, nil)
+		})
+
+		t.Run("case=unknown identity", func(t *testing.T) {
+			send(t, adminTS, "POST", "/identities/"+x.NewUUID().String()+"/lookup-secrets", http.StatusNotFound, nil)
+		})
+	})
+
+	t.Run("case=should summarize the credentials of an identity", func(t *testing.T) {
+		i := identity.NewIdentity("")
+		i.Traits = identity.Traits("{}")
+		i.Credentials = map[identity.CredentialsType]identity.Credentials{
+			identity.CredentialsTypePassword: {
+				Type:        identity.CredentialsTypePassword,
+				Config:      []byte(`{"hashed_password":"$2a$08$.cOYmAd.vCpDOoiVJrO5B.hjTLKQQ6cAK40u8uB.FnZDyPvVvQ9Q."}`),
+				Identifiers: []string{x.NewUUID().String()},
+			},
+			identity.CredentialsTypeWebAuthn: {
+				Type:        identity.CredentialsTypeWebAuthn,
+				Config:      []byte(`{"credentials":[{"id":"THTndqZP5Mjvae1BFvJMaMfEMm7O7HE1ju+7PBaYA7Y=","added_at":"2022-12-16T14:11:55Z","public_key":"pQECAyYgASFYIMJLQhJxQRzhnKPTcPCUODOmxYDYo2obrm9bhp5lvSZ3IlggXjhZvJaPUqF9PXqZqTdWYPR7R+b2n/Wi+IxKKXsS4rU=","display_name":"","authenticator":{"aaguid":"rc4AAjW8xgpkiwsl8fBVAw==","sign_count":3,"clone_warning":false},"flags":{"backup_eligible":true},"is_passwordless":false,"attestation_type":"none"}],"user_handle":"Ef5JiMpMRwuzauWs/9J0gQ=="}`),
+				Identifiers: []string{x.NewUUID().String()},
+			},
+		}
+		require.NoError(t, reg.Persister().CreateIdentity(ctx, i))
+
+		usedAt := time.Now().UTC().Add(-time.Hour).Round(time.Second)
+		sess := session.NewInactiveSession()
+		sess.ID = x.NewUUID()
+		sess.Identity, sess.IdentityID = i, i.ID
+		sess.ExpiresAt = time.Now().Add(time.Hour)
+		sess.AMR = session.AuthenticationMethods{
+			{Method: identity.CredentialsTypePassword, AAL: identity.AuthenticatorAssuranceLevel1, CompletedAt: usedAt.Add(-time.Hour)},
+			{Method: identity.CredentialsTypePassword, AAL: identity.AuthenticatorAssuranceLevel1, CompletedAt: usedAt},
+		}
+		require.NoError(t, reg.SessionPersister().UpsertSession(ctx, sess))
+
+		for name, ts := range map[string]*httptest.Server{"public": publicTS, "admin": adminTS} {
+			t.Run("endpoint="+name, func(t *testing.T) {
+				res := get(t, ts, "/identities/"+i.ID.String()+"/credentials/summary", http.StatusOK)
+				assert.Equal(t, i.ID.String(), res.Get("identity_id").String(), "%s", res.Raw)
+				assert.Equal(t, "aal2", res.Get("available_aal").String(), "%s", res.Raw)
+				assert.True(t, res.Get("mfa_enabled").Bool(), "%s", res.Raw)
+				assert.NotContains(t, res.Raw, "hashed_password")
+				assert.NotContains(t, res.Raw, "public_key")
+
+				password := res.Get(`credentials.#(type=="password")`)
+				assert.True(t, password.Get("first_factor").Bool(), "%s", res.Raw)
+				assert.False(t, password.Get("multi_factor").Bool(), "%s", res.Raw)
+				assert.Equal(t, usedAt.Format(time.RFC3339), password.Get("last_used_at").Time().UTC().Format(time.RFC3339), "%s", res.Raw)
+
+				webauthn := res.Get(`credentials.#(type=="webauthn")`)
+				assert.False(t, webauthn.Get("first_factor").Bool(), "%s", res.Raw)
+				assert.True(t, webauthn.Get("multi_factor").Bool(), "%s", res.Raw)
+				assert.False(t, webauthn.Get("last_used_at").Exists(), "%s", res.Raw)
+				assert.Equal(t, "adce0002-35bc-c60a-648b-0b25f1f05503", webauthn.Get("webauthn_authenticators.0.aaguid").String(), "%s", res.Raw)
+				assert.EqualValues(t, 3, webauthn.Get("webauthn_authenticators.0.sign_count").Int(), "%s", res.Raw)
+				assert.True(t, webauthn.Get("webauthn_authenticators.0.backup_eligible").Bool(), "%s", res.Raw)
+			})
+		}
+
+		t.Run("case=unknown identity", func(t *testing.T) {
+			get(t, adminTS, "/identities/"+x.NewUUID().String()+"/credentials/summary", http.StatusNotFound)
+		})
+	})
+
 	t.Run("case=should paginate all identities", func(t *testing.T) {
 		// Start new server
 		conf, reg := internal.NewFastRegistryWithMocks(t)
@@ -2188,3 +2397,76 @@ func assertJSONArrayElementsMatch(t *testing.T, expected, actual gjson.Result, m
 
 	assert.ElementsMatch(t, expectedStrings, actualStrings, msgAndArgs...)
 }
+
+func TestHandlerAdminAPITokens(t *testing.T) {
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	_, adminTS := testhelpers.NewKratosServerWithCSRF(t, reg)
+	testhelpers.SetIdentitySchemas(t, conf, map[string]string{
+		"default": "file://./stub/encrypted.schema.json",
+	})
+	conf.MustSet(ctx, config.ViperKeySecretsTraits, []string{randx.MustString(32, randx.AlphaNum)})
+
+	i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+	i.Traits = identity.Traits(`{"email":"admin-api-tokens@ory.sh","ssn":"123-45-6789","address":{"street":"Main St 1","zip":12345}}`)
+	i.MetadataAdmin = []byte(`{"notes":"VIP"}`)
+	i.SetCredentials(identity.CredentialsTypePassword, identity.Credentials{
+		Type:        identity.CredentialsTypePassword,
+		Identifiers: []string{"admin-api-tokens@ory.sh"},
+		Config:      sqlxx.JSONRawMessage(`{"hashed_password":"$2a$04$zvZz1zV"}`),
+	})
+	require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+	const (
+		fullToken    = "full-token-0123456789abcdefghijklmnop"
+		supportToken = "support-token-0123456789abcdefghijkl"
+	)
+	conf.MustSet(ctx, config.ViperKeyAdminAPITokens, []map[string]any{
+		{"id": "full", "token": fullToken, "scopes": []string{config.AdminAPITokenScopeCredentialsRead, config.AdminAPITokenScopeMetadataAdminRead}},
+		{"id": "support", "token": supportToken, "redacted_traits": []string{"ssn", "address.street"}},
+	})
+
+	get := func(t *testing.T, href, token string, expectCode int) gjson.Result {
+		t.Helper()
+		req, err := http.NewRequest("GET", adminTS.URL+href, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := adminTS.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.EqualValues(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=requires a token", func(t *testing.T) {
+		get(t, "/identities/"+i.ID.String(), "", http.StatusUnauthorized)
+		get(t, "/identities", "invalid", http.StatusUnauthorized)
+	})
+
+	t.Run("case=returns all fields for tokens with all scopes", func(t *testing.T) {
+		actual := get(t, "/identities/"+i.ID.String()+"?include_credential=password", fullToken, http.StatusOK)
+		assert.Equal(t, "123-45-6789", actual.Get("traits.ssn").String(), "%s", actual.Raw)
+		assert.Equal(t, "Main St 1", actual.Get("traits.address.street").String(), "%s", actual.Raw)
+		assert.Equal(t, "VIP", actual.Get("metadata_admin.notes").String(), "%s", actual.Raw)
+		assert.NotEmpty(t, actual.Get("credentials.password.config.hashed_password").String(), "%s", actual.Raw)
+	})
+
+	t.Run("case=redacts fields for tokens without scopes", func(t *testing.T) {
+		for _, actual := range []gjson.Result{
+			get(t, "/identities/"+i.ID.String()+"?include_credential=password", supportToken, http.StatusOK),
+			get(t, "/identities?ids="+i.ID.String()+"&include_credential=password", supportToken, http.StatusOK).Get("0"),
+		} {
+			assert.Equal(t, i.ID.String(), actual.Get("id").String(), "%s", actual.Raw)
+			assert.Equal(t, "admin-api-tokens@ory.sh", actual.Get("traits.email").String(), "%s", actual.Raw)
+			assert.False(t, actual.Get("traits.ssn").Exists(), "%s", actual.Raw)
+			assert.False(t, actual.Get("traits.address.street").Exists(), "%s", actual.Raw)
+			assert.EqualValues(t, 12345, actual.Get("traits.address.zip").Int(), "%s", actual.Raw)
+			assert.False(t, actual.Get("metadata_admin.notes").Exists(), "%s", actual.Raw)
+			assert.True(t, actual.Get("credentials.password.identifiers").Exists(), "%s", actual.Raw)
+			assert.False(t, actual.Get("credentials.password.config.hashed_password").Exists(), "%s", actual.Raw)
+		}
+	})
+}
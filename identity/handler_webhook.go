@@ -0,0 +1,202 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/decoderx"
+	"github.com/ory/x/randx"
+
+	"github.com/ory/kratos/x"
+)
+
+// Get Identity Webhook Parameters
+//
+// swagger:parameters getIdentityWebhook
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type getIdentityWebhook struct {
+	// ID must be set to the ID of identity you want to get the webhook of.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+}
+
+// swagger:route GET /admin/identities/{id}/webhook identity getIdentityWebhook
+//
+// # Get the Webhook of an Identity
+//
+// Returns the webhook which receives the lifecycle events of the identity. The signing secret is not returned.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: identityWebhook
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) getIdentityWebhook(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	webhook, err := h.r.IdentityWebhookPersister().GetIdentityWebhook(r.Context(), x.ParseUUID(ps.ByName("id")))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, webhook)
+}
+
+// Set Identity Webhook Parameters
+//
+// swagger:parameters setIdentityWebhook
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type setIdentityWebhook struct {
+	// ID must be set to the ID of identity you want to set the webhook of.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+
+	// in: body
+	Body SetIdentityWebhookBody
+}
+
+// Set Identity Webhook Body
+//
+// swagger:model setIdentityWebhookBody
+type SetIdentityWebhookBody struct {
+	// The callback URL the identity's lifecycle events are sent to.
+	//
+	// required: true
+	URL string `json:"url"`
+}
+
+// swagger:route PUT /admin/identities/{id}/webhook identity setIdentityWebhook
+//
+// # Set the Webhook of an Identity
+//
+// Sets the webhook which receives the lifecycle events of the identity, replacing any existing webhook.
+//
+// Before the webhook is set, the callback URL receives a `webhook.verification` event and must respond
+// with a 2xx status code and a JSON object containing the `challenge` of the event. Every request is
+// signed with a secret generated for the webhook, which is only returned by this endpoint.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: identityWebhook
+//	  400: errorGeneric
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) setIdentityWebhook(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+
+	var body SetIdentityWebhookBody
+	if err := h.dx.Decode(r, &body, decoderx.HTTPJSONDecoder()); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	callback, err := url.ParseRequestURI(body.URL)
+	if err != nil || (callback.Scheme != "http" && callback.Scheme != "https") || callback.Host == "" {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("The webhook URL must be an absolute http or https URL.")))
+		return
+	}
+
+	i, err := h.r.PrivilegedIdentityPool().GetIdentity(ctx, x.ParseUUID(ps.ByName("id")), ExpandNothing)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	webhook := &Webhook{
+		IdentityID: i.ID,
+		URL:        callback.String(),
+		Secret:     randx.MustString(32, randx.AlphaNum),
+	}
+
+	if err := h.r.IdentityWebhookSender().Verify(ctx, webhook); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+	webhook.VerifiedAt = time.Now().UTC()
+
+	webhook.EncryptedSecret, err = h.r.Cipher(ctx).Encrypt(ctx, []byte(webhook.Secret))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := h.r.IdentityWebhookPersister().UpsertIdentityWebhook(ctx, webhook); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, webhook)
+}
+
+// Delete Identity Webhook Parameters
+//
+// swagger:parameters deleteIdentityWebhook
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type deleteIdentityWebhook struct {
+	// ID must be set to the ID of identity you want to delete the webhook of.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+}
+
+// swagger:route DELETE /admin/identities/{id}/webhook identity deleteIdentityWebhook
+//
+// # Delete the Webhook of an Identity
+//
+// Deletes the webhook of the identity. The identity's lifecycle events are no longer sent to the callback URL.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  204: emptyResponse
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) deleteIdentityWebhook(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.r.IdentityWebhookPersister().DeleteIdentityWebhook(r.Context(), x.ParseUUID(ps.ByName("id"))); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,258 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
+	"github.com/ory/kratos/x"
+)
+
+type webhookRequest struct {
+	signature string
+	body      []byte
+}
+
+type webhookReceiver struct {
+	sync.Mutex
+	requests      []webhookRequest
+	echoChallenge bool
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.Lock()
+	rc.requests = append(rc.requests, webhookRequest{signature: r.Header.Get(identity.WebhookSignatureHeader), body: body})
+	echo := rc.echoChallenge
+	rc.Unlock()
+
+	if gjson.GetBytes(body, "type").String() == string(identity.WebhookEventVerification) {
+		challenge := "wrong"
+		if echo {
+			challenge = gjson.GetBytes(body, "challenge").String()
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"challenge": challenge})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (rc *webhookReceiver) events() []webhookRequest {
+	rc.Lock()
+	defer rc.Unlock()
+	return append([]webhookRequest{}, rc.requests...)
+}
+
+// lastEvent returns the most recent request of the given event type.
+func (rc *webhookReceiver) lastEvent(event identity.WebhookEvent) *webhookRequest {
+	events := rc.events()
+	for k := len(events) - 1; k >= 0; k-- {
+		if gjson.GetBytes(events[k].body, "type").String() == string(event) {
+			return &events[k]
+		}
+	}
+	return nil
+}
+
+func assertWebhookSignature(t *testing.T, secret string, req *webhookRequest) {
+	t.Helper()
+	ts := strings.TrimPrefix(strings.Split(req.signature, ",")[0], "t=")
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, identity.SignWebhookPayload(secret, time.Unix(unix, 0), req.body), req.signature)
+}
+
+func TestIdentityWebhook(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	_, adminTS := testhelpers.NewKratosServerWithCSRF(t, reg)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	conf.MustSet(ctx, config.ViperKeyAdminBaseURL, adminTS.URL)
+
+	receiver := &webhookReceiver{echoChallenge: true}
+	callbackTS := httptest.NewServer(receiver)
+	t.Cleanup(callbackTS.Close)
+
+	send := func(t *testing.T, method, href string, body string, expectCode int) gjson.Result {
+		t.Helper()
+		req, err := http.NewRequest(method, adminTS.URL+href, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := adminTS.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		raw, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.EqualValues(t, expectCode, res.StatusCode, "%s", raw)
+		return gjson.ParseBytes(raw)
+	}
+
+	createIdentity := func(t *testing.T) *identity.Identity {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"email":"` + uuid.Must(uuid.NewV4()).String() + `@ory.sh"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		return i
+	}
+
+	webhookRoute := func(i *identity.Identity) string {
+		return "/identities/" + i.ID.String() + "/webhook"
+	}
+
+	t.Run("case=rejects invalid callback URLs", func(t *testing.T) {
+		i := createIdentity(t)
+		for _, u := range []string{"", "not-a-url", "ftp://example.com/callback", "/relative"} {
+			send(t, "PUT", webhookRoute(i), `{"url":"`+u+`"}`, http.StatusBadRequest)
+		}
+		send(t, "GET", webhookRoute(i), "", http.StatusNotFound)
+	})
+
+	t.Run("case=returns not found for unknown identities", func(t *testing.T) {
+		send(t, "PUT", "/identities/"+x.NewUUID().String()+"/webhook", `{"url":"`+callbackTS.URL+`"}`, http.StatusNotFound)
+	})
+
+	t.Run("case=fails if the verification handshake fails", func(t *testing.T) {
+		receiver.Lock()
+		receiver.echoChallenge = false
+		receiver.Unlock()
+		t.Cleanup(func() {
+			receiver.Lock()
+			receiver.echoChallenge = true
+			receiver.Unlock()
+		})
+
+		i := createIdentity(t)
+		send(t, "PUT", webhookRoute(i), `{"url":"`+callbackTS.URL+`"}`, http.StatusBadRequest)
+		send(t, "GET", webhookRoute(i), "", http.StatusNotFound)
+	})
+
+	t.Run("case=manages the webhook and delivers signed lifecycle events", func(t *testing.T) {
+		i := createIdentity(t)
+
+		actual := send(t, "PUT", webhookRoute(i), `{"url":"`+callbackTS.URL+`/hooks"}`, http.StatusOK)
+		secret := actual.Get("secret").String()
+		require.Len(t, secret, 32)
+		assert.Equal(t, i.ID.String(), actual.Get("identity_id").String())
+		assert.Equal(t, callbackTS.URL+"/hooks", actual.Get("url").String())
+		assert.NotEmpty(t, actual.Get("verified_at").String())
+
+		verification := receiver.lastEvent(identity.WebhookEventVerification)
+		require.NotNil(t, verification)
+		assertWebhookSignature(t, secret, verification)
+
+		fetched := send(t, "GET", webhookRoute(i), "", http.StatusOK)
+		assert.Equal(t, actual.Get("id").String(), fetched.Get("id").String())
+		assert.False(t, fetched.Get("secret").Exists(), "%s", fetched.Raw)
+
+		stored, err := reg.IdentityWebhookPersister().GetIdentityWebhook(ctx, i.ID)
+		require.NoError(t, err)
+		assert.NotContains(t, stored.EncryptedSecret, secret, "the secret must be stored encrypted")
+
+		send(t, "PUT", "/identities/"+i.ID.String(), `{"traits":{"email":"updated-`+uuid.Must(uuid.NewV4()).String()+`@ory.sh"},"state":"active"}`, http.StatusOK)
+
+		var updated *webhookRequest
+		require.Eventually(t, func() bool {
+			updated = receiver.lastEvent(identity.WebhookEventIdentityUpdated)
+			return updated != nil
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, i.ID.String(), gjson.GetBytes(updated.body, "identity_id").String())
+		assertWebhookSignature(t, secret, updated)
+
+		send(t, "DELETE", webhookRoute(i), "", http.StatusNoContent)
+		send(t, "GET", webhookRoute(i), "", http.StatusNotFound)
+		send(t, "DELETE", webhookRoute(i), "", http.StatusNotFound)
+	})
+
+	t.Run("case=delivers the identity deleted event", func(t *testing.T) {
+		i := createIdentity(t)
+		secret := send(t, "PUT", webhookRoute(i), `{"url":"`+callbackTS.URL+`"}`, http.StatusOK).Get("secret").String()
+
+		send(t, "DELETE", "/identities/"+i.ID.String(), "", http.StatusNoContent)
+
+		var deleted *webhookRequest
+		require.Eventually(t, func() bool {
+			deleted = receiver.lastEvent(identity.WebhookEventIdentityDeleted)
+			return deleted != nil
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, i.ID.String(), gjson.GetBytes(deleted.body, "identity_id").String())
+		assertWebhookSignature(t, secret, deleted)
+
+		_, err := reg.IdentityWebhookPersister().GetIdentityWebhook(ctx, i.ID)
+		require.Error(t, err)
+	})
+
+	setWebhook := func(t *testing.T, i *identity.Identity, u string, verifiedAt time.Time) *identity.Webhook {
+		secret, err := reg.Cipher(ctx).Encrypt(ctx, []byte("secret"))
+		require.NoError(t, err)
+		w := &identity.Webhook{IdentityID: i.ID, URL: u, EncryptedSecret: secret, VerifiedAt: verifiedAt}
+		require.NoError(t, reg.IdentityWebhookPersister().UpsertIdentityWebhook(ctx, w))
+		return w
+	}
+
+	t.Run("case=does not deliver events to unverified webhooks", func(t *testing.T) {
+		var calls atomic.Int32
+		unverifiedTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(unverifiedTS.Close)
+
+		i := createIdentity(t)
+		setWebhook(t, i, unverifiedTS.URL, time.Time{})
+
+		reg.IdentityWebhookSender().Send(ctx, i.ID, identity.WebhookEventIdentityUpdated, nil)
+		time.Sleep(100 * time.Millisecond)
+		assert.Zero(t, calls.Load())
+	})
+
+	t.Run("case=records failed deliveries", func(t *testing.T) {
+		failingTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		t.Cleanup(failingTS.Close)
+
+		i := createIdentity(t)
+		w := setWebhook(t, i, failingTS.URL+"/hooks", time.Now().UTC())
+
+		reg.IdentityWebhookSender().Send(ctx, i.ID, identity.WebhookEventIdentityUpdated, nil)
+
+		var failed *deadletter.Event
+		require.Eventually(t, func() bool {
+			events, _, err := reg.FailedWebhookEventPersister().ListFailedWebhookEvents(ctx, nil)
+			require.NoError(t, err)
+			for k := range events {
+				if events[k].WebhookID == w.ID.String() {
+					failed = &events[k]
+					return true
+				}
+			}
+			return false
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, failingTS.URL+"/hooks", failed.URL)
+		assert.Equal(t, http.MethodPost, failed.Method)
+		assert.Contains(t, failed.Error, "400")
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -26,7 +27,8 @@ import (
 
 // An Identity's State
 //
-// The state can either be `active` or `inactive`.
+// The state can either be `active`, `inactive`, `locked`, or `archived`. Only active identities
+// are able to sign in, unless configured otherwise.
 //
 // swagger:enum State
 type State string
@@ -34,11 +36,13 @@ type State string
 const (
 	StateActive   State = "active"
 	StateInactive State = "inactive"
+	StateLocked   State = "locked"
+	StateArchived State = "archived"
 )
 
 func (lt State) IsValid() error {
 	switch lt {
-	case StateActive, StateInactive:
+	case StateActive, StateInactive, StateLocked, StateArchived:
 		return nil
 	}
 	return errors.New("identity state is not valid")
@@ -88,13 +92,26 @@ type Identity struct {
 	SchemaURL string `json:"schema_url" faker:"-" db:"-"`
 
 	// State is the identity's state.
-	//
-	// This value has currently no effect.
 	State State `json:"state" faker:"-" db:"state"`
 
 	// StateChangedAt contains the last time when the identity's state changed.
 	StateChangedAt *sqlxx.NullTime `json:"state_changed_at,omitempty" faker:"-" db:"state_changed_at"`
 
+	// StateReason is the reason code of the last state change, for example `inactivity`.
+	StateReason string `json:"state_reason,omitempty" faker:"-" db:"state_reason"`
+
+	// LastAuthenticatedAt is the time the identity last signed in. It is maintained by the session
+	// persister and used to find inactive identities.
+	LastAuthenticatedAt sqlxx.NullTime `json:"-" faker:"-" db:"last_authenticated_at" rw:"r"`
+
+	// PasswordResetRequired is set by administrators to force the identity to set a new password after
+	// the next login. Until then, sessions of the identity can only be used to change the password.
+	PasswordResetRequired bool `json:"password_reset_required,omitempty" faker:"-" db:"password_reset_required"`
+
+	// PasskeyEnrollmentSnoozedUntil is set when the identity was asked to enroll a passkey after login.
+	// It will not be asked again before this time.
+	PasskeyEnrollmentSnoozedUntil *sqlxx.NullTime `json:"-" faker:"-" db:"passkey_enrollment_snoozed_until"`
+
 	// Traits represent an identity's traits. The identity is able to create, modify, and delete traits
 	// in a self-service manner. The input will always be validated against the JSON Schema defined
 	// in `schema_url`.
@@ -125,6 +142,15 @@ type Identity struct {
 	// Store metadata about the user which is only accessible through admin APIs such as `GET /admin/identities/<id>`.
 	MetadataAdmin sqlxx.NullJSONRawMessage `json:"metadata_admin,omitempty" faker:"-" db:"metadata_admin"`
 
+	// DerivedTraits are computed from the identity by the derived traits Jsonnet mapper every time
+	// the identity is returned by the session and admin get identity endpoints. They are not persisted.
+	//
+	// Extensions:
+	// ---
+	// x-omitempty: true
+	// ---
+	DerivedTraits json.RawMessage `json:"derived_traits,omitempty" faker:"-" db:"-"`
+
 	// CreatedAt is a helper struct field for gobuffalo.pop.
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 
@@ -186,6 +212,26 @@ func (i *Identity) IsActive() bool {
 	return i.State == StateActive
 }
 
+// HasVerifiedAddress returns true if at least one of the identity's verifiable addresses has been verified.
+func (i *Identity) HasVerifiedAddress() bool {
+	for _, va := range i.VerifiableAddresses {
+		if va.Verified {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAddressVerified returns true if the identity has a verified address with the given value.
+func (i *Identity) IsAddressVerified(value string) bool {
+	for _, va := range i.VerifiableAddresses {
+		if va.Verified && strings.EqualFold(va.Value, value) {
+			return true
+		}
+	}
+	return false
+}
+
 func (i *Identity) SetCredentials(t CredentialsType, c Credentials) {
 	if i.Credentials == nil {
 		i.Credentials = make(map[CredentialsType]Credentials)
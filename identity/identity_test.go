@@ -344,6 +344,20 @@ func TestVerifiableAddresses(t *testing.T) {
 	assert.Equal(t, addresses, CollectVerifiableAddresses([]*Identity{id1, id2, id3}))
 }
 
+func TestVerifiedAddresses(t *testing.T) {
+	t.Parallel()
+
+	i := &Identity{}
+	assert.False(t, i.HasVerifiedAddress())
+
+	i.VerifiableAddresses = []VerifiableAddress{{Value: "foo@ory.sh"}, {Value: "bar@ory.sh", Verified: true}}
+	assert.True(t, i.HasVerifiedAddress())
+	assert.False(t, i.IsAddressVerified("foo@ory.sh"))
+	assert.True(t, i.IsAddressVerified("bar@ory.sh"))
+	assert.True(t, i.IsAddressVerified("Bar@ory.sh"))
+	assert.False(t, i.IsAddressVerified("baz@ory.sh"))
+}
+
 type cipherProvider struct{}
 
 func (c *cipherProvider) Cipher(context.Context) cipher.Cipher {
@@ -19,6 +19,10 @@ const (
 	VerifiableAddressStatusPending   VerifiableAddressStatus = "pending"
 	VerifiableAddressStatusSent      VerifiableAddressStatus = "sent"
 	VerifiableAddressStatusCompleted VerifiableAddressStatus = "completed"
+
+	// VerifiableAddressStatusUndeliverable marks addresses which permanently bounced or complained.
+	// The courier does not send messages to these addresses.
+	VerifiableAddressStatusUndeliverable VerifiableAddressStatus = "undeliverable"
 )
 
 // VerifiableAddressType must not exceed 16 characters as that is the limitation in the SQL Schema
@@ -0,0 +1,68 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"regexp"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+// Built-in reason codes of state transitions performed by Ory Kratos itself.
+const (
+	// StateReasonInactivity is the reason of identities archived because they did not sign in for
+	// the configured period.
+	StateReasonInactivity = "inactivity"
+
+	// StateReasonLogin is the reason of identities reactivated by signing in.
+	StateReasonLogin = "login"
+)
+
+// stateTransitions lists the states an identity may transition to from each state. Archived
+// identities need to be restored to active before they can be locked or deactivated again.
+var stateTransitions = map[State][]State{
+	StateActive:   {StateInactive, StateLocked, StateArchived},
+	StateInactive: {StateActive, StateLocked, StateArchived},
+	StateLocked:   {StateActive, StateInactive, StateArchived},
+	StateArchived: {StateActive},
+}
+
+var stateReasonPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]{0,63}$`)
+
+// ValidateTransition returns an error if the identity may not transition from state `from` to
+// state `to`.
+func (from State) ValidateTransition(to State) error {
+	if err := to.IsValid(); err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.
+			WithReasonf("The supplied state ('%s') was not valid. Valid states are ('%s', '%s', '%s', '%s').", to, StateActive, StateInactive, StateLocked, StateArchived).
+			WithWrap(err))
+	}
+
+	if from == "" || from == to {
+		return nil
+	}
+
+	if !slices.Contains(stateTransitions[from], to) {
+		return errors.WithStack(herodot.ErrBadRequest.
+			WithReasonf("The identity can not transition from state '%s' to state '%s'.", from, to).
+			WithDetail("from", from).
+			WithDetail("to", to))
+	}
+
+	return nil
+}
+
+// ValidateStateReason returns an error if the reason code of a state transition is malformed.
+// Reason codes are optional and consist of up to 64 lowercase letters, digits, and underscores.
+func ValidateStateReason(reason string) error {
+	if reason == "" || stateReasonPattern.MatchString(reason) {
+		return nil
+	}
+
+	return errors.WithStack(herodot.ErrBadRequest.
+		WithReasonf("The supplied state reason ('%s') was not valid. Reasons consist of up to 64 lowercase letters, digits, and underscores.", reason))
+}
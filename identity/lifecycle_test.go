@@ -0,0 +1,50 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/herodot"
+)
+
+func TestStateValidateTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to State
+		allowed  bool
+	}{
+		{from: "", to: StateArchived, allowed: true},
+		{from: StateActive, to: StateActive, allowed: true},
+		{from: StateActive, to: StateInactive, allowed: true},
+		{from: StateActive, to: StateLocked, allowed: true},
+		{from: StateActive, to: StateArchived, allowed: true},
+		{from: StateInactive, to: StateActive, allowed: true},
+		{from: StateLocked, to: StateArchived, allowed: true},
+		{from: StateArchived, to: StateActive, allowed: true},
+		{from: StateArchived, to: StateInactive},
+		{from: StateArchived, to: StateLocked},
+		{from: StateActive, to: "deleted"},
+	} {
+		t.Run(fmt.Sprintf("from=%s/to=%s", tc.from, tc.to), func(t *testing.T) {
+			err := tc.from.ValidateTransition(tc.to)
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, herodot.ErrBadRequest)
+			}
+		})
+	}
+}
+
+func TestValidateStateReason(t *testing.T) {
+	for _, reason := range []string{"", StateReasonInactivity, "fraud_suspected", "gdpr_request_2"} {
+		assert.NoError(t, ValidateStateReason(reason), reason)
+	}
+	for _, reason := range []string{"Fraud", "fraud suspected", "_fraud", "fraud-suspected", string(make([]byte, 65))} {
+		assert.ErrorIs(t, ValidateStateReason(reason), herodot.ErrBadRequest, reason)
+	}
+}
@@ -11,9 +11,15 @@ import (
 	"slices"
 	"sort"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x/events"
 	"github.com/ory/x/sqlcon"
+	"github.com/ory/x/sqlxx"
 
 	"github.com/ory/x/otelx"
 
@@ -43,6 +49,7 @@ type (
 		courier.Provider
 		ValidationProvider
 		ActiveCredentialsCounterStrategyProvider
+		WebhookSenderProvider
 		x.LoggingProvider
 	}
 	ManagementProvider interface {
@@ -454,7 +461,99 @@ func (m *Manager) Update(ctx context.Context, updated *Identity, opts ...Manager
 		return err
 	}
 
-	return m.r.PrivilegedIdentityPool().UpdateIdentity(ctx, updated)
+	if err := original.State.ValidateTransition(updated.State); err != nil {
+		return err
+	}
+	if err := ValidateStateReason(updated.StateReason); err != nil {
+		return err
+	}
+
+	if err := m.r.PrivilegedIdentityPool().UpdateIdentity(ctx, updated); err != nil {
+		return err
+	}
+
+	m.r.IdentityWebhookSender().Send(ctx, updated.ID, WebhookEventIdentityUpdated, nil)
+	if original.State != updated.State {
+		m.StateChanged(ctx, updated, original.State)
+	}
+	return nil
+}
+
+// TransitionState changes the state of the identity, recording the reason code of the change.
+// Only the state columns are updated.
+func (m *Manager) TransitionState(ctx context.Context, i *Identity, to State, reason string) (err error) {
+	ctx, span := m.r.Tracer(ctx).Tracer().Start(ctx, "identity.Manager.TransitionState")
+	defer otelx.End(span, &err)
+
+	from := i.State
+	if err := from.ValidateTransition(to); err != nil {
+		return err
+	}
+	if err := ValidateStateReason(reason); err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+
+	stateChangedAt := sqlxx.NullTime(x.Now())
+	i.State = to
+	i.StateChangedAt = &stateChangedAt
+	i.StateReason = reason
+	if err := m.r.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "state", "state_changed_at", "state_reason"); err != nil {
+		return err
+	}
+
+	m.StateChanged(ctx, i, from)
+	return nil
+}
+
+// ArchiveInactiveIdentities archives all active and inactive identities which did not sign in since
+// the given time. Identities which never signed in are archived if they were created before that
+// time. It returns the number of archived identities.
+func (m *Manager) ArchiveInactiveIdentities(ctx context.Context, inactiveSince time.Time) (n int, err error) {
+	ctx, span := m.r.Tracer(ctx).Tracer().Start(ctx, "identity.Manager.ArchiveInactiveIdentities")
+	defer func() {
+		span.SetAttributes(attribute.Int("identities.archived", n))
+		otelx.End(span, &err)
+	}()
+
+	const batchSize = 1000
+	lastID := uuid.Nil
+	for {
+		is, err := m.r.PrivilegedIdentityPool().ListIdentitiesInactiveSince(ctx, inactiveSince, lastID, batchSize)
+		if err != nil {
+			return n, err
+		}
+
+		for k := range is {
+			if err := m.TransitionState(ctx, &is[k], StateArchived, StateReasonInactivity); err != nil {
+				return n, err
+			}
+			n++
+		}
+
+		if len(is) < batchSize {
+			return n, nil
+		}
+		lastID = is[len(is)-1].ID
+	}
+}
+
+// StateChanged emits the events and webhooks of a state change which was persisted.
+func (m *Manager) StateChanged(ctx context.Context, i *Identity, from State) {
+	trace.SpanFromContext(ctx).AddEvent(events.NewIdentityStateChanged(ctx, i.ID, string(from), string(i.State), i.StateReason))
+	m.r.Audit().
+		WithField("identity_id", i.ID).
+		WithField("identity_state_from", from).
+		WithField("identity_state_to", i.State).
+		WithField("identity_state_reason", i.StateReason).
+		Info("The state of an identity changed.")
+	m.r.IdentityWebhookSender().Send(ctx, i.ID, WebhookEventIdentityStateChanged, &WebhookStateChangedEventData{
+		From:   from,
+		To:     i.State,
+		Reason: i.StateReason,
+	})
 }
 
 func (m *Manager) UpdateSchemaID(ctx context.Context, id uuid.UUID, schemaID string, opts ...ManagerOption) (err error) {
@@ -476,7 +575,12 @@ func (m *Manager) UpdateSchemaID(ctx context.Context, id uuid.UUID, schemaID str
 		return err
 	}
 
-	return m.r.PrivilegedIdentityPool().UpdateIdentity(ctx, original)
+	if err := m.r.PrivilegedIdentityPool().UpdateIdentity(ctx, original); err != nil {
+		return err
+	}
+
+	m.r.IdentityWebhookSender().Send(ctx, original.ID, WebhookEventIdentityUpdated, nil)
+	return nil
 }
 
 func (m *Manager) SetTraits(ctx context.Context, id uuid.UUID, traits Traits, opts ...ManagerOption) (_ *Identity, err error) {
@@ -536,7 +640,12 @@ func (m *Manager) UpdateTraits(ctx context.Context, id uuid.UUID, traits Traits,
 		return err
 	}
 
-	return m.r.PrivilegedIdentityPool().UpdateIdentity(ctx, updated)
+	if err := m.r.PrivilegedIdentityPool().UpdateIdentity(ctx, updated); err != nil {
+		return err
+	}
+
+	m.r.IdentityWebhookSender().Send(ctx, updated.ID, WebhookEventIdentityUpdated, nil)
+	return nil
 }
 
 func (m *Manager) ValidateIdentity(ctx context.Context, i *Identity, o *ManagerOptions) (err error) {
@@ -577,7 +686,12 @@ func (m *Manager) CountActiveMultiFactorCredentials(ctx context.Context, i *Iden
 	// defer otelx.End(span, &err)
 
 	for _, strategy := range m.r.ActiveCredentialsCounterStrategies(ctx) {
-		current, err := strategy.CountActiveMultiFactorCredentials(ctx, i.Credentials)
+		var current int
+		if counter, ok := strategy.(ActiveIdentityMultiFactorCounter); ok {
+			current, err = counter.CountActiveMultiFactorCredentialsOfIdentity(ctx, i)
+		} else {
+			current, err = strategy.CountActiveMultiFactorCredentials(ctx, i.Credentials)
+		}
 		if err != nil {
 			return 0, err
 		}
@@ -24,10 +24,13 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
 
+	"github.com/ory/herodot"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/x"
 )
 
@@ -583,6 +586,80 @@ func TestManager(t *testing.T) {
 		})
 	})
 
+	t.Run("method=TransitionState", func(t *testing.T) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = newTraits("lifecycle-transition@ory.sh", "")
+		require.NoError(t, reg.IdentityManager().Create(ctx, i))
+
+		require.NoError(t, reg.IdentityManager().TransitionState(ctx, i, identity.StateLocked, "fraud_suspected"))
+		actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.Equal(t, identity.StateLocked, actual.State)
+		assert.Equal(t, "fraud_suspected", actual.StateReason)
+		assert.Equal(t, "lifecycle-transition@ory.sh", gjson.GetBytes(actual.Traits, "email").String())
+
+		require.NoError(t, reg.IdentityManager().TransitionState(ctx, i, identity.StateArchived, ""))
+		assert.ErrorIs(t, reg.IdentityManager().TransitionState(ctx, i, identity.StateLocked, ""), herodot.ErrBadRequest,
+			"archived identities must be restored first")
+		assert.ErrorIs(t, reg.IdentityManager().TransitionState(ctx, i, identity.StateActive, "Not A Code"), herodot.ErrBadRequest)
+
+		actual.State = identity.StateLocked
+		assert.ErrorIs(t, reg.IdentityManager().Update(ctx, actual, identity.ManagerAllowWriteProtectedTraits), herodot.ErrBadRequest,
+			"updates enforce the transitions as well")
+
+		require.NoError(t, reg.IdentityManager().TransitionState(ctx, i, identity.StateActive, ""))
+		actual, err = reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.Equal(t, identity.StateActive, actual.State)
+		assert.Empty(t, actual.StateReason)
+	})
+
+	t.Run("method=ArchiveInactiveIdentities", func(t *testing.T) {
+		longAgo := time.Now().Add(-3 * 365 * 24 * time.Hour)
+		create := func(t *testing.T, email string, state identity.State) *identity.Identity {
+			i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+			i.Traits = newTraits(email, "")
+			i.State = state
+			i.CreatedAt = longAgo
+			require.NoError(t, reg.IdentityManager().Create(ctx, i))
+			return i
+		}
+
+		stale := create(t, "lifecycle-stale@ory.sh", identity.StateActive)
+		deactivated := create(t, "lifecycle-inactive@ory.sh", identity.StateInactive)
+		locked := create(t, "lifecycle-locked@ory.sh", identity.StateLocked)
+		returning := create(t, "lifecycle-returning@ory.sh", identity.StateActive)
+
+		s := session.NewInactiveSession()
+		s.IdentityID = returning.ID
+		s.Active = true
+		s.AuthenticatedAt = time.Now().Add(-time.Hour)
+		s.IssuedAt = s.AuthenticatedAt
+		s.ExpiresAt = time.Now().Add(time.Hour)
+		require.NoError(t, reg.SessionPersister().UpsertSession(ctx, s))
+
+		n, err := reg.IdentityManager().ArchiveInactiveIdentities(ctx, time.Now().Add(-2*365*24*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		for _, tc := range []struct {
+			i        *identity.Identity
+			expected identity.State
+		}{
+			{stale, identity.StateArchived},
+			{deactivated, identity.StateArchived},
+			{locked, identity.StateLocked},
+			{returning, identity.StateActive},
+		} {
+			actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, tc.i.ID, identity.ExpandNothing)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.State, "%s", actual.Traits)
+			if tc.expected == identity.StateArchived {
+				assert.Equal(t, identity.StateReasonInactivity, actual.StateReason)
+			}
+		}
+	})
+
 	t.Run("method=CountActiveFirstFactorCredentials", func(t *testing.T) {
 		id := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
 		count, err := reg.IdentityManager().CountActiveFirstFactorCredentials(ctx, id)
@@ -5,6 +5,7 @@ package identity
 
 import (
 	"context"
+	"time"
 
 	"github.com/ory/x/crdbx"
 
@@ -115,6 +116,14 @@ type (
 
 		// FindIdentityByWebauthnUserHandle returns an identity matching a webauthn user handle.
 		FindIdentityByWebauthnUserHandle(ctx context.Context, userHandle []byte) (*Identity, error)
+
+		// ReencryptIdentityTraits encrypts the traits of all identities again using the current traits secret
+		// and the current identity schemas. It returns the number of identities whose traits were updated.
+		ReencryptIdentityTraits(ctx context.Context, batchSize int) (int, error)
+
+		// ListIdentitiesInactiveSince lists active and inactive identities which did not sign in since the given
+		// time, or never signed in and were created before it, ordered by ID. Associations are not expanded.
+		ListIdentitiesInactiveSince(ctx context.Context, since time.Time, afterID uuid.UUID, limit int) ([]Identity, error)
 	}
 )
 
@@ -1092,6 +1092,49 @@ func TestPool(ctx context.Context, p persistence.Persister, m *identity.Manager,
 			})
 		})
 
+		t.Run("case=find identity by its credentials phone number in any format", func(t *testing.T) {
+			number := randx.MustString(8, randx.Numeric)
+			expected := passwordIdentity("", "+49176"+number)
+			expected.Traits = identity.Traits(`{}`)
+
+			require.NoError(t, p.CreateIdentity(ctx, expected))
+			createdIDs = append(createdIDs, expected.ID)
+
+			for _, identifier := range []string{"+49176" + number, "+49 176 " + number, " +49 (176) " + number} {
+				actual, _, err := p.FindByCredentialsIdentifier(ctx, identity.CredentialsTypePassword, identifier)
+				require.NoError(t, err, identifier)
+				assert.Equal(t, expected.ID, actual.ID, identifier)
+			}
+
+			ctx := confighelpers.WithConfigValues(ctx, map[string]any{
+				config.ViperKeyIdentityPhoneNormalizationDefaultRegion: "DE",
+			})
+			actual, _, err := p.FindByCredentialsIdentifier(ctx, identity.CredentialsTypePassword, "0176 "+number)
+			require.NoError(t, err)
+			assert.Equal(t, expected.ID, actual.ID)
+		})
+
+		t.Run("case=find identity by its canonical email credentials identifier", func(t *testing.T) {
+			local := randx.MustString(8, randx.AlphaLower)
+			expected := passwordIdentity("", local+"doe@gmail.com")
+			expected.Traits = identity.Traits(`{}`)
+
+			require.NoError(t, p.CreateIdentity(ctx, expected))
+			createdIDs = append(createdIDs, expected.ID)
+
+			identifier := local + ".Doe+news@googlemail.com"
+			_, _, err := p.FindByCredentialsIdentifier(ctx, identity.CredentialsTypePassword, identifier)
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+
+			ctx := confighelpers.WithConfigValues(ctx, map[string]any{
+				config.ViperKeyIdentityEmailNormalizationStripPlusAlias: true,
+				config.ViperKeyIdentityEmailNormalizationStripGmailDots: true,
+			})
+			actual, _, err := p.FindByCredentialsIdentifier(ctx, identity.CredentialsTypePassword, identifier)
+			require.NoError(t, err)
+			assert.Equal(t, expected.ID, actual.ID)
+		})
+
 		t.Run("suite=verifiable-address", func(t *testing.T) {
 			createIdentityWithAddresses := func(t *testing.T, email string) identity.VerifiableAddress {
 				var i identity.Identity
@@ -1157,6 +1200,42 @@ func TestPool(ctx context.Context, p persistence.Persister, m *identity.Manager,
 				}
 			})
 
+			t.Run("case=find phone number in any format", func(t *testing.T) {
+				var i identity.Identity
+				require.NoError(t, faker.FakeData(&i))
+
+				number := randx.MustString(8, randx.Numeric)
+				i.VerifiableAddresses = append(i.VerifiableAddresses, *identity.NewVerifiableAddress("+49176"+number, i.ID, identity.AddressTypeSMS))
+				require.NoError(t, p.CreateIdentity(ctx, &i))
+
+				actual, err := p.FindVerifiableAddressByValue(ctx, identity.AddressTypeSMS, "+49 176 "+number)
+				require.NoError(t, err)
+				assert.Equal(t, i.VerifiableAddresses[0].ID, actual.ID)
+
+				_, err = p.FindVerifiableAddressByValue(ctx, identity.AddressTypeSMS, "0176 "+number)
+				require.ErrorIs(t, err, sqlcon.ErrNoRows)
+
+				ctx := confighelpers.WithConfigValues(ctx, map[string]any{
+					config.ViperKeyIdentityPhoneNormalizationDefaultRegion: "DE",
+				})
+				actual, err = p.FindVerifiableAddressByValue(ctx, identity.AddressTypeSMS, "0176 "+number)
+				require.NoError(t, err)
+				assert.Equal(t, i.VerifiableAddresses[0].ID, actual.ID)
+			})
+
+			t.Run("case=find phone number stored before normalization", func(t *testing.T) {
+				var i identity.Identity
+				require.NoError(t, faker.FakeData(&i))
+
+				number := randx.MustString(8, randx.Numeric)
+				i.VerifiableAddresses = append(i.VerifiableAddresses, *identity.NewVerifiableAddress("+49 176 "+number, i.ID, identity.AddressTypeSMS))
+				require.NoError(t, p.CreateIdentity(ctx, &i))
+
+				actual, err := p.FindVerifiableAddressByValue(ctx, identity.AddressTypeSMS, "+49 176 "+number)
+				require.NoError(t, err)
+				assert.Equal(t, i.VerifiableAddresses[0].ID, actual.ID)
+			})
+
 			t.Run("case=update", func(t *testing.T) {
 				address := createIdentityWithAddresses(t, "verification.TestPersister.Update@ory.sh ")
 
@@ -0,0 +1,557 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/gtank/cryptopasta"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/jsonschemax"
+	"github.com/ory/x/otelx"
+)
+
+// encryptedTraitPrefix marks trait values which are encrypted at rest. Encrypted values are stored as
+// `ory_enc:v1:<key id>:<encrypted data key>:<ciphertext>` with every part encoded as unpadded base64url.
+// The ciphertext is bound to the identity and its network, see traitsAdditionalData.
+const encryptedTraitPrefix = "ory_enc:v1:"
+
+type (
+	// TraitsKeyWrapper encrypts and decrypts the data keys which encrypt the identity traits. The
+	// default implementation uses the `secrets.traits` secrets but it can be replaced, for example
+	// to delegate the key encryption to a key management service.
+	TraitsKeyWrapper interface {
+		// CurrentKeyID returns the ID of the key which WrapKey encrypts data keys with.
+		CurrentKeyID(ctx context.Context) (string, error)
+
+		// WrapKey encrypts the data key and returns it together with the ID of the key it was encrypted with.
+		WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+
+		// UnwrapKey decrypts a data key which was encrypted with the key of the given ID.
+		UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	}
+	TraitsKeyWrapperProvider interface {
+		IdentityTraitsKeyWrapper() TraitsKeyWrapper
+	}
+
+	traitsSecretsProvider interface {
+		SecretsTraits(ctx context.Context) [][32]byte
+	}
+
+	// LocalTraitsKeyWrapper encrypts data keys with the `secrets.traits` secrets using AES-GCM.
+	LocalTraitsKeyWrapper struct {
+		c traitsSecretsProvider
+	}
+
+	traitsEncrypterDependencies interface {
+		TraitsKeyWrapperProvider
+		schema.IdentitySchemaProvider
+		x.TracingProvider
+	}
+
+	// TraitsEncrypter encrypts the traits annotated with `"encrypt": true` in the identity schema
+	// before they are persisted and decrypts them when they are read.
+	TraitsEncrypter struct {
+		r     traitsEncrypterDependencies
+		paths *ristretto.Cache[string, []string]
+	}
+	TraitsEncrypterProvider interface {
+		IdentityTraitsEncrypter() *TraitsEncrypter
+	}
+
+	encryptedTrait struct {
+		path  string
+		keyID string
+	}
+)
+
+var _ TraitsKeyWrapper = new(LocalTraitsKeyWrapper)
+
+func NewLocalTraitsKeyWrapper(c traitsSecretsProvider) *LocalTraitsKeyWrapper {
+	return &LocalTraitsKeyWrapper{c: c}
+}
+
+func localTraitsKeyID(secret *[32]byte) string {
+	sum := sha256.Sum256(secret[:])
+	return hex.EncodeToString(sum[:8])
+}
+
+func (w *LocalTraitsKeyWrapper) CurrentKeyID(ctx context.Context) (string, error) {
+	secrets := w.c.SecretsTraits(ctx)
+	if len(secrets) == 0 {
+		return "", errors.WithStack(herodot.ErrInternalServerError.WithReason("Unable to encrypt identity traits because no traits secrets were configured."))
+	}
+	return localTraitsKeyID(&secrets[0]), nil
+}
+
+func (w *LocalTraitsKeyWrapper) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	secrets := w.c.SecretsTraits(ctx)
+	if len(secrets) == 0 {
+		return "", nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("Unable to encrypt identity traits because no traits secrets were configured."))
+	}
+
+	wrapped, err := cryptopasta.Encrypt(dataKey, &secrets[0])
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	return localTraitsKeyID(&secrets[0]), wrapped, nil
+}
+
+func (w *LocalTraitsKeyWrapper) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	secrets := w.c.SecretsTraits(ctx)
+	for k := range secrets {
+		if localTraitsKeyID(&secrets[k]) != keyID {
+			continue
+		}
+
+		dataKey, err := cryptopasta.Decrypt(wrapped, &secrets[k])
+		if err != nil {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReason("Unable to decrypt the identity traits data key."))
+		}
+		return dataKey, nil
+	}
+
+	return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decrypt identity traits because the traits secret %q is not configured.", keyID))
+}
+
+func NewTraitsEncrypter(r traitsEncrypterDependencies) *TraitsEncrypter {
+	paths, _ := ristretto.NewCache(&ristretto.Config[string, []string]{
+		MaxCost:     10_000, // one per schema
+		NumCounters: 100_000,
+		BufferItems: 64,
+	})
+	return &TraitsEncrypter{r: r, paths: paths}
+}
+
+// EncryptedTraitPaths returns the paths of the traits which are annotated with `"encrypt": true`
+// in the identity schema. Traits nested in an encrypted trait are not returned. The paths are
+// cached per schema URL.
+func (e *TraitsEncrypter) EncryptedTraitPaths(ctx context.Context, schemaID string) (_ []string, err error) {
+	ctx, span := e.r.Tracer(ctx).Tracer().Start(ctx, "identity.TraitsEncrypter.EncryptedTraitPaths")
+	defer otelx.End(span, &err)
+
+	ss, err := e.r.IdentityTraitsSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := ss.GetByID(schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if paths, ok := e.paths.Get(s.URL.String()); ok {
+		return paths, nil
+	}
+
+	paths, err := e.compileEncryptedTraitPaths(ctx, s.URL.String())
+	if err != nil {
+		return nil, err
+	}
+	e.paths.SetWithTTL(s.URL.String(), paths, 1, 60*time.Minute)
+	return paths, nil
+}
+
+func (e *TraitsEncrypter) compileEncryptedTraitPaths(ctx context.Context, schemaURL string) ([]string, error) {
+	runner, err := schema.NewExtensionRunner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := jsonschema.NewCompiler()
+	c.ExtractAnnotations = true
+	runner.Register(c)
+
+	compiled, err := c.Compile(ctx, schemaURL)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to compile the identity schema.").WithDebugf("%s", err))
+	}
+
+	schemaPaths, err := jsonschemax.ListPathsWithInitializedSchema(compiled)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to list the paths of the identity schema.").WithDebugf("%s", err))
+	}
+
+	var paths []string
+	for _, p := range schemaPaths {
+		ext, ok := p.CustomProperties[schema.ExtensionName].(*schema.ExtensionConfig)
+		if !ok || !ext.Encrypt {
+			continue
+		}
+		if name, ok := strings.CutPrefix(p.Name, "traits."); ok {
+			paths = append(paths, name)
+		}
+	}
+
+	// Sorting guarantees that parents come before their children.
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+	return slices.DeleteFunc(paths, func(p string) bool {
+		return slices.ContainsFunc(paths, func(parent string) bool {
+			return strings.HasPrefix(p, parent+".")
+		})
+	}), nil
+}
+
+// EncryptTraits returns the identity's traits with all traits annotated with `"encrypt": true`
+// encrypted. The identity itself is not modified. Traits which are not encrypted must not look
+// like encrypted traits.
+func (e *TraitsEncrypter) EncryptTraits(ctx context.Context, i *Identity) (_ Traits, err error) {
+	paths, err := e.EncryptedTraitPaths(ctx, i.SchemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if path, found := findEncryptedTraitPrefix(i.Traits, paths); found {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The value of identity trait %q must not start with %q.", path, encryptedTraitPrefix))
+	}
+
+	return e.encrypt(ctx, i.Traits, paths, traitsAdditionalData(i))
+}
+
+// DecryptTraits decrypts the traits of the identity in place which are annotated with
+// `"encrypt": true` in its identity schema.
+func (e *TraitsEncrypter) DecryptTraits(ctx context.Context, i *Identity) (err error) {
+	if !bytes.Contains(i.Traits, []byte(encryptedTraitPrefix)) {
+		return nil
+	}
+
+	ctx, span := e.r.Tracer(ctx).Tracer().Start(ctx, "identity.TraitsEncrypter.DecryptTraits")
+	defer otelx.End(span, &err)
+
+	paths, err := e.EncryptedTraitPaths(ctx, i.SchemaID)
+	if err != nil {
+		return err
+	}
+
+	traits, _, err := e.decrypt(ctx, i.Traits, paths, false, traitsAdditionalData(i))
+	if err != nil {
+		return err
+	}
+
+	i.Traits = traits
+	return nil
+}
+
+// ReencryptTraits takes the traits of an identity as they are stored and encrypts them again using
+// the current key and the current identity schema. It returns false if the traits are up to date.
+func (e *TraitsEncrypter) ReencryptTraits(ctx context.Context, i *Identity) (_ Traits, changed bool, err error) {
+	ctx, span := e.r.Tracer(ctx).Tracer().Start(ctx, "identity.TraitsEncrypter.ReencryptTraits")
+	defer otelx.End(span, &err)
+
+	paths, err := e.EncryptedTraitPaths(ctx, i.SchemaID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Traits which are no longer annotated are decrypted as well so that they are stored in
+	// plain text from now on.
+	plain, encrypted, err := e.decrypt(ctx, i.Traits, paths, true, traitsAdditionalData(i))
+	if err != nil {
+		return nil, false, err
+	}
+
+	expected := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if v := gjson.GetBytes(plain, p); v.Exists() && v.Type != gjson.Null {
+			expected = append(expected, p)
+		}
+	}
+
+	actual := make([]string, 0, len(encrypted))
+	for _, t := range encrypted {
+		actual = append(actual, t.path)
+	}
+	slices.Sort(actual)
+	changed = !slices.Equal(expected, actual)
+
+	if !changed && len(encrypted) > 0 {
+		current, err := e.r.IdentityTraitsKeyWrapper().CurrentKeyID(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		changed = slices.ContainsFunc(encrypted, func(t encryptedTrait) bool {
+			return t.keyID != current
+		})
+	}
+
+	if !changed {
+		return i.Traits, false, nil
+	}
+
+	traits, err := e.encrypt(ctx, plain, paths, traitsAdditionalData(i))
+	if err != nil {
+		return nil, false, err
+	}
+	return traits, true, nil
+}
+
+// traitsAdditionalData returns the additional data which the encrypted traits are authenticated
+// with. It binds the ciphertext to the identity and its network so that encrypted values can not
+// be copied to another identity.
+func traitsAdditionalData(i *Identity) []byte {
+	return append(i.ID.Bytes(), i.NID.Bytes()...)
+}
+
+// sealTrait encrypts the plaintext with AES-256-GCM and returns the nonce followed by the ciphertext.
+func sealTrait(plaintext, key, additionalData []byte) ([]byte, error) {
+	gcm, err := newTraitsGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openTrait decrypts a ciphertext created by sealTrait.
+func openTrait(ciphertext, key, additionalData []byte) ([]byte, error) {
+	gcm, err := newTraitsGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], additionalData)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return plaintext, nil
+}
+
+func newTraitsGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return gcm, nil
+}
+
+func (e *TraitsEncrypter) encrypt(ctx context.Context, traits Traits, paths []string, additionalData []byte) (_ Traits, err error) {
+	if len(paths) == 0 {
+		return traits, nil
+	}
+
+	ctx, span := e.r.Tracer(ctx).Tracer().Start(ctx, "identity.TraitsEncrypter.encrypt")
+	defer otelx.End(span, &err)
+
+	var dataKey [32]byte
+	if _, err := io.ReadFull(rand.Reader, dataKey[:]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	keyID, wrapped, err := e.r.IdentityTraitsKeyWrapper().WrapKey(ctx, dataKey[:])
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := encryptedTraitPrefix +
+		base64.RawURLEncoding.EncodeToString([]byte(keyID)) + ":" +
+		base64.RawURLEncoding.EncodeToString(wrapped) + ":"
+
+	result := []byte(traits)
+	for _, p := range paths {
+		v := gjson.GetBytes(result, p)
+		if !v.Exists() || v.Type == gjson.Null {
+			continue
+		}
+
+		ciphertext, err := sealTrait([]byte(v.Raw), dataKey[:], additionalData)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		result, err = sjson.SetBytes(result, p, prefix+base64.RawURLEncoding.EncodeToString(ciphertext))
+		if err != nil {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to encrypt identity trait %q.", p))
+		}
+	}
+
+	return result, nil
+}
+
+// decrypt decrypts the traits at the given paths. If unannotated is true, it also decrypts all
+// other traits which look encrypted and keeps those it is unable to decrypt as they are.
+func (e *TraitsEncrypter) decrypt(ctx context.Context, traits Traits, paths []string, unannotated bool, additionalData []byte) (Traits, []encryptedTrait, error) {
+	if !bytes.Contains(traits, []byte(encryptedTraitPrefix)) {
+		return traits, nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(traits))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReason("Unable to decode identity traits."))
+	}
+
+	d := &traitsDecrypter{ctx: ctx, w: e.r.IdentityTraitsKeyWrapper(), keys: map[string][]byte{}, paths: paths, unannotated: unannotated, additionalData: additionalData}
+	doc, err := d.walk(doc, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decrypted, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	slices.SortFunc(d.encrypted, func(a, b encryptedTrait) int {
+		return strings.Compare(a.path, b.path)
+	})
+	return decrypted, d.encrypted, nil
+}
+
+type traitsDecrypter struct {
+	ctx            context.Context
+	w              TraitsKeyWrapper
+	keys           map[string][]byte
+	paths          []string
+	unannotated    bool
+	additionalData []byte
+	encrypted      []encryptedTrait
+}
+
+func (d *traitsDecrypter) walk(v any, path string) (any, error) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch t := v.(type) {
+	case map[string]any:
+		for key, child := range t {
+			decrypted, err := d.walk(child, join(key))
+			if err != nil {
+				return nil, err
+			}
+			t[key] = decrypted
+		}
+	case []any:
+		for k, child := range t {
+			decrypted, err := d.walk(child, join(strconv.Itoa(k)))
+			if err != nil {
+				return nil, err
+			}
+			t[k] = decrypted
+		}
+	case string:
+		if !strings.HasPrefix(t, encryptedTraitPrefix) {
+			break
+		}
+		if slices.Contains(d.paths, path) {
+			return d.decrypt(t, path)
+		}
+		if d.unannotated {
+			if decrypted, err := d.decrypt(t, path); err == nil {
+				return decrypted, nil
+			}
+		}
+	}
+	return v, nil
+}
+
+// findEncryptedTraitPrefix returns the path of the first trait outside of the given paths whose
+// value starts with the prefix of encrypted traits.
+func findEncryptedTraitPrefix(traits Traits, paths []string) (path string, found bool) {
+	if !bytes.Contains(traits, []byte(encryptedTraitPrefix)) {
+		return "", false
+	}
+
+	var walk func(v gjson.Result, p string)
+	walk = func(v gjson.Result, p string) {
+		if found || slices.Contains(paths, p) {
+			return
+		}
+		switch {
+		case v.IsObject() || v.IsArray():
+			k := 0
+			v.ForEach(func(key, child gjson.Result) bool {
+				name := key.String()
+				if v.IsArray() {
+					name = strconv.Itoa(k)
+					k++
+				}
+				if p != "" {
+					name = p + "." + name
+				}
+				walk(child, name)
+				return !found
+			})
+		case v.Type == gjson.String && strings.HasPrefix(v.String(), encryptedTraitPrefix):
+			path, found = p, true
+		}
+	}
+	walk(gjson.ParseBytes(traits), "")
+	return path, found
+}
+
+func (d *traitsDecrypter) decrypt(value, path string) (json.RawMessage, error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedTraitPrefix), ":")
+	if len(parts) != 3 {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decrypt identity trait %q because it is malformed.", path))
+	}
+
+	decoded := make([][]byte, len(parts))
+	for k, part := range parts {
+		var err error
+		if decoded[k], err = base64.RawURLEncoding.DecodeString(part); err != nil {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to decrypt identity trait %q because it is malformed.", path))
+		}
+	}
+	keyID, wrapped, ciphertext := string(decoded[0]), decoded[1], decoded[2]
+
+	cacheKey := parts[0] + ":" + parts[1]
+	dataKey, ok := d.keys[cacheKey]
+	if !ok {
+		var err error
+		if dataKey, err = d.w.UnwrapKey(d.ctx, keyID, wrapped); err != nil {
+			return nil, err
+		}
+		d.keys[cacheKey] = dataKey
+	}
+
+	if len(dataKey) != 32 {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decrypt identity trait %q because the data key is invalid.", path))
+	}
+
+	plaintext, err := openTrait(ciphertext, dataKey, d.additionalData)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to decrypt identity trait %q.", path))
+	}
+
+	d.encrypted = append(d.encrypted, encryptedTrait{path: path, keyID: keyID})
+	return plaintext, nil
+}
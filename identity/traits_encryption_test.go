@@ -0,0 +1,195 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/x"
+)
+
+func TestTraitsEncrypter(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetIdentitySchemas(t, conf, map[string]string{
+		"default":   "file://./stub/identity.schema.json",
+		"encrypted": "file://./stub/encrypted.schema.json",
+	})
+
+	const (
+		oldSecret = "old-traits-secret-0123456789abcd"
+		newSecret = "new-traits-secret-0123456789abcd"
+		plain     = `{"email":"jane@ory.sh","ssn":"123-45-6789","address":{"street":"Main St 1","zip":12345},"phones":["+4917612345678"]}`
+	)
+	conf.MustSet(ctx, config.ViperKeySecretsTraits, []string{oldSecret})
+
+	e := reg.IdentityTraitsEncrypter()
+	newIdentity := func() *identity.Identity {
+		i := identity.NewIdentity("encrypted")
+		i.Traits = identity.Traits(plain)
+		return i
+	}
+
+	isEncrypted := func(v gjson.Result) bool {
+		return v.Type == gjson.String && strings.HasPrefix(v.String(), "ory_enc:v1:")
+	}
+
+	t.Run("case=lists the encrypted trait paths", func(t *testing.T) {
+		paths, err := e.EncryptedTraitPaths(ctx, "encrypted")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"address", "phones", "ssn"}, paths)
+
+		paths, err = e.EncryptedTraitPaths(ctx, "default")
+		require.NoError(t, err)
+		assert.Empty(t, paths)
+	})
+
+	t.Run("case=encrypts and decrypts annotated traits", func(t *testing.T) {
+		i := newIdentity()
+		encrypted, err := e.EncryptTraits(ctx, i)
+		require.NoError(t, err)
+		assert.JSONEq(t, plain, string(i.Traits), "the identity must not be modified")
+
+		assert.Equal(t, "jane@ory.sh", gjson.GetBytes(encrypted, "email").String())
+		for _, path := range []string{"ssn", "address", "phones"} {
+			assert.True(t, isEncrypted(gjson.GetBytes(encrypted, path)), "%s: %s", path, encrypted)
+		}
+		assert.NotContains(t, string(encrypted), "123-45-6789")
+		assert.NotContains(t, string(encrypted), "Main St")
+
+		i.Traits = encrypted
+		require.NoError(t, e.DecryptTraits(ctx, i))
+		assert.JSONEq(t, plain, string(i.Traits))
+	})
+
+	t.Run("case=binds the encrypted traits to the identity and network", func(t *testing.T) {
+		i := newIdentity()
+		i.ID, i.NID = x.NewUUID(), x.NewUUID()
+		encrypted, err := e.EncryptTraits(ctx, i)
+		require.NoError(t, err)
+
+		other := newIdentity()
+		other.ID, other.NID = x.NewUUID(), i.NID
+		other.Traits = encrypted
+		require.Error(t, e.DecryptTraits(ctx, other), "the traits must not decrypt for another identity")
+
+		other.ID, other.NID = i.ID, x.NewUUID()
+		other.Traits = encrypted
+		require.Error(t, e.DecryptTraits(ctx, other), "the traits must not decrypt in another network")
+
+		i.Traits = encrypted
+		require.NoError(t, e.DecryptTraits(ctx, i))
+		assert.JSONEq(t, plain, string(i.Traits))
+	})
+
+	t.Run("case=does not modify traits without annotations", func(t *testing.T) {
+		i := identity.NewIdentity("default")
+		i.Traits = identity.Traits(`{"email":"jane@ory.sh","bar":"baz"}`)
+		encrypted, err := e.EncryptTraits(ctx, i)
+		require.NoError(t, err)
+		assert.Equal(t, string(i.Traits), string(encrypted))
+	})
+
+	t.Run("case=only decrypts annotated traits", func(t *testing.T) {
+		forged := `{"email":"ory_enc:v1:a:b:c"}`
+
+		i := identity.NewIdentity("default")
+		i.Traits = identity.Traits(forged)
+		require.NoError(t, e.DecryptTraits(ctx, i))
+		assert.JSONEq(t, forged, string(i.Traits))
+
+		i = identity.NewIdentity("encrypted")
+		i.Traits = identity.Traits(forged)
+		require.NoError(t, e.DecryptTraits(ctx, i))
+		assert.JSONEq(t, forged, string(i.Traits))
+	})
+
+	t.Run("case=rejects unannotated traits which look encrypted", func(t *testing.T) {
+		for _, traits := range []string{
+			`{"email":"ory_enc:v1:a:b:c"}`,
+			`{"email":"jane@ory.sh","nested":{"list":["ory_enc:v1:a:b:c"]}}`,
+		} {
+			i := identity.NewIdentity("encrypted")
+			i.Traits = identity.Traits(traits)
+			_, err := e.EncryptTraits(ctx, i)
+			require.ErrorIs(t, err, herodot.ErrBadRequest, traits)
+		}
+
+		i := identity.NewIdentity("encrypted")
+		i.Traits = identity.Traits(`{"email":"jane@ory.sh","ssn":"ory_enc:v1:a:b:c"}`)
+		encrypted, err := e.EncryptTraits(ctx, i)
+		require.NoError(t, err, "annotated traits are encrypted as they are")
+
+		i.Traits = encrypted
+		require.NoError(t, e.DecryptTraits(ctx, i))
+		assert.Equal(t, "ory_enc:v1:a:b:c", gjson.GetBytes(i.Traits, "ssn").String())
+	})
+
+	t.Run("case=fails without traits secrets", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySecretsTraits, []string{})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySecretsTraits, []string{oldSecret}) })
+
+		_, err := e.EncryptTraits(ctx, newIdentity())
+		require.Error(t, err)
+	})
+
+	t.Run("case=persists encrypted traits and rotates the key", func(t *testing.T) {
+		i := newIdentity()
+		i.Traits = identity.Traits(`{"email":"` + strings.ToLower(t.Name()) + `@ory.sh","ssn":"123-45-6789"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		assert.Equal(t, "123-45-6789", gjson.GetBytes(i.Traits, "ssn").String(), "the identity keeps its plain text traits")
+
+		rawTraits := func(t *testing.T) string {
+			var raw struct {
+				Traits string `db:"traits"`
+			}
+			require.NoError(t, reg.Persister().GetConnection(ctx).RawQuery("SELECT traits FROM identities WHERE id = ?", i.ID).First(&raw))
+			return raw.Traits
+		}
+
+		stored := rawTraits(t)
+		assert.True(t, isEncrypted(gjson.Get(stored, "ssn")), stored)
+
+		actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.Equal(t, "123-45-6789", gjson.GetBytes(actual.Traits, "ssn").String())
+
+		actual.Traits = identity.Traits(`{"email":"` + strings.ToLower(t.Name()) + `@ory.sh","ssn":"987-65-4321"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentity(ctx, actual))
+		assert.NotContains(t, rawTraits(t), "987-65-4321")
+
+		// Rotate the key: the old secret is kept for decryption.
+		conf.MustSet(ctx, config.ViperKeySecretsTraits, []string{newSecret, oldSecret})
+		n, err := reg.PrivilegedIdentityPool().ReencryptIdentityTraits(ctx, 1)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, n, 1)
+
+		n, err = reg.PrivilegedIdentityPool().ReencryptIdentityTraits(ctx, 1)
+		require.NoError(t, err)
+		assert.Zero(t, n, "traits which are up to date must not be updated")
+
+		// The traits can be read without the old secret.
+		conf.MustSet(ctx, config.ViperKeySecretsTraits, []string{newSecret})
+		actual, err = reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.Equal(t, "987-65-4321", gjson.GetBytes(actual.Traits, "ssn").String())
+
+		// Reading traits encrypted with an unknown secret fails.
+		conf.MustSet(ctx, config.ViperKeySecretsTraits, []string{oldSecret})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySecretsTraits, []string{oldSecret}) })
+		_, err = reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.Error(t, err)
+	})
+}
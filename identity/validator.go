@@ -66,8 +66,8 @@ func (v *Validator) ValidateWithRunner(ctx context.Context, i *Identity, runners
 func (v *Validator) Validate(ctx context.Context, i *Identity) error {
 	return otelx.WithSpan(ctx, "identity.Validator.Validate", func(ctx context.Context) error {
 		return v.ValidateWithRunner(ctx, i,
-			NewSchemaExtensionCredentials(i),
-			NewSchemaExtensionVerification(i, v.d.Config().SelfServiceFlowVerificationRequestLifespan(ctx)),
+			NewSchemaExtensionCredentials(i, v.d.Config().IdentityEmailNormalization(ctx), v.d.Config().IdentityPhoneDefaultRegion(ctx)),
+			NewSchemaExtensionVerification(i, v.d.Config().SelfServiceFlowVerificationRequestLifespan(ctx), v.d.Config().IdentityPhoneDefaultRegion(ctx)),
 			NewSchemaExtensionRecovery(i),
 		)
 	})
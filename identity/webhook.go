@@ -0,0 +1,324 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package identity
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/cipher"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+)
+
+// WebhookEvent is the type of lifecycle event delivered to an identity's webhook.
+//
+// swagger:enum identityWebhookEvent
+type WebhookEvent string
+
+const (
+	WebhookEventIdentityUpdated WebhookEvent = "identity.updated"
+	WebhookEventIdentityDeleted WebhookEvent = "identity.deleted"
+	WebhookEventSessionIssued   WebhookEvent = "session.issued"
+	WebhookEventSessionRevoked  WebhookEvent = "session.revoked"
+
+	// WebhookEventIdentityStateChanged is sent in addition to `identity.updated` when the
+	// identity's state changed.
+	WebhookEventIdentityStateChanged WebhookEvent = "identity.state_changed"
+
+	// WebhookEventVerification is sent when the webhook is set. The callback URL must respond with
+	// the challenge contained in the payload.
+	WebhookEventVerification WebhookEvent = "webhook.verification"
+)
+
+const (
+	// WebhookSignatureHeader contains the timestamp and the HMAC-SHA256 signature of every
+	// webhook request in the format `t=<unix timestamp>,v1=<hex encoded signature>`.
+	WebhookSignatureHeader = "Ory-Webhook-Signature"
+
+	webhookTimeout = 10 * time.Second
+)
+
+// Identity Webhook
+//
+// An identity webhook receives the lifecycle events of a single identity, in addition
+// to the web hooks configured globally.
+//
+// swagger:model identityWebhook
+type Webhook struct {
+	// The webhook's ID.
+	//
+	// required: true
+	ID uuid.UUID `json:"id" faker:"-" db:"id"`
+
+	// The ID of the identity whose lifecycle events are delivered to the webhook.
+	//
+	// required: true
+	IdentityID uuid.UUID `json:"identity_id" faker:"-" db:"identity_id"`
+
+	// The callback URL lifecycle events are sent to.
+	//
+	// required: true
+	URL string `json:"url" db:"url"`
+
+	// The secret used to sign the webhook requests. It is only returned when the webhook is set.
+	Secret string `json:"secret,omitempty" faker:"-" db:"-"`
+
+	// EncryptedSecret is the secret encrypted with the cipher secrets.
+	EncryptedSecret string `json:"-" faker:"-" db:"secret"`
+
+	// VerifiedAt is the time the callback URL completed the verification handshake.
+	//
+	// required: true
+	VerifiedAt time.Time `json:"verified_at" faker:"-" db:"verified_at"`
+
+	// CreatedAt is a helper struct field for gobuffalo.pop.
+	CreatedAt time.Time `json:"created_at" faker:"-" db:"created_at"`
+
+	// UpdatedAt is a helper struct field for gobuffalo.pop.
+	UpdatedAt time.Time `json:"updated_at" faker:"-" db:"updated_at"`
+
+	NID uuid.UUID `json:"-" faker:"-" db:"nid"`
+}
+
+func (w Webhook) TableName(context.Context) string {
+	return "identity_webhooks"
+}
+
+type (
+	WebhookPersister interface {
+		// UpsertIdentityWebhook sets the webhook of the identity, replacing any existing webhook.
+		UpsertIdentityWebhook(ctx context.Context, w *Webhook) error
+		GetIdentityWebhook(ctx context.Context, identityID uuid.UUID) (*Webhook, error)
+		DeleteIdentityWebhook(ctx context.Context, identityID uuid.UUID) error
+	}
+	WebhookPersistenceProvider interface {
+		IdentityWebhookPersister() WebhookPersister
+	}
+
+	webhookSenderDependencies interface {
+		WebhookPersistenceProvider
+		cipher.Provider
+		deadletter.PersistenceProvider
+		x.HTTPClientProvider
+		x.LoggingProvider
+		x.TracingProvider
+	}
+	WebhookSender struct {
+		r webhookSenderDependencies
+	}
+	WebhookSenderProvider interface {
+		IdentityWebhookSender() *WebhookSender
+	}
+
+	// WebhookSessionEventData is the data of the session lifecycle events.
+	WebhookSessionEventData struct {
+		SessionID uuid.UUID `json:"session_id"`
+	}
+
+	// WebhookStateChangedEventData is the data of the `identity.state_changed` event.
+	WebhookStateChangedEventData struct {
+		From   State  `json:"from"`
+		To     State  `json:"to"`
+		Reason string `json:"reason,omitempty"`
+	}
+
+	webhookPayload struct {
+		ID         uuid.UUID    `json:"id"`
+		Type       WebhookEvent `json:"type"`
+		IdentityID uuid.UUID    `json:"identity_id"`
+		OccurredAt time.Time    `json:"occurred_at"`
+		Data       any          `json:"data,omitempty"`
+		Challenge  string       `json:"challenge,omitempty"`
+	}
+)
+
+func NewWebhookSender(r webhookSenderDependencies) *WebhookSender {
+	return &WebhookSender{r: r}
+}
+
+// SignWebhookPayload returns the value of the signature header for the given body.
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	_, _ = mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Verify performs the verification handshake with the webhook's callback URL. The callback
+// URL must respond with a 2xx status code and the challenge of the verification payload.
+func (s *WebhookSender) Verify(ctx context.Context, w *Webhook) (err error) {
+	ctx, span := s.r.Tracer(ctx).Tracer().Start(ctx, "identity.WebhookSender.Verify")
+	defer otelx.End(span, &err)
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	payload := s.payload(w, WebhookEventVerification, nil)
+	payload.Challenge = uuid.Must(uuid.NewV4()).String()
+
+	res, err := s.post(ctx, w.URL, w.Secret, payload)
+	if err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReasonf("Unable to reach the webhook callback URL: %s", err))
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("The webhook callback URL responded with status code %d to the verification request.", res.StatusCode))
+	}
+
+	var body struct {
+		Challenge string `json:"challenge"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body); err != nil || body.Challenge != payload.Challenge {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The webhook callback URL did not respond with the challenge of the verification request."))
+	}
+
+	return nil
+}
+
+// Send delivers the lifecycle event to the webhook of the identity, if one is set. Events
+// are delivered in the background and failed deliveries are recorded so that they can be
+// replayed.
+func (s *WebhookSender) Send(ctx context.Context, identityID uuid.UUID, event WebhookEvent, data any) {
+	w, err := s.r.IdentityWebhookPersister().GetIdentityWebhook(ctx, identityID)
+	if errors.Is(err, sqlcon.ErrNoRows) {
+		return
+	} else if err != nil {
+		s.r.Logger().WithError(err).WithField("identity_id", identityID).Error("Unable to load the identity webhook.")
+		return
+	}
+
+	s.Deliver(ctx, w, event, data)
+}
+
+// Deliver delivers the lifecycle event to the webhook in the background. Nothing is sent to
+// webhooks which did not complete the verification handshake.
+func (s *WebhookSender) Deliver(ctx context.Context, w *Webhook, event WebhookEvent, data any) {
+	logger := s.r.Logger().
+		WithField("identity_id", w.IdentityID).
+		WithField("identity_webhook_id", w.ID).
+		WithField("identity_webhook_event", event)
+
+	if w.VerifiedAt.IsZero() {
+		logger.Debug("Skipped the identity webhook event because the webhook is not verified.")
+		return
+	}
+
+	secret, err := s.r.Cipher(ctx).Decrypt(ctx, w.EncryptedSecret)
+	if err != nil {
+		logger.WithError(err).Error("Unable to decrypt the identity webhook secret.")
+		return
+	}
+
+	req, err := s.request(ctx, w.URL, string(secret), s.payload(w, event, data))
+	if err != nil {
+		logger.WithError(err).Error("Unable to create the identity webhook request.")
+		return
+	}
+
+	go func() {
+		ctx := context.WithoutCancel(ctx)
+		ctx, span := s.r.Tracer(ctx).Tracer().Start(ctx, "identity.WebhookSender.Deliver")
+		var err error
+		defer otelx.End(span, &err)
+		defer func() {
+			if err != nil {
+				logger.WithError(err).Warn("Unable to deliver the identity webhook event.")
+				s.recordFailedDelivery(ctx, w, req, err)
+			}
+		}()
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+		defer cancel()
+
+		res, err := s.r.HTTPClient(timeoutCtx).Do(req.WithContext(timeoutCtx))
+		if err != nil {
+			return
+		}
+		defer func() { _ = res.Body.Close() }()
+
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			err = errors.Errorf("identity webhook responded with status code %d", res.StatusCode)
+			return
+		}
+
+		logger.Debug("Delivered the identity webhook event.")
+	}()
+}
+
+// recordFailedDelivery stores the request of a failed delivery so that it can be replayed
+// using the failed web hook events admin API.
+func (s *WebhookSender) recordFailedDelivery(ctx context.Context, w *Webhook, req *retryablehttp.Request, cause error) {
+	logger := s.r.Logger().
+		WithField("identity_id", w.IdentityID).
+		WithField("identity_webhook_id", w.ID)
+
+	body, err := req.BodyBytes()
+	if err != nil {
+		logger.WithError(err).Error("Unable to read the body of the failed identity webhook request.")
+		return
+	}
+
+	event, err := deadletter.NewEvent(ctx, s.r, w.ID.String(), req.Method, req.URL.String(), req.Header.Clone(), body, cause)
+	if err == nil {
+		err = s.r.FailedWebhookEventPersister().CreateFailedWebhookEvent(ctx, event)
+	}
+	if err != nil {
+		logger.WithError(err).Error("Unable to record the failed identity webhook request.")
+		return
+	}
+
+	logger.WithField("failed_webhook_event_id", event.ID).Info("Recorded the failed identity webhook request so that it can be replayed.")
+}
+
+func (s *WebhookSender) payload(w *Webhook, event WebhookEvent, data any) *webhookPayload {
+	return &webhookPayload{
+		ID:         uuid.Must(uuid.NewV4()),
+		Type:       event,
+		IdentityID: w.IdentityID,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+func (s *WebhookSender) request(ctx context.Context, u, secret string, payload *webhookPayload) (*retryablehttp.Request, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, payload.OccurredAt, body))
+
+	return req, nil
+}
+
+func (s *WebhookSender) post(ctx context.Context, u, secret string, payload *webhookPayload) (*http.Response, error) {
+	req, err := s.request(ctx, u, secret, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.r.HTTPClient(ctx).Do(req)
+}
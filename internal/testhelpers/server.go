@@ -32,9 +32,11 @@ func NewKratosServerWithCSRFAndRouters(t *testing.T, reg driver.Registry) (publi
 	reg.WithCSRFHandler(csrfHandler)
 	ran := negroni.New()
 	ran.UseFunc(x.RedirectAdminMiddleware)
+	ran.Use(x.NewAdminAPITokenAuthorizer(reg))
 	ran.UseHandler(ra)
 	rpn := negroni.New()
 	rpn.UseFunc(x.HTTPLoaderContextMiddleware(reg))
+	rpn.UseFunc(x.DebugTraceMiddleware(reg))
 	rpn.UseHandler(rp)
 	public = httptest.NewServer(x.NewTestCSRFHandler(rpn, reg))
 	admin = httptest.NewServer(ran)
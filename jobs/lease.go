@@ -0,0 +1,46 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Lease grants the replica identified by HolderID the right to run jobs until it expires. The
+// holder renews the lease periodically. If it stops doing so, another replica takes over once
+// the lease expired.
+type Lease struct {
+	ID        uuid.UUID `json:"id" faker:"-" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	HolderID  uuid.UUID `json:"holder_id" faker:"-" db:"holder_id"`
+	ExpiresAt time.Time `json:"expires_at" faker:"time_type" db:"expires_at"`
+
+	// CreatedAt is a helper struct field for gobuffalo.pop.
+	CreatedAt time.Time `json:"created_at" faker:"-" db:"created_at"`
+
+	// UpdatedAt is a helper struct field for gobuffalo.pop.
+	UpdatedAt time.Time `json:"updated_at" faker:"-" db:"updated_at"`
+
+	NID uuid.UUID `json:"-" faker:"-" db:"nid"`
+}
+
+func (Lease) TableName(context.Context) string {
+	return "job_leases"
+}
+
+type (
+	LeasePersister interface {
+		// AcquireJobLease acquires or renews the lease with the given name for the holder. It
+		// returns false if another holder has a lease which has not yet expired.
+		AcquireJobLease(ctx context.Context, name string, holder uuid.UUID, ttl time.Duration) (bool, error)
+		// ReleaseJobLease releases the lease with the given name if it is held by the holder.
+		ReleaseJobLease(ctx context.Context, name string, holder uuid.UUID) error
+	}
+	LeasePersistenceProvider interface {
+		JobLeasePersister() LeasePersister
+	}
+)
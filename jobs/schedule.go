@@ -0,0 +1,202 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package jobs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule determines when a job runs.
+type Schedule interface {
+	// Next returns the first time after t at which the job runs.
+	Next(t time.Time) time.Time
+}
+
+type (
+	everySchedule struct {
+		interval time.Duration
+	}
+
+	// cronSchedule is a standard five field cron expression. Each field is a bit set of the
+	// values it matches.
+	cronSchedule struct {
+		minute, hour, dom, month, dow uint64
+		// domStar and dowStar are set if the field was `*`. Like cron, a job runs if either the
+		// day of the month or the day of the week matches, unless one of them is `*`.
+		domStar, dowStar bool
+	}
+
+	cronField struct {
+		min, max int
+	}
+)
+
+var (
+	cronMinute = cronField{0, 59}
+	cronHour   = cronField{0, 23}
+	cronDom    = cronField{1, 31}
+	cronMonth  = cronField{1, 12}
+	cronDow    = cronField{0, 7}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+// ParseSchedule parses a cron expression with the fields minute, hour, day of month, month and
+// day of week, for example `*/15 * * * *`. The descriptors `@yearly`, `@monthly`, `@weekly`,
+// `@daily`, `@hourly` and `@every <duration>` are supported as well. Times are in UTC.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if d, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse schedule %q", spec)
+		}
+		if interval < time.Second {
+			return nil, errors.Errorf("unable to parse schedule %q: the interval must be at least one second", spec)
+		}
+		return &everySchedule{interval: interval}, nil
+	}
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("unable to parse schedule %q: expected five fields but got %d", spec, len(fields))
+	}
+
+	var (
+		s   cronSchedule
+		err error
+	)
+	for _, f := range []struct {
+		field cronField
+		expr  string
+		bits  *uint64
+	}{
+		{cronMinute, fields[0], &s.minute},
+		{cronHour, fields[1], &s.hour},
+		{cronDom, fields[2], &s.dom},
+		{cronMonth, fields[3], &s.month},
+		{cronDow, fields[4], &s.dow},
+	} {
+		if *f.bits, err = f.field.parse(f.expr); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse schedule %q", spec)
+		}
+	}
+	// Both 0 and 7 are Sunday.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+	return &s, nil
+}
+
+// parse parses a comma separated list of `*`, values, ranges and steps.
+func (f cronField) parse(expr string) (bits uint64, _ error) {
+	for _, part := range strings.Split(expr, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(to); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, errors.Errorf("the range %q is empty", rng)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			if hasStep {
+				hi = f.max
+			}
+		}
+
+		inc := 1
+		if hasStep {
+			var err error
+			if inc, err = strconv.Atoi(step); err != nil || inc < 1 {
+				return 0, errors.Errorf("the step %q is invalid", step)
+			}
+		}
+		for v := lo; v <= hi; v += inc {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Errorf("the value %q is not a number", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, errors.Errorf("the value %d is not between %d and %d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (s *everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval).Truncate(time.Second)
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+
+	// Give up if there is no match within five years, for example for `0 0 30 2 *`.
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
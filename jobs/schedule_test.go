@@ -0,0 +1,56 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package jobs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/jobs"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2024, 5, 15, 10, 7, 30, 0, time.UTC)
+
+	for _, tc := range []struct {
+		spec string
+		next time.Time
+	}{
+		{spec: "* * * * *", next: time.Date(2024, 5, 15, 10, 8, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", next: time.Date(2024, 5, 15, 10, 15, 0, 0, time.UTC)},
+		{spec: "5,50 * * * *", next: time.Date(2024, 5, 15, 10, 50, 0, 0, time.UTC)},
+		{spec: "0 9-17/4 * * *", next: time.Date(2024, 5, 15, 13, 0, 0, 0, time.UTC)},
+		{spec: "30 3 * * *", next: time.Date(2024, 5, 16, 3, 30, 0, 0, time.UTC)},
+		{spec: "0 0 1 * *", next: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * * 7", next: time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 25 * 1", next: time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", next: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "@hourly", next: time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{spec: "@weekly", next: time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{spec: "@every 90s", next: time.Date(2024, 5, 15, 10, 9, 0, 0, time.UTC)},
+	} {
+		t.Run("spec="+tc.spec, func(t *testing.T) {
+			s, err := jobs.ParseSchedule(tc.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tc.next, s.Next(now))
+		})
+	}
+
+	t.Run("case=never matches", func(t *testing.T) {
+		s, err := jobs.ParseSchedule("0 0 30 2 *")
+		require.NoError(t, err)
+		assert.True(t, s.Next(now).IsZero())
+	})
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 1ms", "@every soon"} {
+		t.Run("invalid="+spec, func(t *testing.T) {
+			_, err := jobs.ParseSchedule(spec)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package jobs
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+)
+
+// LeaderLeaseName is the name of the lease which the replica running the jobs holds.
+const LeaderLeaseName = "scheduler"
+
+type (
+	schedulerDependencies interface {
+		config.Provider
+		x.LoggingProvider
+		LeasePersistenceProvider
+	}
+
+	// Job is a task which runs periodically.
+	Job struct {
+		// Name identifies the job in the configuration and in logs.
+		Name string
+		// DefaultSchedule is used unless `jobs.schedules.<name>` is set.
+		DefaultSchedule string
+		Run             func(ctx context.Context) error
+	}
+
+	scheduledJob struct {
+		Job
+		schedule Schedule
+		next     time.Time
+		running  bool
+	}
+
+	// Scheduler runs the registered jobs on their schedules. If several replicas run the
+	// scheduler, only the replica holding the leader lease runs jobs.
+	Scheduler struct {
+		r      schedulerDependencies
+		holder uuid.UUID
+
+		mu         sync.Mutex
+		jobs       []*scheduledJob
+		leader     bool
+		leaseless  bool
+		renewAfter time.Time
+		wg         sync.WaitGroup
+	}
+	SchedulerProvider interface {
+		JobScheduler() *Scheduler
+	}
+)
+
+func NewScheduler(r schedulerDependencies) *Scheduler {
+	return &Scheduler{r: r, holder: x.NewUUID()}
+}
+
+// Register adds a job. The schedule is resolved when the scheduler starts, so jobs must be
+// registered before calling Start.
+func (s *Scheduler) Register(job Job) error {
+	if _, err := ParseSchedule(job.DefaultSchedule); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.Name == job.Name {
+			return errors.Errorf("a job named %q is already registered", job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, &scheduledJob{Job: job})
+	return nil
+}
+
+// Jobs returns the names of the registered jobs.
+func (s *Scheduler) Jobs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, len(s.jobs))
+	for i, j := range s.jobs {
+		names[i] = j.Name
+	}
+	return names
+}
+
+// IsLeader reports whether this replica holds the leader lease.
+func (s *Scheduler) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// Start runs the jobs until the context is canceled. The leader lease is released when the
+// scheduler stops so that another replica can take over right away.
+func (s *Scheduler) Start(ctx context.Context) error {
+	if err := s.resolveSchedules(ctx, time.Now()); err != nil {
+		return err
+	}
+	s.r.Logger().WithField("jobs", s.Jobs()).Info("Starting the job scheduler.")
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer s.stop(ctx)
+
+	s.tick(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			s.tick(ctx, now)
+		}
+	}
+}
+
+// resolveSchedules applies the configured schedules and computes when each job runs first.
+// Disabled jobs are removed.
+func (s *Scheduler) resolveSchedules(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	disabled := s.r.Config().JobsDisabled(ctx)
+	jobs := make([]*scheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		if slices.Contains(disabled, j.Name) {
+			continue
+		}
+
+		spec := s.r.Config().JobSchedule(ctx, j.Name)
+		if spec == "" {
+			spec = j.DefaultSchedule
+		}
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			return errors.WithMessagef(err, "the schedule of job %q is invalid", j.Name)
+		}
+		j.schedule = schedule
+		j.next = schedule.Next(now)
+		jobs = append(jobs, j)
+	}
+	s.jobs = jobs
+	return nil
+}
+
+// tick acquires or renews the leader lease if it is due and starts all jobs which are due. Jobs
+// which are due while another replica is the leader or while the previous run is still in
+// progress are skipped.
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !now.Before(s.renewAfter) {
+		s.renewLease(ctx, now)
+	}
+
+	for _, j := range s.jobs {
+		if j.next.IsZero() || now.Before(j.next) {
+			continue
+		}
+		j.next = j.schedule.Next(now)
+		if !s.leader || j.running {
+			continue
+		}
+
+		j.running = true
+		s.wg.Add(1)
+		go s.run(ctx, j)
+	}
+}
+
+func (s *Scheduler) renewLease(ctx context.Context, now time.Time) {
+	ttl := s.r.Config().JobsLeaseDuration(ctx)
+	s.renewAfter = now.Add(ttl / 3)
+
+	// An embedded instance is the only one using the database, so it does not need to write the
+	// lease, which would compete with other writers for the database lock.
+	if s.r.Config().DatabaseSQLiteEmbedded(ctx) {
+		if !s.leader {
+			s.r.Logger().WithField("holder_id", s.holder).Info("Running the jobs without a leader lease in embedded mode.")
+		}
+		s.leader = true
+		s.leaseless = true
+		return
+	}
+
+	leader, err := s.r.JobLeasePersister().AcquireJobLease(ctx, LeaderLeaseName, s.holder, ttl)
+	if err != nil {
+		// Stop running jobs if the lease can not be renewed, as another replica may take over
+		// once it expired.
+		s.r.Logger().WithError(err).Error("Unable to acquire the leader lease of the job scheduler.")
+		leader = false
+	}
+	if leader != s.leader {
+		s.r.Logger().WithField("holder_id", s.holder).WithField("leader", leader).Info("The leadership of the job scheduler changed.")
+	}
+	s.leader = leader
+}
+
+func (s *Scheduler) run(ctx context.Context, j *scheduledJob) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		j.running = false
+		s.mu.Unlock()
+	}()
+
+	start := time.Now()
+	logger := s.r.Logger().WithField("job", j.Name)
+	err := otelx.WithSpan(ctx, "jobs."+j.Name, func(ctx context.Context) error {
+		return j.Run(ctx)
+	})
+	logger = logger.WithField("duration", time.Since(start))
+	if err != nil {
+		logger.WithError(err).Error("The job failed.")
+		return
+	}
+	logger.Info("The job succeeded.")
+}
+
+func (s *Scheduler) stop(ctx context.Context) {
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.leader {
+		return
+	}
+	s.leader = false
+	if s.leaseless {
+		return
+	}
+	if err := s.r.JobLeasePersister().ReleaseJobLease(context.WithoutCancel(ctx), LeaderLeaseName, s.holder); err != nil {
+		s.r.Logger().WithError(err).Warn("Unable to release the leader lease of the job scheduler.")
+	}
+}
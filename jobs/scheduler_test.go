@@ -0,0 +1,115 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package jobs_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/jobs"
+	"github.com/ory/kratos/x"
+)
+
+func TestLeasePersister(t *testing.T) {
+	ctx := context.Background()
+	_, reg := internal.NewFastRegistryWithMocks(t)
+	p := reg.JobLeasePersister()
+	a, b := x.NewUUID(), x.NewUUID()
+
+	acquired, err := p.AcquireJobLease(ctx, "test", a, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = p.AcquireJobLease(ctx, "test", a, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "the holder can renew the lease")
+
+	acquired, err = p.AcquireJobLease(ctx, "test", b, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired, "another holder can not acquire the lease")
+
+	acquired, err = p.AcquireJobLease(ctx, "other", b, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "leases are independent")
+
+	require.NoError(t, p.ReleaseJobLease(ctx, "test", b), "releasing a lease of another holder is a no-op")
+	acquired, err = p.AcquireJobLease(ctx, "test", b, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired)
+
+	require.NoError(t, p.ReleaseJobLease(ctx, "test", a))
+	acquired, err = p.AcquireJobLease(ctx, "test", b, -time.Second)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = p.AcquireJobLease(ctx, "test", a, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired, "an expired lease can be taken over")
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeyJobsLeaseDuration, "3s")
+	conf.MustSet(ctx, config.ViperKeyJobsSchedules+".count", "@every 1s")
+	conf.MustSet(ctx, config.ViperKeyJobsDisabled, []string{"disabled"})
+
+	t.Run("case=builtin jobs are registered", func(t *testing.T) {
+		assert.Equal(t, []string{"cleanup_expired_flows", "prune_expired_sessions", "courier_retries", "archive_inactive_identities"}, reg.JobScheduler().Jobs())
+	})
+
+	t.Run("case=rejects invalid jobs", func(t *testing.T) {
+		s := jobs.NewScheduler(reg)
+		require.NoError(t, s.Register(jobs.Job{Name: "a", DefaultSchedule: "@daily"}))
+		assert.Error(t, s.Register(jobs.Job{Name: "a", DefaultSchedule: "@daily"}))
+		assert.Error(t, s.Register(jobs.Job{Name: "b", DefaultSchedule: "daily"}))
+	})
+
+	t.Run("case=only the leader runs jobs", func(t *testing.T) {
+		type replica struct {
+			s              *jobs.Scheduler
+			runs, disabled atomic.Int32
+			cancel         context.CancelFunc
+			done           chan error
+		}
+		start := func(t *testing.T) *replica {
+			r := &replica{s: jobs.NewScheduler(reg), done: make(chan error, 1)}
+			require.NoError(t, r.s.Register(jobs.Job{Name: "count", DefaultSchedule: "@daily", Run: func(context.Context) error {
+				r.runs.Add(1)
+				return nil
+			}}))
+			require.NoError(t, r.s.Register(jobs.Job{Name: "disabled", DefaultSchedule: "@every 1s", Run: func(context.Context) error {
+				r.disabled.Add(1)
+				return nil
+			}}))
+
+			var ctx context.Context
+			ctx, r.cancel = context.WithCancel(context.Background())
+			go func() { r.done <- r.s.Start(ctx) }()
+			t.Cleanup(r.cancel)
+			return r
+		}
+
+		first := start(t)
+		require.Eventually(t, first.s.IsLeader, time.Second, 10*time.Millisecond)
+		second := start(t)
+
+		require.Eventually(t, func() bool { return first.runs.Load() >= 2 }, 5*time.Second, 50*time.Millisecond)
+		assert.False(t, second.s.IsLeader())
+		assert.Zero(t, second.runs.Load())
+		assert.Zero(t, first.disabled.Load())
+
+		first.cancel()
+		require.NoError(t, <-first.done)
+		require.Eventually(t, func() bool { return second.runs.Load() >= 1 }, 5*time.Second, 50*time.Millisecond, "the other replica takes over once the lease is released")
+		assert.True(t, second.s.IsLeader())
+	})
+}
@@ -0,0 +1,97 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package persistence
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/cmdx"
+	"github.com/ory/x/popx"
+)
+
+// MigrationPhase allows applying schema changes while the previous and the current release run
+// side by side.
+//
+// Expand migrations only add to the schema, for example new tables, nullable columns or indices,
+// and are compatible with the previous release. Contract migrations remove or change parts of the
+// schema the previous release depends on and must only be applied once it no longer runs.
+//
+// A migration belongs to the contract phase if any of its up files contains the line
+//
+//	-- kratos:phase contract
+//
+// All other migrations belong to the expand phase. The expand phase skips pending contract
+// migrations, so expand migrations must not depend on contract migrations with lower versions.
+type MigrationPhase string
+
+const (
+	MigrationPhaseExpand   MigrationPhase = "expand"
+	MigrationPhaseContract MigrationPhase = "contract"
+
+	// MigrationPhaseContractMarker marks a migration as belonging to the contract phase.
+	MigrationPhaseContractMarker = "-- kratos:phase contract"
+)
+
+// ParseMigrationPhase parses the phase, which is either expand or contract.
+func ParseMigrationPhase(phase string) (MigrationPhase, error) {
+	switch p := MigrationPhase(phase); p {
+	case MigrationPhaseExpand, MigrationPhaseContract:
+		return p, nil
+	}
+	return "", errors.Errorf(`unknown migration phase %q, expected "expand" or "contract"`, phase)
+}
+
+// MigrationPhaseOf returns the phase of a migration based on the content of its up file.
+func MigrationPhaseOf(content string) MigrationPhase {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == MigrationPhaseContractMarker {
+			return MigrationPhaseContract
+		}
+	}
+	return MigrationPhaseExpand
+}
+
+type (
+	// MigrationPhaseStatus is the status of a migration and the phase it belongs to.
+	MigrationPhaseStatus struct {
+		popx.MigrationStatus
+		Phase MigrationPhase `json:"phase"`
+	}
+
+	MigrationPhaseStatuses []MigrationPhaseStatus
+)
+
+var _ cmdx.Table = (MigrationPhaseStatuses)(nil)
+
+// HasPending returns true if migrations of the phase have not been applied yet.
+func (m MigrationPhaseStatuses) HasPending(phase MigrationPhase) bool {
+	for _, s := range m {
+		if s.State == popx.Pending && s.Phase == phase {
+			return true
+		}
+	}
+	return false
+}
+
+func (m MigrationPhaseStatuses) Header() []string {
+	return []string{"Version", "Name", "Phase", "Status"}
+}
+
+func (m MigrationPhaseStatuses) Table() [][]string {
+	t := make([][]string, len(m))
+	for i, s := range m {
+		t[i] = []string{s.Version, s.Name, string(s.Phase), s.State}
+	}
+	return t
+}
+
+func (m MigrationPhaseStatuses) Interface() interface{} {
+	return m
+}
+
+func (m MigrationPhaseStatuses) Len() int {
+	return len(m)
+}
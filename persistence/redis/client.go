@@ -0,0 +1,240 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+	maxIdleConns   = 16
+
+	// maxBulkLength and maxArrayLength bound the memory allocated for a reply, so that a
+	// misbehaving server can not make the client allocate arbitrary amounts of memory. The
+	// persister only stores flows and never reads arrays.
+	maxBulkLength  = 16 << 20
+	maxArrayLength = 1024
+)
+
+type (
+	// client is a minimal client for the Redis serialization protocol (RESP). It supports the
+	// few commands the persister needs and keeps a small pool of idle connections.
+	client struct {
+		addr     string
+		username string
+		password string
+		db       int
+		tls      *tls.Config
+		idle     chan *conn
+	}
+	conn struct {
+		net.Conn
+		r *bufio.Reader
+		w *bufio.Writer
+	}
+
+	// Error is a reply of the Redis server which indicates an error.
+	Error string
+)
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// newClient creates a client for URLs of the form `redis://[[user]:password@]host[:port][/db]`.
+// Use `rediss://` to connect using TLS.
+func newClient(u *url.URL) (*client, error) {
+	c := &client{idle: make(chan *conn, maxIdleConns)}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, errors.Errorf("unsupported redis URL scheme %q, use redis or rediss", u.Scheme)
+	}
+
+	c.addr = u.Host
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+		if _, ok := u.User.Password(); !ok {
+			// `redis://password@host` is a common shorthand for the password only.
+			c.username, c.password = "", u.User.Username()
+		}
+	}
+
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil {
+			return nil, errors.Errorf("invalid redis database %q", db)
+		}
+		c.db = n
+	}
+
+	return c, nil
+}
+
+func (c *client) dial(ctx context.Context) (*conn, error) {
+	d := &net.Dialer{Timeout: defaultTimeout}
+	var (
+		nc  net.Conn
+		err error
+	)
+	if c.tls != nil {
+		nc, err = (&tls.Dialer{NetDialer: d, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		nc, err = d.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.username != "" {
+			args = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := cn.do(ctx, args...); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do(ctx, "SELECT", strconv.Itoa(c.db)); err != nil {
+			_ = nc.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// do sends the command and returns the reply. Replies are a string, an int64, nil, or a slice
+// of replies. Errors replied by the server are returned as Error.
+func (c *client) do(ctx context.Context, args ...string) (any, error) {
+	var cn *conn
+	select {
+	case cn = <-c.idle:
+	default:
+		var err error
+		if cn, err = c.dial(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := cn.do(ctx, args...)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state.
+		_ = cn.Close()
+		return nil, err
+	}
+
+	select {
+	case c.idle <- cn:
+	default:
+		_ = cn.Close()
+	}
+	return reply, err
+}
+
+func (c *client) close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			_ = cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (cn *conn) do(ctx context.Context, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	_, _ = fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		_, _ = fmt.Fprintf(cn.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return readReply(cn.r)
+}
+
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: received an empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, errors.WithStack(Error(line[1:]))
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		return n, errors.WithStack(err)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		} else if n < 0 {
+			return nil, nil
+		} else if n > maxBulkLength {
+			return nil, errors.Errorf("redis: received a bulk string of %d bytes which exceeds the limit of %d bytes", n, maxBulkLength)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		} else if n < 0 {
+			return nil, nil
+		} else if n > maxArrayLength {
+			return nil, errors.Errorf("redis: received an array of %d elements which exceeds the limit of %d elements", n, maxArrayLength)
+		}
+		items := make([]any, n)
+		for k := range items {
+			if items[k], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, errors.Errorf("redis: received an unknown reply %q", line)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/persistence"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/sqlcon"
+)
+
+const (
+	kindLoginFlow        = "login_flow"
+	kindRegistrationFlow = "registration_flow"
+	kindRecoveryFlow     = "recovery_flow"
+	kindContinuity       = "continuity_container"
+
+	// expiredRetention is how long expired flows and continuity containers are kept, so that
+	// users get a "flow expired" error instead of a "flow not found" error.
+	expiredRetention = time.Hour
+)
+
+type (
+	persisterDependencies interface {
+		x.TracingProvider
+	}
+
+	// Persister stores short-lived login, registration, and recovery flows and continuity
+	// containers in Redis, where they are evicted once they expired. Everything else is stored
+	// by the wrapped SQL persister.
+	//
+	// Flows which were stored in SQL before Redis was enabled are still found. Flows which are
+	// referenced by codes or tokens are moved to SQL before the code or token is created.
+	Persister struct {
+		persistence.Persister
+		r persisterDependencies
+		c *client
+	}
+)
+
+var _ persistence.Persister = new(Persister)
+
+// NewPersister wraps the SQL persister and stores flows in the Redis server at the given URL.
+func NewPersister(ctx context.Context, r persisterDependencies, p persistence.Persister, u *url.URL) (*Persister, error) {
+	c, err := newClient(u)
+	if err != nil {
+		return nil, err
+	}
+
+	rp := &Persister{Persister: p, r: r, c: c}
+	if err := rp.pingRedis(ctx); err != nil {
+		return nil, err
+	}
+	return rp, nil
+}
+
+func (p *Persister) WithNetworkID(nid uuid.UUID) persistence.Persister {
+	return &Persister{Persister: p.Persister.WithNetworkID(nid), r: p.r, c: p.c}
+}
+
+func (p *Persister) Ping(ctx context.Context) error {
+	if err := p.pingRedis(ctx); err != nil {
+		return err
+	}
+	return p.Persister.Ping(ctx)
+}
+
+func (p *Persister) Close(ctx context.Context) error {
+	_ = p.c.close()
+	return p.Persister.Close(ctx)
+}
+
+func (p *Persister) pingRedis(ctx context.Context) error {
+	_, err := p.c.do(ctx, "PING")
+	return err
+}
+
+func (p *Persister) key(ctx context.Context, kind string, id uuid.UUID) string {
+	return fmt.Sprintf("kratos:%s:%s:%s", p.NetworkID(ctx), kind, id)
+}
+
+// ttl returns how long a model is kept in Redis. Models which are expired for longer than
+// expiredRetention are not stored at all.
+func ttl(expiresAt time.Time) (string, bool) {
+	d := time.Until(expiresAt.Add(expiredRetention))
+	if d < time.Millisecond {
+		return "", false
+	}
+	return strconv.FormatInt(d.Milliseconds(), 10), true
+}
+
+// create stores a new model. It fails with sqlcon.ErrUniqueViolation if the ID is taken.
+func (p *Persister) create(ctx context.Context, kind string, id uuid.UUID, expiresAt time.Time, v any) error {
+	touch(v)
+	px, ok := ttl(expiresAt)
+	if !ok {
+		return afterSave(v)
+	}
+
+	data, err := encode(v)
+	if err != nil {
+		return err
+	}
+
+	reply, err := p.c.do(ctx, "SET", p.key(ctx, kind, id), string(data), "PX", px, "NX")
+	if err != nil {
+		return err
+	} else if reply == nil {
+		return errors.WithStack(sqlcon.ErrUniqueViolation)
+	}
+	return afterSave(v)
+}
+
+// update replaces a stored model. It returns false if the model is not stored in Redis.
+func (p *Persister) update(ctx context.Context, kind string, id uuid.UUID, expiresAt time.Time, v any) (bool, error) {
+	touch(v)
+	px, ok := ttl(expiresAt)
+	if !ok {
+		found, err := p.remove(ctx, kind, id)
+		if err != nil || !found {
+			return false, err
+		}
+		return true, afterSave(v)
+	}
+
+	data, err := encode(v)
+	if err != nil {
+		return false, err
+	}
+
+	reply, err := p.c.do(ctx, "SET", p.key(ctx, kind, id), string(data), "PX", px, "XX")
+	if err != nil || reply == nil {
+		return false, err
+	}
+	return true, afterSave(v)
+}
+
+// find loads a model. It returns false if the model is not stored in Redis.
+func (p *Persister) find(ctx context.Context, kind string, id uuid.UUID, v any) (bool, error) {
+	reply, err := p.c.do(ctx, "GET", p.key(ctx, kind, id))
+	if err != nil || reply == nil {
+		return false, err
+	}
+
+	data, ok := reply.(string)
+	if !ok {
+		return false, errors.Errorf("redis: expected a string reply but got %T", reply)
+	}
+	return true, decode([]byte(data), v)
+}
+
+// remove deletes a model. It returns false if the model is not stored in Redis.
+func (p *Persister) remove(ctx context.Context, kind string, id uuid.UUID) (bool, error) {
+	reply, err := p.c.do(ctx, "DEL", p.key(ctx, kind, id))
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n > 0, nil
+}
+
+// moveToSQL moves a model from Redis to SQL, if it is stored in Redis. Models are moved before
+// SQL rows which reference them are created.
+func moveToSQL[T any](ctx context.Context, p *Persister, kind string, id uuid.UUID, create func(context.Context, *T) error) error {
+	var v T
+	if found, err := p.find(ctx, kind, id, &v); err != nil || !found {
+		return err
+	}
+	if err := create(ctx, &v); err != nil {
+		return err
+	}
+	_, err := p.remove(ctx, kind, id)
+	return err
+}
@@ -0,0 +1,72 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/continuity"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+)
+
+var _ continuity.Persister = new(Persister)
+
+func (p *Persister) SaveContinuitySession(ctx context.Context, c *continuity.Container) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.SaveContinuitySession")
+	defer otelx.End(span, &err)
+
+	if c.ID == uuid.Nil {
+		c.ID = uuid.Must(uuid.NewV4())
+	}
+	c.NID = p.NetworkID(ctx)
+	return p.create(ctx, kindContinuity, c.ID, c.ExpiresAt, c)
+}
+
+func (p *Persister) SetContinuitySessionExpiry(ctx context.Context, id uuid.UUID, expiresAt time.Time) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.SetContinuitySessionExpiry")
+	defer otelx.End(span, &err)
+
+	var c continuity.Container
+	if found, err := p.find(ctx, kindContinuity, id, &c); err != nil {
+		return err
+	} else if !found {
+		return p.Persister.SetContinuitySessionExpiry(ctx, id, expiresAt)
+	}
+
+	c.ExpiresAt = expiresAt
+	if found, err := p.update(ctx, kindContinuity, id, expiresAt, &c); err != nil {
+		return err
+	} else if !found {
+		return errors.WithStack(sqlcon.ErrNoRows)
+	}
+	return nil
+}
+
+func (p *Persister) GetContinuitySession(ctx context.Context, id uuid.UUID) (_ *continuity.Container, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.GetContinuitySession")
+	defer otelx.End(span, &err)
+
+	var c continuity.Container
+	if found, err := p.find(ctx, kindContinuity, id, &c); err != nil {
+		return nil, err
+	} else if found {
+		return &c, nil
+	}
+	return p.Persister.GetContinuitySession(ctx, id)
+}
+
+func (p *Persister) DeleteContinuitySession(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.DeleteContinuitySession")
+	defer otelx.End(span, &err)
+
+	if found, err := p.remove(ctx, kindContinuity, id); err != nil || found {
+		return err
+	}
+	return p.Persister.DeleteContinuitySession(ctx, id)
+}
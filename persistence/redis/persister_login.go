@@ -0,0 +1,87 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+)
+
+var _ login.FlowPersister = new(Persister)
+
+func (p *Persister) CreateLoginFlow(ctx context.Context, r *login.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.CreateLoginFlow")
+	defer otelx.End(span, &err)
+
+	if r.ID == uuid.Nil {
+		r.ID = uuid.Must(uuid.NewV4())
+	}
+	r.NID = p.NetworkID(ctx)
+	r.EnsureInternalContext()
+	return p.create(ctx, kindLoginFlow, r.ID, r.ExpiresAt, r)
+}
+
+func (p *Persister) UpdateLoginFlow(ctx context.Context, r *login.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.UpdateLoginFlow")
+	defer otelx.End(span, &err)
+
+	r.EnsureInternalContext()
+	cp := *r
+	cp.NID = p.NetworkID(ctx)
+	if found, err := p.update(ctx, kindLoginFlow, cp.ID, cp.ExpiresAt, &cp); err != nil || found {
+		return err
+	}
+	return p.Persister.UpdateLoginFlow(ctx, r)
+}
+
+func (p *Persister) GetLoginFlow(ctx context.Context, id uuid.UUID) (_ *login.Flow, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.GetLoginFlow")
+	defer otelx.End(span, &err)
+
+	var r login.Flow
+	if found, err := p.find(ctx, kindLoginFlow, id, &r); err != nil {
+		return nil, err
+	} else if found {
+		return &r, nil
+	}
+	return p.Persister.GetLoginFlow(ctx, id)
+}
+
+func (p *Persister) ForceLoginFlow(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.ForceLoginFlow")
+	defer otelx.End(span, &err)
+
+	var r login.Flow
+	if found, err := p.find(ctx, kindLoginFlow, id, &r); err != nil {
+		return err
+	} else if !found {
+		return p.Persister.ForceLoginFlow(ctx, id)
+	}
+
+	r.Refresh = true
+	if found, err := p.update(ctx, kindLoginFlow, id, r.ExpiresAt, &r); err != nil {
+		return err
+	} else if !found {
+		return errors.WithStack(sqlcon.ErrNoRows)
+	}
+	return nil
+}
+
+// CreateLoginCode moves the flow to SQL first, as login codes reference their flow.
+func (p *Persister) CreateLoginCode(ctx context.Context, params *code.CreateLoginCodeParams) (_ *code.LoginCode, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.CreateLoginCode")
+	defer otelx.End(span, &err)
+
+	if err := moveToSQL(ctx, p, kindLoginFlow, params.FlowID, p.Persister.CreateLoginFlow); err != nil {
+		return nil, err
+	}
+	return p.Persister.CreateLoginCode(ctx, params)
+}
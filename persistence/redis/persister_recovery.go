@@ -0,0 +1,77 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/ory/kratos/selfservice/flow/recovery"
+	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/kratos/selfservice/strategy/link"
+	"github.com/ory/x/otelx"
+)
+
+var _ recovery.FlowPersister = new(Persister)
+
+func (p *Persister) CreateRecoveryFlow(ctx context.Context, r *recovery.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.CreateRecoveryFlow")
+	defer otelx.End(span, &err)
+
+	if r.ID == uuid.Nil {
+		r.ID = uuid.Must(uuid.NewV4())
+	}
+	r.NID = p.NetworkID(ctx)
+	return p.create(ctx, kindRecoveryFlow, r.ID, r.ExpiresAt, r)
+}
+
+func (p *Persister) UpdateRecoveryFlow(ctx context.Context, r *recovery.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.UpdateRecoveryFlow")
+	defer otelx.End(span, &err)
+
+	cp := *r
+	cp.NID = p.NetworkID(ctx)
+	if found, err := p.update(ctx, kindRecoveryFlow, cp.ID, cp.ExpiresAt, &cp); err != nil || found {
+		return err
+	}
+	return p.Persister.UpdateRecoveryFlow(ctx, r)
+}
+
+func (p *Persister) GetRecoveryFlow(ctx context.Context, id uuid.UUID) (_ *recovery.Flow, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.GetRecoveryFlow")
+	defer otelx.End(span, &err)
+
+	var r recovery.Flow
+	if found, err := p.find(ctx, kindRecoveryFlow, id, &r); err != nil {
+		return nil, err
+	} else if found {
+		return &r, nil
+	}
+	return p.Persister.GetRecoveryFlow(ctx, id)
+}
+
+// CreateRecoveryCode moves the flow to SQL first, as recovery codes reference their flow.
+func (p *Persister) CreateRecoveryCode(ctx context.Context, params *code.CreateRecoveryCodeParams) (_ *code.RecoveryCode, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.CreateRecoveryCode")
+	defer otelx.End(span, &err)
+
+	if err := moveToSQL(ctx, p, kindRecoveryFlow, params.FlowID, p.Persister.CreateRecoveryFlow); err != nil {
+		return nil, err
+	}
+	return p.Persister.CreateRecoveryCode(ctx, params)
+}
+
+// CreateRecoveryToken moves the flow to SQL first, as recovery tokens reference their flow.
+func (p *Persister) CreateRecoveryToken(ctx context.Context, token *link.RecoveryToken) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.CreateRecoveryToken")
+	defer otelx.End(span, &err)
+
+	if token.FlowID.Valid {
+		if err := moveToSQL(ctx, p, kindRecoveryFlow, token.FlowID.UUID, p.Persister.CreateRecoveryFlow); err != nil {
+			return err
+		}
+	}
+	return p.Persister.CreateRecoveryToken(ctx, token)
+}
@@ -0,0 +1,65 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/x/otelx"
+)
+
+var _ registration.FlowPersister = new(Persister)
+
+func (p *Persister) CreateRegistrationFlow(ctx context.Context, r *registration.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.CreateRegistrationFlow")
+	defer otelx.End(span, &err)
+
+	if r.ID == uuid.Nil {
+		r.ID = uuid.Must(uuid.NewV4())
+	}
+	r.NID = p.NetworkID(ctx)
+	r.EnsureInternalContext()
+	return p.create(ctx, kindRegistrationFlow, r.ID, r.ExpiresAt, r)
+}
+
+func (p *Persister) UpdateRegistrationFlow(ctx context.Context, r *registration.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.UpdateRegistrationFlow")
+	defer otelx.End(span, &err)
+
+	r.EnsureInternalContext()
+	cp := *r
+	cp.NID = p.NetworkID(ctx)
+	if found, err := p.update(ctx, kindRegistrationFlow, cp.ID, cp.ExpiresAt, &cp); err != nil || found {
+		return err
+	}
+	return p.Persister.UpdateRegistrationFlow(ctx, r)
+}
+
+func (p *Persister) GetRegistrationFlow(ctx context.Context, id uuid.UUID) (_ *registration.Flow, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.GetRegistrationFlow")
+	defer otelx.End(span, &err)
+
+	var r registration.Flow
+	if found, err := p.find(ctx, kindRegistrationFlow, id, &r); err != nil {
+		return nil, err
+	} else if found {
+		return &r, nil
+	}
+	return p.Persister.GetRegistrationFlow(ctx, id)
+}
+
+// CreateRegistrationCode moves the flow to SQL first, as registration codes reference their flow.
+func (p *Persister) CreateRegistrationCode(ctx context.Context, params *code.CreateRegistrationCodeParams) (_ *code.RegistrationCode, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.redis.CreateRegistrationCode")
+	defer otelx.End(span, &err)
+
+	if err := moveToSQL(ctx, p, kindRegistrationFlow, params.FlowID, p.Persister.CreateRegistrationFlow); err != nil {
+		return nil, err
+	}
+	return p.Persister.CreateRegistrationCode(ctx, params)
+}
@@ -0,0 +1,286 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package redis_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	continuity "github.com/ory/kratos/continuity/test"
+	"github.com/ory/kratos/corpx"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/persistence/redis"
+	lf "github.com/ory/kratos/selfservice/flow/login"
+	login "github.com/ory/kratos/selfservice/flow/login/test"
+	recovery "github.com/ory/kratos/selfservice/flow/recovery/test"
+	registration "github.com/ory/kratos/selfservice/flow/registration/test"
+	lc "github.com/ory/kratos/selfservice/strategy/code"
+	code "github.com/ory/kratos/selfservice/strategy/code/test"
+	link "github.com/ory/kratos/selfservice/strategy/link/test"
+	"github.com/ory/x/sqlcon"
+)
+
+func init() {
+	corpx.RegisterFakes()
+}
+
+// server is an in-memory Redis server which supports the commands used by the persister.
+type server struct {
+	sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	l       net.Listener
+}
+
+func newServer(t *testing.T) *server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &server{values: map[string]string{}, expires: map[string]time.Time{}, l: l}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *server) url() *url.URL {
+	return &url.URL{Scheme: "redis", Host: s.l.Addr().String()}
+}
+
+func (s *server) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(c, s.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for k := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[k] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func (s *server) get(key string) (string, bool) {
+	if exp, ok := s.expires[key]; ok && time.Now().After(exp) {
+		delete(s.values, key)
+		delete(s.expires, key)
+	}
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *server) exec(args []string) string {
+	s.Lock()
+	defer s.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		v, ok := s.get(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "SET":
+		key := args[1]
+		_, exists := s.get(key)
+		var ttl time.Duration
+		for k := 3; k < len(args); k++ {
+			switch strings.ToUpper(args[k]) {
+			case "NX":
+				if exists {
+					return "$-1\r\n"
+				}
+			case "XX":
+				if !exists {
+					return "$-1\r\n"
+				}
+			case "PX":
+				ms, _ := strconv.Atoi(args[k+1])
+				ttl = time.Duration(ms) * time.Millisecond
+				k++
+			}
+		}
+		s.values[key] = args[2]
+		delete(s.expires, key)
+		if ttl > 0 {
+			s.expires[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		if _, ok := s.get(args[1]); !ok {
+			return ":0\r\n"
+		}
+		delete(s.values, args[1])
+		delete(s.expires, args[1])
+		return ":1\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func (s *server) ttl(id fmt.Stringer) time.Duration {
+	s.Lock()
+	defer s.Unlock()
+	for key, exp := range s.expires {
+		if strings.HasSuffix(key, id.String()) {
+			return time.Until(exp)
+		}
+	}
+	return 0
+}
+
+func TestPersister(t *testing.T) {
+	ctx := testhelpers.WithDefaultIdentitySchema(context.Background(), "file://./stub/identity.schema.json")
+	_, reg := internal.NewFastRegistryWithMocks(t)
+	srv := newServer(t)
+
+	rp, err := redis.NewPersister(ctx, reg, reg.Persister(), srv.url())
+	require.NoError(t, err)
+	_, p := testhelpers.NewNetwork(t, ctx, rp)
+
+	t.Run("contract=login.TestFlowPersister", func(t *testing.T) {
+		login.TestFlowPersister(ctx, p)(t)
+	})
+	t.Run("contract=registration.TestFlowPersister", func(t *testing.T) {
+		registration.TestFlowPersister(ctx, p)(t)
+	})
+	t.Run("contract=recovery.TestFlowPersister", func(t *testing.T) {
+		recovery.TestFlowPersister(ctx, p)(t)
+	})
+	t.Run("contract=continuity.TestPersister", func(t *testing.T) {
+		continuity.TestPersister(ctx, p)(t)
+	})
+	t.Run("contract=code.TestPersister", func(t *testing.T) {
+		code.TestPersister(ctx, p)(t)
+	})
+	t.Run("contract=link.TestPersister", func(t *testing.T) {
+		link.TestPersister(ctx, p)(t)
+	})
+
+	sqlPersister := p.(*redis.Persister).Persister
+
+	t.Run("case=stores flows in redis with a TTL", func(t *testing.T) {
+		f := &lf.Flow{ExpiresAt: time.Now().Add(time.Minute), RequestURL: "https://www.ory.sh/?return_to=https://www.ory.sh/welcome", CSRFToken: "token"}
+		require.NoError(t, p.CreateLoginFlow(ctx, f))
+
+		_, err := sqlPersister.GetLoginFlow(ctx, f.ID)
+		require.ErrorIs(t, err, sqlcon.ErrNoRows)
+
+		actual, err := p.GetLoginFlow(ctx, f.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "token", actual.CSRFToken)
+		assert.Equal(t, "https://www.ory.sh/welcome", actual.ReturnTo)
+		assert.InDelta(t, (time.Minute + time.Hour).Seconds(), srv.ttl(f.ID).Seconds(), 5)
+	})
+
+	t.Run("case=finds and updates flows stored in SQL", func(t *testing.T) {
+		f := &lf.Flow{ExpiresAt: time.Now().Add(time.Minute), RequestURL: "https://www.ory.sh/"}
+		require.NoError(t, sqlPersister.CreateLoginFlow(ctx, f))
+
+		f.CSRFToken = "updated"
+		require.NoError(t, p.UpdateLoginFlow(ctx, f))
+
+		actual, err := sqlPersister.GetLoginFlow(ctx, f.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "updated", actual.CSRFToken)
+		assert.Zero(t, srv.ttl(f.ID))
+	})
+
+	t.Run("case=moves the flow to SQL when a code references it", func(t *testing.T) {
+		f := &lf.Flow{ExpiresAt: time.Now().Add(time.Minute), RequestURL: "https://www.ory.sh/"}
+		require.NoError(t, p.CreateLoginFlow(ctx, f))
+
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, p.CreateIdentity(ctx, i))
+		_, err := p.CreateLoginCode(ctx, &lc.CreateLoginCodeParams{
+			Address:     "foo@ory.sh",
+			AddressType: identity.CodeChannelEmail,
+			RawCode:     "123456",
+			ExpiresIn:   time.Minute,
+			FlowID:      f.ID,
+			IdentityID:  i.ID,
+		})
+		require.NoError(t, err)
+
+		_, err = sqlPersister.GetLoginFlow(ctx, f.ID)
+		require.NoError(t, err)
+		assert.Zero(t, srv.ttl(f.ID))
+	})
+}
+
+func TestPersisterRejectsOversizedReplies(t *testing.T) {
+	ctx := context.Background()
+	_, reg := internal.NewFastRegistryWithMocks(t)
+
+	for _, reply := range []string{"$1099511627776\r\n", "*1099511627776\r\n"} {
+		t.Run("reply="+strings.TrimSpace(reply), func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = l.Close() })
+			go func() {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer c.Close()
+				if _, err := readCommand(bufio.NewReader(c)); err != nil {
+					return
+				}
+				_, _ = io.WriteString(c, reply)
+			}()
+
+			_, err = redis.NewPersister(ctx, reg, reg.Persister(), &url.URL{Scheme: "redis", Host: l.Addr().String()})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "exceeds the limit")
+		})
+	}
+}
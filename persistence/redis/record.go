@@ -0,0 +1,95 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+)
+
+// columns returns the fields of the struct v points to that are stored in a SQL column, keyed by
+// the column name.
+func columns(v any) map[string]reflect.Value {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	fields := make(map[string]reflect.Value, rt.NumField())
+	for k := range rt.NumField() {
+		f := rt.Field(k)
+		name, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = rv.Field(k)
+	}
+	return fields
+}
+
+// encode serializes the columns of the model. Fields which are not stored in SQL are left out, so
+// that a model read from Redis looks the same as one read from SQL.
+func encode(v any) ([]byte, error) {
+	// The columns are encoded as strings, and marshalers are called directly, as encoding/json
+	// would otherwise compact JSON columns.
+	record := map[string]string{}
+	for name, f := range columns(v) {
+		var (
+			raw []byte
+			err error
+		)
+		if m, ok := f.Addr().Interface().(json.Marshaler); ok {
+			raw, err = m.MarshalJSON()
+		} else {
+			raw, err = json.Marshal(f.Addr().Interface())
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		record[name] = string(raw)
+	}
+	out, err := json.Marshal(record)
+	return out, errors.WithStack(err)
+}
+
+// decode is the inverse of encode. Like pop, it runs the model's AfterFind callback.
+func decode(data []byte, v any) error {
+	var record map[string]string
+	if err := json.Unmarshal(data, &record); err != nil {
+		return errors.WithStack(err)
+	}
+	for name, f := range columns(v) {
+		if raw, ok := record[name]; ok {
+			if err := json.Unmarshal([]byte(raw), f.Addr().Interface()); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	if cb, ok := v.(interface{ AfterFind(*pop.Connection) error }); ok {
+		return cb.AfterFind(nil)
+	}
+	return nil
+}
+
+// touch sets the timestamps of the model the way pop does when saving it.
+func touch(v any) {
+	now := reflect.ValueOf(time.Now().UTC().Truncate(time.Microsecond))
+	fields := columns(v)
+	if f, ok := fields["created_at"]; ok && f.Interface().(time.Time).IsZero() {
+		f.Set(now)
+	}
+	if f, ok := fields["updated_at"]; ok {
+		f.Set(now)
+	}
+}
+
+// afterSave runs the model's AfterSave callback like pop does.
+func afterSave(v any) error {
+	if cb, ok := v.(interface{ AfterSave(*pop.Connection) error }); ok {
+		return cb.AfterSave(nil)
+	}
+	return nil
+}
@@ -21,12 +21,18 @@ import (
 	"github.com/ory/kratos/continuity"
 	"github.com/ory/kratos/courier"
 	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/jobs"
 	"github.com/ory/kratos/selfservice/errorx"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
+	"github.com/ory/kratos/selfservice/flow/funnel"
+	"github.com/ory/kratos/selfservice/flow/inspect"
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/selfservice/flow/recovery"
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/flow/settings"
 	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
+	"github.com/ory/kratos/selfservice/sso"
 	"github.com/ory/kratos/selfservice/strategy/code"
 	"github.com/ory/kratos/selfservice/strategy/link"
 	"github.com/ory/kratos/session"
@@ -40,8 +46,17 @@ type Provider interface {
 type Persister interface {
 	continuity.Persister
 	identity.PrivilegedPool
+	identity.WebhookPersister
+	identity.CredentialsUsagePersister
 	registration.FlowPersister
 	login.FlowPersister
+	crossdevice.FlowPersister
+	funnel.Persister
+	inspect.Persister
+	sso.Persister
+	deadletter.Persister
+	x.IssuedAdminAPITokenPersister
+	jobs.LeasePersister
 	settings.FlowPersister
 	courier.Persister
 	session.Persister
@@ -57,11 +72,16 @@ type Persister interface {
 	code.LoginCodePersister
 
 	CleanupDatabase(context.Context, time.Duration, time.Duration, int) error
+	CollectGarbage(ctx context.Context, tables ...string) error
 	Close(context.Context) error
 	Ping(context.Context) error
+	// ReplicationLag returns how far the database lags behind its primary.
+	ReplicationLag(context.Context) (time.Duration, error)
 	MigrationStatus(context.Context) (popx.MigrationStatuses, error)
 	MigrateDown(ctx context.Context, steps int) error
 	MigrateUp(context.Context) error
+	MigrationPhaseStatus(context.Context) (MigrationPhaseStatuses, error)
+	MigrateUpPhase(context.Context, MigrationPhase) error
 	Migrator() *popx.Migrator
 	MigrationBox() *popx.MigrationBox
 	GetConnection(context.Context) *pop.Connection
@@ -0,0 +1,241 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/sqlcon"
+)
+
+// QueryPlan is the execution plan of a frequently executed query.
+type QueryPlan struct {
+	// Name identifies the query.
+	Name string `json:"name"`
+	// Query is the analyzed SQL statement.
+	Query string `json:"query"`
+	// Plan contains one line per step of the execution plan, as reported by the database.
+	Plan []string `json:"plan"`
+	// SlowCandidate is true if the plan scans a whole table or sorts without an index.
+	SlowCandidate bool `json:"slow_candidate"`
+	// Reason explains why the query is a slow candidate.
+	Reason string `json:"reason,omitempty"`
+}
+
+type hotQuery struct {
+	name  string
+	query string
+	args  func(nid uuid.UUID) []any
+}
+
+func byID(nid uuid.UUID) []any { return []any{uuid.Nil, nid} }
+
+// hotQueries are the queries executed on every session check, login and message delivery.
+var hotQueries = []hotQuery{
+	{
+		name:  "session_by_token",
+		query: "SELECT * FROM sessions WHERE token = ? AND nid = ?",
+		args:  func(nid uuid.UUID) []any { return []any{"token", nid} },
+	},
+	{
+		name:  "sessions_by_identity",
+		query: "SELECT * FROM sessions WHERE identity_id = ? AND nid = ? ORDER BY created_at DESC LIMIT 250",
+		args:  byID,
+	},
+	{
+		name: "identity_by_identifier",
+		query: `SELECT ic.identity_id FROM identity_credentials ic
+INNER JOIN identity_credential_types ict ON ic.identity_credential_type_id = ict.id
+INNER JOIN identity_credential_identifiers ici ON ic.id = ici.identity_credential_id AND ici.identity_credential_type_id = ict.id
+WHERE ici.identifier IN (?, ?, ?) AND ic.nid = ? AND ici.nid = ? AND ict.name = ? LIMIT 1`,
+		args: func(nid uuid.UUID) []any {
+			return []any{"identifier", "identifier", "identifier", nid, nid, "password"}
+		},
+	},
+	{
+		name:  "verifiable_address_by_value",
+		query: "SELECT * FROM identity_verifiable_addresses WHERE nid = ? AND via = ? AND value = ?",
+		args:  func(nid uuid.UUID) []any { return []any{nid, "email", "address"} },
+	},
+	{
+		name:  "recovery_address_by_value",
+		query: "SELECT * FROM identity_recovery_addresses WHERE nid = ? AND via = ? AND value = ?",
+		args:  func(nid uuid.UUID) []any { return []any{nid, "email", "address"} },
+	},
+	{
+		name:  "undeliverable_recipient",
+		query: "SELECT 1 FROM identity_verifiable_addresses WHERE nid = ? AND value = ? AND status = ?",
+		args:  func(nid uuid.UUID) []any { return []any{nid, "address", "undeliverable"} },
+	},
+	{
+		name:  "login_flow_by_id",
+		query: "SELECT * FROM selfservice_login_flows WHERE id = ? AND nid = ?",
+		args:  byID,
+	},
+	{
+		name:  "registration_flow_by_id",
+		query: "SELECT * FROM selfservice_registration_flows WHERE id = ? AND nid = ?",
+		args:  byID,
+	},
+	{
+		name:  "settings_flow_by_id",
+		query: "SELECT * FROM selfservice_settings_flows WHERE id = ? AND nid = ?",
+		args:  byID,
+	},
+	{
+		name:  "recovery_flow_by_id",
+		query: "SELECT * FROM selfservice_recovery_flows WHERE id = ? AND nid = ?",
+		args:  byID,
+	},
+	{
+		name:  "verification_flow_by_id",
+		query: "SELECT * FROM selfservice_verification_flows WHERE id = ? AND nid = ?",
+		args:  byID,
+	},
+	{
+		name:  "queued_messages",
+		query: "SELECT id FROM courier_messages WHERE nid = ? AND status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?) ORDER BY created_at ASC LIMIT 10",
+		args:  func(nid uuid.UUID) []any { return []any{nid, 1, time.Now().UTC()} },
+	},
+}
+
+// AnalyzeQueries explains the execution plans of frequently executed queries against the schema
+// of the database and reports those which are candidates for slow queries. Whether the database
+// uses an index depends on the statistics of the tables, so the analysis should run against a
+// database with production data.
+func AnalyzeQueries(ctx context.Context, c *pop.Connection, nid uuid.UUID) ([]QueryPlan, error) {
+	dialect := c.Dialect.Name()
+
+	var prefix string
+	var detect func(plan []string) string
+	switch dialect {
+	case "mysql":
+		prefix, detect = "EXPLAIN ", detectSlowMySQLPlan
+	case "postgres":
+		prefix, detect = "EXPLAIN ", detectSlowPlan("Seq Scan", "a sequential scan")
+	case "cockroach":
+		prefix, detect = "EXPLAIN ", detectSlowPlan("FULL SCAN", "a full table scan")
+	case "sqlite3":
+		prefix, detect = "EXPLAIN QUERY PLAN ", detectSlowSQLitePlan
+	default:
+		return nil, errors.Errorf("unable to analyze queries of the %s dialect", dialect)
+	}
+
+	plans := make([]QueryPlan, 0, len(hotQueries))
+	for _, q := range hotQueries {
+		plan, err := explain(ctx, c, prefix+q.query, q.args(nid), dialect)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unable to explain query %q", q.name)
+		}
+
+		reason := detect(plan)
+		plans = append(plans, QueryPlan{
+			Name:          q.name,
+			Query:         q.query,
+			Plan:          plan,
+			SlowCandidate: reason != "",
+			Reason:        reason,
+		})
+	}
+	return plans, nil
+}
+
+// explain runs the statement and formats every row of the result as a line of the plan.
+func explain(ctx context.Context, c *pop.Connection, query string, args []any, dialect string) ([]string, error) {
+	rows, err := c.Store.SQLDB().QueryContext(ctx, c.Dialect.TranslateSQL(query), args...)
+	if err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var plan []string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		switch dialect {
+		case "mysql":
+			// MySQL and MariaDB return one row per table with the access type, index and
+			// extra information in separate columns.
+			var fields []string
+			for i, column := range columns {
+				switch strings.ToLower(column) {
+				case "table", "type", "key", "rows", "extra":
+					fields = append(fields, fmt.Sprintf("%s=%s", strings.ToLower(column), values[i].String))
+				}
+			}
+			plan = append(plan, strings.Join(fields, " "))
+		case "sqlite3":
+			// The last column holds the description of the step.
+			plan = append(plan, values[len(values)-1].String)
+		default:
+			var fields []string
+			for _, v := range values {
+				if v.String != "" {
+					fields = append(fields, v.String)
+				}
+			}
+			plan = append(plan, strings.Join(fields, " "))
+		}
+	}
+	return plan, errors.WithStack(rows.Err())
+}
+
+func detectSlowMySQLPlan(plan []string) string {
+	for _, line := range plan {
+		switch {
+		case strings.Contains(line, "type=ALL"):
+			return "The query scans a whole table: " + line
+		case strings.Contains(line, "type=index "):
+			return "The query scans a whole index: " + line
+		case strings.Contains(line, "Using filesort"):
+			return "The query sorts without an index: " + line
+		case strings.Contains(line, "Using temporary"):
+			return "The query uses a temporary table: " + line
+		}
+	}
+	return ""
+}
+
+func detectSlowSQLitePlan(plan []string) string {
+	for _, line := range plan {
+		switch {
+		case strings.HasPrefix(line, "SCAN ") && !strings.Contains(line, "USING INDEX") && !strings.Contains(line, "USING COVERING INDEX"):
+			return "The query scans a whole table: " + line
+		case strings.Contains(line, "USE TEMP B-TREE"):
+			return "The query sorts without an index: " + line
+		}
+	}
+	return ""
+}
+
+func detectSlowPlan(marker, description string) func(plan []string) string {
+	return func(plan []string) string {
+		for _, line := range plan {
+			if strings.Contains(line, marker) {
+				return fmt.Sprintf("The query performs %s: %s", description, strings.TrimSpace(line))
+			}
+		}
+		return ""
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/persistence/sql"
+)
+
+func TestAnalyzeQueries(t *testing.T) {
+	t.Parallel()
+
+	_, reg := internal.NewFastRegistryWithMocks(t)
+	ctx := context.Background()
+	c := reg.Persister().GetConnection(ctx)
+
+	plans, err := sql.AnalyzeQueries(ctx, c, reg.Persister().NetworkID(ctx))
+	require.NoError(t, err)
+	require.NotEmpty(t, plans)
+
+	for _, p := range plans {
+		assert.NotEmpty(t, p.Plan, p.Name)
+		assert.Falsef(t, p.SlowCandidate, "%s: %s", p.Name, p.Reason)
+	}
+
+	t.Run("case=reports full table scans", func(t *testing.T) {
+		require.NoError(t, c.RawQuery("DROP INDEX courier_messages_nid_status_created_at_id_idx").Exec())
+		plans, err := sql.AnalyzeQueries(ctx, c, reg.Persister().NetworkID(ctx))
+		require.NoError(t, err)
+		var found bool
+		for _, p := range plans {
+			if p.Name == "queued_messages" {
+				found = true
+				assert.True(t, p.SlowCandidate)
+				assert.NotEmpty(t, p.Reason)
+			}
+		}
+		assert.True(t, found)
+	})
+}
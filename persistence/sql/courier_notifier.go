@@ -0,0 +1,200 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/x/dbal"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+)
+
+// courierNotifyChannel is the PostgreSQL notification channel for queued courier messages.
+const courierNotifyChannel = "kratos_courier_messages"
+
+// courierNotifierReconnectWait is how long the notifier waits before it reconnects to the
+// database after the connection for notifications failed.
+var courierNotifierReconnectWait = 5 * time.Second
+
+type (
+	courierNotifierDependencies interface {
+		config.Provider
+		x.LoggingProvider
+	}
+
+	// CourierNotifier notifies couriers in the same process directly. Couriers in other
+	// processes are notified using LISTEN/NOTIFY on PostgreSQL and using a changefeed on
+	// CockroachDB. Other databases only support notifying couriers in the same process.
+	CourierNotifier struct {
+		r courierNotifierDependencies
+		c interface {
+			GetConnection(context.Context) *pop.Connection
+		}
+		local *courier.LocalNotifier
+	}
+)
+
+var _ courier.Notifier = new(CourierNotifier)
+
+func NewCourierNotifier(r courierNotifierDependencies, c interface {
+	GetConnection(context.Context) *pop.Connection
+}) *CourierNotifier {
+	return &CourierNotifier{r: r, c: c, local: courier.NewLocalNotifier()}
+}
+
+func (n *CourierNotifier) NotifyMessageQueued(ctx context.Context) {
+	if !n.r.Config().CourierWorkerInstantDispatch(ctx) {
+		return
+	}
+
+	n.local.NotifyMessageQueued(ctx)
+
+	if conn := n.c.GetConnection(ctx); conn.Dialect.Name() == dbal.DriverPostgreSQL {
+		// If the message was queued in a transaction, PostgreSQL delivers the notification
+		// once the transaction commits.
+		if err := conn.RawQuery("SELECT pg_notify(?, '')", courierNotifyChannel).Exec(); err != nil {
+			n.r.Logger().WithError(err).Warn("Unable to notify couriers of the queued message.")
+		}
+	}
+}
+
+func (n *CourierNotifier) WatchMessageQueue(ctx context.Context) (<-chan struct{}, error) {
+	if !n.r.Config().CourierWorkerInstantDispatch(ctx) {
+		// Receiving from a nil channel blocks forever, so the courier only pulls the queue.
+		return nil, nil
+	}
+
+	local, err := n.local.WatchMessageQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case out <- struct{}{}:
+		default:
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range local {
+			notify()
+		}
+	}()
+
+	var watch func(ctx context.Context, dsn string, notify func()) error
+	switch n.c.GetConnection(ctx).Dialect.Name() {
+	case dbal.DriverPostgreSQL:
+		watch = listenPostgreSQL
+	case dbal.DriverCockroachDB:
+		watch = watchCockroachChangefeed
+	}
+	if watch != nil {
+		_, _, _, _, dsn := sqlcon.ParseConnectionOptions(n.r.Logger(), n.r.Config().DSN(ctx))
+		for _, scheme := range []string{"cockroach://", "cockroachdb://", "crdb://"} {
+			if rest, ok := strings.CutPrefix(dsn, scheme); ok {
+				dsn = "postgres://" + rest
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := watch(ctx, dsn, notify)
+				if ctx.Err() != nil {
+					return
+				}
+				n.r.Logger().WithError(err).
+					Warnf("Unable to watch the database for queued courier messages, retrying in %s. Until then, queued messages are dispatched on the next pull.", courierNotifierReconnectWait)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(courierNotifierReconnectWait):
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+func listenPostgreSQL(ctx context.Context, dsn string, notify func()) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+courierNotifyChannel); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Messages might have been queued while the notifier was not listening.
+	notify()
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return errors.WithStack(err)
+		}
+		notify()
+	}
+}
+
+func watchCockroachChangefeed(ctx context.Context, dsn string, notify func()) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	// A core changefeed streams every change of the table over this connection until the
+	// context is canceled.
+	rows, err := conn.Query(ctx, fmt.Sprintf("EXPERIMENTAL CHANGEFEED FOR %s", new(courier.Message).TableName(ctx)))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	// Messages might have been queued while the notifier was not watching.
+	notify()
+	for rows.Next() {
+		var (
+			table      string
+			key, value []byte
+		)
+		if err := rows.Scan(&table, &key, &value); err != nil {
+			return errors.WithStack(err)
+		}
+		if gjson.GetBytes(value, "after.status").Int() == int64(courier.MessageStatusQueued) {
+			notify()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.New("the changefeed ended unexpectedly")
+}
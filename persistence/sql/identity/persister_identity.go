@@ -8,6 +8,7 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -49,6 +50,7 @@ var (
 type dependencies interface {
 	schema.IdentitySchemaProvider
 	identity.ValidationProvider
+	identity.TraitsEncrypterProvider
 	x.LoggingProvider
 	config.Provider
 	contextx.Provider
@@ -202,6 +204,16 @@ func (p *IdentityPersister) FindByCredentialsIdentifier(ctx context.Context, ct
 	// Force case-insensitivity and trimming for identifiers
 	match = NormalizeIdentifier(ct, match)
 
+	// Phone numbers are stored in E.164 format and email addresses in their canonical form. The
+	// normalized values are matched in addition to the raw one, because identities created before
+	// these were normalized may still use the raw value.
+	phone, email := match, match
+	switch ct {
+	case identity.CredentialsTypePassword, identity.CredentialsTypeCodeAuth, identity.CredentialsTypeWebAuthn:
+		phone = x.NormalizePhoneIdentifier(match, p.r.Config().IdentityPhoneDefaultRegion(ctx))
+		email = x.CanonicalizeEmailIdentifier(match, p.r.Config().IdentityEmailNormalization(ctx))
+	}
+
 	if err := p.GetConnection(ctx).RawQuery(`
 		SELECT
 			ic.identity_id
@@ -210,12 +222,14 @@ func (p *IdentityPersister) FindByCredentialsIdentifier(ctx context.Context, ct
 					ON ic.identity_credential_type_id = ict.id
 				INNER JOIN identity_credential_identifiers ici
 					ON ic.id = ici.identity_credential_id AND ici.identity_credential_type_id = ict.id
-		WHERE ici.identifier = ?
+		WHERE ici.identifier IN (?, ?, ?)
 		AND ic.nid = ?
 		AND ici.nid = ?
 		AND ict.name = ?
 		LIMIT 1`, // pop doesn't understand how to add a limit clause to this query
 		match,
+		phone,
+		email,
 		nid,
 		nid,
 		ct,
@@ -277,6 +291,10 @@ LIMIT 1`, jsonPath, jsonPath),
 		return nil, sqlcon.HandleError(err)
 	}
 
+	if err := p.r.IdentityTraitsEncrypter().DecryptTraits(ctx, &id); err != nil {
+		return nil, err
+	}
+
 	return &id, nil
 }
 
@@ -510,6 +528,32 @@ func (p *IdentityPersister) CountIdentities(ctx context.Context) (n int64, err e
 	return int64(count), nil
 }
 
+// encryptTraits replaces the traits of the identities with their encrypted form before they are
+// persisted. The returned function restores the plain text traits. Because the encrypted traits
+// are bound to the identity and its network, the identities must have their IDs set.
+func (p *IdentityPersister) encryptTraits(ctx context.Context, identities ...*identity.Identity) (restore func(), err error) {
+	plain := make([]identity.Traits, len(identities))
+	restore = func() {
+		for k, ident := range identities {
+			if plain[k] != nil {
+				ident.Traits = plain[k]
+			}
+		}
+	}
+
+	for k, ident := range identities {
+		ident.NID = p.NetworkID(ctx)
+		encrypted, err := p.r.IdentityTraitsEncrypter().EncryptTraits(ctx, ident)
+		if err != nil {
+			restore()
+			return nil, err
+		}
+		plain[k], ident.Traits = ident.Traits, encrypted
+	}
+
+	return restore, nil
+}
+
 func (p *IdentityPersister) CreateIdentity(ctx context.Context, ident *identity.Identity) (err error) {
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateIdentity",
 		trace.WithAttributes(
@@ -528,6 +572,9 @@ func (p *IdentityPersister) CreateIdentities(ctx context.Context, identities ...
 
 	for _, ident := range identities {
 		ident.NID = p.NetworkID(ctx)
+		if ident.ID == uuid.Nil {
+			ident.ID = x.NewUUID()
+		}
 
 		if ident.SchemaID == "" {
 			ident.SchemaID = p.r.Config().DefaultIdentityTraitsSchemaID(ctx)
@@ -552,6 +599,12 @@ func (p *IdentityPersister) CreateIdentities(ctx context.Context, identities ...
 		}
 	}
 
+	restoreTraits, err := p.encryptTraits(ctx, identities...)
+	if err != nil {
+		return err
+	}
+	defer restoreTraits()
+
 	var succeededIDs []uuid.UUID
 	var partialErr *identity.CreateIdentitiesError
 	if err := p.Transaction(ctx, func(ctx context.Context, tx *pop.Connection) error {
@@ -723,6 +776,10 @@ func (p *IdentityPersister) HydrateIdentityAssociations(ctx context.Context, i *
 		return err
 	}
 
+	if err := p.r.IdentityTraitsEncrypter().DecryptTraits(ctx, i); err != nil {
+		return err
+	}
+
 	return p.InjectTraitsSchemaURL(ctx, i)
 }
 
@@ -1031,6 +1088,10 @@ func (p *IdentityPersister) ListIdentities(ctx context.Context, params identity.
 			return nil, nil, err
 		}
 
+		if err := p.r.IdentityTraitsEncrypter().DecryptTraits(ctx, i); err != nil {
+			return nil, nil, err
+		}
+
 		is[k] = *i
 	}
 
@@ -1044,6 +1105,14 @@ func (p *IdentityPersister) UpdateIdentityColumns(ctx context.Context, i *identi
 			attribute.Stringer("network.id", p.NetworkID(ctx))))
 	defer otelx.End(span, &err)
 
+	if len(columns) == 0 || slices.Contains(columns, "traits") {
+		restoreTraits, err := p.encryptTraits(ctx, i)
+		if err != nil {
+			return err
+		}
+		defer restoreTraits()
+	}
+
 	if err := p.Transaction(ctx, func(ctx context.Context, tx *pop.Connection) error {
 		_, err := tx.Where("id = ? AND nid = ?", i.ID, p.NetworkID(ctx)).UpdateQuery(i, columns...)
 		return sqlcon.HandleError(err)
@@ -1066,6 +1135,12 @@ func (p *IdentityPersister) UpdateIdentity(ctx context.Context, i *identity.Iden
 		return err
 	}
 
+	restoreTraits, err := p.encryptTraits(ctx, i)
+	if err != nil {
+		return err
+	}
+	defer restoreTraits()
+
 	i.NID = p.NetworkID(ctx)
 	i.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
 	if err := sqlcon.HandleError(p.Transaction(ctx, func(ctx context.Context, tx *pop.Connection) error {
@@ -1103,6 +1178,73 @@ func (p *IdentityPersister) UpdateIdentity(ctx context.Context, i *identity.Iden
 	return nil
 }
 
+func (p *IdentityPersister) ReencryptIdentityTraits(ctx context.Context, batchSize int) (n int, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.ReencryptIdentityTraits",
+		trace.WithAttributes(
+			attribute.Stringer("network.id", p.NetworkID(ctx))))
+	defer func() {
+		span.SetAttributes(attribute.Int("identities.reencrypted", n))
+		otelx.End(span, &err)
+	}()
+
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	lastID := uuid.Nil
+	for {
+		var is []identity.Identity
+		if err := p.GetConnection(ctx).
+			Where("nid = ? AND id > ?", p.NetworkID(ctx), lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			All(&is); err != nil {
+			return n, sqlcon.HandleError(err)
+		}
+
+		for k := range is {
+			i := &is[k]
+			traits, changed, err := p.r.IdentityTraitsEncrypter().ReencryptTraits(ctx, i)
+			if err != nil {
+				return n, err
+			}
+			if !changed {
+				continue
+			}
+
+			i.Traits = traits
+			if _, err := p.GetConnection(ctx).Where("id = ? AND nid = ?", i.ID, p.NetworkID(ctx)).UpdateQuery(i, "traits"); err != nil {
+				return n, sqlcon.HandleError(err)
+			}
+			n++
+		}
+
+		if len(is) < batchSize {
+			return n, nil
+		}
+		lastID = is[len(is)-1].ID
+	}
+}
+
+func (p *IdentityPersister) ListIdentitiesInactiveSince(ctx context.Context, since time.Time, afterID uuid.UUID, limit int) (_ []identity.Identity, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.ListIdentitiesInactiveSince",
+		trace.WithAttributes(
+			attribute.Stringer("network.id", p.NetworkID(ctx))))
+	defer otelx.End(span, &err)
+
+	var is []identity.Identity
+	if err := p.GetConnection(ctx).
+		Where("nid = ? AND id > ? AND state IN (?, ?)", p.NetworkID(ctx), afterID, identity.StateActive, identity.StateInactive).
+		Where("(last_authenticated_at < ? OR (last_authenticated_at IS NULL AND created_at < ?))", since.UTC(), since.UTC()).
+		Order("id ASC").
+		Limit(limit).
+		All(&is); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return is, nil
+}
+
 func (p *IdentityPersister) DeleteIdentity(ctx context.Context, id uuid.UUID) (err error) {
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.DeleteIdentity",
 		trace.WithAttributes(
@@ -1200,8 +1342,16 @@ func (p *IdentityPersister) FindVerifiableAddressByValue(ctx context.Context, vi
 			attribute.Stringer("network.id", p.NetworkID(ctx))))
 	otelx.End(span, &err)
 
+	// Phone numbers are stored in E.164 format. The normalized value is matched in addition to the
+	// raw one, because addresses stored before phone numbers were normalized may still use the raw value.
+	value = stringToLowerTrim(value)
+	normalized := value
+	if via == identity.AddressTypeSMS {
+		normalized = x.NormalizePhoneIdentifier(value, p.r.Config().IdentityPhoneDefaultRegion(ctx))
+	}
+
 	var address identity.VerifiableAddress
-	if err := p.GetConnection(ctx).Where("nid = ? AND via = ? AND value = ?", p.NetworkID(ctx), via, stringToLowerTrim(value)).First(&address); err != nil {
+	if err := p.GetConnection(ctx).Where("nid = ? AND via = ? AND value IN (?, ?)", p.NetworkID(ctx), via, value, normalized).First(&address); err != nil {
 		return nil, sqlcon.HandleError(err)
 	}
 
@@ -0,0 +1 @@
+DROP TABLE selfservice_cross_device_login_flows;
@@ -0,0 +1,33 @@
+CREATE TABLE selfservice_cross_device_login_flows (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    nid CHAR(36) NOT NULL,
+    request_url TEXT NOT NULL,
+    issued_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    state VARCHAR(32) NOT NULL,
+    user_code VARCHAR(16) NOT NULL,
+    device_code VARCHAR(64) NOT NULL,
+    ui TEXT,
+    identity_id CHAR(36) DEFAULT NULL,
+    approved_at timestamp NULL DEFAULT NULL,
+
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT selfservice_cross_device_login_flows_nid_fk FOREIGN KEY (nid) REFERENCES networks (id) ON DELETE CASCADE,
+    CONSTRAINT selfservice_cross_device_login_flows_identity_id_fk FOREIGN KEY (identity_id) REFERENCES identities (id) ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   SELECT * FROM selfservice_cross_device_login_flows WHERE user_code = ? AND nid = ?
+CREATE UNIQUE INDEX selfservice_cross_device_login_flows_user_code_nid_uq_idx ON selfservice_cross_device_login_flows (user_code, nid);
+
+-- Relevant query:
+--   SELECT * FROM selfservice_cross_device_login_flows WHERE device_code = ? AND nid = ?
+CREATE UNIQUE INDEX selfservice_cross_device_login_flows_device_code_nid_uq_idx ON selfservice_cross_device_login_flows (device_code, nid);
+
+-- Relevant query:
+--   DELETE FROM selfservice_cross_device_login_flows WHERE expires_at <= ? AND nid = ?
+CREATE INDEX selfservice_cross_device_login_flows_nid_expires_at_idx ON selfservice_cross_device_login_flows (nid, expires_at);
+
+CREATE INDEX selfservice_cross_device_login_flows_identity_id_idx ON selfservice_cross_device_login_flows (identity_id);
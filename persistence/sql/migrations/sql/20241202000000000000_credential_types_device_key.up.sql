@@ -0,0 +1,3 @@
+INSERT INTO identity_credential_types (id, name)
+SELECT 'a4d2b8c5-3f1e-4c7a-9b6d-2e8f0c1d5a73', 'device_key'
+WHERE NOT EXISTS ( SELECT * FROM identity_credential_types WHERE name = 'device_key');
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE selfservice_registration_flows DROP COLUMN identity_schema_id;
@@ -0,0 +1 @@
+ALTER TABLE selfservice_registration_flows ADD identity_schema_id VARCHAR(128) NULL;
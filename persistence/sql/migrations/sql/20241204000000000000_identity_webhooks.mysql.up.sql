@@ -0,0 +1,18 @@
+CREATE TABLE identity_webhooks (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    nid CHAR(36) NOT NULL,
+    identity_id CHAR(36) NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    verified_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT identity_webhooks_nid_fk FOREIGN KEY (nid) REFERENCES networks (id) ON DELETE CASCADE,
+    CONSTRAINT identity_webhooks_identity_id_fk FOREIGN KEY (identity_id) REFERENCES identities (id) ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   SELECT * FROM identity_webhooks WHERE identity_id = ? AND nid = ?
+CREATE UNIQUE INDEX identity_webhooks_identity_id_nid_uq_idx ON identity_webhooks (identity_id, nid);
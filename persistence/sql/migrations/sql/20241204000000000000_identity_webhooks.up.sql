@@ -0,0 +1,18 @@
+CREATE TABLE identity_webhooks (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "nid" UUID NOT NULL,
+    "identity_id" UUID NOT NULL,
+    "url" TEXT NOT NULL,
+    "secret" TEXT NOT NULL,
+    "verified_at" timestamp NOT NULL,
+
+    "created_at" timestamp NOT NULL,
+    "updated_at" timestamp NOT NULL,
+
+    CONSTRAINT identity_webhooks_nid_fk FOREIGN KEY ("nid") REFERENCES networks ("id") ON DELETE CASCADE,
+    CONSTRAINT identity_webhooks_identity_id_fk FOREIGN KEY ("identity_id") REFERENCES identities ("id") ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   SELECT * FROM identity_webhooks WHERE identity_id = ? AND nid = ?
+CREATE UNIQUE INDEX identity_webhooks_identity_id_nid_uq_idx ON identity_webhooks (identity_id, nid);
@@ -0,0 +1 @@
+DROP TABLE issued_admin_api_tokens;
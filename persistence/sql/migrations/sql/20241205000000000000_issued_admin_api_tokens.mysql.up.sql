@@ -0,0 +1,15 @@
+CREATE TABLE issued_admin_api_tokens (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    nid CHAR(36) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT issued_admin_api_tokens_nid_fk FOREIGN KEY (nid) REFERENCES networks (id) ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   SELECT * FROM issued_admin_api_tokens WHERE token_hash = ? AND nid = ? AND expires_at > ?
+CREATE UNIQUE INDEX issued_admin_api_tokens_token_hash_nid_uq_idx ON issued_admin_api_tokens (token_hash, nid);
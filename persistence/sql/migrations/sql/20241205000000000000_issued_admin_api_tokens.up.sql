@@ -0,0 +1,15 @@
+CREATE TABLE issued_admin_api_tokens (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "nid" UUID NOT NULL,
+    "token_hash" VARCHAR(64) NOT NULL,
+    "expires_at" timestamp NOT NULL,
+
+    "created_at" timestamp NOT NULL,
+    "updated_at" timestamp NOT NULL,
+
+    CONSTRAINT issued_admin_api_tokens_nid_fk FOREIGN KEY ("nid") REFERENCES networks ("id") ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   SELECT * FROM issued_admin_api_tokens WHERE token_hash = ? AND nid = ? AND expires_at > ?
+CREATE UNIQUE INDEX issued_admin_api_tokens_token_hash_nid_uq_idx ON issued_admin_api_tokens (token_hash, nid);
@@ -0,0 +1 @@
+DROP TABLE selfservice_flow_funnel_entries;
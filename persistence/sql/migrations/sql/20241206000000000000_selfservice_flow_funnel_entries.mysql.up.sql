@@ -0,0 +1,21 @@
+CREATE TABLE selfservice_flow_funnel_entries (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    nid CHAR(36) NOT NULL,
+    flow VARCHAR(32) NOT NULL,
+    expires_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    submitted_at timestamp NULL,
+    failed_submissions INT NOT NULL DEFAULT 0,
+    succeeded_at timestamp NULL,
+
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT selfservice_flow_funnel_entries_nid_fk FOREIGN KEY (nid) REFERENCES networks (id) ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   SELECT * FROM selfservice_flow_funnel_entries WHERE nid = ? AND flow = ? AND created_at >= ? AND created_at < ? ORDER BY created_at, id
+CREATE INDEX selfservice_flow_funnel_entries_nid_flow_created_at_idx ON selfservice_flow_funnel_entries (nid, flow, created_at, id);
+-- Relevant query:
+--   DELETE FROM selfservice_flow_funnel_entries WHERE expires_at <= ? AND nid = ?
+CREATE INDEX selfservice_flow_funnel_entries_expires_at_idx ON selfservice_flow_funnel_entries (expires_at);
@@ -0,0 +1 @@
+DROP TABLE sso_connections;
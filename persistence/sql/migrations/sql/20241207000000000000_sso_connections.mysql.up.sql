@@ -0,0 +1,25 @@
+CREATE TABLE sso_connections (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    nid CHAR(36) NOT NULL,
+    organization_id CHAR(36) NOT NULL,
+    protocol VARCHAR(16) NOT NULL,
+    label VARCHAR(255) NOT NULL,
+    provider VARCHAR(64) NOT NULL,
+    issuer_url VARCHAR(2048) NOT NULL,
+    client_id VARCHAR(255) NOT NULL,
+    client_secret TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    idp_metadata MEDIUMTEXT NOT NULL,
+    idp_entity_id VARCHAR(2048) NOT NULL,
+    idp_sso_url VARCHAR(2048) NOT NULL,
+    attribute_mapping TEXT NOT NULL,
+
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT sso_connections_nid_fk FOREIGN KEY (nid) REFERENCES networks (id) ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   SELECT * FROM sso_connections WHERE organization_id = ? AND nid = ? ORDER BY created_at
+CREATE INDEX sso_connections_nid_organization_id_idx ON sso_connections (nid, organization_id);
@@ -0,0 +1 @@
+ALTER TABLE courier_messages DROP COLUMN trace_context;
@@ -0,0 +1 @@
+ALTER TABLE courier_messages ADD trace_context TEXT NULL;
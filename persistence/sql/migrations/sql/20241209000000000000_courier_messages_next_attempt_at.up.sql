@@ -0,0 +1 @@
+ALTER TABLE courier_messages ADD next_attempt_at TIMESTAMP NULL;
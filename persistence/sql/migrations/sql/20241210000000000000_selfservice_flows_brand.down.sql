@@ -0,0 +1,5 @@
+ALTER TABLE selfservice_verification_flows DROP COLUMN brand;
+ALTER TABLE selfservice_recovery_flows DROP COLUMN brand;
+ALTER TABLE selfservice_settings_flows DROP COLUMN brand;
+ALTER TABLE selfservice_registration_flows DROP COLUMN brand;
+ALTER TABLE selfservice_login_flows DROP COLUMN brand;
@@ -0,0 +1,5 @@
+ALTER TABLE selfservice_login_flows ADD brand VARCHAR(64) NULL;
+ALTER TABLE selfservice_registration_flows ADD brand VARCHAR(64) NULL;
+ALTER TABLE selfservice_settings_flows ADD brand VARCHAR(64) NULL;
+ALTER TABLE selfservice_recovery_flows ADD brand VARCHAR(64) NULL;
+ALTER TABLE selfservice_verification_flows ADD brand VARCHAR(64) NULL;
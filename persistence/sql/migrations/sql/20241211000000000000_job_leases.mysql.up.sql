@@ -0,0 +1,16 @@
+CREATE TABLE job_leases (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    nid CHAR(36) NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    holder_id CHAR(36) NOT NULL,
+    expires_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT job_leases_nid_fk FOREIGN KEY (nid) REFERENCES networks (id) ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   UPDATE job_leases SET holder_id = ?, expires_at = ?, updated_at = ? WHERE nid = ? AND name = ? AND (holder_id = ? OR expires_at <= ?)
+CREATE UNIQUE INDEX job_leases_nid_name_uq_idx ON job_leases (nid, name);
@@ -0,0 +1,16 @@
+CREATE TABLE job_leases (
+    "id" UUID NOT NULL PRIMARY KEY,
+    "nid" UUID NOT NULL,
+    "name" VARCHAR(64) NOT NULL,
+    "holder_id" UUID NOT NULL,
+    "expires_at" timestamp NOT NULL,
+
+    "created_at" timestamp NOT NULL,
+    "updated_at" timestamp NOT NULL,
+
+    CONSTRAINT job_leases_nid_fk FOREIGN KEY ("nid") REFERENCES networks ("id") ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   UPDATE job_leases SET holder_id = ?, expires_at = ?, updated_at = ? WHERE nid = ? AND name = ? AND (holder_id = ? OR expires_at <= ?)
+CREATE UNIQUE INDEX job_leases_nid_name_uq_idx ON job_leases (nid, name);
@@ -0,0 +1,2 @@
+ALTER TABLE sessions DROP COLUMN password_reset_required;
+ALTER TABLE identities DROP COLUMN password_reset_required;
@@ -0,0 +1,2 @@
+ALTER TABLE identities ADD password_reset_required boolean NOT NULL DEFAULT FALSE;
+ALTER TABLE sessions ADD password_reset_required boolean NOT NULL DEFAULT FALSE;
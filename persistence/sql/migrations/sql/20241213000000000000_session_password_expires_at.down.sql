@@ -0,0 +1 @@
+ALTER TABLE sessions DROP COLUMN password_expires_at;
@@ -0,0 +1 @@
+ALTER TABLE sessions ADD password_expires_at TIMESTAMP NULL;
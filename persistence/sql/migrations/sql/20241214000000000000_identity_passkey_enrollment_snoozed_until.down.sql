@@ -0,0 +1 @@
+ALTER TABLE identities DROP COLUMN passkey_enrollment_snoozed_until;
@@ -0,0 +1 @@
+ALTER TABLE identities ADD passkey_enrollment_snoozed_until TIMESTAMP NULL;
@@ -0,0 +1 @@
+ALTER TABLE sessions ADD metadata JSON NULL;
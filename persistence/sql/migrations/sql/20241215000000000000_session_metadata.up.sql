@@ -0,0 +1 @@
+ALTER TABLE sessions ADD metadata jsonb NULL;
@@ -0,0 +1 @@
+ALTER TABLE sessions ADD last_active_at TIMESTAMP NULL;
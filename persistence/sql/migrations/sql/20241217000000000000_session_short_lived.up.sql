@@ -0,0 +1 @@
+ALTER TABLE sessions ADD short_lived BOOLEAN NOT NULL DEFAULT FALSE;
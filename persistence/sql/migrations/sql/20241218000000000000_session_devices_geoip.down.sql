@@ -0,0 +1,4 @@
+ALTER TABLE session_devices DROP COLUMN as_organization;
+ALTER TABLE session_devices DROP COLUMN asn;
+ALTER TABLE session_devices DROP COLUMN country;
+ALTER TABLE session_devices DROP COLUMN city;
@@ -0,0 +1,4 @@
+ALTER TABLE session_devices ADD city VARCHAR(255) NULL;
+ALTER TABLE session_devices ADD country VARCHAR(2) NULL;
+ALTER TABLE session_devices ADD asn BIGINT NULL;
+ALTER TABLE session_devices ADD as_organization VARCHAR(255) NULL;
@@ -0,0 +1,18 @@
+CREATE TABLE session_revoke_links (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    nid CHAR(36) NOT NULL,
+    identity_id CHAR(36) NOT NULL,
+    expires_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT session_revoke_links_nid_fk FOREIGN KEY (nid) REFERENCES networks (id) ON DELETE CASCADE,
+    CONSTRAINT session_revoke_links_identity_id_fk FOREIGN KEY (identity_id) REFERENCES identities (id) ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   DELETE FROM session_revoke_links WHERE expires_at <= ? AND nid = ?
+CREATE INDEX session_revoke_links_nid_expires_at_idx ON session_revoke_links (nid, expires_at);
+
+CREATE INDEX session_revoke_links_identity_id_idx ON session_revoke_links (identity_id);
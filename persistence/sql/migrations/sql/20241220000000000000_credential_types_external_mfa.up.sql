@@ -0,0 +1,3 @@
+INSERT INTO identity_credential_types (id, name)
+SELECT 'c7e1f3a9-5b2d-4e8c-a6f4-9d3b1e7c2a58', 'external_mfa'
+WHERE NOT EXISTS ( SELECT * FROM identity_credential_types WHERE name = 'external_mfa');
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS identity_verifiable_addresses_nid_value_status_idx;
@@ -0,0 +1,2 @@
+DROP INDEX identity_credential_identifiers_nid_i_ict_ici_idx ON identity_credential_identifiers;
+DROP INDEX identity_verifiable_addresses_nid_value_status_idx ON identity_verifiable_addresses;
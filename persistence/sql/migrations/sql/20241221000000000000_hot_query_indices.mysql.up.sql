@@ -0,0 +1,5 @@
+CREATE INDEX identity_verifiable_addresses_nid_value_status_idx ON identity_verifiable_addresses (nid ASC, value ASC, status ASC);
+
+-- Covers the identity lookup by credential identifier, so that the identity credential ID is read from the index
+-- instead of the clustered index.
+CREATE INDEX identity_credential_identifiers_nid_i_ict_ici_idx ON identity_credential_identifiers (nid ASC, identifier ASC, identity_credential_type_id ASC, identity_credential_id ASC);
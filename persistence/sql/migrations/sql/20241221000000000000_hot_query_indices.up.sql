@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS identity_verifiable_addresses_nid_value_status_idx ON identity_verifiable_addresses (nid ASC, value ASC, status ASC);
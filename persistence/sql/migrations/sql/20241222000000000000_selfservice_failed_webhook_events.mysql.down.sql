@@ -0,0 +1 @@
+DROP TABLE selfservice_failed_webhook_events;
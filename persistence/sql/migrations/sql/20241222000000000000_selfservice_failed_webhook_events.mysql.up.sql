@@ -0,0 +1,20 @@
+CREATE TABLE selfservice_failed_webhook_events (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    nid CHAR(36) NOT NULL,
+    webhook_id VARCHAR(255) NOT NULL,
+    method VARCHAR(16) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    request MEDIUMTEXT NOT NULL,
+    error TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 1,
+    replayed_at timestamp NULL,
+
+    created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT selfservice_failed_webhook_events_nid_fk FOREIGN KEY (nid) REFERENCES networks (id) ON DELETE CASCADE
+);
+
+-- Relevant query:
+--   SELECT * FROM selfservice_failed_webhook_events WHERE nid = ? AND replayed_at IS NULL ORDER BY created_at DESC, id DESC
+CREATE INDEX selfservice_failed_webhook_events_nid_created_at_id_idx ON selfservice_failed_webhook_events (nid, created_at DESC, id DESC);
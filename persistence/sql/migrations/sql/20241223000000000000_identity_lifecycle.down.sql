@@ -0,0 +1,2 @@
+ALTER TABLE identities DROP COLUMN last_authenticated_at;
+ALTER TABLE identities DROP COLUMN state_reason;
@@ -0,0 +1,2 @@
+ALTER TABLE identities ADD state_reason VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE identities ADD last_authenticated_at TIMESTAMP NULL;
@@ -0,0 +1 @@
+ALTER TABLE selfservice_login_flows DROP COLUMN login_hint;
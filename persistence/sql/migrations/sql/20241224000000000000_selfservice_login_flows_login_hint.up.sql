@@ -0,0 +1 @@
+ALTER TABLE selfservice_login_flows ADD login_hint VARCHAR(320) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS identities_nid_state_last_authenticated_at_idx;
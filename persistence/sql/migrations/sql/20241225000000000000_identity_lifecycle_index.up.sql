@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS identities_nid_state_last_authenticated_at_idx ON identities (nid ASC, state ASC, last_authenticated_at ASC);
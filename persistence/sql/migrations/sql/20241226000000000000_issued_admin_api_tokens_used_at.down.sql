@@ -0,0 +1 @@
+ALTER TABLE issued_admin_api_tokens DROP COLUMN used_at;
@@ -0,0 +1 @@
+ALTER TABLE issued_admin_api_tokens ADD used_at TIMESTAMP NULL;
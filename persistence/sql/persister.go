@@ -7,7 +7,6 @@ import (
 	"context"
 	"embed"
 	"io/fs"
-	"time"
 
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
@@ -42,6 +41,8 @@ type (
 		x.TracingProvider
 		schema.IdentitySchemaProvider
 		identity.ValidationProvider
+		identity.TraitsEncrypterProvider
+		identity.ManagementProvider
 	}
 	Persister struct {
 		nid uuid.UUID
@@ -166,6 +167,53 @@ func (p *Persister) MigrateUp(ctx context.Context) error {
 	return p.mb.Up(ctx)
 }
 
+// MigrationPhaseStatus returns the migration status including the phase of every migration.
+func (p *Persister) MigrationPhaseStatus(ctx context.Context) (persistence.MigrationPhaseStatuses, error) {
+	status, err := p.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	phases := p.migrationPhases()
+	result := make(persistence.MigrationPhaseStatuses, len(status))
+	for i, s := range status {
+		result[i] = persistence.MigrationPhaseStatus{MigrationStatus: s, Phase: phases[s.Version]}
+	}
+	return result, nil
+}
+
+// MigrateUpPhase applies the pending migrations of a phase. The expand phase skips all contract
+// migrations, while the contract phase applies all pending migrations.
+func (p *Persister) MigrateUpPhase(ctx context.Context, phase persistence.MigrationPhase) error {
+	if phase == persistence.MigrationPhaseContract {
+		return p.MigrateUp(ctx)
+	}
+
+	phases := p.migrationPhases()
+	var up popx.Migrations
+	for _, mi := range p.mb.Migrations["up"] {
+		if phases[mi.Version] == persistence.MigrationPhaseExpand {
+			up = append(up, mi)
+		}
+	}
+
+	m := *p.mb.Migrator
+	m.Migrations = map[string]popx.Migrations{"up": up, "down": p.mb.Migrations["down"]}
+	return m.Up(ctx)
+}
+
+// migrationPhases returns the phase of every migration version. A version belongs to the contract
+// phase if the up migration of any dialect is marked as such.
+func (p *Persister) migrationPhases() map[string]persistence.MigrationPhase {
+	phases := make(map[string]persistence.MigrationPhase)
+	for _, mi := range p.mb.Migrations["up"] {
+		if phases[mi.Version] != persistence.MigrationPhaseContract {
+			phases[mi.Version] = persistence.MigrationPhaseOf(mi.Content)
+		}
+	}
+	return phases
+}
+
 func (p *Persister) MigrationBox() *popx.MigrationBox {
 	return p.mb
 }
@@ -181,60 +229,3 @@ func (p *Persister) Close(ctx context.Context) error {
 func (p *Persister) Ping(ctx context.Context) error {
 	return errors.WithStack(p.c.Store.SQLDB().PingContext(ctx))
 }
-
-func (p *Persister) CleanupDatabase(ctx context.Context, wait time.Duration, older time.Duration, batchSize int) error {
-	currentTime := time.Now().Add(-older)
-	p.r.Logger().Printf("Cleaning up records older than %s\n", currentTime)
-
-	p.r.Logger().Println("Cleaning up expired sessions")
-	if err := p.DeleteExpiredSessions(ctx, currentTime, batchSize); err != nil {
-		return err
-	}
-	time.Sleep(wait)
-
-	p.r.Logger().Println("Cleaning up expired continuity containers")
-	if err := p.DeleteExpiredContinuitySessions(ctx, currentTime, batchSize); err != nil {
-		return err
-	}
-	time.Sleep(wait)
-
-	p.r.Logger().Println("Cleaning up expired login flows")
-	if err := p.DeleteExpiredLoginFlows(ctx, currentTime, batchSize); err != nil {
-		return err
-	}
-	time.Sleep(wait)
-
-	p.r.Logger().Println("Cleaning up expired recovery flows")
-	if err := p.DeleteExpiredRecoveryFlows(ctx, currentTime, batchSize); err != nil {
-		return err
-	}
-	time.Sleep(wait)
-
-	p.r.Logger().Println("Cleaning up expired registation flows")
-	if err := p.DeleteExpiredRegistrationFlows(ctx, currentTime, batchSize); err != nil {
-		return err
-	}
-	time.Sleep(wait)
-
-	p.r.Logger().Println("Cleaning up expired settings flows")
-	if err := p.DeleteExpiredSettingsFlows(ctx, currentTime, batchSize); err != nil {
-		return err
-	}
-	time.Sleep(wait)
-
-	p.r.Logger().Println("Cleaning up expired verification flows")
-	if err := p.DeleteExpiredVerificationFlows(ctx, currentTime, batchSize); err != nil {
-		return err
-	}
-	time.Sleep(wait)
-
-	p.r.Logger().Println("Cleaning up expired session token exchangers")
-	if err := p.DeleteExpiredExchangers(ctx, currentTime, batchSize); err != nil {
-		return err
-	}
-	time.Sleep(wait)
-
-	p.r.Logger().Println("Successfully cleaned up the latest batch of the SQL database! " +
-		"This should be re-run periodically, to be sure that all expired data is purged.")
-	return nil
-}
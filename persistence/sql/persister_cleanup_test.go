@@ -9,8 +9,12 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/persistence/sql"
+	"github.com/ory/kratos/x"
 )
 
 func TestPersister_Cleanup(t *testing.T) {
@@ -30,6 +34,40 @@ func TestPersister_Cleanup(t *testing.T) {
 	})
 }
 
+func TestPersister_CollectGarbage(t *testing.T) {
+	t.Parallel()
+
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	p := reg.Persister()
+	ctx := context.Background()
+	conf.MustSet(ctx, config.ViperKeyDatabaseCleanupBatchSize, 2)
+	conf.MustSet(ctx, config.ViperKeyDatabaseCleanupRetention+"."+sql.GarbageCollectIssuedAdminAPITokens, "30m")
+
+	count := func(t *testing.T) int {
+		n, err := p.GetConnection(ctx).Where("nid = ?", p.NetworkID(ctx)).Count(new(x.IssuedAdminAPIToken))
+		require.NoError(t, err)
+		return n
+	}
+	create := func(t *testing.T, expiresAt time.Time) {
+		require.NoError(t, p.CreateIssuedAdminAPIToken(ctx, &x.IssuedAdminAPIToken{TokenHash: x.NewUUID().String(), ExpiresAt: expiresAt}))
+	}
+
+	for range 5 {
+		create(t, time.Now().Add(-time.Hour))
+	}
+	create(t, time.Now().Add(-time.Minute))
+	create(t, time.Now().Add(time.Hour))
+	require.Equal(t, 7, count(t))
+
+	require.NoError(t, p.CollectGarbage(ctx, sql.GarbageCollectIssuedAdminAPITokens))
+	assert.Equal(t, 2, count(t), "all batches are deleted, except for rows within the retention period")
+
+	require.NoError(t, p.CleanupDatabase(ctx, 0, 0, 2))
+	assert.Equal(t, 2, count(t), "the retention applies to the cleanup command as well")
+
+	assert.Error(t, p.CollectGarbage(ctx, "identities"))
+}
+
 func TestPersister_Continuity_Cleanup(t *testing.T) {
 	t.Parallel()
 
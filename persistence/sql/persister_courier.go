@@ -7,18 +7,23 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gobuffalo/pop/v6"
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 
 	"github.com/ory/herodot"
+	"github.com/ory/x/dbal"
 	"github.com/ory/x/otelx"
 	"github.com/ory/x/pagination/keysetpagination"
 	"github.com/ory/x/sqlcon"
 	"github.com/ory/x/uuidx"
 
 	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/persistence/sql/update"
 	"github.com/ory/kratos/x"
 )
@@ -48,6 +53,10 @@ func (p *Persister) ListMessages(ctx context.Context, filter courier.ListCourier
 		q = q.Where("recipient=?", filter.Recipient)
 	}
 
+	if len(filter.Channels) > 0 {
+		q = q.Where("channel IN (?)", filter.Channels)
+	}
+
 	count, err := q.Count(&courier.Message{})
 	if err != nil {
 		return nil, 0, nil, sqlcon.HandleError(err)
@@ -77,22 +86,49 @@ func (p *Persister) NextMessages(ctx context.Context, limit uint8) (messages []c
 	defer otelx.End(span, &err)
 
 	if err := p.Transaction(ctx, func(ctx context.Context, tx *pop.Connection) error {
+		lock := ""
+		switch tx.Dialect.Name() {
+		case dbal.DriverPostgreSQL, dbal.DriverCockroachDB, dbal.DriverMySQL:
+			// Rows claimed by the transaction of another courier worker are skipped instead of
+			// waiting for it, so that concurrent workers never pull the same message.
+			lock = "FOR UPDATE SKIP LOCKED"
+		}
+
+		var claimed []struct {
+			ID uuid.UUID `db:"id"`
+		}
+		//#nosec G201 -- TableName is static
+		if err := tx.RawQuery(fmt.Sprintf(
+			"SELECT id FROM %s WHERE nid = ? AND status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?) ORDER BY created_at ASC LIMIT %d %s",
+			new(courier.Message).TableName(ctx),
+			limit,
+			lock,
+		),
+			p.NetworkID(ctx),
+			courier.MessageStatusQueued,
+			time.Now().UTC(),
+		).All(&claimed); err != nil {
+			return err
+		}
+
+		if len(claimed) == 0 {
+			return sql.ErrNoRows
+		}
+
+		ids := make([]interface{}, len(claimed))
+		for i := range claimed {
+			ids[i] = claimed[i].ID
+		}
+
 		var m []courier.Message
 		if err := tx.
-			Where("nid = ? AND status = ?",
-				p.NetworkID(ctx),
-				courier.MessageStatusQueued,
-			).
+			Where("nid = ?", p.NetworkID(ctx)).
+			Where("id IN (?)", ids...).
 			Order("created_at ASC").
-			Limit(int(limit)).
 			All(&m); err != nil {
 			return err
 		}
 
-		if len(m) == 0 {
-			return sql.ErrNoRows
-		}
-
 		for i := range m {
 			message := &m[i]
 			message.Status = courier.MessageStatusProcessing
@@ -179,6 +215,42 @@ func (p *Persister) IncrementMessageSendCount(ctx context.Context, id uuid.UUID)
 	return nil
 }
 
+func (p *Persister) RequeueMessage(ctx context.Context, id uuid.UUID, notBefore time.Time) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.RequeueMessage")
+	defer otelx.End(span, &err)
+
+	// Messages which the channel abandoned while processing them stay abandoned.
+	return sqlcon.HandleError(p.GetConnection(ctx).RawQuery(
+		"UPDATE courier_messages SET status = ?, next_attempt_at = ? WHERE id = ? AND nid = ? AND status = ?",
+		courier.MessageStatusQueued,
+		notBefore.UTC(),
+		id,
+		p.NetworkID(ctx),
+		courier.MessageStatusProcessing,
+	).Exec())
+}
+
+func (p *Persister) RetryMessage(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.RetryMessage")
+	defer otelx.End(span, &err)
+
+	count, err := p.GetConnection(ctx).RawQuery(
+		"UPDATE courier_messages SET status = ?, send_count = 0, next_attempt_at = NULL WHERE id = ? AND nid = ?",
+		courier.MessageStatusQueued,
+		id,
+		p.NetworkID(ctx),
+	).ExecWithCount()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	}
+
+	if count == 0 {
+		return errors.WithStack(sqlcon.ErrNoRows)
+	}
+
+	return nil
+}
+
 func (p *Persister) FetchMessage(ctx context.Context, msgID uuid.UUID) (_ *courier.Message, err error) {
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.FetchMessage")
 	defer otelx.End(span, &err)
@@ -221,3 +293,55 @@ func (p *Persister) RecordDispatch(ctx context.Context, msgID uuid.UUID, status
 
 	return nil
 }
+
+func (p *Persister) LatestSentMessage(ctx context.Context, recipient string) (_ *courier.Message, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.LatestSentMessage")
+	defer otelx.End(span, &err)
+
+	var m courier.Message
+	if err := p.GetConnection(ctx).
+		Where("nid = ? AND recipient = ? AND status IN (?, ?)",
+			p.NetworkID(ctx),
+			recipient,
+			courier.MessageStatusSent,
+			courier.MessageStatusDelivered,
+		).
+		Order("created_at DESC").
+		First(&m); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &m, nil
+}
+
+func (p *Persister) SetRecipientUndeliverable(ctx context.Context, recipient string) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.SetRecipientUndeliverable")
+	defer otelx.End(span, &err)
+
+	return sqlcon.HandleError(p.GetConnection(ctx).RawQuery(
+		"UPDATE identity_verifiable_addresses SET status = ?, updated_at = ? WHERE nid = ? AND value = ?",
+		identity.VerifiableAddressStatusUndeliverable,
+		time.Now().UTC(),
+		p.NetworkID(ctx),
+		normalizeRecipient(recipient),
+	).Exec())
+}
+
+func (p *Persister) IsRecipientUndeliverable(ctx context.Context, recipient string) (_ bool, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.IsRecipientUndeliverable")
+	defer otelx.End(span, &err)
+
+	exists, err := p.GetConnection(ctx).
+		Where("nid = ? AND value = ? AND status = ?",
+			p.NetworkID(ctx),
+			normalizeRecipient(recipient),
+			identity.VerifiableAddressStatusUndeliverable,
+		).
+		Exists(new(identity.VerifiableAddress))
+	return exists, sqlcon.HandleError(err)
+}
+
+// normalizeRecipient matches the normalization of verifiable address values.
+func normalizeRecipient(recipient string) string {
+	return strings.ToLower(strings.TrimSpace(recipient))
+}
@@ -0,0 +1,164 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/persistence/sql/update"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
+)
+
+var _ crossdevice.FlowPersister = new(Persister)
+
+func (p *Persister) CreateCrossDeviceLoginFlow(ctx context.Context, f *crossdevice.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateCrossDeviceLoginFlow")
+	defer otelx.End(span, &err)
+
+	// The device code is a bearer secret and therefore only stored as a HMAC.
+	deviceCode := f.DeviceCode
+	f.DeviceCode = p.hmacValue(ctx, deviceCode)
+	f.NID = p.NetworkID(ctx)
+	if err := p.GetConnection(ctx).Create(f); err != nil {
+		f.DeviceCode = deviceCode
+		return sqlcon.HandleError(err)
+	}
+
+	f.DeviceCode = deviceCode
+	return nil
+}
+
+func (p *Persister) GetCrossDeviceLoginFlow(ctx context.Context, id uuid.UUID) (_ *crossdevice.Flow, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.GetCrossDeviceLoginFlow")
+	defer otelx.End(span, &err)
+
+	var f crossdevice.Flow
+	if err := p.GetConnection(ctx).Where("id = ? AND nid = ?", id, p.NetworkID(ctx)).First(&f); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &f, nil
+}
+
+func (p *Persister) GetCrossDeviceLoginFlowByUserCode(ctx context.Context, userCode string) (_ *crossdevice.Flow, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.GetCrossDeviceLoginFlowByUserCode")
+	defer otelx.End(span, &err)
+
+	var f crossdevice.Flow
+	if err := p.GetConnection(ctx).Where("user_code = ? AND user_code <> '' AND nid = ?", userCode, p.NetworkID(ctx)).First(&f); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &f, nil
+}
+
+func (p *Persister) GetCrossDeviceLoginFlowByDeviceCode(ctx context.Context, deviceCode string) (_ *crossdevice.Flow, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.GetCrossDeviceLoginFlowByDeviceCode")
+	defer otelx.End(span, &err)
+
+	var f crossdevice.Flow
+	if err := p.Transaction(ctx, func(ctx context.Context, tx *pop.Connection) (err error) {
+		for _, secret := range p.r.Config().SecretsSession(ctx) {
+			if err = tx.Where("device_code = ? AND nid = ?", hmacValueWithSecret(ctx, deviceCode, secret), p.NetworkID(ctx)).First(&f); err == nil {
+				return nil
+			} else if !errors.Is(sqlcon.HandleError(err), sqlcon.ErrNoRows) {
+				return err
+			}
+		}
+		return err
+	}); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &f, nil
+}
+
+func (p *Persister) UpdateCrossDeviceLoginFlow(ctx context.Context, f *crossdevice.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UpdateCrossDeviceLoginFlow")
+	defer otelx.End(span, &err)
+
+	cp := *f
+	cp.NID = p.NetworkID(ctx)
+	// The device code is never updated as the flow might carry it in plain text.
+	return update.Generic(ctx, p.GetConnection(ctx), p.r.Tracer(ctx).Tracer(), cp,
+		"issued_at", "expires_at", "request_url", "state", "user_code", "ui", "identity_id", "approved_at", "updated_at")
+}
+
+func (p *Persister) UpdatePendingCrossDeviceLoginFlow(ctx context.Context, f *crossdevice.Flow) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UpdatePendingCrossDeviceLoginFlow")
+	defer otelx.End(span, &err)
+
+	f.UpdatedAt = time.Now().UTC()
+	//#nosec G201 -- TableName is static
+	n, err := p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"UPDATE %s SET state = ?, ui = ?, identity_id = ?, approved_at = ?, updated_at = ? WHERE id = ? AND nid = ? AND state = ?",
+		new(crossdevice.Flow).TableName(ctx),
+	),
+		f.State,
+		f.UI,
+		f.IdentityID,
+		f.ApprovedAt,
+		f.UpdatedAt,
+		f.ID,
+		p.NetworkID(ctx),
+		crossdevice.StatePending,
+	).ExecWithCount()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	} else if n != 1 {
+		return errors.WithStack(sqlcon.ErrNoRows)
+	}
+	return nil
+}
+
+func (p *Persister) UseApprovedCrossDeviceLoginFlow(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UseApprovedCrossDeviceLoginFlow")
+	defer otelx.End(span, &err)
+
+	//#nosec G201 -- TableName is static
+	n, err := p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"UPDATE %s SET state = ?, updated_at = ? WHERE id = ? AND nid = ? AND state = ?",
+		new(crossdevice.Flow).TableName(ctx),
+	),
+		crossdevice.StateUsed,
+		time.Now().UTC(),
+		id,
+		p.NetworkID(ctx),
+		crossdevice.StateApproved,
+	).ExecWithCount()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	} else if n != 1 {
+		return errors.WithStack(sqlcon.ErrNoRows)
+	}
+	return nil
+}
+
+func (p *Persister) DeleteExpiredCrossDeviceLoginFlows(ctx context.Context, expiresAt time.Time, limit int) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.DeleteExpiredCrossDeviceLoginFlows")
+	defer otelx.End(span, &err)
+	//#nosec G201 -- TableName is static
+	err = p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"DELETE FROM %s WHERE id in (SELECT id FROM (SELECT id FROM %s c WHERE expires_at <= ? and nid = ? ORDER BY expires_at ASC LIMIT %d ) AS s )",
+		new(crossdevice.Flow).TableName(ctx),
+		new(crossdevice.Flow).TableName(ctx),
+		limit,
+	),
+		expiresAt,
+		p.NetworkID(ctx),
+	).Exec()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	}
+	return nil
+}
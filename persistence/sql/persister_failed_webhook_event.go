@@ -0,0 +1,75 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/pagination/keysetpagination"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/persistence/sql/update"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
+	"github.com/ory/kratos/x"
+)
+
+var _ deadletter.Persister = new(Persister)
+
+func (p *Persister) CreateFailedWebhookEvent(ctx context.Context, e *deadletter.Event) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateFailedWebhookEvent")
+	defer otelx.End(span, &err)
+
+	e.NID = p.NetworkID(ctx)
+	return sqlcon.HandleError(p.GetConnection(ctx).Create(e))
+}
+
+func (p *Persister) GetFailedWebhookEvent(ctx context.Context, id uuid.UUID) (_ *deadletter.Event, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.GetFailedWebhookEvent")
+	defer otelx.End(span, &err)
+
+	var e deadletter.Event
+	if err := p.GetConnection(ctx).Where("id = ? AND nid = ?", id, p.NetworkID(ctx)).First(&e); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &e, nil
+}
+
+func (p *Persister) ListFailedWebhookEvents(ctx context.Context, opts []keysetpagination.Option) (_ []deadletter.Event, _ *keysetpagination.Paginator, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.ListFailedWebhookEvents")
+	defer otelx.End(span, &err)
+
+	opts = append(opts, keysetpagination.WithDefaultToken(new(deadletter.Event).DefaultPageToken()))
+	opts = append(opts, keysetpagination.WithDefaultSize(100))
+	opts = append(opts, keysetpagination.WithColumn("created_at", "DESC"))
+	paginator := keysetpagination.GetPaginator(opts...)
+
+	if _, err := uuid.FromString(paginator.Token().Parse("id")["id"]); err != nil {
+		return nil, nil, errors.WithStack(x.PageTokenInvalid)
+	}
+
+	events := make([]deadletter.Event, paginator.Size())
+	if err := p.GetConnection(ctx).
+		Where("nid = ? AND replayed_at IS NULL", p.NetworkID(ctx)).
+		Scope(keysetpagination.Paginate[deadletter.Event](paginator)).
+		All(&events); err != nil {
+		return nil, nil, sqlcon.HandleError(err)
+	}
+
+	events, nextPage := keysetpagination.Result(events, paginator)
+	return events, nextPage, nil
+}
+
+func (p *Persister) UpdateFailedWebhookEvent(ctx context.Context, e *deadletter.Event) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UpdateFailedWebhookEvent")
+	defer otelx.End(span, &err)
+
+	cp := *e
+	cp.NID = p.NetworkID(ctx)
+	return update.Generic(ctx, p.GetConnection(ctx), p.r.Tracer(ctx).Tracer(), &cp)
+}
@@ -0,0 +1,102 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
+)
+
+var _ funnel.Persister = new(Persister)
+
+const flowFunnelPageSize = 1000
+
+func (p *Persister) CreateFlowFunnelEntry(ctx context.Context, e *funnel.Entry) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateFlowFunnelEntry")
+	defer otelx.End(span, &err)
+
+	e.NID = p.NetworkID(ctx)
+	return sqlcon.HandleError(p.GetConnection(ctx).Create(e))
+}
+
+func (p *Persister) MarkFlowFunnelEntryFailed(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.MarkFlowFunnelEntryFailed")
+	defer otelx.End(span, &err)
+
+	now := time.Now().UTC()
+	//#nosec G201 -- TableName is static
+	return sqlcon.HandleError(p.GetConnection(ctx).RawQuery(
+		"UPDATE "+new(funnel.Entry).TableName(ctx)+" SET failed_submissions = failed_submissions + 1, submitted_at = COALESCE(submitted_at, ?), updated_at = ? WHERE id = ? AND nid = ?",
+		now, now, id, p.NetworkID(ctx),
+	).Exec())
+}
+
+func (p *Persister) MarkFlowFunnelEntrySucceeded(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.MarkFlowFunnelEntrySucceeded")
+	defer otelx.End(span, &err)
+
+	now := time.Now().UTC()
+	//#nosec G201 -- TableName is static
+	return sqlcon.HandleError(p.GetConnection(ctx).RawQuery(
+		"UPDATE "+new(funnel.Entry).TableName(ctx)+" SET succeeded_at = COALESCE(succeeded_at, ?), submitted_at = COALESCE(submitted_at, ?), updated_at = ? WHERE id = ? AND nid = ?",
+		now, now, now, id, p.NetworkID(ctx),
+	).Exec())
+}
+
+func (p *Persister) ForEachFlowFunnelEntry(ctx context.Context, name flow.FlowName, from, to time.Time, fn func(*funnel.Entry) error) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.ForEachFlowFunnelEntry")
+	defer otelx.End(span, &err)
+
+	after, afterID := from, uuid.Nil
+	for {
+		var page []funnel.Entry
+		if err := p.GetConnection(ctx).
+			Where("nid = ? AND flow = ? AND created_at < ?", p.NetworkID(ctx), name, to).
+			Where("(created_at > ? OR (created_at = ? AND id > ?))", after, after, afterID).
+			Order("created_at ASC, id ASC").
+			Limit(flowFunnelPageSize).
+			All(&page); err != nil {
+			return sqlcon.HandleError(err)
+		}
+
+		for k := range page {
+			if err := fn(&page[k]); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < flowFunnelPageSize {
+			return nil
+		}
+		after, afterID = page[len(page)-1].CreatedAt, page[len(page)-1].ID
+	}
+}
+
+func (p *Persister) DeleteExpiredFlowFunnelEntries(ctx context.Context, expiresAt time.Time, limit int) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.DeleteExpiredFlowFunnelEntries")
+	defer otelx.End(span, &err)
+	//#nosec G201 -- TableName is static
+	err = p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"DELETE FROM %s WHERE id in (SELECT id FROM (SELECT id FROM %s c WHERE expires_at <= ? and nid = ? ORDER BY expires_at ASC LIMIT %d ) AS s )",
+		new(funnel.Entry).TableName(ctx),
+		new(funnel.Entry).TableName(ctx),
+		limit,
+	),
+		expiresAt,
+		p.NetworkID(ctx),
+	).Exec()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/pagination/keysetpagination"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/inspect"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/recovery"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/x"
+)
+
+var _ inspect.Persister = new(Persister)
+
+// maxInspectedFlowBatches limits the batches of rows which are scanned for errored flows per request.
+const maxInspectedFlowBatches = 10
+
+// inspectedFlowTables maps the flows to their table and the column holding the identity ID.
+var inspectedFlowTables = map[flow.FlowName]struct {
+	tableName      func(context.Context) string
+	identityColumn string
+}{
+	flow.LoginFlow:        {new(login.Flow).TableName, ""},
+	flow.RegistrationFlow: {new(registration.Flow).TableName, ""},
+	flow.SettingsFlow:     {new(settings.Flow).TableName, "identity_id"},
+	flow.RecoveryFlow:     {new(recovery.Flow).TableName, "recovered_identity_id"},
+	flow.VerificationFlow: {new(verification.Flow).TableName, "identity_id"},
+}
+
+func (p *Persister) ListSelfServiceFlows(ctx context.Context, filter inspect.Filter, opts []keysetpagination.Option) (_ []inspect.Summary, _ *keysetpagination.Paginator, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.ListSelfServiceFlows")
+	defer otelx.End(span, &err)
+
+	table, ok := inspectedFlowTables[filter.Flow]
+	if !ok {
+		return nil, nil, errors.Errorf("unable to list flows of type %q", filter.Flow)
+	}
+
+	opts = append(opts, keysetpagination.WithDefaultToken(new(inspect.Summary).DefaultPageToken()))
+	opts = append(opts, keysetpagination.WithDefaultSize(100))
+	paginator := keysetpagination.GetPaginator(opts...)
+
+	token := paginator.Token().Parse("id")
+	afterID, err := uuid.FromString(token["id"])
+	if err != nil {
+		return nil, nil, errors.WithStack(x.PageTokenInvalid)
+	}
+	after, err := time.Parse(time.RFC3339Nano, token["created_at"])
+	if err != nil {
+		return nil, nil, errors.WithStack(x.PageTokenInvalid)
+	}
+
+	identityColumn := "NULL"
+	if table.identityColumn != "" {
+		identityColumn = table.identityColumn
+	}
+
+	now := time.Now().UTC()
+	where := []string{"nid = ?"}
+	args := []any{p.NetworkID(ctx)}
+	if filter.State != "" {
+		where = append(where, "state = ?")
+		args = append(args, filter.State)
+	}
+	if filter.IdentityID.Valid {
+		if table.identityColumn == "" {
+			return nil, nil, errors.Errorf("flows of type %q do not belong to an identity", filter.Flow)
+		}
+		where = append(where, table.identityColumn+" = ?")
+		args = append(args, filter.IdentityID.UUID)
+	}
+	if filter.Expired != nil {
+		if *filter.Expired {
+			where = append(where, "expires_at <= ?")
+		} else {
+			where = append(where, "expires_at > ?")
+		}
+		args = append(args, now)
+	}
+
+	// Errored flows can only be detected by decoding their UI, so the rows are filtered in batches
+	// until the page is full. To bound the work of a single request, a page may contain fewer flows
+	// than requested if too many rows were scanned, in which case the next page continues after the
+	// last scanned row.
+	flows := []inspect.Summary{}
+	for batches := 1; len(flows) <= paginator.Size(); batches++ {
+		var batch []inspect.Summary
+		//#nosec G201 -- table and column names are static
+		if err := p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+			"SELECT id, type, state, active_method, %s AS identity_id, request_url, issued_at, expires_at, ui, created_at, updated_at FROM %s WHERE %s AND (created_at < ? OR (created_at = ? AND id > ?)) ORDER BY created_at DESC, id ASC LIMIT %d",
+			identityColumn,
+			table.tableName(ctx),
+			strings.Join(where, " AND "),
+			paginator.Size()+1,
+		), append(args, after, after, afterID)...).All(&batch); err != nil {
+			return nil, nil, sqlcon.HandleError(err)
+		}
+
+		for k := range batch {
+			batch[k].Hydrate(filter.Flow, now)
+			if !filter.Errored || len(batch[k].Errors) > 0 {
+				flows = append(flows, batch[k])
+			}
+		}
+
+		if len(batch) <= paginator.Size() {
+			break
+		}
+
+		last := batch[len(batch)-1]
+		if len(flows) <= paginator.Size() && batches >= maxInspectedFlowBatches {
+			return flows, keysetpagination.GetPaginator(append(opts, keysetpagination.WithToken(last.PageToken()))...), nil
+		}
+		after, afterID = last.CreatedAt, last.ID
+	}
+
+	flows, nextPage := keysetpagination.Result(flows, paginator)
+	return flows, nextPage, nil
+}
@@ -0,0 +1,205 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/continuity"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
+	"github.com/ory/kratos/selfservice/flow/funnel"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/recovery"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/selfservice/sessiontokenexchange"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+)
+
+// The names of the tables which are garbage collected, as used in `database.cleanup.retention`.
+const (
+	GarbageCollectSessions               = "sessions"
+	GarbageCollectContinuityContainers   = "continuity_containers"
+	GarbageCollectLoginFlows             = "login_flows"
+	GarbageCollectRegistrationFlows      = "registration_flows"
+	GarbageCollectRecoveryFlows          = "recovery_flows"
+	GarbageCollectSettingsFlows          = "settings_flows"
+	GarbageCollectVerificationFlows      = "verification_flows"
+	GarbageCollectCrossDeviceLoginFlows  = "cross_device_login_flows"
+	GarbageCollectSessionTokenExchangers = "session_token_exchangers"
+	GarbageCollectIssuedAdminAPITokens   = "issued_admin_api_tokens"
+	GarbageCollectFlowFunnelEntries      = "flow_funnel_entries"
+)
+
+type gcTable struct {
+	name   string
+	table  func(ctx context.Context) string
+	column string
+}
+
+var gcTables = []gcTable{
+	{GarbageCollectSessions, new(session.Session).TableName, "expires_at"},
+	{GarbageCollectContinuityContainers, new(continuity.Container).TableName, "expires_at"},
+	{GarbageCollectLoginFlows, new(login.Flow).TableName, "expires_at"},
+	{GarbageCollectRecoveryFlows, new(recovery.Flow).TableName, "expires_at"},
+	{GarbageCollectRegistrationFlows, new(registration.Flow).TableName, "expires_at"},
+	{GarbageCollectSettingsFlows, new(settings.Flow).TableName, "expires_at"},
+	{GarbageCollectVerificationFlows, new(verification.Flow).TableName, "expires_at"},
+	{GarbageCollectSessionTokenExchangers, func(context.Context) string { return new(sessiontokenexchange.Exchanger).TableName() }, "created_at"},
+	{GarbageCollectCrossDeviceLoginFlows, new(crossdevice.Flow).TableName, "expires_at"},
+	{GarbageCollectIssuedAdminAPITokens, new(x.IssuedAdminAPIToken).TableName, "expires_at"},
+	{GarbageCollectFlowFunnelEntries, new(funnel.Entry).TableName, "expires_at"},
+}
+
+// GarbageCollectedTables returns the names of all tables which are garbage collected.
+func GarbageCollectedTables() []string {
+	names := make([]string, len(gcTables))
+	for i, t := range gcTables {
+		names[i] = t.name
+	}
+	return names
+}
+
+var gcDeletedRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kratos_database_cleanup_deleted_rows_total",
+	Help: "Number of expired rows deleted by the database cleanup, labelled with the table.",
+}, []string{"table"})
+
+// CleanupCollectors returns the Prometheus collectors of the database cleanup.
+// They are registered by the registry's metrics setup.
+func CleanupCollectors() []prometheus.Collector {
+	return []prometheus.Collector{gcDeletedRows}
+}
+
+type gcOptions struct {
+	tables       []string
+	batchSize    int
+	sleepBatches time.Duration
+	sleepTables  time.Duration
+	keepLast     time.Duration
+}
+
+// CleanupDatabase deletes all expired rows. Each table is cleaned up in batches of batchSize rows
+// until no expired rows are left, waiting `database.cleanup.sleep.batches` between batches and
+// wait between tables. Rows are kept for keepLast after they expired, or longer if
+// `database.cleanup.retention` says so.
+func (p *Persister) CleanupDatabase(ctx context.Context, wait time.Duration, keepLast time.Duration, batchSize int) error {
+	return p.collectGarbage(ctx, gcOptions{
+		tables:       GarbageCollectedTables(),
+		batchSize:    batchSize,
+		sleepBatches: p.r.Config().DatabaseCleanupSleepBatches(ctx),
+		sleepTables:  wait,
+		keepLast:     keepLast,
+	})
+}
+
+// CollectGarbage deletes the expired rows of the given tables using the cleanup settings from
+// the configuration.
+func (p *Persister) CollectGarbage(ctx context.Context, tables ...string) error {
+	return p.collectGarbage(ctx, gcOptions{
+		tables:       tables,
+		batchSize:    p.r.Config().DatabaseCleanupBatchSize(ctx),
+		sleepBatches: p.r.Config().DatabaseCleanupSleepBatches(ctx),
+		sleepTables:  p.r.Config().DatabaseCleanupSleepTables(ctx),
+	})
+}
+
+func (p *Persister) collectGarbage(ctx context.Context, o gcOptions) error {
+	if o.batchSize < 1 {
+		return errors.Errorf("the cleanup batch size must be at least 1 but is %d", o.batchSize)
+	}
+
+	for i, name := range o.tables {
+		table, ok := findGCTable(name)
+		if !ok {
+			return errors.Errorf("the table %q can not be cleaned up", name)
+		}
+		if i > 0 {
+			if err := sleepContext(ctx, o.sleepTables); err != nil {
+				return err
+			}
+		}
+
+		retention := max(o.keepLast, p.r.Config().DatabaseCleanupRetention(ctx, table.name))
+		expiredBefore := time.Now().UTC().Add(-retention)
+
+		var total int64
+		for {
+			deleted, err := p.deleteExpiredBatch(ctx, table, expiredBefore, o.batchSize)
+			if err != nil {
+				return err
+			}
+			total += deleted
+			gcDeletedRows.WithLabelValues(table.name).Add(float64(deleted))
+			if deleted < int64(o.batchSize) {
+				break
+			}
+			if err := sleepContext(ctx, o.sleepBatches); err != nil {
+				return err
+			}
+		}
+
+		p.r.Logger().
+			WithField("table", table.name).
+			WithField("deleted_rows", total).
+			WithField("expired_before", expiredBefore).
+			Info("Cleaned up expired rows.")
+	}
+	return nil
+}
+
+func (p *Persister) deleteExpiredBatch(ctx context.Context, t gcTable, expiredBefore time.Time, limit int) (_ int64, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.deleteExpiredBatch")
+	defer otelx.End(span, &err)
+
+	conn := p.GetConnection(ctx)
+	table := conn.Dialect.Quote(t.table(ctx))
+	//#nosec G201 -- the table and column are static
+	count, err := conn.RawQuery(fmt.Sprintf(
+		"DELETE FROM %s WHERE id in (SELECT id FROM (SELECT id FROM %s c WHERE %s <= ? and nid = ? ORDER BY %s ASC LIMIT %d ) AS s )",
+		table,
+		table,
+		t.column,
+		t.column,
+		limit,
+	),
+		expiredBefore,
+		p.NetworkID(ctx),
+	).ExecWithCount()
+	if err != nil {
+		return 0, sqlcon.HandleError(err)
+	}
+	return int64(count), nil
+}
+
+func findGCTable(name string) (gcTable, bool) {
+	for _, t := range gcTables {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return gcTable{}, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
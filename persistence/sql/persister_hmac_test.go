@@ -63,6 +63,14 @@ func (l *logRegistryOnly) IdentityValidator() *identity.Validator {
 	panic("implement me")
 }
 
+func (l *logRegistryOnly) IdentityTraitsEncrypter() *identity.TraitsEncrypter {
+	panic("implement me")
+}
+
+func (l *logRegistryOnly) IdentityManager() *identity.Manager {
+	panic("implement me")
+}
+
 var _ persisterDependencies = &logRegistryOnly{}
 
 func TestPersisterHMAC(t *testing.T) {
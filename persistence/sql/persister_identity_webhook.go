@@ -0,0 +1,63 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/identity"
+)
+
+var _ identity.WebhookPersister = new(Persister)
+
+func (p *Persister) UpsertIdentityWebhook(ctx context.Context, w *identity.Webhook) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UpsertIdentityWebhook")
+	defer otelx.End(span, &err)
+
+	w.NID = p.NetworkID(ctx)
+	return p.Transaction(ctx, func(ctx context.Context, tx *pop.Connection) error {
+		if err := tx.Where("identity_id = ? AND nid = ?", w.IdentityID, w.NID).Delete(new(identity.Webhook)); err != nil {
+			return sqlcon.HandleError(err)
+		}
+
+		w.ID = uuid.Nil
+		return sqlcon.HandleError(tx.Create(w))
+	})
+}
+
+func (p *Persister) GetIdentityWebhook(ctx context.Context, identityID uuid.UUID) (_ *identity.Webhook, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.GetIdentityWebhook")
+	defer otelx.End(span, &err)
+
+	var w identity.Webhook
+	if err := p.GetConnection(ctx).Where("identity_id = ? AND nid = ?", identityID, p.NetworkID(ctx)).First(&w); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &w, nil
+}
+
+func (p *Persister) DeleteIdentityWebhook(ctx context.Context, identityID uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.DeleteIdentityWebhook")
+	defer otelx.End(span, &err)
+
+	count, err := p.GetConnection(ctx).RawQuery(
+		//#nosec G201 -- TableName is static
+		"DELETE FROM "+new(identity.Webhook).TableName(ctx)+" WHERE identity_id = ? AND nid = ?",
+		identityID, p.NetworkID(ctx),
+	).ExecWithCount()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	}
+	if count == 0 {
+		return sqlcon.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/x"
+)
+
+var _ x.IssuedAdminAPITokenPersister = new(Persister)
+
+func (p *Persister) CreateIssuedAdminAPIToken(ctx context.Context, t *x.IssuedAdminAPIToken) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateIssuedAdminAPIToken")
+	defer otelx.End(span, &err)
+
+	t.NID = p.NetworkID(ctx)
+	return sqlcon.HandleError(p.GetConnection(ctx).Create(t))
+}
+
+func (p *Persister) UseIssuedAdminAPIToken(ctx context.Context, tokenHash string) (_ *x.IssuedAdminAPIToken, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UseIssuedAdminAPIToken")
+	defer otelx.End(span, &err)
+
+	now := time.Now().UTC()
+
+	// The token is marked as used in a single conditional update, so that concurrent requests can
+	// not both use it.
+	//#nosec G201 -- TableName is static
+	count, err := p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"UPDATE %s SET used_at = ?, updated_at = ? WHERE token_hash = ? AND nid = ? AND expires_at > ? AND used_at IS NULL",
+		new(x.IssuedAdminAPIToken).TableName(ctx),
+	), now, now, tokenHash, p.NetworkID(ctx), now).ExecWithCount()
+	if err != nil {
+		return nil, sqlcon.HandleError(err)
+	} else if count == 0 {
+		return nil, errors.WithStack(sqlcon.ErrNoRows)
+	}
+
+	var t x.IssuedAdminAPIToken
+	if err := p.GetConnection(ctx).
+		Where("token_hash = ? AND nid = ?", tokenHash, p.NetworkID(ctx)).
+		First(&t); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &t, nil
+}
+
+func (p *Persister) DeleteExpiredIssuedAdminAPITokens(ctx context.Context, expiresAt time.Time, limit int) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.DeleteExpiredIssuedAdminAPITokens")
+	defer otelx.End(span, &err)
+	//#nosec G201 -- TableName is static
+	err = p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"DELETE FROM %s WHERE id in (SELECT id FROM (SELECT id FROM %s c WHERE expires_at <= ? and nid = ? ORDER BY expires_at ASC LIMIT %d ) AS s )",
+		new(x.IssuedAdminAPIToken).TableName(ctx),
+		new(x.IssuedAdminAPIToken).TableName(ctx),
+		limit,
+	),
+		expiresAt,
+		p.NetworkID(ctx),
+	).Exec()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/jobs"
+)
+
+var _ jobs.LeasePersister = new(Persister)
+
+func (p *Persister) AcquireJobLease(ctx context.Context, name string, holder uuid.UUID, ttl time.Duration) (_ bool, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.AcquireJobLease")
+	defer otelx.End(span, &err)
+
+	now := time.Now().UTC()
+	//#nosec G201 -- TableName is static
+	count, err := p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"UPDATE %s SET holder_id = ?, expires_at = ?, updated_at = ? WHERE nid = ? AND name = ? AND (holder_id = ? OR expires_at <= ?)",
+		new(jobs.Lease).TableName(ctx),
+	),
+		holder,
+		now.Add(ttl),
+		now,
+		p.NetworkID(ctx),
+		name,
+		holder,
+		now,
+	).ExecWithCount()
+	if err != nil {
+		return false, sqlcon.HandleError(err)
+	} else if count > 0 {
+		return true, nil
+	}
+
+	err = sqlcon.HandleError(p.GetConnection(ctx).Create(&jobs.Lease{
+		NID:       p.NetworkID(ctx),
+		Name:      name,
+		HolderID:  holder,
+		ExpiresAt: now.Add(ttl),
+	}))
+	if err == nil {
+		return true, nil
+	} else if !errors.Is(err, sqlcon.ErrUniqueViolation) {
+		return false, err
+	}
+
+	// MySQL reports no affected rows if the update did not change the row, which happens if the
+	// holder renews the lease within the precision of the timestamps.
+	var lease jobs.Lease
+	if err := p.GetConnection(ctx).
+		Where("nid = ? AND name = ?", p.NetworkID(ctx), name).
+		First(&lease); err != nil {
+		return false, sqlcon.HandleError(err)
+	}
+	return lease.HolderID == holder && lease.ExpiresAt.After(now), nil
+}
+
+func (p *Persister) ReleaseJobLease(ctx context.Context, name string, holder uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.ReleaseJobLease")
+	defer otelx.End(span, &err)
+
+	//#nosec G201 -- TableName is static
+	return sqlcon.HandleError(p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"DELETE FROM %s WHERE nid = ? AND name = ? AND holder_id = ?",
+		new(jobs.Lease).TableName(ctx),
+	),
+		p.NetworkID(ctx),
+		name,
+		holder,
+	).Exec())
+}
@@ -5,12 +5,12 @@ package sql
 
 import (
 	"context"
-	"time"
 
 	"github.com/gofrs/uuid"
 
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/kratos/x"
 	"github.com/ory/x/otelx"
 	"github.com/ory/x/sqlcon"
 )
@@ -19,7 +19,7 @@ func (p *Persister) CreateLoginCode(ctx context.Context, params *code.CreateLogi
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateLoginCode")
 	defer otelx.End(span, &err)
 
-	now := time.Now().UTC()
+	now := x.Now().UTC()
 	loginCode := &code.LoginCode{
 		IdentityID:  params.IdentityID,
 		Address:     params.Address,
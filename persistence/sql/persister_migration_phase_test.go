@@ -0,0 +1,88 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	confighelpers "github.com/ory/kratos/driver/config/testhelpers"
+	"github.com/ory/kratos/persistence"
+	"github.com/ory/x/configx"
+	"github.com/ory/x/contextx"
+	"github.com/ory/x/logrusx"
+	"github.com/ory/x/popx"
+)
+
+func TestPersisterMigrationPhases(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	opts := []configx.OptionModifier{configx.SkipValidation()}
+	conf := config.MustNew(t, logrusx.New("", ""), os.Stderr, &confighelpers.TestConfigProvider{Contextualizer: &contextx.Default{}, Options: opts}, opts...)
+	c, err := pop.NewConnection(&pop.ConnectionDetails{URL: "sqlite://" + filepath.Join(t.TempDir(), "db.sqlite") + "?_fk=true"})
+	require.NoError(t, err)
+	require.NoError(t, c.Open())
+
+	p, err := NewPersister(ctx, &logRegistryOnly{c: conf}, c, WithDisabledLogging(true), WithExtraMigrations(fstest.MapFS{
+		"99990000000000000001_phase_test.up.sql":           {Data: []byte("CREATE TABLE phase_test (id INTEGER, legacy TEXT);")},
+		"99990000000000000001_phase_test.down.sql":         {Data: []byte("DROP TABLE phase_test;")},
+		"99990000000000000002_phase_test.up.sql":           {Data: []byte(persistence.MigrationPhaseContractMarker + "\nCREATE TABLE phase_test_contract (id INTEGER);")},
+		"99990000000000000002_phase_test.down.sql":         {Data: []byte("DROP TABLE phase_test_contract;")},
+		"99990000000000000003_phase_test.up.sql":           {Data: []byte("CREATE TABLE phase_test_expand (id INTEGER);")},
+		"99990000000000000003_phase_test.down.sql":         {Data: []byte("DROP TABLE phase_test_expand;")},
+		"99990000000000000004_phase_test.mysql.up.sql":     {Data: []byte(persistence.MigrationPhaseContractMarker + "\nALTER TABLE phase_test DROP COLUMN legacy;")},
+		"99990000000000000004_phase_test.mysql.down.sql":   {Data: []byte("ALTER TABLE phase_test ADD COLUMN legacy TEXT;")},
+		"99990000000000000004_phase_test.sqlite3.up.sql":   {Data: []byte("CREATE TABLE phase_test_sqlite (id INTEGER);")},
+		"99990000000000000004_phase_test.sqlite3.down.sql": {Data: []byte("DROP TABLE phase_test_sqlite;")},
+	}))
+	require.NoError(t, err)
+
+	states := func(t *testing.T) map[string]string {
+		status, err := p.MigrationPhaseStatus(ctx)
+		require.NoError(t, err)
+		result := make(map[string]string)
+		for _, s := range status {
+			if s.Name == "phase_test" {
+				result[s.Version] = string(s.Phase) + " " + s.State
+			}
+		}
+		return result
+	}
+
+	status, err := p.MigrationPhaseStatus(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.HasPending(persistence.MigrationPhaseExpand))
+	assert.True(t, status.HasPending(persistence.MigrationPhaseContract))
+
+	require.NoError(t, p.MigrateUpPhase(ctx, persistence.MigrationPhaseExpand))
+	assert.Equal(t, map[string]string{
+		"99990000000000000001": "expand " + popx.Applied,
+		"99990000000000000002": "contract " + popx.Pending,
+		"99990000000000000003": "expand " + popx.Applied,
+		// The MySQL variant marks the whole version as a contract migration.
+		"99990000000000000004": "contract " + popx.Pending,
+	}, states(t))
+
+	status, err = p.MigrationPhaseStatus(ctx)
+	require.NoError(t, err)
+	assert.False(t, status.HasPending(persistence.MigrationPhaseExpand))
+	assert.True(t, status.HasPending(persistence.MigrationPhaseContract))
+
+	require.NoError(t, p.MigrateUpPhase(ctx, persistence.MigrationPhaseContract))
+	assert.Equal(t, map[string]string{
+		"99990000000000000001": "expand " + popx.Applied,
+		"99990000000000000002": "contract " + popx.Applied,
+		"99990000000000000003": "expand " + popx.Applied,
+		"99990000000000000004": "contract " + popx.Applied,
+	}, states(t))
+}
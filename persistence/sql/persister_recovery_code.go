@@ -5,7 +5,6 @@ package sql
 
 import (
 	"context"
-	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
@@ -13,6 +12,7 @@ import (
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/flow/recovery"
 	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/kratos/x"
 	"github.com/ory/x/otelx"
 	"github.com/ory/x/sqlcon"
 )
@@ -21,7 +21,7 @@ func (p *Persister) CreateRecoveryCode(ctx context.Context, params *code.CreateR
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateRecoveryCode")
 	defer otelx.End(span, &err)
 
-	now := time.Now()
+	now := x.Now()
 	recoveryCode := &code.RecoveryCode{
 		ID:         uuid.Nil,
 		CodeHMAC:   p.hmacValue(ctx, params.RawCode),
@@ -5,7 +5,6 @@ package sql
 
 import (
 	"context"
-	"time"
 
 	"github.com/go-faker/faker/v4/pkg/slice"
 	"github.com/gofrs/uuid"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/kratos/x"
 	"github.com/ory/x/otelx"
 	"github.com/ory/x/sqlcon"
 )
@@ -21,7 +21,7 @@ func (p *Persister) CreateRegistrationCode(ctx context.Context, params *code.Cre
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateRegistrationCode")
 	defer otelx.End(span, &err)
 
-	now := time.Now().UTC()
+	now := x.Now().UTC()
 	registrationCode := &code.RegistrationCode{
 		Address:     params.Address,
 		AddressType: params.AddressType,
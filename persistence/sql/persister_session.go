@@ -6,6 +6,7 @@ package sql
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ory/herodot"
@@ -28,7 +29,10 @@ import (
 	"github.com/ory/x/stringsx"
 )
 
-var _ session.Persister = new(Persister)
+var (
+	_ session.Persister                  = new(Persister)
+	_ identity.CredentialsUsagePersister = new(Persister)
+)
 
 const (
 	SessionDeviceUserAgentMaxLength = 512
@@ -90,9 +94,9 @@ func (p *Persister) ListSessions(ctx context.Context, active *bool, paginatorOpt
 		q := c.Where("nid = ?", nid)
 		if active != nil {
 			if *active {
-				q.Where("active = ? AND expires_at >= ?", *active, time.Now().UTC())
+				q.Where("active = ? AND expires_at >= ?", *active, x.Now().UTC())
 			} else {
-				q.Where("(active = ? OR expires_at < ?)", *active, time.Now().UTC())
+				q.Where("(active = ? OR expires_at < ?)", *active, x.Now().UTC())
 			}
 		}
 
@@ -114,6 +118,9 @@ func (p *Persister) ListSessions(ctx context.Context, active *bool, paginatorOpt
 		if s[k].Identity == nil {
 			continue
 		}
+		if err := p.r.IdentityTraitsEncrypter().DecryptTraits(ctx, s[k].Identity); err != nil {
+			return nil, nil, err
+		}
 		if err := p.InjectTraitsSchemaURL(ctx, s[k].Identity); err != nil {
 			return nil, nil, err
 		}
@@ -146,9 +153,9 @@ func (p *Persister) ListSessionsByIdentity(
 		}
 		if active != nil {
 			if *active {
-				q.Where("active = ? AND expires_at >= ?", *active, time.Now().UTC())
+				q.Where("active = ? AND expires_at >= ?", *active, x.Now().UTC())
 			} else {
-				q.Where("(active = ? OR expires_at < ?)", *active, time.Now().UTC())
+				q.Where("(active = ? OR expires_at < ?)", *active, x.Now().UTC())
 			}
 		}
 
@@ -174,6 +181,15 @@ func (p *Persister) ListSessionsByIdentity(
 		return nil, 0, err
 	}
 
+	for k := range s {
+		if s[k].Identity == nil {
+			continue
+		}
+		if err := p.r.IdentityTraitsEncrypter().DecryptTraits(ctx, s[k].Identity); err != nil {
+			return nil, 0, err
+		}
+	}
+
 	return s, t, nil
 }
 
@@ -232,6 +248,23 @@ func (p *Persister) ExtendSession(ctx context.Context, sessionID uuid.UUID) (err
 	return nil
 }
 
+// UpdateSessionActivity records when a session was last used. It does not change updated_at as
+// the session itself is not changed.
+func (p *Persister) UpdateSessionActivity(ctx context.Context, sessionID uuid.UUID, at time.Time) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UpdateSessionActivity")
+	defer otelx.End(span, &err)
+
+	//#nosec G201 -- TableName is static
+	return sqlcon.HandleError(p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"UPDATE %s SET last_active_at = ? WHERE id = ? AND nid = ?",
+		new(session.Session).TableName(ctx),
+	),
+		at.UTC(),
+		sessionID,
+		p.NetworkID(ctx),
+	).Exec())
+}
+
 // UpsertSession creates a session if not found else updates.
 // This operation also inserts Session device records when a session is being created.
 // The update operation skips updating Session device records since only one record would need to be updated in this case.
@@ -241,11 +274,15 @@ func (p *Persister) UpsertSession(ctx context.Context, s *session.Session) (err
 
 	s.NID = p.NetworkID(ctx)
 
-	var updated bool
+	var updated, reactivated bool
 	defer func() {
 		if err != nil {
 			return
 		}
+		if reactivated {
+			p.r.IdentityManager().StateChanged(ctx, s.Identity, s.ReactivatedIdentityFrom)
+			s.ReactivatedIdentityFrom = ""
+		}
 		if updated {
 			trace.SpanFromContext(ctx).AddEvent(events.NewSessionChanged(ctx, string(s.AuthenticatorAssuranceLevel), s.ID, s.IdentityID))
 		} else {
@@ -255,6 +292,10 @@ func (p *Persister) UpsertSession(ctx context.Context, s *session.Session) (err
 
 	return errors.WithStack(p.Transaction(ctx, func(ctx context.Context, tx *pop.Connection) (err error) {
 		updated = false
+		if reactivated, err = p.reactivateIdentity(ctx, tx, s); err != nil {
+			return err
+		}
+
 		exists := false
 		if !s.ID.IsNil() {
 			exists, err = tx.Where("id = ? AND nid = ?", s.ID, s.NID).Exists(new(session.Session))
@@ -270,7 +311,7 @@ func (p *Persister) UpsertSession(ctx context.Context, s *session.Session) (err
 				return sqlcon.HandleError(err)
 			}
 			updated = true
-			return nil
+			return p.recordIdentityAuthentication(ctx, tx, s)
 		}
 
 		// This must not be eager or identities will be created / updated
@@ -278,6 +319,10 @@ func (p *Persister) UpsertSession(ctx context.Context, s *session.Session) (err
 			return err
 		}
 
+		if err := p.recordIdentityAuthentication(ctx, tx, s); err != nil {
+			return err
+		}
+
 		for i := range s.Devices {
 			device := &(s.Devices[i])
 			device.SessionID = s.ID
@@ -299,6 +344,51 @@ func (p *Persister) UpsertSession(ctx context.Context, s *session.Session) (err
 	}))
 }
 
+// reactivateIdentity persists the reactivation of the session's identity, if the session
+// reactivated it. It returns false if the identity changed its state in the meantime.
+func (p *Persister) reactivateIdentity(ctx context.Context, tx *pop.Connection, s *session.Session) (bool, error) {
+	if s.ReactivatedIdentityFrom == "" || s.Identity == nil {
+		return false, nil
+	}
+
+	//#nosec G201 -- TableName is static
+	n, err := tx.RawQuery(fmt.Sprintf(
+		"UPDATE %s SET state = ?, state_changed_at = ?, state_reason = ? WHERE id = ? AND nid = ? AND state = ?",
+		new(identity.Identity).TableName(ctx),
+	),
+		s.Identity.State,
+		s.Identity.StateChangedAt,
+		s.Identity.StateReason,
+		s.Identity.ID,
+		p.NetworkID(ctx),
+		s.ReactivatedIdentityFrom,
+	).ExecWithCount()
+	if err != nil {
+		return false, sqlcon.HandleError(err)
+	}
+	return n == 1, nil
+}
+
+// recordIdentityAuthentication keeps track of the last time the identity signed in, which is used to
+// find inactive identities. Sessions can not be used for this as they are deleted once expired.
+func (p *Persister) recordIdentityAuthentication(ctx context.Context, tx *pop.Connection, s *session.Session) error {
+	if s.IdentityID == uuid.Nil || s.AuthenticatedAt.IsZero() {
+		return nil
+	}
+
+	at := s.AuthenticatedAt.UTC()
+	//#nosec G201 -- TableName is static
+	return sqlcon.HandleError(tx.RawQuery(fmt.Sprintf(
+		"UPDATE %s SET last_authenticated_at = ? WHERE id = ? AND nid = ? AND (last_authenticated_at IS NULL OR last_authenticated_at < ?)",
+		new(identity.Identity).TableName(ctx),
+	),
+		at,
+		s.IdentityID,
+		p.NetworkID(ctx),
+		at,
+	).Exec())
+}
+
 func (p *Persister) DeleteSession(ctx context.Context, sid uuid.UUID) (err error) {
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.DeleteSession")
 	defer otelx.End(span, &err)
@@ -339,6 +429,37 @@ func (p *Persister) DeleteSessionsByIdentity(ctx context.Context, identityID uui
 	return nil
 }
 
+// GetCredentialsLastUsed derives when each credentials type was last used from the authentication
+// methods of the identity's sessions.
+func (p *Persister) GetCredentialsLastUsed(ctx context.Context, identityID uuid.UUID) (_ map[identity.CredentialsType]time.Time, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.GetCredentialsLastUsed")
+	defer otelx.End(span, &err)
+
+	var rows []struct {
+		AMR session.AuthenticationMethods `db:"authentication_methods"`
+	}
+	//#nosec G201 -- TableName is static
+	if err := p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"SELECT authentication_methods FROM %s WHERE identity_id = ? AND nid = ?",
+		new(session.Session).TableName(ctx),
+	),
+		identityID,
+		p.NetworkID(ctx),
+	).All(&rows); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	lastUsed := make(map[identity.CredentialsType]time.Time)
+	for _, row := range rows {
+		for _, m := range row.AMR {
+			if m.CompletedAt.After(lastUsed[m.Method]) {
+				lastUsed[m.Method] = m.CompletedAt
+			}
+		}
+	}
+	return lastUsed, nil
+}
+
 func (p *Persister) GetSessionByToken(ctx context.Context, token string, expand session.Expandables, identityExpand identity.Expandables) (res *session.Session, err error) {
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.GetSessionByToken")
 	defer otelx.End(span, &err)
@@ -489,6 +610,51 @@ func (p *Persister) RevokeSessionsIdentityExcept(ctx context.Context, iID, sID u
 	return count, nil
 }
 
+// RevokeExcessSessions marks all but the keep most recent active sessions of an identity inactive.
+func (p *Persister) RevokeExcessSessions(ctx context.Context, iID, except uuid.UUID, keep int, byLastUse bool) (res int, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.RevokeExcessSessions")
+	defer otelx.End(span, &err)
+
+	order := "created_at DESC, id DESC"
+	if byLastUse {
+		order = "updated_at DESC, id DESC"
+	}
+
+	nid := p.NetworkID(ctx)
+	if err := p.Transaction(ctx, func(ctx context.Context, tx *pop.Connection) error {
+		var active []session.Session
+		if err := tx.Select("id").
+			Where("identity_id = ? AND id != ? AND nid = ? AND active = ? AND expires_at >= ?", iID, except, nid, true, x.Now().UTC()).
+			Order(order).
+			All(&active); err != nil {
+			return sqlcon.HandleError(err)
+		}
+		if len(active) <= keep {
+			return nil
+		}
+
+		ids := make([]any, 0, len(active)-keep)
+		for _, s := range active[max(keep, 0):] {
+			ids = append(ids, s.ID)
+		}
+
+		//#nosec G201 -- TableName is static
+		count, err := tx.RawQuery(fmt.Sprintf(
+			"UPDATE %s SET active = false WHERE nid = ? AND id IN (%s)",
+			new(session.Session).TableName(ctx),
+			strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "),
+		), append([]any{nid}, ids...)...).ExecWithCount()
+		if err != nil {
+			return sqlcon.HandleError(err)
+		}
+		res = count
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
 func (p *Persister) DeleteExpiredSessions(ctx context.Context, expiresAt time.Time, limit int) (err error) {
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.DeleteExpiredSessions")
 	defer otelx.End(span, &err)
@@ -506,5 +672,40 @@ func (p *Persister) DeleteExpiredSessions(ctx context.Context, expiresAt time.Ti
 	if err != nil {
 		return sqlcon.HandleError(err)
 	}
+
+	//#nosec G201 -- TableName is static
+	err = p.GetConnection(ctx).RawQuery(fmt.Sprintf(
+		"DELETE FROM %s WHERE expires_at <= ? AND nid = ?",
+		new(session.UsedRevokeLink).TableName(ctx),
+	),
+		expiresAt,
+		p.NetworkID(ctx),
+	).Exec()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	}
 	return nil
 }
+
+func (p *Persister) UseRevokeLink(ctx context.Context, linkID, iID uuid.UUID, expiresAt time.Time) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UseRevokeLink")
+	defer otelx.End(span, &err)
+
+	return sqlcon.HandleError(p.GetConnection(ctx).Create(&session.UsedRevokeLink{
+		ID:         linkID,
+		NID:        p.NetworkID(ctx),
+		IdentityID: iID,
+		ExpiresAt:  expiresAt.UTC(),
+	}))
+}
+
+func (p *Persister) IsRevokeLinkUsed(ctx context.Context, linkID uuid.UUID) (_ bool, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.IsRevokeLinkUsed")
+	defer otelx.End(span, &err)
+
+	used, err := p.GetConnection(ctx).Where("id = ? AND nid = ?", linkID, p.NetworkID(ctx)).Exists(new(session.UsedRevokeLink))
+	if err != nil {
+		return false, sqlcon.HandleError(err)
+	}
+	return used, nil
+}
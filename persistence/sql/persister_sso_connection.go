@@ -0,0 +1,89 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/persistence/sql/update"
+	"github.com/ory/kratos/selfservice/sso"
+)
+
+var _ sso.Persister = new(Persister)
+
+func (p *Persister) CreateSSOConnection(ctx context.Context, c *sso.Connection) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateSSOConnection")
+	defer otelx.End(span, &err)
+
+	c.NID = p.NetworkID(ctx)
+	return sqlcon.HandleError(p.GetConnection(ctx).Create(c))
+}
+
+func (p *Persister) UpdateSSOConnection(ctx context.Context, c *sso.Connection) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.UpdateSSOConnection")
+	defer otelx.End(span, &err)
+
+	cp := *c
+	cp.NID = p.NetworkID(ctx)
+	return update.Generic(ctx, p.GetConnection(ctx), p.r.Tracer(ctx).Tracer(), cp)
+}
+
+func (p *Persister) GetSSOConnection(ctx context.Context, organizationID, id uuid.UUID) (_ *sso.Connection, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.GetSSOConnection")
+	defer otelx.End(span, &err)
+
+	var c sso.Connection
+	if err := p.GetConnection(ctx).Where("id = ? AND organization_id = ? AND nid = ?", id, organizationID, p.NetworkID(ctx)).First(&c); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &c, nil
+}
+
+func (p *Persister) FindSSOConnection(ctx context.Context, id uuid.UUID) (_ *sso.Connection, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.FindSSOConnection")
+	defer otelx.End(span, &err)
+
+	var c sso.Connection
+	if err := p.GetConnection(ctx).Where("id = ? AND nid = ?", id, p.NetworkID(ctx)).First(&c); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return &c, nil
+}
+
+func (p *Persister) ListSSOConnections(ctx context.Context, organizationID uuid.UUID) (_ []sso.Connection, err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.ListSSOConnections")
+	defer otelx.End(span, &err)
+
+	cs := make([]sso.Connection, 0)
+	if err := p.GetConnection(ctx).Where("organization_id = ? AND nid = ?", organizationID, p.NetworkID(ctx)).Order("created_at ASC").All(&cs); err != nil {
+		return nil, sqlcon.HandleError(err)
+	}
+
+	return cs, nil
+}
+
+func (p *Persister) DeleteSSOConnection(ctx context.Context, organizationID, id uuid.UUID) (err error) {
+	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.DeleteSSOConnection")
+	defer otelx.End(span, &err)
+
+	count, err := p.GetConnection(ctx).RawQuery(
+		//#nosec G201 -- TableName is static
+		"DELETE FROM "+new(sso.Connection).TableName(ctx)+" WHERE id = ? AND organization_id = ? AND nid = ?",
+		id, organizationID, p.NetworkID(ctx),
+	).ExecWithCount()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	}
+	if count == 0 {
+		return sqlcon.ErrNoRows
+	}
+	return nil
+}
@@ -33,6 +33,7 @@ import (
 	sqltesthelpers "github.com/ory/kratos/persistence/sql/testhelpers"
 	"github.com/ory/kratos/schema"
 	errorx "github.com/ory/kratos/selfservice/errorx/test"
+	crossdevice "github.com/ory/kratos/selfservice/flow/crossdevice/test"
 	lf "github.com/ory/kratos/selfservice/flow/login"
 	login "github.com/ory/kratos/selfservice/flow/login/test"
 	recovery "github.com/ory/kratos/selfservice/flow/recovery/test"
@@ -228,6 +229,10 @@ func TestPersister(t *testing.T) {
 				t.Parallel()
 				login.TestFlowPersister(ctx, p)(t)
 			})
+			t.Run("contract=crossdevice.TestFlowPersister", func(t *testing.T) {
+				t.Parallel()
+				crossdevice.TestFlowPersister(ctx, p)(t)
+			})
 			t.Run("contract=settings.TestFlowPersister", func(t *testing.T) {
 				t.Parallel()
 				settings.TestFlowPersister(ctx, p)(t)
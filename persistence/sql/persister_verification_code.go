@@ -5,7 +5,6 @@ package sql
 
 import (
 	"context"
-	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
@@ -14,6 +13,7 @@ import (
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/flow/verification"
 	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/kratos/x"
 	"github.com/ory/x/otelx"
 	"github.com/ory/x/sqlcon"
 )
@@ -22,7 +22,7 @@ func (p *Persister) CreateVerificationCode(ctx context.Context, params *code.Cre
 	ctx, span := p.r.Tracer(ctx).Tracer().Start(ctx, "persistence.sql.CreateVerificationCode")
 	defer otelx.End(span, &err)
 
-	now := time.Now().UTC()
+	now := x.Now().UTC()
 	verificationCode := &code.VerificationCode{
 		ID:        uuid.Nil,
 		CodeHMAC:  p.hmacValue(ctx, params.RawCode),
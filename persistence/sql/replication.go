@@ -0,0 +1,79 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/dbal"
+)
+
+// ReplicationLag returns how far the database lags behind its primary. It is zero if the
+// database is not a replica or if the database does not report replication lag.
+func (p *Persister) ReplicationLag(ctx context.Context) (time.Duration, error) {
+	db := p.c.Store.SQLDB()
+	switch p.c.Dialect.Name() {
+	case dbal.DriverPostgreSQL:
+		var seconds float64
+		if err := db.QueryRowContext(ctx,
+			"SELECT CASE WHEN pg_is_in_recovery() THEN COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) ELSE 0 END",
+		).Scan(&seconds); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	case dbal.DriverMySQL:
+		return mysqlReplicationLag(ctx, db)
+	}
+	return 0, nil
+}
+
+// mysqlReplicationLag reads the replication lag from the replica status, which is empty on
+// databases which are not replicas.
+func mysqlReplicationLag(ctx context.Context, db *sql.DB) (_ time.Duration, err error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = errors.WithStack(closeErr)
+		}
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if !rows.Next() {
+		return 0, errors.WithStack(rows.Err())
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for k := range values {
+		dest[k] = &values[k]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	for k, column := range columns {
+		if column != "Seconds_Behind_Source" && column != "Seconds_Behind_Master" {
+			continue
+		}
+		if !values[k].Valid {
+			return 0, errors.New("replication is not running")
+		}
+		seconds, err := time.ParseDuration(values[k].String + "s")
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		return seconds, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,53 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+)
+
+type (
+	// SQLiteCheckpoint is the result of a checkpoint of the SQLite write-ahead log.
+	SQLiteCheckpoint struct {
+		// Busy is true if the checkpoint could not complete because of concurrent readers or
+		// writers.
+		Busy bool
+		// LogFrames is the number of frames in the write-ahead log.
+		LogFrames int
+		// CheckpointedFrames is the number of frames which were written back to the database.
+		CheckpointedFrames int
+	}
+
+	// SQLiteCheckpointHook is notified around checkpoints of the write-ahead log, for example to
+	// let a replication tool like Litestream sync the log before it is truncated.
+	SQLiteCheckpointHook interface {
+		// BeforeSQLiteCheckpoint is called before the checkpoint. The checkpoint is skipped if
+		// it returns an error.
+		BeforeSQLiteCheckpoint(ctx context.Context) error
+		AfterSQLiteCheckpoint(ctx context.Context, checkpoint *SQLiteCheckpoint) error
+	}
+)
+
+// CheckpointSQLite checkpoints the write-ahead log of the SQLite database. The mode is one of
+// passive, full, restart and truncate.
+func CheckpointSQLite(ctx context.Context, c *pop.Connection, mode string) (*SQLiteCheckpoint, error) {
+	switch mode {
+	case "passive", "full", "restart", "truncate":
+	default:
+		return nil, errors.Errorf("unsupported checkpoint mode %q", mode)
+	}
+
+	var busy int
+	var checkpoint SQLiteCheckpoint
+	if err := c.Store.SQLDB().QueryRowContext(ctx, "PRAGMA wal_checkpoint("+strings.ToUpper(mode)+")").
+		Scan(&busy, &checkpoint.LogFrames, &checkpoint.CheckpointedFrames); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	checkpoint.Busy = busy != 0
+	return &checkpoint, nil
+}
@@ -230,6 +230,10 @@ func (b *Builder) BuildRequest(ctx context.Context, body interface{}) (*retryabl
 		}
 	}
 
+	if err := b.Config.Signing.sign(b.r, time.Now()); err != nil {
+		return nil, err
+	}
+
 	return b.r, nil
 }
 
@@ -23,6 +23,7 @@ type (
 		Header      http.Header     `json:"-"`
 		RawHeader   json.RawMessage `json:"headers"`
 		Auth        Auth            `json:"auth"`
+		Signing     Signing         `json:"signing"`
 	}
 )
 
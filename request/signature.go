@@ -0,0 +1,117 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package request
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/x"
+)
+
+// The signature headers follow the Standard Webhooks specification (https://www.standardwebhooks.com/),
+// so that receivers can use any of its libraries to verify requests.
+const (
+	HeaderSignatureID        = "Webhook-Id"
+	HeaderSignatureTimestamp = "Webhook-Timestamp"
+	HeaderSignature          = "Webhook-Signature"
+
+	SigningAlgorithmHMACSHA256 = "hmac_sha256"
+	SigningAlgorithmEd25519    = "ed25519"
+)
+
+type (
+	SigningKey struct {
+		Algorithm  string `json:"algorithm"`
+		Secret     string `json:"secret"`
+		PrivateKey string `json:"private_key"`
+	}
+
+	Signing struct {
+		// Keys are all used to sign every request. To rotate keys, add the new key, update
+		// the receivers, and then remove the old key.
+		Keys []SigningKey `json:"keys"`
+	}
+)
+
+// sign adds the signature headers to the request. The signed content is the message ID, the
+// timestamp and the body, separated by dots.
+func (s *Signing) sign(r *retryablehttp.Request, now time.Time) error {
+	if len(s.Keys) == 0 {
+		return nil
+	}
+
+	body, err := r.BodyBytes()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	id := "msg_" + x.NewUUID().String()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	content := []byte(id + "." + timestamp + "." + string(body))
+
+	signatures := make([]string, 0, len(s.Keys))
+	for k, key := range s.Keys {
+		signature, err := key.sign(content)
+		if err != nil {
+			return errors.Wrapf(err, "unable to sign request with key %d", k)
+		}
+		signatures = append(signatures, signature)
+	}
+
+	r.Header.Set(HeaderSignatureID, id)
+	r.Header.Set(HeaderSignatureTimestamp, timestamp)
+	r.Header.Set(HeaderSignature, strings.Join(signatures, " "))
+	return nil
+}
+
+func (k *SigningKey) sign(content []byte) (string, error) {
+	switch k.Algorithm {
+	case SigningAlgorithmHMACSHA256, "":
+		if k.Secret == "" {
+			return "", errors.New("the HMAC signing key has no secret")
+		}
+		mac := hmac.New(sha256.New, []byte(k.Secret))
+		_, _ = mac.Write(content)
+		return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+	case SigningAlgorithmEd25519:
+		key, err := parseEd25519PrivateKey(k.PrivateKey)
+		if err != nil {
+			return "", err
+		}
+		return "v1a," + base64.StdEncoding.EncodeToString(ed25519.Sign(key, content)), nil
+	}
+	return "", fmt.Errorf("unsupported signing algorithm: %s", k.Algorithm)
+}
+
+// parseEd25519PrivateKey parses a PEM encoded PKCS #8 private key, as generated by
+// `openssl genpkey -algorithm ed25519`.
+func parseEd25519PrivateKey(raw string) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil {
+		return nil, errors.New("the Ed25519 signing key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("the signing key is a %T, not an Ed25519 private key", key)
+	}
+	return ed, nil
+}
@@ -0,0 +1,97 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package request
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningKey(t *testing.T) {
+	t.Run("algorithm=hmac_sha256", func(t *testing.T) {
+		// Test vector of the Standard Webhooks specification.
+		secret, err := base64.StdEncoding.DecodeString("MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw")
+		require.NoError(t, err)
+		key := SigningKey{Algorithm: SigningAlgorithmHMACSHA256, Secret: string(secret)}
+
+		actual, err := key.sign([]byte(`msg_p5jXN8AQM9LWM0D4loKWxJek.1614265330.{"test": 2432232314}`))
+		require.NoError(t, err)
+		assert.Equal(t, "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE=", actual)
+	})
+
+	t.Run("algorithm=ed25519", func(t *testing.T) {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		require.NoError(t, err)
+		key := SigningKey{Algorithm: SigningAlgorithmEd25519, PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))}
+
+		actual, err := key.sign([]byte("content"))
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(actual, "v1a,"), actual)
+		signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(actual, "v1a,"))
+		require.NoError(t, err)
+		assert.True(t, ed25519.Verify(pub, []byte("content"), signature))
+	})
+
+	t.Run("case=invalid keys", func(t *testing.T) {
+		for _, key := range []SigningKey{
+			{Algorithm: SigningAlgorithmHMACSHA256},
+			{Algorithm: SigningAlgorithmEd25519, PrivateKey: "not a key"},
+			{Algorithm: "rsa"},
+		} {
+			_, err := key.sign([]byte("content"))
+			assert.Error(t, err, key.Algorithm)
+		}
+	})
+}
+
+func TestSigning(t *testing.T) {
+	t.Run("case=no keys", func(t *testing.T) {
+		req, err := retryablehttp.NewRequest("POST", "https://example.com", strings.NewReader("body"))
+		require.NoError(t, err)
+
+		require.NoError(t, new(Signing).sign(req, time.Now()))
+		assert.Empty(t, req.Header.Get(HeaderSignature))
+	})
+
+	t.Run("case=signs with every key", func(t *testing.T) {
+		req, err := retryablehttp.NewRequest("POST", "https://example.com", strings.NewReader("body"))
+		require.NoError(t, err)
+
+		now := time.Unix(1614265330, 0)
+		s := &Signing{Keys: []SigningKey{
+			{Algorithm: SigningAlgorithmHMACSHA256, Secret: "old-secret-old-secret-old-secret"},
+			{Algorithm: SigningAlgorithmHMACSHA256, Secret: "new-secret-new-secret-new-secret"},
+		}}
+		require.NoError(t, s.sign(req, now))
+
+		id := req.Header.Get(HeaderSignatureID)
+		assert.True(t, strings.HasPrefix(id, "msg_"), id)
+		assert.Equal(t, "1614265330", req.Header.Get(HeaderSignatureTimestamp))
+
+		content := []byte(id + ".1614265330.body")
+		var expected []string
+		for _, key := range s.Keys {
+			signature, err := key.sign(content)
+			require.NoError(t, err)
+			expected = append(expected, signature)
+		}
+		assert.Equal(t, strings.Join(expected, " "), req.Header.Get(HeaderSignature))
+
+		body, err := req.BodyBytes()
+		require.NoError(t, err)
+		assert.Equal(t, "body", string(body), "signing must not consume the body")
+	})
+}
@@ -5,6 +5,7 @@ package schema
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -235,6 +236,26 @@ func NewAddressNotVerifiedError() error {
 	})
 }
 
+func NewTemporaryPasswordExpiredError(expiredAt time.Time) error {
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     "the temporary password has expired",
+			InstancePtr: "#/",
+		},
+		Messages: new(text.Messages).Add(text.NewErrorValidationLoginTemporaryPasswordExpired(expiredAt)),
+	})
+}
+
+func NewTooManySessionsError(maxSessions int) error {
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     fmt.Sprintf("the identity has reached the maximum of %d active sessions", maxSessions),
+			InstancePtr: "#/",
+		},
+		Messages: new(text.Messages).Add(text.NewErrorValidationLoginTooManySessions(maxSessions)),
+	})
+}
+
 func NewNoTOTPDeviceRegistered() error {
 	return errors.WithStack(&ValidationError{
 		ValidationError: &jsonschema.ValidationError{
@@ -265,6 +286,82 @@ func NewNoWebAuthnRegistered() error {
 	})
 }
 
+func NewNoDeviceKeyRegistered() error {
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     `the device key does not exist`,
+			InstancePtr: "#/device_key_id",
+		},
+		Messages: new(text.Messages).Add(text.NewErrorValidationNoDeviceKey()),
+	})
+}
+
+func NewDeviceKeySignatureInvalidError() error {
+	t := text.NewErrorValidationDeviceKeySignatureInvalid()
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     t.Text,
+			InstancePtr: "#/device_key_signature",
+		},
+		Messages: new(text.Messages).Add(t),
+	})
+}
+
+func NewDeviceKeyInvalidError() error {
+	t := text.NewErrorValidationDeviceKeyInvalid()
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     t.Text,
+			InstancePtr: "#/device_key_register",
+		},
+		Messages: new(text.Messages).Add(t),
+	})
+}
+
+func NewWebAuthnAuthenticatorNotAllowedError(instancePtr string) error {
+	t := text.NewErrorValidationWebAuthnAuthenticatorNotAllowed()
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     t.Text,
+			InstancePtr: instancePtr,
+		},
+		Messages: new(text.Messages).Add(t),
+	})
+}
+
+func NewOIDCEmailDomainNotAllowedError(provider, domain string) error {
+	t := text.NewErrorValidationOIDCEmailDomainNotAllowed(provider, domain)
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     fmt.Sprintf("email domain %q is not allowed for provider %q", domain, provider),
+			InstancePtr: "#/",
+		},
+		Messages: new(text.Messages).Add(t),
+	})
+}
+
+func NewNoExternalMFARegistered() error {
+	t := text.NewErrorValidationNoExternalMFA()
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     t.Text,
+			InstancePtr: "#/",
+		},
+		Messages: new(text.Messages).Add(t),
+	})
+}
+
+func NewExternalMFADeniedError() error {
+	t := text.NewErrorValidationExternalMFADenied()
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     t.Text,
+			InstancePtr: "#/",
+		},
+		Messages: new(text.Messages).Add(t),
+	})
+}
+
 func NewHookValidationError(instancePtr, message string, messages text.Messages) *ValidationError {
 	return &ValidationError{
 		ValidationError: &jsonschema.ValidationError{
@@ -359,6 +456,36 @@ func NewLoginCodeInvalid() error {
 	})
 }
 
+func NewSettingsVerificationCodeInvalid() error {
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     `the provided verification code is invalid`,
+			InstancePtr: "#/code",
+		},
+		Messages: new(text.Messages).Add(text.NewErrorValidationSettingsVerificationCodeInvalid()),
+	})
+}
+
+func NewSettingsTraitChangeDiscarded() error {
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     `the changes could not be verified and have been discarded`,
+			InstancePtr: "#/",
+		},
+		Messages: new(text.Messages).Add(text.NewErrorValidationSettingsTraitChangeDiscarded()),
+	})
+}
+
+func NewSettingsLinkAddressNotVerifiedError() error {
+	return errors.WithStack(&ValidationError{
+		ValidationError: &jsonschema.ValidationError{
+			Message:     `a verified address is required to link a provider`,
+			InstancePtr: "#/",
+		},
+		Messages: new(text.Messages).Add(text.NewErrorValidationSettingsLinkAddressNotVerified()),
+	})
+}
+
 func NewLinkedCredentialsDoNotMatch() error {
 	return errors.WithStack(&ValidationError{
 		ValidationError: &jsonschema.ValidationError{
@@ -38,13 +38,24 @@ type (
 				Identifier bool   `json:"identifier"`
 				Via        string `json:"via"`
 			} `json:"code"`
+			ExternalMFA struct {
+				Identifier bool `json:"identifier"`
+			} `json:"external_mfa"`
 		} `json:"credentials"`
 		Verification struct {
-			Via string `json:"via"`
+			Via            string `json:"via"`
+			VerifyOnChange bool   `json:"verify_on_change"`
 		} `json:"verification"`
 		Recovery struct {
 			Via string `json:"via"`
 		} `json:"recovery"`
+		UI struct {
+			Placeholder  string `json:"placeholder,omitempty"`
+			Autocomplete string `json:"autocomplete,omitempty"`
+			InputMode    string `json:"inputmode,omitempty"`
+			Mask         string `json:"mask,omitempty"`
+		} `json:"ui"`
+		Encrypt   bool                   `json:"encrypt"`
 		RawSchema map[string]interface{} `json:"-"`
 	}
 
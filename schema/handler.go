@@ -256,7 +256,7 @@ func (h *Handler) ReadSchema(ctx context.Context, schema *Schema) (src io.ReadCl
 		}
 		src = io.NopCloser(strings.NewReader(string(data)))
 	} else {
-		resp, err := h.r.HTTPClient(ctx).Get(schema.URL.String())
+		resp, err := h.r.HTTPClient(config.WithHTTPClientIntegration(ctx, config.HTTPClientIntegrationSchemas)).Get(schema.URL.String())
 		if err != nil {
 			return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReason("Unable to fetch identity schema."))
 		}
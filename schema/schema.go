@@ -105,6 +105,14 @@ func init() {
 	orderedKeyCache = make(map[string][]string)
 }
 
+// ResetKeysInOrderCache clears the cached key positions of all schemas. It must be called when
+// the contents of a schema change.
+func ResetKeysInOrderCache() {
+	orderedKeyCacheMutex.Lock()
+	defer orderedKeyCacheMutex.Unlock()
+	orderedKeyCache = make(map[string][]string)
+}
+
 func computeKeyPositions(schema []byte, dest *[]string, parents []string) {
 	switch gjson.GetBytes(schema, "type").String() {
 	case "object":
@@ -34,6 +34,14 @@ type ErrorContainer struct {
 	CSRFToken string       `db:"csrf_token" json:"-"`
 }
 
+// legacyErrorContainer additionally contains the error as a list in the `errors` key. It is
+// returned if the `compatibility.legacy_error_format` switch is enabled.
+type legacyErrorContainer struct {
+	*ErrorContainer
+
+	LegacyErrors []json.RawMessage `json:"errors"`
+}
+
 func (e ErrorContainer) TableName(ctx context.Context) string {
 	return "selfservice_errors"
 }
@@ -103,7 +103,7 @@ func (h *Handler) fetchError(w http.ResponseWriter, r *http.Request) error {
 			r.URL.Query().Get("id"))
 	switch id {
 	case "stub:500":
-		h.r.Writer().Write(w, r, &ErrorContainer{ID: x.NewUUID(), Errors: stub500})
+		h.write(w, r, &ErrorContainer{ID: x.NewUUID(), Errors: stub500})
 		return nil
 	}
 
@@ -112,6 +112,15 @@ func (h *Handler) fetchError(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	h.r.Writer().Write(w, r, es)
+	h.write(w, r, es)
 	return nil
 }
+
+func (h *Handler) write(w http.ResponseWriter, r *http.Request, es *ErrorContainer) {
+	if h.r.Config().UseCompatibilitySwitch(r.Context(), config.CompatibilityLegacyErrorFormat) {
+		h.r.Writer().Write(w, r, &legacyErrorContainer{ErrorContainer: es, LegacyErrors: []json.RawMessage{es.Errors}})
+		return
+	}
+
+	h.r.Writer().Write(w, r, es)
+}
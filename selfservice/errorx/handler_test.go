@@ -21,6 +21,7 @@ import (
 	"github.com/tidwall/gjson"
 
 	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/internal"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/x"
@@ -29,7 +30,7 @@ import (
 )
 
 func TestHandler(t *testing.T) {
-	_, reg := internal.NewFastRegistryWithMocks(t)
+	conf, reg := internal.NewFastRegistryWithMocks(t)
 	h := errorx.NewHandler(reg)
 
 	t.Run("case=public authorization", func(t *testing.T) {
@@ -86,6 +87,34 @@ func TestHandler(t *testing.T) {
 		require.NoError(t, err)
 
 		assert.EqualValues(t, "This is a stub error.", gjson.GetBytes(actual, "error.reason").String())
+		assert.False(t, gjson.GetBytes(actual, "errors").Exists(), "%s", actual)
+	})
+
+	t.Run("case=legacy error format", func(t *testing.T) {
+		conf.MustSet(context.Background(), config.ViperKeyCompatibility+"."+config.CompatibilityLegacyErrorFormat.Key, true)
+		t.Cleanup(func() {
+			conf.MustSet(context.Background(), config.ViperKeyCompatibility+"."+config.CompatibilityLegacyErrorFormat.Key, false)
+		})
+
+		router := x.NewRouterPublic()
+		h.RegisterPublicRoutes(router)
+		ts := httptest.NewServer(router)
+		defer ts.Close()
+
+		id, err := reg.SelfServiceErrorPersister().CreateErrorContainer(context.Background(), x.NewUUID().String(), herodot.ErrNotFound.WithReason("foobar"))
+		require.NoError(t, err)
+
+		res, err := ts.Client().Get(ts.URL + errorx.RouteGet + "?id=" + id.String())
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.EqualValues(t, http.StatusOK, res.StatusCode)
+
+		actual, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, id.String(), gjson.GetBytes(actual, "id").String(), "%s", actual)
+		assert.Equal(t, "foobar", gjson.GetBytes(actual, "error.reason").String(), "%s", actual)
+		assert.JSONEq(t, "["+gjson.GetBytes(actual, "error").Raw+"]", gjson.GetBytes(actual, "errors").Raw, "%s", actual)
 	})
 
 	t.Run("case=errors types", func(t *testing.T) {
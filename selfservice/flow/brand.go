@@ -0,0 +1,41 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/x/sqlxx"
+	"github.com/ory/x/urlx"
+)
+
+// BrandFromRequest returns the brand selected using the `brand` query parameter. It must be one
+// of the brands configured in `selfservice.brands`.
+func BrandFromRequest(conf *config.Config, r *http.Request) (sqlxx.NullString, error) {
+	brand := r.URL.Query().Get("brand")
+	if brand == "" {
+		return "", nil
+	}
+
+	if !slices.Contains(conf.SelfServiceBrands(r.Context()), brand) {
+		return "", errors.WithStack(herodot.ErrBadRequest.WithReasonf("The brand %q is not configured.", brand))
+	}
+	return sqlxx.NullString(brand), nil
+}
+
+// AppendBrandTo adds the brand of the flow to the URL, so that the UI can render the theme of the
+// brand before it fetched the flow.
+func AppendBrandTo(src *url.URL, brand sqlxx.NullString) *url.URL {
+	if brand == "" {
+		return src
+	}
+
+	return urlx.CopyWithQuery(src, url.Values{"brand": {brand.String()}})
+}
@@ -267,6 +267,44 @@ func (c ContinueWithRedirectBrowserTo) GetAction() string {
 	return string(c.Action)
 }
 
+// swagger:enum ContinueWithActionOpenDeepLink
+type ContinueWithActionOpenDeepLink string
+
+// #nosec G101 -- only a key constant
+const (
+	ContinueWithActionOpenDeepLinkString ContinueWithActionOpenDeepLink = "open_deep_link"
+)
+
+var _ ContinueWith = new(ContinueWithOpenDeepLink)
+
+// Indicates, that the native app should continue by opening an app deep link or universal link
+//
+// swagger:model continueWithOpenDeepLink
+type ContinueWithOpenDeepLink struct {
+	// Action will always be `open_deep_link`
+	//
+	// required: true
+	Action ContinueWithActionOpenDeepLink `json:"action"`
+
+	// The deep link to open
+	//
+	// The link contains a `payload` query parameter with a signed JSON Web Token describing the completed flow.
+	//
+	// required: true
+	DeepLink string `json:"deep_link"`
+}
+
+func NewContinueWithOpenDeepLink(deepLink string) *ContinueWithOpenDeepLink {
+	return &ContinueWithOpenDeepLink{
+		Action:   ContinueWithActionOpenDeepLinkString,
+		DeepLink: deepLink,
+	}
+}
+
+func (c ContinueWithOpenDeepLink) GetAction() string {
+	return string(c.Action)
+}
+
 func ErrorWithContinueWith(err *herodot.DefaultError, continueWith ...ContinueWith) *herodot.DefaultError {
 	if err.DetailsField == nil {
 		err.DetailsField = map[string]interface{}{}
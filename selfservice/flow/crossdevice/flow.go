@@ -0,0 +1,260 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package crossdevice
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/randx"
+	"github.com/ory/x/sqlxx"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+)
+
+// Cross-Device Login Flow State
+//
+// The state can be one of:
+//
+// - pending: the flow is waiting for the user to approve or deny the sign in on another device
+// - approved: the sign in was approved and the session can be fetched by the initiating device
+// - rejected: the sign in was denied
+// - used: the session was issued to the initiating device
+//
+// swagger:enum crossDeviceLoginFlowState
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateApproved State = "approved"
+	StateRejected State = "rejected"
+	StateUsed     State = "used"
+)
+
+// userCodeAlphabet omits vowels and characters which are easily confused when read from a screen.
+var userCodeAlphabet = []rune("BCDFGHJKLMNPQRSTVWXZ")
+
+const userCodeLength = 8
+
+// Cross-Device Login Flow
+//
+// This object represents a cross-device login flow. It is initiated by a device with limited input
+// capabilities (e.g. a TV) which displays the user code and a QR code. The user then approves the
+// sign in on another device where they already have a session, and the initiating device receives
+// a session token.
+//
+// swagger:model crossDeviceLoginFlow
+type Flow struct {
+	// ID represents the flow's unique ID.
+	//
+	// required: true
+	ID  uuid.UUID `json:"id" faker:"-" db:"id" rw:"r"`
+	NID uuid.UUID `json:"-"  faker:"-" db:"nid"`
+
+	// ExpiresAt is the time (UTC) when the flow expires. If the user still wishes to sign in,
+	// a new flow has to be initiated.
+	//
+	// required: true
+	ExpiresAt time.Time `json:"expires_at" faker:"time_type" db:"expires_at"`
+
+	// IssuedAt is the time (UTC) when the flow started.
+	//
+	// required: true
+	IssuedAt time.Time `json:"issued_at" faker:"time_type" db:"issued_at"`
+
+	// RequestURL is the initial URL that was requested from Ory Kratos.
+	//
+	// required: true
+	RequestURL string `json:"request_url" db:"request_url"`
+
+	// State represents the state of this flow.
+	//
+	// required: true
+	State State `json:"state" db:"state"`
+
+	// UserCode is the short code which the user enters or confirms on the approving device.
+	//
+	// required: true
+	UserCode string `json:"user_code" db:"user_code"`
+
+	// DeviceCode is the secret used by the initiating device to fetch the session. It is never
+	// returned by the API except when the flow is created.
+	DeviceCode string `json:"-" faker:"-" db:"device_code"`
+
+	// VerificationURL is the URL the user has to open on the approving device. It is also
+	// encoded in the QR code.
+	//
+	// required: true
+	VerificationURL string `json:"verification_url" db:"-"`
+
+	// UI contains data which must be shown in the user interface.
+	//
+	// required: true
+	UI *container.Container `json:"ui" db:"ui"`
+
+	// IdentityID is the identity which approved the flow.
+	IdentityID uuid.NullUUID `json:"-" faker:"-" db:"identity_id"`
+
+	// ApprovedAt is the time (UTC) when the flow was approved.
+	ApprovedAt sqlxx.NullTime `json:"-" faker:"-" db:"approved_at"`
+
+	// CreatedAt is a helper struct field for gobuffalo.pop.
+	CreatedAt time.Time `json:"-" db:"created_at"`
+
+	// UpdatedAt is a helper struct field for gobuffalo.pop.
+	UpdatedAt time.Time `json:"-" db:"updated_at"`
+}
+
+func (f Flow) TableName(context.Context) string {
+	return "selfservice_cross_device_login_flows"
+}
+
+func NewFlow(conf *config.Config, r *http.Request) (*Flow, error) {
+	ctx := r.Context()
+	now := x.Now().UTC()
+
+	userCode, err := NewUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Flow{
+		ID:         x.NewUUID(),
+		ExpiresAt:  now.Add(conf.SelfServiceFlowCrossDeviceLoginLifespan(ctx)),
+		IssuedAt:   now,
+		RequestURL: x.RequestURL(r).String(),
+		State:      StatePending,
+		UserCode:   userCode,
+		DeviceCode: randx.MustString(64, randx.AlphaNum),
+	}
+
+	f.SetVerificationURL(conf.SelfServiceFlowCrossDeviceLoginUI(ctx))
+	if err := f.setInitiatorNodes(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// NewUserCode returns a random, human-readable user code formatted as `XXXX-XXXX`.
+func NewUserCode() (string, error) {
+	code, err := randx.RuneSequence(userCodeLength, userCodeAlphabet)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return formatUserCode(string(code)), nil
+}
+
+// NormalizeUserCode brings user input (e.g. `abcd efgh`) into the canonical `ABCD-EFGH` form.
+func NormalizeUserCode(in string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(in) {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() != userCodeLength {
+		return ""
+	}
+	return formatUserCode(b.String())
+}
+
+func formatUserCode(code string) string {
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+func (f *Flow) Valid() error {
+	if f.ExpiresAt.Before(x.Now().UTC()) {
+		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
+	}
+	return nil
+}
+
+func (f Flow) GetID() uuid.UUID {
+	return f.ID
+}
+
+func (f Flow) GetNID() uuid.UUID {
+	return f.NID
+}
+
+func (f *Flow) GetUI() *container.Container {
+	return f.UI
+}
+
+func (f *Flow) GetFlowName() flow.FlowName {
+	return flow.CrossDeviceLoginFlow
+}
+
+func (f *Flow) SetVerificationURL(base *url.URL) {
+	f.VerificationURL = urlx.CopyWithQuery(base, url.Values{"user_code": {f.UserCode}}).String()
+}
+
+// setInitiatorNodes renders the nodes shown on the device which initiated the flow.
+func (f *Flow) setInitiatorNodes() error {
+	src, err := qrCodeToHTMLImage(f.VerificationURL)
+	if err != nil {
+		return err
+	}
+
+	f.UI = &container.Container{Method: "GET", Action: f.VerificationURL}
+	f.UI.Nodes.Append(node.NewTextField(node.CrossDeviceUserCode,
+		text.NewInfoSelfServiceLoginCrossDeviceUserCode(f.UserCode), node.CrossDeviceGroup))
+	f.UI.Nodes.Append(node.NewImageField(node.CrossDeviceQR, src, node.CrossDeviceGroup, node.WithImageAttributes(func(a *node.ImageAttributes) {
+		a.Height = 256
+		a.Width = 256
+	})).WithMetaLabel(text.NewInfoSelfServiceLoginCrossDeviceQRCode()))
+	return nil
+}
+
+// NewApprovalContainer renders the nodes shown on the approving device.
+func (f *Flow) NewApprovalContainer(action string, csrfToken string) *container.Container {
+	c := &container.Container{Method: "POST", Action: action}
+	c.Nodes.Append(node.NewCSRFNode(csrfToken))
+	c.Nodes.Append(node.NewInputField(node.CrossDeviceUserCode, f.UserCode, node.CrossDeviceGroup,
+		node.InputAttributeTypeHidden, node.WithRequiredInputAttribute))
+	c.Nodes.Append(node.NewInputField(node.CrossDeviceAction, string(StateApproved), node.CrossDeviceGroup,
+		node.InputAttributeTypeSubmit).
+		WithMetaLabel(text.NewInfoSelfServiceLoginCrossDeviceApprove()))
+	c.Nodes.Append(node.NewInputField(node.CrossDeviceAction, string(StateRejected), node.CrossDeviceGroup,
+		node.InputAttributeTypeSubmit).
+		WithMetaLabel(text.NewInfoSelfServiceLoginCrossDeviceReject()))
+	return c
+}
+
+func qrCodeToHTMLImage(content string) (string, error) {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	code, err = barcode.Scale(code, 256, 256)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,560 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package crossdevice
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/decoderx"
+	"github.com/ory/x/sqlcon"
+	"github.com/ory/x/sqlxx"
+	"github.com/ory/x/urlx"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+)
+
+const (
+	RouteInitFlow    = "/self-service/login/cross-device"
+	RouteGetFlow     = "/self-service/login/cross-device/flows"
+	RouteApproveFlow = "/self-service/login/cross-device/approve"
+	RouteTokenFlow   = "/self-service/login/cross-device/token"
+)
+
+var (
+	ErrCrossDeviceLoginDisabled = herodot.ErrBadRequest.WithID(text.ErrIDSelfServiceFlowDisabled).WithError("cross-device login flow disabled").WithReason("Cross-device login is not allowed because it was disabled.")
+	ErrUserCodeInvalid          = herodot.ErrNotFound.WithError("user code invalid").WithReason("The code is invalid, has expired, or has already been used. Please try again.")
+	ErrDeviceCodeInvalid        = herodot.ErrNotFound.WithError("device code invalid").WithReason("The device code is invalid or has already been used.")
+	ErrFlowPending              = herodot.ErrBadRequest.WithID(text.ErrIDCrossDeviceLoginPending).WithError("cross-device login pending").WithReason("The sign in has not yet been approved on another device. Please try again later.")
+	ErrFlowRejected             = herodot.ErrForbidden.WithID(text.ErrIDCrossDeviceLoginRejected).WithError("cross-device login rejected").WithReason("The sign in was denied on another device.")
+)
+
+type (
+	handlerDependencies interface {
+		config.Provider
+		x.WriterProvider
+		x.CSRFProvider
+		x.CSRFTokenGeneratorProvider
+		x.LoggingProvider
+		identity.PoolProvider
+		session.ManagementProvider
+		login.HookExecutorProvider
+		login.FlowPersistenceProvider
+		FlowPersistenceProvider
+	}
+	HandlerProvider interface {
+		CrossDeviceLoginHandler() *Handler
+	}
+	Handler struct {
+		d  handlerDependencies
+		dx *decoderx.HTTP
+	}
+)
+
+func NewHandler(d handlerDependencies) *Handler {
+	return &Handler{
+		d:  d,
+		dx: decoderx.NewHTTP(),
+	}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	h.d.CSRFHandler().IgnorePath(RouteInitFlow)
+	h.d.CSRFHandler().IgnorePath(RouteApproveFlow)
+	h.d.CSRFHandler().IgnorePath(RouteTokenFlow)
+
+	public.POST(RouteInitFlow, h.createCrossDeviceLoginFlow)
+	public.GET(RouteGetFlow, h.getCrossDeviceLoginFlow)
+	public.POST(RouteApproveFlow, h.updateCrossDeviceLoginFlow)
+	public.POST(RouteTokenFlow, h.exchangeCrossDeviceLoginFlow)
+}
+
+func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.POST(RouteInitFlow, x.RedirectToPublicRoute(h.d))
+	admin.GET(RouteGetFlow, x.RedirectToPublicRoute(h.d))
+	admin.POST(RouteApproveFlow, x.RedirectToPublicRoute(h.d))
+	admin.POST(RouteTokenFlow, x.RedirectToPublicRoute(h.d))
+}
+
+// Cross-Device Login Flow Initialization Response
+//
+// swagger:model createCrossDeviceLoginFlowResponse
+type createFlowResponse struct {
+	// The cross-device login flow.
+	//
+	// required: true
+	Flow *Flow `json:"flow"`
+
+	// DeviceCode is the secret the initiating device uses to fetch the session once the sign in was
+	// approved. It is only returned once and must be kept secret.
+	//
+	// required: true
+	DeviceCode string `json:"device_code"`
+
+	// Interval is the minimum number of seconds the initiating device must wait between polling
+	// for the session.
+	//
+	// required: true
+	Interval int64 `json:"interval"`
+}
+
+// swagger:route POST /self-service/login/cross-device frontend createCrossDeviceLoginFlow
+//
+// # Create Cross-Device Login Flow
+//
+// This endpoint initiates a cross-device login flow for devices with limited input capabilities
+// (e.g. TVs or consoles). The response contains a user code and a QR code pointing to the verification
+// URL which the user opens on a second device on which they are already signed in.
+//
+// The initiating device then polls `/self-service/login/cross-device/token` with the returned device code
+// until the sign in was approved and a session token is issued.
+//
+// This endpoint is only available if `selfservice.flows.cross_device_login.enabled` is set.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: createCrossDeviceLoginFlowResponse
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) createCrossDeviceLoginFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+	if !h.d.Config().SelfServiceFlowCrossDeviceLoginEnabled(ctx) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrCrossDeviceLoginDisabled))
+		return
+	}
+
+	f, err := NewFlow(h.d.Config(), r)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := h.d.CrossDeviceLoginFlowPersister().CreateCrossDeviceLoginFlow(ctx, f); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.d.Writer().Write(w, r, &createFlowResponse{
+		Flow:       f,
+		DeviceCode: f.DeviceCode,
+		Interval:   int64(h.d.Config().SelfServiceFlowCrossDeviceLoginPollInterval(ctx).Seconds()),
+	})
+}
+
+// Get Cross-Device Login Flow Parameters
+//
+// swagger:parameters getCrossDeviceLoginFlow
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type getCrossDeviceLoginFlow struct {
+	// The user code displayed on the initiating device.
+	//
+	// required: true
+	// in: query
+	UserCode string `json:"user_code"`
+
+	// The Session Token of the approving device.
+	//
+	// in: header
+	SessionToken string `json:"X-Session-Token"`
+
+	// HTTP Cookies
+	//
+	// When using the SDK in a browser app, on the server side you must include the HTTP Cookie Header
+	// sent by the client to your server here. This ensures that CSRF and session cookies are respected.
+	//
+	// in: header
+	// name: Cookie
+	Cookie string `json:"Cookie"`
+}
+
+// swagger:route GET /self-service/login/cross-device/flows frontend getCrossDeviceLoginFlow
+//
+// # Get Cross-Device Login Flow
+//
+// This endpoint is called by the approval UI on the device on which the user is already signed in. It
+// returns the flow identified by the user code together with the nodes needed to approve or deny the
+// sign in.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: crossDeviceLoginFlow
+//	  401: errorGeneric
+//	  403: errorGeneric
+//	  404: errorGeneric
+//	  410: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) getCrossDeviceLoginFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+	if !h.d.Config().SelfServiceFlowCrossDeviceLoginEnabled(ctx) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrCrossDeviceLoginDisabled))
+		return
+	}
+
+	if _, err := h.approvingSession(r); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	f, err := h.pendingFlowByUserCode(r, r.URL.Query().Get("user_code"))
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	f.UI = f.NewApprovalContainer(urlx.AppendPaths(h.d.Config().SelfPublicURL(ctx), RouteApproveFlow).String(), h.csrfToken(r))
+	h.d.Writer().Write(w, r, f)
+}
+
+// Update Cross-Device Login Flow Parameters
+//
+// swagger:parameters updateCrossDeviceLoginFlow
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type updateCrossDeviceLoginFlow struct {
+	// in: body
+	// required: true
+	Body updateCrossDeviceLoginFlowBody
+
+	// The Session Token of the approving device.
+	//
+	// in: header
+	SessionToken string `json:"X-Session-Token"`
+
+	// HTTP Cookies
+	//
+	// When using the SDK in a browser app, on the server side you must include the HTTP Cookie Header
+	// sent by the client to your server here. This ensures that CSRF and session cookies are respected.
+	//
+	// in: header
+	// name: Cookie
+	Cookie string `json:"Cookie"`
+}
+
+// Update Cross-Device Login Flow Request Body
+//
+// swagger:model updateCrossDeviceLoginFlowBody
+type updateCrossDeviceLoginFlowBody struct {
+	// The user code displayed on the initiating device.
+	//
+	// required: true
+	UserCode string `json:"user_code" form:"user_code"`
+
+	// Action is either `approved` to allow the sign in or `rejected` to deny it.
+	//
+	// required: true
+	Action State `json:"action" form:"action"`
+
+	// Sending the anti-csrf token is only required for browser requests.
+	CSRFToken string `json:"csrf_token" form:"csrf_token"`
+}
+
+// swagger:route POST /self-service/login/cross-device/approve frontend updateCrossDeviceLoginFlow
+//
+// # Approve or Deny a Cross-Device Login Flow
+//
+// This endpoint is called from the device on which the user is already signed in. Approving the flow
+// allows the initiating device to fetch a session for the signed in identity.
+//
+// Browser requests must include the anti-CSRF token which is part of the flow's UI nodes.
+//
+//	Consumes:
+//	- application/json
+//	- application/x-www-form-urlencoded
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: crossDeviceLoginFlow
+//	  400: errorGeneric
+//	  401: errorGeneric
+//	  403: errorGeneric
+//	  404: errorGeneric
+//	  410: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) updateCrossDeviceLoginFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+	if !h.d.Config().SelfServiceFlowCrossDeviceLoginEnabled(ctx) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrCrossDeviceLoginDisabled))
+		return
+	}
+
+	sess, err := h.approvingSession(r)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	var p updateCrossDeviceLoginFlowBody
+	if err := h.dx.Decode(r, &p,
+		decoderx.HTTPDecoderSetValidatePayloads(true),
+		decoderx.MustHTTPRawJSONSchemaCompiler(approveSchema),
+		decoderx.HTTPDecoderAllowedMethods("POST"),
+		decoderx.HTTPDecoderJSONFollowsFormFormat()); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := flow.EnsureCSRF(h.d, r, requestFlowType(r), h.d.Config().DisableAPIFlowEnforcement(ctx), h.d.GenerateCSRFToken, p.CSRFToken); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	f, err := h.pendingFlowByUserCode(r, p.UserCode)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	f.UI = &container.Container{Method: "GET", Action: f.VerificationURL}
+	switch p.Action {
+	case StateApproved:
+		f.State = StateApproved
+		f.IdentityID = uuid.NullUUID{UUID: sess.IdentityID, Valid: true}
+		f.ApprovedAt = sqlxx.NullTime(x.Now().UTC())
+		f.UI.Messages.Add(text.NewInfoSelfServiceLoginCrossDeviceApproved())
+	case StateRejected:
+		f.State = StateRejected
+		f.UI.Messages.Add(text.NewInfoSelfServiceLoginCrossDeviceRejected())
+	default:
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf(`The action must be one of "%s" or "%s".`, StateApproved, StateRejected)))
+		return
+	}
+
+	// Only one response is stored if the flow is approved and rejected concurrently.
+	if err := h.d.CrossDeviceLoginFlowPersister().UpdatePendingCrossDeviceLoginFlow(ctx, f); errors.Is(err, sqlcon.ErrNoRows) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrUserCodeInvalid))
+		return
+	} else if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.d.Audit().
+		WithRequest(r).
+		WithField("identity_id", sess.IdentityID).
+		WithField("cross_device_login_flow_id", f.ID).
+		WithField("state", f.State).
+		Info("Identity responded to a cross-device login request.")
+
+	h.d.Writer().Write(w, r, f)
+}
+
+// Exchange Cross-Device Login Flow Parameters
+//
+// swagger:parameters exchangeCrossDeviceLoginFlow
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type exchangeCrossDeviceLoginFlow struct {
+	// in: body
+	// required: true
+	Body exchangeCrossDeviceLoginFlowBody
+}
+
+// Exchange Cross-Device Login Flow Request Body
+//
+// swagger:model exchangeCrossDeviceLoginFlowBody
+type exchangeCrossDeviceLoginFlowBody struct {
+	// The device code returned when creating the flow.
+	//
+	// required: true
+	DeviceCode string `json:"device_code"`
+}
+
+// swagger:route POST /self-service/login/cross-device/token frontend exchangeCrossDeviceLoginFlow
+//
+// # Exchange a Cross-Device Login Flow for a Session
+//
+// This endpoint is polled by the initiating device. As long as the sign in was not approved, it returns
+// an error with ID `cross_device_login_pending`. If the sign in was denied, it returns an error
+// with ID `cross_device_login_rejected`. Once approved, a session is issued and returned together with
+// the session token. The device code can only be exchanged once.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: successfulNativeLogin
+//	  400: errorGeneric
+//	  403: errorGeneric
+//	  404: errorGeneric
+//	  410: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) exchangeCrossDeviceLoginFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+	if !h.d.Config().SelfServiceFlowCrossDeviceLoginEnabled(ctx) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrCrossDeviceLoginDisabled))
+		return
+	}
+
+	var p exchangeCrossDeviceLoginFlowBody
+	if err := h.dx.Decode(r, &p,
+		decoderx.HTTPDecoderSetValidatePayloads(true),
+		decoderx.MustHTTPRawJSONSchemaCompiler(tokenSchema),
+		decoderx.HTTPDecoderAllowedMethods("POST"),
+		decoderx.HTTPDecoderJSONFollowsFormFormat()); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	f, err := h.d.CrossDeviceLoginFlowPersister().GetCrossDeviceLoginFlowByDeviceCode(ctx, p.DeviceCode)
+	if errors.Is(err, sqlcon.ErrNoRows) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrDeviceCodeInvalid))
+		return
+	} else if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := f.Valid(); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	switch f.State {
+	case StatePending:
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrFlowPending.WithDetail("interval", int64(h.d.Config().SelfServiceFlowCrossDeviceLoginPollInterval(ctx).Seconds()))))
+		return
+	case StateRejected:
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrFlowRejected))
+		return
+	case StateApproved:
+		// continue below
+	default:
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrDeviceCodeInvalid))
+		return
+	}
+
+	// Mark the flow as used before issuing the session so that the device code can not be replayed.
+	// Only one of several concurrent exchanges succeeds.
+	if err := h.d.CrossDeviceLoginFlowPersister().UseApprovedCrossDeviceLoginFlow(ctx, f.ID); errors.Is(err, sqlcon.ErrNoRows) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(ErrDeviceCodeInvalid))
+		return
+	} else if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	i, err := h.d.IdentityPool().GetIdentity(ctx, f.IdentityID.UUID, identity.ExpandDefault)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	// The session is issued through an API login flow so that the identity state is checked and
+	// the login hooks run as for any other login method.
+	lf, err := login.NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowLoginRequestLifespan(ctx), "", r, flow.TypeAPI)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+	lf.Active = identity.CredentialsTypeCrossDevice
+	lf.RequestedAAL = identity.AuthenticatorAssuranceLevel1
+	if err := h.d.LoginFlowPersister().CreateLoginFlow(ctx, lf); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.d.Audit().
+		WithRequest(r).
+		WithField("identity_id", i.ID).
+		WithField("cross_device_login_flow_id", f.ID).
+		WithField("login_flow_id", lf.ID).
+		Info("Identity completed a cross-device login flow.")
+
+	sess := session.NewInactiveSession()
+	sess.CompletedLoginFor(identity.CredentialsTypeCrossDevice, identity.AuthenticatorAssuranceLevel1)
+	if err := h.d.LoginHookExecutor().PostLoginHook(w, r, node.CrossDeviceGroup, lf, i, sess, ""); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+}
+
+func (h *Handler) approvingSession(r *http.Request) (*session.Session, error) {
+	ctx := r.Context()
+	sess, err := h.d.SessionManager().FetchFromRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.d.SessionManager().DoesSessionSatisfy(ctx, sess, h.d.Config().SessionWhoAmIAAL(ctx)); err != nil {
+		return nil, err
+	}
+
+	return sess, nil
+}
+
+func (h *Handler) pendingFlowByUserCode(r *http.Request, userCode string) (*Flow, error) {
+	userCode = NormalizeUserCode(userCode)
+	if len(userCode) == 0 {
+		return nil, errors.WithStack(ErrUserCodeInvalid)
+	}
+
+	f, err := h.d.CrossDeviceLoginFlowPersister().GetCrossDeviceLoginFlowByUserCode(r.Context(), userCode)
+	if errors.Is(err, sqlcon.ErrNoRows) {
+		return nil, errors.WithStack(ErrUserCodeInvalid)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := f.Valid(); err != nil {
+		return nil, err
+	}
+
+	if f.State != StatePending {
+		return nil, errors.WithStack(ErrUserCodeInvalid)
+	}
+
+	f.SetVerificationURL(h.d.Config().SelfServiceFlowCrossDeviceLoginUI(r.Context()))
+	return f, nil
+}
+
+func (h *Handler) csrfToken(r *http.Request) string {
+	if requestFlowType(r) == flow.TypeAPI {
+		return ""
+	}
+	return h.d.GenerateCSRFToken(r)
+}
+
+// requestFlowType returns flow.TypeAPI if the approving device authenticated using a session token
+// and flow.TypeBrowser otherwise.
+func requestFlowType(r *http.Request) flow.Type {
+	if len(r.Header.Get("X-Session-Token")) > 0 ||
+		strings.HasPrefix(strings.ToLower(r.Header.Get("Authorization")), "bearer ") {
+		return flow.TypeAPI
+	}
+	return flow.TypeBrowser
+}
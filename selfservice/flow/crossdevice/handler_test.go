@@ -0,0 +1,273 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package crossdevice_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
+	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+)
+
+func TestNormalizeUserCode(t *testing.T) {
+	for in, expected := range map[string]string{
+		"BCDF-GHJK":  "BCDF-GHJK",
+		"bcdfghjk":   "BCDF-GHJK",
+		" bcdf ghjk": "BCDF-GHJK",
+		"BCDF-GHJ":   "",
+		"":           "",
+	} {
+		assert.Equal(t, expected, crossdevice.NormalizeUserCode(in), "%q", in)
+	}
+
+	code, err := crossdevice.NewUserCode()
+	require.NoError(t, err)
+	assert.Equal(t, code, crossdevice.NormalizeUserCode(code))
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	public, _ := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	conf.MustSet(ctx, config.ViperKeySelfServiceCrossDeviceLoginEnabled, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceCrossDeviceLoginUI, "https://www.ory.sh/device")
+
+	initFlow := func(t *testing.T) (f gjson.Result, deviceCode string) {
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", public.URL+crossdevice.RouteInitFlow, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		return gjson.GetBytes(body, "flow"), gjson.GetBytes(body, "device_code").String()
+	}
+
+	exchange := func(t *testing.T, deviceCode string) ([]byte, *http.Response) {
+		return testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", public.URL+crossdevice.RouteTokenFlow,
+			json.RawMessage(`{"device_code":"`+deviceCode+`"}`))
+	}
+
+	respond := func(t *testing.T, hc *http.Client, userCode string, action crossdevice.State) ([]byte, *http.Response) {
+		return testhelpers.HTTPRequestJSON(t, hc, "POST", public.URL+crossdevice.RouteApproveFlow,
+			json.RawMessage(`{"user_code":"`+userCode+`","action":"`+string(action)+`"}`))
+	}
+
+	t.Run("case=is disabled by default", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceCrossDeviceLoginEnabled, false)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceCrossDeviceLoginEnabled, true)
+		})
+
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", public.URL+crossdevice.RouteInitFlow, nil)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, text.ErrIDSelfServiceFlowDisabled, gjson.GetBytes(body, "error.id").String(), "%s", body)
+	})
+
+	t.Run("case=creates a flow with user code and QR code", func(t *testing.T) {
+		f, deviceCode := initFlow(t)
+		assert.Len(t, deviceCode, 64)
+		assert.Equal(t, string(crossdevice.StatePending), f.Get("state").String())
+		assert.False(t, f.Get("device_code").Exists(), "the device code must only be returned outside of the flow")
+
+		userCode := f.Get("user_code").String()
+		assert.Equal(t, userCode, crossdevice.NormalizeUserCode(userCode))
+
+		verificationURL, err := url.Parse(f.Get("verification_url").String())
+		require.NoError(t, err)
+		assert.Equal(t, "www.ory.sh", verificationURL.Host)
+		assert.Equal(t, userCode, verificationURL.Query().Get("user_code"))
+
+		assert.Equal(t, node.CrossDeviceUserCode, f.Get("ui.nodes.0.attributes.id").String())
+		assert.EqualValues(t, text.InfoSelfServiceLoginCrossDeviceUserCode, f.Get("ui.nodes.0.attributes.text.id").Int())
+		assert.Equal(t, node.CrossDeviceQR, f.Get("ui.nodes.1.attributes.id").String())
+		assert.True(t, strings.HasPrefix(f.Get("ui.nodes.1.attributes.src").String(), "data:image/png;base64,"))
+	})
+
+	t.Run("case=exchange is pending until approved", func(t *testing.T) {
+		_, deviceCode := initFlow(t)
+
+		body, res := exchange(t, deviceCode)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, text.ErrIDCrossDeviceLoginPending, gjson.GetBytes(body, "error.id").String(), "%s", body)
+		assert.EqualValues(t, 5, gjson.GetBytes(body, "error.details.interval").Int(), "%s", body)
+	})
+
+	t.Run("case=unknown codes are rejected", func(t *testing.T) {
+		_, res := exchange(t, "not-a-device-code")
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		_, res = respond(t, testhelpers.NewHTTPClientWithArbitrarySessionToken(t, ctx, reg), "BCDF-GHJK", crossdevice.StateApproved)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("case=approval requires a session", func(t *testing.T) {
+		f, _ := initFlow(t)
+
+		res, err := http.Get(public.URL + crossdevice.RouteGetFlow + "?user_code=" + f.Get("user_code").String())
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		_, res = respond(t, http.DefaultClient, f.Get("user_code").String(), crossdevice.StateApproved)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("case=browser approval requires an anti-csrf token", func(t *testing.T) {
+		f, _ := initFlow(t)
+
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		hc := testhelpers.NewHTTPClientWithIdentitySessionCookieLocalhost(t, ctx, reg, i)
+		body, res := respond(t, hc, f.Get("user_code").String(), crossdevice.StateApproved)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, "%s", body)
+	})
+
+	t.Run("case=rejected flows can not be exchanged", func(t *testing.T) {
+		f, deviceCode := initFlow(t)
+
+		hc := testhelpers.NewHTTPClientWithArbitrarySessionToken(t, ctx, reg)
+		body, res := respond(t, hc, f.Get("user_code").String(), crossdevice.StateRejected)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.Equal(t, string(crossdevice.StateRejected), gjson.GetBytes(body, "state").String())
+		assert.EqualValues(t, text.InfoSelfServiceLoginCrossDeviceRejected, gjson.GetBytes(body, "ui.messages.0.id").Int())
+
+		body, res = exchange(t, deviceCode)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.Equal(t, text.ErrIDCrossDeviceLoginRejected, gjson.GetBytes(body, "error.id").String(), "%s", body)
+	})
+
+	t.Run("case=expired flows can not be approved", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceCrossDeviceLoginLifespan, "1ms")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceCrossDeviceLoginLifespan, "10m")
+		})
+
+		f, deviceCode := initFlow(t)
+		time.Sleep(10 * time.Millisecond)
+
+		_, res := respond(t, testhelpers.NewHTTPClientWithArbitrarySessionToken(t, ctx, reg), f.Get("user_code").String(), crossdevice.StateApproved)
+		assert.Equal(t, http.StatusGone, res.StatusCode)
+
+		_, res = exchange(t, deviceCode)
+		assert.Equal(t, http.StatusGone, res.StatusCode)
+	})
+
+	t.Run("case=approved flows issue a session exactly once", func(t *testing.T) {
+		f, deviceCode := initFlow(t)
+		userCode := f.Get("user_code").String()
+
+		approver := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, approver))
+		hc := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, approver)
+
+		res, err := hc.Get(public.URL + crossdevice.RouteGetFlow + "?user_code=" + strings.ToLower(userCode))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var approval json.RawMessage
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&approval))
+		assert.Equal(t, "POST", gjson.GetBytes(approval, "ui.method").String())
+		assert.True(t, strings.HasSuffix(gjson.GetBytes(approval, "ui.action").String(), crossdevice.RouteApproveFlow), "%s", approval)
+		assert.Equal(t, userCode, gjson.GetBytes(approval, `ui.nodes.#(attributes.name=="user_code").attributes.value`).String(), "%s", approval)
+
+		body, res := respond(t, hc, userCode, crossdevice.StateApproved)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.Equal(t, string(crossdevice.StateApproved), gjson.GetBytes(body, "state").String())
+		assert.EqualValues(t, text.InfoSelfServiceLoginCrossDeviceApproved, gjson.GetBytes(body, "ui.messages.0.id").Int())
+
+		// The user code can not be used again.
+		_, res = respond(t, hc, userCode, crossdevice.StateApproved)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		body, res = exchange(t, deviceCode)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		token := gjson.GetBytes(body, "session_token").String()
+		require.NotEmpty(t, token)
+		assert.Equal(t, approver.ID.String(), gjson.GetBytes(body, "session.identity.id").String(), "%s", body)
+		assert.Equal(t, string(identity.CredentialsTypeCrossDevice), gjson.GetBytes(body, "session.authentication_methods.0.method").String(), "%s", body)
+
+		sess, err := reg.SessionPersister().GetSessionByToken(ctx, token, session.ExpandNothing, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.True(t, sess.IsActive())
+
+		_, res = exchange(t, deviceCode)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	approveAs := func(t *testing.T, i *identity.Identity) string {
+		f, deviceCode := initFlow(t)
+		body, res := respond(t, testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i), f.Get("user_code").String(), crossdevice.StateApproved)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		return deviceCode
+	}
+
+	t.Run("case=a flow can only be responded to once", func(t *testing.T) {
+		f, deviceCode := initFlow(t)
+		userCode := f.Get("user_code").String()
+		hc := testhelpers.NewHTTPClientWithArbitrarySessionToken(t, ctx, reg)
+
+		fl, err := reg.CrossDeviceLoginFlowPersister().GetCrossDeviceLoginFlowByUserCode(ctx, userCode)
+		require.NoError(t, err)
+		fl.State = crossdevice.StateRejected
+		require.NoError(t, reg.CrossDeviceLoginFlowPersister().UpdatePendingCrossDeviceLoginFlow(ctx, fl))
+
+		// The approval lost the race against the rejection.
+		_, res := respond(t, hc, userCode, crossdevice.StateApproved)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		_, res = exchange(t, deviceCode)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+
+	t.Run("case=disabled identities do not receive a session", func(t *testing.T) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		deviceCode := approveAs(t, i)
+
+		i.State = identity.StateInactive
+		require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentity(ctx, i))
+
+		body, res := exchange(t, deviceCode)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "%s", body)
+		assert.False(t, gjson.GetBytes(body, "session_token").Exists(), "%s", body)
+	})
+
+	t.Run("case=runs the login hooks", func(t *testing.T) {
+		conf.MustSet(ctx, config.HookStrategyKey(config.ViperKeySelfServiceLoginAfter, string(identity.CredentialsTypeCrossDevice)), []config.SelfServiceHook{{Name: hook.KeySessionDestroyer}})
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.HookStrategyKey(config.ViperKeySelfServiceLoginAfter, string(identity.CredentialsTypeCrossDevice)), nil)
+		})
+
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		deviceCode := approveAs(t, i)
+
+		body, res := exchange(t, deviceCode)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+
+		sessions, _, err := reg.SessionPersister().ListSessionsByIdentity(ctx, i.ID, nil, 1, 10, uuid.Nil, session.ExpandNothing)
+		require.NoError(t, err)
+		var active int
+		for _, s := range sessions {
+			if s.IsActive() {
+				active++
+			}
+		}
+		assert.Equal(t, 1, active, "the approving session must have been revoked")
+	})
+}
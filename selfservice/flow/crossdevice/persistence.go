@@ -0,0 +1,32 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package crossdevice
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type (
+	FlowPersister interface {
+		CreateCrossDeviceLoginFlow(context.Context, *Flow) error
+		GetCrossDeviceLoginFlow(ctx context.Context, id uuid.UUID) (*Flow, error)
+		GetCrossDeviceLoginFlowByUserCode(ctx context.Context, userCode string) (*Flow, error)
+		GetCrossDeviceLoginFlowByDeviceCode(ctx context.Context, deviceCode string) (*Flow, error)
+		UpdateCrossDeviceLoginFlow(context.Context, *Flow) error
+		// UpdatePendingCrossDeviceLoginFlow stores the response to a pending flow. It returns
+		// sqlcon.ErrNoRows if the flow is no longer pending, for example because it was approved or
+		// rejected concurrently.
+		UpdatePendingCrossDeviceLoginFlow(context.Context, *Flow) error
+		// UseApprovedCrossDeviceLoginFlow marks an approved flow as used. It returns sqlcon.ErrNoRows
+		// if the flow is not approved, for example because it was used concurrently.
+		UseApprovedCrossDeviceLoginFlow(ctx context.Context, id uuid.UUID) error
+		DeleteExpiredCrossDeviceLoginFlows(context.Context, time.Time, int) error
+	}
+	FlowPersistenceProvider interface {
+		CrossDeviceLoginFlowPersister() FlowPersister
+	}
+)
@@ -0,0 +1,14 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package crossdevice
+
+import (
+	_ "embed"
+)
+
+//go:embed .schema/approve.schema.json
+var approveSchema []byte
+
+//go:embed .schema/token.schema.json
+var tokenSchema []byte
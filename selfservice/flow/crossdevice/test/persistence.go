@@ -0,0 +1,167 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-faker/faker/v4"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/randx"
+	"github.com/ory/x/sqlcon"
+	"github.com/ory/x/sqlxx"
+
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/persistence"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/x"
+)
+
+func TestFlowPersister(ctx context.Context, p persistence.Persister) func(t *testing.T) {
+	newFlow := func(t *testing.T, expiresIn time.Duration) *crossdevice.Flow {
+		userCode, err := crossdevice.NewUserCode()
+		require.NoError(t, err)
+		return &crossdevice.Flow{
+			ID:         x.NewUUID(),
+			IssuedAt:   time.Now().UTC(),
+			ExpiresAt:  time.Now().UTC().Add(expiresIn),
+			RequestURL: "https://www.ory.sh/self-service/login/cross-device",
+			State:      crossdevice.StatePending,
+			UserCode:   userCode,
+			DeviceCode: randx.MustString(64, randx.AlphaNum),
+			UI:         container.New("https://www.ory.sh/device"),
+		}
+	}
+
+	return func(t *testing.T) {
+		nid, p := testhelpers.NewNetworkUnlessExisting(t, ctx, p)
+
+		t.Run("case=should error when the flow does not exist", func(t *testing.T) {
+			_, err := p.GetCrossDeviceLoginFlow(ctx, x.NewUUID())
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+
+			_, err = p.GetCrossDeviceLoginFlowByUserCode(ctx, "BCDF-GHJK")
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+
+			_, err = p.GetCrossDeviceLoginFlowByDeviceCode(ctx, randx.MustString(64, randx.AlphaNum))
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+		})
+
+		t.Run("case=should create, fetch, and update a flow", func(t *testing.T) {
+			expected := newFlow(t, time.Hour)
+			require.NoError(t, p.CreateCrossDeviceLoginFlow(ctx, expected))
+			assert.Equal(t, nid, expected.NID)
+
+			for _, get := range []func() (*crossdevice.Flow, error){
+				func() (*crossdevice.Flow, error) { return p.GetCrossDeviceLoginFlow(ctx, expected.ID) },
+				func() (*crossdevice.Flow, error) { return p.GetCrossDeviceLoginFlowByUserCode(ctx, expected.UserCode) },
+				func() (*crossdevice.Flow, error) {
+					return p.GetCrossDeviceLoginFlowByDeviceCode(ctx, expected.DeviceCode)
+				},
+			} {
+				actual, err := get()
+				require.NoError(t, err)
+				assert.Equal(t, expected.ID, actual.ID)
+				assert.Equal(t, expected.UserCode, actual.UserCode)
+				assert.NotEqual(t, expected.DeviceCode, actual.DeviceCode, "the device code must be hashed")
+				assert.Equal(t, crossdevice.StatePending, actual.State)
+				assert.False(t, actual.IdentityID.Valid)
+				x.AssertEqualTime(t, expected.ExpiresAt, actual.ExpiresAt)
+			}
+
+			var i identity.Identity
+			require.NoError(t, faker.FakeData(&i))
+			require.NoError(t, p.CreateIdentity(ctx, &i))
+
+			expected.State = crossdevice.StateApproved
+			expected.IdentityID = uuid.NullUUID{UUID: i.ID, Valid: true}
+			expected.ApprovedAt = sqlxx.NullTime(time.Now().UTC())
+			require.NoError(t, p.UpdateCrossDeviceLoginFlow(ctx, expected))
+
+			actual, err := p.GetCrossDeviceLoginFlow(ctx, expected.ID)
+			require.NoError(t, err)
+			assert.Equal(t, crossdevice.StateApproved, actual.State)
+			assert.Equal(t, i.ID, actual.IdentityID.UUID)
+
+			actual, err = p.GetCrossDeviceLoginFlowByDeviceCode(ctx, expected.DeviceCode)
+			require.NoError(t, err, "updating the flow must not change the device code")
+			assert.Equal(t, expected.ID, actual.ID)
+		})
+
+		t.Run("case=should use an approved flow only once", func(t *testing.T) {
+			pending := newFlow(t, time.Hour)
+			require.NoError(t, p.CreateCrossDeviceLoginFlow(ctx, pending))
+			require.ErrorIs(t, p.UseApprovedCrossDeviceLoginFlow(ctx, pending.ID), sqlcon.ErrNoRows)
+
+			approved := newFlow(t, time.Hour)
+			approved.State = crossdevice.StateApproved
+			require.NoError(t, p.CreateCrossDeviceLoginFlow(ctx, approved))
+
+			_, other := testhelpers.NewNetwork(t, ctx, p)
+			require.ErrorIs(t, other.UseApprovedCrossDeviceLoginFlow(ctx, approved.ID), sqlcon.ErrNoRows)
+
+			require.NoError(t, p.UseApprovedCrossDeviceLoginFlow(ctx, approved.ID))
+			require.ErrorIs(t, p.UseApprovedCrossDeviceLoginFlow(ctx, approved.ID), sqlcon.ErrNoRows)
+
+			actual, err := p.GetCrossDeviceLoginFlow(ctx, approved.ID)
+			require.NoError(t, err)
+			assert.Equal(t, crossdevice.StateUsed, actual.State)
+		})
+
+		t.Run("case=should only respond to a pending flow once", func(t *testing.T) {
+			f := newFlow(t, time.Hour)
+			require.NoError(t, p.CreateCrossDeviceLoginFlow(ctx, f))
+
+			_, other := testhelpers.NewNetwork(t, ctx, p)
+			f.State = crossdevice.StateRejected
+			require.ErrorIs(t, other.UpdatePendingCrossDeviceLoginFlow(ctx, f), sqlcon.ErrNoRows)
+
+			require.NoError(t, p.UpdatePendingCrossDeviceLoginFlow(ctx, f))
+
+			f.State = crossdevice.StateApproved
+			require.ErrorIs(t, p.UpdatePendingCrossDeviceLoginFlow(ctx, f), sqlcon.ErrNoRows)
+
+			actual, err := p.GetCrossDeviceLoginFlow(ctx, f.ID)
+			require.NoError(t, err)
+			assert.Equal(t, crossdevice.StateRejected, actual.State)
+		})
+
+		t.Run("case=should not leak flows across networks", func(t *testing.T) {
+			expected := newFlow(t, time.Hour)
+			require.NoError(t, p.CreateCrossDeviceLoginFlow(ctx, expected))
+
+			_, other := testhelpers.NewNetwork(t, ctx, p)
+			_, err := other.GetCrossDeviceLoginFlow(ctx, expected.ID)
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+			_, err = other.GetCrossDeviceLoginFlowByUserCode(ctx, expected.UserCode)
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+			_, err = other.GetCrossDeviceLoginFlowByDeviceCode(ctx, expected.DeviceCode)
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+
+			expected.State = crossdevice.StateRejected
+			require.ErrorIs(t, other.UpdateCrossDeviceLoginFlow(ctx, expected), sqlcon.ErrNoRows)
+		})
+
+		t.Run("case=should delete expired flows", func(t *testing.T) {
+			expired := newFlow(t, -time.Hour)
+			active := newFlow(t, time.Hour)
+			require.NoError(t, p.CreateCrossDeviceLoginFlow(ctx, expired))
+			require.NoError(t, p.CreateCrossDeviceLoginFlow(ctx, active))
+
+			require.NoError(t, p.DeleteExpiredCrossDeviceLoginFlows(ctx, time.Now().UTC(), 100))
+
+			_, err := p.GetCrossDeviceLoginFlow(ctx, expired.ID)
+			require.ErrorIs(t, err, sqlcon.ErrNoRows)
+			_, err = p.GetCrossDeviceLoginFlow(ctx, active.ID)
+			require.NoError(t, err)
+		})
+	}
+}
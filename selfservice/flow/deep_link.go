@@ -0,0 +1,82 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/jwksx"
+	"github.com/ory/x/urlx"
+)
+
+type deepLinkDependencies interface {
+	config.Provider
+	x.HTTPClientProvider
+	x.JWKSFetchProvider
+}
+
+// NewContinueWithNativeDeepLink returns the continue_with action asking native apps to open the
+// configured deep link. The link carries a JSON Web Token describing the completed flow in the
+// `payload` query parameter. Additional claims are added to the token.
+func NewContinueWithNativeDeepLink(ctx context.Context, d deepLinkDependencies, conf *config.NativeDeepLink, f Flow, identityID uuid.UUID, claims map[string]any) (*ContinueWithOpenDeepLink, error) {
+	deepLink, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to parse the native deep link URL: %s", err))
+	}
+
+	key, err := d.JWKSFetcher().ResolveKey(
+		ctx,
+		conf.JWKSURL,
+		jwksx.WithCacheEnabled(),
+		jwksx.WithCacheTTL(time.Hour),
+		jwksx.WithHTTPClient(d.HTTPClient(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	alg := jwt.GetSigningMethod(key.Algorithm())
+	if alg == nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The JSON Web Key must include a valid \"alg\" parameter but \"%s\" was given.", key.Algorithm()))
+	}
+
+	var privateKey interface{}
+	if err := key.Raw(&privateKey); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to decode the given private key."))
+	}
+
+	now := time.Now()
+	token := jwt.New(alg)
+	token.Header["kid"] = key.KeyID()
+	payload := jwt.MapClaims{}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	payload["jti"] = uuid.Must(uuid.NewV4()).String()
+	payload["iss"] = d.Config().SelfPublicURL(ctx).String()
+	payload["sub"] = identityID.String()
+	payload["flow_id"] = f.GetID().String()
+	payload["flow"] = string(f.GetFlowName())
+	payload["iat"] = now.Unix()
+	payload["nbf"] = now.Unix()
+	payload["exp"] = now.Add(conf.TTL).Unix()
+	token.Claims = payload
+
+	signed, err := token.SignedString(privateKey)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to sign the native deep link payload."))
+	}
+
+	q := deepLink.Query()
+	q.Set("payload", signed)
+	return NewContinueWithOpenDeepLink(urlx.CopyWithQuery(deepLink, q).String()), nil
+}
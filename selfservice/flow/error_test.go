@@ -101,6 +101,10 @@ func (t *testFlow) GetTransientPayload() json.RawMessage {
 	return nil
 }
 
+func (t *testFlow) GetBrand() string {
+	return ""
+}
+
 func newTestFlow(r *http.Request, flowType Type) Flow {
 	id := x.NewUUID()
 	requestURL := x.RequestURL(r).String()
@@ -41,6 +41,7 @@ type Flow interface {
 	SetState(State)
 	GetFlowName() FlowName
 	GetTransientPayload() json.RawMessage
+	GetBrand() string
 }
 
 type FlowWithRedirect interface {
@@ -0,0 +1,120 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package funnel
+
+import (
+	"strconv"
+	"time"
+)
+
+// Flow Funnel Bucket
+//
+// A bucket aggregates the flows created in a time range.
+//
+// swagger:model flowFunnelBucket
+type Bucket struct {
+	// The start of the time range, inclusive.
+	//
+	// required: true
+	Start time.Time `json:"start"`
+
+	// The end of the time range, exclusive.
+	//
+	// required: true
+	End time.Time `json:"end"`
+
+	// The number of flows created.
+	//
+	// required: true
+	Created int `json:"created"`
+
+	// The number of flows which were submitted at least once.
+	//
+	// required: true
+	Submitted int `json:"submitted"`
+
+	// The number of flows which succeeded.
+	//
+	// required: true
+	Succeeded int `json:"succeeded"`
+
+	// The number of flows which were neither submitted nor succeeded before they expired.
+	//
+	// required: true
+	AbandonedBeforeSubmission int `json:"abandoned_before_submission"`
+
+	// The number of flows which expired after at least one failed submission.
+	//
+	// required: true
+	AbandonedAfterFailure int `json:"abandoned_after_failure"`
+
+	// The flows which expired after at least one failed submission, keyed by the number of
+	// failed submissions.
+	//
+	// required: true
+	AbandonedByFailedSubmissions map[string]int `json:"abandoned_by_failed_submissions"`
+
+	// The number of flows which have not yet succeeded or expired.
+	//
+	// required: true
+	InProgress int `json:"in_progress"`
+
+	// The total number of failed submissions.
+	//
+	// required: true
+	FailedSubmissions int `json:"failed_submissions"`
+}
+
+// Aggregator buckets the flow funnel entries created in the time range [from, to).
+type Aggregator struct {
+	from, now  time.Time
+	bucketSize time.Duration
+	buckets    []Bucket
+}
+
+func NewAggregator(from, to time.Time, bucketSize time.Duration, now time.Time) *Aggregator {
+	a := &Aggregator{from: from, now: now, bucketSize: bucketSize}
+	for start := from; start.Before(to); start = start.Add(bucketSize) {
+		end := start.Add(bucketSize)
+		if end.After(to) {
+			end = to
+		}
+		a.buckets = append(a.buckets, Bucket{Start: start, End: end, AbandonedByFailedSubmissions: map[string]int{}})
+	}
+	return a
+}
+
+// Add adds the entry to its bucket. Entries outside of the time range are ignored.
+func (a *Aggregator) Add(e *Entry) {
+	if e.CreatedAt.Before(a.from) {
+		return
+	}
+	k := int(e.CreatedAt.Sub(a.from) / a.bucketSize)
+	if k >= len(a.buckets) || e.CreatedAt.Before(a.buckets[k].Start) || !e.CreatedAt.Before(a.buckets[k].End) {
+		return
+	}
+
+	b := &a.buckets[k]
+	b.Created++
+	b.FailedSubmissions += e.FailedSubmissions
+	if e.SubmittedAt.Valid {
+		b.Submitted++
+	}
+
+	switch {
+	case e.SucceededAt.Valid:
+		b.Succeeded++
+	case e.ExpiresAt.After(a.now):
+		b.InProgress++
+	case e.FailedSubmissions > 0:
+		b.AbandonedAfterFailure++
+		b.AbandonedByFailedSubmissions[strconv.Itoa(e.FailedSubmissions)]++
+	default:
+		b.AbandonedBeforeSubmission++
+	}
+}
+
+func (a *Aggregator) Buckets() []Bucket {
+	return a.buckets
+}
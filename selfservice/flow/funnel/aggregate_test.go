@@ -0,0 +1,57 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package funnel_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/selfservice/flow/funnel"
+)
+
+func TestAggregator(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(90 * time.Minute)
+	now := from.Add(2 * time.Hour)
+
+	a := funnel.NewAggregator(from, to, time.Hour, now)
+	at := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: from.Add(d), Valid: true} }
+	for _, e := range []funnel.Entry{
+		// abandoned before submission
+		{CreatedAt: from, ExpiresAt: from.Add(time.Minute)},
+		// abandoned after two failed submissions
+		{CreatedAt: from.Add(time.Minute), ExpiresAt: from.Add(time.Hour), SubmittedAt: at(2 * time.Minute), FailedSubmissions: 2},
+		// succeeded after one failed submission
+		{CreatedAt: from.Add(time.Minute), ExpiresAt: from.Add(time.Hour), SubmittedAt: at(2 * time.Minute), FailedSubmissions: 1, SucceededAt: at(3 * time.Minute)},
+		// in progress in the second, shorter bucket
+		{CreatedAt: from.Add(80 * time.Minute), ExpiresAt: now.Add(time.Hour)},
+		// outside of the time range
+		{CreatedAt: from.Add(-time.Minute), ExpiresAt: from},
+		{CreatedAt: to, ExpiresAt: now},
+	} {
+		a.Add(&e)
+	}
+
+	buckets := a.Buckets()
+	require.Len(t, buckets, 2)
+
+	assert.Equal(t, from, buckets[0].Start)
+	assert.Equal(t, from.Add(time.Hour), buckets[0].End)
+	assert.Equal(t, 3, buckets[0].Created)
+	assert.Equal(t, 2, buckets[0].Submitted)
+	assert.Equal(t, 1, buckets[0].Succeeded)
+	assert.Equal(t, 1, buckets[0].AbandonedBeforeSubmission)
+	assert.Equal(t, 1, buckets[0].AbandonedAfterFailure)
+	assert.Equal(t, map[string]int{"2": 1}, buckets[0].AbandonedByFailedSubmissions)
+	assert.Equal(t, 0, buckets[0].InProgress)
+	assert.Equal(t, 3, buckets[0].FailedSubmissions)
+
+	assert.Equal(t, to, buckets[1].End)
+	assert.Equal(t, 1, buckets[1].Created)
+	assert.Equal(t, 1, buckets[1].InProgress)
+}
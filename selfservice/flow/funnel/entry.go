@@ -0,0 +1,62 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package funnel
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/ory/kratos/selfservice/flow"
+)
+
+// Entry tracks the progress of a single self-service flow through the funnel.
+type Entry struct {
+	// ID is the ID of the tracked flow.
+	ID uuid.UUID `json:"id" faker:"-" db:"id"`
+
+	FlowName flow.FlowName `json:"flow" faker:"-" db:"flow"`
+
+	// ExpiresAt is the time the flow expires. Flows which expire without succeeding are abandoned.
+	ExpiresAt time.Time `json:"expires_at" faker:"time_type" db:"expires_at"`
+
+	// SubmittedAt is the time the flow was submitted for the first time.
+	SubmittedAt sql.NullTime `json:"submitted_at" faker:"-" db:"submitted_at"`
+
+	// FailedSubmissions is the number of submissions which failed.
+	FailedSubmissions int `json:"failed_submissions" faker:"-" db:"failed_submissions"`
+
+	// SucceededAt is the time the flow succeeded.
+	SucceededAt sql.NullTime `json:"succeeded_at" faker:"-" db:"succeeded_at"`
+
+	// CreatedAt is a helper struct field for gobuffalo.pop.
+	CreatedAt time.Time `json:"created_at" faker:"-" db:"created_at"`
+
+	// UpdatedAt is a helper struct field for gobuffalo.pop.
+	UpdatedAt time.Time `json:"updated_at" faker:"-" db:"updated_at"`
+
+	NID uuid.UUID `json:"-" faker:"-" db:"nid"`
+}
+
+func (e Entry) TableName(context.Context) string {
+	return "selfservice_flow_funnel_entries"
+}
+
+type (
+	Persister interface {
+		CreateFlowFunnelEntry(ctx context.Context, e *Entry) error
+		// MarkFlowFunnelEntryFailed records a failed submission of the flow.
+		MarkFlowFunnelEntryFailed(ctx context.Context, id uuid.UUID) error
+		// MarkFlowFunnelEntrySucceeded records that the flow succeeded.
+		MarkFlowFunnelEntrySucceeded(ctx context.Context, id uuid.UUID) error
+		// ForEachFlowFunnelEntry calls fn for every entry of the flow created in the time range [from, to).
+		ForEachFlowFunnelEntry(ctx context.Context, name flow.FlowName, from, to time.Time, fn func(*Entry) error) error
+		DeleteExpiredFlowFunnelEntries(ctx context.Context, deleteOlder time.Time, pageSize int) error
+	}
+	PersistenceProvider interface {
+		FlowFunnelPersister() Persister
+	}
+)
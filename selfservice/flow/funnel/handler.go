@@ -0,0 +1,197 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package funnel
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/x"
+)
+
+const (
+	RouteFunnel = "/flows/:flow/funnel"
+
+	defaultBucketSize = 24 * time.Hour
+	defaultTimeRange  = 7 * 24 * time.Hour
+	maxBuckets        = 1000
+)
+
+var trackedFlows = map[flow.FlowName]bool{
+	flow.LoginFlow:        true,
+	flow.RegistrationFlow: true,
+	flow.SettingsFlow:     true,
+	flow.RecoveryFlow:     true,
+	flow.VerificationFlow: true,
+}
+
+type (
+	handlerDependencies interface {
+		config.Provider
+		x.WriterProvider
+		PersistenceProvider
+	}
+	Handler struct {
+		d handlerDependencies
+	}
+	HandlerProvider interface {
+		FlowFunnelHandler() *Handler
+	}
+)
+
+func NewHandler(d handlerDependencies) *Handler {
+	return &Handler{d: d}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	public.GET(x.AdminPrefix+RouteFunnel, x.RedirectToAdminRoute(h.d))
+}
+
+func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.GET(RouteFunnel, h.getFlowFunnel)
+}
+
+// Get Flow Funnel Parameters
+//
+// swagger:parameters getFlowFunnel
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type getFlowFunnel struct {
+	// The flow to aggregate. One of `login`, `registration`, `settings`, `recovery`, or `verification`.
+	//
+	// required: true
+	// in: path
+	Flow string `json:"flow"`
+
+	// The start of the time range as an RFC 3339 timestamp. Defaults to seven days before `to`.
+	//
+	// in: query
+	From string `json:"from"`
+
+	// The end of the time range as an RFC 3339 timestamp. Defaults to now.
+	//
+	// in: query
+	To string `json:"to"`
+
+	// The size of the time buckets, for example `1h` or `24h`. Defaults to `24h`.
+	//
+	// in: query
+	BucketSize string `json:"bucket_size"`
+}
+
+// Flow Funnel
+//
+// swagger:model flowFunnel
+type Funnel struct {
+	// The aggregated flow.
+	//
+	// required: true
+	Flow flow.FlowName `json:"flow"`
+
+	// The size of the time buckets.
+	//
+	// required: true
+	BucketSize string `json:"bucket_size"`
+
+	// The buckets, ordered by time.
+	//
+	// required: true
+	Buckets []Bucket `json:"buckets"`
+}
+
+// swagger:route GET /admin/flows/{flow}/funnel identity getFlowFunnel
+//
+// # Get the Funnel of a Self-Service Flow
+//
+// Aggregates the self-service flows created in a time range into buckets and returns how many of
+// them were submitted, succeeded, or were abandoned before or after a failed submission.
+//
+// Flows are only tracked if `selfservice.funnel_tracking.enabled` is set.
+//
+//	Produces:
+//	- application/json
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: flowFunnel
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) getFlowFunnel(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := flow.FlowName(ps.ByName("flow"))
+	if !trackedFlows[name] {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Flow %q is not tracked, use one of login, registration, settings, recovery, or verification.", name)))
+		return
+	}
+
+	now := time.Now().UTC()
+	query := r.URL.Query()
+
+	to, err := parseTime(query.Get("to"), now)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to parse the to parameter: %s", err)))
+		return
+	}
+
+	from, err := parseTime(query.Get("from"), to.Add(-defaultTimeRange))
+	if err != nil {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to parse the from parameter: %s", err)))
+		return
+	}
+
+	bucketSize := defaultBucketSize
+	if raw := query.Get("bucket_size"); raw != "" {
+		if bucketSize, err = time.ParseDuration(raw); err != nil {
+			h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to parse the bucket_size parameter: %s", err)))
+			return
+		}
+	}
+
+	if !from.Before(to) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("The from parameter must be before the to parameter.")))
+		return
+	} else if bucketSize < time.Minute {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("The bucket_size parameter must be at least one minute.")))
+		return
+	} else if to.Sub(from)/bucketSize >= maxBuckets {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The time range must not contain more than %d buckets.", maxBuckets)))
+		return
+	}
+
+	a := NewAggregator(from, to, bucketSize, now)
+	if err := h.d.FlowFunnelPersister().ForEachFlowFunnelEntry(r.Context(), name, from, to, func(e *Entry) error {
+		a.Add(e)
+		return nil
+	}); err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.d.Writer().Write(w, r, &Funnel{
+		Flow:       name,
+		BucketSize: bucketSize.String(),
+		Buckets:    a.Buckets(),
+	})
+}
+
+func parseTime(raw string, fallback time.Time) (time.Time, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.WithStack(err)
+	}
+	return t.UTC(), nil
+}
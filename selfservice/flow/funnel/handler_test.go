@@ -0,0 +1,118 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package funnel_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
+	"github.com/ory/kratos/x"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	testhelpers.StrategyEnable(t, conf, identity.CredentialsTypePassword.String(), true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceFunnelTrackingEnabled, true)
+
+	publicTS, adminTS := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	getFunnel := func(t *testing.T, name string, query url.Values, expectCode int) gjson.Result {
+		t.Helper()
+		res, err := adminTS.Client().Get(adminTS.URL + "/admin/flows/" + name + "/funnel?" + query.Encode())
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=tracks registration flows", func(t *testing.T) {
+		abandoned := testhelpers.InitializeRegistrationFlowViaAPI(t, publicTS.Client(), publicTS)
+		failed := testhelpers.InitializeRegistrationFlowViaAPI(t, publicTS.Client(), publicTS)
+
+		for range 2 {
+			res, err := publicTS.Client().Post(failed.Ui.Action, "application/json", bytes.NewBufferString(`{"method":"password","password":"short","traits":{"email":"not-an-email"}}`))
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		}
+
+		// Expire both flows so that they count as abandoned.
+		for _, id := range []string{abandoned.Id, failed.Id} {
+			require.NoError(t, reg.Persister().GetConnection(ctx).RawQuery(
+				"UPDATE selfservice_flow_funnel_entries SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), id).Exec())
+		}
+
+		actual := getFunnel(t, string(flow.RegistrationFlow), url.Values{
+			"from":        {time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)},
+			"to":          {time.Now().UTC().Add(time.Hour).Format(time.RFC3339)},
+			"bucket_size": {"2h"},
+		}, http.StatusOK)
+
+		assert.Equal(t, "registration", actual.Get("flow").String())
+		assert.Equal(t, "2h0m0s", actual.Get("bucket_size").String())
+		require.Len(t, actual.Get("buckets").Array(), 1, "%s", actual.Raw)
+
+		bucket := actual.Get("buckets.0")
+		assert.EqualValues(t, 2, bucket.Get("created").Int(), "%s", bucket.Raw)
+		assert.EqualValues(t, 1, bucket.Get("submitted").Int(), "%s", bucket.Raw)
+		assert.EqualValues(t, 0, bucket.Get("succeeded").Int(), "%s", bucket.Raw)
+		assert.EqualValues(t, 1, bucket.Get("abandoned_before_submission").Int(), "%s", bucket.Raw)
+		assert.EqualValues(t, 1, bucket.Get("abandoned_after_failure").Int(), "%s", bucket.Raw)
+		assert.EqualValues(t, 1, bucket.Get("abandoned_by_failed_submissions.2").Int(), "%s", bucket.Raw)
+		assert.EqualValues(t, 2, bucket.Get("failed_submissions").Int(), "%s", bucket.Raw)
+	})
+
+	t.Run("case=records succeeded flows", func(t *testing.T) {
+		id := x.NewUUID()
+		reg.FlowFunnelRecorder().FlowCreated(ctx, flow.LoginFlow, id, time.Now().UTC().Add(time.Hour))
+		reg.FlowFunnelRecorder().FlowSucceeded(ctx, flow.LoginFlow, id)
+
+		bucket := getFunnel(t, string(flow.LoginFlow), url.Values{}, http.StatusOK).Get("buckets.@reverse.0")
+		assert.EqualValues(t, 1, bucket.Get("created").Int(), "%s", bucket.Raw)
+		assert.EqualValues(t, 1, bucket.Get("submitted").Int(), "%s", bucket.Raw)
+		assert.EqualValues(t, 1, bucket.Get("succeeded").Int(), "%s", bucket.Raw)
+	})
+
+	t.Run("case=does not store flows if tracking is disabled", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceFunnelTrackingEnabled, false)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceFunnelTrackingEnabled, true) })
+
+		reg.FlowFunnelRecorder().FlowCreated(ctx, flow.SettingsFlow, x.NewUUID(), time.Now().UTC().Add(time.Hour))
+
+		var count int
+		require.NoError(t, reg.FlowFunnelPersister().ForEachFlowFunnelEntry(ctx, flow.SettingsFlow, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(*funnel.Entry) error {
+			count++
+			return nil
+		}))
+		assert.Zero(t, count)
+	})
+
+	t.Run("case=rejects invalid parameters", func(t *testing.T) {
+		getFunnel(t, "cross_device_login", url.Values{}, http.StatusBadRequest)
+		getFunnel(t, "registration", url.Values{"bucket_size": {"1s"}}, http.StatusBadRequest)
+		getFunnel(t, "registration", url.Values{"bucket_size": {"not-a-duration"}}, http.StatusBadRequest)
+		getFunnel(t, "registration", url.Values{"from": {"yesterday"}}, http.StatusBadRequest)
+		getFunnel(t, "registration", url.Values{"from": {time.Now().Add(time.Hour).Format(time.RFC3339)}}, http.StatusBadRequest)
+		getFunnel(t, "registration", url.Values{"bucket_size": {"1m"}}, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,98 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package funnel
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/x"
+)
+
+const (
+	eventCreated   = "created"
+	eventFailed    = "failed"
+	eventSucceeded = "succeeded"
+)
+
+var flowFunnelEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kratos_selfservice_flow_funnel_events_total",
+	Help: "Number of self-service flows which were created, failed a submission, or succeeded, labelled with the flow and the event.",
+}, []string{"flow", "event"})
+
+// RecorderCollectors returns the Prometheus collectors of the flow funnel recorder.
+// They are registered by the registry's metrics setup.
+func RecorderCollectors() []prometheus.Collector {
+	return []prometheus.Collector{flowFunnelEvents}
+}
+
+type (
+	recorderDependencies interface {
+		config.Provider
+		x.LoggingProvider
+		PersistenceProvider
+	}
+
+	// Recorder records how self-service flows progress, so that the flows can be aggregated
+	// into funnels. Metrics are always recorded, the individual flows are only stored if
+	// `selfservice.funnel_tracking.enabled` is set.
+	//
+	// Recording never fails the flow, errors are logged instead.
+	Recorder struct {
+		d recorderDependencies
+	}
+	RecorderProvider interface {
+		FlowFunnelRecorder() *Recorder
+	}
+)
+
+func NewRecorder(d recorderDependencies) *Recorder {
+	return &Recorder{d: d}
+}
+
+// FlowCreated records that the flow was created.
+func (r *Recorder) FlowCreated(ctx context.Context, name flow.FlowName, id uuid.UUID, expiresAt time.Time) {
+	flowFunnelEvents.WithLabelValues(string(name), eventCreated).Inc()
+	if !r.d.Config().SelfServiceFunnelTrackingEnabled(ctx) {
+		return
+	}
+
+	if err := r.d.FlowFunnelPersister().CreateFlowFunnelEntry(ctx, &Entry{
+		ID:        id,
+		FlowName:  name,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		r.d.Logger().WithError(err).WithField("flow_id", id).WithField("flow", name).Warn("Unable to record the creation of the flow for funnel tracking.")
+	}
+}
+
+// FlowFailed records that a submission of the flow failed. Submissions of expired flows are
+// not recorded, as the flow is replaced by a new one.
+func (r *Recorder) FlowFailed(ctx context.Context, name flow.FlowName, id uuid.UUID) {
+	flowFunnelEvents.WithLabelValues(string(name), eventFailed).Inc()
+	if !r.d.Config().SelfServiceFunnelTrackingEnabled(ctx) {
+		return
+	}
+
+	if err := r.d.FlowFunnelPersister().MarkFlowFunnelEntryFailed(ctx, id); err != nil {
+		r.d.Logger().WithError(err).WithField("flow_id", id).WithField("flow", name).Warn("Unable to record the failed submission of the flow for funnel tracking.")
+	}
+}
+
+// FlowSucceeded records that the flow succeeded.
+func (r *Recorder) FlowSucceeded(ctx context.Context, name flow.FlowName, id uuid.UUID) {
+	flowFunnelEvents.WithLabelValues(string(name), eventSucceeded).Inc()
+	if !r.d.Config().SelfServiceFunnelTrackingEnabled(ctx) {
+		return
+	}
+
+	if err := r.d.FlowFunnelPersister().MarkFlowFunnelEntrySucceeded(ctx, id); err != nil {
+		r.d.Logger().WithError(err).WithField("flow_id", id).WithField("flow", name).Warn("Unable to record the success of the flow for funnel tracking.")
+	}
+}
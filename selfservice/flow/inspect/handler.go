@@ -0,0 +1,209 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package inspect
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/pagination/keysetpagination"
+	"github.com/ory/x/pagination/migrationpagination"
+)
+
+const (
+	RouteCollection = "/self-service/flows"
+
+	// StateErrored is not stored on the flows. It lists the flows whose UI shows an error.
+	StateErrored flow.State = "errored"
+)
+
+var listedFlows = map[flow.FlowName]bool{
+	flow.LoginFlow:        true,
+	flow.RegistrationFlow: true,
+	flow.SettingsFlow:     true,
+	flow.RecoveryFlow:     true,
+	flow.VerificationFlow: true,
+}
+
+var listedStates = map[flow.State]bool{
+	flow.StateChooseMethod:    true,
+	flow.StateEmailSent:       true,
+	flow.StatePassedChallenge: true,
+	flow.StateShowForm:        true,
+	flow.StateSuccess:         true,
+	StateErrored:              true,
+}
+
+type (
+	handlerDependencies interface {
+		config.Provider
+		x.WriterProvider
+		PersistenceProvider
+	}
+	Handler struct {
+		d handlerDependencies
+	}
+	HandlerProvider interface {
+		FlowInspectionHandler() *Handler
+	}
+)
+
+func NewHandler(d handlerDependencies) *Handler {
+	return &Handler{d: d}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	public.GET(x.AdminPrefix+RouteCollection, x.RedirectToAdminRoute(h.d))
+}
+
+func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.GET(RouteCollection, h.listSelfServiceFlows)
+}
+
+// List Self-Service Flows Parameters
+//
+// swagger:parameters listSelfServiceFlows
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type listSelfServiceFlows struct {
+	keysetpagination.RequestParameters
+
+	// The flow to list. One of `login`, `registration`, `settings`, `recovery`, or `verification`.
+	//
+	// required: true
+	// in: query
+	Type string `json:"type"`
+
+	// Only list flows in this state. Besides the states of the flows, `errored` lists the flows
+	// whose UI shows at least one error message.
+	//
+	// in: query
+	State string `json:"state"`
+
+	// Only list flows of this identity. Login and registration flows do not belong to an identity.
+	//
+	// in: query
+	Identity string `json:"identity"`
+
+	// Only list expired flows if true and only active flows if false.
+	//
+	// in: query
+	Expired *bool `json:"expired"`
+}
+
+// Paginated Self-Service Flow List Response
+//
+// swagger:response listSelfServiceFlows
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type listSelfServiceFlowsResponse struct {
+	migrationpagination.ResponseHeaderAnnotation
+
+	// List of flows
+	//
+	// in:body
+	Body []Summary
+}
+
+// swagger:route GET /admin/self-service/flows identity listSelfServiceFlows
+//
+// # List Self-Service Flows
+//
+// Lists the self-service flows of a type, newest first, including expired flows which were not
+// yet cleaned up. Use this endpoint to inspect the UI state and errors of flows, for example to
+// debug why a user can not complete a flow.
+//
+// Secrets shown in the UI of the flows, such as TOTP secrets, are not included. When listing
+// errored flows, a page may contain fewer flows than requested even if more flows follow.
+//
+//	Produces:
+//	- application/json
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: listSelfServiceFlows
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) listSelfServiceFlows(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	filter, opts, err := parseFilter(r)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	flows, nextPage, err := h.d.FlowInspectionPersister().ListSelfServiceFlows(r.Context(), filter, opts)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	for k := range flows {
+		if err := flows[k].Redact(r.Context()); err != nil {
+			h.d.Writer().WriteError(w, r, err)
+			return
+		}
+	}
+
+	u := *r.URL
+	keysetpagination.Header(w, &u, nextPage)
+	h.d.Writer().Write(w, r, flows)
+}
+
+func parseFilter(r *http.Request) (Filter, []keysetpagination.Option, error) {
+	query := r.URL.Query()
+
+	filter := Filter{
+		Flow:  flow.FlowName(query.Get("type")),
+		State: flow.State(query.Get("state")),
+	}
+	if !listedFlows[filter.Flow] {
+		return Filter{}, nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to list flows of type %q, use one of login, registration, settings, recovery, or verification.", filter.Flow))
+	}
+
+	if filter.State == StateErrored {
+		filter.Errored, filter.State = true, ""
+	} else if filter.State != "" && !listedStates[filter.State] {
+		return Filter{}, nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to list flows in state %q.", filter.State))
+	}
+
+	if raw := query.Get("identity"); raw != "" {
+		if filter.Flow == flow.LoginFlow || filter.Flow == flow.RegistrationFlow {
+			return Filter{}, nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Flows of type %q do not belong to an identity.", filter.Flow))
+		}
+		id, err := uuid.FromString(raw)
+		if err != nil {
+			return Filter{}, nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to parse the identity parameter: %s", err))
+		}
+		filter.IdentityID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	if raw := query.Get("expired"); raw != "" {
+		expired, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Filter{}, nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to parse the expired parameter: %s", err))
+		}
+		filter.Expired = &expired
+	}
+
+	opts, err := keysetpagination.Parse(query, keysetpagination.NewMapPageToken)
+	if err != nil {
+		return Filter{}, nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to parse the pagination parameters: %s", err))
+	}
+
+	return filter, opts, nil
+}
@@ -0,0 +1,190 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package inspect_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/pagination/keysetpagination"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	testhelpers.StrategyEnable(t, conf, identity.CredentialsTypePassword.String(), true)
+
+	publicTS, adminTS := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	listFlows := func(t *testing.T, query url.Values, expectCode int) (gjson.Result, *http.Response) {
+		t.Helper()
+		res, err := adminTS.Client().Get(adminTS.URL + "/admin/self-service/flows?" + query.Encode())
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body), res
+	}
+
+	expired := testhelpers.InitializeLoginFlowViaAPI(t, publicTS.Client(), publicTS, false)
+	require.NoError(t, reg.Persister().GetConnection(ctx).RawQuery(
+		"UPDATE selfservice_login_flows SET expires_at = ? WHERE id = ?", time.Now().UTC().Add(-time.Minute), expired.Id).Exec())
+
+	errored := testhelpers.InitializeLoginFlowViaAPI(t, publicTS.Client(), publicTS, false)
+	res, err := publicTS.Client().Post(errored.Ui.Action, "application/json", bytes.NewBufferString(`{"method":"password","identifier":"unknown@ory.sh","password":"not-the-password"}`))
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	t.Run("case=lists flows newest first", func(t *testing.T) {
+		actual, _ := listFlows(t, url.Values{"type": {"login"}}, http.StatusOK)
+		require.Len(t, actual.Array(), 2, "%s", actual.Raw)
+		assert.Equal(t, errored.Id, actual.Get("0.id").String())
+		assert.Equal(t, "login", actual.Get("0.flow").String())
+		assert.Equal(t, "api", actual.Get("0.type").String())
+		assert.False(t, actual.Get("0.expired").Bool())
+		assert.Equal(t, expired.Id, actual.Get("1.id").String())
+		assert.True(t, actual.Get("1.expired").Bool())
+		assert.Empty(t, actual.Get("1.errors").Array())
+	})
+
+	t.Run("case=filters errored flows", func(t *testing.T) {
+		actual, _ := listFlows(t, url.Values{"type": {"login"}, "state": {"errored"}}, http.StatusOK)
+		require.Len(t, actual.Array(), 1, "%s", actual.Raw)
+		assert.Equal(t, errored.Id, actual.Get("0.id").String())
+		assert.EqualValues(t, text.ErrorValidationInvalidCredentials, actual.Get("0.errors.0.id").Int(), "%s", actual.Raw)
+		assert.True(t, actual.Get("0.ui.nodes").IsArray())
+	})
+
+	t.Run("case=filters expired flows", func(t *testing.T) {
+		actual, _ := listFlows(t, url.Values{"type": {"login"}, "expired": {"true"}}, http.StatusOK)
+		require.Len(t, actual.Array(), 1, "%s", actual.Raw)
+		assert.Equal(t, expired.Id, actual.Get("0.id").String())
+
+		actual, _ = listFlows(t, url.Values{"type": {"login"}, "expired": {"false"}, "state": {string(flow.StateChooseMethod)}}, http.StatusOK)
+		require.Len(t, actual.Array(), 1, "%s", actual.Raw)
+		assert.Equal(t, errored.Id, actual.Get("0.id").String())
+	})
+
+	t.Run("case=paginates", func(t *testing.T) {
+		first, res := listFlows(t, url.Values{"type": {"login"}, "page_size": {"1"}}, http.StatusOK)
+		require.Len(t, first.Array(), 1, "%s", first.Raw)
+		assert.Equal(t, errored.Id, first.Get("0.id").String())
+
+		next := keysetpagination.ParseHeader(res).NextToken
+		require.NotEmpty(t, next)
+		second, res := listFlows(t, url.Values{"type": {"login"}, "page_size": {"1"}, "page_token": {next}}, http.StatusOK)
+		require.Len(t, second.Array(), 1, "%s", second.Raw)
+		assert.Equal(t, expired.Id, second.Get("0.id").String())
+		assert.Empty(t, keysetpagination.ParseHeader(res).NextToken)
+	})
+
+	t.Run("case=filters by identity", func(t *testing.T) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"email":"` + testhelpers.RandomEmail() + `"}`)
+		require.NoError(t, reg.IdentityManager().Create(ctx, i))
+
+		f, err := settings.NewFlow(conf, time.Hour, testhelpers.NewTestHTTPRequest(t, "GET", "/self-service/settings/api", nil), i, flow.TypeAPI)
+		require.NoError(t, err)
+		require.NoError(t, reg.SettingsFlowPersister().CreateSettingsFlow(ctx, f))
+
+		actual, _ := listFlows(t, url.Values{"type": {"settings"}, "identity": {i.ID.String()}}, http.StatusOK)
+		require.Len(t, actual.Array(), 1, "%s", actual.Raw)
+		assert.Equal(t, f.ID.String(), actual.Get("0.id").String())
+		assert.Equal(t, i.ID.String(), actual.Get("0.identity_id").String())
+
+		actual, _ = listFlows(t, url.Values{"type": {"settings"}, "identity": {x.NewUUID().String()}}, http.StatusOK)
+		assert.Empty(t, actual.Array(), "%s", actual.Raw)
+	})
+
+	t.Run("case=bounds the rows scanned for errored flows", func(t *testing.T) {
+		// The errored flow is older than the flows of ten full batches of one row.
+		for range 25 {
+			testhelpers.InitializeLoginFlowViaAPI(t, publicTS.Client(), publicTS, false)
+		}
+
+		query := url.Values{"type": {"login"}, "state": {"errored"}, "page_size": {"1"}}
+		actual, res := listFlows(t, query, http.StatusOK)
+		assert.Empty(t, actual.Array(), "%s", actual.Raw)
+
+		var ids []string
+		for next := keysetpagination.ParseHeader(res).NextToken; next != ""; next = keysetpagination.ParseHeader(res).NextToken {
+			query.Set("page_token", next)
+			actual, res = listFlows(t, query, http.StatusOK)
+			for _, f := range actual.Array() {
+				ids = append(ids, f.Get("id").String())
+			}
+		}
+		assert.Equal(t, []string{errored.Id}, ids)
+	})
+
+	t.Run("case=redacts secrets and traits", func(t *testing.T) {
+		email := testhelpers.RandomEmail()
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"email":"` + email + `"}`)
+		require.NoError(t, reg.IdentityManager().Create(ctx, i))
+
+		f, err := settings.NewFlow(conf, time.Hour, testhelpers.NewTestHTTPRequest(t, "GET", "/self-service/settings/api", nil), i, flow.TypeAPI)
+		require.NoError(t, err)
+		f.UI.Nodes.Upsert(node.NewInputField("traits.email", email, node.ProfileGroup, node.InputAttributeTypeEmail))
+		f.UI.Nodes.Upsert(node.NewInputField("csrf_token", "csrf-token-value", node.DefaultGroup, node.InputAttributeTypeHidden))
+		f.UI.Nodes.Upsert(node.NewTextField("totp_secret_key", text.NewInfoSelfServiceSettingsTOTPSecret("totp-secret-value"), node.TOTPGroup))
+		f.UI.Nodes.Upsert(node.NewImageField("totp_qr", "data:image/png;base64,totp-qr-value", node.TOTPGroup))
+		f.UI.Nodes.Upsert(node.NewTextField("lookup_secret_codes", text.NewInfoSelfServiceSettingsLookupSecret("lookup-secret-value"), node.LookupGroup))
+		require.NoError(t, reg.SettingsFlowPersister().CreateSettingsFlow(ctx, f))
+
+		const supportToken = "support-token-0123456789abcdefghijkl"
+		conf.MustSet(ctx, config.ViperKeyAdminAPITokens, []map[string]any{
+			{"id": "support", "token": supportToken, "redacted_traits": []string{"email"}},
+		})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyAdminAPITokens, nil) })
+
+		req, err := http.NewRequest("GET", adminTS.URL+"/admin/self-service/flows?"+url.Values{"type": {"settings"}, "identity": {i.ID.String()}}.Encode(), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+supportToken)
+		res, err := adminTS.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+
+		for _, secret := range []string{email, "csrf-token-value", "totp-secret-value", "totp-qr-value", "lookup-secret-value"} {
+			assert.NotContains(t, string(body), secret)
+		}
+		actual := gjson.ParseBytes(body)
+		assert.True(t, actual.Get(`0.ui.nodes.#(attributes.name=="traits.email")`).Exists(), "%s", body)
+		assert.True(t, actual.Get(`0.ui.nodes.#(attributes.name=="csrf_token")`).Exists(), "%s", body)
+	})
+
+	t.Run("case=rejects invalid parameters", func(t *testing.T) {
+		listFlows(t, url.Values{}, http.StatusBadRequest)
+		listFlows(t, url.Values{"type": {"cross_device_login"}}, http.StatusBadRequest)
+		listFlows(t, url.Values{"type": {"login"}, "state": {"stuck"}}, http.StatusBadRequest)
+		listFlows(t, url.Values{"type": {"login"}, "identity": {x.NewUUID().String()}}, http.StatusBadRequest)
+		listFlows(t, url.Values{"type": {"settings"}, "identity": {"not-a-uuid"}}, http.StatusBadRequest)
+		listFlows(t, url.Values{"type": {"login"}, "expired": {"maybe"}}, http.StatusBadRequest)
+		listFlows(t, url.Values{"type": {"login"}, "page_token": {"not-a-token"}}, http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,210 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package inspect
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/x/pagination/keysetpagination"
+	"github.com/ory/x/sqlxx"
+)
+
+// Self-Service Flow Summary
+//
+// A summary of a self-service flow for debugging purposes.
+//
+// swagger:model selfServiceFlowSummary
+type Summary struct {
+	// The ID of the flow.
+	//
+	// required: true
+	ID uuid.UUID `json:"id" db:"id"`
+
+	// The flow, one of `login`, `registration`, `settings`, `recovery`, or `verification`.
+	//
+	// required: true
+	Flow flow.FlowName `json:"flow" db:"-"`
+
+	// The flow type, either `api` or `browser`.
+	//
+	// required: true
+	Type flow.Type `json:"type" db:"type"`
+
+	// The state of the flow.
+	State flow.State `json:"state,omitempty" db:"state"`
+
+	// The method which was used last to submit the flow.
+	Active sqlxx.NullString `json:"active,omitempty" db:"active_method"`
+
+	// The identity the flow belongs to. Only set for settings and verification flows, and for
+	// recovery flows once the identity is known.
+	IdentityID uuid.NullUUID `json:"identity_id,omitempty" db:"identity_id"`
+
+	// The URL the flow was initialized with.
+	//
+	// required: true
+	RequestURL string `json:"request_url" db:"request_url"`
+
+	// The time the flow was issued at.
+	//
+	// required: true
+	IssuedAt time.Time `json:"issued_at" db:"issued_at"`
+
+	// The time the flow expires at.
+	//
+	// required: true
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+
+	// Whether the flow has expired.
+	//
+	// required: true
+	Expired bool `json:"expired" db:"-"`
+
+	// The UI the flow currently renders. Nodes which show secrets, such as TOTP secrets and lookup
+	// secrets, are removed, and the values of password and hidden inputs are cleared.
+	//
+	// required: true
+	UI *container.Container `json:"ui" db:"ui"`
+
+	// The error messages of the UI, including the messages of its nodes.
+	//
+	// required: true
+	Errors text.Messages `json:"errors" db:"-"`
+
+	// CreatedAt is a helper struct field for gobuffalo.pop.
+	//
+	// required: true
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// UpdatedAt is a helper struct field for gobuffalo.pop.
+	//
+	// required: true
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Hydrate sets the fields which are derived from the stored flow.
+func (s *Summary) Hydrate(name flow.FlowName, now time.Time) {
+	s.Flow = name
+	s.Expired = !s.ExpiresAt.After(now)
+	s.Errors = text.Messages{}
+	if s.UI == nil {
+		return
+	}
+	for _, m := range s.UI.Messages {
+		if m.Type == text.Error {
+			s.Errors = append(s.Errors, m)
+		}
+	}
+	for _, n := range s.UI.Nodes {
+		for _, m := range n.Messages {
+			if m.Type == text.Error {
+				s.Errors = append(s.Errors, m)
+			}
+		}
+	}
+}
+
+// secretNodeGroups are the groups whose text and image nodes show secrets, such as the TOTP secret
+// and QR code, the lookup secrets, or the user code of a cross-device login.
+var secretNodeGroups = map[node.UiNodeGroup]bool{
+	node.TOTPGroup:        true,
+	node.LookupGroup:      true,
+	node.CrossDeviceGroup: true,
+}
+
+// Redact removes the parts of the UI which contain secrets, and the values of the traits which the
+// admin API token of the request is not allowed to read.
+func (s *Summary) Redact(ctx context.Context) error {
+	if s.UI == nil {
+		return nil
+	}
+
+	nodes := make(node.Nodes, 0, len(s.UI.Nodes))
+	traits := []byte("{}")
+	for _, n := range s.UI.Nodes {
+		switch a := n.Attributes.(type) {
+		case *node.TextAttributes, *node.ImageAttributes:
+			if secretNodeGroups[n.Group] {
+				continue
+			}
+		case *node.InputAttributes:
+			if a.Type == node.InputAttributeTypePassword || a.Type == node.InputAttributeTypeHidden {
+				a.FieldValue = nil
+			} else if path, ok := strings.CutPrefix(a.Name, "traits."); ok && a.FieldValue != nil {
+				var err error
+				if traits, err = sjson.SetBytes(traits, path, a.FieldValue); err != nil {
+					return errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to redact the trait %q.", path))
+				}
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	s.UI.Nodes = nodes
+
+	// The trait values are redacted like the traits of identities, so that the redacted paths of
+	// the token apply to both.
+	redacted, err := identity.RedactForAdminAPIToken(ctx, identity.Identity{Traits: identity.Traits(traits)})
+	if err != nil {
+		return err
+	}
+	for _, n := range s.UI.Nodes {
+		if a, ok := n.Attributes.(*node.InputAttributes); ok {
+			if path, ok := strings.CutPrefix(a.Name, "traits."); ok && !gjson.GetBytes(redacted.Traits, path).Exists() {
+				a.FieldValue = nil
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s Summary) PageToken() keysetpagination.PageToken {
+	return keysetpagination.MapPageToken{
+		"id":         s.ID.String(),
+		"created_at": s.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func (s Summary) DefaultPageToken() keysetpagination.PageToken {
+	return keysetpagination.MapPageToken{
+		"id":         uuid.Nil.String(),
+		"created_at": time.Date(2200, 12, 31, 23, 59, 59, 0, time.UTC).Format(time.RFC3339Nano),
+	}
+}
+
+// Filter narrows down the listed flows. Zero values do not filter.
+type Filter struct {
+	Flow       flow.FlowName
+	State      flow.State
+	IdentityID uuid.NullUUID
+
+	// Errored only lists flows whose UI shows at least one error message.
+	Errored bool
+
+	// Expired lists only expired flows if true and only active flows if false.
+	Expired *bool
+}
+
+type (
+	Persister interface {
+		// ListSelfServiceFlows lists the flows matching the filter, newest first.
+		ListSelfServiceFlows(ctx context.Context, filter Filter, opts []keysetpagination.Option) ([]Summary, *keysetpagination.Paginator, error)
+	}
+	PersistenceProvider interface {
+		FlowInspectionPersister() Persister
+	}
+)
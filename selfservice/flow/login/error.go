@@ -15,6 +15,7 @@ import (
 	"github.com/ory/kratos/x/events"
 
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/text"
 
 	"github.com/pkg/errors"
@@ -40,10 +41,13 @@ var (
 
 type (
 	errorHandlerDependencies interface {
+		funnel.RecorderProvider
 		errorx.ManagementProvider
 		x.WriterProvider
 		x.LoggingProvider
 		config.Provider
+		x.CSRFProvider
+		x.CSRFTokenGeneratorProvider
 		sessiontokenexchange.PersistenceProvider
 
 		FlowPersistenceProvider
@@ -96,11 +100,18 @@ func (s *ErrorHandler) WriteFlowError(w http.ResponseWriter, r *http.Request, f
 	}
 
 	trace.SpanFromContext(r.Context()).AddEvent(events.NewLoginFailed(r.Context(), f.ID, string(f.Type), string(f.RequestedAAL), f.Refresh, err))
+	method := f.Active.String()
+	if method == "" && group != node.DefaultGroup {
+		method = string(group)
+	}
 
+	// Expired flows are counted with their own result, and errors while replacing them are counted
+	// by the recursive call, so that every submission is counted once.
 	if expired, inner := s.PrepareReplacementForExpiredFlow(w, r, f, err); inner != nil {
 		s.WriteFlowError(w, r, f, group, inner)
 		return
 	} else if expired != nil {
+		flow.CountLogin(method, string(f.RequestedAAL), flow.LoginResultExpired)
 		if f.Type == flow.TypeAPI || x.IsJSONRequest(r) {
 			s.d.Writer().WriteError(w, r, expired)
 		} else {
@@ -109,17 +120,21 @@ func (s *ErrorHandler) WriteFlowError(w http.ResponseWriter, r *http.Request, f
 		return
 	}
 
+	s.d.FlowFunnelRecorder().FlowFailed(r.Context(), flow.LoginFlow, f.ID)
+	flow.CountLogin(method, string(f.RequestedAAL), flow.LoginResultFailure)
+
 	f.UI.ResetMessages()
 	if err := f.UI.ParseError(group, err); err != nil {
 		s.forward(w, r, f, err)
 		return
 	}
 
-	if err := sortNodes(r.Context(), f.UI.Nodes); err != nil {
+	if err := sortNodes(r.Context(), s.d.Config(), f.UI.Nodes); err != nil {
 		s.forward(w, r, f, err)
 		return
 	}
 
+	flow.RotateCSRFToken(s.d, w, r, f)
 	if err := s.d.LoginFlowPersister().UpdateLoginFlow(r.Context(), f); err != nil {
 		s.forward(w, r, f, err)
 		return
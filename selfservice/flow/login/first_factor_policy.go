@@ -0,0 +1,148 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+)
+
+const internalContextFirstFactorPolicyPath = "first_factor_policy"
+
+// firstFactorProgress keeps track of the first factors which were completed in a login flow
+// while the identity's first factor policy is not yet satisfied.
+type firstFactorProgress struct {
+	IdentityID uuid.UUID                     `json:"identity_id"`
+	Methods    session.AuthenticationMethods `json:"methods"`
+}
+
+func (p *firstFactorProgress) completed(method identity.CredentialsType) bool {
+	return slices.ContainsFunc(p.Methods, func(m session.AuthenticationMethod) bool {
+		return m.Method == method
+	})
+}
+
+func (p *firstFactorProgress) completedGroup(group node.UiNodeGroup) bool {
+	return slices.ContainsFunc(p.Methods, func(m session.AuthenticationMethod) bool {
+		return m.Method.ToUiNodeGroup() == group
+	})
+}
+
+// enforceFirstFactorPolicy checks whether the identity's first factor policy is satisfied by the
+// methods completed in this login flow. If it is not, the progress is stored in the flow, the
+// nodes of the completed methods are removed, and a message asking for another method is added.
+// Once the policy is satisfied, the methods completed in earlier steps are added to the session.
+func (e *HookExecutor) enforceFirstFactorPolicy(ctx context.Context, f *Flow, i *identity.Identity, s *session.Session) (satisfied bool, err error) {
+	ctx, span := e.d.Tracer(ctx).Tracer().Start(ctx, "HookExecutor.PostLoginHook.enforceFirstFactorPolicy")
+	defer otelx.End(span, &err)
+
+	if f.RequestedAAL != identity.AuthenticatorAssuranceLevel1 {
+		// Second factors are governed by the AAL configuration.
+		return true, nil
+	}
+
+	policy, err := e.d.Config().SelfServiceFlowLoginFirstFactorPolicy(ctx, i.SchemaID)
+	if err != nil {
+		return false, err
+	} else if policy == nil {
+		return true, nil
+	}
+
+	f.EnsureInternalContext()
+	var progress firstFactorProgress
+	if raw := gjson.GetBytes(f.InternalContext, internalContextFirstFactorPolicyPath); raw.IsObject() {
+		if err := json.Unmarshal([]byte(raw.Raw), &progress); err != nil {
+			return false, errors.WithStack(err)
+		}
+	}
+
+	// Progress made by another identity does not count towards this identity's policy.
+	if progress.IdentityID != i.ID {
+		progress = firstFactorProgress{IdentityID: i.ID}
+	}
+
+	previous := progress.Methods
+	for _, m := range s.AMR {
+		if m.AAL == identity.AuthenticatorAssuranceLevel1 && !progress.completed(m.Method) {
+			progress.Methods = append(progress.Methods, m)
+		}
+	}
+
+	var completed int
+	remaining := make([]string, 0, len(policy.Methods))
+	for _, m := range policy.Methods {
+		if progress.completed(identity.CredentialsType(m)) {
+			completed++
+		} else {
+			remaining = append(remaining, m)
+		}
+	}
+
+	if completed >= policy.Minimum {
+		var carried session.AuthenticationMethods
+		for _, m := range previous {
+			if !s.AuthenticatedVia(m.Method) {
+				carried = append(carried, m)
+			}
+		}
+		s.AMR = append(carried, s.AMR...)
+
+		f.InternalContext, err = sjson.DeleteBytes(f.InternalContext, internalContextFirstFactorPolicyPath)
+		return true, errors.WithStack(err)
+	}
+
+	f.InternalContext, err = sjson.SetBytes(f.InternalContext, internalContextFirstFactorPolicyPath, progress)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	// Only offer the methods which have not yet been used.
+	nodes := make(node.Nodes, 0, len(f.UI.Nodes))
+	for _, n := range f.UI.Nodes {
+		if n.Group == node.DefaultGroup || !progress.completedGroup(n.Group) {
+			nodes = append(nodes, n)
+		}
+	}
+	f.UI.Nodes = nodes
+	f.UI.ResetMessages()
+	f.UI.Messages.Add(text.NewInfoSelfServiceLoginMoreFirstFactorsRequired(policy.Minimum-completed, remaining))
+
+	return false, nil
+}
+
+// continueWithNextFirstFactor shows the login flow again so that the next first factor can be
+// completed. The login has neither failed nor succeeded yet, so it is not counted in the metrics.
+func (e *HookExecutor) continueWithNextFirstFactor(w http.ResponseWriter, r *http.Request, f *Flow) error {
+	ctx := r.Context()
+	if f.Type == flow.TypeBrowser {
+		f.UI.SetCSRF(e.d.GenerateCSRFToken(r))
+	}
+
+	if err := e.d.LoginFlowPersister().UpdateLoginFlow(ctx, f); err != nil {
+		return err
+	}
+
+	if f.Type == flow.TypeBrowser && !x.IsJSONRequest(r) {
+		http.Redirect(w, r, f.AppendTo(e.d.Config().SelfServiceFlowLoginUI(ctx)).String(), http.StatusSeeOther)
+		return nil
+	}
+
+	e.d.Writer().Write(w, r, f)
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package login_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+)
+
+func TestFirstFactorPolicy(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/login.schema.json")
+
+	i := testhelpers.SelfServiceHookCreateFakeIdentity(t, reg)
+
+	newFlow := func(t *testing.T) *login.Flow {
+		r := httptest.NewRequest("POST", "/self-service/login", nil)
+		f, err := login.NewFlow(conf, time.Minute, "", r, flow.TypeAPI)
+		require.NoError(t, err)
+		f.UI.Nodes.Append(node.NewInputField("password", nil, node.PasswordGroup, node.InputAttributeTypePassword))
+		f.UI.Nodes.Append(node.NewInputField("passkey_login", nil, node.PasskeyGroup, node.InputAttributeTypeHidden))
+		f.UI.Nodes.Append(node.NewInputField("webauthn_login", nil, node.WebAuthnGroup, node.InputAttributeTypeHidden))
+		require.NoError(t, reg.LoginFlowPersister().CreateLoginFlow(ctx, f))
+		return f
+	}
+
+	submit := func(t *testing.T, f *login.Flow, id *identity.Identity, method identity.CredentialsType) (*httptest.ResponseRecorder, error) {
+		f.Active = method
+		s := session.NewInactiveSession()
+		s.CompletedLoginFor(method, identity.AuthenticatorAssuranceLevel1)
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/self-service/login", nil)
+		return w, reg.LoginHookExecutor().PostLoginHook(w, r, method.ToUiNodeGroup(), f, id, s, "")
+	}
+
+	requireMoreFactors := func(t *testing.T, w *httptest.ResponseRecorder, err error, remaining int) {
+		t.Helper()
+		// Asking for another first factor is not a failed login.
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.False(t, gjson.Get(w.Body.String(), "session").Exists(), w.Body.String())
+		assert.EqualValues(t, text.InfoSelfServiceLoginMoreFirstFactorsRequired, gjson.Get(w.Body.String(), "ui.messages.0.id").Int(), w.Body.String())
+		assert.Equal(t, "info", gjson.Get(w.Body.String(), "ui.messages.0.type").String(), w.Body.String())
+		assert.EqualValues(t, remaining, gjson.Get(w.Body.String(), "ui.messages.0.context.remaining").Int(), w.Body.String())
+	}
+
+	t.Run("case=no policy issues a session after one method", func(t *testing.T) {
+		w, err := submit(t, newFlow(t), i, identity.CredentialsTypePassword)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("case=all methods are required", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceLoginFirstFactorPolicies, []map[string]any{{
+			"identity_schema": i.SchemaID,
+			"methods":         []string{"password", "passkey"},
+		}})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceLoginFirstFactorPolicies, nil) })
+
+		f := newFlow(t)
+		w, err := submit(t, f, i, identity.CredentialsTypePassword)
+		requireMoreFactors(t, w, err, 1)
+		assert.Nil(t, f.UI.Nodes.Find("password"), "the completed method must no longer be offered")
+		assert.NotNil(t, f.UI.Nodes.Find("passkey_login"))
+
+		stored, err := reg.LoginFlowPersister().GetLoginFlow(ctx, f.ID)
+		require.NoError(t, err)
+		assert.Nil(t, stored.UI.Nodes.Find("password"), "the progress must be stored in the flow")
+
+		// Repeating the same method does not count twice.
+		w, err = submit(t, f, i, identity.CredentialsTypePassword)
+		requireMoreFactors(t, w, err, 1)
+
+		w, err = submit(t, f, i, identity.CredentialsTypePasskey)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "password", gjson.Get(w.Body.String(), "session.authentication_methods.0.method").String(), w.Body.String())
+		assert.Equal(t, "passkey", gjson.Get(w.Body.String(), "session.authentication_methods.1.method").String(), w.Body.String())
+		assert.False(t, gjson.GetBytes(f.InternalContext, "first_factor_policy").Exists())
+	})
+
+	t.Run("case=any two of three methods are required", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceLoginFirstFactorPolicies, []map[string]any{{
+			"identity_schema": i.SchemaID,
+			"methods":         []string{"password", "passkey", "webauthn"},
+			"minimum":         2,
+		}})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceLoginFirstFactorPolicies, nil) })
+
+		f := newFlow(t)
+		w, err := submit(t, f, i, identity.CredentialsTypeWebAuthn)
+		requireMoreFactors(t, w, err, 1)
+
+		w, err = submit(t, f, i, identity.CredentialsTypePassword)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("case=progress of another identity does not count", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceLoginFirstFactorPolicies, []map[string]any{{
+			"identity_schema": i.SchemaID,
+			"methods":         []string{"password", "passkey"},
+		}})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceLoginFirstFactorPolicies, nil) })
+
+		other := testhelpers.SelfServiceHookCreateFakeIdentity(t, reg)
+		other.SchemaID = i.SchemaID
+
+		f := newFlow(t)
+		w, err := submit(t, f, i, identity.CredentialsTypePassword)
+		requireMoreFactors(t, w, err, 1)
+
+		w, err = submit(t, f, other, identity.CredentialsTypePasskey)
+		requireMoreFactors(t, w, err, 1)
+	})
+
+	t.Run("case=higher AAL flows are not affected", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceLoginFirstFactorPolicies, []map[string]any{{
+			"identity_schema": i.SchemaID,
+			"methods":         []string{"password", "passkey"},
+		}})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceLoginFirstFactorPolicies, nil) })
+
+		f := newFlow(t)
+		f.RequestedAAL = identity.AuthenticatorAssuranceLevel2
+		w, err := submit(t, f, i, identity.CredentialsTypePassword)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
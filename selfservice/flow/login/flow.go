@@ -147,6 +147,18 @@ type Flow struct {
 	// required: false
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" faker:"-" db:"-"`
 
+	// Brand is the brand of the white-label product this flow belongs to.
+	//
+	// This value is set using the `brand` query parameter when initializing the flow. It is
+	// passed to the UI and used to select the courier templates.
+	Brand sqlxx.NullString `json:"brand,omitempty" faker:"-" db:"brand"`
+
+	// LoginHint is the identifier of the user who is about to sign in.
+	//
+	// This value is set using the `login_hint` query parameter when initializing the flow. The
+	// identifier fields are pre-filled with it, and it is passed on to OpenID Connect providers.
+	LoginHint string `json:"login_hint,omitempty" faker:"-" db:"login_hint"`
+
 	// Contains a list of actions, that could follow this flow
 	//
 	// It can, for example, contain a reference to the verification flow, created as part of the user's
@@ -161,22 +173,34 @@ type Flow struct {
 
 var _ flow.Flow = new(Flow)
 
-func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Request, flowType flow.Type) (*Flow, error) {
-	now := time.Now().UTC()
+func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Request, flowType flow.Type, opts ...x.SecureRedirectOption) (*Flow, error) {
+	now := x.Now().UTC()
 	id := x.NewUUID()
 	requestURL := x.RequestURL(r).String()
 
 	// Pre-validate the return to URL which is contained in the HTTP request.
 	_, err := x.SecureRedirectTo(r,
 		conf.SelfServiceBrowserDefaultReturnTo(r.Context()),
-		x.SecureRedirectUseSourceURL(requestURL),
-		x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
-		x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		append([]x.SecureRedirectOption{
+			x.SecureRedirectUseSourceURL(requestURL),
+			x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
+			x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		}, opts...)...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	brand, err := flow.BrandFromRequest(conf, r)
+	if err != nil {
+		return nil, err
+	}
+
+	loginHint, err := loginHintFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
 	hydraLoginChallenge, err := hydra.GetLoginChallengeID(conf, r)
 	if err != nil {
 		return nil, err
@@ -202,6 +226,8 @@ func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Reques
 			string(identity.AuthenticatorAssuranceLevel1)))),
 		InternalContext: []byte("{}"),
 		State:           flow.StateChooseMethod,
+		Brand:           brand,
+		LoginHint:       loginHint,
 	}, nil
 }
 
@@ -222,7 +248,7 @@ func (f Flow) WhereID(ctx context.Context, alias string) string {
 }
 
 func (f *Flow) Valid() error {
-	if f.ExpiresAt.Before(time.Now()) {
+	if f.ExpiresAt.Before(x.Now()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 	return nil
@@ -239,7 +265,12 @@ func (f *Flow) IsRefresh() bool {
 }
 
 func (f *Flow) AppendTo(src *url.URL) *url.URL {
-	return flow.AppendFlowTo(src, f.ID)
+	return flow.AppendBrandTo(flow.AppendFlowTo(src, f.ID), f.Brand)
+}
+
+func (f *Flow) SetCSRFToken(token string) {
+	f.CSRFToken = token
+	f.UI.SetCSRF(token)
 }
 
 func (f Flow) GetNID() uuid.UUID {
@@ -316,6 +347,10 @@ func (t *Flow) GetTransientPayload() json.RawMessage {
 	return t.TransientPayload
 }
 
+func (t *Flow) GetBrand() string {
+	return t.Brand.String()
+}
+
 var _ flow.FlowWithContinueWith = new(Flow)
 
 func (f *Flow) AddContinueWith(c flow.ContinueWith) {
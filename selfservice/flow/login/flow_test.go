@@ -6,9 +6,11 @@ package login_test
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 
 	"github.com/tidwall/gjson"
 
+	"github.com/ory/herodot"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/x/jsonx"
@@ -139,6 +142,31 @@ func TestNewFlow(t *testing.T) {
 		})
 	})
 
+	t.Run("type=brand", func(t *testing.T) {
+		_, err := login.NewFlow(conf, 0, "csrf", &http.Request{URL: urlx.ParseOrPanic("/?brand=acme"), Host: "ory.sh"}, flow.TypeBrowser)
+		require.ErrorIs(t, err, herodot.ErrBadRequest)
+
+		conf.MustSet(ctx, config.ViperKeySelfServiceBrands, []string{"acme"})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceBrands, []string{}) })
+
+		r, err := login.NewFlow(conf, 0, "csrf", &http.Request{URL: urlx.ParseOrPanic("/?brand=acme"), Host: "ory.sh"}, flow.TypeBrowser)
+		require.NoError(t, err)
+		assert.EqualValues(t, "acme", r.Brand)
+		assert.Equal(t, "acme", r.AppendTo(urlx.ParseOrPanic("https://ui.ory.sh/login")).Query().Get("brand"))
+		raw, err := json.Marshal(r)
+		require.NoError(t, err)
+		assert.Equal(t, "acme", gjson.GetBytes(raw, "brand").String())
+	})
+
+	t.Run("type=login_hint", func(t *testing.T) {
+		r, err := login.NewFlow(conf, 0, "csrf", &http.Request{URL: urlx.ParseOrPanic("/?login_hint=+foo%40ory.sh+"), Host: "ory.sh"}, flow.TypeBrowser)
+		require.NoError(t, err)
+		assert.Equal(t, "foo@ory.sh", r.LoginHint)
+
+		_, err = login.NewFlow(conf, 0, "csrf", &http.Request{URL: urlx.ParseOrPanic("/?login_hint=" + strings.Repeat("a", 321)), Host: "ory.sh"}, flow.TypeBrowser)
+		require.ErrorIs(t, err, herodot.ErrBadRequest)
+	})
+
 	t.Run("should parse login_challenge when Hydra is configured", func(t *testing.T) {
 		_, err := login.NewFlow(conf, 0, "csrf", &http.Request{URL: urlx.ParseOrPanic("https://ory.sh/?login_challenge=badee1"), Host: "ory.sh"}, flow.TypeBrowser)
 		require.Error(t, err)
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ory/x/otelx"
@@ -23,6 +24,7 @@ import (
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/selfservice/sessiontokenexchange"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/text"
@@ -30,6 +32,7 @@ import (
 	"github.com/ory/kratos/x"
 	"github.com/ory/nosurf"
 	"github.com/ory/x/decoderx"
+	"github.com/ory/x/jsonx"
 	"github.com/ory/x/sqlxx"
 	"github.com/ory/x/stringsx"
 	"github.com/ory/x/urlx"
@@ -42,10 +45,13 @@ const (
 	RouteGetFlow = "/self-service/login/flows"
 
 	RouteSubmitFlow = "/self-service/login"
+
+	RouteAdminCreateFlow = "/self-service/login/flows"
 )
 
 type (
 	handlerDependencies interface {
+		funnel.RecorderProvider
 		HookExecutorProvider
 		FlowPersistenceProvider
 		errorx.ManagementProvider
@@ -53,9 +59,12 @@ type (
 		StrategyProvider
 		session.HandlerProvider
 		session.ManagementProvider
+		session.PersistenceProvider
 		x.WriterProvider
 		x.CSRFTokenGeneratorProvider
 		x.CSRFProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
 		x.TracingProvider
 		config.Provider
 		ErrorHandlerProvider
@@ -85,6 +94,9 @@ func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
 
 	public.POST(RouteSubmitFlow, h.updateLoginFlow)
 	public.GET(RouteSubmitFlow, h.updateLoginFlow)
+
+	h.d.CSRFHandler().IgnorePath(x.AdminPrefix + RouteAdminCreateFlow)
+	public.POST(x.AdminPrefix+RouteAdminCreateFlow, x.RedirectToAdminRoute(h.d))
 }
 
 func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
@@ -94,6 +106,8 @@ func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
 
 	admin.POST(RouteSubmitFlow, x.RedirectToPublicRoute(h.d))
 	admin.GET(RouteSubmitFlow, x.RedirectToPublicRoute(h.d))
+
+	admin.POST(RouteAdminCreateFlow, h.createPrefilledLoginFlow)
 }
 
 type FlowOption func(f *Flow)
@@ -132,7 +146,7 @@ func WithIsAccountLinking() FlowOption {
 
 func (h *Handler) NewLoginFlow(w http.ResponseWriter, r *http.Request, ft flow.Type, opts ...FlowOption) (*Flow, *session.Session, error) {
 	conf := h.d.Config()
-	f, err := NewFlow(conf, conf.SelfServiceFlowLoginRequestLifespan(r.Context()), h.d.GenerateCSRFToken(r), r, ft)
+	f, err := NewFlow(conf, conf.SelfServiceFlowLoginRequestLifespan(r.Context()), flow.NewCSRFToken(h.d, w, r, ft), r, ft, x.SecureRedirectAllowReturnToGrants(h.d))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -232,41 +246,12 @@ preLoginHook:
 		}
 	}
 
-	for _, s := range h.d.LoginStrategies(r.Context(), strategyFilters...) {
-		var populateErr error
-
-		switch strategy := s.(type) {
-		case FormHydrator:
-			switch {
-			case f.RequestedAAL == identity.AuthenticatorAssuranceLevel1:
-				switch {
-				case f.IsRefresh():
-					// Refreshing takes precedence over identifier_first auth which can not be a refresh flow.
-					// Therefor this comes first.
-					populateErr = strategy.PopulateLoginMethodFirstFactorRefresh(r, f)
-				case h.d.Config().SelfServiceLoginFlowIdentifierFirstEnabled(r.Context()) && !f.isAccountLinkingFlow:
-					populateErr = strategy.PopulateLoginMethodIdentifierFirstIdentification(r, f)
-				default:
-					populateErr = strategy.PopulateLoginMethodFirstFactor(r, f)
-				}
-			case f.RequestedAAL == identity.AuthenticatorAssuranceLevel2:
-				switch {
-				case f.IsRefresh():
-					// Refresh takes precedence.
-					populateErr = strategy.PopulateLoginMethodSecondFactorRefresh(r, f)
-				default:
-					populateErr = strategy.PopulateLoginMethodSecondFactor(r, f)
-				}
-			}
-		case UnifiedFormHydrator:
-			populateErr = strategy.PopulateLoginMethod(r, f.RequestedAAL, f)
-		default:
-			populateErr = errors.WithStack(x.PseudoPanic.WithReasonf("A login strategy was expected to implement one of the interfaces UnifiedFormHydrator or FormHydrator but did not."))
-		}
-
-		if populateErr != nil {
-			return nil, nil, populateErr
-		}
+	if err := h.PopulateFlow(r, f, strategyFilters...); err != nil {
+		return nil, nil, err
+	}
+	h.addRememberNode(r.Context(), f)
+	if err := h.applyLoginHint(r, f); err != nil {
+		return nil, nil, err
 	}
 
 	if f.Refresh {
@@ -277,10 +262,6 @@ preLoginHook:
 		f.UI.Messages.Add(text.NewInfoLoginMFA())
 	}
 
-	if err := sortNodes(r.Context(), f.UI.Nodes); err != nil {
-		return nil, nil, err
-	}
-
 	if f.Type == flow.TypeBrowser {
 		f.UI.SetCSRF(h.d.GenerateCSRFToken(r))
 	}
@@ -294,9 +275,82 @@ preLoginHook:
 		return nil, nil, err
 	}
 
+	h.d.FlowFunnelRecorder().FlowCreated(r.Context(), flow.LoginFlow, f.ID, f.ExpiresAt)
+
 	return f, nil, nil
 }
 
+// PopulateFlow adds the nodes of all login strategies matching the filters to the flow, depending
+// on its requested AAL and whether it is a refresh, and sorts them.
+func (h *Handler) PopulateFlow(r *http.Request, f *Flow, filters ...StrategyFilter) error {
+	strategies := h.d.LoginStrategies(r.Context(), filters...)
+	h.traceSkippedStrategies(r, f, strategies)
+
+	for _, s := range strategies {
+		if err := flow.TracePopulate(r, f, s.ID().String(), func() error {
+			return h.populateStrategy(r, f, s)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return sortNodes(r.Context(), h.d.Config(), f.UI.Nodes)
+}
+
+func (h *Handler) populateStrategy(r *http.Request, f *Flow, s Strategy) error {
+	var populateErr error
+
+	switch strategy := s.(type) {
+	case FormHydrator:
+		switch {
+		case f.RequestedAAL == identity.AuthenticatorAssuranceLevel1:
+			switch {
+			case f.IsRefresh():
+				// Refreshing takes precedence over identifier_first auth which can not be a refresh flow.
+				// Therefor this comes first.
+				populateErr = strategy.PopulateLoginMethodFirstFactorRefresh(r, f)
+			case h.d.Config().SelfServiceLoginFlowIdentifierFirstEnabled(r.Context()) && !f.isAccountLinkingFlow:
+				populateErr = strategy.PopulateLoginMethodIdentifierFirstIdentification(r, f)
+			default:
+				populateErr = strategy.PopulateLoginMethodFirstFactor(r, f)
+			}
+		case f.RequestedAAL == identity.AuthenticatorAssuranceLevel2:
+			switch {
+			case f.IsRefresh():
+				// Refresh takes precedence.
+				populateErr = strategy.PopulateLoginMethodSecondFactorRefresh(r, f)
+			default:
+				populateErr = strategy.PopulateLoginMethodSecondFactor(r, f)
+			}
+		}
+	case UnifiedFormHydrator:
+		populateErr = strategy.PopulateLoginMethod(r, f.RequestedAAL, f)
+	default:
+		populateErr = errors.WithStack(x.PseudoPanic.WithReasonf("A login strategy was expected to implement one of the interfaces UnifiedFormHydrator or FormHydrator but did not."))
+	}
+
+	return populateErr
+}
+
+// traceSkippedStrategies records the login strategies which are not considered for the flow in
+// the debug trace of the request.
+func (h *Handler) traceSkippedStrategies(r *http.Request, f *Flow, considered Strategies) {
+	if x.DebugTraceFromContext(r.Context()) == nil {
+		return
+	}
+
+	enabled := h.d.LoginStrategies(r.Context())
+	for _, s := range h.d.AllLoginStrategies() {
+		if _, err := considered.Strategy(s.ID()); err == nil {
+			continue
+		} else if _, err := enabled.Strategy(s.ID()); err == nil {
+			flow.TraceStrategySkipped(r, f, s.ID().String(), "The strategy is not available for this flow, for example because of its identity schema, organization or requested method.")
+		} else {
+			flow.TraceStrategySkipped(r, f, s.ID().String(), "The strategy is disabled in the configuration.")
+		}
+	}
+}
+
 func (h *Handler) FromOldFlow(w http.ResponseWriter, r *http.Request, of Flow) (*Flow, error) {
 	nf, _, err := h.NewLoginFlow(w, r, of.Type)
 	if err != nil {
@@ -304,6 +358,7 @@ func (h *Handler) FromOldFlow(w http.ResponseWriter, r *http.Request, of Flow) (
 	}
 
 	nf.RequestURL = of.RequestURL
+	nf.Brand = of.Brand
 	return nf, nil
 }
 
@@ -363,6 +418,24 @@ type createNativeLoginFlow struct {
 	//
 	// in: query
 	Via string `json:"via"`
+
+	// An optional brand of the white-label product the login flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
+
+	// An optional identifier of the user who is about to sign in, such as their email address.
+	//
+	// The identifier fields of the flow are pre-filled with it. If identifier first login is enabled,
+	// the login methods of the identifier are shown right away. The hint is also passed on to OpenID
+	// Connect providers as `login_hint`.
+	//
+	// required: false
+	// in: query
+	LoginHint string `json:"login_hint"`
 }
 
 // swagger:route GET /self-service/login/api frontend createNativeLoginFlow
@@ -414,6 +487,124 @@ func (h *Handler) createNativeLoginFlow(w http.ResponseWriter, r *http.Request,
 	h.d.Writer().Write(w, r, f)
 }
 
+// Create Pre-filled Login Flow Request Body
+//
+// swagger:model createPrefilledLoginFlowBody
+type CreatePrefilledLoginFlowBody struct {
+	// The identifier of the user who is about to sign in, such as their email address.
+	//
+	// required: true
+	LoginHint string `json:"login_hint"`
+
+	// The URL to return the user to after the flow was completed.
+	//
+	// required: false
+	ReturnTo string `json:"return_to"`
+
+	// An optional brand of the white-label product the login flow belongs to.
+	//
+	// required: false
+	Brand string `json:"brand"`
+
+	// EnableSessionTokenExchangeCode requests the login flow to include a code that can be used to retrieve the session token
+	// after the login flow has been completed.
+	//
+	// required: false
+	EnableSessionTokenExchangeCode bool `json:"return_session_token_exchange_code"`
+}
+
+// Create Pre-filled Login Flow Parameters
+//
+// swagger:parameters createPrefilledLoginFlow
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type createPrefilledLoginFlow struct {
+	// in: body
+	// required: true
+	Body CreatePrefilledLoginFlowBody
+}
+
+// swagger:route POST /admin/self-service/login/flows identity createPrefilledLoginFlow
+//
+// # Create a Pre-filled Login Flow
+//
+// This endpoint creates a login flow for native apps on behalf of a user, with the identifier fields
+// pre-filled with the login hint. If identifier first login is enabled, the flow shows the login
+// methods of the identifier right away. This is useful to hand a user over from another system which
+// already knows who the user is.
+//
+// The flow is of type `api`, as browser flows are bound to the anti-CSRF cookie of the browser which
+// created them. To hand over browsers, redirect them to `/self-service/login/browser?login_hint=...`
+// instead.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  201: loginFlow
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) createPrefilledLoginFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var err error
+	ctx, span := h.d.Tracer(r.Context()).Tracer().Start(r.Context(), "selfservice.flow.login.createPrefilledLoginFlow")
+	r = r.WithContext(ctx)
+	defer otelx.End(span, &err)
+
+	var body CreatePrefilledLoginFlowBody
+	if err = jsonx.NewStrictDecoder(r.Body).Decode(&body); err != nil {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithError(err.Error())))
+		return
+	}
+	if strings.TrimSpace(body.LoginHint) == "" {
+		err = errors.WithStack(herodot.ErrBadRequest.WithReason("The login hint must not be empty."))
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	// The flow is created from the parameters of the body as if they were passed to the native
+	// flow initialization endpoint, but without the credentials of the administrator.
+	fr := r.Clone(ctx)
+	fr.Header.Del("Authorization")
+	fr.Header.Del("Cookie")
+	fr.Header.Del("X-Session-Token")
+	q := url.Values{"login_hint": {body.LoginHint}}
+	if body.ReturnTo != "" {
+		q.Set("return_to", body.ReturnTo)
+	}
+	if body.Brand != "" {
+		q.Set("brand", body.Brand)
+	}
+	if body.EnableSessionTokenExchangeCode {
+		q.Set("return_session_token_exchange_code", "true")
+	}
+	fr.URL.RawQuery = q.Encode()
+
+	f, _, err := h.NewLoginFlow(w, fr, flow.TypeAPI)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.d.Audit().
+		WithRequest(r).
+		WithField("login_flow_id", f.ID).
+		Info("An administrator created a pre-filled login flow.")
+
+	h.d.Writer().WriteCreated(w, r,
+		urlx.CopyWithQuery(urlx.AppendPaths(h.d.Config().SelfPublicURL(ctx), RouteGetFlow), url.Values{"id": {f.ID.String()}}).String(),
+		f,
+	)
+}
+
 // Initialize Browser Login Flow Parameters
 //
 // swagger:parameters createBrowserLoginFlow
@@ -478,6 +669,24 @@ type createBrowserLoginFlow struct {
 	//
 	// in: query
 	Via string `json:"via"`
+
+	// An optional brand of the white-label product the login flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
+
+	// An optional identifier of the user who is about to sign in, such as their email address.
+	//
+	// The identifier fields of the flow are pre-filled with it. If identifier first login is enabled,
+	// the login methods of the identifier are shown right away. The hint is also passed on to OpenID
+	// Connect providers as `login_hint`.
+	//
+	// required: false
+	// in: query
+	LoginHint string `json:"login_hint"`
 }
 
 // swagger:route GET /self-service/login/browser frontend createBrowserLoginFlow
@@ -548,6 +757,13 @@ func (h *Handler) createBrowserLoginFlow(w http.ResponseWriter, r *http.Request,
 			r.URL.RawQuery = q.Encode()
 		}
 
+		// The login hint of the OAuth2 client is used unless the application set one itself.
+		if hint := hydraLoginRequest.OidcContext.GetLoginHint(); hint != "" && !r.URL.Query().Has("login_hint") {
+			q := r.URL.Query()
+			q.Set("login_hint", hint)
+			r.URL.RawQuery = q.Encode()
+		}
+
 		// on OAuth2 flows, we need to use the RequestURL
 		// as the ReturnTo URL.
 		// This is because a user might want to switch between
@@ -570,6 +786,13 @@ func (h *Handler) createBrowserLoginFlow(w http.ResponseWriter, r *http.Request,
 				return
 			}
 
+			// The OAuth2 login request is only accepted once the identity has set the new password.
+			if sess.PasswordResetRequired {
+				settingsTo := session.PasswordResetRedirect(ctx, h.d.Config(), x.RequestURL(r))
+				x.AcceptToRedirectOrJSON(w, r, h.d.Writer(), session.NewErrPasswordResetRequired(settingsTo.String()), settingsTo.String())
+				return
+			}
+
 			rt, err := h.d.Hydra().AcceptLoginRequest(ctx,
 				hydra.AcceptLoginRequestParams{
 					LoginChallenge:        string(hydraLoginChallenge),
@@ -593,6 +816,7 @@ func (h *Handler) createBrowserLoginFlow(w http.ResponseWriter, r *http.Request,
 		returnTo, redirErr := x.SecureRedirectTo(r, h.d.Config().SelfServiceBrowserDefaultReturnTo(ctx),
 			x.SecureRedirectAllowSelfServiceURLs(h.d.Config().SelfPublicURL(ctx)),
 			x.SecureRedirectAllowURLs(h.d.Config().SelfServiceBrowserAllowedReturnToDomains(ctx)),
+			x.SecureRedirectAllowReturnToGrants(h.d),
 		)
 		if redirErr != nil {
 			h.d.SelfServiceErrorManager().Forward(ctx, w, r, redirErr)
@@ -836,58 +1060,70 @@ func (h *Handler) updateLoginFlow(w http.ResponseWriter, r *http.Request, _ http
 		return
 	}
 
-	sess, err := h.d.SessionManager().FetchFromRequest(ctx, r)
-	if err == nil {
-		if f.Refresh {
-			// If we want to refresh, continue the login
-			goto continueLogin
-		}
-
-		if f.RequestedAAL > sess.AuthenticatorAssuranceLevel {
-			// If we want to upgrade AAL, continue the login
-			goto continueLogin
-		}
-
-		if x.IsJSONRequest(r) || f.Type == flow.TypeAPI {
-			// We are not upgrading AAL, nor are we refreshing. Error!
-			h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, errors.WithStack(ErrAlreadyLoggedIn))
-			return
-		}
-
+	sess, err := h.sessionForUpdate(r, f)
+	if errors.Is(err, ErrAlreadyLoggedIn) && f.Type == flow.TypeBrowser && !x.IsJSONRequest(r) {
 		http.Redirect(w, r, h.d.Config().SelfServiceBrowserDefaultReturnTo(ctx).String(), http.StatusSeeOther)
 		return
-	} else if e := new(session.ErrNoActiveSessionFound); errors.As(err, &e) {
+	} else if err != nil {
+		h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, err)
+		return
+	}
+
+	if group, err := h.executeFlow(w, r, f, sess); errors.Is(err, flow.ErrCompletedByStrategy) {
+		return
+	} else if err != nil {
+		h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, group, err)
+		return
+	}
+}
+
+// sessionForUpdate returns the session which is refreshed or upgraded by the login flow, or an
+// inactive session if the request has no session.
+func (h *Handler) sessionForUpdate(r *http.Request, f *Flow) (*session.Session, error) {
+	sess, err := h.d.SessionManager().FetchFromRequest(r.Context(), r)
+	if e := new(session.ErrNoActiveSessionFound); errors.As(err, &e) {
 		// Only failure scenario here is if we try to upgrade the session to a higher AAL without actually
 		// having a session.
 		if f.RequestedAAL > identity.AuthenticatorAssuranceLevel1 {
-			h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, errors.WithStack(ErrSessionRequiredForHigherAAL))
-			return
+			return nil, errors.WithStack(ErrSessionRequiredForHigherAAL)
 		}
+		return session.NewInactiveSession(), nil
+	} else if err != nil {
+		return nil, err
+	}
 
-		sess = session.NewInactiveSession()
-	} else {
-		h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, err)
-		return
+	// We are neither refreshing nor upgrading the AAL.
+	if !f.Refresh && f.RequestedAAL <= sess.AuthenticatorAssuranceLevel {
+		return nil, errors.WithStack(ErrAlreadyLoggedIn)
 	}
+	return sess, nil
+}
 
-continueLogin:
+// executeFlow completes the login flow with the responsible strategy and runs the post-login
+// hooks, which respond to the request. It returns flow.ErrCompletedByStrategy if the strategy
+// responded to the request itself.
+func (h *Handler) executeFlow(w http.ResponseWriter, r *http.Request, f *Flow, sess *session.Session) (node.UiNodeGroup, error) {
 	if err := f.Valid(); err != nil {
-		h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, err)
-		return
+		return node.DefaultGroup, err
+	}
+
+	if err := h.storeRemember(r, f); err != nil {
+		return node.DefaultGroup, err
 	}
 
 	var i *identity.Identity
 	var group node.UiNodeGroup
 	for _, ss := range h.d.AllLoginStrategies() {
-		interim, err := ss.Login(w, r, f, sess)
+		var interim *identity.Identity
+		err := flow.TraceStrategy(r, h.d, f, ss.ID().String(), func(r *http.Request) (err error) {
+			interim, err = ss.Login(w, r, f, sess)
+			return err
+		})
 		group = ss.NodeGroup()
 		if errors.Is(err, flow.ErrStrategyNotResponsible) {
 			continue
-		} else if errors.Is(err, flow.ErrCompletedByStrategy) {
-			return
 		} else if err != nil {
-			h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, group, err)
-			return
+			return group, err
 		}
 
 		// What can happen is that we re-authenticate as another user. In this case, we need to use a completely fresh
@@ -896,24 +1132,18 @@ continueLogin:
 			sess = session.NewInactiveSession()
 		}
 
-		method := ss.CompletedAuthenticationMethod(ctx)
+		method := ss.CompletedAuthenticationMethod(r.Context())
 		sess.CompletedLoginForMethod(method)
 		i = interim
 		break
 	}
 
 	if i == nil {
-		h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, errors.WithStack(schema.NewNoLoginStrategyResponsible()))
-		return
+		return node.DefaultGroup, errors.WithStack(schema.NewNoLoginStrategyResponsible())
 	}
 
 	if err := h.d.LoginHookExecutor().PostLoginHook(w, r, group, f, i, sess, ""); err != nil {
-		if errors.Is(err, ErrAddressNotVerified) {
-			h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, errors.WithStack(schema.NewAddressNotVerifiedError()))
-			return
-		}
-
-		h.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, err)
-		return
+		return node.DefaultGroup, err
 	}
+	return group, nil
 }
@@ -58,7 +58,8 @@ func init() {
 func TestFlowLifecycle(t *testing.T) {
 	ctx := context.Background()
 	conf, reg := internal.NewFastRegistryWithMocks(t)
-	reg.WithHydra(hydra.NewFake())
+	fakeHydra := hydra.NewFake()
+	reg.WithHydra(fakeHydra)
 	router := x.NewRouterPublic()
 	ts, _ := testhelpers.NewKratosServerWithRouters(t, reg, router, x.NewRouterAdmin())
 	loginTS := testhelpers.NewLoginUIFlowEchoServer(t, reg)
@@ -562,6 +563,17 @@ func TestFlowLifecycle(t *testing.T) {
 				}
 			})
 
+			t.Run("case=pre-fills the identifier with the login hint", func(t *testing.T) {
+				_, body := initFlow(t, url.Values{"login_hint": {id1mail}}, true)
+				assert.Equal(t, id1mail, gjson.GetBytes(body, "login_hint").String(), "%s", body)
+				assert.Equal(t, id1mail, gjson.GetBytes(body, "ui.nodes.#(attributes.name==identifier).attributes.value").String(), "%s", body)
+			})
+
+			t.Run("case=does not pre-fill the identifier of refresh flows", func(t *testing.T) {
+				_, body := initAuthenticatedFlow(t, url.Values{"refresh": {"true"}, "login_hint": {id1mail}}, true)
+				assert.NotEqual(t, id1mail, gjson.GetBytes(body, "ui.nodes.#(attributes.name==identifier).attributes.value").String(), "%s", body)
+			})
+
 			t.Run("case=can not request refresh and aal at the same time on unauthenticated request", func(t *testing.T) {
 				res, body := initFlow(t, url.Values{"refresh": {"true"}, "aal": {"aal2"}}, true)
 				assert.Contains(t, res.Request.URL.String(), login.RouteInitAPIFlow)
@@ -644,6 +656,12 @@ func TestFlowLifecycle(t *testing.T) {
 				assert.Empty(t, gjson.GetBytes(body, "session_token_exchange_code").String())
 			})
 
+			t.Run("case=pre-fills the identifier with the login hint", func(t *testing.T) {
+				_, body := initSPAFlow(t, url.Values{"login_hint": {id2mail}})
+				assert.Equal(t, id2mail, gjson.GetBytes(body, "login_hint").String(), "%s", body)
+				assert.Equal(t, id2mail, gjson.GetBytes(body, "ui.nodes.#(attributes.name==identifier).attributes.value").String(), "%s", body)
+			})
+
 			t.Run("case=can not request refresh and aal at the same time on unauthenticated request", func(t *testing.T) {
 				res, body := initFlow(t, url.Values{"refresh": {"true"}, "aal": {"aal2"}}, false)
 				assert.Contains(t, res.Request.URL.String(), errorTS.URL)
@@ -781,6 +799,17 @@ func TestFlowLifecycle(t *testing.T) {
 				require.Contains(t, res.Request.URL.String(), loginTS.URL)
 			})
 
+			t.Run("case=oauth2 flow init uses the login hint of the oauth2 client", func(t *testing.T) {
+				fakeHydra.LoginHint = id1mail
+				t.Cleanup(func() { fakeHydra.LoginHint = "" })
+
+				_, body := initSPAFlow(t, url.Values{"login_challenge": {hydra.FakeValidLoginChallenge}})
+				assert.Equal(t, id1mail, gjson.GetBytes(body, "login_hint").String(), "%s", body)
+
+				_, body = initSPAFlow(t, url.Values{"login_challenge": {hydra.FakeValidLoginChallenge}, "login_hint": {id2mail}})
+				assert.Equal(t, id2mail, gjson.GetBytes(body, "login_hint").String(), "%s", body)
+			})
+
 			t.Run("case=oauth2 flow init adds oauth2_login_request field", func(t *testing.T) {
 				res, body := initSPAFlow(t, url.Values{"login_challenge": {hydra.FakeValidLoginChallenge}})
 				assert.NotContains(t, res.Request.URL.String(), loginTS.URL)
@@ -902,4 +931,129 @@ func TestGetFlow(t *testing.T) {
 		res, _ := testhelpers.EasyGet(t, client, public.URL+login.RouteGetFlow+"?id="+x.NewUUID().String())
 		assert.EqualValues(t, http.StatusNotFound, res.StatusCode)
 	})
+
+	t.Run("case=csrf token is rotated per flow", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySecurityCSRFTokenRotation, string(config.CSRFTokenRotationFlow))
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySecurityCSRFTokenRotation, string(config.CSRFTokenRotationSession))
+		})
+
+		client := testhelpers.NewClientWithCookies(t)
+		setupLoginUI(t, client)
+		first := testhelpers.EasyGetBody(t, client, public.URL+login.RouteInitBrowserFlow)
+		second := testhelpers.EasyGetBody(t, client, public.URL+login.RouteInitBrowserFlow)
+
+		body := testhelpers.EasyGetBody(t, client, public.URL+login.RouteGetFlow+"?id="+gjson.GetBytes(first, "id").String())
+		assert.EqualValues(t, x.ErrInvalidCSRFToken.ReasonField, gjson.GetBytes(body, "error.reason").String(), "%s", body)
+
+		body = testhelpers.EasyGetBody(t, client, public.URL+login.RouteGetFlow+"?id="+gjson.GetBytes(second, "id").String())
+		assert.Equal(t, gjson.GetBytes(second, "id").String(), gjson.GetBytes(body, "id").String(), "%s", body)
+	})
+
+	t.Run("case=csrf token is rotated per request", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySecurityCSRFTokenRotation, string(config.CSRFTokenRotationRequest))
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySecurityCSRFTokenRotation, string(config.CSRFTokenRotationSession))
+		})
+
+		client := testhelpers.NewClientWithCookies(t)
+		setupLoginUI(t, client)
+		body := testhelpers.EasyGetBody(t, client, public.URL+login.RouteInitBrowserFlow)
+		id := gjson.GetBytes(body, "id").String()
+		token := gjson.GetBytes(body, "ui.nodes.#(attributes.name==csrf_token).attributes.value").String()
+		require.NotEmpty(t, token, "%s", body)
+
+		submit := func(t *testing.T, token string) []byte {
+			res, err := client.PostForm(public.URL+login.RouteSubmitFlow+"?flow="+id, url.Values{"identifier": {"rotate-csrf@ory.sh"}, "csrf_token": {token}, "password": {"password"}, "method": {"password"}})
+			require.NoError(t, err)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			return body
+		}
+
+		// The flow can still be fetched after the submission and contains a new token.
+		body = submit(t, token)
+		assert.Equal(t, id, gjson.GetBytes(body, "id").String(), "%s", body)
+		rotated := gjson.GetBytes(body, "ui.nodes.#(attributes.name==csrf_token).attributes.value").String()
+		assert.NotEmpty(t, rotated, "%s", body)
+		assert.NotEqual(t, token, rotated, "%s", body)
+
+		// The previous token can not be used again.
+		body = submit(t, token)
+		assert.EqualValues(t, x.ErrInvalidCSRFToken.ReasonField, gjson.GetBytes(body, "reason").String(), "%s", body)
+	})
+}
+
+func TestCreatePrefilledFlow(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	public, admin := testhelpers.NewKratosServerWithCSRF(t, reg)
+	_ = testhelpers.NewLoginUIFlowEchoServer(t, reg)
+
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/password.schema.json")
+	conf.MustSet(ctx, config.ViperKeyURLsAllowedReturnToDomains, []string{"https://www.ory.sh"})
+
+	create := func(t *testing.T, body string, expectCode int) (*http.Response, []byte) {
+		res, err := admin.Client().Post(admin.URL+"/admin"+login.RouteAdminCreateFlow, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		raw, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, expectCode, res.StatusCode, "%s", raw)
+		return res, raw
+	}
+
+	t.Run("case=creates an api flow with the identifier pre-filled", func(t *testing.T) {
+		res, body := create(t, `{"login_hint":"prefilled@ory.sh","return_to":"https://www.ory.sh/welcome","return_session_token_exchange_code":true}`, http.StatusCreated)
+		id := gjson.GetBytes(body, "id").String()
+		assert.Equal(t, "api", gjson.GetBytes(body, "type").String(), "%s", body)
+		assert.Equal(t, "prefilled@ory.sh", gjson.GetBytes(body, "login_hint").String(), "%s", body)
+		assert.Equal(t, "prefilled@ory.sh", gjson.GetBytes(body, "ui.nodes.#(attributes.name==identifier).attributes.value").String(), "%s", body)
+		assert.Equal(t, "https://www.ory.sh/welcome", gjson.GetBytes(body, "return_to").String(), "%s", body)
+		assert.NotEmpty(t, gjson.GetBytes(body, "session_token_exchange_code").String(), "%s", body)
+		assert.Contains(t, res.Header.Get("Location"), login.RouteGetFlow+"?id="+id)
+
+		// The flow can be fetched by the native app.
+		body = testhelpers.EasyGetBody(t, public.Client(), public.URL+login.RouteGetFlow+"?id="+id)
+		assert.Equal(t, id, gjson.GetBytes(body, "id").String(), "%s", body)
+		assert.Equal(t, "prefilled@ory.sh", gjson.GetBytes(body, "ui.nodes.#(attributes.name==identifier).attributes.value").String(), "%s", body)
+	})
+
+	t.Run("case=requires a login hint", func(t *testing.T) {
+		_, body := create(t, `{"login_hint":" "}`, http.StatusBadRequest)
+		assert.Equal(t, "The login hint must not be empty.", gjson.GetBytes(body, "error.reason").String(), "%s", body)
+	})
+
+	t.Run("case=rejects unknown fields", func(t *testing.T) {
+		create(t, `{"login_hint":"prefilled@ory.sh","aal":"aal2"}`, http.StatusBadRequest)
+	})
+
+	t.Run("case=rejects return_to urls which are not allowed", func(t *testing.T) {
+		create(t, `{"login_hint":"prefilled@ory.sh","return_to":"https://evil.com"}`, http.StatusBadRequest)
+	})
+}
+
+func TestDebugTrace(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	public, _ := testhelpers.NewKratosServerWithCSRF(t, reg)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/password.schema.json")
+	conf.MustSet(ctx, config.ViperKeySelfServiceDebugTraceEnabled, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceDebugTraceResponse, true)
+
+	req, err := http.NewRequest("GET", public.URL+login.RouteInitAPIFlow, nil)
+	require.NoError(t, err)
+	req.Header.Set(x.DebugTraceHeader, "true")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	trace := gjson.Parse(res.Header.Get(x.DebugTraceHeader))
+	assert.Equal(t, "populate", trace.Get(`#(name=="password").phase`).String(), "%s", trace.Raw)
+	assert.Equal(t, "success", trace.Get(`#(name=="password").result`).String(), "%s", trace.Raw)
+	assert.Contains(t, trace.Get(`#(name=="password").nodes_added`).Value(), "password/password", "%s", trace.Raw)
+	assert.Equal(t, "skipped", trace.Get(`#(name=="totp").result`).String(), "%s", trace.Raw)
+	assert.Equal(t, "The strategy is disabled in the configuration.", trace.Get(`#(name=="totp").reason`).String(), "%s", trace.Raw)
 }
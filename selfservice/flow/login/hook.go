@@ -18,6 +18,7 @@ import (
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/selfservice/sessiontokenexchange"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/ui/container"
@@ -25,6 +26,7 @@ import (
 	"github.com/ory/kratos/x"
 	"github.com/ory/kratos/x/events"
 	"github.com/ory/x/otelx"
+	"github.com/ory/x/urlx"
 )
 
 type (
@@ -40,17 +42,27 @@ type (
 		PreLoginHooks(ctx context.Context) []PreHookExecutor
 		PostLoginHooks(ctx context.Context, credentialsType identity.CredentialsType) []PostHookExecutor
 	}
+
+	PasswordChangeHookProvider interface {
+		// PasswordChangeLoginHook returns the hook which tells API clients to continue in the settings
+		// flow if the identity must set a new password.
+		PasswordChangeLoginHook() PostHookExecutor
+	}
 )
 
 type (
 	executorDependencies interface {
+		funnel.RecorderProvider
 		config.Provider
 		hydra.Provider
 		identity.PrivilegedPoolProvider
 		identity.ManagementProvider
+		identity.WebhookSenderProvider
 		session.ManagementProvider
 		session.PersistenceProvider
 		x.CSRFTokenGeneratorProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
 		x.WriterProvider
 		x.LoggingProvider
 		x.TracingProvider
@@ -59,6 +71,7 @@ type (
 
 		FlowPersistenceProvider
 		HooksProvider
+		PasswordChangeHookProvider
 		StrategyProvider
 	}
 	HookExecutor struct {
@@ -98,6 +111,11 @@ func (e *HookExecutor) checkAAL(ctx context.Context, s *session.Session, a *Flow
 }
 
 func (e *HookExecutor) handleLoginError(_ http.ResponseWriter, r *http.Request, g node.UiNodeGroup, f *Flow, i *identity.Identity, flowError error) error {
+	if errors.Is(flowError, ErrAddressNotVerified) {
+		// Show the message in the flow regardless of which strategy completed the login.
+		flowError = errors.WithStack(schema.NewAddressNotVerifiedError())
+	}
+
 	if f != nil {
 		if i != nil {
 			cont, err := container.NewFromStruct("", g, i.Traits, "traits")
@@ -138,6 +156,12 @@ func (e *HookExecutor) PostLoginHook(
 	s.IdentityID = i.ID
 	s.Identity = i
 
+	if satisfied, err := e.enforceFirstFactorPolicy(ctx, f, i, s); err != nil {
+		return err
+	} else if !satisfied {
+		return e.continueWithNextFirstFactor(w, r, f)
+	}
+
 	if err := e.maybeLinkCredentials(ctx, s, i, f); err != nil {
 		return err
 	}
@@ -145,6 +169,15 @@ func (e *HookExecutor) PostLoginHook(
 	if err := e.d.SessionManager().ActivateSession(r, s, i, time.Now().UTC()); err != nil {
 		return err
 	}
+	e.applyRemember(ctx, f, s)
+
+	// Identities which must set a new password only receive a session restricted to the settings flow.
+	if i.PasswordResetRequired && f.RequestedAAL == identity.AuthenticatorAssuranceLevel1 {
+		s.PasswordResetRequired = true
+	}
+	if err := s.SetPasswordExpiry(ctx, e.d.Config(), i); err != nil {
+		return err
+	}
 
 	c := e.d.Config()
 	// Verify the redirect URL before we do any other processing.
@@ -155,6 +188,7 @@ func (e *HookExecutor) PostLoginHook(
 		x.SecureRedirectAllowURLs(c.SelfServiceBrowserAllowedReturnToDomains(ctx)),
 		x.SecureRedirectAllowSelfServiceURLs(c.SelfPublicURL(ctx)),
 		x.SecureRedirectOverrideDefaultReturnTo(c.SelfServiceFlowLoginReturnTo(ctx, f.Active.String())),
+		x.SecureRedirectAllowReturnToGrants(e.d),
 	)
 	if err != nil {
 		return err
@@ -165,6 +199,26 @@ func (e *HookExecutor) PostLoginHook(
 		"redirect_reason": "login successful",
 	})...)
 
+	// Identities which must set a new password or which are in the MFA enrollment campaign continue in
+	// the settings flow. OAuth2 login requests are only accepted once the new password is set, so these
+	// logins continue with a new login flow for the same login challenge afterwards.
+	if f.Type == flow.TypeBrowser && f.OAuth2LoginChallenge != "" && s.PasswordResetRequired {
+		loginTo := urlx.CopyWithQuery(urlx.AppendPaths(c.SelfPublicURL(ctx), RouteInitBrowserFlow),
+			url.Values{"login_challenge": {string(f.OAuth2LoginChallenge)}})
+		returnTo = session.PasswordResetRedirect(ctx, c, loginTo)
+		span.SetAttributes(attribute.String("redirect_reason", "password reset required"))
+	} else if f.Type == flow.TypeBrowser && f.OAuth2LoginChallenge == "" && f.ReturnToVerification == "" {
+		if s.PasswordResetRequired {
+			returnTo = session.PasswordResetRedirect(ctx, c, returnTo)
+			span.SetAttributes(attribute.String("redirect_reason", "password reset required"))
+		} else if enrollTo, ok, err := session.MFAEnrollmentRedirect(ctx, c, i, returnTo); err != nil {
+			return err
+		} else if ok {
+			returnTo = enrollTo
+			span.SetAttributes(attribute.String("redirect_reason", "mfa enrollment campaign"))
+		}
+	}
+
 	if f.Type == flow.TypeBrowser && x.IsJSONRequest(r) {
 		f.AddContinueWith(flow.NewContinueWithRedirectBrowserTo(returnTo.String()))
 	}
@@ -178,7 +232,9 @@ func (e *HookExecutor) PostLoginHook(
 		WithField("flow_method", f.Active).
 		Debug("Running ExecuteLoginPostHook.")
 	for k, executor := range e.d.PostLoginHooks(ctx, f.Active) {
-		if err := executor.ExecuteLoginPostHook(w, r, g, f, s); err != nil {
+		if err := flow.TraceHook(r, e.d, f, flow.HookPhasePost, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecuteLoginPostHook(w, r, g, f, s)
+		}); err != nil {
 			if errors.Is(err, ErrHookAbortFlow) {
 				e.d.Logger().
 					WithRequest(r).
@@ -206,8 +262,25 @@ func (e *HookExecutor) PostLoginHook(
 			Debug("ExecuteLoginPostHook completed successfully.")
 	}
 
+	if err := session.ValidateMetadata(ctx, c, s); err != nil {
+		return e.handleLoginError(w, r, g, f, i, err)
+	}
+
+	if revoked, err := session.EnforceConcurrencyLimit(ctx, c, e.d.SessionPersister(), s); err != nil {
+		return e.handleLoginError(w, r, g, f, i, err)
+	} else if revoked > 0 {
+		e.d.Audit().
+			WithRequest(r).
+			WithField("identity_id", i.ID).
+			WithField("revoked_sessions", revoked).
+			Info("Revoked sessions because the identity exceeded the maximum number of active sessions.")
+	}
+
 	if f.Type == flow.TypeAPI {
 		span.SetAttributes(attribute.String("flow_type", string(flow.TypeAPI)))
+		if err := e.d.PasswordChangeLoginHook().ExecuteLoginPostHook(w, r, g, f, s); err != nil {
+			return e.handleLoginError(w, r, g, f, i, err)
+		}
 		if err := e.d.SessionPersister().UpsertSession(ctx, s); err != nil {
 			return errors.WithStack(err)
 		}
@@ -227,6 +300,9 @@ func (e *HookExecutor) PostLoginHook(
 			Method:       f.Active.String(),
 			SSOProvider:  provider,
 		}))
+		e.d.IdentityWebhookSender().Send(ctx, i.ID, identity.WebhookEventSessionIssued, &identity.WebhookSessionEventData{SessionID: s.ID})
+		e.d.FlowFunnelRecorder().FlowSucceeded(ctx, flow.LoginFlow, f.ID)
+		flow.CountLogin(f.Active.String(), string(s.AuthenticatorAssuranceLevel), flow.LoginResultSuccess)
 		if f.IDToken != "" {
 			// We don't want to redirect with the code, if the flow was submitted with an ID token.
 			// This is the case for Sign in with native Apple SDK or Google SDK.
@@ -266,6 +342,9 @@ func (e *HookExecutor) PostLoginHook(
 		IdentityID: i.ID, FlowType: string(f.Type), RequestedAAL: string(f.RequestedAAL), IsRefresh: f.Refresh, Method: f.Active.String(),
 		SSOProvider: provider,
 	}))
+	e.d.IdentityWebhookSender().Send(ctx, i.ID, identity.WebhookEventSessionIssued, &identity.WebhookSessionEventData{SessionID: s.ID})
+	e.d.FlowFunnelRecorder().FlowSucceeded(ctx, flow.LoginFlow, f.ID)
+	flow.CountLogin(f.Active.String(), string(s.AuthenticatorAssuranceLevel), flow.LoginResultSuccess)
 
 	if x.IsJSONRequest(r) {
 		span.SetAttributes(attribute.String("flow_type", "spa"))
@@ -305,7 +384,7 @@ func (e *HookExecutor) PostLoginHook(
 
 		// If Kratos is used as a Hydra login provider, we need to redirect back to Hydra by returning a 422 status
 		// with the post login challenge URL as the body.
-		if f.OAuth2LoginChallenge != "" {
+		if f.OAuth2LoginChallenge != "" && !s.PasswordResetRequired {
 			postChallengeURL, err := e.d.Hydra().AcceptLoginRequest(ctx,
 				hydra.AcceptLoginRequestParams{
 					LoginChallenge:        string(f.OAuth2LoginChallenge),
@@ -359,7 +438,7 @@ func (e *HookExecutor) PostLoginHook(
 	}
 
 	finalReturnTo := returnTo.String()
-	if f.OAuth2LoginChallenge != "" {
+	if f.OAuth2LoginChallenge != "" && !s.PasswordResetRequired {
 		rt, err := e.d.Hydra().AcceptLoginRequest(ctx,
 			hydra.AcceptLoginRequestParams{
 				LoginChallenge:        string(f.OAuth2LoginChallenge),
@@ -383,7 +462,9 @@ func (e *HookExecutor) PostLoginHook(
 
 func (e *HookExecutor) PreLoginHook(w http.ResponseWriter, r *http.Request, a *Flow) error {
 	for _, executor := range e.d.PreLoginHooks(r.Context()) {
-		if err := executor.ExecuteLoginPreHook(w, r, a); err != nil {
+		if err := flow.TraceHook(r, e.d, a, flow.HookPhasePre, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecuteLoginPreHook(w, r, a)
+		}); err != nil {
 			return err
 		}
 	}
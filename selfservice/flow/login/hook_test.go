@@ -189,6 +189,45 @@ func TestLoginExecutor(t *testing.T) {
 					assert.NotEmpty(t, gjson.Get(body, "session.identity.id").String())
 				})
 
+				t.Run("case=restrict the session if the identity must set a new password", func(t *testing.T) {
+					t.Cleanup(testhelpers.SelfServiceHookConfigReset(t, conf))
+
+					useIdentity := testhelpers.SelfServiceHookCreateFakeIdentity(t, reg)
+					useIdentity.PasswordResetRequired = true
+					require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, useIdentity, "password_reset_required"))
+
+					t.Run("api client", func(t *testing.T) {
+						res, body := makeRequestPost(t, newServer(t, flow.TypeAPI, useIdentity), true, url.Values{})
+						assert.EqualValues(t, http.StatusOK, res.StatusCode)
+						assert.True(t, gjson.Get(body, "session.password_reset_required").Bool(), "%s", body)
+					})
+
+					t.Run("browser client continues in the settings flow", func(t *testing.T) {
+						ts := newServer(t, flow.TypeBrowser, useIdentity)
+						_, body := makeRequestPost(t, ts, true, url.Values{})
+						assert.True(t, gjson.Get(body, "session.password_reset_required").Bool(), "%s", body)
+						assert.Equal(t, ts.URL+"/self-service/settings/browser?return_to=https%3A%2F%2Fwww.ory.sh%2F", gjson.Get(body, "continue_with.0.redirect_browser_to").String(), "%s", body)
+					})
+
+					t.Run("oauth2 login is only accepted after setting the new password", func(t *testing.T) {
+						withOAuthChallenge := func(f *login.Flow) {
+							f.OAuth2LoginChallenge = hydra.FakeValidLoginChallenge
+						}
+						ts := newServer(t, flow.TypeBrowser, useIdentity, withOAuthChallenge)
+						loginTo := ts.URL + "/self-service/login/browser?login_challenge=" + hydra.FakeValidLoginChallenge
+						settingsTo := ts.URL + "/self-service/settings/browser?return_to=" + url.QueryEscape(loginTo)
+
+						res, body := makeRequestPost(t, ts, true, url.Values{})
+						assert.EqualValues(t, http.StatusOK, res.StatusCode, "%s", body)
+						assert.True(t, gjson.Get(body, "session.password_reset_required").Bool(), "%s", body)
+						assert.Equal(t, settingsTo, gjson.Get(body, "continue_with.0.redirect_browser_to").String(), "%s", body)
+						assert.NotContains(t, body, hydra.FakePostLoginURL)
+
+						res, _ = makeRequestPost(t, ts, false, url.Values{})
+						assert.Equal(t, settingsTo, res.Request.URL.String())
+					})
+				})
+
 				t.Run("suite=handle login challenge with browser and application/json", func(t *testing.T) {
 					t.Run("case=includes the return_to address for a valid challenge", func(t *testing.T) {
 						t.Cleanup(testhelpers.SelfServiceHookConfigReset(t, conf))
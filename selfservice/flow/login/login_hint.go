@@ -0,0 +1,60 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package login
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+)
+
+// loginHintMaxLength is the maximum length of a login hint, which is the maximum length of an
+// email address.
+const loginHintMaxLength = 320
+
+// LoginHintHydrator is implemented by login strategies which select the login methods of the
+// identifier given as login hint when the flow is created, as if the user entered it.
+type LoginHintHydrator interface {
+	PopulateLoginMethodLoginHint(r *http.Request, f *Flow) error
+}
+
+func loginHintFromRequest(r *http.Request) (string, error) {
+	hint := strings.TrimSpace(r.URL.Query().Get("login_hint"))
+	if len(hint) > loginHintMaxLength {
+		return "", errors.WithStack(herodot.ErrBadRequest.WithReasonf("The login hint must not be longer than %d characters.", loginHintMaxLength))
+	}
+	return hint, nil
+}
+
+// applyLoginHint pre-fills the identifier fields of first factor login flows with the login hint.
+// Refresh flows are skipped because their identifier is the one of the signed in identity.
+func (h *Handler) applyLoginHint(r *http.Request, f *Flow) error {
+	if f.LoginHint == "" || f.RequestedAAL != identity.AuthenticatorAssuranceLevel1 || f.IsRefresh() {
+		return nil
+	}
+
+	// Flows of organizations and account linking flows only show some of the login methods.
+	if !f.OrganizationID.Valid && !f.isAccountLinkingFlow {
+		for _, s := range h.d.LoginStrategies(r.Context()) {
+			hydrator, ok := s.(LoginHintHydrator)
+			if !ok {
+				continue
+			}
+			if err := hydrator.PopulateLoginMethodLoginHint(r, f); err != nil {
+				return err
+			}
+		}
+	}
+
+	for k := range f.UI.Nodes {
+		if f.UI.Nodes[k].ID() == "identifier" {
+			f.UI.Nodes[k].Attributes.SetValue(f.LoginHint)
+		}
+	}
+	return nil
+}
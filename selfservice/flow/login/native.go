@@ -0,0 +1,110 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/session"
+	"github.com/ory/x/otelx"
+)
+
+// NativeFlowParameters configure a native login flow which is executed in-process.
+type NativeFlowParameters struct {
+	// Refresh forces the identity to authenticate again even if the session is still valid.
+	Refresh bool
+	// RequestedAAL requests a specific authenticator assurance level.
+	RequestedAAL identity.AuthenticatorAssuranceLevel
+	// SessionToken is the token of the session to refresh or upgrade.
+	SessionToken string
+}
+
+// CreateNativeFlow initializes a native login flow in-process.
+func (h *Handler) CreateNativeFlow(ctx context.Context, p NativeFlowParameters) (_ *Flow, err error) {
+	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.login.CreateNativeFlow")
+	defer otelx.End(span, &err)
+
+	query := url.Values{}
+	if p.Refresh {
+		query.Set("refresh", "true")
+	}
+	if p.RequestedAAL != "" {
+		query.Set("aal", string(p.RequestedAAL))
+	}
+
+	e, err := flow.NewNativeExecution(ctx, h.d.Config().SelfPublicURL(ctx), http.MethodGet, RouteInitAPIFlow, query, p.SessionToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	f, _, err := h.NewLoginFlow(e, e.Request, flow.TypeAPI)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// UpdateNativeFlow submits the JSON payload of a login method to the native login flow
+// in-process. If the payload is invalid, the flow with the validation messages is returned
+// instead of the response.
+func (h *Handler) UpdateNativeFlow(ctx context.Context, flowID uuid.UUID, sessionToken string, body json.RawMessage) (_ *APIFlowResponse, _ *Flow, err error) {
+	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.login.UpdateNativeFlow")
+	defer otelx.End(span, &err)
+
+	e, err := flow.NewNativeExecution(ctx, h.d.Config().SelfPublicURL(ctx), http.MethodPost, RouteSubmitFlow, url.Values{"flow": {flowID.String()}}, sessionToken, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := h.d.LoginFlowPersister().GetLoginFlow(ctx, flowID)
+	if err != nil {
+		return nil, nil, err
+	} else if f.Type != flow.TypeAPI {
+		return nil, nil, errors.WithStack(herodot.ErrBadRequest.WithReason("Only native login flows can be executed in-process."))
+	}
+
+	sess, err := h.sessionForUpdate(e.Request, f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	group, err := h.executeFlow(e, e.Request, f, sess)
+	if err != nil && !errors.Is(err, flow.ErrCompletedByStrategy) {
+		h.d.LoginFlowErrorHandler().WriteFlowError(e, e.Request, f, group, err)
+	}
+
+	if err := e.Err(); err != nil {
+		return nil, nil, err
+	} else if e.StatusCode() != http.StatusOK {
+		// The flow was updated with validation messages or the next step of the method.
+		f, err := h.d.LoginFlowPersister().GetLoginFlow(ctx, flowID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, f, nil
+	}
+
+	var res struct {
+		Token string `json:"session_token"`
+	}
+	if err := e.Decode(&res); err != nil {
+		return nil, nil, err
+	}
+
+	// The session is loaded from the store because the response only contains its JSON representation.
+	sess, err = h.d.SessionPersister().GetSessionByToken(ctx, res.Token, session.ExpandEverything, identity.ExpandDefault)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &APIFlowResponse{Token: res.Token, Session: sess}, nil, nil
+}
@@ -0,0 +1,117 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package login
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/x/decoderx"
+)
+
+const internalContextRememberPath = "remember"
+
+// rememberSchema declares `remember` as a string because the decoder sets booleans which are
+// missing from forms to false, while a missing choice falls back to the default of the method.
+var rememberSchema = []byte(`{
+  "$id": "https://schemas.ory.sh/kratos/selfservice/login/remember.schema.json",
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "remember": {
+      "type": "string"
+    }
+  }
+}`)
+
+// addRememberNode adds the remember me checkbox to first factor login flows.
+func (h *Handler) addRememberNode(ctx context.Context, f *Flow) {
+	if !h.d.Config().SessionRememberMeEnabled(ctx) || f.RequestedAAL != identity.AuthenticatorAssuranceLevel1 {
+		return
+	}
+
+	// The checkbox has no value so that it is only submitted if it is checked.
+	f.UI.Nodes.Append(node.NewInputField("remember", nil, node.DefaultGroup, node.InputAttributeTypeCheckbox).
+		WithMetaLabel(text.NewInfoNodeLabelRememberMe()))
+}
+
+// storeRemember stores the `remember` field of a login submission in the flow. The choice is kept
+// in the flow because strategies such as OpenID Connect complete the login in a later request.
+func (h *Handler) storeRemember(r *http.Request, f *Flow) error {
+	ctx := r.Context()
+	if !h.d.Config().SessionRememberMeEnabled(ctx) {
+		return nil
+	}
+
+	compiler, err := decoderx.HTTPRawJSONSchemaCompiler(rememberSchema)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var body struct {
+		Remember string `json:"remember" form:"remember"`
+	}
+	if err := decoderx.NewHTTP().Decode(r, &body, compiler,
+		decoderx.HTTPKeepRequestBody(true),
+		decoderx.HTTPDecoderAllowedMethods("POST", "PUT", "PATCH", "GET"),
+		decoderx.HTTPDecoderSetValidatePayloads(false),
+		decoderx.HTTPDecoderJSONFollowsFormFormat()); err != nil {
+		return errors.WithStack(err)
+	}
+	if body.Remember == "" {
+		return nil
+	}
+	// Browsers submit checked checkboxes without a value as "on".
+	remember := body.Remember == "on"
+	if !remember {
+		remember, err = strconv.ParseBool(body.Remember)
+		if err != nil {
+			return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to parse the remember field: %s", err))
+		}
+	}
+
+	f.EnsureInternalContext()
+	if stored := gjson.GetBytes(f.InternalContext, internalContextRememberPath); stored.Exists() && stored.Bool() == remember {
+		return nil
+	}
+
+	f.InternalContext, err = sjson.SetBytes(f.InternalContext, internalContextRememberPath, remember)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return h.d.LoginFlowPersister().UpdateLoginFlow(ctx, f)
+}
+
+// applyRemember shortens sessions issued by first factor logins in which the user did not choose
+// to be remembered. Browser flows which did not submit a choice are not remembered, as unchecked
+// checkboxes are not submitted, while API flows use the default of the login method.
+func (e *HookExecutor) applyRemember(ctx context.Context, f *Flow, s *session.Session) {
+	c := e.d.Config()
+	if !c.SessionRememberMeEnabled(ctx) || f.RequestedAAL != identity.AuthenticatorAssuranceLevel1 || !s.ID.IsNil() {
+		// Refreshing or upgrading a session does not change how long it is remembered.
+		return
+	}
+
+	remember := f.Type == flow.TypeAPI && c.SessionRememberMeDefault(ctx, f.Active.String())
+	if stored := gjson.GetBytes(f.InternalContext, internalContextRememberPath); stored.Exists() {
+		remember = stored.Bool()
+	}
+	if remember {
+		return
+	}
+
+	s.ShortLived = true
+	s.ExpiresAt = s.AuthenticatedAt.Add(c.SessionRememberMeShortLifespan(ctx))
+}
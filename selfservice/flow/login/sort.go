@@ -6,10 +6,15 @@ package login
 import (
 	"context"
 
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/ui/node"
 )
 
-func sortNodes(ctx context.Context, n node.Nodes) error {
+func sortNodes(ctx context.Context, conf *config.Config, n node.Nodes) error {
+	if conf.UseCompatibilitySwitch(ctx, config.CompatibilityLegacyNodeOrdering) {
+		return nil
+	}
+
 	return n.SortBySchema(ctx,
 		node.SortByGroups([]node.UiNodeGroup{
 			node.OpenIDConnectGroup,
@@ -37,10 +37,14 @@ type (
 	handlerDependencies interface {
 		x.WriterProvider
 		x.CSRFProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
 		session.ManagementProvider
 		session.PersistenceProvider
 		errorx.ManagementProvider
+		identity.WebhookSenderProvider
 		config.Provider
+		x.LoggingProvider
 	}
 	HandlerProvider interface {
 		LogoutHandler() *Handler
@@ -158,6 +162,7 @@ func (h *Handler) createBrowserLogoutFlow(w http.ResponseWriter, r *http.Request
 			x.SecureRedirectUseSourceURL(requestURL.String()),
 			x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
 			x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+			x.SecureRedirectAllowReturnToGrants(h.d),
 		)
 		if err != nil {
 			h.d.SelfServiceErrorManager().Forward(r.Context(), w, r, err)
@@ -259,6 +264,11 @@ func (h *Handler) performNativeLogout(w http.ResponseWriter, r *http.Request, _
 	}
 
 	trace.SpanFromContext(r.Context()).AddEvent(events.NewSessionRevoked(r.Context(), sess.ID, sess.IdentityID))
+	h.d.Audit().WithRequest(r).
+		WithField("identity_id", sess.IdentityID).
+		WithField("session_id", sess.ID).
+		Info("An identity logged out and its session was revoked.")
+	h.d.IdentityWebhookSender().Send(r.Context(), sess.IdentityID, identity.WebhookEventSessionRevoked, &identity.WebhookSessionEventData{SessionID: sess.ID})
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -347,6 +357,11 @@ func (h *Handler) updateLogoutFlow(w http.ResponseWriter, r *http.Request, ps ht
 	}
 
 	trace.SpanFromContext(r.Context()).AddEvent(events.NewSessionRevoked(r.Context(), sess.ID, sess.IdentityID))
+	h.d.Audit().WithRequest(r).
+		WithField("identity_id", sess.IdentityID).
+		WithField("session_id", sess.ID).
+		Info("An identity logged out and its session was revoked.")
+	h.d.IdentityWebhookSender().Send(r.Context(), sess.IdentityID, identity.WebhookEventSessionRevoked, &identity.WebhookSessionEventData{SessionID: sess.ID})
 
 	h.completeLogout(w, r)
 }
@@ -358,6 +373,7 @@ func (h *Handler) completeLogout(w http.ResponseWriter, r *http.Request) {
 		x.SecureRedirectUseSourceURL(r.RequestURI),
 		x.SecureRedirectAllowURLs(h.d.Config().SelfServiceBrowserAllowedReturnToDomains(r.Context())),
 		x.SecureRedirectAllowSelfServiceURLs(h.d.Config().SelfPublicURL(r.Context())),
+		x.SecureRedirectAllowReturnToGrants(h.d),
 	)
 	if err != nil {
 		h.d.SelfServiceErrorManager().Forward(r.Context(), w, r, err)
@@ -0,0 +1,76 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	LoginResultSuccess = "success"
+	LoginResultFailure = "failure"
+	LoginResultExpired = "expired"
+
+	methodUnknown = "unknown"
+)
+
+var (
+	registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_selfservice_registrations_total",
+		Help: "Number of identities which completed a registration flow, labelled with the method used.",
+	}, []string{"method"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_selfservice_logins_total",
+		Help: "Number of login flow submissions, labelled with the method used, the authenticator assurance level, and the result.",
+	}, []string{"method", "aal", "result"})
+
+	mfaEnrollments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_selfservice_mfa_enrollments_total",
+		Help: "Number of identities which set up a second factor for the first time, labelled with the method.",
+	}, []string{"method"})
+
+	recoveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_selfservice_recoveries_total",
+		Help: "Number of completed recovery flows, labelled with the method used.",
+	}, []string{"method"})
+)
+
+// EventCollectors returns the Prometheus collectors which count the self-service business events.
+// They are registered by the registry's metrics setup.
+func EventCollectors() []prometheus.Collector {
+	return []prometheus.Collector{registrations, logins, mfaEnrollments, recoveries}
+}
+
+// methodLabel keeps the label set stable for flows which did not record their method.
+func methodLabel(method string) string {
+	if method == "" {
+		return methodUnknown
+	}
+	return method
+}
+
+// CountRegistration records a completed registration.
+func CountRegistration(method string) {
+	registrations.WithLabelValues(methodLabel(method)).Inc()
+}
+
+// CountLogin records a login submission with the given result, which is one of
+// LoginResultSuccess, LoginResultFailure, or LoginResultExpired.
+func CountLogin(method, aal, result string) {
+	if aal == "" {
+		aal = methodUnknown
+	}
+	logins.WithLabelValues(methodLabel(method), aal, result).Inc()
+}
+
+// CountMFAEnrollment records that an identity set up the given second factor for the first time.
+func CountMFAEnrollment(method string) {
+	mfaEnrollments.WithLabelValues(methodLabel(method)).Inc()
+}
+
+// CountRecovery records a completed recovery.
+func CountRecovery(method string) {
+	recoveries.WithLabelValues(methodLabel(method)).Inc()
+}
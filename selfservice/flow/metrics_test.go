@@ -0,0 +1,50 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package flow_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/x"
+)
+
+func TestBusinessMetrics(t *testing.T) {
+	for _, c := range flow.EventCollectors() {
+		require.NoError(t, prometheus.Register(c))
+		t.Cleanup(func() { prometheus.Unregister(c) })
+	}
+
+	flow.CountRegistration("password")
+	flow.CountLogin("totp", "aal2", flow.LoginResultSuccess)
+	flow.CountLogin("", "", flow.LoginResultFailure)
+	flow.CountLogin("password", "aal1", flow.LoginResultExpired)
+	flow.CountMFAEnrollment("webauthn")
+	flow.CountRecovery("code")
+
+	r := httptest.NewRequest("GET", "/metrics/prometheus", nil)
+	w := httptest.NewRecorder()
+	x.ServeMetrics(w, r, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+
+	for _, expected := range []string{
+		`kratos_selfservice_registrations_total{method="password"} 1`,
+		`kratos_selfservice_logins_total{aal="aal2",method="totp",result="success"} 1`,
+		`kratos_selfservice_logins_total{aal="unknown",method="unknown",result="failure"} 1`,
+		`kratos_selfservice_logins_total{aal="aal1",method="password",result="expired"} 1`,
+		`kratos_selfservice_mfa_enrollments_total{method="webauthn"} 1`,
+		`kratos_selfservice_recoveries_total{method="code"} 1`,
+	} {
+		assert.Contains(t, string(body), expected+"\n")
+	}
+}
@@ -11,6 +11,7 @@ package flow
 // - 'settings'
 // - 'recovery'
 // - 'verification'
+// - 'cross_device_login'
 //
 // swagger:ignore
 type FlowName string
@@ -21,6 +22,8 @@ const (
 	SettingsFlow     FlowName = "settings"
 	RecoveryFlow     FlowName = "recovery"
 	VerificationFlow FlowName = "verification"
+
+	CrossDeviceLoginFlow FlowName = "cross_device_login"
 )
 
 func (t Type) String() string {
@@ -0,0 +1,97 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+// NativeExecution executes a native flow in-process, for example for the embedded client. It
+// provides the request and buffers the response of the strategies and hooks, which read the
+// payload and the session token from the request as if it had been sent to the public API.
+type NativeExecution struct {
+	Request *http.Request
+
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+var _ http.ResponseWriter = (*NativeExecution)(nil)
+
+// NewNativeExecution returns an execution of the public route at base. The body is the JSON
+// payload of the method and may be nil.
+func NewNativeExecution(ctx context.Context, base *url.URL, method, route string, query url.Values, sessionToken string, body json.RawMessage) (*NativeExecution, error) {
+	u := *base
+	u.Path = route
+	u.RawQuery = query.Encode()
+
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r.Header.Set("Accept", "application/json")
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if sessionToken != "" {
+		r.Header.Set("X-Session-Token", sessionToken)
+	}
+
+	return &NativeExecution{Request: r, header: http.Header{}}, nil
+}
+
+func (e *NativeExecution) Header() http.Header {
+	return e.header
+}
+
+func (e *NativeExecution) Write(b []byte) (int, error) {
+	if e.status == 0 {
+		e.status = http.StatusOK
+	}
+	return e.body.Write(b)
+}
+
+func (e *NativeExecution) WriteHeader(status int) {
+	if e.status == 0 {
+		e.status = status
+	}
+}
+
+// StatusCode returns the status code of the response.
+func (e *NativeExecution) StatusCode() int {
+	return e.status
+}
+
+// Err returns the error if the response is an error.
+func (e *NativeExecution) Err() error {
+	var envelope struct {
+		Error *herodot.DefaultError `json:"error"`
+	}
+	if e.status == 0 {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReason("The flow was executed without a response."))
+	} else if err := json.Unmarshal(e.body.Bytes(), &envelope); err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Received unexpected response with status code %d: %s", e.status, e.body.String()))
+	} else if envelope.Error == nil {
+		return nil
+	}
+
+	if envelope.Error.CodeField == 0 {
+		envelope.Error.CodeField = e.status
+	}
+	return errors.WithStack(envelope.Error)
+}
+
+// Decode decodes the response into v.
+func (e *NativeExecution) Decode(v any) error {
+	return errors.WithStack(json.Unmarshal(e.body.Bytes(), v))
+}
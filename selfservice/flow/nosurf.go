@@ -6,6 +6,7 @@ package flow
 import (
 	"net/http"
 
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/x"
 )
 
@@ -24,3 +25,47 @@ func GetCSRFToken(reg interface {
 
 	return token
 }
+
+// NewCSRFToken returns the anti-CSRF token for a new flow. If `security.csrf.token_rotation`
+// is set to `flow` or `request`, a new token is issued for browser flows.
+func NewCSRFToken(reg interface {
+	config.Provider
+	x.CSRFProvider
+	x.CSRFTokenGeneratorProvider
+}, w http.ResponseWriter, r *http.Request, p Type) string {
+	token := reg.GenerateCSRFToken(r)
+	if p != TypeBrowser || token == "" {
+		// An empty token indicates that the request was not handled by the anti-CSRF
+		// middleware, for example because it was sent to the admin API.
+		return token
+	}
+
+	if reg.Config().SecurityCSRFTokenRotation(r.Context()) == config.CSRFTokenRotationSession {
+		return token
+	}
+
+	return reg.CSRFHandler().RegenerateToken(w, r)
+}
+
+// RotateCSRFToken issues a new anti-CSRF token for a submitted browser flow if
+// `security.csrf.token_rotation` is set to `request`. The new token is set on the flow,
+// which needs to be persisted by the caller if RotateCSRFToken returns true.
+func RotateCSRFToken(reg interface {
+	config.Provider
+	x.CSRFProvider
+	x.CSRFTokenGeneratorProvider
+}, w http.ResponseWriter, r *http.Request, f interface {
+	GetType() Type
+	SetCSRFToken(string)
+}) bool {
+	if f.GetType() != TypeBrowser || reg.GenerateCSRFToken(r) == "" {
+		return false
+	}
+
+	if reg.Config().SecurityCSRFTokenRotation(r.Context()) != config.CSRFTokenRotationRequest {
+		return false
+	}
+
+	f.SetCSRFToken(reg.CSRFHandler().RegenerateToken(w, r))
+	return true
+}
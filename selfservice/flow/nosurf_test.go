@@ -5,11 +5,17 @@ package flow
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/nosurf"
+	"github.com/ory/x/configx"
+	"github.com/ory/x/contextx"
+	"github.com/ory/x/logrusx"
 )
 
 func TestGetCSRFToken(t *testing.T) {
@@ -40,12 +46,88 @@ func TestGetCSRFToken(t *testing.T) {
 	})
 }
 
+func TestNewCSRFToken(t *testing.T) {
+	for _, tc := range []struct {
+		rotation config.CSRFTokenRotation
+		expected string
+	}{
+		{rotation: config.CSRFTokenRotationSession, expected: "existing"},
+		{rotation: config.CSRFTokenRotationFlow, expected: "regenerated"},
+		{rotation: config.CSRFTokenRotationRequest, expected: "regenerated"},
+	} {
+		t.Run("rotation="+string(tc.rotation), func(t *testing.T) {
+			reg := &mockReg{
+				presentToken:     "existing",
+				regeneratedToken: "regenerated",
+				conf:             newRotationConfig(t, tc.rotation),
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+
+			assert.Equal(t, tc.expected, NewCSRFToken(reg, nil, r, TypeBrowser))
+			assert.Equal(t, "existing", NewCSRFToken(reg, nil, r, TypeAPI), "api flows are never rotated")
+
+			reg.presentToken = ""
+			assert.Equal(t, "", NewCSRFToken(reg, nil, r, TypeBrowser), "requests not handled by the anti-CSRF middleware are never rotated")
+		})
+	}
+}
+
+func TestRotateCSRFToken(t *testing.T) {
+	for _, tc := range []struct {
+		rotation config.CSRFTokenRotation
+		expected string
+	}{
+		{rotation: config.CSRFTokenRotationSession, expected: ""},
+		{rotation: config.CSRFTokenRotationFlow, expected: ""},
+		{rotation: config.CSRFTokenRotationRequest, expected: "regenerated"},
+	} {
+		t.Run("rotation="+string(tc.rotation), func(t *testing.T) {
+			reg := &mockReg{
+				presentToken:     "existing",
+				regeneratedToken: "regenerated",
+				conf:             newRotationConfig(t, tc.rotation),
+			}
+			r := httptest.NewRequest("POST", "/", nil)
+
+			f := &mockFlow{t: TypeBrowser}
+			RotateCSRFToken(reg, nil, r, f)
+			assert.Equal(t, tc.expected, f.token)
+
+			f = &mockFlow{t: TypeAPI}
+			RotateCSRFToken(reg, nil, r, f)
+			assert.Equal(t, "", f.token, "api flows are never rotated")
+		})
+	}
+}
+
+func newRotationConfig(t *testing.T, rotation config.CSRFTokenRotation) *config.Config {
+	return config.MustNew(t, logrusx.New("", ""), os.Stderr, &contextx.Default{}, configx.SkipValidation(), configx.WithValue(config.ViperKeySecurityCSRFTokenRotation, string(rotation)))
+}
+
+type mockFlow struct {
+	t     Type
+	token string
+}
+
+func (f *mockFlow) GetType() Type {
+	return f.t
+}
+
+func (f *mockFlow) SetCSRFToken(token string) {
+	f.token = token
+}
+
 type mockReg struct {
 	presentToken, regeneratedToken string
+	conf                           *config.Config
 
 	nosurf.Handler
 }
 
+func (m *mockReg) Config() *config.Config {
+	return m.conf
+}
+
 func (m *mockReg) GenerateCSRFToken(*http.Request) string {
 	return m.presentToken
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/x"
 )
@@ -36,9 +37,11 @@ var (
 
 type (
 	errorHandlerDependencies interface {
+		funnel.RecorderProvider
 		errorx.ManagementProvider
 		x.WriterProvider
 		x.LoggingProvider
+		x.CSRFProvider
 		x.CSRFTokenGeneratorProvider
 		config.Provider
 		StrategyProvider
@@ -93,7 +96,7 @@ func (s *ErrorHandler) WriteFlowError(
 			}
 		}
 		// create new flow because the old one is not valid
-		newFlow, err := FromOldFlow(s.d.Config(), s.d.Config().SelfServiceFlowRecoveryRequestLifespan(r.Context()), s.d.GenerateCSRFToken(r), r, strategy, *f)
+		newFlow, err := FromOldFlow(s.d.Config(), s.d.Config().SelfServiceFlowRecoveryRequestLifespan(r.Context()), flow.NewCSRFToken(s.d, w, r, f.Type), r, strategy, *f)
 		if err != nil {
 			// failed to create a new session and redirect to it, handle that error as a new one
 			s.WriteFlowError(w, r, f, group, err)
@@ -133,12 +136,15 @@ func (s *ErrorHandler) WriteFlowError(
 		return
 	}
 
+	s.d.FlowFunnelRecorder().FlowFailed(r.Context(), flow.RecoveryFlow, f.ID)
+
 	f.UI.ResetMessages()
 	if err := f.UI.ParseError(group, recoveryErr); err != nil {
 		s.forward(w, r, f, err)
 		return
 	}
 
+	flow.RotateCSRFToken(s.d, w, r, f)
 	f.Active = sqlxx.NullString(group)
 	if err := s.d.RecoveryFlowPersister().UpdateRecoveryFlow(r.Context(), f); err != nil {
 		s.forward(w, r, f, err)
@@ -108,26 +108,39 @@ type Flow struct {
 	//
 	// required: false
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" faker:"-" db:"-"`
+
+	// Brand is the brand of the white-label product this flow belongs to.
+	//
+	// This value is set using the `brand` query parameter when initializing the flow. It is
+	// passed to the UI and used to select the courier templates.
+	Brand sqlxx.NullString `json:"brand,omitempty" faker:"-" db:"brand"`
 }
 
 var _ flow.Flow = new(Flow)
 
-func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Request, strategy Strategy, ft flow.Type) (*Flow, error) {
-	now := time.Now().UTC()
+func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Request, strategy Strategy, ft flow.Type, opts ...x.SecureRedirectOption) (*Flow, error) {
+	now := x.Now().UTC()
 	id := x.NewUUID()
 
 	// Pre-validate the return to URL which is contained in the HTTP request.
 	requestURL := x.RequestURL(r).String()
 	_, err := x.SecureRedirectTo(r,
 		conf.SelfServiceBrowserDefaultReturnTo(r.Context()),
-		x.SecureRedirectUseSourceURL(requestURL),
-		x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
-		x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		append([]x.SecureRedirectOption{
+			x.SecureRedirectUseSourceURL(requestURL),
+			x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
+			x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		}, opts...)...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	brand, err := flow.BrandFromRequest(conf, r)
+	if err != nil {
+		return nil, err
+	}
+
 	flow := &Flow{
 		ID:         id,
 		ExpiresAt:  now.Add(exp),
@@ -140,6 +153,7 @@ func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Reques
 		State:     flow.StateChooseMethod,
 		CSRFToken: csrf,
 		Type:      ft,
+		Brand:     brand,
 	}
 
 	if strategy != nil {
@@ -164,6 +178,7 @@ func FromOldFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Re
 	}
 
 	nf.RequestURL = of.RequestURL
+	nf.Brand = of.Brand
 	return nf, nil
 }
 
@@ -188,14 +203,14 @@ func (f Flow) GetNID() uuid.UUID {
 }
 
 func (f *Flow) Valid() error {
-	if f.ExpiresAt.Before(time.Now().UTC()) {
+	if f.ExpiresAt.Before(x.Now().UTC()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 	return nil
 }
 
 func (f *Flow) AppendTo(src *url.URL) *url.URL {
-	return urlx.CopyWithQuery(src, url.Values{"flow": {f.ID.String()}})
+	return flow.AppendBrandTo(urlx.CopyWithQuery(src, url.Values{"flow": {f.ID.String()}}), f.Brand)
 }
 
 func (f *Flow) SetCSRFToken(token string) {
@@ -249,6 +264,10 @@ func (t *Flow) GetTransientPayload() json.RawMessage {
 	return t.TransientPayload
 }
 
+func (t *Flow) GetBrand() string {
+	return t.Brand.String()
+}
+
 func (f *Flow) ToLoggerField() map[string]interface{} {
 	if f == nil {
 		return map[string]interface{}{}
@@ -26,6 +26,7 @@ import (
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/x"
 )
@@ -43,6 +44,7 @@ type (
 		RecoveryHandler() *Handler
 	}
 	handlerDependencies interface {
+		funnel.RecorderProvider
 		errorx.ManagementProvider
 		identity.ManagementProvider
 		identity.PrivilegedPoolProvider
@@ -52,6 +54,9 @@ type (
 		x.CSRFTokenGeneratorProvider
 		x.WriterProvider
 		x.CSRFProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
+		x.TracingProvider
 		config.Provider
 		ErrorHandlerProvider
 		HookExecutorProvider
@@ -146,6 +151,8 @@ func (h *Handler) createNativeRecoveryFlow(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	h.d.FlowFunnelRecorder().FlowCreated(r.Context(), flow.RecoveryFlow, f.ID, f.ExpiresAt)
+
 	h.d.Writer().Write(w, r, f)
 }
 
@@ -160,6 +167,14 @@ type createBrowserRecoveryFlow struct {
 	//
 	// in: query
 	ReturnTo string `json:"return_to"`
+
+	// An optional brand of the white-label product the recovery flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
 }
 
 // swagger:route GET /self-service/recovery/browser frontend createBrowserRecoveryFlow
@@ -195,7 +210,7 @@ func (h *Handler) createBrowserRecoveryFlow(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	f, err := NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowRecoveryRequestLifespan(r.Context()), h.d.GenerateCSRFToken(r), r, activeRecoveryStrategy, flow.TypeBrowser)
+	f, err := NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowRecoveryRequestLifespan(r.Context()), flow.NewCSRFToken(h.d, w, r, flow.TypeBrowser), r, activeRecoveryStrategy, flow.TypeBrowser, x.SecureRedirectAllowReturnToGrants(h.d))
 	if err != nil {
 		h.d.SelfServiceErrorManager().Forward(r.Context(), w, r, err)
 		return
@@ -211,6 +226,8 @@ func (h *Handler) createBrowserRecoveryFlow(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	h.d.FlowFunnelRecorder().FlowCreated(r.Context(), flow.RecoveryFlow, f.ID, f.ExpiresAt)
+
 	redirTo := f.AppendTo(h.d.Config().SelfServiceFlowRecoveryUI(r.Context())).String()
 	x.AcceptToRedirectOrJSON(w, r, h.d.Writer(), f, redirTo)
 }
@@ -423,7 +440,9 @@ func (h *Handler) updateRecoveryFlow(w http.ResponseWriter, r *http.Request, ps
 	var g node.UiNodeGroup
 	var found bool
 	for _, ss := range h.d.AllRecoveryStrategies() {
-		err := ss.Recover(w, r, f)
+		err := flow.TraceStrategy(r, h.d, f, ss.RecoveryStrategyID(), func(r *http.Request) error {
+			return ss.Recover(w, r, f)
+		})
 		if errors.Is(err, flow.ErrStrategyNotResponsible) {
 			continue
 		} else if errors.Is(err, flow.ErrCompletedByStrategy) {
@@ -443,6 +462,20 @@ func (h *Handler) updateRecoveryFlow(w http.ResponseWriter, r *http.Request, ps
 		return
 	}
 
+	if flow.RotateCSRFToken(h.d, w, r, f) {
+		// The strategies persist the flow themselves, so the new token is set on the stored flow.
+		stored, err := h.d.RecoveryFlowPersister().GetRecoveryFlow(r.Context(), f.ID)
+		if err != nil {
+			h.d.RecoveryFlowErrorHandler().WriteFlowError(w, r, f, g, err)
+			return
+		}
+		stored.SetCSRFToken(f.CSRFToken)
+		if err := h.d.RecoveryFlowPersister().UpdateRecoveryFlow(r.Context(), stored); err != nil {
+			h.d.RecoveryFlowErrorHandler().WriteFlowError(w, r, f, g, err)
+			return
+		}
+	}
+
 	// WARNING - just because no error was returned does not mean that the challenge was accepted. Instead, the
 	// success state is available as:
 	//
@@ -297,3 +297,45 @@ func TestGetFlow(t *testing.T) {
 		assert.EqualValues(t, http.StatusNotFound, res.StatusCode)
 	})
 }
+
+func TestUpdateFlowRotatesCSRFToken(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeySelfServiceRecoveryEnabled, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(recovery.RecoveryStrategyCode),
+		map[string]interface{}{"enabled": true})
+	conf.MustSet(ctx, config.ViperKeySecurityCSRFTokenRotation, string(config.CSRFTokenRotationRequest))
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+
+	publicTS, _ := testhelpers.NewKratosServerWithCSRF(t, reg)
+	_ = testhelpers.NewRecoveryUIFlowEchoServer(t, reg)
+	_ = testhelpers.NewErrorTestServer(t, reg)
+
+	client := testhelpers.NewClientWithCookies(t)
+	f := testhelpers.InitializeRecoveryFlowViaBrowser(t, client, false, publicTS, nil)
+	csrfToken := func(body []byte) string {
+		return gjson.GetBytes(body, "ui.nodes.#(attributes.name==csrf_token).attributes.value").String()
+	}
+	raw, err := json.Marshal(f)
+	require.NoError(t, err)
+	token := csrfToken(raw)
+	require.NotEmpty(t, token, "%s", raw)
+
+	submit := func(t *testing.T, token string) []byte {
+		res, err := client.PostForm(f.Ui.Action, url.Values{"email": {"rotate-csrf@ory.sh"}, "csrf_token": {token}, "method": {"code"}})
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return body
+	}
+
+	body := submit(t, token)
+	assert.Equal(t, "sent_email", gjson.GetBytes(body, "state").String(), "%s", body)
+	rotated := csrfToken(body)
+	assert.NotEmpty(t, rotated, "%s", body)
+	assert.NotEqual(t, token, rotated, "the token is rotated after a successful submission: %s", body)
+
+	body = submit(t, token)
+	assert.EqualValues(t, x.ErrInvalidCSRFToken.ReasonField, gjson.GetBytes(body, "reason").String(), "%s", body)
+}
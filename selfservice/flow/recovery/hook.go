@@ -15,6 +15,7 @@ import (
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
@@ -55,6 +56,7 @@ func (f PostHookExecutorFunc) ExecutePostRecoveryHook(w http.ResponseWriter, r *
 
 type (
 	executorDependencies interface {
+		funnel.RecorderProvider
 		config.Provider
 		identity.ManagementProvider
 		identity.ValidationProvider
@@ -62,6 +64,7 @@ type (
 		HooksProvider
 		x.CSRFTokenGeneratorProvider
 		x.LoggingProvider
+		x.TracingProvider
 		x.WriterProvider
 	}
 
@@ -90,7 +93,9 @@ func (e *HookExecutor) PostRecoveryHook(w http.ResponseWriter, r *http.Request,
 
 	logger.Debug("Running ExecutePostRecoveryHooks.")
 	for k, executor := range e.d.PostRecoveryHooks(r.Context()) {
-		if err := executor.ExecutePostRecoveryHook(w, r, a, s); err != nil {
+		if err := flow.TraceHook(r, e.d, a, flow.HookPhasePost, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecutePostRecoveryHook(w, r, a, s)
+		}); err != nil {
 			var traits identity.Traits
 			if s.Identity != nil {
 				traits = s.Identity.Traits
@@ -106,6 +111,8 @@ func (e *HookExecutor) PostRecoveryHook(w http.ResponseWriter, r *http.Request,
 	}
 
 	trace.SpanFromContext(r.Context()).AddEvent(events.NewRecoverySucceeded(r.Context(), a.ID, s.Identity.ID, string(a.Type), a.Active.String()))
+	e.d.FlowFunnelRecorder().FlowSucceeded(r.Context(), flow.RecoveryFlow, a.ID)
+	flow.CountRecovery(a.Active.String())
 
 	logger.Debug("Post recovery execution hooks completed successfully.")
 
@@ -114,7 +121,9 @@ func (e *HookExecutor) PostRecoveryHook(w http.ResponseWriter, r *http.Request,
 
 func (e *HookExecutor) PreRecoveryHook(w http.ResponseWriter, r *http.Request, a *Flow) error {
 	for _, executor := range e.d.PreRecoveryHooks(r.Context()) {
-		if err := executor.ExecuteRecoveryPreHook(w, r, a); err != nil {
+		if err := flow.TraceHook(r, e.d, a, flow.HookPhasePre, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecuteRecoveryPreHook(w, r, a)
+		}); err != nil {
 			return err
 		}
 	}
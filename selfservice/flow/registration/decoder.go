@@ -13,8 +13,8 @@ import (
 	"github.com/ory/x/decoderx"
 )
 
-func DecodeBody(p interface{}, r *http.Request, dec *decoderx.HTTP, conf *config.Config, schema []byte) error {
-	ds, err := conf.DefaultIdentityTraitsSchemaURL(r.Context())
+func DecodeBody(p interface{}, r *http.Request, dec *decoderx.HTTP, conf *config.Config, f *Flow, schema []byte, opts ...decoderx.HTTPDecoderOption) error {
+	ds, err := f.IdentitySchemaURL(r.Context(), conf)
 	if err != nil {
 		return err
 	}
@@ -29,5 +29,5 @@ func DecodeBody(p interface{}, r *http.Request, dec *decoderx.HTTP, conf *config
 		return errors.WithStack(err)
 	}
 
-	return dec.Decode(r, p, compiler, decoderx.HTTPDecoderSetValidatePayloads(true), decoderx.HTTPDecoderJSONFollowsFormFormat())
+	return dec.Decode(r, p, append([]decoderx.HTTPDecoderOption{compiler, decoderx.HTTPDecoderSetValidatePayloads(true), decoderx.HTTPDecoderJSONFollowsFormFormat()}, opts...)...)
 }
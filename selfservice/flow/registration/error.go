@@ -17,6 +17,7 @@ import (
 	"github.com/ory/kratos/x/events"
 
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/text"
 
 	"github.com/pkg/errors"
@@ -35,10 +36,13 @@ var (
 
 type (
 	errorHandlerDependencies interface {
+		funnel.RecorderProvider
 		errorx.ManagementProvider
 		x.WriterProvider
 		x.LoggingProvider
 		config.Provider
+		x.CSRFProvider
+		x.CSRFTokenGeneratorProvider
 
 		sessiontokenexchange.PersistenceProvider
 		FlowPersistenceProvider
@@ -113,23 +117,26 @@ func (s *ErrorHandler) WriteFlowError(
 		return
 	}
 
+	s.d.FlowFunnelRecorder().FlowFailed(r.Context(), flow.RegistrationFlow, f.ID)
+
 	f.UI.ResetMessages()
 	if err := f.UI.ParseError(group, err); err != nil {
 		s.forward(w, r, f, err)
 		return
 	}
 
-	ds, err := s.d.Config().DefaultIdentityTraitsSchemaURL(r.Context())
+	ds, err := f.IdentitySchemaURL(r.Context(), s.d.Config())
 	if err != nil {
 		s.forward(w, r, f, err)
 		return
 	}
 
-	if err := SortNodes(r.Context(), f.UI.Nodes, ds.String()); err != nil {
+	if err := SortNodes(r.Context(), s.d.Config(), f.UI.Nodes, ds.String()); err != nil {
 		s.forward(w, r, f, err)
 		return
 	}
 
+	flow.RotateCSRFToken(s.d, w, r, f)
 	if err := s.d.RegistrationFlowPersister().UpdateRegistrationFlow(r.Context(), f); err != nil {
 		s.forward(w, r, f, err)
 		return
@@ -97,11 +97,23 @@ type Flow struct {
 	NID            uuid.UUID     `json:"-" faker:"-" db:"nid"`
 	OrganizationID uuid.NullUUID `json:"organization_id,omitempty"  faker:"-" db:"organization_id"`
 
+	// IdentitySchema contains the ID of the identity schema used for this flow.
+	//
+	// This value is set using the `identity_schema` query parameter when initializing the flow.
+	// If not set, the default identity schema is used.
+	IdentitySchema sqlxx.NullString `json:"identity_schema,omitempty" faker:"-" db:"identity_schema_id"`
+
 	// TransientPayload is used to pass data from the registration to a webhook
 	//
 	// required: false
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" faker:"-" db:"-"`
 
+	// Brand is the brand of the white-label product this flow belongs to.
+	//
+	// This value is set using the `brand` query parameter when initializing the flow. It is
+	// passed to the UI and used to select the courier templates.
+	Brand sqlxx.NullString `json:"brand,omitempty" faker:"-" db:"brand"`
+
 	// Contains a list of actions, that could follow this flow
 	//
 	// It can, for example, contain a reference to the verification flow, created as part of the user's
@@ -121,6 +133,10 @@ type Flow struct {
 	// required: true
 	State State `json:"state" faker:"-" db:"state"`
 
+	// Steps contains the steps of a registration flow which is split into several steps, see
+	// `selfservice.flows.registration.steps`. It is empty if the flow is not split into steps.
+	Steps *Steps `json:"steps,omitempty" faker:"-" db:"-"`
+
 	// only used internally
 	IDToken string `json:"-" faker:"-" db:"-"`
 	// Only used internally
@@ -129,22 +145,29 @@ type Flow struct {
 
 var _ flow.Flow = new(Flow)
 
-func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Request, ft flow.Type) (*Flow, error) {
-	now := time.Now().UTC()
+func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Request, ft flow.Type, opts ...x.SecureRedirectOption) (*Flow, error) {
+	now := x.Now().UTC()
 	id := x.NewUUID()
 
 	// Pre-validate the return to URL which is contained in the HTTP request.
 	requestURL := x.RequestURL(r).String()
 	_, err := x.SecureRedirectTo(r,
 		conf.SelfServiceBrowserDefaultReturnTo(r.Context()),
-		x.SecureRedirectUseSourceURL(requestURL),
-		x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
-		x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		append([]x.SecureRedirectOption{
+			x.SecureRedirectUseSourceURL(requestURL),
+			x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
+			x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		}, opts...)...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	brand, err := flow.BrandFromRequest(conf, r)
+	if err != nil {
+		return nil, err
+	}
+
 	hlc, err := hydra.GetLoginChallengeID(conf, r)
 	if err != nil {
 		return nil, err
@@ -164,6 +187,7 @@ func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Reques
 		Type:            ft,
 		InternalContext: []byte("{}"),
 		State:           flow.StateChooseMethod,
+		Brand:           brand,
 	}, nil
 }
 
@@ -180,14 +204,19 @@ func (f Flow) GetNID() uuid.UUID {
 }
 
 func (f *Flow) Valid() error {
-	if f.ExpiresAt.Before(time.Now()) {
+	if f.ExpiresAt.Before(x.Now()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 	return nil
 }
 
 func (f *Flow) AppendTo(src *url.URL) *url.URL {
-	return flow.AppendFlowTo(src, f.ID)
+	return flow.AppendBrandTo(flow.AppendFlowTo(src, f.ID), f.Brand)
+}
+
+func (f *Flow) SetCSRFToken(token string) {
+	f.CSRFToken = token
+	f.UI.SetCSRF(token)
 }
 
 func (f *Flow) GetType() flow.Type {
@@ -230,7 +259,7 @@ func (f *Flow) SetReturnTo() {
 
 func (f *Flow) AfterFind(*pop.Connection) error {
 	f.SetReturnTo()
-	return nil
+	return f.loadSteps()
 }
 
 func (f *Flow) AfterSave(*pop.Connection) error {
@@ -260,6 +289,20 @@ func (f *Flow) SecureRedirectToOpts(ctx context.Context, cfg config.Provider) (o
 	}
 }
 
+// IdentitySchemaID returns the ID of the identity schema that identities registered
+// using this flow are created with.
+func (f *Flow) IdentitySchemaID(ctx context.Context, conf *config.Config) string {
+	if f.IdentitySchema != "" {
+		return string(f.IdentitySchema)
+	}
+	return conf.DefaultIdentityTraitsSchemaID(ctx)
+}
+
+// IdentitySchemaURL returns the URL of the identity schema used for this flow.
+func (f *Flow) IdentitySchemaURL(ctx context.Context, conf *config.Config) (*url.URL, error) {
+	return conf.IdentityTraitsSchemaURL(ctx, f.IdentitySchemaID(ctx, conf))
+}
+
 func (f *Flow) GetState() State {
 	return f.State
 }
@@ -276,6 +319,10 @@ func (f *Flow) GetTransientPayload() json.RawMessage {
 	return f.TransientPayload
 }
 
+func (f *Flow) GetBrand() string {
+	return f.Brand.String()
+}
+
 func (f *Flow) SetReturnToVerification(to string) {
 	f.ReturnToVerification = to
 }
@@ -154,3 +154,29 @@ func TestFlowDontOverrideReturnTo(t *testing.T) {
 	f.SetReturnTo()
 	assert.Equal(t, "/bar", f.ReturnTo)
 }
+
+func TestFlowIdentitySchema(t *testing.T) {
+	ctx := context.Background()
+	conf, _ := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeyDefaultIdentitySchemaID, "default")
+	conf.MustSet(ctx, config.ViperKeyIdentitySchemas, config.Schemas{
+		{ID: "default", URL: "file://./stub/registration.schema.json"},
+		{ID: "customer", URL: "file://./stub/login.schema.json"},
+	})
+
+	f := new(registration.Flow)
+	assert.Equal(t, "default", f.IdentitySchemaID(ctx, conf))
+	u, err := f.IdentitySchemaURL(ctx, conf)
+	require.NoError(t, err)
+	assert.Equal(t, "file://./stub/registration.schema.json", u.String())
+
+	f.IdentitySchema = "customer"
+	assert.Equal(t, "customer", f.IdentitySchemaID(ctx, conf))
+	u, err = f.IdentitySchemaURL(ctx, conf)
+	require.NoError(t, err)
+	assert.Equal(t, "file://./stub/login.schema.json", u.String())
+
+	f.IdentitySchema = "does-not-exist"
+	_, err = f.IdentitySchemaURL(ctx, conf)
+	assert.Error(t, err)
+}
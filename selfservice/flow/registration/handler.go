@@ -20,6 +20,7 @@ import (
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/selfservice/sessiontokenexchange"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/text"
@@ -41,14 +42,20 @@ const (
 
 type (
 	handlerDependencies interface {
+		funnel.RecorderProvider
 		config.Provider
 		errorx.ManagementProvider
 		hydra.Provider
 		session.HandlerProvider
 		session.ManagementProvider
+		session.PersistenceProvider
+		identity.PrivilegedPoolProvider
 		x.WriterProvider
 		x.CSRFTokenGeneratorProvider
 		x.CSRFProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
+		x.TracingProvider
 		StrategyProvider
 		HookExecutorProvider
 		FlowPersistenceProvider
@@ -118,7 +125,7 @@ func (h *Handler) NewRegistrationFlow(w http.ResponseWriter, r *http.Request, ft
 		return nil, errors.WithStack(ErrRegistrationDisabled)
 	}
 
-	f, err := NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowRegistrationRequestLifespan(r.Context()), h.d.GenerateCSRFToken(r), r, ft)
+	f, err := NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowRegistrationRequestLifespan(r.Context()), flow.NewCSRFToken(h.d, w, r, ft), r, ft, x.SecureRedirectAllowReturnToGrants(h.d))
 	if err != nil {
 		return nil, err
 	}
@@ -146,18 +153,44 @@ func (h *Handler) NewRegistrationFlow(w http.ResponseWriter, r *http.Request, ft
 			}}
 		}
 	}
-	for _, s := range h.d.RegistrationStrategies(r.Context(), strategyFilters...) {
-		if err := s.PopulateRegistrationMethod(r, f); err != nil {
+
+	if schemaID := r.URL.Query().Get("identity_schema"); schemaID != "" {
+		f.IdentitySchema = sqlxx.NullString(schemaID)
+	}
+	selectable, err := SelectableIdentitySchemas(r.Context(), h.d.Config())
+	if err != nil {
+		return nil, err
+	}
+	is, err := identitySchema(r.Context(), h.d.Config(), f, selectable)
+	if err != nil {
+		return nil, err
+	}
+	strategyFilters = append(strategyFilters, func(s Strategy) bool {
+		return is.RegistrationMethodEnabled(s.ID().String())
+	})
+
+	strategies := h.d.RegistrationStrategies(r.Context(), strategyFilters...)
+	h.traceSkippedStrategies(r, f, strategies)
+	for _, s := range strategies {
+		if err := flow.TracePopulate(r, f, s.ID().String(), func() error {
+			return s.PopulateRegistrationMethod(r, f)
+		}); err != nil {
 			return nil, err
 		}
 	}
 
-	ds, err := h.d.Config().DefaultIdentityTraitsSchemaURL(r.Context())
+	if err := flow.TracePopulate(r, f, "identity_schema", func() error {
+		return addIdentitySchemaNodes(r.Context(), h.d.Config(), f, selectable)
+	}); err != nil {
+		return nil, err
+	}
+
+	ds, err := f.IdentitySchemaURL(r.Context(), h.d.Config())
 	if err != nil {
 		return nil, err
 	}
 
-	if err := SortNodes(r.Context(), f.UI.Nodes, ds.String()); err != nil {
+	if err := SortNodes(r.Context(), h.d.Config(), f.UI.Nodes, ds.String()); err != nil {
 		return nil, err
 	}
 
@@ -169,16 +202,38 @@ func (h *Handler) NewRegistrationFlow(w http.ResponseWriter, r *http.Request, ft
 		return nil, err
 	}
 
+	h.d.FlowFunnelRecorder().FlowCreated(r.Context(), flow.RegistrationFlow, f.ID, f.ExpiresAt)
+
 	return f, nil
 }
 
+// traceSkippedStrategies records the registration strategies which are not considered for the
+// flow in the debug trace of the request.
+func (h *Handler) traceSkippedStrategies(r *http.Request, f *Flow, considered Strategies) {
+	if x.DebugTraceFromContext(r.Context()) == nil {
+		return
+	}
+
+	enabled := h.d.RegistrationStrategies(r.Context())
+	for _, s := range h.d.AllRegistrationStrategies() {
+		if _, err := considered.Strategy(s.ID()); err == nil {
+			continue
+		} else if _, err := enabled.Strategy(s.ID()); err == nil {
+			flow.TraceStrategySkipped(r, f, s.ID().String(), "The strategy is not available for this flow, for example because of its identity schema or organization.")
+		} else {
+			flow.TraceStrategySkipped(r, f, s.ID().String(), "The strategy is disabled in the configuration.")
+		}
+	}
+}
+
 func (h *Handler) FromOldFlow(w http.ResponseWriter, r *http.Request, of Flow) (*Flow, error) {
-	nf, err := h.NewRegistrationFlow(w, r, of.Type)
+	nf, err := h.NewRegistrationFlow(w, r, of.Type, WithFlowIdentitySchema(string(of.IdentitySchema)))
 	if err != nil {
 		return nil, err
 	}
 
 	nf.RequestURL = of.RequestURL
+	nf.Brand = of.Brand
 	return nf, nil
 }
 
@@ -246,6 +301,22 @@ type createNativeRegistrationFlow struct {
 	// required: false
 	// in: query
 	Organization string `json:"organization"`
+
+	// An optional identity schema to use for the registration flow.
+	//
+	// The schema must be the default identity schema or be marked as selectable.
+	//
+	// required: false
+	// in: query
+	IdentitySchema string `json:"identity_schema"`
+
+	// An optional brand of the white-label product the registration flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
 }
 
 // Create Browser Registration Flow Parameters
@@ -289,6 +360,22 @@ type createBrowserRegistrationFlow struct {
 	// required: false
 	// in: query
 	Organization string `json:"organization"`
+
+	// An optional identity schema to use for the registration flow.
+	//
+	// The schema must be the default identity schema or be marked as selectable.
+	//
+	// required: false
+	// in: query
+	IdentitySchema string `json:"identity_schema"`
+
+	// An optional brand of the white-label product the registration flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
 }
 
 // swagger:route GET /self-service/registration/browser frontend createBrowserRegistrationFlow
@@ -410,6 +497,7 @@ func (h *Handler) createBrowserRegistrationFlow(w http.ResponseWriter, r *http.R
 		returnTo, redirErr := x.SecureRedirectTo(r, h.d.Config().SelfServiceBrowserDefaultReturnTo(ctx),
 			x.SecureRedirectAllowSelfServiceURLs(h.d.Config().SelfPublicURL(ctx)),
 			x.SecureRedirectAllowURLs(h.d.Config().SelfServiceBrowserAllowedReturnToDomains(ctx)),
+			x.SecureRedirectAllowReturnToGrants(h.d),
 		)
 		if redirErr != nil {
 			h.d.SelfServiceErrorManager().Forward(ctx, w, r, redirErr)
@@ -649,21 +737,44 @@ func (h *Handler) updateRegistrationFlow(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	if err := f.Valid(); err != nil {
-		h.d.RegistrationFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, err)
+	if group, err := h.executeFlow(w, r, f); errors.Is(err, flow.ErrCompletedByStrategy) {
+		return
+	} else if err != nil {
+		h.d.RegistrationFlowErrorHandler().WriteFlowError(w, r, f, group, err)
 		return
 	}
+}
+
+// executeFlow registers the identity with the responsible strategy and runs the post-registration
+// hooks, which respond to the request. It returns flow.ErrCompletedByStrategy if the strategy
+// responded to the request itself.
+func (h *Handler) executeFlow(w http.ResponseWriter, r *http.Request, f *Flow) (node.UiNodeGroup, error) {
+	if err := f.Valid(); err != nil {
+		return node.DefaultGroup, err
+	}
+
+	schemas, err := h.d.Config().IdentityTraitsSchemas(r.Context())
+	if err != nil {
+		return node.DefaultGroup, err
+	}
+	sc, err := schemas.FindSchemaByID(f.IdentitySchemaID(r.Context(), h.d.Config()))
+	if err != nil {
+		return node.DefaultGroup, errors.WithStack(ErrIdentitySchemaNotSelectable.WithWrap(err))
+	}
 
-	i := identity.NewIdentity(h.d.Config().DefaultIdentityTraitsSchemaID(r.Context()))
+	i := identity.NewIdentity(sc.ID)
 	var s Strategy
 	for _, ss := range h.d.AllRegistrationStrategies() {
-		if err := ss.Register(w, r, f, i); errors.Is(err, flow.ErrStrategyNotResponsible) {
+		if !sc.RegistrationMethodEnabled(ss.ID().String()) {
+			continue
+		}
+
+		if err := flow.TraceStrategy(r, h.d, f, ss.ID().String(), func(r *http.Request) error {
+			return ss.Register(w, r, f, i)
+		}); errors.Is(err, flow.ErrStrategyNotResponsible) {
 			continue
-		} else if errors.Is(err, flow.ErrCompletedByStrategy) {
-			return
 		} else if err != nil {
-			h.d.RegistrationFlowErrorHandler().WriteFlowError(w, r, f, ss.NodeGroup(), err)
-			return
+			return ss.NodeGroup(), err
 		}
 
 		s = ss
@@ -671,12 +782,11 @@ func (h *Handler) updateRegistrationFlow(w http.ResponseWriter, r *http.Request,
 	}
 
 	if s == nil {
-		h.d.RegistrationFlowErrorHandler().WriteFlowError(w, r, f, node.DefaultGroup, errors.WithStack(schema.NewNoRegistrationStrategyResponsible()))
-		return
+		return node.DefaultGroup, errors.WithStack(schema.NewNoRegistrationStrategyResponsible())
 	}
 
 	if err := h.d.RegistrationExecutor().PostRegistrationHook(w, r, s.ID(), "", "", f, i); err != nil {
-		h.d.RegistrationFlowErrorHandler().WriteFlowError(w, r, f, s.NodeGroup(), err)
-		return
+		return s.NodeGroup(), err
 	}
+	return s.NodeGroup(), nil
 }
@@ -38,6 +38,7 @@ import (
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/strategy/oidc"
 	"github.com/ory/kratos/selfservice/strategy/password"
+	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/x"
 )
 
@@ -316,6 +317,86 @@ func TestDisabledFlow(t *testing.T) {
 	})
 }
 
+func TestIdentitySchemaSelection(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeySelfServiceRegistrationEnabled, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypePassword),
+		map[string]interface{}{"enabled": true})
+	conf.MustSet(ctx, config.ViperKeySelfServiceRegistrationEnableLegacyOneStep, true)
+	conf.MustSet(ctx, config.ViperKeyDefaultIdentitySchemaID, "default")
+	conf.MustSet(ctx, config.ViperKeyIdentitySchemas, config.Schemas{
+		{ID: "default", URL: "file://./stub/login.schema.json"},
+		{ID: "customer", URL: "file://./stub/registration.schema.json", SelfService: config.SchemaSelfService{Selectable: true}},
+		{ID: "partner", URL: "file://./stub/registration.schema.json", SelfService: config.SchemaSelfService{Selectable: true, RegistrationMethods: []string{"code"}}},
+		{ID: "employee", URL: "file://./stub/registration.schema.json"},
+	})
+
+	publicTS, _ := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	initFlow := func(t *testing.T, query string, expectedStatus int) []byte {
+		res, err := publicTS.Client().Get(publicTS.URL + registration.RouteInitAPIFlow + query)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body := ioutilx.MustReadAll(res.Body)
+		require.Equal(t, expectedStatus, res.StatusCode, "%s", body)
+		return body
+	}
+
+	t.Run("case=uses the default schema", func(t *testing.T) {
+		body := initFlow(t, "", http.StatusOK)
+		assert.False(t, gjson.GetBytes(body, "identity_schema").Exists(), "%s", body)
+		assert.True(t, gjson.GetBytes(body, "ui.nodes.#(attributes.name==traits.bar)").Exists(), "%s", body)
+		assert.False(t, gjson.GetBytes(body, "ui.nodes.#(attributes.name==traits.email)").Exists(), "%s", body)
+		assert.True(t, gjson.GetBytes(body, "ui.nodes.#(group==password)").Exists(), "%s", body)
+	})
+
+	t.Run("case=contains a link for every selectable schema", func(t *testing.T) {
+		body := initFlow(t, "?foo=bar", http.StatusOK)
+
+		var ids []string
+		for _, n := range gjson.GetBytes(body, "ui.nodes.#(group==identity_schema)#").Array() {
+			ids = append(ids, n.Get("attributes.id").String())
+			href, err := url.Parse(n.Get("attributes.href").String())
+			require.NoError(t, err)
+			assert.Equal(t, registration.RouteInitAPIFlow, href.Path)
+			assert.Equal(t, "bar", href.Query().Get("foo"))
+			assert.Equal(t, n.Get("attributes.title.context.identity_schema").String(), href.Query().Get("identity_schema"))
+		}
+		assert.ElementsMatch(t, []string{"identity_schema_default", "identity_schema_customer", "identity_schema_partner"}, ids, "%s", body)
+	})
+
+	t.Run("case=uses the selected schema", func(t *testing.T) {
+		body := initFlow(t, "?identity_schema=customer", http.StatusOK)
+		assert.Equal(t, "customer", gjson.GetBytes(body, "identity_schema").String(), "%s", body)
+		assert.True(t, gjson.GetBytes(body, "ui.nodes.#(attributes.name==traits.email)").Exists(), "%s", body)
+
+		f, err := reg.RegistrationFlowPersister().GetRegistrationFlow(ctx, uuid.FromStringOrNil(gjson.GetBytes(body, "id").String()))
+		require.NoError(t, err)
+		assert.EqualValues(t, "customer", f.IdentitySchema)
+	})
+
+	t.Run("case=only enables the schema's registration methods", func(t *testing.T) {
+		body := initFlow(t, "?identity_schema=partner", http.StatusOK)
+		assert.False(t, gjson.GetBytes(body, "ui.nodes.#(group==password)").Exists(), "%s", body)
+
+		res, err := publicTS.Client().Post(gjson.GetBytes(body, "ui.action").String(), "application/json",
+			strings.NewReader(`{"method":"password","password":"dpqyfwprdtkzmmba","traits":{"email":"partner@ory.sh"}}`))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		actual := ioutilx.MustReadAll(res.Body)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, "%s", actual)
+		assert.EqualValues(t, text.ErrorValidationRegistrationNoStrategyFound, gjson.GetBytes(actual, "ui.messages.0.id").Int(), "%s", actual)
+	})
+
+	for _, schemaID := range []string{"employee", "does-not-exist"} {
+		t.Run("case=rejects schema "+schemaID, func(t *testing.T) {
+			body := initFlow(t, "?identity_schema="+schemaID, http.StatusBadRequest)
+			assert.Equal(t, registration.ErrIdentitySchemaNotSelectable.ReasonField, gjson.GetBytes(body, "error.reason").String(), "%s", body)
+		})
+	}
+}
+
 func TestGetFlow(t *testing.T) {
 	ctx := context.Background()
 	conf, reg := internal.NewFastRegistryWithMocks(t)
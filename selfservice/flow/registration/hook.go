@@ -16,6 +16,7 @@ import (
 	"github.com/ory/kratos/hydra"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/selfservice/sessiontokenexchange"
 	"github.com/ory/kratos/session"
@@ -70,10 +71,12 @@ func (f PostHookPrePersistExecutorFunc) ExecutePostRegistrationPrePersistHook(w
 
 type (
 	executorDependencies interface {
+		funnel.RecorderProvider
 		config.Provider
 		identity.ManagementProvider
 		identity.PrivilegedPoolProvider
 		identity.ValidationProvider
+		identity.DisposableEmailBlockerProvider
 		login.FlowPersistenceProvider
 		login.StrategyProvider
 		session.PersistenceProvider
@@ -83,6 +86,7 @@ type (
 		hydra.Provider
 		x.CSRFTokenGeneratorProvider
 		x.HTTPClientProvider
+		x.JWKSFetchProvider
 		x.LoggingProvider
 		x.WriterProvider
 		x.TracingProvider
@@ -112,7 +116,9 @@ func (e *HookExecutor) PostRegistrationHook(w http.ResponseWriter, r *http.Reque
 		WithField("flow_method", ct).
 		Debug("Running PostRegistrationPrePersistHooks.")
 	for k, executor := range e.d.PostRegistrationPrePersistHooks(ctx, ct) {
-		if err := executor.ExecutePostRegistrationPrePersistHook(w, r, registrationFlow, i); err != nil {
+		if err := flow.TraceHook(r, e.d, registrationFlow, flow.HookPhasePostPrePersist, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecutePostRegistrationPrePersistHook(w, r, registrationFlow, i)
+		}); err != nil {
 			if errors.Is(err, ErrHookAbortFlow) {
 				e.d.Logger().
 					WithRequest(r).
@@ -152,6 +158,9 @@ func (e *HookExecutor) PostRegistrationHook(w http.ResponseWriter, r *http.Reque
 	if err := e.d.IdentityValidator().Validate(ctx, i); err != nil {
 		return err
 	}
+	if err := e.d.DisposableEmailBlocker().Validate(ctx, i); err != nil {
+		return err
+	}
 	// We're now creating the identity because any of the hooks could trigger a "redirect" or a "session" which
 	// would imply that the identity has to exist already.
 	if err := e.d.IdentityManager().Create(ctx, i); err != nil {
@@ -193,6 +202,7 @@ func (e *HookExecutor) PostRegistrationHook(w http.ResponseWriter, r *http.Reque
 		x.SecureRedirectAllowURLs(c.SelfServiceBrowserAllowedReturnToDomains(ctx)),
 		x.SecureRedirectAllowSelfServiceURLs(c.SelfPublicURL(ctx)),
 		x.SecureRedirectOverrideDefaultReturnTo(c.SelfServiceFlowRegistrationReturnTo(ctx, ct.String())),
+		x.SecureRedirectAllowReturnToGrants(e.d),
 	)
 	if err != nil {
 		return err
@@ -214,6 +224,8 @@ func (e *HookExecutor) PostRegistrationHook(w http.ResponseWriter, r *http.Reque
 		Info("A new identity has registered using self-service registration.")
 
 	span.AddEvent(events.NewRegistrationSucceeded(ctx, registrationFlow.ID, i.ID, string(registrationFlow.Type), registrationFlow.Active.String(), provider))
+	e.d.FlowFunnelRecorder().FlowSucceeded(ctx, flow.RegistrationFlow, registrationFlow.ID)
+	flow.CountRegistration(registrationFlow.Active.String())
 
 	s := session.NewInactiveSession()
 
@@ -233,7 +245,9 @@ func (e *HookExecutor) PostRegistrationHook(w http.ResponseWriter, r *http.Reque
 		WithField("flow_method", ct).
 		Debug("Running PostRegistrationPostPersistHooks.")
 	for k, executor := range e.d.PostRegistrationPostPersistHooks(ctx, ct) {
-		if err := executor.ExecutePostRegistrationPostPersistHook(w, r, registrationFlow, s); err != nil {
+		if err := flow.TraceHook(r, e.d, registrationFlow, flow.HookPhasePostPersist, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecutePostRegistrationPostPersistHook(w, r, registrationFlow, s)
+		}); err != nil {
 			if errors.Is(err, ErrHookAbortFlow) {
 				e.d.Logger().
 					WithRequest(r).
@@ -339,7 +353,9 @@ func (e *HookExecutor) getDuplicateIdentifier(ctx context.Context, i *identity.I
 
 func (e *HookExecutor) PreRegistrationHook(w http.ResponseWriter, r *http.Request, a *Flow) error {
 	for _, executor := range e.d.PreRegistrationHooks(r.Context()) {
-		if err := executor.ExecuteRegistrationPreHook(w, r, a); err != nil {
+		if err := flow.TraceHook(r, e.d, a, flow.HookPhasePre, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecuteRegistrationPreHook(w, r, a)
+		}); err != nil {
 			return err
 		}
 	}
@@ -128,6 +128,23 @@ func TestRegistrationExecutor(t *testing.T) {
 					require.Error(t, err)
 				})
 
+				t.Run("case=fail for disposable email addresses", func(t *testing.T) {
+					t.Cleanup(testhelpers.SelfServiceHookConfigReset(t, conf))
+					conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsEnabled, true)
+					t.Cleanup(func() {
+						conf.MustSet(ctx, config.ViperKeySelfServiceDisposableEmailsEnabled, false)
+					})
+					i := testhelpers.SelfServiceHookFakeIdentity(t)
+					i.Traits = identity.Traits(`{"email":"jane@mailinator.com"}`)
+
+					res, body := makeRequestPost(t, newServer(t, i, flow.TypeBrowser), false, url.Values{})
+					assert.EqualValues(t, http.StatusInternalServerError, res.StatusCode)
+					assert.Contains(t, body, `email domain "mailinator.com" is disposable`)
+
+					_, err := reg.IdentityPool().GetIdentity(context.Background(), i.ID, identity.ExpandNothing)
+					require.Error(t, err)
+				})
+
 				t.Run("case=use return_to value", func(t *testing.T) {
 					t.Cleanup(testhelpers.SelfServiceHookConfigReset(t, conf))
 					conf.MustSet(ctx, config.ViperKeyURLsAllowedReturnToDomains, []string{"https://www.ory.sh/"})
@@ -0,0 +1,87 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package registration
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/x/sqlxx"
+	"github.com/ory/x/urlx"
+)
+
+var ErrIdentitySchemaNotSelectable = herodot.ErrBadRequest.WithError("identity schema not selectable").WithReason("The requested identity schema does not exist or can not be used for registration.")
+
+// SelectableIdentitySchemas returns the identity schemas which can be chosen when
+// initializing a registration flow. The default identity schema is always selectable.
+func SelectableIdentitySchemas(ctx context.Context, conf *config.Config) (config.Schemas, error) {
+	ss, err := conf.IdentityTraitsSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultID := conf.DefaultIdentityTraitsSchemaID(ctx)
+	selectable := make(config.Schemas, 0, len(ss))
+	for _, s := range ss {
+		if s.ID == defaultID || s.SelfService.Selectable {
+			selectable = append(selectable, s)
+		}
+	}
+
+	return selectable, nil
+}
+
+// WithFlowIdentitySchema sets the identity schema of the flow. The schema is validated
+// when the flow is created.
+func WithFlowIdentitySchema(schemaID string) FlowOption {
+	return func(f *Flow) {
+		f.IdentitySchema = sqlxx.NullString(schemaID)
+	}
+}
+
+// identitySchema validates the identity schema requested for the flow and returns it.
+func identitySchema(ctx context.Context, conf *config.Config, f *Flow, selectable config.Schemas) (*config.Schema, error) {
+	id := f.IdentitySchemaID(ctx, conf)
+	s, err := selectable.FindSchemaByID(id)
+	if err != nil {
+		return nil, errors.WithStack(ErrIdentitySchemaNotSelectable.WithDetail("identity_schema", id))
+	}
+
+	return s, nil
+}
+
+// addIdentitySchemaNodes adds a link for every selectable identity schema which re-initializes
+// the flow with that schema. No links are added if only one schema is selectable.
+func addIdentitySchemaNodes(ctx context.Context, conf *config.Config, f *Flow, selectable config.Schemas) error {
+	if len(selectable) < 2 {
+		return nil
+	}
+
+	requestURL, err := url.Parse(f.RequestURL)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	route := RouteInitBrowserFlow
+	if f.Type == flow.TypeAPI {
+		route = RouteInitAPIFlow
+	}
+
+	for _, s := range selectable {
+		query := requestURL.Query()
+		query.Set("identity_schema", s.ID)
+		href := urlx.CopyWithQuery(urlx.AppendPaths(conf.SelfPublicURL(ctx), route), query)
+
+		f.UI.Nodes.Append(node.NewAnchorField("identity_schema_"+s.ID, href.String(), node.IdentitySchemaGroup, text.NewInfoRegistrationIdentitySchema(s.ID)))
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package registration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/session"
+	"github.com/ory/x/otelx"
+)
+
+// CreateNativeFlow initializes a native registration flow in-process.
+func (h *Handler) CreateNativeFlow(ctx context.Context) (_ *Flow, err error) {
+	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.registration.CreateNativeFlow")
+	defer otelx.End(span, &err)
+
+	e, err := flow.NewNativeExecution(ctx, h.d.Config().SelfPublicURL(ctx), http.MethodGet, RouteInitAPIFlow, nil, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.NewRegistrationFlow(e, e.Request, flow.TypeAPI)
+}
+
+// UpdateNativeFlow submits the JSON payload of a registration method to the native registration
+// flow in-process. If the payload is invalid, the flow with the validation messages is returned
+// instead of the response. The response only contains a session if the session hook is
+// configured for the registration method.
+func (h *Handler) UpdateNativeFlow(ctx context.Context, flowID uuid.UUID, body json.RawMessage) (_ *APIFlowResponse, _ *Flow, err error) {
+	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.registration.UpdateNativeFlow")
+	defer otelx.End(span, &err)
+
+	e, err := flow.NewNativeExecution(ctx, h.d.Config().SelfPublicURL(ctx), http.MethodPost, RouteSubmitFlow, url.Values{"flow": {flowID.String()}}, "", body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := h.d.RegistrationFlowPersister().GetRegistrationFlow(ctx, flowID)
+	if err != nil {
+		return nil, nil, err
+	} else if f.Type != flow.TypeAPI {
+		return nil, nil, errors.WithStack(herodot.ErrBadRequest.WithReason("Only native registration flows can be executed in-process."))
+	}
+
+	group, err := h.executeFlow(e, e.Request, f)
+	if err != nil && !errors.Is(err, flow.ErrCompletedByStrategy) {
+		h.d.RegistrationFlowErrorHandler().WriteFlowError(e, e.Request, f, group, err)
+	}
+
+	if err := e.Err(); err != nil {
+		return nil, nil, err
+	} else if e.StatusCode() != http.StatusOK {
+		// The flow was updated with validation messages or the next step of the method.
+		f, err := h.d.RegistrationFlowPersister().GetRegistrationFlow(ctx, flowID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, f, nil
+	}
+
+	var res struct {
+		Token    string `json:"session_token"`
+		Identity struct {
+			ID uuid.UUID `json:"id"`
+		} `json:"identity"`
+	}
+	if err := e.Decode(&res); err != nil {
+		return nil, nil, err
+	}
+
+	// The identity and session are loaded from the store because the response only contains their
+	// JSON representation.
+	i, err := h.d.PrivilegedIdentityPool().GetIdentity(ctx, res.Identity.ID, identity.ExpandDefault)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := &APIFlowResponse{Identity: i, Token: res.Token}
+	if res.Token != "" {
+		if out.Session, err = h.d.SessionPersister().GetSessionByToken(ctx, res.Token, session.ExpandEverything, identity.ExpandDefault); err != nil {
+			return nil, nil, err
+		}
+	}
+	return out, nil, nil
+}
@@ -6,13 +6,20 @@ package registration
 import (
 	"context"
 
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/ui/node"
 )
 
-func SortNodes(ctx context.Context, n node.Nodes, schemaRef string) error {
+func SortNodes(ctx context.Context, conf *config.Config, n node.Nodes, schemaRef string) error {
+	if conf.UseCompatibilitySwitch(ctx, config.CompatibilityLegacyNodeOrdering) {
+		// Only the traits are ordered by the identity schema.
+		return n.SortBySchema(ctx, node.SortBySchema(schemaRef))
+	}
+
 	return n.SortBySchema(ctx,
 		node.SortBySchema(schemaRef),
 		node.SortByGroups([]node.UiNodeGroup{
+			node.IdentitySchemaGroup,
 			node.OpenIDConnectGroup,
 			node.DefaultGroup,
 			node.WebAuthnGroup,
@@ -0,0 +1,111 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package registration
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/ui/node"
+)
+
+// StepCredentials is the ID of the step in which the credentials are chosen. It always follows
+// the configured steps.
+const StepCredentials = "credentials"
+
+const internalContextKeySteps = "steps"
+
+type (
+	// Registration Flow Steps
+	//
+	// swagger:model registrationFlowSteps
+	Steps struct {
+		// Current is the ID of the step the flow is in.
+		//
+		// required: true
+		Current string `json:"current"`
+
+		// Steps are all steps of the flow in order. The last step is always `credentials`.
+		//
+		// required: true
+		Steps []Step `json:"steps"`
+	}
+
+	// Registration Flow Step
+	//
+	// swagger:model registrationFlowStep
+	Step struct {
+		// ID of the step.
+		//
+		// required: true
+		ID string `json:"id"`
+
+		// Title of the step, if configured.
+		Title string `json:"title,omitempty"`
+	}
+)
+
+// SetStep sets the current step of the flow. The steps are stored in the internal context
+// so that they survive persisting the flow.
+func (f *Flow) SetStep(configured []config.RegistrationStep, current string) (err error) {
+	steps := &Steps{Current: current, Steps: make([]Step, 0, len(configured)+1)}
+	for _, s := range configured {
+		steps.Steps = append(steps.Steps, Step{ID: s.ID, Title: s.Title})
+	}
+	steps.Steps = append(steps.Steps, Step{ID: StepCredentials})
+
+	f.EnsureInternalContext()
+	f.InternalContext, err = sjson.SetBytes(f.InternalContext, internalContextKeySteps, steps)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f.Steps = steps
+	return nil
+}
+
+func (f *Flow) loadSteps() error {
+	raw := gjson.GetBytes(f.InternalContext, internalContextKeySteps)
+	if !raw.IsObject() {
+		return nil
+	}
+
+	var steps Steps
+	if err := json.Unmarshal([]byte(raw.Raw), &steps); err != nil {
+		return errors.WithStack(err)
+	}
+	f.Steps = &steps
+	return nil
+}
+
+// StepOfTrait returns the index of the configured step which asks for the trait with the given
+// path. Traits which are not part of any step belong to the last step.
+func StepOfTrait(configured []config.RegistrationStep, path string) int {
+	for k, s := range configured {
+		for _, trait := range s.Traits {
+			if path == trait || strings.HasPrefix(path, trait+".") {
+				return k
+			}
+		}
+	}
+	return len(configured) - 1
+}
+
+// StepNodes returns the nodes of the given step. Trait nodes of other steps are removed, all
+// other nodes are kept.
+func StepNodes(nodes node.Nodes, configured []config.RegistrationStep, step int) node.Nodes {
+	result := make(node.Nodes, 0, len(nodes))
+	for _, n := range nodes {
+		if path, ok := strings.CutPrefix(n.ID(), "traits."); ok && StepOfTrait(configured, path) != step {
+			continue
+		}
+		result = append(result, n)
+	}
+	return result
+}
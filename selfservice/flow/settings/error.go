@@ -7,6 +7,7 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/gofrs/uuid"
 
@@ -31,6 +32,7 @@ import (
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/x"
@@ -40,11 +42,14 @@ var ErrHookAbortFlow = errors.New("aborted settings hook execution")
 
 type (
 	errorHandlerDependencies interface {
+		funnel.RecorderProvider
 		config.Provider
 		errorx.ManagementProvider
 		x.WriterProvider
 		x.LoggingProvider
 		x.TracingProvider
+		x.CSRFProvider
+		x.CSRFTokenGeneratorProvider
 
 		HandlerProvider
 		FlowPersistenceProvider
@@ -79,6 +84,9 @@ type FlowNeedsReAuth struct {
 
 	// Points to where to redirect the user to next.
 	RedirectBrowserTo string `json:"redirect_browser_to"`
+
+	// requestedAAL is set if the user has to sign in again with a second factor.
+	requestedAAL identity.AuthenticatorAssuranceLevel
 }
 
 func (e *FlowNeedsReAuth) EnhanceJSONError() interface{} {
@@ -92,6 +100,17 @@ func NewFlowNeedsReAuth() *FlowNeedsReAuth {
 	}
 }
 
+// NewFlowNeedsAuthenticationMethod is returned if the session did not complete any of the required
+// authentication methods. If requestedAAL is AAL2, the user is asked to refresh the second factor.
+func NewFlowNeedsAuthenticationMethod(methods []string, requestedAAL identity.AuthenticatorAssuranceLevel) *FlowNeedsReAuth {
+	return &FlowNeedsReAuth{
+		DefaultError: herodot.ErrForbidden.WithID(text.ErrIDAuthenticationMethodRequired).
+			WithReasonf("Updating these fields requires signing in with one of the following methods: %s. Please re-authenticate.", strings.Join(methods, ", ")).
+			WithDetail("required_authentication_methods", methods),
+		requestedAAL: requestedAAL,
+	}
+}
+
 func NewErrorHandler(d errorHandlerDependencies) *ErrorHandler {
 	return &ErrorHandler{d: d}
 }
@@ -108,6 +127,9 @@ func (s *ErrorHandler) reauthenticate(
 	params := url.Values{}
 	params.Set("refresh", "true")
 	params.Set("return_to", returnTo.String())
+	if err.requestedAAL != "" {
+		params.Set("aal", string(err.requestedAAL))
+	}
 
 	redirectTo := urlx.AppendPaths(urlx.CopyWithQuery(s.d.Config().SelfPublicURL(ctx), params), login.RouteInitBrowserFlow).String()
 	err.RedirectBrowserTo = redirectTo
@@ -205,6 +227,8 @@ func (s *ErrorHandler) WriteFlowError(
 		return
 	}
 
+	s.d.FlowFunnelRecorder().FlowFailed(ctx, flow.SettingsFlow, f.ID)
+
 	if errors.Is(err, flow.ErrStrategyAsksToReturnToUI) {
 		if shouldRespondWithJSON {
 			s.d.Writer().Write(w, r, f)
@@ -238,11 +262,12 @@ func (s *ErrorHandler) WriteFlowError(
 		return
 	}
 
-	if err := sortNodes(ctx, f.UI.Nodes, schema.RawURL); err != nil {
+	if err := sortNodes(ctx, s.d.Config(), f.UI.Nodes, schema.RawURL); err != nil {
 		s.forward(ctx, w, r, f, err)
 		return
 	}
 
+	flow.RotateCSRFToken(s.d, w, r, f)
 	if err := s.d.SettingsFlowPersister().UpdateSettingsFlow(ctx, f); err != nil {
 		s.forward(ctx, w, r, f, err)
 		return
@@ -124,6 +124,12 @@ type Flow struct {
 	//
 	// required: false
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" faker:"-" db:"-"`
+
+	// Brand is the brand of the white-label product this flow belongs to.
+	//
+	// This value is set using the `brand` query parameter when initializing the flow. It is
+	// passed to the UI and used to select the courier templates.
+	Brand sqlxx.NullString `json:"brand,omitempty" faker:"-" db:"brand"`
 }
 
 var _ flow.Flow = new(Flow)
@@ -136,22 +142,29 @@ func MustNewFlow(conf *config.Config, exp time.Duration, r *http.Request, i *ide
 	return f
 }
 
-func NewFlow(conf *config.Config, exp time.Duration, r *http.Request, i *identity.Identity, ft flow.Type) (*Flow, error) {
-	now := time.Now().UTC()
+func NewFlow(conf *config.Config, exp time.Duration, r *http.Request, i *identity.Identity, ft flow.Type, opts ...x.SecureRedirectOption) (*Flow, error) {
+	now := x.Now().UTC()
 	id := x.NewUUID()
 
 	// Pre-validate the return to URL which is contained in the HTTP request.
 	requestURL := x.RequestURL(r).String()
 	_, err := x.SecureRedirectTo(r,
 		conf.SelfServiceBrowserDefaultReturnTo(r.Context()),
-		x.SecureRedirectUseSourceURL(requestURL),
-		x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
-		x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		append([]x.SecureRedirectOption{
+			x.SecureRedirectUseSourceURL(requestURL),
+			x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
+			x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		}, opts...)...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	brand, err := flow.BrandFromRequest(conf, r)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Flow{
 		ID:         id,
 		ExpiresAt:  now.Add(exp),
@@ -166,6 +179,7 @@ func NewFlow(conf *config.Config, exp time.Duration, r *http.Request, i *identit
 			Action: flow.AppendFlowTo(urlx.AppendPaths(conf.SelfPublicURL(r.Context()), RouteSubmitFlow), id).String(),
 		},
 		InternalContext: []byte("{}"),
+		Brand:           brand,
 	}, nil
 }
 
@@ -190,11 +204,15 @@ func (f Flow) GetNID() uuid.UUID {
 }
 
 func (f *Flow) AppendTo(src *url.URL) *url.URL {
-	return flow.AppendFlowTo(src, f.ID)
+	return flow.AppendBrandTo(flow.AppendFlowTo(src, f.ID), f.Brand)
+}
+
+func (f *Flow) SetCSRFToken(token string) {
+	f.UI.SetCSRF(token)
 }
 
 func (f *Flow) Valid(s *session.Session) error {
-	if f.ExpiresAt.Before(time.Now().UTC()) {
+	if f.ExpiresAt.Before(x.Now().UTC()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 
@@ -266,6 +284,10 @@ func (t *Flow) GetTransientPayload() json.RawMessage {
 	return t.TransientPayload
 }
 
+func (t *Flow) GetBrand() string {
+	return t.Brand.String()
+}
+
 func (f *Flow) ToLoggerField() map[string]interface{} {
 	if f == nil {
 		return map[string]interface{}{}
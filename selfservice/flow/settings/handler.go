@@ -25,6 +25,7 @@ import (
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/text"
@@ -48,7 +49,10 @@ func ContinuityKey(id string) string {
 
 type (
 	handlerDependencies interface {
+		funnel.RecorderProvider
 		x.CSRFProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
 		x.WriterProvider
 		x.LoggingProvider
 		x.TracingProvider
@@ -75,6 +79,7 @@ type (
 		schema.IdentitySchemaProvider
 
 		login.HandlerProvider
+		login.StrategyProvider
 	}
 	HandlerProvider interface {
 		SettingsHandler() *Handler
@@ -128,10 +133,12 @@ func (h *Handler) NewFlow(ctx context.Context, w http.ResponseWriter, r *http.Re
 	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.settings.Handler.NewFlow")
 	defer otelx.End(span, &err)
 
-	f, err := NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowSettingsFlowLifespan(r.Context()), r, i, ft)
+	f, err := NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowSettingsFlowLifespan(r.Context()), r, i, ft, x.SecureRedirectAllowReturnToGrants(h.d))
 	if err != nil {
 		return nil, err
 	}
+	// The strategies add the anti-CSRF token to the nodes they populate, so it needs to be issued first.
+	csrfToken := flow.NewCSRFToken(h.d, w, r, ft)
 
 	if err := h.d.SettingsHookExecutor().PreSettingsHook(ctx, w, r, f); err != nil {
 		return nil, err
@@ -141,28 +148,52 @@ func (h *Handler) NewFlow(ctx context.Context, w http.ResponseWriter, r *http.Re
 		if err := h.d.ContinuityManager().Abort(ctx, w, r, ContinuityKey(strategy.SettingsStrategyID())); err != nil {
 			return nil, err
 		}
-
-		if err := strategy.PopulateSettingsMethod(ctx, r, i, f); err != nil {
-			return nil, err
-		}
 	}
 
-	ds, err := h.d.Config().DefaultIdentityTraitsSchemaURL(ctx)
-	if err != nil {
+	if err := h.PopulateFlow(ctx, r, i, f); err != nil {
 		return nil, err
 	}
-
-	if err := sortNodes(r.Context(), f.UI.Nodes, ds.String()); err != nil {
-		return nil, err
+	if ft == flow.TypeBrowser && csrfToken != "" {
+		f.SetCSRFToken(csrfToken)
 	}
 
 	if err := h.d.SettingsFlowPersister().CreateSettingsFlow(r.Context(), f); err != nil {
 		return nil, err
 	}
 
+	h.d.FlowFunnelRecorder().FlowCreated(r.Context(), flow.SettingsFlow, f.ID, f.ExpiresAt)
+
 	return f, nil
 }
 
+// PopulateFlow adds the nodes of all enabled settings strategies for the identity to the flow and
+// sorts them.
+func (h *Handler) PopulateFlow(ctx context.Context, r *http.Request, i *identity.Identity, f *Flow) error {
+	strategies := h.d.SettingsStrategies(ctx)
+	if x.DebugTraceFromContext(ctx) != nil {
+		for _, strategy := range h.d.AllSettingsStrategies() {
+			if _, err := strategies.Strategy(strategy.SettingsStrategyID()); err != nil {
+				flow.TraceStrategySkipped(r, f, strategy.SettingsStrategyID(), "The strategy is disabled in the configuration.")
+			}
+		}
+	}
+
+	for _, strategy := range strategies {
+		if err := flow.TracePopulate(r, f, strategy.SettingsStrategyID(), func() error {
+			return strategy.PopulateSettingsMethod(ctx, r, i, f)
+		}); err != nil {
+			return err
+		}
+	}
+
+	ds, err := h.d.Config().DefaultIdentityTraitsSchemaURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	return sortNodes(ctx, h.d.Config(), f.UI.Nodes, ds.String())
+}
+
 func (h *Handler) FromOldFlow(ctx context.Context, w http.ResponseWriter, r *http.Request, i *identity.Identity, of Flow) (*Flow, error) {
 	nf, err := h.NewFlow(ctx, w, r, i, of.Type)
 	if err != nil {
@@ -170,6 +201,7 @@ func (h *Handler) FromOldFlow(ctx context.Context, w http.ResponseWriter, r *htt
 	}
 
 	nf.RequestURL = of.RequestURL
+	nf.Brand = of.Brand
 	return nf, nil
 }
 
@@ -184,6 +216,14 @@ type createNativeSettingsFlow struct {
 	//
 	// in: header
 	SessionToken string `json:"X-Session-Token"`
+
+	// An optional brand of the white-label product the settings flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
 }
 
 // swagger:route GET /self-service/settings/api frontend createNativeSettingsFlow
@@ -220,25 +260,27 @@ type createNativeSettingsFlow struct {
 //		  400: errorGeneric
 //		  default: errorGeneric
 func (h *Handler) createNativeSettingsFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ctx := r.Context()
-	s, err := h.d.SessionManager().FetchFromRequestContext(ctx, r)
+	f, err := h.newNativeFlow(r.Context(), w, r)
 	if err != nil {
 		h.d.Writer().WriteError(w, r, err)
 		return
 	}
 
-	if err := h.d.SessionManager().DoesSessionSatisfy(ctx, s, h.d.Config().SelfServiceSettingsRequiredAAL(ctx)); err != nil {
-		h.d.Writer().WriteError(w, r, err)
-		return
-	}
+	h.d.Writer().Write(w, r, f)
+}
 
-	f, err := h.NewFlow(ctx, w, r, s.Identity, flow.TypeAPI)
+// newNativeFlow initializes a native settings flow for the session of the request.
+func (h *Handler) newNativeFlow(ctx context.Context, w http.ResponseWriter, r *http.Request) (*Flow, error) {
+	s, err := h.d.SessionManager().FetchFromRequestContext(ctx, r)
 	if err != nil {
-		h.d.Writer().WriteError(w, r, err)
-		return
+		return nil, err
 	}
 
-	h.d.Writer().Write(w, r, f)
+	if err := h.d.SessionManager().DoesSessionSatisfy(ctx, s, h.d.Config().SelfServiceSettingsRequiredAAL(ctx)); err != nil {
+		return nil, err
+	}
+
+	return h.NewFlow(ctx, w, r, s.Identity, flow.TypeAPI)
 }
 
 // Create Browser Settings Flow Parameters
@@ -261,6 +303,14 @@ type createBrowserSettingsFlow struct {
 	// in: header
 	// name: Cookie
 	Cookies string `json:"Cookie"`
+
+	// An optional brand of the white-label product the settings flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
 }
 
 // swagger:route GET /self-service/settings/browser frontend createBrowserSettingsFlow
@@ -600,22 +650,38 @@ func (h *Handler) updateSettingsFlow(w http.ResponseWriter, r *http.Request, ps
 		return
 	}
 
-	if err := f.Valid(ss); err != nil {
-		h.d.SettingsFlowErrorHandler().WriteFlowError(ctx, w, r, node.DefaultGroup, f, ss.Identity, err)
+	if group, err := h.executeFlow(ctx, w, r, f, ss); errors.Is(err, flow.ErrCompletedByStrategy) {
+		return
+	} else if err != nil {
+		h.d.SettingsFlowErrorHandler().WriteFlowError(ctx, w, r, group, f, ss.Identity, err)
 		return
 	}
+}
+
+// executeFlow updates the identity with the responsible strategy and runs the post-settings hooks,
+// which respond to the request. It returns flow.ErrCompletedByStrategy if the strategy responded
+// to the request itself.
+func (h *Handler) executeFlow(ctx context.Context, w http.ResponseWriter, r *http.Request, f *Flow, ss *session.Session) (node.UiNodeGroup, error) {
+	if err := h.requireAuthenticationMethods(ctx, ss); err != nil {
+		return node.DefaultGroup, err
+	}
+
+	if err := f.Valid(ss); err != nil {
+		return node.DefaultGroup, err
+	}
 
 	var s string
 	var updateContext *UpdateContext
 	for _, strat := range h.d.AllSettingsStrategies() {
-		uc, err := strat.Settings(ctx, w, r, f, ss)
+		var uc *UpdateContext
+		err := flow.TraceStrategy(r, h.d, f, strat.SettingsStrategyID(), func(r *http.Request) (err error) {
+			uc, err = strat.Settings(r.Context(), w, r, f, ss)
+			return err
+		})
 		if errors.Is(err, flow.ErrStrategyNotResponsible) {
 			continue
-		} else if errors.Is(err, flow.ErrCompletedByStrategy) {
-			return
 		} else if err != nil {
-			h.d.SettingsFlowErrorHandler().WriteFlowError(ctx, w, r, strat.NodeGroup(), f, ss.Identity, err)
-			return
+			return strat.NodeGroup(), err
 		}
 
 		s = strat.SettingsStrategyID()
@@ -624,19 +690,44 @@ func (h *Handler) updateSettingsFlow(w http.ResponseWriter, r *http.Request, ps
 	}
 
 	if updateContext == nil {
-		h.d.SettingsFlowErrorHandler().WriteFlowError(ctx, w, r, node.DefaultGroup, f, ss.Identity, errors.WithStack(schema.NewNoSettingsStrategyResponsible()))
-		return
+		return node.DefaultGroup, errors.WithStack(schema.NewNoSettingsStrategyResponsible())
 	}
 
 	i, err := updateContext.GetIdentityToUpdate()
 	if err != nil {
 		// An identity to update must always be present.
-		h.d.SettingsFlowErrorHandler().WriteFlowError(ctx, w, r, node.DefaultGroup, f, ss.Identity, err)
-		return
+		return node.DefaultGroup, err
 	}
 
 	if err := h.d.SettingsHookExecutor().PostSettingsHook(ctx, w, r, s, updateContext, i); err != nil {
-		h.d.SettingsFlowErrorHandler().WriteFlowError(ctx, w, r, node.DefaultGroup, f, ss.Identity, err)
-		return
+		return node.DefaultGroup, err
 	}
+	return node.DefaultGroup, nil
+}
+
+// requireAuthenticationMethods ensures that the session completed at least one of the authentication
+// methods required to update the settings.
+func (h *Handler) requireAuthenticationMethods(ctx context.Context, sess *session.Session) error {
+	required := h.d.Config().SelfServiceSettingsRequiredAuthenticationMethods(ctx)
+	if len(required) == 0 {
+		return nil
+	}
+
+	for _, method := range required {
+		if sess.AuthenticatedVia(identity.CredentialsType(method)) {
+			return nil
+		}
+	}
+
+	// If all required methods are second factors, the user refreshes the second factor.
+	requestedAAL := identity.AuthenticatorAssuranceLevel2
+	for _, method := range required {
+		strategy, err := h.d.AllLoginStrategies().Strategy(identity.CredentialsType(method))
+		if err != nil || strategy.CompletedAuthenticationMethod(ctx).AAL != identity.AuthenticatorAssuranceLevel2 {
+			requestedAAL = ""
+			break
+		}
+	}
+
+	return errors.WithStack(NewFlowNeedsAuthenticationMethod(required, requestedAAL))
 }
@@ -573,6 +573,52 @@ func TestHandler(t *testing.T) {
 			})
 		})
 
+		t.Run("description=can not submit without a required authentication method", func(t *testing.T) {
+			submit := func(t *testing.T, required []string) (string, *http.Response, *kratos.SettingsFlow) {
+				conf.MustSet(ctx, config.ViperKeySelfServiceSettingsRequiredAuthenticationMethods, required)
+				t.Cleanup(func() {
+					conf.MustSet(ctx, config.ViperKeySelfServiceSettingsRequiredAuthenticationMethods, nil)
+				})
+
+				_, body := initFlow(t, primaryUser, false)
+				var f kratos.SettingsFlow
+				require.NoError(t, json.Unmarshal(body, &f))
+
+				actual, res := testhelpers.SettingsMakeRequest(t, false, true, &f, primaryUser, fmt.Sprintf(`{"method":"profile", "numby": 15, "csrf_token": "%s"}`, x.FakeCSRFToken))
+				return actual, res, &f
+			}
+
+			t.Run("case=second factors", func(t *testing.T) {
+				actual, res, f := submit(t, []string{"totp", "webauthn"})
+				assert.Equal(t, http.StatusForbidden, res.StatusCode, actual)
+				assert.Equal(t, text.ErrIDAuthenticationMethodRequired, gjson.Get(actual, "error.id").String(), actual)
+				assert.Equal(t, `["totp","webauthn"]`, gjson.Get(actual, "error.details.required_authentication_methods").Raw, actual)
+
+				redirectTo, err := url.Parse(gjson.Get(actual, "redirect_browser_to").String())
+				require.NoError(t, err)
+				assert.Equal(t, login.RouteInitBrowserFlow, redirectTo.Path)
+				assert.Equal(t, "true", redirectTo.Query().Get("refresh"))
+				assert.Equal(t, "aal2", redirectTo.Query().Get("aal"))
+				assert.Equal(t, publicTS.URL+"/self-service/settings?flow="+f.GetId(), redirectTo.Query().Get("return_to"))
+			})
+
+			t.Run("case=first factor", func(t *testing.T) {
+				actual, res, _ := submit(t, []string{"code"})
+				assert.Equal(t, http.StatusForbidden, res.StatusCode, actual)
+
+				redirectTo, err := url.Parse(gjson.Get(actual, "redirect_browser_to").String())
+				require.NoError(t, err)
+				assert.Equal(t, "true", redirectTo.Query().Get("refresh"))
+				assert.False(t, redirectTo.Query().Has("aal"), actual)
+			})
+
+			t.Run("case=session completed one of the methods", func(t *testing.T) {
+				actual, res, _ := submit(t, []string{"totp", "password"})
+				assert.Equal(t, http.StatusOK, res.StatusCode, actual)
+				assert.Equal(t, "Your changes have been saved!", gjson.Get(actual, "ui.messages.0.text").String(), actual)
+			})
+		})
+
 		t.Run("description=submit - kratos session cookie issued", func(t *testing.T) {
 			t.Run("type=spa", func(t *testing.T) {
 				_, body := initFlow(t, primaryUser, false)
@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/ory/x/otelx"
 
@@ -31,6 +30,7 @@ import (
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/x"
 )
 
@@ -57,9 +57,13 @@ type (
 	}
 
 	executorDependencies interface {
+		funnel.RecorderProvider
 		identity.ManagementProvider
+		identity.PrivilegedPoolProvider
 		identity.ValidationProvider
+		identity.DisposableEmailBlockerProvider
 		session.ManagementProvider
+		session.PersistenceProvider
 		config.Provider
 
 		HandlerProvider
@@ -67,6 +71,8 @@ type (
 		FlowPersistenceProvider
 
 		x.CSRFTokenGeneratorProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
 		x.LoggingProvider
 		x.WriterProvider
 		x.TracingProvider
@@ -154,6 +160,29 @@ func (e *HookExecutor) handleSettingsError(_ context.Context, _ http.ResponseWri
 	return flowError
 }
 
+// enrollsMFA reports whether the update sets up a second factor which the stored identity does
+// not have yet.
+func (e *HookExecutor) enrollsMFA(ctx context.Context, settingsType string, i *identity.Identity) bool {
+	ct := identity.CredentialsType(settingsType)
+	switch ct {
+	case identity.CredentialsTypeTOTP, identity.CredentialsTypeWebAuthn, identity.CredentialsTypeLookup:
+	default:
+		return false
+	}
+
+	if _, ok := i.GetCredentials(ct); !ok {
+		return false
+	}
+
+	stored, err := e.d.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandCredentials)
+	if err != nil {
+		e.d.Logger().WithError(err).WithField("identity_id", i.ID).Debug("Unable to load the stored identity to detect a second factor enrollment.")
+		return false
+	}
+	_, ok := stored.GetCredentials(ct)
+	return !ok
+}
+
 func (e *HookExecutor) PostSettingsHook(ctx context.Context, w http.ResponseWriter, r *http.Request, settingsType string, ctxUpdate *UpdateContext, i *identity.Identity, opts ...PostSettingsHookOption) (err error) {
 	ctx, span := e.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.settings.HookExecutor.PostSettingsHook")
 	defer otelx.End(span, &err)
@@ -173,6 +202,7 @@ func (e *HookExecutor) PostSettingsHook(ctx context.Context, w http.ResponseWrit
 		x.SecureRedirectOverrideDefaultReturnTo(
 			e.d.Config().SelfServiceFlowSettingsReturnTo(ctx, settingsType,
 				ctxUpdate.Flow.AppendTo(e.d.Config().SelfServiceFlowSettingsUI(ctx)))),
+		x.SecureRedirectAllowReturnToGrants(e.d),
 	)
 	if err != nil {
 		return err
@@ -192,7 +222,9 @@ func (e *HookExecutor) PostSettingsHook(ctx context.Context, w http.ResponseWrit
 			"flow_method":       settingsType,
 		}
 
-		if err := executor.ExecuteSettingsPrePersistHook(w, r, ctxUpdate.Flow, i); err != nil {
+		if err := flow.TraceHook(r, e.d, ctxUpdate.Flow, flow.HookPhasePostPrePersist, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecuteSettingsPrePersistHook(w, r, ctxUpdate.Flow, i)
+		}); err != nil {
 			if errors.Is(err, ErrHookAbortFlow) {
 				e.d.Logger().WithRequest(r).WithFields(logFields).
 					Debug("A ExecuteSettingsPrePersistHook hook aborted early.")
@@ -211,16 +243,39 @@ func (e *HookExecutor) PostSettingsHook(ctx context.Context, w http.ResponseWrit
 		e.d.Logger().WithRequest(r).WithFields(logFields).Debug("ExecuteSettingsPrePersistHook completed successfully.")
 	}
 
+	// Only the profile method changes the traits. Other methods must keep working for identities
+	// which already use a disposable email address.
+	if settingsType == StrategyProfile {
+		if err := e.d.DisposableEmailBlocker().Validate(ctx, i); err != nil {
+			return err
+		}
+	}
+
 	options := []identity.ManagerOption{identity.ManagerExposeValidationErrorsForInternalTypeAssertion}
-	ttl := e.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)
-	if ctxUpdate.Session.AuthenticatedAt.Add(ttl).After(time.Now()) {
+	privilegedErr := EnsurePrivilegedSession(r, e.d, ctxUpdate, node.UiNodeGroup(settingsType))
+	if privilegedErr == nil {
 		options = append(options, identity.ManagerAllowWriteProtectedTraits)
 	}
 
+	// Setting a new password lifts the restriction an administrator placed on the identity.
+	resetsPassword := settingsType == identity.CredentialsTypePassword.String() && i.PasswordResetRequired
+	if resetsPassword {
+		i.PasswordResetRequired = false
+	}
+
+	enrollsMFA := e.enrollsMFA(ctx, settingsType, i)
+	inMFAEnrollmentCampaign := false
+	if enrollsMFA {
+		enrollment, err := session.EvaluateMFAEnrollment(ctx, e.d.Config(), i)
+		if err != nil {
+			return err
+		}
+		inMFAEnrollmentCampaign = enrollment != nil
+	}
 	if err := e.d.IdentityManager().Update(ctx, i, options...); err != nil {
 		if errors.Is(err, identity.ErrProtectedFieldModified) {
 			e.d.Logger().WithError(err).Debug("Modifying protected field requires re-authentication.")
-			return errors.WithStack(NewFlowNeedsReAuth())
+			return privilegedErr
 		}
 		if errors.Is(err, sqlcon.ErrUniqueViolation) {
 			return schema.NewDuplicateCredentialsError(err)
@@ -231,6 +286,36 @@ func (e *HookExecutor) PostSettingsHook(ctx context.Context, w http.ResponseWrit
 		WithRequest(r).
 		WithField("identity_id", i.ID).
 		Debug("An identity's settings have been updated.")
+	if enrollsMFA {
+		flow.CountMFAEnrollment(settingsType)
+		if inMFAEnrollmentCampaign {
+			session.CountMFAEnrollmentCampaignCompletion(i.SchemaID)
+		}
+	}
+
+	updateSession := false
+	if ctxUpdate.Session.PasswordResetRequired && !i.PasswordResetRequired {
+		ctxUpdate.Session.PasswordResetRequired = false
+		updateSession = true
+	}
+	// The new password expires later than the one the session was issued with.
+	if settingsType == identity.CredentialsTypePassword.String() && e.d.Config().PasswordPolicyConfig(ctx).MaxAge > 0 {
+		if err := ctxUpdate.Session.SetPasswordExpiry(ctx, e.d.Config(), i); err != nil {
+			return err
+		}
+		updateSession = true
+	}
+	if updateSession {
+		if err := e.d.SessionPersister().UpsertSession(ctx, ctxUpdate.Session); err != nil {
+			return err
+		}
+	}
+	if resetsPassword {
+		e.d.Audit().
+			WithRequest(r).
+			WithField("identity_id", i.ID).
+			Info("An identity which was required to set a new password did so.")
+	}
 
 	ctxUpdate.UpdateIdentity(i)
 	ctxUpdate.Flow.State = flow.StateSuccess
@@ -254,7 +339,9 @@ func (e *HookExecutor) PostSettingsHook(ctx context.Context, w http.ResponseWrit
 	}
 
 	for k, executor := range e.d.PostSettingsPostPersistHooks(ctx, settingsType) {
-		if err := executor.ExecuteSettingsPostPersistHook(w, r, ctxUpdate.Flow, i, ctxUpdate.Session); err != nil {
+		if err := flow.TraceHook(r, e.d, ctxUpdate.Flow, flow.HookPhasePostPersist, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecuteSettingsPostPersistHook(w, r, ctxUpdate.Flow, i, ctxUpdate.Session)
+		}); err != nil {
 			if errors.Is(err, ErrHookAbortFlow) {
 				e.d.Logger().
 					WithRequest(r).
@@ -286,6 +373,7 @@ func (e *HookExecutor) PostSettingsHook(ctx context.Context, w http.ResponseWrit
 
 	trace.SpanFromContext(ctx).AddEvent(events.NewSettingsSucceeded(
 		ctx, ctxUpdate.Flow.ID, i.ID, string(ctxUpdate.Flow.Type), settingsType))
+	e.d.FlowFunnelRecorder().FlowSucceeded(ctx, flow.SettingsFlow, ctxUpdate.Flow.ID)
 
 	if ctxUpdate.Flow.Type == flow.TypeAPI {
 		updatedFlow, err := e.d.SettingsFlowPersister().GetSettingsFlow(ctx, ctxUpdate.Flow.ID)
@@ -327,7 +415,9 @@ func (e *HookExecutor) PreSettingsHook(ctx context.Context, w http.ResponseWrite
 	defer otelx.End(span, &err)
 
 	for _, executor := range e.d.PreSettingsHooks(ctx) {
-		if err := executor.ExecuteSettingsPreHook(w, r, a); err != nil {
+		if err := flow.TraceHook(r, e.d, a, flow.HookPhasePre, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecuteSettingsPreHook(w, r, a)
+		}); err != nil {
 			return err
 		}
 	}
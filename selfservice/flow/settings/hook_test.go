@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"github.com/tidwall/gjson"
 
 	"github.com/gobuffalo/httptest"
@@ -24,6 +25,7 @@ import (
 	"github.com/ory/kratos/selfservice/flow"
 	"github.com/ory/kratos/selfservice/flow/settings"
 	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/x"
 )
 
@@ -67,6 +69,7 @@ func TestSettingsExecutor(t *testing.T) {
 						i = testhelpers.SelfServiceHookCreateFakeIdentity(t, reg)
 					}
 					sess, _ := testhelpers.NewActiveSession(r, reg, i, time.Now().UTC(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+					sess.PasswordResetRequired = i.PasswordResetRequired
 
 					a, err := settings.NewFlow(conf, time.Minute, r, sess.Identity, ft)
 					require.NoError(t, err)
@@ -180,6 +183,31 @@ func TestSettingsExecutor(t *testing.T) {
 					assert.EqualValues(t, http.StatusOK, res.StatusCode)
 					assert.NotEmpty(t, gjson.Get(body, "identity.id"))
 				})
+
+				t.Run("case=lifts the required password reset once a new password was set", func(t *testing.T) {
+					t.Cleanup(testhelpers.SelfServiceHookConfigReset(t, conf))
+
+					i := testhelpers.SelfServiceHookCreateFakeIdentity(t, reg)
+					i.PasswordResetRequired = true
+					require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "password_reset_required"))
+
+					res, _ := makeRequestPost(t, newServer(t, i, flow.TypeBrowser), false, url.Values{})
+					assert.EqualValues(t, http.StatusOK, res.StatusCode)
+
+					actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+					require.NoError(t, err)
+					sessions, _, err := reg.SessionPersister().ListSessionsByIdentity(ctx, i.ID, nil, 1, 10, uuid.Nil, session.ExpandNothing)
+					require.NoError(t, err)
+
+					if strategy == identity.CredentialsTypePassword.String() {
+						assert.False(t, actual.PasswordResetRequired)
+						require.Len(t, sessions, 1)
+						assert.False(t, sessions[0].PasswordResetRequired)
+					} else {
+						assert.True(t, actual.PasswordResetRequired)
+						assert.Empty(t, sessions)
+					}
+				})
 			})
 
 			for _, kind := range []flow.Type{flow.TypeBrowser, flow.TypeAPI} {
@@ -0,0 +1,77 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/session"
+	"github.com/ory/x/otelx"
+)
+
+// CreateNativeFlow initializes a native settings flow for the session in-process.
+func (h *Handler) CreateNativeFlow(ctx context.Context, sessionToken string) (_ *Flow, err error) {
+	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.settings.CreateNativeFlow")
+	defer otelx.End(span, &err)
+
+	e, err := flow.NewNativeExecution(ctx, h.d.Config().SelfPublicURL(ctx), http.MethodGet, RouteInitAPIFlow, nil, sessionToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.newNativeFlow(ctx, e, e.Request)
+}
+
+// UpdateNativeFlow submits the JSON payload of a settings method to the native settings flow
+// in-process. It returns the updated flow, which contains the validation messages if the payload
+// was invalid, and whether the identity was updated.
+func (h *Handler) UpdateNativeFlow(ctx context.Context, flowID uuid.UUID, sessionToken string, body json.RawMessage) (_ *Flow, updated bool, err error) {
+	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.settings.UpdateNativeFlow")
+	defer otelx.End(span, &err)
+
+	e, err := flow.NewNativeExecution(ctx, h.d.Config().SelfPublicURL(ctx), http.MethodPost, RouteSubmitFlow, url.Values{"flow": {flowID.String()}}, sessionToken, body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	f, err := h.d.SettingsFlowPersister().GetSettingsFlow(ctx, flowID)
+	if err != nil {
+		return nil, false, err
+	} else if f.Type != flow.TypeAPI {
+		return nil, false, errors.WithStack(herodot.ErrBadRequest.WithReason("Only native settings flows can be executed in-process."))
+	}
+
+	ss, err := h.d.SessionManager().FetchFromRequestContext(ctx, e.Request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := h.d.SessionManager().DoesSessionSatisfy(ctx, ss, h.d.Config().SelfServiceSettingsRequiredAAL(ctx), session.WithRequestURL(e.Request.URL.String())); err != nil {
+		return nil, false, err
+	}
+
+	group, err := h.executeFlow(ctx, e, e.Request, f, ss)
+	if err != nil && !errors.Is(err, flow.ErrCompletedByStrategy) {
+		h.d.SettingsFlowErrorHandler().WriteFlowError(ctx, e, e.Request, group, f, ss.Identity, err)
+	}
+
+	if err := e.Err(); err != nil {
+		return nil, false, err
+	}
+
+	// Both the updated flow and the flow with the validation messages are stored.
+	f, err = h.d.SettingsFlowPersister().GetSettingsFlow(ctx, flowID)
+	if err != nil {
+		return nil, false, err
+	}
+	return f, e.StatusCode() == http.StatusOK, nil
+}
@@ -4,6 +4,7 @@
 package settings
 
 import (
+	"context"
 	"net/http"
 	"runtime/debug"
 	"time"
@@ -19,6 +20,7 @@ import (
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/flow"
 	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
 )
 
@@ -83,6 +85,32 @@ func PrepareUpdate(d interface {
 	return c, nil
 }
 
+// IsPrivilegedSession reports whether the session signed in recently enough to update the settings
+// of the given node group.
+func IsPrivilegedSession(ctx context.Context, d config.Provider, sess *session.Session, group node.UiNodeGroup) bool {
+	return sess.AuthenticatedAt.Add(d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAgeForGroup(ctx, string(group))).After(time.Now())
+}
+
+// EnsurePrivilegedSession returns an error if the session signed in too long ago or does not have
+// the AAL required to update the settings of the given node group.
+func EnsurePrivilegedSession(r *http.Request, d interface {
+	config.Provider
+	session.ManagementProvider
+}, ctxUpdate *UpdateContext, group node.UiNodeGroup) error {
+	ctx := r.Context()
+	if !IsPrivilegedSession(ctx, d, ctxUpdate.Session, group) {
+		return errors.WithStack(NewFlowNeedsReAuth())
+	}
+
+	if aal := d.Config().SelfServiceSettingsRequiredAALForGroup(ctx, string(group)); aal != "" {
+		if err := d.SessionManager().DoesSessionSatisfy(ctx, ctxUpdate.Session, aal, session.WithRequestURL(x.RequestURL(r).String())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func ContinuityOptions(p interface{}, i *identity.Identity) []continuity.ManagerOption {
 	return []continuity.ManagerOption{
 		continuity.WithPayload(p),
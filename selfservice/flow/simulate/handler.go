@@ -0,0 +1,327 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package simulate
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/jsonx"
+	"github.com/ory/x/otelx"
+)
+
+const RouteSimulate = "/flows/simulate"
+
+type (
+	handlerDependencies interface {
+		config.Provider
+		x.WriterProvider
+		x.TracingProvider
+		identity.PrivilegedPoolProvider
+		login.HandlerProvider
+		login.StrategyProvider
+		settings.HandlerProvider
+		settings.StrategyProvider
+	}
+	Handler struct {
+		d handlerDependencies
+	}
+	HandlerProvider interface {
+		FlowSimulationHandler() *Handler
+	}
+
+	// identifierFirstPopulator is implemented by the identifier first strategy, which shows the
+	// credentials of an identity once the user entered their identifier.
+	identifierFirstPopulator interface {
+		PopulateIdentifierFirstCredentials(r *http.Request, f *login.Flow, identityHint *identity.Identity, identifier string) error
+	}
+)
+
+func NewHandler(d handlerDependencies) *Handler {
+	return &Handler{d: d}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	public.POST(x.AdminPrefix+RouteSimulate, x.RedirectToAdminRoute(h.d))
+}
+
+func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.POST(RouteSimulate, h.simulateFlow)
+}
+
+// Simulate Flow Request Body
+//
+// swagger:model simulateFlowBody
+type SimulateFlowBody struct {
+	// The ID of the identity to simulate the flow for.
+	//
+	// required: true
+	IdentityID uuid.UUID `json:"identity_id"`
+
+	// The flow to simulate. One of `login` or `settings`.
+	//
+	// required: true
+	Flow flow.FlowName `json:"flow"`
+
+	// The type of the flow. One of `browser` or `api`. Defaults to `browser`.
+	Type flow.Type `json:"type"`
+
+	// The identifier the user enters in the login flow. Defaults to the first identifier of the
+	// identity's credentials.
+	Identifier string `json:"identifier"`
+}
+
+// Simulate Flow Parameters
+//
+// swagger:parameters simulateFlow
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type simulateFlow struct {
+	// in: body
+	// required: true
+	Body SimulateFlowBody
+}
+
+// Simulated Flow
+//
+// swagger:model simulatedFlow
+type SimulatedFlow struct {
+	// The simulated flow.
+	//
+	// required: true
+	Flow flow.FlowName `json:"flow"`
+
+	// The type of the simulated flow.
+	//
+	// required: true
+	Type flow.Type `json:"type"`
+
+	// The ID of the identity the flow was simulated for.
+	//
+	// required: true
+	IdentityID uuid.UUID `json:"identity_id"`
+
+	// The UI the identity would see.
+	//
+	// required: true
+	UI *container.Container `json:"ui"`
+
+	// The methods of the flow and whether they are available to the identity.
+	//
+	// required: true
+	Methods []SimulatedMethod `json:"methods"`
+}
+
+// Simulated Flow Method
+//
+// swagger:model simulatedFlowMethod
+type SimulatedMethod struct {
+	// The method, for example `password`.
+	//
+	// required: true
+	Method string `json:"method"`
+
+	// Whether the method is enabled in the configuration.
+	//
+	// required: true
+	Enabled bool `json:"enabled"`
+
+	// Whether the method shows up in the UI of the identity.
+	//
+	// required: true
+	Available bool `json:"available"`
+}
+
+// swagger:route POST /admin/flows/simulate identity simulateFlow
+//
+// # Simulate a Self-Service Flow for an Identity
+//
+// Returns the UI nodes and the methods which an identity would get in a self-service flow. No
+// flow is created and no hooks are executed.
+//
+// Login flows are simulated for the credentials of the identity the way they are shown once the
+// user entered their identifier, if identifier first login is enabled, and for the first factor
+// otherwise. Settings flows are simulated as shown to a user who is signed in as the identity.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: simulatedFlow
+//	  400: errorGeneric
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) simulateFlow(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var (
+		err error
+		ctx = r.Context()
+	)
+
+	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.flow.simulate.Handler.simulateFlow")
+	defer otelx.End(span, &err)
+	r = r.WithContext(ctx)
+
+	var body SimulateFlowBody
+	if err = jsonx.NewStrictDecoder(r.Body).Decode(&body); err != nil {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithError(err.Error())))
+		return
+	}
+
+	if body.Type == "" {
+		body.Type = flow.TypeBrowser
+	} else if body.Type != flow.TypeBrowser && body.Type != flow.TypeAPI {
+		err = errors.WithStack(herodot.ErrBadRequest.WithReasonf("Flow type %q is not supported, use browser or api.", body.Type))
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	i, err := h.d.PrivilegedIdentityPool().GetIdentity(ctx, body.IdentityID, identity.ExpandDefault)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	result := &SimulatedFlow{Flow: body.Flow, Type: body.Type, IdentityID: i.ID}
+	switch body.Flow {
+	case flow.LoginFlow:
+		result.UI, err = h.simulateLogin(r, i, body.Type, body.Identifier)
+		if err == nil {
+			for _, s := range h.d.AllLoginStrategies() {
+				result.Methods = append(result.Methods, newMethod(string(s.ID()), s.NodeGroup(), result.UI,
+					slices.ContainsFunc(h.d.LoginStrategies(ctx), func(e login.Strategy) bool { return e.ID() == s.ID() })))
+			}
+		}
+	case flow.SettingsFlow:
+		result.UI, err = h.simulateSettings(ctx, r, i, body.Type)
+		if err == nil {
+			for _, s := range h.d.AllSettingsStrategies() {
+				result.Methods = append(result.Methods, newMethod(s.SettingsStrategyID(), s.NodeGroup(), result.UI,
+					slices.ContainsFunc(h.d.SettingsStrategies(ctx), func(e settings.Strategy) bool { return e.SettingsStrategyID() == s.SettingsStrategyID() })))
+			}
+		}
+	default:
+		err = errors.WithStack(herodot.ErrBadRequest.WithReasonf("Flow %q can not be simulated, use login or settings.", body.Flow))
+	}
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.d.Writer().Write(w, r, result)
+}
+
+func (h *Handler) simulateLogin(r *http.Request, i *identity.Identity, ft flow.Type, identifier string) (*container.Container, error) {
+	ctx := r.Context()
+	conf := h.d.Config()
+
+	f, err := login.NewFlow(conf, conf.SelfServiceFlowLoginRequestLifespan(ctx), "", r, ft)
+	if err != nil {
+		return nil, err
+	}
+	f.RequestedAAL = identity.AuthenticatorAssuranceLevel1
+
+	if err := h.d.LoginHandler().PopulateFlow(r, f); err != nil {
+		return nil, err
+	}
+
+	if !conf.SelfServiceLoginFlowIdentifierFirstEnabled(ctx) {
+		return f.UI, nil
+	}
+
+	for _, s := range h.d.LoginStrategies(ctx) {
+		populator, ok := s.(identifierFirstPopulator)
+		if !ok {
+			continue
+		}
+
+		// The account enumeration mitigation hides the credentials of the identity.
+		if !conf.SecurityAccountEnumerationMitigate(ctx) {
+			if err := h.d.PrivilegedIdentityPool().HydrateIdentityAssociations(ctx, i, identity.ExpandCredentials); err != nil {
+				return nil, err
+			}
+		}
+
+		if identifier == "" {
+			identifier = firstIdentifier(i)
+		}
+
+		if err := populator.PopulateIdentifierFirstCredentials(r, f, i, identifier); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	return f.UI, nil
+}
+
+func (h *Handler) simulateSettings(ctx context.Context, r *http.Request, i *identity.Identity, ft flow.Type) (*container.Container, error) {
+	if err := h.d.PrivilegedIdentityPool().HydrateIdentityAssociations(ctx, i, identity.ExpandEverything); err != nil {
+		return nil, err
+	}
+
+	f, err := settings.NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowSettingsFlowLifespan(ctx), r, i, ft)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.d.SettingsHandler().PopulateFlow(ctx, r, i, f); err != nil {
+		return nil, err
+	}
+
+	return f.UI, nil
+}
+
+// firstIdentifier returns the first identifier of the identity's credentials, preferring the
+// password credentials.
+func firstIdentifier(i *identity.Identity) string {
+	if c, ok := i.GetCredentials(identity.CredentialsTypePassword); ok && len(c.Identifiers) > 0 {
+		return c.Identifiers[0]
+	}
+
+	types := make([]string, 0, len(i.Credentials))
+	for t := range i.Credentials {
+		types = append(types, string(t))
+	}
+	slices.Sort(types)
+
+	for _, t := range types {
+		if c := i.Credentials[identity.CredentialsType(t)]; len(c.Identifiers) > 0 {
+			return c.Identifiers[0]
+		}
+	}
+	return ""
+}
+
+func newMethod(method string, group node.UiNodeGroup, ui *container.Container, enabled bool) SimulatedMethod {
+	return SimulatedMethod{
+		Method:  method,
+		Enabled: enabled,
+		Available: enabled && slices.ContainsFunc(ui.Nodes, func(n *node.Node) bool {
+			return n.Group == group
+		}),
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package simulate_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/sqlxx"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	testhelpers.StrategyEnable(t, conf, identity.CredentialsTypePassword.String(), true)
+	testhelpers.StrategyEnable(t, conf, settings.StrategyProfile, true)
+	testhelpers.StrategyEnable(t, conf, identity.CredentialsTypeTOTP.String(), false)
+
+	_, adminTS := testhelpers.NewKratosServer(t, reg)
+
+	i := &identity.Identity{
+		ID:       x.NewUUID(),
+		Traits:   identity.Traits(`{"email":"simulate@ory.sh"}`),
+		SchemaID: config.DefaultIdentityTraitsSchemaID,
+		State:    identity.StateActive,
+		Credentials: map[identity.CredentialsType]identity.Credentials{
+			identity.CredentialsTypePassword: {Type: identity.CredentialsTypePassword, Identifiers: []string{"simulate@ory.sh"}, Config: sqlxx.JSONRawMessage(`{"hashed_password":"foo"}`)},
+		},
+	}
+	require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+	simulate := func(t *testing.T, body string, expectCode int) gjson.Result {
+		t.Helper()
+		res, err := adminTS.Client().Post(adminTS.URL+"/admin/flows/simulate", "application/json", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		actual, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, expectCode, res.StatusCode, "%s", actual)
+		return gjson.ParseBytes(actual)
+	}
+
+	method := func(t *testing.T, actual gjson.Result, name string) gjson.Result {
+		t.Helper()
+		m := actual.Get(`methods.#(method=="` + name + `")`)
+		require.True(t, m.Exists(), "%s", actual.Raw)
+		return m
+	}
+
+	t.Run("case=simulates the login flow", func(t *testing.T) {
+		actual := simulate(t, `{"identity_id":"`+i.ID.String()+`","flow":"login"}`, http.StatusOK)
+
+		assert.Equal(t, "login", actual.Get("flow").String())
+		assert.Equal(t, "browser", actual.Get("type").String())
+		assert.Equal(t, i.ID.String(), actual.Get("identity_id").String())
+		assert.True(t, actual.Get(`ui.nodes.#(attributes.name=="password")`).Exists(), "%s", actual.Raw)
+		assert.True(t, method(t, actual, "password").Get("available").Bool())
+		assert.False(t, method(t, actual, "totp").Get("enabled").Bool())
+		assert.False(t, method(t, actual, "totp").Get("available").Bool())
+	})
+
+	t.Run("case=simulates the credentials step of identifier first login", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceLoginFlowStyle, "identifier_first")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceLoginFlowStyle, "unified") })
+
+		actual := simulate(t, `{"identity_id":"`+i.ID.String()+`","flow":"login","type":"api"}`, http.StatusOK)
+
+		assert.Equal(t, "api", actual.Get("type").String())
+		identifier := actual.Get(`ui.nodes.#(attributes.name=="identifier").attributes`)
+		assert.Equal(t, "simulate@ory.sh", identifier.Get("value").String(), "%s", actual.Raw)
+		assert.Equal(t, "hidden", identifier.Get("type").String(), "%s", actual.Raw)
+		assert.True(t, actual.Get(`ui.nodes.#(attributes.name=="password")`).Exists(), "%s", actual.Raw)
+		assert.True(t, method(t, actual, "password").Get("available").Bool())
+	})
+
+	t.Run("case=simulates the settings flow", func(t *testing.T) {
+		actual := simulate(t, `{"identity_id":"`+i.ID.String()+`","flow":"settings"}`, http.StatusOK)
+
+		assert.Equal(t, "simulate@ory.sh", actual.Get(`ui.nodes.#(attributes.name=="traits.email").attributes.value`).String(), "%s", actual.Raw)
+		assert.True(t, method(t, actual, "profile").Get("available").Bool())
+		assert.True(t, method(t, actual, "password").Get("available").Bool())
+		assert.False(t, method(t, actual, "totp").Get("available").Bool())
+	})
+
+	t.Run("case=does not create flows", func(t *testing.T) {
+		count := func(table string) int {
+			var n int
+			require.NoError(t, reg.Persister().GetConnection(ctx).RawQuery("SELECT COUNT(*) FROM "+table).First(&n))
+			return n
+		}
+		login, settings := count("selfservice_login_flows"), count("selfservice_settings_flows")
+
+		simulate(t, `{"identity_id":"`+i.ID.String()+`","flow":"login"}`, http.StatusOK)
+		simulate(t, `{"identity_id":"`+i.ID.String()+`","flow":"settings"}`, http.StatusOK)
+
+		assert.Equal(t, login, count("selfservice_login_flows"))
+		assert.Equal(t, settings, count("selfservice_settings_flows"))
+	})
+
+	t.Run("case=rejects invalid requests", func(t *testing.T) {
+		simulate(t, `{"identity_id":"`+x.NewUUID().String()+`","flow":"login"}`, http.StatusNotFound)
+		simulate(t, `{"identity_id":"`+i.ID.String()+`","flow":"registration"}`, http.StatusBadRequest)
+		simulate(t, `{"identity_id":"`+i.ID.String()+`","flow":"login","type":"native"}`, http.StatusBadRequest)
+		simulate(t, `{"identity_id":"`+i.ID.String()+`","flow":"login","unknown":true}`, http.StatusBadRequest)
+	})
+}
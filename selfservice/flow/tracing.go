@@ -0,0 +1,188 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package flow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+)
+
+const (
+	HookPhasePre            = "pre"
+	HookPhasePost           = "post"
+	HookPhasePostPrePersist = "post_pre_persist"
+	HookPhasePostPersist    = "post_persist"
+
+	StrategyPhasePopulate = "populate"
+	StrategyPhaseSubmit   = "submit"
+
+	resultSuccess        = "success"
+	resultError          = "error"
+	resultCompleted      = "completed"
+	resultAborted        = "aborted"
+	resultNotResponsible = "not_responsible"
+	resultSkipped        = "skipped"
+)
+
+var (
+	strategyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kratos_selfservice_flow_strategy_duration_seconds",
+		Help:    "Duration of self-service flow submissions handled by a strategy, labelled with the flow, the strategy, and the result. Samples link to their trace using exemplars.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"flow", "strategy", "result"})
+
+	hookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kratos_selfservice_flow_hook_duration_seconds",
+		Help:    "Duration of self-service flow hooks, labelled with the flow, the phase, the hook, and the result. Samples link to their trace using exemplars.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"flow", "phase", "hook", "result"})
+)
+
+// TracingCollectors returns the Prometheus collectors which measure the duration of strategies and hooks.
+// They are registered by the registry's metrics setup.
+func TracingCollectors() []prometheus.Collector {
+	return []prometheus.Collector{strategyDuration, hookDuration}
+}
+
+// runTraced runs fn with the request bound to ctx. Decoders replace the body of the request they
+// read with a buffered copy, so the body is handed back for strategies and hooks running after fn.
+func runTraced(ctx context.Context, r *http.Request, fn func(r *http.Request) error) error {
+	traced := r.WithContext(ctx)
+	defer func() { r.Body = traced.Body }()
+	return fn(traced)
+}
+
+func flowAttributes(f Flow) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Stringer("flow.id", f.GetID()),
+		attribute.String("flow.name", string(f.GetFlowName())),
+		attribute.String("flow.type", string(f.GetType())),
+	}
+}
+
+// TraceStrategy runs a strategy of the flow in its own span and records its duration. The
+// strategy receives the request with the context of the span. Strategies which are not
+// responsible for the request are traced, but not measured.
+func TraceStrategy(r *http.Request, d x.TracingProvider, f Flow, strategy string, run func(r *http.Request) error) error {
+	ctx, span := d.Tracer(r.Context()).Tracer().Start(r.Context(), fmt.Sprintf("selfservice.flow.%s.Strategy", f.GetFlowName()))
+	span.SetAttributes(append(flowAttributes(f), attribute.String("flow.strategy", strategy))...)
+
+	start := time.Now()
+	err := runTraced(ctx, r, run)
+
+	result, spanErr := resultSuccess, err
+	switch {
+	case errors.Is(err, ErrStrategyNotResponsible):
+		result, spanErr = resultNotResponsible, nil
+	case errors.Is(err, ErrCompletedByStrategy):
+		result, spanErr = resultCompleted, nil
+	case err != nil:
+		result = resultError
+	}
+	span.SetAttributes(attribute.String("flow.strategy.result", result))
+	recordDebugTrace(r.Context(), f, x.DebugTraceEvent{Kind: "strategy", Phase: StrategyPhaseSubmit, Name: strategy, Result: result}, spanErr)
+
+	if result != resultNotResponsible {
+		x.ObserveWithExemplar(ctx, strategyDuration.WithLabelValues(string(f.GetFlowName()), strategy, result), time.Since(start).Seconds())
+	}
+	otelx.End(span, &spanErr)
+	return err
+}
+
+// TraceHook runs a hook of the flow in its own span and records its duration. The hook receives
+// the request with the context of the span. The abort error is the flow's error for hooks
+// which stop the execution of further hooks on purpose.
+func TraceHook(r *http.Request, d x.TracingProvider, f Flow, phase string, hook any, abort error, run func(r *http.Request) error) error {
+	name := fmt.Sprintf("%T", hook)
+	ctx, span := d.Tracer(r.Context()).Tracer().Start(r.Context(), fmt.Sprintf("selfservice.flow.%s.Hook", f.GetFlowName()))
+	span.SetAttributes(append(flowAttributes(f), attribute.String("hook.phase", phase), attribute.String("hook.name", name))...)
+
+	start := time.Now()
+	err := runTraced(ctx, r, run)
+
+	result, spanErr := resultSuccess, err
+	switch {
+	case abort != nil && errors.Is(err, abort):
+		result, spanErr = resultAborted, nil
+	case err != nil:
+		result = resultError
+	}
+	span.SetAttributes(attribute.String("hook.result", result))
+	recordDebugTrace(r.Context(), f, x.DebugTraceEvent{Kind: "hook", Phase: phase, Name: name, Result: result}, spanErr)
+
+	x.ObserveWithExemplar(ctx, hookDuration.WithLabelValues(string(f.GetFlowName()), phase, name, result), time.Since(start).Seconds())
+	otelx.End(span, &spanErr)
+	return err
+}
+
+// TracePopulate runs a strategy which adds its nodes to the flow's UI. If the request is debug
+// traced, the trace records which nodes the strategy added or removed.
+func TracePopulate(r *http.Request, f Flow, strategy string, populate func() error) error {
+	trace := x.DebugTraceFromContext(r.Context())
+	if trace == nil {
+		return populate()
+	}
+
+	before := nodeNames(f)
+	err := populate()
+	after := nodeNames(f)
+
+	e := x.DebugTraceEvent{Kind: "strategy", Phase: StrategyPhasePopulate, Name: strategy, Result: resultSuccess}
+	for name := range after {
+		if !before[name] {
+			e.NodesAdded = append(e.NodesAdded, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			e.NodesRemoved = append(e.NodesRemoved, name)
+		}
+	}
+	slices.Sort(e.NodesAdded)
+	slices.Sort(e.NodesRemoved)
+	if err != nil {
+		e.Result = resultError
+	}
+	recordDebugTrace(r.Context(), f, e, err)
+	return err
+}
+
+// TraceStrategySkipped records in the debug trace of the request that a strategy was not
+// considered for the flow, and why.
+func TraceStrategySkipped(r *http.Request, f Flow, strategy, reason string) {
+	recordDebugTrace(r.Context(), f, x.DebugTraceEvent{Kind: "strategy", Phase: StrategyPhasePopulate, Name: strategy, Result: resultSkipped, Reason: reason}, nil)
+}
+
+func recordDebugTrace(ctx context.Context, f Flow, e x.DebugTraceEvent, err error) {
+	trace := x.DebugTraceFromContext(ctx)
+	if trace == nil {
+		return
+	}
+
+	e.Flow = string(f.GetFlowName())
+	if err != nil {
+		e.Error = x.RedactError(err)
+	}
+	trace.Record(e)
+}
+
+func nodeNames(f Flow) map[string]bool {
+	names := map[string]bool{}
+	if ui := f.GetUI(); ui != nil {
+		for _, n := range ui.Nodes {
+			names[string(n.Group)+"/"+n.ID()] = true
+		}
+	}
+	return names
+}
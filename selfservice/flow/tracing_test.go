@@ -0,0 +1,190 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package flow_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+)
+
+type tracingProvider struct {
+	tracer *otelx.Tracer
+}
+
+func (p tracingProvider) Tracer(context.Context) *otelx.Tracer {
+	return p.tracer
+}
+
+type testHook struct{}
+
+func newTracingProvider() (x.TracingProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	return tracingProvider{tracer: otelx.NewNoop(nil, &otelx.Config{}).WithOTLP(tracer)}, recorder
+}
+
+func assertAttributes(t *testing.T, expected map[attribute.Key]string, s sdktrace.ReadOnlySpan) {
+	actual := map[attribute.Key]string{}
+	for _, a := range s.Attributes() {
+		if _, ok := expected[a.Key]; ok {
+			actual[a.Key] = a.Value.Emit()
+		}
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestTraceStrategy(t *testing.T) {
+	f := &login.Flow{ID: x.NewUUID(), Type: flow.TypeBrowser}
+
+	for _, tc := range []struct {
+		err    error
+		result string
+		status codes.Code
+	}{
+		{result: "success", status: codes.Unset},
+		{err: flow.ErrStrategyNotResponsible, result: "not_responsible", status: codes.Unset},
+		{err: errors.WithStack(flow.ErrCompletedByStrategy), result: "completed", status: codes.Unset},
+		{err: errors.New("failed"), result: "error", status: codes.Error},
+	} {
+		t.Run("result="+tc.result, func(t *testing.T) {
+			d, recorder := newTracingProvider()
+
+			var traced bool
+			err := flow.TraceStrategy(httptest.NewRequest("POST", "/", nil), d, f, "password", func(r *http.Request) error {
+				traced = trace.SpanContextFromContext(r.Context()).IsValid()
+				return tc.err
+			})
+			require.ErrorIs(t, err, tc.err)
+			assert.True(t, traced, "the strategy receives the span's context")
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "selfservice.flow.login.Strategy", spans[0].Name())
+			assert.Equal(t, tc.status, spans[0].Status().Code)
+			assertAttributes(t, map[attribute.Key]string{
+				"flow.id":              f.ID.String(),
+				"flow.name":            "login",
+				"flow.type":            "browser",
+				"flow.strategy":        "password",
+				"flow.strategy.result": tc.result,
+			}, spans[0])
+		})
+	}
+
+	t.Run("case=hands the replaced body back to the request", func(t *testing.T) {
+		d, _ := newTracingProvider()
+		r := httptest.NewRequest("POST", "/", strings.NewReader("original"))
+
+		require.NoError(t, flow.TraceStrategy(r, d, f, "password", func(r *http.Request) error {
+			r.Body = io.NopCloser(strings.NewReader("buffered"))
+			return nil
+		}))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "buffered", string(body))
+	})
+}
+
+func TestTraceHook(t *testing.T) {
+	f := &login.Flow{ID: x.NewUUID(), Type: flow.TypeAPI}
+
+	for _, tc := range []struct {
+		err    error
+		result string
+		status codes.Code
+	}{
+		{result: "success", status: codes.Unset},
+		{err: errors.WithStack(login.ErrHookAbortFlow), result: "aborted", status: codes.Unset},
+		{err: errors.New("failed"), result: "error", status: codes.Error},
+	} {
+		t.Run("result="+tc.result, func(t *testing.T) {
+			d, recorder := newTracingProvider()
+
+			err := flow.TraceHook(httptest.NewRequest("POST", "/", nil), d, f, flow.HookPhasePost, new(testHook), login.ErrHookAbortFlow, func(r *http.Request) error {
+				return tc.err
+			})
+			require.ErrorIs(t, err, tc.err)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "selfservice.flow.login.Hook", spans[0].Name())
+			assert.Equal(t, tc.status, spans[0].Status().Code)
+			assertAttributes(t, map[attribute.Key]string{
+				"flow.id":     f.ID.String(),
+				"flow.name":   "login",
+				"flow.type":   "api",
+				"hook.phase":  "post",
+				"hook.name":   "*flow_test.testHook",
+				"hook.result": tc.result,
+			}, spans[0])
+		})
+	}
+}
+
+func TestDebugTrace(t *testing.T) {
+	d, _ := newTracingProvider()
+	newFlow := func() *login.Flow {
+		return &login.Flow{ID: x.NewUUID(), Type: flow.TypeBrowser, UI: &container.Container{
+			Nodes: node.Nodes{node.NewInputField("identifier", "", node.DefaultGroup, node.InputAttributeTypeText)},
+		}}
+	}
+
+	t.Run("case=does nothing if the request is not traced", func(t *testing.T) {
+		f := newFlow()
+		require.NoError(t, flow.TracePopulate(httptest.NewRequest("GET", "/", nil), f, "password", func() error {
+			f.UI.Nodes.Append(node.NewInputField("password", nil, node.PasswordGroup, node.InputAttributeTypePassword))
+			return nil
+		}))
+		assert.Len(t, f.UI.Nodes, 2)
+	})
+
+	t.Run("case=records strategies and hooks", func(t *testing.T) {
+		ctx, trace := x.ContextWithDebugTrace(context.Background())
+		r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		f := newFlow()
+
+		flow.TraceStrategySkipped(r, f, "totp", "The strategy is disabled in the configuration.")
+		require.NoError(t, flow.TracePopulate(r, f, "password", func() error {
+			f.UI.Nodes.Remove("identifier")
+			f.UI.Nodes.Append(node.NewInputField("identifier", "", node.PasswordGroup, node.InputAttributeTypeText))
+			f.UI.Nodes.Append(node.NewInputField("password", nil, node.PasswordGroup, node.InputAttributeTypePassword))
+			return nil
+		}))
+		require.ErrorIs(t, flow.TraceStrategy(r, d, f, "code", func(r *http.Request) error {
+			return flow.ErrStrategyNotResponsible
+		}), flow.ErrStrategyNotResponsible)
+		require.Error(t, flow.TraceHook(r, d, f, flow.HookPhasePost, new(testHook), login.ErrHookAbortFlow, func(r *http.Request) error {
+			return errors.WithStack(herodot.ErrForbidden.WithID("hook_failed").WithReason("jane@example.com is not allowed"))
+		}))
+
+		assert.Equal(t, []x.DebugTraceEvent{
+			{Kind: "strategy", Flow: "login", Phase: "populate", Name: "totp", Result: "skipped", Reason: "The strategy is disabled in the configuration."},
+			{Kind: "strategy", Flow: "login", Phase: "populate", Name: "password", Result: "success", NodesAdded: []string{"password/identifier", "password/password"}, NodesRemoved: []string{"default/identifier"}},
+			{Kind: "strategy", Flow: "login", Phase: "submit", Name: "code", Result: "not_responsible"},
+			{Kind: "hook", Flow: "login", Phase: "post", Name: "*flow_test.testHook", Result: "error", Error: "hook_failed"},
+		}, trace.Events())
+	})
+}
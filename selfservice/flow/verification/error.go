@@ -24,6 +24,7 @@ import (
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/x"
 )
@@ -32,6 +33,7 @@ var ErrHookAbortFlow = errors.New("aborted verification hook execution")
 
 type (
 	errorHandlerDependencies interface {
+		funnel.RecorderProvider
 		errorx.ManagementProvider
 		x.WriterProvider
 		x.LoggingProvider
@@ -114,11 +116,14 @@ func (s *ErrorHandler) WriteFlowError(
 		return
 	}
 
+	s.d.FlowFunnelRecorder().FlowFailed(r.Context(), flow.VerificationFlow, f.ID)
+
 	if err := f.UI.ParseError(group, err); err != nil {
 		s.forward(w, r, f, err)
 		return
 	}
 
+	flow.RotateCSRFToken(s.d, w, r, f)
 	f.Active = sqlxx.NullString(group)
 	if err := s.d.VerificationFlowPersister().UpdateVerificationFlow(r.Context(), f); err != nil {
 		s.forward(w, r, f, err)
@@ -93,10 +93,19 @@ type Flow struct {
 	UpdatedAt time.Time `json:"-" faker:"-" db:"updated_at"`
 	NID       uuid.UUID `json:"-"  faker:"-" db:"nid"`
 
+	// Contains possible actions that could follow this flow
+	ContinueWith []flow.ContinueWith `json:"continue_with,omitempty" faker:"-" db:"-"`
+
 	// TransientPayload is used to pass data from the verification flow to hooks and email templates
 	//
 	// required: false
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" faker:"-" db:"-"`
+
+	// Brand is the brand of the white-label product this flow belongs to.
+	//
+	// This value is set using the `brand` query parameter when initializing the flow. It is
+	// passed to the UI and used to select the courier templates.
+	Brand sqlxx.NullString `json:"brand,omitempty" faker:"-" db:"brand"`
 }
 
 type OAuth2LoginChallengeParams struct {
@@ -125,22 +134,29 @@ func (f Flow) TableName(context.Context) string {
 	return "selfservice_verification_flows"
 }
 
-func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Request, strategy Strategy, ft flow.Type) (*Flow, error) {
-	now := time.Now().UTC()
+func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Request, strategy Strategy, ft flow.Type, opts ...x.SecureRedirectOption) (*Flow, error) {
+	now := x.Now().UTC()
 	id := x.NewUUID()
 
 	// Pre-validate the return to URL which is contained in the HTTP request.
 	requestURL := x.RequestURL(r).String()
 	_, err := x.SecureRedirectTo(r,
 		conf.SelfServiceBrowserDefaultReturnTo(r.Context()),
-		x.SecureRedirectUseSourceURL(requestURL),
-		x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
-		x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		append([]x.SecureRedirectOption{
+			x.SecureRedirectUseSourceURL(requestURL),
+			x.SecureRedirectAllowURLs(conf.SelfServiceBrowserAllowedReturnToDomains(r.Context())),
+			x.SecureRedirectAllowSelfServiceURLs(conf.SelfPublicURL(r.Context())),
+		}, opts...)...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	brand, err := flow.BrandFromRequest(conf, r)
+	if err != nil {
+		return nil, err
+	}
+
 	f := &Flow{
 		ID:         id,
 		ExpiresAt:  now.Add(exp),
@@ -153,6 +169,7 @@ func NewFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Reques
 		CSRFToken: csrf,
 		State:     flow.StateChooseMethod,
 		Type:      ft,
+		Brand:     brand,
 	}
 
 	if strategy != nil {
@@ -177,6 +194,7 @@ func FromOldFlow(conf *config.Config, exp time.Duration, csrf string, r *http.Re
 	}
 
 	nf.RequestURL = of.RequestURL
+	nf.Brand = of.Brand
 	return nf, nil
 }
 
@@ -186,6 +204,7 @@ func NewPostHookFlow(conf *config.Config, exp time.Duration, csrf string, r *htt
 		return nil, err
 	}
 	f.TransientPayload = original.GetTransientPayload()
+	f.Brand = sqlxx.NullString(original.GetBrand())
 	requestURL, err := url.ParseRequestURI(original.GetRequestURL())
 	if err != nil {
 		requestURL = new(url.URL)
@@ -203,7 +222,7 @@ func NewPostHookFlow(conf *config.Config, exp time.Duration, csrf string, r *htt
 }
 
 func (f *Flow) Valid() error {
-	if f.ExpiresAt.Before(time.Now()) {
+	if f.ExpiresAt.Before(x.Now()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 	return nil
@@ -212,7 +231,7 @@ func (f *Flow) Valid() error {
 func (f *Flow) AppendTo(src *url.URL) *url.URL {
 	values := src.Query()
 	values.Set("flow", f.ID.String())
-	return urlx.CopyWithQuery(src, values)
+	return flow.AppendBrandTo(urlx.CopyWithQuery(src, values), f.Brand)
 }
 
 func (f Flow) GetID() uuid.UUID {
@@ -299,6 +318,10 @@ func (t *Flow) GetTransientPayload() json.RawMessage {
 	return t.TransientPayload
 }
 
+func (t *Flow) GetBrand() string {
+	return t.Brand.String()
+}
+
 func (f *Flow) ToLoggerField() map[string]interface{} {
 	if f == nil {
 		return map[string]interface{}{}
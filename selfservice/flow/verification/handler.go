@@ -26,6 +26,7 @@ import (
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/errorx"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/x"
 )
 
@@ -42,6 +43,7 @@ type (
 		VerificationHandler() *Handler
 	}
 	handlerDependencies interface {
+		funnel.RecorderProvider
 		errorx.ManagementProvider
 		identity.ManagementProvider
 		identity.PrivilegedPoolProvider
@@ -53,6 +55,9 @@ type (
 		x.CSRFTokenGeneratorProvider
 		x.WriterProvider
 		x.CSRFProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
+		x.TracingProvider
 		x.LoggingProvider
 
 		FlowPersistenceProvider
@@ -104,7 +109,7 @@ func (h *Handler) NewVerificationFlow(w http.ResponseWriter, r *http.Request, ft
 		return nil, err
 	}
 
-	f, err := NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowVerificationRequestLifespan(r.Context()), h.d.GenerateCSRFToken(r), r, strategy, ft)
+	f, err := NewFlow(h.d.Config(), h.d.Config().SelfServiceFlowVerificationRequestLifespan(r.Context()), flow.NewCSRFToken(h.d, w, r, ft), r, strategy, ft, x.SecureRedirectAllowReturnToGrants(h.d))
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +125,8 @@ func (h *Handler) NewVerificationFlow(w http.ResponseWriter, r *http.Request, ft
 		return nil, err
 	}
 
+	h.d.FlowFunnelRecorder().FlowCreated(r.Context(), flow.VerificationFlow, f.ID, f.ExpiresAt)
+
 	return f, nil
 }
 
@@ -135,6 +142,14 @@ type createNativeVerificationFlow struct {
 	//
 	// in: query
 	ReturnTo string `json:"return_to"`
+
+	// An optional brand of the white-label product the verification flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
 }
 
 // swagger:route GET /self-service/verification/api frontend createNativeVerificationFlow
@@ -185,6 +200,14 @@ type createBrowserVerificationFlow struct {
 	//
 	// in: query
 	ReturnTo string `json:"return_to"`
+
+	// An optional brand of the white-label product the verification flow belongs to.
+	//
+	// The brand must be listed in `selfservice.brands`.
+	//
+	// required: false
+	// in: query
+	Brand string `json:"brand"`
 }
 
 // swagger:route GET /self-service/verification/browser frontend createBrowserVerificationFlow
@@ -435,7 +458,9 @@ func (h *Handler) updateVerificationFlow(w http.ResponseWriter, r *http.Request,
 			continue
 		}
 
-		err := ss.Verify(w, r, f)
+		err := flow.TraceStrategy(r, h.d, f, ss.VerificationStrategyID(), func(r *http.Request) error {
+			return ss.Verify(w, r, f)
+		})
 		if errors.Is(err, flow.ErrStrategyNotResponsible) {
 			continue
 		} else if errors.Is(err, flow.ErrCompletedByStrategy) {
@@ -455,6 +480,20 @@ func (h *Handler) updateVerificationFlow(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	if flow.RotateCSRFToken(h.d, w, r, f) {
+		// The strategies persist the flow themselves, so the new token is set on the stored flow.
+		stored, err := h.d.VerificationFlowPersister().GetVerificationFlow(ctx, f.ID)
+		if err != nil {
+			h.d.VerificationFlowErrorHandler().WriteFlowError(w, r, f, g, err)
+			return
+		}
+		stored.SetCSRFToken(f.CSRFToken)
+		if err := h.d.VerificationFlowPersister().UpdateVerificationFlow(ctx, stored); err != nil {
+			h.d.VerificationFlowErrorHandler().WriteFlowError(w, r, f, g, err)
+			return
+		}
+	}
+
 	// API flows can receive requests from the browser, if the link strategy is used.
 	// However, x.IsBrowserRequest only checks for form submissions, not JSON requests made from a browser context
 	if x.IsBrowserRequest(r) || (f.Type == flow.TypeBrowser && x.IsJSONRequest(r)) {
@@ -526,6 +565,7 @@ func (h *Handler) updateVerificationFlow(w http.ResponseWriter, r *http.Request,
 		h.d.VerificationFlowErrorHandler().WriteFlowError(w, r, f, g, err)
 		return
 	}
+	updatedFlow.ContinueWith = f.ContinueWith
 
 	h.d.Writer().Write(w, r, updatedFlow)
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/funnel"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
@@ -55,6 +56,7 @@ func (f PostHookExecutorFunc) ExecutePostVerificationHook(w http.ResponseWriter,
 
 type (
 	executorDependencies interface {
+		funnel.RecorderProvider
 		config.Provider
 		identity.ManagementProvider
 		identity.ValidationProvider
@@ -62,6 +64,7 @@ type (
 		HooksProvider
 		x.CSRFTokenGeneratorProvider
 		x.LoggingProvider
+		x.TracingProvider
 		x.WriterProvider
 	}
 
@@ -82,7 +85,9 @@ func NewHookExecutor(d executorDependencies) *HookExecutor {
 
 func (e *HookExecutor) PreVerificationHook(w http.ResponseWriter, r *http.Request, a *Flow) error {
 	for _, executor := range e.d.PreVerificationHooks(r.Context()) {
-		if err := executor.ExecuteVerificationPreHook(w, r, a); err != nil {
+		if err := flow.TraceHook(r, e.d, a, flow.HookPhasePre, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecuteVerificationPreHook(w, r, a)
+		}); err != nil {
 			return err
 		}
 	}
@@ -96,7 +101,9 @@ func (e *HookExecutor) PostVerificationHook(w http.ResponseWriter, r *http.Reque
 		WithField("identity_id", i.ID).
 		Debug("Running ExecutePostVerificationHooks.")
 	for k, executor := range e.d.PostVerificationHooks(r.Context()) {
-		if err := executor.ExecutePostVerificationHook(w, r, a, i); err != nil {
+		if err := flow.TraceHook(r, e.d, a, flow.HookPhasePost, executor, ErrHookAbortFlow, func(r *http.Request) error {
+			return executor.ExecutePostVerificationHook(w, r, a, i)
+		}); err != nil {
 			var traits identity.Traits
 			if i != nil {
 				traits = i.Traits
@@ -113,6 +120,7 @@ func (e *HookExecutor) PostVerificationHook(w http.ResponseWriter, r *http.Reque
 	}
 
 	trace.SpanFromContext(r.Context()).AddEvent(events.NewVerificationSucceeded(r.Context(), a.ID, i.ID, string(a.Type), a.Active.String()))
+	e.d.FlowFunnelRecorder().FlowSucceeded(r.Context(), flow.VerificationFlow, a.ID)
 
 	e.d.Logger().
 		WithRequest(r).
@@ -19,32 +19,33 @@ import (
 
 var _ login.PostHookExecutor = new(AddressVerifier)
 
-type AddressVerifier struct{}
+type AddressVerifier struct {
+	allMethods bool
+}
 
 func NewAddressVerifier() *AddressVerifier {
 	return &AddressVerifier{}
 }
 
+// NewRequiredAddressVerifier returns an address verifier which is enforced for every login
+// method it is registered for, and which also rejects identities without any verifiable address.
+// It backs `selfservice.flows.verification.required_for.login`.
+func NewRequiredAddressVerifier() *AddressVerifier {
+	return &AddressVerifier{allMethods: true}
+}
+
 func (e *AddressVerifier) ExecuteLoginPostHook(_ http.ResponseWriter, _ *http.Request, _ node.UiNodeGroup, f *login.Flow, s *session.Session) error {
 	// if the login happens using the password method, there must be at least one verified address
-	if f.Active != identity.CredentialsTypePassword {
+	if !e.allMethods && f.Active != identity.CredentialsTypePassword {
 		return nil
 	}
 
 	// TODO: can this happen at all?
-	if len(s.Identity.VerifiableAddresses) == 0 {
+	if len(s.Identity.VerifiableAddresses) == 0 && !e.allMethods {
 		return errors.WithStack(herodot.ErrInternalServerError.WithReason("A misconfiguration prevents login. Expected to find a verification address but this identity does not have one assigned."))
 	}
 
-	addressVerified := false
-	for _, va := range s.Identity.VerifiableAddresses {
-		if va.Verified {
-			addressVerified = true
-			break
-		}
-	}
-
-	if !addressVerified {
+	if !s.Identity.HasVerifiedAddress() {
 		return login.ErrAddressNotVerified
 	}
 
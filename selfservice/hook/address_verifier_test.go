@@ -94,3 +94,32 @@ func TestAddressVerifier(t *testing.T) {
 		})
 	}
 }
+
+func TestRequiredAddressVerifier(t *testing.T) {
+	verifier := NewRequiredAddressVerifier()
+
+	for _, method := range []identity.CredentialsType{identity.CredentialsTypePassword, identity.CredentialsTypeOIDC, identity.CredentialsTypeCodeAuth} {
+		t.Run("method="+method.String(), func(t *testing.T) {
+			f := &login.Flow{Active: method}
+			for _, tc := range []struct {
+				name                string
+				verifiableAddresses []identity.VerifiableAddress
+				expectedError       error
+			}{
+				{name: "no addresses", expectedError: login.ErrAddressNotVerified},
+				{name: "not verified", verifiableAddresses: []identity.VerifiableAddress{{Verified: false}}, expectedError: login.ErrAddressNotVerified},
+				{name: "verified", verifiableAddresses: []identity.VerifiableAddress{{Verified: false}, {Verified: true}}},
+			} {
+				t.Run("case="+tc.name, func(t *testing.T) {
+					s := &session.Session{ID: x.NewUUID(), Identity: &identity.Identity{ID: x.NewUUID(), VerifiableAddresses: tc.verifiableAddresses}}
+					err := verifier.ExecuteLoginPostHook(nil, nil, node.DefaultGroup, f, s)
+					if tc.expectedError == nil {
+						assert.NoError(t, err)
+					} else {
+						assert.ErrorIs(t, err, tc.expectedError)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tidwall/gjson"
+	grpccodes "google.golang.org/grpc/codes"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/logrusx"
+)
+
+var (
+	hookCircuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kratos_selfservice_hook_circuit_breaker_state",
+		Help: "The state of the circuit breaker of a hook, labelled with the ID of the hook. 0 is closed, 1 is half-open, and 2 is open.",
+	}, []string{"hook_id"})
+	hookCircuitBreakerRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_selfservice_hook_circuit_breaker_rejections_total",
+		Help: "Number of hook calls which were rejected because the circuit breaker of the hook was open, labelled with the ID of the hook.",
+	}, []string{"hook_id"})
+)
+
+// CircuitBreakerCollectors returns the Prometheus collectors of the hook circuit breakers.
+// They are registered by the registry's metrics setup.
+func CircuitBreakerCollectors() []prometheus.Collector {
+	return []prometheus.Collector{hookCircuitBreakerState, hookCircuitBreakerRejections}
+}
+
+// ErrCircuitOpen is returned if a hook is not called because its circuit breaker is open.
+var ErrCircuitOpen = herodot.DefaultError{
+	CodeField:     http.StatusServiceUnavailable,
+	StatusField:   http.StatusText(http.StatusServiceUnavailable),
+	GRPCCodeField: grpccodes.Unavailable,
+	ErrorField:    "the circuit breaker of the hook is open",
+	ReasonField:   "A third-party upstream service is currently unavailable. Please try again later.",
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitHalfOpen:
+		return "half-open"
+	case circuitOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+type (
+	circuitBreakerConfig struct {
+		enabled          bool
+		failureThreshold int
+		openDuration     time.Duration
+		halfOpenRequests int
+	}
+
+	// circuitBreaker stops calling a failing hook. After `failure_threshold` consecutive
+	// failures the circuit opens and calls are rejected for `open_duration`. Afterwards the
+	// circuit is half-open and up to `half_open_requests` probe calls are let through. If a
+	// probe succeeds the circuit closes, otherwise it opens again.
+	circuitBreaker struct {
+		id  string
+		mu  sync.Mutex
+		now func() time.Time
+
+		state    circuitState
+		failures int
+		openedAt time.Time
+		probes   int
+	}
+
+	circuitBreakers struct {
+		mu       sync.Mutex
+		breakers map[string]*circuitBreaker
+	}
+)
+
+// hookCircuitBreakers holds the state of the circuit breakers of all hooks. Like the Jsonnet
+// cache it is shared, because hooks are instantiated for every flow.
+var hookCircuitBreakers = &circuitBreakers{breakers: make(map[string]*circuitBreaker)}
+
+// parseCircuitBreakerConfig reads the `circuit_breaker` block of a hook configuration.
+func parseCircuitBreakerConfig(conf []byte) circuitBreakerConfig {
+	c := circuitBreakerConfig{
+		enabled:          gjson.GetBytes(conf, "circuit_breaker.enabled").Bool(),
+		failureThreshold: 5,
+		openDuration:     30 * time.Second,
+		halfOpenRequests: 1,
+	}
+	if v := gjson.GetBytes(conf, "circuit_breaker.failure_threshold"); v.Exists() && v.Int() > 0 {
+		c.failureThreshold = int(v.Int())
+	}
+	if v := gjson.GetBytes(conf, "circuit_breaker.open_duration"); v.Exists() {
+		if d, err := time.ParseDuration(v.String()); err == nil && d > 0 {
+			c.openDuration = d
+		}
+	}
+	if v := gjson.GetBytes(conf, "circuit_breaker.half_open_requests"); v.Exists() && v.Int() > 0 {
+		c.halfOpenRequests = int(v.Int())
+	}
+	return c
+}
+
+func (b *circuitBreakers) get(id string) *circuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cb, ok := b.breakers[id]
+	if !ok {
+		cb = &circuitBreaker{id: id, now: time.Now}
+		b.breakers[id] = cb
+	}
+	return cb
+}
+
+// allow reports whether the hook may be called.
+func (b *circuitBreaker) allow(c circuitBreakerConfig, l *logrusx.Logger) bool {
+	if !c.enabled {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen {
+		if b.now().Sub(b.openedAt) < c.openDuration {
+			hookCircuitBreakerRejections.WithLabelValues(b.id).Inc()
+			return false
+		}
+		b.transition(circuitHalfOpen, l)
+	}
+
+	if b.state == circuitHalfOpen {
+		if b.probes >= c.halfOpenRequests {
+			hookCircuitBreakerRejections.WithLabelValues(b.id).Inc()
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// record records the outcome of a hook call.
+func (b *circuitBreaker) record(c circuitBreakerConfig, success bool, l *logrusx.Logger) {
+	if !c.enabled {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		if b.state != circuitClosed {
+			b.transition(circuitClosed, l)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= c.failureThreshold {
+		b.transition(circuitOpen, l)
+	}
+}
+
+func (b *circuitBreaker) transition(to circuitState, l *logrusx.Logger) {
+	from := b.state
+	b.state = to
+	b.probes = 0
+	if to == circuitOpen {
+		b.openedAt = b.now()
+	}
+	if to == circuitClosed {
+		b.failures = 0
+	}
+
+	hookCircuitBreakerState.WithLabelValues(b.id).Set(float64(to))
+	entry := l.WithField("hook_id", b.id).WithField("from", from.String()).WithField("to", to.String())
+	if to == circuitOpen {
+		entry.Warn("The circuit breaker of the hook opened, calls are rejected until it is half-open.")
+	} else {
+		entry.Info("The circuit breaker of the hook changed its state.")
+	}
+}
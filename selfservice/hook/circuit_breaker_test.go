@@ -0,0 +1,119 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/x"
+)
+
+func TestWebHookResilience(t *testing.T) {
+	t.Parallel()
+	_, reg := internal.NewFastRegistryWithMocks(t)
+	req := &http.Request{
+		Host:   "www.ory.sh",
+		URL:    &url.URL{Path: "/some_end_point"},
+		Method: http.MethodPost,
+	}
+
+	var hits atomic.Int32
+	var status atomic.Int32
+	var delay atomic.Int64
+	webhookReceiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		time.Sleep(time.Duration(delay.Load()))
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(webhookReceiver.Close)
+
+	newHook := func(extra string) *hook.WebHook {
+		return hook.NewWebHook(reg, json.RawMessage(fmt.Sprintf(`{
+			"id": %q,
+			"url": %q,
+			"method": "GET",
+			"body": "file://stub/test_body.jsonnet"%s
+		}`, x.NewUUID(), webhookReceiver.URL, extra)))
+	}
+	execute := func(wh *hook.WebHook) error {
+		return wh.ExecuteLoginPreHook(nil, req, &login.Flow{ID: x.NewUUID()})
+	}
+	errorCode := func(t *testing.T, err error) int {
+		var statusErr herodot.StatusCodeCarrier
+		require.True(t, errors.As(err, &statusErr), "%+v", err)
+		return statusErr.StatusCode()
+	}
+
+	t.Run("case=retries", func(t *testing.T) {
+		hits.Store(0)
+		status.Store(http.StatusInternalServerError)
+		delay.Store(0)
+
+		require.Error(t, execute(newHook(`, "retry": {"max_retries": 0}`)))
+		assert.EqualValues(t, 1, hits.Load())
+
+		hits.Store(0)
+		require.Error(t, execute(newHook(`, "retry": {"max_retries": 2, "min_wait": "1ms", "max_wait": "5ms"}`)))
+		assert.EqualValues(t, 3, hits.Load())
+	})
+
+	t.Run("case=timeout", func(t *testing.T) {
+		status.Store(http.StatusOK)
+		delay.Store(int64(200 * time.Millisecond))
+		t.Cleanup(func() { delay.Store(0) })
+
+		assert.Equal(t, http.StatusGatewayTimeout, errorCode(t, execute(newHook(`, "timeout": "20ms", "retry": {"max_retries": 0}`))))
+	})
+
+	t.Run("case=circuit breaker", func(t *testing.T) {
+		hits.Store(0)
+		status.Store(http.StatusInternalServerError)
+		delay.Store(0)
+
+		wh := newHook(`, "retry": {"max_retries": 0}, "circuit_breaker": {"enabled": true, "failure_threshold": 2, "open_duration": "100ms"}`)
+		require.Error(t, execute(wh))
+		require.Error(t, execute(wh))
+		assert.EqualValues(t, 2, hits.Load())
+
+		assert.Equal(t, http.StatusServiceUnavailable, errorCode(t, execute(wh)), "the circuit is open")
+		assert.EqualValues(t, 2, hits.Load(), "the hook is not called while the circuit is open")
+
+		time.Sleep(150 * time.Millisecond)
+		require.Error(t, execute(wh), "a failed probe opens the circuit again")
+		assert.EqualValues(t, 3, hits.Load())
+		assert.Equal(t, http.StatusServiceUnavailable, errorCode(t, execute(wh)))
+
+		time.Sleep(150 * time.Millisecond)
+		status.Store(http.StatusOK)
+		require.NoError(t, execute(wh), "a successful probe closes the circuit")
+		require.NoError(t, execute(wh))
+		assert.EqualValues(t, 5, hits.Load())
+	})
+
+	t.Run("case=client errors do not open the circuit", func(t *testing.T) {
+		hits.Store(0)
+		status.Store(http.StatusBadRequest)
+
+		wh := newHook(`, "circuit_breaker": {"enabled": true, "failure_threshold": 1}`)
+		for range 3 {
+			assert.Equal(t, http.StatusBadGateway, errorCode(t, execute(wh)))
+		}
+		assert.EqualValues(t, 3, hits.Load())
+	})
+}
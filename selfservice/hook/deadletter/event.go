@@ -0,0 +1,152 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/cipher"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/pagination/keysetpagination"
+	"github.com/ory/x/sqlxx"
+)
+
+// Failed Web Hook Event
+//
+// A failed event is a web hook request which was sent asynchronously (the web hook
+// ignores the response) and could not be delivered. It can be replayed once the
+// receiver is reachable again.
+//
+// swagger:model failedWebHookEvent
+type Event struct {
+	// The event's ID.
+	//
+	// required: true
+	ID uuid.UUID `json:"id" faker:"-" db:"id"`
+
+	// The ID of the web hook as configured in `id`. Empty if the web hook has no ID.
+	WebhookID string `json:"webhook_id" db:"webhook_id"`
+
+	// The HTTP method of the web hook request.
+	//
+	// required: true
+	Method string `json:"method" db:"method"`
+
+	// The URL of the web hook request.
+	//
+	// required: true
+	URL string `json:"url" db:"url"`
+
+	// EncryptedRequest are the headers and the body of the request encrypted with the cipher
+	// secrets, as they may contain credentials and identity traits.
+	EncryptedRequest string `json:"-" faker:"-" db:"request"`
+
+	// The error of the last delivery attempt.
+	//
+	// required: true
+	Error string `json:"error" db:"error"`
+
+	// The number of delivery attempts, including the original request.
+	//
+	// required: true
+	Attempts int `json:"attempts" db:"attempts"`
+
+	// ReplayedAt is the time the event was replayed successfully.
+	ReplayedAt sqlxx.NullTime `json:"replayed_at" faker:"-" db:"replayed_at"`
+
+	// CreatedAt is a helper struct field for gobuffalo.pop.
+	CreatedAt time.Time `json:"created_at" faker:"-" db:"created_at"`
+
+	// UpdatedAt is a helper struct field for gobuffalo.pop.
+	UpdatedAt time.Time `json:"updated_at" faker:"-" db:"updated_at"`
+
+	NID uuid.UUID `json:"-" faker:"-" db:"nid"`
+}
+
+// request is the part of the web hook request which is stored encrypted.
+type request struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func (e Event) TableName(context.Context) string {
+	return "selfservice_failed_webhook_events"
+}
+
+func (e Event) GetID() uuid.UUID {
+	return e.ID
+}
+
+func (e Event) GetNID() uuid.UUID {
+	return e.NID
+}
+
+func (e Event) PageToken() keysetpagination.PageToken {
+	return keysetpagination.MapPageToken{
+		"id":         e.ID.String(),
+		"created_at": e.CreatedAt.Format(x.MapPaginationDateFormat),
+	}
+}
+
+func (e Event) DefaultPageToken() keysetpagination.PageToken {
+	return keysetpagination.MapPageToken{
+		"id":         uuid.Nil.String(),
+		"created_at": time.Date(2200, 12, 31, 23, 59, 59, 0, time.UTC).Format(x.MapPaginationDateFormat),
+	}
+}
+
+type (
+	Persister interface {
+		CreateFailedWebhookEvent(ctx context.Context, e *Event) error
+		GetFailedWebhookEvent(ctx context.Context, id uuid.UUID) (*Event, error)
+		// ListFailedWebhookEvents lists the events which were not replayed yet, newest first.
+		ListFailedWebhookEvents(ctx context.Context, opts []keysetpagination.Option) ([]Event, *keysetpagination.Paginator, error)
+		UpdateFailedWebhookEvent(ctx context.Context, e *Event) error
+	}
+	PersistenceProvider interface {
+		FailedWebhookEventPersister() Persister
+	}
+)
+
+// NewEvent returns the failed event of the web hook request, encrypting its headers and body.
+func NewEvent(ctx context.Context, c cipher.Provider, webhookID, method, url string, header http.Header, body []byte, cause error) (*Event, error) {
+	raw, err := json.Marshal(&request{Header: header, Body: body})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	encrypted, err := c.Cipher(ctx).Encrypt(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		ID:               x.NewUUID(),
+		WebhookID:        webhookID,
+		Method:           method,
+		URL:              url,
+		EncryptedRequest: encrypted,
+		Error:            cause.Error(),
+		Attempts:         1,
+	}, nil
+}
+
+func (e *Event) request(ctx context.Context, c cipher.Provider) (*request, error) {
+	raw, err := c.Cipher(ctx).Decrypt(ctx, e.EncryptedRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	var r request
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,235 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package deadletter
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	grpccodes "google.golang.org/grpc/codes"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/cipher"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/pagination/keysetpagination"
+	"github.com/ory/x/pagination/migrationpagination"
+	"github.com/ory/x/sqlxx"
+)
+
+const (
+	RouteCollection = "/events/failed"
+	RouteReplay     = "/events/:id/replay"
+)
+
+type (
+	handlerDependencies interface {
+		PersistenceProvider
+		config.Provider
+		cipher.Provider
+		x.HTTPClientProvider
+		x.WriterProvider
+		x.LoggingProvider
+		x.CSRFProvider
+	}
+	Handler struct {
+		r handlerDependencies
+	}
+	HandlerProvider interface {
+		FailedWebhookEventHandler() *Handler
+	}
+)
+
+func NewHandler(r handlerDependencies) *Handler {
+	return &Handler{r: r}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	h.r.CSRFHandler().IgnoreGlob(x.AdminPrefix + "/events/*/replay")
+	public.GET(x.AdminPrefix+RouteCollection, x.RedirectToAdminRoute(h.r))
+	public.POST(x.AdminPrefix+RouteReplay, x.RedirectToAdminRoute(h.r))
+}
+
+func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.GET(RouteCollection, h.listFailedWebhookEvents)
+	admin.POST(RouteReplay, h.replayFailedWebhookEvent)
+}
+
+// Paginated Failed Web Hook Event List Response
+//
+// swagger:response listFailedWebhookEvents
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type listFailedWebhookEventsResponse struct {
+	migrationpagination.ResponseHeaderAnnotation
+
+	// in: body
+	Body []Event
+}
+
+// Paginated List Failed Web Hook Event Parameters
+//
+// swagger:parameters listFailedWebhookEvents
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type listFailedWebhookEvents struct {
+	keysetpagination.RequestParameters
+}
+
+// swagger:route GET /admin/events/failed identity listFailedWebhookEvents
+//
+// # List Failed Web Hook Events
+//
+// Lists the asynchronous web hook requests which could not be delivered and were not
+// replayed yet, newest first.
+//
+//	Produces:
+//	- application/json
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: listFailedWebhookEvents
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) listFailedWebhookEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	opts, err := keysetpagination.Parse(r.URL.Query(), keysetpagination.NewMapPageToken)
+	if err != nil {
+		h.r.Writer().WriteErrorCode(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	events, nextPage, err := h.r.FailedWebhookEventPersister().ListFailedWebhookEvents(r.Context(), opts)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	u := *r.URL
+	keysetpagination.Header(w, &u, nextPage)
+	h.r.Writer().Write(w, r, events)
+}
+
+// Replay Failed Web Hook Event Parameters
+//
+// swagger:parameters replayFailedWebhookEvent
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type replayFailedWebhookEvent struct {
+	// The ID of the failed event.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+}
+
+// swagger:route POST /admin/events/{id}/replay identity replayFailedWebhookEvent
+//
+// # Replay a Failed Web Hook Event
+//
+// Sends the web hook request of a failed event again. If the receiver responds with a 2xx
+// status code, the event is marked as replayed and no longer listed. Otherwise, the error
+// of the attempt is recorded and returned.
+//
+//	Produces:
+//	- application/json
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: failedWebHookEvent
+//	  404: errorGeneric
+//	  409: errorGeneric
+//	  502: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) replayFailedWebhookEvent(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+	e, err := h.r.FailedWebhookEventPersister().GetFailedWebhookEvent(ctx, x.ParseUUID(ps.ByName("id")))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if !time.Time(e.ReplayedAt).IsZero() {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrConflict.WithReasonf("The event was already replayed at %s.", time.Time(e.ReplayedAt).Format(time.RFC3339))))
+		return
+	}
+
+	replayErr := h.replay(r, e)
+	e.Attempts++
+	if replayErr == nil {
+		e.ReplayedAt = sqlxx.NullTime(time.Now().UTC())
+	} else {
+		e.Error = replayErr.Error()
+	}
+
+	if err := h.r.FailedWebhookEventPersister().UpdateFailedWebhookEvent(ctx, e); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Audit().
+		WithRequest(r).
+		WithField("failed_webhook_event_id", e.ID).
+		WithField("webhook_id", e.WebhookID).
+		WithField("replayed", replayErr == nil).
+		Info("An administrator replayed a failed web hook event.")
+
+	if replayErr != nil {
+		h.r.Writer().WriteError(w, r, herodot.DefaultError{
+			CodeField:     http.StatusBadGateway,
+			StatusField:   http.StatusText(http.StatusBadGateway),
+			GRPCCodeField: grpccodes.Aborted,
+			ReasonField:   "The web hook receiver could not be reached or responded improperly. The event was not replayed.",
+			ErrorField:    replayErr.Error(),
+		})
+		return
+	}
+
+	h.r.Writer().Write(w, r, e)
+}
+
+func (h *Handler) replay(r *http.Request, e *Event) error {
+	ctx := config.WithHTTPClientIntegration(r.Context(), config.HTTPClientIntegrationWebhooks)
+
+	stored, err := e.request(ctx, h.r)
+	if err != nil {
+		return err
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, e.Method, e.URL, bytes.NewReader(stored.Body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if stored.Header != nil {
+		req.Header = stored.Header
+	}
+	x.InjectTraceContext(ctx, req.Header)
+
+	res, err := h.r.HTTPClient(ctx).Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<20))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("webhook failed with status code %v", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package deadletter_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	_, reg := internal.NewFastRegistryWithMocks(t)
+	_, adminTS := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	var healthy atomic.Bool
+	var received atomic.Value
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		received.Store(r.Header.Get("Authorization") + " " + string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+
+	event, err := deadletter.NewEvent(ctx, reg, "registration", "POST", receiver.URL,
+		http.Header{"Authorization": {"Bearer secret"}}, []byte(`{"identity_id":"42"}`), errors.New("receiver unreachable"))
+	require.NoError(t, err)
+	require.NoError(t, reg.FailedWebhookEventPersister().CreateFailedWebhookEvent(ctx, event))
+
+	stored, err := reg.FailedWebhookEventPersister().GetFailedWebhookEvent(ctx, event.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, stored.EncryptedRequest, "secret", "the request must be encrypted")
+
+	list := func(t *testing.T) gjson.Result {
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "GET", adminTS.URL+"/admin/events/failed", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+	replay := func(t *testing.T, id string, expectCode int) gjson.Result {
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", adminTS.URL+"/admin/events/"+id+"/replay", nil)
+		require.Equal(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=lists the failed event", func(t *testing.T) {
+		actual := list(t)
+		require.Len(t, actual.Array(), 1, "%s", actual.Raw)
+		assert.Equal(t, event.ID.String(), actual.Get("0.id").String())
+		assert.Equal(t, "registration", actual.Get("0.webhook_id").String())
+		assert.Equal(t, "receiver unreachable", actual.Get("0.error").String())
+		assert.False(t, actual.Get("0.request").Exists(), "%s", actual.Raw)
+	})
+
+	t.Run("case=records the error if the receiver is still failing", func(t *testing.T) {
+		actual := replay(t, event.ID.String(), http.StatusBadGateway)
+		assert.Contains(t, actual.Get("error.message").String(), "status code 400", "%s", actual.Raw)
+
+		stored, err := reg.FailedWebhookEventPersister().GetFailedWebhookEvent(ctx, event.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, stored.Attempts)
+		assert.Contains(t, stored.Error, "status code 400")
+		assert.Len(t, list(t).Array(), 1)
+	})
+
+	t.Run("case=replays the event once the receiver recovered", func(t *testing.T) {
+		healthy.Store(true)
+
+		actual := replay(t, event.ID.String(), http.StatusOK)
+		assert.EqualValues(t, 3, actual.Get("attempts").Int(), "%s", actual.Raw)
+		assert.NotEmpty(t, actual.Get("replayed_at").String(), "%s", actual.Raw)
+		assert.Equal(t, `Bearer secret {"identity_id":"42"}`, received.Load())
+
+		assert.Empty(t, list(t).Array())
+	})
+
+	t.Run("case=does not replay an event twice", func(t *testing.T) {
+		replay(t, event.ID.String(), http.StatusConflict)
+	})
+
+	t.Run("case=returns not found for unknown events", func(t *testing.T) {
+		replay(t, uuid.Must(uuid.NewV4()).String(), http.StatusNotFound)
+	})
+}
@@ -0,0 +1,524 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	hookv1 "github.com/ory/kratos/gen/hook/v1"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/recovery"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlxx"
+)
+
+var _ interface {
+	login.PreHookExecutor
+	login.PostHookExecutor
+
+	registration.PostHookPostPersistExecutor
+	registration.PostHookPrePersistExecutor
+	registration.PreHookExecutor
+
+	verification.PreHookExecutor
+	verification.PostHookExecutor
+
+	recovery.PreHookExecutor
+	recovery.PostHookExecutor
+
+	settings.PreHookExecutor
+	settings.PostHookPrePersistExecutor
+	settings.PostHookPostPersistExecutor
+} = (*GRPCHook)(nil)
+
+const defaultGRPCHookTimeout = 5 * time.Second
+
+type (
+	GRPCHookConnectionsProvider interface {
+		GRPCHookConnections() *GRPCHookConnections
+	}
+
+	grpcHookDependencies interface {
+		x.LoggingProvider
+		config.Provider
+		GRPCHookConnectionsProvider
+	}
+
+	// GRPCHookConnections shares the client connections of all gRPC hooks with the same target,
+	// as hooks are instantiated for every flow.
+	GRPCHookConnections struct {
+		mu    sync.Mutex
+		conns map[string]*grpc.ClientConn
+	}
+
+	// GRPCHook calls the `hook.v1.HookService` of a gRPC service. It is the typed counterpart
+	// of the WebHook.
+	GRPCHook struct {
+		deps grpcHookDependencies
+		conf json.RawMessage
+	}
+
+	grpcHookResponse interface {
+		GetErrors() []*hookv1.ValidationError
+	}
+)
+
+func NewGRPCHookConnections() *GRPCHookConnections {
+	return &GRPCHookConnections{conns: make(map[string]*grpc.ClientConn)}
+}
+
+// Get returns the connection to the target. Connections are established lazily by gRPC.
+func (c *GRPCHookConnections) Get(target string, plaintext bool) (*grpc.ClientConn, error) {
+	key := target
+	if plaintext {
+		key = "plaintext+" + target
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, ok := c.conns[key]; ok {
+		return conn, nil
+	}
+
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if plaintext {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c.conns[key] = conn
+	return conn, nil
+}
+
+// Close closes all connections.
+func (c *GRPCHookConnections) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for key, conn := range c.conns {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = errors.WithStack(cerr)
+		}
+		delete(c.conns, key)
+	}
+	return err
+}
+
+func NewGRPCHook(d grpcHookDependencies, c json.RawMessage) *GRPCHook {
+	return &GRPCHook{deps: d, conf: c}
+}
+
+func (e *GRPCHook) parseResponse() bool {
+	return gjson.GetBytes(e.conf, "response.parse").Bool()
+}
+
+func (e *GRPCHook) ExecuteLoginPreHook(_ http.ResponseWriter, req *http.Request, f *login.Flow) error {
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecuteLoginPreHook", func(ctx context.Context) error {
+		_, err := callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PreLoginResponse, error) {
+			return c.PreLogin(ctx, &hookv1.PreLoginRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req)})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecuteLoginPostHook(_ http.ResponseWriter, req *http.Request, _ node.UiNodeGroup, f *login.Flow, s *session.Session) error {
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecuteLoginPostHook", func(ctx context.Context) error {
+		id, err := grpcHookIdentity(s.Identity)
+		if err != nil {
+			return err
+		}
+		_, err = callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PostLoginResponse, error) {
+			return c.PostLogin(ctx, &hookv1.PostLoginRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req), Identity: id, Session: grpcHookSession(s)})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecuteRegistrationPreHook(_ http.ResponseWriter, req *http.Request, f *registration.Flow) error {
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecuteRegistrationPreHook", func(ctx context.Context) error {
+		_, err := callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PreRegistrationResponse, error) {
+			return c.PreRegistration(ctx, &hookv1.PreRegistrationRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req)})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecutePostRegistrationPrePersistHook(_ http.ResponseWriter, req *http.Request, f *registration.Flow, i *identity.Identity) error {
+	if !e.parseResponse() {
+		return nil
+	}
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecutePostRegistrationPrePersistHook", func(ctx context.Context) error {
+		id, err := grpcHookIdentity(i)
+		if err != nil {
+			return err
+		}
+		resp, err := callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PostRegistrationResponse, error) {
+			return c.PostRegistration(ctx, &hookv1.PostRegistrationRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req), Identity: id})
+		})
+		if err != nil {
+			return err
+		}
+		return applyGRPCHookIdentityUpdate(i, resp.GetIdentity())
+	})
+}
+
+func (e *GRPCHook) ExecutePostRegistrationPostPersistHook(_ http.ResponseWriter, req *http.Request, f *registration.Flow, s *session.Session) error {
+	if e.parseResponse() {
+		return nil
+	}
+
+	// As with web hooks, the hook is decoupled from the request so that it still executes if the
+	// request is canceled.
+	ctx := context.WithoutCancel(req.Context())
+	return otelx.WithSpan(ctx, "selfservice.hook.GRPCHook.ExecutePostRegistrationPostPersistHook", func(ctx context.Context) error {
+		id, err := grpcHookIdentity(s.Identity)
+		if err != nil {
+			return err
+		}
+		_, err = callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PostRegistrationResponse, error) {
+			return c.PostRegistration(ctx, &hookv1.PostRegistrationRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req), Identity: id, Session: grpcHookSession(s)})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecuteSettingsPreHook(_ http.ResponseWriter, req *http.Request, f *settings.Flow) error {
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecuteSettingsPreHook", func(ctx context.Context) error {
+		_, err := callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PreSettingsResponse, error) {
+			return c.PreSettings(ctx, &hookv1.PreSettingsRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req)})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecuteSettingsPrePersistHook(_ http.ResponseWriter, req *http.Request, f *settings.Flow, i *identity.Identity) error {
+	if !e.parseResponse() {
+		return nil
+	}
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecuteSettingsPrePersistHook", func(ctx context.Context) error {
+		id, err := grpcHookIdentity(i)
+		if err != nil {
+			return err
+		}
+		resp, err := callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PostSettingsResponse, error) {
+			return c.PostSettings(ctx, &hookv1.PostSettingsRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req), Identity: id})
+		})
+		if err != nil {
+			return err
+		}
+		return applyGRPCHookIdentityUpdate(i, resp.GetIdentity())
+	})
+}
+
+func (e *GRPCHook) ExecuteSettingsPostPersistHook(_ http.ResponseWriter, req *http.Request, f *settings.Flow, i *identity.Identity, _ *session.Session) error {
+	if e.parseResponse() {
+		return nil
+	}
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecuteSettingsPostPersistHook", func(ctx context.Context) error {
+		id, err := grpcHookIdentity(i)
+		if err != nil {
+			return err
+		}
+		_, err = callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PostSettingsResponse, error) {
+			return c.PostSettings(ctx, &hookv1.PostSettingsRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req), Identity: id})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecuteRecoveryPreHook(_ http.ResponseWriter, req *http.Request, f *recovery.Flow) error {
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecuteRecoveryPreHook", func(ctx context.Context) error {
+		_, err := callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PreRecoveryResponse, error) {
+			return c.PreRecovery(ctx, &hookv1.PreRecoveryRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req)})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecutePostRecoveryHook(_ http.ResponseWriter, req *http.Request, f *recovery.Flow, s *session.Session) error {
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecutePostRecoveryHook", func(ctx context.Context) error {
+		id, err := grpcHookIdentity(s.Identity)
+		if err != nil {
+			return err
+		}
+		_, err = callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PostRecoveryResponse, error) {
+			return c.PostRecovery(ctx, &hookv1.PostRecoveryRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req), Identity: id})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecuteVerificationPreHook(_ http.ResponseWriter, req *http.Request, f *verification.Flow) error {
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecuteVerificationPreHook", func(ctx context.Context) error {
+		_, err := callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PreVerificationResponse, error) {
+			return c.PreVerification(ctx, &hookv1.PreVerificationRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req)})
+		})
+		return err
+	})
+}
+
+func (e *GRPCHook) ExecutePostVerificationHook(_ http.ResponseWriter, req *http.Request, f *verification.Flow, i *identity.Identity) error {
+	return otelx.WithSpan(req.Context(), "selfservice.hook.GRPCHook.ExecutePostVerificationHook", func(ctx context.Context) error {
+		id, err := grpcHookIdentity(i)
+		if err != nil {
+			return err
+		}
+		_, err = callGRPCHook(ctx, e, func(ctx context.Context, c hookv1.HookServiceClient) (*hookv1.PostVerificationResponse, error) {
+			return c.PostVerification(ctx, &hookv1.PostVerificationRequest{Flow: grpcHookFlow(f), Request: e.request(ctx, req), Identity: id})
+		})
+		return err
+	})
+}
+
+// callGRPCHook calls the hook service with the configured timeout and metadata. If the hook
+// is configured to parse the response, validation errors in the response interrupt the flow.
+func callGRPCHook[R grpcHookResponse](ctx context.Context, e *GRPCHook, call func(context.Context, hookv1.HookServiceClient) (R, error)) (resp R, err error) {
+	var (
+		target    = gjson.GetBytes(e.conf, "address").String()
+		plaintext = gjson.GetBytes(e.conf, "insecure").Bool()
+		hookID    = gjson.GetBytes(e.conf, "id").String()
+		timeout   = defaultGRPCHookTimeout
+	)
+	if t := gjson.GetBytes(e.conf, "timeout").String(); t != "" {
+		if timeout, err = time.ParseDuration(t); err != nil {
+			return resp, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The timeout of the gRPC hook is invalid: %s", err))
+		}
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("grpc_hook.address", target),
+		attribute.String("grpc_hook.id", hookID),
+		attribute.Bool("grpc_hook.response.parse", e.parseResponse()),
+	)
+
+	conn, err := e.deps.GRPCHookConnections().Get(target, plaintext)
+	if err != nil {
+		return resp, err
+	}
+
+	breakerID, breakerConf := hookID, parseCircuitBreakerConfig(e.conf)
+	if breakerID == "" {
+		breakerID = target
+	}
+	breaker := hookCircuitBreakers.get(breakerID)
+	if !breaker.allow(breakerConf, e.deps.Logger()) {
+		return resp, errors.WithStack(ErrCircuitOpen)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	gjson.GetBytes(e.conf, "metadata").ForEach(func(key, value gjson.Result) bool {
+		ctx = metadata.AppendToOutgoingContext(ctx, key.String(), value.String())
+		return true
+	})
+
+	start := time.Now()
+	resp, err = call(ctx, hookv1.NewHookServiceClient(conn))
+	breaker.record(breakerConf, err == nil, e.deps.Logger())
+	logger := e.deps.Logger().WithField("grpc_hook_id", hookID).WithField("duration", time.Since(start))
+	if err != nil {
+		logger.WithError(err).Error("gRPC hook request failed")
+		if status.Code(err) == grpccodes.DeadlineExceeded {
+			return resp, herodot.DefaultError{
+				CodeField:     http.StatusGatewayTimeout,
+				StatusField:   http.StatusText(http.StatusGatewayTimeout),
+				GRPCCodeField: grpccodes.DeadlineExceeded,
+				ErrorField:    err.Error(),
+				ReasonField:   "A third-party upstream service could not be reached. Please try again later.",
+			}.WithWrap(errors.WithStack(err))
+		}
+		return resp, herodot.DefaultError{
+			CodeField:     http.StatusBadGateway,
+			StatusField:   http.StatusText(http.StatusBadGateway),
+			GRPCCodeField: grpccodes.Aborted,
+			ErrorField:    err.Error(),
+			ReasonField:   "A third-party upstream service responded improperly. Please try again later.",
+		}.WithWrap(errors.WithStack(err))
+	}
+	logger.Info("gRPC hook request succeeded")
+
+	if !e.parseResponse() || len(resp.GetErrors()) == 0 {
+		return resp, nil
+	}
+
+	validationErrs := make([]*schema.ValidationError, 0, len(resp.GetErrors()))
+	for _, ve := range resp.GetErrors() {
+		messages := text.Messages{}
+		for _, m := range ve.GetMessages() {
+			message, err := grpcHookMessage(m)
+			if err != nil {
+				return resp, err
+			}
+			messages.Add(message)
+		}
+		validationErrs = append(validationErrs, schema.NewHookValidationError(ve.GetInstancePtr(), "a gRPC hook returned an error", messages))
+	}
+	return resp, schema.NewValidationListError(validationErrs)
+}
+
+func (e *GRPCHook) request(ctx context.Context, req *http.Request) *hookv1.Request {
+	allowed := make(map[string]struct{})
+	for _, h := range e.deps.Config().WebhookHeaderAllowlist(ctx) {
+		allowed[textproto.CanonicalMIMEHeaderKey(h)] = struct{}{}
+	}
+
+	headers := make(map[string]string)
+	for key, values := range req.Header {
+		if _, ok := allowed[textproto.CanonicalMIMEHeaderKey(key)]; ok {
+			headers[key] = strings.Join(values, ", ")
+		}
+	}
+
+	return &hookv1.Request{
+		Method:  req.Method,
+		Url:     x.RequestURL(req).String(),
+		Headers: headers,
+	}
+}
+
+func grpcHookFlow(f flow.Flow) *hookv1.Flow {
+	raw, _ := json.Marshal(f)
+	return &hookv1.Flow{
+		Id:         f.GetID().String(),
+		Type:       string(f.GetType()),
+		RequestUrl: f.GetRequestURL(),
+		Json:       raw,
+	}
+}
+
+func grpcHookIdentity(i *identity.Identity) (*hookv1.Identity, error) {
+	if i == nil {
+		return nil, nil
+	}
+
+	id := &hookv1.Identity{
+		Id:       i.ID.String(),
+		SchemaId: i.SchemaID,
+		State:    string(i.State),
+	}
+	for _, field := range []struct {
+		raw []byte
+		to  **structpb.Struct
+	}{
+		{i.Traits, &id.Traits},
+		{i.MetadataPublic, &id.MetadataPublic},
+		{i.MetadataAdmin, &id.MetadataAdmin},
+	} {
+		s, err := jsonToStruct(field.raw)
+		if err != nil {
+			return nil, err
+		}
+		*field.to = s
+	}
+	return id, nil
+}
+
+func grpcHookSession(s *session.Session) *hookv1.Session {
+	if s == nil {
+		return nil
+	}
+
+	methods := make([]string, len(s.AMR))
+	for k, m := range s.AMR {
+		methods[k] = string(m.Method)
+	}
+	return &hookv1.Session{
+		Id:                          s.ID.String(),
+		AuthenticatorAssuranceLevel: string(s.AuthenticatorAssuranceLevel),
+		AuthenticationMethods:       methods,
+	}
+}
+
+func grpcHookMessage(m *hookv1.Message) (*text.Message, error) {
+	var messageContext json.RawMessage
+	if m.GetContext() != nil {
+		raw, err := json.Marshal(m.GetContext().AsMap())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		messageContext = raw
+	}
+
+	t := text.Info
+	if m.GetType() == "error" {
+		t = text.Error
+	}
+	return &text.Message{ID: text.ID(m.GetId()), Text: m.GetText(), Type: t, Context: messageContext}, nil
+}
+
+func applyGRPCHookIdentityUpdate(i *identity.Identity, update *hookv1.IdentityUpdate) error {
+	if update == nil {
+		return nil
+	}
+
+	for _, field := range []struct {
+		from *structpb.Struct
+		to   func(json.RawMessage)
+	}{
+		{update.GetTraits(), func(raw json.RawMessage) { i.Traits = identity.Traits(raw) }},
+		{update.GetMetadataPublic(), func(raw json.RawMessage) { i.MetadataPublic = sqlxx.NullJSONRawMessage(raw) }},
+		{update.GetMetadataAdmin(), func(raw json.RawMessage) { i.MetadataAdmin = sqlxx.NullJSONRawMessage(raw) }},
+	} {
+		if field.from == nil {
+			continue
+		}
+		raw, err := json.Marshal(field.from.AsMap())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		field.to(raw)
+	}
+	return nil
+}
+
+func jsonToStruct(raw []byte) (*structpb.Struct, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, err := structpb.NewStruct(m)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s, nil
+}
@@ -0,0 +1,150 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	hookv1 "github.com/ory/kratos/gen/hook/v1"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+)
+
+type testHookService struct {
+	hookv1.UnimplementedHookServiceServer
+
+	preLogin         func(context.Context, *hookv1.PreLoginRequest) (*hookv1.PreLoginResponse, error)
+	postRegistration func(context.Context, *hookv1.PostRegistrationRequest) (*hookv1.PostRegistrationResponse, error)
+}
+
+func (s *testHookService) PreLogin(ctx context.Context, req *hookv1.PreLoginRequest) (*hookv1.PreLoginResponse, error) {
+	return s.preLogin(ctx, req)
+}
+
+func (s *testHookService) PostRegistration(ctx context.Context, req *hookv1.PostRegistrationRequest) (*hookv1.PostRegistrationResponse, error) {
+	return s.postRegistration(ctx, req)
+}
+
+func TestGRPCHook(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeyWebhookHeaderAllowlist, []string{"User-Agent"})
+
+	svc := new(testHookService)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	hookv1.RegisterHookServiceServer(srv, svc)
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(srv.Stop)
+	t.Cleanup(func() { _ = reg.GRPCHookConnections().Close() })
+
+	newHook := func(extra string) *hook.GRPCHook {
+		return hook.NewGRPCHook(reg, json.RawMessage(fmt.Sprintf(`{"address": "%s", "insecure": true, "metadata": {"authorization": "Bearer secret"}%s}`, l.Addr().String(), extra)))
+	}
+	req := &http.Request{
+		Method: http.MethodPost,
+		Host:   "www.ory.sh",
+		Header: http.Header{"User-Agent": {"test"}, "Cookie": {"secret"}},
+		URL:    &url.URL{Path: "/self-service/login"},
+	}
+	req = req.WithContext(ctx)
+
+	t.Run("case=pre login hook receives the typed request", func(t *testing.T) {
+		f := &login.Flow{ID: x.NewUUID(), RequestURL: "https://www.ory.sh/self-service/login/browser", Type: "browser"}
+		var received *hookv1.PreLoginRequest
+		svc.preLogin = func(ctx context.Context, req *hookv1.PreLoginRequest) (*hookv1.PreLoginResponse, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			assert.Equal(t, []string{"Bearer secret"}, md.Get("authorization"))
+			received = req
+			return &hookv1.PreLoginResponse{}, nil
+		}
+
+		require.NoError(t, newHook("").ExecuteLoginPreHook(nil, req, f))
+		require.NotNil(t, received)
+		assert.Equal(t, f.ID.String(), received.GetFlow().GetId())
+		assert.Equal(t, "browser", received.GetFlow().GetType())
+		assert.Equal(t, f.RequestURL, received.GetFlow().GetRequestUrl())
+		assert.Equal(t, map[string]string{"User-Agent": "test"}, received.GetRequest().GetHeaders(), "only allowed headers are sent")
+	})
+
+	t.Run("case=validation errors only interrupt if the response is parsed", func(t *testing.T) {
+		svc.preLogin = func(context.Context, *hookv1.PreLoginRequest) (*hookv1.PreLoginResponse, error) {
+			return &hookv1.PreLoginResponse{Errors: []*hookv1.ValidationError{{
+				InstancePtr: "#/traits/email",
+				Messages:    []*hookv1.Message{{Id: 1234, Text: "Not allowed.", Type: "error"}},
+			}}}, nil
+		}
+
+		f := &login.Flow{ID: x.NewUUID()}
+		require.NoError(t, newHook("").ExecuteLoginPreHook(nil, req, f))
+
+		err := newHook(`, "response": {"parse": true}`).ExecuteLoginPreHook(nil, req, f)
+		var validationErr *schema.ValidationListError
+		require.ErrorAs(t, err, &validationErr)
+		require.Len(t, validationErr.Validations, 1)
+		assert.Equal(t, "Not allowed.", validationErr.Validations[0].Messages[0].Text)
+	})
+
+	t.Run("case=post registration hook updates the identity", func(t *testing.T) {
+		svc.postRegistration = func(_ context.Context, req *hookv1.PostRegistrationRequest) (*hookv1.PostRegistrationResponse, error) {
+			assert.Equal(t, "some@example.org", req.GetIdentity().GetTraits().AsMap()["email"])
+			traits, err := structpb.NewStruct(map[string]any{"email": "some@other-example.org"})
+			require.NoError(t, err)
+			return &hookv1.PostRegistrationResponse{Identity: &hookv1.IdentityUpdate{Traits: traits}}, nil
+		}
+
+		i := &identity.Identity{ID: x.NewUUID(), Traits: identity.Traits(`{"email":"some@example.org"}`), MetadataPublic: []byte(`{"a":"b"}`)}
+		f := &registration.Flow{ID: x.NewUUID()}
+
+		require.NoError(t, newHook("").ExecutePostRegistrationPrePersistHook(nil, req, f, i))
+		assert.JSONEq(t, `{"email":"some@example.org"}`, string(i.Traits), "the response is not applied unless parsed")
+
+		require.NoError(t, newHook(`, "response": {"parse": true}`).ExecutePostRegistrationPrePersistHook(nil, req, f, i))
+		assert.JSONEq(t, `{"email":"some@other-example.org"}`, string(i.Traits))
+		assert.JSONEq(t, `{"a":"b"}`, string(i.MetadataPublic))
+
+		require.NoError(t, newHook(`, "response": {"parse": true}`).ExecutePostRegistrationPostPersistHook(nil, req, f, &session.Session{Identity: i}), "post persist hooks are skipped if the response is parsed")
+	})
+
+	t.Run("case=maps transport errors", func(t *testing.T) {
+		svc.preLogin = func(ctx context.Context, _ *hookv1.PreLoginRequest) (*hookv1.PreLoginResponse, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		err := newHook(`, "timeout": "50ms"`).ExecuteLoginPreHook(nil, req, &login.Flow{ID: x.NewUUID()})
+		var herodotErr *herodot.DefaultError
+		require.True(t, errors.As(err, &herodotErr), "%+v", err)
+		assert.Equal(t, http.StatusGatewayTimeout, herodotErr.CodeField)
+
+		svc.preLogin = func(context.Context, *hookv1.PreLoginRequest) (*hookv1.PreLoginResponse, error) {
+			return nil, errors.New("boom")
+		}
+		err = newHook("").ExecuteLoginPreHook(nil, req, &login.Flow{ID: x.NewUUID()})
+		require.True(t, errors.As(err, &herodotErr), "%+v", err)
+		assert.Equal(t, http.StatusBadGateway, herodotErr.CodeField)
+	})
+}
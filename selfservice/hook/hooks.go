@@ -7,8 +7,13 @@ const (
 	KeySessionIssuer       = "session"
 	KeySessionDestroyer    = "revoke_active_sessions"
 	KeyWebHook             = "web_hook"
+	KeyGRPCHook            = "grpc_hook"
 	KeyAddressVerifier     = "require_verified_address"
 	KeyVerificationUI      = "show_verification_ui"
+	KeyPasskeyEnrollmentUI = "show_passkey_enrollment_ui"
+	KeyProfileCompletionUI = "show_profile_completion_ui"
 	KeyTwoStepRegistration = "two_step_registration"
 	KeyVerifier            = "verification"
+	KeySessionMetadata     = "session_metadata"
+	KeyLoginNotification   = "login_notification"
 )
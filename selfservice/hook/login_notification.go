@@ -0,0 +1,157 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/courier/template"
+	"github.com/ory/kratos/courier/template/email"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/pointerx"
+)
+
+var _ login.PostHookExecutor = new(LoginNotificationHook)
+
+const (
+	LoginNotificationNewCountry = "new_country"
+	LoginNotificationNewDevice  = "new_device"
+
+	// loginNotificationHistorySize is the number of recent sessions of the identity whose devices
+	// are considered known.
+	loginNotificationHistorySize = 100
+)
+
+type (
+	loginNotificationDependencies interface {
+		config.Provider
+		courier.Provider
+		courier.ConfigProvider
+		x.HTTPClientProvider
+		x.LoggingProvider
+		session.PersistenceProvider
+	}
+
+	// LoginNotificationHook is a post login hook that emails the verified email addresses of the
+	// identity if the login is from a country or device which was not used in earlier sessions.
+	// The email contains a link which signs the identity out of all sessions.
+	LoginNotificationHook struct {
+		d    loginNotificationDependencies
+		conf json.RawMessage
+	}
+)
+
+func NewLoginNotificationHook(d loginNotificationDependencies, c json.RawMessage) *LoginNotificationHook {
+	return &LoginNotificationHook{d: d, conf: c}
+}
+
+func (e *LoginNotificationHook) notifyOn(trigger string) bool {
+	triggers := gjson.GetBytes(e.conf, "notify_on")
+	if !triggers.Exists() {
+		return true
+	}
+	for _, t := range triggers.Array() {
+		if t.String() == trigger {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *LoginNotificationHook) ExecuteLoginPostHook(_ http.ResponseWriter, r *http.Request, _ node.UiNodeGroup, f *login.Flow, s *session.Session) error {
+	return otelx.WithSpan(r.Context(), "selfservice.hook.LoginNotificationHook.ExecuteLoginPostHook", func(ctx context.Context) error {
+		// Refreshing or upgrading a session does not sign in from a new device.
+		if !s.ID.IsNil() || s.Identity == nil || len(s.Devices) == 0 {
+			return nil
+		}
+		device := s.Devices[len(s.Devices)-1]
+
+		history, _, err := e.d.SessionPersister().ListSessionsByIdentity(ctx, s.IdentityID, nil, 1, loginNotificationHistorySize, uuid.Nil, session.Expandables{session.ExpandSessionDevices})
+		if err != nil {
+			return err
+		}
+		if len(history) == 0 {
+			// There is nothing to compare the first login of an identity with.
+			return nil
+		}
+
+		newCountry, newDevice := device.Country != nil, true
+		for _, known := range history {
+			for _, d := range known.Devices {
+				if device.Country != nil && d.Country != nil && *d.Country == *device.Country {
+					newCountry = false
+				}
+				if pointerx.Deref(d.UserAgent) == pointerx.Deref(device.UserAgent) {
+					newDevice = false
+				}
+			}
+		}
+		newCountry = newCountry && e.notifyOn(LoginNotificationNewCountry)
+		newDevice = newDevice && e.notifyOn(LoginNotificationNewDevice)
+		if !newCountry && !newDevice {
+			return nil
+		}
+
+		revokeURL, err := session.NewRevokeLink(ctx, e.d.Config(), s.IdentityID)
+		if err != nil {
+			return err
+		}
+
+		model, err := x.StructToMap(s.Identity)
+		if err != nil {
+			return err
+		}
+
+		c, err := e.d.Courier(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, address := range s.Identity.VerifiableAddresses {
+			if address.Via != identity.AddressTypeEmail || !address.Verified {
+				continue
+			}
+
+			if _, err := c.QueueEmail(ctx, email.NewLoginNotification(e.d, &email.LoginNotificationModel{
+				To:             address.Value,
+				Identity:       model,
+				RequestURL:     f.GetRequestURL(),
+				NewCountry:     newCountry,
+				NewDevice:      newDevice,
+				IPAddress:      pointerx.Deref(device.IPAddress),
+				UserAgent:      pointerx.Deref(device.UserAgent),
+				Location:       pointerx.Deref(device.Location),
+				City:           pointerx.Deref(device.City),
+				Country:        pointerx.Deref(device.Country),
+				ASN:            pointerx.Deref(device.ASN),
+				ASOrganization: pointerx.Deref(device.ASOrganization),
+				RevokeURL:      revokeURL.String(),
+				Branding:       template.Branding{Brand: f.GetBrand()},
+			})); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		e.d.Audit().
+			WithRequest(r).
+			WithField("identity_id", s.IdentityID).
+			WithField("new_country", newCountry).
+			WithField("new_device", newDevice).
+			Info("Sent login notification because the identity signed in from an unknown country or device.")
+		return nil
+	})
+}
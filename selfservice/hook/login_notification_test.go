@@ -0,0 +1,110 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/pagination/keysetpagination"
+	"github.com/ory/x/pointerx"
+)
+
+func TestLoginNotificationHook(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/stub.schema.json")
+	conf.MustSet(ctx, config.ViperKeyPublicBaseURL, "https://www.ory.sh/")
+
+	setup := func(t *testing.T, known ...session.Device) *identity.Identity {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		for _, d := range known {
+			s, err := testhelpers.NewActiveSession(httptest.NewRequest("POST", "/self-service/login", nil), reg, i, time.Now().UTC(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+			require.NoError(t, err)
+			s.Devices = []session.Device{d}
+			require.NoError(t, reg.SessionPersister().UpsertSession(ctx, s))
+		}
+
+		i.VerifiableAddresses = []identity.VerifiableAddress{
+			{Value: i.ID.String() + "@ory.sh", Via: identity.AddressTypeEmail, Verified: true},
+			{Value: i.ID.String() + "@unverified.ory.sh", Via: identity.AddressTypeEmail},
+		}
+		return i
+	}
+
+	execute := func(t *testing.T, conf string, i *identity.Identity, d session.Device) *session.Session {
+		s := &session.Session{IdentityID: i.ID, Identity: i, Devices: []session.Device{d}}
+		r := httptest.NewRequest("POST", "/self-service/login", nil)
+		require.NoError(t, hook.NewLoginNotificationHook(reg, []byte(conf)).ExecuteLoginPostHook(httptest.NewRecorder(), r, node.PasswordGroup, &login.Flow{ID: x.NewUUID()}, s))
+		return s
+	}
+
+	messages := func(t *testing.T, recipient string) []courier.Message {
+		m, _, _, err := reg.CourierPersister().ListMessages(ctx, courier.ListCourierMessagesParameters{Recipient: recipient}, []keysetpagination.Option{})
+		require.NoError(t, err)
+		return m
+	}
+
+	laptop := session.Device{IPAddress: pointerx.Ptr("203.0.113.10"), UserAgent: pointerx.Ptr("Firefox"), Country: pointerx.Ptr("DE")}
+
+	t.Run("case=does not notify on the first login", func(t *testing.T) {
+		i := setup(t)
+		s := execute(t, `{}`, i, laptop)
+		assert.True(t, s.ID.IsNil())
+		assert.Empty(t, messages(t, i.VerifiableAddresses[0].Value))
+	})
+
+	t.Run("case=does not notify on known devices", func(t *testing.T) {
+		i := setup(t, laptop)
+		execute(t, `{}`, i, laptop)
+		assert.Empty(t, messages(t, i.VerifiableAddresses[0].Value))
+	})
+
+	t.Run("case=notifies verified addresses on new devices", func(t *testing.T) {
+		i := setup(t, laptop)
+		phone := laptop
+		phone.UserAgent = pointerx.Ptr("Safari")
+		execute(t, `{}`, i, phone)
+
+		m := testhelpers.CourierExpectMessage(ctx, t, reg, i.VerifiableAddresses[0].Value, "New sign-in to your account")
+		assert.Contains(t, m.Body, "new device")
+		assert.Contains(t, m.Body, "Safari")
+		assert.Contains(t, testhelpers.CourierExpectLinkInMessage(t, m, 1), "https://www.ory.sh/sessions/revoke?token=")
+		assert.Empty(t, messages(t, i.VerifiableAddresses[1].Value))
+	})
+
+	t.Run("case=notifies on new countries", func(t *testing.T) {
+		i := setup(t, laptop)
+		abroad := laptop
+		abroad.Country = pointerx.Ptr("US")
+		execute(t, `{}`, i, abroad)
+
+		m := testhelpers.CourierExpectMessage(ctx, t, reg, i.VerifiableAddresses[0].Value, "New sign-in to your account")
+		assert.Contains(t, m.Body, "new country")
+	})
+
+	t.Run("case=only notifies on the configured triggers", func(t *testing.T) {
+		i := setup(t, laptop)
+		abroad := laptop
+		abroad.Country = pointerx.Ptr("US")
+		execute(t, `{"notify_on":["new_device"]}`, i, abroad)
+		assert.Empty(t, messages(t, i.VerifiableAddresses[0].Value))
+	})
+}
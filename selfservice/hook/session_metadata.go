@@ -0,0 +1,93 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/fetcher"
+	"github.com/ory/x/jsonnetsecure"
+	"github.com/ory/x/otelx"
+)
+
+var _ login.PostHookExecutor = new(SessionMetadataHook)
+
+type (
+	sessionMetadataDependencies interface {
+		x.HTTPClientProvider
+		jsonnetsecure.VMProvider
+		config.Provider
+	}
+
+	// SessionMetadataHook is a post login hook that sets a namespace of the session metadata to the
+	// result of a Jsonnet mapper. The mapper is called with the same context as web hook templates.
+	SessionMetadataHook struct {
+		d    sessionMetadataDependencies
+		conf json.RawMessage
+	}
+)
+
+func NewSessionMetadataHook(d sessionMetadataDependencies, c json.RawMessage) *SessionMetadataHook {
+	return &SessionMetadataHook{d: d, conf: c}
+}
+
+func (e *SessionMetadataHook) ExecuteLoginPostHook(_ http.ResponseWriter, r *http.Request, _ node.UiNodeGroup, f *login.Flow, s *session.Session) error {
+	return otelx.WithSpan(r.Context(), "selfservice.hook.SessionMetadataHook.ExecuteLoginPostHook", func(ctx context.Context) error {
+		namespace := gjson.GetBytes(e.conf, "namespace").String()
+		mapper := gjson.GetBytes(e.conf, "mapper").String()
+		if namespace == "" || mapper == "" {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReason("The session_metadata hook requires a namespace and a mapper."))
+		}
+
+		data := &templateContext{
+			Flow:           f,
+			RequestHeaders: r.Header,
+			RequestMethod:  r.Method,
+			RequestURL:     x.RequestURL(r).String(),
+			RequestCookies: cookies(r),
+			Identity:       s.Identity,
+			Session:        s,
+		}
+		removeDisallowedHeaders(data, e.d.Config().WebhookHeaderAllowlist(ctx))
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(data); err != nil {
+			return errors.WithStack(err)
+		}
+
+		jsonnet, err := fetcher.NewFetcher(fetcher.WithClient(e.d.HTTPClient(ctx)), fetcher.WithCache(jsonnetCache, 60*time.Minute)).FetchContext(ctx, mapper)
+		if err != nil {
+			return err
+		}
+
+		vm, err := e.d.JsonnetVM(ctx)
+		if err != nil {
+			return err
+		}
+		vm.TLACode("ctx", buf.String())
+
+		evaluated, err := vm.EvaluateAnonymousSnippet(mapper, jsonnet.String())
+		if err != nil {
+			return errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithDebug(err.Error()).WithReasonf("Unable to execute session metadata JsonNet."))
+		}
+
+		return s.SetMetadata(map[string]json.RawMessage{namespace: json.RawMessage(strings.TrimSpace(evaluated))})
+	})
+}
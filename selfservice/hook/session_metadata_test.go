@@ -0,0 +1,64 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+)
+
+func TestSessionMetadataHook(t *testing.T) {
+	_, reg := internal.NewFastRegistryWithMocks(t)
+
+	newHook := func(namespace, mapper string) *hook.SessionMetadataHook {
+		conf, err := json.Marshal(map[string]string{
+			"namespace": namespace,
+			"mapper":    "base64://" + base64.StdEncoding.EncodeToString([]byte(mapper)),
+		})
+		require.NoError(t, err)
+		return hook.NewSessionMetadataHook(reg, conf)
+	}
+
+	newSession := func() *session.Session {
+		i := &identity.Identity{ID: x.NewUUID(), Traits: identity.Traits(`{"tenant":"acme"}`)}
+		return &session.Session{ID: x.NewUUID(), IdentityID: i.ID, Identity: i}
+	}
+
+	execute := func(t *testing.T, h *hook.SessionMetadataHook, s *session.Session) error {
+		r := httptest.NewRequest("POST", "/self-service/login", nil)
+		return h.ExecuteLoginPostHook(httptest.NewRecorder(), r, node.PasswordGroup, &login.Flow{ID: x.NewUUID()}, s)
+	}
+
+	t.Run("case=sets the namespace to the mapper result", func(t *testing.T) {
+		s := newSession()
+		s.Metadata = []byte(`{"device":"laptop"}`)
+		require.NoError(t, execute(t, newHook("tenant", `function(ctx) { id: ctx.identity.traits.tenant, session_id: ctx.session.id }`), s))
+		assert.JSONEq(t, `{"device":"laptop","tenant":{"id":"acme","session_id":"`+s.ID.String()+`"}}`, string(s.Metadata))
+	})
+
+	t.Run("case=removes the namespace if the mapper returns null", func(t *testing.T) {
+		s := newSession()
+		s.Metadata = []byte(`{"tenant":{"id":"old"}}`)
+		require.NoError(t, execute(t, newHook("tenant", `function(ctx) null`), s))
+		assert.Empty(t, s.Metadata)
+	})
+
+	t.Run("case=fails on invalid configurations and mappers", func(t *testing.T) {
+		require.Error(t, execute(t, newHook("", `function(ctx) {}`), newSession()))
+		require.Error(t, execute(t, newHook("tenant", `function(ctx) error "nope"`), newSession()))
+	})
+}
@@ -0,0 +1,127 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlxx"
+)
+
+var _ login.PostHookExecutor = new(ShowPasskeyEnrollmentUIHook)
+
+const defaultPasskeyEnrollmentSnooze = 7 * 24 * time.Hour
+
+type (
+	showPasskeyEnrollmentUIDependencies interface {
+		config.Provider
+		identity.PrivilegedPoolProvider
+		settings.HandlerProvider
+		settings.FlowPersistenceProvider
+	}
+
+	// ShowPasskeyEnrollmentUIHook is a post login hook that asks identities without a passkey to enroll one.
+	// It adds a settings flow, which only shows the passkey settings, as a `show_settings_ui` continue_with
+	// item. Identities are asked at most once per snooze duration.
+	ShowPasskeyEnrollmentUIHook struct {
+		d      showPasskeyEnrollmentUIDependencies
+		snooze time.Duration
+	}
+)
+
+func NewShowPasskeyEnrollmentUIHook(d showPasskeyEnrollmentUIDependencies, c json.RawMessage) *ShowPasskeyEnrollmentUIHook {
+	snooze, err := time.ParseDuration(gjson.GetBytes(c, "snooze").String())
+	if err != nil || snooze <= 0 {
+		snooze = defaultPasskeyEnrollmentSnooze
+	}
+	return &ShowPasskeyEnrollmentUIHook{d: d, snooze: snooze}
+}
+
+// ExecuteLoginPostHook creates the passkey enrollment settings flow if the identity has no passkey and
+// was not asked recently. Browser flows which are not submitted as JSON do not receive continue_with
+// items, so this hook does nothing for them.
+func (e *ShowPasskeyEnrollmentUIHook) ExecuteLoginPostHook(w http.ResponseWriter, r *http.Request, _ node.UiNodeGroup, f *login.Flow, s *session.Session) error {
+	return otelx.WithSpan(r.Context(), "selfservice.hook.ShowPasskeyEnrollmentUIHook.ExecuteLoginPostHook", func(ctx context.Context) error {
+		if !e.d.Config().SelfServiceStrategy(ctx, identity.CredentialsTypePasskey.String()).Enabled {
+			return nil
+		}
+		if f.Refresh || s.PasswordResetRequired || (f.Type == flow.TypeBrowser && !x.IsJSONRequest(r)) {
+			return nil
+		}
+
+		i, err := e.d.PrivilegedIdentityPool().GetIdentityConfidential(ctx, s.IdentityID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		if i.PasskeyEnrollmentSnoozedUntil != nil && now.Before(time.Time(*i.PasskeyEnrollmentSnoozedUntil)) {
+			return nil
+		}
+		if ok, err := hasPasskey(i); err != nil || ok {
+			return err
+		}
+
+		sf, err := e.d.SettingsHandler().NewFlow(ctx, w, r.WithContext(ctx), i, f.Type)
+		if err != nil {
+			return err
+		}
+
+		var nodes node.Nodes
+		for _, n := range sf.UI.Nodes {
+			if n.Group == node.DefaultGroup || n.Group == node.PasskeyGroup {
+				nodes = append(nodes, n)
+			}
+		}
+		sf.UI.Nodes = nodes
+		sf.UI.Messages.Add(text.NewInfoSelfServiceSettingsPasskeyEnrollment())
+		sf.Active = sqlxx.NullString(identity.CredentialsTypePasskey)
+		if err := e.d.SettingsFlowPersister().UpdateSettingsFlow(ctx, sf); err != nil {
+			return err
+		}
+
+		snoozedUntil := sqlxx.NullTime(now.Add(e.snooze))
+		i.PasskeyEnrollmentSnoozedUntil = &snoozedUntil
+		if err := e.d.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "passkey_enrollment_snoozed_until"); err != nil {
+			return err
+		}
+
+		var redirectTo string
+		if f.Type == flow.TypeBrowser {
+			redirectTo = sf.AppendTo(e.d.Config().SelfServiceFlowSettingsUI(ctx)).String()
+		}
+		f.AddContinueWith(flow.NewContinueWithSettingsUI(sf, redirectTo))
+		return nil
+	})
+}
+
+func hasPasskey(i *identity.Identity) (bool, error) {
+	c, ok := i.GetCredentials(identity.CredentialsTypePasskey)
+	if !ok || len(c.Config) == 0 {
+		return false, nil
+	}
+
+	var conf identity.CredentialsWebAuthnConfig
+	if err := json.Unmarshal(c.Config, &conf); err != nil {
+		return false, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode passkey credentials.").WithDebug(err.Error()))
+	}
+	return len(conf.Credentials) > 0, nil
+}
@@ -0,0 +1,129 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/x/sqlxx"
+)
+
+func TestShowPasskeyEnrollmentUIHook(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/stub.schema.json")
+	conf.MustSet(ctx, config.ViperKeySelfServiceSettingsURL, "https://www.ory.sh/settings")
+
+	key := config.ViperKeySelfServiceStrategyConfig + "." + string(identity.CredentialsTypePasskey)
+	conf.MustSet(ctx, key+".enabled", true)
+	conf.MustSet(ctx, key+".config.rp.display_name", "Ory Corp")
+	conf.MustSet(ctx, key+".config.rp.id", "localhost")
+	conf.MustSet(ctx, key+".config.rp.origins", []string{"http://localhost:4455"})
+
+	h := hook.NewShowPasskeyEnrollmentUIHook(reg, json.RawMessage(`{"snooze":"24h"}`))
+
+	newIdentity := func(t *testing.T) *identity.Identity {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		return i
+	}
+
+	execute := func(t *testing.T, i *identity.Identity, ft flow.Type) *login.Flow {
+		r := httptest.NewRequest("POST", "/self-service/login", nil)
+		r.Header.Set("Accept", "application/json")
+		f := &login.Flow{Type: ft}
+		require.NoError(t, h.ExecuteLoginPostHook(httptest.NewRecorder(), r, node.PasswordGroup, f, &session.Session{IdentityID: i.ID}))
+		return f
+	}
+
+	settingsFlow := func(t *testing.T, f *login.Flow) *flow.ContinueWithSettingsUI {
+		require.Len(t, f.ContinueWith(), 1)
+		item, ok := f.ContinueWith()[0].(*flow.ContinueWithSettingsUI)
+		require.True(t, ok, "%T", f.ContinueWith()[0])
+		return item
+	}
+
+	t.Run("case=asks identities without passkey to enroll one", func(t *testing.T) {
+		i := newIdentity(t)
+
+		item := settingsFlow(t, execute(t, i, flow.TypeAPI))
+		assert.Empty(t, item.Flow.URL)
+
+		sf, err := reg.SettingsFlowPersister().GetSettingsFlow(ctx, item.Flow.ID)
+		require.NoError(t, err)
+		assert.EqualValues(t, identity.CredentialsTypePasskey, sf.Active)
+		require.NotEmpty(t, sf.UI.Nodes)
+		for _, n := range sf.UI.Nodes {
+			assert.Contains(t, []node.UiNodeGroup{node.DefaultGroup, node.PasskeyGroup}, n.Group, "%+v", n)
+		}
+		require.Len(t, sf.UI.Messages, 1)
+		assert.Equal(t, text.InfoSelfServiceSettingsPasskeyEnrollment, sf.UI.Messages[0].ID)
+
+		t.Run("case=does not ask again while snoozed", func(t *testing.T) {
+			assert.Empty(t, execute(t, i, flow.TypeAPI).ContinueWith())
+
+			actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+			require.NoError(t, err)
+			require.NotNil(t, actual.PasskeyEnrollmentSnoozedUntil)
+			assert.WithinDuration(t, time.Now().Add(24*time.Hour), time.Time(*actual.PasskeyEnrollmentSnoozedUntil), time.Minute)
+		})
+
+		t.Run("case=asks again after the snooze expired", func(t *testing.T) {
+			past := sqlxx.NullTime(time.Now().Add(-time.Minute))
+			i.PasskeyEnrollmentSnoozedUntil = &past
+			require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "passkey_enrollment_snoozed_until"))
+
+			settingsFlow(t, execute(t, i, flow.TypeAPI))
+		})
+	})
+
+	t.Run("case=includes the settings UI URL for browser flows", func(t *testing.T) {
+		item := settingsFlow(t, execute(t, newIdentity(t), flow.TypeBrowser))
+		assert.Equal(t, "https://www.ory.sh/settings?flow="+item.Flow.ID.String(), item.Flow.URL)
+	})
+
+	t.Run("case=does not ask identities with a passkey", func(t *testing.T) {
+		i := newIdentity(t)
+		c, err := json.Marshal(identity.CredentialsWebAuthnConfig{
+			Credentials: identity.CredentialsWebAuthn{{ID: []byte("credential")}},
+			UserHandle:  []byte("handle"),
+		})
+		require.NoError(t, err)
+		i.SetCredentials(identity.CredentialsTypePasskey, identity.Credentials{
+			Type:        identity.CredentialsTypePasskey,
+			Identifiers: []string{"handle"},
+			Config:      c,
+		})
+		require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentity(ctx, i))
+
+		assert.Empty(t, execute(t, i, flow.TypeAPI).ContinueWith())
+	})
+
+	t.Run("case=does nothing if passkeys are disabled", func(t *testing.T) {
+		conf.MustSet(ctx, key+".enabled", false)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, key+".enabled", true)
+		})
+
+		assert.Empty(t, execute(t, newIdentity(t), flow.TypeAPI).ContinueWith())
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/x/otelx"
+)
+
+var _ login.PostHookExecutor = new(ShowPasswordChangeUIHook)
+
+type (
+	showPasswordChangeUIDependencies interface {
+		config.Provider
+		identity.PrivilegedPoolProvider
+		settings.HandlerProvider
+	}
+
+	ShowPasswordChangeUIProvider interface {
+		HookShowPasswordChangeUI() *ShowPasswordChangeUIHook
+	}
+
+	// ShowPasswordChangeUIHook is a post login hook that tells API clients to continue in the settings
+	// flow if the identity must set a new password. Browser clients are redirected by the login hook
+	// executor instead.
+	ShowPasswordChangeUIHook struct {
+		d showPasswordChangeUIDependencies
+	}
+)
+
+func NewShowPasswordChangeUIHook(d showPasswordChangeUIDependencies) *ShowPasswordChangeUIHook {
+	return &ShowPasswordChangeUIHook{d: d}
+}
+
+// ExecuteLoginPostHook creates a settings flow and adds it as a `show_settings_ui` continue_with item if the
+// session was restricted to setting a new password. If the flow is not an API flow, this hook does nothing.
+func (e *ShowPasswordChangeUIHook) ExecuteLoginPostHook(w http.ResponseWriter, r *http.Request, _ node.UiNodeGroup, f *login.Flow, s *session.Session) error {
+	return otelx.WithSpan(r.Context(), "selfservice.hook.ShowPasswordChangeUIHook.ExecuteLoginPostHook", func(ctx context.Context) error {
+		if f.Type != flow.TypeAPI || !s.PasswordResetRequired {
+			return nil
+		}
+
+		i, err := e.d.PrivilegedIdentityPool().GetIdentityConfidential(ctx, s.IdentityID)
+		if err != nil {
+			return err
+		}
+
+		sf, err := e.d.SettingsHandler().NewFlow(ctx, w, r.WithContext(ctx), i, f.Type)
+		if err != nil {
+			return err
+		}
+
+		f.AddContinueWith(flow.NewContinueWithSettingsUI(sf, ""))
+		return nil
+	})
+}
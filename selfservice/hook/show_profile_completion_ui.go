@@ -0,0 +1,117 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlxx"
+)
+
+var _ login.PostHookExecutor = new(ShowProfileCompletionUIHook)
+
+type (
+	showProfileCompletionUIDependencies interface {
+		config.Provider
+		identity.PrivilegedPoolProvider
+		identity.ValidationProvider
+		settings.HandlerProvider
+		settings.FlowPersistenceProvider
+	}
+
+	// ShowProfileCompletionUIHook is a post login hook that asks identities to complete their profile. This is
+	// the case if their traits no longer validate against their identity schema, for example after the schema
+	// added a required trait, or if one of the configured optional traits is not set. It adds a settings flow,
+	// which only shows the profile settings, as a `show_settings_ui` continue_with item.
+	ShowProfileCompletionUIHook struct {
+		d      showProfileCompletionUIDependencies
+		traits []string
+	}
+)
+
+func NewShowProfileCompletionUIHook(d showProfileCompletionUIDependencies, c json.RawMessage) *ShowProfileCompletionUIHook {
+	var traits []string
+	for _, t := range gjson.GetBytes(c, "traits").Array() {
+		traits = append(traits, t.String())
+	}
+	return &ShowProfileCompletionUIHook{d: d, traits: traits}
+}
+
+// ExecuteLoginPostHook creates the profile completion settings flow if the profile of the identity is
+// incomplete. Browser flows which are not submitted as JSON do not receive continue_with items, so this
+// hook does nothing for them.
+func (e *ShowProfileCompletionUIHook) ExecuteLoginPostHook(w http.ResponseWriter, r *http.Request, _ node.UiNodeGroup, f *login.Flow, s *session.Session) error {
+	return otelx.WithSpan(r.Context(), "selfservice.hook.ShowProfileCompletionUIHook.ExecuteLoginPostHook", func(ctx context.Context) error {
+		if !e.d.Config().SelfServiceStrategy(ctx, settings.StrategyProfile).Enabled {
+			return nil
+		}
+		if f.Refresh || s.PasswordResetRequired || (f.Type == flow.TypeBrowser && !x.IsJSONRequest(r)) {
+			return nil
+		}
+
+		i, err := e.d.PrivilegedIdentityPool().GetIdentity(ctx, s.IdentityID, identity.ExpandNothing)
+		if err != nil {
+			return err
+		}
+
+		// Without extension runners, validating does not modify the identity.
+		invalid := e.d.IdentityValidator().ValidateWithRunner(ctx, i)
+		if invalid == nil && !e.missingTraits(i) {
+			return nil
+		}
+
+		sf, err := e.d.SettingsHandler().NewFlow(ctx, w, r.WithContext(ctx), i, f.Type)
+		if err != nil {
+			return err
+		}
+
+		var nodes node.Nodes
+		for _, n := range sf.UI.Nodes {
+			if n.Group == node.DefaultGroup || n.Group == node.ProfileGroup {
+				nodes = append(nodes, n)
+			}
+		}
+		sf.UI.Nodes = nodes
+		sf.UI.Messages.Add(text.NewInfoSelfServiceSettingsProfileCompletion())
+		if invalid != nil {
+			if err := sf.UI.ParseError(node.ProfileGroup, invalid); err != nil {
+				return err
+			}
+		}
+		sf.Active = sqlxx.NullString(settings.StrategyProfile)
+		if err := e.d.SettingsFlowPersister().UpdateSettingsFlow(ctx, sf); err != nil {
+			return err
+		}
+
+		var redirectTo string
+		if f.Type == flow.TypeBrowser {
+			redirectTo = sf.AppendTo(e.d.Config().SelfServiceFlowSettingsUI(ctx)).String()
+		}
+		f.AddContinueWith(flow.NewContinueWithSettingsUI(sf, redirectTo))
+		return nil
+	})
+}
+
+func (e *ShowProfileCompletionUIHook) missingTraits(i *identity.Identity) bool {
+	for _, t := range e.traits {
+		if !gjson.GetBytes(i.Traits, t).Exists() {
+			return true
+		}
+	}
+	return false
+}
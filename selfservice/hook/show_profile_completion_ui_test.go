@@ -0,0 +1,104 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+)
+
+func TestShowProfileCompletionUIHook(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/profile_completion.schema.json")
+	testhelpers.StrategyEnable(t, conf, settings.StrategyProfile, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceSettingsURL, "https://www.ory.sh/settings")
+
+	h := hook.NewShowProfileCompletionUIHook(reg, json.RawMessage(`{"traits":["company"]}`))
+
+	newIdentity := func(t *testing.T, traits string) *identity.Identity {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"email":"foo@ory.sh","name":"Foo","company":"Ory"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+		// Updating the column directly skips the validation, as if the schema changed after the identity was created.
+		i.Traits = identity.Traits(traits)
+		require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "traits"))
+		return i
+	}
+
+	execute := func(t *testing.T, i *identity.Identity, ft flow.Type) *login.Flow {
+		r := httptest.NewRequest("POST", "/self-service/login", nil)
+		r.Header.Set("Accept", "application/json")
+		f := &login.Flow{Type: ft}
+		require.NoError(t, h.ExecuteLoginPostHook(httptest.NewRecorder(), r, node.PasswordGroup, f, &session.Session{IdentityID: i.ID}))
+		return f
+	}
+
+	settingsFlow := func(t *testing.T, f *login.Flow) *settings.Flow {
+		require.Len(t, f.ContinueWith(), 1)
+		item, ok := f.ContinueWith()[0].(*flow.ContinueWithSettingsUI)
+		require.True(t, ok, "%T", f.ContinueWith()[0])
+
+		sf, err := reg.SettingsFlowPersister().GetSettingsFlow(ctx, item.Flow.ID)
+		require.NoError(t, err)
+		assert.EqualValues(t, settings.StrategyProfile, sf.Active)
+		for _, n := range sf.UI.Nodes {
+			assert.Contains(t, []node.UiNodeGroup{node.DefaultGroup, node.ProfileGroup}, n.Group, "%+v", n)
+		}
+		require.NotEmpty(t, sf.UI.Messages)
+		assert.Equal(t, text.InfoSelfServiceSettingsProfileCompletion, sf.UI.Messages[0].ID)
+		return sf
+	}
+
+	t.Run("case=asks identities with missing required traits to complete their profile", func(t *testing.T) {
+		sf := settingsFlow(t, execute(t, newIdentity(t, `{"email":"foo@ory.sh","company":"Ory"}`), flow.TypeAPI))
+
+		n := sf.UI.Nodes.Find("traits.name")
+		require.NotNil(t, n)
+		require.Len(t, n.Messages, 1)
+		assert.Equal(t, text.ErrorValidationRequired, n.Messages[0].ID)
+		assert.Equal(t, "foo@ory.sh", sf.UI.Nodes.Find("traits.email").Attributes.GetValue())
+	})
+
+	t.Run("case=asks identities with missing optional traits to complete their profile", func(t *testing.T) {
+		sf := settingsFlow(t, execute(t, newIdentity(t, `{"email":"foo@ory.sh","name":"Foo"}`), flow.TypeAPI))
+		assert.NotNil(t, sf.UI.Nodes.Find("traits.company"))
+	})
+
+	t.Run("case=includes the settings UI URL for browser flows", func(t *testing.T) {
+		f := execute(t, newIdentity(t, `{"email":"foo@ory.sh"}`), flow.TypeBrowser)
+		sf := settingsFlow(t, f)
+		assert.Equal(t, "https://www.ory.sh/settings?flow="+sf.ID.String(), f.ContinueWith()[0].(*flow.ContinueWithSettingsUI).Flow.URL)
+	})
+
+	t.Run("case=does not ask identities with a complete profile", func(t *testing.T) {
+		assert.Empty(t, execute(t, newIdentity(t, `{"email":"foo@ory.sh","name":"Foo","company":"Ory"}`), flow.TypeAPI).ContinueWith())
+	})
+
+	t.Run("case=does nothing for browser flows not submitted as JSON", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/self-service/login", nil)
+		f := &login.Flow{Type: flow.TypeBrowser}
+		i := newIdentity(t, `{"email":"foo@ory.sh"}`)
+		require.NoError(t, h.ExecuteLoginPostHook(httptest.NewRecorder(), r, node.PasswordGroup, f, &session.Session{IdentityID: i.ID}))
+		assert.Empty(t, f.ContinueWith())
+	})
+}
@@ -32,11 +32,11 @@ func NewTwoStepRegistration(d twoStepRegistrationDeps) *TwoStepRegistration {
 	return &TwoStepRegistration{d: d}
 }
 
-func (e *TwoStepRegistration) ExecuteRegistrationPreHook(_ http.ResponseWriter, _ *http.Request, regFlow *registration.Flow) (err error) {
+func (e *TwoStepRegistration) ExecuteRegistrationPreHook(_ http.ResponseWriter, r *http.Request, regFlow *registration.Flow) (err error) {
 	stepOneNodes := make([]*node.Node, 0, len(regFlow.UI.Nodes))
 	stepTwoNodes := make([]*node.Node, 0, len(regFlow.UI.Nodes))
 	for _, n := range regFlow.UI.Nodes {
-		if n.Group == node.ProfileGroup || n.Group == node.OpenIDConnectGroup || n.Group == node.DefaultGroup || n.Group == node.CaptchaGroup {
+		if n.Group == node.ProfileGroup || n.Group == node.OpenIDConnectGroup || n.Group == node.DefaultGroup || n.Group == node.CaptchaGroup || n.Group == node.IdentitySchemaGroup {
 			stepOneNodes = append(stepOneNodes, n)
 		} else {
 			stepTwoNodes = append(stepTwoNodes, n)
@@ -54,5 +54,16 @@ func (e *TwoStepRegistration) ExecuteRegistrationPreHook(_ http.ResponseWriter,
 		return errors.WithStack(err)
 	}
 
+	steps, err := e.d.Config().SelfServiceFlowRegistrationSteps(r.Context())
+	if err != nil {
+		return err
+	}
+	if len(steps) > 0 {
+		regFlow.UI.Nodes = registration.StepNodes(stepOneNodes, steps, 0)
+		if err := regFlow.SetStep(steps, steps[0].ID); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
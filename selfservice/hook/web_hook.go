@@ -11,12 +11,14 @@ import (
 	"maps"
 	"net/http"
 	"net/textproto"
+	"strconv"
 	"time"
 
 	"github.com/dgraph-io/ristretto"
 	"github.com/gofrs/uuid"
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tidwall/gjson"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -25,6 +27,7 @@ import (
 	grpccodes "google.golang.org/grpc/codes"
 
 	"github.com/ory/herodot"
+	"github.com/ory/kratos/cipher"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/request"
@@ -35,15 +38,29 @@ import (
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/flow/settings"
 	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
 	"github.com/ory/kratos/x/events"
 	"github.com/ory/x/jsonnetsecure"
+	"github.com/ory/x/jsonx"
 	"github.com/ory/x/otelx"
 )
 
+var webhookErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kratos_selfservice_webhook_errors_total",
+	Help: "Number of failed web hook requests, labelled with the ID of the web hook and whether the error was ignored.",
+}, []string{"webhook_id", "ignored"})
+
+// WebHookCollectors returns the Prometheus collectors of the web hook. They are registered by the
+// registry's metrics setup.
+func WebHookCollectors() []prometheus.Collector {
+	return []prometheus.Collector{webhookErrors}
+}
+
 var _ interface {
 	login.PreHookExecutor
 	login.PostHookExecutor
@@ -76,6 +93,8 @@ type (
 		x.TracingProvider
 		jsonnetsecure.VMProvider
 		config.Provider
+		cipher.Provider
+		deadletter.PersistenceProvider
 	}
 
 	templateContext struct {
@@ -88,6 +107,16 @@ type (
 		Session        *session.Session   `json:"session,omitempty"`
 	}
 
+	// legacyTemplateContext is passed to the Jsonnet template if the
+	// `compatibility.legacy_webhook_payload` switch is enabled.
+	legacyTemplateContext struct {
+		Flow           flow.Flow          `json:"flow"`
+		RequestHeaders http.Header        `json:"request_headers"`
+		RequestMethod  string             `json:"request_method"`
+		RequestURL     string             `json:"request_url"`
+		Identity       *identity.Identity `json:"identity,omitempty"`
+	}
+
 	WebHook struct {
 		deps webHookDependencies
 		conf json.RawMessage
@@ -108,8 +137,55 @@ type (
 	rawHookResponse struct {
 		Messages []errorMessage `json:"messages"`
 	}
+
+	// uiPatch changes the UI of the flow the web hook was called for.
+	uiPatch struct {
+		// Messages are added to the messages of the flow.
+		Messages []detailedMessage `json:"messages"`
+
+		// NodeMessages are added to the messages of the node with the given ID, e.g. `traits.email`.
+		NodeMessages map[string][]detailedMessage `json:"node_messages"`
+
+		// AddNodes are added to the flow, or replace the node with the same ID.
+		AddNodes node.Nodes `json:"add_nodes"`
+
+		// RemoveNodes are the IDs of nodes which are removed from the flow.
+		RemoveNodes []string `json:"remove_nodes"`
+	}
 )
 
+func (m detailedMessage) toMessage() *text.Message {
+	msgType := text.Info
+	if m.Type == "error" {
+		msgType = text.Error
+	}
+	return &text.Message{
+		ID:      text.ID(m.ID),
+		Text:    m.Text,
+		Type:    msgType,
+		Context: m.Context,
+	}
+}
+
+func (p *uiPatch) apply(ui *container.Container) {
+	for _, m := range p.Messages {
+		ui.Messages.Add(m.toMessage())
+	}
+	ui.Nodes.Remove(p.RemoveNodes...)
+	for _, n := range p.AddNodes {
+		ui.Nodes.Upsert(n)
+	}
+	for id, messages := range p.NodeMessages {
+		n := ui.Nodes.Find(id)
+		if n == nil {
+			continue
+		}
+		for _, m := range messages {
+			n.Messages.Add(m.toMessage())
+		}
+	}
+}
+
 func cookies(req *http.Request) map[string]string {
 	cookies := make(map[string]string)
 	for _, c := range req.Cookies() {
@@ -295,6 +371,7 @@ func (e *WebHook) ExecuteSettingsPrePersistHook(_ http.ResponseWriter, req *http
 }
 
 func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
+	ctx = config.WithHTTPClientIntegration(ctx, config.HTTPClientIntegrationWebhooks)
 	var (
 		httpClient     = e.deps.HTTPClient(ctx)
 		ignoreResponse = gjson.GetBytes(e.conf, "response.ignore").Bool()
@@ -302,6 +379,7 @@ func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
 		parseResponse  = gjson.GetBytes(e.conf, "response.parse").Bool()
 		emitEvent      = gjson.GetBytes(e.conf, "emit_analytics_event").Bool() || !gjson.GetBytes(e.conf, "emit_analytics_event").Exists() // default true
 		webhookID      = gjson.GetBytes(e.conf, "id").Str
+		breakerConf    = parseCircuitBreakerConfig(e.conf)
 		// The trigger ID is a random ID. It can be used to correlate webhook requests across retries.
 		triggerID = x.NewUUID()
 		tracer    = trace.SpanFromContext(ctx).TracerProvider().Tracer("kratos-webhooks")
@@ -309,6 +387,16 @@ func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
 	if ignoreResponse && (parseResponse || canInterrupt) {
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("A webhook is configured to ignore the response but also to parse the response. This is not possible."))
 	}
+	configureWebHookHTTPClient(httpClient, e.conf)
+	if err := configureWebHookTLS(ctx, httpClient, e.conf, e.deps.Config()); err != nil {
+		return err
+	}
+
+	breakerID := webhookID
+	if breakerID == "" {
+		breakerID = gjson.GetBytes(e.conf, "url").String()
+	}
+	breaker := hookCircuitBreakers.get(breakerID)
 
 	makeRequest := func() (finalErr error) {
 		if ignoreResponse {
@@ -333,8 +421,11 @@ func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
 				"span_id":  spanID.String(),
 			}).WithField("duration", time.Since(startTime))
 			if finalErr != nil {
-				if emitEvent && !errors.Is(finalErr, context.Canceled) {
-					span.AddEvent(events.NewWebhookFailed(ctx, finalErr, triggerID, webhookID))
+				if !errors.Is(finalErr, context.Canceled) {
+					webhookErrors.WithLabelValues(webhookID, strconv.FormatBool(ignoreResponse)).Inc()
+					if emitEvent {
+						span.AddEvent(events.NewWebhookFailed(ctx, finalErr, triggerID, webhookID))
+					}
 				}
 				if ignoreResponse {
 					logger.WithError(finalErr).Warning("Webhook request failed but the error was ignored because the configuration indicated that the upstream response should be ignored")
@@ -363,7 +454,18 @@ func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
 
 		removeDisallowedHeaders(data, e.deps.Config().WebhookHeaderAllowlist(ctx))
 
-		req, err := builder.BuildRequest(ctx, data)
+		var body any = data
+		if e.deps.Config().UseCompatibilitySwitch(ctx, config.CompatibilityLegacyWebhookPayload) {
+			body = &legacyTemplateContext{
+				Flow:           data.Flow,
+				RequestHeaders: data.RequestHeaders,
+				RequestMethod:  data.RequestMethod,
+				RequestURL:     data.RequestURL,
+				Identity:       data.Identity,
+			}
+		}
+
+		req, err := builder.BuildRequest(ctx, body)
 		if errors.Is(err, request.ErrCancel) {
 			span.SetAttributes(attribute.Bool("webhook.jsonnet.canceled", true))
 			return nil
@@ -381,8 +483,24 @@ func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
 		e.deps.Logger().WithRequest(req.Request).Info("Dispatching webhook")
 
 		req = req.WithContext(ctx)
+		if ignoreResponse {
+			// Nobody waits for asynchronous web hooks, so failed requests are kept to be replayed.
+			defer func() {
+				if finalErr != nil && !errors.Is(finalErr, context.Canceled) {
+					e.recordFailedEvent(ctx, webhookID, req, finalErr)
+				}
+			}()
+		}
+		// Propagate the trace context and baggage so that the webhook's receiver can join the trace.
+		x.InjectTraceContext(ctx, req.Header)
+
+		if !breaker.allow(breakerConf, e.deps.Logger()) {
+			span.SetAttributes(attribute.Bool("webhook.circuit_breaker.open", true))
+			return errors.WithStack(ErrCircuitOpen)
+		}
 
 		resp, err := httpClient.Do(req)
+		breaker.record(breakerConf, err == nil && resp.StatusCode < http.StatusInternalServerError, e.deps.Logger())
 		if err != nil {
 			if isTimeoutError(err) {
 				return herodot.DefaultError{
@@ -402,7 +520,7 @@ func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
 		if resp.StatusCode >= http.StatusBadRequest {
 			span.SetStatus(codes.Error, "HTTP status code >= 400")
 			if canInterrupt || parseResponse {
-				if err := parseWebhookResponse(resp, data.Identity); err != nil {
+				if err := parseWebhookResponse(resp, data.Identity, data.Flow, data.writableSession()); err != nil {
 					return err
 				}
 			}
@@ -416,7 +534,7 @@ func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
 		}
 
 		if parseResponse {
-			return parseWebhookResponse(resp, data.Identity)
+			return parseWebhookResponse(resp, data.Identity, data.Flow, data.writableSession())
 		}
 		return nil
 	}
@@ -431,6 +549,36 @@ func (e *WebHook) execute(ctx context.Context, data *templateContext) error {
 	return nil
 }
 
+func (e *WebHook) recordFailedEvent(ctx context.Context, webhookID string, req *retryablehttp.Request, cause error) {
+	logger := e.deps.Logger().WithField("webhook_id", webhookID)
+
+	body, err := req.BodyBytes()
+	if err != nil {
+		logger.WithError(err).Error("Unable to read the body of the failed web hook request.")
+		return
+	}
+
+	event, err := deadletter.NewEvent(ctx, e.deps, webhookID, req.Method, req.URL.String(), req.Header.Clone(), body, cause)
+	if err == nil {
+		err = e.deps.FailedWebhookEventPersister().CreateFailedWebhookEvent(ctx, event)
+	}
+	if err != nil {
+		logger.WithError(err).Error("Unable to record the failed web hook request.")
+		return
+	}
+
+	logger.WithField("failed_webhook_event_id", event.ID).Info("Recorded the failed web hook request so that it can be replayed.")
+}
+
+// writableSession returns the session whose metadata the web hook response may set. Only login
+// sessions are writable, because they are persisted after the post login hooks ran.
+func (t *templateContext) writableSession() *session.Session {
+	if _, ok := t.Flow.(*login.Flow); ok {
+		return t.Session
+	}
+	return nil
+}
+
 func removeDisallowedHeaders(data *templateContext, headerAllowlist []string) {
 	allowedMap := make(map[string]struct{})
 	for _, header := range headerAllowlist {
@@ -445,7 +593,15 @@ func removeDisallowedHeaders(data *templateContext, headerAllowlist []string) {
 	data.RequestHeaders = headers
 }
 
-func parseWebhookResponse(resp *http.Response, id *identity.Identity) (err error) {
+// parseWebhookResponse applies the response of a web hook. A `200 OK` response may
+//
+//   - replace fields of the identity using `identity`,
+//   - patch the identity traits using a JSON Patch in `traits_patch`, and
+//   - change the UI of the flow using `ui`, see uiPatch, and
+//   - set namespaces of the session metadata using `session_metadata` if the session is writable.
+//
+// Responses with a status code of 400 or above are converted to validation errors.
+func parseWebhookResponse(resp *http.Response, id *identity.Identity, f flow.Flow, s *session.Session) (err error) {
 	if resp == nil {
 		return errors.Errorf("empty response provided from the webhook")
 	}
@@ -453,46 +609,50 @@ func parseWebhookResponse(resp *http.Response, id *identity.Identity) (err error
 	if resp.StatusCode == http.StatusOK {
 		type localIdentity identity.Identity
 		var hookResponse struct {
-			Identity *localIdentity `json:"identity"`
+			Identity    *localIdentity  `json:"identity"`
+			TraitsPatch json.RawMessage `json:"traits_patch"`
+			UI          *uiPatch        `json:"ui"`
+
+			SessionMetadata map[string]json.RawMessage `json:"session_metadata"`
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&hookResponse); err != nil {
 			return errors.Wrap(err, "webhook response could not be unmarshalled properly from JSON")
 		}
 
-		if hookResponse.Identity == nil {
-			return nil
-		}
-
-		if len(hookResponse.Identity.Traits) > 0 {
-			id.Traits = hookResponse.Identity.Traits
-		}
-
-		if len(hookResponse.Identity.SchemaID) > 0 {
-			id.SchemaID = hookResponse.Identity.SchemaID
+		if hookResponse.UI != nil && f != nil && f.GetUI() != nil {
+			hookResponse.UI.apply(f.GetUI())
 		}
 
-		if len(hookResponse.Identity.State) > 0 {
-			id.State = hookResponse.Identity.State
-		}
-
-		if len(hookResponse.Identity.VerifiableAddresses) > 0 {
-			id.VerifiableAddresses = hookResponse.Identity.VerifiableAddresses
-		}
-
-		if len(hookResponse.Identity.VerifiableAddresses) > 0 {
-			id.VerifiableAddresses = hookResponse.Identity.VerifiableAddresses
+		if len(hookResponse.SessionMetadata) > 0 && s != nil {
+			if err := s.SetMetadata(hookResponse.SessionMetadata); err != nil {
+				return err
+			}
 		}
 
-		if len(hookResponse.Identity.RecoveryAddresses) > 0 {
-			id.RecoveryAddresses = hookResponse.Identity.RecoveryAddresses
+		if id == nil {
+			// Pre hooks are executed before there is an identity.
+			return nil
 		}
 
-		if len(hookResponse.Identity.MetadataPublic) > 0 {
-			id.MetadataPublic = hookResponse.Identity.MetadataPublic
+		if hookResponse.Identity != nil {
+			applyWebhookIdentity(id, (*identity.Identity)(hookResponse.Identity))
 		}
 
-		if len(hookResponse.Identity.MetadataAdmin) > 0 {
-			id.MetadataAdmin = hookResponse.Identity.MetadataAdmin
+		if len(hookResponse.TraitsPatch) > 0 && string(hookResponse.TraitsPatch) != "null" {
+			traits := map[string]any{}
+			if len(id.Traits) > 0 {
+				if err := json.Unmarshal(id.Traits, &traits); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			if err := jsonx.ApplyJSONPatch(hookResponse.TraitsPatch, &traits); err != nil {
+				return errors.WithStack(herodot.ErrBadRequest.WithReasonf("The traits patch of the webhook response could not be applied: %s", err))
+			}
+			patched, err := json.Marshal(traits)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			id.Traits = patched
 		}
 
 		return nil
@@ -508,18 +668,7 @@ func parseWebhookResponse(resp *http.Response, id *identity.Identity) (err error
 		for _, msg := range hookResponse.Messages {
 			messages := text.Messages{}
 			for _, detail := range msg.DetailedMessages {
-				var msgType text.UITextType
-				if detail.Type == "error" {
-					msgType = text.Error
-				} else {
-					msgType = text.Info
-				}
-				messages.Add(&text.Message{
-					ID:      text.ID(detail.ID),
-					Text:    detail.Text,
-					Type:    msgType,
-					Context: detail.Context,
-				})
+				messages.Add(detail.toMessage())
 			}
 			validationErrs = append(validationErrs, schema.NewHookValidationError(msg.InstancePtr, "a webhook target returned an error", messages))
 		}
@@ -534,11 +683,64 @@ func parseWebhookResponse(resp *http.Response, id *identity.Identity) (err error
 	return nil
 }
 
+func applyWebhookIdentity(id, hookIdentity *identity.Identity) {
+	if len(hookIdentity.Traits) > 0 {
+		id.Traits = hookIdentity.Traits
+	}
+
+	if len(hookIdentity.SchemaID) > 0 {
+		id.SchemaID = hookIdentity.SchemaID
+	}
+
+	if len(hookIdentity.State) > 0 {
+		id.State = hookIdentity.State
+	}
+
+	if len(hookIdentity.VerifiableAddresses) > 0 {
+		id.VerifiableAddresses = hookIdentity.VerifiableAddresses
+	}
+
+	if len(hookIdentity.RecoveryAddresses) > 0 {
+		id.RecoveryAddresses = hookIdentity.RecoveryAddresses
+	}
+
+	if len(hookIdentity.MetadataPublic) > 0 {
+		id.MetadataPublic = hookIdentity.MetadataPublic
+	}
+
+	if len(hookIdentity.MetadataAdmin) > 0 {
+		id.MetadataAdmin = hookIdentity.MetadataAdmin
+	}
+}
+
 func isTimeoutError(err error) bool {
 	var te interface{ Timeout() bool }
 	return errors.As(err, &te) && te.Timeout() || errors.Is(err, context.DeadlineExceeded)
 }
 
+// configureWebHookHTTPClient applies the `timeout` and `retry` settings of a hook to the
+// HTTP client. The timeout applies to every attempt.
+func configureWebHookHTTPClient(httpClient *retryablehttp.Client, conf json.RawMessage) {
+	if v := gjson.GetBytes(conf, "timeout"); v.Exists() {
+		if d, err := time.ParseDuration(v.String()); err == nil && d > 0 {
+			httpClient.HTTPClient.Timeout = d
+		}
+	}
+	if v := gjson.GetBytes(conf, "retry.max_retries"); v.Exists() && v.Int() >= 0 {
+		httpClient.RetryMax = int(v.Int())
+	}
+	if v := gjson.GetBytes(conf, "retry.min_wait"); v.Exists() {
+		if d, err := time.ParseDuration(v.String()); err == nil {
+			httpClient.RetryWaitMin = d
+		}
+	}
+	if v := gjson.GetBytes(conf, "retry.max_wait"); v.Exists() {
+		if d, err := time.ParseDuration(v.String()); err == nil {
+			httpClient.RetryWaitMax = d
+		}
+	}
+}
+
 func instrumentHTTPClientForEvents(ctx context.Context, httpClient *retryablehttp.Client, triggerID uuid.UUID, webhookID string) {
 	// TODO(@alnr): improve this implementation to redact sensitive data
 	var (
@@ -5,15 +5,25 @@ package hook_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	_ "embed"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"sync"
@@ -30,7 +40,9 @@ import (
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
+	"github.com/ory/kratos/cipher"
 	"github.com/ory/kratos/driver/config"
+	confighelpers "github.com/ory/kratos/driver/config/testhelpers"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/internal"
 	"github.com/ory/kratos/schema"
@@ -41,8 +53,10 @@ import (
 	"github.com/ory/kratos/selfservice/flow/settings"
 	"github.com/ory/kratos/selfservice/flow/verification"
 	"github.com/ory/kratos/selfservice/hook"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
 	"github.com/ory/kratos/x/events"
@@ -60,6 +74,13 @@ var transientPayload = json.RawMessage(`{
 	}
 }`)
 
+// registryDependencies are the web hook dependencies provided by the registry.
+type registryDependencies interface {
+	config.Provider
+	cipher.Provider
+	deadletter.PersistenceProvider
+}
+
 func TestWebHooks(t *testing.T) {
 	ctx := context.Background()
 	conf, reg := internal.NewFastRegistryWithMocks(t)
@@ -89,7 +110,7 @@ func TestWebHooks(t *testing.T) {
 	whDeps := struct {
 		x.SimpleLoggerWithClient
 		*jsonnetsecure.TestProvider
-		config.Provider
+		registryDependencies
 	}{
 		x.SimpleLoggerWithClient{L: logger, C: reg.HTTPClient(ctx), T: otelx.NewNoop(logger, &otelx.Config{ServiceName: "kratos"})},
 		jsonnetsecure.NewTestProvider(t),
@@ -777,6 +798,72 @@ func TestWebHooks(t *testing.T) {
 		})
 	})
 
+	t.Run("patch traits and flow UI", func(t *testing.T) {
+		t.Parallel()
+		req := &http.Request{
+			Host:       "www.ory.sh",
+			Header:     map[string][]string{},
+			RequestURI: "/some_end_point",
+			Method:     http.MethodPost,
+			URL:        &url.URL{Path: "some_end_point"},
+		}
+		newHook := func(t *testing.T, response string) *hook.WebHook {
+			ts := newServer(webHookHttpCodeWithBodyEndPoint(t, http.StatusOK, []byte(response)))
+			return hook.NewWebHook(&whDeps, json.RawMessage(fmt.Sprintf(`{"url": "%s", "method": "POST", "body": "%s", "response": {"parse":true}}`, ts.URL+path, "file://./stub/test_body.jsonnet")))
+		}
+
+		t.Run("case=patches the traits", func(t *testing.T) {
+			wh := newHook(t, `{"traits_patch":[{"op":"replace","path":"/email","value":"some@other-example.org"},{"op":"add","path":"/company","value":"Ory"}]}`)
+			id := &identity.Identity{Traits: []byte(`{"email":"some@example.org","name":"Jane"}`)}
+			require.NoError(t, wh.ExecutePostRegistrationPrePersistHook(nil, req, &registration.Flow{ID: x.NewUUID()}, id))
+			assert.JSONEq(t, `{"email":"some@other-example.org","name":"Jane","company":"Ory"}`, string(id.Traits))
+		})
+
+		t.Run("case=rejects an invalid traits patch", func(t *testing.T) {
+			wh := newHook(t, `{"traits_patch":[{"op":"remove","path":"/does/not/exist"}]}`)
+			id := &identity.Identity{Traits: []byte(`{"email":"some@example.org"}`)}
+			require.Error(t, wh.ExecutePostRegistrationPrePersistHook(nil, req, &registration.Flow{ID: x.NewUUID()}, id))
+			assert.JSONEq(t, `{"email":"some@example.org"}`, string(id.Traits))
+		})
+
+		t.Run("case=changes the UI of the flow", func(t *testing.T) {
+			wh := newHook(t, `{
+				"ui": {
+					"messages": [{"id": 1234, "text": "Welcome back!", "type": "info"}],
+					"node_messages": {"traits.email": [{"id": 4000001, "text": "Use your work email.", "type": "error"}]},
+					"remove_nodes": ["traits.phone"],
+					"add_nodes": [{"type": "input", "group": "default", "attributes": {"name": "traits.invite_code", "type": "text", "node_type": "input"}, "messages": [], "meta": {}}]
+				},
+				"traits_patch": [{"op":"add","path":"/email","value":"ignored@example.org"}]
+			}`)
+			f := &login.Flow{ID: x.NewUUID(), UI: container.New("")}
+			f.UI.Nodes.Append(node.NewInputField("traits.email", nil, node.DefaultGroup, node.InputAttributeTypeEmail))
+			f.UI.Nodes.Append(node.NewInputField("traits.phone", nil, node.DefaultGroup, node.InputAttributeTypeTel))
+
+			require.NoError(t, wh.ExecuteLoginPreHook(nil, req, f))
+			require.Len(t, f.UI.Messages, 1)
+			assert.Equal(t, "Welcome back!", f.UI.Messages[0].Text)
+			assert.Equal(t, text.Info, f.UI.Messages[0].Type)
+			require.Len(t, f.UI.Nodes, 2)
+			assert.Equal(t, "traits.email", f.UI.Nodes[0].ID())
+			require.Len(t, f.UI.Nodes[0].Messages, 1)
+			assert.EqualValues(t, 4000001, f.UI.Nodes[0].Messages[0].ID)
+			assert.Equal(t, text.Error, f.UI.Nodes[0].Messages[0].Type)
+			assert.Equal(t, "traits.invite_code", f.UI.Nodes[1].ID())
+		})
+
+		t.Run("case=sets the session metadata after login", func(t *testing.T) {
+			wh := newHook(t, `{"session_metadata":{"tenant":{"id":"acme"},"roles":null}}`)
+			s := &session.Session{ID: x.NewUUID(), Identity: &identity.Identity{ID: x.NewUUID()}, Metadata: []byte(`{"roles":["admin"],"device":"laptop"}`)}
+			require.NoError(t, wh.ExecuteLoginPostHook(nil, req, node.DefaultGroup, &login.Flow{ID: x.NewUUID()}, s))
+			assert.JSONEq(t, `{"tenant":{"id":"acme"},"device":"laptop"}`, string(s.Metadata))
+
+			s = &session.Session{ID: x.NewUUID(), Identity: &identity.Identity{ID: x.NewUUID()}}
+			require.NoError(t, wh.ExecutePostRecoveryHook(nil, req, &recovery.Flow{ID: x.NewUUID()}, s))
+			assert.Empty(t, s.Metadata, "sessions of other flows are not writable")
+		})
+	})
+
 	t.Run("must error when config is erroneous", func(t *testing.T) {
 		t.Parallel()
 		req := &http.Request{
@@ -1037,7 +1124,7 @@ func TestDisallowPrivateIPRanges(t *testing.T) {
 	whDeps := struct {
 		x.SimpleLoggerWithClient
 		*jsonnetsecure.TestProvider
-		config.Provider
+		registryDependencies
 	}{
 		x.SimpleLoggerWithClient{L: logger, C: reg.HTTPClient(context.Background()), T: otelx.NewNoop(logger, &otelx.Config{ServiceName: "kratos"})},
 		jsonnetsecure.NewTestProvider(t),
@@ -1109,7 +1196,7 @@ func TestAsyncWebhook(t *testing.T) {
 	whDeps := struct {
 		x.SimpleLoggerWithClient
 		*jsonnetsecure.TestProvider
-		config.Provider
+		registryDependencies
 	}{
 		x.SimpleLoggerWithClient{L: logger, C: reg.HTTPClient(context.Background()), T: otelx.NewNoop(logger, &otelx.Config{ServiceName: "kratos"})},
 		jsonnetsecure.NewTestProvider(t),
@@ -1184,6 +1271,61 @@ func TestAsyncWebhook(t *testing.T) {
 	require.True(t, found)
 }
 
+func TestAsyncWebhookRecordsFailedEvent(t *testing.T) {
+	t.Parallel()
+	_, reg := internal.NewFastRegistryWithMocks(t)
+	logger := logrusx.New("kratos", "test")
+	whDeps := struct {
+		x.SimpleLoggerWithClient
+		*jsonnetsecure.TestProvider
+		registryDependencies
+	}{
+		x.SimpleLoggerWithClient{L: logger, C: reg.HTTPClient(context.Background()), T: otelx.NewNoop(logger, &otelx.Config{ServiceName: "kratos"})},
+		jsonnetsecure.NewTestProvider(t),
+		reg,
+	}
+
+	webhookReceiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(webhookReceiver.Close)
+
+	req := &http.Request{
+		Header: map[string][]string{"Some-Header": {"Some-Value"}},
+		Host:   "www.ory.sh",
+		TLS:    new(tls.ConnectionState),
+		URL:    &url.URL{Path: "/some_end_point"},
+		Method: http.MethodPost,
+	}
+	s := &session.Session{ID: x.NewUUID(), Identity: &identity.Identity{ID: x.NewUUID()}}
+	f := &registration.Flow{ID: x.NewUUID()}
+
+	wh := hook.NewWebHook(&whDeps, json.RawMessage(fmt.Sprintf(`
+		{
+			"id": "registration-events",
+			"url": %q,
+			"method": "POST",
+			"body": "file://stub/test_body.jsonnet",
+			"response": {
+				"ignore": true
+			}
+		}`, webhookReceiver.URL)))
+	require.NoError(t, wh.ExecutePostRegistrationPostPersistHook(nil, req, f, s))
+
+	var events []deadletter.Event
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		var err error
+		events, _, err = reg.FailedWebhookEventPersister().ListFailedWebhookEvents(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, "registration-events", events[0].WebhookID)
+	assert.Equal(t, webhookReceiver.URL, events[0].URL)
+	assert.Equal(t, http.MethodPost, events[0].Method)
+	assert.Contains(t, events[0].Error, "webhook failed with status code 400")
+}
+
 func TestWebhookEvents(t *testing.T) {
 	t.Parallel()
 	_, reg := internal.NewFastRegistryWithMocks(t)
@@ -1191,7 +1333,7 @@ func TestWebhookEvents(t *testing.T) {
 	whDeps := struct {
 		x.SimpleLoggerWithClient
 		*jsonnetsecure.TestProvider
-		config.Provider
+		registryDependencies
 	}{
 		x.SimpleLoggerWithClient{L: logger, C: reg.HTTPClient(context.Background()), T: otelx.NewNoop(logger, &otelx.Config{ServiceName: "kratos"})},
 		jsonnetsecure.NewTestProvider(t),
@@ -1392,3 +1534,202 @@ func TestWebhookEvents(t *testing.T) {
 		require.Equal(t, i, -1)
 	})
 }
+
+func TestWebHookLegacyPayload(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	logger := logrusx.New("kratos", "test")
+	whDeps := struct {
+		x.SimpleLoggerWithClient
+		*jsonnetsecure.TestProvider
+		registryDependencies
+	}{
+		x.SimpleLoggerWithClient{L: logger, C: reg.HTTPClient(ctx), T: otelx.NewNoop(logger, &otelx.Config{ServiceName: "kratos"})},
+		jsonnetsecure.NewTestProvider(t),
+		reg,
+	}
+
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(ts.Close)
+
+	req := &http.Request{
+		Header: map[string][]string{"Cookie": {"Some-Cookie=Some-Cookie-Value"}},
+		Host:   "www.ory.sh",
+		TLS:    new(tls.ConnectionState),
+		URL:    &url.URL{Path: "/some_end_point"},
+		Method: http.MethodPost,
+	}
+	s := &session.Session{ID: x.NewUUID(), Identity: &identity.Identity{ID: x.NewUUID()}}
+	f := &login.Flow{ID: x.NewUUID()}
+	wh := hook.NewWebHook(&whDeps, json.RawMessage(fmt.Sprintf(`{"url": "%s", "method": "POST", "body": "file://./stub/legacy_body.jsonnet"}`, ts.URL)))
+
+	for _, tc := range []struct {
+		legacy   bool
+		expected bool
+	}{
+		{legacy: false, expected: true},
+		{legacy: true, expected: false},
+	} {
+		t.Run(fmt.Sprintf("legacy=%t", tc.legacy), func(t *testing.T) {
+			conf.MustSet(ctx, config.ViperKeyCompatibility+"."+config.CompatibilityLegacyWebhookPayload.Key, tc.legacy)
+
+			require.NoError(t, wh.ExecuteLoginPostHook(nil, req, node.PasswordGroup, f, s))
+			assert.Equal(t, f.ID.String(), gjson.GetBytes(body, "flow_id").String(), "%s", body)
+			assert.Equal(t, tc.expected, gjson.GetBytes(body, "has_cookies").Bool(), "%s", body)
+			assert.Equal(t, tc.expected, gjson.GetBytes(body, "has_session").Bool(), "%s", body)
+		})
+	}
+}
+
+func TestWebHookMutualTLS(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	_, reg := internal.NewFastRegistryWithMocks(t)
+	logger := logrusx.New("kratos", "test")
+	whDeps := struct {
+		x.SimpleLoggerWithClient
+		*jsonnetsecure.TestProvider
+		registryDependencies
+	}{
+		x.SimpleLoggerWithClient{L: logger, C: reg.HTTPClient(ctx), T: otelx.NewNoop(logger, &otelx.Config{ServiceName: "kratos"})},
+		jsonnetsecure.NewTestProvider(t),
+		reg,
+	}
+
+	dir := t.TempDir()
+	writePEM := func(name, typ string, b []byte) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0o600))
+		return p
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "webhook-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+	caPath := writePEM("ca.crt", "CERTIFICATE", caDER)
+
+	issue := func(serial int64, cn string, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}, ca, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		return der, key
+	}
+
+	serverDER, serverKey := issue(2, "webhook", x509.ExtKeyUsageServerAuth)
+	clientDER, clientKey := issue(3, "kratos", x509.ExtKeyUsageClientAuth)
+	clientKeyDER, err := x509.MarshalPKCS8PrivateKey(clientKey)
+	require.NoError(t, err)
+	clientCertPath := writePEM("client.crt", "CERTIFICATE", clientDER)
+	clientKeyPath := writePEM("client.key", "PRIVATE KEY", clientKeyDER)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	var (
+		mu      sync.Mutex
+		peer    string
+		headers http.Header
+	)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		peer = r.TLS.PeerCertificates[0].Subject.CommonName
+		headers = r.Header.Clone()
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	req := &http.Request{
+		Header: map[string][]string{"Some-Header": {"Some-Value"}},
+		Host:   "www.ory.sh",
+		TLS:    new(tls.ConnectionState),
+		URL:    &url.URL{Path: "/some_end_point"},
+		Method: http.MethodPost,
+	}
+	s := &session.Session{ID: x.NewUUID(), Identity: &identity.Identity{ID: x.NewUUID()}}
+	f := &login.Flow{ID: x.NewUUID()}
+
+	t.Run("case=authenticates with the client certificate and signs the request", func(t *testing.T) {
+		wh := hook.NewWebHook(&whDeps, json.RawMessage(fmt.Sprintf(`{
+  "url": %q,
+  "method": "POST",
+  "body": "file://stub/test_body.jsonnet",
+  "tls": {"client_cert_path": %q, "client_key_path": %q, "ca_cert_path": %q},
+  "signing": {"keys": [{"algorithm": "hmac_sha256", "secret": "a-secret-of-at-least-32-characters"}]}
+}`, ts.URL, clientCertPath, clientKeyPath, caPath)))
+		require.NoError(t, wh.ExecuteLoginPostHook(nil, req, node.DefaultGroup, f, s))
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "kratos", peer)
+		assert.NotEmpty(t, headers.Get("Webhook-Id"))
+		assert.NotEmpty(t, headers.Get("Webhook-Timestamp"))
+		assert.Regexp(t, "^v1,", headers.Get("Webhook-Signature"))
+	})
+
+	t.Run("case=fails without the client certificate", func(t *testing.T) {
+		wh := hook.NewWebHook(&whDeps, json.RawMessage(fmt.Sprintf(`{
+  "url": %q,
+  "method": "POST",
+  "body": "file://stub/test_body.jsonnet",
+  "tls": {"ca_cert_path": %q},
+  "retry": {"max_retries": 0}
+}`, ts.URL, caPath)))
+		require.Error(t, wh.ExecuteLoginPostHook(nil, req, node.DefaultGroup, f, s))
+	})
+
+	t.Run("case=fails with an invalid client certificate", func(t *testing.T) {
+		wh := hook.NewWebHook(&whDeps, json.RawMessage(fmt.Sprintf(`{
+  "url": %q,
+  "method": "POST",
+  "body": "file://stub/test_body.jsonnet",
+  "tls": {"client_cert_path": %q, "client_key_path": %q}
+}`, ts.URL, caPath, clientKeyPath)))
+		err := wh.ExecuteLoginPostHook(nil, req, node.DefaultGroup, f, s)
+		require.Error(t, err)
+		assert.Contains(t, fmt.Sprintf("%+v", err), "Unable to load the client certificate")
+	})
+
+	t.Run("case=private IP ranges remain disallowed", func(t *testing.T) {
+		ctx := confighelpers.WithConfigValue(ctx, config.ViperKeyClientHTTPNoPrivateIPRanges, true)
+		wh := hook.NewWebHook(&whDeps, json.RawMessage(fmt.Sprintf(`{
+  "url": %q,
+  "method": "POST",
+  "body": "file://stub/test_body.jsonnet",
+  "tls": {"client_cert_path": %q, "client_key_path": %q, "ca_cert_path": %q}
+}`, ts.URL, clientCertPath, clientKeyPath, caPath)))
+		err := wh.ExecuteLoginPostHook(nil, req.WithContext(ctx), node.DefaultGroup, f, s)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is not a permitted destination")
+	})
+}
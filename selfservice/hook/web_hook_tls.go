@@ -0,0 +1,105 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x/egress"
+)
+
+// webHookTLSConfig configures mutual TLS for a web hook.
+type webHookTLSConfig struct {
+	ClientCertPath string `json:"client_cert_path"`
+	ClientKeyPath  string `json:"client_key_path"`
+	CACertPath     string `json:"ca_cert_path"`
+}
+
+// webHookTLSTransports caches the transports of web hooks with a TLS configuration so that
+// connections are reused across calls.
+var webHookTLSTransports sync.Map
+
+// configureWebHookTLS replaces the transport of the HTTP client with one which presents the
+// client certificate and trusts the certificate authority configured in the hook's `tls` setting.
+// The egress policy of web hooks continues to apply.
+func configureWebHookTLS(ctx context.Context, httpClient *retryablehttp.Client, conf json.RawMessage, c *config.Config) error {
+	raw := gjson.GetBytes(conf, "tls")
+	if !raw.IsObject() {
+		return nil
+	}
+
+	var tc webHookTLSConfig
+	if err := json.Unmarshal([]byte(raw.Raw), &tc); err != nil {
+		return errors.WithStack(err)
+	}
+
+	policy, err := c.ClientHTTPEgressPolicy(ctx, config.HTTPClientIntegrationWebhooks)
+	if err != nil {
+		return err
+	}
+
+	key := strings.Join([]string{tc.ClientCertPath, tc.ClientKeyPath, tc.CACertPath, policy.Key()}, "\n")
+	if t, ok := webHookTLSTransports.Load(key); ok {
+		httpClient.HTTPClient.Transport = t.(http.RoundTripper)
+		return nil
+	}
+
+	if policy.TLSConfig, err = newWebHookTLSConfig(&tc); err != nil {
+		return err
+	}
+
+	actual, _ := webHookTLSTransports.LoadOrStore(key, egress.NewTransport(policy))
+	httpClient.HTTPClient.Transport = actual.(http.RoundTripper)
+	return nil
+}
+
+func newWebHookTLSConfig(tc *webHookTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if tc.CACertPath != "" {
+		pem, err := os.ReadFile(tc.CACertPath)
+		if err != nil {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to read the CA certificate of the web hook: %s", err))
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("The CA certificate of the web hook does not contain any PEM encoded certificates."))
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if tc.ClientCertPath != "" || tc.ClientKeyPath != "" {
+		// Fail early on invalid certificates instead of on the first call.
+		if _, err := tls.LoadX509KeyPair(tc.ClientCertPath, tc.ClientKeyPath); err != nil {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to load the client certificate of the web hook: %s", err))
+		}
+		// The key pair is loaded on every handshake so that renewed certificates are picked up
+		// without a restart.
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(tc.ClientCertPath, tc.ClientKeyPath)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return &cert, nil
+		}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,277 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hostedui
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/errorx"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/logout"
+	"github.com/ory/kratos/selfservice/flow/recovery"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+	"github.com/ory/nosurf"
+	"github.com/ory/x/sqlcon"
+	"github.com/ory/x/urlx"
+)
+
+const (
+	RouteLogin        = config.HostedUIPath + "/login"
+	RouteRegistration = config.HostedUIPath + "/registration"
+	RouteRecovery     = config.HostedUIPath + "/recovery"
+	RouteVerification = config.HostedUIPath + "/verification"
+	RouteSettings     = config.HostedUIPath + "/settings"
+	RouteError        = config.HostedUIPath + "/error"
+	RouteStylesheet   = config.HostedUIPath + "/hosted.css"
+	RouteScript       = config.HostedUIPath + "/hosted.js"
+)
+
+type (
+	handlerDependencies interface {
+		config.Provider
+		x.WriterProvider
+		x.LoggingProvider
+		x.CSRFTokenGeneratorProvider
+		errorx.ManagementProvider
+		errorx.PersistenceProvider
+		session.ManagementProvider
+		login.FlowPersistenceProvider
+		registration.FlowPersistenceProvider
+		recovery.FlowPersistenceProvider
+		verification.FlowPersistenceProvider
+		settings.FlowPersistenceProvider
+	}
+	HandlerProvider interface {
+		HostedUIHandler() *Handler
+	}
+	// Handler serves the hosted UI, which renders the UI containers of browser flows as HTML
+	// forms.
+	Handler struct {
+		d handlerDependencies
+	}
+
+	// flowPage describes a flow which is about to be rendered.
+	flowPage struct {
+		flow      flow.Flow
+		initRoute string
+		returnTo  string
+		expiresAt time.Time
+		csrfToken string
+		skipCSRF  bool
+		title     string
+		links     []link
+	}
+)
+
+func NewHandler(d handlerDependencies) *Handler {
+	return &Handler{d: d}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	public.GET(RouteLogin, h.enabled(h.login))
+	public.GET(RouteRegistration, h.enabled(h.registration))
+	public.GET(RouteRecovery, h.enabled(h.recovery))
+	public.GET(RouteVerification, h.enabled(h.verification))
+	public.GET(RouteSettings, h.enabled(h.settings))
+	public.GET(RouteError, h.enabled(h.error))
+	public.GET(RouteStylesheet, h.enabled(h.stylesheet))
+	public.GET(RouteScript, h.enabled(h.script))
+}
+
+func (h *Handler) RegisterAdminRoutes(*x.RouterAdmin) {}
+
+func (h *Handler) enabled(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if !h.d.Config().SelfServiceHostedUIEnabled(r.Context()) {
+			h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("The hosted UI is not enabled.")))
+			return
+		}
+		handle(w, r, ps)
+	}
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+	f, err := h.d.LoginFlowPersister().GetLoginFlow(ctx, x.ParseUUID(r.URL.Query().Get("flow")))
+	if err != nil {
+		h.handleFlowError(w, r, login.RouteInitBrowserFlow, err)
+		return
+	}
+
+	var links []link
+	if !f.IsRefresh() && f.RequestedAAL == identity.AuthenticatorAssuranceLevel1 {
+		if h.d.Config().SelfServiceFlowRegistrationEnabled(ctx) {
+			links = append(links, h.initLink(r, registration.RouteInitBrowserFlow, f.ReturnTo, "Sign up"))
+		}
+		if h.d.Config().SelfServiceFlowRecoveryEnabled(ctx) {
+			links = append(links, h.initLink(r, recovery.RouteInitBrowserFlow, f.ReturnTo, "Forgot your password?"))
+		}
+	}
+
+	h.show(w, r, &flowPage{
+		flow:      f,
+		initRoute: login.RouteInitBrowserFlow,
+		returnTo:  f.ReturnTo,
+		expiresAt: f.ExpiresAt,
+		csrfToken: f.CSRFToken,
+		title:     "Sign in",
+		links:     links,
+	})
+}
+
+func (h *Handler) registration(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	f, err := h.d.RegistrationFlowPersister().GetRegistrationFlow(r.Context(), x.ParseUUID(r.URL.Query().Get("flow")))
+	if err != nil {
+		h.handleFlowError(w, r, registration.RouteInitBrowserFlow, err)
+		return
+	}
+
+	h.show(w, r, &flowPage{
+		flow:      f,
+		initRoute: registration.RouteInitBrowserFlow,
+		returnTo:  f.ReturnTo,
+		expiresAt: f.ExpiresAt,
+		csrfToken: f.CSRFToken,
+		title:     "Sign up",
+		links:     []link{h.initLink(r, login.RouteInitBrowserFlow, f.ReturnTo, "Sign in")},
+	})
+}
+
+func (h *Handler) recovery(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	f, err := h.d.RecoveryFlowPersister().GetRecoveryFlow(r.Context(), x.ParseUUID(r.URL.Query().Get("flow")))
+	if err != nil {
+		h.handleFlowError(w, r, recovery.RouteInitBrowserFlow, err)
+		return
+	}
+
+	h.show(w, r, &flowPage{
+		flow:      f,
+		initRoute: recovery.RouteInitBrowserFlow,
+		returnTo:  f.ReturnTo,
+		expiresAt: f.ExpiresAt,
+		csrfToken: f.CSRFToken,
+		skipCSRF:  f.DangerousSkipCSRFCheck,
+		title:     "Recover your account",
+		links:     []link{h.initLink(r, login.RouteInitBrowserFlow, f.ReturnTo, "Sign in")},
+	})
+}
+
+func (h *Handler) verification(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	f, err := h.d.VerificationFlowPersister().GetVerificationFlow(r.Context(), x.ParseUUID(r.URL.Query().Get("flow")))
+	if err != nil {
+		h.handleFlowError(w, r, verification.RouteInitBrowserFlow, err)
+		return
+	}
+
+	h.show(w, r, &flowPage{
+		flow:      f,
+		initRoute: verification.RouteInitBrowserFlow,
+		returnTo:  f.ReturnTo,
+		expiresAt: f.ExpiresAt,
+		csrfToken: f.CSRFToken,
+		title:     "Verify your account",
+	})
+}
+
+func (h *Handler) settings(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+	f, err := h.d.SettingsFlowPersister().GetSettingsFlow(ctx, x.ParseUUID(r.URL.Query().Get("flow")))
+	if err != nil {
+		h.handleFlowError(w, r, settings.RouteInitBrowserFlow, err)
+		return
+	}
+
+	// The settings flow init endpoint takes care of signing in and of upgrading the session
+	// to the required authenticator assurance level.
+	sess, err := h.d.SessionManager().FetchFromRequestContext(ctx, r)
+	if err != nil || sess.IdentityID != f.IdentityID ||
+		h.d.SessionManager().DoesSessionSatisfy(ctx, sess, h.d.Config().SelfServiceSettingsRequiredAAL(ctx)) != nil {
+		h.restart(w, r, settings.RouteInitBrowserFlow, f.ReturnTo)
+		return
+	}
+
+	h.show(w, r, &flowPage{
+		flow:      f,
+		initRoute: settings.RouteInitBrowserFlow,
+		returnTo:  f.ReturnTo,
+		expiresAt: f.ExpiresAt,
+		skipCSRF:  true, // The settings flow is bound to the session instead.
+		title:     "Account settings",
+		links: []link{{
+			Href:  urlx.CopyWithQuery(urlx.AppendPaths(h.d.Config().SelfPublicURL(ctx), logout.RouteSubmitFlow), url.Values{"token": {sess.LogoutToken}}).String(),
+			Title: "Sign out",
+		}},
+	})
+}
+
+func (h *Handler) error(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var ev errorView
+	if id := r.URL.Query().Get("id"); id != "" {
+		container, err := h.d.SelfServiceErrorPersister().ReadErrorContainer(r.Context(), x.ParseUUID(id))
+		if err != nil && !errors.Is(err, sqlcon.ErrNoRows) {
+			h.d.Writer().WriteError(w, r, err)
+			return
+		} else if err == nil {
+			ev = newErrorView(container.Errors)
+		}
+	}
+
+	h.render(w, r, &page{
+		Title: "An error occurred",
+		Error: &ev,
+		Links: []link{{Href: h.d.Config().SelfServiceBrowserDefaultReturnTo(r.Context()).String(), Title: "Go back"}},
+	})
+}
+
+func (h *Handler) show(w http.ResponseWriter, r *http.Request, p *flowPage) {
+	if p.flow.GetType() != flow.TypeBrowser || p.expiresAt.Before(x.Now()) {
+		h.restart(w, r, p.initRoute, p.returnTo)
+		return
+	}
+
+	// Browser flows must include the CSRF token
+	//
+	// Resolves: https://github.com/ory/kratos/issues/1282
+	if !p.skipCSRF && !nosurf.VerifyToken(h.d.GenerateCSRFToken(r), p.csrfToken) {
+		h.d.SelfServiceErrorManager().Forward(r.Context(), w, r, x.CSRFErrorReason(r, h.d))
+		return
+	}
+
+	h.render(w, r, &page{
+		Title: p.title,
+		Flow:  newFlowView(p.flow.GetUI()),
+		Links: p.links,
+	})
+}
+
+// handleFlowError restarts the flow if it does not exist. All other errors are shown on the
+// error page.
+func (h *Handler) handleFlowError(w http.ResponseWriter, r *http.Request, initRoute string, err error) {
+	if errors.Is(err, sqlcon.ErrNoRows) {
+		h.restart(w, r, initRoute, r.URL.Query().Get("return_to"))
+		return
+	}
+	h.d.SelfServiceErrorManager().Forward(r.Context(), w, r, err)
+}
+
+func (h *Handler) restart(w http.ResponseWriter, r *http.Request, initRoute, returnTo string) {
+	http.Redirect(w, r, flow.GetFlowExpiredRedirectURL(r.Context(), h.d.Config(), initRoute, returnTo).String(), http.StatusSeeOther)
+}
+
+func (h *Handler) initLink(r *http.Request, initRoute, returnTo, title string) link {
+	return link{Href: flow.GetFlowExpiredRedirectURL(r.Context(), h.d.Config(), initRoute, returnTo).String(), Title: title}
+}
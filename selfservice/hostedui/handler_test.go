@@ -0,0 +1,131 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hostedui_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/hostedui"
+	"github.com/ory/kratos/x"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	public, _ := testhelpers.NewKratosServerWithCSRF(t, reg)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	testhelpers.StrategyEnable(t, conf, identity.CredentialsTypePassword.String(), true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceHostedUIEnabled, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceBrowserDefaultReturnTo, public.URL+hostedui.RouteSettings)
+
+	get := func(t *testing.T, c *http.Client, path string) (*http.Response, string) {
+		res, err := c.Get(public.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+
+	t.Run("case=is not found if disabled", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceHostedUIEnabled, false)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceHostedUIEnabled, true)
+		})
+
+		res, _ := get(t, testhelpers.NewClientWithCookies(t), hostedui.RouteLogin)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("case=renders the login flow", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceHostedUITheme, map[string]string{"color-primary": "#ff0000"})
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceHostedUITheme, nil)
+		})
+
+		res, body := get(t, testhelpers.NewClientWithCookies(t), login.RouteInitBrowserFlow)
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Equal(t, hostedui.RouteLogin, res.Request.URL.Path)
+		assert.Contains(t, res.Header.Get("Content-Type"), "text/html")
+
+		assert.Contains(t, body, "<title>Sign in</title>")
+		assert.Contains(t, body, `name="csrf_token"`)
+		assert.Contains(t, body, `name="identifier"`)
+		assert.Contains(t, body, `name="password"`)
+		assert.Contains(t, body, `name="method" value="password"`)
+		assert.Contains(t, body, "--kratos-color-primary: #ff0000;")
+		assert.Contains(t, body, "/self-service/registration/browser")
+	})
+
+	t.Run("case=restarts unknown flows", func(t *testing.T) {
+		res, body := get(t, testhelpers.NewClientWithCookies(t), hostedui.RouteLogin+"?flow="+x.NewUUID().String())
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Equal(t, hostedui.RouteLogin, res.Request.URL.Path)
+		assert.NotEmpty(t, res.Request.URL.Query().Get("flow"))
+		assert.Contains(t, body, `name="identifier"`)
+	})
+
+	t.Run("case=does not render flows of other browsers", func(t *testing.T) {
+		res, _ := get(t, testhelpers.NewClientWithCookies(t), login.RouteInitBrowserFlow)
+		require.Equal(t, hostedui.RouteLogin, res.Request.URL.Path)
+
+		res, body := get(t, testhelpers.NewClientWithCookies(t), res.Request.URL.RequestURI())
+		assert.Equal(t, hostedui.RouteError, res.Request.URL.Path)
+		assert.NotContains(t, body, `name="identifier"`)
+	})
+
+	t.Run("case=signs in before rendering the settings flow", func(t *testing.T) {
+		res, body := get(t, testhelpers.NewClientWithCookies(t), settings.RouteInitBrowserFlow)
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Equal(t, hostedui.RouteLogin, res.Request.URL.Path)
+	})
+
+	t.Run("case=renders the settings flow", func(t *testing.T) {
+		id := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		id.Traits = identity.Traits(`{"email":"hosted-ui@example.com"}`)
+		require.NoError(t, reg.IdentityManager().Create(ctx, id))
+		c := testhelpers.NewHTTPClientWithIdentitySessionCookieLocalhost(t, ctx, reg, id)
+		res, body := get(t, c, settings.RouteInitBrowserFlow)
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Equal(t, hostedui.RouteSettings, res.Request.URL.Path)
+
+		assert.Contains(t, body, "<title>Account settings</title>")
+		assert.Contains(t, body, `name="traits.email"`)
+		assert.Contains(t, body, `name="method" value="profile"`)
+		assert.Contains(t, body, "/self-service/logout?token=")
+	})
+
+	t.Run("case=renders errors", func(t *testing.T) {
+		id, err := reg.SelfServiceErrorPersister().CreateErrorContainer(ctx, x.NewUUID().String(), herodot.ErrBadRequest.WithReason("Something is <wrong>."))
+		require.NoError(t, err)
+
+		res, body := get(t, testhelpers.NewClientWithCookies(t), hostedui.RouteError+"?id="+id.String())
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Contains(t, body, "Something is &lt;wrong&gt;.")
+	})
+
+	t.Run("case=serves the assets", func(t *testing.T) {
+		res, body := get(t, http.DefaultClient, hostedui.RouteStylesheet)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, res.Header.Get("Content-Type"), "text/css")
+		assert.Contains(t, body, "--kratos-color-primary")
+
+		res, _ = get(t, http.DefaultClient, hostedui.RouteScript)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Contains(t, res.Header.Get("Content-Type"), "text/javascript")
+	})
+}
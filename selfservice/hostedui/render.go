@@ -0,0 +1,253 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package hostedui
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+)
+
+//go:embed templates/*
+var templates embed.FS
+
+var (
+	pageTemplate = template.Must(template.New("page.gotmpl").ParseFS(templates, "templates/page.gotmpl"))
+
+	themeNamePattern  = regexp.MustCompile(`^[a-z0-9-]+$`)
+	themeValuePattern = regexp.MustCompile(`^[^;{}<>\\]+$`)
+)
+
+type (
+	page struct {
+		Title string
+		Theme template.CSS
+		Flow  *flowView
+		Error *errorView
+		Links []link
+	}
+	link struct {
+		Href  string
+		Title string
+	}
+
+	// flowView is the UI container of a flow split into forms. The nodes of single sign-on
+	// groups are rendered as separate forms because their payloads must not contain the fields
+	// of other methods.
+	flowView struct {
+		Messages text.Messages
+		Forms    []formView
+		Scripts  []*node.ScriptAttributes
+	}
+	formView struct {
+		Action string
+		Method string
+		Nodes  []nodeView
+	}
+	nodeView struct {
+		Group    node.UiNodeGroup
+		Label    string
+		Messages text.Messages
+
+		Input    *node.InputAttributes
+		Image    *node.ImageAttributes
+		Anchor   *node.AnchorAttributes
+		Text     *node.TextAttributes
+		Division *node.DivisionAttributes
+	}
+
+	errorView struct {
+		Code    int    `json:"code"`
+		Status  string `json:"status"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	}
+)
+
+// isSeparateForm returns true for groups whose nodes are rendered in their own form.
+func isSeparateForm(group node.UiNodeGroup) bool {
+	return group == node.OpenIDConnectGroup || group == node.SAMLGroup
+}
+
+func newFlowView(c *container.Container) *flowView {
+	fv := &flowView{Messages: c.Messages}
+
+	var (
+		hidden   []nodeView
+		main     []nodeView
+		separate = map[node.UiNodeGroup][]nodeView{}
+		order    []node.UiNodeGroup
+	)
+	for _, n := range c.Nodes {
+		if script, ok := n.Attributes.(*node.ScriptAttributes); ok {
+			fv.Scripts = append(fv.Scripts, script)
+			continue
+		}
+
+		nv := newNodeView(n)
+		switch {
+		case n.Group == node.DefaultGroup && nv.Input != nil && nv.Input.Type == node.InputAttributeTypeHidden:
+			hidden = append(hidden, nv)
+		case isSeparateForm(n.Group):
+			if _, ok := separate[n.Group]; !ok {
+				order = append(order, n.Group)
+			}
+			separate[n.Group] = append(separate[n.Group], nv)
+		default:
+			main = append(main, nv)
+		}
+	}
+
+	if len(main) > 0 {
+		fv.Forms = append(fv.Forms, formView{Action: c.Action, Method: c.Method, Nodes: append(append([]nodeView{}, hidden...), main...)})
+	}
+	for _, group := range order {
+		nodes := append(append([]nodeView{}, hidden...), separate[group]...)
+		fv.Forms = append(fv.Forms, formView{Action: c.Action, Method: c.Method, Nodes: nodes})
+	}
+
+	return fv
+}
+
+func newNodeView(n *node.Node) nodeView {
+	nv := nodeView{Group: n.Group, Messages: n.Messages}
+	if n.Meta != nil && n.Meta.Label != nil {
+		nv.Label = n.Meta.Label.Text
+	}
+
+	switch a := n.Attributes.(type) {
+	case *node.InputAttributes:
+		nv.Input = a
+		if nv.Label == "" && a.Label != nil {
+			nv.Label = a.Label.Text
+		}
+	case *node.ImageAttributes:
+		nv.Image = a
+	case *node.AnchorAttributes:
+		nv.Anchor = a
+	case *node.TextAttributes:
+		nv.Text = a
+	case *node.DivisionAttributes:
+		nv.Division = a
+	}
+	return nv
+}
+
+func newErrorView(raw json.RawMessage) errorView {
+	var ev errorView
+	_ = json.Unmarshal(raw, &ev)
+	return ev
+}
+
+// Value returns the value of the input as rendered in the value attribute.
+func (nv nodeView) Value() string {
+	if nv.Input == nil || nv.Input.FieldValue == nil {
+		return ""
+	}
+	switch v := nv.Input.FieldValue.(type) {
+	case string:
+		return v
+	case bool:
+		if nv.Input.Type == node.InputAttributeTypeCheckbox {
+			return "true"
+		}
+	}
+	return fmt.Sprint(nv.Input.FieldValue)
+}
+
+// Checked returns true for checkboxes which are checked.
+func (nv nodeView) Checked() bool {
+	if nv.Input == nil || nv.Input.Type != node.InputAttributeTypeCheckbox {
+		return false
+	}
+	checked, _ := nv.Input.FieldValue.(bool)
+	return checked
+}
+
+// ImageSource returns the source of image nodes. Images may be embedded as data URLs, for
+// example the QR codes of TOTP.
+func (nv nodeView) ImageSource() template.URL {
+	if nv.Image == nil {
+		return ""
+	}
+	src := nv.Image.Source
+	if !strings.HasPrefix(src, "data:image/") && !strings.HasPrefix(src, "https://") && !strings.HasPrefix(src, "http://") {
+		return ""
+	}
+	//nolint:gosec // The scheme is validated above.
+	return template.URL(src)
+}
+
+// IsButton returns true for inputs which are rendered as buttons.
+func (nv nodeView) IsButton() bool {
+	return nv.Input != nil && (nv.Input.Type == node.InputAttributeTypeSubmit || nv.Input.Type == node.InputAttributeTypeButton)
+}
+
+// themeCSS returns the declarations of the configured CSS variables. Malformed variables are
+// skipped.
+func themeCSS(theme map[string]string) template.CSS {
+	names := make([]string, 0, len(theme))
+	for name := range theme {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		if !themeNamePattern.MatchString(name) || !themeValuePattern.MatchString(theme[name]) {
+			continue
+		}
+		_, _ = fmt.Fprintf(&b, "--kratos-%s: %s;", name, theme[name])
+	}
+
+	//nolint:gosec // Names and values are validated above.
+	return template.CSS(b.String())
+}
+
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, p *page) {
+	p.Theme = themeCSS(h.d.Config().SelfServiceHostedUITheme(r.Context()))
+
+	var b bytes.Buffer
+	if err := pageTemplate.Execute(&b, p); err != nil {
+		h.d.Writer().WriteError(w, r, errors.WithStack(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	_, _ = w.Write(b.Bytes())
+}
+
+func (h *Handler) stylesheet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	h.serveAsset(w, r, "templates/hosted.css", "text/css; charset=utf-8")
+}
+
+func (h *Handler) script(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	h.serveAsset(w, r, "templates/hosted.js", "text/javascript; charset=utf-8")
+}
+
+func (h *Handler) serveAsset(w http.ResponseWriter, r *http.Request, name, contentType string) {
+	asset, err := templates.ReadFile(name)
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = w.Write(asset)
+}
@@ -0,0 +1,24 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+// Runs the WebAuthn and passkey triggers of the UI nodes. The triggers are provided by the
+// script nodes of the flow.
+;(function () {
+  function run(trigger) {
+    if (typeof window[trigger] === "function") {
+      window[trigger]()
+    }
+  }
+
+  window.addEventListener("load", function () {
+    document.querySelectorAll("[data-onclick-trigger]").forEach(function (el) {
+      el.addEventListener("click", function (event) {
+        event.preventDefault()
+        run(el.getAttribute("data-onclick-trigger"))
+      })
+    })
+    document.querySelectorAll("[data-onload-trigger]").forEach(function (el) {
+      run(el.getAttribute("data-onload-trigger"))
+    })
+  })
+})()
@@ -0,0 +1,161 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/x"
+)
+
+const (
+	CheckDiscovery           = "discovery"
+	CheckJSONWebKeys         = "json_web_keys"
+	CheckMetadata            = "metadata"
+	CheckSigningCertificates = "signing_certificates"
+	CheckSingleSignOnURL     = "single_sign_on_url"
+)
+
+// SSO Connection Test Result
+//
+// swagger:model ssoConnectionTestResult
+type TestResult struct {
+	// Whether all checks succeeded.
+	//
+	// required: true
+	Success bool `json:"success"`
+
+	// The checks which were performed.
+	//
+	// required: true
+	Checks []TestCheck `json:"checks"`
+}
+
+// SSO Connection Test Check
+//
+// swagger:model ssoConnectionTestCheck
+type TestCheck struct {
+	// The name of the check, e.g. `discovery` or `signing_certificates`.
+	//
+	// required: true
+	Name string `json:"name"`
+
+	// Whether the check succeeded.
+	//
+	// required: true
+	Success bool `json:"success"`
+
+	// Explains why the check failed.
+	Message string `json:"message,omitempty"`
+}
+
+func (r *TestResult) add(name string, err error) bool {
+	check := TestCheck{Name: name, Success: err == nil}
+	if err != nil {
+		check.Message = err.Error()
+	}
+	r.Checks = append(r.Checks, check)
+	r.Success = r.Success && check.Success
+	return check.Success
+}
+
+type checkDependencies interface {
+	x.HTTPClientProvider
+}
+
+// Test checks that the identity provider of the connection is reachable and correctly
+// configured. It does not sign in through the connection.
+func Test(ctx context.Context, d checkDependencies, c *Connection) *TestResult {
+	result := &TestResult{Success: true}
+	switch c.Protocol {
+	case ProtocolOIDC:
+		testOIDC(ctx, d, c, result)
+	case ProtocolSAML:
+		testSAML(ctx, d, c, result)
+	}
+	return result
+}
+
+func testOIDC(ctx context.Context, d checkDependencies, c *Connection, result *TestResult) {
+	provider, err := gooidc.NewProvider(gooidc.ClientContext(ctx, d.HTTPClient(ctx).HTTPClient), c.IssuerURL)
+	if !result.add(CheckDiscovery, err) {
+		return
+	}
+
+	var claims struct {
+		JWKSURL string `json:"jwks_uri"`
+	}
+	if err := provider.Claims(&claims); err != nil || claims.JWKSURL == "" {
+		result.add(CheckJSONWebKeys, errors.New("the discovery document does not contain a jwks_uri"))
+		return
+	}
+
+	res, err := get(ctx, d, claims.JWKSURL)
+	if err != nil {
+		result.add(CheckJSONWebKeys, err)
+		return
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var keys struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if res.StatusCode != http.StatusOK {
+		err = errors.Errorf("the JSON Web Key Set responded with status code %d", res.StatusCode)
+	} else if err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&keys); err != nil {
+		err = errors.Errorf("the JSON Web Key Set could not be decoded: %s", err)
+	} else if len(keys.Keys) == 0 {
+		err = errors.New("the JSON Web Key Set does not contain any keys")
+	}
+	result.add(CheckJSONWebKeys, err)
+}
+
+func testSAML(ctx context.Context, d checkDependencies, c *Connection, result *TestResult) {
+	md, err := ParseIDPMetadata([]byte(c.IDPMetadata))
+	if !result.add(CheckMetadata, err) {
+		return
+	}
+
+	now := time.Now()
+	err = errors.New("none of the signing certificates is currently valid")
+	for _, cert := range md.SigningCertificates {
+		if now.After(cert.NotBefore) && now.Before(cert.NotAfter) {
+			err = nil
+			break
+		}
+	}
+	result.add(CheckSigningCertificates, err)
+
+	res, err := get(ctx, d, md.SSOURL)
+	if err == nil {
+		_ = res.Body.Close()
+		if res.StatusCode >= 500 {
+			err = errors.Errorf("the single sign-on URL responded with status code %d", res.StatusCode)
+		}
+	}
+	result.add(CheckSingleSignOnURL, err)
+}
+
+func get(ctx context.Context, d checkDependencies, u string) (*http.Response, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	client := d.HTTPClient(ctx)
+	client.RetryMax = 0
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Errorf("unable to reach %s: %s", u, err)
+	}
+	return res, nil
+}
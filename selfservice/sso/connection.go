@@ -0,0 +1,229 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sso
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/sqlxx"
+)
+
+// Protocol is the protocol of an SSO connection.
+//
+// swagger:enum ssoConnectionProtocol
+type Protocol string
+
+const (
+	ProtocolOIDC Protocol = "oidc"
+	ProtocolSAML Protocol = "saml"
+)
+
+// AttributeMapping maps identity trait paths (e.g. `email` or `name.first`) to the names of
+// the claims or attributes of the identity provider.
+type AttributeMapping map[string]string
+
+// Scan implements the Scanner interface.
+func (m *AttributeMapping) Scan(value interface{}) error {
+	return sqlxx.JSONScan(m, value)
+}
+
+// Value implements the driver Valuer interface.
+func (m AttributeMapping) Value() (driver.Value, error) {
+	return sqlxx.JSONValue(m)
+}
+
+// SSO Connection
+//
+// An SSO connection connects the identity provider of an organization using OpenID Connect
+// or SAML. Identities signing in through the connection belong to the organization.
+//
+// swagger:model ssoConnection
+type Connection struct {
+	// The connection's ID. For OpenID Connect connections it is also the ID of the provider
+	// used in the self-service flows.
+	//
+	// required: true
+	ID uuid.UUID `json:"id" faker:"-" db:"id"`
+
+	// The ID of the organization the connection belongs to.
+	//
+	// required: true
+	OrganizationID uuid.UUID `json:"organization_id" faker:"-" db:"organization_id"`
+
+	// The protocol of the connection.
+	//
+	// required: true
+	Protocol Protocol `json:"protocol" db:"protocol"`
+
+	// The label shown to users.
+	Label string `json:"label" db:"label"`
+
+	// The OpenID Connect provider type, e.g. `generic`, `microsoft`, or `google`. Only used by
+	// OpenID Connect connections and defaults to `generic`.
+	Provider string `json:"provider,omitempty" db:"provider"`
+
+	// The issuer URL of the OpenID Connect provider.
+	IssuerURL string `json:"issuer_url,omitempty" db:"issuer_url"`
+
+	// The OAuth2 client ID of the OpenID Connect connection.
+	ClientID string `json:"client_id,omitempty" db:"client_id"`
+
+	// The OAuth2 client secret of the OpenID Connect connection. It is never returned.
+	ClientSecret string `json:"client_secret,omitempty" faker:"-" db:"-"`
+
+	// EncryptedClientSecret is the client secret encrypted with the cipher secrets.
+	EncryptedClientSecret string `json:"-" faker:"-" db:"client_secret"`
+
+	// The scopes requested from the OpenID Connect provider.
+	Scope sqlxx.StringSliceJSONFormat `json:"scope,omitempty" db:"scope"`
+
+	// The SAML metadata of the identity provider as XML.
+	IDPMetadata string `json:"idp_metadata,omitempty" db:"idp_metadata"`
+
+	// The entity ID of the SAML identity provider, as read from the metadata.
+	IDPEntityID string `json:"idp_entity_id,omitempty" db:"idp_entity_id"`
+
+	// The single sign-on URL of the SAML identity provider, as read from the metadata.
+	IDPSSOURL string `json:"idp_sso_url,omitempty" db:"idp_sso_url"`
+
+	// Maps identity trait paths (e.g. `email` or `name.first`) to the names of the claims
+	// (OpenID Connect) or attributes (SAML) of the identity provider.
+	//
+	// required: true
+	AttributeMapping AttributeMapping `json:"attribute_mapping" db:"attribute_mapping"`
+
+	// CreatedAt is a helper struct field for gobuffalo.pop.
+	CreatedAt time.Time `json:"created_at" faker:"-" db:"created_at"`
+
+	// UpdatedAt is a helper struct field for gobuffalo.pop.
+	UpdatedAt time.Time `json:"updated_at" faker:"-" db:"updated_at"`
+
+	NID uuid.UUID `json:"-" faker:"-" db:"nid"`
+}
+
+func (c Connection) TableName(context.Context) string {
+	return "sso_connections"
+}
+
+func (c Connection) GetID() uuid.UUID {
+	return c.ID
+}
+
+func (c Connection) GetNID() uuid.UUID {
+	return c.NID
+}
+
+type (
+	Persister interface {
+		CreateSSOConnection(ctx context.Context, c *Connection) error
+		UpdateSSOConnection(ctx context.Context, c *Connection) error
+		GetSSOConnection(ctx context.Context, organizationID, id uuid.UUID) (*Connection, error)
+		// FindSSOConnection returns the connection with the given ID, regardless of the organization.
+		FindSSOConnection(ctx context.Context, id uuid.UUID) (*Connection, error)
+		ListSSOConnections(ctx context.Context, organizationID uuid.UUID) ([]Connection, error)
+		DeleteSSOConnection(ctx context.Context, organizationID, id uuid.UUID) error
+	}
+	PersistenceProvider interface {
+		SSOConnectionPersister() Persister
+	}
+)
+
+// Validate validates the connection and derives the SAML identity provider's entity ID and
+// single sign-on URL from its metadata.
+func (c *Connection) Validate() error {
+	if len(c.AttributeMapping) == 0 {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The attribute mapping must map at least one identity trait."))
+	}
+	for trait, attribute := range c.AttributeMapping {
+		if trait == "" || attribute == "" || slices.Contains(strings.Split(trait, "."), "") {
+			return errors.WithStack(herodot.ErrBadRequest.WithReasonf("The attribute mapping of trait %q is invalid.", trait))
+		}
+		for other := range c.AttributeMapping {
+			if strings.HasPrefix(other, trait+".") {
+				return errors.WithStack(herodot.ErrBadRequest.WithReasonf("The attribute mapping can not map both trait %q and its child trait %q.", trait, other))
+			}
+		}
+	}
+
+	switch c.Protocol {
+	case ProtocolOIDC:
+		if c.Provider == "" {
+			c.Provider = "generic"
+		}
+		if c.IssuerURL == "" || c.ClientID == "" {
+			return errors.WithStack(herodot.ErrBadRequest.WithReason("OpenID Connect connections require the issuer URL and the client ID."))
+		}
+		c.IDPMetadata, c.IDPEntityID, c.IDPSSOURL = "", "", ""
+	case ProtocolSAML:
+		md, err := ParseIDPMetadata([]byte(c.IDPMetadata))
+		if err != nil {
+			return err
+		}
+		c.IDPEntityID, c.IDPSSOURL = md.EntityID, md.SSOURL
+		c.Provider, c.IssuerURL, c.ClientID, c.ClientSecret, c.EncryptedClientSecret, c.Scope = "", "", "", "", "", nil
+	default:
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("The protocol must be one of %q or %q.", ProtocolOIDC, ProtocolSAML))
+	}
+
+	return nil
+}
+
+// Mapper returns a base64 encoded Jsonnet snippet which maps the claims of the identity
+// provider to the identity's traits according to the attribute mapping. Claims which are
+// not present are omitted.
+func (c *Connection) Mapper() string {
+	traits := map[string]any{}
+	for trait, attribute := range c.AttributeMapping {
+		path := strings.Split(trait, ".")
+		node := traits
+		for _, segment := range path[:len(path)-1] {
+			child, ok := node[segment].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				node[segment] = child
+			}
+			node = child
+		}
+		node[path[len(path)-1]] = attribute
+	}
+
+	var b strings.Builder
+	b.WriteString("local claims = std.extVar('claims');\n{\n  identity: {\n    traits: std.prune(")
+	writeMapperObject(&b, traits)
+	b.WriteString("),\n  },\n}\n")
+
+	return "base64://" + base64.StdEncoding.EncodeToString([]byte(b.String()))
+}
+
+func writeMapperObject(b *strings.Builder, node map[string]any) {
+	b.WriteString("{")
+	for k, key := range slices.Sorted(maps.Keys(node)) {
+		if k > 0 {
+			b.WriteString(", ")
+		}
+		name, _ := json.Marshal(key)
+		b.Write(name)
+		b.WriteString(": ")
+		switch v := node[key].(type) {
+		case map[string]any:
+			writeMapperObject(b, v)
+		case string:
+			claim, _ := json.Marshal(v)
+			_, _ = fmt.Fprintf(b, "std.get(claims, %s)", claim)
+		}
+	}
+	b.WriteString("}")
+}
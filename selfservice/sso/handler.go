@@ -0,0 +1,499 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sso
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/cipher"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+)
+
+const (
+	RouteCollection = "/organizations/:organization/sso-connections"
+	RouteItem       = RouteCollection + "/:id"
+	RouteTest       = RouteItem + "/test"
+)
+
+type (
+	handlerDependencies interface {
+		checkDependencies
+		config.Provider
+		PersistenceProvider
+		cipher.Provider
+		x.WriterProvider
+		x.LoggingProvider
+		x.CSRFProvider
+	}
+	Handler struct {
+		r handlerDependencies
+	}
+	HandlerProvider interface {
+		SSOConnectionHandler() *Handler
+	}
+)
+
+func NewHandler(r handlerDependencies) *Handler {
+	return &Handler{r: r}
+}
+
+func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
+	h.r.CSRFHandler().IgnoreGlobs(
+		x.AdminPrefix+"/organizations/*/sso-connections",
+		x.AdminPrefix+"/organizations/*/sso-connections/*",
+		x.AdminPrefix+"/organizations/*/sso-connections/*/test",
+	)
+	public.GET(x.AdminPrefix+RouteCollection, x.RedirectToAdminRoute(h.r))
+	public.POST(x.AdminPrefix+RouteCollection, x.RedirectToAdminRoute(h.r))
+	public.GET(x.AdminPrefix+RouteItem, x.RedirectToAdminRoute(h.r))
+	public.PUT(x.AdminPrefix+RouteItem, x.RedirectToAdminRoute(h.r))
+	public.DELETE(x.AdminPrefix+RouteItem, x.RedirectToAdminRoute(h.r))
+	public.POST(x.AdminPrefix+RouteTest, x.RedirectToAdminRoute(h.r))
+}
+
+func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.GET(RouteCollection, h.listSSOConnections)
+	admin.POST(RouteCollection, h.createSSOConnection)
+	admin.GET(RouteItem, h.getSSOConnection)
+	admin.PUT(RouteItem, h.updateSSOConnection)
+	admin.DELETE(RouteItem, h.deleteSSOConnection)
+	admin.POST(RouteTest, h.testSSOConnection)
+}
+
+// SSO Connection Body
+//
+// swagger:model ssoConnectionBody
+type ConnectionBody struct {
+	// The protocol of the connection, either `oidc` or `saml`.
+	//
+	// required: true
+	Protocol Protocol `json:"protocol"`
+
+	// The label shown to users.
+	Label string `json:"label"`
+
+	// The OpenID Connect provider type, e.g. `generic`, `microsoft`, or `google`. Defaults to `generic`.
+	Provider string `json:"provider"`
+
+	// The issuer URL of the OpenID Connect provider. Required for OpenID Connect connections.
+	IssuerURL string `json:"issuer_url"`
+
+	// The OAuth2 client ID. Required for OpenID Connect connections.
+	ClientID string `json:"client_id"`
+
+	// The OAuth2 client secret. Required when creating an OpenID Connect connection. If it is
+	// omitted when updating a connection, the current client secret is kept.
+	ClientSecret string `json:"client_secret"`
+
+	// The scopes requested from the OpenID Connect provider.
+	Scope []string `json:"scope"`
+
+	// The SAML metadata of the identity provider as XML. Required for SAML connections.
+	IDPMetadata string `json:"idp_metadata"`
+
+	// Maps identity trait paths (e.g. `email` or `name.first`) to the names of the claims
+	// (OpenID Connect) or attributes (SAML) of the identity provider.
+	//
+	// required: true
+	AttributeMapping AttributeMapping `json:"attribute_mapping"`
+}
+
+// Organization Parameters
+//
+// swagger:parameters listSSOConnections
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type listSSOConnections struct {
+	// The ID of the organization.
+	//
+	// required: true
+	// in: path
+	Organization string `json:"organization"`
+}
+
+// List of SSO Connections
+//
+// swagger:response listSSOConnections
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type listSSOConnectionsResponse struct {
+	// in: body
+	Body []Connection
+}
+
+// swagger:route GET /admin/organizations/{organization}/sso-connections identity listSSOConnections
+//
+// # List the SSO Connections of an Organization
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: listSSOConnections
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) listSSOConnections(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	organizationID, err := parseID(ps, "organization")
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	cs, err := h.r.SSOConnectionPersister().ListSSOConnections(r.Context(), organizationID)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	for k := range cs {
+		cs[k].ClientSecret = ""
+	}
+	h.r.Writer().Write(w, r, cs)
+}
+
+// Create SSO Connection Parameters
+//
+// swagger:parameters createSSOConnection
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type createSSOConnection struct {
+	// The ID of the organization.
+	//
+	// required: true
+	// in: path
+	Organization string `json:"organization"`
+
+	// in: body
+	// required: true
+	Body ConnectionBody
+}
+
+// swagger:route POST /admin/organizations/{organization}/sso-connections identity createSSOConnection
+//
+// # Create an SSO Connection
+//
+// Creates an OpenID Connect or SAML connection to the identity provider of an organization. OpenID Connect
+// connections can be used in the self-service flows right away using the connection's ID as the provider.
+// Their redirect URI is `<public-url>/self-service/methods/oidc/organization/<organization>/callback/<id>`.
+//
+// The attribute mapping maps the claims or attributes of the identity provider to the identity's traits.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  201: ssoConnection
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) createSSOConnection(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+
+	organizationID, err := parseID(ps, "organization")
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	c := &Connection{OrganizationID: organizationID}
+	if err := h.decode(r, c); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+	if c.Protocol == ProtocolOIDC && c.ClientSecret == "" {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("OpenID Connect connections require a client secret.")))
+		return
+	}
+	if err := h.encryptClientSecret(r, c); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := h.r.SSOConnectionPersister().CreateSSOConnection(ctx, c); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Audit().
+		WithRequest(r).
+		WithField("organization_id", c.OrganizationID).
+		WithField("sso_connection_id", c.ID).
+		Info("Created an SSO connection.")
+
+	c.ClientSecret = ""
+	h.r.Writer().WriteCreated(w, r, x.AdminPrefix+"/organizations/"+c.OrganizationID.String()+"/sso-connections/"+c.ID.String(), c)
+}
+
+// SSO Connection Parameters
+//
+// swagger:parameters getSSOConnection deleteSSOConnection testSSOConnection
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type ssoConnectionParameters struct {
+	// The ID of the organization.
+	//
+	// required: true
+	// in: path
+	Organization string `json:"organization"`
+
+	// The ID of the SSO connection.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+}
+
+// swagger:route GET /admin/organizations/{organization}/sso-connections/{id} identity getSSOConnection
+//
+// # Get an SSO Connection
+//
+// The client secret is not returned.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: ssoConnection
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) getSSOConnection(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	c, err := h.get(r, ps)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, c)
+}
+
+// Update SSO Connection Parameters
+//
+// swagger:parameters updateSSOConnection
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type updateSSOConnection struct {
+	// The ID of the organization.
+	//
+	// required: true
+	// in: path
+	Organization string `json:"organization"`
+
+	// The ID of the SSO connection.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+
+	// in: body
+	// required: true
+	Body ConnectionBody
+}
+
+// swagger:route PUT /admin/organizations/{organization}/sso-connections/{id} identity updateSSOConnection
+//
+// # Update an SSO Connection
+//
+// Replaces the SSO connection. If the client secret is omitted, the current client secret is kept.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: ssoConnection
+//	  400: errorGeneric
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) updateSSOConnection(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+
+	c, err := h.get(r, ps)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	previousSecret := c.EncryptedClientSecret
+	if err := h.decode(r, c); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+	if c.Protocol == ProtocolOIDC && c.ClientSecret == "" {
+		if previousSecret == "" {
+			h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("OpenID Connect connections require a client secret.")))
+			return
+		}
+		c.EncryptedClientSecret = previousSecret
+	} else if err := h.encryptClientSecret(r, c); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := h.r.SSOConnectionPersister().UpdateSSOConnection(ctx, c); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Audit().
+		WithRequest(r).
+		WithField("organization_id", c.OrganizationID).
+		WithField("sso_connection_id", c.ID).
+		Info("Updated an SSO connection.")
+
+	c.ClientSecret = ""
+	h.r.Writer().Write(w, r, c)
+}
+
+// swagger:route DELETE /admin/organizations/{organization}/sso-connections/{id} identity deleteSSOConnection
+//
+// # Delete an SSO Connection
+//
+// Identities which signed in through the connection are not deleted, but can no longer sign in through it.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  204: emptyResponse
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) deleteSSOConnection(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	organizationID, err := parseID(ps, "organization")
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	id := x.ParseUUID(ps.ByName("id"))
+	if err := h.r.SSOConnectionPersister().DeleteSSOConnection(r.Context(), organizationID, id); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Audit().
+		WithRequest(r).
+		WithField("organization_id", organizationID).
+		WithField("sso_connection_id", id).
+		Info("Deleted an SSO connection.")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:route POST /admin/organizations/{organization}/sso-connections/{id}/test identity testSSOConnection
+//
+// # Test an SSO Connection
+//
+// Checks that the identity provider of the connection is reachable and correctly configured. For OpenID Connect
+// connections, the discovery document and the JSON Web Key Set are fetched. For SAML connections, the metadata
+// and the validity of the signing certificates are checked and the single sign-on URL is requested.
+//
+// The checks do not sign in through the connection. A failed check does not result in an error response.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: ssoConnectionTestResult
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) testSSOConnection(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	c, err := h.get(r, ps)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Writer().Write(w, r, Test(r.Context(), h.r, c))
+}
+
+func (h *Handler) get(r *http.Request, ps httprouter.Params) (*Connection, error) {
+	organizationID, err := parseID(ps, "organization")
+	if err != nil {
+		return nil, err
+	}
+
+	return h.r.SSOConnectionPersister().GetSSOConnection(r.Context(), organizationID, x.ParseUUID(ps.ByName("id")))
+}
+
+func (h *Handler) decode(r *http.Request, c *Connection) error {
+	var body ConnectionBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReason("The request body could not be decoded."))
+	}
+
+	c.Protocol = body.Protocol
+	c.Label = body.Label
+	c.Provider = body.Provider
+	c.IssuerURL = body.IssuerURL
+	c.ClientID = body.ClientID
+	c.ClientSecret = body.ClientSecret
+	c.EncryptedClientSecret = ""
+	c.Scope = body.Scope
+	c.IDPMetadata = body.IDPMetadata
+	c.AttributeMapping = body.AttributeMapping
+	return c.Validate()
+}
+
+func (h *Handler) encryptClientSecret(r *http.Request, c *Connection) (err error) {
+	if c.ClientSecret == "" {
+		return nil
+	}
+
+	c.EncryptedClientSecret, err = h.r.Cipher(r.Context()).Encrypt(r.Context(), []byte(c.ClientSecret))
+	return err
+}
+
+func parseID(ps httprouter.Params, name string) (uuid.UUID, error) {
+	id, err := uuid.FromString(ps.ByName(name))
+	if err != nil {
+		return uuid.Nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The %s ID must be a UUID.", name))
+	}
+	return id, nil
+}
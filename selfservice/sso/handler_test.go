@@ -0,0 +1,318 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sso_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/sso"
+	"github.com/ory/kratos/selfservice/strategy/oidc"
+	"github.com/ory/x/urlx"
+)
+
+func newIDPMetadata(t *testing.T, ssoURL string, notAfter time.Time) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+	}, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return fmt.Sprintf(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/metadata">
+  <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <KeyDescriptor use="signing">
+      <KeyInfo xmlns="http://www.w3.org/2000/09/xmldsig#"><X509Data><X509Certificate>%s</X509Certificate></X509Data></KeyInfo>
+    </KeyDescriptor>
+    <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="%s/post"/>
+    <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="%s"/>
+  </IDPSSODescriptor>
+</EntityDescriptor>`, base64.StdEncoding.EncodeToString(der), ssoURL, ssoURL)
+}
+
+func newOIDCServer(t *testing.T, keys string) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 ts.URL,
+				"authorization_endpoint": ts.URL + "/oauth2/auth",
+				"token_endpoint":         ts.URL + "/oauth2/token",
+				"jwks_uri":               ts.URL + "/jwks.json",
+			})
+		case "/jwks.json":
+			_, _ = w.Write([]byte(keys))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.StrategyEnable(t, conf, identity.CredentialsTypeOIDC.String(), true)
+	_, adminTS := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	orgID := uuid.Must(uuid.NewV4())
+	collection := func(orgID uuid.UUID) string {
+		return adminTS.URL + "/admin/organizations/" + orgID.String() + "/sso-connections"
+	}
+
+	oidcServer := newOIDCServer(t, `{"keys":[{"kty":"RSA","kid":"a","n":"AQAB","e":"AQAB"}]}`)
+	createOIDC := func(t *testing.T) gjson.Result {
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", collection(orgID), map[string]any{
+			"protocol":          "oidc",
+			"label":             "Acme",
+			"issuer_url":        oidcServer.URL,
+			"client_id":         "client",
+			"client_secret":     "secret",
+			"scope":             []string{"openid", "email"},
+			"attribute_mapping": map[string]string{"email": "email", "name.first": "given_name"},
+		})
+		require.Equal(t, http.StatusCreated, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=creates and reads an OpenID Connect connection", func(t *testing.T) {
+		actual := createOIDC(t)
+		id := actual.Get("id").String()
+		assert.Equal(t, orgID.String(), actual.Get("organization_id").String())
+		assert.Equal(t, "generic", actual.Get("provider").String())
+		assert.False(t, actual.Get("client_secret").Exists(), "%s", actual.Raw)
+
+		stored, err := reg.SSOConnectionPersister().GetSSOConnection(ctx, orgID, uuid.FromStringOrNil(id))
+		require.NoError(t, err)
+		assert.NotEqual(t, "secret", stored.EncryptedClientSecret)
+
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "GET", collection(orgID)+"/"+id, nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.Equal(t, "given_name", gjson.GetBytes(body, "attribute_mapping.name\\.first").String(), "%s", body)
+		assert.False(t, gjson.GetBytes(body, "client_secret").Exists(), "%s", body)
+
+		body, res = testhelpers.HTTPRequestJSON(t, http.DefaultClient, "GET", collection(orgID), nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.Contains(t, gjson.GetBytes(body, "#.id").String(), id)
+
+		t.Run("case=is scoped to the organization", func(t *testing.T) {
+			other := uuid.Must(uuid.NewV4())
+			body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "GET", collection(other)+"/"+id, nil)
+			assert.Equal(t, http.StatusNotFound, res.StatusCode, "%s", body)
+
+			body, res = testhelpers.HTTPRequestJSON(t, http.DefaultClient, "GET", collection(other), nil)
+			require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+			assert.JSONEq(t, "[]", string(body))
+
+			body, res = testhelpers.HTTPRequestJSON(t, http.DefaultClient, "DELETE", collection(other)+"/"+id, nil)
+			assert.Equal(t, http.StatusNotFound, res.StatusCode, "%s", body)
+		})
+
+		t.Run("case=is used as OpenID Connect provider", func(t *testing.T) {
+			s, err := reg.AllLoginStrategies().Strategy(identity.CredentialsTypeOIDC)
+			require.NoError(t, err)
+			provider, err := s.(*oidc.Strategy).Provider(ctx, id)
+			require.NoError(t, err)
+
+			pc := provider.Config()
+			assert.Equal(t, "secret", pc.ClientSecret)
+			assert.Equal(t, orgID.String(), pc.OrganizationID)
+			assert.Contains(t, pc.Redir(urlx.ParseOrPanic("https://www.ory.sh")), "/organization/"+orgID.String()+"/callback/"+id)
+
+			mapper, err := base64.StdEncoding.DecodeString(pc.Mapper[len("base64://"):])
+			require.NoError(t, err)
+			vm, err := reg.JsonnetVM(ctx)
+			require.NoError(t, err)
+			vm.ExtCode("claims", `{"email":"foo@example.com","given_name":"Foo"}`)
+			out, err := vm.EvaluateAnonymousSnippet("mapper.jsonnet", string(mapper))
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"identity":{"traits":{"email":"foo@example.com","name":{"first":"Foo"}}}}`, out)
+		})
+
+		t.Run("case=update keeps the client secret", func(t *testing.T) {
+			body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "PUT", collection(orgID)+"/"+id, map[string]any{
+				"protocol":          "oidc",
+				"label":             "Acme Corp",
+				"issuer_url":        oidcServer.URL,
+				"client_id":         "client",
+				"attribute_mapping": map[string]string{"email": "email"},
+			})
+			require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+			assert.Equal(t, "Acme Corp", gjson.GetBytes(body, "label").String())
+
+			s, err := reg.AllLoginStrategies().Strategy(identity.CredentialsTypeOIDC)
+			require.NoError(t, err)
+			provider, err := s.(*oidc.Strategy).Provider(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, "secret", provider.Config().ClientSecret)
+			assert.Equal(t, "Acme Corp", provider.Config().Label)
+		})
+
+		t.Run("case=test succeeds", func(t *testing.T) {
+			body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", collection(orgID)+"/"+id+"/test", nil)
+			require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+			assert.True(t, gjson.GetBytes(body, "success").Bool(), "%s", body)
+			assert.Equal(t, `["discovery","json_web_keys"]`, gjson.GetBytes(body, "checks.#.name").Raw)
+		})
+
+		t.Run("case=deletes the connection", func(t *testing.T) {
+			body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "DELETE", collection(orgID)+"/"+id, nil)
+			require.Equal(t, http.StatusNoContent, res.StatusCode, "%s", body)
+
+			body, res = testhelpers.HTTPRequestJSON(t, http.DefaultClient, "GET", collection(orgID)+"/"+id, nil)
+			assert.Equal(t, http.StatusNotFound, res.StatusCode, "%s", body)
+
+			s, err := reg.AllLoginStrategies().Strategy(identity.CredentialsTypeOIDC)
+			require.NoError(t, err)
+			_, err = s.(*oidc.Strategy).Provider(ctx, id)
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("case=test reports failing checks", func(t *testing.T) {
+		emptyServer := newOIDCServer(t, `{"keys":[]}`)
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", collection(orgID), map[string]any{
+			"protocol":          "oidc",
+			"issuer_url":        emptyServer.URL,
+			"client_id":         "client",
+			"client_secret":     "secret",
+			"attribute_mapping": map[string]string{"email": "email"},
+		})
+		require.Equal(t, http.StatusCreated, res.StatusCode, "%s", body)
+
+		body, res = testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", collection(orgID)+"/"+gjson.GetBytes(body, "id").String()+"/test", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.False(t, gjson.GetBytes(body, "success").Bool(), "%s", body)
+		assert.Equal(t, `[true,false]`, gjson.GetBytes(body, "checks.#.success").Raw, "%s", body)
+		assert.Equal(t, "the JSON Web Key Set does not contain any keys", gjson.GetBytes(body, "checks.1.message").String(), "%s", body)
+	})
+
+	t.Run("case=creates and tests a SAML connection", func(t *testing.T) {
+		idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		t.Cleanup(idp.Close)
+
+		body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", collection(orgID), map[string]any{
+			"protocol":          "saml",
+			"label":             "Acme SAML",
+			"client_id":         "ignored",
+			"idp_metadata":      newIDPMetadata(t, idp.URL, time.Now().Add(-time.Hour)),
+			"attribute_mapping": map[string]string{"email": "urn:oid:0.9.2342.19200300.100.1.3"},
+		})
+		require.Equal(t, http.StatusCreated, res.StatusCode, "%s", body)
+		assert.Equal(t, "https://idp.example.com/metadata", gjson.GetBytes(body, "idp_entity_id").String())
+		assert.Equal(t, idp.URL, gjson.GetBytes(body, "idp_sso_url").String())
+		assert.False(t, gjson.GetBytes(body, "client_id").Exists(), "%s", body)
+
+		id := gjson.GetBytes(body, "id").String()
+		body, res = testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", collection(orgID)+"/"+id+"/test", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.False(t, gjson.GetBytes(body, "success").Bool(), "%s", body)
+		assert.Equal(t, `[true,false,true]`, gjson.GetBytes(body, "checks.#.success").Raw, "%s", body)
+		assert.Equal(t, "signing_certificates", gjson.GetBytes(body, "checks.1.name").String(), "%s", body)
+
+		t.Run("case=is not used as OpenID Connect provider", func(t *testing.T) {
+			s, err := reg.AllLoginStrategies().Strategy(identity.CredentialsTypeOIDC)
+			require.NoError(t, err)
+			_, err = s.(*oidc.Strategy).Provider(ctx, id)
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("case=rejects invalid connections", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			body   map[string]any
+			reason string
+		}{
+			{
+				name:   "unknown protocol",
+				body:   map[string]any{"protocol": "ldap", "attribute_mapping": map[string]string{"email": "mail"}},
+				reason: "The protocol must be one of",
+			},
+			{
+				name:   "missing attribute mapping",
+				body:   map[string]any{"protocol": "oidc", "issuer_url": "https://idp.example.com", "client_id": "a", "client_secret": "b"},
+				reason: "The attribute mapping must map at least one identity trait.",
+			},
+			{
+				name:   "conflicting attribute mapping",
+				body:   map[string]any{"protocol": "oidc", "issuer_url": "https://idp.example.com", "client_id": "a", "client_secret": "b", "attribute_mapping": map[string]string{"name": "name", "name.first": "given_name"}},
+				reason: `The attribute mapping can not map both trait "name" and its child trait "name.first".`,
+			},
+			{
+				name:   "missing issuer URL",
+				body:   map[string]any{"protocol": "oidc", "client_id": "a", "client_secret": "b", "attribute_mapping": map[string]string{"email": "email"}},
+				reason: "OpenID Connect connections require the issuer URL and the client ID.",
+			},
+			{
+				name:   "missing client secret",
+				body:   map[string]any{"protocol": "oidc", "issuer_url": "https://idp.example.com", "client_id": "a", "attribute_mapping": map[string]string{"email": "email"}},
+				reason: "OpenID Connect connections require a client secret.",
+			},
+			{
+				name:   "invalid SAML metadata",
+				body:   map[string]any{"protocol": "saml", "idp_metadata": `<EntityDescriptor entityID="a"></EntityDescriptor>`, "attribute_mapping": map[string]string{"email": "mail"}},
+				reason: "The SAML identity provider metadata is invalid: the IDPSSODescriptor is missing.",
+			},
+		} {
+			t.Run("case="+tc.name, func(t *testing.T) {
+				body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "POST", collection(orgID), tc.body)
+				require.Equal(t, http.StatusBadRequest, res.StatusCode, "%s", body)
+				assert.Contains(t, gjson.GetBytes(body, "error.reason").String(), tc.reason)
+			})
+		}
+
+		t.Run("case=invalid organization ID", func(t *testing.T) {
+			body, res := testhelpers.HTTPRequestJSON(t, http.DefaultClient, "GET", adminTS.URL+"/admin/organizations/not-a-uuid/sso-connections", nil)
+			require.Equal(t, http.StatusBadRequest, res.StatusCode, "%s", body)
+		})
+	})
+}
+
+func TestParseIDPMetadata(t *testing.T) {
+	t.Run("case=prefers the HTTP-Redirect binding", func(t *testing.T) {
+		md, err := sso.ParseIDPMetadata([]byte(newIDPMetadata(t, "https://idp.example.com/sso", time.Now().Add(time.Hour))))
+		require.NoError(t, err)
+		assert.Equal(t, "https://idp.example.com/metadata", md.EntityID)
+		assert.Equal(t, "https://idp.example.com/sso", md.SSOURL)
+		require.Len(t, md.SigningCertificates, 1)
+	})
+
+	t.Run("case=requires an absolute single sign-on URL", func(t *testing.T) {
+		_, err := sso.ParseIDPMetadata([]byte(newIDPMetadata(t, "/sso", time.Now().Add(time.Hour))))
+		var he *herodot.DefaultError
+		require.ErrorAs(t, err, &he)
+		assert.Equal(t, "The SAML identity provider metadata is invalid: the single sign-on service location must be an absolute http or https URL.", he.Reason())
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package sso
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+const (
+	samlBindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	samlBindingHTTPPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// IDPMetadata contains the parts of a SAML identity provider's metadata which are required
+// to sign in through it.
+type IDPMetadata struct {
+	EntityID string
+	SSOURL   string
+
+	// SigningCertificates are the certificates used by the identity provider to sign assertions.
+	SigningCertificates []*x509.Certificate
+}
+
+type samlEntityDescriptor struct {
+	XMLName          xml.Name `xml:"EntityDescriptor"`
+	EntityID         string   `xml:"entityID,attr"`
+	IDPSSODescriptor *struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// ParseIDPMetadata parses the SAML metadata of an identity provider.
+func ParseIDPMetadata(raw []byte) (*IDPMetadata, error) {
+	invalid := func(reason string) error {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("The SAML identity provider metadata is invalid: %s", reason))
+	}
+
+	var ed samlEntityDescriptor
+	if err := xml.Unmarshal(raw, &ed); err != nil {
+		return nil, invalid(err.Error())
+	}
+	if ed.EntityID == "" {
+		return nil, invalid("the entity ID is missing.")
+	}
+	if ed.IDPSSODescriptor == nil {
+		return nil, invalid("the IDPSSODescriptor is missing.")
+	}
+
+	md := &IDPMetadata{EntityID: ed.EntityID}
+	for _, binding := range []string{samlBindingHTTPRedirect, samlBindingHTTPPost} {
+		for _, s := range ed.IDPSSODescriptor.SingleSignOnServices {
+			if s.Binding == binding && md.SSOURL == "" {
+				md.SSOURL = s.Location
+			}
+		}
+	}
+	if md.SSOURL == "" {
+		return nil, invalid("no single sign-on service with the HTTP-Redirect or HTTP-POST binding is defined.")
+	}
+	if u, err := url.Parse(md.SSOURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, invalid("the single sign-on service location must be an absolute http or https URL.")
+	}
+
+	for _, kd := range ed.IDPSSODescriptor.KeyDescriptors {
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+		for _, encoded := range kd.Certificates {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+			if err != nil {
+				return nil, invalid("a signing certificate is not base64 encoded.")
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, invalid("a signing certificate could not be parsed.")
+			}
+			md.SigningCertificates = append(md.SigningCertificates, cert)
+		}
+	}
+	if len(md.SigningCertificates) == 0 {
+		return nil, invalid("no signing certificate is defined.")
+	}
+
+	return md, nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/gofrs/uuid"
 
 	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/x"
 )
 
 // swagger:ignore
@@ -69,7 +70,7 @@ func (f *LoginCode) Validate() error {
 	if f == nil {
 		return errors.WithStack(ErrCodeNotFound)
 	}
-	if f.ExpiresAt.Before(time.Now().UTC()) {
+	if f.ExpiresAt.Before(x.Now().UTC()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 	if f.UsedAt.Valid {
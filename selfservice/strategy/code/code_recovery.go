@@ -17,6 +17,7 @@ import (
 	"github.com/ory/herodot"
 
 	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/x"
 )
 
 type RecoveryCodeType int
@@ -81,7 +82,7 @@ func (f *RecoveryCode) Validate() error {
 	if f == nil {
 		return errors.WithStack(ErrCodeNotFound)
 	}
-	if f.ExpiresAt.Before(time.Now().UTC()) {
+	if f.ExpiresAt.Before(x.Now().UTC()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 	if f.UsedAt.Valid {
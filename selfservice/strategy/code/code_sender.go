@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ory/herodot"
+	"github.com/ory/kratos/courier/template"
 	"github.com/ory/kratos/courier/template/email"
 	"github.com/ory/kratos/courier/template/sms"
 
@@ -115,6 +116,7 @@ func (s *Sender) SendCode(ctx context.Context, f flow.Flow, id *identity.Identit
 					RegistrationCode: rawCode,
 					Traits:           model,
 					RequestURL:       f.GetRequestURL(),
+					Branding:         template.Branding{Brand: f.GetBrand()},
 					TransientPayload: transientPayload,
 					ExpiresInMinutes: int(s.deps.Config().SelfServiceCodeMethodLifespan(ctx).Minutes()),
 				})
@@ -124,6 +126,7 @@ func (s *Sender) SendCode(ctx context.Context, f flow.Flow, id *identity.Identit
 					RegistrationCode: rawCode,
 					Identity:         model,
 					RequestURL:       f.GetRequestURL(),
+					Branding:         template.Branding{Brand: f.GetBrand()},
 					TransientPayload: transientPayload,
 					ExpiresInMinutes: int(s.deps.Config().SelfServiceCodeMethodLifespan(ctx).Minutes()),
 				})
@@ -166,6 +169,7 @@ func (s *Sender) SendCode(ctx context.Context, f flow.Flow, id *identity.Identit
 					LoginCode:        rawCode,
 					Identity:         model,
 					RequestURL:       f.GetRequestURL(),
+					Branding:         template.Branding{Brand: f.GetBrand()},
 					TransientPayload: transientPayload,
 					ExpiresInMinutes: int(s.deps.Config().SelfServiceCodeMethodLifespan(ctx).Minutes()),
 				})
@@ -175,6 +179,7 @@ func (s *Sender) SendCode(ctx context.Context, f flow.Flow, id *identity.Identit
 					LoginCode:        rawCode,
 					Identity:         model,
 					RequestURL:       f.GetRequestURL(),
+					Branding:         template.Branding{Brand: f.GetBrand()},
 					TransientPayload: transientPayload,
 					ExpiresInMinutes: int(s.deps.Config().SelfServiceCodeMethodLifespan(ctx).Minutes()),
 				})
@@ -205,28 +210,7 @@ func (s *Sender) SendRecoveryCode(ctx context.Context, f *recovery.Flow, via ide
 
 	address, err := s.deps.IdentityPool().FindRecoveryAddressByValue(ctx, identity.RecoveryAddressTypeEmail, to)
 	if errors.Is(err, sqlcon.ErrNoRows) {
-		notifyUnknownRecipients := s.deps.Config().SelfServiceFlowRecoveryNotifyUnknownRecipients(ctx)
-		s.deps.Audit().
-			WithField("via", via).
-			WithSensitiveField("email_address", address).
-			WithField("strategy", "code").
-			WithField("was_notified", notifyUnknownRecipients).
-			Info("Account recovery was requested for an unknown address.")
-
-		transientPayload, err := x.ParseRawMessageOrEmpty(f.GetTransientPayload())
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		if !notifyUnknownRecipients {
-			// do nothing
-		} else if err := s.send(ctx, string(via), email.NewRecoveryCodeInvalid(s.deps, &email.RecoveryCodeInvalidModel{
-			To:               to,
-			RequestURL:       f.RequestURL,
-			TransientPayload: transientPayload,
-		})); err != nil {
-			return err
-		}
-		return errors.WithStack(ErrUnknownAddress)
+		return s.sendRecoveryCodeInvalid(ctx, f, via, to, "Account recovery was requested for an unknown address.")
 	} else if err != nil {
 		// DB error
 		return err
@@ -238,6 +222,11 @@ func (s *Sender) SendRecoveryCode(ctx context.Context, f *recovery.Flow, via ide
 		return err
 	}
 
+	if s.deps.Config().SelfServiceFlowVerificationRequiredForRecovery(ctx) && !i.IsAddressVerified(address.Value) {
+		// Unverified addresses are treated like unknown addresses to prevent account enumeration.
+		return s.sendRecoveryCodeInvalid(ctx, f, via, to, "Account recovery was requested for an unverified address.")
+	}
+
 	rawCode := GenerateCode()
 
 	var code *RecoveryCode
@@ -254,7 +243,39 @@ func (s *Sender) SendRecoveryCode(ctx context.Context, f *recovery.Flow, via ide
 		return err
 	}
 
-	return s.SendRecoveryCodeTo(ctx, i, rawCode, code, f)
+	if err := s.SendRecoveryCodeTo(ctx, i, rawCode, code, f); err != nil {
+		return err
+	}
+
+	return s.sendRecoveryPushApproval(ctx, f, i, address)
+}
+
+// sendRecoveryCodeInvalid notifies the recipient, if enabled, that recovery is not possible for the
+// address and returns ErrUnknownAddress.
+func (s *Sender) sendRecoveryCodeInvalid(ctx context.Context, f *recovery.Flow, via identity.VerifiableAddressType, to, reason string) error {
+	notifyUnknownRecipients := s.deps.Config().SelfServiceFlowRecoveryNotifyUnknownRecipients(ctx)
+	s.deps.Audit().
+		WithField("via", via).
+		WithSensitiveField("email_address", to).
+		WithField("strategy", "code").
+		WithField("was_notified", notifyUnknownRecipients).
+		Info(reason)
+
+	transientPayload, err := x.ParseRawMessageOrEmpty(f.GetTransientPayload())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !notifyUnknownRecipients {
+		// do nothing
+	} else if err := s.send(ctx, string(via), email.NewRecoveryCodeInvalid(s.deps, &email.RecoveryCodeInvalidModel{
+		To:               to,
+		RequestURL:       f.RequestURL,
+		Branding:         template.Branding{Brand: f.GetBrand()},
+		TransientPayload: transientPayload,
+	})); err != nil {
+		return err
+	}
+	return errors.WithStack(ErrUnknownAddress)
 }
 
 func (s *Sender) SendRecoveryCodeTo(ctx context.Context, i *identity.Identity, codeString string, code *RecoveryCode, f *recovery.Flow) error {
@@ -281,6 +302,7 @@ func (s *Sender) SendRecoveryCodeTo(ctx context.Context, i *identity.Identity, c
 		RecoveryCode:     codeString,
 		Identity:         model,
 		RequestURL:       f.GetRequestURL(),
+		Branding:         template.Branding{Brand: f.GetBrand()},
 		TransientPayload: transientPayload,
 		ExpiresInMinutes: int(s.deps.Config().SelfServiceCodeMethodLifespan(ctx).Minutes()),
 	}
@@ -318,6 +340,7 @@ func (s *Sender) SendVerificationCode(ctx context.Context, f *verification.Flow,
 		} else if err := s.send(ctx, string(via), email.NewVerificationCodeInvalid(s.deps, &email.VerificationCodeInvalidModel{
 			To:               to,
 			RequestURL:       f.GetRequestURL(),
+			Branding:         template.Branding{Brand: f.GetBrand()},
 			TransientPayload: transientPayload,
 		})); err != nil {
 			return err
@@ -345,7 +368,11 @@ func (s *Sender) SendVerificationCode(ctx context.Context, f *verification.Flow,
 		return err
 	}
 
-	return s.SendVerificationCodeTo(ctx, f, i, rawCode, code)
+	if err := s.SendVerificationCodeTo(ctx, f, i, rawCode, code); err != nil {
+		return err
+	}
+
+	return s.sendVerificationPushApproval(ctx, f, i, address)
 }
 
 func (s *Sender) constructVerificationLink(ctx context.Context, fID uuid.UUID, codeStr string) string {
@@ -387,6 +414,7 @@ func (s *Sender) SendVerificationCodeTo(ctx context.Context, f *verification.Flo
 			Identity:         model,
 			VerificationCode: codeString,
 			RequestURL:       f.GetRequestURL(),
+			Branding:         template.Branding{Brand: f.GetBrand()},
 			TransientPayload: transientPayload,
 			ExpiresInMinutes: int(s.deps.Config().SelfServiceCodeMethodLifespan(ctx).Minutes()),
 		})
@@ -396,6 +424,7 @@ func (s *Sender) SendVerificationCodeTo(ctx context.Context, f *verification.Flo
 			VerificationCode: codeString,
 			Identity:         model,
 			RequestURL:       f.GetRequestURL(),
+			Branding:         template.Branding{Brand: f.GetBrand()},
 			TransientPayload: transientPayload,
 			ExpiresInMinutes: int(s.deps.Config().SelfServiceCodeMethodLifespan(ctx).Minutes()),
 		})
@@ -0,0 +1,395 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package code
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/courier/template"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/recovery"
+	"github.com/ory/kratos/selfservice/flow/verification"
+	"github.com/ory/kratos/selfservice/strategy/devicekey"
+	"github.com/ory/kratos/text"
+	"github.com/ory/x/decoderx"
+	"github.com/ory/x/randx"
+	"github.com/ory/x/urlx"
+)
+
+const (
+	RoutePushApproval = "/self-service/code/push-approval"
+
+	// pushChallengePrefix distinguishes push challenges from codes. Push challenges are stored like
+	// codes, but they may only be used through the push approval endpoint.
+	pushChallengePrefix = "push_"
+	pushChallengeLength = 32
+)
+
+func GeneratePushChallenge() string {
+	return pushChallengePrefix + randx.MustString(pushChallengeLength, randx.AlphaNum)
+}
+
+func isPushChallenge(code string) bool {
+	return strings.HasPrefix(code, pushChallengePrefix)
+}
+
+// sendRecoveryPushApproval sends a push notification asking to approve the recovery flow to
+// every device of the identity which has registered a push token.
+func (s *Sender) sendRecoveryPushApproval(ctx context.Context, f *recovery.Flow, i *identity.Identity, address *identity.RecoveryAddress) error {
+	if !s.deps.Config().SelfServiceCodeMethodPushApprovalEnabled(ctx) {
+		return nil
+	}
+
+	keys, err := s.pushDeviceKeys(ctx, i.ID)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	challenge := GeneratePushChallenge()
+	if _, err := s.deps.RecoveryCodePersister().CreateRecoveryCode(ctx, &CreateRecoveryCodeParams{
+		RawCode:         challenge,
+		CodeType:        RecoveryCodeTypeSelfService,
+		ExpiresIn:       s.deps.Config().SelfServiceCodeMethodLifespan(ctx),
+		RecoveryAddress: address,
+		FlowID:          f.ID,
+		IdentityID:      i.ID,
+	}); err != nil {
+		return err
+	}
+
+	return s.sendPushApproval(ctx, f, i.ID, keys, challenge, &courier.PushNotification{
+		Type:  template.TypeRecoveryPushApproval,
+		Title: "Account recovery requested",
+		Body:  "Someone asked to recover your account. Approve the request if it was you.",
+	})
+}
+
+// sendVerificationPushApproval sends a push notification asking to approve the verification of
+// the address to every device of the identity which has registered a push token.
+func (s *Sender) sendVerificationPushApproval(ctx context.Context, f *verification.Flow, i *identity.Identity, address *identity.VerifiableAddress) error {
+	if !s.deps.Config().SelfServiceCodeMethodPushApprovalEnabled(ctx) {
+		return nil
+	}
+
+	keys, err := s.pushDeviceKeys(ctx, i.ID)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	challenge := GeneratePushChallenge()
+	if _, err := s.deps.VerificationCodePersister().CreateVerificationCode(ctx, &CreateVerificationCodeParams{
+		RawCode:           challenge,
+		ExpiresIn:         s.deps.Config().SelfServiceCodeMethodLifespan(ctx),
+		VerifiableAddress: address,
+		FlowID:            f.ID,
+	}); err != nil {
+		return err
+	}
+
+	return s.sendPushApproval(ctx, f, i.ID, keys, challenge, &courier.PushNotification{
+		Type:  template.TypeVerificationPushApproval,
+		Title: "Address verification requested",
+		Body:  "Someone asked to verify " + address.Value + ". Approve the request if it was you.",
+		Data:  map[string]string{"address": address.Value},
+	})
+}
+
+func (s *Sender) pushDeviceKeys(ctx context.Context, identityID uuid.UUID) ([]identity.CredentialDeviceKey, error) {
+	i, err := s.deps.PrivilegedIdentityPool().GetIdentityConfidential(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, ok := i.GetCredentials(identity.CredentialsTypeDeviceKey)
+	if !ok {
+		return nil, nil
+	}
+
+	var cc identity.CredentialsDeviceKeyConfig
+	if err := json.Unmarshal(cred.Config, &cc); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode identity credentials.").WithDebug(err.Error()))
+	}
+
+	keys := make([]identity.CredentialDeviceKey, 0, len(cc.Keys))
+	for _, key := range cc.Keys {
+		if len(key.PushToken) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *Sender) sendPushApproval(ctx context.Context, f flow.Flow, identityID uuid.UUID, keys []identity.CredentialDeviceKey, challenge string, n *courier.PushNotification) error {
+	c, err := s.deps.Courier(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		data := map[string]string{
+			"flow_id":       f.GetID().String(),
+			"flow":          string(f.GetFlowName()),
+			"identity_id":   identityID.String(),
+			"device_key_id": key.ID,
+			"challenge":     challenge,
+			"approval_url":  urlx.AppendPaths(s.deps.Config().SelfPublicURL(ctx), RoutePushApproval).String(),
+		}
+		for k, v := range n.Data {
+			data[k] = v
+		}
+
+		s.deps.Audit().
+			WithField("identity_id", identityID).
+			WithField("flow_id", f.GetID()).
+			WithField("device_key_id", key.ID).
+			WithField("push_platform", key.PushPlatform).
+			Infof("Sending out %s approval push notification.", f.GetFlowName())
+
+		if _, err := c.QueuePush(ctx, &courier.PushNotification{
+			Type:        n.Type,
+			Platform:    key.PushPlatform,
+			DeviceToken: key.PushToken,
+			Title:       n.Title,
+			Body:        n.Body,
+			Data:        data,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Approve Push Challenge Request Body
+//
+// swagger:model approvePushChallengeBody
+type approvePushChallengeBody struct {
+	// The ID of the flow to approve, as sent in the push notification.
+	//
+	// required: true
+	FlowID uuid.UUID `json:"flow_id"`
+
+	// The type of the flow to approve, either `recovery` or `verification`.
+	//
+	// required: true
+	Flow flow.FlowName `json:"flow"`
+
+	// The ID of the identity, as sent in the push notification.
+	//
+	// required: true
+	IdentityID uuid.UUID `json:"identity_id"`
+
+	// The ID of the device key the push notification was sent to.
+	//
+	// required: true
+	DeviceKeyID string `json:"device_key_id"`
+
+	// The challenge sent in the push notification.
+	//
+	// required: true
+	Challenge string `json:"challenge"`
+
+	// The base64-encoded signature of the challenge, created with the private device key.
+	// ECDSA signatures are ASN.1 encoded and computed over the SHA-256 digest of the challenge.
+	//
+	// required: true
+	Signature string `json:"signature"`
+}
+
+// Approve Push Challenge Parameters
+//
+// swagger:parameters approvePushChallenge
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type approvePushChallenge struct {
+	// in: body
+	Body approvePushChallengeBody
+}
+
+// swagger:route POST /self-service/code/push-approval frontend approvePushChallenge
+//
+// # Approve a Recovery or Verification Flow from a Device
+//
+// Native apps call this endpoint after the user approved a recovery or verification request in a
+// push notification. The app proves possession of the device key the notification was sent to by
+// signing the challenge of the notification.
+//
+// Approving a verification flow verifies the address. Approving a recovery flow allows the client
+// which started the flow to complete it by submitting the flow without a code.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  204: emptyResponse
+//	  400: errorGeneric
+//	  403: errorGeneric
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (s *Strategy) approvePushChallenge(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+	if !s.deps.Config().SelfServiceCodeMethodPushApprovalEnabled(ctx) {
+		s.deps.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("Push approvals are disabled.")))
+		return
+	}
+
+	var p approvePushChallengeBody
+	if err := s.dx.Decode(r, &p, decoderx.HTTPJSONDecoder()); err != nil {
+		s.deps.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if err := s.verifyPushChallengeSignature(ctx, &p); err != nil {
+		s.deps.Writer().WriteError(w, r, err)
+		return
+	}
+
+	var err error
+	switch p.Flow {
+	case flow.RecoveryFlow:
+		err = s.approveRecoveryPushChallenge(ctx, &p)
+	case flow.VerificationFlow:
+		err = s.approveVerificationPushChallenge(w, r, &p)
+	default:
+		err = errors.WithStack(herodot.ErrBadRequest.WithReasonf("Push approvals are not supported for %q flows.", p.Flow))
+	}
+	if err != nil {
+		s.deps.Writer().WriteError(w, r, err)
+		return
+	}
+
+	s.deps.Audit().
+		WithRequest(r).
+		WithField("identity_id", p.IdentityID).
+		WithField("flow_id", p.FlowID).
+		WithField("device_key_id", p.DeviceKeyID).
+		Infof("A %s flow was approved from a device.", p.Flow)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Strategy) verifyPushChallengeSignature(ctx context.Context, p *approvePushChallengeBody) error {
+	if !isPushChallenge(p.Challenge) {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The challenge is invalid."))
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(p.Signature)
+	if err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The signature must be base64 encoded."))
+	}
+
+	i, err := s.deps.PrivilegedIdentityPool().GetIdentityConfidential(ctx, p.IdentityID)
+	if err != nil {
+		return err
+	}
+
+	var cc identity.CredentialsDeviceKeyConfig
+	if cred, ok := i.GetCredentials(identity.CredentialsTypeDeviceKey); ok {
+		if err := json.Unmarshal(cred.Config, &cc); err != nil {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode identity credentials.").WithDebug(err.Error()))
+		}
+	}
+
+	key := cc.Find(p.DeviceKeyID)
+	if key == nil {
+		return errors.WithStack(herodot.ErrForbidden.WithReason("The device key is not registered for this identity."))
+	}
+
+	if err := devicekey.VerifySignature(key.PublicKey, []byte(p.Challenge), signature); err != nil {
+		return errors.WithStack(herodot.ErrForbidden.WithReason("The signature of the challenge is invalid.").WithDebug(err.Error()))
+	}
+
+	return nil
+}
+
+func (s *Strategy) approveRecoveryPushChallenge(ctx context.Context, p *approvePushChallengeBody) error {
+	f, err := s.deps.RecoveryFlowPersister().GetRecoveryFlow(ctx, p.FlowID)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Valid(); err != nil {
+		return err
+	}
+
+	if f.State != flow.StateEmailSent {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The recovery flow can not be approved in its current state."))
+	}
+
+	code, err := s.deps.RecoveryCodePersister().UseRecoveryCode(ctx, f.ID, p.Challenge)
+	if err != nil {
+		return err
+	}
+
+	if code.IdentityID != p.IdentityID {
+		return errors.WithStack(herodot.ErrForbidden.WithReason("The challenge was not issued for this identity."))
+	}
+
+	// The client which started the flow completes the recovery by submitting the flow without a code.
+	f.RecoveredIdentityID = uuid.NullUUID{UUID: code.IdentityID, Valid: true}
+	f.UI.Messages.Set(text.NewRecoveryPushApproved())
+	f.UI.Nodes.Remove("code")
+
+	return s.deps.RecoveryFlowPersister().UpdateRecoveryFlow(ctx, f)
+}
+
+func (s *Strategy) approveVerificationPushChallenge(w http.ResponseWriter, r *http.Request, p *approvePushChallengeBody) error {
+	ctx := r.Context()
+
+	f, err := s.deps.VerificationFlowPersister().GetVerificationFlow(ctx, p.FlowID)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Valid(); err != nil {
+		return err
+	}
+
+	if f.State != flow.StateEmailSent {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The verification flow can not be approved in its current state."))
+	}
+
+	code, err := s.deps.VerificationCodePersister().UseVerificationCode(ctx, f.ID, p.Challenge)
+	if err != nil {
+		return err
+	}
+
+	if code.VerifiableAddress.IdentityID != p.IdentityID {
+		return errors.WithStack(herodot.ErrForbidden.WithReason("The challenge was not issued for this identity."))
+	}
+
+	i, err := s.verificationPassChallenge(ctx, f, code)
+	if err != nil {
+		return err
+	}
+
+	if err := s.deps.VerificationFlowPersister().UpdateVerificationFlow(ctx, f); err != nil {
+		return err
+	}
+
+	return s.deps.VerificationExecutor().PostVerificationHook(w, r, f, i)
+}
+
+// recoveryPushApproved returns true if the flow was approved on a device and the client which
+// started the flow may complete it without a code.
+func recoveryPushApproved(f *recovery.Flow, body *recoverySubmitPayload) bool {
+	return f.State == flow.StateEmailSent && f.RecoveredIdentityID.Valid && len(body.Code) == 0
+}
@@ -0,0 +1,206 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package code_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/ioutilx"
+	"github.com/ory/x/sqlxx"
+)
+
+func TestPushApproval(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	initViper(t, ctx, conf)
+	conf.MustSet(ctx, config.ViperKeyCodeConfigPushApprovalEnabled, true)
+	conf.MustSet(ctx, config.ViperKeyUseContinueWithTransitions, true)
+
+	_ = testhelpers.NewVerificationUIFlowEchoServer(t, reg)
+	_ = testhelpers.NewRecoveryUIFlowEchoServer(t, reg)
+	_ = testhelpers.NewErrorTestServer(t, reg)
+	public, _ := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	createIdentity := func(t *testing.T) (*identity.Identity, string, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+
+		keyID := x.NewUUID().String()
+		cc, err := json.Marshal(identity.CredentialsDeviceKeyConfig{Keys: []identity.CredentialDeviceKey{{
+			ID:           keyID,
+			DisplayName:  "phone",
+			PublicKey:    der,
+			PushToken:    "device-token-" + keyID,
+			PushPlatform: courier.PushPlatformFCM,
+		}}})
+		require.NoError(t, err)
+
+		email := testhelpers.RandomEmail()
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.ID = x.NewUUID()
+		i.Traits = identity.Traits(`{"email":"` + email + `"}`)
+		i.SetCredentials(identity.CredentialsTypeDeviceKey, identity.Credentials{
+			Type:        identity.CredentialsTypeDeviceKey,
+			Identifiers: []string{i.ID.String()},
+			Config:      sqlxx.JSONRawMessage(cc),
+		})
+		require.NoError(t, reg.IdentityManager().Create(ctx, i, identity.ManagerAllowWriteProtectedTraits))
+		return i, keyID, key
+	}
+
+	expectPushNotification := func(t *testing.T, keyID, title string) map[string]string {
+		message := testhelpers.CourierExpectMessage(ctx, t, reg, "device-token-"+keyID, title)
+		assert.Equal(t, courier.MessageTypePush, message.Type)
+		n, err := courier.NewPushNotificationFromMessage(*message)
+		require.NoError(t, err)
+		assert.Equal(t, courier.PushPlatformFCM, n.Platform)
+		return n.Data
+	}
+
+	approve := func(t *testing.T, data map[string]string, key *ecdsa.PrivateKey, expectedStatus int) string {
+		digest := sha256.Sum256([]byte(data["challenge"]))
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+
+		payload, err := json.Marshal(map[string]string{
+			"flow_id":       data["flow_id"],
+			"flow":          data["flow"],
+			"identity_id":   data["identity_id"],
+			"device_key_id": data["device_key_id"],
+			"challenge":     data["challenge"],
+			"signature":     base64.StdEncoding.EncodeToString(signature),
+		})
+		require.NoError(t, err)
+
+		res, err := public.Client().Post(data["approval_url"], "application/json", bytes.NewReader(payload))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body := ioutilx.MustReadAll(res.Body)
+		require.Equal(t, expectedStatus, res.StatusCode, "%s", body)
+		return string(body)
+	}
+
+	t.Run("flow=verification", func(t *testing.T) {
+		i, keyID, key := createIdentity(t)
+		email := i.VerifiableAddresses[0].Value
+
+		hc := testhelpers.NewDebugClient(t)
+		f := testhelpers.InitializeVerificationFlowViaAPI(t, hc, public)
+		body, res := testhelpers.VerificationMakeRequest(t, true, f, hc, testhelpers.EncodeFormAsJSON(t, true, url.Values{
+			"method": {"code"},
+			"email":  {email},
+		}))
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+
+		data := expectPushNotification(t, keyID, "Address verification requested")
+		assert.Equal(t, f.Id, data["flow_id"])
+		assert.Equal(t, string(flow.VerificationFlow), data["flow"])
+		assert.Equal(t, i.ID.String(), data["identity_id"])
+		assert.Equal(t, email, data["address"])
+		assert.Equal(t, public.URL+code.RoutePushApproval, data["approval_url"])
+
+		t.Run("case=the challenge can not be used as a code", func(t *testing.T) {
+			body, _ := testhelpers.VerificationMakeRequest(t, true, f, hc, testhelpers.EncodeFormAsJSON(t, true, url.Values{
+				"method": {"code"},
+				"code":   {data["challenge"]},
+			}))
+			assert.EqualValues(t, text.ErrorValidationVerificationCodeInvalidOrAlreadyUsed, gjson.Get(body, "ui.messages.0.id").Int(), "%s", body)
+		})
+
+		t.Run("case=rejects signatures of other keys", func(t *testing.T) {
+			other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			require.NoError(t, err)
+			approve(t, data, other, http.StatusForbidden)
+		})
+
+		approve(t, data, key, http.StatusNoContent)
+
+		actual, err := reg.VerificationFlowPersister().GetVerificationFlow(ctx, x.ParseUUID(f.Id))
+		require.NoError(t, err)
+		assert.Equal(t, flow.StatePassedChallenge, actual.State)
+
+		address, err := reg.IdentityPool().FindVerifiableAddressByValue(ctx, identity.VerifiableAddressTypeEmail, email)
+		require.NoError(t, err)
+		assert.True(t, address.Verified)
+
+		t.Run("case=the challenge can only be used once", func(t *testing.T) {
+			approve(t, data, key, http.StatusBadRequest)
+		})
+	})
+
+	t.Run("flow=recovery", func(t *testing.T) {
+		i, keyID, key := createIdentity(t)
+		email := i.RecoveryAddresses[0].Value
+
+		hc := testhelpers.NewDebugClient(t)
+		f := testhelpers.InitializeRecoveryFlowViaAPI(t, hc, public)
+		body, res := testhelpers.RecoveryMakeRequest(t, true, f, hc, testhelpers.EncodeFormAsJSON(t, true, url.Values{
+			"method": {"code"},
+			"email":  {email},
+		}))
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+
+		data := expectPushNotification(t, keyID, "Account recovery requested")
+		assert.Equal(t, string(flow.RecoveryFlow), data["flow"])
+
+		t.Run("case=the flow can not be completed before it is approved", func(t *testing.T) {
+			body, _ := testhelpers.RecoveryMakeRequest(t, true, f, hc, testhelpers.EncodeFormAsJSON(t, true, url.Values{
+				"method": {"code"},
+			}))
+			assert.EqualValues(t, text.ErrorValidationRecoveryCodeInvalidOrAlreadyUsed, gjson.Get(body, "ui.messages.0.id").Int(), "%s", body)
+		})
+
+		approve(t, data, key, http.StatusNoContent)
+
+		actual, err := reg.RecoveryFlowPersister().GetRecoveryFlow(ctx, x.ParseUUID(f.Id))
+		require.NoError(t, err)
+		assert.Equal(t, flow.StateEmailSent, actual.State)
+		assert.EqualValues(t, text.InfoSelfServiceRecoveryPushApproved, actual.UI.Messages[0].ID)
+
+		body, res = testhelpers.RecoveryMakeRequest(t, true, f, hc, testhelpers.EncodeFormAsJSON(t, true, url.Values{
+			"method": {"code"},
+		}))
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Equal(t, string(flow.StatePassedChallenge), gjson.Get(body, "state").String(), "%s", body)
+		assert.EqualValues(t, flow.ContinueWithActionSetOrySessionTokenString, gjson.Get(body, "continue_with.#(action==set_ory_session_token).action").String(), "%s", body)
+	})
+
+	t.Run("case=push approvals are disabled", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyCodeConfigPushApprovalEnabled, false)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyCodeConfigPushApprovalEnabled, true)
+		})
+
+		res, err := public.Client().Post(public.URL+code.RoutePushApproval, "application/json", bytes.NewReader([]byte(`{}`)))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+}
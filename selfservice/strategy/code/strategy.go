@@ -70,6 +70,8 @@ type (
 		x.LoggingProvider
 		x.TracingProvider
 		x.TransactionPersistenceProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
 
 		config.Provider
 
@@ -308,7 +310,7 @@ func (s *Strategy) populateChooseMethodFlow(r *http.Request, f flow.Flow) error
 				// we won't find the address in the list of addresses.
 				//
 				// Since we don't know if the via parameter is an email address or a phone number, we need to normalize for both.
-				value = x.GracefulNormalization(value)
+				value = x.GracefulNormalization(value, s.deps.Config().IdentityPhoneDefaultRegion(ctx))
 
 				addresses, found, err := FindCodeAddressCandidates(sess.Identity, s.deps.Config().SelfServiceCodeMethodMissingCredentialFallbackEnabled(ctx))
 				if err != nil {
@@ -340,7 +342,7 @@ func (s *Strategy) populateChooseMethodFlow(r *http.Request, f flow.Flow) error
 		}
 
 	case *registration.Flow:
-		ds, err := s.deps.Config().DefaultIdentityTraitsSchemaURL(ctx)
+		ds, err := f.IdentitySchemaURL(ctx, s.deps.Config())
 		if err != nil {
 			return err
 		}
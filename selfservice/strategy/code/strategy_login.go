@@ -80,6 +80,12 @@ type updateLoginFlowWithCodeMethod struct {
 	//
 	// required: false
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" form:"transient_payload"`
+
+	// Remember the session for its full lifespan. Only used if remember me is enabled. API flows
+	// which omit this field use the default of the login method.
+	//
+	// required: false
+	Remember *bool `json:"remember,omitempty" form:"remember"`
 }
 
 func (s *Strategy) RegisterLoginRoutes(*x.RouterPublic) {}
@@ -96,6 +102,30 @@ func (s *Strategy) CompletedAuthenticationMethod(ctx context.Context) session.Au
 	}
 }
 
+var _ identity.ActiveIdentityMultiFactorCounter = new(Strategy)
+
+// CountActiveMultiFactorCredentialsOfIdentity counts the addresses a second factor code can be sent to. If
+// the identity has no code credential addresses and the missing credential fallback is enabled, codes are
+// sent to its verifiable addresses instead (see findIdentityForIdentifier), so these are counted.
+func (s *Strategy) CountActiveMultiFactorCredentialsOfIdentity(ctx context.Context, i *identity.Identity) (int, error) {
+	count, err := s.CountActiveMultiFactorCredentials(ctx, i.Credentials)
+	if err != nil || count > 0 {
+		return count, err
+	}
+
+	if !s.deps.Config().SelfServiceCodeStrategy(ctx).MFAEnabled ||
+		!s.deps.Config().SelfServiceCodeMethodMissingCredentialFallbackEnabled(ctx) {
+		return 0, nil
+	}
+
+	for _, va := range i.VerifiableAddresses {
+		if _, err := identity.NewCodeChannel(va.Via); err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (s *Strategy) HandleLoginError(r *http.Request, f *login.Flow, body *updateLoginFlowWithCodeMethod, err error) error {
 	if errors.Is(err, flow.ErrCompletedByStrategy) {
 		return err
@@ -281,7 +311,7 @@ func (s *Strategy) findIdentityForIdentifier(ctx context.Context, identifier str
 		return nil, nil, errors.WithStack(schema.NewRequiredError("#/identifier", "identifier"))
 	}
 
-	identifier = x.GracefulNormalization(identifier)
+	identifier = x.GracefulNormalization(identifier, s.deps.Config().IdentityPhoneDefaultRegion(ctx))
 
 	var addresses []Address
 
@@ -327,6 +357,11 @@ func (s *Strategy) findIdentityForIdentifier(ctx context.Context, identifier str
 			}
 		}
 		return nil, nil, err
+	} else if requestedAAL == identity.AuthenticatorAssuranceLevel2 && session != nil && i.ID != session.IdentityID {
+		// The second factor must be completed with an address of the identity that completed the first factor.
+		// Otherwise, codes could be sent to the addresses of other identities.
+		span.SetAttributes(attribute.String("not_responsible_reason", "identifier belongs to another identity"))
+		return nil, nil, errors.WithStack(schema.NewNoCodeAuthnCredentials())
 	} else if isFallback {
 		fallbackAllowed := s.deps.Config().SelfServiceCodeMethodMissingCredentialFallbackEnabled(ctx)
 		span.SetAttributes(
@@ -394,8 +429,10 @@ func (s *Strategy) loginSendCode(ctx context.Context, w http.ResponseWriter, r *
 		return err
 	}
 
+	emails, region := s.deps.Config().IdentityEmailNormalization(ctx), s.deps.Config().IdentityPhoneDefaultRegion(ctx)
 	if address, found := lo.Find(addresses, func(item Address) bool {
-		return item.To == x.GracefulNormalization(p.Identifier)
+		return item.To == x.GracefulNormalization(p.Identifier, region) ||
+			(item.Via == identity.CodeChannelEmail && x.CanonicalizeEmailIdentifier(item.To, emails) == x.CanonicalizeEmailIdentifier(p.Identifier, emails))
 	}); found {
 		addresses = []Address{address}
 	}
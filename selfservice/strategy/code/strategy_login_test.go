@@ -26,6 +26,7 @@ import (
 	"github.com/ory/kratos/selfservice/flow"
 
 	"github.com/ory/x/ioutilx"
+	"github.com/ory/x/pagination/keysetpagination"
 	"github.com/ory/x/snapshotx"
 	"github.com/ory/x/sqlcon"
 	"github.com/ory/x/stringsx"
@@ -401,7 +402,7 @@ func TestLoginCodeStrategy(t *testing.T) {
 					v.Set("identifier", phone)
 				}, false, nil)
 
-				message := testhelpers.CourierExpectMessage(ctx, t, reg, x.GracefulNormalization(phone), "Your login code is:")
+				message := testhelpers.CourierExpectMessage(ctx, t, reg, x.GracefulNormalization(phone, ""), "Your login code is:")
 				loginCode := testhelpers.CourierExpectCodeInMessage(t, message, 1)
 				assert.NotEmpty(t, loginCode)
 
@@ -988,11 +989,11 @@ func TestLoginCodeStrategy(t *testing.T) {
 						var message *courier.Message
 						if !strings.HasPrefix(identifier, "+") {
 							// email
-							message = testhelpers.CourierExpectMessage(ctx, t, reg, x.GracefulNormalization(identifier), "Use code")
+							message = testhelpers.CourierExpectMessage(ctx, t, reg, x.GracefulNormalization(identifier, ""), "Use code")
 							assert.Contains(t, message.Body, "Login to your account with the following code")
 						} else {
 							// SMS
-							message = testhelpers.CourierExpectMessage(ctx, t, reg, x.GracefulNormalization(identifier), "Your login code is:")
+							message = testhelpers.CourierExpectMessage(ctx, t, reg, x.GracefulNormalization(identifier, ""), "Your login code is:")
 						}
 						loginCode := testhelpers.CourierExpectCodeInMessage(t, message, 1)
 						assert.NotEmpty(t, loginCode)
@@ -1053,6 +1054,40 @@ func TestLoginCodeStrategy(t *testing.T) {
 					require.Equal(t, "This account does not exist or has not setup sign in with code.", gjson.Get(s.body, "ui.messages.0.text").String(), "%s", body)
 				})
 
+				t.Run("case=cannot use identifier of another identity", func(t *testing.T) {
+					identity := createIdentity(ctx, t, reg, false)
+					other := createIdentity(ctx, t, reg, false)
+					otherEmail := gjson.Get(other.Traits.String(), "email").String()
+
+					var cl *http.Client
+					var f *oryClient.LoginFlow
+					if tc.apiType == ApiTypeNative {
+						cl = testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, identity)
+						f = testhelpers.InitializeLoginFlowViaAPI(t, cl, public, false, testhelpers.InitFlowWithAAL("aal2"))
+					} else {
+						cl = testhelpers.NewHTTPClientWithIdentitySessionCookieLocalhost(t, ctx, reg, identity)
+						f = testhelpers.InitializeLoginFlowViaBrowser(t, cl, public, false, tc.apiType == ApiTypeSPA, false, false, testhelpers.InitFlowWithAAL("aal2"))
+					}
+
+					s := &state{
+						flowID:        f.GetId(),
+						identity:      identity,
+						client:        cl,
+						testServer:    public,
+						identityEmail: gjson.Get(identity.Traits.String(), "email").String(),
+					}
+					s = submitLogin(ctx, t, s, tc.apiType, func(v *url.Values) {
+						v.Del("address")
+						v.Set("identifier", otherEmail)
+					}, false, nil)
+
+					require.Equal(t, "This account does not exist or has not setup sign in with code.", gjson.Get(s.body, "ui.messages.0.text").String(), "%s", s.body)
+
+					_, total, _, err := reg.CourierPersister().ListMessages(ctx, courier.ListCourierMessagesParameters{Recipient: otherEmail}, []keysetpagination.Option{})
+					require.NoError(t, err)
+					assert.Zero(t, total)
+				})
+
 				t.Run("case=verify initial payload", func(t *testing.T) {
 					fixedEmail := fmt.Sprintf("fixed_mfa_test_%s@ory.sh", tc.apiType)
 					identity := createIdentity(ctx, t, reg, false, fixedEmail)
@@ -1117,6 +1152,80 @@ func TestLoginCodeStrategy(t *testing.T) {
 	}
 }
 
+func TestCountActiveMultiFactorCredentialsOfIdentity(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/default.schema.json")
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypeCodeAuth)+".mfa_enabled", true)
+
+	s, err := reg.AllLoginStrategies().Strategy(identity.CredentialsTypeCodeAuth)
+	require.NoError(t, err)
+	counter, ok := s.(identity.ActiveIdentityMultiFactorCounter)
+	require.True(t, ok)
+
+	newIdentity := func(credentials string) *identity.Identity {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.VerifiableAddresses = []identity.VerifiableAddress{{Value: testhelpers.RandomEmail(), Via: identity.AddressTypeEmail}}
+		if credentials != "" {
+			i.Credentials = map[identity.CredentialsType]identity.Credentials{identity.CredentialsTypeCodeAuth: {
+				Type:   identity.CredentialsTypeCodeAuth,
+				Config: sqlxx.JSONRawMessage(credentials),
+			}}
+		}
+		return i
+	}
+
+	for _, tc := range []struct {
+		desc        string
+		credentials string
+		fallback    bool
+		mfaEnabled  bool
+		expected    int
+	}{
+		{desc: "counts the code credential addresses", credentials: `{"addresses":[{"channel":"email","address":"a@ory.sh"},{"channel":"sms","address":"+1234567890"}]}`, mfaEnabled: true, expected: 2},
+		{desc: "prefers the code credential addresses over the fallback", credentials: `{"addresses":[{"channel":"sms","address":"+1234567890"}]}`, fallback: true, mfaEnabled: true, expected: 1},
+		{desc: "counts the verifiable addresses with the fallback", fallback: true, mfaEnabled: true, expected: 1},
+		{desc: "counts the verifiable addresses of empty code credentials with the fallback", credentials: `{"addresses":[]}`, fallback: true, mfaEnabled: true, expected: 1},
+		{desc: "does not count the verifiable addresses without the fallback", mfaEnabled: true, expected: 0},
+		{desc: "does not count anything if mfa is disabled", credentials: `{"addresses":[{"channel":"email","address":"a@ory.sh"}]}`, fallback: true, expected: 0},
+	} {
+		t.Run("case="+tc.desc, func(t *testing.T) {
+			ctx := configtesthelpers.WithConfigValue(ctx, config.ViperKeyCodeConfigMissingCredentialFallbackEnabled, tc.fallback)
+			ctx = configtesthelpers.WithConfigValue(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypeCodeAuth)+".mfa_enabled", tc.mfaEnabled)
+
+			actual, err := counter.CountActiveMultiFactorCredentialsOfIdentity(ctx, newIdentity(tc.credentials))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+
+	t.Run("case=highest available aal requires a code for identities using the fallback", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyCodeConfigMissingCredentialFallbackEnabled, true)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyCodeConfigMissingCredentialFallbackEnabled, nil)
+		})
+
+		email := testhelpers.RandomEmail()
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(fmt.Sprintf(`{"tos": true, "email": "%s"}`, email))
+		i.VerifiableAddresses = []identity.VerifiableAddress{{Value: email, Via: identity.AddressTypeEmail, Status: identity.VerifiableAddressStatusPending}}
+		i.Credentials = map[identity.CredentialsType]identity.Credentials{
+			identity.CredentialsTypePassword: {Type: identity.CredentialsTypePassword, Identifiers: []string{email}, Config: sqlxx.JSONRawMessage(`{}`)},
+		}
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentities(ctx, i)) // We explicitly bypass identity validation to test the legacy code path
+
+		i, err := reg.IdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		sess := &session.Session{IdentityID: i.ID, Identity: i, AMR: session.AuthenticationMethods{{Method: identity.CredentialsTypePassword, AAL: identity.AuthenticatorAssuranceLevel1}}}
+		var aalErr *session.ErrAALNotSatisfied
+		assert.ErrorAs(t, reg.SessionManager().DoesSessionSatisfy(ctx, sess, config.HighestAvailableAAL), &aalErr)
+
+		conf.MustSet(ctx, config.ViperKeyCodeConfigMissingCredentialFallbackEnabled, false)
+		sess.Identity.InternalAvailableAAL = identity.NullableAuthenticatorAssuranceLevel{}
+		assert.NoError(t, reg.SessionManager().DoesSessionSatisfy(ctx, sess, config.HighestAvailableAAL))
+	})
+}
+
 func TestFormHydration(t *testing.T) {
 	ctx := context.Background()
 	conf, reg := internal.NewFastRegistryWithMocks(t)
@@ -213,12 +213,24 @@ func (s *Strategy) recoveryIssueSession(w http.ResponseWriter, r *http.Request,
 			return s.retryRecoveryFlow(w, r, f.Type, RetryWithError(err))
 		}
 		f.ContinueWith = append(f.ContinueWith, flow.NewContinueWithSetToken(sess.Token))
+
+		deepLink, err := s.deps.Config().SelfServiceFlowRecoveryNativeDeepLink(ctx)
+		if err != nil {
+			return s.retryRecoveryFlow(w, r, f.Type, RetryWithError(err))
+		} else if deepLink != nil {
+			c, err := flow.NewContinueWithNativeDeepLink(ctx, s.deps, deepLink, f, id.ID, nil)
+			if err != nil {
+				return s.retryRecoveryFlow(w, r, f.Type, RetryWithError(err))
+			}
+			f.ContinueWith = append(f.ContinueWith, c)
+		}
 	}
 
 	sf, err := s.deps.SettingsHandler().NewFlow(ctx, w, r, sess.Identity, f.Type)
 	if err != nil {
 		return s.retryRecoveryFlow(w, r, f.Type, RetryWithError(err))
 	}
+	sf.Brand = f.Brand
 
 	returnToURL := s.deps.Config().SelfServiceFlowRecoveryReturnTo(r.Context(), nil)
 	returnTo := ""
@@ -259,7 +271,22 @@ func (s *Strategy) recoveryIssueSession(w http.ResponseWriter, r *http.Request,
 
 func (s *Strategy) recoveryUseCode(w http.ResponseWriter, r *http.Request, body *recoverySubmitPayload, f *recovery.Flow) error {
 	ctx := r.Context()
-	code, err := s.deps.RecoveryCodePersister().UseRecoveryCode(ctx, f.ID, body.Code)
+	if recoveryPushApproved(f, body) {
+		recovered, err := s.deps.IdentityPool().GetIdentity(ctx, f.RecoveredIdentityID.UUID, identity.ExpandEverything)
+		if err != nil {
+			return s.HandleRecoveryError(w, r, f, nil, err)
+		}
+		return s.recoveryIssueSession(w, r, f, recovered)
+	}
+
+	var code *RecoveryCode
+	var err error
+	if isPushChallenge(body.Code) {
+		// Push challenges may only be used through the push approval endpoint.
+		err = errors.WithStack(ErrCodeNotFound)
+	} else {
+		code, err = s.deps.RecoveryCodePersister().UseRecoveryCode(ctx, f.ID, body.Code)
+	}
 	if errors.Is(err, ErrCodeNotFound) {
 		f.UI.Messages.Clear()
 		f.UI.Messages.Add(text.NewErrorValidationRecoveryCodeInvalidOrAlreadyUsed())
@@ -388,6 +415,8 @@ func (s *Strategy) recoveryHandleFormSubmission(w http.ResponseWriter, r *http.R
 		return s.HandleRecoveryError(w, r, f, body, err)
 	}
 
+	// A new code invalidates previous approvals.
+	f.RecoveredIdentityID = uuid.NullUUID{}
 	f.TransientPayload = body.TransientPayload
 	if err := s.deps.CodeSender().SendRecoveryCode(ctx, f, identity.VerifiableAddressTypeEmail, body.Email); err != nil {
 		if !errors.Is(err, ErrUnknownAddress) {
@@ -605,6 +605,31 @@ func TestRecovery(t *testing.T) {
 		})
 	})
 
+	t.Run("description=should not send a code to an unverified address if verification is required", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceVerificationRequiredForRecovery, true)
+		conf.MustSet(ctx, config.ViperKeySelfServiceRecoveryNotifyUnknownRecipients, true)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceVerificationRequiredForRecovery, false)
+			conf.MustSet(ctx, config.ViperKeySelfServiceRecoveryNotifyUnknownRecipients, false)
+		})
+
+		for _, flowType := range flowTypeCases {
+			t.Run("type="+string(flowType.ClientType), func(t *testing.T) {
+				email := "recoverunverified_" + string(flowType.ClientType) + "@ory.sh"
+				createIdentityToRecover(t, reg, email)
+
+				body := submitRecovery(t, flowType.GetClient(t), flowType.ClientType, func(v url.Values) {
+					v.Set("email", email)
+				}, http.StatusOK)
+				assert.EqualValues(t, node.CodeGroup, gjson.Get(body, "active").String(), "%s", body)
+				assertx.EqualAsJSON(t, text.NewRecoveryEmailWithCodeSent(), json.RawMessage(gjson.Get(body, "ui.messages.0").Raw))
+
+				message := testhelpers.CourierExpectMessage(ctx, t, reg, email, "Account access attempted")
+				assert.Contains(t, message.Body, "If this was you, check if you signed up using a different address.")
+			})
+		}
+	})
+
 	t.Run("description=should not be able to recover an inactive account", func(t *testing.T) {
 		for _, flowType := range flowTypeCases {
 			t.Run("type="+string(flowType.ClientType), func(t *testing.T) {
@@ -627,10 +652,10 @@ func TestRecovery(t *testing.T) {
 
 				if flowType.ClientType == RecoveryClientTypeAPI || flowType.ClientType == RecoveryClientTypeSPA {
 					body = submitRecoveryCode(t, cl, body, flowType.ClientType, recoveryCode, http.StatusUnauthorized)
-					assertx.EqualAsJSON(t, session.ErrIdentityDisabled.WithDetail("identity_id", addr.IdentityID), json.RawMessage(gjson.Get(body, "error").Raw), "%s", body)
+					assertx.EqualAsJSON(t, session.ErrIdentityDisabled.WithDetail("identity_id", addr.IdentityID).WithDetail("state", identity.StateInactive), json.RawMessage(gjson.Get(body, "error").Raw), "%s", body)
 				} else {
 					body = submitRecoveryCode(t, cl, body, flowType.ClientType, recoveryCode, http.StatusOK)
-					assertx.EqualAsJSON(t, session.ErrIdentityDisabled.WithDetail("identity_id", addr.IdentityID), json.RawMessage(body), "%s", body)
+					assertx.EqualAsJSON(t, session.ErrIdentityDisabled.WithDetail("identity_id", addr.IdentityID).WithDetail("state", identity.StateInactive), json.RawMessage(body), "%s", body)
 				}
 			})
 		}
@@ -1458,10 +1483,10 @@ func TestRecovery_WithContinueWith(t *testing.T) {
 					fallthrough
 				case RecoveryClientTypeSPA:
 					body = submitRecoveryCode(t, cl, body, testCase.ClientType, recoveryCode, http.StatusUnauthorized)
-					assertx.EqualAsJSON(t, session.ErrIdentityDisabled.WithDetail("identity_id", addr.IdentityID), json.RawMessage(gjson.Get(body, "error").Raw), "%s", body)
+					assertx.EqualAsJSON(t, session.ErrIdentityDisabled.WithDetail("identity_id", addr.IdentityID).WithDetail("state", identity.StateInactive), json.RawMessage(gjson.Get(body, "error").Raw), "%s", body)
 				default:
 					body = submitRecoveryCode(t, cl, body, testCase.ClientType, recoveryCode, http.StatusOK)
-					assertx.EqualAsJSON(t, session.ErrIdentityDisabled.WithDetail("identity_id", addr.IdentityID), json.RawMessage(body), "%s", body)
+					assertx.EqualAsJSON(t, session.ErrIdentityDisabled.WithDetail("identity_id", addr.IdentityID).WithDetail("state", identity.StateInactive), json.RawMessage(body), "%s", body)
 				}
 			})
 		}
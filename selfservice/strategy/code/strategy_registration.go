@@ -138,7 +138,7 @@ func (s *Strategy) Register(w http.ResponseWriter, r *http.Request, f *registrat
 	}
 
 	var p updateRegistrationFlowWithCodeMethod
-	if err := registration.DecodeBody(&p, r, s.dx, s.deps.Config(), registrationSchema); err != nil {
+	if err := registration.DecodeBody(&p, r, s.dx, s.deps.Config(), f, registrationSchema); err != nil {
 		return s.HandleRegistrationError(ctx, r, f, &p, err)
 	}
 
@@ -30,6 +30,8 @@ func (s *Strategy) VerificationStrategyID() string {
 }
 
 func (s *Strategy) RegisterPublicVerificationRoutes(public *x.RouterPublic) {
+	s.deps.CSRFHandler().IgnorePath(RoutePushApproval)
+	public.POST(RoutePushApproval, s.approvePushChallenge)
 }
 
 func (s *Strategy) RegisterAdminVerificationRoutes(admin *x.RouterAdmin) {
@@ -240,7 +242,14 @@ func (s *Strategy) verificationHandleFormSubmission(ctx context.Context, w http.
 }
 
 func (s *Strategy) verificationUseCode(ctx context.Context, w http.ResponseWriter, r *http.Request, codeString string, f *verification.Flow) error {
-	code, err := s.deps.VerificationCodePersister().UseVerificationCode(ctx, f.ID, codeString)
+	var code *VerificationCode
+	var err error
+	if isPushChallenge(codeString) {
+		// Push challenges may only be used through the push approval endpoint.
+		err = errors.WithStack(ErrCodeNotFound)
+	} else {
+		code, err = s.deps.VerificationCodePersister().UseVerificationCode(ctx, f.ID, codeString)
+	}
 	if errors.Is(err, ErrCodeNotFound) {
 		f.UI.Messages.Clear()
 		f.UI.Messages.Add(text.NewErrorValidationVerificationCodeInvalidOrAlreadyUsed())
@@ -258,18 +267,56 @@ func (s *Strategy) verificationUseCode(ctx context.Context, w http.ResponseWrite
 		return s.retryVerificationFlowWithError(ctx, w, r, f.Type, err)
 	}
 
+	i, err := s.verificationPassChallenge(ctx, f, code)
+	if err != nil {
+		return s.retryVerificationFlowWithError(ctx, w, r, f.Type, err)
+	}
+	address := code.VerifiableAddress
+
+	// See https://github.com/ory/kratos/issues/1547
+	f.SetCSRFToken(flow.GetCSRFToken(s.deps, w, r, f.Type))
+
+	if err := s.deps.VerificationFlowPersister().UpdateVerificationFlow(ctx, f); err != nil {
+		return s.retryVerificationFlowWithError(ctx, w, r, flow.TypeBrowser, err)
+	}
+
+	if f.Type.IsAPI() {
+		deepLink, err := s.deps.Config().SelfServiceFlowVerificationNativeDeepLink(ctx)
+		if err != nil {
+			return s.retryVerificationFlowWithError(ctx, w, r, f.Type, err)
+		} else if deepLink != nil {
+			c, err := flow.NewContinueWithNativeDeepLink(ctx, s.deps, deepLink, f, i.ID, map[string]any{
+				"verified_address": address.Value,
+			})
+			if err != nil {
+				return s.retryVerificationFlowWithError(ctx, w, r, f.Type, err)
+			}
+			f.ContinueWith = append(f.ContinueWith, c)
+		}
+	}
+
+	if err := s.deps.VerificationExecutor().PostVerificationHook(w, r, f, i); err != nil {
+		return s.retryVerificationFlowWithError(ctx, w, r, f.Type, err)
+	}
+
+	return nil
+}
+
+// verificationPassChallenge marks the address of the code as verified and moves the flow to the
+// passed challenge state. The caller persists the flow.
+func (s *Strategy) verificationPassChallenge(ctx context.Context, f *verification.Flow, code *VerificationCode) (*identity.Identity, error) {
 	address := code.VerifiableAddress
 	address.Verified = true
 	verifiedAt := sqlxx.NullTime(time.Now().UTC())
 	address.VerifiedAt = &verifiedAt
 	address.Status = identity.VerifiableAddressStatusCompleted
 	if err := s.deps.PrivilegedIdentityPool().UpdateVerifiableAddress(ctx, address, "verified", "verified_at", "status"); err != nil {
-		return s.retryVerificationFlowWithError(ctx, w, r, f.Type, err)
+		return nil, err
 	}
 
 	i, err := s.deps.IdentityPool().GetIdentity(ctx, code.VerifiableAddress.IdentityID, identity.ExpandDefault)
 	if err != nil {
-		return s.retryVerificationFlowWithError(ctx, w, r, f.Type, err)
+		return nil, err
 	}
 
 	returnTo := f.ContinueURL(ctx, s.deps.Config())
@@ -280,23 +327,13 @@ func (s *Strategy) verificationUseCode(ctx context.Context, w http.ResponseWrite
 	}
 
 	f.State = flow.StatePassedChallenge
-	// See https://github.com/ory/kratos/issues/1547
-	f.SetCSRFToken(flow.GetCSRFToken(s.deps, w, r, f.Type))
 	f.UI.Messages.Set(text.NewInfoSelfServiceVerificationSuccessful())
 	f.UI.
 		Nodes.
 		Append(node.NewAnchorField("continue", returnTo.String(), node.CodeGroup, text.NewInfoNodeLabelContinue()).
 			WithMetaLabel(text.NewInfoNodeLabelContinue()))
 
-	if err := s.deps.VerificationFlowPersister().UpdateVerificationFlow(ctx, f); err != nil {
-		return s.retryVerificationFlowWithError(ctx, w, r, flow.TypeBrowser, err)
-	}
-
-	if err := s.deps.VerificationExecutor().PostVerificationHook(w, r, f, i); err != nil {
-		return s.retryVerificationFlowWithError(ctx, w, r, f.Type, err)
-	}
-
-	return nil
+	return i, nil
 }
 
 func (s *Strategy) retryVerificationFlowWithMessage(ctx context.Context, w http.ResponseWriter, r *http.Request, ft flow.Type, message *text.Message) error {
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v5"
 
 	"github.com/ory/x/urlx"
 
@@ -479,6 +480,58 @@ func TestVerification(t *testing.T) {
 		assert.Equal(t, text.ErrIDSelfServiceFlowReplaced, gjson.GetBytes(f2, "error.id").String())
 	})
 
+	t.Run("case=should continue with a signed native deep link via api", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceVerificationNativeDeepLink, map[string]any{
+			"url":      "myapp://verified?foo=bar",
+			"jwks_url": "file://./stub/jwk.es256.json",
+		})
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceVerificationNativeDeepLink, nil)
+		})
+
+		_ = expectSuccess(t, nil, true, false, func(v url.Values) {
+			v.Set("email", verificationEmail)
+		})
+
+		message := testhelpers.CourierExpectMessage(ctx, t, reg, verificationEmail, "Use code")
+		verificationLink := testhelpers.CourierExpectLinkInMessage(t, message, 1)
+
+		res, err := testhelpers.NewClientWithCookies(t).Get(verificationLink)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		original := ioutilx.MustReadAll(res.Body)
+		code := gjson.GetBytes(original, "ui.nodes.#(attributes.name==code).attributes.value").String()
+		require.NotEmpty(t, code)
+
+		req, err := http.NewRequest("POST", gjson.GetBytes(original, "ui.action").String(), strings.NewReader(fmt.Sprintf(`{"code": "%v"}`, code)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		res, err = testhelpers.NewDebugClient(t).Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body := ioutilx.MustReadAll(res.Body)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+
+		assert.EqualValues(t, "passed_challenge", gjson.GetBytes(body, "state").String(), "%s", body)
+		assert.EqualValues(t, flow.ContinueWithActionOpenDeepLinkString, gjson.GetBytes(body, "continue_with.0.action").String(), "%s", body)
+
+		deepLink, err := url.Parse(gjson.GetBytes(body, "continue_with.0.deep_link").String())
+		require.NoError(t, err)
+		assert.Equal(t, "myapp", deepLink.Scheme)
+		assert.Equal(t, "bar", deepLink.Query().Get("foo"))
+
+		payload, _, err := jwt.NewParser().ParseUnverified(deepLink.Query().Get("payload"), jwt.MapClaims{})
+		require.NoError(t, err)
+		claims := payload.Claims.(jwt.MapClaims)
+		assert.Equal(t, "ES256", payload.Method.Alg())
+		assert.Equal(t, identityToVerify.ID.String(), claims["sub"])
+		assert.Equal(t, gjson.GetBytes(body, "id").String(), claims["flow_id"])
+		assert.Equal(t, "verification", claims["flow"])
+		assert.Equal(t, verificationEmail, claims["verified_address"])
+	})
+
 	resendVerificationCode := func(t *testing.T, client *http.Client, flow string, flowType ClientType, statusCode int) string {
 		action := gjson.Get(flow, "ui.action").String()
 		assert.NotEmpty(t, action)
@@ -0,0 +1,59 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package devicekey
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+)
+
+// ParsePublicKey parses a DER-encoded PKIX public key and ensures that it is either
+// an ECDSA P-256 or an Ed25519 key, which are the key types offered by the secure
+// enclaves of common mobile platforms.
+func ParsePublicKey(der []byte) (any, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+		}
+		return k, nil
+	case ed25519.PublicKey:
+		return k, nil
+	}
+
+	return nil, errors.Errorf("unsupported public key type %T", key)
+}
+
+// VerifySignature checks that signature is a valid signature of message. ECDSA signatures
+// are expected to be ASN.1 encoded and computed over the SHA-256 digest of message.
+func VerifySignature(der []byte, message []byte, signature []byte) error {
+	key, err := ParsePublicKey(der)
+	if err != nil {
+		return err
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if !ecdsa.VerifyASN1(k, digest[:], signature) {
+			return errors.New("the ECDSA signature is invalid")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, message, signature) {
+			return errors.New("the Ed25519 signature is invalid")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package devicekey
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/decoderx"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/randx"
+)
+
+const InternalContextKeyChallenge = "challenge"
+
+func (s *Strategy) RegisterLoginRoutes(r *x.RouterPublic) {
+}
+
+func (s *Strategy) PopulateLoginMethod(r *http.Request, requestedAAL identity.AuthenticatorAssuranceLevel, f *login.Flow) error {
+	// Device keys can only refresh the first factor of sessions created in native apps.
+	if requestedAAL != identity.AuthenticatorAssuranceLevel1 || !f.IsRefresh() || f.Type != flow.TypeAPI {
+		return nil
+	}
+
+	sess, err := s.d.SessionManager().FetchFromRequest(r.Context(), r)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.d.PrivilegedIdentityPool().GetIdentityConfidential(r.Context(), sess.IdentityID)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := id.GetCredentials(s.ID()); !ok {
+		// Identity has no device keys
+		return nil
+	}
+
+	challenge := randx.MustString(32, randx.AlphaNum)
+	f.EnsureInternalContext()
+	f.InternalContext, err = sjson.SetBytes(f.InternalContext, flow.PrefixInternalContextKey(s.ID(), InternalContextKeyChallenge), challenge)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f.UI.SetCSRF(s.d.GenerateCSRFToken(r))
+	f.UI.SetNode(NewChallengeNode(challenge))
+	f.UI.SetNode(node.NewInputField(node.DeviceKeyID, "", node.DeviceKeyGroup, node.InputAttributeTypeHidden, node.WithRequiredInputAttribute))
+	f.UI.SetNode(node.NewInputField(node.DeviceKeySignature, "", node.DeviceKeyGroup, node.InputAttributeTypeHidden, node.WithRequiredInputAttribute))
+	f.UI.GetNodes().Append(node.NewInputField("method", s.ID(), node.DeviceKeyGroup, node.InputAttributeTypeSubmit).WithMetaLabel(text.NewInfoSelfServiceLoginDeviceKey()))
+
+	return nil
+}
+
+func (s *Strategy) handleLoginError(r *http.Request, f *login.Flow, err error) error {
+	if f != nil {
+		f.UI.Nodes.ResetNodes(node.DeviceKeySignature)
+		if f.Type == flow.TypeBrowser {
+			f.UI.SetCSRF(s.d.GenerateCSRFToken(r))
+		}
+	}
+
+	return err
+}
+
+// Update Login Flow with Device Key Method
+//
+// swagger:model updateLoginFlowWithDeviceKeyMethod
+type updateLoginFlowWithDeviceKeyMethod struct {
+	// Method should be set to "device_key" when refreshing a session using a device key.
+	//
+	// required: true
+	Method string `json:"method"`
+
+	// Sending the anti-csrf token is only required for browser login flows.
+	CSRFToken string `json:"csrf_token"`
+
+	// The ID of the device key which signed the challenge.
+	//
+	// required: true
+	KeyID string `json:"device_key_id"`
+
+	// The base64-encoded signature of the `device_key_challenge` node's value.
+	//
+	// required: true
+	Signature string `json:"device_key_signature"`
+
+	// Transient data to pass along to any webhooks
+	//
+	// required: false
+	TransientPayload json.RawMessage `json:"transient_payload,omitempty" form:"transient_payload"`
+}
+
+func (s *Strategy) Login(_ http.ResponseWriter, r *http.Request, f *login.Flow, sess *session.Session) (i *identity.Identity, err error) {
+	ctx, span := s.d.Tracer(r.Context()).Tracer().Start(r.Context(), "selfservice.strategy.devicekey.Strategy.Login")
+	defer otelx.End(span, &err)
+
+	if err := login.CheckAAL(f, identity.AuthenticatorAssuranceLevel1); err != nil {
+		span.SetAttributes(attribute.String("not_responsible_reason", "requested AAL is not AAL1"))
+		return nil, err
+	}
+
+	if err := flow.MethodEnabledAndAllowedFromRequest(r, f.GetFlowName(), s.ID().String(), s.d); err != nil {
+		return nil, err
+	}
+
+	var p updateLoginFlowWithDeviceKeyMethod
+	if err := s.hd.Decode(r, &p,
+		decoderx.HTTPDecoderSetValidatePayloads(true),
+		decoderx.MustHTTPRawJSONSchemaCompiler(loginSchema),
+		decoderx.HTTPDecoderJSONFollowsFormFormat()); err != nil {
+		return nil, s.handleLoginError(r, f, err)
+	}
+	f.TransientPayload = p.TransientPayload
+
+	if err := flow.EnsureCSRF(s.d, r, f.Type, s.d.Config().DisableAPIFlowEnforcement(ctx), s.d.GenerateCSRFToken, p.CSRFToken); err != nil {
+		return nil, s.handleLoginError(r, f, err)
+	}
+
+	if !f.IsRefresh() || f.Type != flow.TypeAPI || sess == nil || sess.IdentityID == uuid.Nil {
+		return nil, s.handleLoginError(r, f, errors.WithStack(herodot.ErrBadRequest.WithReason("Device keys can only be used to refresh an existing session in API flows.")))
+	}
+
+	challenge := gjson.GetBytes(f.InternalContext, flow.PrefixInternalContextKey(s.ID(), InternalContextKeyChallenge)).String()
+	if challenge == "" {
+		return nil, s.handleLoginError(r, f, errors.WithStack(herodot.ErrBadRequest.WithReason("The login flow does not contain a device key challenge. Please create a new login flow.")))
+	}
+
+	i, c, err := s.d.PrivilegedIdentityPool().FindByCredentialsIdentifier(ctx, s.ID(), sess.IdentityID.String())
+	if err != nil {
+		return nil, s.handleLoginError(r, f, errors.WithStack(schema.NewNoDeviceKeyRegistered()))
+	}
+
+	var o identity.CredentialsDeviceKeyConfig
+	if err := json.Unmarshal(c.Config, &o); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("The device key credentials could not be decoded properly").WithDebug(err.Error()).WithWrap(err))
+	}
+
+	key := o.Find(p.KeyID)
+	if key == nil {
+		return nil, s.handleLoginError(r, f, errors.WithStack(schema.NewNoDeviceKeyRegistered()))
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(p.Signature)
+	if err != nil {
+		return nil, s.handleLoginError(r, f, errors.WithStack(schema.NewDeviceKeySignatureInvalidError()))
+	}
+
+	if err := VerifySignature(key.PublicKey, []byte(challenge), signature); err != nil {
+		span.SetAttributes(attribute.String("verification_error", err.Error()))
+		return nil, s.handleLoginError(r, f, errors.WithStack(schema.NewDeviceKeySignatureInvalidError()))
+	}
+
+	// Each challenge can only be signed once.
+	f.InternalContext, err = sjson.DeleteBytes(f.InternalContext, flow.PrefixInternalContextKey(s.ID(), InternalContextKeyChallenge))
+	if err != nil {
+		return nil, s.handleLoginError(r, f, errors.WithStack(err))
+	}
+
+	f.Active = s.ID()
+	if err = s.d.LoginFlowPersister().UpdateLoginFlow(ctx, f); err != nil {
+		return nil, s.handleLoginError(r, f, errors.WithStack(herodot.ErrInternalServerError.WithReason("Could not update flow").WithDebug(err.Error())))
+	}
+
+	return i, nil
+}
@@ -0,0 +1,156 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package devicekey_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+)
+
+func createIdentityWithDeviceKey(t *testing.T, reg *driver.RegistryDefault) (*identity.Identity, string, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+	require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(context.Background(), i))
+
+	keyID := x.NewUUID().String()
+	co, err := json.Marshal(identity.CredentialsDeviceKeyConfig{Keys: []identity.CredentialDeviceKey{{
+		ID:          keyID,
+		DisplayName: "phone",
+		PublicKey:   der,
+		AddedAt:     time.Now().UTC(),
+	}}})
+	require.NoError(t, err)
+
+	i.SetCredentials(identity.CredentialsTypeDeviceKey, identity.Credentials{
+		Type:        identity.CredentialsTypeDeviceKey,
+		Identifiers: []string{i.ID.String()},
+		Config:      co,
+	})
+	require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentity(context.Background(), i))
+	return i, keyID, key
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, challenge string) string {
+	digest := sha256.Sum256([]byte(challenge))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestCompleteLogin(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypeDeviceKey)+".enabled", true)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	publicTS, _ := testhelpers.NewKratosServer(t, reg)
+
+	newSession := func(t *testing.T, i *identity.Identity) (*session.Session, *http.Client) {
+		req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+		sess, err := testhelpers.NewActiveSession(req, reg, i, time.Now().Add(-time.Hour).UTC(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+		require.NoError(t, err)
+		return sess, testhelpers.NewHTTPClientWithSessionToken(t, ctx, reg, sess)
+	}
+
+	challengeOf := func(t *testing.T, nodes any) string {
+		raw, err := json.Marshal(nodes)
+		require.NoError(t, err)
+		return gjson.GetBytes(raw, "#(attributes.name=="+node.DeviceKeyChallenge+").attributes.value").String()
+	}
+
+	refresh := func(t *testing.T, hc *http.Client, keyID string, signer func(challenge string) string) (string, *http.Response) {
+		f := testhelpers.InitializeLoginFlowViaAPI(t, hc, publicTS, true)
+		challenge := challengeOf(t, f.Ui.Nodes)
+		require.NotEmpty(t, challenge)
+
+		payload, err := json.Marshal(map[string]string{
+			"method":               "device_key",
+			"device_key_id":        keyID,
+			"device_key_signature": signer(challenge),
+		})
+		require.NoError(t, err)
+		return testhelpers.LoginMakeRequest(t, true, false, f, hc, string(payload))
+	}
+
+	t.Run("case=challenge is not offered without device keys", func(t *testing.T) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		_, hc := newSession(t, i)
+		f := testhelpers.InitializeLoginFlowViaAPI(t, hc, publicTS, true)
+		assert.Empty(t, challengeOf(t, f.Ui.Nodes))
+	})
+
+	t.Run("case=challenge is not offered without a session", func(t *testing.T) {
+		f := testhelpers.InitializeLoginFlowViaAPI(t, http.DefaultClient, publicTS, false)
+		assert.Empty(t, challengeOf(t, f.Ui.Nodes))
+	})
+
+	t.Run("case=refreshes the session with a valid signature", func(t *testing.T) {
+		i, keyID, key := createIdentityWithDeviceKey(t, reg)
+		sess, hc := newSession(t, i)
+
+		body, res := refresh(t, hc, keyID, func(challenge string) string { return sign(t, key, challenge) })
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Equal(t, i.ID.String(), gjson.Get(body, "session.identity.id").String(), body)
+		assert.Equal(t, string(identity.CredentialsTypeDeviceKey), gjson.Get(body, "session.authentication_methods.1.method").String(), body)
+
+		actual, err := reg.SessionPersister().GetSession(ctx, sess.ID, session.ExpandNothing)
+		require.NoError(t, err)
+		assert.True(t, actual.AuthenticatedAt.After(sess.AuthenticatedAt.Add(time.Minute)), "%s should be after %s", actual.AuthenticatedAt, sess.AuthenticatedAt)
+	})
+
+	t.Run("case=rejects a signature of another challenge", func(t *testing.T) {
+		i, keyID, key := createIdentityWithDeviceKey(t, reg)
+		_, hc := newSession(t, i)
+
+		body, res := refresh(t, hc, keyID, func(string) string { return sign(t, key, "another challenge") })
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+		assert.EqualValues(t, text.ErrorValidationDeviceKeySignatureInvalid, gjson.Get(body, "ui.nodes.#(attributes.name==device_key_signature).messages.0.id").Int(), body)
+	})
+
+	t.Run("case=rejects a signature of another key", func(t *testing.T) {
+		i, keyID, _ := createIdentityWithDeviceKey(t, reg)
+		_, hc := newSession(t, i)
+		_, _, other := createIdentityWithDeviceKey(t, reg)
+
+		body, res := refresh(t, hc, keyID, func(challenge string) string { return sign(t, other, challenge) })
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+		assert.EqualValues(t, text.ErrorValidationDeviceKeySignatureInvalid, gjson.Get(body, "ui.nodes.#(attributes.name==device_key_signature).messages.0.id").Int(), body)
+	})
+
+	t.Run("case=rejects an unknown key", func(t *testing.T) {
+		i, _, key := createIdentityWithDeviceKey(t, reg)
+		_, hc := newSession(t, i)
+
+		body, res := refresh(t, hc, x.NewUUID().String(), func(challenge string) string { return sign(t, key, challenge) })
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+		assert.EqualValues(t, text.ErrorValidationNoDeviceKey, gjson.Get(body, "ui.nodes.#(attributes.name==device_key_id).messages.0.id").Int(), body)
+	})
+}
@@ -0,0 +1,31 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package devicekey
+
+import (
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+)
+
+func NewChallengeNode(challenge string) *node.Node {
+	return node.NewInputField(node.DeviceKeyChallenge, challenge, node.DeviceKeyGroup, node.InputAttributeTypeHidden)
+}
+
+func NewRegisterNodes() []*node.Node {
+	return []*node.Node{
+		node.NewInputField(node.DeviceKeyRegisterDisplayName, "", node.DeviceKeyGroup, node.InputAttributeTypeText).
+			WithMetaLabel(text.NewInfoSelfServiceRegisterDeviceKeyDisplayName()),
+		node.NewInputField(node.DeviceKeyRegisterAttestation, "", node.DeviceKeyGroup, node.InputAttributeTypeHidden),
+		node.NewInputField(node.DeviceKeyRegisterPushToken, "", node.DeviceKeyGroup, node.InputAttributeTypeHidden),
+		node.NewInputField(node.DeviceKeyRegisterPushPlatform, "", node.DeviceKeyGroup, node.InputAttributeTypeHidden),
+		node.NewInputField(node.DeviceKeyRegister, "", node.DeviceKeyGroup, node.InputAttributeTypeHidden).
+			WithMetaLabel(text.NewInfoSelfServiceSettingsRegisterDeviceKey()),
+	}
+}
+
+func NewRemoveNode(key *identity.CredentialDeviceKey) *node.Node {
+	return node.NewInputField(node.DeviceKeyRemove, key.ID, node.DeviceKeyGroup, node.InputAttributeTypeSubmit).
+		WithMetaLabel(text.NewInfoSelfServiceRemoveDeviceKey(key.DisplayName, key.AddedAt))
+}
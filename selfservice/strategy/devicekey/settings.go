@@ -0,0 +1,318 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package devicekey
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/decoderx"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlxx"
+)
+
+func (s *Strategy) RegisterSettingsRoutes(_ *x.RouterPublic) {
+}
+
+func (s *Strategy) SettingsStrategyID() string {
+	return identity.CredentialsTypeDeviceKey.String()
+}
+
+// Update Settings Flow with Device Key Method
+//
+// swagger:model updateSettingsFlowWithDeviceKeyMethod
+type updateSettingsFlowWithDeviceKeyMethod struct {
+	// Register a device key
+	//
+	// The base64-encoded DER (PKIX) public key of an ECDSA P-256 or Ed25519 key pair
+	// which was generated on the device.
+	Register string `json:"device_key_register"`
+
+	// Name of the device key to be registered
+	RegisterDisplayName string `json:"device_key_register_displayname"`
+
+	// Attestation statement of the device key to be registered
+	//
+	// The attestation is stored alongside the key but is not verified.
+	RegisterAttestation string `json:"device_key_register_attestation"`
+
+	// Push token of the device key to be registered
+	//
+	// If set, the device receives push notifications asking to approve recovery and
+	// verification requests.
+	RegisterPushToken string `json:"device_key_register_push_token"`
+
+	// Push platform of the device key to be registered
+	//
+	// The push service which issued the push token. Required if a push token is set.
+	//
+	// enum: fcm,apns
+	RegisterPushPlatform string `json:"device_key_register_push_platform"`
+
+	// Remove a device key
+	//
+	// Contains the ID of the device key to be removed.
+	Remove string `json:"device_key_remove"`
+
+	// CSRFToken is the anti-CSRF token
+	CSRFToken string `json:"csrf_token"`
+
+	// Method
+	//
+	// Should be set to "device_key" when trying to add or remove a device key.
+	//
+	// required: true
+	Method string `json:"method"`
+
+	// Flow is flow ID.
+	//
+	// swagger:ignore
+	Flow string `json:"flow"`
+
+	// Transient data to pass along to any webhooks
+	//
+	// required: false
+	TransientPayload json.RawMessage `json:"transient_payload,omitempty" form:"transient_payload"`
+}
+
+func (p *updateSettingsFlowWithDeviceKeyMethod) GetFlowID() uuid.UUID {
+	return x.ParseUUID(p.Flow)
+}
+
+func (p *updateSettingsFlowWithDeviceKeyMethod) SetFlowID(rid uuid.UUID) {
+	p.Flow = rid.String()
+}
+
+func (s *Strategy) Settings(ctx context.Context, w http.ResponseWriter, r *http.Request, f *settings.Flow, ss *session.Session) (_ *settings.UpdateContext, err error) {
+	ctx, span := s.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.strategy.devicekey.Strategy.Settings")
+	defer otelx.End(span, &err)
+
+	var p updateSettingsFlowWithDeviceKeyMethod
+	ctxUpdate, err := settings.PrepareUpdate(s.d, w, r, f, ss, settings.ContinuityKey(s.SettingsStrategyID()), &p)
+	if errors.Is(err, settings.ErrContinuePreviousAction) {
+		return ctxUpdate, s.continueSettingsFlow(ctx, r, ctxUpdate, p)
+	} else if err != nil {
+		return ctxUpdate, s.handleSettingsError(w, r, ctxUpdate, p, err)
+	}
+
+	if err := s.decodeSettingsFlow(r, &p); err != nil {
+		return ctxUpdate, s.handleSettingsError(w, r, ctxUpdate, p, err)
+	}
+
+	if len(p.Register+p.Remove) > 0 {
+		// This method has only two submit buttons
+		p.Method = s.SettingsStrategyID()
+		if err := flow.MethodEnabledAndAllowed(ctx, f.GetFlowName(), s.SettingsStrategyID(), p.Method, s.d); err != nil {
+			return nil, s.handleSettingsError(w, r, ctxUpdate, p, err)
+		}
+	} else {
+		span.SetAttributes(attribute.String("not_responsible_reason", "neither register nor remove was set"))
+		return nil, errors.WithStack(flow.ErrStrategyNotResponsible)
+	}
+
+	// This does not come from the payload!
+	p.Flow = ctxUpdate.Flow.ID.String()
+	if err := s.continueSettingsFlow(ctx, r, ctxUpdate, p); err != nil {
+		return ctxUpdate, s.handleSettingsError(w, r, ctxUpdate, p, err)
+	}
+
+	return ctxUpdate, nil
+}
+
+func (s *Strategy) decodeSettingsFlow(r *http.Request, dest interface{}) error {
+	compiler, err := decoderx.HTTPRawJSONSchemaCompiler(settingsSchema)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return decoderx.NewHTTP().Decode(r, dest, compiler,
+		decoderx.HTTPDecoderSetValidatePayloads(true),
+		decoderx.HTTPDecoderJSONFollowsFormFormat(),
+	)
+}
+
+func (s *Strategy) continueSettingsFlow(ctx context.Context, r *http.Request, ctxUpdate *settings.UpdateContext, p updateSettingsFlowWithDeviceKeyMethod) error {
+	if len(p.Register+p.Remove) > 0 {
+		if err := flow.MethodEnabledAndAllowed(ctx, flow.SettingsFlow, s.SettingsStrategyID(), s.SettingsStrategyID(), s.d); err != nil {
+			return err
+		}
+
+		if err := flow.EnsureCSRF(s.d, r, ctxUpdate.Flow.Type, s.d.Config().DisableAPIFlowEnforcement(ctx), s.d.GenerateCSRFToken, p.CSRFToken); err != nil {
+			return err
+		}
+
+		if err := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup()); err != nil {
+			return err
+		}
+	} else {
+		return errors.New("ended up in unexpected state")
+	}
+
+	switch {
+	case len(p.Remove) > 0:
+		return s.continueSettingsFlowRemove(ctx, ctxUpdate, p)
+	case len(p.Register) > 0:
+		return s.continueSettingsFlowAdd(ctx, ctxUpdate, p)
+	}
+
+	return errors.New("ended up in unexpected state")
+}
+
+func (s *Strategy) continueSettingsFlowRemove(ctx context.Context, ctxUpdate *settings.UpdateContext, p updateSettingsFlowWithDeviceKeyMethod) error {
+	i, err := s.d.PrivilegedIdentityPool().GetIdentityConfidential(ctx, ctxUpdate.Session.IdentityID)
+	if err != nil {
+		return err
+	}
+
+	cred, ok := i.GetCredentials(s.ID())
+	if !ok {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("You tried to remove a device key but you have no device keys set up."))
+	}
+
+	var cc identity.CredentialsDeviceKeyConfig
+	if err := json.Unmarshal(cred.Config, &cc); err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode identity credentials.").WithDebug(err.Error()))
+	}
+
+	updated := make([]identity.CredentialDeviceKey, 0, len(cc.Keys))
+	for _, key := range cc.Keys {
+		if key.ID != p.Remove {
+			updated = append(updated, key)
+		}
+	}
+
+	if len(updated) == len(cc.Keys) {
+		return errors.WithStack(schema.NewNoDeviceKeyRegistered())
+	}
+
+	if len(updated) == 0 {
+		i.DeleteCredentialsType(s.ID())
+		ctxUpdate.UpdateIdentity(i)
+		return nil
+	}
+
+	cc.Keys = updated
+	cred.Config, err = json.Marshal(cc)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to encode identity credentials.").WithDebug(err.Error()))
+	}
+
+	i.SetCredentials(s.ID(), *cred)
+	ctxUpdate.UpdateIdentity(i)
+	return nil
+}
+
+func (s *Strategy) continueSettingsFlowAdd(ctx context.Context, ctxUpdate *settings.UpdateContext, p updateSettingsFlowWithDeviceKeyMethod) error {
+	der, err := base64.StdEncoding.DecodeString(p.Register)
+	if err != nil {
+		return errors.WithStack(schema.NewDeviceKeyInvalidError())
+	}
+
+	if _, err := ParsePublicKey(der); err != nil {
+		return errors.WithStack(schema.NewDeviceKeyInvalidError())
+	}
+
+	if len(p.RegisterPushToken) > 0 && len(p.RegisterPushPlatform) == 0 {
+		return errors.WithStack(schema.NewRequiredError("#/device_key_register_push_platform", node.DeviceKeyRegisterPushPlatform))
+	}
+
+	i, err := s.d.PrivilegedIdentityPool().GetIdentityConfidential(ctx, ctxUpdate.Session.IdentityID)
+	if err != nil {
+		return err
+	}
+
+	cred := i.GetCredentialsOr(s.ID(), &identity.Credentials{Config: sqlxx.JSONRawMessage("{}")})
+
+	var cc identity.CredentialsDeviceKeyConfig
+	if err := json.Unmarshal(cred.Config, &cc); err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode identity credentials.").WithDebug(err.Error()))
+	}
+
+	cc.Keys = append(cc.Keys, identity.CredentialDeviceKey{
+		ID:           x.NewUUID().String(),
+		DisplayName:  p.RegisterDisplayName,
+		PublicKey:    der,
+		Attestation:  p.RegisterAttestation,
+		PushToken:    p.RegisterPushToken,
+		PushPlatform: p.RegisterPushPlatform,
+		AddedAt:      time.Now().UTC().Round(time.Second),
+	})
+
+	co, err := json.Marshal(cc)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to encode identity credentials.").WithDebug(err.Error()))
+	}
+
+	// We do not really need the identifier, so we add the identity's ID
+	i.SetCredentials(s.ID(), identity.Credentials{Type: s.ID(), Identifiers: []string{i.ID.String()}, Config: co})
+
+	// Since we added the method, it also means that we have authenticated it
+	if err := s.d.SessionManager().SessionAddAuthenticationMethods(ctx, ctxUpdate.Session.ID, s.CompletedAuthenticationMethod(ctx)); err != nil {
+		return err
+	}
+
+	ctxUpdate.UpdateIdentity(i)
+	return nil
+}
+
+func (s *Strategy) PopulateSettingsMethod(ctx context.Context, r *http.Request, id *identity.Identity, f *settings.Flow) (err error) {
+	_, span := s.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.strategy.devicekey.Strategy.PopulateSettingsMethod")
+	defer otelx.End(span, &err)
+
+	// Device keys are generated by native apps.
+	if f.Type != flow.TypeAPI {
+		return nil
+	}
+
+	f.UI.SetCSRF(s.d.GenerateCSRFToken(r))
+
+	if cred, ok := id.GetCredentials(s.ID()); ok {
+		var cc identity.CredentialsDeviceKeyConfig
+		if err := json.Unmarshal(cred.Config, &cc); err != nil {
+			return errors.WithStack(err)
+		}
+
+		for k := range cc.Keys {
+			f.UI.Nodes.Append(NewRemoveNode(&cc.Keys[k]))
+		}
+	}
+
+	for _, n := range NewRegisterNodes() {
+		f.UI.Nodes.Upsert(n)
+	}
+
+	return nil
+}
+
+func (s *Strategy) handleSettingsError(w http.ResponseWriter, r *http.Request, ctxUpdate *settings.UpdateContext, p updateSettingsFlowWithDeviceKeyMethod, err error) error {
+	// Do not pause flow if the flow type is an API flow as we can't save cookies in those flows.
+	if e := new(settings.FlowNeedsReAuth); errors.As(err, &e) && ctxUpdate.Flow != nil && ctxUpdate.Flow.Type == flow.TypeBrowser {
+		if err := s.d.ContinuityManager().Pause(r.Context(), w, r, settings.ContinuityKey(s.SettingsStrategyID()), settings.ContinuityOptions(p, ctxUpdate.GetSessionIdentity())...); err != nil {
+			return err
+		}
+	}
+
+	if ctxUpdate.Flow != nil {
+		ctxUpdate.Flow.UI.ResetMessages()
+		ctxUpdate.Flow.UI.SetCSRF(s.d.GenerateCSRFToken(r))
+		ctxUpdate.Flow.UI.Nodes.ResetNodes(node.DeviceKeyRegister)
+	}
+
+	return err
+}
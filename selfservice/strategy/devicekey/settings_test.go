@@ -0,0 +1,138 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package devicekey_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+)
+
+func TestCompleteSettings(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypeDeviceKey)+".enabled", true)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	publicTS, _ := testhelpers.NewKratosServer(t, reg)
+
+	submit := func(t *testing.T, hc *http.Client, values map[string]string) (string, *http.Response) {
+		f := testhelpers.InitializeSettingsFlowViaAPI(t, hc, publicTS)
+		values["method"] = "device_key"
+		payload, err := json.Marshal(values)
+		require.NoError(t, err)
+		return testhelpers.SettingsMakeRequest(t, true, false, f, hc, string(payload))
+	}
+
+	deviceKeys := func(t *testing.T, i *identity.Identity) []identity.CredentialDeviceKey {
+		actual, err := reg.PrivilegedIdentityPool().GetIdentityConfidential(ctx, i.ID)
+		require.NoError(t, err)
+		c, ok := actual.GetCredentials(identity.CredentialsTypeDeviceKey)
+		if !ok {
+			return nil
+		}
+		var cc identity.CredentialsDeviceKeyConfig
+		require.NoError(t, json.Unmarshal(c.Config, &cc))
+		return cc.Keys
+	}
+
+	t.Run("case=registers a device key", func(t *testing.T) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		hc := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+
+		f := testhelpers.InitializeSettingsFlowViaAPI(t, hc, publicTS)
+		raw, err := json.Marshal(f.Ui.Nodes)
+		require.NoError(t, err)
+		assert.True(t, gjson.GetBytes(raw, "#(attributes.name=="+node.DeviceKeyRegister+")").Exists(), "%s", raw)
+
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+
+		body, res := submit(t, hc, map[string]string{
+			node.DeviceKeyRegister:            base64.StdEncoding.EncodeToString(der),
+			node.DeviceKeyRegisterDisplayName: "phone",
+		})
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.EqualValues(t, text.InfoSelfServiceSettingsRemoveDeviceKey, gjson.Get(body, "ui.nodes.#(attributes.name=="+node.DeviceKeyRemove+").meta.label.id").Int(), body)
+
+		keys := deviceKeys(t, i)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "phone", keys[0].DisplayName)
+		assert.Equal(t, der, keys[0].PublicKey)
+	})
+
+	t.Run("case=registers a device key with a push token", func(t *testing.T) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		hc := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+
+		body, res := submit(t, hc, map[string]string{
+			node.DeviceKeyRegister:          base64.StdEncoding.EncodeToString(der),
+			node.DeviceKeyRegisterPushToken: "device-token",
+		})
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, "the push platform is required: %s", body)
+		assert.Empty(t, deviceKeys(t, i))
+
+		body, res = submit(t, hc, map[string]string{
+			node.DeviceKeyRegister:             base64.StdEncoding.EncodeToString(der),
+			node.DeviceKeyRegisterPushToken:    "device-token",
+			node.DeviceKeyRegisterPushPlatform: "apns",
+		})
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+
+		keys := deviceKeys(t, i)
+		require.Len(t, keys, 1)
+		assert.Equal(t, "device-token", keys[0].PushToken)
+		assert.Equal(t, "apns", keys[0].PushPlatform)
+	})
+
+	t.Run("case=rejects unsupported keys", func(t *testing.T) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		hc := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+
+		body, res := submit(t, hc, map[string]string{node.DeviceKeyRegister: base64.StdEncoding.EncodeToString(der)})
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+		assert.EqualValues(t, text.ErrorValidationDeviceKeyInvalid, gjson.Get(body, "ui.nodes.#(attributes.name=="+node.DeviceKeyRegister+").messages.0.id").Int(), body)
+		assert.Empty(t, deviceKeys(t, i))
+	})
+
+	t.Run("case=removes a device key", func(t *testing.T) {
+		i, keyID, _ := createIdentityWithDeviceKey(t, reg)
+		hc := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+
+		body, res := submit(t, hc, map[string]string{node.DeviceKeyRemove: keyID})
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Empty(t, deviceKeys(t, i))
+	})
+}
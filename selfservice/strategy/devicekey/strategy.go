@@ -0,0 +1,97 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package devicekey
+
+import (
+	"context"
+
+	"github.com/ory/kratos/continuity"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/errorx"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/decoderx"
+)
+
+var (
+	_ login.Strategy                    = new(Strategy)
+	_ login.UnifiedFormHydrator         = new(Strategy)
+	_ settings.Strategy                 = new(Strategy)
+	_ identity.ActiveCredentialsCounter = new(Strategy)
+)
+
+type deviceKeyStrategyDependencies interface {
+	x.LoggingProvider
+	x.WriterProvider
+	x.CSRFTokenGeneratorProvider
+	x.CSRFProvider
+	x.TracingProvider
+
+	config.Provider
+
+	continuity.ManagementProvider
+
+	errorx.ManagementProvider
+
+	login.HooksProvider
+	login.ErrorHandlerProvider
+	login.HookExecutorProvider
+	login.FlowPersistenceProvider
+	login.HandlerProvider
+
+	settings.FlowPersistenceProvider
+	settings.HookExecutorProvider
+	settings.HooksProvider
+	settings.ErrorHandlerProvider
+
+	identity.PrivilegedPoolProvider
+	identity.ValidationProvider
+	identity.ManagementProvider
+
+	session.HandlerProvider
+	session.ManagementProvider
+}
+
+// Strategy lets native apps re-authenticate an existing session by signing a challenge
+// with a device-bound key, which is usually unlocked using platform biometrics.
+type Strategy struct {
+	d  deviceKeyStrategyDependencies
+	hd *decoderx.HTTP
+}
+
+func NewStrategy(d any) *Strategy {
+	return &Strategy{
+		d:  d.(deviceKeyStrategyDependencies),
+		hd: decoderx.NewHTTP(),
+	}
+}
+
+// CountActiveFirstFactorCredentials returns zero because device keys can only refresh
+// an existing session and never sign in an identity on their own.
+func (s *Strategy) CountActiveFirstFactorCredentials(_ context.Context, _ map[identity.CredentialsType]identity.Credentials) (count int, err error) {
+	return 0, nil
+}
+
+func (s *Strategy) CountActiveMultiFactorCredentials(_ context.Context, _ map[identity.CredentialsType]identity.Credentials) (count int, err error) {
+	return 0, nil
+}
+
+func (s *Strategy) ID() identity.CredentialsType {
+	return identity.CredentialsTypeDeviceKey
+}
+
+func (s *Strategy) NodeGroup() node.UiNodeGroup {
+	return node.DeviceKeyGroup
+}
+
+func (s *Strategy) CompletedAuthenticationMethod(ctx context.Context) session.AuthenticationMethod {
+	return session.AuthenticationMethod{
+		Method: s.ID(),
+		AAL:    identity.AuthenticatorAssuranceLevel1,
+	}
+}
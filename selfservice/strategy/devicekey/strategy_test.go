@@ -0,0 +1,68 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package devicekey_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/selfservice/strategy/devicekey"
+)
+
+func TestVerifySignature(t *testing.T) {
+	message := []byte("challenge")
+
+	t.Run("key=ecdsa", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+
+		digest := sha256.Sum256(message)
+		signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+
+		assert.NoError(t, devicekey.VerifySignature(der, message, signature))
+		assert.Error(t, devicekey.VerifySignature(der, []byte("other"), signature))
+	})
+
+	t.Run("key=ed25519", func(t *testing.T) {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		require.NoError(t, err)
+
+		signature := ed25519.Sign(priv, message)
+		assert.NoError(t, devicekey.VerifySignature(der, message, signature))
+		assert.Error(t, devicekey.VerifySignature(der, []byte("other"), signature))
+	})
+
+	t.Run("key=unsupported", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+		_, err = devicekey.ParsePublicKey(der)
+		assert.Error(t, err)
+
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		der, err = x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+		require.NoError(t, err)
+		_, err = devicekey.ParsePublicKey(der)
+		assert.Error(t, err)
+
+		_, err = devicekey.ParsePublicKey([]byte("not a key"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package externalmfa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+const (
+	ProviderIDDuo      = "duo"
+	ProviderIDRedirect = "redirect"
+)
+
+type (
+	Configuration struct {
+		// Provider is either "duo" or "redirect".
+		Provider string `json:"provider"`
+
+		// Label is the name of the provider shown to users.
+		Label string `json:"label"`
+
+		Duo      DuoConfiguration      `json:"duo"`
+		Redirect RedirectConfiguration `json:"redirect"`
+	}
+
+	DuoConfiguration struct {
+		APIHostname    string `json:"api_hostname"`
+		IntegrationKey string `json:"integration_key"`
+		SecretKey      string `json:"secret_key"`
+
+		// Factor is the Duo factor used to challenge the user, defaults to "auto".
+		Factor string `json:"factor"`
+	}
+
+	RedirectConfiguration struct {
+		AuthURL      string `json:"auth_url"`
+		VerifyURL    string `json:"verify_url"`
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+)
+
+func (c *Configuration) label() string {
+	if c.Label != "" {
+		return c.Label
+	}
+	switch c.Provider {
+	case ProviderIDDuo:
+		return "Duo"
+	}
+	return "external provider"
+}
+
+func (s *Strategy) Config(ctx context.Context) (*Configuration, error) {
+	var c Configuration
+
+	conf := s.d.Config().SelfServiceStrategy(ctx, string(s.ID())).Config
+	if err := json.NewDecoder(bytes.NewBuffer(conf)).Decode(&c); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode external MFA configuration: %s", err))
+	}
+
+	return &c, nil
+}
+
+func (s *Strategy) Provider(ctx context.Context) (Provider, error) {
+	c, err := s.Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Provider {
+	case ProviderIDDuo:
+		return NewProviderDuo(&c.Duo, s.d), nil
+	case ProviderIDRedirect:
+		return NewProviderRedirect(&c.Redirect, s.d), nil
+	}
+
+	return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("External MFA provider %q is not supported.", c.Provider))
+}
@@ -0,0 +1,272 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package externalmfa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/continuity"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/decoderx"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/randx"
+	"github.com/ory/x/urlx"
+)
+
+const (
+	RouteCallback = "/self-service/methods/external_mfa/callback"
+
+	continuityName = "ory_kratos_external_mfa"
+)
+
+type redirectContainer struct {
+	State  string `json:"state"`
+	FlowID string `json:"flow_id"`
+}
+
+func (s *Strategy) RegisterLoginRoutes(r *x.RouterPublic) {
+	if handle, _, _ := r.Lookup("GET", RouteCallback); handle == nil {
+		r.GET(RouteCallback, s.handleCallback)
+	}
+}
+
+func (s *Strategy) PopulateLoginMethod(r *http.Request, requestedAAL identity.AuthenticatorAssuranceLevel, f *login.Flow) error {
+	// This strategy can only solve AAL2
+	if requestedAAL != identity.AuthenticatorAssuranceLevel2 {
+		return nil
+	}
+
+	// We have done proper validation before so this should never error
+	sess, err := s.d.SessionManager().FetchFromRequest(r.Context(), r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.username(r.Context(), sess); err != nil {
+		// Identity has no external MFA
+		return nil
+	}
+
+	c, err := s.Config(r.Context())
+	if err != nil {
+		return err
+	}
+
+	// Redirecting to the provider only works in the browser.
+	if c.Provider == ProviderIDRedirect && f.Type != flow.TypeBrowser {
+		return nil
+	}
+
+	f.UI.SetCSRF(s.d.GenerateCSRFToken(r))
+	f.UI.GetNodes().Append(node.NewInputField("method", s.ID(), node.ExternalMFAGroup, node.InputAttributeTypeSubmit).WithMetaLabel(text.NewInfoSelfServiceLoginExternalMFA(c.label())))
+
+	return nil
+}
+
+// Update Login Flow with External MFA Method
+//
+// swagger:model updateLoginFlowWithExternalMfaMethod
+type updateLoginFlowWithExternalMFAMethod struct {
+	// Method should be set to "external_mfa" when logging in using the external MFA strategy.
+	//
+	// required: true
+	Method string `json:"method"`
+
+	// Sending the anti-csrf token is only required for browser login flows.
+	CSRFToken string `json:"csrf_token"`
+
+	// Transient data to pass along to any webhooks
+	//
+	// required: false
+	TransientPayload json.RawMessage `json:"transient_payload,omitempty" form:"transient_payload"`
+}
+
+func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow, sess *session.Session) (i *identity.Identity, err error) {
+	ctx, span := s.d.Tracer(r.Context()).Tracer().Start(r.Context(), "selfservice.strategy.externalmfa.Strategy.Login")
+	defer otelx.End(span, &err)
+
+	if err := login.CheckAAL(f, identity.AuthenticatorAssuranceLevel2); err != nil {
+		span.SetAttributes(attribute.String("not_responsible_reason", "requested AAL is not AAL2"))
+		return nil, err
+	}
+
+	if err := flow.MethodEnabledAndAllowedFromRequest(r, f.GetFlowName(), s.ID().String(), s.d); err != nil {
+		return nil, err
+	}
+
+	var p updateLoginFlowWithExternalMFAMethod
+	if err := s.hd.Decode(r, &p,
+		decoderx.HTTPDecoderSetValidatePayloads(true),
+		decoderx.MustHTTPRawJSONSchemaCompiler(loginSchema),
+		decoderx.HTTPDecoderJSONFollowsFormFormat()); err != nil {
+		return nil, err
+	}
+	f.TransientPayload = p.TransientPayload
+
+	if err := flow.EnsureCSRF(s.d, r, f.Type, s.d.Config().DisableAPIFlowEnforcement(ctx), s.d.GenerateCSRFToken, p.CSRFToken); err != nil {
+		return nil, err
+	}
+
+	username, err := s.username(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := s.Provider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Active = s.ID()
+	if err = s.d.LoginFlowPersister().UpdateLoginFlow(ctx, f); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("Could not update flow").WithDebug(err.Error()))
+	}
+
+	switch p := provider.(type) {
+	case PushProvider:
+		if err := p.Authenticate(ctx, username); errors.Is(err, ErrDenied) {
+			s.d.Logger().WithError(err).WithField("identity_id", sess.IdentityID).Info("External MFA provider denied the sign in.")
+			return nil, errors.WithStack(schema.NewExternalMFADeniedError())
+		} else if err != nil {
+			return nil, err
+		}
+
+		return s.d.PrivilegedIdentityPool().GetIdentity(ctx, sess.IdentityID, identity.ExpandDefault)
+	case RedirectProvider:
+		return nil, s.redirect(w, r, f, p, username)
+	}
+
+	return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("External MFA provider %q is not supported.", provider.ID()))
+}
+
+func (s *Strategy) redirect(w http.ResponseWriter, r *http.Request, f *login.Flow, p RedirectProvider, username string) error {
+	ctx := r.Context()
+
+	if f.Type != flow.TypeBrowser {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("The external MFA provider can only be used in browser flows."))
+	}
+
+	state := randx.MustString(32, randx.AlphaNum)
+	authURL, err := p.AuthURL(username, state, s.redirectURI(ctx))
+	if err != nil {
+		return err
+	}
+
+	if err := s.d.ContinuityManager().Pause(ctx, w, r, continuityName,
+		continuity.WithPayload(&redirectContainer{State: state, FlowID: f.ID.String()}),
+		continuity.WithLifespan(time.Minute*30)); err != nil {
+		return err
+	}
+
+	if x.IsJSONRequest(r) {
+		s.d.Writer().WriteError(w, r, flow.NewBrowserLocationChangeRequiredError(authURL))
+	} else {
+		http.Redirect(w, r, authURL, http.StatusSeeOther)
+	}
+
+	return errors.WithStack(flow.ErrCompletedByStrategy)
+}
+
+func (s *Strategy) handleCallback(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+
+	var container redirectContainer
+	if _, err := s.d.ContinuityManager().Continue(ctx, w, r, continuityName, continuity.WithPayload(&container)); err != nil {
+		s.d.SelfServiceErrorManager().Forward(ctx, w, r, err)
+		return
+	}
+
+	f, err := s.d.LoginFlowPersister().GetLoginFlow(ctx, uuid.FromStringOrNil(container.FlowID))
+	if err != nil {
+		s.d.SelfServiceErrorManager().Forward(ctx, w, r, err)
+		return
+	}
+
+	if err := s.completeCallback(w, r, f, &container); err != nil {
+		s.d.LoginFlowErrorHandler().WriteFlowError(w, r, f, s.NodeGroup(), err)
+		return
+	}
+}
+
+func (s *Strategy) completeCallback(w http.ResponseWriter, r *http.Request, f *login.Flow, container *redirectContainer) error {
+	ctx := r.Context()
+
+	if err := f.Valid(); err != nil {
+		return err
+	}
+
+	if r.URL.Query().Get("state") != container.State {
+		return errors.WithStack(herodot.ErrBadRequest.WithReason("Unable to complete the external MFA flow because the query state parameter does not match the state parameter from the session cookie."))
+	}
+
+	sess, err := s.d.SessionManager().FetchFromRequest(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	username, err := s.username(ctx, sess)
+	if err != nil {
+		return err
+	}
+
+	provider, err := s.Provider(ctx)
+	if err != nil {
+		return err
+	}
+
+	p, ok := provider.(RedirectProvider)
+	if !ok {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("External MFA provider %q does not support redirects.", provider.ID()))
+	}
+
+	if err := p.Verify(ctx, username, r.URL.Query().Get("code"), s.redirectURI(ctx)); errors.Is(err, ErrDenied) {
+		s.d.Logger().WithError(err).WithField("identity_id", sess.IdentityID).Info("External MFA provider denied the sign in.")
+		return errors.WithStack(schema.NewExternalMFADeniedError())
+	} else if err != nil {
+		return err
+	}
+
+	i, err := s.d.PrivilegedIdentityPool().GetIdentity(ctx, sess.IdentityID, identity.ExpandDefault)
+	if err != nil {
+		return err
+	}
+
+	sess.CompletedLoginForMethod(s.CompletedAuthenticationMethod(ctx))
+	return s.d.LoginHookExecutor().PostLoginHook(w, r, s.NodeGroup(), f, i, sess, "")
+}
+
+// username returns the identifier of the identity at the external MFA provider.
+func (s *Strategy) username(ctx context.Context, sess *session.Session) (string, error) {
+	i, err := s.d.PrivilegedIdentityPool().GetIdentityConfidential(ctx, sess.IdentityID)
+	if err != nil {
+		return "", err
+	}
+
+	c, ok := i.GetCredentials(s.ID())
+	if !ok || len(c.Identifiers) == 0 || c.Identifiers[0] == "" {
+		return "", errors.WithStack(schema.NewNoExternalMFARegistered())
+	}
+
+	return c.Identifiers[0], nil
+}
+
+func (s *Strategy) redirectURI(ctx context.Context) string {
+	return urlx.AppendPaths(s.d.Config().SelfPublicURL(ctx), RouteCallback).String()
+}
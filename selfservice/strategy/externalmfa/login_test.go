@@ -0,0 +1,189 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package externalmfa_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/x"
+)
+
+func createIdentity(t *testing.T, reg *driver.RegistryDefault, withExternalMFA bool) (*identity.Identity, string) {
+	username := x.NewUUID().String()
+	i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+	i.Traits = identity.Traits(fmt.Sprintf(`{"username":%q}`, username))
+	if withExternalMFA {
+		i.SetCredentials(identity.CredentialsTypeExternalMFA, identity.Credentials{
+			Type:        identity.CredentialsTypeExternalMFA,
+			Identifiers: []string{username},
+			Config:      []byte(`{}`),
+		})
+	}
+	require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(context.Background(), i))
+	return i, username
+}
+
+func TestCompleteLogin(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypePassword)+".enabled", true)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+
+	publicTS, _ := testhelpers.NewKratosServer(t, reg)
+	errTS := testhelpers.NewErrorTestServer(t, reg)
+	uiTS := testhelpers.NewLoginUIFlowEchoServer(t, reg)
+	redirTS := testhelpers.NewRedirSessionEchoTS(t, reg)
+	conf.MustSet(ctx, config.ViperKeySelfServiceErrorUI, errTS.URL+"/error-ts")
+	conf.MustSet(ctx, config.ViperKeySelfServiceLoginUI, uiTS.URL+"/login-ts")
+
+	duoResult := "allow"
+	duoTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"stat":"OK","response":{"result":%q,"status":"done","status_msg":"Finished"}}`, duoResult)
+	}))
+	t.Cleanup(duoTS.Close)
+
+	// The fake provider issues the login hint as code, unless a subject is forced.
+	var forcedSubject string
+	providerTS := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth":
+			q := r.URL.Query()
+			assert.Equal(t, "client", q.Get("client_id"))
+			code := q.Get("login_hint")
+			if forcedSubject != "" {
+				code = forcedSubject
+			}
+			http.Redirect(w, r, q.Get("redirect_uri")+"?"+url.Values{"state": {q.Get("state")}, "code": {code}}.Encode(), http.StatusSeeOther)
+		case "/verify":
+			if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"subject": r.PostFormValue("code"), "result": "allow"})
+		}
+	}))
+	t.Cleanup(providerTS.Close)
+
+	useProvider := func(t *testing.T, provider string) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypeExternalMFA), map[string]interface{}{
+			"enabled": true,
+			"config": map[string]interface{}{
+				"provider": provider,
+				"label":    "Acme",
+				"duo":      map[string]interface{}{"api_hostname": duoTS.URL, "integration_key": "ikey", "secret_key": "skey"},
+				"redirect": map[string]interface{}{"auth_url": providerTS.URL + "/auth", "verify_url": providerTS.URL + "/verify", "client_id": "client", "client_secret": "secret"},
+			},
+		})
+	}
+
+	methodNode := `ui.nodes.#(attributes.value=="external_mfa")`
+
+	doAPIFlow := func(t *testing.T, i *identity.Identity) (string, *http.Response) {
+		apiClient := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+		f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false, testhelpers.InitFlowWithAAL(identity.AuthenticatorAssuranceLevel2))
+		return testhelpers.LoginMakeRequest(t, true, false, f, apiClient, `{"method":"external_mfa"}`)
+	}
+
+	doBrowserFlow := func(t *testing.T, i *identity.Identity) (string, *http.Response) {
+		browserClient := testhelpers.NewHTTPClientWithIdentitySessionCookie(t, ctx, reg, i)
+		f := testhelpers.InitializeLoginFlowViaBrowser(t, browserClient, publicTS, false, false, false, false, testhelpers.InitFlowWithAAL(identity.AuthenticatorAssuranceLevel2))
+		values := testhelpers.SDKFormFieldsToURLValues(f.Ui.Nodes)
+		values.Set("method", "external_mfa")
+		return testhelpers.LoginMakeRequest(t, false, false, f, browserClient, values.Encode())
+	}
+
+	t.Run("case=method is offered when the identity has external mfa", func(t *testing.T) {
+		useProvider(t, "duo")
+		i, _ := createIdentity(t, reg, true)
+		apiClient := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+		f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false, testhelpers.InitFlowWithAAL(identity.AuthenticatorAssuranceLevel2))
+		raw, err := json.Marshal(f)
+		require.NoError(t, err)
+		assert.EqualValues(t, text.InfoSelfServiceLoginExternalMFA, gjson.GetBytes(raw, methodNode+".meta.label.id").Int(), "%s", raw)
+		assert.Equal(t, "Verify with Acme", gjson.GetBytes(raw, methodNode+".meta.label.text").String(), "%s", raw)
+	})
+
+	t.Run("case=method is not offered without external mfa", func(t *testing.T) {
+		useProvider(t, "duo")
+		i, _ := createIdentity(t, reg, false)
+		apiClient := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+		f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false, testhelpers.InitFlowWithAAL(identity.AuthenticatorAssuranceLevel2))
+		raw, err := json.Marshal(f)
+		require.NoError(t, err)
+		assert.False(t, gjson.GetBytes(raw, methodNode).Exists(), "%s", raw)
+	})
+
+	t.Run("case=redirect is not offered to api flows", func(t *testing.T) {
+		useProvider(t, "redirect")
+		i, _ := createIdentity(t, reg, true)
+		apiClient := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+		f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false, testhelpers.InitFlowWithAAL(identity.AuthenticatorAssuranceLevel2))
+		raw, err := json.Marshal(f)
+		require.NoError(t, err)
+		assert.False(t, gjson.GetBytes(raw, methodNode).Exists(), "%s", raw)
+	})
+
+	t.Run("case=duo approves the sign in", func(t *testing.T) {
+		useProvider(t, "duo")
+		duoResult = "allow"
+		i, _ := createIdentity(t, reg, true)
+
+		body, res := doAPIFlow(t, i)
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Equal(t, i.ID.String(), gjson.Get(body, "session.identity.id").String(), body)
+		assert.Equal(t, "aal2", gjson.Get(body, "session.authenticator_assurance_level").String(), body)
+		assert.Equal(t, string(identity.CredentialsTypeExternalMFA), gjson.Get(body, "session.authentication_methods.1.method").String(), body)
+	})
+
+	t.Run("case=duo denies the sign in", func(t *testing.T) {
+		useProvider(t, "duo")
+		duoResult = "deny"
+		t.Cleanup(func() { duoResult = "allow" })
+		i, _ := createIdentity(t, reg, true)
+
+		body, res := doAPIFlow(t, i)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+		assert.EqualValues(t, text.ErrorValidationExternalMFADenied, gjson.Get(body, "ui.messages.0.id").Int(), body)
+	})
+
+	t.Run("case=redirect provider completes the sign in", func(t *testing.T) {
+		useProvider(t, "redirect")
+		i, _ := createIdentity(t, reg, true)
+
+		body, res := doBrowserFlow(t, i)
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Contains(t, res.Request.URL.String(), redirTS.URL+"/return-ts")
+		assert.Equal(t, i.ID.String(), gjson.Get(body, "identity.id").String(), body)
+		assert.Equal(t, "aal2", gjson.Get(body, "authenticator_assurance_level").String(), body)
+		assert.Equal(t, string(identity.CredentialsTypeExternalMFA), gjson.Get(body, "authentication_methods.1.method").String(), body)
+	})
+
+	t.Run("case=redirect provider rejects codes of another subject", func(t *testing.T) {
+		useProvider(t, "redirect")
+		forcedSubject = "someone-else"
+		t.Cleanup(func() { forcedSubject = "" })
+		i, _ := createIdentity(t, reg, true)
+
+		body, res := doBrowserFlow(t, i)
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Contains(t, res.Request.URL.String(), uiTS.URL+"/login-ts")
+		assert.EqualValues(t, text.ErrorValidationExternalMFADenied, gjson.Get(body, "ui.messages.0.id").Int(), body)
+	})
+}
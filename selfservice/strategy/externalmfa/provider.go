@@ -0,0 +1,34 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package externalmfa
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDenied is returned by providers if the user did not approve the sign in.
+var ErrDenied = errors.New("the external MFA provider denied the sign in")
+
+// Provider is an external MFA provider. Every provider implements either PushProvider or
+// RedirectProvider.
+type Provider interface {
+	ID() string
+}
+
+// PushProvider challenges the user out of band (e.g. with a push notification) and blocks until
+// the user approved or denied the sign in.
+type PushProvider interface {
+	Provider
+	Authenticate(ctx context.Context, username string) error
+}
+
+// RedirectProvider sends the user to the provider, which redirects back with a code that is
+// verified server-side.
+type RedirectProvider interface {
+	Provider
+	AuthURL(username, state, redirectURI string) (string, error)
+	Verify(ctx context.Context, username, code, redirectURI string) error
+}
@@ -0,0 +1,144 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package externalmfa
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505 -- required by the Duo request signature
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/httpx"
+)
+
+const duoAuthPath = "/auth/v2/auth"
+
+var _ PushProvider = new(ProviderDuo)
+
+// ProviderDuo challenges users with the Duo Auth API.
+//
+// See https://duo.com/docs/authapi
+type ProviderDuo struct {
+	c *DuoConfiguration
+	d x.HTTPClientProvider
+}
+
+func NewProviderDuo(c *DuoConfiguration, d x.HTTPClientProvider) *ProviderDuo {
+	return &ProviderDuo{c: c, d: d}
+}
+
+func (p *ProviderDuo) ID() string {
+	return ProviderIDDuo
+}
+
+type duoResponse struct {
+	Stat     string `json:"stat"`
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	Response struct {
+		Result    string `json:"result"`
+		Status    string `json:"status"`
+		StatusMsg string `json:"status_msg"`
+	} `json:"response"`
+}
+
+// Authenticate sends the challenge and blocks until the user responded to it.
+func (p *ProviderDuo) Authenticate(ctx context.Context, username string) error {
+	factor := p.c.Factor
+	if factor == "" {
+		factor = "auto"
+	}
+
+	params := url.Values{
+		"username": {username},
+		"factor":   {factor},
+		"device":   {"auto"},
+	}
+
+	endpoint := p.endpoint()
+	body := duoCanonicalParams(params)
+	req, err := retryablehttp.NewRequestWithContext(ctx, "POST", endpoint.String(), strings.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	date := time.Now().UTC().Format(time.RFC1123Z)
+	req.Header.Set("Date", date)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.c.IntegrationKey, duoSignature(p.c.SecretKey, date, "POST", endpoint.Host, endpoint.Path, body))
+
+	// Retrying would send the user another challenge.
+	res, err := p.d.HTTPClient(ctx, httpx.ResilientClientWithMaxRetry(0)).Do(req)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to reach the Duo Auth API: %s", err))
+	}
+	defer res.Body.Close()
+
+	var r duoResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to decode the Duo Auth API response: %s", err))
+	}
+
+	if r.Stat != "OK" {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The Duo Auth API returned an error: %s", r.Message).WithDebugf("code=%d status=%d", r.Code, res.StatusCode))
+	}
+
+	if r.Response.Result != "allow" {
+		return errors.Wrapf(ErrDenied, "duo responded with %q: %s", r.Response.Status, r.Response.StatusMsg)
+	}
+
+	return nil
+}
+
+// endpoint returns the URL of the auth endpoint. The API hostname may include a scheme, which
+// is useful for testing.
+func (p *ProviderDuo) endpoint() *url.URL {
+	u, err := url.Parse(p.c.APIHostname)
+	if err != nil || u.Host == "" {
+		u = &url.URL{Scheme: "https", Host: p.c.APIHostname}
+	}
+	u.Path = duoAuthPath
+	return u
+}
+
+// duoCanonicalParams encodes the parameters sorted by key, escaping spaces as %20.
+func duoCanonicalParams(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	}
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range params[k] {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// duoSignature computes the HMAC-SHA1 signature of the canonical request.
+//
+// See https://duo.com/docs/authapi#authentication
+func duoSignature(secretKey, date, method, host, path, params string) string {
+	canonical := strings.Join([]string{date, strings.ToUpper(method), strings.ToLower(host), path, params}, "\n")
+	mac := hmac.New(sha1.New, []byte(secretKey))
+	_, _ = mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,87 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package externalmfa
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/httpx"
+)
+
+type httpClientProvider struct{}
+
+func (httpClientProvider) HTTPClient(_ context.Context, opts ...httpx.ResilientOptions) *retryablehttp.Client {
+	return httpx.NewResilientClient(opts...)
+}
+
+func TestDuoSignature(t *testing.T) {
+	// Example from https://duo.com/docs/authapi#authentication
+	params := duoCanonicalParams(url.Values{"username": {"root"}, "realname": {"First Last"}})
+	assert.Equal(t, "realname=First%20Last&username=root", params)
+
+	signature := duoSignature("Zh5eGmUq9zpfQnyUIu5OL9iWoMMv5ZNmk3zLJ4Ep", "Tue, 21 Aug 2012 17:29:18 -0000", "post", "API-XXXXXXXX.DUOSECURITY.COM", "/accounts/v1/account/list", params)
+	assert.Equal(t, "2d97d6166319781b5a3a07af39d366f491234edc", signature)
+}
+
+func TestProviderDuo(t *testing.T) {
+	ctx := context.Background()
+	conf := &DuoConfiguration{IntegrationKey: "DIWJ8X6AEYOR5OMC6TQ1", SecretKey: "Zh5eGmUq9zpfQnyUIu5OL9iWoMMv5ZNmk3zLJ4Ep"}
+
+	var result string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, duoAuthPath, r.URL.Path)
+		assert.Equal(t, "device=auto&factor=push&username=foo%20bar", string(body))
+
+		expected := conf.IntegrationKey + ":" + duoSignature(conf.SecretKey, r.Header.Get("Date"), r.Method, r.Host, r.URL.Path, string(body))
+		if r.Header.Get("Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte(expected)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"stat":"FAIL","code":40103,"message":"Invalid signature in request credentials"}`))
+			return
+		}
+
+		_, _ = fmt.Fprintf(w, `{"stat":"OK","response":{"result":%q,"status":"done","status_msg":"Finished"}}`, result)
+	}))
+	t.Cleanup(ts.Close)
+
+	conf.APIHostname = ts.URL
+	conf.Factor = "push"
+	p := NewProviderDuo(conf, httpClientProvider{})
+
+	t.Run("case=allows the sign in", func(t *testing.T) {
+		result = "allow"
+		assert.NoError(t, p.Authenticate(ctx, "foo bar"))
+	})
+
+	t.Run("case=denies the sign in", func(t *testing.T) {
+		result = "deny"
+		err := p.Authenticate(ctx, "foo bar")
+		assert.True(t, errors.Is(err, ErrDenied), "%+v", err)
+	})
+
+	t.Run("case=fails with invalid credentials", func(t *testing.T) {
+		p := NewProviderDuo(&DuoConfiguration{APIHostname: ts.URL, IntegrationKey: conf.IntegrationKey, SecretKey: "wrong", Factor: "push"}, httpClientProvider{})
+		err := p.Authenticate(ctx, "foo bar")
+		require.Error(t, err)
+		assert.False(t, errors.Is(err, ErrDenied))
+	})
+
+	t.Run("case=uses https for plain hostnames", func(t *testing.T) {
+		p := NewProviderDuo(&DuoConfiguration{APIHostname: "api-xxxxxxxx.duosecurity.com"}, httpClientProvider{})
+		assert.Equal(t, "https://api-xxxxxxxx.duosecurity.com/auth/v2/auth", p.endpoint().String())
+	})
+}
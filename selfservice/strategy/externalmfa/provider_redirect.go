@@ -0,0 +1,94 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package externalmfa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/httpx"
+)
+
+var _ RedirectProvider = new(ProviderRedirect)
+
+// ProviderRedirect sends users to a provider which redirects back with a code. The code is
+// verified by posting it to the verification URL.
+type ProviderRedirect struct {
+	c *RedirectConfiguration
+	d x.HTTPClientProvider
+}
+
+func NewProviderRedirect(c *RedirectConfiguration, d x.HTTPClientProvider) *ProviderRedirect {
+	return &ProviderRedirect{c: c, d: d}
+}
+
+func (p *ProviderRedirect) ID() string {
+	return ProviderIDRedirect
+}
+
+func (p *ProviderRedirect) AuthURL(username, state, redirectURI string) (string, error) {
+	u, err := url.Parse(p.c.AuthURL)
+	if err != nil {
+		return "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to parse the external MFA authorization URL: %s", err))
+	}
+
+	q := u.Query()
+	q.Set("client_id", p.c.ClientID)
+	q.Set("state", state)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("login_hint", username)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+type redirectVerifyResponse struct {
+	Subject string `json:"subject"`
+	Result  string `json:"result"`
+}
+
+func (p *ProviderRedirect) Verify(ctx context.Context, username, code, redirectURI string) error {
+	body := url.Values{"code": {code}, "redirect_uri": {redirectURI}}
+	req, err := retryablehttp.NewRequestWithContext(ctx, "POST", p.c.VerifyURL, strings.NewReader(body.Encode()))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(p.c.ClientID, p.c.ClientSecret)
+
+	res, err := p.d.HTTPClient(ctx, httpx.ResilientClientWithMaxRetry(0)).Do(req)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to reach the external MFA provider: %s", err))
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The external MFA provider responded with status code %d.", res.StatusCode))
+	}
+
+	var r redirectVerifyResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to decode the external MFA provider response: %s", err))
+	}
+
+	if r.Result != "allow" {
+		return errors.Wrapf(ErrDenied, "provider responded with %q", r.Result)
+	}
+
+	// The code must have been issued for the identity which is signing in.
+	if r.Subject != username {
+		return errors.Wrapf(ErrDenied, "provider verified subject %q but expected %q", r.Subject, username)
+	}
+
+	return nil
+}
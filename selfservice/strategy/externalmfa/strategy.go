@@ -0,0 +1,96 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package externalmfa
+
+import (
+	"context"
+
+	"github.com/ory/kratos/continuity"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/selfservice/errorx"
+	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/decoderx"
+)
+
+var (
+	_ login.Strategy                    = new(Strategy)
+	_ login.UnifiedFormHydrator         = new(Strategy)
+	_ identity.ActiveCredentialsCounter = new(Strategy)
+)
+
+type externalMFAStrategyDependencies interface {
+	x.LoggingProvider
+	x.WriterProvider
+	x.CSRFTokenGeneratorProvider
+	x.CSRFProvider
+	x.TracingProvider
+	x.HTTPClientProvider
+
+	config.Provider
+
+	continuity.ManagementProvider
+
+	errorx.ManagementProvider
+
+	login.HooksProvider
+	login.ErrorHandlerProvider
+	login.HookExecutorProvider
+	login.FlowPersistenceProvider
+	login.HandlerProvider
+
+	identity.PrivilegedPoolProvider
+
+	session.HandlerProvider
+	session.ManagementProvider
+}
+
+// Strategy completes the second factor at an external MFA provider such as Duo. The
+// identity's username at the provider is the identifier of its external_mfa credentials.
+type Strategy struct {
+	d  externalMFAStrategyDependencies
+	hd *decoderx.HTTP
+}
+
+func NewStrategy(d any) *Strategy {
+	return &Strategy{
+		d:  d.(externalMFAStrategyDependencies),
+		hd: decoderx.NewHTTP(),
+	}
+}
+
+func (s *Strategy) CountActiveFirstFactorCredentials(_ context.Context, _ map[identity.CredentialsType]identity.Credentials) (count int, err error) {
+	return 0, nil
+}
+
+func (s *Strategy) CountActiveMultiFactorCredentials(ctx context.Context, cc map[identity.CredentialsType]identity.Credentials) (count int, err error) {
+	if !s.d.Config().SelfServiceStrategy(ctx, string(s.ID())).Enabled {
+		return 0, nil
+	}
+
+	for _, c := range cc {
+		if c.Type == s.ID() && len(c.Identifiers) > 0 && c.Identifiers[0] != "" {
+			count++
+		}
+	}
+	return
+}
+
+func (s *Strategy) ID() identity.CredentialsType {
+	return identity.CredentialsTypeExternalMFA
+}
+
+func (s *Strategy) NodeGroup() node.UiNodeGroup {
+	return node.ExternalMFAGroup
+}
+
+func (s *Strategy) CompletedAuthenticationMethod(ctx context.Context) session.AuthenticationMethod {
+	return session.AuthenticationMethod{
+		Method: s.ID(),
+		AAL:    identity.AuthenticatorAssuranceLevel2,
+	}
+}
@@ -4,6 +4,7 @@
 package idfirst
 
 import (
+	"context"
 	"net/http"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -26,9 +27,10 @@ import (
 )
 
 var (
-	_                     login.FormHydrator = new(Strategy)
-	_                     login.Strategy     = new(Strategy)
-	ErrNoCredentialsFound                    = errors.New("no credentials found")
+	_                     login.FormHydrator      = new(Strategy)
+	_                     login.Strategy          = new(Strategy)
+	_                     login.LoginHintHydrator = new(Strategy)
+	ErrNoCredentialsFound                         = errors.New("no credentials found")
 )
 
 func (s *Strategy) handleLoginError(r *http.Request, f *login.Flow, payload updateLoginFlowWithIdentifierFirstMethod, err error) error {
@@ -69,10 +71,33 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 		return nil, s.handleLoginError(r, f, p, err)
 	}
 
-	var opts []login.FormHydratorModifier
+	identityHint, err := s.findIdentityHint(ctx, p.Identifier)
+	if err != nil {
+		return nil, s.handleLoginError(r, f, p, err)
+	}
+
+	if err := s.PopulateIdentifierFirstCredentials(r, f, identityHint, p.Identifier); err != nil {
+		return nil, s.handleLoginError(r, f, p, err)
+	}
+
+	f.Active = s.ID()
+	if err = s.d.LoginFlowPersister().UpdateLoginFlow(ctx, f); err != nil {
+		return nil, s.handleLoginError(r, f, p, err)
+	}
 
-	// Look up the user by the identifier.
-	identityHint, err := s.d.PrivilegedIdentityPool().FindIdentityByCredentialIdentifier(ctx, p.Identifier,
+	if x.IsJSONRequest(r) {
+		s.d.Writer().WriteCode(w, r, http.StatusBadRequest, f)
+	} else {
+		http.Redirect(w, r, f.AppendTo(s.d.Config().SelfServiceFlowLoginUI(ctx)).String(), http.StatusSeeOther)
+	}
+
+	return nil, flow.ErrCompletedByStrategy
+}
+
+// findIdentityHint looks up the identity of the identifier. It returns nil if no identity has the
+// identifier.
+func (s *Strategy) findIdentityHint(ctx context.Context, identifier string) (*identity.Identity, error) {
+	identityHint, err := s.d.PrivilegedIdentityPool().FindIdentityByCredentialIdentifier(ctx, identifier,
 		// We are dealing with user input -> lookup should be case-insensitive.
 		false,
 	)
@@ -81,25 +106,60 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 		// We have to mitigate account enumeration. So we continue without setting the identity hint.
 		//
 		// This will later be handled by `didPopulate`.
+		return nil, nil
 	} else if err != nil {
 		// An error happened during lookup
-		return nil, s.handleLoginError(r, f, p, err)
+		return nil, err
 	} else if !s.d.Config().SecurityAccountEnumerationMitigate(ctx) {
 		// Hydrate credentials
 		if err := s.d.PrivilegedIdentityPool().HydrateIdentityAssociations(ctx, identityHint, identity.ExpandCredentials); err != nil {
-			return nil, s.handleLoginError(r, f, p, err)
+			return nil, err
 		}
 	}
 
+	return identityHint, nil
+}
+
+// PopulateLoginMethodLoginHint shows the login methods of the login hint, as if the user entered it
+// as identifier. If the account does not exist and account enumeration mitigation is disabled, the
+// flow stays at the identification step instead of showing an error.
+func (s *Strategy) PopulateLoginMethodLoginHint(r *http.Request, f *login.Flow) error {
+	ctx := r.Context()
+	if !s.d.Config().SelfServiceLoginFlowIdentifierFirstEnabled(ctx) {
+		return nil
+	}
+
+	identityHint, err := s.findIdentityHint(ctx, f.LoginHint)
+	if err != nil {
+		return err
+	}
+
+	if err := s.PopulateIdentifierFirstCredentials(r, f, identityHint, f.LoginHint); err != nil {
+		if validationErr := new(schema.ValidationError); errors.As(err, &validationErr) {
+			return nil
+		}
+		return err
+	}
+
+	f.Active = s.ID()
+	return nil
+}
+
+// PopulateIdentifierFirstCredentials adds the credentials of all login strategies for the identity
+// hint to the flow, as shown once the user entered their identifier. The identity hint is nil if
+// no identity has the identifier.
+func (s *Strategy) PopulateIdentifierFirstCredentials(r *http.Request, f *login.Flow, identityHint *identity.Identity, identifier string) error {
 	f.UI.ResetMessages()
-	f.UI.Nodes.SetValueAttribute("identifier", p.Identifier)
+	f.UI.Nodes.SetValueAttribute("identifier", identifier)
 
 	// Add identity hint
-	opts = append(opts, login.WithIdentityHint(identityHint))
-	opts = append(opts, login.WithIdentifier(p.Identifier))
+	opts := []login.FormHydratorModifier{
+		login.WithIdentityHint(identityHint),
+		login.WithIdentifier(identifier),
+	}
 
 	didPopulate := false
-	for _, ls := range s.d.LoginStrategies(ctx) {
+	for _, ls := range s.d.LoginStrategies(r.Context()) {
 		populator, ok := ls.(login.FormHydrator)
 		if !ok {
 			continue
@@ -111,7 +171,7 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 		} else if errors.Is(err, ErrNoCredentialsFound) {
 			// This strategy is not responsible for this flow. We do not set didPopulate to true if that happens.
 		} else if err != nil {
-			return nil, s.handleLoginError(r, f, p, err)
+			return err
 		} else {
 			didPopulate = true
 		}
@@ -119,8 +179,8 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 
 	// If no strategy populated, it means that the account (very likely) does not exist. We show a user not found error,
 	// but only if account enumeration mitigation is disabled. Otherwise, we proceed to render the rest of the form.
-	if !didPopulate && !s.d.Config().SecurityAccountEnumerationMitigate(ctx) {
-		return nil, s.handleLoginError(r, f, p, errors.WithStack(schema.NewAccountNotFoundError()))
+	if !didPopulate && !s.d.Config().SecurityAccountEnumerationMitigate(r.Context()) {
+		return errors.WithStack(schema.NewAccountNotFoundError())
 	}
 
 	// We found credentials - hide the identifier.
@@ -142,18 +202,7 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 		f.UI.Nodes[k].Attributes = attrs
 	}
 
-	f.Active = s.ID()
-	if err = s.d.LoginFlowPersister().UpdateLoginFlow(ctx, f); err != nil {
-		return nil, s.handleLoginError(r, f, p, err)
-	}
-
-	if x.IsJSONRequest(r) {
-		s.d.Writer().WriteCode(w, r, http.StatusBadRequest, f)
-	} else {
-		http.Redirect(w, r, f.AppendTo(s.d.Config().SelfServiceFlowLoginUI(ctx)).String(), http.StatusSeeOther)
-	}
-
-	return nil, flow.ErrCompletedByStrategy
+	return nil
 }
 
 func (s *Strategy) PopulateLoginMethodFirstFactorRefresh(r *http.Request, sr *login.Flow) error {
@@ -424,6 +424,44 @@ func TestCompleteLogin(t *testing.T) {
 		})
 	})
 
+	t.Run("case=should show the login methods of the login hint", func(t *testing.T) {
+		testhelpers.StrategyEnable(t, conf, identity.CredentialsTypePassword.String(), true)
+		conf.MustSet(ctx, config.ViperKeySecurityAccountEnumerationMitigate, false)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, "selfservice.methods.password", nil)
+			conf.MustSet(ctx, config.ViperKeySecurityAccountEnumerationMitigate, nil)
+		})
+
+		initFlow := func(t *testing.T, hint string) string {
+			res, err := apiClient.Get(publicTS.URL + login.RouteInitAPIFlow + "?" + url.Values{"login_hint": {hint}}.Encode())
+			require.NoError(t, err)
+			body := string(ioutilx.MustReadAll(res.Body))
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+			return body
+		}
+
+		t.Run("case=account exists", func(t *testing.T) {
+			identifier := x.NewUUID().String()
+			createIdentity(ctx, reg, t, identifier, "password")
+
+			body := initFlow(t, identifier)
+			assert.Equal(t, identifier, gjson.Get(body, "login_hint").String(), "%s", body)
+			assert.Equal(t, "identifier_first", gjson.Get(body, "active").String(), "%s", body)
+			assert.Contains(t, body, "current-password")
+			assert.Equal(t, "hidden", gjson.Get(body, "ui.nodes.#(attributes.name==identifier).attributes.type").String(), "%s", body)
+			assert.Equal(t, identifier, gjson.Get(body, "ui.nodes.#(attributes.name==identifier).attributes.value").String(), "%s", body)
+		})
+
+		t.Run("case=account does not exist", func(t *testing.T) {
+			body := initFlow(t, "does-not-exist@ory.sh")
+			assert.Empty(t, gjson.Get(body, "active").String(), "%s", body)
+			assert.NotContains(t, body, text.NewErrorValidationAccountNotFound().Text, "%s", body)
+			assert.Equal(t, "text", gjson.Get(body, "ui.nodes.#(attributes.name==identifier).attributes.type").String(), "%s", body)
+			assert.Equal(t, "does-not-exist@ory.sh", gjson.Get(body, "ui.nodes.#(attributes.name==identifier).attributes.value").String(), "%s", body)
+		})
+	})
+
 	t.Run("should pass with real request", func(t *testing.T) {
 		identifier, pwd := x.NewUUID().String(), "password"
 		createIdentity(ctx, reg, t, identifier, pwd)
@@ -7,6 +7,7 @@ import (
 	"context"
 	"net/url"
 
+	"github.com/ory/kratos/courier/template"
 	"github.com/ory/kratos/courier/template/email"
 
 	"github.com/pkg/errors"
@@ -66,28 +67,7 @@ func (s *Sender) SendRecoveryLink(ctx context.Context, f *recovery.Flow, via ide
 
 	address, err := s.r.IdentityPool().FindRecoveryAddressByValue(ctx, identity.RecoveryAddressTypeEmail, to)
 	if errors.Is(err, sqlcon.ErrNoRows) {
-		notifyUnknownRecipients := s.r.Config().SelfServiceFlowRecoveryNotifyUnknownRecipients(ctx)
-		s.r.Audit().
-			WithField("via", via).
-			WithField("strategy", "link").
-			WithSensitiveField("email_address", address).
-			WithField("was_notified", notifyUnknownRecipients).
-			Info("Account recovery was requested for an unknown address.")
-
-		transientPayload, err := x.ParseRawMessageOrEmpty(f.GetTransientPayload())
-		if err != nil {
-			return errors.WithStack(err)
-		}
-		if !notifyUnknownRecipients {
-			// do nothing
-		} else if err := s.send(ctx, string(via), email.NewRecoveryInvalid(s.r, &email.RecoveryInvalidModel{
-			To:               to,
-			RequestURL:       f.GetRequestURL(),
-			TransientPayload: transientPayload,
-		})); err != nil {
-			return err
-		}
-		return errors.WithStack(ErrUnknownAddress)
+		return s.sendRecoveryInvalid(ctx, f, via, to, "Account recovery was requested for an unknown address.")
 	} else if err != nil {
 		// DB error
 		return err
@@ -99,6 +79,11 @@ func (s *Sender) SendRecoveryLink(ctx context.Context, f *recovery.Flow, via ide
 		return err
 	}
 
+	if s.r.Config().SelfServiceFlowVerificationRequiredForRecovery(ctx) && !i.IsAddressVerified(address.Value) {
+		// Unverified addresses are treated like unknown addresses to prevent account enumeration.
+		return s.sendRecoveryInvalid(ctx, f, via, to, "Account recovery was requested for an unverified address.")
+	}
+
 	token := NewSelfServiceRecoveryToken(address, f, s.r.Config().SelfServiceLinkMethodLifespan(ctx))
 	if err := s.r.RecoveryTokenPersister().CreateRecoveryToken(ctx, token); err != nil {
 		return err
@@ -111,6 +96,34 @@ func (s *Sender) SendRecoveryLink(ctx context.Context, f *recovery.Flow, via ide
 	return nil
 }
 
+// sendRecoveryInvalid notifies the recipient, if enabled, that recovery is not possible for the
+// address and returns ErrUnknownAddress.
+func (s *Sender) sendRecoveryInvalid(ctx context.Context, f *recovery.Flow, via identity.VerifiableAddressType, to, reason string) error {
+	notifyUnknownRecipients := s.r.Config().SelfServiceFlowRecoveryNotifyUnknownRecipients(ctx)
+	s.r.Audit().
+		WithField("via", via).
+		WithField("strategy", "link").
+		WithSensitiveField("email_address", to).
+		WithField("was_notified", notifyUnknownRecipients).
+		Info(reason)
+
+	transientPayload, err := x.ParseRawMessageOrEmpty(f.GetTransientPayload())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !notifyUnknownRecipients {
+		// do nothing
+	} else if err := s.send(ctx, string(via), email.NewRecoveryInvalid(s.r, &email.RecoveryInvalidModel{
+		To:               to,
+		RequestURL:       f.GetRequestURL(),
+		Branding:         template.Branding{Brand: f.GetBrand()},
+		TransientPayload: transientPayload,
+	})); err != nil {
+		return err
+	}
+	return errors.WithStack(ErrUnknownAddress)
+}
+
 // SendVerificationLink sends a verification link to the specified address
 //
 // If the address does not exist in the store and dispatching invalid emails is enabled (CourierEnableInvalidDispatch is
@@ -141,6 +154,7 @@ func (s *Sender) SendVerificationLink(ctx context.Context, f *verification.Flow,
 		} else if err := s.send(ctx, string(via), email.NewVerificationInvalid(s.r, &email.VerificationInvalidModel{
 			To:               to,
 			RequestURL:       f.GetRequestURL(),
+			Branding:         template.Branding{Brand: f.GetBrand()},
 			TransientPayload: transientPayload,
 		})); err != nil {
 			return err
@@ -201,6 +215,7 @@ func (s *Sender) SendRecoveryTokenTo(ctx context.Context, f *recovery.Flow, i *i
 			RecoveryURL:      recoveryUrl,
 			Identity:         model,
 			RequestURL:       f.GetRequestURL(),
+			Branding:         template.Branding{Brand: f.GetBrand()},
 			TransientPayload: transientPayload,
 			ExpiresInMinutes: int(s.r.Config().SelfServiceLinkMethodLifespan(ctx).Minutes()),
 		}))
@@ -238,6 +253,7 @@ func (s *Sender) SendVerificationTokenTo(ctx context.Context, f *verification.Fl
 			VerificationURL:  verificationUrl,
 			Identity:         model,
 			RequestURL:       f.GetRequestURL(),
+			Branding:         template.Branding{Brand: f.GetBrand()},
 			TransientPayload: transientPayload,
 			ExpiresInMinutes: int(s.r.Config().SelfServiceLinkMethodLifespan(ctx).Minutes()),
 		})); err != nil {
@@ -340,6 +340,7 @@ func (s *Strategy) recoveryIssueSession(ctx context.Context, w http.ResponseWrit
 	if err != nil {
 		return s.retryRecoveryFlowWithError(w, r, flow.TypeBrowser, err)
 	}
+	sf.Brand = f.Brand
 
 	returnToURL := s.d.Config().SelfServiceFlowRecoveryReturnTo(r.Context(), nil)
 	returnTo := ""
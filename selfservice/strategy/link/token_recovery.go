@@ -68,7 +68,7 @@ func (RecoveryToken) TableName(ctx context.Context) string {
 }
 
 func NewSelfServiceRecoveryToken(address *identity.RecoveryAddress, f *recovery.Flow, expiresIn time.Duration) *RecoveryToken {
-	now := time.Now().UTC()
+	now := x.Now().UTC()
 	var identityID = uuid.UUID{}
 	var recoveryAddressID = uuid.UUID{}
 	if address != nil {
@@ -89,7 +89,7 @@ func NewSelfServiceRecoveryToken(address *identity.RecoveryAddress, f *recovery.
 }
 
 func NewAdminRecoveryToken(identityID uuid.UUID, fID uuid.UUID, expiresIn time.Duration) *RecoveryToken {
-	now := time.Now().UTC()
+	now := x.Now().UTC()
 	return &RecoveryToken{
 		ID:         x.NewUUID(),
 		Token:      randx.MustString(32, randx.AlphaNum),
@@ -102,7 +102,7 @@ func NewAdminRecoveryToken(identityID uuid.UUID, fID uuid.UUID, expiresIn time.D
 }
 
 func (f *RecoveryToken) Valid() error {
-	if f.ExpiresAt.Before(time.Now()) {
+	if f.ExpiresAt.Before(x.Now()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 	return nil
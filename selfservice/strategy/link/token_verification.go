@@ -56,7 +56,7 @@ func (VerificationToken) TableName(ctx context.Context) string {
 }
 
 func NewSelfServiceVerificationToken(address *identity.VerifiableAddress, f *verification.Flow, expiresIn time.Duration) *VerificationToken {
-	now := time.Now().UTC()
+	now := x.Now().UTC()
 	return &VerificationToken{
 		ID:                x.NewUUID(),
 		Token:             randx.MustString(32, randx.AlphaNum),
@@ -68,7 +68,7 @@ func NewSelfServiceVerificationToken(address *identity.VerifiableAddress, f *ver
 }
 
 func (f *VerificationToken) Valid() error {
-	if f.ExpiresAt.Before(time.Now().UTC()) {
+	if f.ExpiresAt.Before(x.Now().UTC()) {
 		return errors.WithStack(flow.NewFlowExpiredError(f.ExpiresAt))
 	}
 	return nil
@@ -4,6 +4,7 @@
 package lookup
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -19,6 +20,8 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ory/herodot"
+	"github.com/ory/kratos/courier/template"
+	"github.com/ory/kratos/courier/template/email"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/flow"
@@ -167,6 +170,8 @@ func (s *Strategy) Login(_ http.ResponseWriter, r *http.Request, f *login.Flow,
 		return nil, s.handleLoginError(r, f, errors.WithStack(herodot.ErrInternalServerError.WithReason("Unable to update identity.").WithDebug(err.Error())))
 	}
 
+	s.notifyLowCodes(r, f, toUpdate, o.RemainingCodes())
+
 	f.Active = s.ID()
 	if err = s.d.LoginFlowPersister().UpdateLoginFlow(ctx, f); err != nil {
 		return nil, s.handleLoginError(r, f, errors.WithStack(herodot.ErrInternalServerError.WithReason("Could not update flow.").WithDebug(err.Error())))
@@ -174,3 +179,54 @@ func (s *Strategy) Login(_ http.ResponseWriter, r *http.Request, f *login.Flow,
 
 	return i, nil
 }
+
+// notifyLowCodes warns the identity's verified email addresses when the number of unused lookup
+// secrets drops to the configured threshold. Failures are logged but do not fail the login.
+func (s *Strategy) notifyLowCodes(r *http.Request, f *login.Flow, i *identity.Identity, remaining int) {
+	ctx := r.Context()
+	threshold := s.d.Config().LookupSecretLowCodesThreshold(ctx)
+	if threshold <= 0 || remaining > threshold {
+		return
+	}
+
+	if err := s.sendLowCodes(ctx, f, i, remaining); err != nil {
+		s.d.Logger().WithRequest(r).WithError(err).Warn("Unable to send the lookup secrets low email.")
+		return
+	}
+
+	s.d.Audit().
+		WithRequest(r).
+		WithField("identity_id", i.ID).
+		WithField("remaining_codes", remaining).
+		Info("Sent lookup secrets low email because only few lookup secrets are left.")
+}
+
+func (s *Strategy) sendLowCodes(ctx context.Context, f *login.Flow, i *identity.Identity, remaining int) error {
+	model, err := x.StructToMap(i)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.d.Courier(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, address := range i.VerifiableAddresses {
+		if address.Via != identity.AddressTypeEmail || !address.Verified {
+			continue
+		}
+
+		if _, err := c.QueueEmail(ctx, email.NewLookupSecretsLow(s.d, &email.LookupSecretsLowModel{
+			To:             address.Value,
+			Identity:       model,
+			RequestURL:     f.GetRequestURL(),
+			RemainingCodes: remaining,
+			Branding:       template.Branding{Brand: f.GetBrand()},
+		})); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
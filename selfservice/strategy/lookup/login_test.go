@@ -21,6 +21,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tidwall/gjson"
 
+	"github.com/ory/kratos/courier"
+	"github.com/ory/kratos/courier/template"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/internal"
@@ -30,6 +32,7 @@ import (
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/assertx"
+	"github.com/ory/x/pagination/keysetpagination"
 )
 
 var lookupCodeGJSONQuery = "ui.nodes.#(attributes.name==" + identity.CredentialsTypeLookup.String() + ")"
@@ -269,6 +272,52 @@ func TestCompleteLogin(t *testing.T) {
 		})
 	})
 
+	t.Run("case=should send an email when few codes are left", func(t *testing.T) {
+		testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/email.schema.json")
+		t.Cleanup(func() { testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/login.schema.json") })
+
+		messages := func(t *testing.T, recipient string) []courier.Message {
+			m, _, _, err := reg.CourierPersister().ListMessages(ctx, courier.ListCourierMessagesParameters{Recipient: recipient}, []keysetpagination.Option{})
+			require.NoError(t, err)
+			return m
+		}
+
+		signIn := func(t *testing.T, threshold int) string {
+			conf.MustSet(ctx, config.ViperKeyLookupSecretLowCodesThreshold, threshold)
+			t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyLookupSecretLowCodesThreshold, 0) })
+
+			id, _ := createIdentity(t, reg)
+			id.VerifiableAddresses[0].Via = identity.AddressTypeEmail
+			id.VerifiableAddresses[0].Verified = true
+			id.VerifiableAddresses[0].Status = identity.VerifiableAddressStatusCompleted
+			require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentity(ctx, id))
+
+			// Eight of the twelve codes are unused, so seven are left after this login.
+			body, _ := doAPIFlow(t, func(v url.Values) {
+				v.Set(node.LookupCodeEnter, "key-0")
+			}, id)
+			require.True(t, gjson.Get(body, "session.active").Bool(), "%s", body)
+			return id.VerifiableAddresses[0].Value
+		}
+
+		t.Run("case=below the threshold", func(t *testing.T) {
+			recipient := signIn(t, 7)
+			m := messages(t, recipient)
+			require.Len(t, m, 1)
+			assert.Equal(t, "You are running out of backup recovery codes", m[0].Subject)
+			assert.Contains(t, m[0].Body, "7")
+			assert.EqualValues(t, template.TypeLookupSecretsLow, m[0].TemplateType)
+		})
+
+		t.Run("case=above the threshold", func(t *testing.T) {
+			assert.Empty(t, messages(t, signIn(t, 6)))
+		})
+
+		t.Run("case=disabled", func(t *testing.T) {
+			assert.Empty(t, messages(t, signIn(t, 0)))
+		})
+	})
+
 	t.Run("case=should fail because lookup can not handle AAL1", func(t *testing.T) {
 		apiClient := testhelpers.NewDebugClient(t)
 		f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false)
@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 
@@ -16,8 +15,6 @@ import (
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 
-	"github.com/ory/x/randx"
-
 	"github.com/ory/herodot"
 	"github.com/ory/kratos/ui/node"
 
@@ -45,14 +42,13 @@ const (
 	InternalContextKeyRegenerated = "regenerated"
 )
 
-const numCodes = 12
-
 var allSettingsNodes = []string{
 	node.LookupRegenerate,
 	node.LookupReveal,
 	node.LookupRegenerate,
 	node.LookupDisable,
 	node.LookupCodes,
+	node.LookupStatus,
 	node.LookupConfirm,
 }
 
@@ -159,8 +155,8 @@ func (s *Strategy) continueSettingsFlow(ctx context.Context, r *http.Request, ct
 			return err
 		}
 
-		if ctxUpdate.Session.AuthenticatedAt.Add(s.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)).Before(time.Now()) {
-			return errors.WithStack(settings.NewFlowNeedsReAuth())
+		if err := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup()); err != nil {
+			return err
 		}
 	} else {
 		return errors.New("ended up in unexpected state")
@@ -257,16 +253,14 @@ func (s *Strategy) continueSettingsFlowReveal(ctx context.Context, ctxUpdate *se
 }
 
 func (s *Strategy) continueSettingsFlowRegenerate(ctx context.Context, ctxUpdate *settings.UpdateContext) error {
-	codes := make([]identity.RecoveryCode, numCodes)
-	for k := range codes {
-		codes[k] = identity.RecoveryCode{Code: randx.MustString(8, randx.AlphaLowerNum)}
-	}
+	regenerated := identity.NewCredentialsLookupConfig()
+	codes := regenerated.RecoveryCodes
 
 	for _, n := range allSettingsNodes {
 		ctxUpdate.Flow.UI.Nodes.Remove(n)
 	}
 
-	ctxUpdate.Flow.UI.Nodes.Upsert((&identity.CredentialsLookupConfig{RecoveryCodes: codes}).ToNode())
+	ctxUpdate.Flow.UI.Nodes.Upsert(regenerated.ToNode())
 	ctxUpdate.Flow.UI.Nodes.Upsert(NewConfirmLookupNode())
 
 	var err error
@@ -284,7 +278,7 @@ func (s *Strategy) continueSettingsFlowRegenerate(ctx context.Context, ctxUpdate
 
 func (s *Strategy) continueSettingsFlowConfirm(ctx context.Context, ctxUpdate *settings.UpdateContext) error {
 	codes := gjson.GetBytes(ctxUpdate.Flow.InternalContext, flow.PrefixInternalContextKey(s.ID(), InternalContextKeyRegenerated)).Array()
-	if len(codes) != numCodes {
+	if len(codes) != identity.LookupSecretCodes {
 		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("You must (re-)generate recovery backup codes before you can save them."))
 	}
 
@@ -356,6 +350,13 @@ func (s *Strategy) PopulateSettingsMethod(ctx context.Context, r *http.Request,
 	}
 
 	if hasLookup {
+		if c, ok := id.GetCredentials(s.ID()); ok {
+			var creds identity.CredentialsLookupConfig
+			if err := json.Unmarshal(c.Config, &creds); err != nil {
+				return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode lookup codes from JSON.").WithDebug(err.Error()))
+			}
+			f.UI.Nodes.Upsert(creds.ToStatusNode())
+		}
 		f.UI.Nodes.Upsert(NewRevealLookupNode())
 		f.UI.Nodes.Upsert(NewDisableLookupNode())
 	} else {
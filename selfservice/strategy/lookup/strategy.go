@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ory/kratos/continuity"
+	"github.com/ory/kratos/courier"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/hash"
 	"github.com/ory/kratos/identity"
@@ -36,9 +37,13 @@ type lookupStrategyDependencies interface {
 	x.CSRFProvider
 	x.TransactionPersistenceProvider
 	x.TracingProvider
+	x.HTTPClientProvider
 
 	config.Provider
 
+	courier.Provider
+	courier.ConfigProvider
+
 	continuity.ManagementProvider
 
 	errorx.ManagementProvider
@@ -33,9 +33,11 @@ type (
 	OAuth1Provider interface {
 		Provider
 		OAuth1(ctx context.Context) *oauth1.Config
-		AuthURL(ctx context.Context, state string) (string, error)
+		// AuthURL returns the authorization URL and the request token secret. The secret is
+		// stored in the continuity container and passed to ExchangeToken on callback.
+		AuthURL(ctx context.Context, state string) (authURL string, requestSecret string, err error)
 		Claims(ctx context.Context, token *oauth1.Token) (*Claims, error)
-		ExchangeToken(ctx context.Context, req *http.Request) (*oauth1.Token, error)
+		ExchangeToken(ctx context.Context, req *http.Request, requestSecret string) (*oauth1.Token, error)
 	}
 )
 
@@ -12,6 +12,8 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ory/herodot"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/x/stringsx"
 	"github.com/ory/x/urlx"
 )
 
@@ -21,6 +23,7 @@ type Configuration struct {
 
 	// Provider is either "generic" for a generic OAuth 2.0 / OpenID Connect Provider or one of:
 	// - generic
+	// - generic-oauth1
 	// - google
 	// - github
 	// - github-app
@@ -64,6 +67,15 @@ type Configuration struct {
 	// `provider` is set to `generic`.
 	TokenURL string `json:"token_url"`
 
+	// RequestTokenURL is the OAuth1 request token url, typically something like: https://example.org/oauth/request_token
+	// Should only be used when `provider` is set to `generic-oauth1`. In that case, `auth_url` is the OAuth1
+	// authorization url and `token_url` the OAuth1 access token url.
+	RequestTokenURL string `json:"request_token_url"`
+
+	// UserInfoURL is the url which returns the user's profile as a JSON object when `provider` is set to
+	// `generic-oauth1`. The object is made available to the Jsonnet mapper as `claims.raw_claims`.
+	UserInfoURL string `json:"userinfo_url"`
+
 	// Tenant is the Azure AD Tenant to use for authentication, and must be set when `provider` is set to `microsoft`.
 	// Can be either `common`, `organizations`, `consumers` for a multitenant application or a specific tenant like
 	// `8eaef023-2b34-4da1-9baa-8bc8c9d6a490` or `contoso.onmicrosoft.com`.
@@ -137,6 +149,16 @@ type Configuration struct {
 	// NetIDTokenOriginHeader contains the orgin header to be used when exchanging a
 	// NetID FedCM token for an ID token.
 	NetIDTokenOriginHeader string `json:"net_id_token_origin_header"`
+
+	// AllowedEmailDomains is an optional list of email domains. If set, only identities whose
+	// `email` claim belongs to one of these domains (or their subdomains) may sign in or sign up
+	// with this provider.
+	AllowedEmailDomains []string `json:"allowed_email_domains"`
+
+	// DeniedEmailDomains is an optional list of email domains. Identities whose `email` claim
+	// belongs to one of these domains (or their subdomains) may not sign in or sign up with this
+	// provider.
+	DeniedEmailDomains []string `json:"denied_email_domains"`
 }
 
 func (p Configuration) Redir(public *url.URL) string {
@@ -154,6 +176,30 @@ func (p Configuration) Redir(public *url.URL) string {
 	return urlx.AppendPaths(public, strings.Replace(RouteCallback, ":provider", p.ID, 1)).String()
 }
 
+// ValidateEmailDomain returns a validation error if the domain of the given email claim is not
+// allowed by AllowedEmailDomains or is denied by DeniedEmailDomains.
+func (p Configuration) ValidateEmailDomain(email string) error {
+	if len(p.AllowedEmailDomains) == 0 && len(p.DeniedEmailDomains) == 0 {
+		return nil
+	}
+
+	_, domain, _ := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	matches := func(domains []string) bool {
+		for _, d := range domains {
+			d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if domain == "" || (len(p.AllowedEmailDomains) > 0 && !matches(p.AllowedEmailDomains)) || matches(p.DeniedEmailDomains) {
+		return schema.NewOIDCEmailDomainNotAllowedError(stringsx.Coalesce(p.Label, p.ID), domain)
+	}
+	return nil
+}
+
 type ConfigurationCollection struct {
 	BaseRedirectURI string          `json:"base_redirect_uri"`
 	Providers       []Configuration `json:"providers"`
@@ -164,30 +210,31 @@ type ConfigurationCollection struct {
 // If you add a provider here, please also add a test to
 // provider_private_net_test.go
 var supportedProviders = map[string]func(config *Configuration, reg Dependencies) Provider{
-	"generic":     NewProviderGenericOIDC,
-	"google":      NewProviderGoogle,
-	"github":      NewProviderGitHub,
-	"github-app":  NewProviderGitHubApp,
-	"gitlab":      NewProviderGitLab,
-	"microsoft":   NewProviderMicrosoft,
-	"discord":     NewProviderDiscord,
-	"salesforce":  NewProviderSalesforce,
-	"slack":       NewProviderSlack,
-	"facebook":    NewProviderFacebook,
-	"auth0":       NewProviderAuth0,
-	"vk":          NewProviderVK,
-	"yandex":      NewProviderYandex,
-	"apple":       NewProviderApple,
-	"spotify":     NewProviderSpotify,
-	"netid":       NewProviderNetID,
-	"dingtalk":    NewProviderDingTalk,
-	"linkedin":    NewProviderLinkedIn,
-	"linkedin_v2": NewProviderLinkedInV2,
-	"patreon":     NewProviderPatreon,
-	"lark":        NewProviderLark,
-	"x":           NewProviderX,
-	"jackson":     NewProviderJackson,
-	"fedcm-test":  NewProviderTestFedcm,
+	"generic":        NewProviderGenericOIDC,
+	"generic-oauth1": NewProviderGenericOAuth1,
+	"google":         NewProviderGoogle,
+	"github":         NewProviderGitHub,
+	"github-app":     NewProviderGitHubApp,
+	"gitlab":         NewProviderGitLab,
+	"microsoft":      NewProviderMicrosoft,
+	"discord":        NewProviderDiscord,
+	"salesforce":     NewProviderSalesforce,
+	"slack":          NewProviderSlack,
+	"facebook":       NewProviderFacebook,
+	"auth0":          NewProviderAuth0,
+	"vk":             NewProviderVK,
+	"yandex":         NewProviderYandex,
+	"apple":          NewProviderApple,
+	"spotify":        NewProviderSpotify,
+	"netid":          NewProviderNetID,
+	"dingtalk":       NewProviderDingTalk,
+	"linkedin":       NewProviderLinkedIn,
+	"linkedin_v2":    NewProviderLinkedInV2,
+	"patreon":        NewProviderPatreon,
+	"lark":           NewProviderLark,
+	"x":              NewProviderX,
+	"jackson":        NewProviderJackson,
+	"fedcm-test":     NewProviderTestFedcm,
 }
 
 func (c ConfigurationCollection) Provider(id string, reg Dependencies) (Provider, error) {
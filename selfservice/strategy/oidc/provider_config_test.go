@@ -7,6 +7,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -15,7 +18,9 @@ import (
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/strategy/oidc"
+	"github.com/ory/kratos/text"
 )
 
 func TestConfig(t *testing.T) {
@@ -34,3 +39,60 @@ func TestConfig(t *testing.T) {
 	require.Len(t, collection.Providers, 1)
 	assert.Equal(t, "generic", collection.Providers[0].Provider)
 }
+
+func TestProviderResolvesSecretReferences(t *testing.T) {
+	ctx := context.Background()
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"data":{"github":"resolved-client-secret"},"metadata":{}}}`))
+	}))
+	t.Cleanup(vault.Close)
+	t.Setenv("VAULT_ADDR", vault.URL)
+
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypeOIDC)+".config", map[string]interface{}{
+		"providers": []map[string]interface{}{
+			{"id": "github", "provider": "github", "client_id": "client", "client_secret": "vault://secret/data/kratos#github", "mapper_url": "file://./stub/oidc.hydra.jsonnet"},
+		},
+	})
+
+	s := oidc.NewStrategy(reg)
+	provider, err := s.Provider(ctx, "github")
+	require.NoError(t, err)
+	assert.Equal(t, "resolved-client-secret", provider.Config().ClientSecret)
+
+	collection, err := s.Config(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "vault://secret/data/kratos#github", collection.Providers[0].ClientSecret, "the configuration must keep the reference")
+}
+
+func TestConfigurationValidateEmailDomain(t *testing.T) {
+	for k, tc := range []struct {
+		c       oidc.Configuration
+		email   string
+		allowed bool
+	}{
+		{c: oidc.Configuration{}, email: "jane@example.org", allowed: true},
+		{c: oidc.Configuration{}, email: "", allowed: true},
+		{c: oidc.Configuration{AllowedEmailDomains: []string{"corp.com"}}, email: "jane@corp.com", allowed: true},
+		{c: oidc.Configuration{AllowedEmailDomains: []string{"Corp.com"}}, email: "Jane@EU.corp.COM", allowed: true},
+		{c: oidc.Configuration{AllowedEmailDomains: []string{"corp.com"}}, email: "jane@notcorp.com"},
+		{c: oidc.Configuration{AllowedEmailDomains: []string{"corp.com"}}, email: ""},
+		{c: oidc.Configuration{DeniedEmailDomains: []string{"gmail.com"}}, email: "jane@corp.com", allowed: true},
+		{c: oidc.Configuration{DeniedEmailDomains: []string{"gmail.com"}}, email: "jane@GMail.com"},
+		{c: oidc.Configuration{AllowedEmailDomains: []string{"corp.com"}, DeniedEmailDomains: []string{"contractors.corp.com"}}, email: "jane@contractors.corp.com"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			err := tc.c.ValidateEmailDomain(tc.email)
+			if tc.allowed {
+				require.NoError(t, err)
+				return
+			}
+
+			var e *schema.ValidationError
+			require.ErrorAs(t, err, &e)
+			require.Len(t, e.Messages, 1)
+			assert.Equal(t, text.ErrorValidationOIDCEmailDomainNotAllowed, e.Messages[0].ID)
+		})
+	}
+}
@@ -13,11 +13,10 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/oauth2"
 
-	"github.com/ory/x/httpx"
-
 	"github.com/hashicorp/go-retryablehttp"
 
 	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
 )
 
 type ProviderDingTalk struct {
@@ -85,7 +84,7 @@ func (g *ProviderDingTalk) ExchangeOAuth2Token(ctx context.Context, code string,
 	}
 
 	r := strings.NewReader(string(bs))
-	client := g.reg.HTTPClient(ctx, httpx.ResilientClientDisallowInternalIPs())
+	client := g.reg.HTTPClient(config.WithHTTPClientNoPrivateIPRanges(ctx))
 	req, err := retryablehttp.NewRequest("POST", conf.Endpoint.TokenURL, r)
 	if err != nil {
 		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("%s", err))
@@ -128,7 +127,7 @@ func (g *ProviderDingTalk) Claims(ctx context.Context, exchange *oauth2.Token, _
 	userInfoURL := "https://api.dingtalk.com/v1.0/contact/users/me"
 	accessToken := exchange.AccessToken
 
-	client := g.reg.HTTPClient(ctx, httpx.ResilientClientDisallowInternalIPs())
+	client := g.reg.HTTPClient(config.WithHTTPClientNoPrivateIPRanges(ctx))
 	req, err := retryablehttp.NewRequest("GET", userInfoURL, nil)
 	if err != nil {
 		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("%s", err))
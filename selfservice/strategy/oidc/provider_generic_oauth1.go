@@ -0,0 +1,160 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/oauth1"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/otelx"
+)
+
+var _ OAuth1Provider = (*ProviderGenericOAuth1)(nil)
+
+// ProviderGenericOAuth1 implements social sign in for legacy OAuth 1.0a providers. The profile
+// returned by the user info URL is passed to the Jsonnet mapper as raw claims.
+type ProviderGenericOAuth1 struct {
+	config *Configuration
+	reg    Dependencies
+}
+
+func NewProviderGenericOAuth1(
+	config *Configuration,
+	reg Dependencies,
+) Provider {
+	return &ProviderGenericOAuth1{
+		config: config,
+		reg:    reg,
+	}
+}
+
+func (p *ProviderGenericOAuth1) Config() *Configuration {
+	return p.config
+}
+
+func (p *ProviderGenericOAuth1) OAuth1(ctx context.Context) *oauth1.Config {
+	return &oauth1.Config{
+		ConsumerKey:    p.config.ClientID,
+		ConsumerSecret: p.config.ClientSecret,
+		Endpoint: oauth1.Endpoint{
+			RequestTokenURL: p.config.RequestTokenURL,
+			AuthorizeURL:    p.config.AuthURL,
+			AccessTokenURL:  p.config.TokenURL,
+		},
+		CallbackURL: p.config.Redir(p.reg.Config().OIDCRedirectURIBase(ctx)),
+		HTTPClient:  p.reg.HTTPClient(ctx).HTTPClient,
+	}
+}
+
+func (p *ProviderGenericOAuth1) AuthURL(ctx context.Context, state string) (_ string, _ string, err error) {
+	ctx, span := p.reg.Tracer(ctx).Tracer().Start(ctx, "selfservice.strategy.oidc.ProviderGenericOAuth1.AuthURL")
+	defer otelx.End(span, &err)
+
+	c := p.OAuth1(ctx)
+
+	// We need to cheat so that callback validates on return
+	callback, err := url.Parse(c.CallbackURL)
+	if err != nil {
+		return "", "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to parse the OAuth1 callback URL: %s", err))
+	}
+	q := callback.Query()
+	q.Set("state", state)
+	q.Set("code", "unused")
+	callback.RawQuery = q.Encode()
+	c.CallbackURL = callback.String()
+
+	requestToken, requestSecret, err := c.RequestToken()
+	if err != nil {
+		return "", "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to sign in with %s because the OAuth1 request token could not be initialized: %s", p.config.ID, err))
+	}
+
+	authzURL, err := c.AuthorizationURL(requestToken)
+	if err != nil {
+		return "", "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to sign in with %s because the OAuth1 authorization URL could not be parsed: %s", p.config.ID, err))
+	}
+
+	// The request token secret is required to sign the access token request.
+	encryptedSecret, err := p.reg.Cipher(ctx).Encrypt(ctx, []byte(requestSecret))
+	if err != nil {
+		return "", "", err
+	}
+
+	return authzURL.String(), encryptedSecret, nil
+}
+
+func (p *ProviderGenericOAuth1) ExchangeToken(ctx context.Context, req *http.Request, requestSecret string) (*oauth1.Token, error) {
+	requestToken, verifier, err := oauth1.ParseAuthorizationCallback(req)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReasonf("Unable to parse the OAuth1 authorization callback: %s", err))
+	}
+
+	secret, err := p.reg.Cipher(ctx).Decrypt(ctx, requestSecret)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReason("Unable to decrypt the OAuth1 request token secret."))
+	}
+
+	accessToken, accessSecret, err := p.OAuth1(ctx).AccessToken(requestToken, string(secret), verifier)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to exchange the OAuth1 request token: %s", err))
+	}
+
+	return oauth1.NewToken(accessToken, accessSecret), nil
+}
+
+func (p *ProviderGenericOAuth1) CheckError(ctx context.Context, r *http.Request) error {
+	if r.URL.Query().Get("denied") == "" {
+		return nil
+	}
+
+	return errors.WithStack(herodot.ErrBadRequest.WithReasonf(`Unable to sign in with %s because the user denied the request.`, p.config.ID))
+}
+
+func (p *ProviderGenericOAuth1) Claims(ctx context.Context, token *oauth1.Token) (*Claims, error) {
+	ctx = context.WithValue(ctx, oauth1.HTTPClient, p.reg.HTTPClient(ctx).HTTPClient)
+	client := p.OAuth1(ctx).Client(ctx, token)
+
+	resp, err := client.Get(p.config.UserInfoURL)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("%s", err))
+	}
+	defer resp.Body.Close()
+
+	if err := logUpstreamError(p.reg.Logger(), resp); err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("%s", err))
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode the user info response: %s", err))
+	}
+	if err := json.Unmarshal(body, &claims.RawClaims); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode the user info response: %s", err))
+	}
+
+	// Most OAuth1 providers do not return a `sub` claim, so we fall back to the user's ID.
+	if claims.Subject == "" {
+		for _, key := range []string{"id_str", "id", "user_id"} {
+			if id := gjson.GetBytes(body, key); id.Exists() && id.String() != "" {
+				claims.Subject = id.String()
+				break
+			}
+		}
+	}
+	claims.Issuer = p.config.UserInfoURL
+
+	return &claims, nil
+}
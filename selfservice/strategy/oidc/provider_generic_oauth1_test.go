@@ -0,0 +1,103 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dghubble/oauth1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/selfservice/strategy/oidc"
+)
+
+func TestProviderGenericOAuth1(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	conf.MustSet(ctx, config.ViperKeyClientHTTPNoPrivateIPRanges, false)
+
+	var callback string
+	router := http.NewServeMux()
+	router.HandleFunc("/request_token", func(w http.ResponseWriter, r *http.Request) {
+		callback = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		_, _ = w.Write([]byte("oauth_token=request-token&oauth_token_secret=request-secret&oauth_callback_confirmed=true"))
+	})
+	router.HandleFunc("/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if !assert.Contains(t, r.Header.Get("Authorization"), `oauth_token="request-token"`) ||
+			!assert.Contains(t, r.Header.Get("Authorization"), `oauth_verifier="verifier"`) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+		_, _ = w.Write([]byte("oauth_token=access-token&oauth_token_secret=access-secret"))
+	})
+	router.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if !assert.Contains(t, r.Header.Get("Authorization"), `oauth_token="access-token"`) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":1234,"screen_name":"jane","email":"jane@example.org"}`))
+	})
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+
+	c := &oidc.Configuration{
+		ID:              "evernote",
+		Provider:        "generic-oauth1",
+		ClientID:        "consumer-key",
+		ClientSecret:    "consumer-secret",
+		RequestTokenURL: ts.URL + "/request_token",
+		AuthURL:         ts.URL + "/authorize",
+		TokenURL:        ts.URL + "/access_token",
+		UserInfoURL:     ts.URL + "/userinfo",
+	}
+	p := oidc.NewProviderGenericOAuth1(c, reg).(oidc.OAuth1Provider)
+
+	authURL, requestSecret, err := p.AuthURL(ctx, "the-state")
+	require.NoError(t, err)
+	assert.Equal(t, ts.URL+"/authorize?oauth_token=request-token", authURL)
+	assert.Contains(t, callback, url.QueryEscape("state=the-state"), "the callback must carry the state")
+	assert.NotEqual(t, "request-secret", requestSecret, "the request token secret must be encrypted")
+
+	r := httptest.NewRequest("GET", "/callback?state=the-state&code=unused&oauth_token=request-token&oauth_verifier=verifier", nil)
+	token, err := p.ExchangeToken(ctx, r, requestSecret)
+	require.NoError(t, err)
+	assert.Equal(t, oauth1.NewToken("access-token", "access-secret"), token)
+
+	claims, err := p.Claims(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "1234", claims.Subject)
+	assert.Equal(t, ts.URL+"/userinfo", claims.Issuer)
+	assert.Equal(t, "jane@example.org", claims.Email)
+	assert.Equal(t, "jane", claims.RawClaims["screen_name"])
+
+	t.Run("case=fails on an invalid request token secret", func(t *testing.T) {
+		_, err := p.ExchangeToken(ctx, r, "not-encrypted")
+		require.Error(t, err)
+	})
+
+	t.Run("case=does not call private networks", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyClientHTTPNoPrivateIPRanges, true)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyClientHTTPNoPrivateIPRanges, false)
+		})
+
+		_, _, err := p.AuthURL(ctx, "the-state")
+		require.Error(t, err)
+		assert.Contains(t, fmt.Sprintf("%+v", err), "is not a permitted destination")
+
+		_, err = p.Claims(ctx, token)
+		require.Error(t, err)
+		assert.Contains(t, fmt.Sprintf("%+v", err), "is not a permitted destination")
+	})
+}
@@ -75,11 +75,14 @@ var _ IDTokenVerifier = new(ProviderGoogle)
 
 const issuerUrlGoogle = "https://accounts.google.com"
 
+// Google's native SDKs may issue ID tokens without the scheme in the issuer.
+const issuerUrlGoogleLegacy = "accounts.google.com"
+
 func (p *ProviderGoogle) Verify(ctx context.Context, rawIDToken string) (*Claims, error) {
 	keySet := gooidc.NewRemoteKeySet(ctx, p.JWKSUrl)
 	ctx = gooidc.ClientContext(ctx, p.reg.HTTPClient(ctx).HTTPClient)
 
-	return verifyToken(ctx, keySet, p.config, rawIDToken, issuerUrlGoogle)
+	return verifyToken(ctx, keySet, p.config, rawIDToken, issuerUrlGoogle, issuerUrlGoogleLegacy)
 }
 
 var _ NonceValidationSkipper = new(ProviderGoogle)
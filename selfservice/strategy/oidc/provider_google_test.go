@@ -133,4 +133,26 @@ func TestGoogleVerify(t *testing.T) {
 		_, err := google.Verify(context.Background(), token)
 		require.NoError(t, err)
 	})
+
+	t.Run("case=succeeds with the issuer used by native SDKs", func(t *testing.T) {
+		p := createProvider(ts.URL)
+		claims := makeClaims("com.example.app")
+		claims.Issuer = "accounts.google.com"
+		token := createIdToken(t, claims)
+
+		c, err := p.Verify(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "accounts.google.com", c.Issuer)
+	})
+
+	t.Run("case=fails due to issuer mismatch", func(t *testing.T) {
+		p := createProvider(ts.URL)
+		claims := makeClaims("com.example.app")
+		claims.Issuer = "https://accounts.example.com"
+		token := createIdToken(t, claims)
+
+		_, err := p.Verify(context.Background(), token)
+		require.Error(t, err)
+		assert.Equal(t, `oidc: id token issued by a different provider, expected one of ["https://accounts.google.com" "accounts.google.com"] got "https://accounts.example.com"`, err.Error())
+	})
 }
@@ -13,7 +13,7 @@ import (
 	"golang.org/x/oauth2"
 
 	"github.com/ory/herodot"
-	"github.com/ory/x/httpx"
+	"github.com/ory/kratos/driver/config"
 )
 
 var _ OAuth2Provider = (*ProviderLark)(nil)
@@ -79,7 +79,7 @@ func (g *ProviderLark) Claims(ctx context.Context, exchange *oauth2.Token, query
 		Mobile       string `json:"mobile"`
 	}
 	var (
-		client = g.reg.HTTPClient(ctx, httpx.ResilientClientDisallowInternalIPs())
+		client = g.reg.HTTPClient(config.WithHTTPClientNoPrivateIPRanges(ctx))
 		user   larkClaim
 	)
 
@@ -41,7 +41,7 @@ func NewProviderX(
 	}
 }
 
-func (p *ProviderX) ExchangeToken(ctx context.Context, req *http.Request) (*oauth1.Token, error) {
+func (p *ProviderX) ExchangeToken(ctx context.Context, req *http.Request, _ string) (*oauth1.Token, error) {
 	requestToken, verifier, err := oauth1.ParseAuthorizationCallback(req)
 	if err != nil {
 		return nil, err
@@ -55,7 +55,7 @@ func (p *ProviderX) ExchangeToken(ctx context.Context, req *http.Request) (*oaut
 	return oauth1.NewToken(accessToken, accessSecret), nil
 }
 
-func (p *ProviderX) AuthURL(ctx context.Context, state string) (_ string, err error) {
+func (p *ProviderX) AuthURL(ctx context.Context, state string) (_ string, _ string, err error) {
 	ctx, span := p.reg.Tracer(ctx).Tracer().Start(ctx, "selfservice.strategy.oidc.ProviderLinkedIn.fetch")
 	defer otelx.End(span, &err)
 
@@ -67,16 +67,17 @@ func (p *ProviderX) AuthURL(ctx context.Context, state string) (_ string, err er
 	requestToken, _, err := c.RequestToken()
 	if err != nil {
 		span.RecordError(err)
-		return "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf(`Unable to sign in with X because the OAuth1 request token could not be initialized: %s`, err))
+		return "", "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf(`Unable to sign in with X because the OAuth1 request token could not be initialized: %s`, err))
 	}
 
 	authzURL, err := c.AuthorizationURL(requestToken)
 	if err != nil {
 		span.RecordError(err)
-		return "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf(`Unable to sign in with X because the OAuth1 authorization URL could not be parsed: %s`, err))
+		return "", "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf(`Unable to sign in with X because the OAuth1 authorization URL could not be parsed: %s`, err))
 	}
 
-	return authzURL.String(), nil
+	// X does not verify the request token secret, so we do not need to keep it.
+	return authzURL.String(), "", nil
 }
 
 func (p *ProviderX) CheckError(ctx context.Context, r *http.Request) error {
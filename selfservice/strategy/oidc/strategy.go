@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
@@ -37,15 +38,20 @@ import (
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/flow/settings"
 	"github.com/ory/kratos/selfservice/sessiontokenexchange"
+	"github.com/ory/kratos/selfservice/sso"
 	"github.com/ory/kratos/selfservice/strategy"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/ui/container"
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
+	"github.com/ory/kratos/x/secretref"
 	"github.com/ory/x/decoderx"
+	"github.com/ory/x/httpx"
 	"github.com/ory/x/jsonnetsecure"
 	"github.com/ory/x/otelx"
+	"github.com/ory/x/pointerx"
+	"github.com/ory/x/sqlcon"
 	"github.com/ory/x/sqlxx"
 	"github.com/ory/x/stringsx"
 	"github.com/ory/x/urlx"
@@ -73,8 +79,11 @@ type Dependencies interface {
 	x.CSRFTokenGeneratorProvider
 	x.WriterProvider
 	x.HTTPClientProvider
+	x.JWKSFetchProvider
 	x.TracingProvider
 
+	secretref.Provider
+
 	identity.ValidationProvider
 	identity.PrivilegedPoolProvider
 	identity.ActiveCredentialsCounterStrategyProvider
@@ -106,6 +115,8 @@ type Dependencies interface {
 
 	cipher.Provider
 
+	sso.PersistenceProvider
+
 	jsonnetsecure.VMProvider
 }
 
@@ -152,6 +163,9 @@ type AuthCodeContainer struct {
 	State            string          `json:"state"`
 	Traits           json.RawMessage `json:"traits"`
 	TransientPayload json.RawMessage `json:"transient_payload"`
+
+	// OAuth1RequestSecret is the encrypted OAuth1 request token secret.
+	OAuth1RequestSecret string `json:"oauth1_request_secret,omitempty"`
 }
 
 func (s *Strategy) CountActiveFirstFactorCredentials(_ context.Context, cc map[identity.CredentialsType]identity.Credentials) (count int, err error) {
@@ -265,7 +279,7 @@ func (s *Strategy) SetOnConflictingIdentity(t testing.TB, handler ConflictingIde
 
 func NewStrategy(d any, opts ...NewStrategyOpt) *Strategy {
 	s := &Strategy{
-		d:                           d.(Dependencies),
+		d:                           httpClientDependencies{Dependencies: d.(Dependencies)},
 		validator:                   schema.NewValidator(),
 		credType:                    identity.CredentialsTypeOIDC,
 		handleUnknownProviderError:  func(err error) error { return err },
@@ -279,6 +293,16 @@ func NewStrategy(d any, opts ...NewStrategyOpt) *Strategy {
 	return s
 }
 
+// httpClientDependencies marks the HTTP clients of the strategy and its providers as being used
+// by the OIDC integration, so that its egress settings apply.
+type httpClientDependencies struct {
+	Dependencies
+}
+
+func (d httpClientDependencies) HTTPClient(ctx context.Context, opts ...httpx.ResilientOptions) *retryablehttp.Client {
+	return d.Dependencies.HTTPClient(config.WithHTTPClientIntegration(ctx, config.HTTPClientIntegrationOIDC), opts...)
+}
+
 func (s *Strategy) ID() identity.CredentialsType {
 	return s.credType
 }
@@ -407,7 +431,7 @@ func (s *Strategy) alreadyAuthenticated(ctx context.Context, w http.ResponseWrit
 		} else if !isForced(f) {
 			returnTo := s.d.Config().SelfServiceBrowserDefaultReturnTo(ctx)
 			if redirecter, ok := f.(flow.FlowWithRedirect); ok {
-				r, err := x.SecureRedirectTo(r, returnTo, redirecter.SecureRedirectToOpts(ctx, s.d)...)
+				r, err := x.SecureRedirectTo(r, returnTo, append(redirecter.SecureRedirectToOpts(ctx, s.d), x.SecureRedirectAllowReturnToGrants(s.d))...)
 				if err == nil {
 					returnTo = r
 				}
@@ -485,7 +509,7 @@ func (s *Strategy) HandleCallback(w http.ResponseWriter, r *http.Request, ps htt
 			return
 		}
 	case OAuth1Provider:
-		token, err := p.ExchangeToken(ctx, r)
+		token, err := p.ExchangeToken(ctx, r, cntnr.OAuth1RequestSecret)
 		if err != nil {
 			s.forwardError(ctx, w, r, req, s.HandleError(ctx, w, r, req, state.ProviderId, nil, err))
 			return
@@ -503,6 +527,11 @@ func (s *Strategy) HandleCallback(w http.ResponseWriter, r *http.Request, ps htt
 		return
 	}
 
+	if err = provider.Config().ValidateEmailDomain(claims.Email); err != nil {
+		s.forwardError(ctx, w, r, req, s.HandleError(ctx, w, r, req, state.ProviderId, nil, err))
+		return
+	}
+
 	span.SetAttributes(attribute.StringSlice("claims", slices.Collect(maps.Keys(claims.RawClaims))))
 
 	switch a := req.(type) {
@@ -598,13 +627,73 @@ func (s *Strategy) Config(ctx context.Context) (*ConfigurationCollection, error)
 }
 
 func (s *Strategy) Provider(ctx context.Context, id string) (Provider, error) {
-	if c, err := s.Config(ctx); err != nil {
+	c, err := s.Config(ctx)
+	if err != nil {
 		return nil, err
-	} else if provider, err := c.Provider(id, s.d); err != nil {
+	}
+
+	for k := range c.Providers {
+		if c.Providers[k].ID != id {
+			continue
+		}
+		if c.Providers[k].ClientSecret, err = s.d.SecretResolver().Resolve(ctx, c.Providers[k].ClientSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf, err := s.ssoConnectionConfiguration(ctx, c, id); err != nil {
+		return nil, err
+	} else if conf != nil {
+		c.Providers = append(c.Providers, *conf)
+	}
+
+	provider, err := c.Provider(id, s.d)
+	if err != nil {
 		return nil, s.handleUnknownProviderError(err)
-	} else {
-		return provider, nil
 	}
+	return provider, nil
+}
+
+// ssoConnectionConfiguration returns the provider configuration of the OpenID Connect SSO
+// connection with the given ID. It returns nil if the ID belongs to a configured provider or
+// no such connection exists.
+func (s *Strategy) ssoConnectionConfiguration(ctx context.Context, c *ConfigurationCollection, id string) (*Configuration, error) {
+	for k := range c.Providers {
+		if c.Providers[k].ID == id {
+			return nil, nil
+		}
+	}
+
+	connectionID, err := uuid.FromString(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	conn, err := s.d.SSOConnectionPersister().FindSSOConnection(ctx, connectionID)
+	if errors.Is(err, sqlcon.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if conn.Protocol != sso.ProtocolOIDC {
+		return nil, nil
+	}
+
+	secret, err := s.d.Cipher(ctx).Decrypt(ctx, conn.EncryptedClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Configuration{
+		ID:             conn.ID.String(),
+		Provider:       conn.Provider,
+		Label:          conn.Label,
+		ClientID:       conn.ClientID,
+		ClientSecret:   string(secret),
+		IssuerURL:      conn.IssuerURL,
+		Scope:          conn.Scope,
+		Mapper:         conn.Mapper(),
+		OrganizationID: conn.OrganizationID.String(),
+	}, nil
 }
 
 func (s *Strategy) forwardError(ctx context.Context, w http.ResponseWriter, r *http.Request, f flow.Flow, err error) {
@@ -653,7 +742,7 @@ func (s *Strategy) HandleError(ctx context.Context, w http.ResponseWriter, r *ht
 			if lf.Type == flow.TypeAPI {
 				returnTo := s.d.Config().SelfServiceBrowserDefaultReturnTo(ctx)
 				if redirecter, ok := f.(flow.FlowWithRedirect); ok {
-					secureReturnTo, err := x.SecureRedirectTo(r, returnTo, redirecter.SecureRedirectToOpts(ctx, s.d)...)
+					secureReturnTo, err := x.SecureRedirectTo(r, returnTo, append(redirecter.SecureRedirectToOpts(ctx, s.d), x.SecureRedirectAllowReturnToGrants(s.d))...)
 					if err == nil {
 						returnTo = secureReturnTo
 					}
@@ -681,7 +770,7 @@ func (s *Strategy) HandleError(ctx context.Context, w http.ResponseWriter, r *ht
 		AddProvider(rf.UI, usedProviderID, text.NewInfoRegistrationContinue())
 
 		if traits != nil {
-			ds, err := s.d.Config().DefaultIdentityTraitsSchemaURL(ctx)
+			ds, err := rf.IdentitySchemaURL(ctx, s.d.Config())
 			if err != nil {
 				return err
 			}
@@ -791,11 +880,15 @@ func (s *Strategy) ProcessIDToken(r *http.Request, provider Provider, idToken, i
 	}
 	claims, err := verifier.Verify(r.Context(), idToken)
 	if err != nil {
-		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Could not verify id_token").WithError(err.Error()))
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Could not verify id_token").WithError(err.Error()))
 	}
 
 	if err := claims.Validate(); err != nil {
-		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The id_token claims were invalid").WithError(err.Error()))
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The id_token claims were invalid").WithError(err.Error()))
+	}
+
+	if err := provider.Config().ValidateEmailDomain(claims.Email); err != nil {
+		return nil, err
 	}
 
 	// First check if the JWT contains the nonce claim.
@@ -803,17 +896,17 @@ func (s *Strategy) ProcessIDToken(r *http.Request, provider Provider, idToken, i
 		// If it doesn't, check if the provider supports nonces.
 		if nonceSkipper, ok := verifier.(NonceValidationSkipper); !ok || !nonceSkipper.CanSkipNonce(claims) {
 			// If the provider supports nonces, abort the flow!
-			return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("No nonce was included in the id_token but is required by the provider"))
+			return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("No nonce was included in the id_token but is required by the provider"))
 		}
 		// If the provider does not support nonces, we don't do validation and return the claim.
 		// This case only applies to Apple, as some of their devices do not support nonces.
 		// https://developer.apple.com/documentation/sign_in_with_apple/sign_in_with_apple_rest_api/authenticating_users_with_sign_in_with_apple
 	} else if idTokenNonce == "" {
 		// A nonce was present in the JWT token, but no nonce was submitted in the flow
-		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("No nonce was provided but is required by the provider"))
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("No nonce was provided but is required by the provider"))
 	} else if idTokenNonce != claims.Nonce {
 		// The nonce from the JWT token does not match the nonce from the flow.
-		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The supplied nonce does not match the nonce from the id_token"))
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The supplied nonce does not match the nonce from the id_token"))
 	}
 	// Nonce checking was successful
 
@@ -845,12 +938,13 @@ func (s *Strategy) linkCredentials(ctx context.Context, i *identity.Identity, to
 	} else {
 		creds.Identifiers = append(creds.Identifiers, identity.OIDCUniqueID(provider, subject))
 		conf.Providers = append(conf.Providers, identity.CredentialsOIDCProvider{
-			Subject:             subject,
-			Provider:            provider,
-			InitialAccessToken:  tokens.GetAccessToken(),
-			InitialRefreshToken: tokens.GetRefreshToken(),
-			InitialIDToken:      tokens.GetIDToken(),
-			Organization:        organization,
+			Subject:              subject,
+			Provider:             provider,
+			InitialAccessToken:   tokens.GetAccessToken(),
+			InitialRefreshToken:  tokens.GetRefreshToken(),
+			InitialIDToken:       tokens.GetIDToken(),
+			Organization:         organization,
+			AccessTokenExpiresAt: tokens.GetExpiresAt(),
 		})
 
 		creds.Config, err = json.Marshal(conf)
@@ -867,21 +961,21 @@ func (s *Strategy) linkCredentials(ctx context.Context, i *identity.Identity, to
 	return nil
 }
 
-func getAuthRedirectURL(ctx context.Context, provider Provider, req ider, state string, upstreamParameters map[string]string, opts []oauth2.AuthCodeOption) (codeURL string, err error) {
+func getAuthRedirectURL(ctx context.Context, provider Provider, req ider, state string, upstreamParameters map[string]string, opts []oauth2.AuthCodeOption) (codeURL string, oauth1RequestSecret string, err error) {
 	switch p := provider.(type) {
 	case OAuth2Provider:
 		c, err := p.OAuth2(ctx)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 		opts = append(opts, UpstreamParameters(upstreamParameters)...)
 		opts = append(opts, p.AuthCodeURLOptions(req)...)
 
-		return c.AuthCodeURL(state, opts...), nil
+		return c.AuthCodeURL(state, opts...), "", nil
 	case OAuth1Provider:
 		return p.AuthURL(ctx, state)
 	default:
-		return "", errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The provider %s does not support the OAuth 2.0 or OAuth 1.0 protocol", provider.Config().Provider))
+		return "", "", errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The provider %s does not support the OAuth 2.0 or OAuth 1.0 protocol", provider.Config().Provider))
 	}
 }
 
@@ -908,5 +1002,9 @@ func (s *Strategy) encryptOAuth2Tokens(ctx context.Context, token *oauth2.Token)
 		return nil, err
 	}
 
+	if !token.Expiry.IsZero() {
+		et.ExpiresAt = pointerx.Ptr(token.Expiry.UTC())
+	}
+
 	return et, nil
 }
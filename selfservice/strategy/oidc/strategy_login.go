@@ -97,6 +97,12 @@ type UpdateLoginFlowWithOidcMethod struct {
 	//
 	// required: false
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" form:"transient_payload"`
+
+	// Remember the session for its full lifespan. Only used if remember me is enabled. API flows
+	// which omit this field use the default of the login method.
+	//
+	// required: false
+	Remember *bool `json:"remember,omitempty" form:"remember"`
 }
 
 func (s *Strategy) handleConflictingIdentity(ctx context.Context, w http.ResponseWriter, r *http.Request, loginFlow *login.Flow, token *identity.CredentialsOIDCEncryptedTokens, claims *Claims, provider Provider, container *AuthCodeContainer) (verdict ConflictingIdentityVerdict, id *identity.Identity, credentials *identity.Credentials, err error) {
@@ -257,8 +263,13 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 		return nil, err
 	}
 
+	ds, err := s.d.Config().DefaultIdentityTraitsSchemaURL(ctx)
+	if err != nil {
+		return nil, s.HandleError(ctx, w, r, f, "", nil, err)
+	}
+
 	var p UpdateLoginFlowWithOidcMethod
-	if err := s.newLinkDecoder(ctx, &p, r); err != nil {
+	if err := s.newLinkDecoder(&p, r, ds); err != nil {
 		return nil, s.HandleError(ctx, w, r, f, "", nil, err)
 	}
 
@@ -322,12 +333,29 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 	if err != nil {
 		return nil, s.HandleError(ctx, w, r, f, pid, nil, err)
 	}
+	var up map[string]string
+	if err := json.NewDecoder(bytes.NewBuffer(p.UpstreamParameters)).Decode(&up); err != nil {
+		return nil, err
+	}
+	if _, ok := up["login_hint"]; !ok && f.LoginHint != "" {
+		if up == nil {
+			up = make(map[string]string, 1)
+		}
+		up["login_hint"] = f.LoginHint
+	}
+
+	codeURL, requestSecret, err := getAuthRedirectURL(ctx, provider, f, state, up, pkce)
+	if err != nil {
+		return nil, s.HandleError(ctx, w, r, f, pid, nil, err)
+	}
+
 	if err := s.d.ContinuityManager().Pause(ctx, w, r, sessionName,
 		continuity.WithPayload(&AuthCodeContainer{
-			State:            state,
-			FlowID:           f.ID.String(),
-			Traits:           p.Traits,
-			TransientPayload: f.TransientPayload,
+			State:               state,
+			FlowID:              f.ID.String(),
+			Traits:              p.Traits,
+			TransientPayload:    f.TransientPayload,
+			OAuth1RequestSecret: requestSecret,
 		}),
 		continuity.WithLifespan(time.Minute*30)); err != nil {
 		return nil, s.HandleError(ctx, w, r, f, pid, nil, err)
@@ -338,16 +366,6 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 		return nil, s.HandleError(ctx, w, r, f, pid, nil, errors.WithStack(herodot.ErrInternalServerError.WithReason("Could not update flow").WithDebug(err.Error())))
 	}
 
-	var up map[string]string
-	if err := json.NewDecoder(bytes.NewBuffer(p.UpstreamParameters)).Decode(&up); err != nil {
-		return nil, err
-	}
-
-	codeURL, err := getAuthRedirectURL(ctx, provider, f, state, up, pkce)
-	if err != nil {
-		return nil, s.HandleError(ctx, w, r, f, pid, nil, err)
-	}
-
 	if x.IsJSONRequest(r) {
 		s.d.Writer().WriteError(w, r, flow.NewBrowserLocationChangeRequiredError(codeURL))
 	} else {
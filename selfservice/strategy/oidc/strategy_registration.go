@@ -8,6 +8,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -121,12 +122,7 @@ type UpdateRegistrationFlowWithOidcMethod struct {
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" form:"transient_payload"`
 }
 
-func (s *Strategy) newLinkDecoder(ctx context.Context, p interface{}, r *http.Request) error {
-	ds, err := s.d.Config().DefaultIdentityTraitsSchemaURL(ctx)
-	if err != nil {
-		return err
-	}
-
+func (s *Strategy) newLinkDecoder(p interface{}, r *http.Request, ds *url.URL) error {
 	raw, err := sjson.SetBytes(linkSchema, "properties.traits.$ref", ds.String()+"#/properties/traits")
 	if err != nil {
 		return errors.WithStack(err)
@@ -154,8 +150,13 @@ func (s *Strategy) Register(w http.ResponseWriter, r *http.Request, f *registrat
 	ctx, span := s.d.Tracer(r.Context()).Tracer().Start(r.Context(), "selfservice.strategy.oidc.Strategy.Register")
 	defer otelx.End(span, &err)
 
+	ds, err := f.IdentitySchemaURL(ctx, s.d.Config())
+	if err != nil {
+		return s.HandleError(ctx, w, r, f, "", nil, err)
+	}
+
 	var p UpdateRegistrationFlowWithOidcMethod
-	if err := s.newLinkDecoder(ctx, &p, r); err != nil {
+	if err := s.newLinkDecoder(&p, r, ds); err != nil {
 		return s.HandleError(ctx, w, r, f, "", nil, err)
 	}
 
@@ -220,26 +221,27 @@ func (s *Strategy) Register(w http.ResponseWriter, r *http.Request, f *registrat
 	if err != nil {
 		return s.HandleError(ctx, w, r, f, pid, nil, err)
 	}
-	if err := s.d.ContinuityManager().Pause(ctx, w, r, sessionName,
-		continuity.WithPayload(&AuthCodeContainer{
-			State:            state,
-			FlowID:           f.ID.String(),
-			Traits:           p.Traits,
-			TransientPayload: f.TransientPayload,
-		}),
-		continuity.WithLifespan(time.Minute*30)); err != nil {
-		return s.HandleError(ctx, w, r, f, pid, nil, err)
-	}
-
 	var up map[string]string
 	if err := json.NewDecoder(bytes.NewBuffer(p.UpstreamParameters)).Decode(&up); err != nil {
 		return err
 	}
 
-	codeURL, err := getAuthRedirectURL(ctx, provider, f, state, up, pkce)
+	codeURL, requestSecret, err := getAuthRedirectURL(ctx, provider, f, state, up, pkce)
 	if err != nil {
 		return s.HandleError(ctx, w, r, f, pid, nil, err)
 	}
+
+	if err := s.d.ContinuityManager().Pause(ctx, w, r, sessionName,
+		continuity.WithPayload(&AuthCodeContainer{
+			State:               state,
+			FlowID:              f.ID.String(),
+			Traits:              p.Traits,
+			TransientPayload:    f.TransientPayload,
+			OAuth1RequestSecret: requestSecret,
+		}),
+		continuity.WithLifespan(time.Minute*30)); err != nil {
+		return s.HandleError(ctx, w, r, f, pid, nil, err)
+	}
 	if x.IsJSONRequest(r) {
 		s.d.Writer().WriteError(w, r, flow.NewBrowserLocationChangeRequiredError(codeURL))
 	} else {
@@ -319,6 +321,7 @@ func (s *Strategy) processRegistration(ctx context.Context, w http.ResponseWrite
 	if err != nil {
 		return nil, s.HandleError(ctx, w, r, rf, provider.Config().ID, nil, err)
 	}
+	i.SchemaID = rf.IdentitySchemaID(ctx, s.d.Config())
 
 	// Validate the identity itself
 	if err := s.d.IdentityValidator().Validate(ctx, i); err != nil {
@@ -26,6 +26,7 @@ import (
 
 	"github.com/ory/kratos/continuity"
 	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/flow"
 	"github.com/ory/kratos/selfservice/flow/settings"
 	"github.com/ory/kratos/selfservice/strategy"
@@ -331,6 +332,10 @@ func (s *Strategy) isLinkable(ctx context.Context, ctxUpdate *settings.UpdateCon
 		return nil, err
 	}
 
+	if s.d.Config().SelfServiceFlowVerificationRequiredForOIDCLinking(ctx) && !i.HasVerifiedAddress() {
+		return nil, errors.WithStack(schema.NewSettingsLinkAddressNotVerifiedError())
+	}
+
 	linkable, err := s.linkableProviders(providers, i)
 	if err != nil {
 		return nil, err
@@ -355,8 +360,8 @@ func (s *Strategy) initLinkProvider(ctx context.Context, w http.ResponseWriter,
 		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, err)
 	}
 
-	if ctxUpdate.Session.AuthenticatedAt.Add(s.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)).Before(time.Now()) {
-		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, errors.WithStack(settings.NewFlowNeedsReAuth()))
+	if err := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup()); err != nil {
+		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, err)
 	}
 
 	provider, err := s.Provider(ctx, p.Link)
@@ -373,26 +378,27 @@ func (s *Strategy) initLinkProvider(ctx context.Context, w http.ResponseWriter,
 	if err != nil {
 		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, err)
 	}
-	if err := s.d.ContinuityManager().Pause(ctx, w, r, sessionName,
-		continuity.WithPayload(&AuthCodeContainer{
-			State:  state,
-			FlowID: ctxUpdate.Flow.ID.String(),
-			Traits: p.Traits,
-		}),
-		continuity.WithLifespan(time.Minute*30)); err != nil {
-		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, err)
-	}
-
 	var up map[string]string
 	if err := json.NewDecoder(bytes.NewBuffer(p.UpstreamParameters)).Decode(&up); err != nil {
 		return err
 	}
 
-	codeURL, err := getAuthRedirectURL(ctx, provider, req, state, up, pkce)
+	codeURL, requestSecret, err := getAuthRedirectURL(ctx, provider, req, state, up, pkce)
 	if err != nil {
 		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, err)
 	}
 
+	if err := s.d.ContinuityManager().Pause(ctx, w, r, sessionName,
+		continuity.WithPayload(&AuthCodeContainer{
+			State:               state,
+			FlowID:              ctxUpdate.Flow.ID.String(),
+			Traits:              p.Traits,
+			OAuth1RequestSecret: requestSecret,
+		}),
+		continuity.WithLifespan(time.Minute*30)); err != nil {
+		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, err)
+	}
+
 	if x.IsJSONRequest(r) {
 		s.d.Writer().WriteError(w, r, flow.NewBrowserLocationChangeRequiredError(codeURL))
 	} else {
@@ -407,8 +413,8 @@ func (s *Strategy) linkProvider(ctx context.Context, w http.ResponseWriter, r *h
 		Link: provider.Config().ID, FlowID: ctxUpdate.Flow.ID.String(),
 	}
 
-	if ctxUpdate.Session.AuthenticatedAt.Add(s.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)).Before(time.Now()) {
-		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, errors.WithStack(settings.NewFlowNeedsReAuth()))
+	if err := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup()); err != nil {
+		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, err)
 	}
 
 	i, err := s.isLinkable(ctx, ctxUpdate, p.Link)
@@ -431,8 +437,8 @@ func (s *Strategy) linkProvider(ctx context.Context, w http.ResponseWriter, r *h
 }
 
 func (s *Strategy) unlinkProvider(ctx context.Context, w http.ResponseWriter, r *http.Request, ctxUpdate *settings.UpdateContext, p *updateSettingsFlowWithOidcMethod) error {
-	if ctxUpdate.Session.AuthenticatedAt.Add(s.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)).Before(time.Now()) {
-		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, errors.WithStack(settings.NewFlowNeedsReAuth()))
+	if err := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup()); err != nil {
+		return s.handleSettingsError(ctx, w, r, ctxUpdate, p, err)
 	}
 
 	providers, err := s.Config(ctx)
@@ -1446,6 +1446,21 @@ func TestStrategy(t *testing.T) {
 			require.Equal(t, "select_account", loc.Query().Get("prompt"))
 		})
 
+		t.Run("case=should pass the login hint of the flow when logging in", func(t *testing.T) {
+			f := newBrowserLoginFlow(t, returnTS.URL, time.Minute)
+			f.LoginHint = "oidc-login-hint@ory.sh"
+			require.NoError(t, reg.LoginFlowPersister().UpdateLoginFlow(context.Background(), f))
+			action := assertFormValues(t, f.ID, "valid")
+
+			res, err := c.PostForm(action, url.Values{"provider": {"valid"}})
+			require.NoError(t, err)
+			require.Equal(t, http.StatusSeeOther, res.StatusCode)
+
+			loc, err := res.Location()
+			require.NoError(t, err)
+			require.Equal(t, "oidc-login-hint@ory.sh", loc.Query().Get("login_hint"))
+		})
+
 		t.Run("case=should ignore invalid parameters when logging in", func(t *testing.T) {
 			f := newBrowserLoginFlow(t, returnTS.URL, time.Minute)
 			action := assertFormValues(t, f.ID, "valid")
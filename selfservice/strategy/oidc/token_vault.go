@@ -0,0 +1,262 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	grpccodes "google.golang.org/grpc/codes"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+)
+
+const RouteUpstreamToken = "/identities/:id/credentials/oidc/:provider/token"
+
+// upstreamTokenExpiryLeeway is the time before its expiry after which an upstream access token is
+// refreshed, so that callers do not receive tokens which expire while they are being used.
+const upstreamTokenExpiryLeeway = time.Minute
+
+type (
+	TokenVaultHandlerProvider interface {
+		OIDCTokenVaultHandler() *TokenVaultHandler
+	}
+
+	// TokenVaultHandler allows trusted backends to fetch a currently valid access token of the
+	// upstream OAuth2 provider an identity signed in with. Expired access tokens are refreshed
+	// using the stored refresh token.
+	TokenVaultHandler struct {
+		d Dependencies
+		s *Strategy
+	}
+)
+
+func NewTokenVaultHandler(d Dependencies) *TokenVaultHandler {
+	return &TokenVaultHandler{d: d, s: NewStrategy(d)}
+}
+
+func (h *TokenVaultHandler) RegisterPublicRoutes(public *x.RouterPublic) {
+	public.GET(RouteUpstreamToken, x.RedirectToAdminRoute(h.d))
+}
+
+func (h *TokenVaultHandler) RegisterAdminRoutes(admin *x.RouterAdmin) {
+	admin.GET(RouteUpstreamToken, h.getUpstreamToken)
+}
+
+// Upstream OAuth2 Access Token
+//
+// swagger:model identityUpstreamToken
+type UpstreamToken struct {
+	// The ID of the OpenID Connect provider as configured in Ory Kratos.
+	//
+	// required: true
+	Provider string `json:"provider"`
+
+	// The subject of the identity at the provider.
+	//
+	// required: true
+	Subject string `json:"subject"`
+
+	// The access token issued by the provider.
+	//
+	// required: true
+	AccessToken string `json:"access_token"`
+
+	// The expiry of the access token, if known.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Get Upstream Token Parameters
+//
+// swagger:parameters getIdentityUpstreamToken
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type getIdentityUpstreamToken struct {
+	// ID must be set to the ID of the identity.
+	//
+	// required: true
+	// in: path
+	ID string `json:"id"`
+
+	// Provider must be set to the ID of the OpenID Connect provider.
+	//
+	// required: true
+	// in: path
+	Provider string `json:"provider"`
+}
+
+// swagger:route GET /admin/identities/{id}/credentials/oidc/{provider}/token identity getIdentityUpstreamToken
+//
+// # Get a Valid Upstream Access Token of an Identity
+//
+// Returns a currently valid access token of the OAuth2 provider the identity signed in with,
+// which allows calling the provider's APIs on behalf of the user. If the stored access token
+// expired, it is refreshed using the stored refresh token first.
+//
+// If admin API tokens are configured, the token must have the `upstream_tokens:read` scope.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: identityUpstreamToken
+//	  403: errorGeneric
+//	  404: errorGeneric
+//	  default: errorGeneric
+func (h *TokenVaultHandler) getUpstreamToken(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := r.Context()
+	if t, ok := x.AdminAPITokenFromContext(ctx); ok && !t.HasScope(config.AdminAPITokenScopeUpstreamTokensRead) {
+		h.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrForbidden.WithReasonf("The admin API token requires the %s scope to read upstream tokens.", config.AdminAPITokenScopeUpstreamTokensRead)))
+		return
+	}
+
+	token, err := h.UpstreamToken(ctx, x.ParseUUID(ps.ByName("id")), ps.ByName("provider"))
+	if err != nil {
+		h.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.d.Audit().WithRequest(r).
+		WithField("identity_id", ps.ByName("id")).
+		WithField("provider", token.Provider).
+		Info("An upstream access token was read.")
+
+	h.d.Writer().Write(w, r, token)
+}
+
+// UpstreamToken returns a currently valid access token of the identity for the given provider.
+func (h *TokenVaultHandler) UpstreamToken(ctx context.Context, identityID uuid.UUID, providerID string) (_ *UpstreamToken, err error) {
+	ctx, span := h.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.strategy.oidc.TokenVaultHandler.UpstreamToken")
+	defer otelx.End(span, &err)
+
+	i, err := h.d.PrivilegedIdentityPool().GetIdentityConfidential(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+
+	var conf identity.CredentialsOIDC
+	creds, err := i.ParseCredentials(identity.CredentialsTypeOIDC, &conf)
+	if err != nil {
+		return nil, err
+	}
+
+	k := -1
+	for j := range conf.Providers {
+		if conf.Providers[j].Provider == providerID {
+			k = j
+			break
+		}
+	}
+	if k < 0 {
+		return nil, errors.WithStack(herodot.ErrNotFound.WithReasonf("The identity has not signed in with provider %s.", providerID))
+	}
+	c := &conf.Providers[k]
+
+	accessToken, err := h.decrypt(ctx, c.InitialAccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.AccessTokenExpiresAt != nil && time.Now().Add(upstreamTokenExpiryLeeway).After(*c.AccessTokenExpiresAt) {
+		refreshed, err := h.refresh(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens, err := h.s.encryptOAuth2Tokens(ctx, refreshed)
+		if err != nil {
+			return nil, err
+		}
+		c.InitialAccessToken, c.InitialRefreshToken, c.AccessTokenExpiresAt = tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresAt
+		if tokens.IDToken != "" {
+			c.InitialIDToken = tokens.IDToken
+		}
+
+		creds.Config, err = json.Marshal(conf)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		i.SetCredentials(identity.CredentialsTypeOIDC, *creds)
+		if err := h.d.PrivilegedIdentityPool().UpdateIdentity(ctx, i); err != nil {
+			return nil, err
+		}
+
+		accessToken = refreshed.AccessToken
+	}
+
+	return &UpstreamToken{
+		Provider:    c.Provider,
+		Subject:     c.Subject,
+		AccessToken: accessToken,
+		ExpiresAt:   c.AccessTokenExpiresAt,
+	}, nil
+}
+
+func (h *TokenVaultHandler) refresh(ctx context.Context, c *identity.CredentialsOIDCProvider) (*oauth2.Token, error) {
+	refreshToken, err := h.decrypt(ctx, c.InitialRefreshToken)
+	if err != nil {
+		return nil, err
+	} else if refreshToken == "" {
+		return nil, errors.WithStack(herodot.ErrNotFound.WithReasonf("The access token of provider %s expired and no refresh token is available.", c.Provider))
+	}
+
+	provider, err := h.s.Provider(ctx, c.Provider)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := provider.(OAuth2Provider)
+	if !ok {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The provider %s does not support refreshing OAuth2 tokens.", c.Provider))
+	}
+	oc, err := p.OAuth2(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, h.d.HTTPClient(config.WithHTTPClientIntegration(ctx, config.HTTPClientIntegrationOIDC)).HTTPClient)
+	token, err := oc.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken, Expiry: time.Now().Add(-time.Minute)}).Token()
+	if err != nil {
+		return nil, errors.WithStack(herodot.DefaultError{
+			CodeField:     http.StatusBadGateway,
+			StatusField:   http.StatusText(http.StatusBadGateway),
+			GRPCCodeField: grpccodes.Aborted,
+			ErrorField:    "refreshing the upstream access token failed",
+		}.WithWrap(err).WithReasonf("Unable to refresh the access token of provider %s: %s", c.Provider, err))
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	if !token.Expiry.IsZero() {
+		token.Expiry = token.Expiry.UTC()
+	}
+
+	return token, nil
+}
+
+func (h *TokenVaultHandler) decrypt(ctx context.Context, ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	plaintext, err := h.d.Cipher(ctx).Decrypt(ctx, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,161 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package oidc_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/pointerx"
+)
+
+func TestTokenVaultHandler(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/stub.schema.json")
+	conf.MustSet(ctx, config.ViperKeyClientHTTPNoPrivateIPRanges, false)
+
+	var refreshes atomic.Int32
+	var upstream *httptest.Server
+	router := http.NewServeMux()
+	router.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 upstream.URL,
+			"authorization_endpoint": upstream.URL + "/auth",
+			"token_endpoint":         upstream.URL + "/token",
+			"jwks_uri":               upstream.URL + "/jwks",
+		})
+	})
+	router.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "refresh-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		refreshes.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"refreshed-access-token","token_type":"Bearer","expires_in":3600}`))
+	})
+	upstream = httptest.NewServer(router)
+	t.Cleanup(upstream.Close)
+
+	conf.MustSet(ctx, config.ViperKeySelfServiceStrategyConfig+"."+string(identity.CredentialsTypeOIDC)+".config", map[string]any{
+		"providers": []map[string]any{
+			{"id": "upstream", "provider": "generic", "client_id": "client", "client_secret": "secret", "issuer_url": upstream.URL, "mapper_url": "file://./stub/oidc.hydra.jsonnet"},
+		},
+	})
+
+	_, ts := testhelpers.NewKratosServerWithCSRF(t, reg)
+
+	encrypt := func(t *testing.T, plaintext string) string {
+		ciphertext, err := reg.Cipher(ctx).Encrypt(ctx, []byte(plaintext))
+		require.NoError(t, err)
+		return ciphertext
+	}
+	createIdentity := func(t *testing.T, expiresAt time.Time, refreshToken string) *identity.Identity {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{}`)
+		creds, err := identity.NewCredentialsOIDC(&identity.CredentialsOIDCEncryptedTokens{
+			AccessToken:  encrypt(t, "access-token"),
+			RefreshToken: encrypt(t, refreshToken),
+			ExpiresAt:    pointerx.Ptr(expiresAt),
+		}, "upstream", x.NewUUID().String(), "")
+		require.NoError(t, err)
+		i.SetCredentials(identity.CredentialsTypeOIDC, *creds)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		return i
+	}
+	fetch := func(t *testing.T, i *identity.Identity, provider string, expectedStatus int) []byte {
+		res, err := ts.Client().Get(ts.URL + "/admin/identities/" + i.ID.String() + "/credentials/oidc/" + provider + "/token")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body := x.MustReadAll(res.Body)
+		require.Equal(t, expectedStatus, res.StatusCode, "%s", body)
+		return body
+	}
+
+	t.Run("case=returns the stored access token while it is valid", func(t *testing.T) {
+		i := createIdentity(t, time.Now().Add(time.Hour), "refresh-token")
+
+		body := fetch(t, i, "upstream", http.StatusOK)
+		assert.Equal(t, "access-token", gjson.GetBytes(body, "access_token").String(), "%s", body)
+		assert.Equal(t, "upstream", gjson.GetBytes(body, "provider").String(), "%s", body)
+		assert.EqualValues(t, 0, refreshes.Load())
+	})
+
+	t.Run("case=refreshes an expired access token", func(t *testing.T) {
+		i := createIdentity(t, time.Now().Add(-time.Hour), "refresh-token")
+
+		body := fetch(t, i, "upstream", http.StatusOK)
+		assert.Equal(t, "refreshed-access-token", gjson.GetBytes(body, "access_token").String(), "%s", body)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), gjson.GetBytes(body, "expires_at").Time(), time.Minute)
+		assert.EqualValues(t, 1, refreshes.Load())
+
+		t.Run("case=stores the refreshed access token", func(t *testing.T) {
+			body := fetch(t, i, "upstream", http.StatusOK)
+			assert.Equal(t, "refreshed-access-token", gjson.GetBytes(body, "access_token").String(), "%s", body)
+			assert.EqualValues(t, 1, refreshes.Load())
+
+			actual, err := reg.PrivilegedIdentityPool().GetIdentityConfidential(ctx, i.ID)
+			require.NoError(t, err)
+			config := actual.Credentials[identity.CredentialsTypeOIDC].Config
+			assert.NotContains(t, string(config), "refreshed-access-token", "the token must be stored encrypted")
+			refreshToken, err := reg.Cipher(ctx).Decrypt(ctx, gjson.GetBytes(config, "providers.0.initial_refresh_token").String())
+			require.NoError(t, err)
+			assert.Equal(t, "refresh-token", string(refreshToken), "the refresh token is kept if the provider does not rotate it")
+		})
+	})
+
+	t.Run("case=fails if the refresh token is rejected", func(t *testing.T) {
+		i := createIdentity(t, time.Now().Add(-time.Hour), "revoked-refresh-token")
+
+		body := fetch(t, i, "upstream", http.StatusBadGateway)
+		assert.Contains(t, gjson.GetBytes(body, "error.reason").String(), "Unable to refresh the access token of provider upstream", "%s", body)
+	})
+
+	t.Run("case=fails for providers the identity did not sign in with", func(t *testing.T) {
+		i := createIdentity(t, time.Now().Add(time.Hour), "refresh-token")
+
+		fetch(t, i, "other", http.StatusNotFound)
+	})
+
+	t.Run("case=requires the scope of the admin API token", func(t *testing.T) {
+		i := createIdentity(t, time.Now().Add(time.Hour), "refresh-token")
+		conf.MustSet(ctx, config.ViperKeyAdminAPITokens, []map[string]any{
+			{"id": "support", "token": "support-token-support-token-support-token"},
+			{"id": "backend", "token": "backend-token-backend-token-backend-token", "scopes": []string{config.AdminAPITokenScopeUpstreamTokensRead}},
+		})
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyAdminAPITokens, nil)
+		})
+
+		for token, status := range map[string]int{
+			"support-token-support-token-support-token": http.StatusForbidden,
+			"backend-token-backend-token-backend-token": http.StatusOK,
+		} {
+			req, err := http.NewRequest("GET", ts.URL+"/admin/identities/"+i.ID.String()+"/credentials/oidc/upstream/token", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+token)
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			_ = res.Body.Close()
+			assert.Equal(t, status, res.StatusCode)
+		}
+	})
+}
@@ -6,18 +6,23 @@ package oidc
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 )
 
-func verifyToken(ctx context.Context, keySet oidc.KeySet, config *Configuration, rawIDToken, issuerURL string) (*Claims, error) {
+// verifyToken verifies the ID token against the key set. The token must be issued by one of
+// the given issuer URLs and for the client ID or one of the additional ID token audiences.
+func verifyToken(ctx context.Context, keySet oidc.KeySet, config *Configuration, rawIDToken string, issuerURLs ...string) (*Claims, error) {
 	tokenAudiences := append([]string{config.ClientID}, config.AdditionalIDTokenAudiences...)
 	var token *oidc.IDToken
 	err := fmt.Errorf("no audience matched the token's audience")
 	for _, aud := range tokenAudiences {
-		verifier := oidc.NewVerifier(issuerURL, keySet, &oidc.Config{
+		verifier := oidc.NewVerifier(issuerURLs[0], keySet, &oidc.Config{
 			ClientID: aud,
+			// The issuer is checked below, because some providers use more than one issuer.
+			SkipIssuerCheck: true,
 		})
 		token, err = verifier.Verify(ctx, rawIDToken)
 		if err != nil && strings.Contains(err.Error(), "oidc: expected audience") {
@@ -41,6 +46,10 @@ func verifyToken(ctx context.Context, keySet oidc.KeySet, config *Configuration,
 		return nil, fmt.Errorf("token is nil")
 	}
 
+	if !slices.Contains(issuerURLs, token.Issuer) {
+		return nil, fmt.Errorf("oidc: id token issued by a different provider, expected one of %q got %q", issuerURLs, token.Issuer)
+	}
+
 	if err := token.Claims(claims); err != nil {
 		return nil, err
 	}
@@ -147,6 +147,12 @@ type updateLoginFlowWithPasskeyMethod struct {
 	//
 	// This must contain the ID of the WebAuthN connection.
 	Login string `json:"passkey_login"`
+
+	// Remember the session for its full lifespan. Only used if remember me is enabled. API flows
+	// which omit this field use the default of the login method.
+	//
+	// required: false
+	Remember *bool `json:"remember,omitempty" form:"remember"`
 }
 
 func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow, _ *session.Session) (i *identity.Identity, err error) {
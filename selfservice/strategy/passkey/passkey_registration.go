@@ -94,9 +94,9 @@ func (s *Strategy) handleRegistrationError(_ http.ResponseWriter, r *http.Reques
 	return err
 }
 
-func (s *Strategy) decode(r *http.Request) (*updateRegistrationFlowWithPasskeyMethod, error) {
+func (s *Strategy) decode(r *http.Request, regFlow *registration.Flow) (*updateRegistrationFlowWithPasskeyMethod, error) {
 	var p updateRegistrationFlowWithPasskeyMethod
-	err := registration.DecodeBody(&p, r, s.hd, s.d.Config(), registrationSchema)
+	err := registration.DecodeBody(&p, r, s.hd, s.d.Config(), regFlow, registrationSchema)
 	return &p, err
 }
 
@@ -109,7 +109,7 @@ func (s *Strategy) Register(w http.ResponseWriter, r *http.Request, regFlow *reg
 		return flow.ErrStrategyNotResponsible
 	}
 
-	params, err := s.decode(r)
+	params, err := s.decode(r, regFlow)
 	if err != nil {
 		return s.handleRegistrationError(w, r, regFlow, params, err)
 	}
@@ -164,16 +164,12 @@ func (s *Strategy) Register(w http.ResponseWriter, r *http.Request, regFlow *reg
 			herodot.ErrInternalServerError.WithReasonf("Unable to get webAuthn config").WithDebug(err.Error())))
 	}
 
-	credential, err := webAuthn.CreateCredential(&webauthnx.User{
+	credential, err := webauthnx.CreateCredential(ctx, s.d, webAuthn, s.d.Config().PasskeyAttestation(ctx), "#/passkey_register", &webauthnx.User{
 		ID:     webAuthnSess.UserID,
 		Config: webAuthn.Config,
 	}, webAuthnSess, webAuthnResponse)
 	if err != nil {
-		if devErr := new(protocol.Error); errors.As(err, &devErr) {
-			s.d.Logger().WithError(err).WithField("error_devinfo", devErr.DevInfo).Error("Failed to create WebAuthn credential")
-		}
-		return s.handleRegistrationError(w, r, regFlow, params, errors.WithStack(
-			herodot.ErrInternalServerError.WithReasonf("Unable to create WebAuthn credential: %s", err)))
+		return s.handleRegistrationError(w, r, regFlow, params, err)
 	}
 
 	credentialWebAuthn := identity.CredentialFromWebAuthn(credential, true)
@@ -212,11 +208,11 @@ func (s *Strategy) PopulateRegistrationMethod(r *http.Request, regFlow *registra
 		return nil
 	}
 
-	defaultSchemaURL, err := s.d.Config().DefaultIdentityTraitsSchemaURL(ctx)
+	schemaURL, err := regFlow.IdentitySchemaURL(ctx, s.d.Config())
 	if err != nil {
 		return err
 	}
-	nodes, err := s.populateRegistrationNodes(ctx, defaultSchemaURL)
+	nodes, err := s.populateRegistrationNodes(ctx, schemaURL)
 	if err != nil {
 		return err
 	}
@@ -228,7 +224,7 @@ func (s *Strategy) PopulateRegistrationMethod(r *http.Request, regFlow *registra
 	// Passkey nodes begin
 	createData := new(passkeyCreateData)
 
-	fieldName, err := s.PasskeyDisplayNameFromSchema(ctx, defaultSchemaURL.String())
+	fieldName, err := s.PasskeyDisplayNameFromSchema(ctx, schemaURL.String())
 	if err != nil {
 		return err
 	}
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 
@@ -255,8 +254,8 @@ func (s *Strategy) continueSettingsFlow(
 			return err
 		}
 
-		if ctxUpdate.Session.AuthenticatedAt.Add(s.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)).Before(time.Now()) {
-			return errors.WithStack(settings.NewFlowNeedsReAuth())
+		if err := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup()); err != nil {
+			return err
 		}
 	} else {
 		return errors.New("ended up in unexpected state")
@@ -346,12 +345,12 @@ func (s *Strategy) continueSettingsFlowAdd(ctx context.Context, ctxUpdate *setti
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to get webAuthn config.").WithDebug(err.Error()))
 	}
 
-	credential, err := web.CreateCredential(&webauthnx.User{
+	credential, err := webauthnx.CreateCredential(ctx, s.d, web, s.d.Config().PasskeyAttestation(ctx), "#/passkey_settings_register", &webauthnx.User{
 		ID:     webAuthnSess.UserID,
 		Config: web.Config,
 	}, webAuthnSess, webAuthnResponse)
 	if err != nil {
-		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to create WebAuthn credential: %s", err))
+		return err
 	}
 
 	i, err := s.d.PrivilegedIdentityPool().GetIdentityConfidential(ctx, ctxUpdate.Session.IdentityID)
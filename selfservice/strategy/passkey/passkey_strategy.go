@@ -21,6 +21,7 @@ import (
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
+	"github.com/ory/kratos/x/webauthnx"
 	"github.com/ory/x/decoderx"
 )
 
@@ -62,6 +63,8 @@ type strategyDependencies interface {
 
 	session.HandlerProvider
 	session.ManagementProvider
+
+	webauthnx.MetadataLoaderProvider
 }
 
 var (
@@ -117,6 +117,19 @@ func (s *Strategy) Login(w http.ResponseWriter, r *http.Request, f *login.Flow,
 		}
 	}
 
+	// Temporary passwords set by an administrator can only be used until they expire.
+	if o.IsExpired(time.Now()) {
+		return nil, s.handleLoginError(r, f, p, schema.NewTemporaryPasswordExpiredError(*o.ExpiresAt))
+	}
+
+	// Passwords older than the maximum password age must be rotated before the session can be used.
+	if maxAge := s.d.Config().PasswordPolicyConfig(ctx).MaxAge; maxAge > 0 && !i.PasswordResetRequired && time.Now().After(o.RotateAt(c, maxAge)) {
+		i.PasswordResetRequired = true
+		if err := s.d.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "password_reset_required"); err != nil {
+			return nil, s.handleLoginError(r, f, p, err)
+		}
+	}
+
 	f.Active = s.ID()
 	if err = s.d.LoginFlowPersister().UpdateLoginFlow(ctx, f); err != nil {
 		return nil, s.handleLoginError(r, f, p, errors.WithStack(herodot.ErrInternalServerError.WithReason("Could not update flow").WithDebug(err.Error())))
@@ -133,11 +146,6 @@ func (s *Strategy) migratePasswordHash(ctx context.Context, identifier uuid.UUID
 	if err != nil {
 		return err
 	}
-	co, err := json.Marshal(&identity.CredentialsPassword{HashedPassword: string(hpw)})
-	if err != nil {
-		return errors.Wrap(err, "unable to encode password configuration to JSON")
-	}
-
 	i, err := s.d.PrivilegedIdentityPool().GetIdentityConfidential(ctx, identifier)
 	if err != nil {
 		return err
@@ -148,6 +156,24 @@ func (s *Strategy) migratePasswordHash(ctx context.Context, identifier uuid.UUID
 		return errors.New("expected to find password credential but could not")
 	}
 
+	// Keep the expiry and age of the password when migrating the hash.
+	var previous identity.CredentialsPassword
+	if len(c.Config) > 0 {
+		if err := json.Unmarshal(c.Config, &previous); err != nil {
+			return errors.Wrap(err, "unable to decode password configuration from JSON")
+		}
+	}
+
+	co, err := json.Marshal(&identity.CredentialsPassword{
+		HashedPassword: string(hpw),
+		Temporary:      previous.Temporary,
+		ExpiresAt:      previous.ExpiresAt,
+		ChangedAt:      previous.ChangedAt,
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to encode password configuration to JSON")
+	}
+
 	c.Config = co
 	i.SetCredentials(s.ID(), *c)
 
@@ -45,6 +45,7 @@ import (
 	"github.com/ory/kratos/internal/testhelpers"
 	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/selfservice/flow/login"
+	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/assertx"
@@ -481,6 +482,67 @@ func TestCompleteLogin(t *testing.T) {
 		})
 	})
 
+	t.Run("case=remember me", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionRememberMeEnabled, true)
+		conf.MustSet(ctx, config.ViperKeySessionRememberMeShortLifespan, "10m")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySessionRememberMeEnabled, false)
+			conf.MustSet(ctx, config.ViperKeySessionRememberMeMethods+".password", false)
+		})
+
+		identifier, pwd := x.NewUUID().String(), "password"
+		createIdentity(ctx, reg, t, identifier, pwd)
+
+		values := func(remember string) func(v url.Values) {
+			return func(v url.Values) {
+				v.Set("identifier", identifier)
+				v.Set("password", pwd)
+				if remember != "" {
+					v.Set("remember", remember)
+				}
+			}
+		}
+
+		assertShortLived := func(t *testing.T, expiresAt gjson.Result) {
+			assert.WithinDuration(t, time.Now().Add(10*time.Minute), expiresAt.Time(), time.Minute)
+		}
+		assertRemembered := func(t *testing.T, expiresAt gjson.Result) {
+			assert.WithinDuration(t, time.Now().Add(conf.SessionLifespan(ctx)), expiresAt.Time(), time.Minute)
+		}
+
+		t.Run("case=shows the remember node", func(t *testing.T) {
+			f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false)
+			raw, err := json.Marshal(f)
+			require.NoError(t, err)
+			assert.Equal(t, "checkbox", gjson.GetBytes(raw, "ui.nodes.#(attributes.name==remember).attributes.type").String(), "%s", raw)
+		})
+
+		t.Run("type=browser", func(t *testing.T) {
+			body := testhelpers.SubmitLoginForm(t, false, testhelpers.NewClientWithCookies(t), publicTS, values(""),
+				false, false, http.StatusOK, redirTS.URL)
+			assertShortLived(t, gjson.Get(body, "expires_at"))
+
+			body = testhelpers.SubmitLoginForm(t, false, testhelpers.NewClientWithCookies(t), publicTS, values("true"),
+				false, false, http.StatusOK, redirTS.URL)
+			assertRemembered(t, gjson.Get(body, "expires_at"))
+		})
+
+		t.Run("type=api", func(t *testing.T) {
+			body := testhelpers.SubmitLoginForm(t, true, nil, publicTS, values(""),
+				false, false, http.StatusOK, publicTS.URL+login.RouteSubmitFlow)
+			assertShortLived(t, gjson.Get(body, "session.expires_at"))
+
+			conf.MustSet(ctx, config.ViperKeySessionRememberMeMethods+".password", true)
+			body = testhelpers.SubmitLoginForm(t, true, nil, publicTS, values(""),
+				false, false, http.StatusOK, publicTS.URL+login.RouteSubmitFlow)
+			assertRemembered(t, gjson.Get(body, "session.expires_at"))
+
+			body = testhelpers.SubmitLoginForm(t, true, nil, publicTS, values("false"),
+				false, false, http.StatusOK, publicTS.URL+login.RouteSubmitFlow)
+			assertShortLived(t, gjson.Get(body, "session.expires_at"))
+		})
+	})
+
 	t.Run("should pass with real request", func(t *testing.T) {
 		identifier, pwd := x.NewUUID().String(), "password"
 		createIdentity(ctx, reg, t, identifier, pwd)
@@ -782,6 +844,99 @@ func TestCompleteLogin(t *testing.T) {
 		assert.Empty(t, gjson.Get(body, "continue_with").Array(), "%s", body)
 	})
 
+	t.Run("suite=temporary passwords", func(t *testing.T) {
+		createTemporaryIdentity := func(t *testing.T, expiresAt time.Time) (string, string) {
+			identifier, pwd := x.NewUUID().String(), "password"
+			i := createIdentity(ctx, reg, t, identifier, pwd)
+
+			c := i.Credentials[identity.CredentialsTypePassword]
+			var o identity.CredentialsPassword
+			require.NoError(t, json.Unmarshal(c.Config, &o))
+			o.Temporary, o.ExpiresAt = true, &expiresAt
+			require.NoError(t, i.SetCredentialsWithConfig(identity.CredentialsTypePassword, c, o))
+			i.PasswordResetRequired = true
+			require.NoError(t, reg.IdentityManager().Update(ctx, i, identity.ManagerAllowWriteProtectedTraits))
+			return identifier, pwd
+		}
+
+		t.Run("case=api flow continues in the settings flow", func(t *testing.T) {
+			identifier, pwd := createTemporaryIdentity(t, time.Now().Add(time.Hour))
+			f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false)
+
+			body, res := testhelpers.LoginMakeRequest(t, true, false, f, apiClient, fmt.Sprintf(`{"method":"password","identifier":"%s","password":"%s"}`, identifier, pwd))
+			assert.EqualValues(t, http.StatusOK, res.StatusCode, body)
+			assert.True(t, gjson.Get(body, "session.password_reset_required").Bool(), "%s", body)
+			assert.EqualValues(t, flow.ContinueWithActionShowSettingsUIString, gjson.Get(body, "continue_with.0.action").String(), "%s", body)
+
+			sf, err := reg.SettingsFlowPersister().GetSettingsFlow(ctx, uuid.FromStringOrNil(gjson.Get(body, "continue_with.0.flow.id").String()))
+			require.NoError(t, err)
+			assert.EqualValues(t, gjson.Get(body, "session.identity.id").String(), sf.IdentityID.String())
+		})
+
+		t.Run("case=expired temporary passwords are rejected", func(t *testing.T) {
+			identifier, pwd := createTemporaryIdentity(t, time.Now().Add(-time.Minute))
+			f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false)
+
+			body, res := testhelpers.LoginMakeRequest(t, true, false, f, apiClient, fmt.Sprintf(`{"method":"password","identifier":"%s","password":"%s"}`, identifier, pwd))
+			assert.EqualValues(t, http.StatusBadRequest, res.StatusCode, body)
+			assert.EqualValues(t, text.ErrorValidationLoginTemporaryPasswordExpired, gjson.Get(body, "ui.messages.0.id").Int(), "%s", body)
+		})
+	})
+
+	t.Run("suite=password expiry policy", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyPasswordMaxAge, "720h")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyPasswordMaxAge, "") })
+
+		createAgedIdentity := func(t *testing.T, changedAt time.Time) (*identity.Identity, string, string) {
+			identifier, pwd := x.NewUUID().String(), "password"
+			i := createIdentity(ctx, reg, t, identifier, pwd)
+
+			c := i.Credentials[identity.CredentialsTypePassword]
+			var o identity.CredentialsPassword
+			require.NoError(t, json.Unmarshal(c.Config, &o))
+			o.ChangedAt = &changedAt
+			require.NoError(t, i.SetCredentialsWithConfig(identity.CredentialsTypePassword, c, o))
+			require.NoError(t, reg.IdentityManager().Update(ctx, i, identity.ManagerAllowWriteProtectedTraits))
+			return i, identifier, pwd
+		}
+
+		login := func(t *testing.T, identifier, pwd string) string {
+			f := testhelpers.InitializeLoginFlowViaAPI(t, apiClient, publicTS, false)
+			body, res := testhelpers.LoginMakeRequest(t, true, false, f, apiClient, fmt.Sprintf(`{"method":"password","identifier":"%s","password":"%s"}`, identifier, pwd))
+			require.EqualValues(t, http.StatusOK, res.StatusCode, body)
+			return body
+		}
+
+		t.Run("case=passwords within the maximum age can be used", func(t *testing.T) {
+			i, identifier, pwd := createAgedIdentity(t, time.Now().Add(-24*time.Hour))
+
+			body := login(t, identifier, pwd)
+			assert.False(t, gjson.Get(body, "session.password_reset_required").Bool(), "%s", body)
+			assert.Empty(t, gjson.Get(body, "continue_with").Array(), "%s", body)
+
+			sess, err := reg.SessionPersister().GetSession(ctx, uuid.FromStringOrNil(gjson.Get(body, "session.id").String()), session.ExpandNothing)
+			require.NoError(t, err)
+			require.NotNil(t, sess.PasswordExpiresAt)
+			assert.WithinDuration(t, time.Now().Add(29*24*time.Hour), time.Time(*sess.PasswordExpiresAt), time.Minute)
+
+			actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+			require.NoError(t, err)
+			assert.False(t, actual.PasswordResetRequired)
+		})
+
+		t.Run("case=expired passwords must be changed", func(t *testing.T) {
+			i, identifier, pwd := createAgedIdentity(t, time.Now().Add(-31*24*time.Hour))
+
+			body := login(t, identifier, pwd)
+			assert.True(t, gjson.Get(body, "session.password_reset_required").Bool(), "%s", body)
+			assert.EqualValues(t, flow.ContinueWithActionShowSettingsUIString, gjson.Get(body, "continue_with.0.action").String(), "%s", body)
+
+			actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+			require.NoError(t, err)
+			assert.True(t, actual.PasswordResetRequired)
+		})
+	})
+
 	t.Run("should login even if old form field name is used", func(t *testing.T) {
 		identifier, pwd := x.NewUUID().String(), "password"
 		createIdentity(ctx, reg, t, identifier, pwd)
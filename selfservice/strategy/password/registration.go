@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/ory/x/otelx"
 
@@ -23,6 +24,7 @@ import (
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/errorsx"
+	"github.com/ory/x/pointerx"
 )
 
 // Update Registration Flow with Password Method
@@ -73,8 +75,8 @@ func (s *Strategy) handleRegistrationError(r *http.Request, f *registration.Flow
 	return err
 }
 
-func (s *Strategy) decode(p *UpdateRegistrationFlowWithPasswordMethod, r *http.Request) (err error) {
-	return registration.DecodeBody(p, r, s.hd, s.d.Config(), registrationSchema)
+func (s *Strategy) decode(p *UpdateRegistrationFlowWithPasswordMethod, r *http.Request, f *registration.Flow) (err error) {
+	return registration.DecodeBody(p, r, s.hd, s.d.Config(), f, registrationSchema)
 }
 
 func (s *Strategy) Register(_ http.ResponseWriter, r *http.Request, f *registration.Flow, i *identity.Identity) (err error) {
@@ -86,7 +88,7 @@ func (s *Strategy) Register(_ http.ResponseWriter, r *http.Request, f *registrat
 	}
 
 	var p UpdateRegistrationFlowWithPasswordMethod
-	if err := s.decode(&p, r); err != nil {
+	if err := s.decode(&p, r, f); err != nil {
 		return s.handleRegistrationError(r, f, p, err)
 	}
 
@@ -137,7 +139,7 @@ func (s *Strategy) Register(_ http.ResponseWriter, r *http.Request, f *registrat
 	case err := <-errC:
 		return s.handleRegistrationError(r, f, p, err)
 	case h := <-hpw:
-		co, err := json.Marshal(&identity.CredentialsPassword{HashedPassword: string(h)})
+		co, err := json.Marshal(&identity.CredentialsPassword{HashedPassword: string(h), ChangedAt: pointerx.Ptr(time.Now().UTC())})
 		if err != nil {
 			return s.handleRegistrationError(r, f, p, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to encode password options to JSON: %s", err)))
 		}
@@ -179,7 +181,7 @@ func (s *Strategy) validateCredentials(ctx context.Context, i *identity.Identity
 }
 
 func (s *Strategy) PopulateRegistrationMethod(r *http.Request, f *registration.Flow) error {
-	ds, err := s.d.Config().DefaultIdentityTraitsSchemaURL(r.Context())
+	ds, err := f.IdentitySchemaURL(r.Context(), s.d.Config())
 	if err != nil {
 		return err
 	}
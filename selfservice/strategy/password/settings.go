@@ -28,6 +28,7 @@ import (
 	"github.com/ory/kratos/selfservice/flow/settings"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/decoderx"
+	"github.com/ory/x/pointerx"
 )
 
 func (s *Strategy) RegisterSettingsRoutes(_ *x.RouterPublic) {
@@ -127,8 +128,8 @@ func (s *Strategy) continueSettingsFlow(ctx context.Context, r *http.Request, ct
 		return err
 	}
 
-	if ctxUpdate.Session.AuthenticatedAt.Add(s.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)).Before(time.Now()) {
-		return errors.WithStack(settings.NewFlowNeedsReAuth())
+	if err := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup()); err != nil {
+		return err
 	}
 
 	if len(p.Password) == 0 {
@@ -161,7 +162,7 @@ func (s *Strategy) continueSettingsFlow(ctx context.Context, r *http.Request, ct
 	case err := <-errC:
 		return err
 	case h := <-hpw:
-		co, err := json.Marshal(&identity.CredentialsPassword{HashedPassword: string(h)})
+		co, err := json.Marshal(&identity.CredentialsPassword{HashedPassword: string(h), ChangedAt: pointerx.Ptr(time.Now().UTC())})
 		if err != nil {
 			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to encode password options to JSON: %s", err))
 		}
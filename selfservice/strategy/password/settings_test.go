@@ -200,6 +200,23 @@ func TestSettings(t *testing.T) {
 		})
 	})
 
+	t.Run("description=should require reauthentication for the password group", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceSettingsPrivilegedAuthenticationAfter, "5m")
+		conf.MustSet(ctx, config.ViperKeySelfServiceSettingsGroups+".password.privileged_session_max_age", "1ns")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceSettingsGroups+".password.privileged_session_max_age", "")
+		})
+
+		var payload = func(v url.Values) {
+			v.Set("method", "password")
+			v.Set("password", x.NewUUID().String())
+		}
+
+		actual := testhelpers.SubmitSettingsForm(t, true, false, apiUser1, publicTS, payload,
+			http.StatusForbidden, publicTS.URL+settings.RouteSubmitFlow)
+		assertx.EqualAsJSONExcept(t, settings.NewFlowNeedsReAuth(), json.RawMessage(actual), []string{"redirect_browser_to"})
+	})
+
 	t.Run("description=should not be able to make requests for another user", func(t *testing.T) {
 		t.Run("type=api", func(t *testing.T) {
 			f := testhelpers.InitializeSettingsFlowViaAPI(t, apiUser1, publicTS)
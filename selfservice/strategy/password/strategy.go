@@ -16,6 +16,7 @@ import (
 
 	"github.com/ory/x/decoderx"
 
+	"github.com/ory/kratos/cipher"
 	"github.com/ory/kratos/continuity"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/hash"
@@ -24,6 +25,7 @@ import (
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/selfservice/flow/registration"
 	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/x"
 )
@@ -43,9 +45,11 @@ type registrationStrategyDependencies interface {
 	x.TracingProvider
 	jsonnetsecure.VMProvider
 	config.Provider
+	cipher.Provider
 	continuity.ManagementProvider
 
 	errorx.ManagementProvider
+	deadletter.PersistenceProvider
 	ValidationProvider
 	hash.HashProvider
 
@@ -35,4 +35,10 @@ type updateLoginFlowWithPasswordMethod struct {
 	//
 	// required: false
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty" form:"transient_payload"`
+
+	// Remember the session for its full lifespan. Only used if remember me is enabled. API flows
+	// which omit this field use the default of the login method.
+	//
+	// required: false
+	Remember *bool `json:"remember,omitempty" form:"remember"`
 }
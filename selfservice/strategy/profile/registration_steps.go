@@ -0,0 +1,206 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/jsonschemax"
+)
+
+// currentStep returns the index of the configured step the flow is in. If the credentials are
+// being chosen, the number of configured steps is returned.
+func currentStep(regFlow *registration.Flow, steps []config.RegistrationStep) int {
+	if regFlow.Steps == nil {
+		return 0
+	}
+	if regFlow.Steps.Current == registration.StepCredentials {
+		return len(steps)
+	}
+	for k, step := range steps {
+		if step.ID == regFlow.Steps.Current {
+			return k
+		}
+	}
+	return 0
+}
+
+func (s *Strategy) displayNextStep(ctx context.Context, w http.ResponseWriter, r *http.Request, regFlow *registration.Flow, i *identity.Identity, steps []config.RegistrationStep, params updateRegistrationFlowWithProfileMethod) error {
+	current := currentStep(regFlow, steps)
+	if current >= len(steps)-1 {
+		// The traits not asked for in any step belong to the last step, so we validate all traits.
+		return s.displayStepTwoNodes(ctx, w, r, regFlow, i, steps, params)
+	}
+
+	regFlow.UI.ResetMessages()
+	regFlow.TransientPayload = params.TransientPayload
+
+	if err := flow.EnsureCSRF(s.d, r, regFlow.Type, s.d.Config().DisableAPIFlowEnforcement(ctx), s.d.GenerateCSRFToken, params.CSRFToken); err != nil {
+		return s.handleRegistrationError(r, regFlow, params, err)
+	}
+
+	if len(params.Traits) == 0 {
+		params.Traits = json.RawMessage("{}")
+	}
+	i.Traits = identity.Traits(params.Traits)
+	if err := stepValidationError(s.d.IdentityValidator().Validate(ctx, i), steps, current); err != nil {
+		return s.handleRegistrationError(r, regFlow, params, err)
+	}
+
+	return s.displayStep(ctx, w, r, regFlow, steps, current+1, params)
+}
+
+func (s *Strategy) displayPreviousStep(ctx context.Context, w http.ResponseWriter, r *http.Request, regFlow *registration.Flow, steps []config.RegistrationStep, params updateRegistrationFlowWithProfileMethod) error {
+	regFlow.Active = ""
+	regFlow.State = "choose_method"
+	regFlow.UI.ResetMessages()
+	return s.displayStep(ctx, w, r, regFlow, steps, max(currentStep(regFlow, steps)-1, 0), params)
+}
+
+// displayStep shows the trait nodes of the given step. The values of the traits of the other
+// steps are kept in hidden fields, so that they are submitted again.
+func (s *Strategy) displayStep(ctx context.Context, w http.ResponseWriter, r *http.Request, regFlow *registration.Flow, steps []config.RegistrationStep, step int, params updateRegistrationFlowWithProfileMethod) error {
+	var nodes node.Nodes
+	if err := json.Unmarshal([]byte(gjson.GetBytes(regFlow.InternalContext, "stepOneNodes").Raw), &nodes); err != nil {
+		return s.handleRegistrationError(r, regFlow, params, errors.WithStack(err))
+	}
+
+	regFlow.UI.Nodes = registration.StepNodes(nodes, steps, step)
+	regFlow.UI.UpdateNodeValuesFromJSON(params.Traits, "traits", node.DefaultGroup)
+	for _, n := range regFlow.UI.Nodes {
+		path, ok := strings.CutPrefix(n.ID(), "traits.")
+		if !ok || n.Type != node.Input || registration.StepOfTrait(steps, path) == step {
+			continue
+		}
+		if attr, ok := n.Attributes.(*node.InputAttributes); ok {
+			attr.Type = node.InputAttributeTypeHidden
+		}
+	}
+
+	if step > 0 {
+		regFlow.UI.Nodes.Append(node.NewInputField(
+			"screen",
+			"previous",
+			node.ProfileGroup,
+			node.InputAttributeTypeSubmit,
+		).WithMetaLabel(text.NewInfoRegistrationBack()))
+	}
+
+	if regFlow.Type == flow.TypeBrowser {
+		regFlow.UI.SetCSRF(s.d.GenerateCSRFToken(r))
+	}
+
+	if err := regFlow.SetStep(steps, steps[step].ID); err != nil {
+		return s.handleRegistrationError(r, regFlow, params, err)
+	}
+
+	if err := s.d.RegistrationFlowPersister().UpdateRegistrationFlow(ctx, regFlow); err != nil {
+		return s.handleRegistrationError(r, regFlow, params, err)
+	}
+
+	redirectTo := regFlow.AppendTo(s.d.Config().SelfServiceFlowRegistrationUI(ctx)).String()
+	if x.IsJSONRequest(r) {
+		s.d.Writer().WriteCode(w, r, http.StatusBadRequest, regFlow)
+	} else {
+		http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+	}
+
+	return flow.ErrCompletedByStrategy
+}
+
+// stepValidationError removes all validation errors from err which do not concern the traits
+// of the given step. It returns nil if no validation error remains.
+func stepValidationError(err error, steps []config.RegistrationStep, step int) error {
+	if err == nil {
+		return nil
+	}
+
+	inStep := func(pointer string) bool {
+		dotted, err := jsonschemax.JSONPointerToDotNotation(pointer)
+		if err != nil {
+			return true
+		}
+		path, ok := strings.CutPrefix(dotted, "traits.")
+		if !ok {
+			return false
+		}
+		if registration.StepOfTrait(steps, path) == step {
+			return true
+		}
+		// The pointer may also refer to an object containing a trait of the step.
+		return slices.ContainsFunc(steps[step].Traits, func(trait string) bool {
+			return strings.HasPrefix(trait, path+".")
+		})
+	}
+
+	if e := new(schema.ValidationListError); errors.As(err, &e) {
+		var validations []*schema.ValidationError
+		for _, v := range e.Validations {
+			if inStep(v.InstancePtr) {
+				validations = append(validations, v)
+			}
+		}
+		if len(validations) == 0 {
+			return nil
+		}
+		return errors.WithStack(&schema.ValidationListError{Validations: validations})
+	} else if e := new(jsonschema.ValidationError); errors.As(err, &e) {
+		if filtered := filterValidationError(e, inStep); filtered != nil {
+			return errors.WithStack(filtered)
+		}
+		return nil
+	}
+
+	return err
+}
+
+func filterValidationError(e *jsonschema.ValidationError, keep func(pointer string) bool) *jsonschema.ValidationError {
+	if ctx, ok := e.Context.(*jsonschema.ValidationErrorContextRequired); ok {
+		missing := slices.DeleteFunc(slices.Clone(ctx.Missing), func(pointer string) bool {
+			return !keep(pointer)
+		})
+		if len(missing) == 0 {
+			return nil
+		}
+		filtered := *e
+		filtered.Context = &jsonschema.ValidationErrorContextRequired{Missing: missing}
+		return &filtered
+	}
+
+	if len(e.Causes) > 0 {
+		var causes []*jsonschema.ValidationError
+		for _, cause := range e.Causes {
+			if c := filterValidationError(cause, keep); c != nil {
+				causes = append(causes, c)
+			}
+		}
+		if len(causes) == 0 {
+			return nil
+		}
+		filtered := *e
+		filtered.Causes = causes
+		return &filtered
+	}
+
+	if !keep(e.InstancePtr) {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,108 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package profile_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow/registration"
+	"github.com/ory/kratos/x"
+)
+
+func TestRegistrationSteps(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/registration_steps.schema.json")
+	testhelpers.StrategyEnable(t, conf, identity.CredentialsTypePassword.String(), true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceRegistrationEnabled, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceRegistrationSteps, []map[string]any{
+		{"id": "name", "title": "Your Name", "traits": []string{"name"}},
+		{"id": "address", "traits": []string{"address.street"}},
+	})
+
+	_ = testhelpers.NewRegistrationUIFlowEchoServer(t, reg)
+	_ = testhelpers.NewErrorTestServer(t, reg)
+	publicTS, _ := testhelpers.NewKratosServer(t, reg)
+
+	client := &http.Client{}
+	f := testhelpers.InitializeRegistrationFlowViaAPI(t, client, publicTS)
+
+	submit := func(t *testing.T, body string) string {
+		actual, res := testhelpers.RegistrationMakeRequest(t, true, false, f, client, body)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, "%s", actual)
+		return actual
+	}
+
+	nodeType := func(flow string, name string) string {
+		return gjson.Get(flow, "ui.nodes.#(attributes.name==\""+name+"\").attributes.type").String()
+	}
+
+	t.Run("case=first step only contains its traits", func(t *testing.T) {
+		res, err := client.Get(publicTS.URL + registration.RouteGetFlow + "?id=" + f.Id)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		actual := string(x.MustReadAll(res.Body))
+
+		assert.Equal(t, "name", gjson.Get(actual, "steps.current").String(), "%s", actual)
+		assert.Equal(t, []any{"name", "address", "credentials"}, gjson.Get(actual, "steps.steps.#.id").Value())
+		assert.Equal(t, "Your Name", gjson.Get(actual, "steps.steps.0.title").String())
+
+		assert.Equal(t, "text", nodeType(actual, "traits.name.first"))
+		assert.Empty(t, nodeType(actual, "traits.address.street"))
+		assert.Empty(t, nodeType(actual, "traits.email"))
+	})
+
+	t.Run("case=validates only the traits of the current step", func(t *testing.T) {
+		actual := submit(t, `{"method":"profile","traits":{"name":{"first":"Jane"}}}`)
+
+		assert.Equal(t, "name", gjson.Get(actual, "steps.current").String(), "%s", actual)
+		assert.NotEmpty(t, gjson.Get(actual, "ui.nodes.#(attributes.name==traits.name.last).messages.0.text").String(), "%s", actual)
+		assert.Empty(t, gjson.Get(actual, "ui.nodes.#(attributes.name==traits.email).messages").Array(), "%s", actual)
+		assert.Empty(t, gjson.Get(actual, "ui.messages").Array(), "%s", actual)
+	})
+
+	t.Run("case=advances to the next step", func(t *testing.T) {
+		actual := submit(t, `{"method":"profile","traits":{"name":{"first":"Jane","last":"Doe"}}}`)
+
+		assert.Equal(t, "address", gjson.Get(actual, "steps.current").String(), "%s", actual)
+		assert.Equal(t, "hidden", nodeType(actual, "traits.name.first"))
+		assert.Equal(t, "Jane", gjson.Get(actual, "ui.nodes.#(attributes.name==traits.name.first).attributes.value").String())
+		assert.Equal(t, "text", nodeType(actual, "traits.address.street"))
+		assert.Equal(t, "email", nodeType(actual, "traits.email"), "traits which are not part of any step belong to the last step")
+		assert.Equal(t, "submit", nodeType(actual, "screen"))
+	})
+
+	t.Run("case=goes back to the previous step", func(t *testing.T) {
+		actual := submit(t, `{"screen":"previous","traits":{"name":{"first":"Jane","last":"Doe"},"address":{"street":"Main St"}}}`)
+
+		assert.Equal(t, "name", gjson.Get(actual, "steps.current").String(), "%s", actual)
+		assert.Equal(t, "text", nodeType(actual, "traits.name.first"))
+		assert.Equal(t, "hidden", nodeType(actual, "traits.address.street"))
+		assert.Equal(t, "Main St", gjson.Get(actual, "ui.nodes.#(attributes.name==traits.address.street).attributes.value").String())
+		assert.Empty(t, nodeType(actual, "screen"))
+	})
+
+	t.Run("case=continues with the credentials after the last step", func(t *testing.T) {
+		actual := submit(t, `{"method":"profile","traits":{"name":{"first":"Jane","last":"Doe"}}}`)
+		require.Equal(t, "address", gjson.Get(actual, "steps.current").String(), "%s", actual)
+
+		actual = submit(t, `{"method":"profile","traits":{"name":{"first":"Jane","last":"Doe"},"address":{"street":"Main St"},"email":"jane@ory.sh"}}`)
+		assert.Equal(t, registration.StepCredentials, gjson.Get(actual, "steps.current").String(), "%s", actual)
+		assert.Equal(t, "password", nodeType(actual, "password"), "%s", actual)
+		assert.Equal(t, "hidden", nodeType(actual, "traits.address.street"))
+
+		actual = submit(t, `{"screen":"previous","traits":{"name":{"first":"Jane","last":"Doe"},"address":{"street":"Main St"},"email":"jane@ory.sh"}}`)
+		assert.Equal(t, "address", gjson.Get(actual, "steps.current").String(), "%s", actual)
+	})
+}
@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"github.com/ory/x/otelx"
 
@@ -21,6 +20,7 @@ import (
 
 	"github.com/ory/herodot"
 	"github.com/ory/kratos/continuity"
+	"github.com/ory/kratos/courier"
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/schema"
@@ -66,6 +66,10 @@ type (
 		registration.FlowPersistenceProvider
 
 		schema.IdentitySchemaProvider
+
+		courier.Provider
+		courier.ConfigProvider
+		x.HTTPClientProvider
 	}
 	Strategy struct {
 		d  strategyDependencies
@@ -171,24 +175,55 @@ func (s *Strategy) continueFlow(ctx context.Context, r *http.Request, ctxUpdate
 		return err
 	}
 
-	options := []identity.ManagerOption{identity.ManagerExposeValidationErrorsForInternalTypeAssertion}
-	ttl := s.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)
-	if ctxUpdate.Session.AuthenticatedAt.Add(ttl).After(time.Now()) {
-		options = append(options, identity.ManagerAllowWriteProtectedTraits)
+	pending, err := pendingTraitChange(ctxUpdate.Flow)
+	if err != nil {
+		return err
+	}
+	if pending != nil && p.Code != "" {
+		return s.continueTraitChange(r, ctxUpdate, pending, p.Code)
 	}
 
-	update, err := s.d.IdentityManager().SetTraits(ctx, ctxUpdate.GetSessionIdentity().ID, identity.Traits(p.Traits), options...)
+	update, err := s.setTraits(r, ctxUpdate, p.Traits)
 	if err != nil {
-		if errors.Is(err, identity.ErrProtectedFieldModified) {
-			return settings.NewFlowNeedsReAuth()
-		}
 		return err
 	}
 
+	addresses, err := s.addressesToVerifyOnChange(ctx, ctxUpdate.GetSessionIdentity(), update)
+	if err != nil {
+		return err
+	} else if len(addresses) > 0 {
+		return s.startTraitChange(ctx, ctxUpdate, p.Traits, addresses)
+	} else if pending != nil {
+		// The traits were changed again and no longer need to be verified.
+		if err := s.storeTraitChange(ctx, ctxUpdate.Flow, nil); err != nil {
+			return err
+		}
+	}
+
 	ctxUpdate.UpdateIdentity(update)
 	return nil
 }
 
+func (s *Strategy) setTraits(r *http.Request, ctxUpdate *settings.UpdateContext, traits json.RawMessage) (*identity.Identity, error) {
+	ctx := r.Context()
+	options := []identity.ManagerOption{identity.ManagerExposeValidationErrorsForInternalTypeAssertion}
+
+	// Only changing protected traits (e.g. identifiers) requires a privileged session.
+	privilegedErr := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup())
+	if privilegedErr == nil {
+		options = append(options, identity.ManagerAllowWriteProtectedTraits)
+	}
+
+	update, err := s.d.IdentityManager().SetTraits(ctx, ctxUpdate.GetSessionIdentity().ID, identity.Traits(traits), options...)
+	if err != nil {
+		if errors.Is(err, identity.ErrProtectedFieldModified) {
+			return nil, privilegedErr
+		}
+		return nil, err
+	}
+	return update, nil
+}
+
 // Update Settings Flow with Profile Method
 //
 // swagger:model updateSettingsFlowWithProfileMethod
@@ -210,6 +245,13 @@ type updateSettingsFlowWithProfileMethod struct {
 	// required: true
 	Method string `json:"method"`
 
+	// Code
+	//
+	// The code sent to a changed trait which must be verified before the change is saved.
+	//
+	// required: false
+	Code string `json:"code,omitempty"`
+
 	// FlowIDRequestID is the flow ID.
 	//
 	// swagger:ignore
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/ory/x/decoderx"
 	"github.com/ory/x/otelx/semconv"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/tidwall/gjson"
 
+	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/selfservice/flow"
 	"github.com/ory/kratos/selfservice/flow/registration"
@@ -42,7 +44,7 @@ func (s *Strategy) PopulateRegistrationMethod(r *http.Request, f *registration.F
 		return nil
 	}
 
-	ds, err := s.d.Config().DefaultIdentityTraitsSchemaURL(r.Context())
+	ds, err := f.IdentitySchemaURL(r.Context(), s.d.Config())
 	if err != nil {
 		return err
 	}
@@ -122,8 +124,8 @@ type updateRegistrationFlowWithProfileMethod struct {
 	TransientPayload json.RawMessage `json:"transient_payload,omitempty"`
 }
 
-func (s *Strategy) decode(p *updateRegistrationFlowWithProfileMethod, r *http.Request) error {
-	return registration.DecodeBody(p, r, s.dc, s.d.Config(), registrationSchema)
+func (s *Strategy) decode(p *updateRegistrationFlowWithProfileMethod, r *http.Request, f *registration.Flow, opts ...decoderx.HTTPDecoderOption) error {
+	return registration.DecodeBody(p, r, s.dc, s.d.Config(), f, registrationSchema, opts...)
 }
 
 func (s *Strategy) Register(w http.ResponseWriter, r *http.Request, regFlow *registration.Flow, i *identity.Identity) (err error) {
@@ -137,25 +139,45 @@ func (s *Strategy) Register(w http.ResponseWriter, r *http.Request, regFlow *reg
 
 	var params updateRegistrationFlowWithProfileMethod
 
-	if err = s.decode(&params, r); err != nil {
+	steps, err := s.d.Config().SelfServiceFlowRegistrationSteps(ctx)
+	if err != nil {
+		return s.handleRegistrationError(r, regFlow, params, err)
+	}
+
+	var opts []decoderx.HTTPDecoderOption
+	if len(steps) > 0 {
+		// The traits of later steps are still missing, so the traits are validated step by step instead.
+		opts = append(opts, decoderx.HTTPDecoderSetValidatePayloads(false))
+	}
+
+	if err = s.decode(&params, r, regFlow, opts...); err != nil {
 		return s.handleRegistrationError(r, regFlow, params, err)
 	}
 
 	if params.Method == "profile" || len(params.Screen) > 0 {
 		switch params.Screen {
 		case RegistrationScreenCredentialSelection:
-			return s.displayStepTwoNodes(ctx, w, r, regFlow, i, params)
+			return s.displayStepTwoNodes(ctx, w, r, regFlow, i, steps, params)
 		case RegistrationScreenPrevious:
+			if len(steps) > 0 {
+				return s.displayPreviousStep(ctx, w, r, regFlow, steps, params)
+			}
 			return s.displayStepOneNodes(ctx, w, r, regFlow, params)
 		default:
+			if len(steps) > 0 {
+				return s.displayNextStep(ctx, w, r, regFlow, i, steps, params)
+			}
 			// FIXME In this scenario we are on the first step of the registration flow and the user clicked on "continue".
 			// FIXME The appropriate solution would be to also have `screen=credential-selection` available, but that
 			// FIXME is not the case right now. So instead, we fall back.
-			return s.displayStepTwoNodes(ctx, w, r, regFlow, i, params)
+			return s.displayStepTwoNodes(ctx, w, r, regFlow, i, steps, params)
 		}
 	} else if params.Method == "profile:back" {
 		// "profile:back" is kept for backwards compatibility.
 		span.AddEvent(semconv.NewDeprecatedFeatureUsedEvent(ctx, "profile:back"))
+		if len(steps) > 0 {
+			return s.displayPreviousStep(ctx, w, r, regFlow, steps, params)
+		}
 		return s.displayStepOneNodes(ctx, w, r, regFlow, params)
 	}
 
@@ -186,7 +208,7 @@ func (s *Strategy) displayStepOneNodes(ctx context.Context, w http.ResponseWrite
 	return flow.ErrCompletedByStrategy
 }
 
-func (s *Strategy) displayStepTwoNodes(ctx context.Context, w http.ResponseWriter, r *http.Request, regFlow *registration.Flow, i *identity.Identity, params updateRegistrationFlowWithProfileMethod) error {
+func (s *Strategy) displayStepTwoNodes(ctx context.Context, w http.ResponseWriter, r *http.Request, regFlow *registration.Flow, i *identity.Identity, steps []config.RegistrationStep, params updateRegistrationFlowWithProfileMethod) error {
 	// Reset state-esque flow fields
 	regFlow.Active = ""
 	regFlow.State = "choose_method"
@@ -234,6 +256,12 @@ func (s *Strategy) displayStepTwoNodes(ctx context.Context, w http.ResponseWrite
 		regFlow.UI.SetCSRF(s.d.GenerateCSRFToken(r))
 	}
 
+	if len(steps) > 0 {
+		if err := regFlow.SetStep(steps, registration.StepCredentials); err != nil {
+			return s.handleRegistrationError(r, regFlow, params, err)
+		}
+	}
+
 	if err = s.d.RegistrationFlowPersister().UpdateRegistrationFlow(ctx, regFlow); err != nil {
 		return s.handleRegistrationError(r, regFlow, params, err)
 	}
@@ -0,0 +1,335 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package profile
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/kratos/courier/template"
+	"github.com/ory/kratos/courier/template/email"
+	"github.com/ory/kratos/courier/template/sms"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/strategy/code"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/jsonschemax"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/sqlxx"
+)
+
+const (
+	internalContextKeyTraitChange = "trait_change"
+
+	// traitChangeMaxAttempts is the number of wrong codes after which a pending trait change is
+	// discarded.
+	traitChangeMaxAttempts = 5
+)
+
+type (
+	// traitChange is a settings change which is committed once all of its addresses are
+	// verified. It is stored in the internal context of the settings flow.
+	traitChange struct {
+		Traits    json.RawMessage      `json:"traits"`
+		Addresses []traitChangeAddress `json:"addresses"`
+		CodeHMAC  string               `json:"code_hmac"`
+		ExpiresAt time.Time            `json:"expires_at"`
+		Attempts  int                  `json:"attempts"`
+	}
+	traitChangeAddress struct {
+		Value    string `json:"value"`
+		Via      string `json:"via"`
+		Verified bool   `json:"verified"`
+	}
+)
+
+func (c *traitChange) next() *traitChangeAddress {
+	for k := range c.Addresses {
+		if !c.Addresses[k].Verified {
+			return &c.Addresses[k]
+		}
+	}
+	return nil
+}
+
+func traitChangeKey() string {
+	return flow.PrefixInternalContextKey(identity.CredentialsType(settings.StrategyProfile), internalContextKeyTraitChange)
+}
+
+// pendingTraitChange returns the trait change waiting for verification, if any.
+func pendingTraitChange(f *settings.Flow) (*traitChange, error) {
+	raw := gjson.GetBytes(f.InternalContext, traitChangeKey())
+	if !raw.IsObject() {
+		return nil, nil
+	}
+
+	var c traitChange
+	if err := json.Unmarshal([]byte(raw.Raw), &c); err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode the pending trait change.").WithDebug(err.Error()))
+	}
+	return &c, nil
+}
+
+func (s *Strategy) storeTraitChange(ctx context.Context, f *settings.Flow, c *traitChange) (err error) {
+	f.EnsureInternalContext()
+	if c == nil {
+		f.InternalContext, err = sjson.DeleteBytes(f.InternalContext, traitChangeKey())
+		f.UI.Nodes.Remove("code")
+	} else {
+		f.InternalContext, err = sjson.SetBytes(f.InternalContext, traitChangeKey(), c)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.d.SettingsFlowPersister().UpdateSettingsFlow(ctx, f)
+}
+
+// verifyOnChangePaths returns the gjson paths of all traits annotated with
+// `"verification": {"verify_on_change": true}`.
+func (s *Strategy) verifyOnChangePaths(ctx context.Context, schemaID string) ([]string, error) {
+	schemas, err := s.d.IdentityTraitsSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ss, err := schemas.GetByID(schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	runner, err := schema.NewExtensionRunner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := jsonschema.NewCompiler()
+	c.ExtractAnnotations = true
+	runner.Register(c)
+
+	compiled, err := c.Compile(ctx, ss.URL.String())
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to compile the identity schema.").WithDebugf("%s", err))
+	}
+
+	schemaPaths, err := jsonschemax.ListPathsWithInitializedSchema(compiled)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to list the paths of the identity schema.").WithDebugf("%s", err))
+	}
+
+	var paths []string
+	for _, p := range schemaPaths {
+		ext, ok := p.CustomProperties[schema.ExtensionName].(*schema.ExtensionConfig)
+		if !ok || !ext.Verification.VerifyOnChange || ext.Verification.Via == "" {
+			continue
+		}
+		if name, ok := strings.CutPrefix(p.Name, "traits."); ok {
+			// gjson returns the length of an array for a trailing "#", but we need its items.
+			paths = append(paths, strings.TrimSuffix(name, ".#"))
+		}
+	}
+	return paths, nil
+}
+
+// addressesToVerifyOnChange returns the addresses of the updated identity which stem from traits
+// annotated with `verify_on_change` and which the original identity does not have yet.
+func (s *Strategy) addressesToVerifyOnChange(ctx context.Context, original, updated *identity.Identity) ([]traitChangeAddress, error) {
+	paths, err := s.verifyOnChangePaths(ctx, updated.SchemaID)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+
+	values := map[string]bool{}
+	var collect func(gjson.Result)
+	collect = func(v gjson.Result) {
+		if v.IsArray() {
+			v.ForEach(func(_, item gjson.Result) bool {
+				collect(item)
+				return true
+			})
+		} else if v.Type == gjson.String {
+			values[strings.ToLower(strings.TrimSpace(v.String()))] = true
+		}
+	}
+	for _, p := range paths {
+		collect(gjson.GetBytes(updated.Traits, p))
+	}
+
+	var addresses []traitChangeAddress
+	for _, a := range updated.VerifiableAddresses {
+		if !values[strings.ToLower(a.Value)] {
+			continue
+		}
+		if slices.ContainsFunc(original.VerifiableAddresses, func(known identity.VerifiableAddress) bool {
+			return known.Value == a.Value && known.Via == a.Via
+		}) {
+			continue
+		}
+		addresses = append(addresses, traitChangeAddress{Value: a.Value, Via: a.Via})
+	}
+	return addresses, nil
+}
+
+func hmacCode(secret []byte, code string) string {
+	h := hmac.New(sha512.New512_256, secret)
+	_, _ = h.Write([]byte(code))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *Strategy) codeMatches(ctx context.Context, c *traitChange, supplied string) bool {
+	for _, secret := range s.d.Config().SecretsSession(ctx) {
+		if subtle.ConstantTimeCompare([]byte(hmacCode(secret, supplied)), []byte(c.CodeHMAC)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// sendTraitChangeCode sends a new code to the next address of the trait change which is not yet
+// verified and asks the user to enter it.
+func (s *Strategy) sendTraitChangeCode(ctx context.Context, ctxUpdate *settings.UpdateContext, c *traitChange) (err error) {
+	ctx, span := s.d.Tracer(ctx).Tracer().Start(ctx, "selfservice.strategy.profile.Strategy.sendTraitChangeCode")
+	defer otelx.End(span, &err)
+
+	address := c.next()
+	f := ctxUpdate.Flow
+	lifespan := s.d.Config().SelfServiceCodeMethodLifespan(ctx)
+
+	rawCode := code.GenerateCode()
+	c.CodeHMAC = hmacCode(s.d.Config().SecretsSession(ctx)[0], rawCode)
+	c.ExpiresAt = time.Now().UTC().Add(lifespan)
+	c.Attempts = 0
+
+	model, err := x.StructToMap(ctxUpdate.GetSessionIdentity())
+	if err != nil {
+		return err
+	}
+
+	s.d.Audit().
+		WithField("via", address.Via).
+		WithField("identity_id", ctxUpdate.Session.IdentityID).
+		WithField("settings_flow_id", f.ID).
+		WithSensitiveField("address", address.Value).
+		Info("Sending out a code to verify a changed trait.")
+
+	cr, err := s.d.Courier(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch address.Via {
+	case identity.ChannelTypeEmail:
+		_, err = cr.QueueEmail(ctx, email.NewVerificationCodeValid(s.d, &email.VerificationCodeValidModel{
+			To:               address.Value,
+			VerificationURL:  f.AppendTo(s.d.Config().SelfServiceFlowSettingsUI(ctx)).String(),
+			VerificationCode: rawCode,
+			Identity:         model,
+			RequestURL:       f.GetRequestURL(),
+			Branding:         template.Branding{Brand: f.GetBrand()},
+			ExpiresInMinutes: int(lifespan.Minutes()),
+		}))
+	case identity.ChannelTypeSMS:
+		_, err = cr.QueueSMS(ctx, sms.NewVerificationCodeValid(s.d, &sms.VerificationCodeValidModel{
+			To:               address.Value,
+			VerificationCode: rawCode,
+			Identity:         model,
+			RequestURL:       f.GetRequestURL(),
+			Branding:         template.Branding{Brand: f.GetBrand()},
+			ExpiresInMinutes: int(lifespan.Minutes()),
+		}))
+	default:
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Expected email or sms but got %s", address.Via))
+	}
+	if err != nil {
+		return err
+	}
+
+	f.UI.Nodes.Upsert(node.NewInputField("code", nil, node.ProfileGroup, node.InputAttributeTypeText, node.WithRequiredInputAttribute).
+		WithMetaLabel(text.NewInfoNodeLabelVerificationCode()))
+	f.UI.ResetMessages()
+	f.UI.Messages.Set(text.NewInfoSelfServiceSettingsVerifyTraitChange(code.MaskAddress(address.Value)))
+	return s.storeTraitChange(ctx, f, c)
+}
+
+// startTraitChange holds back the traits until the given addresses have been verified.
+func (s *Strategy) startTraitChange(ctx context.Context, ctxUpdate *settings.UpdateContext, traits json.RawMessage, addresses []traitChangeAddress) error {
+	if err := s.sendTraitChangeCode(ctx, ctxUpdate, &traitChange{Traits: traits, Addresses: addresses}); err != nil {
+		return err
+	}
+	return flow.ErrStrategyAsksToReturnToUI
+}
+
+// continueTraitChange verifies the code of a pending trait change. Once all addresses are
+// verified, the traits are set on the identity to update. The change is discarded if it expired
+// or if too many wrong codes were submitted.
+func (s *Strategy) continueTraitChange(r *http.Request, ctxUpdate *settings.UpdateContext, c *traitChange, supplied string) error {
+	ctx := r.Context()
+	f := ctxUpdate.Flow
+	if time.Now().After(c.ExpiresAt) {
+		if err := s.storeTraitChange(ctx, f, nil); err != nil {
+			return err
+		}
+		return schema.NewSettingsTraitChangeDiscarded()
+	}
+
+	if !s.codeMatches(ctx, c, supplied) {
+		c.Attempts++
+		if c.Attempts >= traitChangeMaxAttempts {
+			if err := s.storeTraitChange(ctx, f, nil); err != nil {
+				return err
+			}
+			return schema.NewSettingsTraitChangeDiscarded()
+		}
+		if err := s.storeTraitChange(ctx, f, c); err != nil {
+			return err
+		}
+		return schema.NewSettingsVerificationCodeInvalid()
+	}
+
+	c.next().Verified = true
+	if c.next() != nil {
+		if err := s.sendTraitChangeCode(ctx, ctxUpdate, c); err != nil {
+			return err
+		}
+		return flow.ErrStrategyAsksToReturnToUI
+	}
+
+	update, err := s.setTraits(r, ctxUpdate, c.Traits)
+	if err != nil {
+		return err
+	}
+
+	now := sqlxx.NullTime(time.Now().UTC())
+	for k, a := range update.VerifiableAddresses {
+		for _, verified := range c.Addresses {
+			if a.Value == verified.Value && a.Via == verified.Via {
+				update.VerifiableAddresses[k].Verified = true
+				update.VerifiableAddresses[k].VerifiedAt = &now
+				update.VerifiableAddresses[k].Status = identity.VerifiableAddressStatusCompleted
+			}
+		}
+	}
+
+	// The flow's internal context is reset once the settings are saved.
+	f.UI.Nodes.Remove("code")
+	ctxUpdate.UpdateIdentity(update)
+	return nil
+}
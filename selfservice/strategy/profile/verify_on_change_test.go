@@ -0,0 +1,163 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package profile_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/sqlxx"
+)
+
+func TestVerifyOnChange(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/verify_on_change.schema.json")
+	testhelpers.StrategyEnable(t, conf, identity.CredentialsTypePassword.String(), true)
+	testhelpers.StrategyEnable(t, conf, settings.StrategyProfile, true)
+	conf.MustSet(ctx, config.ViperKeySelfServiceSettingsPrivilegedAuthenticationAfter, "10m")
+
+	_ = testhelpers.NewSettingsUIEchoServer(t, reg)
+	_ = testhelpers.NewErrorTestServer(t, reg)
+	publicTS, _ := testhelpers.NewKratosServer(t, reg)
+
+	newUser := func(t *testing.T) (*identity.Identity, *http.Client) {
+		email := x.NewUUID().String() + "@ory.sh"
+		id := &identity.Identity{
+			ID: x.NewUUID(),
+			Credentials: map[identity.CredentialsType]identity.Credentials{
+				"password": {Type: "password", Identifiers: []string{email}, Config: sqlxx.JSONRawMessage(`{"hashed_password":"foo"}`)},
+			},
+			Traits:   identity.Traits(`{"email":"` + email + `","backup_email":"old-` + email + `","name":"john"}`),
+			SchemaID: config.DefaultIdentityTraitsSchemaID,
+			State:    identity.StateActive,
+		}
+		return id, testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, id)
+	}
+
+	submit := func(t *testing.T, client *http.Client, f string, body string) (string, *http.Response) {
+		sf, _, err := testhelpers.NewSDKCustomClient(publicTS, client).FrontendAPI.GetSettingsFlow(ctx).Id(f).Execute()
+		require.NoError(t, err)
+		return testhelpers.SettingsMakeRequest(t, true, false, sf, client, body)
+	}
+
+	traits := func(id *identity.Identity, backupEmail string) string {
+		return `{"email":"` + gjson.GetBytes(id.Traits, "email").String() + `","backup_email":"` + backupEmail + `","name":"john"}`
+	}
+
+	stored := func(t *testing.T, id *identity.Identity) *identity.Identity {
+		actual, err := reg.PrivilegedIdentityPool().GetIdentityConfidential(ctx, id.ID)
+		require.NoError(t, err)
+		return actual
+	}
+
+	expectCode := func(t *testing.T, to string) string {
+		return testhelpers.CourierExpectCodeInMessage(t, testhelpers.CourierExpectMessage(ctx, t, reg, to, ""), 1)
+	}
+
+	t.Run("case=changes to other traits are saved right away", func(t *testing.T) {
+		id, client := newUser(t)
+		f := testhelpers.InitializeSettingsFlowViaAPI(t, client, publicTS)
+
+		actual, res := submit(t, client, f.Id, `{"method":"profile","traits":{"email":"`+gjson.GetBytes(id.Traits, "email").String()+`","backup_email":"`+gjson.GetBytes(id.Traits, "backup_email").String()+`","name":"jane"}}`)
+		require.Equal(t, http.StatusOK, res.StatusCode, actual)
+		assert.Equal(t, "success", gjson.Get(actual, "state").String(), actual)
+		assert.Equal(t, "jane", gjson.GetBytes(stored(t, id).Traits, "name").String())
+	})
+
+	t.Run("case=saves the change once the code is verified", func(t *testing.T) {
+		id, client := newUser(t)
+		backup := "new-" + x.NewUUID().String() + "@ory.sh"
+		f := testhelpers.InitializeSettingsFlowViaAPI(t, client, publicTS)
+
+		actual, res := submit(t, client, f.Id, `{"method":"profile","traits":`+traits(id, backup)+`}`)
+		require.Equal(t, http.StatusOK, res.StatusCode, actual)
+		assert.Equal(t, "show_form", gjson.Get(actual, "state").String(), actual)
+		assert.Equal(t, int64(text.InfoSelfServiceSettingsVerifyTraitChange), gjson.Get(actual, "ui.messages.0.id").Int(), actual)
+		assert.True(t, gjson.Get(actual, `ui.nodes.#(attributes.name=="code")`).Exists(), actual)
+		assert.Equal(t, gjson.GetBytes(id.Traits, "backup_email").String(), gjson.GetBytes(stored(t, id).Traits, "backup_email").String(), "the change is not saved before it is verified")
+
+		code := expectCode(t, backup)
+
+		actual, res = submit(t, client, f.Id, `{"method":"profile","code":"000000","traits":`+traits(id, backup)+`}`)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, actual)
+		assert.Equal(t, int64(text.ErrorValidationSettingsVerificationCodeInvalid), gjson.Get(actual, `ui.nodes.#(attributes.name=="code").messages.0.id`).Int(), actual)
+
+		actual, res = submit(t, client, f.Id, `{"method":"profile","code":"`+code+`","traits":`+traits(id, backup)+`}`)
+		require.Equal(t, http.StatusOK, res.StatusCode, actual)
+		assert.Equal(t, "success", gjson.Get(actual, "state").String(), actual)
+		assert.False(t, gjson.Get(actual, `ui.nodes.#(attributes.name=="code")`).Exists(), actual)
+
+		updated := stored(t, id)
+		assert.Equal(t, backup, gjson.GetBytes(updated.Traits, "backup_email").String())
+		var found bool
+		for _, a := range updated.VerifiableAddresses {
+			if a.Value == backup {
+				found = true
+				assert.True(t, a.Verified)
+				assert.Equal(t, identity.VerifiableAddressStatusCompleted, a.Status)
+			}
+		}
+		assert.True(t, found, "%+v", updated.VerifiableAddresses)
+	})
+
+	t.Run("case=discards the change after too many wrong codes", func(t *testing.T) {
+		id, client := newUser(t)
+		backup := "new-" + x.NewUUID().String() + "@ory.sh"
+		f := testhelpers.InitializeSettingsFlowViaAPI(t, client, publicTS)
+
+		actual, res := submit(t, client, f.Id, `{"method":"profile","traits":`+traits(id, backup)+`}`)
+		require.Equal(t, http.StatusOK, res.StatusCode, actual)
+		code := expectCode(t, backup)
+
+		for k := 0; k < 4; k++ {
+			actual, res = submit(t, client, f.Id, `{"method":"profile","code":"000000","traits":`+traits(id, backup)+`}`)
+			require.Equal(t, http.StatusBadRequest, res.StatusCode, actual)
+			assert.Equal(t, int64(text.ErrorValidationSettingsVerificationCodeInvalid), gjson.Get(actual, `ui.nodes.#(attributes.name=="code").messages.0.id`).Int(), actual)
+		}
+
+		actual, res = submit(t, client, f.Id, `{"method":"profile","code":"000000","traits":`+traits(id, backup)+`}`)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, actual)
+		assert.Equal(t, int64(text.ErrorValidationSettingsTraitChangeDiscarded), gjson.Get(actual, "ui.messages.0.id").Int(), actual)
+		assert.False(t, gjson.Get(actual, `ui.nodes.#(attributes.name=="code")`).Exists(), actual)
+
+		// The correct code no longer works because the change was discarded. Submitting the traits
+		// again starts a new verification.
+		actual, res = submit(t, client, f.Id, `{"method":"profile","code":"`+code+`","traits":`+traits(id, backup)+`}`)
+		require.Equal(t, http.StatusOK, res.StatusCode, actual)
+		assert.Equal(t, int64(text.InfoSelfServiceSettingsVerifyTraitChange), gjson.Get(actual, "ui.messages.0.id").Int(), actual)
+		assert.Equal(t, gjson.GetBytes(id.Traits, "backup_email").String(), gjson.GetBytes(stored(t, id).Traits, "backup_email").String())
+	})
+
+	t.Run("case=discards the change once the code expired", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyCodeLifespan, "1ns")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyCodeLifespan, time.Hour.String()) })
+
+		id, client := newUser(t)
+		backup := "new-" + x.NewUUID().String() + "@ory.sh"
+		f := testhelpers.InitializeSettingsFlowViaAPI(t, client, publicTS)
+
+		actual, res := submit(t, client, f.Id, `{"method":"profile","traits":`+traits(id, backup)+`}`)
+		require.Equal(t, http.StatusOK, res.StatusCode, actual)
+		code := expectCode(t, backup)
+
+		actual, res = submit(t, client, f.Id, `{"method":"profile","code":"`+code+`","traits":`+traits(id, backup)+`}`)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode, actual)
+		assert.Equal(t, int64(text.ErrorValidationSettingsTraitChangeDiscarded), gjson.Get(actual, "ui.messages.0.id").Int(), actual)
+		assert.Equal(t, gjson.GetBytes(id.Traits, "backup_email").String(), gjson.GetBytes(stored(t, id).Traits, "backup_email").String())
+	})
+}
@@ -7,13 +7,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"image/png"
+	"strings"
+	"text/template"
 
+	"github.com/boombuler/barcode/qr"
 	"github.com/pkg/errors"
 	"github.com/pquerna/otp"
 	stdtotp "github.com/pquerna/otp/totp"
 
+	"github.com/ory/herodot"
 	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
 )
 
 // rfc4226 recommends:
@@ -27,11 +34,86 @@ import (
 const secretSize = 160 / 8
 const digits = otp.DigitsSix
 
+const (
+	QRCodeFormatPNG = "png"
+	QRCodeFormatSVG = "svg"
+
+	// qrCodeSize is the width and height of rendered QR codes in pixels.
+	qrCodeSize = 256
+
+	// qrCodeQuietZone is the number of light modules surrounding SVG QR codes.
+	qrCodeQuietZone = 4
+)
+
 func NewKey(ctx context.Context, accountName string, d interface {
 	config.Provider
 }) (*otp.Key, error) {
+	return newKey(d.Config().TOTPIssuer(ctx), accountName)
+}
+
+// NewKeyForIdentity generates a key whose issuer and label are rendered from the TOTP templates
+// of the identity's schema. The configured issuer and the account name are used if the schema
+// has no templates.
+func NewKeyForIdentity(ctx context.Context, i *identity.Identity, accountName string, d interface {
+	config.Provider
+}) (*otp.Key, error) {
+	issuer := d.Config().TOTPIssuer(ctx)
+
+	schemas, err := d.Config().IdentityTraitsSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := schemas.FindSchemaByID(i.SchemaID)
+	if err != nil || s.SelfService.TOTP == nil {
+		return newKey(issuer, accountName)
+	}
+
+	var traits map[string]interface{}
+	if len(i.Traits) > 0 {
+		if err := json.Unmarshal(i.Traits, &traits); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	data := &keyTemplateData{ID: i.ID.String(), Traits: traits, Issuer: issuer, AccountName: accountName}
+	if s.SelfService.TOTP.Issuer != "" {
+		if issuer, err = renderKeyTemplate(s.SelfService.TOTP.Issuer, data); err != nil {
+			return nil, err
+		}
+	}
+	if s.SelfService.TOTP.Label != "" {
+		if accountName, err = renderKeyTemplate(s.SelfService.TOTP.Label, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return newKey(issuer, accountName)
+}
+
+type keyTemplateData struct {
+	ID          string
+	Traits      map[string]interface{}
+	Issuer      string
+	AccountName string
+}
+
+func renderKeyTemplate(tpl string, data *keyTemplateData) (string, error) {
+	t, err := template.New("totp").Option("missingkey=zero").Parse(tpl)
+	if err != nil {
+		return "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to parse the TOTP template of the identity schema: %s", err))
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to render the TOTP template of the identity schema: %s", err))
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+func newKey(issuer, accountName string) (*otp.Key, error) {
 	key, err := stdtotp.Generate(stdtotp.GenerateOpts{
-		Issuer:      d.Config().TOTPIssuer(ctx),
+		Issuer:      issuer,
 		AccountName: accountName,
 		SecretSize:  secretSize,
 		Digits:      digits,
@@ -44,16 +126,80 @@ func NewKey(ctx context.Context, accountName string, d interface {
 	return key, err
 }
 
-func KeyToHTMLImage(key *otp.Key) (string, error) {
+// KeyToImage renders the key as a QR code in the given format and returns the image and its
+// content type.
+func KeyToImage(key *otp.Key, format string) ([]byte, string, error) {
+	switch format {
+	case QRCodeFormatSVG:
+		img, err := keyToSVG(key)
+		return img, "image/svg+xml", err
+	case QRCodeFormatPNG, "":
+		img, err := keyToPNG(key)
+		return img, "image/png", err
+	}
+	return nil, "", errors.WithStack(herodot.ErrBadRequest.WithReasonf("The QR code format %q is not supported.", format))
+}
+
+func keyToPNG(key *otp.Key) ([]byte, error) {
 	var buf bytes.Buffer
-	img, err := key.Image(256, 256)
+	img, err := key.Image(qrCodeSize, qrCodeSize)
 	if err != nil {
-		return "", errors.WithStack(err)
+		return nil, errors.WithStack(err)
 	}
 
 	if err := png.Encode(&buf, img); err != nil {
-		return "", errors.WithStack(err)
+		return nil, errors.WithStack(err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// keyToSVG renders the QR code as a single path with one horizontal segment per run of dark
+// modules, which keeps the image small.
+func keyToSVG(key *otp.Key) ([]byte, error) {
+	code, err := qr.Encode(key.String(), qr.M, qr.Auto)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	bounds := code.Bounds()
+	size := bounds.Dx() + 2*qrCodeQuietZone
+	isDark := func(x, y int) bool {
+		r, _, _, _ := code.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+		return r == 0
+	}
+
+	var buf bytes.Buffer
+	_, _ = fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`, size, size, qrCodeSize, qrCodeSize)
+	_, _ = fmt.Fprint(&buf, `<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			if !isDark(x, y) {
+				continue
+			}
+			start := x
+			for x < bounds.Dx() && isDark(x, y) {
+				x++
+			}
+			_, _ = fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start+qrCodeQuietZone, y+qrCodeQuietZone, x-start, x-start)
+		}
+	}
+	_, _ = fmt.Fprint(&buf, `"/></svg>`)
+
+	return buf.Bytes(), nil
+}
+
+// KeyToHTMLImage renders the key as a PNG QR code data URI.
+func KeyToHTMLImage(key *otp.Key) (string, error) {
+	return KeyToHTMLImageWithFormat(key, QRCodeFormatPNG)
+}
+
+// KeyToHTMLImageWithFormat renders the key as a QR code data URI in the given format.
+func KeyToHTMLImageWithFormat(key *otp.Key, format string) (string, error) {
+	img, contentType, err := KeyToImage(key, format)
+	if err != nil {
+		return "", err
 	}
 
-	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(img), nil
 }
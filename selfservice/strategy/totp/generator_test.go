@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/internal"
 	"github.com/ory/kratos/selfservice/strategy/totp"
 )
@@ -34,4 +35,42 @@ func TestGenerator(t *testing.T) {
 	img, err := totp.KeyToHTMLImage(key)
 	require.NoError(t, err)
 	assert.True(t, strings.HasPrefix(img, "data:image/png;base64,"), "image is a base64 encoded png")
+
+	img, err = totp.KeyToHTMLImageWithFormat(key, totp.QRCodeFormatSVG)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(img, "data:image/svg+xml;base64,"), "image is a base64 encoded svg")
+
+	raw, contentType, err := totp.KeyToImage(key, totp.QRCodeFormatSVG)
+	require.NoError(t, err)
+	assert.Equal(t, "image/svg+xml", contentType)
+	assert.True(t, strings.HasPrefix(string(raw), "<svg "))
+	assert.True(t, strings.HasSuffix(string(raw), "</svg>"))
+
+	_, _, err = totp.KeyToImage(key, "gif")
+	require.Error(t, err)
+
+	t.Run("case=renders the templates of the identity schema", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentitySchemas, []config.Schema{{
+			ID:  config.DefaultIdentityTraitsSchemaID,
+			URL: "file://./stub/login.schema.json",
+			SelfService: config.SchemaSelfService{TOTP: &config.SchemaSelfServiceTOTP{
+				Issuer: "{{ .Issuer }} (Staff)",
+				Label:  "{{ .Traits.subject }}",
+			}},
+		}})
+
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"subject":"foo@ory.sh"}`)
+
+		key, err := totp.NewKeyForIdentity(ctx, i, "foo", reg)
+		require.NoError(t, err)
+		assert.Equal(t, "foobar.com (Staff)", key.Issuer())
+		assert.Equal(t, "foo@ory.sh", key.AccountName())
+
+		i.SchemaID = "unknown"
+		key, err = totp.NewKeyForIdentity(ctx, i, "foo", reg)
+		require.NoError(t, err)
+		assert.Equal(t, "foobar.com", key.Issuer())
+		assert.Equal(t, "foo", key.AccountName())
+	})
 }
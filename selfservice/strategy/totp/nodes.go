@@ -23,10 +23,14 @@ func NewTOTPImageQRNode(key *otp.Key) (*node.Node, error) {
 		return nil, err
 	}
 
+	return NewTOTPImageQRNodeWithSource(src), nil
+}
+
+func NewTOTPImageQRNodeWithSource(src string) *node.Node {
 	return node.NewImageField(node.TOTPQR, src, node.TOTPGroup, node.WithImageAttributes(func(a *node.ImageAttributes) {
-		a.Height = 256
-		a.Width = 256
-	})).WithMetaLabel(text.NewInfoSelfServiceSettingsTOTPQRCode()), nil
+		a.Height = qrCodeSize
+		a.Width = qrCodeSize
+	})).WithMetaLabel(text.NewInfoSelfServiceSettingsTOTPQRCode())
 }
 
 func NewTOTPSourceURLNode(key *otp.Key) *node.Node {
@@ -35,6 +39,17 @@ func NewTOTPSourceURLNode(key *otp.Key) *node.Node {
 		WithMetaLabel(text.NewInfoSelfServiceSettingsTOTPSecretLabel())
 }
 
+// NewTOTPSecretKeyInputNode shows the secret as a disabled input, which UIs can render with a
+// copy to clipboard button. Disabled inputs are not submitted with the form.
+func NewTOTPSecretKeyInputNode(key *otp.Key) *node.Node {
+	return node.NewInputField(node.TOTPSecretKey, key.Secret(), node.TOTPGroup,
+		node.InputAttributeTypeText,
+		node.WithInputAttributes(func(a *node.InputAttributes) {
+			a.Disabled = true
+		})).
+		WithMetaLabel(text.NewInfoSelfServiceSettingsTOTPSecretLabel())
+}
+
 func NewUnlinkTOTPNode() *node.Node {
 	return node.NewInputField(node.TOTPUnlink, "true", node.TOTPGroup,
 		node.InputAttributeTypeSubmit,
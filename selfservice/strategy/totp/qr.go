@@ -0,0 +1,159 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package totp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	"github.com/pquerna/otp"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/selfservice/flow/settings"
+	"github.com/ory/kratos/selfservice/strategy"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/urlx"
+)
+
+const (
+	RouteBase   = "/self-service/methods/totp"
+	RouteQRCode = RouteBase + "/qr"
+)
+
+const (
+	QRCodeSourceDataURI  = "data_uri"
+	QRCodeSourceEndpoint = "endpoint"
+)
+
+// qrCodeSource returns the source of the QR code image node, which either embeds the QR code or
+// points to the QR code endpoint.
+func (s *Strategy) qrCodeSource(ctx context.Context, f *settings.Flow, key *otp.Key) (string, error) {
+	format := s.d.Config().TOTPQRCodeFormat(ctx)
+	if s.d.Config().TOTPQRCodeSource(ctx) == QRCodeSourceEndpoint {
+		return urlx.CopyWithQuery(urlx.AppendPaths(s.d.Config().SelfPublicURL(ctx), RouteQRCode), url.Values{
+			"flow":   {f.ID.String()},
+			"format": {format},
+		}).String(), nil
+	}
+
+	return KeyToHTMLImageWithFormat(key, format)
+}
+
+// swagger:parameters getTotpQrCode
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type getTotpQrCode struct {
+	// The ID of the settings flow which sets up TOTP.
+	//
+	// required: true
+	// in: query
+	FlowID string `json:"flow"`
+
+	// The image format, either `png` or `svg`. Defaults to the configured format.
+	//
+	// in: query
+	Format string `json:"format"`
+
+	// The Session Token of the identity, required for native apps.
+	//
+	// in: header
+	SessionToken string `json:"X-Session-Token"`
+
+	// The session cookie of the identity, required for browsers.
+	//
+	// in: header
+	// name: Cookie
+	Cookie string `json:"Cookie"`
+}
+
+// swagger:route GET /self-service/methods/totp/qr frontend getTotpQrCode
+//
+// # Get the TOTP QR Code of a Settings Flow
+//
+// Renders the QR code of the TOTP key which is being set up in the given settings flow. The
+// settings flow's image node points to this endpoint if `selfservice.methods.totp.config.qr_code.source`
+// is set to `endpoint`. The flow must belong to the identity of the session.
+//
+//	Produces:
+//	- image/png
+//	- image/svg+xml
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: emptyResponse
+//	  400: errorGeneric
+//	  403: errorGeneric
+//	  404: errorGeneric
+//	  410: errorGeneric
+//	  default: errorGeneric
+func (s *Strategy) getQRCode(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+
+	sess, err := s.d.SessionManager().FetchFromRequest(ctx, r)
+	if err != nil {
+		s.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	f, err := s.d.SettingsFlowPersister().GetSettingsFlow(ctx, x.ParseUUID(r.URL.Query().Get("flow")))
+	if err != nil {
+		s.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if f.IdentityID != sess.IdentityID {
+		s.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrForbidden.
+			WithID(text.ErrIDInitiatedBySomeoneElse).
+			WithReasonf("The request was made for another identity and has been blocked for security reasons.")))
+		return
+	}
+
+	if f.ExpiresAt.Before(time.Now().UTC()) {
+		s.d.Writer().WriteError(w, r, errors.WithStack(x.ErrGone.WithReason("The settings flow has expired.")))
+		return
+	}
+
+	keyURL := gjson.GetBytes(f.InternalContext, flow.PrefixInternalContextKey(s.ID(), InternalContextKeyURL)).String()
+	if keyURL == "" {
+		s.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrNotFound.WithReason("The settings flow does not set up TOTP.")))
+		return
+	}
+
+	key, err := otp.NewKeyFromURL(keyURL)
+	if err != nil {
+		s.d.Writer().WriteError(w, r, errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReason("Could not decode TOTP key from the internal context.")))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = s.d.Config().TOTPQRCodeFormat(ctx)
+	}
+
+	img, contentType, err := KeyToImage(key, format)
+	if err != nil {
+		s.d.Writer().WriteError(w, r, err)
+		return
+	}
+
+	// The QR code contains the TOTP secret and must never be cached.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(img)
+}
+
+func (s *Strategy) registerQRCodeRoute(r *x.RouterPublic) {
+	if handle, _, _ := r.Lookup("GET", RouteQRCode); handle == nil {
+		r.GET(RouteQRCode, strategy.IsDisabled(s.d, s.ID().String(), s.getQRCode))
+	}
+}
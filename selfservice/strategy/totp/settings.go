@@ -7,7 +7,6 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"time"
 
 	"github.com/ory/x/otelx"
 
@@ -37,7 +36,8 @@ import (
 
 const InternalContextKeyURL = "url"
 
-func (s *Strategy) RegisterSettingsRoutes(_ *x.RouterPublic) {
+func (s *Strategy) RegisterSettingsRoutes(r *x.RouterPublic) {
+	s.registerQRCodeRoute(r)
 }
 
 func (s *Strategy) SettingsStrategyID() string {
@@ -143,8 +143,8 @@ func (s *Strategy) continueSettingsFlow(ctx context.Context, r *http.Request, ct
 		return err
 	}
 
-	if ctxUpdate.Session.AuthenticatedAt.Add(s.d.Config().SelfServiceFlowSettingsPrivilegedSessionMaxAge(ctx)).Before(time.Now()) {
-		return errors.WithStack(settings.NewFlowNeedsReAuth())
+	if err := settings.EnsurePrivilegedSession(r, s.d, ctxUpdate, s.NodeGroup()); err != nil {
+		return err
 	}
 
 	hasTOTP, err := s.identityHasTOTP(ctx, ctxUpdate.Session.Identity)
@@ -278,7 +278,7 @@ func (s *Strategy) PopulateSettingsMethod(ctx context.Context, r *http.Request,
 		_ = s.d.IdentityValidator().ValidateWithRunner(ctx, id, e)
 
 		// No TOTP set up yet, add nodes allowing us to add it.
-		key, err := NewKey(ctx, e.AccountName, s.d)
+		key, err := NewKeyForIdentity(ctx, id, e.AccountName, s.d)
 		if err != nil {
 			return err
 		}
@@ -288,13 +288,17 @@ func (s *Strategy) PopulateSettingsMethod(ctx context.Context, r *http.Request,
 			return err
 		}
 
-		qr, err := NewTOTPImageQRNode(key)
+		src, err := s.qrCodeSource(ctx, f, key)
 		if err != nil {
 			return err
 		}
 
-		f.UI.Nodes.Upsert(NewTOTPSourceURLNode(key))
-		f.UI.Nodes.Upsert(qr)
+		if s.d.Config().TOTPSecretKeyNode(ctx) == "input" {
+			f.UI.Nodes.Upsert(NewTOTPSecretKeyInputNode(key))
+		} else {
+			f.UI.Nodes.Upsert(NewTOTPSourceURLNode(key))
+		}
+		f.UI.Nodes.Upsert(NewTOTPImageQRNodeWithSource(src))
 		f.UI.Nodes.Upsert(NewVerifyTOTPNode())
 		f.UI.Nodes.Append(node.NewInputField("method", "totp", node.TOTPGroup, node.InputAttributeTypeSubmit).WithMetaLabel(text.NewInfoNodeLabelSave()))
 	}
@@ -7,6 +7,7 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/url"
 	"testing"
@@ -86,6 +87,57 @@ func TestCompleteSettings(t *testing.T) {
 		})
 	})
 
+	t.Run("case=device setup renders the QR code at the endpoint", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyTOTPQRCodeSource, totp.QRCodeSourceEndpoint)
+		conf.MustSet(ctx, config.ViperKeyTOTPQRCodeFormat, totp.QRCodeFormatSVG)
+		conf.MustSet(ctx, config.ViperKeyTOTPSecretKeyNode, "input")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyTOTPQRCodeSource, "")
+			conf.MustSet(ctx, config.ViperKeyTOTPQRCodeFormat, "")
+			conf.MustSet(ctx, config.ViperKeyTOTPSecretKeyNode, "")
+		})
+
+		id := createIdentityWithoutTOTP(t, reg)
+		apiClient := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, id)
+		f := testhelpers.InitializeSettingsFlowViaAPI(t, apiClient, publicTS)
+		nodes, err := json.Marshal(f.Ui.Nodes)
+		require.NoError(t, err)
+
+		secret := gjson.GetBytes(nodes, "#(attributes.name==totp_secret_key).attributes")
+		assert.Equal(t, "text", secret.Get("type").String(), "%s", nodes)
+		assert.True(t, secret.Get("disabled").Bool(), "%s", nodes)
+		assert.NotEmpty(t, secret.Get("value").String(), "%s", nodes)
+
+		src := gjson.GetBytes(nodes, "#(attributes.id==totp_qr).attributes.src").String()
+		assert.Equal(t, publicTS.URL+totp.RouteQRCode+"?flow="+f.Id+"&format=svg", src)
+
+		res, err := apiClient.Get(src)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.Equal(t, "image/svg+xml", res.Header.Get("Content-Type"))
+		assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+		assert.Contains(t, string(body), "<svg ")
+
+		res, err = apiClient.Get(publicTS.URL + totp.RouteQRCode + "?flow=" + f.Id + "&format=png")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, "image/png", res.Header.Get("Content-Type"))
+
+		other := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, createIdentityWithoutTOTP(t, reg))
+		res, err = other.Get(src)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res, err = http.Get(src)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
 	doAPIFlow := func(t *testing.T, v func(url.Values), id *identity.Identity) (string, *http.Response) {
 		apiClient := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, id)
 		f := testhelpers.InitializeSettingsFlowViaAPI(t, apiClient, publicTS)
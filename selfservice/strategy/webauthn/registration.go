@@ -90,8 +90,8 @@ func (s *Strategy) handleRegistrationError(r *http.Request, f *registration.Flow
 	return err
 }
 
-func (s *Strategy) decode(p *updateRegistrationFlowWithWebAuthnMethod, r *http.Request) error {
-	return registration.DecodeBody(p, r, s.hd, s.d.Config(), registrationSchema)
+func (s *Strategy) decode(p *updateRegistrationFlowWithWebAuthnMethod, r *http.Request, f *registration.Flow) error {
+	return registration.DecodeBody(p, r, s.hd, s.d.Config(), f, registrationSchema)
 }
 
 func (s *Strategy) Register(_ http.ResponseWriter, r *http.Request, regFlow *registration.Flow, i *identity.Identity) (err error) {
@@ -104,7 +104,7 @@ func (s *Strategy) Register(_ http.ResponseWriter, r *http.Request, regFlow *reg
 	}
 
 	var p updateRegistrationFlowWithWebAuthnMethod
-	if err := s.decode(&p, r); err != nil {
+	if err := s.decode(&p, r, regFlow); err != nil {
 		return s.handleRegistrationError(r, regFlow, p, err)
 	}
 
@@ -153,13 +153,10 @@ func (s *Strategy) Register(_ http.ResponseWriter, r *http.Request, regFlow *reg
 			herodot.ErrInternalServerError.WithReasonf("Unable to get webAuthn config.").WithDebug(err.Error())))
 	}
 
-	credential, err := web.CreateCredential(webauthnx.NewUser(webAuthnSess.UserID, nil, web.Config), webAuthnSess, webAuthnResponse)
+	credential, err := webauthnx.CreateCredential(ctx, s.d, web, s.d.Config().WebAuthnAttestation(ctx), "#/webauthn_register",
+		webauthnx.NewUser(webAuthnSess.UserID, nil, web.Config), webAuthnSess, webAuthnResponse)
 	if err != nil {
-		if devErr := new(protocol.Error); errors.As(err, &devErr) {
-			s.d.Logger().WithError(err).WithField("error_devinfo", devErr.DevInfo).Error("Failed to create WebAuthn credential")
-		}
-		return s.handleRegistrationError(r, regFlow, p, errors.WithStack(
-			herodot.ErrInternalServerError.WithReasonf("Unable to create WebAuthn credential: %s", err)))
+		return s.handleRegistrationError(r, regFlow, p, err)
 	}
 
 	credentialWebAuthn := identity.CredentialFromWebAuthn(credential, true)
@@ -198,7 +195,7 @@ func (s *Strategy) PopulateRegistrationMethod(r *http.Request, f *registration.F
 		return nil
 	}
 
-	ds, err := s.d.Config().DefaultIdentityTraitsSchemaURL(ctx)
+	ds, err := f.IdentitySchemaURL(ctx, s.d.Config())
 	if err != nil {
 		return err
 	}
@@ -30,6 +30,7 @@ import (
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/assertx"
+	"github.com/ory/x/sqlcon"
 )
 
 var (
@@ -482,6 +483,44 @@ func TestRegistration(t *testing.T) {
 		})
 	})
 
+	t.Run("case=should reject authenticators which are not allowed by the attestation policy", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			key  string
+		}{
+			{name: "not in allow list", key: config.ViperKeyWebAuthnAttestationAllowedAAGUIDs},
+			{name: "in deny list", key: config.ViperKeyWebAuthnAttestationDeniedAAGUIDs},
+		} {
+			t.Run("case="+tc.name, func(t *testing.T) {
+				aaguid := x.NewUUID().String()
+				if tc.key == config.ViperKeyWebAuthnAttestationDeniedAAGUIDs {
+					// The fixture was created without attestation, so the authenticator reports the zero AAGUID.
+					aaguid = uuid.Nil.String()
+				}
+				conf.MustSet(ctx, tc.key, []string{aaguid})
+				t.Cleanup(func() {
+					conf.MustSet(ctx, tc.key, nil)
+				})
+
+				for _, f := range flows {
+					t.Run("type="+f, func(t *testing.T) {
+						email := testhelpers.RandomEmail()
+						actual, _, _ := makeRegistration(t, f, func(v url.Values) {
+							v.Set("traits.username", email)
+							v.Set("traits.foobar", "bazbar")
+							v.Set(node.WebAuthnRegister, string(registrationFixtureSuccessResponse))
+							v.Del("method")
+						})
+						assert.Contains(t, actual, text.NewErrorValidationWebAuthnAuthenticatorNotAllowed().Text, "%s", actual)
+
+						_, _, err := reg.PrivilegedIdentityPool().FindByCredentialsIdentifier(ctx, identity.CredentialsTypeWebAuthn, email)
+						require.ErrorIs(t, err, sqlcon.ErrNoRows)
+					})
+				}
+			})
+		}
+	})
+
 	t.Run("case=should fail if no identifier was set in the schema", func(t *testing.T) {
 		testhelpers.SetDefaultIdentitySchema(conf, "file://stub/missing-identifier.schema.json")
 
@@ -21,6 +21,7 @@ import (
 	"github.com/ory/kratos/session"
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/kratos/x"
+	"github.com/ory/kratos/x/webauthnx"
 	"github.com/ory/x/decoderx"
 )
 
@@ -68,6 +69,8 @@ type webauthnStrategyDependencies interface {
 
 	session.HandlerProvider
 	session.ManagementProvider
+
+	webauthnx.MetadataLoaderProvider
 }
 
 type Strategy struct {
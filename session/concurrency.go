@@ -0,0 +1,64 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/x/pointerx"
+)
+
+var (
+	concurrencyRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kratos_session_concurrency_rejections_total",
+		Help: "Number of logins which were rejected because the identity reached the maximum number of active sessions.",
+	})
+
+	concurrencyEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_session_concurrency_evictions_total",
+		Help: "Number of sessions which were revoked because the identity exceeded the maximum number of active sessions, labelled with the policy.",
+	}, []string{"policy"})
+)
+
+// ConcurrencyCollectors returns the Prometheus collectors of the session concurrency limits.
+// They are registered by the registry's metrics setup.
+func ConcurrencyCollectors() []prometheus.Collector {
+	return []prometheus.Collector{concurrencyRejections, concurrencyEvictions}
+}
+
+// EnforceConcurrencyLimit applies the maximum number of active sessions per identity to a session
+// which is about to be issued. Depending on the configured policy, it either rejects the session
+// or revokes the sessions of the identity which exceed the limit. It returns the number of revoked
+// sessions.
+func EnforceConcurrencyLimit(ctx context.Context, c *config.Config, p Persister, s *Session) (int, error) {
+	maxSessions := c.SessionConcurrencyMaxSessions(ctx)
+	if maxSessions <= 0 {
+		return 0, nil
+	}
+
+	policy := c.SessionConcurrencyPolicy(ctx)
+	if policy == config.SessionConcurrencyPolicyReject {
+		// The session itself is excluded as refreshing or upgrading a session does not issue a new one.
+		_, others, err := p.ListSessionsByIdentity(ctx, s.IdentityID, pointerx.Ptr(true), 1, 1, s.ID, ExpandNothing)
+		if err != nil {
+			return 0, err
+		}
+		if others >= int64(maxSessions) {
+			concurrencyRejections.Inc()
+			return 0, schema.NewTooManySessionsError(maxSessions)
+		}
+		return 0, nil
+	}
+
+	revoked, err := p.RevokeExcessSessions(ctx, s.IdentityID, s.ID, maxSessions-1, policy == config.SessionConcurrencyPolicyEvictLeastRecentlyUsed)
+	if err != nil {
+		return 0, err
+	}
+	concurrencyEvictions.WithLabelValues(string(policy)).Add(float64(revoked))
+	return revoked, nil
+}
@@ -0,0 +1,117 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+)
+
+func TestEnforceConcurrencyLimit(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+
+	setup := func(t *testing.T, active int) (*identity.Identity, []*session.Session) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+		sessions := make([]*session.Session, active)
+		for k := range sessions {
+			s, err := testhelpers.NewActiveSession(testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil), reg, i, time.Now().UTC(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+			require.NoError(t, err)
+			s.CreatedAt = time.Now().UTC().Add(-time.Duration(active-k) * time.Minute)
+			require.NoError(t, reg.SessionPersister().UpsertSession(ctx, s))
+			sessions[k] = s
+		}
+		return i, sessions
+	}
+
+	isActive := func(t *testing.T, s *session.Session) bool {
+		actual, err := reg.SessionPersister().GetSession(ctx, s.ID, session.ExpandNothing)
+		require.NoError(t, err)
+		return actual.Active
+	}
+
+	t.Run("case=does nothing without a limit", func(t *testing.T) {
+		i, sessions := setup(t, 3)
+		revoked, err := session.EnforceConcurrencyLimit(ctx, conf, reg.SessionPersister(), &session.Session{IdentityID: i.ID})
+		require.NoError(t, err)
+		assert.Zero(t, revoked)
+		for _, s := range sessions {
+			assert.True(t, isActive(t, s))
+		}
+	})
+
+	conf.MustSet(ctx, config.ViperKeySessionConcurrencyMaxSessions, 2)
+	t.Cleanup(func() {
+		conf.MustSet(ctx, config.ViperKeySessionConcurrencyMaxSessions, 0)
+		conf.MustSet(ctx, config.ViperKeySessionConcurrencyPolicy, "")
+	})
+
+	t.Run("policy=reject", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionConcurrencyPolicy, string(config.SessionConcurrencyPolicyReject))
+
+		t.Run("case=rejects a new session", func(t *testing.T) {
+			i, sessions := setup(t, 2)
+			_, err := session.EnforceConcurrencyLimit(ctx, conf, reg.SessionPersister(), &session.Session{IdentityID: i.ID})
+			var ve *schema.ValidationError
+			require.ErrorAs(t, err, &ve)
+			assert.Equal(t, text.ErrorValidationLoginTooManySessions, ve.Messages[0].ID)
+			for _, s := range sessions {
+				assert.True(t, isActive(t, s))
+			}
+		})
+
+		t.Run("case=allows refreshing an existing session", func(t *testing.T) {
+			_, sessions := setup(t, 2)
+			_, err := session.EnforceConcurrencyLimit(ctx, conf, reg.SessionPersister(), sessions[0])
+			require.NoError(t, err)
+		})
+
+		t.Run("case=allows a new session below the limit", func(t *testing.T) {
+			i, _ := setup(t, 1)
+			_, err := session.EnforceConcurrencyLimit(ctx, conf, reg.SessionPersister(), &session.Session{IdentityID: i.ID})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("policy=evict_oldest", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionConcurrencyPolicy, string(config.SessionConcurrencyPolicyEvictOldest))
+
+		i, sessions := setup(t, 3)
+		revoked, err := session.EnforceConcurrencyLimit(ctx, conf, reg.SessionPersister(), &session.Session{IdentityID: i.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 2, revoked)
+		assert.False(t, isActive(t, sessions[0]))
+		assert.False(t, isActive(t, sessions[1]))
+		assert.True(t, isActive(t, sessions[2]))
+	})
+
+	t.Run("policy=evict_least_recently_used", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionConcurrencyPolicy, string(config.SessionConcurrencyPolicyEvictLeastRecentlyUsed))
+
+		i, sessions := setup(t, 3)
+		require.NoError(t, reg.Persister().GetConnection(ctx).RawQuery("UPDATE sessions SET updated_at = ? WHERE id = ?", time.Now().UTC().Add(time.Minute), sessions[0].ID).Exec())
+
+		revoked, err := session.EnforceConcurrencyLimit(ctx, conf, reg.SessionPersister(), &session.Session{IdentityID: i.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 2, revoked)
+		assert.True(t, isActive(t, sessions[0]))
+		assert.False(t, isActive(t, sessions[1]))
+		assert.False(t, isActive(t, sessions[2]))
+	})
+}
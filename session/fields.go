@@ -0,0 +1,58 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/ory/herodot"
+)
+
+// fieldPath matches dot-separated field paths such as `identity.traits.email`. Other characters
+// are rejected because they have a special meaning in gjson and sjson paths.
+var fieldPath = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// parseFields parses the comma-separated list of field paths of the `fields` query parameter.
+func parseFields(in string) ([]string, error) {
+	if in == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(in, ",")
+	for k, f := range fields {
+		f = strings.TrimSpace(f)
+		if !fieldPath.MatchString(f) {
+			return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The field %q is not a valid field path. Field paths consist of field names separated by dots, for example `identity.traits.email`.", f))
+		}
+		fields[k] = f
+	}
+	return fields, nil
+}
+
+// projectFields returns the JSON encoding of v which only contains the given fields. Fields which
+// do not exist are omitted.
+func projectFields(v any, fields []string) (json.RawMessage, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	projected := []byte("{}")
+	for _, f := range fields {
+		value := gjson.GetBytes(raw, f)
+		if !value.Exists() {
+			continue
+		}
+		if projected, err = sjson.SetRawBytes(projected, f, []byte(value.Raw)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return projected, nil
+}
@@ -0,0 +1,144 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/fetcher"
+	"github.com/ory/x/otelx"
+	"github.com/ory/x/pointerx"
+)
+
+type (
+	geoIPResolverDependencies interface {
+		config.Provider
+		x.HTTPClientProvider
+		x.TracingProvider
+	}
+
+	// GeoIPResolver resolves the location and network of session devices from MaxMind databases.
+	GeoIPResolver struct {
+		r geoIPResolverDependencies
+
+		mu        sync.Mutex
+		databases map[string]*maxminddb.Reader
+	}
+
+	GeoIPResolverProvider interface {
+		SessionGeoIPResolver() *GeoIPResolver
+	}
+
+	geoIPCityRecord struct {
+		City struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"city"`
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+
+	geoIPASNRecord struct {
+		AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+		AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+	}
+)
+
+func NewGeoIPResolver(r geoIPResolverDependencies) *GeoIPResolver {
+	return &GeoIPResolver{r: r, databases: make(map[string]*maxminddb.Reader)}
+}
+
+// database returns the reader of the database at the given location. Databases are loaded once
+// per location, so changing the location in the configuration loads the new database.
+func (g *GeoIPResolver) database(ctx context.Context, location string) (*maxminddb.Reader, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if db, ok := g.databases[location]; ok {
+		return db, nil
+	}
+
+	raw, err := fetcher.NewFetcher(fetcher.WithClient(g.r.HTTPClient(ctx))).FetchBytes(ctx, location)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	db, err := maxminddb.FromBytes(raw)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	g.databases[location] = db
+	return db, nil
+}
+
+// EnrichDevice sets the city, country, and network of the device from the configured databases.
+// If the location of the device was not set from request headers, it is set from the city and
+// country. Devices without an IP address or without a match in the databases are left unchanged.
+func (g *GeoIPResolver) EnrichDevice(ctx context.Context, d *Device) (err error) {
+	ctx, span := g.r.Tracer(ctx).Tracer().Start(ctx, "session.GeoIPResolver.EnrichDevice")
+	defer otelx.End(span, &err)
+
+	if d.IPAddress == nil {
+		return nil
+	}
+	ip := net.ParseIP(*d.IPAddress)
+	if ip == nil {
+		return nil
+	}
+
+	if location := g.r.Config().SessionGeoIPCityDatabase(ctx); location != "" {
+		db, err := g.database(ctx, location)
+		if err != nil {
+			return err
+		}
+
+		var record geoIPCityRecord
+		if err := db.Lookup(ip, &record); err != nil {
+			return errors.WithStack(err)
+		}
+
+		var resolved []string
+		if city := record.City.Names["en"]; city != "" {
+			d.City = pointerx.Ptr(city)
+			resolved = append(resolved, city)
+		}
+		if country := record.Country.ISOCode; country != "" {
+			d.Country = pointerx.Ptr(country)
+			resolved = append(resolved, country)
+		}
+		if (d.Location == nil || *d.Location == "") && len(resolved) > 0 {
+			d.Location = pointerx.Ptr(strings.Join(resolved, ", "))
+		}
+	}
+
+	if location := g.r.Config().SessionGeoIPASNDatabase(ctx); location != "" {
+		db, err := g.database(ctx, location)
+		if err != nil {
+			return err
+		}
+
+		var record geoIPASNRecord
+		if err := db.Lookup(ip, &record); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if record.AutonomousSystemNumber != 0 {
+			d.ASN = pointerx.Ptr(int64(record.AutonomousSystemNumber))
+		}
+		if record.AutonomousSystemOrganization != "" {
+			d.ASOrganization = pointerx.Ptr(record.AutonomousSystemOrganization)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/session"
+	"github.com/ory/x/pointerx"
+)
+
+func writeGeoIPDatabase(t *testing.T, databaseType string, record mmdbtype.Map) []byte {
+	w, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: databaseType, IncludeReservedNetworks: true})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("203.0.113.0/24")
+	require.NoError(t, err)
+	require.NoError(t, w.Insert(network, record))
+
+	var buf bytes.Buffer
+	_, err = w.WriteTo(&buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestGeoIPResolver(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+
+	city := writeGeoIPDatabase(t, "GeoLite2-City", mmdbtype.Map{
+		"city":    mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("Berlin")}},
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("DE")},
+	})
+	asn := writeGeoIPDatabase(t, "GeoLite2-ASN", mmdbtype.Map{
+		"autonomous_system_number":       mmdbtype.Uint32(64496),
+		"autonomous_system_organization": mmdbtype.String("Example Networks"),
+	})
+	asnPath := filepath.Join(t.TempDir(), "asn.mmdb")
+	require.NoError(t, os.WriteFile(asnPath, asn, 0o600))
+
+	t.Run("case=does nothing without databases", func(t *testing.T) {
+		d := session.Device{IPAddress: pointerx.Ptr("203.0.113.10")}
+		require.NoError(t, reg.SessionGeoIPResolver().EnrichDevice(ctx, &d))
+		assert.Nil(t, d.City)
+		assert.Nil(t, d.ASN)
+	})
+
+	conf.MustSet(ctx, config.ViperKeySessionGeoIPCityDatabase, "base64://"+base64.StdEncoding.EncodeToString(city))
+	conf.MustSet(ctx, config.ViperKeySessionGeoIPASNDatabase, "file://"+asnPath)
+	t.Cleanup(func() {
+		conf.MustSet(ctx, config.ViperKeySessionGeoIPCityDatabase, "")
+		conf.MustSet(ctx, config.ViperKeySessionGeoIPASNDatabase, "")
+	})
+
+	t.Run("case=resolves the location and network", func(t *testing.T) {
+		d := session.Device{IPAddress: pointerx.Ptr("203.0.113.10"), Location: pointerx.Ptr("")}
+		require.NoError(t, reg.SessionGeoIPResolver().EnrichDevice(ctx, &d))
+		assert.Equal(t, "Berlin", *d.City)
+		assert.Equal(t, "DE", *d.Country)
+		assert.Equal(t, "Berlin, DE", *d.Location)
+		assert.EqualValues(t, 64496, *d.ASN)
+		assert.Equal(t, "Example Networks", *d.ASOrganization)
+	})
+
+	t.Run("case=keeps the location from request headers", func(t *testing.T) {
+		d := session.Device{IPAddress: pointerx.Ptr("203.0.113.10"), Location: pointerx.Ptr("Munich, DE")}
+		require.NoError(t, reg.SessionGeoIPResolver().EnrichDevice(ctx, &d))
+		assert.Equal(t, "Munich, DE", *d.Location)
+		assert.Equal(t, "Berlin", *d.City)
+	})
+
+	t.Run("case=leaves unknown addresses unchanged", func(t *testing.T) {
+		d := session.Device{IPAddress: pointerx.Ptr("198.51.100.10"), Location: pointerx.Ptr("")}
+		require.NoError(t, reg.SessionGeoIPResolver().EnrichDevice(ctx, &d))
+		assert.Nil(t, d.City)
+		assert.Nil(t, d.Country)
+		assert.Nil(t, d.ASN)
+		assert.Equal(t, "", *d.Location)
+	})
+
+	t.Run("case=fails on invalid databases", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionGeoIPCityDatabase, "base64://"+base64.StdEncoding.EncodeToString([]byte("not a database")))
+		d := session.Device{IPAddress: pointerx.Ptr("203.0.113.10")}
+		require.Error(t, reg.SessionGeoIPResolver().EnrichDevice(ctx, &d))
+	})
+}
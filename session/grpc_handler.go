@@ -0,0 +1,87 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	sessionv1 "github.com/ory/kratos/gen/session/v1"
+	"github.com/ory/kratos/identity"
+)
+
+// GRPCHandler implements the session service of the gRPC API.
+type GRPCHandler struct {
+	sessionv1.UnimplementedSessionServiceServer
+	h *Handler
+}
+
+var _ sessionv1.SessionServiceServer = (*GRPCHandler)(nil)
+
+func NewGRPCHandler(h *Handler) *GRPCHandler {
+	return &GRPCHandler{h: h}
+}
+
+// Whoami returns the session of the session token. It applies the same checks as the whoami
+// endpoint of the public API.
+func (g *GRPCHandler) Whoami(ctx context.Context, req *sessionv1.WhoamiRequest) (*sessionv1.WhoamiResponse, error) {
+	ctx, span := g.h.r.Tracer(ctx).Tracer().Start(ctx, "sessions.GRPCHandler.Whoami")
+	defer span.End()
+
+	// The session manager and the audit log work on HTTP requests, so the token is passed in the
+	// header which native apps use.
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, RouteWhoami, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r.Header.Set("X-Session-Token", req.GetSessionToken())
+
+	s, err := g.h.r.SessionManager().FetchFromRequest(ctx, r)
+	if err != nil {
+		g.h.r.Audit().WithRequest(r).WithError(err).Info("No valid session found.")
+		return nil, ErrNoSessionFound.WithWrap(err)
+	}
+
+	if err := g.h.prepareWhoami(ctx, r, s); err != nil {
+		return nil, err
+	}
+
+	ps, err := protoSession(s)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionv1.WhoamiResponse{Session: ps}, nil
+}
+
+func protoSession(s *Session) (*sessionv1.Session, error) {
+	methods := make([]string, len(s.AMR))
+	for k, m := range s.AMR {
+		methods[k] = string(m.Method)
+	}
+
+	var i *identity.Identity
+	if s.Identity != nil {
+		withoutAdminMetadata := *s.Identity
+		withoutAdminMetadata.MetadataAdmin = nil
+		i = &withoutAdminMetadata
+	}
+	pi, err := identity.ProtoIdentity(i)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionv1.Session{
+		Id:                          s.ID.String(),
+		Active:                      s.IsActive(),
+		AuthenticatorAssuranceLevel: string(s.AuthenticatorAssuranceLevel),
+		AuthenticationMethods:       methods,
+		AuthenticatedAt:             timestamppb.New(s.AuthenticatedAt),
+		IssuedAt:                    timestamppb.New(s.IssuedAt),
+		ExpiresAt:                   timestamppb.New(s.ExpiresAt),
+		Identity:                    pi,
+	}, nil
+}
@@ -5,6 +5,7 @@ package session
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -15,7 +16,9 @@ import (
 
 	"github.com/ory/x/pagination/keysetpagination"
 
+	"github.com/ory/x/otelx"
 	"github.com/ory/x/pointerx"
+	"github.com/ory/x/urlx"
 
 	"github.com/gofrs/uuid"
 	"github.com/julienschmidt/httprouter"
@@ -26,6 +29,7 @@ import (
 	"github.com/ory/herodot"
 
 	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
 	"github.com/ory/kratos/x"
 )
 
@@ -40,6 +44,9 @@ type (
 		config.Provider
 		sessiontokenexchange.PersistenceProvider
 		TokenizerProvider
+		identity.DerivedTraitsMapperProvider
+		identity.PrivilegedPoolProvider
+		x.TransactionPersistenceProvider
 	}
 	HandlerProvider interface {
 		SessionHandler() *Handler
@@ -80,6 +87,7 @@ func (h *Handler) RegisterAdminRoutes(admin *x.RouterAdmin) {
 	admin.GET(AdminRouteIdentitiesSessions, h.listIdentitySessions)
 	admin.DELETE(AdminRouteIdentitiesSessions, h.deleteIdentitySessions)
 	admin.PATCH(AdminRouteSessionExtendId, h.adminSessionExtend)
+	admin.POST(AdminRouteIntrospect, h.introspectSessions)
 
 	admin.DELETE(RouteCollection, x.RedirectToPublicRoute(h.r))
 }
@@ -88,6 +96,7 @@ func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
 	// We need to completely ignore the whoami/logout path so that we do not accidentally set
 	// some cookie.
 	h.r.CSRFHandler().IgnorePath(RouteWhoami)
+	h.r.CSRFHandler().IgnorePath(RouteWhoamiEvents)
 	h.r.CSRFHandler().IgnorePath(RouteCollection)
 	h.r.CSRFHandler().IgnoreGlob(RouteCollection + "/*")
 	h.r.CSRFHandler().IgnoreGlob(RouteCollection + "/*/extend")
@@ -100,8 +109,11 @@ func (h *Handler) RegisterPublicRoutes(public *x.RouterPublic) {
 	public.DELETE(RouteCollection, h.deleteMySessions)
 	public.DELETE(RouteSession, h.deleteMySession)
 	public.GET(RouteCollection, h.listMySessions)
+	public.GET(RouteWhoamiEvents, h.listenToSessionEvents)
 
 	public.GET(RouteExchangeCodeForSessionToken, h.exchangeCode)
+	public.GET(RouteRevokeLink, h.getRevokeLink)
+	public.POST(RouteRevokeLink, h.updateRevokeLink)
 
 	public.DELETE(AdminRouteIdentitiesSessions, x.RedirectToAdminRoute(h.r))
 }
@@ -133,6 +145,14 @@ type toSession struct {
 	//
 	// in: query
 	TokenizeAs string `json:"tokenize_as"`
+
+	// Returns only the given fields of the session
+	//
+	// A comma-separated list of field paths, for example `id,authenticated_at,identity.traits.email`. Fields which
+	// do not exist are omitted. Use this to reduce the size of the response if the session has large traits or metadata.
+	//
+	// in: query
+	Fields string `json:"fields"`
 }
 
 // swagger:route GET /sessions/whoami frontend toSession
@@ -175,11 +195,25 @@ type toSession struct {
 //	console.log(session.tokenized) // The JWT
 //	```
 //
+// Use the `fields` query parameter to only return some fields of the session:
+//
+//	```js
+//	// pseudo-code example
+//	// ...
+//	const session = await client.toSession("the-session-token", { fields: "id,identity.traits.email" })
+//	```
+//
 // Depending on your configuration this endpoint might return a 403 status code if the session has a lower Authenticator
 // Assurance Level (AAL) than is possible for the identity. This can happen if the identity has password + webauthn
 // credentials (which would result in AAL2) but the session has only AAL1. If this error occurs, ask the user
 // to sign in with the second factor or change the configuration.
 //
+// If the MFA enrollment campaign is enabled, the `mfa_enrollment` field is set for identities which are asked to set
+// up a second factor. Once the deadline of the campaign passed, this endpoint returns a 403 status code for them.
+//
+// If a maximum password age is configured, the `password_expiry` field is set once the password of the identity
+// expires soon. Signing in with an expired password requires the identity to set a new password first.
+//
 // This endpoint is useful for:
 //
 // - AJAX calls. Remember to send credentials and set up CORS correctly!
@@ -198,6 +232,8 @@ type toSession struct {
 //
 // - `session_inactive`: No active session was found in the request (e.g. no Ory Session Cookie / Ory Session Token).
 // - `session_aal2_required`: An active session was found but it does not fulfil the Authenticator Assurance Level, implying that the session must (e.g.) authenticate the second factor.
+// - `session_mfa_enrollment_required`: An active session was found but the deadline of the MFA enrollment campaign passed and the identity has not set up a second factor yet.
+// - `session_password_reset_required`: An active session was found but an administrator required the identity to set a new password. The session can only be used in the settings flow until then.
 //
 //	Produces:
 //	- application/json
@@ -226,22 +262,16 @@ func (h *Handler) whoami(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 		return
 	}
 
-	var aalErr *ErrAALNotSatisfied
-	if err := h.r.SessionManager().DoesSessionSatisfy(ctx, s, c.SessionWhoAmIAAL(ctx),
-		// For the time being we want to update the AAL in the database if it is unset.
-		UpsertAAL,
-	); errors.As(err, &aalErr) {
-		h.r.Audit().WithRequest(r).WithError(err).Info("Session was found but AAL is not satisfied for calling this endpoint.")
+	if err := h.prepareWhoami(ctx, r, s); err != nil {
 		h.r.Writer().WriteError(w, r, err)
 		return
-	} else if err != nil {
-		h.r.Audit().WithRequest(r).WithError(err).Info("No valid session cookie found.")
-		h.r.Writer().WriteError(w, r, herodot.ErrUnauthorized.WithWrap(err).WithReasonf("Unable to determine AAL."))
-		return
 	}
 
-	// s.Devices = nil
-	s.Identity = s.Identity.CopyWithoutCredentials()
+	fields, err := parseFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
 
 	tokenizeTemplate := r.URL.Query().Get("tokenize_as")
 	if tokenizeTemplate != "" {
@@ -270,7 +300,106 @@ func (h *Handler) whoami(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 		return
 	}
 
-	h.r.Writer().Write(w, r, s)
+	var payload any = s
+	if len(fields) > 0 {
+		projected, err := projectFields(s, fields)
+		if err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+		payload = projected
+	}
+
+	// Tokenized sessions are signed at request time and are never the same.
+	if len(tokenizeTemplate) > 0 {
+		h.r.Writer().Write(w, r, payload)
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(err))
+		return
+	}
+	if x.NotModified(w, r, whoamiETag(body)) {
+		return
+	}
+	h.r.Writer().Write(w, r, json.RawMessage(body))
+}
+
+// whoamiETag derives the entity tag of the whoami response from its body, so that it changes
+// whenever any field of the session, its identity or the computed fields change.
+func whoamiETag(body []byte) string {
+	return x.WeakETag(string(body))
+}
+
+// prepareWhoami checks that the session satisfies the requirements of the whoami endpoint and
+// removes the credentials of its identity.
+// Whoami returns the session of the session token in-process. The session must satisfy the same
+// requirements as for the whoami endpoint.
+func (h *Handler) Whoami(ctx context.Context, sessionToken string) (_ *Session, err error) {
+	ctx, span := h.r.Tracer(ctx).Tracer().Start(ctx, "sessions.Handler.Whoami")
+	defer otelx.End(span, &err)
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, urlx.AppendPaths(h.r.Config().SelfPublicURL(ctx), RouteWhoami).String(), nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r.Header.Set("X-Session-Token", sessionToken)
+
+	s, err := h.r.SessionManager().FetchFromRequest(ctx, r)
+	if err != nil {
+		return nil, errors.WithStack(ErrNoSessionFound.WithWrap(err))
+	}
+
+	if err := h.prepareWhoami(ctx, r, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (h *Handler) prepareWhoami(ctx context.Context, r *http.Request, s *Session) error {
+	c := h.r.Config()
+
+	var aalErr *ErrAALNotSatisfied
+	if err := h.r.SessionManager().DoesSessionSatisfy(ctx, s, c.SessionWhoAmIAAL(ctx),
+		// For the time being we want to update the AAL in the database if it is unset.
+		UpsertAAL,
+	); errors.As(err, &aalErr) {
+		h.r.Audit().WithRequest(r).WithError(err).Info("Session was found but AAL is not satisfied for calling this endpoint.")
+		return err
+	} else if err != nil {
+		h.r.Audit().WithRequest(r).WithError(err).Info("No valid session cookie found.")
+		return herodot.ErrUnauthorized.WithWrap(err).WithReasonf("Unable to determine AAL.")
+	}
+
+	if s.PasswordResetRequired {
+		h.r.Audit().WithRequest(r).WithField("identity_id", s.IdentityID).Info("Session was found but the identity must set a new password.")
+		return NewErrPasswordResetRequired(settingsFlowURL(ctx, c, "").String())
+	}
+
+	enrollment, err := EvaluateMFAEnrollment(ctx, c, s.Identity)
+	if err != nil {
+		return err
+	}
+	if enrollment != nil && enrollment.Enforced {
+		mfaEnrollmentRejections.WithLabelValues(s.Identity.SchemaID).Inc()
+		h.r.Audit().WithRequest(r).WithField("identity_id", s.Identity.ID).Info("Session was found but the identity must set up a second factor.")
+		return NewErrMFAEnrollmentRequired(settingsFlowURL(ctx, c, "").String())
+	}
+	s.MFAEnrollment = enrollment
+	s.PasswordExpiry = EvaluatePasswordExpiry(ctx, c, s)
+
+	// s.Devices = nil
+	s.Identity = s.Identity.CopyWithoutCredentials()
+
+	if s.Identity != nil {
+		if err := h.r.IdentityDerivedTraitsMapper().DeriveTraits(ctx, s.Identity); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Delete Identity Session Parameters
@@ -314,6 +443,7 @@ func (h *Handler) deleteIdentitySessions(w http.ResponseWriter, r *http.Request,
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
+	h.r.Audit().WithRequest(r).WithField("identity_id", iID).Info("An administrator deleted all sessions of an identity.")
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -421,6 +551,13 @@ func (h *Handler) adminListSessions(w http.ResponseWriter, r *http.Request, ps h
 		return
 	}
 
+	for k := range sess {
+		if err := h.prepareIdentity(r.Context(), &sess[k]); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+	}
+
 	u := *r.URL
 	keysetpagination.Header(w, &u, nextPage)
 	h.r.Writer().Write(w, r, sess)
@@ -500,6 +637,11 @@ func (h *Handler) getSession(w http.ResponseWriter, r *http.Request, ps httprout
 		return
 	}
 
+	if err := h.prepareIdentity(r.Context(), sess); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
 	h.r.Writer().Write(w, r, sess)
 }
 
@@ -547,6 +689,7 @@ func (h *Handler) disableSession(w http.ResponseWriter, r *http.Request, ps http
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
+	h.r.Audit().WithRequest(r).WithField("session_id", sID).Info("An administrator revoked a session.")
 
 	h.r.Writer().WriteCode(w, r, http.StatusNoContent, nil)
 }
@@ -628,6 +771,13 @@ func (h *Handler) listIdentitySessions(w http.ResponseWriter, r *http.Request, p
 		return
 	}
 
+	for k := range sess {
+		if err := h.prepareIdentity(r.Context(), &sess[k]); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+	}
+
 	x.PaginationHeader(w, *r.URL, total, page, perPage)
 	h.r.Writer().Write(w, r, sess)
 }
@@ -689,6 +839,10 @@ func (h *Handler) deleteMySessions(w http.ResponseWriter, r *http.Request, _ htt
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
+	h.r.Audit().WithRequest(r).
+		WithField("identity_id", s.IdentityID).
+		WithField("revoked_sessions", n).
+		Info("An identity revoked all of its other sessions.")
 
 	h.r.Writer().WriteCode(w, r, http.StatusOK, &deleteMySessionsCount{Count: n})
 }
@@ -764,6 +918,10 @@ func (h *Handler) deleteMySession(w http.ResponseWriter, r *http.Request, ps htt
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
+	h.r.Audit().WithRequest(r).
+		WithField("identity_id", s.Identity.ID).
+		WithField("session_id", sessionID).
+		Info("An identity revoked one of its sessions.")
 
 	h.r.Writer().WriteCode(w, r, http.StatusNoContent, nil)
 }
@@ -847,6 +1005,13 @@ func (h *Handler) listMySessions(w http.ResponseWriter, r *http.Request, _ httpr
 		return
 	}
 
+	for k := range sess {
+		if err := h.prepareIdentity(r.Context(), &sess[k]); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+	}
+
 	x.PaginationHeader(w, *r.URL, total, page, perPage)
 	h.r.Writer().Write(w, r, sess)
 }
@@ -939,9 +1104,32 @@ func (h *Handler) adminSessionExtend(w http.ResponseWriter, r *http.Request, ps
 		h.r.Writer().WriteError(w, r, err)
 		return
 	}
+	if err := h.prepareIdentity(r.Context(), s); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
 	h.r.Writer().Write(w, r, s)
 }
 
+// prepareIdentity sets the derived traits of the session's identity and removes the fields which
+// the admin API token of the request is not allowed to read.
+func (h *Handler) prepareIdentity(ctx context.Context, s *Session) error {
+	if s.Identity == nil {
+		return nil
+	}
+
+	if err := h.r.IdentityDerivedTraitsMapper().DeriveTraits(ctx, s.Identity); err != nil {
+		return err
+	}
+
+	redacted, err := identity.RedactForAdminAPIToken(ctx, *s.Identity)
+	if err != nil {
+		return err
+	}
+	s.Identity = &redacted
+	return nil
+}
+
 func (h *Handler) IsNotAuthenticated(wrap httprouter.Handle, onAuthenticated httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		if _, err := h.r.SessionManager().FetchFromRequest(r.Context(), r); err != nil {
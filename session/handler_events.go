@@ -0,0 +1,213 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/sqlcon"
+
+	"github.com/ory/kratos/x"
+)
+
+const RouteWhoamiEvents = RouteWhoami + "/events"
+
+// Session Event Type
+//
+// swagger:enum sessionEventType
+type EventType string
+
+const (
+	// EventTypeExpiring is sent once the session expires or becomes idle within `session.events.warn_before`.
+	EventTypeExpiring EventType = "session_expiring"
+
+	// EventTypeExtended is sent if a session for which EventTypeExpiring was sent has been extended.
+	EventTypeExtended EventType = "session_extended"
+
+	// EventTypeExpired is sent once the session has expired or become idle. It is the last event of the stream.
+	EventTypeExpired EventType = "session_expired"
+
+	// EventTypeRevoked is sent once the session was revoked, for example because the user
+	// signed out on another tab or an administrator revoked the session. It is the last event of the stream.
+	EventTypeRevoked EventType = "session_revoked"
+)
+
+// Session Event
+//
+// The payload of an event sent by the session event stream.
+//
+// swagger:model sessionEvent
+type Event struct {
+	// The ID of the session.
+	//
+	// required: true
+	SessionID string `json:"session_id"`
+
+	// The time the session expires at. If the session becomes idle earlier, this is the time it
+	// becomes idle unless it is used again.
+	//
+	// required: true
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// The number of seconds until the session expires or becomes idle.
+	//
+	// required: true
+	ExpiresIn int64 `json:"expires_in"`
+
+	// Extendable is true if the session may be extended right now, respecting `session.earliest_possible_extend`.
+	//
+	// required: true
+	Extendable bool `json:"extendable"`
+}
+
+// Listen to Session Events Parameters
+//
+// swagger:parameters listenToSessionEvents
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type listenToSessionEvents struct {
+	// Set the Session Token when calling from non-browser clients.
+	//
+	// in: header
+	SessionToken string `json:"X-Session-Token"`
+
+	// Set the Cookie Header when calling from a server-side application.
+	//
+	// in: header
+	Cookie string `json:"Cookie"`
+}
+
+// swagger:route GET /sessions/whoami/events frontend listenToSessionEvents
+//
+// # Listen to Session Events
+//
+// Opens a stream of server-sent events (`text/event-stream`) for the session which authenticated the request.
+// Use it to warn users before they are signed out, for example using the browser's `EventSource` API:
+//
+//	```js
+//	// pseudo-code example
+//	const events = new EventSource('/sessions/whoami/events', { withCredentials: true })
+//	events.addEventListener('session_expiring', (e) => {
+//	  const { expires_in, extendable } = JSON.parse(e.data)
+//	  // Show "you are about to be logged out" prompt
+//	})
+//	```
+//
+// The following events are sent, each with a JSON `sessionEvent` payload:
+//
+// - `session_expiring`: The session expires or becomes idle within `session.events.warn_before`.
+// - `session_extended`: The session was extended or used after `session_expiring` was sent.
+// - `session_expired`: The session has expired or become idle. The stream ends after this event.
+// - `session_revoked`: The session was revoked. The stream ends after this event.
+//
+// If the request is not authenticated, the endpoint returns a HTTP 401 status code.
+//
+//	Produces:
+//	- text/event-stream
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: sessionEvent
+//	  401: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) listenToSessionEvents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+
+	s, err := h.r.SessionManager().FetchFromRequest(ctx, r)
+	if err != nil {
+		h.r.Audit().WithRequest(r).WithError(err).Info("No valid session found.")
+		h.r.Writer().WriteError(w, r, ErrNoSessionFound.WithWrap(err))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		h.r.Logger().WithError(err).Error("Unable to flush session event stream.")
+		return
+	}
+
+	send := func(t EventType, s *Session, expiresAt time.Time) error {
+		payload, err := json.Marshal(&Event{
+			SessionID:  s.ID.String(),
+			ExpiresAt:  expiresAt,
+			ExpiresIn:  int64(expiresAt.Sub(x.Now()).Round(time.Second).Seconds()),
+			Extendable: s.CanBeRefreshed(ctx, h.r.Config()),
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", t, payload); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(rc.Flush())
+	}
+
+	ticker := time.NewTicker(h.r.Config().SessionEventsCheckInterval(ctx))
+	defer ticker.Stop()
+
+	var warned time.Time
+	for {
+		// The session is signed out once it expires or becomes idle, whichever happens first.
+		expiresAt := s.ExpiresAtOrIdle(ctx, h.r.Config())
+		now := x.Now()
+
+		switch {
+		case !expiresAt.After(now):
+			_ = send(EventTypeExpired, s, expiresAt)
+			return
+		case !s.IsActive():
+			_ = send(EventTypeRevoked, s, expiresAt)
+			return
+		case expiresAt.Sub(now) <= h.r.Config().SessionEventsWarnBefore(ctx):
+			if !warned.Equal(expiresAt) {
+				if err := send(EventTypeExpiring, s, expiresAt); err != nil {
+					return
+				}
+				warned = expiresAt
+			}
+		case !warned.IsZero():
+			if err := send(EventTypeExtended, s, expiresAt); err != nil {
+				return
+			}
+			warned = time.Time{}
+		default:
+			// Keeps the connection from being closed by proxies.
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			} else if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := h.r.SessionPersister().GetSession(ctx, s.ID, ExpandNothing)
+		if errors.Is(err, sqlcon.ErrNoRows) {
+			s.Active = false
+			continue
+		} else if err != nil {
+			// The client is expected to reconnect.
+			h.r.Logger().WithError(err).Error("Unable to fetch session for session event stream.")
+			return
+		}
+		s = current
+	}
+}
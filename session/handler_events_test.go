@@ -0,0 +1,169 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	. "github.com/ory/kratos/session"
+)
+
+type sessionEvent struct {
+	Type EventType
+	Data string
+}
+
+func TestSessionEvents(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	ts, _ := testhelpers.NewKratosServer(t, reg)
+
+	conf.MustSet(ctx, config.ViperKeySessionEventsCheckInterval, "10ms")
+	conf.MustSet(ctx, config.ViperKeySessionEventsWarnBefore, "1m")
+
+	newSession := func(t *testing.T, expiresIn time.Duration) (*Session, *http.Client) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+		req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+		s, err := testhelpers.NewActiveSession(req, reg, i, time.Now(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+		require.NoError(t, err)
+		s.ExpiresAt = time.Now().Add(expiresIn).UTC()
+		return s, testhelpers.NewHTTPClientWithSessionToken(t, ctx, reg, s)
+	}
+
+	listen := func(t *testing.T, hc *http.Client) <-chan sessionEvent {
+		ctx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+
+		req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+RouteWhoamiEvents, nil)
+		require.NoError(t, err)
+		res, err := hc.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		events := make(chan sessionEvent)
+		go func() {
+			defer close(events)
+			defer res.Body.Close()
+
+			var e sessionEvent
+			scanner := bufio.NewScanner(res.Body)
+			for scanner.Scan() {
+				switch line := scanner.Text(); {
+				case strings.HasPrefix(line, "event: "):
+					e.Type = EventType(strings.TrimPrefix(line, "event: "))
+				case strings.HasPrefix(line, "data: "):
+					e.Data = strings.TrimPrefix(line, "data: ")
+				case line == "" && e.Type != "":
+					events <- e
+					e = sessionEvent{}
+				}
+			}
+		}()
+		return events
+	}
+
+	next := func(t *testing.T, events <-chan sessionEvent) sessionEvent {
+		select {
+		case e, ok := <-events:
+			require.True(t, ok, "the event stream was closed")
+			return e
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for session event")
+		}
+		return sessionEvent{}
+	}
+
+	requireClosed := func(t *testing.T, events <-chan sessionEvent) {
+		select {
+		case e, ok := <-events:
+			require.False(t, ok, "expected the event stream to be closed but got %+v", e)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for the event stream to be closed")
+		}
+	}
+
+	t.Run("case=requires a session", func(t *testing.T) {
+		res, err := http.Get(ts.URL + RouteWhoamiEvents)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("case=warns before the session expires", func(t *testing.T) {
+		s, hc := newSession(t, time.Second)
+		events := listen(t, hc)
+
+		e := next(t, events)
+		assert.Equal(t, EventTypeExpiring, e.Type)
+		assert.Equal(t, s.ID.String(), gjson.Get(e.Data, "session_id").String(), e.Data)
+		assert.LessOrEqual(t, gjson.Get(e.Data, "expires_in").Int(), int64(1), e.Data)
+		assert.True(t, gjson.Get(e.Data, "extendable").Exists(), e.Data)
+
+		e = next(t, events)
+		assert.Equal(t, EventTypeExpired, e.Type)
+		requireClosed(t, events)
+	})
+
+	t.Run("case=notifies when an expiring session was extended", func(t *testing.T) {
+		s, hc := newSession(t, 30*time.Second)
+		events := listen(t, hc)
+		assert.Equal(t, EventTypeExpiring, next(t, events).Type)
+
+		require.NoError(t, reg.SessionPersister().ExtendSession(ctx, s.ID))
+		e := next(t, events)
+		assert.Equal(t, EventTypeExtended, e.Type)
+		assert.Greater(t, gjson.Get(e.Data, "expires_in").Int(), int64(60), e.Data)
+	})
+
+	t.Run("case=warns before the session becomes idle", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "2s")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "0s") })
+
+		s, hc := newSession(t, time.Hour)
+		events := listen(t, hc)
+
+		e := next(t, events)
+		assert.Equal(t, EventTypeExpiring, e.Type)
+		assert.Equal(t, s.ID.String(), gjson.Get(e.Data, "session_id").String(), e.Data)
+		assert.LessOrEqual(t, gjson.Get(e.Data, "expires_in").Int(), int64(2), e.Data)
+
+		e = next(t, events)
+		assert.Equal(t, EventTypeExpired, e.Type)
+		requireClosed(t, events)
+	})
+
+	t.Run("case=notifies when the session was revoked", func(t *testing.T) {
+		s, hc := newSession(t, time.Hour)
+		events := listen(t, hc)
+
+		require.NoError(t, reg.SessionPersister().RevokeSessionById(ctx, s.ID))
+		assert.Equal(t, EventTypeRevoked, next(t, events).Type)
+		requireClosed(t, events)
+	})
+
+	t.Run("case=notifies when the session was deleted", func(t *testing.T) {
+		s, hc := newSession(t, time.Hour)
+		events := listen(t, hc)
+
+		require.NoError(t, reg.SessionPersister().DeleteSession(ctx, s.ID))
+		assert.Equal(t, EventTypeRevoked, next(t, events).Type)
+		requireClosed(t, events)
+	})
+}
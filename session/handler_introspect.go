@@ -0,0 +1,186 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/x/jsonx"
+)
+
+const AdminRouteIntrospect = RouteCollection + "/introspect"
+
+// Batch Session Introspection Request Body
+//
+// swagger:model introspectSessionsBody
+type IntrospectSessionsBody struct {
+	// The sessions to introspect. The number of items is limited by `session.introspect.max_items`.
+	//
+	// required: true
+	Items []IntrospectSessionsItem `json:"items"`
+}
+
+// Batch Session Introspection Item
+//
+// Identifies one session by either its token or its ID.
+//
+// swagger:model introspectSessionsItem
+type IntrospectSessionsItem struct {
+	// The session token.
+	SessionToken string `json:"session_token,omitempty"`
+
+	// The session ID.
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// Batch Session Introspection Response
+//
+// swagger:model introspectSessionsResponse
+type IntrospectSessionsResponse struct {
+	// The results in the order of the request items.
+	//
+	// required: true
+	Items []IntrospectSessionsResult `json:"items"`
+}
+
+// Batch Session Introspection Result
+//
+// swagger:model introspectSessionsResult
+type IntrospectSessionsResult struct {
+	// Active is true if the session exists and would be accepted by `/sessions/whoami`: it is
+	// active, its identity is active, and it was used within the idle timeout.
+	//
+	// required: true
+	Active bool `json:"active"`
+
+	// The session, if it exists. Inactive sessions are included as well.
+	Session *Session `json:"session,omitempty"`
+
+	// The error which occurred while looking up this item.
+	Error *herodot.DefaultError `json:"error,omitempty"`
+}
+
+// Batch Session Introspection Parameters
+//
+// swagger:parameters introspectSessions
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type introspectSessions struct {
+	// in: body
+	// required: true
+	Body IntrospectSessionsBody
+}
+
+// Batch Session Introspection Response
+//
+// swagger:response introspectSessions
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type introspectSessionsResponse struct {
+	// in: body
+	Body IntrospectSessionsResponse
+}
+
+// swagger:route POST /admin/sessions/introspect identity introspectSessions
+//
+// # Introspect Sessions
+//
+// Looks up several sessions by their tokens or IDs in one request. This endpoint is useful for
+// gateways which validate many session tokens at once.
+//
+// The results are returned in the order of the request items. Items which could not be looked up,
+// for example because the session does not exist, contain an error instead of the session and do
+// not fail the request.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Security:
+//	  oryAccessToken:
+//
+//	Responses:
+//	  200: introspectSessions
+//	  400: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) introspectSessions(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+
+	var body IntrospectSessionsBody
+	if err := jsonx.NewStrictDecoder(r.Body).Decode(&body); err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithError(err.Error())))
+		return
+	}
+
+	if len(body.Items) == 0 {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReason("At least one session token or ID must be given.")))
+		return
+	} else if max := h.r.Config().SessionIntrospectMaxItems(ctx); len(body.Items) > max {
+		h.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("At most %d session tokens or IDs can be introspected at once.", max)))
+		return
+	}
+
+	res := IntrospectSessionsResponse{Items: make([]IntrospectSessionsResult, len(body.Items))}
+	for k, item := range body.Items {
+		s, err := h.introspectSessionsItem(r, item)
+		if err != nil {
+			res.Items[k].Error = introspectSessionsError(err)
+			if res.Items[k].Error.StatusCode() >= http.StatusInternalServerError {
+				h.r.Logger().WithRequest(r).WithError(err).Error("Unable to introspect a session.")
+			}
+			continue
+		}
+		res.Items[k].Active = s.IsUsable(ctx, h.r.Config())
+		if err := h.prepareIdentity(ctx, s); err != nil {
+			h.r.Writer().WriteError(w, r, err)
+			return
+		}
+		res.Items[k].Session = s
+	}
+
+	h.r.Writer().Write(w, r, &res)
+}
+
+func (h *Handler) introspectSessionsItem(r *http.Request, item IntrospectSessionsItem) (*Session, error) {
+	switch {
+	case item.SessionToken != "" && item.SessionID != "":
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("Either a session token or a session ID must be given, but not both."))
+	case item.SessionToken != "":
+		return h.r.SessionPersister().GetSessionByToken(r.Context(), item.SessionToken, ExpandDefault, identity.ExpandDefault)
+	case item.SessionID != "":
+		id, err := uuid.FromString(item.SessionID)
+		if err != nil {
+			return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The session ID %q is not a valid UUID.", item.SessionID).WithWrap(err))
+		}
+		return h.r.SessionPersister().GetSession(r.Context(), id, ExpandDefault)
+	default:
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("Either a session token or a session ID must be given."))
+	}
+}
+
+// introspectSessionsError returns the error of an item without internal details.
+func introspectSessionsError(err error) *herodot.DefaultError {
+	var e *herodot.DefaultError
+	if !errors.As(err, &e) || e.StatusCode() >= http.StatusInternalServerError {
+		return herodot.ErrInternalServerError.WithReason("The session could not be looked up.")
+	}
+	return &herodot.DefaultError{
+		CodeField:   e.CodeField,
+		StatusField: e.StatusField,
+		ErrorField:  e.ErrorField,
+		ReasonField: e.ReasonField,
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	. "github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+)
+
+func TestIntrospectSessions(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	_, ts, _, _ := testhelpers.NewKratosServerWithCSRFAndRouters(t, reg)
+
+	introspect := func(t *testing.T, body any) (*http.Response, string) {
+		raw, err := json.Marshal(body)
+		require.NoError(t, err)
+		res, err := ts.Client().Post(ts.URL+"/admin"+AdminRouteIntrospect, "application/json", bytes.NewReader(raw))
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(res.Body)
+		require.NoError(t, err)
+		return res, buf.String()
+	}
+
+	t.Run("case=returns partial results in request order", func(t *testing.T) {
+		active := testhelpers.CreateSession(t, reg)
+		revoked := testhelpers.CreateSession(t, reg)
+		require.NoError(t, reg.SessionPersister().RevokeSessionById(ctx, revoked.ID))
+
+		res, body := introspect(t, IntrospectSessionsBody{Items: []IntrospectSessionsItem{
+			{SessionToken: active.Token},
+			{SessionID: revoked.ID.String()},
+			{SessionToken: "does-not-exist"},
+			{SessionID: x.NewUUID().String()},
+			{SessionID: "not-a-uuid"},
+			{SessionToken: active.Token, SessionID: active.ID.String()},
+			{},
+		}})
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+
+		items := gjson.Get(body, "items").Array()
+		require.Len(t, items, 7, body)
+
+		assert.True(t, items[0].Get("active").Bool(), body)
+		assert.Equal(t, active.ID.String(), items[0].Get("session.id").String(), body)
+		assert.Equal(t, active.IdentityID.String(), items[0].Get("session.identity.id").String(), body)
+		assert.False(t, items[0].Get("error").Exists(), body)
+
+		assert.False(t, items[1].Get("active").Bool(), body)
+		assert.Equal(t, revoked.ID.String(), items[1].Get("session.id").String(), body)
+
+		for k, code := range map[int]int64{2: 404, 3: 404, 4: 400, 5: 400, 6: 400} {
+			assert.False(t, items[k].Get("active").Bool(), "%d: %s", k, body)
+			assert.False(t, items[k].Get("session").Exists(), "%d: %s", k, body)
+			assert.Equal(t, code, items[k].Get("error.code").Int(), "%d: %s", k, body)
+		}
+	})
+
+	t.Run("case=idle sessions and sessions of inactive identities are not active", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "10m")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "0s") })
+
+		active := testhelpers.CreateSession(t, reg)
+		idle := testhelpers.CreateSession(t, reg)
+		require.NoError(t, reg.SessionPersister().UpdateSessionActivity(ctx, idle.ID, time.Now().Add(-11*time.Minute)))
+		require.NoError(t, reg.Persister().GetConnection(ctx).RawQuery("UPDATE sessions SET authenticated_at = ? WHERE id = ?", time.Now().UTC().Add(-30*time.Minute), idle.ID).Exec())
+
+		disabled := testhelpers.CreateSession(t, reg)
+		disabled.Identity.State = identity.StateInactive
+		require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, disabled.Identity, "state"))
+
+		res, body := introspect(t, IntrospectSessionsBody{Items: []IntrospectSessionsItem{
+			{SessionToken: active.Token},
+			{SessionToken: idle.Token},
+			{SessionID: disabled.ID.String()},
+		}})
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+
+		items := gjson.Get(body, "items").Array()
+		require.Len(t, items, 3, body)
+		assert.True(t, items[0].Get("active").Bool(), body)
+		assert.False(t, items[1].Get("active").Bool(), body)
+		assert.Equal(t, idle.ID.String(), items[1].Get("session.id").String(), body)
+		assert.False(t, items[2].Get("active").Bool(), body)
+		assert.Equal(t, disabled.ID.String(), items[2].Get("session.id").String(), body)
+	})
+
+	t.Run("case=rejects empty requests", func(t *testing.T) {
+		res, body := introspect(t, IntrospectSessionsBody{})
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+	})
+
+	t.Run("case=rejects unknown fields", func(t *testing.T) {
+		res, body := introspect(t, map[string]any{"tokens": []string{"foo"}})
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+	})
+
+	t.Run("case=rejects too many items", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionIntrospectMaxItems, 2)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySessionIntrospectMaxItems, nil) })
+
+		res, body := introspect(t, IntrospectSessionsBody{Items: make([]IntrospectSessionsItem, 3)})
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+		assert.Contains(t, gjson.Get(body, "error.reason").String(), "At most 2", body)
+	})
+}
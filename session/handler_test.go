@@ -251,6 +251,121 @@ func TestSessionWhoAmI(t *testing.T) {
 		assert.Empty(t, res.Header.Get("Ory-Session-Cache-For"))
 	})
 
+	t.Run("case=supports conditional requests", func(t *testing.T) {
+		s := testhelpers.CreateSession(t, reg)
+
+		whoami := func(t *testing.T, ifNoneMatch string) *http.Response {
+			req, err := http.NewRequest("GET", ts.URL+RouteWhoami, nil)
+			require.NoError(t, err)
+			req.Header.Set("X-Session-Token", s.Token)
+			if ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", ifNoneMatch)
+			}
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = res.Body.Close() })
+			return res
+		}
+
+		res := whoami(t, "")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		etag := res.Header.Get("ETag")
+		require.True(t, strings.HasPrefix(etag, `W/"`), etag)
+
+		res = whoami(t, etag)
+		assert.Equal(t, http.StatusNotModified, res.StatusCode)
+		assert.Empty(t, x.MustReadAll(res.Body))
+		assert.Equal(t, etag, res.Header.Get("ETag"))
+
+		require.NoError(t, reg.SessionPersister().ExtendSession(ctx, s.ID))
+		s.Identity.Traits = identity.Traits(`{"email":"` + x.NewUUID().String() + `@ory.sh"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentity(ctx, s.Identity))
+
+		res = whoami(t, etag)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.NotEqual(t, etag, res.Header.Get("ETag"))
+
+		t.Run("case=changes if only the addresses change", func(t *testing.T) {
+			s.Identity.VerifiableAddresses = []identity.VerifiableAddress{
+				*identity.NewVerifiableEmailAddress(x.NewUUID().String()+"@ory.sh", s.Identity.ID),
+			}
+			require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentity(ctx, s.Identity))
+
+			res := whoami(t, "")
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			etag := res.Header.Get("ETag")
+
+			actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, s.Identity.ID, identity.ExpandEverything)
+			require.NoError(t, err)
+			require.Len(t, actual.VerifiableAddresses, 1)
+			address := actual.VerifiableAddresses[0]
+			address.Verified = true
+			address.Status = identity.VerifiableAddressStatusCompleted
+			require.NoError(t, reg.PrivilegedIdentityPool().UpdateVerifiableAddress(ctx, &address))
+
+			res = whoami(t, etag)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.NotEqual(t, etag, res.Header.Get("ETag"))
+		})
+
+		t.Run("case=changes if computed fields change", func(t *testing.T) {
+			res := whoami(t, "")
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			etag := res.Header.Get("ETag")
+
+			conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".enabled", true)
+			t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".enabled", false) })
+
+			res = whoami(t, etag)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.NotEqual(t, etag, res.Header.Get("ETag"))
+		})
+	})
+
+	t.Run("case=returns only the requested fields", func(t *testing.T) {
+		s := testhelpers.CreateSession(t, reg)
+
+		whoami := func(t *testing.T, fields string) (*http.Response, []byte) {
+			req, err := http.NewRequest("GET", ts.URL+RouteWhoami+"?fields="+url.QueryEscape(fields), nil)
+			require.NoError(t, err)
+			req.Header.Set("X-Session-Token", s.Token)
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			return res, x.MustReadAll(res.Body)
+		}
+
+		res, body := whoami(t, "id, identity.id,authenticated_at,identity.does_not_exist")
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+		assert.Equal(t, s.IdentityID.String(), res.Header.Get("X-Kratos-Authenticated-Identity-Id"))
+		assert.Equal(t, s.ID.String(), gjson.GetBytes(body, "id").String(), "%s", body)
+		assert.Equal(t, s.IdentityID.String(), gjson.GetBytes(body, "identity.id").String(), "%s", body)
+		assert.True(t, gjson.GetBytes(body, "authenticated_at").Exists(), "%s", body)
+		assert.Len(t, gjson.ParseBytes(body).Map(), 3, "%s", body)
+		assert.Len(t, gjson.GetBytes(body, "identity").Map(), 1, "%s", body)
+
+		for _, fields := range []string{"identity.traits.#", "id,", "identity..id", "identity|@pretty"} {
+			res, body := whoami(t, fields)
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, "%s: %s", fields, body)
+		}
+	})
+
+	t.Run("derived traits", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "file://./stub/derived_traits.jsonnet")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "")
+		})
+
+		client := testhelpers.NewClientWithCookies(t)
+		testhelpers.MockHydrateCookieClient(t, client, ts.URL+"/set")
+
+		res, err := client.Get(ts.URL + RouteWhoami)
+		require.NoError(t, err)
+		body := x.MustReadAll(res.Body)
+		assert.EqualValues(t, http.StatusOK, res.StatusCode, string(body))
+		assert.Equal(t, "bar.sh", gjson.GetBytes(body, "identity.derived_traits.email_domain").String(), "%s", body)
+	})
+
 	/*
 		t.Run("case=respects AAL config", func(t *testing.T) {
 			conf.MustSet(ctx, config.ViperKeySessionLifespan, "1m")
@@ -484,6 +599,39 @@ func TestHandlerAdminSessionManagement(t *testing.T) {
 	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
 	conf.MustSet(ctx, config.ViperKeyPublicBaseURL, ts.URL)
 
+	t.Run("case=should include derived traits", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "file://./stub/derived_traits.jsonnet")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyIdentityDerivedTraitsMapper, "")
+		})
+
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		i.Traits = identity.Traits(`{"email":"derived-` + x.NewUUID().String() + `@bar.sh"}`)
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+		t.Cleanup(func() {
+			// The other cases expect to see only their own sessions.
+			require.NoError(t, reg.PrivilegedIdentityPool().DeleteIdentity(ctx, i.ID))
+		})
+		req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+		s, err := testhelpers.NewActiveSession(req, reg, i, time.Now().UTC(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+		require.NoError(t, err)
+		require.NoError(t, reg.SessionPersister().UpsertSession(ctx, s))
+
+		for href, path := range map[string]string{
+			"/admin/sessions/" + s.ID.String() + "?expand=identity": "identity.derived_traits.email_domain",
+			"/admin/sessions?expand=identity&page_size=1000":        `#(id=="` + s.ID.String() + `").identity.derived_traits.email_domain`,
+			"/admin/identities/" + i.ID.String() + "/sessions":      "0.identity.derived_traits.email_domain",
+		} {
+			t.Run("href="+href, func(t *testing.T) {
+				res, err := ts.Client().Get(ts.URL + href)
+				require.NoError(t, err)
+				body := ioutilx.MustReadAll(res.Body)
+				require.Equal(t, http.StatusOK, res.StatusCode, "%s", body)
+				assert.Equal(t, "bar.sh", gjson.GetBytes(body, path).String(), "%s", body)
+			})
+		}
+	})
+
 	t.Run("case=should return 202 after invalidating all sessions", func(t *testing.T) {
 		client := testhelpers.NewClientWithCookies(t)
 		var s *Session
@@ -842,6 +990,77 @@ func TestHandlerAdminSessionManagement(t *testing.T) {
 	})
 }
 
+func TestHandlerAdminAPITokenRedaction(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	_, ts, _, _ := testhelpers.NewKratosServerWithCSRFAndRouters(t, reg)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+
+	i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+	i.Traits = identity.Traits(`{"email":"redacted-` + x.NewUUID().String() + `@ory.sh"}`)
+	i.MetadataAdmin = []byte(`{"notes":"VIP"}`)
+	require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+	req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+	s, err := testhelpers.NewActiveSession(req, reg, i, time.Now().UTC(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+	require.NoError(t, err)
+	require.NoError(t, reg.SessionPersister().UpsertSession(ctx, s))
+
+	const supportToken = "support-token-0123456789abcdefghijkl"
+	conf.MustSet(ctx, config.ViperKeyAdminAPITokens, []map[string]any{
+		{"id": "support", "token": supportToken, "redacted_traits": []string{"email"}},
+	})
+	t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyAdminAPITokens, nil) })
+
+	do := func(t *testing.T, method, href string, body io.Reader) gjson.Result {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+"/admin"+href, body)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+supportToken)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		raw := ioutilx.MustReadAll(res.Body)
+		require.Equal(t, http.StatusOK, res.StatusCode, "%s", raw)
+		return gjson.ParseBytes(raw)
+	}
+
+	assertRedacted := func(t *testing.T, session gjson.Result) {
+		t.Helper()
+		assert.Equal(t, s.ID.String(), session.Get("id").String(), "%s", session.Raw)
+		assert.Equal(t, i.ID.String(), session.Get("identity.id").String(), "%s", session.Raw)
+		assert.False(t, session.Get("identity.traits.email").Exists(), "%s", session.Raw)
+		assert.False(t, session.Get("identity.metadata_admin").Exists(), "%s", session.Raw)
+	}
+
+	t.Run("endpoint=adminListSessions", func(t *testing.T) {
+		sessions := do(t, "GET", "/sessions?expand=identity&page_size=1000", nil)
+		session := sessions.Get(`#(id=="` + s.ID.String() + `")`)
+		require.True(t, session.Exists(), "%s", sessions.Raw)
+		assertRedacted(t, session)
+	})
+
+	t.Run("endpoint=getSession", func(t *testing.T) {
+		assertRedacted(t, do(t, "GET", "/sessions/"+s.ID.String()+"?expand=identity", nil))
+	})
+
+	t.Run("endpoint=listIdentitySessions", func(t *testing.T) {
+		assertRedacted(t, do(t, "GET", "/identities/"+i.ID.String()+"/sessions", nil).Get("0"))
+	})
+
+	t.Run("endpoint=extendSession", func(t *testing.T) {
+		assertRedacted(t, do(t, "PATCH", "/sessions/"+s.ID.String()+"/extend", nil))
+	})
+
+	t.Run("endpoint=introspectSessions", func(t *testing.T) {
+		res := do(t, "POST", AdminRouteIntrospect, strings.NewReader(`{"items":[{"session_token":"`+s.Token+`"},{"session_id":"`+s.ID.String()+`"}]}`))
+		for _, item := range res.Get("items").Array() {
+			assertRedacted(t, item.Get("session"))
+		}
+	})
+}
+
 func TestHandlerSelfServiceSessionManagement(t *testing.T) {
 	ctx := context.Background()
 	conf, reg := internal.NewFastRegistryWithMocks(t)
@@ -19,6 +19,7 @@ import (
 	"github.com/ory/kratos/selfservice/sessiontokenexchange"
 	"github.com/ory/kratos/ui/node"
 	"github.com/ory/x/otelx"
+	"github.com/ory/x/pointerx"
 
 	"github.com/ory/x/randx"
 
@@ -33,6 +34,7 @@ import (
 	"github.com/ory/kratos/driver/config"
 
 	"github.com/ory/x/sqlcon"
+	"github.com/ory/x/sqlxx"
 
 	"github.com/ory/herodot"
 
@@ -51,10 +53,13 @@ type (
 		x.CookieProvider
 		x.LoggingProvider
 		x.CSRFProvider
+		x.HTTPClientProvider
+		x.JWKSFetchProvider
 		x.TracingProvider
 		x.TransactionPersistenceProvider
 		PersistenceProvider
 		sessiontokenexchange.PersistenceProvider
+		GeoIPResolverProvider
 	}
 	ManagerHTTP struct {
 		cookieName func(ctx context.Context) string
@@ -169,7 +174,7 @@ func (s *ManagerHTTP) IssueCookie(ctx context.Context, w http.ResponseWriter, r
 	}
 
 	cookie.Options.MaxAge = 0
-	if s.r.Config().SessionPersistentCookie(ctx) {
+	if s.r.Config().SessionPersistentCookie(ctx) && !session.ShortLived {
 		if session.ExpiresAt.IsZero() {
 			cookie.Options.MaxAge = int(s.r.Config().SessionLifespan(ctx).Seconds())
 		} else {
@@ -265,13 +270,34 @@ func (s *ManagerHTTP) FetchFromRequest(ctx context.Context, r *http.Request) (_
 
 	trace.SpanFromContext(ctx).AddEvent(events.NewSessionChecked(ctx, se.ID, se.IdentityID))
 
-	if !se.IsActive() {
+	if !se.IsUsable(ctx, s.r.Config()) {
 		return nil, errors.WithStack(NewErrNoActiveSessionFound())
 	}
+	s.recordActivity(ctx, se)
 
 	return se, nil
 }
 
+// recordActivity stores the use of the session if the idle timeout is set. To prevent a write per
+// request, uses within the activity update interval of the last recorded use are skipped.
+func (s *ManagerHTTP) recordActivity(ctx context.Context, se *Session) {
+	if s.r.Config().SessionIdleTimeout(ctx) <= 0 {
+		return
+	}
+
+	now := x.Now().UTC()
+	if se.LastActive().Add(s.r.Config().SessionActivityUpdateInterval(ctx)).After(now) {
+		return
+	}
+
+	// A failed write only shortens the time until the session becomes idle, so the request proceeds.
+	if err := s.r.SessionPersister().UpdateSessionActivity(ctx, se.ID, now); err != nil {
+		s.r.Logger().WithError(err).WithField("session_id", se.ID).Warn("Unable to record the session activity.")
+		return
+	}
+	se.LastActiveAt = pointerx.Ptr(sqlxx.NullTime(now))
+}
+
 func (s *ManagerHTTP) PurgeFromRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) (err error) {
 	ctx, span := s.r.Tracer(ctx).Tracer().Start(ctx, "sessions.ManagerHTTP.PurgeFromRequest")
 	defer otelx.End(span, &err)
@@ -349,9 +375,10 @@ func (s *ManagerHTTP) DoesSessionSatisfy(ctx context.Context, sess *Session, req
 
 		// Identity AAL is not 2, we refresh:
 
-		// The identity was apparently fetched without credentials. Let's hydrate them.
+		// The identity was apparently fetched without credentials. Let's hydrate them together with the
+		// verifiable addresses, which some second factors (e.g. codes) can be sent to.
 		if len(sess.Identity.Credentials) == 0 {
-			if err := s.r.PrivilegedIdentityPool().HydrateIdentityAssociations(ctx, sess.Identity, identity.ExpandCredentials); err != nil {
+			if err := s.r.PrivilegedIdentityPool().HydrateIdentityAssociations(ctx, sess.Identity, identity.Expandables{identity.ExpandFieldCredentials, identity.ExpandFieldVerifiableAddresses}); err != nil {
 				return err
 			}
 		}
@@ -424,7 +451,7 @@ func (s *ManagerHTTP) MaybeRedirectAPICodeFlow(w http.ResponseWriter, r *http.Re
 
 	returnTo := s.r.Config().SelfServiceBrowserDefaultReturnTo(ctx)
 	if redirecter, ok := f.(flow.FlowWithRedirect); ok {
-		r, err := x.SecureRedirectTo(r, returnTo, redirecter.SecureRedirectToOpts(ctx, s.r)...)
+		r, err := x.SecureRedirectTo(r, returnTo, append(redirecter.SecureRedirectToOpts(ctx, s.r), x.SecureRedirectAllowReturnToGrants(s.r))...)
 		if err == nil {
 			returnTo = r
 		}
@@ -455,7 +482,20 @@ func (s *ManagerHTTP) ActivateSession(r *http.Request, session *Session, i *iden
 	}
 
 	if !i.IsActive() {
-		return errors.WithStack(ErrIdentityDisabled.WithDetail("identity_id", i.ID))
+		if s.r.Config().IdentityLoginBehavior(ctx, string(i.State)) != config.IdentityLoginBehaviorReactivate {
+			return errors.WithStack(ErrIdentityDisabled.WithDetail("identity_id", i.ID).WithDetail("state", i.State))
+		}
+		if err := i.State.ValidateTransition(identity.StateActive); err != nil {
+			return err
+		}
+
+		// The reactivation is persisted together with the session so that it is discarded if a
+		// post-login hook rejects the login.
+		session.ReactivatedIdentityFrom = i.State
+		stateChangedAt := sqlxx.NullTime(x.Now())
+		i.State = identity.StateActive
+		i.StateChangedAt = &stateChangedAt
+		i.StateReason = identity.StateReasonLogin
 	}
 
 	if err := s.r.IdentityManager().RefreshAvailableAAL(ctx, i); err != nil {
@@ -471,6 +511,10 @@ func (s *ManagerHTTP) ActivateSession(r *http.Request, session *Session, i *iden
 	session.AuthenticatedAt = authenticatedAt
 
 	session.SetSessionDeviceInformation(r.WithContext(ctx))
+	if err := s.r.SessionGeoIPResolver().EnrichDevice(ctx, &session.Devices[len(session.Devices)-1]); err != nil {
+		// The session is issued even if the location of the device could not be resolved.
+		s.r.Logger().WithError(err).Warn("Unable to resolve the location of the session device.")
+	}
 	session.SetAuthenticatorAssuranceLevel()
 
 	span.SetAttributes(
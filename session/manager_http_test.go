@@ -279,6 +279,53 @@ func TestManagerHTTP(t *testing.T) {
 			assert.EqualValues(t, http.StatusOK, res.StatusCode)
 		})
 
+		t.Run("case=idle timeout", func(t *testing.T) {
+			req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+			conf.MustSet(ctx, config.ViperKeySessionLifespan, "1h")
+			conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "10m")
+			conf.MustSet(ctx, config.ViperKeySessionActivityUpdateInterval, "1m")
+			t.Cleanup(func() {
+				conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "0s")
+				conf.MustSet(ctx, config.ViperKeySessionActivityUpdateInterval, "1m")
+			})
+
+			i := identity.Identity{Traits: []byte("{}")}
+			require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(context.Background(), &i))
+			s, _ = testhelpers.NewActiveSession(req, reg, &i, time.Now().Add(-5*time.Minute), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+
+			c := testhelpers.NewClientWithCookies(t)
+			testhelpers.MockHydrateCookieClient(t, c, pts.URL+"/session/set")
+
+			res, err := c.Get(pts.URL + "/session/get")
+			require.NoError(t, err)
+			assert.EqualValues(t, http.StatusOK, res.StatusCode)
+
+			actual, err := reg.SessionPersister().GetSession(ctx, s.ID, session.ExpandNothing)
+			require.NoError(t, err)
+			require.NotNil(t, actual.LastActiveAt)
+			lastActive := actual.LastActive()
+			assert.WithinDuration(t, time.Now(), lastActive, time.Minute)
+
+			t.Run("case=skips writes within the update interval", func(t *testing.T) {
+				res, err := c.Get(pts.URL + "/session/get")
+				require.NoError(t, err)
+				assert.EqualValues(t, http.StatusOK, res.StatusCode)
+
+				actual, err := reg.SessionPersister().GetSession(ctx, s.ID, session.ExpandNothing)
+				require.NoError(t, err)
+				assert.Equal(t, lastActive, actual.LastActive())
+			})
+
+			t.Run("case=rejects idle sessions", func(t *testing.T) {
+				require.NoError(t, reg.SessionPersister().UpdateSessionActivity(ctx, s.ID, time.Now().Add(-11*time.Minute)))
+				require.NoError(t, reg.Persister().GetConnection(ctx).RawQuery("UPDATE sessions SET authenticated_at = ? WHERE id = ?", time.Now().UTC().Add(-30*time.Minute), s.ID).Exec())
+
+				res, err := c.Get(pts.URL + "/session/get")
+				require.NoError(t, err)
+				assert.EqualValues(t, http.StatusUnauthorized, res.StatusCode)
+			})
+		})
+
 		t.Run("case=key rotation", func(t *testing.T) {
 			req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
 			original := conf.GetProvider(ctx).Strings(config.ViperKeySecretsCookie)
@@ -0,0 +1,68 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/schema"
+)
+
+// SetMetadata replaces the metadata of the given namespaces. Namespaces set to null are removed,
+// all other namespaces of the session are left untouched.
+func (s *Session) SetMetadata(namespaces map[string]json.RawMessage) error {
+	metadata := map[string]json.RawMessage{}
+	if len(s.Metadata) > 0 && string(s.Metadata) != "null" {
+		if err := json.Unmarshal(s.Metadata, &metadata); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for namespace, value := range namespaces {
+		if namespace == "" {
+			return errors.WithStack(herodot.ErrBadRequest.WithReason("Session metadata namespaces must not be empty."))
+		}
+		if len(value) == 0 || string(value) == "null" {
+			delete(metadata, namespace)
+			continue
+		}
+		metadata[namespace] = value
+	}
+
+	if len(metadata) == 0 {
+		s.Metadata = nil
+		return nil
+	}
+
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to encode the session metadata: %s", err))
+	}
+	s.Metadata = raw
+	return nil
+}
+
+// ValidateMetadata checks the session metadata against the configured size limit and JSON Schema.
+func ValidateMetadata(ctx context.Context, c *config.Config, s *Session) error {
+	if len(s.Metadata) == 0 {
+		return nil
+	}
+
+	if maxSize := c.SessionMetadataMaxSize(ctx); len(s.Metadata) > maxSize {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("The session metadata set by the login hooks exceeds the maximum size of %d bytes.", maxSize))
+	}
+
+	if href := c.SessionMetadataSchema(ctx); href != "" {
+		if err := schema.NewValidator().Validate(ctx, href, json.RawMessage(s.Metadata)); err != nil {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReason("The session metadata set by the login hooks does not match the session metadata schema.").WithDebugf("%+v", err))
+		}
+	}
+
+	return nil
+}
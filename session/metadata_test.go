@@ -0,0 +1,74 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/session"
+)
+
+func TestSessionMetadata(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+
+	t.Run("case=sets and removes namespaces", func(t *testing.T) {
+		s := new(session.Session)
+		require.NoError(t, s.SetMetadata(map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":"acme"}`), "roles": json.RawMessage(`["admin"]`)}))
+		assert.JSONEq(t, `{"tenant":{"id":"acme"},"roles":["admin"]}`, string(s.Metadata))
+
+		require.NoError(t, s.SetMetadata(map[string]json.RawMessage{"roles": json.RawMessage(`null`)}))
+		assert.JSONEq(t, `{"tenant":{"id":"acme"}}`, string(s.Metadata))
+
+		require.NoError(t, s.SetMetadata(map[string]json.RawMessage{"tenant": nil}))
+		assert.Empty(t, s.Metadata)
+
+		require.Error(t, s.SetMetadata(map[string]json.RawMessage{"": json.RawMessage(`true`)}))
+	})
+
+	t.Run("case=enforces the size limit", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionMetadataMaxSize, 32)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySessionMetadataMaxSize, 4096) })
+
+		s := new(session.Session)
+		require.NoError(t, s.SetMetadata(map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":"acme"}`)}))
+		require.NoError(t, session.ValidateMetadata(ctx, conf, s))
+
+		require.NoError(t, s.SetMetadata(map[string]json.RawMessage{"notes": json.RawMessage(`"` + strings.Repeat("a", 32) + `"`)}))
+		assert.Contains(t, fmt.Sprintf("%+v", session.ValidateMetadata(ctx, conf, s)), "exceeds the maximum size")
+	})
+
+	t.Run("case=validates against the schema", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionMetadataSchema, "file://./stub/metadata.schema.json")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySessionMetadataSchema, "") })
+
+		s := new(session.Session)
+		require.NoError(t, s.SetMetadata(map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":"acme"}`)}))
+		require.NoError(t, session.ValidateMetadata(ctx, conf, s))
+
+		require.NoError(t, s.SetMetadata(map[string]json.RawMessage{"tenant": json.RawMessage(`{"name":"acme"}`)}))
+		assert.Contains(t, fmt.Sprintf("%+v", session.ValidateMetadata(ctx, conf, s)), "does not match the session metadata schema")
+	})
+
+	t.Run("case=is persisted", func(t *testing.T) {
+		s := testhelpers.CreateSession(t, reg)
+		require.NoError(t, s.SetMetadata(map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":"acme"}`)}))
+		require.NoError(t, reg.SessionPersister().UpsertSession(ctx, s))
+
+		actual, err := reg.SessionPersister().GetSession(ctx, s.ID, session.ExpandNothing)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"tenant":{"id":"acme"}}`, string(actual.Metadata))
+	})
+}
@@ -0,0 +1,177 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/urlx"
+)
+
+// MFAEnrollment is set on sessions of identities which are asked to set up a second factor.
+//
+// swagger:model sessionMFAEnrollment
+type MFAEnrollment struct {
+	// Required is true if the identity is part of the MFA enrollment campaign and has not set up
+	// a second factor yet.
+	//
+	// required: true
+	Required bool `json:"required"`
+
+	// Enforced is true once the deadline passed. From then on, the session can only be used
+	// after a second factor was set up.
+	//
+	// required: true
+	Enforced bool `json:"enforced"`
+
+	// Deadline is the time after which setting up a second factor is enforced.
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+var (
+	mfaEnrollmentRedirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_session_mfa_enrollment_campaign_redirects_total",
+		Help: "Number of logins which continued in the settings flow to set up a second factor, labelled with the identity schema and whether the deadline passed.",
+	}, []string{"schema", "enforced"})
+
+	mfaEnrollmentRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_session_mfa_enrollment_campaign_rejections_total",
+		Help: "Number of session checks which were rejected because the deadline for setting up a second factor passed, labelled with the identity schema.",
+	}, []string{"schema"})
+
+	mfaEnrollmentCompletions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_session_mfa_enrollment_campaign_completions_total",
+		Help: "Number of identities in the MFA enrollment campaign which set up a second factor, labelled with the identity schema.",
+	}, []string{"schema"})
+)
+
+// MFAEnrollmentCollectors returns the Prometheus collectors of the MFA enrollment campaign.
+// They are registered by the registry's metrics setup.
+func MFAEnrollmentCollectors() []prometheus.Collector {
+	return []prometheus.Collector{mfaEnrollmentRedirects, mfaEnrollmentRejections, mfaEnrollmentCompletions}
+}
+
+// EvaluateMFAEnrollment returns whether the identity is asked to set up a second factor. It returns
+// nil if the campaign is disabled, the identity is not part of the rollout, or it already has a
+// second factor.
+func EvaluateMFAEnrollment(ctx context.Context, c *config.Config, i *identity.Identity) (*MFAEnrollment, error) {
+	e, _, err := evaluateMFAEnrollment(ctx, c, i)
+	return e, err
+}
+
+func evaluateMFAEnrollment(ctx context.Context, c *config.Config, i *identity.Identity) (*MFAEnrollment, *config.MFAEnrollmentCampaign, error) {
+	if i == nil || i.InternalAvailableAAL.String == string(identity.AuthenticatorAssuranceLevel2) {
+		return nil, nil, nil
+	}
+
+	campaign, err := c.SelfServiceMFAEnrollmentCampaign(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !campaign.Enabled {
+		return nil, nil, nil
+	}
+
+	percentage := campaign.RolloutPercentage
+	schemas, err := c.IdentityTraitsSchemas(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s, err := schemas.FindSchemaByID(i.SchemaID); err == nil && s.SelfService.MFAEnrollmentRolloutPercentage != nil {
+		percentage = *s.SelfService.MFAEnrollmentRolloutPercentage
+	}
+	if rolloutBucket(i) >= percentage {
+		return nil, nil, nil
+	}
+
+	e := &MFAEnrollment{Required: true}
+	if !campaign.Deadline.IsZero() {
+		e.Deadline = &campaign.Deadline
+		e.Enforced = x.Now().After(campaign.Deadline)
+	}
+	return e, campaign, nil
+}
+
+// MFAEnrollmentRedirect returns the URL of the settings flow if a browser login of the identity
+// should continue there instead of returning to returnTo.
+func MFAEnrollmentRedirect(ctx context.Context, c *config.Config, i *identity.Identity, returnTo *url.URL) (*url.URL, bool, error) {
+	e, campaign, err := evaluateMFAEnrollment(ctx, c, i)
+	if err != nil || e == nil {
+		return nil, false, err
+	}
+	if !campaign.RedirectAfterLogin && !e.Enforced {
+		return nil, false, nil
+	}
+
+	mfaEnrollmentRedirects.WithLabelValues(i.SchemaID, boolLabel(e.Enforced)).Inc()
+	return settingsFlowURL(ctx, c, returnTo.String()), true, nil
+}
+
+// CountMFAEnrollmentCampaignCompletion records that an identity which was asked to set up a second
+// factor did so.
+func CountMFAEnrollmentCampaignCompletion(schemaID string) {
+	mfaEnrollmentCompletions.WithLabelValues(schemaID).Inc()
+}
+
+func settingsFlowURL(ctx context.Context, c *config.Config, returnTo string) *url.URL {
+	u := urlx.AppendPaths(c.SelfPublicURL(ctx), "/self-service/settings/browser")
+	if returnTo == "" {
+		return u
+	}
+	return urlx.CopyWithQuery(u, url.Values{"return_to": {returnTo}})
+}
+
+// rolloutBucket assigns the identity to one of 100 buckets. The assignment only depends on the
+// identity ID, so raising the rollout percentage keeps the identities which were already affected.
+func rolloutBucket(i *identity.Identity) int {
+	h := fnv.New32a()
+	_, _ = h.Write(i.ID.Bytes())
+	return int(h.Sum32() % 100)
+}
+
+func boolLabel(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// ErrMFAEnrollmentRequired is returned when the deadline of the MFA enrollment campaign passed
+// and the identity has not set up a second factor.
+type ErrMFAEnrollmentRequired struct {
+	*herodot.DefaultError `json:"error"`
+	RedirectTo            string `json:"redirect_browser_to"`
+}
+
+func (e *ErrMFAEnrollmentRequired) EnhanceJSONError() interface{} {
+	return e
+}
+
+// NewErrMFAEnrollmentRequired creates a new ErrMFAEnrollmentRequired.
+func NewErrMFAEnrollmentRequired(redirectTo string) *ErrMFAEnrollmentRequired {
+	return &ErrMFAEnrollmentRequired{
+		RedirectTo: redirectTo,
+		DefaultError: &herodot.DefaultError{
+			IDField:     text.ErrIDMFAEnrollmentRequired,
+			StatusField: http.StatusText(http.StatusForbidden),
+			ErrorField:  "A second factor must be set up",
+			ReasonField: "An active session was found but the identity has not set up a second factor, which is required. Please set up a second factor in the settings to resolve this issue.",
+			CodeField:   http.StatusForbidden,
+			DetailsField: map[string]interface{}{
+				"redirect_browser_to": redirectTo,
+			},
+		},
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/pointerx"
+)
+
+func TestEvaluateMFAEnrollment(t *testing.T) {
+	ctx := context.Background()
+	conf, _ := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+
+	newIdentity := func(aal identity.AuthenticatorAssuranceLevel) *identity.Identity {
+		return &identity.Identity{
+			ID:                   x.NewUUID(),
+			SchemaID:             "default",
+			InternalAvailableAAL: identity.NewNullableAuthenticatorAssuranceLevel(aal),
+		}
+	}
+
+	t.Run("case=disabled campaign", func(t *testing.T) {
+		e, err := session.EvaluateMFAEnrollment(ctx, conf, newIdentity(identity.AuthenticatorAssuranceLevel1))
+		require.NoError(t, err)
+		assert.Nil(t, e)
+	})
+
+	conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".enabled", true)
+
+	t.Run("case=flags identities without a second factor", func(t *testing.T) {
+		e, err := session.EvaluateMFAEnrollment(ctx, conf, newIdentity(identity.AuthenticatorAssuranceLevel1))
+		require.NoError(t, err)
+		require.NotNil(t, e)
+		assert.True(t, e.Required)
+		assert.False(t, e.Enforced)
+		assert.Nil(t, e.Deadline)
+
+		e, err = session.EvaluateMFAEnrollment(ctx, conf, newIdentity(identity.AuthenticatorAssuranceLevel2))
+		require.NoError(t, err)
+		assert.Nil(t, e)
+	})
+
+	t.Run("case=enforces after the deadline", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".deadline", time.Now().Add(-time.Hour).Format(time.RFC3339))
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".deadline", "") })
+
+		e, err := session.EvaluateMFAEnrollment(ctx, conf, newIdentity(identity.AuthenticatorAssuranceLevel1))
+		require.NoError(t, err)
+		require.NotNil(t, e)
+		assert.True(t, e.Enforced)
+		require.NotNil(t, e.Deadline)
+	})
+
+	t.Run("case=respects the rollout percentage", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".rollout_percentage", 0)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".rollout_percentage", 100) })
+
+		i := newIdentity(identity.AuthenticatorAssuranceLevel1)
+		e, err := session.EvaluateMFAEnrollment(ctx, conf, i)
+		require.NoError(t, err)
+		assert.Nil(t, e)
+
+		conf.MustSet(ctx, config.ViperKeyIdentitySchemas, config.Schemas{{
+			ID:          "default",
+			URL:         "file://./stub/identity.schema.json",
+			SelfService: config.SchemaSelfService{MFAEnrollmentRolloutPercentage: pointerx.Ptr(100)},
+		}})
+		t.Cleanup(func() { testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json") })
+
+		e, err = session.EvaluateMFAEnrollment(ctx, conf, i)
+		require.NoError(t, err)
+		assert.NotNil(t, e, "the schema overrides the rollout percentage")
+	})
+
+	t.Run("case=assigns identities to the rollout by their ID", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".rollout_percentage", 50)
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".rollout_percentage", 100) })
+
+		var affected int
+		for range 1000 {
+			i := newIdentity(identity.AuthenticatorAssuranceLevel1)
+			first, err := session.EvaluateMFAEnrollment(ctx, conf, i)
+			require.NoError(t, err)
+			second, err := session.EvaluateMFAEnrollment(ctx, conf, i)
+			require.NoError(t, err)
+			assert.Equal(t, first != nil, second != nil)
+			if first != nil {
+				affected++
+			}
+		}
+		assert.InDelta(t, 500, affected, 100)
+	})
+}
+
+func TestSessionWhoAmIMFAEnrollment(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	ts, _ := testhelpers.NewKratosServer(t, reg)
+	conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".enabled", true)
+
+	i := createAAL1Identity(t, reg)
+	require.NoError(t, reg.IdentityManager().Create(ctx, i))
+	client := testhelpers.NewHTTPClientWithIdentitySessionToken(t, ctx, reg, i)
+
+	whoami := func(t *testing.T, expectCode int) gjson.Result {
+		t.Helper()
+		res, err := client.Get(ts.URL + session.RouteWhoami)
+		require.NoError(t, err)
+		body := x.MustReadAll(res.Body)
+		require.NoError(t, res.Body.Close())
+		require.EqualValues(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=flags the session", func(t *testing.T) {
+		body := whoami(t, http.StatusOK)
+		assert.True(t, body.Get("mfa_enrollment.required").Bool(), "%s", body.Raw)
+		assert.False(t, body.Get("mfa_enrollment.enforced").Bool(), "%s", body.Raw)
+	})
+
+	t.Run("case=rejects the session after the deadline", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".deadline", time.Now().Add(-time.Minute).Format(time.RFC3339))
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySelfServiceMFAEnrollmentCampaign+".deadline", "") })
+
+		body := whoami(t, http.StatusForbidden)
+		assert.Equal(t, "session_mfa_enrollment_required", body.Get("error.id").String(), "%s", body.Raw)
+		assert.Equal(t, ts.URL+"/self-service/settings/browser", body.Get("redirect_browser_to").String(), "%s", body.Raw)
+	})
+
+	t.Run("case=does not flag identities with a second factor", func(t *testing.T) {
+		i.InternalAvailableAAL = identity.NewNullableAuthenticatorAssuranceLevel(identity.AuthenticatorAssuranceLevel2)
+		require.NoError(t, reg.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "available_aal"))
+		conf.MustSet(ctx, config.ViperKeySessionWhoAmIAAL, "aal1")
+
+		body := whoami(t, http.StatusOK)
+		assert.False(t, body.Get("mfa_enrollment").Exists(), "%s", body.Raw)
+	})
+}
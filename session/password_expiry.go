@@ -0,0 +1,72 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/sqlxx"
+)
+
+// PasswordExpiry is set on sessions of identities whose password expires soon.
+//
+// swagger:model sessionPasswordExpiry
+type PasswordExpiry struct {
+	// ExpiresAt is the time after which the identity must set a new password when signing in
+	// with its password.
+	//
+	// required: true
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// Expired is true if the password already expired.
+	//
+	// required: true
+	Expired bool `json:"expired"`
+}
+
+// SetPasswordExpiry records when the password of the identity expires under the password expiry policy.
+// It does nothing if the policy is disabled or the identity has no password.
+func (s *Session) SetPasswordExpiry(ctx context.Context, c *config.Config, i *identity.Identity) error {
+	maxAge := c.PasswordPolicyConfig(ctx).MaxAge
+	if maxAge <= 0 {
+		s.PasswordExpiresAt = nil
+		return nil
+	}
+
+	expiresAt, ok, err := i.PasswordRotateAt(maxAge)
+	if err != nil {
+		return err
+	} else if !ok {
+		s.PasswordExpiresAt = nil
+		return nil
+	}
+
+	at := sqlxx.NullTime(expiresAt.UTC())
+	s.PasswordExpiresAt = &at
+	return nil
+}
+
+// EvaluatePasswordExpiry returns the password expiry warning of the session. It returns nil if the policy
+// is disabled or the password does not expire within the configured warning period.
+func EvaluatePasswordExpiry(ctx context.Context, c *config.Config, s *Session) *PasswordExpiry {
+	policy := c.PasswordPolicyConfig(ctx)
+	if policy.MaxAge <= 0 || s.PasswordExpiresAt == nil {
+		return nil
+	}
+
+	expiresAt := time.Time(*s.PasswordExpiresAt)
+	now := x.Now()
+	if now.Before(expiresAt.Add(-policy.ExpiryWarning)) {
+		return nil
+	}
+
+	return &PasswordExpiry{
+		ExpiresAt: expiresAt,
+		Expired:   !now.Before(expiresAt),
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+)
+
+func TestEvaluatePasswordExpiry(t *testing.T) {
+	ctx := context.Background()
+	conf, _ := internal.NewFastRegistryWithMocks(t)
+
+	changedAt := time.Now().Add(-80 * 24 * time.Hour).UTC().Round(time.Second)
+	i := &identity.Identity{ID: x.NewUUID()}
+	require.NoError(t, i.SetCredentialsWithConfig(identity.CredentialsTypePassword, identity.Credentials{}, identity.CredentialsPassword{
+		HashedPassword: "hash",
+		ChangedAt:      &changedAt,
+	}))
+
+	t.Run("case=disabled policy", func(t *testing.T) {
+		s := new(session.Session)
+		require.NoError(t, s.SetPasswordExpiry(ctx, conf, i))
+		assert.Nil(t, s.PasswordExpiresAt)
+		assert.Nil(t, session.EvaluatePasswordExpiry(ctx, conf, s))
+	})
+
+	conf.MustSet(ctx, config.ViperKeyPasswordMaxAge, "2160h")
+
+	t.Run("case=records when the password expires", func(t *testing.T) {
+		s := new(session.Session)
+		require.NoError(t, s.SetPasswordExpiry(ctx, conf, i))
+		require.NotNil(t, s.PasswordExpiresAt)
+		assert.Equal(t, changedAt.Add(90*24*time.Hour), time.Time(*s.PasswordExpiresAt))
+
+		s = new(session.Session)
+		require.NoError(t, s.SetPasswordExpiry(ctx, conf, &identity.Identity{ID: x.NewUUID()}))
+		assert.Nil(t, s.PasswordExpiresAt)
+	})
+
+	t.Run("case=warns near expiry", func(t *testing.T) {
+		s := new(session.Session)
+		require.NoError(t, s.SetPasswordExpiry(ctx, conf, i))
+		assert.Nil(t, session.EvaluatePasswordExpiry(ctx, conf, s))
+
+		conf.MustSet(ctx, config.ViperKeyPasswordExpiryWarning, "336h")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyPasswordExpiryWarning, "168h") })
+
+		e := session.EvaluatePasswordExpiry(ctx, conf, s)
+		require.NotNil(t, e)
+		assert.Equal(t, changedAt.Add(90*24*time.Hour), e.ExpiresAt)
+		assert.False(t, e.Expired)
+	})
+
+	t.Run("case=flags expired passwords", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyPasswordMaxAge, "720h")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeyPasswordMaxAge, "2160h") })
+
+		s := new(session.Session)
+		require.NoError(t, s.SetPasswordExpiry(ctx, conf, i))
+		e := session.EvaluatePasswordExpiry(ctx, conf, s)
+		require.NotNil(t, e)
+		assert.True(t, e.Expired)
+	})
+}
+
+func TestSessionWhoAmIPasswordExpiry(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	ts, _ := testhelpers.NewKratosServer(t, reg)
+	conf.MustSet(ctx, config.ViperKeyPasswordMaxAge, "2160h")
+
+	i := createAAL1Identity(t, reg)
+	require.NoError(t, reg.IdentityManager().Create(ctx, i))
+
+	whoami := func(t *testing.T, changedAt time.Time) gjson.Result {
+		t.Helper()
+		c := i.Credentials[identity.CredentialsTypePassword]
+		c.Config = []byte(fmt.Sprintf(`{"hashed_password":"hash","password_changed_at":"%s"}`, changedAt.Format(time.RFC3339)))
+		i.Credentials[identity.CredentialsTypePassword] = c
+
+		req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+		s, err := testhelpers.NewActiveSession(req, reg, i, time.Now(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+		require.NoError(t, err)
+		require.NoError(t, s.SetPasswordExpiry(ctx, conf, i))
+
+		res, err := testhelpers.NewHTTPClientWithSessionToken(t, ctx, reg, s).Get(ts.URL + session.RouteWhoami)
+		require.NoError(t, err)
+		body := x.MustReadAll(res.Body)
+		require.NoError(t, res.Body.Close())
+		require.EqualValues(t, http.StatusOK, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=does not warn about recently changed passwords", func(t *testing.T) {
+		body := whoami(t, time.Now().Add(-time.Hour))
+		assert.False(t, body.Get("password_expiry").Exists(), "%s", body.Raw)
+	})
+
+	t.Run("case=warns about passwords which expire soon", func(t *testing.T) {
+		body := whoami(t, time.Now().Add(-88*24*time.Hour))
+		assert.True(t, body.Get("password_expiry.expires_at").Exists(), "%s", body.Raw)
+		assert.False(t, body.Get("password_expiry.expired").Bool(), "%s", body.Raw)
+	})
+}
@@ -0,0 +1,47 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/text"
+)
+
+// PasswordResetRedirect returns the URL of the settings flow which browser logins continue with
+// if the session is restricted to setting a new password.
+func PasswordResetRedirect(ctx context.Context, c *config.Config, returnTo *url.URL) *url.URL {
+	return settingsFlowURL(ctx, c, returnTo.String())
+}
+
+// ErrPasswordResetRequired is returned when the session can only be used to set a new password.
+type ErrPasswordResetRequired struct {
+	*herodot.DefaultError `json:"error"`
+	RedirectTo            string `json:"redirect_browser_to"`
+}
+
+func (e *ErrPasswordResetRequired) EnhanceJSONError() interface{} {
+	return e
+}
+
+// NewErrPasswordResetRequired creates a new ErrPasswordResetRequired.
+func NewErrPasswordResetRequired(redirectTo string) *ErrPasswordResetRequired {
+	return &ErrPasswordResetRequired{
+		RedirectTo: redirectTo,
+		DefaultError: &herodot.DefaultError{
+			IDField:     text.ErrIDPasswordResetRequired,
+			StatusField: http.StatusText(http.StatusForbidden),
+			ErrorField:  "A new password must be set",
+			ReasonField: "An active session was found but the identity is required to set a new password. Please set a new password in the settings to resolve this issue.",
+			CodeField:   http.StatusForbidden,
+			DetailsField: map[string]interface{}{
+				"redirect_browser_to": redirectTo,
+			},
+		},
+	}
+}
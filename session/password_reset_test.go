@@ -0,0 +1,58 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
+)
+
+func TestSessionWhoAmIPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	ts, _ := testhelpers.NewKratosServer(t, reg)
+
+	i := createAAL1Identity(t, reg)
+	i.PasswordResetRequired = true
+	require.NoError(t, reg.IdentityManager().Create(ctx, i))
+
+	whoami := func(t *testing.T, restricted bool, expectCode int) gjson.Result {
+		t.Helper()
+		req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+		s, err := testhelpers.NewActiveSession(req, reg, i, time.Now(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+		require.NoError(t, err)
+		s.PasswordResetRequired = restricted
+
+		res, err := testhelpers.NewHTTPClientWithSessionToken(t, ctx, reg, s).Get(ts.URL + session.RouteWhoami)
+		require.NoError(t, err)
+		body := x.MustReadAll(res.Body)
+		require.NoError(t, res.Body.Close())
+		require.EqualValues(t, expectCode, res.StatusCode, "%s", body)
+		return gjson.ParseBytes(body)
+	}
+
+	t.Run("case=rejects restricted sessions", func(t *testing.T) {
+		body := whoami(t, true, http.StatusForbidden)
+		assert.Equal(t, "session_password_reset_required", body.Get("error.id").String(), "%s", body.Raw)
+		assert.Equal(t, ts.URL+"/self-service/settings/browser", body.Get("redirect_browser_to").String(), "%s", body.Raw)
+	})
+
+	t.Run("case=accepts sessions issued before the password reset was required", func(t *testing.T) {
+		body := whoami(t, false, http.StatusOK)
+		assert.True(t, body.Get("identity.password_reset_required").Bool(), "%s", body.Raw)
+	})
+}
@@ -38,6 +38,9 @@ type Persister interface {
 	// ExtendSession updates the expiry of a session.
 	ExtendSession(ctx context.Context, sessionID uuid.UUID) error
 
+	// UpdateSessionActivity records when a session was last used.
+	UpdateSessionActivity(ctx context.Context, sessionID uuid.UUID, at time.Time) error
+
 	// DeleteSession removes a session from the store.
 	DeleteSession(ctx context.Context, id uuid.UUID) error
 
@@ -50,7 +53,7 @@ type Persister interface {
 	// instead of a session ID.
 	GetSessionByToken(ctx context.Context, token string, expandables Expandables, identityExpandables identity.Expandables) (*Session, error)
 
-	// DeleteExpiredSessions deletes sessions that expired before the given time.
+	// DeleteExpiredSessions deletes sessions and used session revocation links that expired before the given time.
 	DeleteExpiredSessions(context.Context, time.Time, int) error
 
 	// DeleteSessionByToken deletes a session associated with the given token.
@@ -70,6 +73,18 @@ type Persister interface {
 
 	// RevokeSessionsIdentityExcept marks all except the given session of an identity inactive. It returns the number of sessions that were revoked.
 	RevokeSessionsIdentityExcept(ctx context.Context, iID, sID uuid.UUID) (int, error)
+
+	// RevokeExcessSessions marks all but the keep most recent active sessions of an identity inactive, ignoring the
+	// given session. Sessions are ranked by when they were issued or, if byLastUse is set, when they were last changed
+	// or extended. It returns the number of sessions that were revoked.
+	RevokeExcessSessions(ctx context.Context, iID, except uuid.UUID, keep int, byLastUse bool) (int, error)
+
+	// UseRevokeLink records that the session revocation link with the given ID was used. It returns
+	// sqlcon.ErrUniqueViolation if the link was used before.
+	UseRevokeLink(ctx context.Context, linkID, iID uuid.UUID, expiresAt time.Time) error
+
+	// IsRevokeLinkUsed returns whether the session revocation link with the given ID was used.
+	IsRevokeLinkUsed(ctx context.Context, linkID uuid.UUID) (bool, error)
 }
 
 type DevicePersister interface {
@@ -0,0 +1,349 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/container"
+	"github.com/ory/kratos/ui/node"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/decoderx"
+	"github.com/ory/x/sqlcon"
+	"github.com/ory/x/urlx"
+)
+
+const RouteRevokeLink = RouteCollection + "/revoke"
+
+// UsedRevokeLink records that a session revocation link was used, so that it can not be used again.
+type UsedRevokeLink struct {
+	ID         uuid.UUID `db:"id"`
+	NID        uuid.UUID `db:"nid"`
+	IdentityID uuid.UUID `db:"identity_id"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+func (UsedRevokeLink) TableName(context.Context) string {
+	return "session_revoke_links"
+}
+
+// Session Revocation Link
+//
+// swagger:model sessionRevokeLink
+type RevokeLink struct {
+	// ExpiresAt is the time at which the link expires.
+	//
+	// required: true
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// UI contains the form which confirms the revocation or, once confirmed, its result.
+	//
+	// required: true
+	UI *container.Container `json:"ui"`
+}
+
+// revokeLinkClaims are signed into the token of a session revocation link.
+type revokeLinkClaims struct {
+	ID         uuid.UUID `json:"jti"`
+	IdentityID uuid.UUID `json:"iid"`
+	ExpiresAt  int64     `json:"exp"`
+}
+
+// Update Session Revocation Link Body
+//
+// swagger:model updateSessionRevokeLinkBody
+type updateRevokeLinkBody struct {
+	// Token is the token of the link.
+	//
+	// required: true
+	Token string `json:"token" form:"token"`
+
+	// ResetPassword requires the identity to set a new password on its next login.
+	ResetPassword bool `json:"reset_password" form:"reset_password"`
+}
+
+var revokeLinkSchema = []byte(`{
+  "$id": "https://schemas.ory.sh/kratos/session/revoke_link.schema.json",
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["token"],
+  "properties": {
+    "token": {
+      "type": "string"
+    },
+    "reset_password": {
+      "type": "boolean"
+    }
+  }
+}`)
+
+func signRevokeLink(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewRevokeLink returns a single-use link which revokes all sessions of the identity once the
+// revocation is confirmed. The link is signed with the current session secret and expires after
+// the configured lifespan.
+func NewRevokeLink(ctx context.Context, c *config.Config, identityID uuid.UUID) (*url.URL, error) {
+	raw, err := json.Marshal(&revokeLinkClaims{
+		ID:         x.NewUUID(),
+		IdentityID: identityID,
+		ExpiresAt:  x.Now().Add(c.SessionRevokeLinkLifespan(ctx)).Unix(),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	token := payload + "." + signRevokeLink(c.SecretsSession(ctx)[0], payload)
+	return urlx.CopyWithQuery(urlx.AppendPaths(c.SelfPublicURL(ctx), RouteRevokeLink), url.Values{"token": {token}}), nil
+}
+
+// parseRevokeLink verifies the token of a session revocation link against all session secrets,
+// so links remain valid while secrets are rotated.
+func parseRevokeLink(ctx context.Context, c *config.Config, token string) (*revokeLinkClaims, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("The session revocation link is malformed."))
+	}
+
+	var valid bool
+	for _, secret := range c.SecretsSession(ctx) {
+		if hmac.Equal([]byte(signRevokeLink(secret, payload)), []byte(signature)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, errors.WithStack(herodot.ErrForbidden.WithReason("The session revocation link is invalid."))
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReason("The session revocation link is malformed."))
+	}
+
+	var claims revokeLinkClaims
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReason("The session revocation link is malformed."))
+	}
+
+	if x.Now().After(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, errors.WithStack(herodot.ErrForbidden.WithReason("The session revocation link expired."))
+	}
+
+	return &claims, nil
+}
+
+var errRevokeLinkUsed = herodot.ErrForbidden.WithReason("The session revocation link was already used.")
+
+func (h *Handler) checkRevokeLink(ctx context.Context, token string) (*revokeLinkClaims, error) {
+	claims, err := parseRevokeLink(ctx, h.r.Config(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := h.r.SessionPersister().IsRevokeLinkUsed(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	} else if used {
+		return nil, errors.WithStack(errRevokeLinkUsed)
+	}
+
+	return claims, nil
+}
+
+// swagger:parameters getSessionRevokeLink
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type getSessionRevokeLink struct {
+	// The token of the link.
+	//
+	// required: true
+	// in: query
+	Token string `json:"token"`
+}
+
+// swagger:route GET /sessions/revoke frontend getSessionRevokeLink
+//
+// # Get the Confirmation Form of a Session Revocation Link
+//
+// Security notifications contain links to this endpoint which revoke all sessions of the identity.
+// Browsers are redirected to the configured confirmation page, which fetches the confirmation
+// form from this endpoint using `Accept: application/json`. The sessions are only revoked once
+// the form is submitted, so that links opened by email scanners do not revoke them.
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: sessionRevokeLink
+//	  303: emptyResponse
+//	  400: errorGeneric
+//	  403: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) getRevokeLink(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+	token := r.URL.Query().Get("token")
+
+	claims, err := h.checkRevokeLink(ctx, token)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	if ui := h.r.Config().SessionRevokeLinkUIURL(ctx); ui != nil && x.IsBrowserRequest(r) {
+		http.Redirect(w, r, urlx.CopyWithQuery(ui, url.Values{"token": {token}}).String(), http.StatusSeeOther)
+		return
+	}
+
+	i, err := h.r.PrivilegedIdentityPool().GetIdentity(ctx, claims.IdentityID, identity.ExpandCredentials)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	form := &container.Container{
+		Method: "POST",
+		Action: urlx.AppendPaths(h.r.Config().SelfPublicURL(ctx), RouteRevokeLink).String(),
+	}
+	form.Messages.Add(text.NewInfoSelfServiceLogoutRevokeAllSessions())
+	form.Nodes.Append(node.NewInputField("token", token, node.DefaultGroup, node.InputAttributeTypeHidden))
+	if _, ok := i.GetCredentials(identity.CredentialsTypePassword); ok {
+		form.Nodes.Append(node.NewInputField("reset_password", false, node.DefaultGroup, node.InputAttributeTypeCheckbox).
+			WithMetaLabel(text.NewInfoNodeLabelRequirePasswordReset()))
+	}
+	form.Nodes.Append(node.NewInputField("revoke", "true", node.DefaultGroup, node.InputAttributeTypeSubmit).
+		WithMetaLabel(text.NewInfoNodeLabelRevokeAllSessions()))
+
+	h.r.Writer().Write(w, r, &RevokeLink{ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(), UI: form})
+}
+
+// swagger:parameters updateSessionRevokeLink
+//
+//nolint:deadcode,unused
+//lint:ignore U1000 Used to generate Swagger and OpenAPI definitions
+type updateSessionRevokeLink struct {
+	// in: body
+	// required: true
+	Body updateRevokeLinkBody
+}
+
+// swagger:route POST /sessions/revoke frontend updateSessionRevokeLink
+//
+// # Confirm a Session Revocation Link
+//
+// Revokes all sessions of the identity the link was issued for and, if requested, requires the
+// identity to set a new password on its next login. Each link can only be used once. Browsers
+// are redirected to the default return URL.
+//
+//	Consumes:
+//	- application/json
+//	- application/x-www-form-urlencoded
+//
+//	Produces:
+//	- application/json
+//
+//	Schemes: http, https
+//
+//	Responses:
+//	  200: sessionRevokeLink
+//	  303: emptyResponse
+//	  400: errorGeneric
+//	  403: errorGeneric
+//	  default: errorGeneric
+func (h *Handler) updateRevokeLink(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+
+	compiler, err := decoderx.HTTPRawJSONSchemaCompiler(revokeLinkSchema)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, errors.WithStack(err))
+		return
+	}
+
+	var body updateRevokeLinkBody
+	if err := h.dx.Decode(r, &body, compiler,
+		decoderx.HTTPDecoderAllowedMethods("POST"),
+		decoderx.HTTPDecoderJSONFollowsFormFormat()); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	claims, err := h.checkRevokeLink(ctx, body.Token)
+	if err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	var revoked int
+	if err := h.r.TransactionalPersisterProvider().Transaction(ctx, func(ctx context.Context, _ *pop.Connection) (err error) {
+		if err := h.r.SessionPersister().UseRevokeLink(ctx, claims.ID, claims.IdentityID, time.Unix(claims.ExpiresAt, 0)); errors.Is(err, sqlcon.ErrUniqueViolation) {
+			return errors.WithStack(errRevokeLinkUsed)
+		} else if err != nil {
+			return err
+		}
+
+		revoked, err = h.r.SessionPersister().RevokeSessionsIdentityExcept(ctx, claims.IdentityID, uuid.Nil)
+		if err != nil {
+			return err
+		}
+
+		if !body.ResetPassword {
+			return nil
+		}
+
+		i, err := h.r.PrivilegedIdentityPool().GetIdentity(ctx, claims.IdentityID, identity.ExpandCredentials)
+		if err != nil {
+			return err
+		}
+		if _, ok := i.GetCredentials(identity.CredentialsTypePassword); !ok {
+			return nil
+		}
+		i.PasswordResetRequired = true
+		return h.r.PrivilegedIdentityPool().UpdateIdentityColumns(ctx, i, "password_reset_required")
+	}); err != nil {
+		h.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	h.r.Audit().
+		WithRequest(r).
+		WithField("identity_id", claims.IdentityID).
+		WithField("revoked_sessions", revoked).
+		WithField("password_reset_required", body.ResetPassword).
+		Info("Revoked all sessions of the identity using a link from a security notification.")
+
+	if x.IsBrowserRequest(r) {
+		http.Redirect(w, r, h.r.Config().SelfServiceBrowserDefaultReturnTo(ctx).String(), http.StatusSeeOther)
+		return
+	}
+
+	result := &container.Container{Method: "POST", Action: urlx.AppendPaths(h.r.Config().SelfPublicURL(ctx), RouteRevokeLink).String()}
+	result.Messages.Add(text.NewInfoSelfServiceLogoutAllSessionsRevoked(revoked))
+	h.r.Writer().Write(w, r, &RevokeLink{ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(), UI: result})
+}
@@ -0,0 +1,216 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package session_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/x"
+)
+
+func TestRevokeLink(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	ts, _, _, _ := testhelpers.NewKratosServerWithCSRFAndRouters(t, reg)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	conf.MustSet(ctx, config.ViperKeyPublicBaseURL, ts.URL)
+	conf.MustSet(ctx, config.ViperKeySelfServiceBrowserDefaultReturnTo, "https://www.ory.sh/return")
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	setup := func(t *testing.T, withPassword bool) (*identity.Identity, []*session.Session) {
+		i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+		if withPassword {
+			i.SetCredentials(identity.CredentialsTypePassword, identity.Credentials{
+				Type:        identity.CredentialsTypePassword,
+				Identifiers: []string{x.NewUUID().String()},
+				Config:      []byte(`{"hashed_password":"$2a$04$zvZz1zV"}`),
+			})
+		}
+		require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+
+		sessions := make([]*session.Session, 2)
+		for k := range sessions {
+			s, err := testhelpers.NewActiveSession(testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil), reg, i, time.Now().UTC(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+			require.NoError(t, err)
+			require.NoError(t, reg.SessionPersister().UpsertSession(ctx, s))
+			sessions[k] = s
+		}
+		return i, sessions
+	}
+
+	newLink := func(t *testing.T, i *identity.Identity) (*url.URL, string) {
+		link, err := session.NewRevokeLink(ctx, conf, i.ID)
+		require.NoError(t, err)
+		return link, link.Query().Get("token")
+	}
+
+	do := func(t *testing.T, req *http.Request) (*http.Response, string) {
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+
+	get := func(t *testing.T, link string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", link, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+		return do(t, req)
+	}
+
+	submit := func(t *testing.T, values url.Values, accept string) (*http.Response, string) {
+		req, err := http.NewRequest("POST", ts.URL+session.RouteRevokeLink, strings.NewReader(values.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", accept)
+		return do(t, req)
+	}
+
+	isActive := func(t *testing.T, s *session.Session) bool {
+		actual, err := reg.SessionPersister().GetSession(ctx, s.ID, session.ExpandNothing)
+		require.NoError(t, err)
+		return actual.Active
+	}
+
+	t.Run("case=returns the confirmation form", func(t *testing.T) {
+		i, sessions := setup(t, true)
+		link, token := newLink(t, i)
+
+		res, body := get(t, link.String())
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.Equal(t, ts.URL+session.RouteRevokeLink, gjson.Get(body, "ui.action").String())
+		assert.EqualValues(t, text.InfoSelfServiceLogoutRevokeAllSessions, gjson.Get(body, "ui.messages.0.id").Int(), body)
+		assert.Equal(t, token, gjson.Get(body, `ui.nodes.#(attributes.name=="token").attributes.value`).String(), body)
+		assert.True(t, gjson.Get(body, `ui.nodes.#(attributes.name=="reset_password")`).Exists(), body)
+		assert.True(t, isActive(t, sessions[0]), "opening the link must not revoke sessions")
+	})
+
+	t.Run("case=omits the password reset without password credentials", func(t *testing.T) {
+		i, _ := setup(t, false)
+		link, _ := newLink(t, i)
+
+		res, body := get(t, link.String())
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.False(t, gjson.Get(body, `ui.nodes.#(attributes.name=="reset_password")`).Exists(), body)
+	})
+
+	t.Run("case=redirects browsers to the confirmation page", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionRevokeLinkUIURL, "https://www.ory.sh/revoke")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySessionRevokeLinkUIURL, "") })
+
+		i, _ := setup(t, false)
+		link, token := newLink(t, i)
+
+		req, err := http.NewRequest("GET", link.String(), nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "text/html")
+		res, _ := do(t, req)
+		assert.Equal(t, http.StatusSeeOther, res.StatusCode)
+		assert.Equal(t, "https://www.ory.sh/revoke?token="+url.QueryEscape(token), res.Header.Get("Location"))
+	})
+
+	t.Run("case=revokes all sessions", func(t *testing.T) {
+		i, sessions := setup(t, true)
+		_, token := newLink(t, i)
+
+		res, body := submit(t, url.Values{"token": {token}}, "application/json")
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.EqualValues(t, text.InfoSelfServiceLogoutAllSessionsRevoked, gjson.Get(body, "ui.messages.0.id").Int(), body)
+		assert.EqualValues(t, 2, gjson.Get(body, "ui.messages.0.context.revoked_sessions").Int(), body)
+		for _, s := range sessions {
+			assert.False(t, isActive(t, s))
+		}
+
+		actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.False(t, actual.PasswordResetRequired)
+	})
+
+	t.Run("case=requires a password reset", func(t *testing.T) {
+		i, sessions := setup(t, true)
+		_, token := newLink(t, i)
+
+		res, _ := submit(t, url.Values{"token": {token}, "reset_password": {"true"}}, "text/html")
+		assert.Equal(t, http.StatusSeeOther, res.StatusCode)
+		assert.Equal(t, "https://www.ory.sh/return", res.Header.Get("Location"))
+		assert.False(t, isActive(t, sessions[1]))
+
+		actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, i.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.True(t, actual.PasswordResetRequired)
+	})
+
+	t.Run("case=links can only be used once", func(t *testing.T) {
+		i, _ := setup(t, false)
+		link, token := newLink(t, i)
+
+		res, body := submit(t, url.Values{"token": {token}}, "application/json")
+		require.Equal(t, http.StatusOK, res.StatusCode, body)
+
+		res, body = submit(t, url.Values{"token": {token}}, "application/json")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, body)
+		res, body = get(t, link.String())
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, body)
+	})
+
+	t.Run("case=accepts links signed with rotated secrets", func(t *testing.T) {
+		i, sessions := setup(t, false)
+		_, token := newLink(t, i)
+
+		secrets := conf.SecretsSession(ctx)
+		conf.MustSet(ctx, config.ViperKeySecretsDefault, []string{"a-new-secret-of-32-characters-00", string(secrets[0])})
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySecretsDefault, []string{string(secrets[0])}) })
+
+		res, body := submit(t, url.Values{"token": {token}}, "application/json")
+		assert.Equal(t, http.StatusOK, res.StatusCode, body)
+		assert.False(t, isActive(t, sessions[0]))
+	})
+
+	t.Run("case=rejects tampered links", func(t *testing.T) {
+		i, sessions := setup(t, false)
+		link, token := newLink(t, i)
+
+		res, _ := get(t, link.String()+"x")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		res, _ = get(t, ts.URL+session.RouteRevokeLink+"?token=nope")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		res, _ = submit(t, url.Values{"token": {token + "x"}}, "application/json")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.True(t, isActive(t, sessions[0]))
+	})
+
+	t.Run("case=rejects expired links", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionRevokeLinkLifespan, "1ns")
+		t.Cleanup(func() { conf.MustSet(ctx, config.ViperKeySessionRevokeLinkLifespan, "") })
+
+		i, sessions := setup(t, false)
+		_, token := newLink(t, i)
+		time.Sleep(time.Second)
+
+		res, _ := submit(t, url.Values{"token": {token}}, "application/json")
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+		assert.True(t, isActive(t, sessions[0]))
+	})
+}
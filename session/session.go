@@ -25,18 +25,24 @@ import (
 	"github.com/ory/herodot"
 	"github.com/ory/kratos/identity"
 	"github.com/ory/x/randx"
+	"github.com/ory/x/sqlxx"
 )
 
 var ErrIdentityDisabled = herodot.ErrUnauthorized.WithError("identity is disabled").WithReason("This account was disabled.")
 
 type lifespanProvider interface {
 	SessionLifespan(ctx context.Context) time.Duration
+	SessionRememberMeShortLifespan(ctx context.Context) time.Duration
 }
 
 type refreshWindowProvider interface {
 	SessionRefreshMinTimeLeft(ctx context.Context) time.Duration
 }
 
+type idleTimeoutProvider interface {
+	SessionIdleTimeout(ctx context.Context) time.Duration
+}
+
 // Device corresponding to a Session
 //
 // swagger:model sessionDevice
@@ -58,6 +64,19 @@ type Device struct {
 	// Geo Location corresponding to the IP Address
 	Location *string `json:"location" faker:"ptr_geo_location" db:"location"`
 
+	// City corresponding to the IP Address, resolved from the configured GeoIP database
+	City *string `json:"city,omitempty" faker:"-" db:"city"`
+
+	// Country (ISO 3166-1 alpha-2 code) corresponding to the IP Address, resolved from the
+	// configured GeoIP database
+	Country *string `json:"country,omitempty" faker:"-" db:"country"`
+
+	// Autonomous System Number of the network the IP Address belongs to
+	ASN *int64 `json:"asn,omitempty" faker:"-" db:"asn"`
+
+	// Organization operating the autonomous system the IP Address belongs to
+	ASOrganization *string `json:"as_organization,omitempty" faker:"-" db:"as_organization"`
+
 	// Time of capture
 	CreatedAt time.Time `json:"-" faker:"-" db:"created_at"`
 
@@ -145,6 +164,41 @@ type Session struct {
 	// It is only set when the `tokenize` query parameter was set to a valid tokenize template during calls to `/session/whoami`.
 	Tokenized string `json:"tokenized,omitempty" faker:"-" db:"-"`
 
+	// PairwiseSubject is the pseudonymous identifier of the identity for the audience of the tokenize template.
+	//
+	// It is only set when the `tokenize_as` query parameter was set to a tokenize template with the `pairwise` subject type.
+	PairwiseSubject string `json:"pairwise_subject,omitempty" faker:"-" db:"-"`
+
+	// PasswordResetRequired is true if the identity must set a new password before this session
+	// can be used. Until then, the session is only accepted by the settings flow.
+	PasswordResetRequired bool `json:"password_reset_required,omitempty" faker:"-" db:"password_reset_required"`
+
+	// PasswordExpiresAt is when the password of the identity expires under the password expiry policy
+	// which was in place when this session was issued.
+	PasswordExpiresAt *sqlxx.NullTime `json:"-" faker:"-" db:"password_expires_at"`
+
+	// ShortLived is true if the user chose not to be remembered when logging in. The session then
+	// has the short lifespan and its cookie is removed when the browser is closed.
+	ShortLived bool `json:"-" faker:"-" db:"short_lived"`
+
+	// LastActiveAt is when the session was last used, recorded at most once per activity update
+	// interval and only if the idle timeout is set.
+	LastActiveAt *sqlxx.NullTime `json:"-" faker:"-" db:"last_active_at"`
+
+	// Metadata is set by login hooks. It is an object whose keys are the namespaces the hooks
+	// wrote to, for example `{"tenant": {"id": "acme"}}`.
+	Metadata sqlxx.NullJSONRawMessage `json:"metadata,omitempty" faker:"-" db:"metadata"`
+
+	// MFAEnrollment is set if the identity is asked to set up a second factor.
+	MFAEnrollment *MFAEnrollment `json:"mfa_enrollment,omitempty" faker:"-" db:"-"`
+
+	// PasswordExpiry is set if the password of the identity expires soon.
+	PasswordExpiry *PasswordExpiry `json:"password_expiry,omitempty" faker:"-" db:"-"`
+
+	// ReactivatedIdentityFrom is the state the identity was in before this session reactivated it.
+	// The identity is only reactivated once the session is persisted.
+	ReactivatedIdentityFrom identity.State `json:"-" faker:"-" db:"-"`
+
 	// The Session Token
 	//
 	// The token of this session.
@@ -171,7 +225,7 @@ func (s Session) TableName(ctx context.Context) string {
 }
 
 func (s *Session) CompletedLoginForMethod(method AuthenticationMethod) {
-	method.CompletedAt = time.Now().UTC()
+	method.CompletedAt = x.Now().UTC()
 	s.AMR = append(s.AMR, method)
 }
 
@@ -285,11 +339,46 @@ func (s Session) Declassified() *Session {
 }
 
 func (s *Session) IsActive() bool {
-	return s.Active && s.ExpiresAt.After(time.Now()) && (s.Identity == nil || s.Identity.IsActive())
+	return s.Active && s.ExpiresAt.After(x.Now()) && (s.Identity == nil || s.Identity.IsActive())
+}
+
+// LastActive returns when the session was last used or, if no use was recorded, authenticated.
+func (s *Session) LastActive() time.Time {
+	if s.LastActiveAt != nil && time.Time(*s.LastActiveAt).After(s.AuthenticatedAt) {
+		return time.Time(*s.LastActiveAt)
+	}
+	return s.AuthenticatedAt
+}
+
+// IsIdle returns true if the session was not used within the idle timeout.
+func (s *Session) IsIdle(ctx context.Context, c idleTimeoutProvider) bool {
+	timeout := c.SessionIdleTimeout(ctx)
+	return timeout > 0 && s.LastActive().Add(timeout).Before(x.Now())
+}
+
+// ExpiresAtOrIdle returns when the session expires or, if that happens earlier, when it becomes
+// idle unless it is used again.
+func (s *Session) ExpiresAtOrIdle(ctx context.Context, c idleTimeoutProvider) time.Time {
+	if timeout := c.SessionIdleTimeout(ctx); timeout > 0 {
+		if idleAt := s.LastActive().Add(timeout); idleAt.Before(s.ExpiresAt) {
+			return idleAt
+		}
+	}
+	return s.ExpiresAt
+}
+
+// IsUsable returns true if the session can authenticate requests: it is active, its identity is
+// active, and it was used within the idle timeout.
+func (s *Session) IsUsable(ctx context.Context, c idleTimeoutProvider) bool {
+	return s.IsActive() && !s.IsIdle(ctx, c)
 }
 
 func (s *Session) Refresh(ctx context.Context, c lifespanProvider) *Session {
-	s.ExpiresAt = time.Now().Add(c.SessionLifespan(ctx)).UTC()
+	lifespan := c.SessionLifespan(ctx)
+	if s.ShortLived {
+		lifespan = c.SessionRememberMeShortLifespan(ctx)
+	}
+	s.ExpiresAt = x.Now().Add(lifespan).UTC()
 	return s
 }
 
@@ -297,11 +386,14 @@ func (s *Session) MarshalJSON() ([]byte, error) {
 	type ss Session
 	out := ss(*s)
 	out.Active = s.IsActive()
+	if string(out.Metadata) == "null" {
+		out.Metadata = nil
+	}
 	return json.Marshal(out)
 }
 
 func (s *Session) CanBeRefreshed(ctx context.Context, c refreshWindowProvider) bool {
-	return s.ExpiresAt.Add(-c.SessionRefreshMinTimeLeft(ctx)).Before(time.Now())
+	return s.ExpiresAt.Add(-c.SessionRefreshMinTimeLeft(ctx)).Before(x.Now())
 }
 
 // List of (Used) AuthenticationMethods
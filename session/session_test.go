@@ -20,6 +20,8 @@ import (
 	"github.com/ory/kratos/internal"
 	"github.com/ory/kratos/internal/testhelpers"
 	"github.com/ory/kratos/session"
+	"github.com/ory/x/pointerx"
+	"github.com/ory/x/sqlxx"
 )
 
 func TestSession(t *testing.T) {
@@ -53,6 +55,40 @@ func TestSession(t *testing.T) {
 		assert.False(t, (&session.Session{Active: true}).IsActive())
 	})
 
+	t.Run("case=idle", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "10m")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "0s")
+		})
+
+		s := &session.Session{AuthenticatedAt: time.Now().Add(-time.Hour)}
+		assert.True(t, s.IsIdle(ctx, conf))
+
+		s.LastActiveAt = pointerx.Ptr(sqlxx.NullTime(time.Now().Add(-time.Minute)))
+		assert.False(t, s.IsIdle(ctx, conf))
+		assert.Equal(t, time.Time(*s.LastActiveAt), s.LastActive())
+
+		conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "0s")
+		assert.False(t, (&session.Session{AuthenticatedAt: time.Now().Add(-time.Hour)}).IsIdle(ctx, conf))
+	})
+
+	t.Run("case=usable", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "10m")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySessionIdleTimeout, "0s")
+		})
+
+		s := &session.Session{Active: true, ExpiresAt: time.Now().Add(time.Hour), AuthenticatedAt: time.Now()}
+		assert.True(t, s.IsUsable(ctx, conf))
+
+		s.AuthenticatedAt = time.Now().Add(-time.Hour)
+		assert.False(t, s.IsUsable(ctx, conf), "idle sessions are not usable")
+
+		s.AuthenticatedAt = time.Now()
+		s.Identity = &identity.Identity{State: identity.StateInactive}
+		assert.False(t, s.IsUsable(ctx, conf), "sessions of inactive identities are not usable")
+	})
+
 	t.Run("case=amr", func(t *testing.T) {
 		s := session.NewInactiveSession()
 		s.CompletedLoginFor(identity.CredentialsTypeOIDC, identity.AuthenticatorAssuranceLevel1)
@@ -80,6 +116,45 @@ func TestSession(t *testing.T) {
 		assert.Empty(t, s.AuthenticatedAt)
 	})
 
+	t.Run("case=activate depends on the login behavior of the state", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyIdentityLifecycleLoginBehavior+".archived", "reactivate")
+		conf.MustSet(ctx, config.ViperKeyIdentityLifecycleLoginBehavior+".inactive", "deny")
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyIdentityLifecycleLoginBehavior+".archived", "deny")
+		})
+		req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+
+		create := func(t *testing.T, state identity.State) *identity.Identity {
+			i := identity.NewIdentity(config.DefaultIdentityTraitsSchemaID)
+			i.Traits = identity.Traits(`{}`)
+			i.State = state
+			require.NoError(t, reg.PrivilegedIdentityPool().CreateIdentity(ctx, i))
+			return i
+		}
+
+		archived := create(t, identity.StateArchived)
+		s := session.NewInactiveSession()
+		require.NoError(t, reg.SessionManager().ActivateSession(req, s, archived, authAt))
+		assert.True(t, s.Active)
+		assert.Equal(t, identity.StateActive, s.Identity.State)
+
+		actual, err := reg.PrivilegedIdentityPool().GetIdentity(ctx, archived.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.Equal(t, identity.StateArchived, actual.State, "the identity is only reactivated once the session is persisted")
+
+		require.NoError(t, reg.SessionPersister().UpsertSession(ctx, s))
+		actual, err = reg.PrivilegedIdentityPool().GetIdentity(ctx, archived.ID, identity.ExpandNothing)
+		require.NoError(t, err)
+		assert.Equal(t, identity.StateActive, actual.State)
+		assert.Equal(t, identity.StateReasonLogin, actual.StateReason)
+
+		for _, state := range []identity.State{identity.StateInactive, identity.StateLocked} {
+			s := session.NewInactiveSession()
+			require.ErrorIs(t, reg.SessionManager().ActivateSession(req, s, create(t, state), authAt), session.ErrIdentityDisabled, "%s", state)
+			assert.False(t, s.Active)
+		}
+	})
+
 	t.Run("case=client information reverse proxy forward", func(t *testing.T) {
 		for _, tc := range []struct {
 			input    string
@@ -0,0 +1,38 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build testclock
+
+package session_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/internal/testhelpers"
+	"github.com/ory/kratos/x"
+)
+
+func TestSessionWithTestClock(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	testhelpers.SetDefaultIdentitySchema(conf, "file://./stub/identity.schema.json")
+	conf.MustSet(ctx, config.ViperKeySessionLifespan, "1h")
+	t.Cleanup(x.ResetClock)
+
+	i := &identity.Identity{State: identity.StateActive, NID: x.NewUUID()}
+	req := testhelpers.NewTestHTTPRequest(t, "GET", "/sessions/whoami", nil)
+	s, err := testhelpers.NewActiveSession(req, reg, i, x.Now(), identity.CredentialsTypePassword, identity.AuthenticatorAssuranceLevel1)
+	require.NoError(t, err)
+	assert.True(t, s.IsActive())
+
+	x.AdvanceClock(2 * time.Hour)
+	assert.False(t, s.IsActive(), "the session expires without sleeping")
+}
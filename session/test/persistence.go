@@ -513,6 +513,61 @@ func TestPersister(ctx context.Context, conf *config.Config, p interface {
 			}
 		})
 
+		t.Run("method=revoke excess sessions for identity", func(t *testing.T) {
+			createSessions := func(t *testing.T) []session.Session {
+				sessions := make([]session.Session, 3)
+				for i := range sessions {
+					require.NoError(t, faker.FakeData(&sessions[i]))
+				}
+				require.NoError(t, p.CreateIdentity(ctx, sessions[0].Identity))
+				for i := range sessions {
+					sessions[i].IdentityID, sessions[i].Identity = sessions[0].IdentityID, sessions[0].Identity
+					sessions[i].Active = true
+					sessions[i].ExpiresAt = time.Now().UTC().Add(time.Hour)
+					sessions[i].CreatedAt = time.Now().UTC().Add(-time.Duration(len(sessions)-i) * time.Hour)
+					require.NoError(t, p.UpsertSession(ctx, &sessions[i]))
+				}
+				return sessions
+			}
+
+			assertActive := func(t *testing.T, sessions []session.Session, expected ...bool) {
+				for k, s := range sessions {
+					actual, err := p.GetSession(ctx, s.ID, session.ExpandNothing)
+					require.NoError(t, err)
+					assert.Equal(t, expected[k], actual.Active, "session %d", k)
+				}
+			}
+
+			t.Run("case=by issue time", func(t *testing.T) {
+				sessions := createSessions(t)
+
+				_, other := testhelpers.NewNetwork(t, ctx, p)
+				n, err := other.RevokeExcessSessions(ctx, sessions[0].IdentityID, sessions[2].ID, 0, false)
+				require.NoError(t, err)
+				assert.Equal(t, 0, n)
+				assertActive(t, sessions, true, true, true)
+
+				n, err = p.RevokeExcessSessions(ctx, sessions[0].IdentityID, sessions[2].ID, 2, false)
+				require.NoError(t, err)
+				assert.Equal(t, 0, n)
+
+				n, err = p.RevokeExcessSessions(ctx, sessions[0].IdentityID, sessions[2].ID, 1, false)
+				require.NoError(t, err)
+				assert.Equal(t, 1, n)
+				assertActive(t, sessions, false, true, true)
+			})
+
+			t.Run("case=by last use", func(t *testing.T) {
+				sessions := createSessions(t)
+				require.NoError(t, p.GetConnection(ctx).RawQuery("UPDATE sessions SET updated_at = ? WHERE id = ?", time.Now().UTC().Add(time.Minute), sessions[0].ID).Exec())
+
+				n, err := p.RevokeExcessSessions(ctx, sessions[0].IdentityID, sessions[2].ID, 1, true)
+				require.NoError(t, err)
+				assert.Equal(t, 1, n)
+				assertActive(t, sessions, true, false, true)
+			})
+		})
+
 		t.Run("method=revoke specific session for identity", func(t *testing.T) {
 			sessions := make([]session.Session, 2)
 			for i := range sessions {
@@ -582,6 +637,31 @@ func TestPersister(ctx context.Context, conf *config.Config, p interface {
 			require.Error(t, err)
 		})
 
+		t.Run("case=use revoke link", func(t *testing.T) {
+			var i identity.Identity
+			require.NoError(t, faker.FakeData(&i))
+			require.NoError(t, p.CreateIdentity(ctx, &i))
+
+			linkID := x.NewUUID()
+			used, err := p.IsRevokeLinkUsed(ctx, linkID)
+			require.NoError(t, err)
+			assert.False(t, used)
+
+			require.NoError(t, p.UseRevokeLink(ctx, linkID, i.ID, time.Now().Add(time.Hour)))
+			used, err = p.IsRevokeLinkUsed(ctx, linkID)
+			require.NoError(t, err)
+			assert.True(t, used)
+
+			assert.ErrorIs(t, p.UseRevokeLink(ctx, linkID, i.ID, time.Now().Add(time.Hour)), sqlcon.ErrUniqueViolation)
+
+			t.Run("on another network", func(t *testing.T) {
+				_, other := testhelpers.NewNetwork(t, ctx, p)
+				used, err := other.IsRevokeLinkUsed(ctx, linkID)
+				require.NoError(t, err)
+				assert.False(t, used)
+			})
+		})
+
 		t.Run("network isolation", func(t *testing.T) {
 			nid1, p := testhelpers.NewNetwork(t, ctx, p)
 			nid2, _ := testhelpers.NewNetwork(t, ctx, p)
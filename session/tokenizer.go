@@ -5,6 +5,9 @@ package session
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"time"
 
@@ -23,6 +26,7 @@ import (
 	"github.com/ory/x/jsonnetsecure"
 	"github.com/ory/x/jwksx"
 	"github.com/ory/x/otelx"
+	"github.com/ory/x/stringsx"
 )
 
 type (
@@ -56,6 +60,15 @@ func (s *Tokenizer) SetNowFunc(t func() time.Time) {
 	s.nowFunc = t
 }
 
+// PairwiseSubject derives a stable pseudonymous identifier of an identity for the given audience.
+// Different audiences receive different identifiers which can not be correlated without the secret.
+func PairwiseSubject(secret []byte, audience string, identityID uuid.UUID) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(audience))
+	_, _ = mac.Write(identityID.Bytes())
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
 func (s *Tokenizer) TokenizeSession(ctx context.Context, template string, session *Session) (err error) {
 	ctx, span := s.r.Tracer(ctx).Tracer().Start(ctx, "sessions.ManagerHTTP.TokenizeSession")
 	defer otelx.End(span, &err)
@@ -89,6 +102,16 @@ func (s *Tokenizer) TokenizeSession(ctx context.Context, template string, sessio
 		return err
 	}
 
+	subject := session.IdentityID.String()
+	switch tpl.SubjectType {
+	case "", config.SessionTokenizeSubjectTypePublic:
+	case config.SessionTokenizeSubjectTypePairwise:
+		subject = PairwiseSubject(s.r.Config().SecretsPairwise(ctx)[0], stringsx.Coalesce(tpl.Audience, template), session.IdentityID)
+		session.PairwiseSubject = subject
+	default:
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unknown subject type \"%s\" in tokenizer template \"%s\".", tpl.SubjectType, template))
+	}
+
 	now := s.nowFunc()
 	token := jwt.New(alg)
 	token.Header["kid"] = key.KeyID()
@@ -96,11 +119,14 @@ func (s *Tokenizer) TokenizeSession(ctx context.Context, template string, sessio
 		"jti": uuid.Must(uuid.NewV4()).String(),
 		"iss": s.r.Config().SelfPublicURL(ctx).String(),
 		"exp": now.Add(tpl.TTL).Unix(),
-		"sub": session.IdentityID.String(),
+		"sub": subject,
 		"sid": session.ID.String(),
 		"nbf": now.Unix(),
 		"iat": now.Unix(),
 	}
+	if tpl.Audience != "" {
+		claims["aud"] = tpl.Audience
+	}
 
 	if mapper := tpl.ClaimsMapperURL; len(mapper) > 0 {
 		sessionRaw, err := json.Marshal(session)
@@ -135,7 +161,7 @@ func (s *Tokenizer) TokenizeSession(ctx context.Context, template string, sessio
 			return errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReasonf("Unable to encode tokenized claims."))
 		}
 
-		claims["sub"] = session.IdentityID.String()
+		claims["sub"] = subject
 	}
 
 	var privateKey interface{}
@@ -103,6 +103,56 @@ func TestTokenizer(t *testing.T) {
 		snapshotx.SnapshotT(t, token.Claims, snapshotx.ExceptPaths("jti"))
 	})
 
+	t.Run("case=pairwise-subject", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySecretsPairwise, []string{"pairwise-secret-pairwise-secret"})
+		tokenize := func(t *testing.T, tid, audience string, s *session.Session) jwt.MapClaims {
+			conf.MustSet(ctx, config.ViperKeySessionTokenizerTemplates+"."+tid, &config.SessionTokenizeFormat{
+				TTL:         time.Minute,
+				JWKSURL:     "file://stub/jwk.es256.json",
+				SubjectType: config.SessionTokenizeSubjectTypePairwise,
+				Audience:    audience,
+			})
+			require.NoError(t, tkn.TokenizeSession(ctx, tid, s))
+			return validateTokenized(t, s.Tokenized, es256Key).Claims.(jwt.MapClaims)
+		}
+
+		claims := tokenize(t, "pairwise-a", "https://a.example.org", s)
+		assert.Equal(t, s.PairwiseSubject, claims["sub"])
+		assert.Equal(t, session.PairwiseSubject([]byte("pairwise-secret-pairwise-secret"), "https://a.example.org", i.ID), claims["sub"])
+		assert.NotContains(t, claims["sub"], i.ID.String())
+		assert.Equal(t, "https://a.example.org", claims["aud"])
+
+		t.Run("case=is stable for the same audience", func(t *testing.T) {
+			assert.Equal(t, claims["sub"], tokenize(t, "pairwise-a2", "https://a.example.org", s)["sub"])
+		})
+
+		t.Run("case=differs between audiences", func(t *testing.T) {
+			other := tokenize(t, "pairwise-b", "", s)
+			assert.NotEqual(t, claims["sub"], other["sub"])
+			assert.Equal(t, session.PairwiseSubject([]byte("pairwise-secret-pairwise-secret"), "pairwise-b", i.ID), other["sub"], "the audience defaults to the template name")
+			assert.Nil(t, other["aud"])
+		})
+
+		t.Run("case=differs between identities", func(t *testing.T) {
+			other := *s
+			other.IdentityID = uuid.Must(uuid.NewV4())
+			assert.NotEqual(t, claims["sub"], tokenize(t, "pairwise-a", "https://a.example.org", &other)["sub"])
+		})
+
+		t.Run("case=is not overwritten by the claims mapper", func(t *testing.T) {
+			tid := "pairwise-jsonnet"
+			conf.MustSet(ctx, config.ViperKeySessionTokenizerTemplates+"."+tid, &config.SessionTokenizeFormat{
+				TTL:             time.Minute,
+				JWKSURL:         "file://stub/jwk.es256.json",
+				ClaimsMapperURL: "file://stub/rs512-template.jsonnet",
+				SubjectType:     config.SessionTokenizeSubjectTypePairwise,
+			})
+			require.NoError(t, tkn.TokenizeSession(ctx, tid, s))
+			claims := validateTokenized(t, s.Tokenized, es256Key).Claims.(jwt.MapClaims)
+			assert.Equal(t, session.PairwiseSubject([]byte("pairwise-secret-pairwise-secret"), tid, i.ID), claims["sub"])
+		})
+	})
+
 	t.Run("case=es512-without-jsonnet", func(t *testing.T) {
 		tid := "es512-no-template"
 		setTokenizeConfig(conf, tid, "jwk.es512.json", "")
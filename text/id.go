@@ -9,34 +9,45 @@ package text
 type ID int
 
 const (
-	InfoSelfServiceLoginRoot                 ID = 1010000 + iota // 1010000
-	InfoSelfServiceLogin                                         // 1010001
-	InfoSelfServiceLoginWith                                     // 1010002
-	InfoSelfServiceLoginReAuth                                   // 1010003
-	InfoSelfServiceLoginMFA                                      // 1010004
-	InfoSelfServiceLoginVerify                                   // 1010005
-	InfoSelfServiceLoginTOTPLabel                                // 1010006
-	InfoLoginLookupLabel                                         // 1010007
-	InfoSelfServiceLoginWebAuthn                                 // 1010008
-	InfoLoginTOTP                                                // 1010009
-	InfoLoginLookup                                              // 1010010
-	InfoSelfServiceLoginContinueWebAuthn                         // 1010011
-	InfoSelfServiceLoginWebAuthnPasswordless                     // 1010012
-	InfoSelfServiceLoginContinue                                 // 1010013
-	InfoSelfServiceLoginCodeSent                                 // 1010014
-	InfoSelfServiceLoginCode                                     // 1010015
-	InfoSelfServiceLoginLink                                     // 1010016
-	InfoSelfServiceLoginAndLink                                  // 1010017
-	InfoSelfServiceLoginWithAndLink                              // 1010018
-	InfoSelfServiceLoginCodeMFA                                  // 1010019
-	InfoSelfServiceLoginCodeMFAHint                              // 1010020
-	InfoSelfServiceLoginPasskey                                  // 1010021
-	InfoSelfServiceLoginPassword                                 // 1010022
-	InfoSelfServiceLoginAAL2CodeAddress                          // 1010023
+	InfoSelfServiceLoginRoot                     ID = 1010000 + iota // 1010000
+	InfoSelfServiceLogin                                             // 1010001
+	InfoSelfServiceLoginWith                                         // 1010002
+	InfoSelfServiceLoginReAuth                                       // 1010003
+	InfoSelfServiceLoginMFA                                          // 1010004
+	InfoSelfServiceLoginVerify                                       // 1010005
+	InfoSelfServiceLoginTOTPLabel                                    // 1010006
+	InfoLoginLookupLabel                                             // 1010007
+	InfoSelfServiceLoginWebAuthn                                     // 1010008
+	InfoLoginTOTP                                                    // 1010009
+	InfoLoginLookup                                                  // 1010010
+	InfoSelfServiceLoginContinueWebAuthn                             // 1010011
+	InfoSelfServiceLoginWebAuthnPasswordless                         // 1010012
+	InfoSelfServiceLoginContinue                                     // 1010013
+	InfoSelfServiceLoginCodeSent                                     // 1010014
+	InfoSelfServiceLoginCode                                         // 1010015
+	InfoSelfServiceLoginLink                                         // 1010016
+	InfoSelfServiceLoginAndLink                                      // 1010017
+	InfoSelfServiceLoginWithAndLink                                  // 1010018
+	InfoSelfServiceLoginCodeMFA                                      // 1010019
+	InfoSelfServiceLoginCodeMFAHint                                  // 1010020
+	InfoSelfServiceLoginPasskey                                      // 1010021
+	InfoSelfServiceLoginPassword                                     // 1010022
+	InfoSelfServiceLoginAAL2CodeAddress                              // 1010023
+	InfoSelfServiceLoginCrossDeviceUserCode                          // 1010024
+	InfoSelfServiceLoginCrossDeviceQRCode                            // 1010025
+	InfoSelfServiceLoginCrossDeviceApprove                           // 1010026
+	InfoSelfServiceLoginCrossDeviceReject                            // 1010027
+	InfoSelfServiceLoginCrossDeviceApproved                          // 1010028
+	InfoSelfServiceLoginCrossDeviceRejected                          // 1010029
+	InfoSelfServiceLoginDeviceKey                                    // 1010030
+	InfoSelfServiceLoginExternalMFA                                  // 1010031
+	InfoSelfServiceLoginMoreFirstFactorsRequired                     // 1010032
 )
 
 const (
-	InfoSelfServiceLogout ID = 1020000 + iota
+	InfoSelfServiceLogout                   ID = 1020000 + iota // 1020000
+	InfoSelfServiceLogoutRevokeAllSessions                      // 1020001
+	InfoSelfServiceLogoutAllSessionsRevoked                     // 1020002
 )
 
 const (
@@ -54,6 +65,7 @@ const (
 	InfoSelfServiceRegistrationRegisterPasskey                       // 1040007
 	InfoSelfServiceRegistrationBack                                  // 1040008
 	InfoSelfServiceRegistrationChooseCredentials                     // 1040009
+	InfoSelfServiceRegistrationIdentitySchema                        // 1040010
 )
 
 const (
@@ -78,6 +90,14 @@ const (
 	InfoSelfServiceSettingsRemoveWebAuthn
 	InfoSelfServiceSettingsRegisterPasskey
 	InfoSelfServiceSettingsRemovePasskey
+	InfoSelfServiceSettingsRegisterDeviceKey
+	InfoSelfServiceSettingsRegisterDeviceKeyDisplayName
+	InfoSelfServiceSettingsRemoveDeviceKey
+	InfoSelfServiceSettingsVerifyTraitChange
+	InfoSelfServiceSettingsPasskeyEnrollment
+	InfoSelfServiceSettingsLookupSecretUnused
+	InfoSelfServiceSettingsLookupSecretStatus
+	InfoSelfServiceSettingsProfileCompletion
 )
 
 const (
@@ -85,6 +105,7 @@ const (
 	InfoSelfServiceRecoverySuccessful                            // 1060001
 	InfoSelfServiceRecoveryEmailSent                             // 1060002
 	InfoSelfServiceRecoveryEmailWithCodeSent                     // 1060003
+	InfoSelfServiceRecoveryPushApproved                          // 1060004
 )
 
 const (
@@ -104,6 +125,9 @@ const (
 	InfoNodeLabelLoginCode                                  // 1070013
 	InfoNodeLabelLoginAndLinkCredential                     // 1070014
 	InfoNodeLabelCaptcha                                    // 1070015
+	InfoNodeLabelRememberMe                                 // 1070016
+	InfoNodeLabelRevokeAllSessions                          // 1070017
+	InfoNodeLabelRequirePasswordReset                       // 1070018
 )
 
 const (
@@ -153,6 +177,14 @@ const (
 	ErrorValidationTraitsMismatch
 	ErrorValidationAccountNotFound
 	ErrorValidationCaptchaError
+	ErrorValidationNoDeviceKey
+	ErrorValidationDeviceKeySignatureInvalid
+	ErrorValidationDeviceKeyInvalid
+	ErrorValidationWebAuthnAuthenticatorNotAllowed
+	ErrorValidationDisposableEmail
+	ErrorValidationOIDCEmailDomainNotAllowed
+	ErrorValidationNoExternalMFA
+	ErrorValidationExternalMFADenied
 )
 
 const (
@@ -167,6 +199,9 @@ const (
 	ErrorValidationLoginCodeInvalidOrAlreadyUsed                        // 4010008
 	ErrorValidationLoginLinkedCredentialsDoNotMatch                     // 4010009
 	ErrorValidationLoginAddressUnknown                                  // 4010010
+	ErrorValidationLoginMoreFirstFactorsRequired                        // 4010011 (replaced by InfoSelfServiceLoginMoreFirstFactorsRequired)
+	ErrorValidationLoginTemporaryPasswordExpired                        // 4010012
+	ErrorValidationLoginTooManySessions                                 // 4010013
 )
 
 const (
@@ -179,6 +214,9 @@ const (
 const (
 	ErrorValidationSettings ID = 4050000 + iota
 	ErrorValidationSettingsFlowExpired
+	ErrorValidationSettingsVerificationCodeInvalid
+	ErrorValidationSettingsTraitChangeDiscarded
+	ErrorValidationSettingsLinkAddressNotVerified
 )
 
 const (
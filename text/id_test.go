@@ -26,6 +26,7 @@ func TestIDs(t *testing.T) {
 	assert.Equal(t, 1060001, int(InfoSelfServiceRecoverySuccessful))
 	assert.Equal(t, 1060002, int(InfoSelfServiceRecoveryEmailSent))
 	assert.Equal(t, 1060003, int(InfoSelfServiceRecoveryEmailWithCodeSent))
+	assert.Equal(t, 1060004, int(InfoSelfServiceRecoveryPushApproved))
 
 	assert.Equal(t, 1070000, int(InfoNodeLabel))
 	assert.Equal(t, 1070001, int(InfoNodeLabelInputPassword))
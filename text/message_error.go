@@ -12,14 +12,20 @@ const (
 	ErrIDSelfServiceBrowserLocationChangeRequiredError = "browser_location_change_required"
 	ErrIDSelfServiceFlowReplaced                       = "self_service_flow_replaced"
 
-	ErrIDAlreadyLoggedIn             = "session_already_available"
-	ErrIDAddressNotVerified          = "session_verified_address_required"
-	ErrIDSessionHasAALAlready        = "session_aal_already_fulfilled"
-	ErrIDSessionRequiredForHigherAAL = "session_aal1_required"
-	ErrIDHigherAALRequired           = "session_aal2_required"
-	ErrNoActiveSession               = "session_inactive"
-	ErrIDRedirectURLNotAllowed       = "self_service_flow_return_to_forbidden"
-	ErrIDInitiatedBySomeoneElse      = "security_identity_mismatch"
+	ErrIDAlreadyLoggedIn              = "session_already_available"
+	ErrIDAddressNotVerified           = "session_verified_address_required"
+	ErrIDSessionHasAALAlready         = "session_aal_already_fulfilled"
+	ErrIDSessionRequiredForHigherAAL  = "session_aal1_required"
+	ErrIDHigherAALRequired            = "session_aal2_required"
+	ErrIDMFAEnrollmentRequired        = "session_mfa_enrollment_required"
+	ErrIDAuthenticationMethodRequired = "session_authentication_method_required"
+	ErrIDPasswordResetRequired        = "session_password_reset_required"
+	ErrNoActiveSession                = "session_inactive"
+	ErrIDRedirectURLNotAllowed        = "self_service_flow_return_to_forbidden"
+	ErrIDInitiatedBySomeoneElse       = "security_identity_mismatch"
 
 	ErrIDCSRF = "security_csrf_violation"
+
+	ErrIDCrossDeviceLoginPending  = "cross_device_login_pending"
+	ErrIDCrossDeviceLoginRejected = "cross_device_login_rejected"
 )
@@ -5,6 +5,7 @@ package text
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -272,6 +273,29 @@ func NewErrorValidationAddressUnknown() *Message {
 	}
 }
 
+func NewErrorValidationLoginTemporaryPasswordExpired(expiredAt time.Time) *Message {
+	return &Message{
+		ID:   ErrorValidationLoginTemporaryPasswordExpired,
+		Text: "Your temporary password has expired. Please recover your account or ask an administrator for a new password.",
+		Type: Error,
+		Context: context(map[string]any{
+			"expired_at":      expiredAt,
+			"expired_at_unix": expiredAt.Unix(),
+		}),
+	}
+}
+
+func NewErrorValidationLoginTooManySessions(maxSessions int) *Message {
+	return &Message{
+		ID:   ErrorValidationLoginTooManySessions,
+		Text: fmt.Sprintf("You are signed in on too many devices. Sign out on another device before signing in here, at most %d sessions are allowed.", maxSessions),
+		Type: Error,
+		Context: context(map[string]any{
+			"max_sessions": maxSessions,
+		}),
+	}
+}
+
 func NewInfoSelfServiceLoginCodeMFA() *Message {
 	return &Message{
 		ID:   InfoSelfServiceLoginCodeMFA,
@@ -291,3 +315,85 @@ func NewInfoSelfServiceLoginAAL2CodeAddress(channel string, to string) *Message
 		}),
 	}
 }
+
+func NewInfoSelfServiceLoginDeviceKey() *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginDeviceKey,
+		Type: Info,
+		Text: "Confirm with this device",
+	}
+}
+
+func NewInfoSelfServiceLoginExternalMFA(provider string) *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginExternalMFA,
+		Type: Info,
+		Text: fmt.Sprintf("Verify with %s", provider),
+		Context: context(map[string]any{
+			"provider": provider,
+		}),
+	}
+}
+
+func NewInfoSelfServiceLoginMoreFirstFactorsRequired(remaining int, methods []string) *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginMoreFirstFactorsRequired,
+		Text: fmt.Sprintf("Your account requires %d additional sign in method(s). Please continue with one of: %s.", remaining, strings.Join(methods, ", ")),
+		Type: Info,
+		Context: context(map[string]any{
+			"remaining": remaining,
+			"methods":   methods,
+		}),
+	}
+}
+
+func NewInfoSelfServiceLoginCrossDeviceUserCode(code string) *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginCrossDeviceUserCode,
+		Type: Info,
+		Text: fmt.Sprintf("To sign in on this device, scan the QR code or open the sign in page on another device and enter the code %s.", code),
+		Context: context(map[string]any{
+			"user_code": code,
+		}),
+	}
+}
+
+func NewInfoSelfServiceLoginCrossDeviceQRCode() *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginCrossDeviceQRCode,
+		Type: Info,
+		Text: "Scan this QR code with a device on which you are already signed in.",
+	}
+}
+
+func NewInfoSelfServiceLoginCrossDeviceApprove() *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginCrossDeviceApprove,
+		Type: Info,
+		Text: "Allow sign in",
+	}
+}
+
+func NewInfoSelfServiceLoginCrossDeviceReject() *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginCrossDeviceReject,
+		Type: Info,
+		Text: "Deny sign in",
+	}
+}
+
+func NewInfoSelfServiceLoginCrossDeviceApproved() *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginCrossDeviceApproved,
+		Type: Info,
+		Text: "You approved the sign in. You can now continue on the other device.",
+	}
+}
+
+func NewInfoSelfServiceLoginCrossDeviceRejected() *Message {
+	return &Message{
+		ID:   InfoSelfServiceLoginCrossDeviceRejected,
+		Type: Info,
+		Text: "You denied the sign in on the other device.",
+	}
+}
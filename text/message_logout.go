@@ -0,0 +1,23 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package text
+
+func NewInfoSelfServiceLogoutRevokeAllSessions() *Message {
+	return &Message{
+		ID:   InfoSelfServiceLogoutRevokeAllSessions,
+		Text: "If you do not recognize a recent sign-in to your account, sign out of all sessions.",
+		Type: Info,
+	}
+}
+
+func NewInfoSelfServiceLogoutAllSessionsRevoked(revoked int) *Message {
+	return &Message{
+		ID:   InfoSelfServiceLogoutAllSessionsRevoked,
+		Text: "You were signed out of all sessions.",
+		Type: Info,
+		Context: context(map[string]any{
+			"revoked_sessions": revoked,
+		}),
+	}
+}
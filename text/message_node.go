@@ -86,6 +86,30 @@ func NewInfoNodeLabelContinue() *Message {
 	}
 }
 
+func NewInfoNodeLabelRememberMe() *Message {
+	return &Message{
+		ID:   InfoNodeLabelRememberMe,
+		Text: "Remember me",
+		Type: Info,
+	}
+}
+
+func NewInfoNodeLabelRevokeAllSessions() *Message {
+	return &Message{
+		ID:   InfoNodeLabelRevokeAllSessions,
+		Text: "Sign out everywhere",
+		Type: Info,
+	}
+}
+
+func NewInfoNodeLabelRequirePasswordReset() *Message {
+	return &Message{
+		ID:   InfoNodeLabelRequirePasswordReset,
+		Text: "Require a new password",
+		Type: Info,
+	}
+}
+
 func NewInfoNodeLabelID() *Message {
 	return &Message{
 		ID:   InfoNodeLabelID,
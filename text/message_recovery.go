@@ -50,6 +50,14 @@ func NewRecoveryEmailWithCodeSent() *Message {
 	}
 }
 
+func NewRecoveryPushApproved() *Message {
+	return &Message{
+		ID:   InfoSelfServiceRecoveryPushApproved,
+		Type: Info,
+		Text: "The recovery request has been approved on one of your devices. Continue to recover your account.",
+	}
+}
+
 func NewErrorValidationRecoveryTokenInvalidOrAlreadyUsed() *Message {
 	return &Message{
 		ID:   ErrorValidationRecoveryTokenInvalidOrAlreadyUsed,
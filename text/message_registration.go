@@ -52,6 +52,17 @@ func NewInfoSelfServiceChooseCredentials() *Message {
 	}
 }
 
+func NewInfoRegistrationIdentitySchema(schemaID string) *Message {
+	return &Message{
+		ID:   InfoSelfServiceRegistrationIdentitySchema,
+		Text: fmt.Sprintf("Sign up as %s", schemaID),
+		Type: Info,
+		Context: context(map[string]any{
+			"identity_schema": schemaID,
+		}),
+	}
+}
+
 func NewErrorValidationRegistrationFlowExpired(expiredAt time.Time) *Message {
 	return &Message{
 		ID:   ErrorValidationRegistrationFlowExpired,
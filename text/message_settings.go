@@ -128,6 +128,27 @@ func NewInfoSelfServiceSettingsLookupSecretUsed(usedAt time.Time) *Message {
 	}
 }
 
+func NewInfoSelfServiceSettingsLookupSecretUnused() *Message {
+	return &Message{
+		ID:   InfoSelfServiceSettingsLookupSecretUnused,
+		Text: "Secret was not used yet",
+		Type: Info,
+	}
+}
+
+func NewInfoSelfServiceSettingsLookupSecretStatus(remaining, total int, raw any) *Message {
+	return &Message{
+		ID:   InfoSelfServiceSettingsLookupSecretStatus,
+		Text: fmt.Sprintf("%d of %d back up recovery codes are left.", remaining, total),
+		Type: Info,
+		Context: context(map[string]any{
+			"remaining_codes": remaining,
+			"total_codes":     total,
+			"secrets":         raw,
+		}),
+	}
+}
+
 func NewInfoSelfServiceSettingsLookupSecretsLabel() *Message {
 	return &Message{
 		ID:   InfoSelfServiceSettingsLookupSecretLabel,
@@ -207,3 +228,83 @@ func NewInfoSelfServiceRemovePasskey(name string, createdAt time.Time) *Message
 		}),
 	}
 }
+
+func NewInfoSelfServiceSettingsRegisterDeviceKey() *Message {
+	return &Message{
+		ID:   InfoSelfServiceSettingsRegisterDeviceKey,
+		Text: "Add device key",
+		Type: Info,
+	}
+}
+
+func NewInfoSelfServiceRegisterDeviceKeyDisplayName() *Message {
+	return &Message{
+		ID:   InfoSelfServiceSettingsRegisterDeviceKeyDisplayName,
+		Text: "Name of the device",
+		Type: Info,
+	}
+}
+
+func NewInfoSelfServiceRemoveDeviceKey(name string, createdAt time.Time) *Message {
+	return &Message{
+		ID:   InfoSelfServiceSettingsRemoveDeviceKey,
+		Text: fmt.Sprintf("Remove device key \"%s\"", name),
+		Type: Info,
+		Context: context(map[string]any{
+			"display_name":  name,
+			"added_at":      createdAt,
+			"added_at_unix": createdAt.Unix(),
+		}),
+	}
+}
+
+func NewInfoSelfServiceSettingsVerifyTraitChange(address string) *Message {
+	return &Message{
+		ID:   InfoSelfServiceSettingsVerifyTraitChange,
+		Text: fmt.Sprintf("A code has been sent to %s. Enter it to save your changes.", address),
+		Type: Info,
+		Context: context(map[string]any{
+			"address": address,
+		}),
+	}
+}
+
+func NewInfoSelfServiceSettingsPasskeyEnrollment() *Message {
+	return &Message{
+		ID:   InfoSelfServiceSettingsPasskeyEnrollment,
+		Text: "Add a passkey to sign in faster and more securely next time.",
+		Type: Info,
+	}
+}
+
+func NewInfoSelfServiceSettingsProfileCompletion() *Message {
+	return &Message{
+		ID:   InfoSelfServiceSettingsProfileCompletion,
+		Text: "Please complete your profile.",
+		Type: Info,
+	}
+}
+
+func NewErrorValidationSettingsVerificationCodeInvalid() *Message {
+	return &Message{
+		ID:   ErrorValidationSettingsVerificationCodeInvalid,
+		Text: "The verification code is invalid, please try again.",
+		Type: Error,
+	}
+}
+
+func NewErrorValidationSettingsTraitChangeDiscarded() *Message {
+	return &Message{
+		ID:   ErrorValidationSettingsTraitChangeDiscarded,
+		Text: "Your changes could not be verified and have been discarded, please try again.",
+		Type: Error,
+	}
+}
+
+func NewErrorValidationSettingsLinkAddressNotVerified() *Message {
+	return &Message{
+		ID:   ErrorValidationSettingsLinkAddressNotVerified,
+		Text: "Please verify your email address before linking a social sign in provider.",
+		Type: Error,
+	}
+}
@@ -432,3 +432,74 @@ func NewErrorCaptchaFailed() *Message {
 		Type: Error,
 	}
 }
+
+func NewErrorValidationNoDeviceKey() *Message {
+	return &Message{
+		ID:   ErrorValidationNoDeviceKey,
+		Text: "This device key does not exist or was removed.",
+		Type: Error,
+	}
+}
+
+func NewErrorValidationDeviceKeySignatureInvalid() *Message {
+	return &Message{
+		ID:   ErrorValidationDeviceKeySignatureInvalid,
+		Text: "The device key signature is invalid, please try again.",
+		Type: Error,
+	}
+}
+
+func NewErrorValidationDeviceKeyInvalid() *Message {
+	return &Message{
+		ID:   ErrorValidationDeviceKeyInvalid,
+		Text: "The device key is not a supported public key. Only ECDSA P-256 and Ed25519 keys are supported.",
+		Type: Error,
+	}
+}
+
+func NewErrorValidationWebAuthnAuthenticatorNotAllowed() *Message {
+	return &Message{
+		ID:   ErrorValidationWebAuthnAuthenticatorNotAllowed,
+		Text: "This security key or passkey provider is not allowed. Please use a different one.",
+		Type: Error,
+	}
+}
+
+func NewErrorValidationDisposableEmail(domain string) *Message {
+	return &Message{
+		ID:   ErrorValidationDisposableEmail,
+		Text: fmt.Sprintf("Email addresses of the disposable email provider %s are not allowed. Please use a different email address.", domain),
+		Type: Error,
+		Context: context(map[string]any{
+			"domain": domain,
+		}),
+	}
+}
+
+func NewErrorValidationOIDCEmailDomainNotAllowed(provider, domain string) *Message {
+	return &Message{
+		ID:   ErrorValidationOIDCEmailDomainNotAllowed,
+		Text: fmt.Sprintf("Email addresses of the domain %s are not allowed to sign in with %s.", domain, provider),
+		Type: Error,
+		Context: context(map[string]any{
+			"provider": provider,
+			"domain":   domain,
+		}),
+	}
+}
+
+func NewErrorValidationNoExternalMFA() *Message {
+	return &Message{
+		ID:   ErrorValidationNoExternalMFA,
+		Text: "You have no second factor set up at the external MFA provider.",
+		Type: Error,
+	}
+}
+
+func NewErrorValidationExternalMFADenied() *Message {
+	return &Message{
+		ID:   ErrorValidationExternalMFADenied,
+		Text: "The external MFA provider denied the sign in, please try again.",
+		Type: Error,
+	}
+}
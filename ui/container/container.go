@@ -116,11 +116,30 @@ func NewFromJSONSchema(ctx context.Context, action string, group node.UiNodeGrou
 }
 
 func NodesFromJSONSchema(ctx context.Context, group node.UiNodeGroup, jsonSchemaRef, prefix string, compiler *jsonschema.Compiler) (node.Nodes, error) {
+	if compiler == nil {
+		compiler = jsonschema.NewCompiler()
+	}
+
+	// The extension makes the `ory.sh/kratos` keyword, which contains presentation hints for the
+	// nodes, available in the custom properties of the paths.
+	runner, err := schema.NewExtensionRunner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	runner.Register(compiler)
+
 	paths, err := jsonschemax.ListPaths(ctx, jsonSchemaRef, compiler)
 	if err != nil {
 		return nil, err
 	}
 
+	compiled, err := compiler.Compile(ctx, jsonSchemaRef)
+	if err != nil {
+		return nil, err
+	}
+	deps := dependencies(compiled)
+	secs := sections(compiled)
+
 	nodes := node.Nodes{}
 	for _, value := range paths {
 		if value.TypeHint == jsonschemax.JSON {
@@ -128,12 +147,31 @@ func NodesFromJSONSchema(ctx context.Context, group node.UiNodeGroup, jsonSchema
 		}
 
 		name := addPrefix(value.Name, prefix, ".")
-		nodes = append(nodes, node.NewInputFieldFromSchema(name, group, value))
+		var opts []node.InputAttributesModifier
+		if conditions, ok := deps[value.Name]; ok {
+			opts = append(opts, withDependencies(conditions, prefix))
+		}
+		n := node.NewInputFieldFromSchema(name, group, value, opts...)
+		if i := strings.LastIndex(value.Name, "."); i > 0 {
+			if section, ok := secs[value.Name[:i]]; ok {
+				n.Meta.Section = &node.Section{ID: addPrefix(section.ID, prefix, "."), Title: section.Title}
+			}
+		}
+		nodes = append(nodes, n)
 	}
 
 	return nodes, nil
 }
 
+func withDependencies(conditions []node.InputAttributesDependency, prefix string) node.InputAttributesModifier {
+	return func(a *node.InputAttributes) {
+		a.DependsOn = make([]node.InputAttributesDependency, len(conditions))
+		for k, c := range conditions {
+			a.DependsOn[k] = node.InputAttributesDependency{Name: addPrefix(c.Name, prefix, "."), Values: c.Values}
+		}
+	}
+}
+
 func (c *Container) GetNodes() *node.Nodes {
 	return &c.Nodes
 }
@@ -265,6 +303,10 @@ func translateValidationError(err *jsonschema.ValidationError) *text.Message {
 	case "type":
 		allowedTypes, actualType, _ := strings.Cut(strings.TrimPrefix(err.Message, "expected "), ", but got ")
 		return text.NewErrorValidationWrongType(strings.Split(allowedTypes, " or "), actualType)
+	case "disposable_email":
+		domain := ""
+		_, _ = fmt.Sscanf(err.Message, "email domain %q is disposable", &domain)
+		return text.NewErrorValidationDisposableEmail(domain)
 	case "const":
 		if err.Message != "const failed" {
 			expectedValue := strings.TrimPrefix(err.Message, "value must be ")
@@ -164,8 +164,8 @@ func TestContainer(t *testing.T) {
 				expect: &Container{
 					Nodes: node.Nodes{
 						node.NewInputField("numby", nil, node.DefaultGroup, node.InputAttributeTypeNumber),
-						node.NewInputField("objy.numby", nil, node.DefaultGroup, node.InputAttributeTypeNumber),
-						node.NewInputField("objy.stringy", nil, node.DefaultGroup, node.InputAttributeTypeText),
+						inSection(node.NewInputField("objy.numby", nil, node.DefaultGroup, node.InputAttributeTypeNumber), "objy"),
+						inSection(node.NewInputField("objy.stringy", nil, node.DefaultGroup, node.InputAttributeTypeText), "objy"),
 						node.NewInputField("stringy", nil, node.DefaultGroup, node.InputAttributeTypeText),
 					},
 				},
@@ -176,8 +176,8 @@ func TestContainer(t *testing.T) {
 				expect: &Container{
 					Nodes: node.Nodes{
 						node.NewInputField("traits.numby", nil, node.DefaultGroup, node.InputAttributeTypeNumber),
-						node.NewInputField("traits.objy.numby", nil, node.DefaultGroup, node.InputAttributeTypeNumber),
-						node.NewInputField("traits.objy.stringy", nil, node.DefaultGroup, node.InputAttributeTypeText),
+						inSection(node.NewInputField("traits.objy.numby", nil, node.DefaultGroup, node.InputAttributeTypeNumber), "traits.objy"),
+						inSection(node.NewInputField("traits.objy.stringy", nil, node.DefaultGroup, node.InputAttributeTypeText), "traits.objy"),
 						node.NewInputField("traits.stringy", nil, node.DefaultGroup, node.InputAttributeTypeText),
 					},
 				},
@@ -187,8 +187,8 @@ func TestContainer(t *testing.T) {
 				expect: &Container{
 					Nodes: node.Nodes{
 						node.NewInputField("fruits", nil, node.DefaultGroup, node.InputAttributeTypeText),
-						node.NewInputField("meal.chef", nil, node.DefaultGroup, node.InputAttributeTypeText),
-						node.NewInputField("meal.name", nil, node.DefaultGroup, node.InputAttributeTypeText, node.WithRequiredInputAttribute),
+						inSection(node.NewInputField("meal.chef", nil, node.DefaultGroup, node.InputAttributeTypeText), "meal"),
+						inSection(node.NewInputField("meal.name", nil, node.DefaultGroup, node.InputAttributeTypeText, node.WithRequiredInputAttribute), "meal"),
 
 						// FIXME https://github.com/ory/kratos/issues/1316
 						//
@@ -198,6 +198,26 @@ func TestContainer(t *testing.T) {
 					},
 				},
 			},
+			{
+				ref: "./stub/ui_hints.schema.json",
+				expect: &Container{
+					Nodes: node.Nodes{
+						node.NewInputField("email", nil, node.DefaultGroup, node.InputAttributeTypeEmail, node.WithInputAttributes(func(a *node.InputAttributes) {
+							a.Autocomplete = node.InputAttributeAutocompleteEmail
+							a.Placeholder = "jane@example.com"
+						})),
+						node.NewInputField("given_name", nil, node.DefaultGroup, node.InputAttributeTypeText, node.WithInputAttributes(func(a *node.InputAttributes) {
+							a.Autocomplete = node.InputAttributeAutocompleteGivenName
+							a.Placeholder = "Jane"
+						})),
+						node.NewInputField("phone", nil, node.DefaultGroup, node.InputAttributeTypeText, node.WithInputAttributes(func(a *node.InputAttributes) {
+							a.Autocomplete = node.InputAttributeAutocompleteTel
+							a.InputMode = node.InputAttributeInputModeNumeric
+							a.Mask = "(999) 999-9999"
+						})),
+					},
+				},
+			},
 		} {
 			t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
 				actual, err := NewFromJSONSchema(ctx, "action",
@@ -210,6 +230,63 @@ func TestContainer(t *testing.T) {
 		}
 	})
 
+	t.Run("method=NewFromJSONSchema/case=dependencies", func(t *testing.T) {
+		actual, err := NewFromJSONSchema(ctx, "action", node.DefaultGroup, "./stub/dependencies.schema.json", "traits", nil)
+		require.NoError(t, err)
+
+		dependsOn := func(name string) []node.InputAttributesDependency {
+			n := actual.Nodes.Find(name)
+			require.NotNil(t, n, name)
+			return n.Attributes.(*node.InputAttributes).DependsOn
+		}
+
+		assert.Empty(t, dependsOn("traits.account_type"))
+		assert.Equal(t, []node.InputAttributesDependency{{Name: "traits.account_type", Values: []any{"business"}}}, dependsOn("traits.company_name"))
+		assert.Empty(t, dependsOn("traits.address.country"))
+		assert.Equal(t, []node.InputAttributesDependency{{Name: "traits.address.country", Values: []any{"US", "CA"}}}, dependsOn("traits.address.state"))
+
+		t.Run("case=nested objects are sections", func(t *testing.T) {
+			for _, name := range []string{"traits.address.country", "traits.address.state"} {
+				n := actual.Nodes.Find(name)
+				require.NotNil(t, n, name)
+				assert.Equal(t, &node.Section{ID: "traits.address", Title: text.NewInfoNodeLabelGenerated("Address")}, n.Meta.Section, name)
+			}
+			assert.Nil(t, actual.Nodes.Find("traits.company_name").Meta.Section)
+		})
+
+		t.Run("case=conditional requiredness is enforced", func(t *testing.T) {
+			for _, tc := range []struct {
+				traits  string
+				missing string
+			}{
+				{traits: `{"account_type":"personal"}`},
+				{traits: `{"account_type":"business","company_name":"Ory"}`},
+				{traits: `{"account_type":"business"}`, missing: "company_name"},
+				{traits: `{"address":{"country":"DE"}}`},
+				{traits: `{"address":{"country":"US"}}`, missing: "address.state"},
+			} {
+				t.Run("traits="+tc.traits, func(t *testing.T) {
+					err := schema.NewValidator().Validate(ctx, "file://./stub/dependencies.schema.json", json.RawMessage(tc.traits))
+					if tc.missing == "" {
+						require.NoError(t, err)
+						return
+					}
+					require.Error(t, err)
+
+					c := New("action")
+					require.NoError(t, c.ParseError(node.DefaultGroup, err))
+					found := false
+					for _, n := range c.Nodes {
+						if n.ID() == tc.missing {
+							found = len(n.Messages) > 0
+						}
+					}
+					assert.True(t, found, "%+v", c.Nodes)
+				})
+			}
+		})
+	})
+
 	t.Run("method=ParseError", func(t *testing.T) {
 		for k, tc := range []struct {
 			err       error
@@ -328,3 +405,8 @@ func TestContainer(t *testing.T) {
 		require.EqualValues(t, "bar", c.Nodes[0].Attributes.GetValue())
 	})
 }
+
+func inSection(n *node.Node, id string) *node.Node {
+	n.Meta.Section = &node.Section{ID: id}
+	return n
+}
@@ -0,0 +1,109 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/kratos/ui/node"
+)
+
+// dependencies returns the conditions of the fields which are constrained by an `if` / `then`
+// keyword of the JSON Schema, keyed by the path of the field.
+//
+// Only conditions which compare properties of the object with `const` or `enum` are supported,
+// for example:
+//
+//	{
+//	  "if": {"properties": {"account_type": {"const": "business"}}},
+//	  "then": {"required": ["company_name"]}
+//	}
+//
+// The fields listed in `required` or `properties` of `then` depend on the condition.
+func dependencies(s *jsonschema.Schema) map[string][]node.InputAttributesDependency {
+	deps := make(map[string][]node.InputAttributesDependency)
+	collectDependencies(s, nil, make(map[*jsonschema.Schema]bool), deps)
+	return deps
+}
+
+func collectDependencies(s *jsonschema.Schema, parents []string, visiting map[*jsonschema.Schema]bool, deps map[string][]node.InputAttributesDependency) {
+	if s == nil || visiting[s] {
+		return
+	}
+	visiting[s] = true
+	defer delete(visiting, s)
+
+	if s.Ref != nil {
+		collectDependencies(s.Ref, parents, visiting, deps)
+		return
+	}
+
+	for _, sub := range s.AllOf {
+		collectDependencies(sub, parents, visiting, deps)
+	}
+
+	if s.If != nil && s.Then != nil {
+		if conditions := dependencyConditions(s.If, parents); len(conditions) > 0 {
+			for _, target := range dependencyTargets(s.Then) {
+				name := joinPath(parents, target)
+				deps[name] = append(deps[name], conditions...)
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+		collectDependencies(s.Properties[name], append(slices.Clip(parents), name), visiting, deps)
+	}
+}
+
+// dependencyConditions returns the conditions of the `if` keyword, or nil if it contains a
+// condition which can not be expressed as a dependency.
+func dependencyConditions(s *jsonschema.Schema, parents []string) []node.InputAttributesDependency {
+	s = resolveRef(s)
+	if len(s.Properties) == 0 {
+		return nil
+	}
+
+	conditions := make([]node.InputAttributesDependency, 0, len(s.Properties))
+	for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+		property := resolveRef(s.Properties[name])
+
+		var values []any
+		switch {
+		case len(property.Constant) > 0:
+			values = property.Constant[:1]
+		case len(property.Enum) > 0:
+			values = property.Enum
+		default:
+			return nil
+		}
+
+		conditions = append(conditions, node.InputAttributesDependency{
+			Name:   joinPath(parents, name),
+			Values: values,
+		})
+	}
+	return conditions
+}
+
+func dependencyTargets(s *jsonschema.Schema) []string {
+	s = resolveRef(s)
+	targets := slices.Concat(s.Required, slices.Collect(maps.Keys(s.Properties)))
+	slices.Sort(targets)
+	return slices.Compact(targets)
+}
+
+func resolveRef(s *jsonschema.Schema) *jsonschema.Schema {
+	for s.Ref != nil {
+		s = s.Ref
+	}
+	return s
+}
+
+func joinPath(parents []string, name string) string {
+	return strings.Join(append(slices.Clip(parents), name), ".")
+}
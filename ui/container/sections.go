@@ -0,0 +1,43 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/ui/node"
+)
+
+// sections returns the sections of the objects nested in the JSON Schema, keyed by the path of
+// the object. Neither the root object nor the `traits` object of identity schemas are sections.
+func sections(s *jsonschema.Schema) map[string]*node.Section {
+	result := make(map[string]*node.Section)
+	collectSections(s, nil, make(map[*jsonschema.Schema]bool), result)
+	return result
+}
+
+func collectSections(s *jsonschema.Schema, parents []string, visiting map[*jsonschema.Schema]bool, result map[string]*node.Section) {
+	s = resolveRef(s)
+	if visiting[s] || len(s.Properties) == 0 {
+		return
+	}
+	visiting[s] = true
+	defer delete(visiting, s)
+
+	if len(parents) > 0 && !(len(parents) == 1 && parents[0] == "traits") {
+		path := strings.Join(parents, ".")
+		section := &node.Section{ID: path}
+		if s.Title != "" {
+			section.Title = text.NewInfoNodeLabelGenerated(s.Title)
+		}
+		result[path] = section
+	}
+
+	for name, property := range s.Properties {
+		collectSections(property, append(slices.Clip(parents), name), visiting, result)
+	}
+}
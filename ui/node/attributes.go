@@ -32,6 +32,27 @@ const (
 	InputAttributeAutocompleteCurrentPassword UiNodeInputAttributeAutocomplete = "current-password"
 	InputAttributeAutocompleteNewPassword     UiNodeInputAttributeAutocomplete = "new-password"
 	InputAttributeAutocompleteOneTimeCode     UiNodeInputAttributeAutocomplete = "one-time-code"
+	InputAttributeAutocompleteName            UiNodeInputAttributeAutocomplete = "name"
+	InputAttributeAutocompleteGivenName       UiNodeInputAttributeAutocomplete = "given-name"
+	InputAttributeAutocompleteFamilyName      UiNodeInputAttributeAutocomplete = "family-name"
+	InputAttributeAutocompleteNickname        UiNodeInputAttributeAutocomplete = "nickname"
+	InputAttributeAutocompleteUsername        UiNodeInputAttributeAutocomplete = "username"
+	InputAttributeAutocompleteOrganization    UiNodeInputAttributeAutocomplete = "organization"
+	InputAttributeAutocompleteStreetAddress   UiNodeInputAttributeAutocomplete = "street-address"
+	InputAttributeAutocompletePostalCode      UiNodeInputAttributeAutocomplete = "postal-code"
+	InputAttributeAutocompleteCountry         UiNodeInputAttributeAutocomplete = "country"
+	InputAttributeAutocompleteBirthday        UiNodeInputAttributeAutocomplete = "bday"
+)
+
+const (
+	InputAttributeInputModeNone    UiNodeInputAttributeInputMode = "none"
+	InputAttributeInputModeText    UiNodeInputAttributeInputMode = "text"
+	InputAttributeInputModeDecimal UiNodeInputAttributeInputMode = "decimal"
+	InputAttributeInputModeNumeric UiNodeInputAttributeInputMode = "numeric"
+	InputAttributeInputModeTel     UiNodeInputAttributeInputMode = "tel"
+	InputAttributeInputModeSearch  UiNodeInputAttributeInputMode = "search"
+	InputAttributeInputModeEmail   UiNodeInputAttributeInputMode = "email"
+	InputAttributeInputModeURL     UiNodeInputAttributeInputMode = "url"
 )
 
 // swagger:enum UiNodeInputAttributeType
@@ -40,6 +61,9 @@ type UiNodeInputAttributeType string
 // swagger:enum UiNodeInputAttributeAutocomplete
 type UiNodeInputAttributeAutocomplete string
 
+// swagger:enum UiNodeInputAttributeInputMode
+type UiNodeInputAttributeInputMode string
+
 // Attributes represents a list of attributes (e.g. `href="foo"` for links).
 //
 // swagger:model uiNodeAttributes
@@ -86,6 +110,16 @@ type InputAttributes struct {
 	// The autocomplete attribute for the input.
 	Autocomplete UiNodeInputAttributeAutocomplete `json:"autocomplete,omitempty"`
 
+	// The inputmode attribute for the input. It hints at the virtual keyboard to show.
+	InputMode UiNodeInputAttributeInputMode `json:"inputmode,omitempty"`
+
+	// The input's placeholder text.
+	Placeholder string `json:"placeholder,omitempty"`
+
+	// The input's display mask, for example `(999) 999-9999`. Browsers do not support input
+	// masks, so it is up to the UI to apply it.
+	Mask string `json:"mask,omitempty"`
+
 	// The input's label text.
 	Label *text.Message `json:"label,omitempty"`
 
@@ -122,6 +156,10 @@ type InputAttributes struct {
 	// MaxLength may contain the input's maximum length.
 	MaxLength int `json:"maxlength,omitempty"`
 
+	// DependsOn lists the conditions under which this input is relevant. The UI should only show
+	// the input if all conditions are met. The input might be required if they are.
+	DependsOn []InputAttributesDependency `json:"depends_on,omitempty" faker:"-"`
+
 	// NodeType represents this node's types. It is a mirror of `node.type` and
 	// is primarily used to allow compatibility with OpenAPI 3.0.  In this struct it technically always is "input".
 	//
@@ -129,6 +167,21 @@ type InputAttributes struct {
 	NodeType UiNodeType `json:"node_type"`
 }
 
+// InputAttributesDependency is a condition on the value of another input.
+//
+// swagger:model uiNodeInputAttributesDependency
+type InputAttributesDependency struct {
+	// The name of the input the condition depends on.
+	//
+	// required: true
+	Name string `json:"name"`
+
+	// The condition is met if the input has one of these values.
+	//
+	// required: true
+	Values []any `json:"values" faker:"-"`
+}
+
 // ImageAttributes represents the attributes of an image node.
 //
 // swagger:model uiNodeImageAttributes
@@ -6,6 +6,7 @@ package node
 import (
 	"encoding/json"
 
+	"github.com/ory/kratos/schema"
 	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/x"
 	"github.com/ory/x/jsonschemax"
@@ -202,6 +203,16 @@ func NewInputFieldFromSchema(name string, group UiNodeGroup, p jsonschemax.Path,
 		attr.Pattern = p.Pattern.String()
 	}
 
+	// Presentation hints from the identity schema extension
+	if e, ok := p.CustomProperties[schema.ExtensionName].(*schema.ExtensionConfig); ok {
+		if e.UI.Autocomplete != "" {
+			attr.Autocomplete = UiNodeInputAttributeAutocomplete(e.UI.Autocomplete)
+		}
+		attr.InputMode = UiNodeInputAttributeInputMode(e.UI.InputMode)
+		attr.Placeholder = e.UI.Placeholder
+		attr.Mask = e.UI.Mask
+	}
+
 	// Set disabled if the custom property is set
 	if isDisabled, ok := p.CustomProperties[DisableFormField]; ok {
 		if isDisabled, ok := isDisabled.(bool); ok {
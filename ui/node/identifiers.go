@@ -15,6 +15,7 @@ const (
 	LookupRegenerate = "lookup_secret_regenerate"
 	LookupDisable    = "lookup_secret_disable"
 	LookupCodes      = "lookup_secret_codes"
+	LookupStatus     = "lookup_secret_status"
 	LookupConfirm    = "lookup_secret_confirm"
 	LookupCodeEnter  = "lookup_secret"
 )
@@ -43,3 +44,21 @@ const (
 	PasskeyLoginTrigger     = "passkey_login_trigger" //#nosec G101 -- Not a credential
 	PasskeyRemove           = "passkey_remove"
 )
+
+const (
+	DeviceKeyChallenge            = "device_key_challenge"
+	DeviceKeyID                   = "device_key_id"
+	DeviceKeySignature            = "device_key_signature"
+	DeviceKeyRegister             = "device_key_register"
+	DeviceKeyRegisterDisplayName  = "device_key_register_displayname"
+	DeviceKeyRegisterAttestation  = "device_key_register_attestation"
+	DeviceKeyRegisterPushToken    = "device_key_register_push_token"
+	DeviceKeyRegisterPushPlatform = "device_key_register_push_platform"
+	DeviceKeyRemove               = "device_key_remove"
+)
+
+const (
+	CrossDeviceUserCode = "user_code"
+	CrossDeviceQR       = "cross_device_qr"
+	CrossDeviceAction   = "action"
+)
@@ -51,6 +51,10 @@ const (
 	WebAuthnGroup        UiNodeGroup = "webauthn"
 	PasskeyGroup         UiNodeGroup = "passkey"
 	IdentifierFirstGroup UiNodeGroup = "identifier_first"
+	CrossDeviceGroup     UiNodeGroup = "cross_device"
+	DeviceKeyGroup       UiNodeGroup = "device_key"
+	ExternalMFAGroup     UiNodeGroup = "external_mfa"
+	IdentitySchemaGroup  UiNodeGroup = "identity_schema"
 	CaptchaGroup         UiNodeGroup = "captcha" // Available in OEL
 	SAMLGroup            UiNodeGroup = "saml"    // Available in OEL
 )
@@ -114,6 +118,27 @@ type Meta struct {
 	// If you wish to use other titles or labels implement that directly in
 	// your UI.
 	Label *text.Message `json:"label,omitempty"`
+
+	// Section is the logical section of the form the node belongs to. Nodes of the same section
+	// are adjacent once sorted.
+	Section *Section `json:"section,omitempty"`
+}
+
+// A Node's Section
+//
+// Sections are derived from the objects of the identity schema. A UI can render each section
+// as a fieldset.
+//
+// swagger:model uiNodeSection
+type Section struct {
+	// ID is the path of the object the section is derived from, for example `traits.address`.
+	// Sections of nested objects have IDs prefixed with the ID of the outer section.
+	//
+	// required: true
+	ID string `json:"id"`
+
+	// Title is the title of the object, if it has one.
+	Title *text.Message `json:"title,omitempty"`
 }
 
 // Used for en/decoding the Attributes field.
@@ -273,6 +298,25 @@ func (n Nodes) SortBySchema(ctx context.Context, opts ...SortOption) error {
 		return lastPrefix
 	}
 
+	// Nodes of a section are sorted to the position of the first node of the section so that
+	// sections are never interleaved with other nodes.
+	sectionPositions := make(map[string]int)
+	for _, node := range n {
+		if node.Meta == nil || node.Meta.Section == nil {
+			continue
+		}
+		key := string(node.Group) + "/" + node.Meta.Section.ID
+		if p, ok := sectionPositions[key]; !ok || getKeyPosition(node) < p {
+			sectionPositions[key] = getKeyPosition(node)
+		}
+	}
+	getSectionPosition := func(node *Node) int {
+		if node.Meta == nil || node.Meta.Section == nil {
+			return getKeyPosition(node)
+		}
+		return sectionPositions[string(node.Group)+"/"+node.Meta.Section.ID]
+	}
+
 	if len(o.orderByGroups) > 0 {
 		// Sort by groups so that default is in front, then oidc, password, ...
 		sort.Slice(n, func(i, j int) bool {
@@ -287,6 +331,13 @@ func (n Nodes) SortBySchema(ctx context.Context, opts ...SortOption) error {
 		b := n[j]
 
 		if a.Group == b.Group {
+			sa, sb := getSectionPosition(a), getSectionPosition(b)
+			if sa < sb {
+				return true
+			} else if sa > sb {
+				return false
+			}
+
 			pa, pb := getKeyPosition(a), getKeyPosition(b)
 			if pa < pb {
 				return true
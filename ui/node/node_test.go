@@ -136,6 +136,28 @@ func TestNodesSort(t *testing.T) {
 	}
 }
 
+func TestNodesSortSections(t *testing.T) {
+	inSection := func(n *node.Node, id string) *node.Node {
+		n.Meta.Section = &node.Section{ID: id}
+		return n
+	}
+
+	nodes := node.Nodes{
+		node.NewInputField("traits.name", nil, node.DefaultGroup, node.InputAttributeTypeText),
+		inSection(node.NewInputField("traits.address.zip", nil, node.DefaultGroup, node.InputAttributeTypeText), "traits.address"),
+		node.NewInputField("traits.email", nil, node.DefaultGroup, node.InputAttributeTypeEmail),
+		inSection(node.NewInputField("traits.address.street", nil, node.DefaultGroup, node.InputAttributeTypeText), "traits.address"),
+	}
+	require.NoError(t, nodes.SortBySchema(ctx, node.SortUseOrder([]string{"traits.email", "traits.address.street", "traits.name"})))
+
+	ids := make([]string, len(nodes))
+	for k, n := range nodes {
+		ids[k] = n.ID()
+	}
+	assert.Equal(t, []string{"traits.email", "traits.address.street", "traits.address.zip", "traits.name"}, ids,
+		"the nodes of a section are adjacent even if some are not part of the schema")
+}
+
 func TestNodesUpsert(t *testing.T) {
 	var nodes node.Nodes
 	nodes.Upsert(node.NewCSRFNode("foo"))
@@ -0,0 +1,123 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/x/healthx"
+	"github.com/ory/x/logrusx"
+	prometheus "github.com/ory/x/prometheusx"
+	"github.com/ory/x/sqlcon"
+)
+
+type adminAPITokenContextKey struct{}
+
+type (
+	adminAPITokenAuthorizerDependencies interface {
+		config.Provider
+		IssuedAdminAPITokenPersistenceProvider
+		LoggingProvider
+		WriterProvider
+	}
+
+	// AdminAPITokenAuthorizer is a middleware which requires requests to the admin API to include
+	// one of the configured admin API tokens or an unused and unexpired issued admin API token. The
+	// token is stored in the request context so that handlers can redact the fields the token is not
+	// allowed to read.
+	AdminAPITokenAuthorizer struct {
+		r adminAPITokenAuthorizerDependencies
+	}
+)
+
+func NewAdminAPITokenAuthorizer(r adminAPITokenAuthorizerDependencies) *AdminAPITokenAuthorizer {
+	return &AdminAPITokenAuthorizer{r: r}
+}
+
+// AdminAPITokenFromContext returns the admin API token the request was authorized with. It
+// returns false if no admin API tokens are configured.
+func AdminAPITokenFromContext(ctx context.Context) (*config.AdminAPIToken, bool) {
+	t, ok := ctx.Value(adminAPITokenContextKey{}).(*config.AdminAPIToken)
+	return t, ok
+}
+
+// WithAdminAPIToken returns a copy of the context which contains the admin API token.
+func WithAdminAPIToken(ctx context.Context, t *config.AdminAPIToken) context.Context {
+	return context.WithValue(ctx, adminAPITokenContextKey{}, t)
+}
+
+func (a *AdminAPITokenAuthorizer) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	switch r.URL.Path {
+	case AdminPrefix + healthx.AliveCheckPath,
+		AdminPrefix + healthx.ReadyCheckPath,
+		AdminPrefix + healthx.VersionPath,
+		AdminPrefix + prometheus.MetricsPrometheusPath:
+		next(w, r)
+		return
+	}
+
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		presented = ""
+	}
+
+	ctx, err := a.authorize(r.Context(), presented, func(l *logrusx.Logger) *logrusx.Logger { return l.WithRequest(r) })
+	if errors.Is(err, ErrMissingAdminAPIToken) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		a.r.Writer().WriteError(w, r, errors.WithStack(herodot.ErrUnauthorized.WithReason("The request must include a valid admin API token in the Authorization header.")))
+		return
+	} else if err != nil {
+		a.r.Writer().WriteError(w, r, err)
+		return
+	}
+
+	next(w, r.WithContext(ctx))
+}
+
+// ErrMissingAdminAPIToken is returned if admin API tokens are configured and the request does not
+// include a valid one.
+var ErrMissingAdminAPIToken = errors.New("missing or invalid admin API token")
+
+// authorize returns a copy of the context which contains the admin API token matching the
+// presented token. The context is returned unchanged if no admin API tokens are configured.
+func (a *AdminAPITokenAuthorizer) authorize(ctx context.Context, presented string, withRequest func(*logrusx.Logger) *logrusx.Logger) (context.Context, error) {
+	tokens, err := a.r.Config().AdminAPITokens(ctx)
+	if err != nil {
+		return nil, err
+	} else if len(tokens) == 0 {
+		return ctx, nil
+	} else if len(presented) == 0 {
+		return nil, errors.WithStack(ErrMissingAdminAPIToken)
+	}
+
+	for k := range tokens {
+		if subtle.ConstantTimeCompare([]byte(tokens[k].Token), []byte(presented)) == 1 {
+			withRequest(a.r.Logger()).WithField("admin_api_token_id", tokens[k].ID).Debug("Authorized admin API request.")
+			return WithAdminAPIToken(ctx, &tokens[k]), nil
+		}
+	}
+
+	issued, err := a.r.IssuedAdminAPITokenPersister().UseIssuedAdminAPIToken(ctx, HashIssuedAdminAPIToken(presented))
+	if errors.Is(err, sqlcon.ErrNoRows) {
+		return nil, errors.WithStack(ErrMissingAdminAPIToken)
+	} else if err != nil {
+		return nil, err
+	}
+
+	withRequest(a.r.Audit()).
+		WithField("issued_admin_api_token_id", issued.ID).
+		WithField("expires_at", issued.ExpiresAt).
+		Info("Authorized admin API request with an issued admin API token.")
+	return WithAdminAPIToken(ctx, &config.AdminAPIToken{
+		ID:     "issued:" + issued.ID.String(),
+		Scopes: config.AdminAPITokenScopes(),
+	}), nil
+}
@@ -0,0 +1,71 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/ory/x/randx"
+	"github.com/ory/x/sqlxx"
+)
+
+// IssuedAdminAPIToken is an admin API token which was issued using `kratos ops issue-admin-token`
+// instead of being configured in `serve.admin.api_tokens`. It grants all scopes for a single request
+// and is used to regain access to the admin API if the regular admin API tokens are unavailable.
+// The token is rejected once it was used or has expired.
+//
+// Only the SHA-256 hash of the token is stored.
+type IssuedAdminAPIToken struct {
+	ID        uuid.UUID      `json:"id" faker:"-" db:"id"`
+	TokenHash string         `json:"-" faker:"-" db:"token_hash"`
+	ExpiresAt time.Time      `json:"expires_at" faker:"time_type" db:"expires_at"`
+	UsedAt    sqlxx.NullTime `json:"used_at" faker:"-" db:"used_at"`
+
+	// CreatedAt is a helper struct field for gobuffalo.pop.
+	CreatedAt time.Time `json:"created_at" faker:"-" db:"created_at"`
+
+	// UpdatedAt is a helper struct field for gobuffalo.pop.
+	UpdatedAt time.Time `json:"updated_at" faker:"-" db:"updated_at"`
+
+	NID uuid.UUID `json:"-" faker:"-" db:"nid"`
+}
+
+func (t IssuedAdminAPIToken) TableName(context.Context) string {
+	return "issued_admin_api_tokens"
+}
+
+type (
+	IssuedAdminAPITokenPersister interface {
+		CreateIssuedAdminAPIToken(ctx context.Context, t *IssuedAdminAPIToken) error
+		// UseIssuedAdminAPIToken marks the issued admin API token with the given hash as used and
+		// returns it. It returns sqlcon.ErrNoRows if the token does not exist, has expired, or
+		// was already used.
+		UseIssuedAdminAPIToken(ctx context.Context, tokenHash string) (*IssuedAdminAPIToken, error)
+		DeleteExpiredIssuedAdminAPITokens(ctx context.Context, deleteOlder time.Time, pageSize int) error
+	}
+	IssuedAdminAPITokenPersistenceProvider interface {
+		IssuedAdminAPITokenPersister() IssuedAdminAPITokenPersister
+	}
+)
+
+// NewIssuedAdminAPIToken generates a new admin API token which expires after the given
+// lifespan. The returned token is not stored and must be handed to the operator.
+func NewIssuedAdminAPIToken(lifespan time.Duration) (string, *IssuedAdminAPIToken) {
+	token := randx.MustString(48, randx.AlphaNum)
+	return token, &IssuedAdminAPIToken{
+		TokenHash: HashIssuedAdminAPIToken(token),
+		ExpiresAt: Now().UTC().Add(lifespan),
+	}
+}
+
+// HashIssuedAdminAPIToken returns the hash under which an issued admin API token is stored.
+func HashIssuedAdminAPIToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
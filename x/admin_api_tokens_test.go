@@ -0,0 +1,107 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/x"
+)
+
+func TestAdminAPITokenAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+
+	n := negroni.New(x.NewAdminAPITokenAuthorizer(reg))
+	var scopes []string
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := x.AdminAPITokenFromContext(r.Context()); ok {
+			scopes = token.Scopes
+			_, _ = w.Write([]byte(token.ID))
+		}
+	})
+	ts := httptest.NewServer(n)
+	t.Cleanup(ts.Close)
+
+	do := func(t *testing.T, path, token string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+
+	t.Run("case=allows all requests if no tokens are configured", func(t *testing.T) {
+		res, body := do(t, "/admin/identities", "")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, body)
+	})
+
+	conf.MustSet(ctx, config.ViperKeyAdminAPITokens, []map[string]any{
+		{"id": "support", "token": "support-token-0123456789abcdefghijkl", "redacted_traits": []string{"ssn"}},
+		{"id": "full", "token": "full-token-0123456789abcdefghijklmnop", "scopes": []string{config.AdminAPITokenScopeCredentialsRead}},
+	})
+
+	t.Run("case=rejects requests without a valid token", func(t *testing.T) {
+		for _, token := range []string{"", "not-a-token", "support-token-0123456789abcdefghijk"} {
+			res, _ := do(t, "/admin/identities", token)
+			assert.Equal(t, http.StatusUnauthorized, res.StatusCode, token)
+			assert.Equal(t, "Bearer", res.Header.Get("WWW-Authenticate"))
+		}
+	})
+
+	t.Run("case=stores the token in the request context", func(t *testing.T) {
+		res, body := do(t, "/admin/identities", "support-token-0123456789abcdefghijkl")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "support", body)
+
+		res, body = do(t, "/admin/identities", "full-token-0123456789abcdefghijklmnop")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "full", body)
+	})
+
+	t.Run("case=accepts issued tokens once until they expire", func(t *testing.T) {
+		token, issued := x.NewIssuedAdminAPIToken(time.Minute)
+		require.NoError(t, reg.IssuedAdminAPITokenPersister().CreateIssuedAdminAPIToken(ctx, issued))
+		assert.NotContains(t, issued.TokenHash, token)
+
+		res, body := do(t, "/admin/identities", token)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "issued:"+issued.ID.String(), body)
+		assert.Equal(t, config.AdminAPITokenScopes(), scopes)
+
+		res, _ = do(t, "/admin/identities", token)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode, "issued tokens can only be used once")
+
+		expiredToken, expired := x.NewIssuedAdminAPIToken(-time.Minute)
+		require.NoError(t, reg.IssuedAdminAPITokenPersister().CreateIssuedAdminAPIToken(ctx, expired))
+
+		res, _ = do(t, "/admin/identities", expiredToken)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("case=does not require a token for health checks", func(t *testing.T) {
+		for _, path := range []string{"/admin/health/alive", "/admin/health/ready", "/admin/version", "/admin/metrics/prometheus"} {
+			res, _ := do(t, path, "")
+			assert.Equal(t, http.StatusOK, res.StatusCode, path)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	formatter interface {
+		format(e *event) ([]byte, error)
+	}
+
+	jsonFormatter struct{}
+
+	// cefFormatter formats events in the ArcSight Common Event Format.
+	cefFormatter struct {
+		version string
+	}
+)
+
+func (jsonFormatter) format(e *event) ([]byte, error) {
+	record := make(map[string]any, len(e.Fields)+3)
+	for k, v := range e.Fields {
+		record[k] = v
+	}
+	record["time"] = e.Time.UTC()
+	record["level"] = e.Level.String()
+	record["msg"] = e.Message
+
+	out, err := json.Marshal(record)
+	return out, errors.WithStack(err)
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	nonSignatureChars   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// signatureID derives a stable event class ID from the log message.
+func signatureID(message string) string {
+	return strings.Trim(nonSignatureChars.ReplaceAllString(strings.ToLower(message), "_"), "_")
+}
+
+func cefSeverity(l logrus.Level) int {
+	switch l {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 10
+	case logrus.ErrorLevel:
+		return 8
+	case logrus.WarnLevel:
+		return 6
+	case logrus.InfoLevel:
+		return 3
+	default:
+		return 1
+	}
+}
+
+func (f cefFormatter) format(e *event) ([]byte, error) {
+	fields := make(map[string]any, len(e.Fields))
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+
+	var ext []string
+	add := func(key string, value any) {
+		if s := fmt.Sprint(value); s != "" {
+			ext = append(ext, key+"="+cefExtensionEscaper.Replace(s))
+		}
+	}
+
+	add("rt", e.Time.UnixMilli())
+	add("msg", e.Message)
+	if req, ok := fields["http_request"].(map[string]any); ok {
+		delete(fields, "http_request")
+		add("src", hostOf(fmt.Sprint(req["remote"])))
+		add("requestMethod", req["method"])
+		add("request", fmt.Sprintf("%v://%v%v", req["scheme"], req["host"], req["path"]))
+		if headers, ok := req["headers"].(map[string]any); ok && headers["user-agent"] != nil {
+			add("requestClientApplication", headers["user-agent"])
+		}
+	}
+	if id, ok := fields["identity_id"]; ok {
+		delete(fields, "identity_id")
+		add("duid", id)
+	}
+	if err, ok := fields["error"]; ok {
+		delete(fields, "error")
+		if m, ok := err.(map[string]any); ok {
+			err = m["message"]
+		}
+		add("reason", err)
+		add("outcome", "failure")
+	}
+	if len(fields) > 0 {
+		raw, err := json.Marshal(fields)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		add("cs1Label", "fields")
+		add("cs1", string(raw))
+	}
+
+	return []byte(fmt.Sprintf("CEF:0|Ory|Kratos|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(f.version),
+		cefHeaderEscaper.Replace(signatureID(e.Message)),
+		cefHeaderEscaper.Replace(e.Message),
+		cefSeverity(e.Level),
+		strings.Join(ext, " "),
+	)), nil
+}
+
+// hostOf strips the port from a remote address.
+func hostOf(remote string) string {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
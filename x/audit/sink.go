@@ -0,0 +1,234 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ory/x/logrusx"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCEF  = "cef"
+
+	// maxBatchSize is the maximum number of events sent in one request to an HTTP collector.
+	maxBatchSize = 100
+
+	dropReasonBufferFull     = "buffer_full"
+	dropReasonDeliveryFailed = "delivery_failed"
+)
+
+var droppedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "kratos_audit_sink_events_dropped_total",
+	Help: "Number of audit log events which were not shipped to the audit log sink, labelled with the reason.",
+}, []string{"reason"})
+
+// SinkCollectors returns the Prometheus collectors of the audit log sink.
+// They are registered by the registry's metrics setup.
+func SinkCollectors() []prometheus.Collector {
+	return []prometheus.Collector{droppedEvents}
+}
+
+type (
+	Options struct {
+		// URL is the address of the sink. Use `udp://` or `tcp://` for syslog and `http://` or
+		// `https://` for an HTTP collector.
+		URL *url.URL
+
+		// Format is either FormatJSON or FormatCEF.
+		Format string
+
+		// Headers are sent with every request to an HTTP collector.
+		Headers map[string]string
+
+		// BufferSize is the number of events which are buffered while the sink is unavailable.
+		BufferSize int
+
+		// MaxRetries is the number of times a failed delivery is retried before the events are
+		// dropped.
+		MaxRetries int
+
+		// BlockTimeout is how long logging an event waits for space in a full buffer before the
+		// event is dropped.
+		BlockTimeout time.Duration
+
+		// Version is the version of Ory Kratos reported to the sink.
+		Version string
+	}
+
+	// Sink ships audit log events to a syslog server or an HTTP collector. It is a logrus hook
+	// and picks up all entries logged with the audit logger.
+	//
+	// Events are buffered and delivered in the background, so that an unavailable sink does not
+	// slow down requests. Once the buffer is full, logging waits up to the block timeout before
+	// the event is dropped. Dropped events are counted.
+	Sink struct {
+		l         *logrusx.Logger
+		o         Options
+		transport transport
+		events    chan *event
+		done      chan struct{}
+
+		// mu guards closing the events channel while events are buffered.
+		mu     sync.RWMutex
+		closed bool
+	}
+
+	event struct {
+		Time    time.Time
+		Level   logrus.Level
+		Message string
+		Fields  map[string]any
+	}
+
+	transport interface {
+		send(ctx context.Context, events []*event) error
+		close() error
+	}
+)
+
+var _ logrus.Hook = new(Sink)
+
+// NewSink creates the sink and starts delivering events in the background.
+func NewSink(l *logrusx.Logger, o Options) (*Sink, error) {
+	if o.Format == "" {
+		o.Format = FormatJSON
+	} else if o.Format != FormatJSON && o.Format != FormatCEF {
+		return nil, errors.Errorf("unknown audit log format %q, use json or cef", o.Format)
+	}
+	if o.BufferSize < 1 {
+		o.BufferSize = 1
+	}
+
+	s := &Sink{
+		l:      l,
+		o:      o,
+		events: make(chan *event, o.BufferSize),
+		done:   make(chan struct{}),
+	}
+
+	switch o.URL.Scheme {
+	case "udp", "tcp":
+		s.transport = newSyslogTransport(o.URL, s.formatter())
+	case "http", "https":
+		s.transport = newHTTPTransport(o.URL, o.Headers, o.Format, s.formatter())
+	default:
+		return nil, errors.Errorf("unsupported audit log sink URL scheme %q, use udp, tcp, http, or https", o.URL.Scheme)
+	}
+
+	go s.run()
+	return s, nil
+}
+
+func (s *Sink) formatter() formatter {
+	if s.o.Format == FormatCEF {
+		return cefFormatter{version: s.o.Version}
+	}
+	return jsonFormatter{}
+}
+
+func (s *Sink) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire buffers audit events. Other log entries are ignored.
+func (s *Sink) Fire(entry *logrus.Entry) error {
+	if entry.Data["audience"] != "audit" {
+		return nil
+	}
+
+	fields := make(map[string]any, len(entry.Data))
+	for k, v := range entry.Data {
+		if k == "audience" {
+			continue
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		fields[k] = v
+	}
+	e := &event{Time: entry.Time, Level: entry.Level, Message: entry.Message, Fields: fields}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil
+	}
+
+	select {
+	case s.events <- e:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(s.o.BlockTimeout)
+	defer timer.Stop()
+	select {
+	case s.events <- e:
+	case <-timer.C:
+		droppedEvents.WithLabelValues(dropReasonBufferFull).Inc()
+	}
+	return nil
+}
+
+// Close delivers the buffered events and stops the sink.
+func (s *Sink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.transport.close()
+}
+
+func (s *Sink) run() {
+	defer close(s.done)
+	for e := range s.events {
+		batch := []*event{e}
+	collect:
+		for len(batch) < maxBatchSize {
+			select {
+			case e, ok := <-s.events:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, e)
+			default:
+				break collect
+			}
+		}
+		s.deliver(batch)
+	}
+}
+
+func (s *Sink) deliver(batch []*event) {
+	var bo backoff.BackOff = backoff.NewExponentialBackOff()
+	//nolint:gosec // disable G115
+	bo = backoff.WithMaxRetries(bo, uint64(max(s.o.MaxRetries, 0)))
+
+	if err := backoff.Retry(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return s.transport.send(ctx, batch)
+	}, bo); err != nil {
+		droppedEvents.WithLabelValues(dropReasonDeliveryFailed).Add(float64(len(batch)))
+		s.l.WithError(err).WithField("events", len(batch)).Warn("Unable to ship audit log events to the audit log sink, the events were dropped.")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/logrusx"
+)
+
+func newLogger(t *testing.T, o Options) (*logrusx.Logger, *Sink) {
+	l := logrusx.New("kratos", "test", logrusx.ForceLevel(logrus.TraceLevel))
+	l.Logrus().SetOutput(io.Discard)
+
+	s, err := NewSink(l, o)
+	require.NoError(t, err)
+	l.Logrus().AddHook(s)
+	return l, s
+}
+
+func TestSink(t *testing.T) {
+	t.Run("case=ships JSON batches to an HTTP collector", func(t *testing.T) {
+		received := make(chan []map[string]any, 10)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+			var batch []map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+			received <- batch
+		}))
+		t.Cleanup(ts.Close)
+
+		u, _ := url.Parse(ts.URL)
+		l, s := newLogger(t, Options{URL: u, Headers: map[string]string{"Authorization": "secret"}, BufferSize: 10})
+
+		l.WithField("audience", "audit").WithField("identity_id", "some-id").Info("An administrator deleted an identity.")
+		l.WithField("audience", "application").Info("Not an audit event.")
+		require.NoError(t, s.Close(context.Background()))
+
+		var events []map[string]any
+		for len(received) > 0 {
+			events = append(events, <-received...)
+		}
+		require.Len(t, events, 1)
+		assert.Equal(t, "An administrator deleted an identity.", events[0]["msg"])
+		assert.Equal(t, "some-id", events[0]["identity_id"])
+		assert.Equal(t, "info", events[0]["level"])
+		assert.NotContains(t, events[0], "audience")
+	})
+
+	t.Run("case=retries failed deliveries", func(t *testing.T) {
+		var calls int
+		received := make(chan string, 10)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			received <- string(body)
+		}))
+		t.Cleanup(ts.Close)
+
+		u, _ := url.Parse(ts.URL)
+		l, s := newLogger(t, Options{URL: u, Format: FormatCEF, MaxRetries: 3, BufferSize: 10})
+
+		l.WithField("audience", "audit").Info("No valid session cookie found.")
+		require.NoError(t, s.Close(context.Background()))
+
+		require.Len(t, received, 1)
+		assert.True(t, strings.HasPrefix(<-received, "CEF:0|Ory|Kratos|"))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("case=sends syslog messages over UDP", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+
+		u := &url.URL{Scheme: "udp", Host: conn.LocalAddr().String()}
+		l, s := newLogger(t, Options{URL: u, BufferSize: 10})
+
+		l.WithField("audience", "audit").Warn("Encountered self-service login error.")
+		require.NoError(t, s.Close(context.Background()))
+
+		buf := make([]byte, 4096)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+
+		msg := string(buf[:n])
+		assert.True(t, strings.HasPrefix(msg, "<84>1 "), msg)
+		assert.Contains(t, msg, ` kratos `)
+		assert.Contains(t, msg, `"msg":"Encountered self-service login error."`)
+	})
+
+	t.Run("case=rejects unknown formats and schemes", func(t *testing.T) {
+		l := logrusx.New("kratos", "test")
+		_, err := NewSink(l, Options{URL: &url.URL{Scheme: "http", Host: "localhost"}, Format: "xml"})
+		assert.Error(t, err)
+		_, err = NewSink(l, Options{URL: &url.URL{Scheme: "ftp", Host: "localhost"}})
+		assert.Error(t, err)
+	})
+}
+
+func TestCEFFormatter(t *testing.T) {
+	out, err := cefFormatter{version: "v1.3.0"}.format(&event{
+		Time:    time.Unix(1700000000, 0),
+		Level:   logrus.InfoLevel,
+		Message: "Encountered self-service login error.",
+		Fields: map[string]any{
+			"http_request": map[string]any{
+				"remote":  "192.0.2.1:1234",
+				"method":  "POST",
+				"scheme":  "https",
+				"host":    "auth.example.org",
+				"path":    "/self-service/login",
+				"headers": map[string]any{"user-agent": "curl/8.0"},
+			},
+			"identity_id": "some-id",
+			"error":       errors.New("the provided credentials are invalid").Error(),
+			"login_flow":  "a=b",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `CEF:0|Ory|Kratos|v1.3.0|encountered_self_service_login_error|Encountered self-service login error.|3|`+
+		`rt=1700000000000 msg=Encountered self-service login error. src=192.0.2.1 requestMethod=POST `+
+		`request=https://auth.example.org/self-service/login requestClientApplication=curl/8.0 duid=some-id `+
+		`reason=the provided credentials are invalid outcome=failure cs1Label=fields cs1={"login_flow":"a\=b"}`, string(out))
+}
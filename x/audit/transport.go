@@ -0,0 +1,191 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// httpTransport posts batches of events to an HTTP collector. JSON events are sent as an
+	// array, CEF events as one event per line.
+	httpTransport struct {
+		url     string
+		headers map[string]string
+		json    bool
+		f       formatter
+		c       *http.Client
+	}
+
+	// syslogTransport sends events to a syslog server using RFC 5424. Over TCP, messages are
+	// framed using octet counting (RFC 6587).
+	syslogTransport struct {
+		network, addr string
+		hostname      string
+		f             formatter
+
+		mu   sync.Mutex
+		conn net.Conn
+	}
+)
+
+func newHTTPTransport(u *url.URL, headers map[string]string, format string, f formatter) *httpTransport {
+	return &httpTransport{
+		url:     u.String(),
+		headers: headers,
+		json:    format == FormatJSON,
+		f:       f,
+		c:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *httpTransport) send(ctx context.Context, events []*event) error {
+	var body bytes.Buffer
+	if t.json {
+		body.WriteByte('[')
+	}
+	for k, e := range events {
+		out, err := t.f.format(e)
+		if err != nil {
+			return err
+		}
+		if k > 0 && t.json {
+			body.WriteByte(',')
+		}
+		body.Write(out)
+		if !t.json {
+			body.WriteByte('\n')
+		}
+	}
+	if t.json {
+		body.WriteByte(']')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url, &body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if t.json {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := t.c.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= 300 {
+		return errors.Errorf("the audit log collector responded with status code %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (t *httpTransport) close() error {
+	t.c.CloseIdleConnections()
+	return nil
+}
+
+func newSyslogTransport(u *url.URL, f formatter) *syslogTransport {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &syslogTransport{network: u.Scheme, addr: u.Host, hostname: hostname, f: f}
+}
+
+// syslogSeverity maps log levels to syslog severities.
+func syslogSeverity(l logrus.Level) int {
+	switch l {
+	case logrus.PanicLevel:
+		return 0
+	case logrus.FatalLevel:
+		return 2
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// facilityAuthPriv is the syslog facility for security and authorization messages.
+const facilityAuthPriv = 10
+
+func (t *syslogTransport) message(e *event) ([]byte, error) {
+	out, err := t.f.format(e)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(fmt.Sprintf("<%d>1 %s %s kratos %d audit - ",
+		facilityAuthPriv*8+syslogSeverity(e.Level),
+		e.Time.UTC().Format(time.RFC3339Nano),
+		t.hostname,
+		os.Getpid(),
+	)), out...), nil
+}
+
+func (t *syslogTransport) send(ctx context.Context, events []*event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		conn, err := (&net.Dialer{}).DialContext(ctx, t.network, t.addr)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		t.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = t.conn.SetWriteDeadline(deadline)
+	}
+
+	for _, e := range events {
+		msg, err := t.message(e)
+		if err != nil {
+			return err
+		}
+		if t.network == "tcp" {
+			msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+		}
+		if _, err := t.conn.Write(msg); err != nil {
+			// Reconnect on the next attempt.
+			_ = t.conn.Close()
+			t.conn = nil
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (t *syslogTransport) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return errors.WithStack(err)
+}
@@ -0,0 +1,13 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import "time"
+
+// Now returns the current time. Flows, sessions and one-time codes use it instead of time.Now to
+// compute and check their expiry. Binaries built with the `testclock` build tag can fast-forward
+// it, so that integration tests do not need to sleep until something expires.
+func Now() time.Time {
+	return time.Now().Add(clockOffset())
+}
@@ -0,0 +1,19 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !testclock
+
+package x
+
+import (
+	"time"
+
+	"github.com/ory/herodot"
+)
+
+func clockOffset() time.Duration {
+	return 0
+}
+
+// RegisterTestClockRoutes does nothing unless built with the `testclock` build tag.
+func RegisterTestClockRoutes(*RouterAdmin, herodot.Writer) {}
@@ -0,0 +1,17 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !testclock
+
+package x
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNow(t *testing.T) {
+	assert.WithinDuration(t, time.Now(), Now(), time.Second)
+}
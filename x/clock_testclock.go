@@ -0,0 +1,76 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build testclock
+
+package x
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+)
+
+// AdminRouteTestClock allows integration tests to fast-forward the clock. It only exists in
+// binaries built with the `testclock` build tag, which must never be used in production.
+const AdminRouteTestClock = "/test/clock"
+
+var offset atomic.Int64
+
+func clockOffset() time.Duration {
+	return time.Duration(offset.Load())
+}
+
+// AdvanceClock moves the time returned by Now forward by d.
+func AdvanceClock(d time.Duration) {
+	offset.Add(int64(d))
+}
+
+// ResetClock makes Now return the current time again.
+func ResetClock() {
+	offset.Store(0)
+}
+
+type testClock struct {
+	Now    time.Time `json:"now"`
+	Offset string    `json:"offset"`
+}
+
+// RegisterTestClockRoutes registers the routes to read, advance and reset the clock.
+func RegisterTestClockRoutes(admin *RouterAdmin, w herodot.Writer) {
+	write := func(rw http.ResponseWriter, r *http.Request) {
+		w.Write(rw, r, &testClock{Now: Now().UTC(), Offset: clockOffset().String()})
+	}
+
+	admin.GET(AdminRouteTestClock, func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		write(rw, r)
+	})
+
+	admin.PUT(AdminRouteTestClock, func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		var body struct {
+			Advance string `json:"advance"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteError(rw, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode the request body: %s", err)))
+			return
+		}
+		d, err := time.ParseDuration(body.Advance)
+		if err != nil || d < 0 {
+			w.WriteError(rw, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The field advance must be a positive duration such as 1h30m.")))
+			return
+		}
+		AdvanceClock(d)
+		write(rw, r)
+	})
+
+	admin.DELETE(AdminRouteTestClock, func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ResetClock()
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,58 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build testclock
+
+package x
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+)
+
+func TestTestClock(t *testing.T) {
+	t.Cleanup(ResetClock)
+
+	AdvanceClock(time.Hour)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), Now(), time.Second)
+
+	ResetClock()
+	assert.WithinDuration(t, time.Now(), Now(), time.Second)
+
+	t.Run("case=routes", func(t *testing.T) {
+		router := NewRouterAdmin()
+		RegisterTestClockRoutes(router, herodot.NewJSONWriter(nil))
+		ts := httptest.NewServer(router)
+		t.Cleanup(ts.Close)
+
+		do := func(t *testing.T, method, body string, expectCode int) gjson.Result {
+			req, err := http.NewRequest(method, ts.URL+AdminPrefix+AdminRouteTestClock, strings.NewReader(body))
+			require.NoError(t, err)
+			res, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			actual := MustReadAll(res.Body)
+			require.Equal(t, expectCode, res.StatusCode, "%s", actual)
+			return gjson.ParseBytes(actual)
+		}
+
+		actual := do(t, "PUT", `{"advance":"2h"}`, http.StatusOK)
+		assert.Equal(t, "2h0m0s", actual.Get("offset").String())
+		assert.WithinDuration(t, time.Now().Add(2*time.Hour), actual.Get("now").Time(), time.Second)
+
+		do(t, "PUT", `{"advance":"-1h"}`, http.StatusBadRequest)
+		assert.Equal(t, "2h0m0s", do(t, "GET", "", http.StatusOK).Get("offset").String())
+
+		do(t, "DELETE", "", http.StatusNoContent)
+		assert.Equal(t, "0s", do(t, "GET", "", http.StatusOK).Get("offset").String())
+	})
+}
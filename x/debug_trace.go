@@ -0,0 +1,140 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+)
+
+// DebugTraceHeader is the request header which opts into a debug trace, and the response header
+// which carries it.
+const DebugTraceHeader = "X-Kratos-Debug-Trace"
+
+type (
+	debugTraceDependencies interface {
+		config.Provider
+		LoggingProvider
+	}
+	debugTraceContextKey struct{}
+
+	// DebugTrace records how the self-service strategies and hooks handled a request. It only
+	// contains names and error IDs, never values or error messages.
+	DebugTrace struct {
+		mu     sync.Mutex
+		events []DebugTraceEvent
+	}
+
+	// DebugTraceEvent is a step of a debug trace.
+	DebugTraceEvent struct {
+		// Kind is either "strategy" or "hook".
+		Kind string `json:"kind"`
+		// Flow is the name of the flow, for example "login".
+		Flow string `json:"flow"`
+		// Phase is the step of the flow, for example "populate" or "submit" for strategies and
+		// "pre" or "post" for hooks.
+		Phase string `json:"phase"`
+		// Name is the ID of the strategy or the type of the hook.
+		Name string `json:"name"`
+		// Result is the outcome of the step, for example "success", "skipped" or "error".
+		Result string `json:"result"`
+		// Reason explains why a step was skipped.
+		Reason string `json:"reason,omitempty"`
+		// Error is the redacted error of the step.
+		Error string `json:"error,omitempty"`
+		// NodesAdded contains the nodes the strategy added to the UI, as "group/name".
+		NodesAdded []string `json:"nodes_added,omitempty"`
+		// NodesRemoved contains the nodes the strategy removed from the UI, as "group/name".
+		NodesRemoved []string `json:"nodes_removed,omitempty"`
+	}
+)
+
+// DebugTraceMiddleware starts a debug trace for requests which carry the debug trace header if
+// debug traces are enabled. The trace is logged once the request completes, and optionally
+// returned in the debug trace response header.
+func DebugTraceMiddleware(d debugTraceDependencies) negroni.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		ctx := r.Context()
+		if requested, _ := strconv.ParseBool(r.Header.Get(DebugTraceHeader)); !requested || !d.Config().SelfServiceDebugTraceEnabled(ctx) {
+			next(rw, r)
+			return
+		}
+
+		ctx, trace := ContextWithDebugTrace(ctx)
+		if d.Config().SelfServiceDebugTraceResponse(ctx) {
+			nrw, ok := rw.(negroni.ResponseWriter)
+			if !ok {
+				nrw = negroni.NewResponseWriter(rw)
+				rw = nrw
+			}
+			nrw.Before(func(nrw negroni.ResponseWriter) {
+				if raw, err := json.Marshal(trace.Events()); err == nil {
+					nrw.Header().Set(DebugTraceHeader, string(raw))
+				}
+			})
+		}
+
+		next(rw, r.WithContext(ctx))
+
+		if events := trace.Events(); len(events) > 0 {
+			d.Logger().WithRequest(r).WithField("debug_trace", events).Info("Recorded a self-service debug trace.")
+		}
+	}
+}
+
+// ContextWithDebugTrace starts a debug trace and returns the context carrying it.
+func ContextWithDebugTrace(ctx context.Context) (context.Context, *DebugTrace) {
+	trace := new(DebugTrace)
+	return context.WithValue(ctx, debugTraceContextKey{}, trace), trace
+}
+
+// DebugTraceFromContext returns the debug trace of the request, or nil if the request is not
+// traced.
+func DebugTraceFromContext(ctx context.Context) *DebugTrace {
+	trace, _ := ctx.Value(debugTraceContextKey{}).(*DebugTrace)
+	return trace
+}
+
+// Record adds the event to the trace. It is a no-op on nil traces.
+func (t *DebugTrace) Record(e DebugTraceEvent) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, e)
+}
+
+// Events returns the events recorded so far.
+func (t *DebugTrace) Events() []DebugTraceEvent {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]DebugTraceEvent{}, t.events...)
+}
+
+// RedactError returns the ID or status of herodot errors and the type of all other errors.
+// Error messages are omitted because they may contain personal data.
+func RedactError(err error) string {
+	var e *herodot.DefaultError
+	if errors.As(err, &e) {
+		if e.ID() != "" {
+			return e.ID()
+		}
+		return e.Status()
+	}
+	return fmt.Sprintf("%T", errors.Cause(err))
+}
@@ -0,0 +1,94 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/x"
+)
+
+func TestDebugTraceMiddleware(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+
+	n := negroni.New(x.DebugTraceMiddleware(reg))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace := x.DebugTraceFromContext(r.Context())
+		trace.Record(x.DebugTraceEvent{Kind: "strategy", Flow: "login", Phase: "populate", Name: "password", Result: "success", NodesAdded: []string{"password/password"}})
+		if trace == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	ts := httptest.NewServer(n)
+	t.Cleanup(ts.Close)
+
+	do := func(t *testing.T, header string) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL, nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set(x.DebugTraceHeader, header)
+		}
+		res, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res
+	}
+
+	t.Run("case=does not trace if disabled", func(t *testing.T) {
+		res := do(t, "true")
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Empty(t, res.Header.Get(x.DebugTraceHeader))
+	})
+
+	conf.MustSet(ctx, config.ViperKeySelfServiceDebugTraceEnabled, true)
+	t.Cleanup(func() {
+		conf.MustSet(ctx, config.ViperKeySelfServiceDebugTraceEnabled, false)
+	})
+
+	t.Run("case=does not trace requests without the header", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(t, "").StatusCode)
+		assert.Equal(t, http.StatusNoContent, do(t, "false").StatusCode)
+	})
+
+	t.Run("case=traces requests with the header", func(t *testing.T) {
+		res := do(t, "true")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, res.Header.Get(x.DebugTraceHeader))
+	})
+
+	t.Run("case=returns the trace in the response", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeySelfServiceDebugTraceResponse, true)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeySelfServiceDebugTraceResponse, false)
+		})
+
+		res := do(t, "true")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var events []x.DebugTraceEvent
+		require.NoError(t, json.Unmarshal([]byte(res.Header.Get(x.DebugTraceHeader)), &events))
+		assert.Equal(t, []x.DebugTraceEvent{{Kind: "strategy", Flow: "login", Phase: "populate", Name: "password", Result: "success", NodesAdded: []string{"password/password"}}}, events)
+	})
+}
+
+func TestRedactError(t *testing.T) {
+	assert.Equal(t, "session_inactive", x.RedactError(errors.WithStack(herodot.ErrUnauthorized.WithID("session_inactive").WithReason("Session of jane@example.com is inactive."))))
+	assert.Equal(t, "Bad Request", x.RedactError(errors.WithStack(herodot.ErrBadRequest.WithReason("The email jane@example.com is invalid."))))
+	assert.Equal(t, "*errors.fundamental", x.RedactError(errors.New("jane@example.com")))
+}
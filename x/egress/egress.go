@@ -0,0 +1,291 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+// Package egress restricts the destinations of outgoing HTTP requests.
+package egress
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"code.dny.dev/ssrf"
+	"github.com/gobwas/glob"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ErrDestinationNotAllowed is returned for requests to addresses which are not permitted by the
+// policy.
+var ErrDestinationNotAllowed = errors.New("the destination is not allowed by the egress policy")
+
+var privateIPGuardian = ssrf.New(ssrf.WithAnyPort(), ssrf.WithAnyNetwork())
+
+// Policy restricts the destinations of outgoing HTTP requests.
+//
+// Destinations are checked against the IP address which is actually dialed, after the host name
+// has been resolved. A host name which resolves to an allowed address when it is validated but to
+// a forbidden one when the connection is opened (DNS rebinding) is therefore rejected.
+//
+// Proxies, whether configured in the policy or in the HTTP_PROXY and HTTPS_PROXY environment
+// variables, are dialed without checking their address. If a proxy is used, the proxy resolves the
+// host name itself. The destination is then resolved and checked before the request is handed to
+// the proxy, which cannot rule out DNS rebinding. The proxy should enforce an equivalent policy in
+// this case.
+type Policy struct {
+	// ProxyURL is the proxy used for all requests. If nil, the proxy is taken from the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables when the transport is created.
+	ProxyURL *url.URL
+
+	// AllowedCIDRs, if not empty, are the only address ranges requests may be sent to. Addresses
+	// in these ranges may be called even if they are private.
+	AllowedCIDRs []netip.Prefix
+
+	// DeniedCIDRs are address ranges requests may never be sent to. They take precedence over
+	// all other settings.
+	DeniedCIDRs []netip.Prefix
+
+	// DisallowPrivateIPRanges denies requests to private, loopback and other special purpose
+	// addresses.
+	DisallowPrivateIPRanges bool
+
+	// PrivateIPExceptionURLs are globs of URLs which may be called even if they resolve to
+	// private addresses.
+	PrivateIPExceptionURLs []string
+
+	// TLSConfig is the TLS configuration of the transport. If nil, the default is used.
+	TLSConfig *tls.Config
+}
+
+// IsDefault returns true if neither a proxy nor address ranges are configured. The default
+// transports of ory/x/httpx enforce such policies as well.
+func (p *Policy) IsDefault() bool {
+	return p.ProxyURL == nil && len(p.AllowedCIDRs) == 0 && len(p.DeniedCIDRs) == 0
+}
+
+// Key returns a string which identifies the policy, ignoring the TLS configuration. It can be used
+// to cache transports.
+func (p *Policy) Key() string {
+	var b strings.Builder
+	if p.ProxyURL != nil {
+		b.WriteString(p.ProxyURL.String())
+	}
+	fmt.Fprintf(&b, "\n%v\n%v\n%t\n%s", p.AllowedCIDRs, p.DeniedCIDRs, p.DisallowPrivateIPRanges, strings.Join(p.PrivateIPExceptionURLs, " "))
+	return b.String()
+}
+
+// Check returns an error if requests to the address are not permitted. If allowPrivate is true,
+// private addresses are permitted regardless of DisallowPrivateIPRanges.
+func (p *Policy) Check(addr netip.Addr, allowPrivate bool) error {
+	addr = addr.Unmap()
+
+	for _, prefix := range p.DeniedCIDRs {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s is in the denied range %s", ErrDestinationNotAllowed, addr, prefix)
+		}
+	}
+
+	if len(p.AllowedCIDRs) > 0 {
+		for _, prefix := range p.AllowedCIDRs {
+			if prefix.Contains(addr) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s is not in any of the allowed ranges", ErrDestinationNotAllowed, addr)
+	}
+
+	if p.DisallowPrivateIPRanges && !allowPrivate {
+		if err := privateIPGuardian.Safe("", netip.AddrPortFrom(addr, 0).String(), nil); err != nil {
+			return fmt.Errorf("%w: %s", ErrDestinationNotAllowed, err)
+		}
+	}
+
+	return nil
+}
+
+// IsPrivateIPException returns true if the URL matches one of the globs in
+// PrivateIPExceptionURLs.
+func (p *Policy) IsPrivateIPException(u *url.URL) bool {
+	if len(p.PrivateIPExceptionURLs) == 0 {
+		return false
+	}
+
+	stripped := *u
+	stripped.RawQuery = ""
+	stripped.RawFragment = ""
+	stripped.Fragment = ""
+
+	for _, exception := range p.PrivateIPExceptionURLs {
+		compiled, err := glob.Compile(exception, '.', '/')
+		if err != nil {
+			continue
+		}
+		if compiled.Match(stripped.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedTransports caches the transports returned by SharedTransport by the key of their policy.
+var sharedTransports sync.Map
+
+// SharedTransport returns a transport which enforces the policy and is shared with all callers
+// using an equal policy, so that connections are pooled across calls. Policies with a TLS
+// configuration are not cached, use NewTransport and cache the transport instead.
+func SharedTransport(p *Policy) http.RoundTripper {
+	if p.TLSConfig != nil {
+		return NewTransport(p)
+	}
+
+	key := p.Key()
+	if t, ok := sharedTransports.Load(key); ok {
+		return t.(http.RoundTripper)
+	}
+	actual, _ := sharedTransports.LoadOrStore(key, NewTransport(p))
+	return actual.(http.RoundTripper)
+}
+
+// NewTransport returns a transport which enforces the policy.
+func NewTransport(p *Policy) http.RoundTripper {
+	return otelhttp.NewTransport(&transport{
+		policy:           p,
+		strict:           newHTTPTransport(p, false),
+		privateException: newHTTPTransport(p, true),
+	})
+}
+
+type transport struct {
+	policy           *Policy
+	strict           *http.Transport
+	privateException *http.Transport
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	allowPrivate := t.policy.IsPrivateIPException(r.URL)
+
+	proxy, err := t.strict.Proxy(r)
+	if err != nil {
+		return nil, err
+	}
+	if proxy != nil {
+		// The proxy dials the destination, so the best we can do is to check the addresses the
+		// host name resolves to now.
+		if err := t.checkHost(r.Context(), r.URL.Hostname(), allowPrivate); err != nil {
+			return nil, err
+		}
+	}
+
+	if allowPrivate {
+		return t.privateException.RoundTrip(r)
+	}
+	return t.strict.RoundTrip(r)
+}
+
+func (t *transport) checkHost(ctx context.Context, host string, allowPrivate bool) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return t.policy.Check(addr, allowPrivate)
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := t.policy.Check(addr, allowPrivate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newHTTPTransport(p *Policy, allowPrivate bool) *http.Transport {
+	checked := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: could not parse %s: %s", ErrDestinationNotAllowed, address, err)
+			}
+			return p.Check(addr.Addr(), allowPrivate)
+		},
+	}
+
+	// The proxies are configured by the operator and therefore exempt from the policy, which is
+	// applied to the destination host instead.
+	var proxy func(*http.Request) (*url.URL, error)
+	proxies := map[string]bool{}
+	if p.ProxyURL != nil {
+		proxy = http.ProxyURL(p.ProxyURL)
+		proxies[proxyHostPort(p.ProxyURL)] = true
+	} else {
+		env := httpproxy.FromEnvironment()
+		proxyFunc := env.ProxyFunc()
+		proxy = func(r *http.Request) (*url.URL, error) { return proxyFunc(r.URL) }
+		for _, raw := range []string{env.HTTPProxy, env.HTTPSProxy} {
+			if u := parseEnvironmentProxy(raw); u != nil {
+				proxies[proxyHostPort(u)] = true
+			}
+		}
+	}
+
+	dial := checked.DialContext
+	if len(proxies) > 0 {
+		unchecked := &net.Dialer{Timeout: checked.Timeout, KeepAlive: checked.KeepAlive}
+		dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if proxies[address] {
+				return unchecked.DialContext(ctx, network, address)
+			}
+			return checked.DialContext(ctx, network, address)
+		}
+	}
+
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dial,
+		TLSClientConfig:       p.TLSConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// parseEnvironmentProxy parses a proxy URL of the HTTP_PROXY or HTTPS_PROXY environment variables,
+// which may omit the scheme.
+func parseEnvironmentProxy(raw string) *url.URL {
+	if raw == "" {
+		return nil
+	}
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u
+	}
+	if u, err := url.Parse("http://" + raw); err == nil && u.Host != "" {
+		return u
+	}
+	return nil
+}
+
+func proxyHostPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	switch u.Scheme {
+	case "https":
+		return net.JoinHostPort(u.Hostname(), "443")
+	case "socks5", "socks5h":
+		return net.JoinHostPort(u.Hostname(), "1080")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
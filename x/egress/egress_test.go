@@ -0,0 +1,141 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package egress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		policy       Policy
+		addr         string
+		allowPrivate bool
+		allowed      bool
+	}{
+		{name: "empty policy", addr: "127.0.0.1", allowed: true},
+		{name: "public address", policy: Policy{DisallowPrivateIPRanges: true}, addr: "8.8.8.8", allowed: true},
+		{name: "private address", policy: Policy{DisallowPrivateIPRanges: true}, addr: "10.1.2.3"},
+		{name: "loopback address", policy: Policy{DisallowPrivateIPRanges: true}, addr: "::1"},
+		{name: "mapped private address", policy: Policy{DisallowPrivateIPRanges: true}, addr: "::ffff:127.0.0.1"},
+		{name: "private exception", policy: Policy{DisallowPrivateIPRanges: true}, addr: "10.1.2.3", allowPrivate: true, allowed: true},
+		{name: "denied", policy: Policy{DeniedCIDRs: []netip.Prefix{netip.MustParsePrefix("8.8.0.0/16")}}, addr: "8.8.8.8"},
+		{name: "denied exception", policy: Policy{DeniedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}, addr: "10.1.2.3", allowPrivate: true},
+		{name: "not allowed", policy: Policy{AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("8.8.0.0/16")}}, addr: "1.1.1.1"},
+		{name: "allowed private", policy: Policy{DisallowPrivateIPRanges: true, AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}, addr: "10.1.2.3", allowed: true},
+		{
+			name: "denied takes precedence",
+			policy: Policy{
+				AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+				DeniedCIDRs:  []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+			},
+			addr: "10.1.2.3",
+		},
+	} {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			err := tc.policy.Check(netip.MustParseAddr(tc.addr), tc.allowPrivate)
+			if tc.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrDestinationNotAllowed)
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	// The host name resolves to a loopback address, which is only detected once it is dialed.
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	u.Host = "localhost:" + u.Port()
+
+	do := func(t *testing.T, p *Policy, target string) error {
+		res, err := (&http.Client{Transport: NewTransport(p)}).Get(target)
+		if err != nil {
+			return err
+		}
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		return nil
+	}
+
+	t.Run("case=private addresses are rejected after resolving", func(t *testing.T) {
+		assert.ErrorIs(t, do(t, &Policy{DisallowPrivateIPRanges: true}, u.String()), ErrDestinationNotAllowed)
+	})
+
+	t.Run("case=private exception URLs are allowed", func(t *testing.T) {
+		p := &Policy{DisallowPrivateIPRanges: true, PrivateIPExceptionURLs: []string{"http://localhost:*/allowed"}}
+		assert.NoError(t, do(t, p, u.String()+"/allowed?foo=bar"))
+		assert.ErrorIs(t, do(t, p, u.String()+"/other"), ErrDestinationNotAllowed)
+	})
+
+	t.Run("case=allowed CIDRs", func(t *testing.T) {
+		assert.NoError(t, do(t, &Policy{DisallowPrivateIPRanges: true, AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}}, u.String()))
+		assert.ErrorIs(t, do(t, &Policy{AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("8.8.8.8/32")}}, u.String()), ErrDestinationNotAllowed)
+	})
+
+	t.Run("case=denied CIDRs", func(t *testing.T) {
+		assert.ErrorIs(t, do(t, &Policy{DeniedCIDRs: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}}, u.String()), ErrDestinationNotAllowed)
+	})
+
+	t.Run("case=proxy", func(t *testing.T) {
+		var proxied atomic.Int32
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied.Add(1)
+			assert.Equal(t, "203.0.113.10", r.URL.Hostname())
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(proxy.Close)
+		proxyURL, err := url.Parse(proxy.URL)
+		require.NoError(t, err)
+
+		// The proxy itself is on a loopback address but exempt from the policy.
+		p := &Policy{ProxyURL: proxyURL, DisallowPrivateIPRanges: true, AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}}
+		require.NoError(t, do(t, p, "http://203.0.113.10/hook"))
+		assert.EqualValues(t, 1, proxied.Load())
+
+		// Destinations are still checked before the request is sent to the proxy.
+		p = &Policy{ProxyURL: proxyURL, DeniedCIDRs: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}}
+		assert.ErrorIs(t, do(t, p, "http://203.0.113.10/hook"), ErrDestinationNotAllowed)
+		assert.EqualValues(t, 1, proxied.Load())
+	})
+	t.Run("case=environment proxy", func(t *testing.T) {
+		var proxied atomic.Int32
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(proxy.Close)
+
+		// The proxy is on a loopback address but dialed regardless of the policy.
+		t.Setenv("HTTP_PROXY", proxy.URL)
+		p := &Policy{DisallowPrivateIPRanges: true, AllowedCIDRs: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}}
+		require.NoError(t, do(t, p, "http://203.0.113.10/hook"))
+		assert.EqualValues(t, 1, proxied.Load())
+
+		// The policy still applies to the destination.
+		assert.ErrorIs(t, do(t, p, "http://198.51.100.10/hook"), ErrDestinationNotAllowed)
+		assert.EqualValues(t, 1, proxied.Load())
+	})
+}
+
+func TestSharedTransport(t *testing.T) {
+	p := &Policy{DeniedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	assert.Same(t, SharedTransport(p), SharedTransport(&Policy{DeniedCIDRs: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}))
+	assert.NotSame(t, SharedTransport(p), SharedTransport(&Policy{DeniedCIDRs: p.DeniedCIDRs, DisallowPrivateIPRanges: true}))
+}
@@ -0,0 +1,128 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+)
+
+// ErrorCodeDocumentationURL is the base of the documentation URLs of error codes.
+const ErrorCodeDocumentationURL = "https://www.ory.sh/docs/kratos/reference/error-codes#"
+
+type errorEnvelopeDependencies interface {
+	config.Provider
+}
+
+// WithErrorEnvelope wraps the error enhancer of the writer. If the `feature_flags.error_envelope`
+// flag is enabled, JSON errors are returned in the error envelope, see ErrorEnvelope.
+func WithErrorEnvelope(d errorEnvelopeDependencies, w *herodot.JSONWriter) *herodot.JSONWriter {
+	enhance := w.ErrorEnhancer
+	w.ErrorEnhancer = func(r *http.Request, err error) interface{} {
+		var payload interface{} = err
+		if enhance != nil {
+			payload = enhance(r, err)
+		}
+		if !d.Config().UseErrorEnvelope(r.Context()) {
+			return payload
+		}
+
+		envelope, eerr := ErrorEnvelope(r, payload, w.EnableDebug)
+		if eerr != nil {
+			return payload
+		}
+		return envelope
+	}
+	return w
+}
+
+// ErrorEnvelope converts an error payload to the error envelope. The `error` object contains
+//
+//   - `code`, a stable string which SDKs can branch on. It is the error ID if the error has
+//     one, and otherwise derived from the HTTP status, e.g. `not_found`.
+//   - `status_code`, the HTTP status code.
+//   - `request_id`, taken from the `X-Request-ID` header or the trace ID of the request.
+//   - `docs_url`, which points to the documentation of the code.
+//
+// All other fields of the payload, such as `redirect_browser_to`, are kept.
+func ErrorEnvelope(r *http.Request, payload interface{}, debug bool) (json.RawMessage, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if !gjson.GetBytes(raw, "error").IsObject() {
+		if raw, err = sjson.SetRawBytes([]byte(`{}`), "error", raw); err != nil {
+			return nil, err
+		}
+	}
+
+	e := gjson.GetBytes(raw, "error")
+	statusCode := int(e.Get("code").Int())
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+	code := e.Get("id").String()
+	if code == "" {
+		code = StatusErrorCode(statusCode)
+	}
+	requestID := e.Get("request").String()
+	if requestID == "" {
+		requestID = RequestIDFromRequest(r)
+	}
+
+	for _, set := range []struct {
+		path  string
+		value interface{}
+	}{
+		{"error.code", code},
+		{"error.status_code", statusCode},
+		{"error.request_id", requestID},
+		{"error.docs_url", ErrorCodeDocumentationURL + code},
+	} {
+		if raw, err = sjson.SetBytes(raw, set.path, set.value); err != nil {
+			return nil, err
+		}
+	}
+
+	remove := []string{"error.request"}
+	if !debug {
+		remove = append(remove, "error.debug")
+	}
+	for _, path := range remove {
+		if raw, err = sjson.DeleteBytes(raw, path); err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// StatusErrorCode returns the error code for errors without an ID, e.g. `not_found` for 404.
+func StatusErrorCode(statusCode int) string {
+	status := http.StatusText(statusCode)
+	if status == "" {
+		return "unknown_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(status, "-", " ")), " ", "_")
+}
+
+// RequestIDFromRequest returns the `X-Request-ID` header or, if it is not set, the trace ID of
+// the request. If neither is available, a random ID is returned.
+func RequestIDFromRequest(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		return id
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return uuid.Must(uuid.NewV4()).String()
+}
@@ -0,0 +1,85 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/selfservice/flow"
+	"github.com/ory/kratos/text"
+	"github.com/ory/kratos/x"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+	w := x.WithErrorEnvelope(reg, herodot.NewJSONWriter(nil))
+
+	write := func(t *testing.T, err error, header http.Header) (int, gjson.Result) {
+		t.Helper()
+		r := httptest.NewRequest("GET", "/", nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		w.WriteError(rec, r, err)
+		return rec.Code, gjson.ParseBytes(rec.Body.Bytes())
+	}
+
+	t.Run("case=disabled", func(t *testing.T) {
+		code, body := write(t, herodot.ErrNotFound.WithReason("not here"), nil)
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.EqualValues(t, http.StatusNotFound, body.Get("error.code").Int(), "%s", body.Raw)
+		assert.False(t, body.Get("error.docs_url").Exists(), "%s", body.Raw)
+	})
+
+	conf.MustSet(ctx, config.ViperKeyFeatureFlagErrorEnvelope, true)
+
+	t.Run("case=derives the code from the status", func(t *testing.T) {
+		code, body := write(t, herodot.ErrNotFound.WithReason("not here").WithDebug("secret"), http.Header{"X-Request-Id": {"request-1"}})
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, "not_found", body.Get("error.code").String(), "%s", body.Raw)
+		assert.EqualValues(t, http.StatusNotFound, body.Get("error.status_code").Int(), "%s", body.Raw)
+		assert.Equal(t, "request-1", body.Get("error.request_id").String(), "%s", body.Raw)
+		assert.Equal(t, x.ErrorCodeDocumentationURL+"not_found", body.Get("error.docs_url").String(), "%s", body.Raw)
+		assert.Equal(t, "not here", body.Get("error.reason").String(), "%s", body.Raw)
+		assert.False(t, body.Get("error.debug").Exists(), "%s", body.Raw)
+	})
+
+	t.Run("case=uses the error ID as code", func(t *testing.T) {
+		_, body := write(t, flow.NewBrowserLocationChangeRequiredError("https://www.ory.sh/"), nil)
+		assert.Equal(t, text.ErrIDSelfServiceBrowserLocationChangeRequiredError, body.Get("error.code").String(), "%s", body.Raw)
+		assert.Equal(t, text.ErrIDSelfServiceBrowserLocationChangeRequiredError, body.Get("error.id").String(), "%s", body.Raw)
+		assert.Equal(t, "https://www.ory.sh/", body.Get("redirect_browser_to").String(), "%s", body.Raw)
+		assert.NotEmpty(t, body.Get("error.request_id").String(), "%s", body.Raw)
+	})
+
+	t.Run("case=wraps plain errors", func(t *testing.T) {
+		code, body := write(t, errors.New("boom"), nil)
+		assert.Equal(t, http.StatusInternalServerError, code)
+		assert.Equal(t, "internal_server_error", body.Get("error.code").String(), "%s", body.Raw)
+		assert.Equal(t, "boom", body.Get("error.message").String(), "%s", body.Raw)
+	})
+}
+
+func TestStatusErrorCode(t *testing.T) {
+	for status, code := range map[int]string{
+		http.StatusBadRequest:           "bad_request",
+		http.StatusMultiStatus:          "multi_status",
+		http.StatusNonAuthoritativeInfo: "non_authoritative_information",
+		599:                             "unknown_error",
+	} {
+		assert.Equal(t, code, x.StatusErrorCode(status))
+	}
+}
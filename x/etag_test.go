@@ -0,0 +1,51 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeakETag(t *testing.T) {
+	now := time.Now()
+	etag := WeakETag("id", now)
+	assert.Regexp(t, `^W/"[0-9a-f]{32}"$`, etag)
+	assert.Equal(t, etag, WeakETag("id", now))
+	assert.NotEqual(t, etag, WeakETag("id", now.Add(time.Nanosecond)))
+	assert.NotEqual(t, WeakETag("a", "bc"), WeakETag("ab", "c"))
+}
+
+func TestNotModified(t *testing.T) {
+	const etag = `W/"abc"`
+	for k, tc := range []struct {
+		method      string
+		ifNoneMatch string
+		expected    bool
+	}{
+		{method: "GET", ifNoneMatch: "", expected: false},
+		{method: "GET", ifNoneMatch: `W/"abc"`, expected: true},
+		{method: "GET", ifNoneMatch: `"abc"`, expected: true},
+		{method: "HEAD", ifNoneMatch: `W/"xyz", W/"abc"`, expected: true},
+		{method: "GET", ifNoneMatch: `W/"xyz"`, expected: false},
+		{method: "GET", ifNoneMatch: `*`, expected: true},
+		{method: "POST", ifNoneMatch: `W/"abc"`, expected: false},
+	} {
+		r := httptest.NewRequest(tc.method, "/", nil)
+		if tc.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tc.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+
+		assert.Equal(t, tc.expected, NotModified(w, r, etag), "%d", k)
+		assert.Equal(t, etag, w.Header().Get("ETag"), "%d", k)
+		if tc.expected {
+			assert.Equal(t, http.StatusNotModified, w.Code, "%d", k)
+		}
+	}
+}
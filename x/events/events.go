@@ -39,6 +39,7 @@ const (
 	IdentityCreated          semconv.Event = "IdentityCreated"
 	IdentityUpdated          semconv.Event = "IdentityUpdated"
 	IdentityDeleted          semconv.Event = "IdentityDeleted"
+	IdentityStateChanged     semconv.Event = "IdentityStateChanged"
 	WebhookDelivered         semconv.Event = "WebhookDelivered"
 	WebhookSucceeded         semconv.Event = "WebhookSucceeded"
 	WebhookFailed            semconv.Event = "WebhookFailed"
@@ -64,6 +65,8 @@ const (
 	AttributeKeyWebhookTriggerID                semconv.AttributeKey = "WebhookTriggerID"
 	AttributeKeyReason                          semconv.AttributeKey = "Reason"
 	AttributeKeyFlowID                          semconv.AttributeKey = "FlowID"
+	AttributeKeyIdentityStateFrom               semconv.AttributeKey = "IdentityStateFrom"
+	AttributeKeyIdentityStateTo                 semconv.AttributeKey = "IdentityStateTo"
 )
 
 func attrSessionID(val uuid.UUID) otelattr.KeyValue {
@@ -331,6 +334,19 @@ func NewIdentityUpdated(ctx context.Context, identityID uuid.UUID) (string, trac
 		)
 }
 
+func NewIdentityStateChanged(ctx context.Context, identityID uuid.UUID, from, to, reason string) (string, trace.EventOption) {
+	return IdentityStateChanged.String(),
+		trace.WithAttributes(
+			append(
+				semconv.AttributesFromContext(ctx),
+				semconv.AttrIdentityID(identityID),
+				otelattr.String(AttributeKeyIdentityStateFrom.String(), from),
+				otelattr.String(AttributeKeyIdentityStateTo.String(), to),
+				otelattr.String(AttributeKeyReason.String(), reason),
+			)...,
+		)
+}
+
 func NewLoginFailed(ctx context.Context, flowID uuid.UUID, flowType, requestedAAL string, isRefresh bool, err error) (string, trace.EventOption) {
 	return LoginFailed.String(),
 		trace.WithAttributes(append(
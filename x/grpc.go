@@ -0,0 +1,165 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/ory/herodot"
+	"github.com/ory/x/logrusx"
+)
+
+// GRPCStatusError converts the error to a gRPC status error. Errors carrying an HTTP status code
+// are mapped to the gRPC code with the same meaning. The status of herodot errors is not used
+// directly because it includes the stack trace and not all errors set a gRPC code.
+func GRPCStatusError(err error) error {
+	if err == nil {
+		return nil
+	} else if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	var e *herodot.DefaultError
+	if !errors.As(err, &e) {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Error(codes.Internal, http.StatusText(http.StatusInternalServerError))
+	}
+
+	message := e.ErrorField
+	if e.ReasonField != "" {
+		message += ": " + e.ReasonField
+	}
+
+	switch e.StatusCode() {
+	case http.StatusBadRequest:
+		return status.Error(codes.InvalidArgument, message)
+	case http.StatusUnauthorized:
+		return status.Error(codes.Unauthenticated, message)
+	case http.StatusForbidden:
+		return status.Error(codes.PermissionDenied, message)
+	case http.StatusNotFound, http.StatusGone:
+		return status.Error(codes.NotFound, message)
+	case http.StatusConflict:
+		return status.Error(codes.AlreadyExists, message)
+	case http.StatusPreconditionFailed, http.StatusUnprocessableEntity:
+		return status.Error(codes.FailedPrecondition, message)
+	case http.StatusTooManyRequests:
+		return status.Error(codes.ResourceExhausted, message)
+	case http.StatusNotImplemented:
+		return status.Error(codes.Unimplemented, message)
+	case http.StatusServiceUnavailable:
+		return status.Error(codes.Unavailable, message)
+	case http.StatusGatewayTimeout:
+		return status.Error(codes.DeadlineExceeded, message)
+	default:
+		return status.Error(codes.Internal, message)
+	}
+}
+
+// GRPCErrorUnaryInterceptor converts the errors returned by unary handlers to gRPC status errors
+// and logs internal errors.
+func GRPCErrorUnaryInterceptor(l *logrusx.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		res, err := handler(ctx, req)
+		return res, grpcError(l, info.FullMethod, err)
+	}
+}
+
+// GRPCErrorStreamInterceptor converts the errors returned by stream handlers to gRPC status errors
+// and logs internal errors.
+func GRPCErrorStreamInterceptor(l *logrusx.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return grpcError(l, info.FullMethod, handler(srv, ss))
+	}
+}
+
+func grpcError(l *logrusx.Logger, method string, err error) error {
+	converted := GRPCStatusError(err)
+	if status.Code(converted) == codes.Internal {
+		l.WithError(err).WithField("grpc_method", method).Error("An error occurred while handling a gRPC call.")
+	}
+	return converted
+}
+
+// UnaryServerInterceptor requires calls to the given services to include a valid admin API token
+// in the `authorization` metadata if admin API tokens are configured.
+func (a *AdminAPITokenAuthorizer) UnaryServerInterceptor(services ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !isGRPCServiceMethod(info.FullMethod, services) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := a.authorizeGRPC(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is like UnaryServerInterceptor for streaming calls.
+func (a *AdminAPITokenAuthorizer) StreamServerInterceptor(services ...string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !isGRPCServiceMethod(info.FullMethod, services) {
+			return handler(srv, ss)
+		}
+
+		ctx, err := a.authorizeGRPC(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStreamWithContext{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (a *AdminAPITokenAuthorizer) authorizeGRPC(ctx context.Context, method string) (context.Context, error) {
+	var presented string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		if token, ok := strings.CutPrefix(values[0], "Bearer "); ok {
+			presented = token
+		}
+	}
+
+	ctx, err := a.authorize(ctx, presented, func(l *logrusx.Logger) *logrusx.Logger { return l.WithField("grpc_method", method) })
+	if errors.Is(err, ErrMissingAdminAPIToken) {
+		return nil, status.Error(codes.Unauthenticated, "The call must include a valid admin API token in the authorization metadata.")
+	} else if err != nil {
+		return nil, GRPCStatusError(err)
+	}
+	return ctx, nil
+}
+
+// isGRPCServiceMethod returns true if the full method name, which has the form
+// `/package.Service/Method`, belongs to one of the services.
+func isGRPCServiceMethod(fullMethod string, services []string) bool {
+	service, _, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	for _, s := range services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+type serverStreamWithContext struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStreamWithContext) Context() context.Context {
+	return s.ctx
+}
@@ -4,6 +4,7 @@
 package x
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"strings"
@@ -21,10 +22,11 @@ import (
 )
 
 type secureRedirectOptions struct {
-	allowlist       []url.URL
-	defaultReturnTo *url.URL
-	returnTo        string
-	sourceURL       string
+	allowlist           []url.URL
+	defaultReturnTo     *url.URL
+	returnTo            string
+	sourceURL           string
+	verifyReturnToGrant func(ctx context.Context, grant string) (*url.URL, error)
 }
 
 type SecureRedirectOption func(*secureRedirectOptions)
@@ -60,6 +62,16 @@ func SecureRedirectAllowSelfServiceURLs(publicURL *url.URL) SecureRedirectOption
 	}
 }
 
+// SecureRedirectAllowReturnToGrants allows `?return_to=` values which are not part of the
+// allow list if the source URL carries a valid signed `?return_to_grant=` for them.
+func SecureRedirectAllowReturnToGrants(d returnToGrantDependencies) SecureRedirectOption {
+	return func(o *secureRedirectOptions) {
+		o.verifyReturnToGrant = func(ctx context.Context, grant string) (*url.URL, error) {
+			return VerifyReturnToGrant(ctx, d, grant)
+		}
+	}
+}
+
 // SecureRedirectOverrideDefaultReturnTo overrides the defaultReturnTo address specified
 // as the second arg.
 func SecureRedirectOverrideDefaultReturnTo(defaultReturnTo *url.URL) SecureRedirectOption {
@@ -76,8 +88,17 @@ func SecureRedirectToIsAllowedHost(returnTo *url.URL, allowed url.URL) bool {
 	return strings.EqualFold(allowed.Host, returnTo.Host)
 }
 
+func secureRedirectIsAllowed(returnTo *url.URL, allowed url.URL) bool {
+	return strings.EqualFold(allowed.Scheme, returnTo.Scheme) &&
+		SecureRedirectToIsAllowedHost(returnTo, allowed) &&
+		strings.HasPrefix(
+			stringsx.Coalesce(returnTo.Path, "/"),
+			stringsx.Coalesce(allowed.Path, "/"))
+}
+
 // TakeOverReturnToParameter carries over the return_to parameter to a new URL
 // If `from` does not contain the `return_to` query parameter, the first non-empty value from `fallback` is used instead.
+// The `return_to_grant` parameter is carried over as well.
 func TakeOverReturnToParameter(from string, to string, fallback ...string) (string, error) {
 	fromURL, err := url.Parse(from)
 	if err != nil {
@@ -94,6 +115,9 @@ func TakeOverReturnToParameter(from string, to string, fallback ...string) (stri
 	}
 	toQuery := toURL.Query()
 	toQuery.Set("return_to", returnTo)
+	if grant := fromURL.Query().Get("return_to_grant"); grant != "" {
+		toQuery.Set("return_to_grant", grant)
+	}
 	toURL.RawQuery = toQuery.Encode()
 	return toURL.String(), nil
 }
@@ -132,15 +156,28 @@ func SecureRedirectTo(r *http.Request, defaultReturnTo *url.URL, opts ...SecureR
 	returnTo.Scheme = stringsx.Coalesce(returnTo.Scheme, o.defaultReturnTo.Scheme)
 
 	for _, allowed := range o.allowlist {
-		if strings.EqualFold(allowed.Scheme, returnTo.Scheme) &&
-			SecureRedirectToIsAllowedHost(returnTo, allowed) &&
-			strings.HasPrefix(
-				stringsx.Coalesce(returnTo.Path, "/"),
-				stringsx.Coalesce(allowed.Path, "/")) {
+		if secureRedirectIsAllowed(returnTo, allowed) {
 			return returnTo, nil
 		}
 	}
 
+	// URLs which are not part of the allow list may be allowed by a signed grant.
+	if grant := source.Query().Get("return_to_grant"); grant != "" {
+		if o.verifyReturnToGrant != nil {
+			allowed, err := o.verifyReturnToGrant(r.Context(), grant)
+			if err != nil {
+				return nil, errors.WithStack(herodot.ErrBadRequest.
+					WithID(text.ErrIDRedirectURLNotAllowed).
+					WithWrap(err).
+					WithReasonf("Requested return_to URL %q is not allowed because the return_to grant is invalid.", returnTo),
+				)
+			}
+			if secureRedirectIsAllowed(returnTo, *allowed) {
+				return returnTo, nil
+			}
+		}
+	}
+
 	return nil, errors.WithStack(herodot.ErrBadRequest.
 		WithID(text.ErrIDRedirectURLNotAllowed).
 		WithReasonf("Requested return_to URL %q is not allowed.", returnTo),
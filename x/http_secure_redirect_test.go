@@ -5,14 +5,21 @@ package x_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -21,6 +28,7 @@ import (
 
 	"github.com/ory/kratos/driver/config"
 	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/text"
 	"github.com/ory/kratos/x"
 )
 
@@ -115,6 +123,7 @@ func TestTakeOverReturnToParameter(t *testing.T) {
 		"case=only return_to is taken over when multiple query parameters are set": {fromUrl: "https://original.bar?return_to=https://allowed.domain&flow=12312", toURL: "https://output.bar", expectedOutputUrl: "https://output.bar?return_to=https%3A%2F%2Fallowed.domain"},
 		"case=output query parameters are preserved":                               {fromUrl: "https://original.bar?return_to=https://allowed.domain", toURL: "https://output.bar?flow=123321", expectedOutputUrl: "https://output.bar?flow=123321&return_to=https%3A%2F%2Fallowed.domain"},
 		"case=when original return_to is empty do nothing":                         {fromUrl: "https://original.bar?return_to=", toURL: "https://output.bar?flow=123123", expectedOutputUrl: "https://output.bar?flow=123123"},
+		"case=return_to_grant is taken over":                                       {fromUrl: "https://original.bar?return_to=https://allowed.domain&return_to_grant=grant", toURL: "https://output.bar", expectedOutputUrl: "https://output.bar?return_to=https%3A%2F%2Fallowed.domain&return_to_grant=grant"},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -313,3 +322,123 @@ func TestSecureRedirectTo(t *testing.T) {
 		assert.Equal(t, body, "http://www.ory.sh/kratos")
 	})
 }
+
+func TestSecureRedirectToWithReturnToGrant(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]any{{
+		"kty": "EC",
+		"crv": "P-256",
+		"kid": "grant",
+		"alg": "ES256",
+		"use": "sig",
+		"x":   base64.RawURLEncoding.EncodeToString(key.PublicKey.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.PublicKey.Y.FillBytes(make([]byte, 32))),
+	}}})
+	require.NoError(t, err)
+	conf.MustSet(ctx, config.ViperKeyURLsReturnToGrants, map[string]any{
+		"jwks_url": "base64://" + base64.StdEncoding.EncodeToString(jwks),
+		"issuer":   "https://backend.example.com",
+	})
+
+	sign := func(t *testing.T, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = "grant"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	validClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":       "https://backend.example.com",
+			"exp":       time.Now().Add(time.Hour).Unix(),
+			"return_to": "https://shop.example.org/account",
+		}
+	}
+
+	redirect := func(t *testing.T, returnTo, grant string) (*url.URL, error) {
+		r := httptest.NewRequest("GET", "https://kratos.example.com/?"+url.Values{"return_to": {returnTo}, "return_to_grant": {grant}}.Encode(), nil)
+		return x.SecureRedirectTo(r,
+			urlx.ParseOrPanic("https://www.ory.sh/default-return-to"),
+			x.SecureRedirectAllowURLs([]url.URL{*urlx.ParseOrPanic("https://www.ory.sh")}),
+			x.SecureRedirectAllowReturnToGrants(reg),
+		)
+	}
+
+	t.Run("case=allows the URL of the grant", func(t *testing.T) {
+		returnTo, err := redirect(t, "https://shop.example.org/account/orders", sign(t, validClaims()))
+		require.NoError(t, err)
+		assert.Equal(t, "https://shop.example.org/account/orders", returnTo.String())
+	})
+
+	t.Run("case=static allow list still applies", func(t *testing.T) {
+		returnTo, err := redirect(t, "https://www.ory.sh/kratos", sign(t, validClaims()))
+		require.NoError(t, err)
+		assert.Equal(t, "https://www.ory.sh/kratos", returnTo.String())
+	})
+
+	t.Run("case=rejects URLs outside of the grant", func(t *testing.T) {
+		for _, returnTo := range []string{
+			"https://shop.example.org/admin",
+			"https://evil.example.org/account",
+			"http://shop.example.org/account",
+		} {
+			_, err := redirect(t, returnTo, sign(t, validClaims()))
+			require.Error(t, err, returnTo)
+			assert.Equal(t, text.ErrIDRedirectURLNotAllowed, errors.Cause(err).(*herodot.DefaultError).ID(), returnTo)
+		}
+	})
+
+	t.Run("case=rejects invalid grants", func(t *testing.T) {
+		expired := validClaims()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+		noExpiry := validClaims()
+		delete(noExpiry, "exp")
+
+		wrongIssuer := validClaims()
+		wrongIssuer["iss"] = "https://evil.example.org"
+
+		wildcard := validClaims()
+		wildcard["return_to"] = "https://*.example.org/"
+
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		forged := jwt.NewWithClaims(jwt.SigningMethodES256, validClaims())
+		forged.Header["kid"] = "grant"
+		forgedGrant, err := forged.SignedString(otherKey)
+		require.NoError(t, err)
+
+		symmetric := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+		symmetric.Header["kid"] = "grant"
+		symmetricGrant, err := symmetric.SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		for name, grant := range map[string]string{
+			"expired":      sign(t, expired),
+			"no expiry":    sign(t, noExpiry),
+			"wrong issuer": sign(t, wrongIssuer),
+			"wildcard":     sign(t, wildcard),
+			"forged":       forgedGrant,
+			"symmetric":    symmetricGrant,
+			"malformed":    "not-a-jwt",
+		} {
+			_, err := redirect(t, "https://shop.example.org/account", grant)
+			require.Error(t, err, name)
+			assert.Equal(t, text.ErrIDRedirectURLNotAllowed, errors.Cause(err).(*herodot.DefaultError).ID(), name)
+		}
+	})
+
+	t.Run("case=ignores grants if not enabled", func(t *testing.T) {
+		conf.MustSet(ctx, config.ViperKeyURLsReturnToGrants, nil)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, config.ViperKeyURLsReturnToGrants, nil)
+		})
+
+		_, err := redirect(t, "https://shop.example.org/account", sign(t, validClaims()))
+		require.Error(t, err)
+	})
+}
@@ -11,15 +11,17 @@ import (
 	"golang.org/x/oauth2"
 
 	"github.com/ory/jsonschema/v3/httploader"
+	"github.com/ory/kratos/driver/config"
 )
 
-func HTTPLoaderContextMiddleware(reg interface {
-	HTTPClientProvider
-}) negroni.HandlerFunc {
+func HTTPLoaderContextMiddleware(reg HTTPClientProvider) negroni.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-		hc := reg.HTTPClient(r.Context())
-		ctx := context.WithValue(r.Context(), oauth2.HTTPClient, hc)
-		ctx = context.WithValue(ctx, httploader.ContextKey, hc)
-		next(rw, r.WithContext(ctx))
+		next(rw, r.WithContext(WithHTTPLoaderContext(r.Context(), reg)))
 	}
 }
+
+// WithHTTPLoaderContext adds the HTTP clients for OAuth2 and JSON Schema loading to the context.
+func WithHTTPLoaderContext(ctx context.Context, reg HTTPClientProvider) context.Context {
+	loaderCtx := context.WithValue(ctx, oauth2.HTTPClient, reg.HTTPClient(config.WithHTTPClientIntegration(ctx, config.HTTPClientIntegrationOIDC)))
+	return context.WithValue(loaderCtx, httploader.ContextKey, reg.HTTPClient(config.WithHTTPClientIntegration(ctx, config.HTTPClientIntegrationSchemas)))
+}
@@ -0,0 +1,171 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+)
+
+// RequestPriority decides which requests are rejected first when the service is overloaded.
+type RequestPriority int
+
+const (
+	// RequestPriorityLow is used for requests which start new user journeys, such as
+	// initializing self-service flows.
+	RequestPriorityLow RequestPriority = iota
+
+	// RequestPriorityHigh is used for requests which belong to already started user
+	// journeys, such as flow submissions and session checks.
+	RequestPriorityHigh
+
+	// RequestPriorityStream is used for long-lived streaming requests, such as session
+	// event streams. They are counted separately from other requests.
+	RequestPriorityStream
+
+	// RequestPriorityCritical is used for requests which are never rejected, such as
+	// health checks.
+	RequestPriorityCritical
+)
+
+func (p RequestPriority) String() string {
+	switch p {
+	case RequestPriorityLow:
+		return "low"
+	case RequestPriorityHigh:
+		return "high"
+	case RequestPriorityStream:
+		return "stream"
+	default:
+		return "critical"
+	}
+}
+
+const (
+	persisterLatencyProbeInterval = time.Second
+	persisterLatencyProbeTimeout  = 10 * time.Second
+)
+
+var (
+	ErrServiceOverloaded = herodot.DefaultError{
+		CodeField:   http.StatusServiceUnavailable,
+		StatusField: http.StatusText(http.StatusServiceUnavailable),
+		ErrorField:  "The service is temporarily overloaded.",
+		ReasonField: "Please retry the request after the time given in the Retry-After header.",
+	}
+
+	loadSheddingRequestsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kratos_load_shedding_requests_rejected_total",
+		Help: "Number of public requests rejected because the service was overloaded, labelled with the request priority.",
+	}, []string{"priority"})
+)
+
+// LoadSheddingCollectors returns the Prometheus collectors of the load shedding.
+// They are registered by the registry's metrics setup.
+func LoadSheddingCollectors() []prometheus.Collector {
+	return []prometheus.Collector{loadSheddingRequestsRejected}
+}
+
+type (
+	loadShedderDependencies interface {
+		config.Provider
+		LoggingProvider
+		WriterProvider
+		PingContext(context.Context) error
+	}
+
+	// LoadShedder is a middleware which rejects requests with 503 Service Unavailable
+	// when too many requests are in flight or the persister is slow. Low priority
+	// requests are rejected before high priority requests.
+	LoadShedder struct {
+		d        loadShedderDependencies
+		priority func(*http.Request) RequestPriority
+
+		inFlight         atomic.Int64
+		streams          atomic.Int64
+		persisterLatency atomic.Int64
+		lastProbe        atomic.Int64
+		probing          atomic.Bool
+	}
+)
+
+func NewLoadShedder(d loadShedderDependencies, priority func(*http.Request) RequestPriority) *LoadShedder {
+	return &LoadShedder{d: d, priority: priority}
+}
+
+func (s *LoadShedder) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	ctx := r.Context()
+	conf := s.d.Config().PublicLoadShedding(ctx)
+	if !conf.Enabled {
+		next(w, r)
+		return
+	}
+
+	priority := s.priority(r)
+	counter, threshold := &s.inFlight, conf.HighPriority
+	switch priority {
+	case RequestPriorityLow:
+		threshold = conf.LowPriority
+	case RequestPriorityStream:
+		// Streams stay open for as long as the client is connected and would otherwise
+		// exhaust the in-flight budget of regular requests.
+		counter, threshold = &s.streams, conf.Streams
+	}
+
+	inFlight := counter.Add(1)
+	defer counter.Add(-1)
+	s.probePersisterLatency(ctx)
+
+	if priority == RequestPriorityCritical {
+		next(w, r)
+		return
+	}
+
+	if inFlight > threshold.MaxInFlight || s.PersisterLatency() > threshold.MaxPersisterLatency {
+		loadSheddingRequestsRejected.WithLabelValues(priority.String()).Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(conf.RetryAfter.Seconds()))))
+		s.d.Writer().WriteError(w, r, errors.WithStack(ErrServiceOverloaded))
+		return
+	}
+
+	next(w, r)
+}
+
+// PersisterLatency returns the latency of the most recent persister health check.
+func (s *LoadShedder) PersisterLatency() time.Duration {
+	return time.Duration(s.persisterLatency.Load())
+}
+
+// probePersisterLatency measures the latency of the persister in the background. At most
+// one probe runs at a time, and probes are started at most once per probe interval.
+func (s *LoadShedder) probePersisterLatency(ctx context.Context) {
+	now := time.Now()
+	if now.Sub(time.Unix(0, s.lastProbe.Load())) < persisterLatencyProbeInterval || !s.probing.CompareAndSwap(false, true) {
+		return
+	}
+	s.lastProbe.Store(now.UnixNano())
+
+	go func() {
+		defer s.probing.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), persisterLatencyProbeTimeout)
+		defer cancel()
+
+		start := time.Now()
+		if err := s.d.PingContext(ctx); err != nil {
+			s.d.Logger().WithError(err).Warn("Unable to reach the persister while measuring its latency.")
+		}
+		s.persisterLatency.Store(int64(time.Since(start)))
+	}()
+}
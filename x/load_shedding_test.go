@@ -0,0 +1,171 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/kratos/driver"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/x"
+)
+
+type slowPersisterRegistry struct {
+	driver.Registry
+	latency time.Duration
+}
+
+func (r *slowPersisterRegistry) PingContext(ctx context.Context) error {
+	time.Sleep(r.latency)
+	return r.Registry.PingContext(ctx)
+}
+
+func TestLoadShedder(t *testing.T) {
+	priority := func(r *http.Request) x.RequestPriority {
+		switch r.URL.Path {
+		case "/low":
+			return x.RequestPriorityLow
+		case "/critical":
+			return x.RequestPriorityCritical
+		case "/stream":
+			return x.RequestPriorityStream
+		}
+		return x.RequestPriorityHigh
+	}
+
+	newServer := func(t *testing.T, d driver.Registry, block <-chan struct{}) (*x.LoadShedder, *httptest.Server) {
+		ls := x.NewLoadShedder(d, priority)
+		n := negroni.New(ls)
+		n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("block") {
+				<-block
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		ts := httptest.NewServer(n)
+		t.Cleanup(ts.Close)
+		return ls, ts
+	}
+
+	get := func(t require.TestingT, ts *httptest.Server, path string) *http.Response {
+		res, err := ts.Client().Get(ts.URL + path)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		return res
+	}
+
+	t.Run("case=passes all requests when disabled", func(t *testing.T) {
+		ctx := context.Background()
+		conf, reg := internal.NewFastRegistryWithMocks(t)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingLowInFlight, 1)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingLowLatency, "1ns")
+
+		_, ts := newServer(t, &slowPersisterRegistry{Registry: reg, latency: time.Millisecond}, nil)
+		for _, path := range []string{"/low", "/high", "/critical"} {
+			assert.Equal(t, http.StatusNoContent, get(t, ts, path).StatusCode, path)
+		}
+	})
+
+	t.Run("case=sheds low priority requests first when too many requests are in flight", func(t *testing.T) {
+		ctx := context.Background()
+		conf, reg := internal.NewFastRegistryWithMocks(t)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingEnabled, true)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingRetryAfter, "1500ms")
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingLowInFlight, 1)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingHighInFlight, 2)
+
+		block := make(chan struct{})
+		_, ts := newServer(t, reg, block)
+
+		done := make(chan *http.Response)
+		go func() {
+			res, err := ts.Client().Get(ts.URL + "/high?block")
+			if err == nil {
+				_ = res.Body.Close()
+			}
+			done <- res
+		}()
+
+		require.EventuallyWithT(t, func(t *assert.CollectT) {
+			res := get(t, ts, "/low")
+			assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+			assert.Equal(t, "2", res.Header.Get("Retry-After"))
+		}, 5*time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/high").StatusCode)
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/critical").StatusCode)
+
+		close(block)
+		res := <-done
+		require.NotNil(t, res)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/low").StatusCode)
+	})
+
+	t.Run("case=sheds low priority requests first when the persister is slow", func(t *testing.T) {
+		ctx := context.Background()
+		conf, reg := internal.NewFastRegistryWithMocks(t)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingEnabled, true)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingLowLatency, "10ms")
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingHighLatency, "1m")
+
+		ls, ts := newServer(t, &slowPersisterRegistry{Registry: reg, latency: 50 * time.Millisecond}, nil)
+
+		// The first request starts measuring the persister latency.
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/low").StatusCode)
+		require.Eventually(t, func() bool {
+			return ls.PersisterLatency() >= 50*time.Millisecond
+		}, 5*time.Second, 10*time.Millisecond)
+
+		res := get(t, ts, "/low")
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.Equal(t, "5", res.Header.Get("Retry-After"))
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/high").StatusCode)
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/critical").StatusCode)
+	})
+
+	t.Run("case=streams are counted separately from other requests", func(t *testing.T) {
+		ctx := context.Background()
+		conf, reg := internal.NewFastRegistryWithMocks(t)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingEnabled, true)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingLowInFlight, 1)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingHighInFlight, 1)
+		conf.MustSet(ctx, config.ViperKeyPublicLoadSheddingStreamsInFlight, 1)
+
+		block := make(chan struct{})
+		_, ts := newServer(t, reg, block)
+
+		done := make(chan *http.Response)
+		go func() {
+			res, err := ts.Client().Get(ts.URL + "/stream?block")
+			if err == nil {
+				_ = res.Body.Close()
+			}
+			done <- res
+		}()
+
+		require.EventuallyWithT(t, func(t *assert.CollectT) {
+			assert.Equal(t, http.StatusServiceUnavailable, get(t, ts, "/stream").StatusCode)
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// The open stream does not count against the in-flight budget of other requests.
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/low").StatusCode)
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/high").StatusCode)
+
+		close(block)
+		res := <-done
+		require.NotNil(t, res)
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.Equal(t, http.StatusNoContent, get(t, ts, "/stream").StatusCode)
+	})
+}
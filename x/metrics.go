@@ -0,0 +1,36 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var metricsHandler = promhttp.InstrumentMetricHandler(
+	prometheus.DefaultRegisterer,
+	promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}),
+)
+
+// ServeMetrics serves the Prometheus metrics. Scrapers which accept the OpenMetrics format also
+// receive the exemplars linking latency samples to traces.
+func ServeMetrics(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	metricsHandler.ServeHTTP(w, r)
+}
+
+// ObserveWithExemplar observes v and, if the span of ctx is sampled, attaches its trace ID as
+// exemplar.
+func ObserveWithExemplar(ctx context.Context, o prometheus.Observer, v float64) {
+	sc := trace.SpanContextFromContext(ctx)
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && sc.IsSampled() {
+		eo.ObserveWithExemplar(v, prometheus.Labels{"trace_id": sc.TraceID().String()})
+		return
+	}
+	o.Observe(v)
+}
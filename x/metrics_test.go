@@ -0,0 +1,59 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/ory/kratos/x"
+)
+
+func TestObserveWithExemplar(t *testing.T) {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kratos_test_observe_with_exemplar_seconds",
+		Buckets: []float64{1, 2},
+	}, []string{"sampled"})
+	prometheus.MustRegister(h)
+	t.Cleanup(func() { prometheus.Unregister(h) })
+
+	sampled, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "test")
+	defer span.End()
+	x.ObserveWithExemplar(sampled, h.WithLabelValues("true"), 0.1)
+
+	notSampled, notSampledSpan := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())).Tracer("test").Start(context.Background(), "test")
+	defer notSampledSpan.End()
+	x.ObserveWithExemplar(notSampled, h.WithLabelValues("false"), 0.1)
+
+	scrape := func(t *testing.T, accept string) string {
+		r := httptest.NewRequest("GET", "/metrics/prometheus", nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		x.ServeMetrics(w, r, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		body, err := io.ReadAll(w.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	t.Run("case=exposes exemplars in the OpenMetrics format", func(t *testing.T) {
+		body := scrape(t, "application/openmetrics-text; version=0.0.1")
+		assert.Contains(t, body, `kratos_test_observe_with_exemplar_seconds_bucket{sampled="true",le="1.0"} 1 # {trace_id="`+span.SpanContext().TraceID().String()+`"} 0.1`)
+		assert.Contains(t, body, `kratos_test_observe_with_exemplar_seconds_bucket{sampled="false",le="1.0"} 1`+"\n")
+	})
+
+	t.Run("case=serves the text format by default", func(t *testing.T) {
+		body := scrape(t, "text/plain")
+		assert.Contains(t, body, `kratos_test_observe_with_exemplar_seconds_bucket{sampled="true",le="1"} 1`+"\n")
+		assert.NotContains(t, body, "trace_id")
+	})
+}
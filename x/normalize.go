@@ -8,6 +8,8 @@ import (
 
 	"github.com/nyaruka/phonenumbers"
 	"github.com/pkg/errors"
+
+	"github.com/ory/kratos/driver/config"
 )
 
 // NormalizeEmailIdentifier normalizes an email address.
@@ -18,9 +20,35 @@ func NormalizeEmailIdentifier(value string) string {
 	return value
 }
 
-// NormalizePhoneIdentifier normalizes a phone number.
-func NormalizePhoneIdentifier(value string) string {
-	if number, err := phonenumbers.Parse(value, ""); err == nil && phonenumbers.IsValidNumber(number) {
+// CanonicalizeEmailIdentifier normalizes an email address and additionally applies the configured
+// rules, which map different spellings of the same mailbox to the same identifier. Values which are
+// not email addresses are returned as is.
+func CanonicalizeEmailIdentifier(value string, c *config.EmailNormalization) string {
+	value = NormalizeEmailIdentifier(value)
+
+	at := strings.LastIndex(value, "@")
+	if at < 1 {
+		return value
+	}
+
+	local, domain := value[:at], value[at+1:]
+	if c.StripPlusAlias {
+		if plus := strings.Index(local, "+"); plus > 0 {
+			local = local[:plus]
+		}
+	}
+	if c.StripGmailDots && (domain == "gmail.com" || domain == "googlemail.com") {
+		local, domain = strings.ReplaceAll(local, ".", ""), "gmail.com"
+	}
+
+	return local + "@" + domain
+}
+
+// NormalizePhoneIdentifier normalizes a phone number to E.164. Numbers in national format are
+// parsed as numbers of the default region, an ISO 3166-1 alpha-2 code. Without a default region,
+// only numbers in international format are normalized.
+func NormalizePhoneIdentifier(value, defaultRegion string) string {
+	if number, err := phonenumbers.Parse(value, defaultRegion); err == nil && phonenumbers.IsValidNumber(number) {
 		value = phonenumbers.Format(number, phonenumbers.E164)
 	}
 	return value
@@ -38,8 +66,10 @@ func NormalizeOtherIdentifier(value string) string {
 // - email
 // - phone
 // - username
-func GracefulNormalization(value string) string {
-	if number, err := phonenumbers.Parse(value, ""); err == nil && phonenumbers.IsValidNumber(number) {
+//
+// Phone numbers in national format are parsed as numbers of the default region.
+func GracefulNormalization(value, defaultRegion string) string {
+	if number, err := phonenumbers.Parse(value, defaultRegion); err == nil && phonenumbers.IsValidNumber(number) {
 		return phonenumbers.Format(number, phonenumbers.E164)
 	} else if strings.Contains(value, "@") {
 		return NormalizeEmailIdentifier(value)
@@ -54,12 +84,14 @@ func GracefulNormalization(value string) string {
 // - email
 // - phone
 // - username
-func NormalizeIdentifier(value, format string) (string, error) {
+//
+// Phone numbers in national format are parsed as numbers of the default region.
+func NormalizeIdentifier(value, format, defaultRegion string) (string, error) {
 	switch format {
 	case "email":
 		return NormalizeEmailIdentifier(value), nil
 	case "sms":
-		number, err := phonenumbers.Parse(value, "")
+		number, err := phonenumbers.Parse(value, defaultRegion)
 		if err != nil {
 			return "", err
 		}
@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/kratos/driver/config"
 )
 
 func TestNormalizeEmailIdentifier(t *testing.T) {
@@ -24,18 +26,43 @@ func TestNormalizeEmailIdentifier(t *testing.T) {
 	}
 }
 
+func TestCanonicalizeEmailIdentifier(t *testing.T) {
+	all := &config.EmailNormalization{StripPlusAlias: true, StripGmailDots: true}
+	tests := []struct {
+		input    string
+		c        *config.EmailNormalization
+		expected string
+	}{
+		{" John.Doe+news@GMAIL.com ", &config.EmailNormalization{}, "john.doe+news@gmail.com"},
+		{"john.doe+news@gmail.com", &config.EmailNormalization{StripPlusAlias: true}, "john.doe@gmail.com"},
+		{"john.doe+news@gmail.com", &config.EmailNormalization{StripGmailDots: true}, "johndoe+news@gmail.com"},
+		{"john.doe+news@googlemail.com", all, "johndoe@gmail.com"},
+		{"john.doe+news@example.org", all, "john.doe@example.org"},
+		{"+news@example.org", all, "+news@example.org"},
+		{"invalid-email", all, "invalid-email"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, CanonicalizeEmailIdentifier(test.input, test.c), test.input)
+	}
+}
+
 func TestNormalizePhoneIdentifier(t *testing.T) {
 	tests := []struct {
 		input    string
+		region   string
 		expected string
 	}{
-		{"+1 650-253-0000", "+16502530000"},
-		{"+1 (650) 253-0000", "+16502530000"},
-		{"invalid-phone", "invalid-phone"},
+		{"+1 650-253-0000", "", "+16502530000"},
+		{"+1 (650) 253-0000", "", "+16502530000"},
+		{"+1 650-253-0000", "DE", "+16502530000"},
+		{"0170 1234567", "DE", "+491701234567"},
+		{"0170 1234567", "", "0170 1234567"},
+		{"invalid-phone", "", "invalid-phone"},
 	}
 
 	for _, test := range tests {
-		assert.Equal(t, test.expected, NormalizePhoneIdentifier(test.input))
+		assert.Equal(t, test.expected, NormalizePhoneIdentifier(test.input, test.region), test.input)
 	}
 }
 
@@ -57,16 +84,18 @@ func TestNormalizeOtherIdentifier(t *testing.T) {
 func TestGracefulNormalization(t *testing.T) {
 	tests := []struct {
 		input    string
+		region   string
 		expected string
 	}{
-		{"+1 650-253-0000", "+16502530000"},
-		{"  EXAMPLE@DOMAIN.COM  ", "example@domain.com"},
-		{"  username  ", "username"},
-		{"invalid-phone", "invalid-phone"},
+		{"+1 650-253-0000", "", "+16502530000"},
+		{"0170 1234567", "DE", "+491701234567"},
+		{"  EXAMPLE@DOMAIN.COM  ", "DE", "example@domain.com"},
+		{"  username  ", "DE", "username"},
+		{"invalid-phone", "", "invalid-phone"},
 	}
 
 	for _, test := range tests {
-		assert.Equal(t, test.expected, GracefulNormalization(test.input))
+		assert.Equal(t, test.expected, GracefulNormalization(test.input, test.region), test.input)
 	}
 }
 
@@ -74,17 +103,20 @@ func TestNormalizeIdentifier(t *testing.T) {
 	tests := []struct {
 		input    string
 		format   string
+		region   string
 		expected string
 		err      bool
 	}{
-		{"  EXAMPLE@DOMAIN.COM  ", "email", "example@domain.com", false},
-		{"+1 650-253-0000", "sms", "+16502530000", false},
-		{"  username  ", "username", "username", false},
-		{"invalid-phone", "sms", "", true},
+		{"  EXAMPLE@DOMAIN.COM  ", "email", "", "example@domain.com", false},
+		{"+1 650-253-0000", "sms", "", "+16502530000", false},
+		{"0170 1234567", "sms", "DE", "+491701234567", false},
+		{"0170 1234567", "sms", "", "", true},
+		{"  username  ", "username", "", "username", false},
+		{"invalid-phone", "sms", "", "", true},
 	}
 
 	for _, test := range tests {
-		result, err := NormalizeIdentifier(test.input, test.format)
+		result, err := NormalizeIdentifier(test.input, test.format, test.region)
 		if test.err {
 			assert.Error(t, err)
 		} else {
@@ -140,11 +140,14 @@ func NosurfBaseCookieHandler(reg interface {
 	return func(w http.ResponseWriter, r *http.Request) http.Cookie {
 		secure := reg.Config().CookieSecure(r.Context())
 
-		sameSite := reg.Config().CookieSameSiteMode(r.Context())
+		sameSite := reg.Config().CSRFCookieSameSiteMode(r.Context())
 		if !secure {
 			sameSite = http.SameSiteLaxMode
 		}
 
+		// Partitioned cookies (CHIPS) must be secure, otherwise browsers reject them.
+		partitioned := secure && reg.Config().CSRFCookiePartitioned(r.Context())
+
 		domain := ""
 		if d := reg.Config().CookieDomain(r.Context()); d != "" {
 			domain = d
@@ -152,13 +155,14 @@ func NosurfBaseCookieHandler(reg interface {
 
 		name := CSRFCookieName(reg, r)
 		cookie := http.Cookie{
-			Name:     name,
-			MaxAge:   nosurf.MaxAge,
-			Path:     reg.Config().CookiePath(r.Context()),
-			Domain:   domain,
-			HttpOnly: true,
-			Secure:   secure,
-			SameSite: sameSite,
+			Name:        name,
+			MaxAge:      nosurf.MaxAge,
+			Path:        reg.Config().CookiePath(r.Context()),
+			Domain:      domain,
+			HttpOnly:    true,
+			Secure:      secure,
+			SameSite:    sameSite,
+			Partitioned: partitioned,
 		}
 
 		if alias := reg.Config().SelfPublicURL(r.Context()); reg.Config().SelfPublicURL(r.Context()).String() != alias.String() {
@@ -56,6 +56,21 @@ func TestNosurfBaseCookieHandler(t *testing.T) {
 	assert.EqualValues(t, http.SameSiteNoneMode, cookie.SameSite, "can be none because https/secure is true")
 	assert.True(t, cookie.Secure, "true because secure mode")
 	assert.True(t, cookie.HttpOnly)
+	assert.False(t, cookie.Partitioned, "not partitioned by default")
+
+	require.NoError(t, conf.Set(ctx, config.ViperKeySecurityCSRFCookieSameSite, "Strict"))
+	cookie = x.NosurfBaseCookieHandler(reg)(httptest.NewRecorder(), httptest.NewRequest("GET", "https://foo/bar", nil))
+	assert.EqualValues(t, http.SameSiteStrictMode, cookie.SameSite, "the anti-CSRF same site mode overrides the cookie same site mode")
+
+	require.NoError(t, conf.Set(ctx, config.ViperKeySecurityCSRFCookieSameSite, "None"))
+	require.NoError(t, conf.Set(ctx, config.ViperKeySecurityCSRFCookiePartitioned, true))
+	cookie = x.NosurfBaseCookieHandler(reg)(httptest.NewRecorder(), httptest.NewRequest("GET", "https://foo/bar", nil))
+	assert.EqualValues(t, http.SameSiteNoneMode, cookie.SameSite)
+	assert.True(t, cookie.Partitioned, "partitioned because secure mode")
+
+	require.NoError(t, conf.Set(ctx, "dev", true))
+	cookie = x.NosurfBaseCookieHandler(reg)(httptest.NewRecorder(), httptest.NewRequest("GET", "https://foo/bar", nil))
+	assert.False(t, cookie.Partitioned, "partitioned cookies must be secure")
 }
 
 func TestNosurfBaseCookieHandlerAliasing(t *testing.T) {
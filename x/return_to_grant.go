@@ -0,0 +1,92 @@
+// Copyright © 2024 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/x/jwksx"
+)
+
+// returnToGrantAlgorithms are the signing algorithms accepted for return_to grants. Symmetric
+// algorithms are excluded because the keys are public.
+var returnToGrantAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type (
+	returnToGrantDependencies interface {
+		config.Provider
+		HTTPClientProvider
+		JWKSFetchProvider
+	}
+
+	returnToGrantClaims struct {
+		jwt.RegisteredClaims
+		ReturnTo string `json:"return_to"`
+	}
+)
+
+// VerifyReturnToGrant verifies a return_to grant and returns the URL it allows. Grants are JSON
+// Web Tokens signed by a key of the configured JSON Web Key Set. They must expire and carry the
+// allowed URL in the `return_to` claim.
+func VerifyReturnToGrant(ctx context.Context, d returnToGrantDependencies, grant string) (*url.URL, error) {
+	conf, err := d.Config().SelfServiceBrowserReturnToGrants(ctx)
+	if err != nil {
+		return nil, err
+	} else if conf == nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("Signed return_to grants are not enabled."))
+	}
+
+	opts := []jwt.ParserOption{jwt.WithExpirationRequired(), jwt.WithValidMethods(returnToGrantAlgorithms)}
+	if conf.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(conf.Issuer))
+	}
+
+	var claims returnToGrantClaims
+	if _, err := jwt.ParseWithClaims(grant, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := d.JWKSFetcher().ResolveKey(
+			ctx,
+			conf.JWKSURL,
+			jwksx.WithForceKID(kid),
+			jwksx.WithCacheEnabled(),
+			jwksx.WithCacheTTL(time.Hour),
+			jwksx.WithHTTPClient(d.HTTPClient(ctx)))
+		if err != nil {
+			return nil, err
+		}
+
+		if key.Algorithm() != "" && key.Algorithm() != token.Method.Alg() {
+			return nil, errors.Errorf("the token is signed with algorithm %q but the key requires %q", token.Method.Alg(), key.Algorithm())
+		}
+
+		pub, err := key.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+
+		var raw interface{}
+		if err := pub.Raw(&raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	}, opts...); err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReasonf("The return_to grant is invalid: %s", err))
+	}
+
+	allowed, err := url.ParseRequestURI(claims.ReturnTo)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithWrap(err).WithReasonf("The return_to claim of the return_to grant is not a valid URL: %s", err))
+	} else if allowed.Host == "" || allowed.Host[:1] == "*" {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The return_to claim of the return_to grant must be an absolute URL without wildcards but got %q.", claims.ReturnTo))
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,59 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package secretref
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const backendTimeout = 10 * time.Second
+
+// DefaultBackends returns the built-in secret backends. They are configured using the
+// environment variables of the respective secret manager's tooling.
+func DefaultBackends() []Backend {
+	return []Backend{
+		NewVaultBackendFromEnv(),
+		NewAWSSecretsManagerBackendFromEnv(),
+		NewGCPSecretManagerBackendFromEnv(),
+	}
+}
+
+func httpClientOrDefault(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return &http.Client{Timeout: backendTimeout}
+}
+
+func doRequest(c *http.Client, req *http.Request) ([]byte, error) {
+	res, err := httpClientOrDefault(c).Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, errors.Errorf("secret backend responded with status code %d", res.StatusCode)
+	}
+
+	return body, nil
+}
+
+func newRequest(ctx context.Context, method, u string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return req, nil
+}
@@ -0,0 +1,161 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package secretref
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/x/stringsx"
+)
+
+// AWSSecretsManagerBackend resolves `awssm://<secret-id>[#<key>]` references using the AWS Secrets
+// Manager API. The secret ID can be the name or the ARN of the secret.
+type AWSSecretsManagerBackend struct {
+	// Endpoint overrides the regional Secrets Manager endpoint.
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+
+	now func() time.Time
+}
+
+var _ Backend = new(AWSSecretsManagerBackend)
+
+// NewAWSSecretsManagerBackendFromEnv configures the backend using the `AWS_REGION`,
+// `AWS_ACCESS_KEY_ID`, `AWS_SECRET_ACCESS_KEY`, `AWS_SESSION_TOKEN`, and
+// `AWS_ENDPOINT_URL_SECRETS_MANAGER` environment variables.
+func NewAWSSecretsManagerBackendFromEnv() *AWSSecretsManagerBackend {
+	return &AWSSecretsManagerBackend{
+		Endpoint:        os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER"),
+		Region:          stringsx.Coalesce(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+func (b *AWSSecretsManagerBackend) Scheme() string {
+	return "awssm"
+}
+
+func (b *AWSSecretsManagerBackend) Fetch(ctx context.Context, ref Reference) ([]byte, error) {
+	if b.Region == "" {
+		return nil, errors.New("the AWS region is not set, set the AWS_REGION environment variable")
+	} else if b.AccessKeyID == "" || b.SecretAccessKey == "" {
+		return nil, errors.New("the AWS credentials are not set, set the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables")
+	}
+
+	endpoint := stringsx.Coalesce(b.Endpoint, fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", b.Region))
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	payload, err := json.Marshal(map[string]string{"SecretId": ref.Path})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	req, err := newRequest(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	b.sign(req, u.Host, payload)
+
+	body, err := doRequest(b.Client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if s := gjson.GetBytes(body, "SecretString"); s.Exists() {
+		return []byte(s.String()), nil
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(gjson.GetBytes(body, "SecretBinary").String())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return secret, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to the request.
+func (b *AWSSecretsManagerBackend) sign(req *http.Request, host string, payload []byte) {
+	now := time.Now
+	if b.now != nil {
+		now = b.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	headers := map[string]string{
+		"content-type": req.Header.Get("Content-Type"),
+		"host":         host,
+		"x-amz-date":   amzDate,
+		"x-amz-target": req.Header.Get("X-Amz-Target"),
+	}
+	signed := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if b.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.SessionToken)
+		headers["x-amz-security-token"] = b.SessionToken
+		signed = []string{"content-type", "host", "x-amz-date", "x-amz-security-token", "x-amz-target"}
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signed {
+		canonicalHeaders.WriteString(h + ":" + headers[h] + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		http.MethodPost,
+		"/",
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + b.Region + "/secretsmanager/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.SecretAccessKey), date)
+	key = hmacSHA256(key, b.Region)
+	key = hmacSHA256(key, "secretsmanager")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign)),
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,96 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package secretref
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+// GCPSecretManagerBackend resolves `gcpsm://projects/<project>/secrets/<secret>[/versions/<version>][#<key>]`
+// references using the Google Cloud Secret Manager REST API. If no version is given, the latest
+// version is used.
+//
+// The access token is read from the `GOOGLE_OAUTH_ACCESS_TOKEN` environment variable or, if it is
+// not set, requested from the metadata server of the workload's service account.
+type GCPSecretManagerBackend struct {
+	Endpoint         string
+	MetadataEndpoint string
+	AccessToken      string
+	Client           *http.Client
+}
+
+var _ Backend = new(GCPSecretManagerBackend)
+
+func NewGCPSecretManagerBackendFromEnv() *GCPSecretManagerBackend {
+	return &GCPSecretManagerBackend{
+		Endpoint:         "https://secretmanager.googleapis.com",
+		MetadataEndpoint: "http://metadata.google.internal",
+		AccessToken:      os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+	}
+}
+
+func (b *GCPSecretManagerBackend) Scheme() string {
+	return "gcpsm"
+}
+
+func (b *GCPSecretManagerBackend) Fetch(ctx context.Context, ref Reference) ([]byte, error) {
+	name := strings.Trim(ref.Path, "/")
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/secrets/") {
+		return nil, errors.New("the reference must have the format gcpsm://projects/<project>/secrets/<secret>")
+	} else if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newRequest(ctx, http.MethodGet, strings.TrimRight(b.Endpoint, "/")+"/v1/"+name+":access", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	body, err := doRequest(b.Client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(gjson.GetBytes(body, "payload.data").String())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return secret, nil
+}
+
+func (b *GCPSecretManagerBackend) accessToken(ctx context.Context) (string, error) {
+	if b.AccessToken != "" {
+		return b.AccessToken, nil
+	}
+
+	req, err := newRequest(ctx, http.MethodGet, strings.TrimRight(b.MetadataEndpoint, "/")+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	body, err := doRequest(b.Client, req)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to request an access token from the metadata server")
+	}
+
+	token := gjson.GetBytes(body, "access_token").String()
+	if token == "" {
+		return "", errors.New("the metadata server did not return an access token")
+	}
+	return token, nil
+}
@@ -0,0 +1,68 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package secretref
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+// VaultBackend resolves `vault://<path>#<key>` references using the HashiCorp Vault HTTP API.
+// Both the KV version 1 and version 2 secrets engines are supported. For the version 2 engine
+// the path must include the `data` segment, for example `vault://secret/data/kratos#smtp_password`.
+type VaultBackend struct {
+	Address   string
+	Token     string
+	Namespace string
+	Client    *http.Client
+}
+
+var _ Backend = new(VaultBackend)
+
+// NewVaultBackendFromEnv configures the backend using the `VAULT_ADDR`, `VAULT_TOKEN`, and
+// `VAULT_NAMESPACE` environment variables.
+func NewVaultBackendFromEnv() *VaultBackend {
+	return &VaultBackend{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+}
+
+func (b *VaultBackend) Scheme() string {
+	return "vault"
+}
+
+func (b *VaultBackend) Fetch(ctx context.Context, ref Reference) ([]byte, error) {
+	if b.Address == "" {
+		return nil, errors.New("the Vault address is not set, set the VAULT_ADDR environment variable")
+	} else if ref.Key == "" {
+		return nil, errors.New("the reference must select a key of the Vault secret using the URI fragment")
+	}
+
+	req, err := newRequest(ctx, http.MethodGet, strings.TrimRight(b.Address, "/")+"/v1/"+strings.TrimLeft(ref.Path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", b.Token)
+	if b.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.Namespace)
+	}
+
+	body, err := doRequest(b.Client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The KV version 2 secrets engine nests the secret in another data object.
+	if data := gjson.GetBytes(body, "data.data"); data.IsObject() && gjson.GetBytes(body, "data.metadata").IsObject() {
+		return []byte(data.Raw), nil
+	}
+	return []byte(gjson.GetBytes(body, "data").Raw), nil
+}
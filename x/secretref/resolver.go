@@ -0,0 +1,155 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package secretref
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x"
+	"github.com/ory/x/otelx"
+)
+
+type (
+	// Reference points to a secret stored in an external secret backend, for example
+	// `vault://secret/data/kratos#smtp_password`.
+	Reference struct {
+		// Scheme selects the secret backend.
+		Scheme string
+
+		// Path identifies the secret in the secret backend.
+		Path string
+
+		// Key is the optional fragment of the reference. If set, the secret is parsed as a JSON
+		// object and the value of the key is used.
+		Key string
+	}
+
+	// Backend fetches secrets from an external secret manager.
+	Backend interface {
+		// Scheme returns the URI scheme of references resolved by this backend.
+		Scheme() string
+
+		// Fetch returns the raw secret the reference points to.
+		Fetch(ctx context.Context, ref Reference) ([]byte, error)
+	}
+
+	dependencies interface {
+		config.Provider
+		x.LoggingProvider
+		x.TracingProvider
+	}
+
+	// Resolver resolves configuration values which reference secrets in external secret
+	// backends. Resolved secrets are cached and fetched again after the configured refresh
+	// interval, which allows rotating secrets without restarting Ory Kratos.
+	Resolver struct {
+		d        dependencies
+		backends map[string]Backend
+
+		sync.Mutex
+		cache map[string]cachedSecret
+	}
+	Provider interface {
+		SecretResolver() *Resolver
+	}
+
+	cachedSecret struct {
+		value     string
+		fetchedAt time.Time
+	}
+)
+
+func NewResolver(d dependencies, backends ...Backend) *Resolver {
+	r := &Resolver{
+		d:        d,
+		backends: make(map[string]Backend, len(backends)),
+		cache:    make(map[string]cachedSecret),
+	}
+	for _, b := range backends {
+		r.backends[b.Scheme()] = b
+	}
+	return r
+}
+
+// ParseReference parses the value into a reference if it uses the scheme of one of the
+// backends.
+func (r *Resolver) ParseReference(value string) (Reference, bool) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		return Reference{}, false
+	} else if _, ok := r.backends[scheme]; !ok {
+		return Reference{}, false
+	}
+
+	path, key, _ := strings.Cut(rest, "#")
+	return Reference{Scheme: scheme, Path: path, Key: key}, true
+}
+
+// Resolve returns the secret the value references. Values which are not secret references
+// are returned as they are.
+//
+// If the secret can not be fetched again after the refresh interval, the previously fetched
+// secret is returned and the error is logged.
+func (r *Resolver) Resolve(ctx context.Context, value string) (_ string, err error) {
+	ref, ok := r.ParseReference(value)
+	if !ok {
+		return value, nil
+	}
+
+	ctx, span := r.d.Tracer(ctx).Tracer().Start(ctx, "secretref.Resolver.Resolve")
+	defer otelx.End(span, &err)
+
+	r.Lock()
+	cached, found := r.cache[value]
+	r.Unlock()
+
+	if found && time.Since(cached.fetchedAt) < r.d.Config().SecretBackendsRefreshInterval(ctx) {
+		return cached.value, nil
+	}
+
+	secret, err := r.fetch(ctx, ref)
+	if err != nil {
+		if found {
+			r.d.Logger().
+				WithError(err).
+				WithField("secret_backend", ref.Scheme).
+				WithField("secret_path", ref.Path).
+				Warn("Unable to refresh the secret from the secret backend, using the previously fetched secret.")
+			return cached.value, nil
+		}
+		return "", err
+	}
+
+	r.Lock()
+	r.cache[value] = cachedSecret{value: secret, fetchedAt: time.Now()}
+	r.Unlock()
+
+	return secret, nil
+}
+
+func (r *Resolver) fetch(ctx context.Context, ref Reference) (string, error) {
+	raw, err := r.backends[ref.Scheme].Fetch(ctx, ref)
+	if err != nil {
+		return "", errors.WithStack(herodot.ErrInternalServerError.WithWrap(err).WithReasonf("Unable to fetch secret %q from the %s secret backend: %s", ref.Path, ref.Scheme, err))
+	}
+
+	if ref.Key == "" {
+		return string(raw), nil
+	}
+
+	value := gjson.GetBytes(raw, gjson.Escape(ref.Key))
+	if !value.Exists() || value.Type != gjson.String {
+		return "", errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Secret %q in the %s secret backend does not contain the string key %q.", ref.Path, ref.Scheme, ref.Key))
+	}
+
+	return value.String(), nil
+}
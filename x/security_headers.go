@@ -0,0 +1,96 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ory/kratos/driver/config"
+)
+
+type (
+	securityHeadersDependencies interface {
+		config.Provider
+		LoggingProvider
+	}
+
+	// SecurityHeaders is a middleware which sets security headers, such as HSTS, on all
+	// responses. Routes can override the headers, for example to allow cross-origin use of
+	// a script.
+	SecurityHeaders struct {
+		d      securityHeadersDependencies
+		iface  string
+		routes []config.SecurityHeadersRoute
+	}
+)
+
+// NewSecurityHeaders creates the middleware for the public or admin endpoint. The given routes
+// are applied before the routes from the configuration.
+func NewSecurityHeaders(d securityHeadersDependencies, iface string, routes ...config.SecurityHeadersRoute) *SecurityHeaders {
+	return &SecurityHeaders{d: d, iface: iface, routes: routes}
+}
+
+func (s *SecurityHeaders) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	conf, err := s.d.Config().SecurityHeaders(r.Context(), s.iface)
+	if err != nil {
+		s.d.Logger().WithError(err).Error("Unable to load the security headers route configuration.")
+	}
+	if conf == nil || !conf.Enabled {
+		next(w, r)
+		return
+	}
+
+	h := w.Header()
+	set := func(key, value string) {
+		if value != "" {
+			h.Set(key, value)
+		}
+	}
+
+	// Browsers ignore HSTS on plain HTTP, and sending it from a local development setup
+	// would only be confusing.
+	if hsts := conf.StrictTransportSecurity; hsts.Enabled && RequestURL(r).Scheme == "https" {
+		value := fmt.Sprintf("max-age=%d", int64(hsts.MaxAge.Seconds()))
+		if hsts.IncludeSubdomains {
+			value += "; includeSubDomains"
+		}
+		if hsts.Preload {
+			value += "; preload"
+		}
+		h.Set("Strict-Transport-Security", value)
+	}
+	set("X-Content-Type-Options", conf.ContentTypeOptions)
+	set("X-Frame-Options", conf.FrameOptions)
+	set("Referrer-Policy", conf.ReferrerPolicy)
+	set("Cross-Origin-Opener-Policy", conf.CrossOriginOpenerPolicy)
+	set("Cross-Origin-Embedder-Policy", conf.CrossOriginEmbedderPolicy)
+	set("Cross-Origin-Resource-Policy", conf.CrossOriginResourcePolicy)
+
+	for _, routes := range [][]config.SecurityHeadersRoute{s.routes, conf.Routes} {
+		for _, route := range routes {
+			if !matchesRoute(route.Path, r.URL.Path) {
+				continue
+			}
+			for key, value := range route.Headers {
+				if value == "" {
+					h.Del(key)
+				} else {
+					h.Set(key, value)
+				}
+			}
+		}
+	}
+
+	next(w, r)
+}
+
+// matchesRoute matches the path exactly, or as a prefix if the pattern ends with `*`.
+func matchesRoute(pattern, path string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return pattern == path
+}
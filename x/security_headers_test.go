@@ -0,0 +1,98 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/internal"
+	"github.com/ory/kratos/x"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	ctx := context.Background()
+	conf, reg := internal.NewFastRegistryWithMocks(t)
+
+	n := negroni.New(x.NewSecurityHeaders(reg, "public", config.SecurityHeadersRoute{
+		Path:    "/script.js",
+		Headers: map[string]string{"Cross-Origin-Resource-Policy": "cross-origin"},
+	}))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	get := func(t *testing.T, path string, header http.Header) http.Header {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		return rec.Header()
+	}
+
+	t.Run("case=sets default headers", func(t *testing.T) {
+		h := get(t, "/", nil)
+		assert.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", h.Get("X-Frame-Options"))
+		assert.Equal(t, "strict-origin-when-cross-origin", h.Get("Referrer-Policy"))
+		assert.Empty(t, h.Get("Cross-Origin-Opener-Policy"))
+		assert.Empty(t, h.Get("Strict-Transport-Security"), "HSTS is not sent over plain HTTP")
+	})
+
+	t.Run("case=sends HSTS over HTTPS", func(t *testing.T) {
+		h := get(t, "/", http.Header{"X-Forwarded-Proto": {"https"}})
+		assert.Equal(t, "max-age=31536000", h.Get("Strict-Transport-Security"))
+
+		conf.MustSet(ctx, "serve.public.security_headers.strict_transport_security.include_subdomains", true)
+		conf.MustSet(ctx, "serve.public.security_headers.strict_transport_security.preload", true)
+		t.Cleanup(func() {
+			conf.MustSet(ctx, "serve.public.security_headers.strict_transport_security.include_subdomains", false)
+			conf.MustSet(ctx, "serve.public.security_headers.strict_transport_security.preload", false)
+		})
+
+		h = get(t, "/", http.Header{"X-Forwarded-Proto": {"https"}})
+		assert.Equal(t, "max-age=31536000; includeSubDomains; preload", h.Get("Strict-Transport-Security"))
+	})
+
+	t.Run("case=applies built-in and configured route overrides", func(t *testing.T) {
+		assert.Equal(t, "cross-origin", get(t, "/script.js", nil).Get("Cross-Origin-Resource-Policy"))
+
+		conf.MustSet(ctx, "serve.public.security_headers.cross_origin_opener_policy", "same-origin")
+		conf.MustSet(ctx, "serve.public.security_headers.routes", []map[string]any{
+			{"path": "/self-service/*", "headers": map[string]any{"X-Frame-Options": "", "Cross-Origin-Opener-Policy": "same-origin-allow-popups"}},
+		})
+		t.Cleanup(func() {
+			conf.MustSet(ctx, "serve.public.security_headers.cross_origin_opener_policy", "")
+			conf.MustSet(ctx, "serve.public.security_headers.routes", []map[string]any{})
+		})
+
+		h := get(t, "/self-service/login/browser", nil)
+		assert.Empty(t, h.Get("X-Frame-Options"))
+		assert.Equal(t, "same-origin-allow-popups", h.Get("Cross-Origin-Opener-Policy"))
+
+		h = get(t, "/sessions/whoami", nil)
+		assert.Equal(t, "DENY", h.Get("X-Frame-Options"))
+		assert.Equal(t, "same-origin", h.Get("Cross-Origin-Opener-Policy"))
+	})
+
+	t.Run("case=can be disabled", func(t *testing.T) {
+		conf.MustSet(ctx, "serve.public.security_headers.enabled", false)
+		t.Cleanup(func() { conf.MustSet(ctx, "serve.public.security_headers.enabled", true) })
+
+		h := get(t, "/", http.Header{"X-Forwarded-Proto": {"https"}})
+		assert.Empty(t, h.Get("X-Content-Type-Options"))
+		assert.Empty(t, h.Get("Strict-Transport-Security"))
+	})
+}
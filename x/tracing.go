@@ -0,0 +1,60 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/luna-duclos/instrumentedsql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	otelsql "github.com/ory/x/otelx/sql"
+)
+
+// AttributeKeySQLQueryLabel labels SQL spans with the persister operation which issued the query.
+const AttributeKeySQLQueryLabel = "db.query.label"
+
+// InjectTraceContext adds the trace context and baggage of ctx to the headers of an outgoing
+// request, so that the receiver can continue the trace.
+func InjectTraceContext(ctx context.Context, h http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+type (
+	sqlTracer struct {
+		instrumentedsql.Tracer
+	}
+	sqlSpan struct {
+		instrumentedsql.Span
+		label string
+	}
+)
+
+// NewSQLTracer returns a tracer for the instrumented SQL driver. In addition to the spans of
+// the otelx SQL tracer, it labels every SQL span with the name of the enclosing persister span
+// (e.g. `persistence.sql.GetLoginFlow`), so that slow queries can be attributed without
+// recording the query arguments.
+func NewSQLTracer() instrumentedsql.Tracer {
+	return sqlTracer{Tracer: otelsql.NewTracer()}
+}
+
+func (t sqlTracer) GetSpan(ctx context.Context) instrumentedsql.Span {
+	s := sqlSpan{Span: t.Tracer.GetSpan(ctx)}
+	if named, ok := trace.SpanFromContext(ctx).(interface{ Name() string }); ok && strings.HasPrefix(named.Name(), "persistence.") {
+		s.label = named.Name()
+	}
+	return s
+}
+
+func (s sqlSpan) NewChild(name string) instrumentedsql.Span {
+	child := sqlSpan{Span: s.Span.NewChild(name), label: s.label}
+	if s.label != "" {
+		child.SetLabel(AttributeKeySQLQueryLabel, s.label)
+	}
+	return child
+}
@@ -0,0 +1,79 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package x_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ory/kratos/x"
+)
+
+func spanAttribute(s sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, a := range s.Attributes() {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestSQLTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	query := func(ctx context.Context) {
+		conn := x.NewSQLTracer().GetSpan(ctx).NewChild("sql-conn-query")
+		conn.NewChild("sql-rows-close").Finish()
+		conn.Finish()
+	}
+
+	ctx, span := tracer.Start(context.Background(), "persistence.sql.GetLoginFlow")
+	query(ctx)
+	span.End()
+
+	ctx, span = tracer.Start(context.Background(), "selfservice.flow.login.Strategy")
+	query(ctx)
+	span.End()
+
+	labels := map[string][]string{}
+	for _, s := range recorder.Ended() {
+		label, _ := spanAttribute(s, x.AttributeKeySQLQueryLabel)
+		labels[s.Name()] = append(labels[s.Name()], label.AsString())
+	}
+	assert.Equal(t, []string{"persistence.sql.GetLoginFlow", ""}, labels["sql-conn-query"])
+	assert.Equal(t, []string{"persistence.sql.GetLoginFlow", ""}, labels["sql-rows-close"])
+	assert.Equal(t, []string{""}, labels["persistence.sql.GetLoginFlow"], "the persister span itself is not labelled")
+}
+
+func TestInjectTraceContext(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	tracer := sdktrace.NewTracerProvider().Tracer("test")
+	member, err := baggage.NewMember("tenant", "acme")
+	require.NoError(t, err)
+	b, err := baggage.New(member)
+	require.NoError(t, err)
+
+	ctx, span := tracer.Start(baggage.ContextWithBaggage(context.Background(), b), "test")
+	defer span.End()
+
+	h := http.Header{}
+	x.InjectTraceContext(ctx, h)
+
+	assert.Contains(t, h.Get("traceparent"), span.SpanContext().TraceID().String())
+	assert.Equal(t, "tenant=acme", h.Get("baggage"))
+}
@@ -0,0 +1,140 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package webauthnx
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/go-webauthn/webauthn/metadata"
+	"github.com/go-webauthn/webauthn/metadata/providers/cached"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/schema"
+	"github.com/ory/kratos/x"
+)
+
+type (
+	MetadataLoaderProvider interface {
+		WebAuthnMetadataLoader() *MetadataLoader
+	}
+
+	attestationDependencies interface {
+		x.LoggingProvider
+		MetadataLoaderProvider
+	}
+
+	// MetadataLoader loads the FIDO Metadata Service (MDS) BLOB. The BLOB is cached in a file and only
+	// downloaded again once it is outdated.
+	MetadataLoader struct {
+		mu        sync.Mutex
+		client    *http.Client
+		providers map[string]metadata.Provider
+	}
+)
+
+func NewMetadataLoader(client *http.Client) *MetadataLoader {
+	return &MetadataLoader{client: client, providers: map[string]metadata.Provider{}}
+}
+
+// Provider returns the metadata provider backed by the BLOB cached at path.
+func (l *MetadataLoader) Provider(path string) (metadata.Provider, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if p, ok := l.providers[path]; ok {
+		return p, nil
+	}
+
+	p, err := cached.New(cached.WithPath(path), cached.WithClient(l.client))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	l.providers[path] = p
+	return p, nil
+}
+
+// CreateCredential verifies the registration response like webauthn.WebAuthn.CreateCredential and
+// additionally enforces the attestation policy. If the policy rejects the authenticator, a validation
+// error pointing to instancePtr is returned.
+func CreateCredential(ctx context.Context, d attestationDependencies, web *webauthn.WebAuthn, policy *config.WebAuthnAttestation, instancePtr string, user webauthn.User, session webauthn.SessionData, response *protocol.ParsedCredentialCreationData) (*webauthn.Credential, error) {
+	if policy.MDSEnabled {
+		mds, err := d.WebAuthnMetadataLoader().Provider(policy.MDSCachePath)
+		if err != nil {
+			return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to load the FIDO Metadata Service BLOB.").WithDebug(err.Error()))
+		}
+		web.Config.MDS = mds
+	}
+
+	credential, err := web.CreateCredential(user, session, response)
+	if err != nil {
+		devErr := new(protocol.Error)
+		isProtocolErr := errors.As(err, &devErr)
+		if isProtocolErr {
+			d.Logger().WithError(err).WithField("error_devinfo", devErr.DevInfo).Error("Failed to create WebAuthn credential")
+		}
+		if isProtocolErr && policy.Enabled() && devErr.Type == protocol.ErrInvalidAttestation.Type {
+			return nil, schema.NewWebAuthnAuthenticatorNotAllowedError(instancePtr)
+		}
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to create WebAuthn credential: %s", err))
+	}
+
+	if !AuthenticatorAllowed(policy, credential) {
+		d.Logger().
+			WithField("aaguid", aaguidString(credential.Authenticator.AAGUID)).
+			WithField("attestation_type", credential.AttestationType).
+			WithContext(ctx).
+			Info("Rejected WebAuthn authenticator because of the attestation policy.")
+		return nil, schema.NewWebAuthnAuthenticatorNotAllowedError(instancePtr)
+	}
+
+	return credential, nil
+}
+
+// AuthenticatorAllowed returns true if the attestation policy allows the authenticator which created the
+// credential.
+func AuthenticatorAllowed(policy *config.WebAuthnAttestation, credential *webauthn.Credential) bool {
+	// Without an attestation statement there is nothing the metadata service could vouch for.
+	if policy.MDSEnabled && protocol.AttestationFormat(credential.AttestationType) == protocol.AttestationFormatNone {
+		return false
+	}
+
+	aaguid, err := uuid.FromBytes(credential.Authenticator.AAGUID)
+	if err != nil {
+		aaguid = uuid.Nil
+	}
+
+	for _, denied := range policy.DeniedAAGUIDs {
+		if id, err := uuid.FromString(denied); err == nil && id == aaguid {
+			return false
+		}
+	}
+
+	if len(policy.AllowedAAGUIDs) == 0 {
+		return true
+	}
+
+	for _, allowed := range policy.AllowedAAGUIDs {
+		if id, err := uuid.FromString(allowed); err == nil && id == aaguid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func aaguidString(id []byte) string {
+	aaguid, err := uuid.FromBytes(id)
+	if err != nil {
+		return ""
+	}
+	return aaguid.String()
+}
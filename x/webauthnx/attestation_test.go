@@ -0,0 +1,71 @@
+// Copyright © 2023 Ory Corp
+// SPDX-License-Identifier: Apache-2.0
+
+package webauthnx_test
+
+import (
+	"testing"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ory/kratos/driver/config"
+	"github.com/ory/kratos/x/webauthnx"
+)
+
+func TestAuthenticatorAllowed(t *testing.T) {
+	yubikey := uuid.Must(uuid.FromString("ee882879-721c-4913-9775-3dfcce97072a"))
+	other := uuid.Must(uuid.NewV4())
+
+	credential := func(aaguid uuid.UUID, format string) *webauthn.Credential {
+		return &webauthn.Credential{
+			AttestationType: format,
+			Authenticator:   webauthn.Authenticator{AAGUID: aaguid.Bytes()},
+		}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		policy     config.WebAuthnAttestation
+		credential *webauthn.Credential
+		allowed    bool
+	}{
+		{
+			name:       "no policy",
+			credential: credential(uuid.Nil, "none"),
+			allowed:    true,
+		},
+		{
+			name:       "allow list matches",
+			policy:     config.WebAuthnAttestation{AllowedAAGUIDs: []string{"EE882879-721C-4913-9775-3DFCCE97072A"}},
+			credential: credential(yubikey, "packed"),
+			allowed:    true,
+		},
+		{
+			name:       "allow list does not match",
+			policy:     config.WebAuthnAttestation{AllowedAAGUIDs: []string{yubikey.String()}},
+			credential: credential(other, "packed"),
+		},
+		{
+			name:       "deny list matches",
+			policy:     config.WebAuthnAttestation{DeniedAAGUIDs: []string{yubikey.String()}},
+			credential: credential(yubikey, "packed"),
+		},
+		{
+			name:       "deny list does not match",
+			policy:     config.WebAuthnAttestation{DeniedAAGUIDs: []string{yubikey.String()}},
+			credential: credential(other, "packed"),
+			allowed:    true,
+		},
+		{
+			name:       "metadata service requires attestation",
+			policy:     config.WebAuthnAttestation{MDSEnabled: true},
+			credential: credential(yubikey, "none"),
+		},
+	} {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.allowed, webauthnx.AuthenticatorAllowed(&tc.policy, tc.credential))
+		})
+	}
+}
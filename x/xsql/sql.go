@@ -10,11 +10,16 @@ import (
 	"github.com/gobuffalo/pop/v6"
 
 	"github.com/ory/kratos/selfservice/errorx"
+	"github.com/ory/kratos/selfservice/flow/crossdevice"
+	"github.com/ory/kratos/selfservice/flow/funnel"
+	"github.com/ory/kratos/selfservice/hook/deadletter"
 	"github.com/ory/kratos/selfservice/sessiontokenexchange"
+	"github.com/ory/kratos/selfservice/sso"
 
 	"github.com/ory/kratos/continuity"
 	"github.com/ory/kratos/courier"
 	"github.com/ory/kratos/identity"
+	"github.com/ory/kratos/jobs"
 	"github.com/ory/kratos/selfservice/flow/login"
 	"github.com/ory/kratos/selfservice/flow/recovery"
 	"github.com/ory/kratos/selfservice/flow/registration"
@@ -23,6 +28,7 @@ import (
 	"github.com/ory/kratos/selfservice/strategy/code"
 	"github.com/ory/kratos/selfservice/strategy/link"
 	"github.com/ory/kratos/session"
+	"github.com/ory/kratos/x"
 )
 
 func CleanSQL(t testing.TB, c *pop.Connection) {
@@ -35,8 +41,13 @@ func CleanSQL(t testing.TB, c *pop.Connection) {
 		new(courier.Message).TableName(ctx),
 
 		new(session.Device).TableName(ctx),
+		new(session.UsedRevokeLink).TableName(ctx),
 		new(session.Session).TableName(ctx),
 		new(login.Flow).TableName(ctx),
+		new(crossdevice.Flow).TableName(ctx),
+		new(funnel.Entry).TableName(ctx),
+		new(sso.Connection).TableName(ctx),
+		new(deadletter.Event).TableName(ctx),
 		new(registration.Flow).TableName(ctx),
 		new(settings.Flow).TableName(ctx),
 
@@ -55,9 +66,12 @@ func CleanSQL(t testing.TB, c *pop.Connection) {
 		new(identity.Credentials).TableName(ctx),
 		new(identity.VerifiableAddress).TableName(ctx),
 		new(identity.RecoveryAddress).TableName(ctx),
+		new(identity.Webhook).TableName(ctx),
 		new(identity.Identity).TableName(ctx),
 		new(identity.CredentialsTypeTable).TableName(ctx),
 		new(sessiontokenexchange.Exchanger).TableName(),
+		new(x.IssuedAdminAPIToken).TableName(ctx),
+		new(jobs.Lease).TableName(ctx),
 		"networks",
 		"schema_migration",
 	} {